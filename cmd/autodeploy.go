@@ -3,13 +3,13 @@ package cmd
 import (
 	_ "embed"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
 	"lightfold/pkg/util"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
@@ -21,11 +21,11 @@ var (
 	autoDeployTarget    string
 	autoDeployNoConfirm bool
 
-	autoDeploySuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	autoDeployMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	autoDeployValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	autoDeployErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	autoDeployLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
+	autoDeploySuccessStyle = style.Success
+	autoDeployMutedStyle   = style.Muted
+	autoDeployValueStyle   = style.Value
+	autoDeployErrorStyle   = style.Error
+	autoDeployLabelStyle   = style.Header
 )
 
 var autoDeployCmd = &cobra.Command{
@@ -63,7 +63,7 @@ Examples:
 		if !util.IsGitRepository(projectPath) {
 			fmt.Fprintf(os.Stderr, "%s\n", autoDeployErrorStyle.Render("Error: Not a git repository"))
 			fmt.Fprintf(os.Stderr, "Initialize git first: git init\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		org, repo, err := util.GetGitHubRepo(projectPath)
@@ -71,7 +71,7 @@ Examples:
 			fmt.Fprintf(os.Stderr, "%s\n", autoDeployErrorStyle.Render("Error: Not a GitHub repository"))
 			fmt.Fprintf(os.Stderr, "Remote URL must be a GitHub repository.\n")
 			fmt.Fprintf(os.Stderr, "Details: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		workflowPath := filepath.Join(projectPath, ".github", "workflows", "lightfold-deploy.yml")
@@ -95,12 +95,12 @@ Examples:
 		workflowDir := filepath.Join(projectPath, ".github", "workflows")
 		if err := os.MkdirAll(workflowDir, 0755); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", autoDeployErrorStyle.Render(fmt.Sprintf("Error creating workflow directory: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if err := os.WriteFile(workflowPath, []byte(rendered), 0644); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", autoDeployErrorStyle.Render(fmt.Sprintf("Error writing workflow file: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		tokens, err := config.LoadTokens()
@@ -114,7 +114,7 @@ Examples:
 			providerToken = tokens.GetToken(providerName)
 		}
 
-		fmt.Printf("\n%s\n\n", autoDeploySuccessStyle.Render("✓ GitHub Actions workflow created!"))
+		fmt.Printf("\n%s\n\n", autoDeploySuccessStyle.Render(style.Check()+" GitHub Actions workflow created!"))
 
 		fmt.Printf("%s %s\n", autoDeployMutedStyle.Render("Location:"), autoDeployValueStyle.Render(workflowPath))
 		fmt.Printf("%s %s\n", autoDeployMutedStyle.Render("Branch:"), autoDeployValueStyle.Render(autoDeployBranch))
@@ -131,9 +131,9 @@ Examples:
 
 		fmt.Println(autoDeployMutedStyle.Render("2. Add GitHub secret via web UI:"))
 		githubSecretsURL := fmt.Sprintf("https://github.com/%s/%s/settings/secrets/actions/new", org, repo)
-		fmt.Printf("   • Go to: %s\n", autoDeployValueStyle.Render(githubSecretsURL))
-		fmt.Printf("   • Name: %s\n", autoDeployValueStyle.Render("PROVIDER_TOKEN"))
-		fmt.Printf("   • Value: (copy token below)\n\n")
+		fmt.Printf("   %s Go to: %s\n", style.Bullet(), autoDeployValueStyle.Render(githubSecretsURL))
+		fmt.Printf("   %s Name: %s\n", style.Bullet(), autoDeployValueStyle.Render("PROVIDER_TOKEN"))
+		fmt.Printf("   %s Value: (copy token below)\n\n", style.Bullet())
 
 		if providerToken != "" {
 			// Mask token (show last 8 characters)
@@ -144,7 +144,7 @@ Examples:
 			fmt.Printf("   %s %s: %s\n", autoDeployMutedStyle.Render("Your"), autoDeployValueStyle.Render(providerName), autoDeployMutedStyle.Render(maskedToken))
 			fmt.Printf("   %s %s\n\n", autoDeployMutedStyle.Render("Full token:"), providerToken)
 		} else {
-			fmt.Printf("   %s No token found for provider '%s'\n", autoDeployErrorStyle.Render("⚠"), providerName)
+			fmt.Printf("   %s No token found for provider '%s'\n", autoDeployErrorStyle.Render(style.Warn()), providerName)
 			fmt.Printf("   Run: lightfold config set-token %s\n\n", providerName)
 		}
 
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"os"
+	"path"
+	"strings"
+)
+
+// setBaseDir validates deploy.base_dir: an absolute directory other than /,
+// without characters the shell or systemd would split on
+func setBaseDir(t *config.TargetConfig, v string) error {
+	dir := path.Clean(strings.TrimSpace(v))
+	switch {
+	case v == "" || dir == config.RemoteAppBaseDir:
+		ensureDeploy(t).BaseDir = ""
+		return nil
+	case !path.IsAbs(dir) || dir == "/":
+		return fmt.Errorf("base directory must be an absolute path other than /, e.g. /opt/apps: %s", v)
+	case strings.ContainsAny(dir, "'\"$`\\ \t%;&|"):
+		return fmt.Errorf("base directory may not contain quotes, spaces or shell characters: %s", v)
+	}
+	ensureDeploy(t).BaseDir = dir
+	return nil
+}
+
+// checkBaseDir rejects a deploy.base_dir that differs from the directory the
+// target's app is already deployed under: the releases, shared files, env
+// and first-deploy marker would be left behind and the app deployed afresh
+func checkBaseDir(targetName string, target config.TargetConfig) error {
+	targetState, err := state.LoadState(targetName)
+	if err != nil || targetState.LastRelease == "" {
+		return nil
+	}
+	deployed := config.AppDir(targetState.BaseDir, "")
+	if configured := target.RemoteBaseDir(); configured != deployed {
+		return fmt.Errorf("target '%s' is deployed under %s, not deploy.base_dir %s; moving a deployed app is not supported. "+
+			"Set it back with 'lightfold config set --target %s deploy.base_dir=%s', or deploy to the new directory as a new target on a fresh server",
+			targetName, deployed, configured, targetName, deployed)
+	}
+	return nil
+}
+
+// exitIfBaseDirMoved stops a deploy whose deploy.base_dir no longer matches
+// where the app lives
+func exitIfBaseDirMoved(targetName string, target config.TargetConfig) {
+	if err := checkBaseDir(targetName, target); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
+
+// recordBaseDir saves where a deploy put the app, which checkBaseDir compares
+// against later changes
+func recordBaseDir(targetName string, target config.TargetConfig) {
+	if err := state.RecordBaseDir(targetName, target.RemoteBaseDir()); err != nil {
+		fmt.Printf("Warning: failed to record the base directory: %v\n", err)
+	}
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"lightfold/cmd/ui/sequential"
+	"lightfold/cmd/ui/style"
 	"lightfold/cmd/utils"
 	"lightfold/pkg/builders"
 	_ "lightfold/pkg/builders/dockerfile"
@@ -13,25 +16,31 @@ import (
 	"lightfold/pkg/deploy"
 	"lightfold/pkg/detector"
 	"lightfold/pkg/providers"
+	"lightfold/pkg/providers/cloudinit"
 	_ "lightfold/pkg/providers/digitalocean"
+	_ "lightfold/pkg/providers/external"
 	_ "lightfold/pkg/providers/hetzner"
 	_ "lightfold/pkg/providers/linode"
 	_ "lightfold/pkg/providers/vultr"
 	"lightfold/pkg/proxy"
-	_ "lightfold/pkg/proxy/nginx"
+	"lightfold/pkg/proxy/nginx"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/ssl"
 	_ "lightfold/pkg/ssl/certbot"
 	"lightfold/pkg/state"
 	"lightfold/pkg/util"
+	"maps"
+	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	tui "lightfold/cmd/ui"
 
-	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
 )
 
 // Wrapper functions that delegate to utils package
@@ -40,16 +49,51 @@ func loadConfigOrExit() *config.Config {
 }
 
 func loadTargetOrExit(cfg *config.Config, targetName string) config.TargetConfig {
-	return utils.LoadTargetOrExit(cfg, targetName)
+	target := utils.LoadTargetOrExit(cfg, targetName)
+	applySSHOptions(target.SSH)
+	return target
 }
 
 func resolveTarget(cfg *config.Config, targetFlag string, pathArg string) (config.TargetConfig, string) {
-	return utils.ResolveTargetOrExit(cfg, targetFlag, pathArg)
+	target, targetName := utils.ResolveTargetOrExit(cfg, targetFlag, pathArg)
+	applySSHOptions(target.SSH)
+	return target, targetName
 }
 
-func createTarget(targetName, projectPath string, cfg *config.Config) (config.TargetConfig, error) {
+// applySSHOptions makes SSH executors created afterwards use the target's
+// keepalive and reconnect settings
+func applySSHOptions(opts *config.SSHOptions) {
+	sshpkg.SetDefaultConnectionOptions(sshConnectionOptions(opts))
+}
+
+func sshConnectionOptions(opts *config.SSHOptions) sshpkg.ConnectionOptions {
+	connOpts := sshpkg.DefaultConnectionOptions()
+	if opts == nil {
+		return connOpts
+	}
+	switch {
+	case opts.KeepAliveSeconds < 0:
+		connOpts.KeepAliveInterval = 0
+	case opts.KeepAliveSeconds > 0:
+		connOpts.KeepAliveInterval = time.Duration(opts.KeepAliveSeconds) * time.Second
+	}
+	if opts.KeepAliveMaxMissed > 0 {
+		connOpts.KeepAliveMaxMissed = opts.KeepAliveMaxMissed
+	}
+	switch {
+	case opts.ReconnectAttempts < 0:
+		connOpts.ReconnectAttempts = 0
+	case opts.ReconnectAttempts > 0:
+		connOpts.ReconnectAttempts = opts.ReconnectAttempts
+	}
+	return connOpts
+}
+
+// createTarget creates the target's infrastructure. framework is the target's
+// framework override, "" to pick by detection score.
+func createTarget(targetName, projectPath string, cfg *config.Config, framework string) (config.TargetConfig, error) {
 	if target, exists := cfg.GetTarget(targetName); exists && state.IsCreated(targetName) {
-		skipStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+		skipStyle := style.Muted
 		fmt.Printf("  %s\n", skipStyle.Render("Infrastructure already created (skipping)"))
 		return target, nil
 	}
@@ -60,11 +104,23 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 		return config.TargetConfig{}, fmt.Errorf("invalid project path: %w", err)
 	}
 
-	detection := detector.DetectFramework(projectPath)
+	detection := detector.DetectFrameworkAs(projectPath, framework)
 
 	targetConfig := config.TargetConfig{
-		ProjectPath: projectPath,
-		Framework:   detection.Framework,
+		ProjectPath:       projectPath,
+		Framework:         detection.Framework,
+		FrameworkOverride: framework,
+	}
+
+	if userDataFileFlag != "" {
+		userDataFile, err := filepath.Abs(userDataFileFlag)
+		if err != nil {
+			return config.TargetConfig{}, fmt.Errorf("invalid user data file path: %w", err)
+		}
+		if _, err := cloudinit.LoadSnippet(userDataFile); err != nil {
+			return config.TargetConfig{}, err
+		}
+		targetConfig.UserDataFile = userDataFile
 	}
 
 	var provider string
@@ -109,6 +165,20 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 			} else {
 				return config.TargetConfig{}, fmt.Errorf("invalid existing server configuration")
 			}
+		case "adopt":
+			configMap, ok := providerConfig.(map[string]string)
+			if !ok || configMap["server_id"] == "" {
+				return config.TargetConfig{}, fmt.Errorf("invalid adopted server configuration")
+			}
+
+			server, err := fetchAdoptedServer(configMap["provider"], configMap["server_id"])
+			if err != nil {
+				return config.TargetConfig{}, err
+			}
+
+			if err := utils.SetupTargetWithAdoptedServer(&targetConfig, configMap["provider"], server, configMap["ssh_key"], configMap["ssh_key_name"], configMap["username"]); err != nil {
+				return config.TargetConfig{}, fmt.Errorf("failed to adopt server: %w", err)
+			}
 		default:
 			bootstrap, err := findProviderBootstrap(provider)
 			if err != nil {
@@ -127,13 +197,13 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 			return config.TargetConfig{}, fmt.Errorf("failed to save config: %w", err)
 		}
 
-		if provider == "byos" || provider == "existing" {
+		if provider == "byos" || provider == "existing" || provider == "adopt" {
 			byosConfig, err := targetConfig.GetSSHProviderConfig()
 			if err != nil {
 				return config.TargetConfig{}, fmt.Errorf("failed to get BYOS config: %w", err)
 			}
 
-			sshExecutor := sshpkg.NewExecutor(byosConfig.GetIP(), "22", byosConfig.GetUsername(), byosConfig.GetSSHKey())
+			sshExecutor := sshpkg.NewExecutorFromConfig(byosConfig)
 			defer sshExecutor.Disconnect()
 
 			if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
@@ -145,13 +215,20 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 				return config.TargetConfig{}, fmt.Errorf("SSH connection failed: %w", result.Error)
 			}
 
-			successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-			mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-			fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render("SSH connection validated"))
+			successStyle := style.Success
+			mutedStyle := style.Muted
+			fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("SSH connection validated"))
+
+			if targetConfig.UserDataFile != "" {
+				if err := runUserDataScript(sshExecutor, targetConfig.UserDataFile); err != nil {
+					return config.TargetConfig{}, err
+				}
+				fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("Applied user data file"))
+			}
 
 			// Allocate port if not already set
 			if targetConfig.Port == 0 {
-				port, err := utils.GetOrAllocatePort(&targetConfig, targetName)
+				port, err := utils.GetOrAllocatePort(&targetConfig, targetName, sshExecutor)
 				if err != nil {
 					return config.TargetConfig{}, fmt.Errorf("failed to allocate port: %w", err)
 				}
@@ -168,22 +245,25 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 
 			// Show port allocation for existing servers
 			if targetConfig.Port > 0 {
-				fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render(fmt.Sprintf("Allocated to port %d", targetConfig.Port)))
+				fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(fmt.Sprintf("Allocated to port %d", targetConfig.Port)))
 			}
 
-			markerCmd := fmt.Sprintf("sudo mkdir -p %s && echo 'created' | sudo tee %s/%s > /dev/null", config.RemoteLightfoldDir, config.RemoteLightfoldDir, config.RemoteCreatedMarker)
-			result = sshExecutor.Execute(markerCmd)
+			result = writeCreatedMarker(sshExecutor)
 			if result.Error != nil || result.ExitCode != 0 {
 				return config.TargetConfig{}, fmt.Errorf("failed to write created marker: %w", result.Error)
 			}
 
-			if err := state.MarkCreated(targetName, ""); err != nil {
+			adoptedID := ""
+			if byosConfig.IsAdopted() {
+				adoptedID = byosConfig.GetServerID()
+			}
+			if err := state.MarkCreated(targetName, adoptedID); err != nil {
 				return config.TargetConfig{}, fmt.Errorf("failed to update state: %w", err)
 			}
 			state.ClearCreateFailure(targetName)
 		} else {
-			projectName := util.GetTargetName(projectPath)
-			orchestrator, err := deploy.GetOrchestrator(targetConfig, projectPath, projectName, targetName)
+			appName := utils.RemoteAppName(&targetConfig, targetName)
+			orchestrator, err := deploy.GetOrchestrator(targetConfig, projectPath, appName, targetName)
 			if err != nil {
 				return config.TargetConfig{}, fmt.Errorf("failed to create orchestrator: %w", err)
 			}
@@ -255,6 +335,12 @@ func createTarget(targetName, projectPath string, cfg *config.Config) (config.Ta
 // isCalledFromDeploy tracks if configureTarget is being called from deploy command
 var isCalledFromDeploy bool
 
+// serverConfigured reports whether the server carries the marker written at the end of configure
+func serverConfigured(sshExecutor *sshpkg.Executor) bool {
+	result := sshExecutor.Execute(fmt.Sprintf("test -f %s/%s && echo 'configured'", config.RemoteLightfoldDir, config.RemoteConfiguredMarker))
+	return result.ExitCode == 0 && strings.TrimSpace(result.Stdout) == "configured"
+}
+
 func configureTarget(target config.TargetConfig, targetName string, force bool) error {
 	// Skip SSH configuration for container providers (e.g., fly.io)
 	tokens, _ := config.LoadTokens()
@@ -264,7 +350,7 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 			provider, err := providers.GetProvider(target.Provider, token)
 			if err == nil && !provider.SupportsSSH() {
 				// Container providers don't need SSH configuration
-				skipStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+				skipStyle := style.Muted
 				fmt.Printf("%s\n", skipStyle.Render("Container provider detected - skipping SSH configuration"))
 				return nil
 			}
@@ -290,6 +376,10 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 		return fmt.Errorf("invalid target configuration: %w", err)
 	}
 
+	if err := selectContainerPort(&target, targetName); err != nil {
+		return err
+	}
+
 	// Check if server is already configured
 	if !force {
 		providerCfg, err := target.GetSSHProviderConfig()
@@ -297,25 +387,23 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 			return err
 		}
 
-		sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 		if err := sshExecutor.Connect(3, 2*time.Second); err == nil {
-			result := sshExecutor.Execute(fmt.Sprintf("test -f %s/%s && echo 'configured'", config.RemoteLightfoldDir, config.RemoteConfiguredMarker))
-
-			if result.ExitCode == 0 && strings.TrimSpace(result.Stdout) == "configured" {
+			if serverConfigured(sshExecutor) {
 				// Server is configured, but check if we need to install a new runtime for multi-app scenario
-				needsRuntimeInstall := utils.CheckIfRuntimeNeeded(sshExecutor, projectPath)
+				needsRuntimeInstall := utils.CheckIfRuntimeNeeded(sshExecutor, projectPath, target.FrameworkOverride)
 				sshExecutor.Disconnect()
 
 				if !needsRuntimeInstall {
 					if err := state.MarkConfigured(targetName); err != nil {
 						fmt.Printf("Warning: failed to update local state: %v\n", err)
 					}
-					skipStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+					skipStyle := style.Muted
 					fmt.Printf("%s\n", skipStyle.Render("Server already configured (skipping)"))
 					return nil
 				}
 				// Runtime needed for new app - continue to configuration
-				mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+				mutedStyle := style.Muted
 				fmt.Printf("%s\n", mutedStyle.Render("Server configured, but installing runtime for this app..."))
 			} else {
 				sshExecutor.Disconnect()
@@ -323,8 +411,12 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 		}
 	}
 
-	projectName := util.GetTargetName(projectPath)
-	orchestrator, err := deploy.GetOrchestrator(target, projectPath, projectName, targetName)
+	if providerCfg, err := target.GetSSHProviderConfig(); err == nil {
+		printRuntimeMatrix(providerCfg.GetIP(), targetName, detector.DetectFrameworkAs(projectPath, target.FrameworkOverride))
+	}
+
+	appName := utils.RemoteAppName(&target, targetName)
+	orchestrator, err := deploy.GetOrchestrator(target, projectPath, appName, targetName)
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
 	}
@@ -334,6 +426,12 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 		return err
 	}
 
+	orchestrator.SetAutoInstallBuilder(autoInstallBuilder)
+	orchestrator.SetSkipMigrations(skipMigrations)
+	orchestrator.SetRerunFirstDeploy(rerunFirstDeploy)
+	orchestrator.SetForceBuild(forceBuild)
+	orchestrator.SetAptLockOptions(aptWaitFlag, killStaleAptFlag)
+
 	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultProvisioningTimeout)
 	defer cancel()
 
@@ -341,6 +439,11 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 		if markErr := state.MarkConfigureFailed(targetName, err.Error()); markErr != nil {
 			fmt.Printf("Warning: failed to mark configure failure in state: %v\n", markErr)
 		}
+		printMigrationBackoutWarning(err)
+		var memoryErr *deploy.BuildMemoryError
+		if errors.As(err, &memoryErr) {
+			printBuildMemoryWarning(memoryErr.Shortfall, targetName)
+		}
 		return fmt.Errorf("configuration failed: %w", err)
 	}
 
@@ -355,12 +458,13 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 	if err != nil {
 		fmt.Printf("Warning: failed to load config for cleanup: %v\n", err)
 	} else {
-		detection := detector.DetectFramework(projectPath)
-		sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+		detection := detector.DetectFrameworkAs(projectPath, target.FrameworkOverride)
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 		defer sshExecutor.Disconnect()
 
-		executor := deploy.NewExecutor(sshExecutor, projectName, projectPath, &detection)
-		if err := executor.CleanupOldReleases(cfg.NumReleases); err != nil {
+		executor := deploy.NewExecutor(sshExecutor, appName, projectPath, &detection)
+		executor.SetBaseDir(target.RemoteBaseDir())
+		if err := executor.CleanupOldReleases(cfg.KeepReleasesFor(target)); err != nil {
 			fmt.Printf("Warning: failed to cleanup old releases: %v\n", err)
 		}
 	}
@@ -368,21 +472,256 @@ func configureTarget(target config.TargetConfig, targetName string, force bool)
 	return nil
 }
 
-func handleBYOSWithFlags(targetConfig *config.TargetConfig, targetName string) error {
-	if ipFlag == "" {
-		return fmt.Errorf("--ip flag is required for BYOS mode")
+// printMigrationBackoutWarning calls out migrations a failed deploy left
+// applied. They are never reverted automatically, and the previous release
+// now runs against the migrated schema.
+func printMigrationBackoutWarning(err error) {
+	var backout *deploy.MigrationBackoutError
+	if !errors.As(err, &backout) {
+		return
+	}
+	warningStyle := style.Warning
+	fmt.Fprintf(os.Stderr, "\n%s\n", warningStyle.Render(style.Warn()+" Migrations ran before this deploy failed and were NOT reverted"))
+	fmt.Fprintf(os.Stderr, "  The previous release is serving again against the migrated schema.\n")
+	fmt.Fprintf(os.Stderr, "  If it cannot run against it, back out '%s' by hand.\n\n", backout.Command)
+}
+
+// checkBuildMemory warns before a remote build on a server with less memory
+// than the app's framework needs to build. It fails for builds that cannot
+// finish unless --force-build is set.
+func checkBuildMemory(executor *deploy.Executor, targetName string) error {
+	shortfall, err := executor.CheckBuildMemory()
+	if shortfall != nil {
+		printBuildMemoryWarning(shortfall, targetName)
+	}
+	return err
+}
+
+// uploadReleaseAssets copies the release's built assets to the target's asset
+// bucket. A failed upload is an error unless the target continues on asset
+// errors, in which case it is printed as a warning.
+func uploadReleaseAssets(executor *deploy.Executor, releasePath string) error {
+	upload, err := executor.UploadAssets(context.Background(), releasePath)
+	if err != nil || upload == nil {
+		return err
+	}
+	if upload.Err != nil {
+		fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), deployMutedStyle.Render(fmt.Sprintf("%v; pages link assets the bucket may not have until an upload succeeds", upload.Err)))
+		return nil
+	}
+	fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render(fmt.Sprintf("Uploading assets to %s (%s)...", upload.Destination, upload.Result)))
+	return nil
+}
+
+// setProxyHealthCheck turns on the post-deploy health check through nginx for
+// targets whose builder serves the app behind it. It stays off in maintenance
+// mode, where nginx answers with the maintenance page.
+func setProxyHealthCheck(executor *deploy.Executor, target config.TargetConfig, targetName, serverIP string) {
+	if state.InMaintenance(targetName) {
+		return
+	}
+	builderName := target.Builder
+	if builderName == "" {
+		builderName = "native"
+	}
+	if builder, err := builders.GetBuilder(builderName); err == nil && !builder.NeedsNginx() {
+		return
+	}
+	executor.SetProxyHealthCheck(target.ProxyHealthCheckHost(serverIP))
+}
+
+// recordDeployHealth saves the deploy's app and nginx health check results for
+// 'lightfold status', when the deploy got as far as checking
+func recordDeployHealth(executor *deploy.Executor, targetName string) {
+	health := executor.DeployHealth()
+	if health.CheckedAt.IsZero() {
+		return
+	}
+	if err := state.RecordDeployHealth(targetName, health); err != nil {
+		fmt.Printf("Warning: failed to record health checks: %v\n", err)
+	}
+}
+
+// recordFirstDeploy saves the outcome of the first-deploy commands when the
+// deploy ran them
+func recordFirstDeploy(executor *deploy.Executor, targetName string) {
+	run := executor.FirstDeployResult()
+	if run == nil {
+		return
+	}
+	if err := state.RecordFirstDeploy(targetName, *run); err != nil {
+		fmt.Printf("Warning: failed to record first-deploy commands: %v\n", err)
+	}
+}
+
+// printBuildMemoryWarning calls out a build likely to be killed for running
+// out of memory, with the ways around it
+func printBuildMemoryWarning(shortfall *deploy.BuildMemoryShortfall, targetName string) {
+	warningStyle := style.Warning
+	fmt.Printf("\n%s\n", warningStyle.Render(fmt.Sprintf("%s Low memory: %s", style.Warn(), shortfall)))
+	fmt.Printf("  The build is likely to be killed for running out of memory. Instead:\n")
+	fmt.Printf("  - Build the image on this machine with --builder dockerfile\n")
+	fmt.Printf("  - Move to a size with at least %d MB of memory\n", shortfall.RecommendedMB)
+	fmt.Printf("  - Add swap space to the server\n")
+	fmt.Printf("  %s\n\n", deployMutedStyle.Render(fmt.Sprintf("Skip this check with 'lightfold config set --target %s deploy.skip_build_memory_check=true'", targetName)))
+}
+
+// promptSudoPassword reads the deploy user's sudo password without echoing it,
+// for executors whose sudo needs one with --sudo-password-prompt. The password
+// only lives in memory for the current command.
+func promptSudoPassword(username, serverIP string) (string, error) {
+	fmt.Printf("Sudo password for %s@%s: ", username, serverIP)
+	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read sudo password: %w", err)
+	}
+	if len(passwordBytes) == 0 {
+		return "", fmt.Errorf("sudo password cannot be empty")
+	}
+	return string(passwordBytes), nil
+}
+
+// fetchAdoptedServer looks up a server chosen for adoption so its current IP and metadata are recorded
+func fetchAdoptedServer(providerName, serverID string) (*providers.Server, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+
+	provider, err := providers.GetProvider(providerName, tokens.GetToken(providerName))
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := provider.GetServer(context.Background(), serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up server %s: %w", serverID, err)
+	}
+
+	return server, nil
+}
+
+// writeCreatedMarker records on the server that lightfold created or adopted it
+func writeCreatedMarker(sshExecutor *sshpkg.Executor) *sshpkg.CommandResult {
+	return sshExecutor.ExecuteSudo(fmt.Sprintf("sh -c 'mkdir -p %s && echo created > %s/%s'",
+		config.RemoteLightfoldDir, config.RemoteLightfoldDir, config.RemoteCreatedMarker))
+}
+
+// serverKnownApps returns the app names of the server's registered apps and
+// of the configured targets on it
+func serverKnownApps(serverIP string, cfg *config.Config) map[string]bool {
+	known := make(map[string]bool)
+	if serverState, err := state.GetServerState(serverIP); err == nil {
+		for _, app := range serverState.DeployedApps {
+			known[app.TargetName] = true
+			if app.AppName != "" {
+				known[app.AppName] = true
+			}
+		}
+	}
+	if cfg != nil {
+		for name, target := range cfg.Targets {
+			if target.ServerIP == serverIP {
+				known[utils.RemoteAppName(&target, name)] = true
+			}
+		}
+	}
+	return known
+}
+
+// pruneStalePortReservations removes the server's port reservations for apps
+// that are not installed there and belong to no target this machine knows,
+// e.g. targets destroyed since, and returns how many it removed
+func pruneStalePortReservations(runner sshpkg.SudoRunner, serverIP string, cfg *config.Config) (int, error) {
+	reservations, err := deploy.ListPortReservations(runner)
+	if err != nil {
+		return 0, err
+	}
+
+	known := serverKnownApps(serverIP, cfg)
+	removed := 0
+	stale := deploy.StalePortReservations(reservations, func(appName string) bool { return known[appName] }, time.Now())
+	for _, reservation := range stale {
+		if err := deploy.ReleasePortReservation(runner, reservation.Port, reservation.AppName); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// runUserDataScript applies a user data file on a server lightfold did not
+// provision, where there is no cloud-init run to merge it into
+func runUserDataScript(sshExecutor *sshpkg.Executor, userDataFile string) error {
+	snippet, err := cloudinit.LoadSnippet(userDataFile)
+	if err != nil {
+		return err
 	}
-	if sshKeyFlag == "" {
-		return fmt.Errorf("--ssh-key flag is required for BYOS mode")
+
+	script, err := snippet.Script()
+	if err != nil {
+		return fmt.Errorf("failed to convert user data file to a script: %w", err)
+	}
+
+	remotePath := "/tmp/lightfold-user-data.sh"
+	if err := sshExecutor.WriteRemoteFile(remotePath, script, config.PermEnvFile); err != nil {
+		return fmt.Errorf("failed to upload user data script: %w", err)
+	}
+
+	result := sshExecutor.ExecuteSudo(fmt.Sprintf("bash %s", remotePath))
+	sshExecutor.Execute(fmt.Sprintf("rm -f %s", remotePath))
+	if result.Error != nil {
+		return fmt.Errorf("failed to run user data script: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("user data script failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+
+	return nil
+}
+
+// newBYOSConfig builds the connection to a server lightfold did not
+// provision from --ip, --ssh-key and --user, or an --ssh-host alias they
+// override
+func newBYOSConfig(ip, sshKey, user, sshHost string) (*config.BYOSConfig, error) {
+	byosConfig := &config.BYOSConfig{
+		IP:       ip,
+		SSHKey:   sshKey,
+		Username: user,
+		SSHHost:  sshHost,
+	}
+	if sshHost == "" {
+		if ip == "" {
+			return nil, fmt.Errorf("--ip or --ssh-host flag is required for BYOS mode")
+		}
+		if sshKey == "" {
+			return nil, fmt.Errorf("--ssh-key flag is required for BYOS mode")
+		}
+		if byosConfig.Username == "" {
+			byosConfig.Username = "root"
+		}
+	} else {
+		if _, err := config.ResolveSSHHost(sshHost); err != nil {
+			return nil, err
+		}
+		if byosConfig.GetSSHKey() == "" {
+			return nil, fmt.Errorf("ssh config sets no IdentityFile for %s; add one or pass --ssh-key", sshHost)
+		}
 	}
-	if userFlag == "" {
-		userFlag = "root"
+	return byosConfig, nil
+}
+
+func handleBYOSWithFlags(targetConfig *config.TargetConfig, targetName string) error {
+	byosConfig, err := newBYOSConfig(ipFlag, sshKeyFlag, userFlag, sshHostFlag)
+	if err != nil {
+		return err
 	}
 
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	successStyle := style.Success
+	mutedStyle := style.Muted
 
-	sshExecutor := sshpkg.NewExecutor(ipFlag, "22", userFlag, sshKeyFlag)
+	sshExecutor := sshpkg.NewExecutorFromConfig(byosConfig)
 	defer sshExecutor.Disconnect()
 
 	result := sshExecutor.Execute("echo 'SSH connection successful'")
@@ -390,21 +729,21 @@ func handleBYOSWithFlags(targetConfig *config.TargetConfig, targetName string) e
 		return fmt.Errorf("SSH connection failed: %w", result.Error)
 	}
 
-	fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render("SSH connection validated"))
+	fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("SSH connection validated"))
+
+	if targetConfig.UserDataFile != "" {
+		if err := runUserDataScript(sshExecutor, targetConfig.UserDataFile); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("Applied user data file"))
+	}
 
-	markerCmd := fmt.Sprintf("sudo mkdir -p %s && echo 'created' | sudo tee %s/%s > /dev/null", config.RemoteLightfoldDir, config.RemoteLightfoldDir, config.RemoteCreatedMarker)
-	result = sshExecutor.Execute(markerCmd)
+	result = writeCreatedMarker(sshExecutor)
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to write created marker: %w", result.Error)
 	}
 
 	targetConfig.Provider = "byos"
-	byosConfig := &config.DigitalOceanConfig{
-		IP:          ipFlag,
-		SSHKey:      sshKeyFlag,
-		Username:    userFlag,
-		Provisioned: false,
-	}
 	targetConfig.SetProviderConfig("byos", byosConfig)
 	if err := state.MarkCreated(targetName, ""); err != nil {
 		return fmt.Errorf("failed to update state: %w", err)
@@ -427,6 +766,9 @@ func handleProvisionWithFlags(targetConfig *config.TargetConfig, targetName, pro
 	if err != nil {
 		return err
 	}
+	if err := providers.ValidateIPStack(bootstrap.canonical, ipStackFlag); err != nil {
+		return err
+	}
 
 	if imageFlag == "" {
 		imageFlag = "ubuntu-22-04-x64"
@@ -443,9 +785,10 @@ func handleProvisionWithFlags(targetConfig *config.TargetConfig, targetName, pro
 		}
 	} else {
 		cfgFromFlags, cfgErr := bootstrap.prepareConfigFromFlags(targetName, provisionInputs{
-			Region: regionFlag,
-			Size:   sizeFlag,
-			Image:  imageFlag,
+			Region:  regionFlag,
+			Size:    sizeFlag,
+			Image:   imageFlag,
+			IPStack: ipStackFlag,
 		})
 		if cfgErr != nil {
 			return cfgErr
@@ -455,8 +798,8 @@ func handleProvisionWithFlags(targetConfig *config.TargetConfig, targetName, pro
 		}
 	}
 
-	projectName := util.GetTargetName(projectPath)
-	orchestrator, err := deploy.GetOrchestrator(*targetConfig, projectPath, projectName, targetName)
+	appName := utils.RemoteAppName(targetConfig, targetName)
+	orchestrator, err := deploy.GetOrchestrator(*targetConfig, projectPath, appName, targetName)
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
 	}
@@ -486,9 +829,9 @@ func handleProvisionWithFlags(targetConfig *config.TargetConfig, targetName, pro
 		state.ClearCreateFailure(targetName)
 	}
 
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render(fmt.Sprintf("Server provisioned at %s", result.Server.PublicIPv4)))
+	successStyle := style.Success
+	mutedStyle := style.Muted
+	fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(fmt.Sprintf("Server provisioned at %s", result.Server.PrimaryIP())))
 
 	return nil
 }
@@ -523,21 +866,90 @@ var validateConfigureTargetConfig = func(target config.TargetConfig) error {
 }
 
 func resolveBuilder(target config.TargetConfig, projectPath string, detection *detector.Detection, flagValue string) string {
+	builderName, _ := resolveBuilderWithReason(target, projectPath, detection, flagValue)
+	return builderName
+}
+
+// resolveBuilderWithReason picks the builder like resolveBuilder and explains why
+func resolveBuilderWithReason(target config.TargetConfig, projectPath string, detection *detector.Detection, flagValue string) (string, string) {
 	if flagValue != "" {
-		return flagValue
+		return flagValue, "set by --builder"
 	}
 
 	if target.Builder != "" {
 		if builder, err := builders.GetBuilder(target.Builder); err == nil && builder.IsAvailable() {
-			return target.Builder
+			return target.Builder, "saved in target config"
 		}
 	}
 
-	builderName, err := builders.AutoSelectBuilder(projectPath, detection)
+	builderName, reason, err := builders.AutoSelectBuilderWithReason(projectPath, detection)
 	if err != nil {
-		return "native"
+		return "native", "auto-detection failed"
 	}
-	return builderName
+	return builderName, "auto-detected: " + reason
+}
+
+// domainProxyConfig is the HTTP-only proxy config for the app serving domain,
+// with the path routes other targets registered on the domain
+func domainProxyConfig(target *config.TargetConfig, appName, serverIP, domain string, port int) proxy.ProxyConfig {
+	proxyConfig := proxy.ProxyConfig{
+		Domain:      domain,
+		Port:        port,
+		AppName:     appName,
+		PathRoutes:  proxyPathRoutes(serverIP, domain),
+		StaticPaths: deploy.StaticPathsFor(target.Framework, config.AppDir(target.RemoteBaseDir(), appName), target.Deploy),
+	}
+	// Static sites are served from their build output, with its mapped directories
+	detection := detector.DetectFrameworkAs(target.ProjectPath, target.FrameworkOverride)
+	proxyConfig.StaticRoot, proxyConfig.OutputDirs = deploy.StaticSiteFor(&detection, config.AppDir(target.RemoteBaseDir(), appName), target.Deploy)
+	if target.Domain != nil && target.Domain.Domain == domain {
+		proxyConfig.RedirectFrom = target.Domain.RedirectFrom
+		proxyConfig.DisableHTTP2, proxyConfig.HTTP3 = domainProtocols(target.Domain)
+	}
+	return proxyConfig
+}
+
+// installSSLSite replaces the site certbot installed its certificate into
+// with lightfold's HTTPS config, which carries the HTTP/2 and HTTP/3 settings
+// and security headers certbot's edit lacks. certbot's version is put back
+// when nginx rejects the new one.
+func installSSLSite(proxyManager proxy.ProxyManager, sshExecutor *sshpkg.Executor, proxyConfig proxy.ProxyConfig) error {
+	configPath := proxyManager.GetConfigPath(proxyConfig.AppName)
+	site := sshExecutor.ExecuteSudo("cat " + configPath)
+	certPath, keyPath := nginx.CertificatePaths(site.Stdout)
+	if site.ExitCode != 0 || certPath == "" || keyPath == "" {
+		return fmt.Errorf("no certificate found in %s", configPath)
+	}
+
+	proxyConfig.SSLEnabled = true
+	proxyConfig.SSLCertPath = certPath
+	proxyConfig.SSLKeyPath = keyPath
+	if err := proxyManager.Configure(proxyConfig); err != nil {
+		if restoreErr := sshExecutor.InstallFile(sshpkg.RemoteFile{Path: configPath, Mode: 0644, Owner: "root:root"}, site.Stdout); restoreErr != nil {
+			return fmt.Errorf("%w (restoring certbot's config also failed: %v)", err, restoreErr)
+		}
+		return err
+	}
+	if err := keepDomainSiteMaintenance(proxyManager, sshExecutor, proxyConfig.AppName); err != nil {
+		return err
+	}
+	if proxyConfig.HTTP3 {
+		// QUIC runs over UDP, which the firewall set up at provisioning blocks
+		if result := sshExecutor.ExecuteSudo("which ufw >/dev/null && ufw allow 443/udp"); result.ExitCode != 0 {
+			fmt.Printf("Warning: failed to open UDP port 443 for HTTP/3: %s\n", strings.TrimSpace(result.Stderr))
+		}
+	}
+	return proxyManager.Reload()
+}
+
+// keepDomainSiteMaintenance puts the maintenance block back into the site the
+// proxy manager just rewrote, so domain changes during maintenance keep it on
+func keepDomainSiteMaintenance(proxyManager proxy.ProxyManager, sshExecutor *sshpkg.Executor, appName string) error {
+	executor := deploy.NewExecutor(sshExecutor, appName, "", nil)
+	if err := executor.KeepMaintenanceIn(proxyManager.GetConfigPath(appName)); err != nil {
+		return fmt.Errorf("failed to keep maintenance mode: %w", err)
+	}
+	return nil
 }
 
 func configureDomainAndSSL(target *config.TargetConfig, targetName string, domain string, enableSSL bool) error {
@@ -550,16 +962,29 @@ func configureDomainAndSSL(target *config.TargetConfig, targetName string, domai
 		return fmt.Errorf("failed to get SSH config: %w", err)
 	}
 
-	sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 	defer sshExecutor.Disconnect()
 
 	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
 
+	// Resolve the port before touching nginx so a domain never points at nothing
+	port, discovered, err := utils.ResolveTargetPort(target, targetName, sshExecutor)
+	if err != nil {
+		return err
+	}
+	if discovered {
+		target.Port = port
+	}
+
 	if target.Domain == nil {
 		target.Domain = &config.DomainConfig{}
 	}
+	if target.Domain.Domain != domain {
+		// A redirect belongs to the domain it was set up for
+		target.Domain.RedirectFrom = ""
+	}
 	target.Domain.Domain = domain
 	target.Domain.SSLEnabled = enableSSL
 	target.Domain.ProxyType = "nginx"
@@ -569,14 +994,20 @@ func configureDomainAndSSL(target *config.TargetConfig, targetName string, domai
 
 		checkResult := sshExecutor.Execute("which certbot")
 		if checkResult.ExitCode != 0 {
-			installCmd := "sudo apt-get update && sudo apt-get install -y certbot python3-certbot-nginx"
-			installResult := sshExecutor.Execute(installCmd)
+			installCmd := "sh -c 'apt-get update && apt-get install -y certbot python3-certbot-nginx'"
+			installResult := sshExecutor.ExecuteSudo(installCmd)
 			if installResult.Error != nil || installResult.ExitCode != 0 {
 				return fmt.Errorf("failed to install certbot: %w", installResult.Error)
 			}
 		}
 	}
 
+	if enableSSL && target.Domain != nil {
+		if err := checkNginxProtocols(sshExecutor, target.Domain, targetName); err != nil {
+			return err
+		}
+	}
+
 	proxyManager, err := proxy.GetManager("nginx")
 	if err != nil {
 		return fmt.Errorf("failed to get proxy manager: %w", err)
@@ -586,34 +1017,27 @@ func configureDomainAndSSL(target *config.TargetConfig, targetName string, domai
 		nginxMgr.SetExecutor(sshExecutor)
 	}
 
-	// Get or allocate port for this app
-	port := target.Port
-	if port == 0 {
-		port = utils.ExtractPortFromTarget(target, target.ProjectPath)
-	}
-
-	appName := targetName
+	appName := resolveAppName(target, targetName, sshExecutor)
 
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	successStyle := style.Success
+	mutedStyle := style.Muted
 
-	httpOnlyConfig := proxy.ProxyConfig{
-		Domain:      domain,
-		Port:        port,
-		AppName:     appName,
-		SSLEnabled:  false, // HTTP only first
-		SSLCertPath: "",
-		SSLKeyPath:  "",
-	}
+	httpOnlyConfig := domainProxyConfig(target, appName, providerCfg.GetIP(), domain, port) // HTTP only first
 	if err := proxyManager.Configure(httpOnlyConfig); err != nil {
 		return fmt.Errorf("failed to configure proxy: %w", err)
 	}
+	if err := keepDomainSiteMaintenance(proxyManager, sshExecutor, appName); err != nil {
+		return err
+	}
 
 	if err := proxyManager.Reload(); err != nil {
 		return fmt.Errorf("failed to reload proxy: %w", err)
 	}
 
-	fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render("Configured reverse proxy with domain"))
+	fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("Configured reverse proxy with domain"))
+	if target.Domain.RedirectFrom != "" {
+		fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(fmt.Sprintf("Redirecting %s to %s", target.Domain.RedirectFrom, domain)))
+	}
 
 	if enableSSL {
 		sslManager, err := ssl.GetManager("certbot")
@@ -624,21 +1048,51 @@ func configureDomainAndSSL(target *config.TargetConfig, targetName string, domai
 		if certbotMgr, ok := sslManager.(interface{ SetExecutor(*sshpkg.Executor) }); ok {
 			certbotMgr.SetExecutor(sshExecutor)
 		}
+		if stagingMgr, ok := sslManager.(interface{ SetStaging(bool) }); ok {
+			stagingMgr.SetStaging(target.Domain.SSLStaging)
+		}
+		if aliasMgr, ok := sslManager.(interface{ SetAliases([]string) }); ok {
+			aliasMgr.SetAliases(target.Domain.Names()[1:])
+		}
+
+		reused, _ := sslManager.CertificateExists(target.Domain.Names())
 
 		email := "noreply@" + domain
 		if err := sslManager.IssueCertificate(domain, email); err != nil {
 			return fmt.Errorf("failed to issue SSL certificate: %w", err)
 		}
 
-		if err := sslManager.EnableAutoRenewal(); err != nil {
-			fmt.Printf("Warning: failed to enable auto-renewal: %v\n", err)
+		// Renewal that silently never runs lets the certificate expire, so the
+		// schedule is verified and shown rather than assumed
+		renewalErr := sslManager.EnableAutoRenewal()
+		renewal, err := checkRenewal(sshExecutor, domain, false, false)
+		if err != nil {
+			renewal.Error = err.Error()
+		} else if renewalErr != nil && renewal.Method == "" {
+			renewal.Error = renewalErr.Error()
 		}
+		recordRenewal(targetName, renewal)
 
 		if err := state.MarkSSLConfigured(targetName); err != nil {
 			fmt.Printf("Warning: failed to update SSL state: %v\n", err)
 		}
 
-		fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render(fmt.Sprintf("Issued SSL certificate for %s", domain)))
+		// The certificate is in place either way, so a config nginx rejects
+		// leaves certbot's HTTPS setup serving the domain
+		if err := installSSLSite(proxyManager, sshExecutor, httpOnlyConfig); err != nil {
+			fmt.Printf("Warning: kept certbot's HTTPS config without the HTTP/2 and HTTP/3 settings: %v\n", err)
+		}
+
+		names := strings.Join(target.Domain.Names(), " and ")
+		sslMessage := fmt.Sprintf("Issued SSL certificate for %s", names)
+		if reused {
+			sslMessage = fmt.Sprintf("Reused existing SSL certificate for %s", names)
+		}
+		if target.Domain.SSLStaging {
+			sslMessage += " (staging - not trusted by browsers)"
+		}
+		fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(sslMessage))
+		printRenewal(renewal, targetName)
 	}
 
 	cfg, err := config.LoadConfig()
@@ -654,22 +1108,126 @@ func configureDomainAndSSL(target *config.TargetConfig, targetName string, domai
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
+	recordDomainApplied(*target, targetName)
+
 	protocol := "http"
 	if enableSSL {
 		protocol = "https"
 	}
 
-	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	fmt.Printf("\n%s %s\n", successStyle.Render("✓"), successStyle.Render("Domain configured successfully!"))
+	valueStyle := style.Value
+	fmt.Printf("\n%s %s\n", successStyle.Render(style.Check()), successStyle.Render("Domain configured successfully!"))
 	fmt.Printf("  %s %s\n\n", mutedStyle.Render("Your app is now available at:"), valueStyle.Render(fmt.Sprintf("%s://%s", protocol, domain)))
 
 	return nil
 }
 
-func syncTarget(target config.TargetConfig, targetName string, cfg *config.Config) (*state.TargetState, error) {
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+// extraIPv6 returns the server's IPv6 address when it has one besides the IP
+// lightfold connects to, for showing next to that IP
+func extraIPv6(providerCfg config.ProviderConfig) string {
+	_, ipv6 := config.PublicAddresses(providerCfg)
+	if ipv6 == "" || sameIP(ipv6, providerCfg.GetIP()) {
+		return ""
+	}
+	return ipv6
+}
+
+// urlHost brackets IPv6 literals so the address can be used in a URL
+func urlHost(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// resolveAppName returns the app name the target is deployed under on the
+// server. A deployment found only under a legacy underscore name is adopted:
+// the name is saved as the target's app_name so every command keeps using it.
+func resolveAppName(target *config.TargetConfig, targetName string, runner utils.CommandRunner) string {
+	if legacy, found := utils.DetectLegacyAppName(runner, target, targetName); found {
+		return legacy
+	}
+	return utils.RemoteAppName(target, targetName)
+}
+
+// adoptAppName makes the app name a deploy resolved permanent. A legacy
+// deployment's name is saved as the target's app_name, and an nginx site that
+// domain setup named after the target is renamed to the app's site in one step,
+// so the old one is not left enabled next to it. Only deploys call this;
+// read-only commands just resolve the name.
+func adoptAppName(target *config.TargetConfig, targetName, appName string, sshExecutor *sshpkg.Executor) {
+	if appName != utils.RemoteAppName(target, targetName) {
+		target.AppName = appName
+		fmt.Fprintf(os.Stderr, "Adopted existing deployment '%s' (deployed under a legacy app name)\n", appName)
+		if cfg, err := config.LoadConfig(); err == nil {
+			if saved, exists := cfg.GetTarget(targetName); exists {
+				saved.AppName = appName
+				if err := cfg.SetTarget(targetName, saved); err == nil {
+					if err := cfg.SaveConfig(); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: failed to save app name: %v\n", err)
+					}
+				}
+			}
+		}
+	}
+
+	if targetName == appName || serverAppNamed(target.ServerIP, targetName) {
+		return
+	}
+	result := sshExecutor.ExecuteSudo("sh -c " + util.ShellQuote(renameNginxSiteScript(targetName, appName)))
+	if result.Error != nil || result.ExitCode != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rename the nginx site of %s to %s: %s\n", targetName, appName, strings.TrimSpace(result.Stderr))
+	}
+}
+
+// serverAppNamed reports whether another app on the server is deployed under name
+func serverAppNamed(serverIP, name string) bool {
+	serverState, err := state.GetServerState(serverIP)
+	if err != nil {
+		return false
+	}
+	for _, app := range serverState.DeployedApps {
+		if app.AppName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameNginxSiteScript moves the site domain setup wrote under the target name
+// to the app's name, or drops it when the app's site already exists, removes
+// the old enabled link and reloads nginx. It does nothing without an old site.
+func renameNginxSiteScript(oldName, appName string) string {
+	site := func(dir, name string) string {
+		return util.ShellQuote(fmt.Sprintf("/etc/nginx/%s/%s.conf", dir, name))
+	}
+	return fmt.Sprintf(`[ -e %[1]s ] || exit 0
+if [ -e %[2]s ]; then rm -f %[1]s; else mv %[1]s %[2]s && ln -sf %[2]s %[4]s; fi
+rm -f %[3]s
+nginx -t && systemctl reload nginx`,
+		site("sites-available", oldName), site("sites-available", appName),
+		site("sites-enabled", oldName), site("sites-enabled", appName))
+}
+
+// releaseCommit reads the commit a release on the server was built from, out
+// of its manifest or the .git-commit marker of older releases
+func releaseCommit(sshExecutor *sshpkg.Executor, releasePath string) string {
+	result := sshExecutor.Execute(fmt.Sprintf("cat %s/%s 2>/dev/null", releasePath, deploy.ReleaseManifestFile))
+	var manifest deploy.ReleaseManifest
+	if result.ExitCode == 0 && json.Unmarshal([]byte(result.Stdout), &manifest) == nil && manifest.Commit != "" {
+		return manifest.Commit
+	}
+	result = sshExecutor.Execute(fmt.Sprintf("cat %s/.git-commit 2>/dev/null", releasePath))
+	if result.ExitCode != 0 {
+		return ""
+	}
+	return strings.TrimSpace(result.Stdout)
+}
+
+func syncTarget(target config.TargetConfig, targetName string, cfg *config.Config, fixDomain bool) (*state.TargetState, error) {
+	successStyle := style.Success
+	mutedStyle := style.Muted
+	labelStyle := style.Label
 
 	if target.Provider == "s3" {
 		fmt.Printf("%s\n", mutedStyle.Render("S3 deployments don't require syncing (static files)"))
@@ -683,7 +1241,7 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 
 	changesDetected := false
 
-	if handler, ok := providerStateHandlers[target.Provider]; ok {
+	if handler, ok := stateHandlerFor(target.Provider); ok {
 		providerCfg, err := handler.cfgAccessor(&target)
 		if err == nil && providerCfg != nil && providerCfg.IsProvisioned() {
 			serverID := providerCfg.GetServerID()
@@ -693,11 +1251,11 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 
 			if providerCfg.GetIP() == "" && serverID != "" {
 				originalIP := providerCfg.GetIP()
-				fmt.Printf("%s Recovering server IP from %s API...\n", labelStyle.Render("→"), handler.displayName)
+				fmt.Printf("%s Recovering server IP from %s API...\n", labelStyle.Render(style.Arrow()), handler.displayName)
 
 				updated, _, recErr := tryRecoverProviderIP(&target, targetName, targetState)
 				if recErr != nil {
-					fmt.Printf("%s Failed to recover IP: %v\n", mutedStyle.Render("  ⚠"), recErr)
+					fmt.Printf("%s Failed to recover IP: %v\n", mutedStyle.Render("  "+style.Warn()), recErr)
 				} else if updated {
 					if updatedCfg, loadErr := config.LoadConfig(); loadErr == nil {
 						if updatedTarget, exists := updatedCfg.GetTarget(targetName); exists {
@@ -705,7 +1263,7 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 							if refreshedCfg, cfgErr := handler.cfgAccessor(&target); cfgErr == nil && refreshedCfg != nil {
 								newIP := refreshedCfg.GetIP()
 								if newIP != "" && newIP != originalIP {
-									fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render(fmt.Sprintf("IP updated: %s → %s", originalIP, newIP)))
+									fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("IP updated: %s %s %s", originalIP, style.Arrow(), newIP)))
 									changesDetected = true
 								}
 							}
@@ -725,33 +1283,44 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 		return nil, fmt.Errorf("server IP address is not configured")
 	}
 
-	fmt.Printf("%s Connecting to server at %s...\n", labelStyle.Render("→"), providerCfg.GetIP())
+	fmt.Printf("%s Connecting to server at %s...\n", labelStyle.Render(style.Arrow()), providerCfg.GetIP())
 
-	sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
-		return nil, fmt.Errorf("failed to connect via SSH: %w", err)
+		// A provisioned server may have come back with a new IP (e.g. an AWS
+		// instance restarted without an Elastic IP); ask the provider before failing
+		newIP, refreshed := refreshChangedIP(&target, targetName, targetState, providerCfg.GetIP())
+		if !refreshed {
+			return nil, fmt.Errorf("failed to connect via SSH: %w", err)
+		}
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("IP updated: %s %s %s", providerCfg.GetIP(), style.Arrow(), newIP)))
+		changesDetected = true
+
+		sshExecutor = sshpkg.NewExecutor(newIP, "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			return nil, fmt.Errorf("failed to connect via SSH: %w", err)
+		}
 	}
 	defer sshExecutor.Disconnect()
 
-	fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render("SSH connection established"))
+	fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render("SSH connection established"))
 
 	if !targetState.Created {
 		targetState.Created = true
 		changesDetected = true
-		fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render("Server is created (SSH accessible)"))
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render("Server is created (SSH accessible)"))
 	}
 
-	fmt.Printf("%s Syncing remote state markers...\n", labelStyle.Render("→"))
+	fmt.Printf("%s Syncing remote state markers...\n", labelStyle.Render(style.Arrow()))
 
 	createdResult := sshExecutor.Execute(fmt.Sprintf("test -f %s/%s && echo 'true' || echo 'false'", config.RemoteLightfoldDir, config.RemoteCreatedMarker))
 	if createdResult.ExitCode == 0 {
 		remoteCreated := strings.TrimSpace(createdResult.Stdout) == "true"
 
 		if !remoteCreated {
-			markerCmd := fmt.Sprintf("sudo mkdir -p %s && echo 'created' | sudo tee %s/%s > /dev/null", config.RemoteLightfoldDir, config.RemoteLightfoldDir, config.RemoteCreatedMarker)
-			markerResult := sshExecutor.Execute(markerCmd)
+			markerResult := writeCreatedMarker(sshExecutor)
 			if markerResult.ExitCode == 0 {
-				fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render("Created marker written to server"))
+				fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render("Created marker written to server"))
 				changesDetected = true
 			}
 		}
@@ -763,15 +1332,31 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 		if targetState.Configured != remoteConfigured {
 			targetState.Configured = remoteConfigured
 			changesDetected = true
-			fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render(fmt.Sprintf("Configured state updated: %v", remoteConfigured)))
+			fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("Configured state updated: %v", remoteConfigured)))
 		}
 	}
 
-	fmt.Printf("%s Syncing deployment information...\n", labelStyle.Render("→"))
+	serverIP := target.ServerIP
+	if serverIP == "" {
+		serverIP = providerCfg.GetIP()
+	}
+	if pruned, err := pruneStalePortReservations(sshExecutor, serverIP, cfg); err != nil {
+		fmt.Printf("%s %s\n", mutedStyle.Render("  "+style.Warn()), mutedStyle.Render(fmt.Sprintf("Could not clean up port reservations: %v", err)))
+	} else if pruned > 0 {
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("Removed %d stale port reservation(s)", pruned)))
+	}
+	// Apps installed before reservations existed get theirs from their unit
+	if reserved, err := deploy.BackfillPortReservations(sshExecutor, slices.Sorted(maps.Keys(serverKnownApps(serverIP, cfg)))); err != nil {
+		fmt.Printf("%s %s\n", mutedStyle.Render("  "+style.Warn()), mutedStyle.Render(fmt.Sprintf("Could not reserve installed ports: %v", err)))
+	} else if len(reserved) > 0 {
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("Reserved %d installed port(s)", len(reserved))))
+	}
+
+	fmt.Printf("%s Syncing deployment information...\n", labelStyle.Render(style.Arrow()))
 
-	appName := strings.ReplaceAll(targetName, "-", "_")
+	appName := resolveAppName(&target, targetName, sshExecutor)
 
-	currentReleaseResult := sshExecutor.Execute(fmt.Sprintf("readlink -f %s/%s/current 2>/dev/null", config.RemoteAppBaseDir, appName))
+	currentReleaseResult := sshExecutor.Execute(fmt.Sprintf("readlink -f %s/current 2>/dev/null", config.AppDir(target.RemoteBaseDir(), appName)))
 	if currentReleaseResult.ExitCode == 0 {
 		currentReleasePath := strings.TrimSpace(currentReleaseResult.Stdout)
 		if currentReleasePath != "" && !strings.Contains(currentReleasePath, "none") {
@@ -779,20 +1364,12 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 			if releaseTimestamp != "" && targetState.LastRelease != releaseTimestamp {
 				targetState.LastRelease = releaseTimestamp
 				changesDetected = true
-				fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render(fmt.Sprintf("Current release: %s", releaseTimestamp)))
-
-				gitCommitResult := sshExecutor.Execute(fmt.Sprintf("cat %s/.git-commit 2>/dev/null", currentReleasePath))
-				if gitCommitResult.ExitCode == 0 {
-					gitCommit := strings.TrimSpace(gitCommitResult.Stdout)
-					if gitCommit != "" && targetState.LastCommit != gitCommit {
-						targetState.LastCommit = gitCommit
-						changesDetected = true
-						commitShort := gitCommit
-						if len(commitShort) > 7 {
-							commitShort = commitShort[:7]
-						}
-						fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render(fmt.Sprintf("Git commit: %s", commitShort)))
-					}
+				fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("Current release: %s", releaseTimestamp)))
+
+				if gitCommit := releaseCommit(sshExecutor, currentReleasePath); gitCommit != "" && targetState.LastCommit != gitCommit {
+					targetState.LastCommit = gitCommit
+					changesDetected = true
+					fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("Git commit: %s", shortCommit(gitCommit))))
 				}
 
 				if targetState.LastDeploy.IsZero() {
@@ -805,25 +1382,63 @@ func syncTarget(target config.TargetConfig, targetName string, cfg *config.Confi
 
 	serviceResult := sshExecutor.Execute(fmt.Sprintf("systemctl is-active %s 2>/dev/null", appName))
 	if serviceResult.ExitCode == 0 && strings.TrimSpace(serviceResult.Stdout) == "active" {
-		fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render("Service is active"))
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render("Service is active"))
+	}
+
+	driftReason := ""
+	if target.Domain != nil && target.Domain.Domain != "" && target.Domain.PathPrefix == "" {
+		fmt.Printf("%s Checking domain configuration...\n", labelStyle.Render(style.Arrow()))
+		driftReason = domainDriftReason(target, targetState, remoteServesDomain(sshExecutor, target.Domain.Domain))
+		if driftReason == "" {
+			if targetState.DomainServerIP == "" {
+				targetState.DomainServerID, targetState.DomainServerIP = domainServerIdentity(target, targetState)
+				changesDetected = true
+			}
+			fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render(fmt.Sprintf("%s is configured on this server", target.Domain.Domain)))
+		}
 	}
 
 	if changesDetected {
-		fmt.Printf("%s Saving synced state...\n", labelStyle.Render("→"))
+		fmt.Printf("%s Saving synced state...\n", labelStyle.Render(style.Arrow()))
 		if err := state.SaveState(targetName, targetState); err != nil {
 			return nil, fmt.Errorf("failed to save state: %w", err)
 		}
-		fmt.Printf("%s %s\n", successStyle.Render("  ✓"), mutedStyle.Render("State saved successfully"))
+		fmt.Printf("%s %s\n", successStyle.Render("  "+style.Check()), mutedStyle.Render("State saved successfully"))
 	} else {
-		fmt.Printf("%s %s\n", mutedStyle.Render("ℹ"), mutedStyle.Render("No changes detected - state is already in sync"))
+		fmt.Printf("%s %s\n", mutedStyle.Render(style.Info()), mutedStyle.Render("No changes detected - state is already in sync"))
+	}
+
+	if driftReason != "" {
+		if err := handleDomainDrift(&target, targetName, driftReason, fixDomain); err != nil {
+			return nil, fmt.Errorf("failed to reconfigure domain: %w", err)
+		}
+		if reloaded, err := state.LoadState(targetName); err == nil {
+			targetState = reloaded
+		}
 	}
 
 	return targetState, nil
 }
 
-// getOrAllocatePort gets the port for a target, allocating one if necessary
+// getOrAllocatePort gets the port for a target, allocating one if necessary.
+// Allocating connects to the server to reserve the new port there.
 func getOrAllocatePort(target *config.TargetConfig, targetName string) (int, error) {
-	return utils.GetOrAllocatePort(target, targetName)
+	port, source, err := utils.PlanPort(target, targetName)
+	if err != nil || source != "allocated" {
+		return port, err
+	}
+
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return 0, err
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return 0, fmt.Errorf("failed to connect to reserve a port: %w", err)
+	}
+	defer sshExecutor.Disconnect()
+
+	return utils.GetOrAllocatePort(target, targetName, sshExecutor)
 }
 
 // registerAppWithServer registers an app in the server state
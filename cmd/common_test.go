@@ -3,10 +3,12 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector"
+	sshpkg "lightfold/pkg/ssh"
 )
 
 func TestResolveBuilder_FlagPriority(t *testing.T) {
@@ -233,3 +235,56 @@ func TestResolveBuilder_NilDetection(t *testing.T) {
 		t.Error("Expected non-empty builder name even with nil detection")
 	}
 }
+
+// legacyDeploymentRunner reports that only /srv/my_app exists on the server
+type legacyDeploymentRunner struct{ commands []string }
+
+func (r *legacyDeploymentRunner) Execute(command string) *sshpkg.CommandResult {
+	r.commands = append(r.commands, command)
+	return &sshpkg.CommandResult{Stdout: "my_app\n"}
+}
+
+func TestResolveAppName_AdoptsLegacyDeployment(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	target := config.TargetConfig{ProjectPath: "/home/dev/my-app", Provider: "hetzner"}
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{"my-app": target}}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &legacyDeploymentRunner{}
+	if got := resolveAppName(&target, "my-app", runner); got != "my_app" {
+		t.Fatalf("resolveAppName() = %q, want the legacy deployment my_app", got)
+	}
+
+	// Resolving is read-only: only deploys adopt the name
+	saved, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.AppName != "" || saved.Targets["my-app"].AppName != "" {
+		t.Errorf("resolveAppName() changed the target: app_name = %q, saved %q", target.AppName, saved.Targets["my-app"].AppName)
+	}
+
+	// Once adopted, the name is used without checking the server again
+	target.AppName = "my_app"
+	runner.commands = nil
+	if got := resolveAppName(&target, "my-app", runner); got != "my_app" || len(runner.commands) != 0 {
+		t.Errorf("resolveAppName() after adoption = %q with %d server checks", got, len(runner.commands))
+	}
+}
+
+func TestRenameNginxSiteScript(t *testing.T) {
+	script := renameNginxSiteScript("api-prod", "api")
+	for _, want := range []string{
+		"[ -e '/etc/nginx/sites-available/api-prod.conf' ] || exit 0\n",
+		"mv '/etc/nginx/sites-available/api-prod.conf' '/etc/nginx/sites-available/api.conf' && ln -sf '/etc/nginx/sites-available/api.conf' '/etc/nginx/sites-enabled/api.conf'",
+		"rm -f '/etc/nginx/sites-enabled/api-prod.conf'\n",
+		"nginx -t && systemctl reload nginx",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"lightfold/pkg/builders"
+	"lightfold/pkg/config"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// registerFlagCompletions walks the command tree and attaches dynamic completion
+// to every --target, --provider and --builder flag. It runs from Execute so flags
+// registered in any command file's init are covered.
+func registerFlagCompletions(root *cobra.Command) {
+	completions := map[string]cobra.CompletionFunc{
+		"target":   completeTargetNames,
+		"provider": completeProviderNames,
+		"builder":  completeBuilderNames,
+	}
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for flagName, fn := range completions {
+			if cmd.Flags().Lookup(flagName) == nil {
+				continue
+			}
+			if _, exists := cmd.GetFlagCompletionFunc(flagName); exists {
+				continue
+			}
+			_ = cmd.RegisterFlagCompletionFunc(flagName, fn)
+		}
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
+	}
+	walk(root)
+}
+
+// completeTargetNames offers target names from the local config.
+// It never touches the network and returns nothing if the config is missing or unreadable.
+func completeTargetNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range cfg.Targets {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProviderNames offers provider names accepted by --provider
+func completeProviderNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, bootstrap := range providerBootstraps {
+		if strings.HasPrefix(bootstrap.canonical, toComplete) {
+			names = append(names, bootstrap.canonical)
+		}
+	}
+	if strings.HasPrefix("byos", toComplete) {
+		names = append(names, "byos")
+	}
+	sort.Strings(names)
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBuilderNames offers registered builder names, including ones whose tooling isn't installed locally
+func completeBuilderNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	for _, name := range builders.ListBuilders() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteTargetNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := &config.Config{
+		Targets: map[string]config.TargetConfig{
+			"api-prod":    {Provider: "hetzner"},
+			"api-staging": {Provider: "hetzner"},
+			"web":         {Provider: "digitalocean"},
+		},
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	names, directive := completeTargetNames(deployCmd, nil, "api")
+	if !reflect.DeepEqual(names, []string{"api-prod", "api-staging"}) {
+		t.Errorf("Expected api targets, got %v", names)
+	}
+	if directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("Expected NoFileComp directive, got %v", directive)
+	}
+
+	names, _ = completeTargetNames(deployCmd, nil, "")
+	if len(names) != 3 {
+		t.Errorf("Expected all 3 targets, got %v", names)
+	}
+}
+
+func TestCompleteTargetNames_MissingConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	names, directive := completeTargetNames(deployCmd, nil, "")
+	if len(names) != 0 {
+		t.Errorf("Expected no completions without a config file, got %v", names)
+	}
+	if directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("Expected NoFileComp directive, got %v", directive)
+	}
+}
+
+func TestCompleteProviderAndBuilderNames(t *testing.T) {
+	providers, _ := completeProviderNames(createCmd, nil, "")
+	for _, expected := range []string{"aws", "byos", "digitalocean", "hetzner"} {
+		if !containsCompletion(providers, expected) {
+			t.Errorf("Expected provider %q in %v", expected, providers)
+		}
+	}
+
+	providers, _ = completeProviderNames(createCmd, nil, "h")
+	if !reflect.DeepEqual(providers, []string{"hetzner"}) {
+		t.Errorf("Expected only hetzner for prefix h, got %v", providers)
+	}
+
+	builderNames, _ := completeBuilderNames(deployCmd, nil, "")
+	for _, expected := range []string{"dockerfile", "native", "nixpacks"} {
+		if !containsCompletion(builderNames, expected) {
+			t.Errorf("Expected builder %q in %v", expected, builderNames)
+		}
+	}
+}
+
+func TestRegisterFlagCompletions(t *testing.T) {
+	registerFlagCompletions(rootCmd)
+
+	for _, tc := range []struct {
+		cmd  *cobra.Command
+		flag string
+	}{
+		{deployCmd, "target"},
+		{deployCmd, "builder"},
+		{pushCmd, "target"},
+		{statusCmd, "target"},
+		{destroyCmd, "target"},
+		{logsCmd, "target"},
+		{domainAddCmd, "target"},
+		{createCmd, "provider"},
+	} {
+		if _, ok := tc.cmd.GetFlagCompletionFunc(tc.flag); !ok {
+			t.Errorf("Expected completion for %s --%s", tc.cmd.Name(), tc.flag)
+		}
+	}
+}
+
+func containsCompletion(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -1,27 +1,34 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"lightfold/cmd/ui/deployment"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector"
 	"os"
 	"strings"
 	"syscall"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 var (
-	configStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	configLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	configValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	configMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	configErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	configSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82")).Bold(true)
+	configStyle        = style.Title
+	configLabelStyle   = style.Header
+	configValueStyle   = style.Value
+	configMutedStyle   = style.Faint
+	configErrorStyle   = style.Error
+	configSuccessStyle = style.Success.Bold(true)
+)
+
+var (
+	configShowTargetFlag string
+	configSetTargetFlag  string
+	configSetTokenForce  bool
 )
 
 var configCmd = &cobra.Command{
@@ -37,18 +44,18 @@ var configListCmd = &cobra.Command{
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		tokens, err := config.LoadTokens()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if jsonOutput {
 			output := map[string]interface{}{
-				"targets": cfg.Targets,
+				"targets": redactedTargets(cfg),
 				"providers": func() map[string]bool {
 					result := make(map[string]bool)
 					for provider := range tokens {
@@ -142,12 +149,22 @@ var configListCmd = &cobra.Command{
 			fmt.Println(configMutedStyle.Render("  No provider tokens configured"))
 		} else {
 			for provider := range tokens {
-				fmt.Printf("  %s %s\n", configLabelStyle.Render("•"), configValueStyle.Render(provider))
+				fmt.Printf("  %s %s\n", configLabelStyle.Render(style.Bullet()), configValueStyle.Render(provider))
 			}
 		}
 
 		fmt.Printf("\n%s\n", configStyle.Render("Global Settings:"))
 		fmt.Printf("  %s: %s\n", configLabelStyle.Render("Keep Releases"), configValueStyle.Render(fmt.Sprintf("%d", cfg.NumReleases)))
+		packWorkers := "auto (CPU count)"
+		if cfg.PackWorkers > 0 {
+			packWorkers = fmt.Sprintf("%d", cfg.PackWorkers)
+		}
+		fmt.Printf("  %s: %s\n", configLabelStyle.Render("Pack Workers"), configValueStyle.Render(packWorkers))
+		envEncryption := "off"
+		if cfg.EnvEncryption != nil {
+			envEncryption = cfg.EnvEncryption.Describe()
+		}
+		fmt.Printf("  %s: %s\n", configLabelStyle.Render("Env Encryption"), configValueStyle.Render(envEncryption))
 		fmt.Println()
 	},
 }
@@ -157,9 +174,11 @@ var configSetTokenCmd = &cobra.Command{
 	Short: "Set or update provider API token",
 	Long: `Set or update API token for a cloud provider.
 
-Supported providers: digitalocean, hetzner, s3
+Supported providers: digitalocean, hetzner, vultr, linode, flyio, aws, s3
 
-If token is not provided as an argument, you will be prompted to enter it securely.`,
+If token is not provided as an argument, you will be prompted to enter it securely.
+The token is checked against the provider's API first and is not stored if it is
+rejected; use --force to store it anyway.`,
 	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		provider := strings.ToLower(args[0])
@@ -173,30 +192,49 @@ If token is not provided as an argument, you will be prompted to enter it secure
 			fmt.Println()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error reading token: %v", err)))
-				os.Exit(1)
+				exit(1)
 			}
 			token = string(tokenBytes)
 		}
 
 		if strings.TrimSpace(token) == "" {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render("Token cannot be empty"))
-			os.Exit(1)
+			exit(1)
+		}
+
+		if !configSetTokenForce {
+			ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+			health := checkToken(ctx, provider, token)
+			cancel()
+
+			if health.Checked && !health.Valid {
+				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Token for '%s' was rejected: %s", provider, health.Error)))
+				fmt.Fprintf(os.Stderr, "%s\n", configMutedStyle.Render("Use --force to store it anyway"))
+				exit(1)
+			}
+			if health.Valid {
+				account := ""
+				if health.Account != "" {
+					account = " (" + health.Account + ")"
+				}
+				fmt.Printf("%s\n", configSuccessStyle.Render(style.Check()+" Token is valid"+account))
+			}
 		}
 
 		tokens, err := config.LoadTokens()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		tokens.SetToken(provider, token)
 
 		if err := tokens.SaveTokens(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("✓ Token for '%s' saved successfully", provider)))
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Token for '%s' saved successfully", style.Check(), provider)))
 	},
 }
 
@@ -210,20 +248,16 @@ var configGetTokenCmd = &cobra.Command{
 		tokens, err := config.LoadTokens()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		token := tokens.GetToken(provider)
 		if token == "" {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("No token found for provider: %s", provider)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		maskedToken := "****" + token[len(token)-4:]
-		if len(token) < 10 {
-			maskedToken = "********"
-		}
-		fmt.Printf("%s: %s\n", configLabelStyle.Render(provider), configValueStyle.Render(maskedToken))
+		fmt.Printf("%s: %s\n", configLabelStyle.Render(provider), configValueStyle.Render(tokenPreview(provider, token)))
 	},
 }
 
@@ -237,12 +271,12 @@ var configDeleteTokenCmd = &cobra.Command{
 		tokens, err := config.LoadTokens()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if !tokens.HasToken(provider) {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("No token found for provider: %s", provider)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Printf("Delete token for %s? (y/N): ", provider)
@@ -257,10 +291,10 @@ var configDeleteTokenCmd = &cobra.Command{
 
 		if err := tokens.SaveTokens(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving tokens: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("✓ Token for '%s' deleted successfully", provider)))
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Token for '%s' deleted successfully", style.Check(), provider)))
 	},
 }
 
@@ -275,28 +309,62 @@ Old releases beyond this count will be automatically deleted after each deployme
 		var count int
 		if _, err := fmt.Sscanf(args[0], "%d", &count); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render("Invalid count: must be a positive integer"))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if count < 1 {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render("Count must be at least 1"))
-			os.Exit(1)
+			exit(1)
 		}
 
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		cfg.NumReleases = count
 
 		if err := cfg.SaveConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("✓ Keep releases set to %d", count)))
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Keep releases set to %d", style.Check(), count)))
+	},
+}
+
+var configSetPackWorkersCmd = &cobra.Command{
+	Use:   "set-pack-workers <count>",
+	Short: "Set how many files are read in parallel when packing a release",
+	Long: `Set how many files are read and hashed in parallel when creating the release
+tarball. Use 0 to match the number of CPUs (default).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var count int
+		if _, err := fmt.Sscanf(args[0], "%d", &count); err != nil || count < 0 {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render("Invalid count: must be 0 or a positive integer"))
+			exit(1)
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading config: %v", err)))
+			exit(1)
+		}
+
+		cfg.PackWorkers = count
+
+		if err := cfg.SaveConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
+			exit(1)
+		}
+
+		if count == 0 {
+			fmt.Printf("%s\n", configSuccessStyle.Render(style.Check()+" Pack workers set to the number of CPUs"))
+			return
+		}
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Pack workers set to %d", style.Check(), count)))
 	},
 }
 
@@ -310,13 +378,13 @@ This allows you to customize the deployment plan detected by the framework detec
 		targetName := cmd.Flag("target").Value.String()
 		if targetName == "" {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render("Error: --target flag is required"))
-			os.Exit(1)
+			exit(1)
 		}
 
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		target, exists := cfg.GetTarget(targetName)
@@ -324,12 +392,12 @@ This allows you to customize the deployment plan detected by the framework detec
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Target '%s' not found", targetName)))
 			fmt.Println("\nAvailable targets:")
 			for name := range cfg.Targets {
-				fmt.Printf("  • %s\n", name)
+				fmt.Printf("  %s %s\n", style.Bullet(), name)
 			}
-			os.Exit(1)
+			exit(1)
 		}
 
-		detection := detector.DetectFramework(target.ProjectPath)
+		detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
 
 		buildCmds := detection.BuildPlan
 		runCmds := detection.RunPlan
@@ -345,7 +413,7 @@ This allows you to customize the deployment plan detected by the framework detec
 		wantsDeploy, newBuildCmds, newRunCmds, err := deployment.ShowDeploymentEditor(detection, buildCmds, runCmds)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if !wantsDeploy {
@@ -362,13 +430,158 @@ This allows you to customize the deployment plan detected by the framework detec
 		cfg.SetTarget(targetName, target)
 		if err := cfg.SaveConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("✓ Deployment configuration updated for target '%s'", targetName)))
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Deployment configuration updated for target '%s'", style.Check(), targetName)))
 	},
 }
 
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration of a target",
+	Long: `Show every stored setting of a target as key = value, with secrets masked.
+
+Examples:
+  lightfold config show                  # Target for the current directory
+  lightfold config show --target myapp
+  lightfold config show --target myapp --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, configShowTargetFlag, "")
+
+		view, err := targetSettingsView(target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		redactEncryptedEnv(cfg, view)
+
+		if jsonOutput {
+			settings := make(map[string]string, len(view))
+			for _, setting := range view {
+				settings[setting.Key] = setting.Value
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(map[string]interface{}{"target": targetName, "settings": settings})
+			return
+		}
+
+		fmt.Println(configStyle.Render(fmt.Sprintf("Target: %s", targetName)))
+		for _, setting := range view {
+			fmt.Printf("  %s = %s\n", configLabelStyle.Render(setting.Key), configValueStyle.Render(setting.Value))
+		}
+		fmt.Printf("\n%s\n", configMutedStyle.Render("Change a setting with 'lightfold config set --target "+targetName+" key=value'"))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [--target <name>] key=value [key=value...]",
+	Short: "Change target and global settings",
+	Long: `Change one or more settings of a target, or global settings without
+--target. Values are validated before anything is saved; if one is invalid,
+no change is written.
+
+Keys:
+` + configSetKeysHelp() + `
+Global keys:
+` + configSetGlobalKeysHelp() + `
+Examples:
+  lightfold config set --target myapp port=8080
+  lightfold config set --target myapp builder=nixpacks deploy.skip_build=true
+  lightfold config set --target myapp do.size=s-2vcpu-4gb
+  lightfold config set telemetry=on telemetry.endpoint=https://crash.example.com/report`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		if configSetTargetFlag == "" {
+			for _, arg := range args {
+				key, value, err := parseSettingArg(arg)
+				if err == nil {
+					err = applyGlobalSetting(cfg, key, value)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+					exit(1)
+				}
+			}
+			if err := validateGlobalSettings(cfg); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			if err := cfg.SaveConfig(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
+				exit(1)
+			}
+			for _, arg := range args {
+				key, value, _ := parseSettingArg(arg)
+				fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s %s = %s", style.Check(), key, strings.TrimSpace(value))))
+			}
+			return
+		}
+
+		target, exists := cfg.GetTarget(configSetTargetFlag)
+		if !exists {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Target '%s' not found", configSetTargetFlag)))
+			exit(1)
+		}
+
+		for _, arg := range args {
+			key, value, err := parseSettingArg(arg)
+			if err == nil {
+				err = applyTargetSetting(&target, key, value)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+		}
+
+		if err := checkBaseDir(configSetTargetFlag, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		if err := cfg.SetTarget(configSetTargetFlag, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		if err := cfg.SaveConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
+			exit(1)
+		}
+
+		for _, arg := range args {
+			key, value, _ := parseSettingArg(arg)
+			value = strings.TrimSpace(value)
+			if cfg.EnvEncryption != nil && strings.HasPrefix(key, envVarsSettingPrefix) {
+				value = encryptedEnvPlaceholder
+			}
+			fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s %s = %s", style.Check(), key, value)))
+		}
+		fmt.Println(configMutedStyle.Render("Run 'lightfold deploy' to apply the changes to the server"))
+	},
+}
+
+func configSetGlobalKeysHelp() string {
+	var b strings.Builder
+	for _, setting := range globalSettings {
+		fmt.Fprintf(&b, "  %-22s %s\n", setting.Key, setting.Description)
+	}
+	return b.String()
+}
+
+func configSetKeysHelp() string {
+	var b strings.Builder
+	for _, setting := range targetSettings {
+		fmt.Fprintf(&b, "  %-22s %s\n", setting.Key, setting.Description)
+	}
+	return b.String()
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 
@@ -376,9 +589,18 @@ func init() {
 	configCmd.AddCommand(configSetTokenCmd)
 	configCmd.AddCommand(configGetTokenCmd)
 	configCmd.AddCommand(configDeleteTokenCmd)
+	configCmd.AddCommand(configTokensCmd)
 	configCmd.AddCommand(configSetNumReleasesCmd)
+	configCmd.AddCommand(configSetPackWorkersCmd)
 	configCmd.AddCommand(configEditDeploymentCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSetCmd)
+
+	configSetTokenCmd.Flags().BoolVar(&configSetTokenForce, "force", false, "Store the token even if the provider rejects it")
 
 	configEditDeploymentCmd.Flags().String("target", "", "Target name to edit")
 	configEditDeploymentCmd.MarkFlagRequired("target")
+
+	configShowCmd.Flags().StringVar(&configShowTargetFlag, "target", "", "Target name (defaults to current directory)")
+	configSetCmd.Flags().StringVar(&configSetTargetFlag, "target", "", "Target name to change (omit for global keys)")
 }
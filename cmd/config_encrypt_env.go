@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// encryptedEnvPlaceholder stands in for env values in output when they are
+// stored encrypted
+const encryptedEnvPlaceholder = "<encrypted>"
+
+var (
+	encryptEnvSSHKeyFlag   string
+	encryptEnvKeychainFlag bool
+	encryptEnvDisableFlag  bool
+	encryptEnvPrintKeyFlag bool
+)
+
+var configEncryptEnvCmd = &cobra.Command{
+	Use:   "encrypt-env",
+	Short: "Store the env values in config.json encrypted",
+	Long: `Encrypt the deploy.env_vars values of every target in ~/.lightfold/config.json.
+
+Values are encrypted with AES-256-GCM and decrypted in memory when lightfold
+loads the config. The key is kept in the OS keychain (macOS Keychain, or the
+Secret Service through secret-tool on Linux), or derived from an SSH private
+key with --ssh-key. Where neither is available, e.g. in CI, set
+LIGHTFOLD_ENV_KEY to the key printed by --print-key.
+
+Running it again encrypts values added since; --disable stores them as
+plaintext again.
+
+Examples:
+  lightfold config encrypt-env
+  lightfold config encrypt-env --ssh-key ~/.ssh/id_ed25519
+  lightfold config encrypt-env --print-key   # Key for LIGHTFOLD_ENV_KEY in CI
+  lightfold config encrypt-env --disable`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		enc, err := envEncryptionFromFlags(cfg.EnvEncryption, encryptEnvSSHKeyFlag, encryptEnvKeychainFlag, encryptEnvDisableFlag)
+		if err == nil {
+			err = cfg.SetEnvEncryption(enc)
+		}
+		if err == nil {
+			err = cfg.SaveConfig()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		values, targets := countEnvValues(cfg)
+		if enc == nil {
+			fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Stored %d env values across %d targets as plaintext", style.Check(), values, targets)))
+			return
+		}
+		fmt.Printf("%s\n", configSuccessStyle.Render(fmt.Sprintf("%s Encrypted %d env values across %d targets", style.Check(), values, targets)))
+		fmt.Printf("%s\n", configMutedStyle.Render("Key: "+enc.Describe()))
+
+		if encryptEnvPrintKeyFlag {
+			key, err := config.ExportEnvKey(enc)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			fmt.Printf("\n%s=%s\n", config.EnvKeyVar, key)
+			fmt.Printf("%s\n", configMutedStyle.Render("Store it as a CI secret; anyone with it can read the env values in config.json"))
+		}
+	},
+}
+
+// envEncryptionFromFlags returns the encryption encrypt-env switches to: the
+// current one unless a flag picks another, the keychain by default, or nil
+// for --disable
+func envEncryptionFromFlags(current *config.EnvEncryptionConfig, sshKey string, keychain, disable bool) (*config.EnvEncryptionConfig, error) {
+	if disable {
+		if sshKey != "" || keychain {
+			return nil, fmt.Errorf("--disable cannot be combined with --ssh-key or --keychain")
+		}
+		return nil, nil
+	}
+	if sshKey != "" && keychain {
+		return nil, fmt.Errorf("--ssh-key and --keychain cannot be combined")
+	}
+	if sshKey != "" {
+		if strings.HasPrefix(sshKey, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				sshKey = filepath.Join(home, sshKey[2:])
+			}
+		}
+		absPath, err := filepath.Abs(sshKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH key path: %w", err)
+		}
+		return &config.EnvEncryptionConfig{KeySource: config.EnvKeySourceSSH, SSHKey: absPath}, nil
+	}
+	if current != nil && !keychain {
+		return current, nil
+	}
+	return &config.EnvEncryptionConfig{KeySource: config.EnvKeySourceKeychain}, nil
+}
+
+// countEnvValues returns how many env values are stored and in how many targets
+func countEnvValues(cfg *config.Config) (values, targets int) {
+	for _, target := range cfg.Targets {
+		if target.Deploy == nil || len(target.Deploy.EnvVars) == 0 {
+			continue
+		}
+		values += len(target.Deploy.EnvVars)
+		targets++
+	}
+	return values, targets
+}
+
+// redactedTargets returns the targets with their env values replaced by
+// <encrypted> when the config stores them encrypted
+func redactedTargets(cfg *config.Config) map[string]config.TargetConfig {
+	if cfg.EnvEncryption == nil {
+		return cfg.Targets
+	}
+	targets := make(map[string]config.TargetConfig, len(cfg.Targets))
+	for name, target := range cfg.Targets {
+		if target.Deploy != nil && len(target.Deploy.EnvVars) > 0 {
+			deploy := *target.Deploy
+			deploy.EnvVars = make(map[string]string, len(target.Deploy.EnvVars))
+			for key := range target.Deploy.EnvVars {
+				deploy.EnvVars[key] = encryptedEnvPlaceholder
+			}
+			target.Deploy = &deploy
+		}
+		targets[name] = target
+	}
+	return targets
+}
+
+// redactEncryptedEnv shows env values in a settings view as <encrypted> when
+// the config stores them encrypted
+func redactEncryptedEnv(cfg *config.Config, view []configSetting) {
+	if cfg.EnvEncryption == nil {
+		return
+	}
+	for i := range view {
+		if strings.HasPrefix(view[i].Key, envVarsSettingPrefix) {
+			view[i].Value = encryptedEnvPlaceholder
+		}
+	}
+}
+
+// exitIfEnvLocked stops a command that would send a target's env values to
+// a server when they could not be decrypted
+func exitIfEnvLocked(cfg *config.Config, targetName string) {
+	if err := cfg.CheckTargetEnv(targetName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
+
+func init() {
+	configCmd.AddCommand(configEncryptEnvCmd)
+
+	configEncryptEnvCmd.Flags().StringVar(&encryptEnvSSHKeyFlag, "ssh-key", "", "Derive the key from this SSH private key instead of the OS keychain")
+	configEncryptEnvCmd.Flags().BoolVar(&encryptEnvKeychainFlag, "keychain", false, "Keep the key in the OS keychain (the default)")
+	configEncryptEnvCmd.Flags().BoolVar(&encryptEnvDisableFlag, "disable", false, "Store env values as plaintext again")
+	configEncryptEnvCmd.Flags().BoolVar(&encryptEnvPrintKeyFlag, "print-key", false, "Print the key to set as LIGHTFOLD_ENV_KEY where the key source is unavailable")
+}
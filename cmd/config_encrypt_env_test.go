@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEnvEncryptionFromFlags(t *testing.T) {
+	sshEnc := &config.EnvEncryptionConfig{KeySource: config.EnvKeySourceSSH, SSHKey: "/keys/id_ed25519"}
+
+	tests := []struct {
+		name     string
+		current  *config.EnvEncryptionConfig
+		sshKey   string
+		keychain bool
+		disable  bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "defaults to the keychain", want: config.EnvKeySourceKeychain},
+		{name: "keeps the current source", current: sshEnc, want: config.EnvKeySourceSSH},
+		{name: "switches to the keychain", current: sshEnc, keychain: true, want: config.EnvKeySourceKeychain},
+		{name: "switches to an ssh key", sshKey: "/keys/id_rsa", want: config.EnvKeySourceSSH},
+		{name: "disables", current: sshEnc, disable: true},
+		{name: "rejects disable with a source", disable: true, keychain: true, wantErr: true},
+		{name: "rejects both sources", sshKey: "/keys/id_rsa", keychain: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := envEncryptionFromFlags(tt.current, tt.sshKey, tt.keychain, tt.disable)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("got %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.KeySource != tt.want {
+				t.Errorf("got %+v, want key source %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptEnvMigration(t *testing.T) {
+	home, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(home, "id_ed25519")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := newSettingsTarget(t)
+	target.Deploy = &config.DeploymentOptions{EnvVars: map[string]string{"DATABASE_URL": "postgres://u:hunter2@db/app"}}
+	cfg.SetTarget("shop", target)
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// What encrypt-env --ssh-key does
+	enc, err := envEncryptionFromFlags(cfg.EnvEncryption, keyPath, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetEnvEncryption(enc); err != nil {
+		t.Fatalf("SetEnvEncryption() error: %v", err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(config.GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "hunter2") {
+		t.Errorf("config.json still holds the plaintext value:\n%s", raw)
+	}
+
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Targets["shop"].Deploy.EnvVars["DATABASE_URL"]; got != "postgres://u:hunter2@db/app" {
+		t.Errorf("DATABASE_URL = %q, want it decrypted on load", got)
+	}
+	if values, targets := countEnvValues(loaded); values != 1 || targets != 1 {
+		t.Errorf("countEnvValues() = %d, %d, want 1, 1", values, targets)
+	}
+
+	view, err := targetSettingsView(loaded.Targets["shop"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactEncryptedEnv(loaded, view)
+	for _, setting := range view {
+		if setting.Key == "deploy.env_vars.DATABASE_URL" && setting.Value != encryptedEnvPlaceholder {
+			t.Errorf("config show value = %q, want %s", setting.Value, encryptedEnvPlaceholder)
+		}
+	}
+	if got := redactedTargets(loaded)["shop"].Deploy.EnvVars["DATABASE_URL"]; got != encryptedEnvPlaceholder {
+		t.Errorf("config list --json value = %q, want %s", got, encryptedEnvPlaceholder)
+	}
+	if loaded.Targets["shop"].Deploy.EnvVars["DATABASE_URL"] == encryptedEnvPlaceholder {
+		t.Error("redactedTargets() changed the loaded config")
+	}
+}
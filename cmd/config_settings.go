@@ -0,0 +1,708 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/pkg/builders"
+	"lightfold/pkg/builders/nixpacks"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/util"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// targetSetting is a key `lightfold config set` can change
+type targetSetting struct {
+	Key         string
+	Description string
+	set         func(target *config.TargetConfig, value string) error
+}
+
+// providerKeyPrefixes maps config key prefixes to provider names; other providers use their own name
+var providerKeyPrefixes = map[string]string{
+	"do": "digitalocean",
+}
+
+// providerSizeFields and providerRegionFields name the provider config fields
+// holding the server size and region, which are validated together
+var (
+	providerSizeFields = map[string]string{
+		"digitalocean": "size",
+		"hetzner":      "server_type",
+		"vultr":        "plan",
+		"linode":       "plan",
+		"aws":          "instance_type",
+		"flyio":        "size",
+	}
+	providerRegionFields = map[string]string{
+		"digitalocean": "region",
+		"hetzner":      "location",
+		"vultr":        "region",
+		"linode":       "region",
+		"aws":          "region",
+		"flyio":        "region",
+	}
+)
+
+// configSetProvider returns a provider client used to check sizes and regions,
+// or nil when no token is stored for it
+var configSetProvider = func(name string) providers.Provider {
+	tokens, err := config.LoadTokens()
+	if err != nil || !tokens.HasToken(name) {
+		return nil
+	}
+	provider, err := providers.GetProvider(name, tokens.GetToken(name))
+	if err != nil {
+		return nil
+	}
+	return provider
+}
+
+var targetSettings = buildTargetSettings()
+
+func buildTargetSettings() []targetSetting {
+	settings := []targetSetting{
+		{Key: "builder", Description: "Builder used on deploy (native, nixpacks, dockerfile)", set: setBuilder},
+		{Key: "port", Description: "Application port (1-65535)", set: setPort},
+		{Key: "container_port", Description: "Port the app listens on inside its container, dockerfile builder (0 reads the Dockerfile)", set: func(t *config.TargetConfig, v string) error {
+			port, err := strconv.Atoi(v)
+			if err != nil || port < 0 || port > 65535 {
+				return fmt.Errorf("container port must be between 1 and 65535 (0 reads the Dockerfile), got %q", v)
+			}
+			t.ContainerPort = port
+			return nil
+		}},
+		{Key: "nixpacks_version", Description: "Nixpacks version the nixpacks builder must use, e.g. 1.29.1 (empty unpins)", set: func(t *config.TargetConfig, v string) error {
+			if v != "" {
+				if err := nixpacks.ValidateVersion(v); err != nil {
+					return err
+				}
+			}
+			t.NixpacksVersion = strings.TrimPrefix(v, "v")
+			return nil
+		}},
+		{Key: "framework", Description: "Framework the app deploys as instead of detection's pick (empty clears)", set: setFrameworkOverride},
+		{Key: "domain.domain", Description: "Domain served by the target", set: setDomain},
+		{Key: "domain.email", Description: "Email used for SSL certificate registration", set: func(t *config.TargetConfig, v string) error {
+			if v != "" && !strings.Contains(v, "@") {
+				return fmt.Errorf("invalid email address: %s", v)
+			}
+			ensureDomain(t).Email = v
+			return nil
+		}},
+		{Key: "domain.http2", Description: "Serve HTTPS over HTTP/2, on unless set to false (true/false)", set: func(t *config.TargetConfig, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			domain := ensureDomain(t)
+			domain.HTTP2 = nil
+			if !enabled {
+				domain.HTTP2 = &enabled
+			}
+			return nil
+		}},
+		{Key: "domain.http3", Description: "Also serve HTTP/3 over QUIC on UDP port 443, needs nginx 1.25+ (true/false)", set: func(t *config.TargetConfig, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDomain(t).HTTP3 = enabled
+			return nil
+		}},
+		{Key: "protected", Description: "Require typing the target name to push, deploy or destroy (true/false)", set: func(t *config.TargetConfig, v string) error {
+			protected, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			t.Protected = protected
+			return nil
+		}},
+		{Key: "deploy.skip_build", Description: "Skip the build step on deploy (true/false)", set: func(t *config.TargetConfig, v string) error {
+			skip, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).SkipBuild = skip
+			return nil
+		}},
+		{Key: "deploy.build_command", Description: "Build command override", set: func(t *config.TargetConfig, v string) error {
+			ensureDeploy(t).BuildCommand = v
+			return nil
+		}},
+		{Key: "deploy.run_command", Description: "Run command override", set: func(t *config.TargetConfig, v string) error {
+			ensureDeploy(t).RunCommand = v
+			return nil
+		}},
+		{Key: "deploy.migration_command", Description: "Migration command run before each release goes live (empty uses the framework default)", set: func(t *config.TargetConfig, v string) error {
+			ensureDeploy(t).MigrationCommand = v
+			return nil
+		}},
+		{Key: "deploy.skip_migrations", Description: "Never run migrations on deploy (true/false)", set: func(t *config.TargetConfig, v string) error {
+			skip, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).SkipMigrations = skip
+			return nil
+		}},
+		{Key: "deploy.first_deploy_commands", Description: "Command run once in the release after the app's first healthy deploy, e.g. to seed data (empty removes it)", set: func(t *config.TargetConfig, v string) error {
+			ensureDeploy(t).FirstDeployCommands = nil
+			if v = strings.TrimSpace(v); v != "" {
+				ensureDeploy(t).FirstDeployCommands = []string{v}
+			}
+			return nil
+		}},
+		{Key: "deploy.skip_build_memory_check", Description: "Build without checking the server has the memory the framework needs (true/false)", set: func(t *config.TargetConfig, v string) error {
+			skip, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).SkipBuildMemoryCheck = skip
+			return nil
+		}},
+		{Key: "deploy.proxy_health_check", Description: "Also health check through nginx after each deploy and roll back when it fails (true/false; defaults to true with a domain)", set: func(t *config.TargetConfig, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).ProxyHealthCheck = &enabled
+			return nil
+		}},
+		{Key: "deploy.base_dir", Description: "Directory apps are deployed under on the server, e.g. /opt/apps (empty uses /srv); cannot change once the app is deployed", set: setBaseDir},
+		{Key: "deploy.subdir", Description: "Monorepo app directory to deploy, e.g. apps/web; Turborepo and Nx builds are scoped to it (empty deploys the whole project)", set: setSubdir},
+		{Key: "deploy.drain_seconds", Description: "Seconds allowed for in-flight requests on restart", set: func(t *config.TargetConfig, v string) error {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				return fmt.Errorf("expected a non-negative number of seconds, got %q", v)
+			}
+			ensureDeploy(t).DrainSeconds = seconds
+			return nil
+		}},
+		{Key: "deploy.keep_releases", Description: "Releases kept on the server after a deploy (0 uses the global keep_releases)", set: func(t *config.TargetConfig, v string) error {
+			return setNonNegative(v, &ensureDeploy(t).KeepReleases)
+		}},
+		{Key: "deploy.workers", Description: "Gunicorn/uvicorn workers (0 sizes from the server)", set: func(t *config.TargetConfig, v string) error {
+			return setNonNegative(v, &ensureDeploy(t).Workers)
+		}},
+		{Key: "deploy.threads", Description: "Gunicorn threads per worker, or Node's libuv threadpool size", set: func(t *config.TargetConfig, v string) error {
+			return setNonNegative(v, &ensureDeploy(t).Threads)
+		}},
+		{Key: "deploy.max_requests", Description: "Requests a worker serves before it is restarted", set: func(t *config.TargetConfig, v string) error {
+			return setNonNegative(v, &ensureDeploy(t).MaxRequests)
+		}},
+		{Key: "deploy.static_paths", Description: "Directories nginx serves, e.g. /media/=shared/media,/assets/=current/public/assets (empty restores the framework defaults)", set: setStaticPaths},
+		{Key: "deploy.disable_static_paths", Description: "Proxy static and media URLs to the app instead of nginx (true/false)", set: func(t *config.TargetConfig, v string) error {
+			disable, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).DisableStaticPaths = disable
+			return nil
+		}},
+		{Key: "deploy.build_output_dirs", Description: "Static sites: build output subdirectories served under URL prefixes, e.g. /=en,/de/=de,/fr/=fr (empty serves the whole build output)", set: setBuildOutputDirs},
+		{Key: "deploy.expose_port", Description: "Bind the app on all interfaces so it is reachable directly on its port, not only through nginx (true/false)", set: func(t *config.TargetConfig, v string) error {
+			expose, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureDeploy(t).ExposePort = expose
+			return nil
+		}},
+		{Key: "deploy.maintenance_allow", Description: "Paths still proxied to the app in maintenance mode, e.g. /healthz,/api/status (empty allows /healthz)", set: setMaintenanceAllow},
+		{Key: "assets.bucket", Description: "S3 bucket the framework's built assets are uploaded to after each build (empty turns uploads off)", set: func(t *config.TargetConfig, v string) error {
+			ensureAssets(t).Bucket = v
+			return nil
+		}},
+		{Key: "assets.region", Description: "Region of the asset bucket (empty uses the AWS default)", set: func(t *config.TargetConfig, v string) error {
+			ensureAssets(t).Region = v
+			return nil
+		}},
+		{Key: "assets.prefix", Description: "Key prefix for assets inside the bucket, e.g. myapp", set: func(t *config.TargetConfig, v string) error {
+			if strings.Contains(v, "..") {
+				return fmt.Errorf("prefix cannot contain '..': %s", v)
+			}
+			ensureAssets(t).Prefix = strings.Trim(v, "/")
+			return nil
+		}},
+		{Key: "assets.public_url", Description: "URL the asset bucket is served from, e.g. https://d111111abcdef8.cloudfront.net", set: func(t *config.TargetConfig, v string) error {
+			if v != "" && !strings.HasPrefix(v, "https://") && !strings.HasPrefix(v, "http://") {
+				return fmt.Errorf("expected an http:// or https:// URL, got %q", v)
+			}
+			ensureAssets(t).PublicURL = strings.TrimRight(v, "/")
+			return nil
+		}},
+		{Key: "assets.continue_on_error", Description: "Deploy even when the asset upload fails (true/false)", set: func(t *config.TargetConfig, v string) error {
+			continueOnError, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", v)
+			}
+			ensureAssets(t).ContinueOnError = continueOnError
+			return nil
+		}},
+		{Key: "ssh.keepalive_seconds", Description: "Seconds between SSH keepalive requests (0 default, -1 off)", set: func(t *config.TargetConfig, v string) error {
+			return setOptionalCount(v, &ensureSSH(t).KeepAliveSeconds)
+		}},
+		{Key: "ssh.keepalive_max_missed", Description: "Unanswered SSH keepalives before reconnecting (0 default)", set: func(t *config.TargetConfig, v string) error {
+			return setNonNegative(v, &ensureSSH(t).KeepAliveMaxMissed)
+		}},
+		{Key: "ssh.reconnect_attempts", Description: "Redials of a dropped SSH connection (0 default, -1 off)", set: func(t *config.TargetConfig, v string) error {
+			return setOptionalCount(v, &ensureSSH(t).ReconnectAttempts)
+		}},
+	}
+
+	for _, provider := range util.SortedKeys(providerSizeFields) {
+		prefix := providerKeyPrefix(provider)
+		settings = append(settings,
+			providerSetting(prefix, provider, providerSizeFields[provider], "Server size"),
+			providerSetting(prefix, provider, providerRegionFields[provider], "Region"))
+	}
+
+	return settings
+}
+
+func setNonNegative(v string, field *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a non-negative number, got %q", v)
+	}
+	*field = n
+	return nil
+}
+
+// setOptionalCount accepts a non-negative number, or -1 to turn the feature off
+func setOptionalCount(v string, field *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < -1 {
+		return fmt.Errorf("expected a non-negative number or -1, got %q", v)
+	}
+	*field = n
+	return nil
+}
+
+// setStaticPaths parses comma-separated url=dir pairs into the static path overrides
+func setStaticPaths(t *config.TargetConfig, v string) error {
+	paths := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		urlPrefix, dir, ok := strings.Cut(pair, "=")
+		urlPrefix, dir = strings.TrimSpace(urlPrefix), strings.TrimSpace(dir)
+		if !ok || !strings.HasPrefix(urlPrefix, "/") || dir == "" {
+			return fmt.Errorf("expected /url/=directory pairs, got %q", pair)
+		}
+		if strings.Contains(dir, "..") {
+			return fmt.Errorf("static directory cannot contain '..': %s", dir)
+		}
+		paths[urlPrefix] = dir
+	}
+
+	if len(paths) == 0 {
+		paths = nil
+	}
+	ensureDeploy(t).StaticPaths = paths
+	return nil
+}
+
+// setMaintenanceAllow parses comma-separated paths left reachable in
+// maintenance mode
+func setMaintenanceAllow(t *config.TargetConfig, v string) error {
+	paths := parseMaintenanceAllow(v)
+	if err := deploy.ValidateMaintenancePaths(paths); err != nil {
+		return err
+	}
+	ensureDeploy(t).MaintenanceAllow = paths
+	return nil
+}
+
+// parseMaintenanceAllow splits a comma-separated path list, dropping blanks
+func parseMaintenanceAllow(v string) []string {
+	var paths []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// setBuildOutputDirs parses comma-separated url=dir pairs into the build
+// output directories, keeping their order
+func setBuildOutputDirs(t *config.TargetConfig, v string) error {
+	var dirs []config.BuildOutputDir
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		urlPrefix, dir, ok := strings.Cut(pair, "=")
+		urlPrefix, dir = strings.TrimSpace(urlPrefix), strings.Trim(strings.TrimSpace(dir), "/")
+		if !ok || !strings.HasPrefix(urlPrefix, "/") || dir == "" {
+			return fmt.Errorf("expected /url/=directory pairs, got %q", pair)
+		}
+		if strings.Contains(dir, "..") || strings.ContainsAny(dir, "'\" \t") {
+			return fmt.Errorf("build output directory must be a plain path inside the build output: %s", dir)
+		}
+		key := strings.Trim(urlPrefix, "/")
+		if seen[key] {
+			return fmt.Errorf("URL prefix %s is mapped twice", urlPrefix)
+		}
+		seen[key] = true
+		dirs = append(dirs, config.BuildOutputDir{PathPrefix: urlPrefix, Dir: dir})
+	}
+	ensureDeploy(t).BuildOutputDirs = dirs
+	return nil
+}
+
+// setSubdir selects the app of a monorepo the target deploys. The directory
+// must exist in the project so a typo does not deploy the workspace root.
+func setSubdir(t *config.TargetConfig, v string) error {
+	dir := strings.Trim(strings.TrimSpace(v), "/")
+	if dir == "" {
+		ensureDeploy(t).Subdir = ""
+		return nil
+	}
+	if filepath.IsAbs(dir) || strings.Contains(dir, "..") || strings.ContainsAny(dir, "'\" \t") {
+		return fmt.Errorf("subdir must be a plain path inside the project: %s", dir)
+	}
+	if info, err := os.Stat(filepath.Join(t.ProjectPath, dir)); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory in %s", dir, t.ProjectPath)
+	}
+	ensureDeploy(t).Subdir = dir
+	return nil
+}
+
+func providerSetting(prefix, provider, field, description string) targetSetting {
+	return targetSetting{
+		Key:         prefix + "." + field,
+		Description: fmt.Sprintf("%s (%s targets)", description, provider),
+		set: func(t *config.TargetConfig, v string) error {
+			if t.Provider != provider {
+				return fmt.Errorf("target uses provider %s, not %s", t.Provider, provider)
+			}
+			if v == "" {
+				return fmt.Errorf("%s cannot be empty", field)
+			}
+			return setProviderField(t, provider, field, v)
+		},
+	}
+}
+
+func providerKeyPrefix(provider string) string {
+	for prefix, name := range providerKeyPrefixes {
+		if name == provider {
+			return prefix
+		}
+	}
+	return provider
+}
+
+// settingKeys lists the keys accepted by `config set`
+func settingKeys() []string {
+	keys := make([]string, 0, len(targetSettings))
+	for _, setting := range targetSettings {
+		keys = append(keys, setting.Key)
+	}
+	return keys
+}
+
+// applyTargetSetting validates value for key and applies it to the target
+func applyTargetSetting(target *config.TargetConfig, key, value string) error {
+	for _, setting := range targetSettings {
+		if setting.Key == key {
+			if err := setting.set(target, strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown key %q. Valid keys: %s", key, strings.Join(settingKeys(), ", "))
+}
+
+// globalSetting is a key `lightfold config set` changes for every target,
+// set without --target
+type globalSetting struct {
+	Key         string
+	Description string
+	set         func(cfg *config.Config, value string) error
+}
+
+var globalSettings = []globalSetting{
+	{Key: "telemetry", Description: "Send crash reports to telemetry.endpoint (on, off)", set: setTelemetry},
+	{Key: "telemetry.endpoint", Description: "URL crash reports are POSTed to", set: setTelemetryEndpoint},
+	{Key: "detection.margin", Description: "Score points within which detection asks which framework an app is (0 for the default)", set: setDetectionMargin},
+}
+
+// findGlobalSetting returns the global setting for key
+func findGlobalSetting(key string) (globalSetting, bool) {
+	for _, setting := range globalSettings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return globalSetting{}, false
+}
+
+// applyGlobalSetting validates value for a global key and applies it
+func applyGlobalSetting(cfg *config.Config, key, value string) error {
+	setting, ok := findGlobalSetting(key)
+	if !ok {
+		keys := []string{}
+		for _, setting := range globalSettings {
+			keys = append(keys, setting.Key)
+		}
+		return fmt.Errorf("unknown key %q without --target. Valid keys: %s", key, strings.Join(keys, ", "))
+	}
+	if err := setting.set(cfg, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	return nil
+}
+
+// validateGlobalSettings checks settings that depend on each other once all
+// of a command's keys are applied
+func validateGlobalSettings(cfg *config.Config) error {
+	if cfg.Telemetry != nil && cfg.Telemetry.Enabled && cfg.Telemetry.Endpoint == "" {
+		return fmt.Errorf("telemetry=on needs telemetry.endpoint")
+	}
+	return nil
+}
+
+func setDetectionMargin(cfg *config.Config, v string) error {
+	margin, err := strconv.ParseFloat(v, 64)
+	if err != nil || margin < 0 {
+		return fmt.Errorf("want a score margin of 0 or more, got %q", v)
+	}
+	cfg.DetectionMargin = margin
+	return nil
+}
+
+func setTelemetry(cfg *config.Config, v string) error {
+	if cfg.Telemetry == nil {
+		cfg.Telemetry = &config.TelemetryConfig{}
+	}
+	switch strings.ToLower(v) {
+	case "on", "true":
+		cfg.Telemetry.Enabled = true
+	case "off", "false":
+		cfg.Telemetry.Enabled = false
+	default:
+		return fmt.Errorf("want on or off, got %q", v)
+	}
+	return nil
+}
+
+func setTelemetryEndpoint(cfg *config.Config, v string) error {
+	if cfg.Telemetry == nil {
+		cfg.Telemetry = &config.TelemetryConfig{}
+	}
+	if v == "" {
+		cfg.Telemetry.Endpoint = ""
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("want an http(s) URL, got %q", v)
+	}
+	cfg.Telemetry.Endpoint = v
+	return nil
+}
+
+// parseSettingArg splits a key=value argument
+func parseSettingArg(arg string) (string, string, error) {
+	key, value, found := strings.Cut(arg, "=")
+	if !found || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("expected key=value, got %q", arg)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+func setBuilder(t *config.TargetConfig, v string) error {
+	for _, name := range builders.ListBuilders() {
+		if name == v {
+			t.Builder = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown builder %q. Valid builders: %s", v, strings.Join(builders.ListBuilders(), ", "))
+}
+
+func setFrameworkOverride(t *config.TargetConfig, v string) error {
+	if v == "" {
+		t.FrameworkOverride = ""
+		return nil
+	}
+	name, err := detector.LookupFramework(v)
+	if err != nil {
+		return err
+	}
+	t.FrameworkOverride = name
+	t.Framework = name
+	return nil
+}
+
+func setPort(t *config.TargetConfig, v string) error {
+	port, err := strconv.Atoi(v)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port must be a number between 1 and 65535, got %q", v)
+	}
+	t.Port = port
+	return nil
+}
+
+func setDomain(t *config.TargetConfig, v string) error {
+	if !isValidDomain(v) {
+		return fmt.Errorf("invalid domain format: %s", v)
+	}
+	ensureDomain(t).Domain = v
+	return nil
+}
+
+func ensureDomain(t *config.TargetConfig) *config.DomainConfig {
+	if t.Domain == nil {
+		t.Domain = &config.DomainConfig{}
+	}
+	return t.Domain
+}
+
+func ensureSSH(t *config.TargetConfig) *config.SSHOptions {
+	if t.SSH == nil {
+		t.SSH = &config.SSHOptions{}
+	}
+	return t.SSH
+}
+
+func ensureAssets(t *config.TargetConfig) *config.AssetsConfig {
+	if t.Assets == nil {
+		t.Assets = &config.AssetsConfig{}
+	}
+	return t.Assets
+}
+
+func ensureDeploy(t *config.TargetConfig) *config.DeploymentOptions {
+	if t.Deploy == nil {
+		t.Deploy = &config.DeploymentOptions{}
+	}
+	return t.Deploy
+}
+
+// setProviderField updates one field of the provider config, then checks that
+// the resulting size is offered in the region when the provider can be queried
+func setProviderField(t *config.TargetConfig, provider, field, value string) error {
+	fields := map[string]interface{}{}
+	if raw, ok := t.ProviderConfig[provider]; ok {
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("failed to parse provider config: %w", err)
+		}
+	}
+	fields[field] = value
+
+	if client := configSetProvider(provider); client != nil {
+		region, _ := fields[providerRegionFields[provider]].(string)
+		size, _ := fields[providerSizeFields[provider]].(string)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := providers.ValidateSizeForRegion(ctx, client, region, size); err != nil {
+			return err
+		}
+	}
+
+	return t.SetProviderConfig(provider, fields)
+}
+
+// configSetting is one line of `config show`
+type configSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// secretKeyMarkers flag config fields whose values are masked by `config show`
+var secretKeyMarkers = []string{"secret", "password", "root_pass", "token", "access_key"}
+
+// targetSettingsView flattens a target into dotted keys with secrets masked.
+// Provider fields use the same prefixes as `config set`, e.g. do.size.
+func targetSettingsView(target config.TargetConfig) ([]configSetting, error) {
+	data, err := json.Marshal(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal target: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse target: %w", err)
+	}
+
+	if providerFields, ok := fields["provider_config"].(map[string]interface{}); ok {
+		for provider, value := range providerFields {
+			fields[providerKeyPrefix(provider)] = value
+		}
+		delete(fields, "provider_config")
+	}
+
+	var view []configSetting
+	flattenSettings("", fields, &view)
+
+	if target.Builder == "" {
+		view = append(view, configSetting{Key: "builder", Value: "auto"})
+	}
+	if target.Port == 0 {
+		view = append(view, configSetting{Key: "port", Value: "auto"})
+	}
+	if !target.Protected {
+		view = append(view, configSetting{Key: "protected", Value: "false"})
+	}
+
+	sort.Slice(view, func(i, j int) bool { return view[i].Key < view[j].Key })
+	return view, nil
+}
+
+func flattenSettings(prefix string, value interface{}, view *[]configSetting) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childKey := key
+			if prefix != "" {
+				childKey = prefix + "." + key
+			}
+			flattenSettings(childKey, child, view)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenSettings(fmt.Sprintf("%s[%d]", prefix, i), child, view)
+		}
+	default:
+		*view = append(*view, configSetting{Key: prefix, Value: maskSettingValue(prefix, fmt.Sprint(v))})
+	}
+}
+
+func maskSettingValue(key, value string) string {
+	if value == "" {
+		return value
+	}
+	lower := strings.ToLower(key)
+	isSecret := strings.HasPrefix(lower, "deploy.env_vars.")
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(lower, marker) {
+			isSecret = true
+		}
+	}
+	if !isSecret {
+		return value
+	}
+	if len(value) < 10 {
+		return "********"
+	}
+	return "****" + value[len(value)-4:]
+}
@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"context"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sizesProvider offers a fixed list of sizes in every region
+type sizesProvider struct {
+	MockProvider
+	sizes []string
+}
+
+func (s *sizesProvider) SupportsSSH() bool { return true }
+
+func (s *sizesProvider) GetSizes(ctx context.Context, region string) ([]providers.Size, error) {
+	sizes := make([]providers.Size, 0, len(s.sizes))
+	for _, id := range s.sizes {
+		sizes = append(sizes, providers.Size{ID: id})
+	}
+	return sizes, nil
+}
+
+func newSettingsTarget(t *testing.T) config.TargetConfig {
+	t.Helper()
+	target := config.TargetConfig{ProjectPath: "/home/me/myapp", Framework: "Next.js", Provider: "digitalocean"}
+	if err := target.SetProviderConfig("digitalocean", &config.DigitalOceanConfig{
+		IP: "203.0.113.10", Username: "deploy", SSHKey: "~/.lightfold/keys/id", Region: "nyc1", Size: "s-1vcpu-1gb",
+	}); err != nil {
+		t.Fatalf("SetProviderConfig() error: %v", err)
+	}
+	return target
+}
+
+func TestConfigSetRoundTrip(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	target := newSettingsTarget(t)
+
+	settings := map[string]string{
+		"builder":           "nixpacks",
+		"port":              "8080",
+		"domain.domain":     "app.example.com",
+		"deploy.skip_build": "true",
+		"deploy.workers":    "3",
+		"do.size":           "s-2vcpu-4gb",
+	}
+	for key, value := range settings {
+		if err := applyTargetSetting(&target, key, value); err != nil {
+			t.Fatalf("applyTargetSetting(%s) error: %v", key, err)
+		}
+	}
+	cfg.SetTarget("myapp", target)
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error: %v", err)
+	}
+
+	reloaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	saved, _ := reloaded.GetTarget("myapp")
+	doConfig, err := saved.GetDigitalOceanConfig()
+	if err != nil {
+		t.Fatalf("GetDigitalOceanConfig() error: %v", err)
+	}
+
+	if saved.Builder != "nixpacks" || saved.Port != 8080 || saved.Domain.Domain != "app.example.com" || !saved.Deploy.SkipBuild {
+		t.Errorf("settings not persisted: %+v", saved)
+	}
+	if doConfig.Size != "s-2vcpu-4gb" || doConfig.Region != "nyc1" || doConfig.IP != "203.0.113.10" {
+		t.Errorf("provider config = %+v, want size changed and other fields kept", doConfig)
+	}
+
+	view, err := targetSettingsView(saved)
+	if err != nil {
+		t.Fatalf("targetSettingsView() error: %v", err)
+	}
+	shown := map[string]string{}
+	for _, setting := range view {
+		shown[setting.Key] = setting.Value
+	}
+	for key, value := range settings {
+		if shown[key] != value {
+			t.Errorf("config show %s = %q, want %q", key, shown[key], value)
+		}
+	}
+}
+
+func TestApplyTargetSettingFramework(t *testing.T) {
+	target := newSettingsTarget(t)
+
+	if err := applyTargetSetting(&target, "framework", "django"); err != nil {
+		t.Fatalf("applyTargetSetting(framework) error: %v", err)
+	}
+	if target.FrameworkOverride != "Django" || target.Framework != "Django" {
+		t.Errorf("framework = %q, override = %q; want Django", target.Framework, target.FrameworkOverride)
+	}
+	if err := applyTargetSetting(&target, "framework", "cobol"); err == nil {
+		t.Error("expected an unknown framework to be rejected")
+	}
+	if err := applyTargetSetting(&target, "framework", ""); err != nil || target.FrameworkOverride != "" {
+		t.Errorf("clearing framework: override = %q, err = %v", target.FrameworkOverride, err)
+	}
+}
+
+func TestApplyTargetSettingStaticPaths(t *testing.T) {
+	var target config.TargetConfig
+
+	if err := applyTargetSetting(&target, "deploy.static_paths", "/media/=shared/uploads, /assets/=/var/www/assets"); err != nil {
+		t.Fatalf("applyTargetSetting() error: %v", err)
+	}
+	want := map[string]string{"/media/": "shared/uploads", "/assets/": "/var/www/assets"}
+	if len(target.Deploy.StaticPaths) != len(want) {
+		t.Fatalf("StaticPaths = %v, want %v", target.Deploy.StaticPaths, want)
+	}
+	for url, dir := range want {
+		if target.Deploy.StaticPaths[url] != dir {
+			t.Errorf("StaticPaths[%s] = %q, want %q", url, target.Deploy.StaticPaths[url], dir)
+		}
+	}
+
+	if err := applyTargetSetting(&target, "deploy.static_paths", ""); err != nil || target.Deploy.StaticPaths != nil {
+		t.Errorf("empty value should restore the framework defaults, got %v, %v", target.Deploy.StaticPaths, err)
+	}
+	if err := applyTargetSetting(&target, "deploy.disable_static_paths", "true"); err != nil || !target.Deploy.DisableStaticPaths {
+		t.Errorf("disable_static_paths not set: %v", err)
+	}
+}
+
+func TestApplyTargetSettingBuildOutputDirs(t *testing.T) {
+	var target config.TargetConfig
+
+	if err := applyTargetSetting(&target, "deploy.build_output_dirs", "/=en, /de/=de/, /fr/=fr"); err != nil {
+		t.Fatalf("applyTargetSetting() error: %v", err)
+	}
+	want := []config.BuildOutputDir{{PathPrefix: "/", Dir: "en"}, {PathPrefix: "/de/", Dir: "de"}, {PathPrefix: "/fr/", Dir: "fr"}}
+	if !reflect.DeepEqual(target.Deploy.BuildOutputDirs, want) {
+		t.Errorf("BuildOutputDirs = %v, want %v", target.Deploy.BuildOutputDirs, want)
+	}
+
+	if err := applyTargetSetting(&target, "deploy.build_output_dirs", ""); err != nil || target.Deploy.BuildOutputDirs != nil {
+		t.Errorf("empty value should serve the whole build output, got %v, %v", target.Deploy.BuildOutputDirs, err)
+	}
+}
+
+func TestApplyTargetSettingMaintenanceAllow(t *testing.T) {
+	var target config.TargetConfig
+
+	if err := applyTargetSetting(&target, "deploy.maintenance_allow", "/healthz, /api/status,"); err != nil {
+		t.Fatalf("applyTargetSetting() error: %v", err)
+	}
+	if want := []string{"/healthz", "/api/status"}; !reflect.DeepEqual(target.Deploy.MaintenanceAllow, want) {
+		t.Errorf("MaintenanceAllow = %v, want %v", target.Deploy.MaintenanceAllow, want)
+	}
+
+	if err := applyTargetSetting(&target, "deploy.maintenance_allow", ""); err != nil || target.Deploy.MaintenanceAllow != nil {
+		t.Errorf("empty value should restore the default, got %v, %v", target.Deploy.MaintenanceAllow, err)
+	}
+}
+
+func TestApplyTargetSettingBaseDir(t *testing.T) {
+	var target config.TargetConfig
+
+	if err := applyTargetSetting(&target, "deploy.base_dir", "/opt/apps/"); err != nil {
+		t.Fatalf("applyTargetSetting() error: %v", err)
+	}
+	if target.Deploy.BaseDir != "/opt/apps" || target.RemoteBaseDir() != "/opt/apps" {
+		t.Errorf("BaseDir = %q, RemoteBaseDir() = %q, want /opt/apps", target.Deploy.BaseDir, target.RemoteBaseDir())
+	}
+
+	if err := applyTargetSetting(&target, "deploy.base_dir", "/srv"); err != nil || target.Deploy.BaseDir != "" {
+		t.Errorf("/srv should restore the default, got %q, %v", target.Deploy.BaseDir, err)
+	}
+}
+
+func TestCheckBaseDir(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	target := newSettingsTarget(t)
+	target.Deploy = &config.DeploymentOptions{BaseDir: "/opt/apps"}
+	if err := checkBaseDir("myapp", target); err != nil {
+		t.Errorf("checkBaseDir() before the first deploy = %v, want nil", err)
+	}
+
+	if err := state.UpdateDeployment("myapp", "abc123", "20240101000000", ""); err != nil {
+		t.Fatal(err)
+	}
+	err := checkBaseDir("myapp", target)
+	if err == nil || !strings.Contains(err.Error(), "deployed under /srv") || !strings.Contains(err.Error(), "deploy.base_dir=/srv") {
+		t.Errorf("checkBaseDir() after deploying under /srv = %v, want a deployed under /srv error", err)
+	}
+
+	if err := state.RecordBaseDir("myapp", "/opt/apps"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBaseDir("myapp", target); err != nil {
+		t.Errorf("checkBaseDir() with the recorded base = %v, want nil", err)
+	}
+}
+
+func TestApplyTargetSettingDomainProtocols(t *testing.T) {
+	target := newSettingsTarget(t)
+	if err := applyTargetSetting(&target, "domain.http2", "false"); err != nil {
+		t.Fatalf("applyTargetSetting(domain.http2) error: %v", err)
+	}
+	if err := applyTargetSetting(&target, "domain.http3", "true"); err != nil {
+		t.Fatalf("applyTargetSetting(domain.http3) error: %v", err)
+	}
+	if target.Domain.HTTP2Enabled() || !target.Domain.HTTP3 {
+		t.Errorf("domain = %+v, want http2 off and http3 on", target.Domain)
+	}
+
+	// Turning HTTP/2 back on clears the override, as if it was never set
+	if err := applyTargetSetting(&target, "domain.http2", "true"); err != nil {
+		t.Fatalf("applyTargetSetting(domain.http2) error: %v", err)
+	}
+	if target.Domain.HTTP2 != nil || !target.Domain.HTTP2Enabled() {
+		t.Errorf("domain.http2=true should clear the override, got %v", *target.Domain.HTTP2)
+	}
+}
+
+func TestApplyTargetSettingRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"port", "0", "between 1 and 65535"},
+		{"port", "70000", "between 1 and 65535"},
+		{"port", "http", "between 1 and 65535"},
+		{"builder", "buildpacks", "Valid builders"},
+		{"nixpacks_version", "latest", "invalid nixpacks version"},
+		{"container_port", "70000", "between 1 and 65535"},
+		{"domain.domain", "not a domain", "invalid domain"},
+		{"deploy.skip_build", "maybe", "true or false"},
+		{"protected", "yes please", "true or false"},
+		{"domain.http3", "quic", "true or false"},
+		{"deploy.workers", "-1", "non-negative"},
+		{"deploy.static_paths", "media", "/url/=directory"},
+		{"deploy.static_paths", "/media/=../etc", "'..'"},
+		{"deploy.build_output_dirs", "de", "/url/=directory"},
+		{"deploy.build_output_dirs", "/de/=../de", "inside the build output"},
+		{"deploy.build_output_dirs", "/de/=de,/de=deutsch", "mapped twice"},
+		{"deploy.maintenance_allow", "healthz", "invalid maintenance path"},
+		{"deploy.maintenance_allow", "/api/{id}", "invalid maintenance path"},
+		{"deploy.base_dir", "opt/apps", "absolute path"},
+		{"deploy.base_dir", "/", "absolute path"},
+		{"deploy.base_dir", "/opt/my apps", "shell characters"},
+		{"deploy.subdir", "../shared", "plain path inside the project"},
+		{"deploy.subdir", "apps/missing", "not a directory"},
+		{"assets.public_url", "d111.cloudfront.net", "http:// or https://"},
+		{"assets.prefix", "../other", "'..'"},
+		{"assets.continue_on_error", "sometimes", "true or false"},
+		{"ssh.keepalive_seconds", "-2", "or -1"},
+		{"ssh.reconnect_attempts", "often", "or -1"},
+		{"hetzner.server_type", "cx22", "not hetzner"},
+		{"do.size", "", "cannot be empty"},
+		{"region", "nyc3", "Valid keys: builder, port"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			target := newSettingsTarget(t)
+			err := applyTargetSetting(&target, tt.key, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("applyTargetSetting(%s=%s) error = %v, want %q", tt.key, tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyTargetSettingChecksSizeWithProvider(t *testing.T) {
+	original := configSetProvider
+	configSetProvider = func(name string) providers.Provider {
+		return &sizesProvider{sizes: []string{"s-1vcpu-1gb", "s-2vcpu-4gb"}}
+	}
+	defer func() { configSetProvider = original }()
+
+	target := newSettingsTarget(t)
+	if err := applyTargetSetting(&target, "do.size", "s-2vcpu-4gb"); err != nil {
+		t.Errorf("valid size rejected: %v", err)
+	}
+	err := applyTargetSetting(&target, "do.size", "s-64vcpu")
+	if err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Errorf("invalid size error = %v, want not available", err)
+	}
+}
+
+func TestTargetSettingsViewMasksSecrets(t *testing.T) {
+	target := config.TargetConfig{
+		Provider: "s3",
+		Deploy:   &config.DeploymentOptions{EnvVars: map[string]string{"DATABASE_URL": "postgres://u:hunter2@db/app"}},
+	}
+	target.SetProviderConfig("s3", &config.S3Config{Bucket: "site", Region: "us-east-1", AccessKey: "AKIAEXAMPLE1234", SecretKey: "verysecretvalue"})
+
+	view, err := targetSettingsView(target)
+	if err != nil {
+		t.Fatalf("targetSettingsView() error: %v", err)
+	}
+	for _, setting := range view {
+		for _, secret := range []string{"hunter2", "verysecret", "AKIAEXAMPLE"} {
+			if strings.Contains(setting.Value, secret) {
+				t.Errorf("%s = %q leaks a secret", setting.Key, setting.Value)
+			}
+		}
+		if setting.Key == "s3.bucket" && setting.Value != "site" {
+			t.Errorf("s3.bucket = %q, want it shown", setting.Value)
+		}
+	}
+}
+
+func TestApplyGlobalSettingTelemetry(t *testing.T) {
+	cfg := &config.Config{}
+	if err := applyGlobalSetting(cfg, "telemetry", "on"); err != nil {
+		t.Fatalf("applyGlobalSetting() error: %v", err)
+	}
+	if err := validateGlobalSettings(cfg); err == nil {
+		t.Error("telemetry=on without an endpoint should be rejected")
+	}
+
+	if err := applyGlobalSetting(cfg, "telemetry.endpoint", "https://crash.example.com/report"); err != nil {
+		t.Fatalf("applyGlobalSetting() error: %v", err)
+	}
+	if err := validateGlobalSettings(cfg); err != nil || cfg.TelemetryEndpoint() != "https://crash.example.com/report" {
+		t.Errorf("TelemetryEndpoint() = %q, %v", cfg.TelemetryEndpoint(), err)
+	}
+
+	applyGlobalSetting(cfg, "telemetry", "off")
+	if cfg.TelemetryEndpoint() != "" {
+		t.Errorf("TelemetryEndpoint() = %q with telemetry off, want empty", cfg.TelemetryEndpoint())
+	}
+
+	for key, value := range map[string]string{"telemetry": "maybe", "telemetry.endpoint": "crash.example.com", "port": "8080"} {
+		if err := applyGlobalSetting(cfg, key, value); err == nil {
+			t.Errorf("applyGlobalSetting(%s=%s) succeeded, want an error", key, value)
+		}
+	}
+}
+
+func TestSSHConnectionOptions(t *testing.T) {
+	defaults := sshpkg.DefaultConnectionOptions()
+	if got := sshConnectionOptions(nil); got != defaults {
+		t.Errorf("sshConnectionOptions(nil) = %+v, want defaults", got)
+	}
+
+	var target config.TargetConfig
+	for key, value := range map[string]string{"ssh.keepalive_seconds": "5", "ssh.reconnect_attempts": "-1"} {
+		if err := applyTargetSetting(&target, key, value); err != nil {
+			t.Fatalf("applyTargetSetting(%s) error: %v", key, err)
+		}
+	}
+
+	got := sshConnectionOptions(target.SSH)
+	if got.KeepAliveInterval != 5*time.Second || got.ReconnectAttempts != 0 || got.KeepAliveMaxMissed != defaults.KeepAliveMaxMissed {
+		t.Errorf("sshConnectionOptions() = %+v, want 5s keepalives and reconnecting off", got)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
+	"lightfold/pkg/providers"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const tokenCheckTimeout = 15 * time.Second
+
+// tokenHealth is the result of checking one stored provider token
+type tokenHealth struct {
+	Provider string `json:"provider"`
+	Preview  string `json:"preview"`
+	Checked  bool   `json:"checked"` // False when lightfold has no API client for the provider
+	Valid    bool   `json:"valid"`
+	Account  string `json:"account,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+var configTokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "List provider tokens and check that they still work",
+	Long: `List every stored provider token with a masked preview, check it against the
+provider's API and show the account it belongs to where the API reports it.
+
+Exits with a non-zero status if any token is rejected.
+
+Examples:
+  lightfold config tokens
+  lightfold config tokens --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tokens, err := config.LoadTokens()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", configErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
+			exit(1)
+		}
+
+		results := checkTokens(tokens)
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(results)
+		} else {
+			printTokenHealth(results)
+		}
+
+		for _, health := range results {
+			if health.Checked && !health.Valid {
+				exit(1)
+			}
+		}
+	},
+}
+
+// maskToken shows only the first and last four characters of a token
+func maskToken(token string) string {
+	if len(token) < 12 {
+		return "********"
+	}
+	return token[:4] + "…" + token[len(token)-4:]
+}
+
+// tokenPreview masks a stored token. AWS tokens are stored as JSON credentials,
+// so the profile name or masked access key ID is shown instead.
+func tokenPreview(provider, token string) string {
+	if provider == "aws" {
+		var creds struct {
+			AccessKeyID string `json:"access_key_id"`
+			Profile     string `json:"profile"`
+		}
+		if json.Unmarshal([]byte(token), &creds) == nil {
+			if creds.Profile != "" {
+				return "profile " + creds.Profile
+			}
+			if creds.AccessKeyID != "" {
+				return maskToken(creds.AccessKeyID)
+			}
+		}
+	}
+	return maskToken(token)
+}
+
+// checkToken validates a token with its provider's API
+func checkToken(ctx context.Context, provider, token string) tokenHealth {
+	if !providers.IsRegistered(provider) {
+		return tokenHealth{Provider: provider, Preview: tokenPreview(provider, token)}
+	}
+
+	client, err := providers.GetProvider(provider, token)
+	if err != nil {
+		return tokenHealth{Provider: provider, Preview: tokenPreview(provider, token), Checked: true, Error: err.Error()}
+	}
+	return checkProviderToken(ctx, client, provider, token)
+}
+
+// checkProviderToken validates credentials and, when the provider reports it,
+// looks up the account the token belongs to
+func checkProviderToken(ctx context.Context, client providers.Provider, provider, token string) tokenHealth {
+	health := tokenHealth{Provider: provider, Preview: tokenPreview(provider, token), Checked: true}
+
+	if err := client.ValidateCredentials(ctx); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Valid = true
+
+	if describer, ok := client.(providers.AccountDescriber); ok {
+		if account, err := describer.AccountInfo(ctx); err == nil {
+			health.Account = account
+		}
+	}
+	return health
+}
+
+// checkTokens checks all stored tokens in parallel, sorted by provider
+func checkTokens(tokens config.TokenConfig) []tokenHealth {
+	names := make([]string, 0, len(tokens))
+	for name := range tokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]tokenHealth, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		crash.Go(func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+			defer cancel()
+			results[i] = checkToken(ctx, name, tokens[name])
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+func printTokenHealth(results []tokenHealth) {
+	fmt.Println(configStyle.Render("Provider Tokens:"))
+	if len(results) == 0 {
+		fmt.Println(configMutedStyle.Render("  No provider tokens configured"))
+		return
+	}
+
+	width := 0
+	for _, health := range results {
+		width = max(width, len(health.Provider))
+	}
+
+	for _, health := range results {
+		var status string
+		switch {
+		case !health.Checked:
+			status = configMutedStyle.Render("not checked")
+		case health.Valid:
+			status = configSuccessStyle.Render(style.Check() + " valid")
+		default:
+			status = configErrorStyle.Render(style.Cross() + " invalid")
+		}
+
+		name := health.Provider + strings.Repeat(" ", width-len(health.Provider))
+		fmt.Printf("  %s  %s  %s", configLabelStyle.Render(name), configValueStyle.Render(health.Preview), status)
+		if health.Account != "" {
+			fmt.Printf("  %s", configMutedStyle.Render(health.Account))
+		}
+		fmt.Println()
+		if health.Error != "" {
+			fmt.Printf("    %s\n", configMutedStyle.Render(health.Error))
+		}
+	}
+}
@@ -3,19 +3,20 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
 	"os"
 	"strings"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
 var (
 	configureTargetFlag string
 	configureForceFlag  bool
+	configureNoSnapshot bool
 )
 
 var configureCmd = &cobra.Command{
@@ -29,7 +30,11 @@ Examples:
   lightfold configure                    # Configure current directory
   lightfold configure ~/Projects/myapp   # Configure specific project
   lightfold configure --target myapp     # Configure named target
-  lightfold configure --force            # Force reconfiguration`,
+  lightfold configure --force            # Force reconfiguration
+  lightfold configure --force --no-snapshot  # Reconfigure without offering a snapshot
+
+With --force on a configured DigitalOcean, Hetzner or Vultr server, configure
+offers to take a provider snapshot first (see 'lightfold server snapshot').`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := loadConfigOrExit()
@@ -40,15 +45,23 @@ Examples:
 		}
 
 		target, targetName := resolveTarget(cfg, configureTargetFlag, pathArg)
+		exitIfEnvLocked(cfg, targetName)
 
 		if err := processConfigureFlags(&target); err != nil {
 			fmt.Fprintf(os.Stderr, "Error processing configuration options: %v\n", err)
-			os.Exit(1)
+			exit(1)
+		}
+
+		if configureForceFlag && !configureNoSnapshot && state.IsConfigured(targetName) {
+			if err := offerConfigureSnapshot(target, targetName); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
 		}
 
 		if err := configureTarget(target, targetName, configureForceFlag); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Prompt for optional domain configuration
@@ -57,8 +70,8 @@ Examples:
 		// Remind user to push their code (only if not called from deploy command)
 		if !isCalledFromDeploy {
 			fmt.Println()
-			hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-			mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+			hintStyle := style.Header
+			mutedStyle := style.Muted
 			fmt.Printf("%s\n", hintStyle.Render("Next step:"))
 			fmt.Printf("%s\n", mutedStyle.Render(fmt.Sprintf("  Run 'lightfold push --target %s' to deploy your application", targetName)))
 		}
@@ -81,9 +94,9 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 	}
 
 	fmt.Println()
-	promptStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
-	hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	warningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("226"))
+	promptStyle := style.Label
+	hintStyle := style.Muted
+	warningStyle := style.Fg("226")
 
 	// Check if this is a multi-app deployment
 	providerCfg, err := target.GetSSHProviderConfig()
@@ -121,9 +134,9 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 		// If multi-app, offer port-based access
 		if isMultiApp && appPort > 0 {
 			fmt.Println()
-			fmt.Printf("%s\n", warningStyle.Render(fmt.Sprintf("ℹ Multi-app deployment detected: This server hosts %d apps", len(serverState.DeployedApps))))
-			fmt.Printf("%s\n", hintStyle.Render(fmt.Sprintf("  • App '%s' is running on port %d", targetName, appPort)))
-			fmt.Printf("%s\n", hintStyle.Render("  • Without a domain, only the last deployed app is accessible via http://"+serverIP))
+			fmt.Printf("%s\n", warningStyle.Render(fmt.Sprintf("%s Multi-app deployment detected: This server hosts %d apps", style.Info(), len(serverState.DeployedApps))))
+			fmt.Printf("%s\n", hintStyle.Render(fmt.Sprintf("  %s App '%s' is running on port %d", style.Bullet(), targetName, appPort)))
+			fmt.Printf("%s\n", hintStyle.Render("  "+style.Bullet()+" Without a domain, only the last deployed app is accessible via http://"+serverIP))
 			fmt.Println()
 
 			// Prompt to open port
@@ -133,7 +146,7 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 
 			if portResponse == "y" || portResponse == "yes" {
 				fmt.Println()
-				fmt.Printf("%s\n", warningStyle.Render("⚠ SECURITY WARNING:"))
+				fmt.Printf("%s\n", warningStyle.Render(style.Warn()+" SECURITY WARNING:"))
 				fmt.Printf("%s\n", hintStyle.Render("  Opening application ports directly exposes your app without nginx's security layer."))
 				fmt.Printf("%s\n", hintStyle.Render("  Consider using custom domains instead for better security and SSL support."))
 				fmt.Println()
@@ -142,12 +155,15 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 				confirmResponse = strings.TrimSpace(strings.ToLower(confirmResponse))
 
 				if confirmResponse == "y" || confirmResponse == "yes" {
-					if err := openPort(serverIP, appPort, providerCfg); err != nil {
+					if err := openPort(appPort, providerCfg); err != nil {
 						fmt.Printf("%s\n", warningStyle.Render(fmt.Sprintf("Failed to open port: %v", err)))
+					} else if err := saveExposePort(targetName); err != nil {
+						fmt.Printf("%s\n", warningStyle.Render(fmt.Sprintf("Port opened, but failed to save deploy.expose_port: %v", err)))
 					} else {
-						successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-						fmt.Printf("\n%s\n", successStyle.Render(fmt.Sprintf("✓ Port %d opened successfully!", appPort)))
-						fmt.Printf("%s\n", hintStyle.Render(fmt.Sprintf("  Access your app at: http://%s:%d", serverIP, appPort)))
+						successStyle := style.Success
+						fmt.Printf("\n%s\n", successStyle.Render(fmt.Sprintf("%s Port %d opened successfully!", style.Check(), appPort)))
+						fmt.Printf("%s\n", hintStyle.Render("  The app listens on all interfaces from its next deploy (deploy.expose_port)"))
+						fmt.Printf("%s\n", hintStyle.Render(fmt.Sprintf("  Access your app at: http://%s:%d", urlHost(serverIP), appPort)))
 					}
 				}
 			} else {
@@ -166,7 +182,7 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 
 	// If no domain provided, skip gracefully
 	if domain == "" {
-		domainHint := fmt.Sprintf("💡 You can add a domain later with: lightfold domain add --target %s --domain example.com", targetName)
+		domainHint := fmt.Sprintf("%s You can add a domain later with: lightfold domain add --target %s --domain example.com", style.Info(), targetName)
 		fmt.Printf("\n%s\n", hintStyle.Render(domainHint))
 		return
 	}
@@ -183,14 +199,32 @@ func promptDomainConfiguration(target *config.TargetConfig, targetName string) {
 	}
 }
 
+// saveExposePort records that the target is reached directly on its port,
+// so deploys bind the app on all interfaces instead of 127.0.0.1
+func saveExposePort(targetName string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	target, ok := cfg.GetTarget(targetName)
+	if !ok {
+		return fmt.Errorf("target '%s' not found", targetName)
+	}
+	ensureDeploy(&target).ExposePort = true
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		return err
+	}
+	return cfg.SaveConfig()
+}
+
 // openPort opens a firewall port on the server via UFW
-func openPort(serverIP string, port int, providerCfg config.ProviderConfig) error {
+func openPort(port int, providerCfg config.ProviderConfig) error {
 	// Create SSH executor
-	sshExecutor := sshpkg.NewExecutor(serverIP, "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 
 	// Open the port with UFW
-	cmd := fmt.Sprintf("sudo ufw allow %d/tcp", port)
-	result := sshExecutor.Execute(cmd)
+	cmd := fmt.Sprintf("ufw allow %d/tcp", port)
+	result := sshExecutor.ExecuteSudo(cmd)
 
 	if result.ExitCode != 0 {
 		return fmt.Errorf("failed to open port: %s", result.Stderr)
@@ -204,7 +238,15 @@ func init() {
 
 	configureCmd.Flags().StringVar(&configureTargetFlag, "target", "", "Target name (defaults to current directory)")
 	configureCmd.Flags().BoolVarP(&configureForceFlag, "force", "f", false, "Force reconfiguration even if already configured")
+	configureCmd.Flags().BoolVar(&configureNoSnapshot, "no-snapshot", false, "Don't offer a provider snapshot before --force reconfigures the server")
 	configureCmd.Flags().StringVar(&envFile, "env-file", "", "Path to .env file with environment variables")
 	configureCmd.Flags().StringArrayVar(&envVars, "env", []string{}, "Environment variables in KEY=VALUE format (can be used multiple times)")
 	configureCmd.Flags().BoolVar(&skipBuild, "skip-build", false, "Skip the build step during configuration")
+	configureCmd.Flags().BoolVar(&skipMigrations, "skip-migrations", false, "Deploy without running database migrations")
+	configureCmd.Flags().BoolVar(&rerunFirstDeploy, "rerun-first-deploy", false, "Run deploy.first_deploy_commands again although they succeeded before")
+	configureCmd.Flags().BoolVar(&forceBuild, "force-build", false, "Build even when the server has too little memory for the framework")
+	configureCmd.Flags().IntVar(&containerPortFlag, "container-port", 0, "Port the app listens on inside its container (dockerfile builder; read from the Dockerfile's EXPOSE by default)")
+	configureCmd.Flags().BoolVar(&autoInstallBuilder, "auto-install-builder", false, "Install the target's pinned nixpacks version on the server when another version is installed")
+	configureCmd.Flags().DurationVar(&aptWaitFlag, "apt-wait", config.DefaultServerReadyTimeout, "How long to wait for apt/dpkg locks held on the server (e.g. by unattended-upgrades)")
+	configureCmd.Flags().BoolVar(&killStaleAptFlag, "kill-stale-apt", false, "Stop unattended-upgrades when it has held the apt locks for over 30m")
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"lightfold/pkg/builders/dockerfile"
+	"lightfold/pkg/config"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var containerPortFlag int
+
+// selectContainerPort settles which port a dockerfile build's container
+// serves on when the Dockerfile exposes several: --container-port, the saved
+// choice, or a prompt. The choice is saved so later deploys reuse it.
+func selectContainerPort(target *config.TargetConfig, targetName string) error {
+	if target.Builder != "dockerfile" {
+		return nil
+	}
+
+	port := containerPortFlag
+	if port == 0 {
+		if target.ContainerPort > 0 {
+			return nil
+		}
+		ports, err := dockerfile.ReadPorts(target.ProjectPath)
+		if err != nil {
+			return nil // the build reports a missing Dockerfile
+		}
+		_, err = dockerfile.ContainerPort(ports, 0, nil)
+		var multiple *dockerfile.MultiplePortsError
+		if !errors.As(err, &multiple) {
+			return err
+		}
+		if jsonOutput || skipInteractive || !isTerminal() {
+			return err
+		}
+		if port, err = promptContainerPort(multiple.Ports); err != nil {
+			return err
+		}
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid container port %d: must be between 1 and 65535", port)
+	}
+	if port == target.ContainerPort {
+		return nil
+	}
+
+	target.ContainerPort = port
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.SetTarget(targetName, *target); err != nil {
+		return fmt.Errorf("failed to save container port: %w", err)
+	}
+	return cfg.SaveConfig()
+}
+
+// promptContainerPort asks which of the Dockerfile's exposed ports the app
+// serves on, defaulting to the first
+func promptContainerPort(ports []int) (int, error) {
+	choices := make([]string, len(ports))
+	for i, port := range ports {
+		choices[i] = strconv.Itoa(port)
+	}
+
+	fmt.Printf("The Dockerfile exposes ports %s. Which one does the app serve on? [%d]: ", strings.Join(choices, ", "), ports[0])
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return ports[0], nil
+	}
+	for _, port := range ports {
+		if response == strconv.Itoa(port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not one of the exposed ports %s", response, strings.Join(choices, ", "))
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
+	"lightfold/pkg/selfupdate"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// crashCommand is the command being run, recorded for crash dumps
+var crashCommand *cobra.Command
+
+// crashExit ends the process after a crash dump; tests replace it
+var crashExit = exit
+
+// recoverCrash turns a panic in a command into a local crash dump, sent to
+// the telemetry endpoint when the user opted in. Deferred by Execute.
+// Goroutines started from cmd go through crash.Go, which hands their panics
+// to handleCrash too.
+func recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	handleCrash(r, debug.Stack())
+}
+
+// handleCrash writes the crash dump for a panic and exits with status 2
+func handleCrash(r interface{}, stack []byte) {
+	sshpkg.ClosePool()
+	crash.StopCapture()
+
+	command := "lightfold"
+	var flags map[string][]string
+	if crashCommand != nil {
+		command = crashCommand.CommandPath()
+		flags = crashFlags(crashCommand)
+	}
+	cfg, _ := loadCrashConfig()
+	report := crash.NewReport(Version, command, flags, r, stack, crashSecrets(cfg))
+
+	fmt.Fprintf(os.Stderr, "\n%s lightfold crashed: %s\n", style.Error.Render("Error:"), report.Panic)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", stack)
+		crashExit(2)
+		return
+	}
+	path, err := crash.WriteDump(filepath.Join(home, config.LocalConfigDir, "crashes"), report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write crash dump: %v\n%s\n", err, stack)
+		crashExit(2)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Crash dump: %s\n", path)
+	fmt.Fprintf(os.Stderr, "Please attach it to an issue at https://github.com/%s/issues\n", selfupdate.Repository)
+	fmt.Fprintf(os.Stderr, "%s\n", style.Muted.Render("It holds the command, flags with secrets removed, versions, the stack trace and recent output."))
+
+	if cfg != nil && cfg.TelemetryEndpoint() != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := crash.Send(ctx, cfg.TelemetryEndpoint(), report); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", style.Muted.Render(fmt.Sprintf("Could not send the crash report: %v", err)))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", style.Muted.Render("Crash report sent (telemetry is on)"))
+		}
+	}
+	crashExit(2)
+}
+
+// loadCrashConfig loads the config for a crash dump, surviving a config that
+// panics to load
+func loadCrashConfig() (cfg *config.Config, err error) {
+	defer func() {
+		if recover() != nil {
+			cfg, err = nil, fmt.Errorf("config could not be loaded")
+		}
+	}()
+	return config.LoadConfig()
+}
+
+// crashFlags returns the values of the flags set on the command line. String
+// array and slice flags keep one entry per value so --env KEY=VALUE pairs can
+// be sanitized one by one.
+func crashFlags(cmd *cobra.Command) map[string][]string {
+	flags := map[string][]string{}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			flags[flag.Name] = slice.GetSlice()
+			return
+		}
+		flags[flag.Name] = []string{flag.Value.String()}
+	})
+	return flags
+}
+
+// crashSecrets returns the env values of every target and the stored API
+// tokens, which are scrubbed from crash dumps wherever they appear
+func crashSecrets(cfg *config.Config) []string {
+	secrets := []string{}
+	if cfg != nil {
+		for _, target := range cfg.Targets {
+			if target.Deploy == nil {
+				continue
+			}
+			for _, value := range target.Deploy.EnvVars {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	if tokens, err := config.LoadTokens(); err == nil {
+		for _, token := range tokens {
+			secrets = append(secrets, token)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"lightfold/pkg/crash"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoroutinePanicWritesCrashDump(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	exited := make(chan int, 1)
+	crashExit = func(code int) { exited <- code }
+	crash.SetHandler(handleCrash)
+	t.Cleanup(func() {
+		crashExit = exit
+		crash.SetHandler(nil)
+	})
+
+	crash.Go(func() { panic("worker boom") })
+	if code := <-exited; code != 2 {
+		t.Errorf("exit code = %d, want 2", code)
+	}
+
+	dumps, _ := filepath.Glob(filepath.Join(home, ".lightfold", "crashes", "*.json"))
+	if len(dumps) != 1 {
+		t.Fatalf("crash dumps = %v, want one", dumps)
+	}
+	data, _ := os.ReadFile(dumps[0])
+	if !strings.Contains(string(data), "worker boom") || !strings.Contains(string(data), "TestGoroutinePanicWritesCrashDump") {
+		t.Errorf("dump should hold the panic and the goroutine's stack, got:\n%s", data)
+	}
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/state"
 	"lightfold/pkg/util"
 	"os"
 
@@ -14,9 +16,13 @@ var (
 	ipFlag       string
 	sshKeyFlag   string
 	userFlag     string
+	sshHostFlag  string
 	regionFlag   string
 	sizeFlag     string
 	imageFlag    string
+	ipStackFlag  string
+
+	userDataFileFlag string
 )
 
 var createCmd = &cobra.Command{
@@ -28,10 +34,23 @@ This command supports two modes:
 
 1. BYOS (Bring Your Own Server) - Use existing infrastructure:
    lightfold create --target myapp --provider byos --ip 192.168.1.100 --ssh-key ~/.ssh/id_rsa --user deploy
+   lightfold create --target myapp --provider byos --ssh-host myserver-prod
+
+   --ssh-host takes a Host alias from ~/.ssh/config; its HostName, User, Port,
+   IdentityFile and ProxyJump are read on every connect. --ip, --ssh-key and
+   --user override them when given.
 
 2. Auto-provision - Create new infrastructure:
    lightfold create --target myapp --provider do --region nyc1 --size s-1vcpu-1gb
    lightfold create --target myapp --provider hetzner --region nbg1 --size cx11
+   lightfold create --target myapp --provider hetzner --region nbg1 --size cx22 --ip-stack ipv6-only
+
+Extra cloud-init (e.g. monitoring agents, CA certificates):
+   lightfold create --target myapp --provider do --region nyc1 --size s-1vcpu-1gb --user-data-file ./extra.yaml
+
+   The file may contain write_files and runcmd entries, which are merged into the
+   generated cloud-init before the server is created. On BYOS and existing servers
+   the same entries run once over SSH as a root script.
 
 If no target name is provided, the current directory name will be used.`,
 	Args: cobra.MaximumNArgs(1),
@@ -47,7 +66,7 @@ If no target name is provided, the current directory name will be used.`,
 		projectPath, err = util.ValidateProjectPath(projectPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if targetName == "" {
@@ -56,10 +75,29 @@ If no target name is provided, the current directory name will be used.`,
 
 		cfg := loadConfigOrExit()
 
-		_, err = createTarget(targetName, projectPath, cfg)
+		if err := utils.CheckTargetNameCollision(cfg, targetName, projectPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		var framework string
+		if !state.IsCreated(targetName) {
+			target, _ := cfg.GetTarget(targetName)
+			if err := applyFrameworkFlag(&target); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			if _, err := resolveFramework(cfg, &target, projectPath, true); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			framework = target.FrameworkOverride
+		}
+
+		_, err = createTarget(targetName, projectPath, cfg, framework)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Printf("Run 'lightfold configure --target %s' to configure the server.\n", targetName)
@@ -70,17 +108,21 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 
 	createCmd.Flags().StringVar(&targetName, "target", "", "Target name (defaults to current directory name)")
-	createCmd.Flags().StringVar(&providerFlag, "provider", "", "Provider: byos, do, hetzner (required)")
+	createCmd.Flags().StringVar(&providerFlag, "provider", "", "Provider: byos, do, hetzner, or exec:<name> for a lightfold-provider-<name> plugin (required)")
 
 	// BYOS flags
 	createCmd.Flags().StringVar(&ipFlag, "ip", "", "Server IP address (for BYOS)")
 	createCmd.Flags().StringVar(&sshKeyFlag, "ssh-key", "", "SSH private key path (for BYOS)")
-	createCmd.Flags().StringVar(&userFlag, "user", "root", "SSH username (for BYOS)")
+	createCmd.Flags().StringVar(&userFlag, "user", "", "SSH username (for BYOS, default root)")
+	createCmd.Flags().StringVar(&sshHostFlag, "ssh-host", "", "Host alias from ~/.ssh/config (for BYOS)")
 
 	// Provision flags
 	createCmd.Flags().StringVar(&regionFlag, "region", "", "Region/location (for provisioning)")
 	createCmd.Flags().StringVar(&sizeFlag, "size", "", "Server size/type (for provisioning)")
 	createCmd.Flags().StringVar(&imageFlag, "image", "ubuntu-22-04-x64", "OS image (for provisioning)")
+	createCmd.Flags().StringVar(&ipStackFlag, "ip-stack", "", "Public IP stack: dual, ipv4-only or ipv6-only (ipv6-only on Hetzner and Vultr; defaults to the provider's)")
+	createCmd.Flags().StringVar(&frameworkFlag, "framework", "", "Deploy as this framework (e.g. django), skipping detection's pick; saved to the target")
+	createCmd.Flags().StringVar(&userDataFileFlag, "user-data-file", "", "Cloud-init YAML with extra write_files/runcmd entries (run as a script on BYOS servers)")
 
 	createCmd.MarkFlagRequired("provider")
 }
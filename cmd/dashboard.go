@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lightfold/cmd/ui/dashboard"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
+	"lightfold/pkg/state"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Show an interactive overview of all targets",
+	Long: `Show every target in a table with its provider, framework, server, service
+state and last deploy. Service states are read from the servers in the
+background.
+
+Keys act on the selected target:
+  enter, s   Show the target's status
+  p          Push the target
+  l          Show the app's logs
+  o          Open the app's URL in the browser
+  r          Read all service states again
+  q          Quit
+
+Running lightfold without arguments opens the dashboard in a terminal when
+targets are configured. Use 'lightfold detect' for framework detection.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if jsonOutput || skipInteractive || !isTerminal() {
+			fmt.Fprintln(os.Stderr, "Error: the dashboard needs an interactive terminal; use 'lightfold status' instead")
+			exit(1)
+		}
+		runDashboard(loadConfigOrExit())
+	},
+}
+
+// runDefaultCommand is bare `lightfold`: the dashboard in a terminal once
+// targets exist, framework detection otherwise
+func runDefaultCommand(cmd *cobra.Command, args []string) {
+	if len(args) == 0 && !jsonOutput && !skipInteractive && isTerminal() {
+		if cfg, err := config.LoadConfig(); err == nil && len(cfg.Targets) > 0 {
+			runDashboard(cfg)
+			return
+		}
+	}
+	runRootCommand(cmd, args)
+}
+
+func runDashboard(cfg *config.Config) {
+	names := make([]string, 0, len(cfg.Targets))
+	for name := range cfg.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]dashboard.Row, 0, len(names))
+	for _, name := range names {
+		targetState, err := state.LoadState(name)
+		if err != nil {
+			targetState = &state.TargetState{}
+		}
+		rows = append(rows, dashboardRow(summarizeTarget(name, cfg.Targets[name], targetState), cfg.Targets[name], targetState))
+	}
+
+	model := dashboard.New(dashboard.Options{
+		Rows:  rows,
+		Fetch: dashboardFetcher(cfg),
+		Run:   runDashboardAction,
+		Open:  openBrowser,
+	})
+	if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
+
+// dashboardFetcher returns the dashboard's row reader. Like the status
+// summary, at most statusWorkers servers are read at once.
+func dashboardFetcher(cfg *config.Config) func(string) dashboard.Row {
+	workers := make(chan struct{}, statusWorkers)
+	return func(name string) dashboard.Row {
+		workers <- struct{}{}
+		defer func() { <-workers }()
+
+		target := cfg.Targets[name]
+		targetState, err := state.LoadState(name)
+		if err != nil {
+			return dashboard.Row{Target: name, Provider: target.Provider, Framework: target.Framework, Err: err.Error()}
+		}
+		summary := summarizeTarget(name, target, targetState)
+		collectRemoteSummary(&summary, target, name, targetState)
+		return dashboardRow(summary, target, targetState)
+	}
+}
+
+// dashboardRow turns a target's status summary into a dashboard row
+func dashboardRow(summary StatusOutput, target config.TargetConfig, targetState *state.TargetState) dashboard.Row {
+	row := dashboard.Row{
+		Target:     summary.Target,
+		Provider:   summary.Provider,
+		Framework:  summary.Framework,
+		ServerIP:   summary.ServerIP,
+		Domain:     summary.Domain,
+		LastDeploy: targetState.LastDeploy,
+		Service:    summary.ServiceStatus,
+		Uptime:     summary.ServiceUptime,
+		Err:        summary.RemoteError,
+	}
+	switch {
+	case summary.Paused:
+		row.Service = "paused"
+	case !summary.Created:
+		row.Service = "not created"
+	}
+
+	switch {
+	case summary.Domain != "" && target.Domain.SSLEnabled:
+		row.URL = "https://" + summary.Domain
+	case summary.Domain != "":
+		row.URL = "http://" + summary.Domain
+	case summary.Flyio != nil && summary.Flyio.Hostname != "":
+		row.URL = "https://" + summary.Flyio.Hostname
+	case summary.ServerIP != "" && summary.Created:
+		row.URL = "http://" + summary.ServerIP
+	}
+	return row
+}
+
+// dashboardActionArgs are the lightfold arguments an action runs with
+func dashboardActionArgs(action dashboard.Action) []string {
+	return []string{action.Kind.String(), "--target", action.Target}
+}
+
+// runDashboardAction runs an action as a lightfold child process with the
+// terminal handed over, and waits for enter before going back to the table
+func runDashboardAction(action dashboard.Action) tea.Cmd {
+	executable, err := os.Executable()
+	if err != nil {
+		return func() tea.Msg { return dashboard.ActionDoneMsg{Action: action, Err: err} }
+	}
+	child := &pausedCommand{cmd: exec.Command(executable, dashboardActionArgs(action)...)}
+	return tea.Exec(child, func(err error) tea.Msg {
+		return dashboard.ActionDoneMsg{Action: action, Err: err}
+	})
+}
+
+// pausedCommand runs a command and waits for enter afterwards, so its output
+// stays readable until the dashboard takes the screen back
+type pausedCommand struct {
+	cmd   *exec.Cmd
+	stdin io.Reader
+	out   io.Writer
+}
+
+func (c *pausedCommand) SetStdin(r io.Reader)  { c.cmd.Stdin, c.stdin = r, r }
+func (c *pausedCommand) SetStdout(w io.Writer) { c.cmd.Stdout, c.out = w, w }
+func (c *pausedCommand) SetStderr(w io.Writer) { c.cmd.Stderr = w }
+
+func (c *pausedCommand) Run() error {
+	err := c.cmd.Run()
+	if c.stdin == nil {
+		c.stdin = os.Stdin
+	}
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+	fmt.Fprintf(c.out, "\n%s", style.Muted.Render("Press enter to return to the dashboard"))
+	bufio.NewReader(c.stdin).ReadString('\n')
+	return err
+}
+
+// openBrowser opens a URL with the platform's default handler
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// The handler hands the URL over and exits; don't leave it a zombie
+	crash.Go(func() { cmd.Wait() })
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"lightfold/cmd/ui/dashboard"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers/flyio"
+	"lightfold/pkg/state"
+	"slices"
+	"testing"
+)
+
+func TestDashboardRowURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary StatusOutput
+		target  config.TargetConfig
+		want    string
+	}{
+		{
+			name:    "domain with ssl",
+			summary: StatusOutput{Domain: "app.example.com", ServerIP: "203.0.113.10", Created: true},
+			target:  config.TargetConfig{Domain: &config.DomainConfig{Domain: "app.example.com", SSLEnabled: true}},
+			want:    "https://app.example.com",
+		},
+		{
+			name:    "domain without ssl",
+			summary: StatusOutput{Domain: "app.example.com", Created: true},
+			target:  config.TargetConfig{Domain: &config.DomainConfig{Domain: "app.example.com"}},
+			want:    "http://app.example.com",
+		},
+		{
+			name:    "fly.io hostname",
+			summary: StatusOutput{Created: true, Flyio: &flyio.AppStatus{Hostname: "shop-123.fly.dev"}},
+			want:    "https://shop-123.fly.dev",
+		},
+		{
+			name:    "server ip",
+			summary: StatusOutput{ServerIP: "203.0.113.10", Created: true},
+			want:    "http://203.0.113.10",
+		},
+		{
+			name:    "not created",
+			summary: StatusOutput{ServerIP: "203.0.113.10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dashboardRow(tt.summary, tt.target, &state.TargetState{}).URL; got != tt.want {
+				t.Errorf("URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDashboardRowService(t *testing.T) {
+	if got := dashboardRow(StatusOutput{Created: true, Paused: true}, config.TargetConfig{}, &state.TargetState{}).Service; got != "paused" {
+		t.Errorf("paused target service = %q", got)
+	}
+	if got := dashboardRow(StatusOutput{}, config.TargetConfig{}, &state.TargetState{}).Service; got != "not created" {
+		t.Errorf("uncreated target service = %q", got)
+	}
+}
+
+func TestDashboardActionArgs(t *testing.T) {
+	for kind, want := range map[dashboard.ActionKind][]string{
+		dashboard.ActionStatus: {"status", "--target", "shop"},
+		dashboard.ActionPush:   {"push", "--target", "shop"},
+		dashboard.ActionLogs:   {"logs", "--target", "shop"},
+	} {
+		if got := dashboardActionArgs(dashboard.Action{Kind: kind, Target: "shop"}); !slices.Equal(got, want) {
+			t.Errorf("dashboardActionArgs(%s) = %v, want %v", kind, got, want)
+		}
+	}
+}
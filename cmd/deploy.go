@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
 	"lightfold/pkg/deploy"
 	"lightfold/pkg/detector"
+	installers "lightfold/pkg/runtime/installers"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
 	"lightfold/pkg/util"
 	"os"
 	"path/filepath"
@@ -19,19 +23,35 @@ import (
 )
 
 var (
-	envFile           string
-	envVars           []string
-	skipBuild         bool
-	deployTargetFlag  string
-	deployForceFlag   bool
-	deployDryRun      bool
-	deployBuilderFlag string
-	deployServerIP    string
-
-	deployStepHeaderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	deploySuccessStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	deployMutedStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	deployValueStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+	envFile                 string
+	envVars                 []string
+	skipBuild               bool
+	skipMigrations          bool
+	rerunFirstDeploy        bool
+	forceBuild              bool
+	autoInstallBuilder      bool
+	aptWaitFlag             time.Duration
+	killStaleAptFlag        bool
+	deployTargetFlag        string
+	deployForceFlag         bool
+	deployDryRun            bool
+	deployBuilderFlag       string
+	deployServerIP          string
+	deployNoDrain           bool
+	deployWaitForLock       time.Duration
+	deployAfterCurrent      bool
+	deployKeepFailedRelease bool
+	deployAllFlag           bool
+	deployIncludePaused     bool
+	deployTail              time.Duration
+	deployTailLogs          bool // --tail was given; a bare --tail streams until Ctrl-C
+	deployBuildArgs         []string
+	deployBuildTarget       string
+
+	deployStepHeaderStyle = style.Header
+	deploySuccessStyle    = style.Success
+	deployMutedStyle      = style.Muted
+	deployValueStyle      = style.Value
 )
 
 var deployCmd = &cobra.Command{
@@ -50,9 +70,33 @@ Examples:
   lightfold deploy ~/Projects/myapp          # Deploy specific project
   lightfold deploy --target myapp            # Deploy named target
   lightfold deploy --target myapp --force    # Force rerun all steps
-  lightfold deploy --dry-run                 # Preview deployment plan`,
+  lightfold deploy --dry-run                 # Preview deployment plan
+  lightfold deploy --dry-run --json          # Deployment plan as JSON
+  lightfold deploy --all                     # Deploy every target except paused ones
+  lightfold deploy --environment staging     # Deploy the staging environment from lightfold.yml
+  lightfold deploy --after-current           # Queue behind a deploy already running on the server
+  lightfold deploy --tail=1m                 # Stream the app's logs for a minute after deploying`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		deployTailLogs = cmd.Flags().Changed("tail")
+		if deployTail < 0 {
+			fmt.Fprintf(os.Stderr, "Error: --tail must not be negative\n")
+			exit(1)
+		}
+
+		if deployAllFlag {
+			if deployTargetFlag != "" || deployEnvironmentFlag != "" || len(args) > 0 {
+				fmt.Fprintf(os.Stderr, "Error: --all cannot be combined with a target\n")
+				exit(1)
+			}
+			if deployTailLogs {
+				fmt.Fprintf(os.Stderr, "Error: --tail cannot be combined with --all\n")
+				exit(1)
+			}
+			deployAllTargets(loadConfigOrExit())
+			return
+		}
+
 		effectiveTarget := deployTargetFlag
 		if effectiveTarget == "" {
 			if len(args) > 0 {
@@ -64,52 +108,89 @@ Examples:
 
 		cfg := loadConfigOrExit()
 
-		target, exists := cfg.GetTarget(effectiveTarget)
+		var target config.TargetConfig
+		var exists bool
 		var projectPath string
 		var targetName string
+		var environment *deployEnvironment
 
-		if !exists {
+		if deployEnvironmentFlag != "" {
+			if deployTargetFlag != "" {
+				fmt.Fprintf(os.Stderr, "Error: --environment cannot be combined with --target\n")
+				exit(1)
+			}
 			var err error
 			projectPath, err = util.ValidateProjectPath(effectiveTarget)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
+			}
+			projectPath = filepath.Clean(projectPath)
+			environment, target, exists, err = resolveEnvironmentTarget(cfg, projectPath, deployEnvironmentFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			targetName = environment.TargetName
+		} else if target, exists = cfg.GetTarget(effectiveTarget); !exists {
+			var err error
+			projectPath, err = util.ValidateProjectPath(effectiveTarget)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			if name, existing, found := cfg.FindTargetByPath(projectPath); found {
+				target, targetName, exists = existing, name, true
+			} else {
+				targetName = util.GetTargetName(projectPath)
+				if err := utils.CheckTargetNameCollision(cfg, targetName, projectPath); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					exit(1)
+				}
 			}
-			targetName = util.GetTargetName(projectPath)
 		} else {
 			projectPath = target.ProjectPath
 			targetName = effectiveTarget
 		}
 
 		projectPath = filepath.Clean(projectPath)
+		exitIfPaused(targetName)
+		exitIfBaseDirMoved(targetName, target)
+		exitIfEnvLocked(cfg, targetName)
+		if !deployDryRun {
+			// deploy re-runs configure, which keeps the maintenance page up
+			exitIfMaintenance(targetName, true)
+		}
+
+		if err := applyFrameworkFlag(&target); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
 
 		if deployDryRun {
-			fmt.Println("DRY RUN - Deployment plan:")
-			fmt.Printf("Target: %s\n", targetName)
-			fmt.Printf("Steps:\n")
-			if !exists || deployForceFlag {
-				fmt.Println("  1. ✓ detect - Framework detection")
-			} else {
-				fmt.Println("  1. ⊘ detect - Skipped (cached)")
-			}
-			if !state.IsCreated(targetName) || deployForceFlag {
-				fmt.Println("  2. ✓ create - Infrastructure provisioning")
-			} else {
-				fmt.Println("  2. ⊘ create - Skipped (already created)")
-			}
-			if !state.IsConfigured(targetName) || deployForceFlag {
-				fmt.Println("  3. ✓ configure - Server configuration")
-			} else {
-				fmt.Println("  3. ⊘ configure - Skipped (already configured)")
+			plan, err := buildDeployPlan(target, targetName, projectPath, exists, deployForceFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
 			}
-			fmt.Println("  4. ✓ push - Release deployment")
+			printDeployPlan(plan)
 			return
 		}
 
+		if exists {
+			exitUnlessProtectedConfirmed(target, targetName)
+		}
+
 		fmt.Printf("\n%s\n", deployStepHeaderStyle.Render(fmt.Sprintf("Step 1/4: Analyzing '%s' app", targetName)))
-		detection := detector.DetectFramework(projectPath)
+		detection, err := resolveFramework(cfg, &target, projectPath, !exists)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		framework := target.FrameworkOverride
 
-		fmt.Printf("%s %s (%s)\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render(detection.Framework), deployMutedStyle.Render(detection.Language))
+		fmt.Printf("%s %s (%s)\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render(detection.Framework), deployMutedStyle.Render(detection.Language))
+		exitIfLibrary(detection, deployForceFlag)
 
 		if pm, ok := detection.Meta["package_manager"]; ok && pm != "" {
 			if len(detection.BuildPlan) > 0 {
@@ -118,93 +199,119 @@ Examples:
 			}
 		}
 
+		if app := detection.Meta["monorepo_app"]; app != "" {
+			fmt.Printf("  %s %s\n", deployMutedStyle.Render("App:"), deployMutedStyle.Render(fmt.Sprintf("%s in %s (%s)", app, detection.Meta["monorepo_subdir"], detection.Meta["monorepo_tool"])))
+		}
+
 		builderName := resolveBuilder(target, projectPath, &detection, deployBuilderFlag)
 
 		fmt.Printf("  %s %s\n", deployMutedStyle.Render("Builder:"), deployMutedStyle.Render(builderName))
+		if summary := staticServingSummary(&detection); summary != "" {
+			fmt.Printf("  %s %s\n", deployMutedStyle.Render("Serving:"), deployMutedStyle.Render(summary))
+		}
 
 		if target.Builder != builderName {
 			target.Builder = builderName
 		}
 
 		fmt.Printf("\n%s\n", deployStepHeaderStyle.Render("Step 2/4: Creating infrastructure"))
-		var err error
 
 		// If server-ip is provided, setup target with existing server
 		if deployServerIP != "" {
 			if exists {
 				if err := utils.SetupTargetWithExistingServer(&target, deployServerIP, 0); err != nil {
 					fmt.Fprintf(os.Stderr, "Error configuring target for existing server: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 				if err := cfg.SetTarget(targetName, target); err != nil {
 					fmt.Fprintf(os.Stderr, "Error saving target config: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 				if err := cfg.SaveConfig(); err != nil {
 					fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 
 				if err := state.MarkCreated(targetName, ""); err != nil {
 					fmt.Printf("Warning: failed to update state: %v\n", err)
 				}
 
-				skipStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+				skipStyle := style.Muted
 				fmt.Printf("  %s\n", skipStyle.Render(fmt.Sprintf("Using existing server %s (skipping provisioning)", deployServerIP)))
 			} else {
 				target = config.TargetConfig{
-					ProjectPath: projectPath,
-					Framework:   detection.Framework,
+					ProjectPath:       projectPath,
+					Framework:         detection.Framework,
+					FrameworkOverride: framework,
 				}
 
 				if err := utils.SetupTargetWithExistingServer(&target, deployServerIP, 0); err != nil {
 					fmt.Fprintf(os.Stderr, "Error configuring target for existing server: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 
 				if err := cfg.SetTarget(targetName, target); err != nil {
 					fmt.Fprintf(os.Stderr, "Error saving target config: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 				if err := cfg.SaveConfig(); err != nil {
 					fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-					os.Exit(1)
+					exit(1)
 				}
 
 				if err := state.MarkCreated(targetName, ""); err != nil {
 					fmt.Printf("Warning: failed to update state: %v\n", err)
 				}
 
-				skipStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+				skipStyle := style.Muted
 				fmt.Printf("  %s\n", skipStyle.Render(fmt.Sprintf("Using existing server %s (skipping provisioning)", deployServerIP)))
 			}
 		} else {
 			// Normal flow - create infrastructure
-			target, err = createTarget(targetName, projectPath, cfg)
+			target, err = createTarget(targetName, projectPath, cfg, framework)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating infrastructure: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 
 		cfg = loadConfigOrExit()
 		target = loadTargetOrExit(cfg, targetName)
 
+		// A new environment target gets its lightfold.yml settings once it exists
+		if environment != nil {
+			if err := applyEnvironment(&target, environment, projectPath); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		}
+
 		target.Builder = builderName
+		if framework != "" {
+			target.FrameworkOverride = framework
+			target.Framework = detection.Framework
+		}
 		if err := cfg.SetTarget(targetName, target); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving builder config: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 		if err := cfg.SaveConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-			os.Exit(1)
+			exit(1)
+		}
+
+		// Configuring is what runs the dockerfile or nixpacks builder, so it
+		// gets the build options; they are applied again to the reloaded target
+		if err := target.ProcessBuildOptions(deployBuildArgs, deployBuildTarget); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
 		}
 
 		fmt.Printf("\n%s\n", deployStepHeaderStyle.Render("Step 3/4: Configuring server"))
 		isCalledFromDeploy = true
 		if err := configureTarget(target, targetName, deployForceFlag); err != nil {
 			fmt.Fprintf(os.Stderr, "Error configuring server: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 		isCalledFromDeploy = false
 
@@ -220,7 +327,11 @@ Examples:
 
 		if err := target.ProcessDeploymentOptions(envFile, envVars, skipBuild); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
+		}
+		if err := target.ProcessBuildOptions(deployBuildArgs, deployBuildTarget); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
 		}
 
 		// Branch on deployment strategy
@@ -228,7 +339,7 @@ Examples:
 			// Container-based deployment (e.g., fly.io)
 			if err := deployViaContainer(target, targetName, projectPath, &detection); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 			return
 		}
@@ -237,7 +348,7 @@ Examples:
 		sshProviderCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Ensure server state is initialized
@@ -250,7 +361,7 @@ Examples:
 			port, err := getOrAllocatePort(&target, targetName)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error allocating port: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 			target.Port = port
 
@@ -263,76 +374,148 @@ Examples:
 			}
 		}
 
-		sshExecutor := sshpkg.NewExecutor(sshProviderCfg.GetIP(), "22", sshProviderCfg.GetUsername(), sshProviderCfg.GetSSHKey())
+		if err := utils.CheckServerAppCollision(target.ServerIP, targetName, utils.RemoteAppName(&target, targetName)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		sshExecutor := sshpkg.NewExecutorFromConfig(sshProviderCfg)
 		defer sshExecutor.Disconnect()
 
 		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
 			fmt.Fprintf(os.Stderr, "Error connecting to server: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
-		projectName := util.GetTargetName(projectPath)
+		appName := resolveAppName(&target, targetName, sshExecutor)
+		adoptAppName(&target, targetName, appName, sshExecutor)
 
 		var executor *deploy.Executor
 		if target.Deploy != nil && (len(target.Deploy.BuildCommands) > 0 || len(target.Deploy.RunCommands) > 0) {
-			executor = deploy.NewExecutorWithOptions(sshExecutor, projectName, projectPath, &detection, target.Deploy)
+			executor = deploy.NewExecutorWithOptions(sshExecutor, appName, projectPath, &detection, target.Deploy)
 		} else {
-			executor = deploy.NewExecutor(sshExecutor, projectName, projectPath, &detection)
+			executor = deploy.NewExecutor(sshExecutor, appName, projectPath, &detection)
+		}
+		if target.Deploy != nil {
+			executor.SetDrainSeconds(target.Deploy.DrainSeconds)
 		}
+		executor.SetNoDrain(deployNoDrain)
+		executor.SetBaseDir(target.RemoteBaseDir())
+		executor.ApplyServerTuning(sshProviderCfg.GetIP(), target.Deploy)
+		executor.SetMigrationOptions(target.Deploy, skipMigrations)
+		executor.SetFirstDeployOptions(target.Deploy, rerunFirstDeploy)
+		executor.SetAssetOptions(target.Assets)
+		executor.SetBuildMemoryOptions(target.Builder, target.Deploy, forceBuild)
+		executor.SetPackWorkers(cfg.PackWorkers)
+		setProxyHealthCheck(executor, target, targetName, sshProviderCfg.GetIP())
 
-		tmpTarball := fmt.Sprintf("/tmp/lightfold-%s-release.tar.gz", projectName)
+		if !target.Deploy.SkipBuild {
+			if err := checkBuildMemory(executor, targetName); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		}
+
+		currentCommit := getGitCommit(projectPath)
+		if wait := lockWait(deployWaitForLock, deployAfterCurrent); wait > 0 {
+			queueBehindRunningDeploy(executor, projectPath, currentCommit, wait)
+		}
+		if err := executor.AcquireLease(currentCommit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		defer executor.ReleaseLease()
+		// os.Exit skips deferred calls, so exits past this point release the lease first
+		exitReleasing := func(code int, paths ...string) {
+			executor.ReleaseLease()
+			exitRemoving(code, paths...)
+		}
+
+		tmpTarball := fmt.Sprintf("/tmp/lightfold-%s-release.tar.gz", appName)
 		if err := executor.CreateReleaseTarball(tmpTarball); err != nil {
 			state.MarkPushFailed(targetName, fmt.Sprintf("failed to create tarball: %v", err))
 			fmt.Fprintf(os.Stderr, "Error creating tarball: %v\n", err)
-			os.Exit(1)
+			exitReleasing(1)
 		}
 		defer os.Remove(tmpTarball)
-		fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Creating release tarball..."))
+		fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Creating release tarball..."))
+
+		if !confirmReleaseSecrets(executor.ReleaseSecrets(), secretBuildArgs(target.Deploy)) {
+			fmt.Println(deployMutedStyle.Render("Deployment cancelled."))
+			exitReleasing(0, tmpTarball)
+		}
 
 		releasePath, err := executor.UploadRelease(tmpTarball)
 		if err != nil {
 			state.MarkPushFailed(targetName, fmt.Sprintf("failed to upload release: %v", err))
 			fmt.Fprintf(os.Stderr, "Error uploading release: %v\n", err)
-			os.Exit(1)
+			exitReleasing(1, tmpTarball)
 		}
-		fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Uploading release to server..."))
+		fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Uploading release to server..."))
 
 		if !target.Deploy.SkipBuild {
 			if err := executor.BuildReleaseWithEnv(releasePath, target.Deploy.EnvVars); err != nil {
 				state.MarkPushFailed(targetName, fmt.Sprintf("failed to build release: %v", err))
 				fmt.Fprintf(os.Stderr, "Error building release: %v\n", err)
-				os.Exit(1)
+				discardFailedRelease(executor, releasePath, deployKeepFailedRelease)
+				exitReleasing(1, tmpTarball)
+			}
+			fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Building app..."))
+
+			if err := uploadReleaseAssets(executor, releasePath); err != nil {
+				state.MarkPushFailed(targetName, err.Error())
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				discardFailedRelease(executor, releasePath, deployKeepFailedRelease)
+				exitReleasing(1, tmpTarball)
 			}
-			fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Building app..."))
 		}
 
-		if len(target.Deploy.EnvVars) > 0 {
-			if err := executor.WriteEnvironmentFile(target.Deploy.EnvVars); err != nil {
+		if releaseEnv := executor.ReleaseEnvironment(target.Deploy.EnvVars); len(releaseEnv) > 0 {
+			if err := executor.WriteEnvironmentFile(releaseEnv); err != nil {
 				state.MarkPushFailed(targetName, fmt.Sprintf("failed to write environment file: %v", err))
 				fmt.Fprintf(os.Stderr, "Error writing environment: %v\n", err)
-				os.Exit(1)
+				discardFailedRelease(executor, releasePath, deployKeepFailedRelease)
+				exitReleasing(1, tmpTarball)
 			}
-			fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Configuring environment variables..."))
+			fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Configuring environment variables..."))
 		}
 
-		if err := executor.DeployWithHealthCheck(releasePath, target.Port, 5, 3*time.Second); err != nil {
+		var switchedAt int64
+		if deployTailLogs {
+			switchedAt = remoteUnixTime(sshExecutor)
+		}
+		err = executor.DeployWithHealthCheck(releasePath, target.Port, 5, 3*time.Second)
+		recordDeployHealth(executor, targetName)
+		recordFirstDeploy(executor, targetName)
+		if err != nil {
+			var superseded *deploy.SupersededError
+			if errors.As(err, &superseded) {
+				discardFailedRelease(executor, releasePath, deployKeepFailedRelease)
+				executor.ReleaseLease()
+				exitSuperseded(targetName, filepath.Base(releasePath), currentCommit, superseded, tmpTarball)
+			}
 			state.MarkPushFailed(targetName, fmt.Sprintf("deployment failed: %v", err))
 			fmt.Fprintf(os.Stderr, "Error during deployment: %v\n", err)
-			os.Exit(1)
+			printMigrationBackoutWarning(err)
+			if deployTailLogs && errors.Is(err, deploy.ErrRolledBack) {
+				showFailedReleaseLogs(sshExecutor, appName, detection.Framework, detection.Meta["deployment_type"] == "static", switchedAt)
+			}
+			exitReleasing(1, tmpTarball)
 		}
-		fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Deploying and running health checks..."))
+		fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Deploying and running health checks..."))
+		fmt.Printf("  %s\n", deployMutedStyle.Render("Health: "+executor.DeployHealth().Summary()))
 
-		executor.CleanupOldReleases(cfg.NumReleases)
-		fmt.Printf("%s %s\n", deploySuccessStyle.Render("✓"), deployMutedStyle.Render("Cleaning up old releases..."))
+		executor.CleanupOldReleases(cfg.KeepReleasesFor(target))
+		fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render("Cleaning up old releases..."))
 
 		releaseTimestamp := filepath.Base(releasePath)
-		currentCommit := getGitCommit(projectPath)
 		if err := state.ClearPushFailure(targetName); err != nil {
 			fmt.Printf("Warning: failed to clear push failure in state: %v\n", err)
 		}
-		if err := state.UpdateDeployment(targetName, currentCommit, releaseTimestamp); err != nil {
+		if err := state.UpdateDeployment(targetName, currentCommit, releaseTimestamp, executor.UploadedChecksum()); err != nil {
 			fmt.Printf("Warning: failed to update state: %v\n", err)
 		}
+		recordBaseDir(targetName, target)
 
 		// Register app with server state
 		if err := registerAppWithServer(&target, targetName, target.Port, target.Framework); err != nil {
@@ -343,11 +526,14 @@ Examples:
 
 		// Build success message lines
 		successLines := []string{
-			deploySuccessStyle.Render(fmt.Sprintf("✓ Successfully deployed '%s'", targetName)),
+			deploySuccessStyle.Render(fmt.Sprintf("%s Successfully deployed '%s'", style.Check(), targetName)),
 			"",
 			fmt.Sprintf("%s %s", deployMutedStyle.Render("Server:"), deployValueStyle.Render(sshProviderCfg.GetIP())),
-			fmt.Sprintf("%s %s", deployMutedStyle.Render("Release:"), deployValueStyle.Render(releaseTimestamp)),
 		}
+		if ipv6 := extraIPv6(sshProviderCfg); ipv6 != "" {
+			successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("IPv6:"), deployValueStyle.Render(ipv6)))
+		}
+		successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Release:"), deployValueStyle.Render(releaseTimestamp)))
 
 		// Check if this is a multi-app deployment
 		serverState, serverStateErr := state.GetServerState(sshProviderCfg.GetIP())
@@ -364,11 +550,11 @@ Examples:
 			} else if !isMultiApp {
 				// Single-app without domain - nginx proxies port 80 to app port
 				// Don't show internal port, just the access URL
-				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Access:"), deployValueStyle.Render(fmt.Sprintf("http://%s", sshProviderCfg.GetIP()))))
+				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Access:"), deployValueStyle.Render(fmt.Sprintf("http://%s", urlHost(sshProviderCfg.GetIP())))))
 			} else {
-				// Multi-app without domain - needs domain or direct port access
-				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Port:"), deployValueStyle.Render(fmt.Sprintf("%d", target.Port))))
-				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Access:"), deployValueStyle.Render(fmt.Sprintf("http://%s:%d (direct port access)", sshProviderCfg.GetIP(), target.Port))))
+				// Multi-app without domain - nginx doesn't route to it, so it listens on all interfaces
+				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Port:"), deployValueStyle.Render(fmt.Sprintf("App exposed directly on port %d", target.Port))))
+				successLines = append(successLines, fmt.Sprintf("%s %s", deployMutedStyle.Render("Access:"), deployValueStyle.Render(fmt.Sprintf("http://%s:%d (direct port access)", urlHost(sshProviderCfg.GetIP()), target.Port))))
 			}
 		}
 
@@ -397,13 +583,11 @@ Examples:
 			successLines = append(successLines, "")
 			successLines = append(successLines, deployMutedStyle.Render("Other apps on this server:"))
 			for _, app := range otherApps {
-				successLines = append(successLines, fmt.Sprintf("  • %s", deployMutedStyle.Render(app)))
+				successLines = append(successLines, fmt.Sprintf("  %s %s", style.Bullet(), deployMutedStyle.Render(app)))
 			}
 		}
 
-		successBox := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("82")).
+		successBox := style.Box(style.ColorSuccess).
 			Padding(0, 1).
 			Render(lipgloss.JoinVertical(lipgloss.Left, successLines...))
 
@@ -411,24 +595,93 @@ Examples:
 		fmt.Println()
 
 		if target.Domain == nil || target.Domain.Domain == "" {
-			hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+			hintStyle := style.Muted
 			domainHint := fmt.Sprintf("Have a domain? Run 'lightfold domain add --target %s --domain example.com' to add a custom domain.", targetName)
 			fmt.Printf("%s\n", hintStyle.Render(domainHint))
 		}
+
+		if deployTailLogs {
+			// Ctrl-C ends the tail; the deploy is done and must not hold the lease
+			executor.ReleaseLease()
+			tailAfterDeploy(sshExecutor, appName, detection.Framework, detection.Meta["deployment_type"] == "static", deployTail)
+		}
 	},
 }
 
+// staticServingSummary describes how a static site is built and served, or "" for server apps
+func staticServingSummary(detection *detector.Detection) string {
+	if detection == nil || detection.Meta["deployment_type"] != "static" {
+		return ""
+	}
+
+	buildOutput := detection.Meta["build_output"]
+	if buildOutput == "" {
+		buildOutput = "dist/"
+	}
+
+	summary := fmt.Sprintf("static files from %s via nginx (no app service)", buildOutput)
+	switch detection.Framework {
+	case "Hugo":
+		version := detection.Meta["hugo_version"]
+		if version == "" {
+			version = installers.DefaultHugoVersion + " (default)"
+		}
+		summary += fmt.Sprintf(", Hugo %s installed on server", version)
+	case "Jekyll":
+		summary += ", Ruby + Bundler installed on server"
+	}
+
+	return summary
+}
+
+// exitIfLibrary stops a deploy of a project detection found to be a library,
+// such as a Vite component library, unless force is set
+func exitIfLibrary(detection detector.Detection, force bool) {
+	reason := detection.Meta["library"]
+	if reason == "" {
+		return
+	}
+	if force {
+		fmt.Printf("  %s\n", deployMutedStyle.Render(fmt.Sprintf("Deploying a library anyway (--force): %s", reason)))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: this project looks like a library, not an app: %s\n", reason)
+	fmt.Fprintf(os.Stderr, "Libraries are published to a package registry and have nothing to serve. Pass --force to deploy it anyway\n")
+	exit(1)
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
 
 	deployCmd.Flags().StringVar(&deployTargetFlag, "target", "", "Target name (defaults to current directory)")
 	deployCmd.Flags().StringVar(&deployServerIP, "server-ip", "", "Deploy to an existing server (skips server provisioning)")
-	deployCmd.Flags().BoolVar(&deployForceFlag, "force", false, "Force rerun all steps")
+	deployCmd.Flags().BoolVar(&deployForceFlag, "force", false, "Force rerun all steps, and deploy projects detected as libraries")
 	deployCmd.Flags().BoolVar(&deployDryRun, "dry-run", false, "Show deployment plan without executing")
+	deployCmd.Flags().StringVar(&frameworkFlag, "framework", "", "Deploy as this framework (e.g. django), skipping detection's pick; saved to the target")
 	deployCmd.Flags().StringVar(&deployBuilderFlag, "builder", "", "Builder to use: native, nixpacks, or dockerfile (auto-detected if not specified)")
 	deployCmd.Flags().StringVar(&envFile, "env-file", "", "Path to .env file with environment variables")
 	deployCmd.Flags().StringArrayVar(&envVars, "env", []string{}, "Environment variables in KEY=VALUE format (can be used multiple times)")
 	deployCmd.Flags().BoolVar(&skipBuild, "skip-build", false, "Skip the build step during deployment")
+	deployCmd.Flags().StringArrayVar(&deployBuildArgs, "build-arg", []string{}, "Build argument in KEY=VALUE format passed to docker build --build-arg or nixpacks --env (can be used multiple times)")
+	deployCmd.Flags().StringVar(&deployBuildTarget, "build-target", "", "Dockerfile stage to build (docker build --target)")
+	deployCmd.Flags().BoolVar(&skipMigrations, "skip-migrations", false, "Deploy without running database migrations")
+	deployCmd.Flags().BoolVar(&rerunFirstDeploy, "rerun-first-deploy", false, "Run deploy.first_deploy_commands again although they succeeded before")
+	deployCmd.Flags().BoolVar(&forceBuild, "force-build", false, "Build even when the server has too little memory for the framework")
+	deployCmd.Flags().IntVar(&containerPortFlag, "container-port", 0, "Port the app listens on inside its container (dockerfile builder; read from the Dockerfile's EXPOSE by default)")
+	deployCmd.Flags().BoolVar(&autoInstallBuilder, "auto-install-builder", false, "Install the target's pinned nixpacks version on the server when another version is installed")
+	deployCmd.Flags().DurationVar(&aptWaitFlag, "apt-wait", config.DefaultServerReadyTimeout, "How long to wait for apt/dpkg locks held on the server (e.g. by unattended-upgrades)")
+	deployCmd.Flags().BoolVar(&killStaleAptFlag, "kill-stale-apt", false, "Stop unattended-upgrades when it has held the apt locks for over 30m")
+	deployCmd.Flags().BoolVar(&deployNoDrain, "no-drain", false, "Restart without waiting for in-flight connections to drain")
+	deployCmd.Flags().DurationVar(&deployWaitForLock, "wait-for-lock", 0, "Wait up to this long for a deploy already running on the server instead of taking over (bare flag waits 30m; use --wait-for-lock=1h)")
+	deployCmd.Flags().Lookup("wait-for-lock").NoOptDefVal = defaultLockWait.String()
+	deployCmd.Flags().DurationVar(&deployTail, "tail", 0, "Stream the app's logs after a successful deploy for this long (bare flag streams until Ctrl-C); after a rollback, show the failed release's logs")
+	deployCmd.Flags().Lookup("tail").NoOptDefVal = "0s"
+	deployCmd.Flags().BoolVar(&deployAfterCurrent, "after-current", false, "Queue behind a deploy already running on the server (same as --wait-for-lock)")
+	deployCmd.Flags().BoolVar(&deployKeepFailedRelease, "keep-failed-release", false, "Keep the release directory on the server when the deploy fails before going live (for debugging)")
+	deployCmd.Flags().StringVar(&confirmProtectedFlag, "confirm-protected", "", "Confirm deploying a protected target by passing its name (required without a terminal)")
+	deployCmd.Flags().StringVar(&deployEnvironmentFlag, "environment", "", "Deploy an environment from lightfold.yml to the <project>-<environment> target")
+	deployCmd.Flags().BoolVar(&deployAllFlag, "all", false, "Deploy every configured target, skipping paused ones")
+	deployCmd.Flags().BoolVar(&deployIncludePaused, "include-paused", false, "With --all, also resume and deploy paused targets")
 }
 
 // deployViaContainer handles deployment for container-based providers (e.g., fly.io)
@@ -454,8 +707,9 @@ func deployViaContainer(target config.TargetConfig, targetName, projectPath stri
 
 		projectName := util.GetTargetName(projectPath)
 		deployer := deploy.NewFlyioDeployer(projectName, projectPath, targetName, detection, flyioConfig, token)
+		deployer.SetLabels(target.Labels)
 
-		fmt.Printf("%s %s\n", deployMutedStyle.Render("→"), deployMutedStyle.Render("Starting fly.io deployment..."))
+		fmt.Printf("%s %s\n", deployMutedStyle.Render(style.Arrow()), deployMutedStyle.Render("Starting fly.io deployment..."))
 		fmt.Println()
 
 		if err := deployer.Deploy(ctx, target.Deploy); err != nil {
@@ -468,19 +722,17 @@ func deployViaContainer(target config.TargetConfig, targetName, projectPath stri
 		if err := state.ClearPushFailure(targetName); err != nil {
 			fmt.Printf("Warning: failed to clear push failure in state: %v\n", err)
 		}
-		if err := state.UpdateDeployment(targetName, currentCommit, time.Now().Format("20060102150405")); err != nil {
+		if err := state.UpdateDeployment(targetName, currentCommit, timefmt.ReleaseName(time.Now()), ""); err != nil {
 			fmt.Printf("Warning: failed to update state: %v\n", err)
 		}
 
 		fmt.Println()
-		successBox := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("82")).
+		successBox := style.Box(style.ColorSuccess).
 			Padding(0, 1).
 			Render(
 				lipgloss.JoinVertical(
 					lipgloss.Left,
-					deploySuccessStyle.Render(fmt.Sprintf("✓ Successfully deployed '%s' to fly.io", targetName)),
+					deploySuccessStyle.Render(fmt.Sprintf("%s Successfully deployed '%s' to fly.io", style.Check(), targetName)),
 					"",
 					fmt.Sprintf("%s %s", deployMutedStyle.Render("App:"), deployValueStyle.Render(flyioConfig.AppName)),
 					fmt.Sprintf("%s %s", deployMutedStyle.Render("Region:"), deployValueStyle.Render(flyioConfig.Region)),
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"os"
+	"os/exec"
+	"sort"
+)
+
+// deployAllSelection splits the configured targets into those deploy --all
+// runs and the paused ones it skips, both sorted by name
+func deployAllSelection(cfg *config.Config, includePaused bool) (selected, skipped []string) {
+	for name := range cfg.Targets {
+		if !includePaused && state.IsPaused(name) {
+			skipped = append(skipped, name)
+			continue
+		}
+		selected = append(selected, name)
+	}
+	sort.Strings(selected)
+	sort.Strings(skipped)
+	return selected, skipped
+}
+
+// deployAllTargets deploys every selected target in turn. Each target runs in
+// its own `lightfold deploy --target` process so one failure does not stop
+// the rest. Paused targets included with --include-paused are resumed first.
+func deployAllTargets(cfg *config.Config) {
+	selected, skipped := deployAllSelection(cfg, deployIncludePaused)
+	for _, name := range skipped {
+		fmt.Printf("%s %s\n", deployMutedStyle.Render(style.Paused()), deployMutedStyle.Render(fmt.Sprintf("Skipping paused target '%s' (use --include-paused to deploy it)", name)))
+	}
+	if len(selected) == 0 {
+		fmt.Println(deployMutedStyle.Render("No targets to deploy."))
+		return
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	var failed []string
+	for i, name := range selected {
+		fmt.Printf("\n%s\n", deployStepHeaderStyle.Render(fmt.Sprintf("Target %d/%d: %s", i+1, len(selected), name)))
+
+		// Paused targets are only selected with --include-paused; they need
+		// their servers back before deploy will touch them
+		if state.IsPaused(name) {
+			if err := runSelf(self, "resume", "--target", name); err != nil {
+				failed = append(failed, name)
+				continue
+			}
+		}
+
+		if err := runSelf(self, deployAllArgs(name)...); err != nil {
+			failed = append(failed, name)
+		}
+	}
+
+	fmt.Println()
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Deployed %d/%d targets; failed: %v\n", len(selected)-len(failed), len(selected), failed)
+		exit(1)
+	}
+	fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render(fmt.Sprintf("Deployed %d targets", len(selected))))
+}
+
+func runSelf(self string, args ...string) error {
+	child := exec.Command(self, args...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	return child.Run()
+}
+
+// deployAllArgs builds the deploy invocation for one target, passing on the
+// flags that apply to every target
+func deployAllArgs(targetName string) []string {
+	args := []string{"deploy", "--target", targetName}
+	if deployForceFlag {
+		args = append(args, "--force")
+	}
+	if deployDryRun {
+		args = append(args, "--dry-run")
+	}
+	if skipBuild {
+		args = append(args, "--skip-build")
+	}
+	if skipMigrations {
+		args = append(args, "--skip-migrations")
+	}
+	if rerunFirstDeploy {
+		args = append(args, "--rerun-first-deploy")
+	}
+	if forceBuild {
+		args = append(args, "--force-build")
+	}
+	if deployNoDrain {
+		args = append(args, "--no-drain")
+	}
+	if deployKeepFailedRelease {
+		args = append(args, "--keep-failed-release")
+	}
+	if skipInteractive {
+		args = append(args, "--no-interactive")
+	}
+	if debugFlag {
+		args = append(args, "--debug")
+	}
+	return args
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+func TestDeployAllSelectionSkipsPaused(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{
+		"web":     {Provider: "hetzner"},
+		"staging": {Provider: "hetzner"},
+		"api":     {Provider: "digitalocean"},
+	}}
+	if err := state.MarkPaused("staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	selected, skipped := deployAllSelection(cfg, false)
+	if strings.Join(selected, ",") != "api,web" || strings.Join(skipped, ",") != "staging" {
+		t.Errorf("deployAllSelection() = %v, skipped %v", selected, skipped)
+	}
+
+	selected, skipped = deployAllSelection(cfg, true)
+	if strings.Join(selected, ",") != "api,staging,web" || len(skipped) != 0 {
+		t.Errorf("deployAllSelection(includePaused) = %v, skipped %v", selected, skipped)
+	}
+}
+
+func TestTargetServersNeedServerIDs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	target := config.TargetConfig{Provider: "hetzner"}
+	if err := target.SetProviderConfig("hetzner", &config.HetznerConfig{ServerID: "42", IP: "203.0.113.10", Provisioned: true}); err != nil {
+		t.Fatal(err)
+	}
+	target.Servers = []config.ServerConfig{{ServerID: "43", IP: "203.0.113.11"}}
+
+	servers, err := targetServers(target, "web")
+	if err != nil {
+		t.Fatalf("targetServers() error: %v", err)
+	}
+	if len(servers) != 2 || servers[0].serverID != "42" || servers[0].index != -1 || servers[1].serverID != "43" || servers[1].index != 0 {
+		t.Errorf("targetServers() = %+v", servers)
+	}
+
+	target.Servers = append(target.Servers, config.ServerConfig{IP: "203.0.113.12"})
+	if _, err := targetServers(target, "web"); err == nil || !strings.Contains(err.Error(), "203.0.113.12") {
+		t.Errorf("targetServers() error = %v, want the server without an ID", err)
+	}
+}
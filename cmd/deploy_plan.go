@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/builders"
+	"lightfold/pkg/builders/dockerfile"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/runtime"
+	installers "lightfold/pkg/runtime/installers"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"os"
+	"sort"
+	"time"
+)
+
+const runtimeUnknownUnreachable = "unknown (server unreachable)"
+
+// deployPlan is what `deploy --dry-run` would do, computed with the same
+// helpers the real pipeline uses
+type deployPlan struct {
+	Target         string             `json:"target"`
+	ProjectPath    string             `json:"project_path"`
+	Detection      deployPlanDetect   `json:"detection"`
+	Builder        string             `json:"builder"`
+	BuilderReason  string             `json:"builder_reason"`
+	BuilderVersion string             `json:"builder_version,omitempty"`
+	BuilderPin     string             `json:"builder_pin,omitempty"` // Pinned nixpacks version
+	Steps          []deployPlanStep   `json:"steps"`
+	Runtime        string             `json:"runtime,omitempty"`
+	RuntimeInstall string             `json:"runtime_install"`
+	Port           int                `json:"port,omitempty"`
+	PortSource     string             `json:"port_source,omitempty"`
+	EnvKeys        []string           `json:"env_keys"`
+	Migration      string             `json:"migration,omitempty"` // Command run before the switch
+	Domain         *deployPlanDomain  `json:"domain,omitempty"`
+	Warnings       []string           `json:"warnings,omitempty"`
+	detection      detector.Detection `json:"-"`
+}
+
+type deployPlanDetect struct {
+	Framework  string  `json:"framework"`
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+	Serving    string  `json:"serving,omitempty"`
+}
+
+type deployPlanStep struct {
+	Name   string `json:"name"`
+	Run    bool   `json:"run"`
+	Reason string `json:"reason"`
+}
+
+type deployPlanDomain struct {
+	Domain  string   `json:"domain"`
+	SSL     bool     `json:"ssl"`
+	Pending []string `json:"pending"`
+}
+
+// dialPlanServer connects to the target's server for the read-only probes; replaced in tests
+var dialPlanServer = func(target config.TargetConfig) (*sshpkg.Executor, error) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return nil, err
+	}
+	if providerCfg.GetIP() == "" {
+		return nil, fmt.Errorf("no server IP")
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := sshExecutor.Connect(1, time.Second); err != nil {
+		return nil, err
+	}
+	return sshExecutor, nil
+}
+
+// buildDeployPlan computes the deploy plan without changing config, state or the server.
+// exists reports whether the target is already in the config.
+func buildDeployPlan(target config.TargetConfig, targetName, projectPath string, exists, force bool) (*deployPlan, error) {
+	detection := detector.DetectAppAs(projectPath, target.AppSubdir(), target.FrameworkOverride)
+	builderName, builderReason := resolveBuilderWithReason(target, projectPath, &detection, deployBuilderFlag)
+
+	plan := &deployPlan{
+		Target:      targetName,
+		ProjectPath: projectPath,
+		Detection: deployPlanDetect{
+			Framework:  detection.Framework,
+			Language:   detection.Language,
+			Confidence: detection.Confidence,
+			Serving:    staticServingSummary(&detection),
+		},
+		Builder:       builderName,
+		BuilderReason: builderReason,
+		EnvKeys:       []string{},
+		detection:     detection,
+	}
+
+	if !exists || force {
+		plan.Steps = append(plan.Steps, deployPlanStep{Name: "detect", Run: true, Reason: "framework detection"})
+	} else {
+		plan.Steps = append(plan.Steps, deployPlanStep{Name: "detect", Run: false, Reason: "cached"})
+	}
+
+	created := exists && state.IsCreated(targetName)
+	switch {
+	case deployServerIP != "":
+		plan.Steps = append(plan.Steps, deployPlanStep{Name: "create", Run: false, Reason: fmt.Sprintf("using existing server %s", deployServerIP)})
+		created = true
+	case created:
+		plan.Steps = append(plan.Steps, deployPlanStep{Name: "create", Run: false, Reason: "already created"})
+	default:
+		plan.Steps = append(plan.Steps, deployPlanStep{Name: "create", Run: true, Reason: "infrastructure provisioning"})
+	}
+
+	if rt := runtime.GetRuntimeForDetection(detection.Language, detection.Framework); rt != runtime.RuntimeUnknown {
+		plan.Runtime = string(rt)
+	}
+
+	var sshExecutor *sshpkg.Executor
+	if created && target.RequiresSSHDeployment() {
+		if executor, err := dialPlanServer(target); err == nil {
+			sshExecutor = executor
+			defer sshExecutor.Disconnect()
+		}
+	}
+
+	planBuilderVersion(plan, target, sshExecutor)
+	if builderName == "dockerfile" && target.ContainerPort == 0 && containerPortFlag == 0 {
+		if ports, err := dockerfile.ReadPorts(projectPath); err == nil {
+			if _, err := dockerfile.ContainerPort(ports, 0, nil); err != nil {
+				plan.Warnings = append(plan.Warnings, err.Error())
+			}
+		}
+	}
+	plan.Steps = append(plan.Steps, planConfigureStep(target, targetName, created, force, sshExecutor, plan))
+	plan.Steps = append(plan.Steps, deployPlanStep{Name: "push", Run: true, Reason: "release deployment"})
+
+	if target.RequiresSSHDeployment() {
+		planTarget := target
+		if planTarget.ServerIP == "" {
+			if providerCfg, err := planTarget.GetSSHProviderConfig(); err == nil {
+				planTarget.ServerIP = providerCfg.GetIP()
+			}
+		}
+		if port, source, err := utils.PlanPort(&planTarget, targetName); err == nil {
+			plan.Port, plan.PortSource = port, source
+		} else {
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+	}
+
+	if err := target.ProcessDeploymentOptions(envFile, envVars, skipBuild); err != nil {
+		return nil, err
+	}
+	buildConfig := target
+	buildConfig.Builder = builderName
+	if err := buildConfig.ProcessBuildOptions(deployBuildArgs, deployBuildTarget); err != nil {
+		return nil, err
+	}
+	executor := deploy.NewExecutor(nil, utils.RemoteAppName(&target, targetName), projectPath, &detection)
+	executor.SetBaseDir(target.RemoteBaseDir())
+	executor.SetMigrationOptions(target.Deploy, skipMigrations)
+	plan.Migration = executor.MigrationCommand()
+	for key := range executor.ReleaseEnvironment(target.Deploy.EnvVars) {
+		plan.EnvKeys = append(plan.EnvKeys, key)
+	}
+	sort.Strings(plan.EnvKeys)
+
+	plan.Domain = planDomain(target, targetName, sshExecutor)
+
+	return plan, nil
+}
+
+// planBuilderVersion fills in the version of the builder's tool a deploy would
+// build with, warning when the server lacks the pinned nixpacks version. The
+// server-side builders are only asked when the server is reachable.
+func planBuilderVersion(plan *deployPlan, target config.TargetConfig, sshExecutor *sshpkg.Executor) {
+	if plan.Builder == "nixpacks" {
+		plan.BuilderPin = target.NixpacksVersion
+	}
+	if sshExecutor == nil && plan.Builder != "dockerfile" {
+		return
+	}
+
+	builder, err := builders.GetBuilder(plan.Builder)
+	if err != nil {
+		return
+	}
+	version, err := builder.Version(context.Background(), &builders.BuildOptions{
+		ProjectPath:     plan.ProjectPath,
+		Detection:       &plan.detection,
+		SSHExecutor:     sshExecutor,
+		RequiredVersion: plan.BuilderPin,
+	})
+	if err != nil {
+		return
+	}
+	plan.BuilderVersion = version
+
+	if plan.BuilderPin != "" && version != plan.BuilderPin {
+		installed := "nixpacks is not installed"
+		if version != "" {
+			installed = "nixpacks " + version + " is installed"
+		}
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("nixpacks %s is required but %s on the server; deploy with --auto-install-builder to install it", plan.BuilderPin, installed))
+	}
+}
+
+// planConfigureStep mirrors configureTarget's skip check. It fills in the
+// plan's runtime install from the same probe.
+func planConfigureStep(target config.TargetConfig, targetName string, created, force bool, sshExecutor *sshpkg.Executor, plan *deployPlan) deployPlanStep {
+	if created && !target.RequiresSSHDeployment() {
+		plan.RuntimeInstall = "not applicable (container provider)"
+		return deployPlanStep{Name: "configure", Run: false, Reason: "container provider"}
+	}
+
+	if !created {
+		plan.RuntimeInstall = "install during configure (new server)"
+		return deployPlanStep{Name: "configure", Run: true, Reason: "new server"}
+	}
+
+	if sshExecutor == nil {
+		plan.RuntimeInstall = runtimeUnknownUnreachable
+		if force || !state.IsConfigured(targetName) {
+			return deployPlanStep{Name: "configure", Run: true, Reason: "not configured (server unreachable)"}
+		}
+		return deployPlanStep{Name: "configure", Run: false, Reason: "configured (cached; server unreachable)"}
+	}
+
+	needsRuntime, err := installers.RuntimeNeedsInstall(&installers.Context{SSH: sshExecutor, Server: sshExecutor.Host, Detection: &plan.detection})
+	switch {
+	case err != nil:
+		plan.RuntimeInstall = fmt.Sprintf("unknown (%v)", err)
+	case needsRuntime:
+		plan.RuntimeInstall = "required"
+	case plan.Runtime == "":
+		plan.RuntimeInstall = "none"
+	default:
+		plan.RuntimeInstall = "already installed"
+	}
+
+	switch {
+	case force:
+		return deployPlanStep{Name: "configure", Run: true, Reason: "--force"}
+	case !serverConfigured(sshExecutor):
+		return deployPlanStep{Name: "configure", Run: true, Reason: "server not configured"}
+	case err != nil || needsRuntime:
+		return deployPlanStep{Name: "configure", Run: true, Reason: "runtime install needed for this app"}
+	default:
+		return deployPlanStep{Name: "configure", Run: false, Reason: "already configured"}
+	}
+}
+
+// planDomain lists the domain and SSL work still outstanding for the target
+func planDomain(target config.TargetConfig, targetName string, sshExecutor *sshpkg.Executor) *deployPlanDomain {
+	if target.Domain == nil || target.Domain.Domain == "" {
+		return nil
+	}
+
+	domain := &deployPlanDomain{
+		Domain:  target.Domain.Domain,
+		SSL:     target.Domain.SSLEnabled,
+		Pending: []string{},
+	}
+	if target.Domain.PathPrefix != "" {
+		return domain
+	}
+
+	targetState, _ := state.LoadState(targetName)
+	if targetState == nil {
+		targetState = &state.TargetState{}
+	}
+
+	nginxPresent := true
+	if sshExecutor != nil {
+		nginxPresent = remoteServesDomain(sshExecutor, target.Domain.Domain)
+	}
+	if reason := domainDriftReason(target, targetState, nginxPresent); reason != "" {
+		domain.Pending = append(domain.Pending, fmt.Sprintf("reconfigure nginx: %s (run 'lightfold sync --target %s --fix')", reason, targetName))
+	}
+	if target.Domain.SSLEnabled && !targetState.SSLConfigured {
+		domain.Pending = append(domain.Pending, "issue SSL certificate")
+	}
+	return domain
+}
+
+// printDeployPlan writes the plan as JSON with --json, or as text
+func printDeployPlan(plan *deployPlan) {
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(plan)
+		return
+	}
+
+	fmt.Println("DRY RUN - Deployment plan:")
+	fmt.Printf("Target: %s\n", plan.Target)
+	fmt.Printf("Detected: %s (%s)\n", plan.Detection.Framework, plan.Detection.Language)
+	builder := plan.Builder
+	if plan.BuilderVersion != "" {
+		builder += " " + plan.BuilderVersion
+	}
+	fmt.Printf("Builder: %s (%s)\n", builder, plan.BuilderReason)
+	if plan.BuilderPin != "" {
+		fmt.Printf("Required nixpacks version: %s\n", plan.BuilderPin)
+	}
+	fmt.Printf("Steps:\n")
+	for i, step := range plan.Steps {
+		mark := style.Check()
+		if !step.Run {
+			mark = style.Skipped()
+		}
+		fmt.Printf("  %d. %s %s - %s\n", i+1, mark, step.Name, step.Reason)
+	}
+	if plan.Runtime != "" {
+		fmt.Printf("Runtime: %s - %s\n", plan.Runtime, plan.RuntimeInstall)
+	}
+	if plan.Port > 0 {
+		fmt.Printf("Port: %d (%s)\n", plan.Port, plan.PortSource)
+	}
+	if len(plan.EnvKeys) > 0 {
+		fmt.Printf("Environment: %d variables\n", len(plan.EnvKeys))
+		for _, key := range plan.EnvKeys {
+			fmt.Printf("  - %s\n", key)
+		}
+	}
+	if plan.Migration != "" {
+		fmt.Printf("Migrations: %s (before the switch)\n", plan.Migration)
+	}
+	if plan.Detection.Serving != "" {
+		fmt.Printf("Serving: %s\n", plan.Detection.Serving)
+	}
+	if plan.Domain != nil {
+		fmt.Printf("Domain: %s\n", plan.Domain.Domain)
+		for _, action := range plan.Domain.Pending {
+			fmt.Printf("  - %s\n", action)
+		}
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildDeployPlan(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	originalDial := dialPlanServer
+	dialPlanServer = func(config.TargetConfig) (*sshpkg.Executor, error) {
+		return nil, errors.New("connection refused")
+	}
+	t.Cleanup(func() { dialPlanServer = originalDial })
+
+	originalEnv := envVars
+	envVars = []string{"API_KEY=secret-value", "DEBUG=1"}
+	t.Cleanup(func() { envVars = originalEnv })
+
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{"name":"app","dependencies":{"express":"^4.0.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := config.TargetConfig{
+		ProjectPath: projectPath,
+		Provider:    "digitalocean",
+		Builder:     "native",
+		ServerIP:    "203.0.113.10",
+		Domain:      &config.DomainConfig{Domain: "app.example.com", SSLEnabled: true},
+	}
+	if err := target.SetProviderConfig("digitalocean", &config.DigitalOceanConfig{IP: "203.0.113.10", Username: "deploy", SSHKey: "/tmp/key", Provisioned: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.MarkCreated("app", "123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.RegisterApp("203.0.113.10", state.DeployedApp{TargetName: "app", AppName: "app", Port: 3005}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := state.GetServerState("203.0.113.10")
+
+	plan, err := buildDeployPlan(target, "app", projectPath, true, false)
+	if err != nil {
+		t.Fatalf("buildDeployPlan() error = %v", err)
+	}
+
+	if plan.Builder != "native" || plan.BuilderReason != "saved in target config" {
+		t.Errorf("builder = %q (%q), want native from target config", plan.Builder, plan.BuilderReason)
+	}
+	if plan.Steps[1].Run {
+		t.Errorf("create step should be skipped for a created target")
+	}
+	if !plan.Steps[2].Run {
+		t.Errorf("configure step should run when the server was never configured")
+	}
+	if plan.RuntimeInstall != runtimeUnknownUnreachable {
+		t.Errorf("RuntimeInstall = %q, want %q", plan.RuntimeInstall, runtimeUnknownUnreachable)
+	}
+	if plan.Port != 3005 || plan.PortSource != "registered" {
+		t.Errorf("port = %d (%s), want 3005 (registered)", plan.Port, plan.PortSource)
+	}
+	if strings.Join(plan.EnvKeys, ",") != "API_KEY,DEBUG" {
+		t.Errorf("EnvKeys = %v, want [API_KEY DEBUG]", plan.EnvKeys)
+	}
+	if plan.Domain == nil || len(plan.Domain.Pending) != 1 || plan.Domain.Pending[0] != "issue SSL certificate" {
+		t.Errorf("Domain = %+v, want pending SSL issuance", plan.Domain)
+	}
+
+	out, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "secret-value") {
+		t.Errorf("plan JSON leaks env values: %s", out)
+	}
+
+	after, _ := state.GetServerState("203.0.113.10")
+	if after.NextPort != before.NextPort {
+		t.Errorf("dry run changed NextPort from %d to %d", before.NextPort, after.NextPort)
+	}
+}
+
+func TestBuildDeployPlan_NewTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{"name":"app"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := buildDeployPlan(config.TargetConfig{}, "app", projectPath, false, false)
+	if err != nil {
+		t.Fatalf("buildDeployPlan() error = %v", err)
+	}
+
+	for _, step := range plan.Steps {
+		if !step.Run {
+			t.Errorf("step %s should run for a new target", step.Name)
+		}
+	}
+	if plan.PortSource != "detected" {
+		t.Errorf("PortSource = %q, want detected", plan.PortSource)
+	}
+	if plan.Domain != nil {
+		t.Errorf("Domain = %+v, want nil", plan.Domain)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultLockWait is how long --after-current and a bare --wait-for-lock wait
+// for a deploy already running on the server
+const defaultLockWait = 30 * time.Minute
+
+// lockWait returns how long a deploy queues behind a running one; 0 means it
+// does not wait and takes over as before
+func lockWait(waitForLock time.Duration, afterCurrent bool) time.Duration {
+	if waitForLock > 0 {
+		return waitForLock
+	}
+	if afterCurrent {
+		return defaultLockWait
+	}
+	return 0
+}
+
+// queueOnServers waits for deploys running on any of the target's servers
+// before anything is uploaded, so a cancelled wait leaves every server as it was
+func queueOnServers(serverTargets []config.TargetConfig, targetName, projectPath, commit string, timeout time.Duration) {
+	for _, serverTarget := range serverTargets {
+		providerCfg, err := serverTarget.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to connect to %s: %v\n", providerCfg.GetIP(), err)
+			exit(1)
+		}
+
+		executor := deploy.NewExecutor(sshExecutor, resolveAppName(&serverTarget, targetName, sshExecutor), projectPath, nil)
+		executor.SetBaseDir(serverTarget.RemoteBaseDir())
+		queueBehindRunningDeploy(executor, projectPath, commit, timeout)
+		sshExecutor.Disconnect()
+	}
+}
+
+// queueBehindRunningDeploy waits until no other deploy holds the server's
+// lease, showing who holds it and for how long. It exits when the wait is
+// interrupted, times out, or a newer commit was deployed in the meantime.
+func queueBehindRunningDeploy(executor *deploy.Executor, projectPath, commit string, timeout time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	live := isTerminal() && !jsonOutput
+	waited := false
+	lastHolder := ""
+	report := func(holder deploy.Lease) {
+		waited = true
+		message := deploy.WaitingMessage(holder, time.Now()) + "... (Ctrl-C to stop)"
+		if live {
+			fmt.Printf("\r\033[K%s %s", pushMutedStyle.Render(style.Waiting()), pushMutedStyle.Render(message))
+			return
+		}
+		if holder.Token != lastHolder {
+			lastHolder = holder.Token
+			fmt.Printf("%s %s\n", pushMutedStyle.Render(style.Waiting()), pushMutedStyle.Render(message))
+		}
+	}
+	isNewer := func(deployedCommit string) bool {
+		return commit != "" && deployedCommit != commit && gitIsAncestor(projectPath, commit, deployedCommit)
+	}
+
+	err := executor.WaitForLease(ctx, timeout, isNewer, report)
+	if live && waited {
+		fmt.Print("\r\033[K")
+	}
+
+	var superseded *deploy.SupersededError
+	switch {
+	case err == nil:
+		if waited {
+			fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Running deploy finished"))
+		}
+	case errors.Is(err, context.Canceled):
+		fmt.Println(pushMutedStyle.Render("Stopped waiting; nothing was changed on the server."))
+		exit(130)
+	case errors.As(err, &superseded):
+		fmt.Printf("%s %s\n", pushMutedStyle.Render(style.Skipped()), pushMutedStyle.Render(fmt.Sprintf("Not deploying: %v while this deploy waited", superseded)))
+		exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
+
+// gitIsAncestor reports whether ancestor is an ancestor of commit in the
+// project's repository. Unknown commits are not ancestors.
+func gitIsAncestor(projectPath, ancestor, commit string) bool {
+	return exec.Command("git", "-C", projectPath, "merge-base", "--is-ancestor", ancestor, commit).Run() == nil
+}
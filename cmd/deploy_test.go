@@ -570,7 +570,7 @@ func TestDeployFlow_Integration(t *testing.T) {
 		t.Fatalf("Failed to mark configured: %v", err)
 	}
 
-	if err := state.UpdateDeployment(targetName, "abc123", "20251006213525"); err != nil {
+	if err := state.UpdateDeployment(targetName, "abc123", "20251006213525", ""); err != nil {
 		t.Fatalf("Failed to update deployment: %v", err)
 	}
 
@@ -3,8 +3,12 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
 	"lightfold/pkg/firewall"
 	"lightfold/pkg/providers"
 	_ "lightfold/pkg/providers/aws"
@@ -24,12 +28,15 @@ import (
 )
 
 var (
-	destroyTargetFlag string
-
-	destroyWarningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true)
-	destroyDangerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	destroyMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245")) // Match bubbletea descStyle
-	destroySuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
+	destroyTargetFlag       string
+	destroyDeleteServerFlag bool
+	destroyKeepServerFlag   bool
+	destroyForceFlag        bool
+
+	destroyWarningStyle = style.Fg("208").Bold(true)
+	destroyDangerStyle  = style.Error
+	destroyMutedStyle   = style.Muted // Match bubbletea descStyle
+	destroySuccessStyle = style.Success
 )
 
 var destroyCmd = &cobra.Command{
@@ -42,315 +49,642 @@ and state for a deployment target.
 
 This command will:
   • Delete the provisioned VM from your cloud provider (if provisioned)
+  • Delete the SSH key uploaded to the provider when no other target uses it
+  • Keep adopted servers (created outside lightfold) unless --delete-server is given
+  • Remove the app's service, scheduled jobs, nginx site and certificate from servers that are kept
+    and still host other apps (or always, with --keep-server)
   • Remove the target configuration from ~/.lightfold/config.json
   • Remove the target state from ~/.lightfold/state/<target>.json
   • Preserve API tokens (shared across targets)
 
+A deletion plan is shown first. Afterwards a checklist reports what was removed,
+skipped or failed, and a JSON report is saved to ~/.lightfold/logs. A failed step
+does not stop the rest of the teardown; if the VM or remote cleanup fails, the
+target config is kept so running destroy again finishes the job.
+
 For safety, you must type the exact target name to confirm destruction.
 
 Examples:
-  lightfold destroy --target myapp-prod`,
+  lightfold destroy --target myapp-prod
+  lightfold destroy --target myapp-prod --delete-server
+  lightfold destroy --target myapp-prod --keep-server`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		if destroyTargetFlag == "" {
 			fmt.Fprintf(os.Stderr, "Error: --target flag is required\n")
-			os.Exit(1)
+			exit(1)
+		}
+		if destroyKeepServerFlag && destroyDeleteServerFlag {
+			fmt.Fprintf(os.Stderr, "Error: --keep-server and --delete-server cannot be used together\n")
+			exit(1)
 		}
 
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		target, exists := cfg.GetTarget(destroyTargetFlag)
 		if !exists {
 			fmt.Println(destroyMutedStyle.Render(fmt.Sprintf("Target '%s' not found in configuration.", destroyTargetFlag)))
 
-			targetState, _ := state.LoadState(destroyTargetFlag)
-			if targetState != nil && (targetState.Created || targetState.Configured) {
-				fmt.Println(destroyMutedStyle.Render("However, state file exists. Cleaning up state..."))
-				if err := state.DeleteState(destroyTargetFlag); err != nil {
-					fmt.Fprintf(os.Stderr, "Error deleting state: %v\n", err)
-					os.Exit(1)
-				}
-				fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render(fmt.Sprintf("Deleted state for target '%s'", destroyTargetFlag)))
+			steps := orphanedDestroyPlan(cfg, destroyTargetFlag)
+			if len(steps) == 0 {
+				return
 			}
+			fmt.Println(destroyMutedStyle.Render("Cleaning up what is left of a previous teardown..."))
+			finishDestroy(runDestroyPlan(destroyTargetFlag, steps, destroyForceFlag))
 			return
 		}
 
-		provisionedID := state.GetProvisionedID(destroyTargetFlag)
-		providerCfg, _ := target.GetAnyProviderConfig()
+		plan := newTargetDestroyPlan(cfg, target, destroyTargetFlag)
+		defer plan.close()
+		steps := plan.steps()
 
-		fmt.Printf("\n%s\n", destroyWarningStyle.Render("⚠️  WARNING: This will permanently destroy the following:"))
+		fmt.Printf("\n%s\n", destroyWarningStyle.Render(style.Warn()+"  WARNING: This will permanently destroy the following:"))
 		fmt.Println()
+		printDestroyPlan(steps)
+		fmt.Println()
+		fmt.Printf("%s\n\n", destroyDangerStyle.Render("This action cannot be undone!"))
 
-		if provisionedID != "" && providerCfg != nil {
-			fmt.Printf("  %s VM: %s", destroyDangerStyle.Render("•"), provisionedID)
-			if providerCfg.GetIP() != "" {
-				fmt.Printf(" (%s)", providerCfg.GetIP())
+		if target.Protected {
+			exitUnlessProtectedConfirmed(target, destroyTargetFlag)
+		} else {
+			targetBaseName := util.GetTargetName(destroyTargetFlag)
+			reader := bufio.NewReader(os.Stdin)
+			fmt.Printf("Type the target name '%s' to confirm: ", targetBaseName)
+			confirmation, err := reader.ReadString('\n')
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading confirmation: %v\n", err)
+				exit(1)
+			}
+
+			confirmation = strings.TrimSpace(confirmation)
+			if confirmation != targetBaseName {
+				fmt.Println("\nCancelled. Target name did not match.")
+				exit(0)
 			}
-			fmt.Printf(" [Provider: %s]\n", target.Provider)
-		} else if providerCfg != nil && providerCfg.GetIP() != "" {
-			fmt.Printf("  %s BYOS server (IP: %s) - local config only\n", destroyMutedStyle.Render("•"), providerCfg.GetIP())
 		}
 
 		fmt.Println()
-		fmt.Printf("%s\n\n", destroyDangerStyle.Render("This action cannot be undone!"))
+		finishDestroy(runDestroyPlan(destroyTargetFlag, steps, destroyForceFlag))
+	},
+}
 
-		targetBaseName := util.GetTargetName(destroyTargetFlag)
-		reader := bufio.NewReader(os.Stdin)
-		fmt.Printf("Type the target name '%s' to confirm: ", targetBaseName)
-		confirmation, err := reader.ReadString('\n')
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading confirmation: %v\n", err)
-			os.Exit(1)
-		}
+// finishDestroy prints the checklist, saves the JSON report and exits non-zero on failures
+func finishDestroy(report *destroyReport) {
+	fmt.Println()
+	printDestroyChecklist(report)
+	fmt.Println()
 
-		confirmation = strings.TrimSpace(confirmation)
-		if confirmation != targetBaseName {
-			fmt.Println("\nCancelled. Target name did not match.")
-			os.Exit(0)
-		}
+	reportPath, reportErr := writeDestroyReport(report)
+	removed, skipped, failed := report.counts()
 
-		fmt.Println()
+	if failed > 0 {
+		fmt.Printf("%s\n", destroyWarningStyle.Render(fmt.Sprintf("%s Target '%s' partially destroyed: %d removed, %d skipped, %d failed", style.Warn(), report.Target, removed, skipped, failed)))
+		fmt.Printf("%s\n", destroyMutedStyle.Render(fmt.Sprintf("Fix the failures above and re-run: lightfold destroy --target %s", report.Target)))
+	} else {
+		successBox := style.Box(style.ColorError).
+			Padding(0, 1).
+			Render(
+				lipgloss.JoinVertical(
+					lipgloss.Left,
+					destroyDangerStyle.Render(fmt.Sprintf("%s Target '%s' destroyed successfully", style.Check(), report.Target)),
+					"",
+					destroyMutedStyle.Render(fmt.Sprintf("%d removed, %d skipped", removed, skipped)),
+				),
+			)
+		fmt.Println(successBox)
+	}
 
-		// Only destroy if: (1) VM was provisioned by this target AND (2) no other apps on the server
-		shouldDestroyVM := false
-		var otherApps []state.DeployedApp
+	if reportErr != nil {
+		fmt.Printf("%s %s\n", destroyWarningStyle.Render(style.Warn()), destroyMutedStyle.Render(fmt.Sprintf("Could not save destroy report: %v", reportErr)))
+	} else {
+		fmt.Printf("%s\n", destroyMutedStyle.Render("Report: "+reportPath))
+	}
 
-		if provisionedID != "" && target.Provider != "" && target.Provider != "byos" {
-			targetProvisionedVM := false
-			if providerCfg != nil {
-				targetProvisionedVM = providerCfg.IsProvisioned()
-			}
+	if failed > 0 {
+		exit(1)
+	}
+}
 
-			if target.ServerIP != "" {
-				serverState, err := state.GetServerState(target.ServerIP)
-				if err == nil {
-					for _, app := range serverState.DeployedApps {
-						if app.TargetName != destroyTargetFlag {
-							otherApps = append(otherApps, app)
-						}
-					}
-				}
-			}
+// targetDestroyPlan decides what destroying a configured target removes. Provider
+// and SSH connections are opened lazily, once, when a step first needs them.
+type targetDestroyPlan struct {
+	cfg        *config.Config
+	target     config.TargetConfig
+	targetName string
+
+	providerCfg   config.ProviderConfig
+	provisionedID string
+	destroyVM     bool
+	otherApps     []state.DeployedApp
+
+	provider    providers.Provider
+	providerErr error
+	providerSet bool
+
+	ssh      *sshpkg.Executor
+	sshErr   error
+	sshTried bool
+}
 
-			if !targetProvisionedVM {
-				shouldDestroyVM = false
-			} else if len(otherApps) > 0 {
-				shouldDestroyVM = false
-				fmt.Printf("%s %s\n", destroyWarningStyle.Render("⚠"), destroyWarningStyle.Render("VM will NOT be destroyed - other apps are deployed to this server:"))
-				for _, app := range otherApps {
-					fmt.Printf("  %s %s (port %d)\n", destroyMutedStyle.Render("•"), app.AppName, app.Port)
-				}
-				fmt.Println()
-				fmt.Printf("%s %s\n", destroyMutedStyle.Render("ℹ"), destroyMutedStyle.Render("Only removing this app's configuration and local state"))
-				fmt.Println()
-			} else {
-				shouldDestroyVM = true
+func newTargetDestroyPlan(cfg *config.Config, target config.TargetConfig, targetName string) *targetDestroyPlan {
+	p := &targetDestroyPlan{
+		cfg:           cfg,
+		target:        target,
+		targetName:    targetName,
+		provisionedID: state.GetProvisionedID(targetName),
+	}
+	p.providerCfg, _ = target.GetAnyProviderConfig()
+	p.decideVM()
+	return p
+}
+
+// decideVM applies the VM rules: only destroy a VM this target provisioned (or an
+// adopted one with --delete-server), and never while other apps still run on it
+func (p *targetDestroyPlan) decideVM() {
+	adopted := p.providerCfg != nil && p.providerCfg.IsAdopted()
+
+	var serverState *state.ServerState
+	if p.target.ServerIP != "" {
+		if s, err := state.GetServerState(p.target.ServerIP); err == nil {
+			serverState = s
+			p.otherApps = otherDeployedApps(s, p.targetName)
+		}
+	}
+
+	if destroyKeepServerFlag || len(p.otherApps) > 0 {
+		return
+	}
+
+	if p.provisionedID != "" && p.target.Provider != "" && p.target.Provider != "byos" && p.providerCfg != nil {
+		if p.providerCfg.IsProvisioned() || (adopted && destroyDeleteServerFlag) {
+			p.destroyVM = true
+			return
+		}
+	}
+
+	// If the current target didn't provision the VM but is the last app on it,
+	// still destroy the VM to avoid orphaned provider resources
+	if serverState != nil {
+		keepAdopted := (serverState.Adopted || adopted) && !destroyDeleteServerFlag
+		if !keepAdopted && serverState.ServerID != "" && serverState.Provider != "" && serverState.Provider != "byos" {
+			p.destroyVM = true
+			p.provisionedID = serverState.ServerID
+			if p.target.Provider == "" || p.target.Provider == "byos" {
+				p.target.Provider = serverState.Provider
 			}
 		}
+	}
+}
 
-		// Fallback check: If current target didn't provision the VM but is the last app on the server,
-		// we should still destroy the VM to avoid orphaned AWS resources
-		if !shouldDestroyVM && target.ServerIP != "" {
-			serverState, err := state.GetServerState(target.ServerIP)
-			if err == nil {
-				// Count remaining apps (excluding the current one being destroyed)
-				remainingApps := 0
-				for _, app := range serverState.DeployedApps {
-					if app.TargetName != destroyTargetFlag {
-						remainingApps++
-					}
-				}
+func (p *targetDestroyPlan) steps() []*destroyStep {
+	var steps []*destroyStep
+	ip := ""
+	if p.providerCfg != nil {
+		ip = p.providerCfg.GetIP()
+	}
+
+	steps = append(steps, p.vmStep(ip))
+	if step := p.staticIPStep(); step != nil {
+		steps = append(steps, step)
+	}
+
+	if len(p.target.Servers) > 0 {
+		steps = append(steps, skippedStep("extra_servers", fmt.Sprintf("%d extra server(s)", len(p.target.Servers)),
+			"not destroyed; delete them from the provider console"))
+	}
+	if p.target.LoadBalancer != nil {
+		steps = append(steps, skippedStep("load_balancer", fmt.Sprintf("Load balancer %s", p.target.LoadBalancer.Name),
+			fmt.Sprintf("not destroyed; run 'lightfold server load-balancer --target %s --delete' first", p.targetName)))
+	}
+
+	if !p.destroyVM && ip != "" && p.target.Provider != "flyio" {
+		steps = append(steps, p.remoteAppSteps(ip)...)
+	}
+
+	if p.target.Domain != nil && p.target.Domain.Domain != "" && p.target.Domain.PathPrefix == "" {
+		steps = append(steps, skippedStep("dns", fmt.Sprintf("DNS record for %s", p.target.Domain.Domain),
+			"lightfold does not manage DNS; remove or repoint it at your DNS provider"))
+	}
+
+	steps = append(steps, p.providerKeyStep())
+
+	if p.target.ServerIP != "" {
+		if _, err := state.GetAppFromServer(p.target.ServerIP, p.targetName); err == nil {
+			serverIP := p.target.ServerIP
+			step := plannedStep("server_state", fmt.Sprintf("App registration on server %s", serverIP), func() (string, error) {
+				return "", state.UnregisterApp(serverIP, p.targetName)
+			})
+			step.localRecord = true
+			steps = append(steps, step)
+		}
+	}
 
-				// If this is the last app and the server was provisioned (not BYOS)
-				if remainingApps == 0 && serverState.ServerID != "" && serverState.Provider != "" && serverState.Provider != "byos" {
-					shouldDestroyVM = true
-					provisionedID = serverState.ServerID
+	stateStep := plannedStep("state", "Local state file", func() (string, error) {
+		return "", state.DeleteState(p.targetName)
+	})
+	stateStep.localRecord = true
 
-					// Override target.Provider for destroy call if not set
-					if target.Provider == "" || target.Provider == "byos" {
-						target.Provider = serverState.Provider
-					}
+	configStep := plannedStep("config", fmt.Sprintf("Target '%s' in config", p.targetName), func() (string, error) {
+		return "", p.cfg.DeleteTarget(p.targetName)
+	})
+	configStep.localRecord = true
 
-					fmt.Printf("%s %s\n", destroyWarningStyle.Render("ℹ"), destroyMutedStyle.Render("Last app on server - will destroy provisioned VM"))
-				}
-			}
+	steps = append(steps, stateStep, configStep, localKeysStep(p.cfg))
+	return steps
+}
+
+func (p *targetDestroyPlan) vmStep(ip string) *destroyStep {
+	description := "Server"
+	if p.provisionedID != "" {
+		description = fmt.Sprintf("VM %s", p.provisionedID)
+	}
+	if ip != "" {
+		description += fmt.Sprintf(" (%s)", ip)
+	}
+
+	switch {
+	case p.destroyVM:
+		description += fmt.Sprintf(" [Provider: %s]", p.target.Provider)
+	case p.providerCfg == nil || ip == "" && p.provisionedID == "":
+		return skippedStep("vm", description, "no server recorded")
+	case destroyKeepServerFlag:
+		return skippedStep("vm", description, "kept (--keep-server)")
+	case len(p.otherApps) > 0:
+		names := make([]string, 0, len(p.otherApps))
+		for _, app := range p.otherApps {
+			names = append(names, app.AppName)
+		}
+		return skippedStep("vm", description, "kept, other apps deployed: "+strings.Join(names, ", "))
+	case p.providerCfg.IsAdopted():
+		return skippedStep("vm", description, "adopted server kept, use --delete-server to delete it")
+	default:
+		return skippedStep("vm", description, "BYOS server kept, local config only")
+	}
+
+	step := plannedStep("vm", description, func() (string, error) {
+		provider, err := p.getProvider()
+		if err != nil {
+			return "", err
 		}
 
-		if shouldDestroyVM {
-			fmt.Printf("%s %s\n", destroyWarningStyle.Render("→"), destroyMutedStyle.Render("Destroying VM..."))
+		ctx, cancel := context.WithTimeout(context.Background(), config.DefaultDestroyTimeout)
+		defer cancel()
 
-			tokens, err := config.LoadTokens()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error loading tokens: %v\n", err)
-				os.Exit(1)
+		if err := provider.Destroy(ctx, p.provisionedID); err != nil {
+			if isProviderNotFound(err) {
+				return "already deleted", nil
 			}
+			return "", fmt.Errorf("failed to destroy VM: %w", err)
+		}
+		return "", nil
+	})
+	step.retryable = true
+	return step
+}
 
-			token := tokens.GetToken(target.Provider)
-			if token == "" {
-				fmt.Fprintf(os.Stderr, "\n%s %s\n",
-					destroyDangerStyle.Render("✗"), "No API token found for provider '"+target.Provider+"'")
-				fmt.Fprintln(os.Stderr, "\nCannot destroy VM without API token. Aborting to prevent orphaned resources.")
-				fmt.Fprintln(os.Stderr, "Local config and state preserved. Re-run after adding token with:")
-				fmt.Fprintf(os.Stderr, "  lightfold config set-token %s\n\n", target.Provider)
-				os.Exit(1)
-			}
+// staticIPStep releases an AWS Elastic IP allocated at provisioning. Deleting the
+// instance only disassociates it, and an unattached address is still billed.
+func (p *targetDestroyPlan) staticIPStep() *destroyStep {
+	if p.target.Provider != "aws" {
+		return nil
+	}
+	awsConfig, err := p.target.GetAWSConfig()
+	if err != nil || !strings.HasPrefix(awsConfig.ElasticIP, "eipalloc-") {
+		return nil
+	}
+
+	allocationID := awsConfig.ElasticIP
+	description := fmt.Sprintf("Elastic IP %s", allocationID)
+	if !p.destroyVM {
+		return skippedStep("static_ip", description, "kept with the server")
+	}
+
+	step := plannedStep("static_ip", description, func() (string, error) {
+		provider, err := p.getProvider()
+		if err != nil {
+			return "", err
+		}
+		releaser, ok := provider.(providers.StaticIPReleaser)
+		if !ok {
+			return fmt.Sprintf("not supported by %s; release it from the provider console", provider.DisplayName()), nil
+		}
 
-			provider, err := providers.GetProvider(target.Provider, token)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "\n%s %s\n",
-					destroyDangerStyle.Render("✗"), fmt.Sprintf("Error getting provider '%s': %v", target.Provider, err))
-				fmt.Fprintln(os.Stderr, "\nCannot destroy VM due to provider error. Aborting to prevent orphaned resources.")
-				fmt.Fprintln(os.Stderr, "Local config and state preserved.")
-				fmt.Fprintln(os.Stderr)
-				os.Exit(1)
-			}
+		ctx, cancel := context.WithTimeout(context.Background(), config.DefaultDestroyTimeout)
+		defer cancel()
 
-			ctx, cancel := context.WithTimeout(context.Background(), config.DefaultDestroyTimeout)
-			defer cancel()
-
-			if err := provider.Destroy(ctx, provisionedID); err != nil {
-				providerErr, ok := err.(*providers.ProviderError)
-				isNotFound := false
-
-				if ok {
-					isNotFound = providerErr.Code == "not_found" ||
-						providerErr.Code == "server_not_found" ||
-						providerErr.Code == "machine_not_found" ||
-						providerErr.Code == "droplet_not_found"
-
-					if !isNotFound {
-						errMsg := strings.ToLower(providerErr.Message)
-						isNotFound = strings.Contains(errMsg, "not found") ||
-							strings.Contains(errMsg, "404") ||
-							strings.Contains(errMsg, "does not exist")
-					}
-				}
+		return "", releaser.ReleaseStaticIP(ctx, allocationID)
+	})
+	step.retryable = true
+	return step
+}
 
-				if isNotFound {
-					fmt.Printf("%s %s\n",
-						destroyWarningStyle.Render("⚠"), destroyMutedStyle.Render("VM not found (may have been deleted manually) - proceeding with local cleanup"))
-				} else {
-					fmt.Fprintf(os.Stderr, "\n%s %s\n",
-						destroyDangerStyle.Render("✗"), fmt.Sprintf("Failed to destroy VM: %v", err))
-					fmt.Fprintln(os.Stderr, "\nVM destruction failed. Aborting to prevent inconsistent state.")
-					fmt.Fprintln(os.Stderr, "Local config and state preserved. Please investigate the error and retry.")
-					fmt.Fprintln(os.Stderr)
-					os.Exit(1)
-				}
-			} else {
-				fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render("VM destroyed successfully"))
-			}
+// remoteAppSteps removes this app from a server that is being kept
+func (p *targetDestroyPlan) remoteAppSteps(ip string) []*destroyStep {
+	shared := len(p.otherApps) > 0
+	if !shared && !destroyKeepServerFlag {
+		return []*destroyStep{skippedStep("remote_app", fmt.Sprintf("App files and service on %s", ip),
+			"left in place; use --keep-server to remove them")}
+	}
+
+	appName := utils.RemoteAppName(&p.target, p.targetName)
+	var steps []*destroyStep
+
+	service := plannedStep("service", fmt.Sprintf("Service %s on %s", appName, ip), func() (string, error) {
+		exec, err := p.connect()
+		if err != nil {
+			return "", err
 		}
+		result := exec.ExecuteSudo(fmt.Sprintf("systemctl disable --now %s 2>/dev/null; rm -f /etc/systemd/system/%s.service && systemctl daemon-reload", appName, appName))
+		if result.Error != nil || result.ExitCode != 0 {
+			return "", fmt.Errorf("failed to remove service: %s", commandError(result))
+		}
+		return "", nil
+	})
+	service.retryable = true
 
-		if target.ServerIP != "" {
-			if err := state.UnregisterApp(target.ServerIP, destroyTargetFlag); err != nil {
-				fmt.Printf("%s %s\n", destroyWarningStyle.Render("⚠"), destroyMutedStyle.Render(fmt.Sprintf("Failed to unregister app from server: %v", err)))
-			} else {
-				fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render("Unregistered app from server state"))
-
-				serverState, err := state.GetServerState(target.ServerIP)
-				if err == nil {
-					if len(serverState.DeployedApps) > 0 {
-						fmt.Printf("%s %s\n", destroyMutedStyle.Render("ℹ"), destroyMutedStyle.Render(fmt.Sprintf("Server still hosts %d other app(s)", len(serverState.DeployedApps))))
-					}
+	nginx := plannedStep("nginx", fmt.Sprintf("Nginx site %s on %s", appName, ip), func() (string, error) {
+		exec, err := p.connect()
+		if err != nil {
+			return "", err
+		}
+		result := exec.ExecuteSudo(fmt.Sprintf(
+			"rm -f /etc/nginx/sites-enabled/%[1]s /etc/nginx/sites-enabled/%[1]s.conf /etc/nginx/sites-available/%[1]s /etc/nginx/sites-available/%[1]s.conf && (! command -v nginx >/dev/null || (nginx -t && systemctl reload nginx))",
+			appName))
+		if result.Error != nil || result.ExitCode != 0 {
+			return "", fmt.Errorf("failed to remove nginx site: %s", commandError(result))
+		}
+		return "", nil
+	})
+	nginx.retryable = true
+	steps = append(steps, service, nginx)
+
+	if p.target.Deploy != nil && len(p.target.Deploy.Jobs) > 0 {
+		jobs := plannedStep("jobs", fmt.Sprintf("Scheduled jobs of %s on %s", appName, ip), func() (string, error) {
+			exec, err := p.connect()
+			if err != nil {
+				return "", err
+			}
+			result := exec.ExecuteSudo(deploy.RemoveJobsCommand(appName))
+			if result.Error != nil || result.ExitCode != 0 {
+				return "", fmt.Errorf("failed to remove job timers: %s", commandError(result))
+			}
+			return "", nil
+		})
+		jobs.retryable = true
+		steps = append(steps, jobs)
+	}
+
+	if domain := p.target.Domain; domain != nil && domain.Domain != "" && domain.SSLEnabled && domain.PathPrefix == "" {
+		if domain.SSLManager == "" || domain.SSLManager == "certbot" {
+			cert := plannedStep("certificate", fmt.Sprintf("Certificate for %s on %s", domain.Domain, ip), func() (string, error) {
+				exec, err := p.connect()
+				if err != nil {
+					return "", err
 				}
-
-				// Clean up firewall port if not destroying VM (multi-app scenario with no domain)
-				if !shouldDestroyVM && len(otherApps) > 0 && target.Port > 0 {
-					// Only close firewall port if app had no domain configured
-					if target.Domain == nil || target.Domain.Domain == "" {
-						providerCfg, err := target.GetAnyProviderConfig()
-						if err == nil && providerCfg.GetIP() != "" {
-							fmt.Printf("%s %s\n", destroyMutedStyle.Render("→"), destroyMutedStyle.Render(fmt.Sprintf("Closing firewall port %d...", target.Port)))
-
-							sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
-							if err := sshExecutor.Connect(3, 10*time.Second); err == nil {
-								defer sshExecutor.Disconnect()
-
-								firewallMgr := firewall.GetDefault(sshExecutor)
-								if err := firewallMgr.ClosePort(target.Port); err != nil {
-									fmt.Printf("%s %s\n", destroyWarningStyle.Render("⚠"), destroyMutedStyle.Render(fmt.Sprintf("Failed to close firewall port: %v", err)))
-								} else {
-									fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render(fmt.Sprintf("Closed firewall port %d", target.Port)))
-								}
-							} else {
-								fmt.Printf("%s %s\n", destroyMutedStyle.Render("ℹ"), destroyMutedStyle.Render("Skipping firewall cleanup (SSH connection failed)"))
-							}
-						}
-					}
+				result := exec.ExecuteSudo(fmt.Sprintf("certbot delete --cert-name %s --non-interactive", domain.Domain))
+				output := result.Stdout + result.Stderr
+				if strings.Contains(output, "No certificate found") {
+					return "already removed", nil
 				}
-
-				// Clean up unused runtimes if keeping the VM (only makes sense for multi-app servers)
-				if !shouldDestroyVM && len(otherApps) > 0 {
-					providerCfg, err := target.GetAnyProviderConfig()
-					if err == nil && providerCfg.GetIP() != "" {
-						fmt.Printf("%s %s\n", destroyMutedStyle.Render("→"), destroyMutedStyle.Render("Analyzing runtime dependencies..."))
-
-						sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
-						if err := sshExecutor.Connect(3, 10*time.Second); err == nil {
-							defer sshExecutor.Disconnect()
-
-							if err := runtime.CleanupUnusedRuntimes(sshExecutor, target.ServerIP, destroyTargetFlag); err != nil {
-								fmt.Printf("%s %s\n", destroyWarningStyle.Render("⚠"), destroyMutedStyle.Render(fmt.Sprintf("Runtime cleanup warning: %v", err)))
-								fmt.Printf("%s %s\n", destroyMutedStyle.Render("  "), destroyMutedStyle.Render("You may manually run: apt-get autoremove -y"))
-							} else {
-								fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render("Cleaned up unused runtimes"))
-							}
-						} else {
-							fmt.Printf("%s %s\n", destroyMutedStyle.Render("ℹ"), destroyMutedStyle.Render("Skipping runtime cleanup (SSH connection failed)"))
-						}
-					}
+				if result.Error != nil || result.ExitCode != 0 {
+					return "", fmt.Errorf("failed to delete certificate: %s", commandError(result))
 				}
+				return "", nil
+			})
+			cert.retryable = true
+			steps = append(steps, cert)
+		} else {
+			steps = append(steps, skippedStep("certificate", fmt.Sprintf("Certificate for %s", domain.Domain),
+				fmt.Sprintf("managed by %s", domain.SSLManager)))
+		}
+	}
+
+	// Apps without a domain are reached on their own port
+	if p.target.Port > 0 && (p.target.Domain == nil || p.target.Domain.Domain == "") {
+		port := p.target.Port
+		firewallStep := plannedStep("firewall", fmt.Sprintf("Firewall port %d on %s", port, ip), func() (string, error) {
+			exec, err := p.connect()
+			if err != nil {
+				return "", err
+			}
+			return "", firewall.GetDefault(exec).ClosePort(port)
+		})
+		firewallStep.retryable = true
+		steps = append(steps, firewallStep)
+	}
+
+	// A freed port can be allocated to the next app on the server
+	if p.target.Port > 0 {
+		port := p.target.Port
+		reservation := plannedStep("port_reservation", fmt.Sprintf("Port %d reservation on %s", port, ip), func() (string, error) {
+			exec, err := p.connect()
+			if err != nil {
+				return "", err
+			}
+			return "", deploy.ReleasePortReservation(exec, port, appName)
+		})
+		reservation.retryable = true
+		steps = append(steps, reservation)
+	}
+
+	if shared {
+		steps = append(steps, plannedStep("runtimes", fmt.Sprintf("Runtimes no other app on %s needs", ip), func() (string, error) {
+			exec, err := p.connect()
+			if err != nil {
+				return "", err
+			}
+			if err := runtime.CleanupUnusedRuntimes(exec, p.target.ServerIP, p.targetName); err != nil {
+				return "", fmt.Errorf("%w (you may run: apt-get autoremove -y)", err)
 			}
+			return "", nil
+		}))
+	}
+
+	return steps
+}
+
+// providerKeyStep deletes the SSH key uploaded at provisioning, unless another target shares it
+func (p *targetDestroyPlan) providerKeyStep() *destroyStep {
+	keyName := providerSSHKeyName(p.target)
+	description := fmt.Sprintf("SSH key %s on %s", keyName, p.target.Provider)
+
+	if !p.destroyVM || keyName == "" {
+		return skippedStep("provider_ssh_key", "Provider SSH key", "no VM is deleted")
+	}
+	for name, other := range p.cfg.Targets {
+		if name != p.targetName && other.Provider == p.target.Provider && providerSSHKeyName(other) == keyName {
+			return skippedStep("provider_ssh_key", description, fmt.Sprintf("still used by target '%s'", name))
 		}
+	}
 
-		if err := state.DeleteState(destroyTargetFlag); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deleting state: %v\n", err)
-			os.Exit(1)
+	return plannedStep("provider_ssh_key", description, func() (string, error) {
+		provider, err := p.getProvider()
+		if err != nil {
+			return "", err
+		}
+		deleter, ok := provider.(providers.SSHKeyDeleter)
+		if !ok {
+			return fmt.Sprintf("not supported by %s; remove it from the provider console", provider.DisplayName()), nil
 		}
-		fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render("Deleted state file"))
 
-		if err := cfg.DeleteTarget(destroyTargetFlag); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deleting target config: %v\n", err)
-			os.Exit(1)
+		ctx, cancel := context.WithTimeout(context.Background(), config.DefaultDestroyTimeout)
+		defer cancel()
+
+		deleted, err := deleter.DeleteSSHKey(ctx, keyName)
+		if err != nil {
+			return "", err
 		}
-		fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render("Removed target from config"))
+		if !deleted {
+			return "already deleted", nil
+		}
+		return "", nil
+	})
+}
 
-		keysDeleted, err := sshpkg.CleanupUnusedKeys(cfg.Targets)
+func localKeysStep(cfg *config.Config) *destroyStep {
+	return plannedStep("local_keys", "Unused local SSH keypairs", func() (string, error) {
+		deleted, err := sshpkg.CleanupUnusedKeys(cfg.Targets)
 		if err != nil {
-			fmt.Printf("%s %s\n", destroyWarningStyle.Render("⚠"), destroyMutedStyle.Render(fmt.Sprintf("SSH key cleanup warning: %v", err)))
-		} else if keysDeleted > 0 {
-			fmt.Printf("%s %s\n", destroySuccessStyle.Render("✓"), destroyMutedStyle.Render(fmt.Sprintf("Cleaned up %d unused SSH key(s)", keysDeleted)))
-		} else {
-			fmt.Printf("%s %s\n", destroyMutedStyle.Render("ℹ"), destroyMutedStyle.Render("No unused SSH keys found"))
+			return "", err
+		}
+		if deleted == 0 {
+			return "none unused", nil
 		}
+		return fmt.Sprintf("%d deleted", deleted), nil
+	})
+}
 
-		fmt.Println()
+// orphanedDestroyPlan finishes a teardown whose target config is already gone
+func orphanedDestroyPlan(cfg *config.Config, targetName string) []*destroyStep {
+	var steps []*destroyStep
 
-		successBox := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("196")).
-			Padding(0, 1).
-			Render(
-				lipgloss.JoinVertical(
-					lipgloss.Left,
-					destroyDangerStyle.Render(fmt.Sprintf("✓ Target '%s' destroyed successfully", destroyTargetFlag)),
-					"",
-					destroyMutedStyle.Render("All resources and configuration removed"),
-				),
-			)
+	servers, _ := state.ListAllServers()
+	for _, serverIP := range servers {
+		if _, err := state.GetAppFromServer(serverIP, targetName); err != nil {
+			continue
+		}
+		ip := serverIP
+		steps = append(steps, plannedStep("server_state", fmt.Sprintf("App registration on server %s", ip), func() (string, error) {
+			return "", state.UnregisterApp(ip, targetName)
+		}))
+	}
+
+	if targetState, _ := state.LoadState(targetName); targetState != nil && (targetState.Created || targetState.Configured) {
+		steps = append(steps, plannedStep("state", "Local state file", func() (string, error) {
+			return "", state.DeleteState(targetName)
+		}))
+	}
+
+	if len(steps) == 0 {
+		return nil
+	}
+	return append(steps, localKeysStep(cfg))
+}
 
-		fmt.Println(successBox)
-	},
+func (p *targetDestroyPlan) getProvider() (providers.Provider, error) {
+	if p.providerSet {
+		return p.provider, p.providerErr
+	}
+	p.providerSet = true
+
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		p.providerErr = fmt.Errorf("failed to load tokens: %w", err)
+		return nil, p.providerErr
+	}
+	token := tokens.GetToken(p.target.Provider)
+	if token == "" {
+		p.providerErr = fmt.Errorf("no API token for provider '%s'; add it with 'lightfold config set-token %s'", p.target.Provider, p.target.Provider)
+		return nil, p.providerErr
+	}
+
+	p.provider, p.providerErr = providers.GetProvider(p.target.Provider, token)
+	return p.provider, p.providerErr
+}
+
+func (p *targetDestroyPlan) connect() (*sshpkg.Executor, error) {
+	if p.sshTried {
+		return p.ssh, p.sshErr
+	}
+	p.sshTried = true
+
+	providerCfg, err := p.target.GetSSHProviderConfig()
+	if err != nil {
+		p.sshErr = err
+		return nil, err
+	}
+
+	exec := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := exec.Connect(3, 10*time.Second); err != nil {
+		p.sshErr = fmt.Errorf("SSH connection failed: %w", err)
+		return nil, p.sshErr
+	}
+	p.ssh = exec
+	return exec, nil
+}
+
+func (p *targetDestroyPlan) close() {
+	if p.ssh != nil {
+		p.ssh.Disconnect()
+	}
+}
+
+// providerSSHKeyName returns the name the target's SSH key was uploaded under
+func providerSSHKeyName(target config.TargetConfig) string {
+	var fields struct {
+		SSHKeyName string `json:"ssh_key_name"`
+	}
+	if raw, ok := target.ProviderConfig[target.Provider]; ok {
+		json.Unmarshal(raw, &fields)
+	}
+	if fields.SSHKeyName != "" {
+		return fields.SSHKeyName
+	}
+	if target.ProjectPath == "" {
+		return ""
+	}
+	return "lightfold-" + util.GetTargetName(target.ProjectPath)
+}
+
+// isProviderNotFound reports whether a provider error means the server is already gone
+func isProviderNotFound(err error) bool {
+	providerErr, ok := err.(*providers.ProviderError)
+	if !ok {
+		return false
+	}
+
+	switch providerErr.Code {
+	case "not_found", "server_not_found", "machine_not_found", "droplet_not_found":
+		return true
+	}
+
+	errMsg := strings.ToLower(providerErr.Message)
+	return strings.Contains(errMsg, "not found") ||
+		strings.Contains(errMsg, "404") ||
+		strings.Contains(errMsg, "does not exist")
+}
+
+func commandError(result *sshpkg.CommandResult) string {
+	if result.Error != nil {
+		return result.Error.Error()
+	}
+	if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+		return stderr
+	}
+	return fmt.Sprintf("exit code %d", result.ExitCode)
 }
 
 func init() {
 	rootCmd.AddCommand(destroyCmd)
 
 	destroyCmd.Flags().StringVar(&destroyTargetFlag, "target", "", "Target name (required)")
+	destroyCmd.Flags().BoolVar(&destroyDeleteServerFlag, "delete-server", false, "Also delete adopted servers (created outside lightfold) from the provider")
+	destroyCmd.Flags().BoolVar(&destroyKeepServerFlag, "keep-server", false, "Keep the server and remove only this app's service, nginx site and certificate from it")
+	destroyCmd.Flags().StringVar(&confirmProtectedFlag, "confirm-protected", "", "Confirm destroying a protected target by passing its name (required without a terminal)")
+	destroyCmd.Flags().BoolVar(&destroyForceFlag, "force", false, "Remove local config and state even if the VM or remote cleanup fails")
 	destroyCmd.MarkFlagRequired("target")
 }
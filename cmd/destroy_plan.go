@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type destroyStatus string
+
+const (
+	destroyPending destroyStatus = "pending"
+	destroyRemoved destroyStatus = "removed"
+	destroySkipped destroyStatus = "skipped"
+	destroyFailed  destroyStatus = "failed"
+)
+
+// destroyStep is one item of the deletion plan shown before destroy runs
+type destroyStep struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Status      destroyStatus `json:"status"`
+	Detail      string        `json:"detail,omitempty"`
+
+	// run deletes the resource and returns a detail for the checklist. Steps
+	// planned as skipped have no run func.
+	run func() (string, error)
+
+	// retryable steps leave local records in place when they fail, so that
+	// re-running destroy still knows what is left to clean up
+	retryable bool
+	// localRecord steps remove the records a re-run needs (config, state)
+	localRecord bool
+}
+
+// destroyReport is written to ~/.lightfold/logs after a destroy
+type destroyReport struct {
+	Target     string         `json:"target"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Steps      []*destroyStep `json:"steps"`
+}
+
+func plannedStep(name, description string, run func() (string, error)) *destroyStep {
+	return &destroyStep{Name: name, Description: description, Status: destroyPending, run: run}
+}
+
+func skippedStep(name, description, reason string) *destroyStep {
+	return &destroyStep{Name: name, Description: description, Status: destroySkipped, Detail: reason}
+}
+
+// runDestroyPlan executes every pending step. A failing step never stops the
+// teardown; it only keeps local records when the failure must be retried.
+func runDestroyPlan(targetName string, steps []*destroyStep, force bool) *destroyReport {
+	report := &destroyReport{Target: targetName, StartedAt: time.Now().UTC(), Steps: steps}
+
+	retryNeeded := false
+	for _, step := range steps {
+		if step.Status != destroyPending {
+			continue
+		}
+
+		if step.localRecord && retryNeeded && !force {
+			step.Status = destroySkipped
+			step.Detail = fmt.Sprintf("kept so 'lightfold destroy --target %s' can retry the failed steps", targetName)
+			continue
+		}
+
+		fmt.Printf("%s %s\n", destroyWarningStyle.Render(style.Arrow()), destroyMutedStyle.Render(step.Description+"..."))
+		detail, err := step.run()
+		if err != nil {
+			step.Status = destroyFailed
+			step.Detail = err.Error()
+			if step.retryable {
+				retryNeeded = true
+			}
+		} else {
+			step.Status = destroyRemoved
+			step.Detail = detail
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report
+}
+
+// counts returns how many steps were removed, skipped and failed
+func (r *destroyReport) counts() (removed, skipped, failed int) {
+	for _, step := range r.Steps {
+		switch step.Status {
+		case destroyRemoved:
+			removed++
+		case destroySkipped:
+			skipped++
+		case destroyFailed:
+			failed++
+		}
+	}
+	return removed, skipped, failed
+}
+
+// writeDestroyReport saves the report as JSON and returns its path
+func writeDestroyReport(report *destroyReport) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	logsDir := filepath.Join(homeDir, config.LocalConfigDir, config.LocalLogsDir)
+	if err := os.MkdirAll(logsDir, config.PermDirectory); err != nil {
+		return "", fmt.Errorf("failed to create logs directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal destroy report: %w", err)
+	}
+
+	path := filepath.Join(logsDir, fmt.Sprintf("destroy-%s-%s.json", report.Target, report.StartedAt.Format("20060102-150405")))
+	if err := os.WriteFile(path, data, config.PermConfigFile); err != nil {
+		return "", fmt.Errorf("failed to write destroy report: %w", err)
+	}
+	return path, nil
+}
+
+func printDestroyPlan(steps []*destroyStep) {
+	for _, step := range steps {
+		if step.Status == destroySkipped {
+			fmt.Printf("  %s %s %s\n", destroyMutedStyle.Render(style.Pending()), destroyMutedStyle.Render(step.Description),
+				destroyMutedStyle.Render("- "+step.Detail))
+			continue
+		}
+		fmt.Printf("  %s %s\n", destroyDangerStyle.Render(style.Bullet()), step.Description)
+	}
+}
+
+// printDestroyChecklist lists every step of the plan with its outcome
+func printDestroyChecklist(report *destroyReport) {
+	for _, step := range report.Steps {
+		line := step.Description
+		if step.Detail != "" {
+			line += " - " + step.Detail
+		}
+
+		switch step.Status {
+		case destroyRemoved:
+			fmt.Printf("  %s %s\n", destroySuccessStyle.Render(style.Check()), line)
+		case destroyFailed:
+			fmt.Printf("  %s %s\n", destroyDangerStyle.Render(style.Cross()), line)
+		default:
+			fmt.Printf("  %s %s\n", destroyMutedStyle.Render(style.Pending()), destroyMutedStyle.Render(line))
+		}
+	}
+}
@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"lightfold/pkg/config"
 	"lightfold/pkg/providers"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssh/sshtest"
 	"lightfold/pkg/state"
 	"os"
 	"path/filepath"
@@ -393,3 +397,202 @@ func TestDestroyWithDifferentProviders(t *testing.T) {
 		t.Errorf("Hetzner provisioned ID should still be 'hetzner-456', got '%s'", id)
 	}
 }
+
+func TestRunDestroyPlanContinuesAfterFailure(t *testing.T) {
+	var ran []string
+	step := func(name string, err error) *destroyStep {
+		return plannedStep(name, name, func() (string, error) {
+			ran = append(ran, name)
+			return "", err
+		})
+	}
+
+	keyStep := step("provider_ssh_key", errors.New("api error"))
+	stateStep := step("state", nil)
+	stateStep.localRecord = true
+	steps := []*destroyStep{
+		skippedStep("vm", "vm", "kept"),
+		keyStep,
+		stateStep,
+	}
+
+	report := runDestroyPlan("myapp", steps, false)
+
+	if len(ran) != 2 {
+		t.Fatalf("expected both pending steps to run, ran %v", ran)
+	}
+	removed, skipped, failed := report.counts()
+	if removed != 1 || skipped != 1 || failed != 1 {
+		t.Errorf("counts = %d removed, %d skipped, %d failed; want 1, 1, 1", removed, skipped, failed)
+	}
+	if keyStep.Detail != "api error" {
+		t.Errorf("failed step detail = %q, want the error", keyStep.Detail)
+	}
+}
+
+func TestRunDestroyPlanKeepsLocalRecordsForRetry(t *testing.T) {
+	newSteps := func() (*destroyStep, *destroyStep) {
+		vm := plannedStep("vm", "VM", func() (string, error) { return "", errors.New("timeout") })
+		vm.retryable = true
+		cfgStep := plannedStep("config", "config", func() (string, error) { return "", nil })
+		cfgStep.localRecord = true
+		return vm, cfgStep
+	}
+
+	vm, cfgStep := newSteps()
+	runDestroyPlan("myapp", []*destroyStep{vm, cfgStep}, false)
+	if vm.Status != destroyFailed {
+		t.Errorf("vm status = %s, want failed", vm.Status)
+	}
+	if cfgStep.Status != destroySkipped {
+		t.Errorf("config status = %s, want skipped so destroy can be re-run", cfgStep.Status)
+	}
+
+	vm, cfgStep = newSteps()
+	runDestroyPlan("myapp", []*destroyStep{vm, cfgStep}, true)
+	if cfgStep.Status != destroyRemoved {
+		t.Errorf("config status with --force = %s, want removed", cfgStep.Status)
+	}
+}
+
+func TestWriteDestroyReport(t *testing.T) {
+	home, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	report := &destroyReport{
+		Target:    "myapp",
+		StartedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		Steps: []*destroyStep{
+			{Name: "vm", Description: "VM 123", Status: destroyRemoved},
+			{Name: "dns", Description: "DNS record", Status: destroySkipped, Detail: "not managed"},
+		},
+	}
+
+	path, err := writeDestroyReport(report)
+	if err != nil {
+		t.Fatalf("writeDestroyReport() error = %v", err)
+	}
+	if want := filepath.Join(home, ".lightfold", "logs", "destroy-myapp-20250301-120000.json"); path != want {
+		t.Errorf("report path = %s, want %s", path, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var decoded destroyReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if len(decoded.Steps) != 2 || decoded.Steps[1].Status != destroySkipped {
+		t.Errorf("unexpected report steps: %+v", decoded.Steps)
+	}
+}
+
+func TestProviderSSHKeyName(t *testing.T) {
+	target := config.TargetConfig{ProjectPath: "/home/me/myapp", Provider: "digitalocean"}
+	target.SetProviderConfig("digitalocean", &config.DigitalOceanConfig{IP: "1.2.3.4"})
+	if got := providerSSHKeyName(target); got != "lightfold-myapp" {
+		t.Errorf("providerSSHKeyName() = %q, want lightfold-myapp", got)
+	}
+
+	target.SetProviderConfig("digitalocean", &config.DigitalOceanConfig{IP: "1.2.3.4", SSHKeyName: "team-key"})
+	if got := providerSSHKeyName(target); got != "team-key" {
+		t.Errorf("providerSSHKeyName() = %q, want team-key", got)
+	}
+}
+
+func TestIsProviderNotFound(t *testing.T) {
+	if !isProviderNotFound(&providers.ProviderError{Code: "droplet_not_found"}) {
+		t.Error("droplet_not_found should count as not found")
+	}
+	if !isProviderNotFound(&providers.ProviderError{Code: "destroy_failed", Message: "server does not exist"}) {
+		t.Error("'does not exist' message should count as not found")
+	}
+	if isProviderNotFound(&providers.ProviderError{Code: "destroy_failed", Message: "rate limited"}) {
+		t.Error("rate limit should not count as not found")
+	}
+	if isProviderNotFound(errors.New("not found")) {
+		t.Error("plain errors should not count as not found")
+	}
+}
+
+// staticIPMockProvider is a MockProvider that can release static IPs
+type staticIPMockProvider struct {
+	MockProvider
+	released []string
+}
+
+func (m *staticIPMockProvider) SupportsSSH() bool { return true }
+
+func (m *staticIPMockProvider) ReleaseStaticIP(ctx context.Context, id string) error {
+	m.released = append(m.released, id)
+	return nil
+}
+
+func TestStaticIPStep(t *testing.T) {
+	awsTarget := func(elasticIP string) config.TargetConfig {
+		target := config.TargetConfig{Provider: "aws"}
+		target.SetProviderConfig("aws", &config.AWSConfig{IP: "203.0.113.9", InstanceID: "i-123", ElasticIP: elasticIP, Provisioned: true})
+		return target
+	}
+
+	if step := (&targetDestroyPlan{target: awsTarget("allocate"), destroyVM: true}).staticIPStep(); step != nil {
+		t.Errorf("Expected no step for an unallocated Elastic IP, got %+v", step)
+	}
+	if step := (&targetDestroyPlan{target: config.TargetConfig{Provider: "hetzner"}, destroyVM: true}).staticIPStep(); step != nil {
+		t.Errorf("Expected no step for non-AWS targets, got %+v", step)
+	}
+
+	kept := (&targetDestroyPlan{target: awsTarget("eipalloc-abc"), destroyVM: false}).staticIPStep()
+	if kept == nil || kept.Status != destroySkipped {
+		t.Errorf("Expected the Elastic IP to be kept with a kept server, got %+v", kept)
+	}
+
+	provider := &staticIPMockProvider{}
+	plan := &targetDestroyPlan{target: awsTarget("eipalloc-abc"), destroyVM: true, provider: provider, providerSet: true}
+	step := plan.staticIPStep()
+	if step == nil || step.Status != destroyPending {
+		t.Fatalf("Expected a pending release step, got %+v", step)
+	}
+	if _, err := step.run(); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if len(provider.released) != 1 || provider.released[0] != "eipalloc-abc" {
+		t.Errorf("Expected eipalloc-abc to be released, got %v", provider.released)
+	}
+}
+
+func TestRemoteAppStepsReleasePortReservation(t *testing.T) {
+	server := &sshtest.Server{}
+	sshpkg.UsePool(sshpkg.NewPool(server.Dial))
+	t.Cleanup(func() { sshpkg.ClosePool() })
+	exec := sshpkg.NewExecutor("203.0.113.20", "22", "deploy", sshtest.WriteKey(t))
+	if err := exec.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	t.Cleanup(func() { exec.Disconnect() })
+
+	plan := &targetDestroyPlan{
+		target:     config.TargetConfig{Port: 3001, AppName: "shop"},
+		targetName: "shop",
+		otherApps:  []state.DeployedApp{{TargetName: "blog"}},
+		ssh:        exec,
+		sshTried:   true,
+	}
+	var released *destroyStep
+	for _, step := range plan.remoteAppSteps("203.0.113.20") {
+		if step.Name == "port_reservation" {
+			released = step
+		}
+	}
+	if released == nil || released.Status != destroyPending {
+		t.Fatalf("Expected a pending port reservation step, got %+v", released)
+	}
+	if _, err := released.run(); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if !server.Ran(config.RemotePortsDir+"/3001") || !server.Ran("rm -f") {
+		t.Errorf("Expected shop's reservation of port 3001 removed, got %v", server.Commands())
+	}
+}
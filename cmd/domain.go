@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
 	"lightfold/pkg/proxy"
 	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl/certbot"
 	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
 	"os"
 	"strings"
 	"time"
@@ -16,14 +21,20 @@ import (
 )
 
 var (
-	domainTargetFlag string
-
-	domainStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	domainLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	domainValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	domainErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	domainSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82")).Bold(true)
-	domainMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	domainTargetFlag      string
+	domainPathFlag        string
+	domainStagingFlag     bool
+	domainDNSProviderFlag string
+	domainPlanFlag        bool
+	domainHTTP2Flag       bool
+	domainHTTP3Flag       bool
+
+	domainStyle        = style.Title
+	domainLabelStyle   = style.Header
+	domainValueStyle   = style.Value
+	domainErrorStyle   = style.Error
+	domainSuccessStyle = style.Success.Bold(true)
+	domainMutedStyle   = style.Muted
 )
 
 var domainCmd = &cobra.Command{
@@ -40,17 +51,59 @@ var domainAddCmd = &cobra.Command{
 Examples:
   lightfold domain add --domain example.com              # Current directory
   lightfold domain add ~/Projects/myapp --domain app.com # Specific path
-  lightfold domain add --target myapp --domain web.com   # Named target`,
+  lightfold domain add --target myapp --domain web.com   # Named target
+  lightfold domain add --target api --domain web.com --path /api # Route web.com/api/ to another target
+  lightfold domain add --domain example.com --staging    # Test with a Let's Encrypt staging certificate
+  lightfold domain add --domain example.com --dns-provider digitalocean # Wildcard certificate for previews
+  lightfold domain add --domain example.com --plan       # Show the nginx and certificate changes only
+  lightfold domain add --domain example.com --redirect-www # Also serve www.example.com as a 301 to example.com
+  lightfold domain add --domain example.com --http3      # Also serve HTTP/3 over QUIC
+
+An existing valid certificate for the domain is reused rather than reissued,
+so retrying does not count against Let's Encrypt's duplicate certificate limit.
+
+--dns-provider lets certbot answer DNS challenges through the DNS host's API
+(token from 'lightfold config set-token <provider>'), which preview deployments
+need for their *.preview.<domain> wildcard certificate.
+
+--redirect-www adds the www name of an apex domain, or the apex of a www
+domain, to the certificate and answers it with a 301 to --domain. Adding an
+apex domain without the flag asks whether to redirect www. The choice is saved
+with the domain, so deploys that regenerate the nginx config keep it.
+
+HTTPS sites are served over HTTP/2 unless --http2=false is given. --http3 also
+serves HTTP/3 over QUIC on UDP port 443 and advertises it with an Alt-Svc
+header; it needs nginx 1.25 or later built with the http_v3 module, which is
+checked before anything changes. The firewall set up by lightfold is opened for
+UDP 443, but cloud firewalls such as AWS security groups must allow it too.
+Both choices are saved with the domain, like --redirect-www.
+
+--plan renders the nginx configs that would be written, diffs them against the
+server's current files and lists the certbot commands, without changing
+anything on the server or in the config.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		domain := cmd.Flag("domain").Value.String()
 		if domain == "" {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: --domain flag is required"))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if !isValidDomain(domain) {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: Invalid domain format: %s", domain)))
-			os.Exit(1)
+			exit(1)
+		}
+
+		if err := validateRedirectWWW(domain); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		redirectWWWSet := cmd.Flags().Changed("redirect-www")
+
+		if domainDNSProviderFlag != "" {
+			if err := certbot.ValidateDNSProvider(domainDNSProviderFlag); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
 		}
 
 		var pathArg string
@@ -64,39 +117,104 @@ Examples:
 		if !state.IsCreated(targetName) {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: Target infrastructure not created yet"))
 			fmt.Fprintf(os.Stderr, "Run 'lightfold create' first\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		if !state.IsConfigured(targetName) {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: Target not configured yet"))
 			fmt.Fprintf(os.Stderr, "Run 'lightfold configure' first\n")
-			os.Exit(1)
+			exit(1)
+		}
+
+		if domainRedirectWWWFlag && (target.Provider == "flyio" || domainPathFlag != "") {
+			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: --redirect-www covers domains served by nginx on the target's own server, not fly.io targets or --path routes"))
+			exit(1)
+		}
+
+		protocolsSet := cmd.Flags().Changed("http2") || cmd.Flags().Changed("http3")
+		if protocolsSet && (target.Provider == "flyio" || domainPathFlag != "") {
+			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: --http2 and --http3 cover domains served by nginx on the target's own server, not fly.io targets or --path routes"))
+			exit(1)
+		}
+
+		if domainPlanFlag && (target.Provider == "flyio" || domainPathFlag != "") {
+			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: --plan covers domains served by nginx on the target's own server, not fly.io targets or --path routes"))
+			exit(1)
+		}
+
+		if target.Provider == "flyio" {
+			if domainPathFlag != "" {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: Path routes are not supported for fly.io targets"))
+				exit(1)
+			}
+
+			if err := addFlyioDomain(cfg, target, targetName, domain); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error configuring domain: %v", err)))
+				exit(1)
+			}
+			fmt.Println()
+			return
+		}
+
+		if domainPathFlag != "" {
+			prefix, err := normalizePathPrefix(domainPathFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+
+			if err := addPathRoute(cfg, target, targetName, domain, prefix); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error adding path route: %v", err)))
+				exit(1)
+			}
+
+			fmt.Printf("\n%s %s\n\n", domainSuccessStyle.Render(style.Check()),
+				domainMutedStyle.Render(fmt.Sprintf("%s%s/ now routes to %s", domain, prefix, targetName)))
+			return
 		}
 
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		sshExecutor := sshpkg.NewExecutor(
-			providerCfg.GetIP(),
-			"22",
-			providerCfg.GetUsername(),
-			providerCfg.GetSSHKey(),
-		)
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 
 		testResult := sshExecutor.Execute("echo 'connection test'")
 		if testResult.ExitCode != 0 {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: Cannot connect to server: %s", testResult.Stderr)))
-			os.Exit(1)
+			exit(1)
+		}
+
+		if domainPlanFlag {
+			planTarget := target
+			planTarget.Domain = &config.DomainConfig{Domain: domain, RedirectFrom: resolveWWWRedirect(redirectWWWSet, target, domain, false)}
+			if target.Domain != nil {
+				planTarget.Domain.HTTP2, planTarget.Domain.HTTP3 = target.Domain.HTTP2, target.Domain.HTTP3
+			}
+			applyDomainProtocolFlags(cmd, planTarget.Domain)
+			plan, err := buildDomainPlan(sshExecutor, &planTarget, targetName, providerCfg.GetIP(), domain, domainStagingFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			printDomainPlan(plan)
+			return
 		}
 
 		// Prompt for SSL
 		fmt.Printf("\n%s\n", domainStyle.Render("Domain Configuration"))
 		fmt.Printf("  Domain: %s\n", domainValueStyle.Render(domain))
 		fmt.Printf("  Target: %s\n", domainValueStyle.Render(targetName))
-		fmt.Printf("  IP:     %s\n\n", domainValueStyle.Render(providerCfg.GetIP()))
+		ipv4, ipv6 := config.PublicAddresses(providerCfg)
+		if ipv4 != "" {
+			fmt.Printf("  IP:     %s\n", domainValueStyle.Render(ipv4))
+		}
+		if ipv6 != "" {
+			fmt.Printf("  IPv6:   %s\n", domainValueStyle.Render(ipv6))
+		}
+		fmt.Println()
 
 		enableSSL := true
 		fmt.Printf("Enable SSL? (Y/n): ")
@@ -105,20 +223,46 @@ Examples:
 		if strings.ToLower(strings.TrimSpace(sslResponse)) == "n" {
 			enableSSL = false
 		}
+		redirectFrom := resolveWWWRedirect(redirectWWWSet, target, domain, true)
 
 		// Display DNS configuration instructions
-		fmt.Printf("%s\n\n", domainLabelStyle.Render("Add the following A record to your domain registrar's DNS settings:"))
+		recordLabel := "Add the following A record to your domain registrar's DNS settings:"
+		switch {
+		case ipv4 != "" && ipv6 != "":
+			recordLabel = "Add the following A and AAAA records to your domain registrar's DNS settings:"
+		case ipv4 == "":
+			recordLabel = "Add the following AAAA record to your domain registrar's DNS settings:"
+		}
+		fmt.Printf("%s\n\n", domainLabelStyle.Render(recordLabel))
 
-		dnsBoxStyle := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("86")).
+		dnsBoxStyle := style.Box(style.ColorHeader).
 			Padding(0, 1).
-			Foreground(lipgloss.Color("245"))
+			Foreground(lipgloss.Color(style.ColorMuted))
 
-		dnsContent := fmt.Sprintf("  Type:  A\n  Name:  @ (or leave blank for root domain)\n  Value: %s\n  TTL:   3600 (or default)", providerCfg.GetIP())
-		fmt.Printf("%s\n", dnsBoxStyle.Render(dnsContent))
+		var dnsRecords []string
+		if ipv4 != "" {
+			dnsRecords = append(dnsRecords, fmt.Sprintf("  Type:  A\n  Name:  @ (or leave blank for root domain)\n  Value: %s\n  TTL:   3600 (or default)", ipv4))
+		}
+		if ipv6 != "" {
+			dnsRecords = append(dnsRecords, fmt.Sprintf("  Type:  AAAA\n  Name:  @ (or leave blank for root domain)\n  Value: %s\n  TTL:   3600 (or default)", ipv6))
+		}
+		switch {
+		case strings.HasPrefix(redirectFrom, "www."):
+			dnsRecords = append(dnsRecords, fmt.Sprintf("  Type:  CNAME\n  Name:  www\n  Value: %s\n  TTL:   3600 (or default)", domain))
+		case redirectFrom != "":
+			// The apex cannot be a CNAME, so it gets the server's address too
+			recordType := "A"
+			if ipv4 == "" {
+				recordType = "AAAA"
+			}
+			dnsRecords = append(dnsRecords, fmt.Sprintf("  Type:  %s\n  Name:  @ (%s, redirected to %s)\n  Value: %s\n  TTL:   3600 (or default)", recordType, redirectFrom, domain, serverAddress(ipv4, ipv6, "")))
+		}
+		fmt.Printf("%s\n", dnsBoxStyle.Render(strings.Join(dnsRecords, "\n\n")))
 
 		fmt.Printf("\n%s\n", domainMutedStyle.Render("For subdomains (e.g., app.example.com), use the subdomain name instead of '@'"))
+		if domainDNSProviderFlag != "" {
+			fmt.Printf("%s\n", domainMutedStyle.Render(fmt.Sprintf("For preview deployments, point a *.preview.%s record at the same address", domain)))
+		}
 		fmt.Printf("%s\n\n", domainMutedStyle.Render("DNS propagation typically takes 5-60 minutes."))
 
 		fmt.Printf("Have you configured DNS? (Y/n): ")
@@ -128,7 +272,19 @@ Examples:
 		if strings.ToLower(strings.TrimSpace(dnsResponse)) == "n" || strings.ToLower(strings.TrimSpace(dnsResponse)) == "no" {
 			fmt.Printf("\n%s\n", domainErrorStyle.Render("Please configure DNS before continuing."))
 			fmt.Printf("%s\n\n", domainMutedStyle.Render("Run this command again after DNS is configured."))
-			os.Exit(1)
+			exit(1)
+		}
+
+		recordsOK := true
+		for _, name := range (&config.DomainConfig{Domain: domain, RedirectFrom: redirectFrom}).Names() {
+			problems, ok := checkDomainRecords(name, ipv4, ipv6)
+			for _, problem := range problems {
+				fmt.Printf("%s %s\n", domainErrorStyle.Render(style.Warn()), domainMutedStyle.Render(problem))
+			}
+			recordsOK = recordsOK && ok
+		}
+		if !recordsOK && enableSSL {
+			fmt.Printf("%s\n", domainMutedStyle.Render("Certificate issuance fails until the records point at this server; DNS may still be propagating."))
 		}
 
 		if target.Domain == nil {
@@ -136,24 +292,33 @@ Examples:
 		}
 
 		target.Domain.Domain = domain
+		target.Domain.RedirectFrom = redirectFrom
 		target.Domain.SSLEnabled = enableSSL
+		target.Domain.SSLStaging = enableSSL && domainStagingFlag
 		if enableSSL {
 			target.Domain.SSLManager = "certbot"
 		}
 		target.Domain.ProxyType = "nginx"
+		applyDomainProtocolFlags(cmd, target.Domain)
+		if domainDNSProviderFlag != "" {
+			target.Domain.DNSProvider = domainDNSProviderFlag
+		}
 
 		cfg.SetTarget(targetName, target)
 		if err := cfg.SaveConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("\n%s %s\n", domainSuccessStyle.Render("✓"), domainMutedStyle.Render("Domain configuration saved"))
+		fmt.Printf("\n%s %s\n", domainSuccessStyle.Render(style.Check()), domainMutedStyle.Render("Domain configuration saved"))
 
 		if err := configureDomainAndSSL(&target, targetName, domain, enableSSL); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error configuring domain: %v", err)))
-			fmt.Fprintf(os.Stderr, "\nYou can retry with: lightfold domain add --domain %s --target %s\n", domain, targetName)
-			os.Exit(1)
+			var rateLimit *certbot.RateLimitError
+			if !errors.As(err, &rateLimit) {
+				fmt.Fprintf(os.Stderr, "\nYou can retry with: lightfold domain add --domain %s --target %s\n", domain, targetName)
+			}
+			exit(1)
 		}
 
 		// Update state with SSL information
@@ -178,7 +343,8 @@ var domainRemoveCmd = &cobra.Command{
 Examples:
   lightfold domain remove              # Current directory
   lightfold domain remove ~/Projects/myapp # Specific path
-  lightfold domain remove --target myapp   # Named target`,
+  lightfold domain remove --target myapp   # Named target
+  lightfold domain remove --target api --path /api # Remove a path route only`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Resolve target
 		var pathArg string
@@ -189,9 +355,39 @@ Examples:
 		cfg := loadConfigOrExit()
 		target, targetName := resolveTarget(cfg, domainTargetFlag, pathArg)
 
+		if domainPathFlag != "" {
+			prefix, err := normalizePathPrefix(domainPathFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+
+			route, err := findTargetPathRoute(target, cmd.Flag("domain").Value.String(), prefix)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+
+			fmt.Printf("Remove path route %s%s/ from %s? (y/N): ", route.Domain, route.PathPrefix, targetName)
+			var response string
+			fmt.Scanln(&response)
+			if strings.ToLower(strings.TrimSpace(response)) != "y" {
+				fmt.Println("Cancelled")
+				return
+			}
+
+			if err := removePathRoute(cfg, target, targetName, route); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error removing path route: %v", err)))
+				exit(1)
+			}
+
+			fmt.Printf("\n%s\n\n", domainSuccessStyle.Render(fmt.Sprintf("%s Path route %s%s/ removed", style.Check(), route.Domain, route.PathPrefix)))
+			return
+		}
+
 		if target.Domain == nil || target.Domain.Domain == "" {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render("Error: No domain configured for this target"))
-			os.Exit(1)
+			exit(1)
 		}
 
 		currentDomain := target.Domain.Domain
@@ -214,31 +410,51 @@ Examples:
 			return
 		}
 
+		if target.Provider == "flyio" {
+			if err := removeFlyioDomain(target); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error removing domain: %v", err)))
+				exit(1)
+			}
+
+			target.Domain = nil
+			cfg.SetTarget(targetName, target)
+			if err := cfg.SaveConfig(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
+				exit(1)
+			}
+
+			fmt.Printf("\n%s\n\n", domainSuccessStyle.Render(style.Check()+" Domain removed from the fly.io app"))
+			return
+		}
+
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Test SSH connection
-		sshExecutor := sshpkg.NewExecutor(
-			providerCfg.GetIP(),
-			"22",
-			providerCfg.GetUsername(),
-			providerCfg.GetSSHKey(),
-		)
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 
-		testResult := sshExecutor.Execute("echo 'connection test'")
-		if testResult.ExitCode != 0 {
-			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: Cannot connect to server: %s", testResult.Stderr)))
-			os.Exit(1)
+		defer sshExecutor.Disconnect()
+
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error: Cannot connect to server: %v", err)))
+			exit(1)
 		}
 
 		fmt.Printf("\n%s\n", domainStyle.Render("Reverting to IP-based configuration..."))
 
 		if err := revertToIPBasedNginx(&target, targetName, sshExecutor); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error reverting configuration: %v", err)))
-			os.Exit(1)
+			exit(1)
+		}
+
+		if routes, err := state.GetPathRoutesForDomain(providerCfg.GetIP(), currentDomain); err == nil {
+			for _, route := range routes {
+				fmt.Printf("Note: %s%s/ (target '%s') is served at http://%s%s/ until %s is added again\n",
+					currentDomain, route.PathPrefix, route.TargetName, urlHost(providerCfg.GetIP()), route.PathPrefix, currentDomain)
+			}
 		}
 
 		target.Domain = nil
@@ -246,11 +462,14 @@ Examples:
 		cfg.SetTarget(targetName, target)
 		if err := cfg.SaveConfig(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", domainErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
-			os.Exit(1)
+			exit(1)
+		}
+		if err := state.ClearDomainApplied(targetName); err != nil {
+			fmt.Printf("Warning: failed to update domain state: %v\n", err)
 		}
 
-		fmt.Printf("\n%s\n", domainSuccessStyle.Render("✓ Domain removed successfully!"))
-		fmt.Printf("%s\n", domainValueStyle.Render(fmt.Sprintf("Your app is now available at: http://%s", providerCfg.GetIP())))
+		fmt.Printf("\n%s\n", domainSuccessStyle.Render(style.Check()+" Domain removed successfully!"))
+		fmt.Printf("%s\n", domainValueStyle.Render(fmt.Sprintf("Your app is now available at: http://%s", urlHost(providerCfg.GetIP()))))
 		fmt.Println()
 	},
 }
@@ -286,8 +505,18 @@ Examples:
 			sslStatus := "Disabled"
 			if target.Domain.SSLEnabled {
 				sslStatus = "Enabled"
+				if target.Domain.SSLStaging {
+					sslStatus = "Enabled (staging certificate)"
+				}
 			}
 			fmt.Printf("  %s:        %s\n", domainLabelStyle.Render("SSL"), domainValueStyle.Render(sslStatus))
+			if target.Domain.SSLEnabled && target.Domain.SSLManager != "flyio" {
+				fmt.Printf("  %s:  %s\n", domainLabelStyle.Render("Protocols"), domainValueStyle.Render(domainProtocolNames(target.Domain)))
+			}
+
+			if target.Domain.RedirectFrom != "" {
+				fmt.Printf("  %s:   %s\n", domainLabelStyle.Render("Redirect"), domainValueStyle.Render(fmt.Sprintf("%s → %s (301)", target.Domain.RedirectFrom, target.Domain.Domain)))
+			}
 
 			if target.Domain.SSLManager != "" {
 				fmt.Printf("  %s: %s\n", domainLabelStyle.Render("SSL Manager"), domainValueStyle.Render(target.Domain.SSLManager))
@@ -297,12 +526,17 @@ Examples:
 				fmt.Printf("  %s:  %s\n", domainLabelStyle.Render("Proxy Type"), domainValueStyle.Render(target.Domain.ProxyType))
 			}
 
-			// Show SSL renewal info from state
-			if target.Domain.SSLEnabled {
+			if target.Domain.SSLManager == "flyio" {
+				showFlyioDomain(target)
+			}
+
+			// Show SSL renewal info from state and the server
+			if target.Domain.SSLEnabled && target.Domain.SSLManager != "flyio" {
 				if targetState, err := state.GetTargetState(targetName); err == nil && !targetState.LastSSLRenewal.IsZero() {
-					renewalTime := targetState.LastSSLRenewal.Format("2006-01-02 15:04:05")
+					renewalTime := timefmt.Human(targetState.LastSSLRenewal)
 					fmt.Printf("  %s: %s\n", domainLabelStyle.Render("Last Renewal"), domainValueStyle.Render(renewalTime))
 				}
+				showRenewal(target, targetName)
 			}
 
 			fmt.Println()
@@ -312,6 +546,7 @@ Examples:
 				fmt.Printf("%s\n", domainValueStyle.Render(fmt.Sprintf("Your app is available at: http://%s", target.Domain.Domain)))
 			}
 		}
+		printDomainRoutes(target, targetName)
 		fmt.Println()
 	},
 }
@@ -350,9 +585,23 @@ func revertToIPBasedNginx(target *config.TargetConfig, targetName string, sshExe
 		nginxMgr.SetExecutor(sshExecutor)
 	}
 
-	port := utils.ExtractPortFromTarget(target, target.ProjectPath)
+	port, discovered, err := utils.ResolveTargetPort(target, targetName, sshExecutor)
+	if err != nil {
+		return err
+	}
+	if discovered {
+		target.Port = port
+	}
+
+	appName := resolveAppName(target, targetName, sshExecutor)
 
-	appName := targetName
+	// Path routes stay on the site so the other apps keep answering on the IP
+	var pathRoutes []proxy.PathRoute
+	if target.Domain != nil {
+		if providerCfg, err := target.GetSSHProviderConfig(); err == nil {
+			pathRoutes = proxyPathRoutes(providerCfg.GetIP(), target.Domain.Domain)
+		}
+	}
 
 	// Configure nginx without domain (IP-based)
 	proxyConfig := proxy.ProxyConfig{
@@ -362,11 +611,16 @@ func revertToIPBasedNginx(target *config.TargetConfig, targetName string, sshExe
 		SSLEnabled:  false,
 		SSLCertPath: "",
 		SSLKeyPath:  "",
+		StaticPaths: deploy.StaticPathsFor(target.Framework, config.AppDir(target.RemoteBaseDir(), appName), target.Deploy),
+		PathRoutes:  pathRoutes,
 	}
 
 	if err := proxyManager.Configure(proxyConfig); err != nil {
 		return fmt.Errorf("failed to configure proxy: %w", err)
 	}
+	if err := keepDomainSiteMaintenance(proxyManager, sshExecutor, appName); err != nil {
+		return err
+	}
 
 	if err := proxyManager.Reload(); err != nil {
 		return fmt.Errorf("failed to reload proxy: %w", err)
@@ -388,4 +642,14 @@ func init() {
 	domainAddCmd.Flags().StringVarP(&domainTargetFlag, "target", "t", "", "Target name")
 	domainRemoveCmd.Flags().StringVarP(&domainTargetFlag, "target", "t", "", "Target name")
 	domainShowCmd.Flags().StringVarP(&domainTargetFlag, "target", "t", "", "Target name")
+
+	domainAddCmd.Flags().BoolVar(&domainStagingFlag, "staging", false, "Issue the certificate from the Let's Encrypt staging environment (not browser-trusted, no production rate limits)")
+	domainAddCmd.Flags().StringVar(&domainPathFlag, "path", "", "Serve this target under a path prefix on another target's domain (e.g. /api)")
+	domainAddCmd.Flags().BoolVar(&domainPlanFlag, "plan", false, "Show the nginx config and certbot changes without applying them")
+	domainAddCmd.Flags().BoolVar(&domainRedirectWWWFlag, "redirect-www", false, "Also serve the www name of an apex domain (or the apex of a www domain) as a 301 to --domain")
+	domainAddCmd.Flags().BoolVar(&domainHTTP2Flag, "http2", true, "Serve HTTPS over HTTP/2 (--http2=false to turn it off)")
+	domainAddCmd.Flags().BoolVar(&domainHTTP3Flag, "http3", false, "Also serve HTTP/3 over QUIC on UDP port 443 (needs nginx 1.25+ with the http_v3 module)")
+	domainAddCmd.Flags().StringVar(&domainDNSProviderFlag, "dns-provider", "", "DNS host certbot uses for wildcard preview certificates (digitalocean, cloudflare)")
+	domainRemoveCmd.Flags().StringVar(&domainPathFlag, "path", "", "Remove only the path route with this prefix")
+	domainRemoveCmd.Flags().String("domain", "", "Domain of the path route to remove (with --path)")
 }
@@ -0,0 +1,510 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// Outcomes of one domain check layer
+const (
+	domainCheckPass = "pass"
+	domainCheckWarn = "warn"
+	domainCheckFail = "fail"
+	domainCheckSkip = "skip"
+)
+
+// certExpiryWarning is how close to expiry a certificate is reported
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// domainProbeTimeout bounds each HTTP request the check makes
+const domainProbeTimeout = 10 * time.Second
+
+// domainCheck is the result of one layer between a visitor and the app
+type domainCheck struct {
+	Layer  string `json:"layer"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// domainCheckReport is what `domain check --json` prints
+type domainCheckReport struct {
+	Target       string        `json:"target"`
+	Domain       string        `json:"domain"`
+	OK           bool          `json:"ok"`
+	FirstFailure string        `json:"first_failure,omitempty"`
+	Checks       []domainCheck `json:"checks"`
+}
+
+var domainCheckCmd = &cobra.Command{
+	Use:   "check [path]",
+	Short: "Diagnose why a domain does not reach the app",
+	Long: `Check every layer between a visitor and the app, in order:
+
+  DNS          A/AAAA records point at the server
+  Local        the domain answers from this machine
+  Server       the domain answers from the server itself
+  Nginx        a site serves the domain and 'nginx -t' passes
+  Certificate  the served certificate covers the domain and is not expiring
+  Upstream     something listens on the app's port
+  Health       the health check path answers through the domain
+  Redirect     the www or apex name answers with a 301 to the domain
+               (only with a redirect from 'domain add --redirect-www')
+
+The first failing layer is highlighted with a suggested fix. When the server
+answers itself but not from here, the problem is DNS or a firewall rather than
+the server's config.
+
+Examples:
+  lightfold domain check                 # Current directory
+  lightfold domain check --target myapp  # Named target
+  lightfold domain check --json          # Machine-readable report`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, domainTargetFlag, pathArg)
+
+		if target.Domain == nil || target.Domain.Domain == "" {
+			fmt.Fprintf(os.Stderr, "Error: target '%s' has no domain; add one with 'lightfold domain add --target %s --domain example.com'\n", targetName, targetName)
+			exit(1)
+		}
+		if target.Domain.SSLManager == "flyio" {
+			fmt.Fprintf(os.Stderr, "Error: fly.io serves %s; check it with 'fly certs show %s'\n", target.Domain.Domain, target.Domain.Domain)
+			exit(1)
+		}
+
+		report := runDomainChecks(target, targetName)
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(report)
+		} else {
+			printDomainCheckReport(report)
+		}
+		if !report.OK {
+			exit(1)
+		}
+	},
+}
+
+// runDomainChecks checks each layer for the target's domain. Server-side
+// layers are skipped when the server cannot be reached over SSH.
+func runDomainChecks(target config.TargetConfig, targetName string) domainCheckReport {
+	domain := target.Domain.Domain
+	ipv4, ipv6 := domainServerAddresses(target)
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	scheme := "http"
+	if target.Domain.SSLEnabled {
+		scheme = "https"
+	}
+
+	var checks []domainCheck
+	checks = append(checks, domainDNSCheck(domain, ipv4, ipv6))
+	checks = append(checks, domainLocalCheck(scheme, domain))
+
+	var runner sshpkg.SudoRunner
+	var sshErr error
+	if providerCfg, err := target.GetSSHProviderConfig(); err != nil {
+		sshErr = err
+	} else {
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		defer sshExecutor.Disconnect()
+		if result := sshExecutor.Execute("true"); result.Error != nil {
+			sshErr = result.Error
+		} else {
+			runner = sshExecutor
+		}
+	}
+
+	if runner == nil {
+		for _, layer := range []string{"Server", "Nginx"} {
+			checks = append(checks, domainCheck{Layer: layer, Status: domainCheckSkip, Detail: fmt.Sprintf("cannot connect over SSH: %v", sshErr)})
+		}
+	} else {
+		checks = append(checks, domainServerCheck(runner, scheme, domain))
+		checks = append(checks, domainNginxCheck(runner, domain, targetName))
+	}
+	checks[1].Fix = localFailureFix(checks[0], checks[1], checks[2])
+
+	checks = append(checks, domainCertificateCheck(target, targetName, ipv4, ipv6))
+
+	if runner == nil {
+		checks = append(checks, domainCheck{Layer: "Upstream", Status: domainCheckSkip, Detail: "cannot connect over SSH"})
+	} else {
+		checks = append(checks, domainUpstreamCheck(runner, &target, targetName, detection.Meta["deployment_type"] == "static"))
+	}
+
+	checks = append(checks, domainHealthCheck(scheme, domain, domainHealthPath(target, detection), targetName))
+
+	if from := target.Domain.RedirectFrom; from != "" {
+		if runner == nil {
+			checks = append(checks, domainCheck{Layer: "Redirect", Status: domainCheckSkip, Detail: "cannot connect over SSH"})
+		} else {
+			checks = append(checks, domainRedirectCheck(runner, scheme, from, domain, targetName))
+		}
+	}
+
+	report := domainCheckReport{Target: targetName, Domain: domain, OK: true, Checks: checks}
+	if failed := firstFailedCheck(checks); failed != nil {
+		report.OK = false
+		report.FirstFailure = failed.Layer
+	}
+	return report
+}
+
+// firstFailedCheck returns the lowest layer that failed, or nil
+func firstFailedCheck(checks []domainCheck) *domainCheck {
+	for i := range checks {
+		if checks[i].Status == domainCheckFail {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+// domainDNSCheck compares the domain's records with the server's addresses
+func domainDNSCheck(domain, ipv4, ipv6 string) domainCheck {
+	check := domainCheck{Layer: "DNS"}
+	addresses, err := lookupHost(domain)
+	if err != nil || len(addresses) == 0 {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("%s does not resolve", domain)
+		check.Fix = fmt.Sprintf("Create an A record for %s pointing at %s", domain, serverAddress(ipv4, ipv6, "the server"))
+		return check
+	}
+
+	problems, ok := checkDomainRecords(domain, ipv4, ipv6)
+	switch {
+	case !ok:
+		check.Status = domainCheckFail
+		check.Detail = strings.Join(problems, "; ")
+		check.Fix = fmt.Sprintf("Point the records for %s at the server, then wait for the old ones to expire from caches", domain)
+	case len(problems) > 0:
+		check.Status = domainCheckWarn
+		check.Detail = strings.Join(problems, "; ")
+	default:
+		check.Status = domainCheckPass
+		check.Detail = fmt.Sprintf("%s resolves to %s", domain, strings.Join(addresses, ", "))
+	}
+	return check
+}
+
+// serverAddress prefers the server's IPv4 address, then IPv6, then fallback
+func serverAddress(ipv4, ipv6, fallback string) string {
+	if ipv4 != "" {
+		return ipv4
+	}
+	if ipv6 != "" {
+		return ipv6
+	}
+	return fallback
+}
+
+// domainProbeClient makes requests without following redirects or verifying
+// certificates, which the certificate layer checks on its own
+var domainProbeClient = &http.Client{
+	Timeout:   domainProbeTimeout,
+	Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// domainLocalCheck requests the domain from this machine
+func domainLocalCheck(scheme, domain string) domainCheck {
+	check := domainCheck{Layer: "Local"}
+	url := fmt.Sprintf("%s://%s/", scheme, domain)
+	resp, err := domainProbeClient.Get(url)
+	if err != nil {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("%s did not answer: %v", url, err)
+		return check
+	}
+	resp.Body.Close()
+	check.Status = domainCheckPass
+	check.Detail = fmt.Sprintf("%s answered %d", url, resp.StatusCode)
+	return check
+}
+
+// localFailureFix explains a request from this machine that failed by what
+// the DNS and server layers found: with the server answering itself and DNS
+// pointing at it, a firewall is in the way
+func localFailureFix(dns, local, server domainCheck) string {
+	switch {
+	case local.Status != domainCheckFail:
+		return ""
+	case dns.Status == domainCheckFail:
+		return "Fix DNS first; this machine is not reaching the server"
+	case server.Status == domainCheckPass:
+		return "The server answers itself, so a firewall is blocking ports 80 and 443; check the provider's firewall and 'ufw status' on the server"
+	default:
+		return "The server does not answer either; see the layers below"
+	}
+}
+
+// domainServerCheck requests the domain from the server itself, resolved to
+// the loopback address so DNS is left out
+func domainServerCheck(runner sshpkg.SudoRunner, scheme, domain string) domainCheck {
+	check := domainCheck{Layer: "Server"}
+	port := 80
+	if scheme == "https" {
+		port = 443
+	}
+	result := runner.ExecuteSudo(fmt.Sprintf("curl -sk -o /dev/null -w '%%{http_code}' --max-time %d --resolve %s:%d:127.0.0.1 %s://%s/",
+		int(domainProbeTimeout.Seconds()), domain, port, scheme, domain))
+	code := strings.TrimSpace(result.Stdout)
+	if result.Error != nil || code == "" || code == "000" {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("nothing answers for %s on port %d on the server", domain, port)
+		check.Fix = "Check that nginx is running with 'systemctl status nginx' on the server"
+		return check
+	}
+	check.Status = domainCheckPass
+	check.Detail = fmt.Sprintf("%s://%s/ answered %s on the server", scheme, domain, code)
+	return check
+}
+
+// serverNameGrep lists the enabled nginx sites whose server_name directive
+// names domain
+func serverNameGrep(domain string) string {
+	return "grep -lsE -- " + util.ShellQuote(serverNamePattern(domain)) + " /etc/nginx/sites-enabled/*"
+}
+
+// serverNamePattern matches a server_name line naming domain. The domain is
+// matched literally and as a whole name, so example.com matches neither
+// www.example.com nor exampleXcom.
+func serverNamePattern(domain string) string {
+	return `^[[:space:]]*server_name([[:space:]]+[^;[:space:]]+)*[[:space:]]+` + regexp.QuoteMeta(domain) + `([[:space:]]|;|$)`
+}
+
+// domainNginxCheck verifies an enabled site names the domain and the config is valid
+func domainNginxCheck(runner sshpkg.SudoRunner, domain, targetName string) domainCheck {
+	check := domainCheck{Layer: "Nginx"}
+	site := runner.ExecuteSudo(serverNameGrep(domain))
+	siteFile := strings.TrimSpace(strings.SplitN(site.Stdout, "\n", 2)[0])
+	if site.Error != nil || site.ExitCode != 0 || siteFile == "" {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("no enabled nginx site serves %s", domain)
+		check.Fix = fmt.Sprintf("Run 'lightfold sync --target %s --fix' to recreate the site", targetName)
+		return check
+	}
+
+	test := runner.ExecuteSudo("nginx -t")
+	if test.Error != nil || test.ExitCode != 0 {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("nginx -t failed: %s", firstOutputLine(test.Stderr))
+		check.Fix = "Fix the reported config error on the server, then reload nginx with 'systemctl reload nginx'"
+		return check
+	}
+	check.Status = domainCheckPass
+	check.Detail = fmt.Sprintf("%s serves %s; nginx -t passes", siteFile, domain)
+	return check
+}
+
+func firstOutputLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return "no output"
+}
+
+// domainCertificateCheck fetches the certificate the server presents for the
+// domain. It connects to the server's address rather than the domain so a
+// DNS problem does not hide a certificate one.
+func domainCertificateCheck(target config.TargetConfig, targetName, ipv4, ipv6 string) domainCheck {
+	domain := target.Domain.Domain
+	if !target.Domain.SSLEnabled {
+		return domainCheck{Layer: "Certificate", Status: domainCheckSkip, Detail: "SSL is not enabled"}
+	}
+
+	address := serverAddress(ipv4, ipv6, domain)
+	dialer := &net.Dialer{Timeout: domainProbeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(address, "443"), &tls.Config{ServerName: domain, InsecureSkipVerify: true})
+	if err != nil {
+		return domainCheck{
+			Layer:  "Certificate",
+			Status: domainCheckFail,
+			Detail: fmt.Sprintf("TLS handshake with %s failed: %v", address, err),
+			Fix:    fmt.Sprintf("Re-issue the certificate with 'lightfold domain add --target %s --domain %s'", targetName, domain),
+		}
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return domainCheck{Layer: "Certificate", Status: domainCheckFail, Detail: "the server presented no certificate"}
+	}
+	check := evaluateCertificate(certs[0], domain, targetName, target.Domain.SSLStaging, time.Now())
+	if from := target.Domain.RedirectFrom; from != "" && check.Status != domainCheckFail {
+		if err := certs[0].VerifyHostname(from); err != nil {
+			check.Status = domainCheckFail
+			check.Detail = fmt.Sprintf("certificate for %s does not cover the redirected %s", strings.Join(certificateNames(certs[0]), ", "), from)
+			check.Fix = fmt.Sprintf("Re-issue the certificate with 'lightfold domain add --target %s --domain %s --redirect-www'", targetName, domain)
+		}
+	}
+	return check
+}
+
+// evaluateCertificate checks that a served certificate covers the domain and
+// is not expired or about to expire
+func evaluateCertificate(cert *x509.Certificate, domain, targetName string, staging bool, now time.Time) domainCheck {
+	check := domainCheck{Layer: "Certificate"}
+	reissue := fmt.Sprintf("Re-issue the certificate with 'lightfold domain add --target %s --domain %s'", targetName, domain)
+
+	if err := cert.VerifyHostname(domain); err != nil {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("certificate for %s does not cover %s", strings.Join(certificateNames(cert), ", "), domain)
+		check.Fix = reissue
+		return check
+	}
+
+	remaining := cert.NotAfter.Sub(now)
+	expiry := cert.NotAfter.Format("2006-01-02")
+	switch {
+	case remaining <= 0:
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("certificate expired on %s", expiry)
+		check.Fix = reissue + "; 'systemctl status certbot.timer' shows why renewal stopped"
+	case remaining < certExpiryWarning:
+		check.Status = domainCheckWarn
+		check.Detail = fmt.Sprintf("certificate expires on %s (%d days)", expiry, int(remaining.Hours()/24))
+		check.Fix = "Renewal should have happened by now; check 'systemctl status certbot.timer' on the server"
+	case staging:
+		check.Status = domainCheckWarn
+		check.Detail = fmt.Sprintf("staging certificate valid until %s; browsers will not trust it", expiry)
+		check.Fix = reissue + " without --staging"
+	default:
+		check.Status = domainCheckPass
+		check.Detail = fmt.Sprintf("certificate covers %s, valid until %s", domain, expiry)
+	}
+	return check
+}
+
+func certificateNames(cert *x509.Certificate) []string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames
+	}
+	return []string{cert.Subject.CommonName}
+}
+
+// domainUpstreamCheck confirms something listens on the port nginx proxies to
+func domainUpstreamCheck(runner sshpkg.SudoRunner, target *config.TargetConfig, targetName string, static bool) domainCheck {
+	check := domainCheck{Layer: "Upstream"}
+	if static {
+		check.Status = domainCheckSkip
+		check.Detail = "static site served by nginx"
+		return check
+	}
+	port, _, err := utils.ResolveTargetPort(target, targetName, runner)
+	if err != nil {
+		check.Status = domainCheckFail
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("See why the app is not running with 'lightfold logs --target %s'", targetName)
+		return check
+	}
+	check.Status = domainCheckPass
+	check.Detail = fmt.Sprintf("the app listens on port %d", port)
+	return check
+}
+
+// domainHealthPath is the app's health check path under the target's path prefix
+func domainHealthPath(target config.TargetConfig, detection detector.Detection) string {
+	healthPath := "/"
+	if p, ok := detection.Healthcheck["path"].(string); ok && p != "" {
+		healthPath = p
+	}
+	return path.Join("/", target.Domain.PathPrefix, healthPath)
+}
+
+// domainHealthCheck requests the health check path through the domain
+func domainHealthCheck(scheme, domain, healthPath, targetName string) domainCheck {
+	check := domainCheck{Layer: "Health"}
+	url := fmt.Sprintf("%s://%s%s", scheme, domain, healthPath)
+	resp, err := domainProbeClient.Get(url)
+	if err != nil {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("%s did not answer: %v", url, err)
+		check.Fix = "Fix the first failing layer above"
+		return check
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("%s answered %d", url, resp.StatusCode)
+		check.Fix = fmt.Sprintf("The request reached the server; see 'lightfold logs --target %s' for the app's error", targetName)
+		if resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout {
+			check.Fix = fmt.Sprintf("nginx could not reach the app; see 'lightfold logs --target %s'", targetName)
+		}
+		return check
+	}
+	check.Status = domainCheckPass
+	check.Detail = fmt.Sprintf("%s answered %d", url, resp.StatusCode)
+	return check
+}
+
+func printDomainCheckReport(report domainCheckReport) {
+	fmt.Printf("\n%s\n", domainStyle.Render(fmt.Sprintf("Domain check: %s", report.Domain)))
+	fmt.Printf("  Target: %s\n\n", domainValueStyle.Render(report.Target))
+
+	for _, check := range report.Checks {
+		symbol, symbolStyle := style.Check(), domainSuccessStyle
+		switch check.Status {
+		case domainCheckWarn:
+			symbol, symbolStyle = style.Warn(), style.Warning
+		case domainCheckFail:
+			symbol, symbolStyle = style.Cross(), domainErrorStyle
+		case domainCheckSkip:
+			symbol, symbolStyle = style.Skipped(), domainMutedStyle
+		}
+
+		layer := fmt.Sprintf("%-12s", check.Layer)
+		detail := domainMutedStyle.Render(check.Detail)
+		if check.Layer == report.FirstFailure {
+			layer = domainErrorStyle.Render(layer)
+			detail = style.ErrorText.Render(check.Detail) + " " + domainErrorStyle.Render(style.Pointer()+" first failure")
+		}
+		fmt.Printf("  %s %s %s\n", symbolStyle.Render(symbol), layer, detail)
+		if check.Fix != "" && check.Status != domainCheckPass {
+			fmt.Printf("  %s %s\n", strings.Repeat(" ", lipgloss.Width(symbol)+12), domainValueStyle.Render("Fix: "+check.Fix))
+		}
+	}
+
+	fmt.Println()
+	if report.OK {
+		fmt.Printf("%s\n\n", domainSuccessStyle.Render(fmt.Sprintf("%s %s reaches the app", style.Check(), report.Domain)))
+	} else {
+		fmt.Printf("%s\n\n", domainErrorStyle.Render(fmt.Sprintf("%s %s fails at %s", style.Cross(), report.Domain, report.FirstFailure)))
+	}
+}
+
+func init() {
+	domainCmd.AddCommand(domainCheckCmd)
+	domainCheckCmd.Flags().StringVarP(&domainTargetFlag, "target", "t", "", "Target name")
+}
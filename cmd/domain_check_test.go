@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	sshpkg "lightfold/pkg/ssh"
+	"math/big"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDomainServer answers sudo commands by prefix
+type fakeDomainServer map[string]*sshpkg.CommandResult
+
+func (f fakeDomainServer) ExecuteSudo(command string) *sshpkg.CommandResult {
+	for prefix, result := range f {
+		if strings.HasPrefix(command, prefix) {
+			return result
+		}
+	}
+	return &sshpkg.CommandResult{ExitCode: 1}
+}
+
+func newTestCertificate(t *testing.T, notAfter time.Time, names ...string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestEvaluateCertificate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		staging    bool
+		wantStatus string
+		wantDetail string
+	}{
+		{"valid", newTestCertificate(t, now.AddDate(0, 2, 0), "example.com", "www.example.com"), false, domainCheckPass, "valid until 2025-08-01"},
+		{"wildcard", newTestCertificate(t, now.AddDate(0, 2, 0), "*.example.com"), false, domainCheckFail, "does not cover example.com"},
+		{"other domain", newTestCertificate(t, now.AddDate(0, 2, 0), "other.com"), false, domainCheckFail, "certificate for other.com does not cover"},
+		{"expired", newTestCertificate(t, now.AddDate(0, 0, -1), "example.com"), false, domainCheckFail, "expired on 2025-05-31"},
+		{"expiring", newTestCertificate(t, now.AddDate(0, 0, 5), "example.com"), false, domainCheckWarn, "(5 days)"},
+		{"staging", newTestCertificate(t, now.AddDate(0, 2, 0), "example.com"), true, domainCheckWarn, "browsers will not trust it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := evaluateCertificate(tt.cert, "example.com", "myapp", tt.staging, now)
+			if check.Status != tt.wantStatus || !strings.Contains(check.Detail, tt.wantDetail) {
+				t.Errorf("evaluateCertificate() = %s %q, want %s containing %q", check.Status, check.Detail, tt.wantStatus, tt.wantDetail)
+			}
+			if check.Status != domainCheckPass && check.Fix == "" {
+				t.Errorf("evaluateCertificate() = %s without a fix", check.Status)
+			}
+		})
+	}
+}
+
+func TestDomainNginxCheck(t *testing.T) {
+	server := fakeDomainServer{
+		"grep -lsE": {Stdout: "/etc/nginx/sites-enabled/myapp.conf\n"},
+		"nginx -t":  {Stderr: "nginx: configuration file /etc/nginx/nginx.conf test is successful"},
+	}
+	if check := domainNginxCheck(server, "example.com", "myapp"); check.Status != domainCheckPass {
+		t.Errorf("domainNginxCheck() = %+v, want pass", check)
+	}
+
+	server["nginx -t"] = &sshpkg.CommandResult{ExitCode: 1, Stderr: "\nnginx: [emerg] unknown directive \"proxy_passs\" in /etc/nginx/sites-enabled/myapp.conf:12\n"}
+	check := domainNginxCheck(server, "example.com", "myapp")
+	if check.Status != domainCheckFail || !strings.Contains(check.Detail, `unknown directive "proxy_passs"`) {
+		t.Errorf("domainNginxCheck() with a bad config = %+v", check)
+	}
+
+	delete(server, "grep -lsE")
+	check = domainNginxCheck(server, "example.com", "myapp")
+	if check.Status != domainCheckFail || !strings.Contains(check.Fix, "lightfold sync --target myapp --fix") {
+		t.Errorf("domainNginxCheck() without a site = %+v", check)
+	}
+}
+
+func TestServerNamePattern(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep not available")
+	}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"  server_name example.com;", true},
+		{"server_name www.example.com example.com;", true},
+		{"  server_name example.com www.example.com;", true},
+		{"  server_name www.example.com;", false},
+		{"  server_name exampleXcom;", false},
+		{"  server_name example.com.evil.net;", false},
+		{"  # server_name example.com;", false},
+		{"  proxy_set_header Host example.com;", false},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("grep", "-qE", "--", serverNamePattern("example.com"))
+		cmd.Stdin = strings.NewReader(tt.line + "\n")
+		if got := cmd.Run() == nil; got != tt.want {
+			t.Errorf("pattern matches %q = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDomainServerCheck(t *testing.T) {
+	server := fakeDomainServer{"curl": {Stdout: "000"}}
+	if check := domainServerCheck(server, "https", "example.com"); check.Status != domainCheckFail || !strings.Contains(check.Detail, "port 443") {
+		t.Errorf("domainServerCheck() = %+v, want a failure on port 443", check)
+	}
+	server["curl"] = &sshpkg.CommandResult{Stdout: "301"}
+	if check := domainServerCheck(server, "http", "example.com"); check.Status != domainCheckPass {
+		t.Errorf("domainServerCheck() = %+v, want pass", check)
+	}
+}
+
+func TestDomainDNSCheck(t *testing.T) {
+	original := lookupHost
+	defer func() { lookupHost = original }()
+
+	lookupHost = func(host string) ([]string, error) { return nil, errors.New("no such host") }
+	if check := domainDNSCheck("example.com", "203.0.113.10", ""); check.Status != domainCheckFail || !strings.Contains(check.Fix, "203.0.113.10") {
+		t.Errorf("unresolved domain = %+v", check)
+	}
+
+	lookupHost = func(host string) ([]string, error) { return []string{"203.0.113.10"}, nil }
+	if check := domainDNSCheck("example.com", "203.0.113.10", ""); check.Status != domainCheckPass {
+		t.Errorf("matching record = %+v, want pass", check)
+	}
+	if check := domainDNSCheck("example.com", "203.0.113.20", ""); check.Status != domainCheckFail {
+		t.Errorf("record for another server = %+v, want fail", check)
+	}
+}
+
+func TestLocalFailureFix(t *testing.T) {
+	pass := domainCheck{Status: domainCheckPass}
+	fail := domainCheck{Status: domainCheckFail}
+
+	if fix := localFailureFix(pass, fail, pass); !strings.Contains(fix, "firewall") {
+		t.Errorf("server answering itself: fix = %q, want a firewall hint", fix)
+	}
+	if fix := localFailureFix(fail, fail, pass); !strings.Contains(fix, "Fix DNS first") {
+		t.Errorf("DNS failing: fix = %q", fix)
+	}
+	if fix := localFailureFix(pass, fail, fail); strings.Contains(fix, "firewall") {
+		t.Errorf("server failing too: fix = %q, should not blame the firewall", fix)
+	}
+	if fix := localFailureFix(pass, pass, pass); fix != "" {
+		t.Errorf("local passing: fix = %q, want none", fix)
+	}
+}
+
+func TestFirstFailedCheck(t *testing.T) {
+	checks := []domainCheck{
+		{Layer: "DNS", Status: domainCheckWarn},
+		{Layer: "Local", Status: domainCheckPass},
+		{Layer: "Nginx", Status: domainCheckFail},
+		{Layer: "Health", Status: domainCheckFail},
+	}
+	if failed := firstFailedCheck(checks); failed == nil || failed.Layer != "Nginx" {
+		t.Errorf("firstFailedCheck() = %+v, want Nginx", failed)
+	}
+	if failed := firstFailedCheck(checks[:2]); failed != nil {
+		t.Errorf("firstFailedCheck() = %+v, want nil", failed)
+	}
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lookupHost resolves a domain; replaced in tests
+var lookupHost = net.LookupHost
+
+// domainServerIdentity returns the server ID and IP the target currently points at
+func domainServerIdentity(target config.TargetConfig, targetState *state.TargetState) (string, string) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return "", ""
+	}
+	serverID := providerCfg.GetServerID()
+	if serverID == "" && targetState != nil {
+		serverID = targetState.ProvisionedID
+	}
+	return serverID, providerCfg.GetIP()
+}
+
+// recordDomainApplied notes that the target's domain config now lives on its current server
+func recordDomainApplied(target config.TargetConfig, targetName string) {
+	targetState, _ := state.LoadState(targetName)
+	serverID, serverIP := domainServerIdentity(target, targetState)
+	if err := state.MarkDomainApplied(targetName, serverID, serverIP); err != nil {
+		fmt.Printf("Warning: failed to record domain state: %v\n", err)
+	}
+}
+
+// domainDriftReason explains why the domain config no longer matches the
+// target's server, or returns "" when it does. nginxPresent reports whether the
+// server's nginx config serves the domain.
+func domainDriftReason(target config.TargetConfig, targetState *state.TargetState, nginxPresent bool) string {
+	if target.Domain == nil || target.Domain.Domain == "" || target.Domain.PathPrefix != "" {
+		return ""
+	}
+
+	serverID, serverIP := domainServerIdentity(target, targetState)
+	if targetState.DomainDrifted(serverID, serverIP) {
+		return fmt.Sprintf("%s was configured on %s, but the server is now %s", target.Domain.Domain, targetState.DomainServerIP, serverIP)
+	}
+	if !nginxPresent {
+		return fmt.Sprintf("the server at %s has no nginx config for %s", serverIP, target.Domain.Domain)
+	}
+	return ""
+}
+
+// remoteServesDomain checks whether an enabled nginx site on the server names the domain
+func remoteServesDomain(sshExecutor *sshpkg.Executor, domain string) bool {
+	result := sshExecutor.Execute(fmt.Sprintf("grep -qs 'server_name.*%s' /etc/nginx/sites-enabled/* && echo 'true' || echo 'false'", domain))
+	return result.ExitCode == 0 && strings.TrimSpace(result.Stdout) == "true"
+}
+
+// domainServerAddresses returns the public IPv4 and IPv6 address of the target's server
+func domainServerAddresses(target config.TargetConfig) (string, string) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return "", ""
+	}
+	return config.PublicAddresses(providerCfg)
+}
+
+// checkDomainRecords compares the domain's A and AAAA records with the server's
+// addresses. ok is false when a record points elsewhere or no record reaches
+// the server, which makes certificate validation fail. A missing AAAA record
+// is reported but still ok.
+func checkDomainRecords(domain, ipv4, ipv6 string) (problems []string, ok bool) {
+	addresses, _ := lookupHost(domain)
+
+	var v4, v6 []string
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, address)
+		} else {
+			v6 = append(v6, address)
+		}
+	}
+
+	ok = true
+	reachable := false
+	check := func(recordType, family string, found []string, want string) {
+		switch {
+		case want == "" && len(found) > 0:
+			problems = append(problems, fmt.Sprintf("%s record for %s points to %s, but the server has no %s address; remove it",
+				recordType, domain, strings.Join(found, ", "), family))
+			ok = false
+		case want == "":
+		case len(found) == 0:
+			problems = append(problems, fmt.Sprintf("%s has no %s record; point one at %s", domain, recordType, want))
+		case !containsIP(found, want) || len(found) > 1:
+			problems = append(problems, fmt.Sprintf("%s record for %s resolves to %s, not %s", recordType, domain, strings.Join(found, ", "), want))
+			ok = false
+		default:
+			reachable = true
+		}
+	}
+	check("A", "IPv4", v4, ipv4)
+	check("AAAA", "IPv6", v6, ipv6)
+
+	return problems, ok && reachable
+}
+
+func containsIP(addresses []string, ip string) bool {
+	for _, address := range addresses {
+		if sameIP(address, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameIP compares addresses so differently written IPv6 literals match
+func sameIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	return ipA.Equal(ipB)
+}
+
+// handleDomainDrift warns that the domain config is not on the target's current
+// server and re-applies it when fix is set or the user agrees
+func handleDomainDrift(target *config.TargetConfig, targetName, reason string, fix bool) error {
+	warningStyle := style.Warning
+	mutedStyle := style.Muted
+
+	box := style.Box(style.ColorWarning).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			warningStyle.Render(fmt.Sprintf("%s Domain %s is not set up on the current server", style.Warn(), target.Domain.Domain)),
+			"",
+			mutedStyle.Render(reason),
+			mutedStyle.Render("Visitors may reach the old address or get certificate errors."),
+		))
+	fmt.Printf("\n%s\n", box)
+
+	if !fix {
+		if jsonOutput || skipInteractive || !isTerminal() {
+			fmt.Printf("%s\n", mutedStyle.Render(fmt.Sprintf("Run 'lightfold sync --target %s --fix' to reconfigure nginx and re-issue the certificate", targetName)))
+			return nil
+		}
+		fmt.Printf("Reconfigure nginx and re-issue the certificate now? (y/N): ")
+		response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(response)) != "y" {
+			return nil
+		}
+	}
+
+	return reapplyDomain(target, targetName)
+}
+
+// reapplyDomain re-runs the proxy and certificate setup for the target's domain
+// on its current server. Certificates are only requested once DNS points at
+// the server, since the ACME challenge would fail otherwise.
+func reapplyDomain(target *config.TargetConfig, targetName string) error {
+	domain := target.Domain.Domain
+	ipv4, ipv6 := domainServerAddresses(*target)
+
+	problems, ok := checkDomainRecords(domain, ipv4, ipv6)
+	if !ok && target.Domain.SSLEnabled {
+		return fmt.Errorf("DNS for %s is not ready: %s. Update the records, wait for them to propagate, then run 'lightfold sync --target %s --fix'",
+			domain, strings.Join(problems, "; "), targetName)
+	}
+	for _, problem := range problems {
+		fmt.Printf("Warning: %s\n", problem)
+	}
+
+	return configureDomainAndSSL(target, targetName, domain, target.Domain.SSLEnabled)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+func newDomainTarget(t *testing.T, ip string) config.TargetConfig {
+	t.Helper()
+	target := config.TargetConfig{
+		Provider: "digitalocean",
+		Domain:   &config.DomainConfig{Domain: "app.example.com", SSLEnabled: true},
+	}
+	if err := target.SetProviderConfig("digitalocean", &config.DigitalOceanConfig{
+		IP: ip, Username: "deploy", SSHKey: "~/.lightfold/keys/id", DropletID: "101", Provisioned: true,
+	}); err != nil {
+		t.Fatalf("SetProviderConfig() error: %v", err)
+	}
+	return target
+}
+
+func TestDomainDriftReason(t *testing.T) {
+	applied := &state.TargetState{DomainServerID: "101", DomainServerIP: "203.0.113.10"}
+
+	tests := []struct {
+		name         string
+		ip           string
+		state        *state.TargetState
+		nginxPresent bool
+		want         string
+	}{
+		{"in sync", "203.0.113.10", applied, true, ""},
+		{"IP changed", "203.0.113.20", applied, true, "was configured on 203.0.113.10"},
+		{"nginx config missing", "203.0.113.10", applied, false, "has no nginx config"},
+		{"not recorded yet", "203.0.113.10", &state.TargetState{}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := domainDriftReason(newDomainTarget(t, tt.ip), tt.state, tt.nginxPresent)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("domainDriftReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReapplyDomainWaitsForDNSBeforeIssuingCertificate(t *testing.T) {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) { return []string{"203.0.113.10"}, nil }
+	defer func() { lookupHost = original }()
+
+	target := newDomainTarget(t, "203.0.113.20")
+	err := reapplyDomain(&target, "myapp")
+	if err == nil || !strings.Contains(err.Error(), "resolves to 203.0.113.10, not 203.0.113.20") {
+		t.Errorf("reapplyDomain() error = %v, want a DNS mismatch error", err)
+	}
+}
+
+func TestCheckDomainRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		ipv4    string
+		ipv6    string
+		wantOK  bool
+		want    string
+	}{
+		{name: "A record matches", records: []string{"203.0.113.10"}, ipv4: "203.0.113.10", wantOK: true},
+		{name: "A and AAAA match", records: []string{"203.0.113.10", "2001:db8:0:0::1"}, ipv4: "203.0.113.10", ipv6: "2001:db8::1", wantOK: true},
+		{name: "missing AAAA is reported", records: []string{"203.0.113.10"}, ipv4: "203.0.113.10", ipv6: "2001:db8::1", wantOK: true, want: "no AAAA record; point one at 2001:db8::1"},
+		{name: "stale AAAA", records: []string{"203.0.113.10", "2001:db8::99"}, ipv4: "203.0.113.10", ipv6: "2001:db8::1", want: "AAAA record for example.com resolves to 2001:db8::99, not 2001:db8::1"},
+		{name: "AAAA without server IPv6", records: []string{"203.0.113.10", "2001:db8::99"}, ipv4: "203.0.113.10", want: "server has no IPv6 address"},
+		{name: "IPv6-only server", records: []string{"2001:db8::1"}, ipv6: "2001:db8::1", wantOK: true},
+		{name: "wrong A record", records: []string{"203.0.113.20"}, ipv4: "203.0.113.10", want: "resolves to 203.0.113.20, not 203.0.113.10"},
+		{name: "no records", ipv4: "203.0.113.10", want: "no A record"},
+	}
+
+	original := lookupHost
+	defer func() { lookupHost = original }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupHost = func(host string) ([]string, error) { return tt.records, nil }
+
+			problems, ok := checkDomainRecords("example.com", tt.ipv4, tt.ipv6)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v (problems: %v)", ok, tt.wantOK, problems)
+			}
+			joined := strings.Join(problems, "\n")
+			if tt.want == "" && len(problems) > 0 || !strings.Contains(joined, tt.want) {
+				t.Errorf("problems = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
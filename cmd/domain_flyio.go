@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers/flyio"
+	"lightfold/pkg/state"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// flyCertificateClient is the part of the fly.io client the domain commands use
+type flyCertificateClient interface {
+	AddCertificate(ctx context.Context, appName, hostname string) (*flyio.Certificate, error)
+	CheckCertificate(ctx context.Context, appName, hostname string) (*flyio.Certificate, error)
+	DeleteCertificate(ctx context.Context, appName, hostname string) error
+}
+
+var (
+	// newFlyCertificateClient builds the fly.io client; replaced in tests
+	newFlyCertificateClient = func(token string) flyCertificateClient { return flyio.NewClient(token) }
+
+	flyCertPollTimeout  = 2 * time.Minute
+	flyCertPollInterval = 10 * time.Second
+)
+
+// flyioDomainContext returns the certificate client and app name for a fly.io target
+func flyioDomainContext(target config.TargetConfig) (flyCertificateClient, string, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load tokens: %w", err)
+	}
+	token := tokens.GetToken("flyio")
+	if token == "" {
+		return nil, "", fmt.Errorf("fly.io API token not found. Run 'lightfold config set-token flyio' first")
+	}
+
+	flyioConfig, err := target.GetFlyioConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get fly.io config: %w", err)
+	}
+	if flyioConfig.AppName == "" {
+		return nil, "", fmt.Errorf("no fly.io app recorded for this target. Run 'lightfold create' first")
+	}
+
+	return newFlyCertificateClient(token), flyioConfig.AppName, nil
+}
+
+// addFlyioDomain attaches the domain to the target's fly.io app, shows the DNS
+// records fly.io expects and waits a short while for the certificate
+func addFlyioDomain(cfg *config.Config, target config.TargetConfig, targetName, domain string) error {
+	client, appName, err := flyioDomainContext(target)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cert, err := client.AddCertificate(ctx, appName, domain)
+	if err != nil {
+		return err
+	}
+
+	target.Domain = &config.DomainConfig{
+		Domain:     domain,
+		SSLEnabled: true,
+		SSLManager: "flyio",
+		ProxyType:  "flyio",
+	}
+	cfg.SetTarget(targetName, target)
+	if err := cfg.SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Printf("\n%s\n", domainStyle.Render("Domain Configuration"))
+	fmt.Printf("  Domain: %s\n", domainValueStyle.Render(domain))
+	fmt.Printf("  Target: %s\n", domainValueStyle.Render(targetName))
+	fmt.Printf("  App:    %s\n\n", domainValueStyle.Render(appName))
+
+	if !cert.Issued {
+		printFlyioDNSRecords(cert)
+		cert = waitForFlyioCertificate(ctx, client, appName, domain, cert)
+	}
+
+	fmt.Printf("\n%s %s\n", domainSuccessStyle.Render(style.Check()), domainMutedStyle.Render("Domain configuration saved"))
+	if !cert.Issued {
+		fmt.Printf("%s\n", domainMutedStyle.Render(fmt.Sprintf("Certificate status: %s. fly.io issues it once DNS is in place; check with 'lightfold domain show --target %s'", cert.Status, targetName)))
+		return nil
+	}
+
+	if targetState, err := state.GetTargetState(targetName); err == nil {
+		targetState.SSLConfigured = true
+		targetState.LastSSLRenewal = time.Now()
+		if err := state.SaveState(targetName, targetState); err != nil {
+			fmt.Printf("Warning: failed to update SSL state: %v\n", err)
+		}
+	}
+
+	fmt.Printf("%s\n", domainValueStyle.Render(fmt.Sprintf("Your app is available at: https://%s", domain)))
+	return nil
+}
+
+// waitForFlyioCertificate polls fly.io until the certificate is issued or the
+// poll timeout passes, returning the last status seen
+func waitForFlyioCertificate(ctx context.Context, client flyCertificateClient, appName, domain string, cert *flyio.Certificate) *flyio.Certificate {
+	fmt.Printf("\n%s\n", domainMutedStyle.Render("Waiting for fly.io to issue the certificate..."))
+
+	deadline := time.Now().Add(flyCertPollTimeout)
+	for !cert.Issued && time.Now().Before(deadline) {
+		time.Sleep(flyCertPollInterval)
+		latest, err := client.CheckCertificate(ctx, appName, domain)
+		if err != nil {
+			continue
+		}
+		cert = latest
+	}
+
+	if cert.Issued {
+		fmt.Printf("%s %s\n", domainSuccessStyle.Render(style.Check()), domainMutedStyle.Render("Certificate issued"))
+	}
+	return cert
+}
+
+// printFlyioDNSRecords shows the records fly.io needs, as reported by its API
+func printFlyioDNSRecords(cert *flyio.Certificate) {
+	fmt.Printf("%s\n\n", domainLabelStyle.Render("Add the following records to your domain registrar's DNS settings:"))
+
+	var lines []string
+	for _, record := range cert.Records {
+		lines = append(lines, fmt.Sprintf("  Type:  %s\n  Name:  %s\n  Value: %s", record.Type, record.Name, record.Value))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  fly.io did not report any records; see the app's certificates page")
+	}
+
+	dnsBoxStyle := style.Box(style.ColorHeader).
+		Padding(0, 1).
+		Foreground(lipgloss.Color(style.ColorMuted))
+	fmt.Printf("%s\n", dnsBoxStyle.Render(strings.Join(lines, "\n\n")))
+
+	for _, message := range cert.ValidationErrors {
+		fmt.Printf("%s\n", domainMutedStyle.Render("  ! "+message))
+	}
+	fmt.Printf("%s\n", domainMutedStyle.Render("DNS propagation typically takes 5-60 minutes."))
+}
+
+// showFlyioDomain prints the live certificate status of the target's domain
+func showFlyioDomain(target config.TargetConfig) {
+	client, appName, err := flyioDomainContext(target)
+	if err != nil {
+		fmt.Printf("  %s: %s\n", domainLabelStyle.Render("Certificate"), domainMutedStyle.Render(err.Error()))
+		return
+	}
+
+	cert, err := client.CheckCertificate(context.Background(), appName, target.Domain.Domain)
+	if err != nil {
+		fmt.Printf("  %s: %s\n", domainLabelStyle.Render("Certificate"), domainMutedStyle.Render(err.Error()))
+		return
+	}
+
+	fmt.Printf("  %s: %s\n", domainLabelStyle.Render("Certificate"), domainValueStyle.Render(cert.Status))
+	if !cert.ExpiresAt.IsZero() {
+		fmt.Printf("  %s:     %s\n", domainLabelStyle.Render("Expires"), domainValueStyle.Render(cert.ExpiresAt.Format("2006-01-02")))
+	}
+	if !cert.Issued {
+		fmt.Println()
+		printFlyioDNSRecords(cert)
+	}
+}
+
+// removeFlyioDomain deletes the domain's certificate and hostname from the fly.io app
+func removeFlyioDomain(target config.TargetConfig) error {
+	client, appName, err := flyioDomainContext(target)
+	if err != nil {
+		return err
+	}
+	return client.DeleteCertificate(context.Background(), appName, target.Domain.Domain)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers/flyio"
+	"testing"
+	"time"
+)
+
+// fakeFlyCerts issues the certificate after a number of checks
+type fakeFlyCerts struct {
+	checksUntilIssued int
+	checks            int
+	added             []string
+	deleted           []string
+}
+
+func (f *fakeFlyCerts) AddCertificate(ctx context.Context, appName, hostname string) (*flyio.Certificate, error) {
+	f.added = append(f.added, appName+"/"+hostname)
+	return f.status(hostname), nil
+}
+
+func (f *fakeFlyCerts) CheckCertificate(ctx context.Context, appName, hostname string) (*flyio.Certificate, error) {
+	f.checks++
+	return f.status(hostname), nil
+}
+
+func (f *fakeFlyCerts) DeleteCertificate(ctx context.Context, appName, hostname string) error {
+	f.deleted = append(f.deleted, appName+"/"+hostname)
+	return nil
+}
+
+func (f *fakeFlyCerts) status(hostname string) *flyio.Certificate {
+	if f.checks >= f.checksUntilIssued {
+		return &flyio.Certificate{Hostname: hostname, Status: "Ready", Issued: true}
+	}
+	return &flyio.Certificate{
+		Hostname: hostname,
+		Status:   "Awaiting configuration",
+		Records:  []flyio.DNSRecord{{Type: "CNAME", Name: hostname, Value: "myapp-123.fly.dev"}},
+	}
+}
+
+func setupFlyioDomainTest(t *testing.T, fake *fakeFlyCerts) (*config.Config, config.TargetConfig) {
+	t.Helper()
+
+	originalClient, originalTimeout, originalInterval := newFlyCertificateClient, flyCertPollTimeout, flyCertPollInterval
+	newFlyCertificateClient = func(token string) flyCertificateClient { return fake }
+	flyCertPollTimeout, flyCertPollInterval = 50*time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		newFlyCertificateClient, flyCertPollTimeout, flyCertPollInterval = originalClient, originalTimeout, originalInterval
+	})
+
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens() error: %v", err)
+	}
+	tokens.SetToken("flyio", "fly-token")
+	if err := tokens.SaveTokens(); err != nil {
+		t.Fatalf("SaveTokens() error: %v", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	target := config.TargetConfig{ProjectPath: "/home/me/myapp", Framework: "Next.js", Provider: "flyio"}
+	target.SetProviderConfig("flyio", &config.FlyioConfig{AppName: "myapp-123", Provisioned: true})
+	cfg.SetTarget("myapp", target)
+	return cfg, target
+}
+
+func TestAddFlyioDomainWaitsForCertificate(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	fake := &fakeFlyCerts{checksUntilIssued: 2}
+	cfg, target := setupFlyioDomainTest(t, fake)
+
+	if err := addFlyioDomain(cfg, target, "myapp", "app.example.com"); err != nil {
+		t.Fatalf("addFlyioDomain() error: %v", err)
+	}
+
+	if len(fake.added) != 1 || fake.added[0] != "myapp-123/app.example.com" {
+		t.Errorf("added certificates = %v, want app.example.com on myapp-123", fake.added)
+	}
+	if fake.checks != 2 {
+		t.Errorf("checks = %d, want polling until issued (2)", fake.checks)
+	}
+
+	reloaded, _ := config.LoadConfig()
+	saved, _ := reloaded.GetTarget("myapp")
+	if saved.Domain == nil || saved.Domain.Domain != "app.example.com" || saved.Domain.SSLManager != "flyio" || !saved.Domain.SSLEnabled {
+		t.Errorf("saved domain = %+v, want app.example.com managed by flyio", saved.Domain)
+	}
+}
+
+func TestAddFlyioDomainSavesPendingCertificate(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	fake := &fakeFlyCerts{checksUntilIssued: 1 << 30}
+	cfg, target := setupFlyioDomainTest(t, fake)
+
+	if err := addFlyioDomain(cfg, target, "myapp", "app.example.com"); err != nil {
+		t.Fatalf("addFlyioDomain() error: %v", err)
+	}
+
+	reloaded, _ := config.LoadConfig()
+	saved, _ := reloaded.GetTarget("myapp")
+	if saved.Domain == nil || saved.Domain.Domain != "app.example.com" {
+		t.Errorf("pending domain not saved: %+v", saved.Domain)
+	}
+}
+
+func TestRemoveFlyioDomainDeletesCertificate(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	fake := &fakeFlyCerts{}
+	_, target := setupFlyioDomainTest(t, fake)
+	target.Domain = &config.DomainConfig{Domain: "app.example.com", SSLEnabled: true, SSLManager: "flyio"}
+
+	if err := removeFlyioDomain(target); err != nil {
+		t.Fatalf("removeFlyioDomain() error: %v", err)
+	}
+	if len(fake.deleted) != 1 || fake.deleted[0] != "myapp-123/app.example.com" {
+		t.Errorf("deleted certificates = %v, want app.example.com on myapp-123", fake.deleted)
+	}
+}
+
+func TestFlyioDomainRequiresToken(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	target := config.TargetConfig{Provider: "flyio"}
+	target.SetProviderConfig("flyio", &config.FlyioConfig{AppName: "myapp-123"})
+
+	if _, _, err := flyioDomainContext(target); err == nil {
+		t.Error("expected an error without a fly.io token")
+	}
+}
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl/certbot"
+	"strings"
+)
+
+// domainPlanContext is how many unchanged lines are shown around a change
+const domainPlanContext = 2
+
+// domainPlanRunner runs the read-only commands a domain plan needs
+type domainPlanRunner interface {
+	utils.CommandRunner
+	sshpkg.SudoRunner
+}
+
+type domainFileAction string
+
+const (
+	domainFileCreate    domainFileAction = "create"
+	domainFileModify    domainFileAction = "modify"
+	domainFileUnchanged domainFileAction = "unchanged"
+	domainFileKeep      domainFileAction = "keep"
+)
+
+// domainPlanFile is a file on the server that domain add would write, or an
+// existing one it leaves in place that still affects the domain
+type domainPlanFile struct {
+	Path   string
+	Action domainFileAction
+	Note   string
+	Diff   []string // Against the current remote file, for modified files
+}
+
+// domainPlan is what `domain add --plan` shows, computed with the same proxy
+// config and certbot command the real domain add uses
+type domainPlan struct {
+	Target     string
+	Domain     string
+	Redirect   string // www or apex name answered with a 301 to Domain
+	AppName    string
+	Port       int
+	HTTPConfig string // Written first, before a certificate exists
+	SSLConfig  string // Rendered once the certificate is issued
+	Files      []domainPlanFile
+	Commands   []string
+	Warnings   []string
+}
+
+// buildDomainPlan renders the nginx configs domain add would write and
+// compares them with the server's current files. It only reads from the
+// server: a legacy app name is used but not adopted into the config.
+func buildDomainPlan(runner domainPlanRunner, target *config.TargetConfig, targetName, serverIP, domain string, staging bool) (*domainPlan, error) {
+	port, _, err := utils.ResolveTargetPort(target, targetName, runner)
+	if err != nil {
+		return nil, err
+	}
+
+	appName := utils.RemoteAppName(target, targetName)
+	if legacy, found := utils.DetectLegacyAppName(runner, target, targetName); found {
+		appName = legacy
+	}
+
+	nginxMgr := nginx.NewManager(nil)
+	certMgr := certbot.NewManager(nil)
+	certMgr.SetStaging(staging)
+
+	proxyConfig := domainProxyConfig(target, appName, serverIP, domain, port)
+	if proxyConfig.RedirectFrom != "" {
+		certMgr.SetAliases([]string{proxyConfig.RedirectFrom})
+	}
+	plan := &domainPlan{
+		Target:     targetName,
+		Domain:     domain,
+		Redirect:   proxyConfig.RedirectFrom,
+		AppName:    appName,
+		Port:       port,
+		HTTPConfig: nginxMgr.GenerateConfig(proxyConfig),
+	}
+
+	certPath, keyPath, _ := certMgr.GetCertificatePath(domain)
+	proxyConfig.SSLEnabled = true
+	proxyConfig.SSLCertPath = certPath
+	proxyConfig.SSLKeyPath = keyPath
+	plan.SSLConfig = nginxMgr.GenerateConfig(proxyConfig)
+
+	sitePath := nginxMgr.GetConfigPath(appName)
+	site := runner.ExecuteSudo("cat " + sitePath)
+	switch {
+	case site.ExitCode != 0:
+		plan.Files = append(plan.Files, domainPlanFile{Path: sitePath, Action: domainFileCreate, Note: "replaced by the HTTPS config once certbot issues the certificate"})
+	case site.Stdout == plan.HTTPConfig:
+		plan.Files = append(plan.Files, domainPlanFile{Path: sitePath, Action: domainFileUnchanged, Note: "replaced by the HTTPS config once certbot issues the certificate"})
+	default:
+		plan.Files = append(plan.Files,
+			domainPlanFile{Path: sitePath, Action: domainFileModify, Note: "replaced by the HTTPS config once certbot issues the certificate", Diff: diffLines(site.Stdout, plan.HTTPConfig)},
+			domainPlanFile{Path: sshpkg.BackupPath(sitePath), Action: domainFileModify, Note: "keeps the current version"},
+		)
+	}
+
+	enabledPath := nginxMgr.GetEnabledPath(appName)
+	if runner.ExecuteSudo("test -L "+enabledPath).ExitCode == 0 {
+		plan.Files = append(plan.Files, domainPlanFile{Path: enabledPath, Action: domainFileUnchanged, Note: "enables the site"})
+	} else {
+		plan.Files = append(plan.Files, domainPlanFile{Path: enabledPath, Action: domainFileCreate, Note: "symlink that enables the site"})
+	}
+
+	// The site deploys write (no .conf suffix) and the default site stay
+	// enabled next to the domain's server block
+	deploySite := fmt.Sprintf("/etc/nginx/sites-enabled/%s", appName)
+	if runner.ExecuteSudo("test -e "+deploySite).ExitCode == 0 {
+		plan.Files = append(plan.Files, domainPlanFile{Path: deploySite, Action: domainFileKeep, Note: "site written by deploys"})
+	}
+	if runner.ExecuteSudo("test -e /etc/nginx/sites-enabled/default").ExitCode == 0 {
+		plan.Files = append(plan.Files, domainPlanFile{Path: "/etc/nginx/sites-enabled/default", Action: domainFileKeep, Note: "removed by the next deploy"})
+	}
+
+	others := runner.ExecuteSudo(serverNameGrep(domain))
+	if others.ExitCode == 0 {
+		for _, path := range strings.Fields(others.Stdout) {
+			if path != enabledPath {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s already declares server_name %s; nginx ignores all but the first server block for a name", path, domain))
+			}
+		}
+	}
+
+	plan.Commands = append(plan.Commands, "nginx -t", "systemctl reload nginx")
+	if runner.Execute("which certbot").ExitCode != 0 {
+		plan.Commands = append(plan.Commands, "apt-get update && apt-get install -y certbot python3-certbot-nginx")
+	}
+	plan.Commands = append(plan.Commands,
+		certMgr.IssueCommand(domain, "noreply@"+domain),
+		"systemctl enable certbot.timer && systemctl start certbot.timer",
+		"nginx -t", "systemctl reload nginx",
+	)
+	if proxyConfig.HTTP3 {
+		plan.Commands = append(plan.Commands, "ufw allow 443/udp")
+	}
+	if target.Domain != nil {
+		if err := checkNginxProtocols(runner, target.Domain, targetName); err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+	}
+
+	return plan, nil
+}
+
+// diffLines compares two texts line by line. Removed and added lines are
+// prefixed with "-" and "+", with a few unchanged lines of context around
+// them prefixed with " "; longer unchanged runs are cut to "...".
+func diffLines(before, after string) []string {
+	a, b := splitLines(before), splitLines(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	keep := make([]bool, len(lines))
+	changed := false
+	for i, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		changed = true
+		for k := max(0, i-domainPlanContext); k <= min(len(lines)-1, i+domainPlanContext); k++ {
+			keep[k] = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var diff []string
+	for i, line := range lines {
+		if keep[i] {
+			diff = append(diff, line)
+		} else if i == 0 || keep[i-1] {
+			diff = append(diff, " ...")
+		}
+	}
+	return diff
+}
+
+func printDomainPlan(plan *domainPlan) {
+	fmt.Printf("\n%s\n", domainStyle.Render("Domain Plan"))
+	fmt.Printf("  Domain: %s\n", domainValueStyle.Render(plan.Domain))
+	if plan.Redirect != "" {
+		fmt.Printf("  Redirect: %s\n", domainValueStyle.Render(fmt.Sprintf("%s → %s (301)", plan.Redirect, plan.Domain)))
+	}
+	fmt.Printf("  Target: %s\n", domainValueStyle.Render(plan.Target))
+	fmt.Printf("  App:    %s (port %d)\n", domainValueStyle.Render(plan.AppName), plan.Port)
+	fmt.Printf("%s\n", domainMutedStyle.Render("Nothing has been changed on the server."))
+
+	fmt.Printf("\n%s\n", domainLabelStyle.Render("Files"))
+	for _, file := range plan.Files {
+		fmt.Printf("  %-9s %s %s\n", file.Action, file.Path, domainMutedStyle.Render("- "+file.Note))
+		for _, line := range file.Diff {
+			switch line[0] {
+			case '+':
+				fmt.Printf("      %s\n", style.Success.Render(line))
+			case '-':
+				fmt.Printf("      %s\n", style.ErrorText.Render(line))
+			default:
+				fmt.Printf("      %s\n", domainMutedStyle.Render(line))
+			}
+		}
+	}
+
+	fmt.Printf("\n%s\n", domainLabelStyle.Render("Commands"))
+	for _, command := range plan.Commands {
+		fmt.Printf("  %s %s\n", domainMutedStyle.Render("$"), command)
+	}
+	fmt.Printf("%s\n", domainMutedStyle.Render("An existing valid certificate for the domain is installed with 'certbot install --nginx' instead of being reissued."))
+
+	fmt.Printf("\n%s\n", domainLabelStyle.Render("nginx config (HTTP only, written first)"))
+	fmt.Printf("%s\n", indentLines(plan.HTTPConfig))
+	fmt.Printf("%s\n", domainLabelStyle.Render("nginx config once the certificate is issued"))
+	fmt.Printf("%s\n", indentLines(plan.SSLConfig))
+
+	if len(plan.Warnings) > 0 {
+		for _, warning := range plan.Warnings {
+			fmt.Printf("%s %s\n", domainErrorStyle.Render(style.Warn()), domainMutedStyle.Render(warning))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%s\n\n", domainMutedStyle.Render("Answering 'n' to 'Enable SSL?' writes only the HTTP config and skips certbot."))
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func indentLines(text string) string {
+	lines := splitLines(text)
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "    " + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakePlanServer answers plain and sudo commands by prefix
+type fakePlanServer struct {
+	fakeDomainServer
+}
+
+func (f fakePlanServer) Execute(command string) *sshpkg.CommandResult {
+	return f.ExecuteSudo(command)
+}
+
+func TestDiffLines(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	after := "a\nb\nc\nd\nE\nf\ng\nh\n"
+	want := []string{" ...", " c", " d", "-e", "+E", " f", " g", " ..."}
+	if got := diffLines(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines() = %q, want %q", got, want)
+	}
+
+	if got := diffLines("a\nb\n", "a\nb\n"); got != nil {
+		t.Errorf("diffLines() of equal texts = %q, want nil", got)
+	}
+	if got := diffLines("", "a\n"); !reflect.DeepEqual(got, []string{"+a"}) {
+		t.Errorf("diffLines() from empty = %q", got)
+	}
+}
+
+func TestBuildDomainPlan(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	target := &config.TargetConfig{Port: 3000, AppName: "web"}
+	server := fakePlanServer{fakeDomainServer{
+		"ss -tlnH": {Stdout: "LISTEN 0 511 127.0.0.1:3000 0.0.0.0:*\n"},
+		"cat /etc/nginx/sites-available/web.conf":  {Stdout: "server {\n  listen 80;\n  server_name _;\n}\n"},
+		"test -e /etc/nginx/sites-enabled/default": {},
+		serverNameGrep("example.com"):              {Stdout: "/etc/nginx/sites-enabled/blog.conf\n"},
+		"which certbot":                            {Stdout: "/usr/bin/certbot\n"},
+	}}
+
+	plan, err := buildDomainPlan(server, target, "web-prod", "203.0.113.10", "example.com", true)
+	if err != nil {
+		t.Fatalf("buildDomainPlan() error = %v", err)
+	}
+
+	if !strings.Contains(plan.HTTPConfig, "server_name example.com;") || strings.Contains(plan.HTTPConfig, "ssl_certificate") {
+		t.Errorf("HTTP config:\n%s", plan.HTTPConfig)
+	}
+	if !strings.Contains(plan.SSLConfig, "ssl_certificate /etc/letsencrypt/live/example.com/fullchain.pem;") {
+		t.Errorf("SSL config:\n%s", plan.SSLConfig)
+	}
+
+	actions := map[string]domainFileAction{}
+	for _, file := range plan.Files {
+		actions[file.Path] = file.Action
+	}
+	want := map[string]domainFileAction{
+		"/etc/nginx/sites-available/web.conf":                    domainFileModify,
+		sshpkg.BackupPath("/etc/nginx/sites-available/web.conf"): domainFileModify,
+		"/etc/nginx/sites-enabled/web.conf":                      domainFileCreate,
+		"/etc/nginx/sites-enabled/default":                       domainFileKeep,
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("file actions = %v, want %v", actions, want)
+	}
+	if diff := plan.Files[0].Diff; len(diff) == 0 || !containsLine(diff, "-  server_name _;") || !containsLine(diff, "+  server_name example.com;") {
+		t.Errorf("diff = %q", diff)
+	}
+
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "blog.conf") {
+		t.Errorf("warnings = %v, want the conflicting blog site", plan.Warnings)
+	}
+	if !containsLine(plan.Commands, "certbot --nginx -d example.com --non-interactive --agree-tos --email noreply@example.com --test-cert --break-my-certs") {
+		t.Errorf("commands = %v, want the staging certbot command", plan.Commands)
+	}
+	for _, command := range plan.Commands {
+		if strings.Contains(command, "apt-get") {
+			t.Errorf("commands = %v, certbot is already installed", plan.Commands)
+		}
+	}
+}
+
+func TestBuildDomainPlanHTTP3(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	target := &config.TargetConfig{Port: 3000, AppName: "web", Domain: &config.DomainConfig{Domain: "example.com", HTTP3: true}}
+	server := fakePlanServer{fakeDomainServer{
+		"ss -tlnH":      {Stdout: "LISTEN 0 511 127.0.0.1:3000 0.0.0.0:*\n"},
+		"which certbot": {Stdout: "/usr/bin/certbot\n"},
+		"nginx -V":      {Stdout: "nginx version: nginx/1.24.0 (Ubuntu)\nconfigure arguments: --with-http_ssl_module --with-http_v2_module\n"},
+	}}
+
+	plan, err := buildDomainPlan(server, target, "web", "203.0.113.10", "example.com", false)
+	if err != nil {
+		t.Fatalf("buildDomainPlan() error = %v", err)
+	}
+	if !strings.Contains(plan.SSLConfig, "listen 443 quic;") || strings.Contains(plan.HTTPConfig, "quic") {
+		t.Errorf("only the SSL config should listen for QUIC:\n%s\n%s", plan.HTTPConfig, plan.SSLConfig)
+	}
+	if !containsLine(plan.Commands, "ufw allow 443/udp") {
+		t.Errorf("commands = %v, want UDP 443 opened", plan.Commands)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "cannot serve HTTP/3") {
+		t.Errorf("warnings = %v, want the missing http_v3 module", plan.Warnings)
+	}
+}
+
+func TestBuildDomainPlanNothingListening(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := fakePlanServer{fakeDomainServer{"ss -tlnH": {Stdout: ""}}}
+	if _, err := buildDomainPlan(server, &config.TargetConfig{Port: 3000}, "web", "203.0.113.10", "example.com", false); err == nil {
+		t.Error("buildDomainPlan() succeeded with nothing listening on the port")
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
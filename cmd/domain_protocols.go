@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// applyDomainProtocolFlags saves --http2 and --http3 on the domain when they
+// were given, so a later domain add without them keeps the earlier choice
+func applyDomainProtocolFlags(cmd *cobra.Command, domain *config.DomainConfig) {
+	if cmd.Flags().Changed("http2") {
+		domain.HTTP2 = nil
+		if !domainHTTP2Flag {
+			off := false
+			domain.HTTP2 = &off
+		}
+	}
+	if cmd.Flags().Changed("http3") {
+		domain.HTTP3 = domainHTTP3Flag
+	}
+}
+
+// domainProtocols returns the proxy config's protocol settings for a domain
+func domainProtocols(domain *config.DomainConfig) (disableHTTP2, http3 bool) {
+	return !domain.HTTP2Enabled(), domain.HTTP3
+}
+
+// domainProtocolNames lists the HTTP versions an HTTPS domain is served over
+func domainProtocolNames(domain *config.DomainConfig) string {
+	names := []string{"HTTP/1.1"}
+	if domain.HTTP2Enabled() {
+		names = append(names, "HTTP/2")
+	}
+	if domain.HTTP3 {
+		names = append(names, "HTTP/3")
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkNginxProtocols refuses HTTP/2 or HTTP/3 settings the server's nginx
+// was not built for, before anything is written. When nginx -V cannot be run
+// the check is left to nginx -t.
+func checkNginxProtocols(runner sshpkg.CommandRunner, domain *config.DomainConfig, targetName string) error {
+	info, err := nginx.DetectBuild(runner)
+	if err != nil {
+		return nil
+	}
+	var protocols proxy.ProxyConfig
+	protocols.DisableHTTP2, protocols.HTTP3 = domainProtocols(domain)
+	return info.CheckProtocols(protocols, targetName)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var domainRedirectWWWFlag bool
+
+// wwwCounterpart returns the name a www redirect pairs with domain: the www
+// name of an apex domain, or the apex of a www name. ok is false for other
+// subdomains, which have no such counterpart.
+func wwwCounterpart(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	apex, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", false
+	}
+	switch domain {
+	case apex:
+		return "www." + apex, true
+	case "www." + apex:
+		return apex, true
+	}
+	return "", false
+}
+
+// validateRedirectWWW rejects --redirect-www for a domain without a www or
+// apex counterpart, before anything is changed
+func validateRedirectWWW(domain string) error {
+	if !domainRedirectWWWFlag {
+		return nil
+	}
+	if _, ok := wwwCounterpart(domain); !ok {
+		return fmt.Errorf("--redirect-www needs an apex domain such as example.com, or its www name")
+	}
+	return nil
+}
+
+// resolveWWWRedirect decides which name domain add redirects to domain:
+// the counterpart when --redirect-www was given (or "" for
+// --redirect-www=false), the target's earlier choice when the domain is
+// unchanged, and otherwise the answer to a question asked for apex domains
+// when ask is set
+func resolveWWWRedirect(flagSet bool, target config.TargetConfig, domain string, ask bool) string {
+	counterpart, ok := wwwCounterpart(domain)
+	if flagSet {
+		if !domainRedirectWWWFlag || !ok {
+			return ""
+		}
+		return counterpart
+	}
+	if target.Domain != nil && target.Domain.Domain == domain {
+		return target.Domain.RedirectFrom
+	}
+	if !ask || !ok || !strings.HasPrefix(counterpart, "www.") {
+		return ""
+	}
+
+	fmt.Printf("Redirect %s to %s? (y/N): ", counterpart, domain)
+	var response string
+	fmt.Scanln(&response)
+	if response = strings.ToLower(strings.TrimSpace(response)); response == "y" || response == "yes" {
+		return counterpart
+	}
+	return ""
+}
+
+// domainRedirectCheck requests the redirected name from the server itself
+// and expects a 301 to the same URL on the domain
+func domainRedirectCheck(runner sshpkg.SudoRunner, scheme, from, to, targetName string) domainCheck {
+	check := domainCheck{Layer: "Redirect"}
+	port := 80
+	if scheme == "https" {
+		port = 443
+	}
+	result := runner.ExecuteSudo(fmt.Sprintf("curl -sk -o /dev/null -w '%%{http_code} %%{redirect_url}' --max-time %d --resolve %s:%d:127.0.0.1 %s://%s/",
+		int(domainProbeTimeout.Seconds()), from, port, scheme, from))
+	fields := strings.Fields(result.Stdout)
+	want := fmt.Sprintf("%s://%s/", scheme, to)
+	fix := fmt.Sprintf("Run 'lightfold domain add --target %s --domain %s --redirect-www' to recreate the redirect", targetName, to)
+
+	switch {
+	case result.Error != nil || len(fields) == 0 || fields[0] == "000":
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("nothing answers for %s on port %d on the server", from, port)
+		check.Fix = fix
+	case fields[0] != "301" || len(fields) < 2 || fields[1] != want:
+		location := "no Location"
+		if len(fields) > 1 {
+			location = fields[1]
+		}
+		check.Status = domainCheckFail
+		check.Detail = fmt.Sprintf("%s://%s/ answered %s (%s), want 301 to %s", scheme, from, fields[0], location, want)
+		check.Fix = fix
+	default:
+		check.Status = domainCheckPass
+		check.Detail = fmt.Sprintf("%s://%s/ redirects to %s", scheme, from, want)
+	}
+	return check
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"strings"
+	"testing"
+)
+
+func TestWWWCounterpart(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{"example.com", "www.example.com", true},
+		{"www.example.com", "example.com", true},
+		{"Example.COM", "www.example.com", true},
+		{"example.co.uk", "www.example.co.uk", true},
+		{"www.example.co.uk", "example.co.uk", true},
+		{"app.example.com", "", false},
+		{"www.app.example.com", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := wwwCounterpart(tt.domain)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("wwwCounterpart(%q) = %q, %v, want %q, %v", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolveWWWRedirect(t *testing.T) {
+	defer func() { domainRedirectWWWFlag = false }()
+	previous := config.TargetConfig{Domain: &config.DomainConfig{Domain: "example.com", RedirectFrom: "www.example.com"}}
+
+	domainRedirectWWWFlag = true
+	if got := resolveWWWRedirect(true, config.TargetConfig{}, "www.example.com", false); got != "example.com" {
+		t.Errorf("--redirect-www on a www domain = %q, want example.com", got)
+	}
+
+	domainRedirectWWWFlag = false
+	if got := resolveWWWRedirect(true, previous, "example.com", false); got != "" {
+		t.Errorf("--redirect-www=false = %q, want no redirect", got)
+	}
+	if got := resolveWWWRedirect(false, previous, "example.com", false); got != "www.example.com" {
+		t.Errorf("re-adding the domain = %q, want the saved redirect kept", got)
+	}
+	if got := resolveWWWRedirect(false, previous, "example.org", false); got != "" {
+		t.Errorf("a new domain without the flag = %q, want no redirect", got)
+	}
+}
+
+func TestDomainRedirectCheck(t *testing.T) {
+	curl := "curl -sk -o /dev/null -w '%{http_code} %{redirect_url}'"
+	tests := []struct {
+		name       string
+		stdout     string
+		wantStatus string
+		wantDetail string
+	}{
+		{"redirects", "301 https://example.com/", domainCheckPass, "redirects to https://example.com/"},
+		{"served instead of redirected", "200 ", domainCheckFail, "answered 200 (no Location)"},
+		{"redirects elsewhere", "301 https://www.example.com/", domainCheckFail, "want 301 to https://example.com/"},
+		{"nothing answers", "000 ", domainCheckFail, "nothing answers for www.example.com on port 443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakeDomainServer{curl: {Stdout: tt.stdout}}
+			check := domainRedirectCheck(server, "https", "www.example.com", "example.com", "web")
+			if check.Status != tt.wantStatus || !strings.Contains(check.Detail, tt.wantDetail) {
+				t.Errorf("domainRedirectCheck() = %+v", check)
+			}
+		})
+	}
+
+	server := fakeDomainServer{curl: &sshpkg.CommandResult{Stdout: "301 http://example.com/"}}
+	if check := domainRedirectCheck(server, "http", "www.example.com", "example.com", "web"); check.Status != domainCheckPass {
+		t.Errorf("HTTP redirect check = %+v", check)
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl/certbot"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var domainVerifyRenewalCmd = &cobra.Command{
+	Use:   "verify-renewal [path]",
+	Short: "Check that the domain's certificate renews",
+	Long: `Check that certbot renewal is scheduled on the server, through the
+certbot systemd timer or a cron job, and run 'certbot renew --dry-run' to
+prove renewal would succeed. When nothing is scheduled, the timer is enabled
+or a cron job installed in ` + certbot.CronRenewalFile + `.
+
+The result is saved, and 'lightfold status' flags targets whose certificate
+expires within 14 days while verification fails.
+
+Examples:
+  lightfold domain verify-renewal                 # Current directory
+  lightfold domain verify-renewal --target myapp  # Named target`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, domainTargetFlag, pathArg)
+
+		if target.Domain == nil || target.Domain.Domain == "" || !target.Domain.SSLEnabled {
+			fmt.Fprintf(os.Stderr, "Error: target '%s' has no domain with SSL; add one with 'lightfold domain add --target %s --domain example.com'\n", targetName, targetName)
+			exit(1)
+		}
+		if target.Domain.SSLManager == "flyio" {
+			fmt.Fprintf(os.Stderr, "Error: fly.io renews the certificate for %s itself\n", target.Domain.Domain)
+			exit(1)
+		}
+
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		defer sshExecutor.Disconnect()
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to connect to server: %v\n", err)
+			exit(1)
+		}
+
+		fmt.Printf("\n%s\n", domainStyle.Render(fmt.Sprintf("Certificate renewal: %s", target.Domain.Domain)))
+		fmt.Printf("  Target: %s\n\n", domainValueStyle.Render(targetName))
+
+		renewal, err := checkRenewal(sshExecutor, target.Domain.Domain, true, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		recordRenewal(targetName, renewal)
+		printRenewal(renewal, targetName)
+		fmt.Println()
+		if renewal.Failed() {
+			exit(1)
+		}
+	},
+}
+
+// checkRenewal verifies how certificate renewal is scheduled on the server
+// and when certName expires. fix enables the timer or installs the cron
+// fallback when nothing renews; dryRun also runs 'certbot renew --dry-run'.
+// The error reports a server that could not be checked at all.
+func checkRenewal(runner sshpkg.SudoRunner, certName string, fix, dryRun bool) (state.SSLRenewal, error) {
+	renewal := state.SSLRenewal{CheckedAt: time.Now().UTC()}
+	schedule, err := certbot.ProbeRenewal(runner, certName)
+	if err != nil {
+		return renewal, err
+	}
+	renewal.CertExpiry = schedule.CertExpiry
+
+	if !schedule.Scheduled() && fix {
+		if fixed, err := certbot.EnsureRenewal(runner); err != nil {
+			renewal.Error = err.Error()
+		} else {
+			schedule.Method, schedule.NextRun = fixed.Method, fixed.NextRun
+		}
+	}
+	renewal.Method, renewal.NextRun = schedule.Method, schedule.NextRun
+	if !schedule.Scheduled() && renewal.Error == "" {
+		renewal.Error = "neither certbot.timer nor a cron job renews certificates"
+	}
+
+	if dryRun {
+		renewal.DryRun = state.ProbePassed
+		if err := certbot.RenewDryRun(runner); err != nil {
+			renewal.DryRun = state.ProbeFailed
+			renewal.Error = err.Error()
+		}
+	}
+	return renewal, nil
+}
+
+// recordRenewal saves a renewal check for status and domain show
+func recordRenewal(targetName string, renewal state.SSLRenewal) {
+	if err := state.RecordSSLRenewal(targetName, renewal); err != nil {
+		fmt.Printf("Warning: failed to record renewal check: %v\n", err)
+	}
+}
+
+// renewalMethod describes how renewal is scheduled, e.g.
+// "certbot.timer, next run Fri 2026-10-17 05:43:12 UTC"
+func renewalMethod(renewal state.SSLRenewal) string {
+	switch {
+	case renewal.Method == "":
+		return "not scheduled"
+	case renewal.NextRun != "":
+		return renewal.Method + ", next run " + renewal.NextRun
+	}
+	return renewal.Method
+}
+
+// printRenewal shows a renewal check with the fix when it failed
+func printRenewal(renewal state.SSLRenewal, targetName string) {
+	successStyle := style.Success
+	mutedStyle := style.Muted
+
+	if renewal.Method != "" {
+		fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("Certificate renewal scheduled ("+renewalMethod(renewal)+")"))
+	} else {
+		message := "Certificate renewal is NOT scheduled"
+		if renewal.DryRun != state.ProbeFailed {
+			message += ": " + renewal.Error
+		}
+		fmt.Printf("%s %s\n", domainErrorStyle.Render(style.Cross()), style.ErrorText.Render(message))
+	}
+	switch renewal.DryRun {
+	case state.ProbePassed:
+		fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render("certbot renew --dry-run succeeded"))
+	case state.ProbeFailed:
+		fmt.Printf("%s %s\n", domainErrorStyle.Render(style.Cross()), style.ErrorText.Render(renewal.Error))
+	}
+	if !renewal.CertExpiry.IsZero() {
+		fmt.Printf("  %s\n", mutedStyle.Render("Certificate expires "+renewal.CertExpiry.Format("2006-01-02")))
+	}
+	if renewal.Failed() {
+		fmt.Printf("  %s\n", domainValueStyle.Render(fmt.Sprintf("Fix: check 'systemctl status certbot.timer' on the server, then run 'lightfold domain verify-renewal --target %s'", targetName)))
+	}
+}
+
+// showRenewal adds the renewal schedule to domain show: read from the server
+// when it answers, otherwise the last recorded check
+func showRenewal(target config.TargetConfig, targetName string) {
+	renewal, live := liveRenewal(target)
+	if !live {
+		targetState, err := state.GetTargetState(targetName)
+		if err != nil || targetState.SSLRenewal == nil {
+			return
+		}
+		renewal = *targetState.SSLRenewal
+	}
+
+	value := domainValueStyle.Render(renewalMethod(renewal))
+	if renewal.Failed() {
+		value = domainErrorStyle.Render(style.Cross() + " " + renewalMethod(renewal))
+	}
+	if !live {
+		value += " " + domainMutedStyle.Render("(checked "+timefmt.Human(renewal.CheckedAt)+")")
+	}
+	fmt.Printf("  %s:    %s\n", domainLabelStyle.Render("Renewal"), value)
+	if renewal.DryRun == state.ProbeFailed {
+		fmt.Printf("  %s\n", domainErrorStyle.Render(renewal.Error))
+	}
+	if !renewal.CertExpiry.IsZero() {
+		fmt.Printf("  %s:    %s\n", domainLabelStyle.Render("Expires"), domainValueStyle.Render(renewal.CertExpiry.Format("2006-01-02")))
+	}
+}
+
+// liveRenewal reads the renewal schedule from the target's server without
+// changing anything
+func liveRenewal(target config.TargetConfig) (state.SSLRenewal, bool) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return state.SSLRenewal{}, false
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+	if result := sshExecutor.Execute("true"); result.Error != nil {
+		return state.SSLRenewal{}, false
+	}
+
+	renewal, err := checkRenewal(sshExecutor, target.Domain.Domain, false, false)
+	return renewal, err == nil
+}
+
+// sslRenewalRisk describes a certificate that expires within
+// certExpiryWarning while its renewal verification failed, or returns ""
+func sslRenewalRisk(renewal *state.SSLRenewal, now time.Time) string {
+	if !renewal.Failed() || renewal.CertExpiry.IsZero() {
+		return ""
+	}
+	remaining := renewal.CertExpiry.Sub(now)
+	expiry := renewal.CertExpiry.Format("2006-01-02")
+	switch {
+	case remaining <= 0:
+		return fmt.Sprintf("certificate expired on %s and renewal verification failed", expiry)
+	case remaining < certExpiryWarning:
+		return fmt.Sprintf("certificate expires on %s (%d days) and renewal verification failed", expiry, int(remaining.Hours()/24))
+	}
+	return ""
+}
+
+func init() {
+	domainCmd.AddCommand(domainVerifyRenewalCmd)
+	domainVerifyRenewalCmd.Flags().StringVarP(&domainTargetFlag, "target", "t", "", "Target name")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl/certbot"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSLRenewalRisk(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	failed := &state.SSLRenewal{Method: certbot.RenewalTimer, DryRun: state.ProbeFailed, CertExpiry: now.Add(5 * 24 * time.Hour)}
+	if risk := sslRenewalRisk(failed, now); !strings.Contains(risk, "2026-10-21 (5 days)") {
+		t.Errorf("risk = %q, want expiry in 5 days", risk)
+	}
+
+	unscheduled := &state.SSLRenewal{CertExpiry: now.Add(-time.Hour)}
+	if risk := sslRenewalRisk(unscheduled, now); !strings.Contains(risk, "expired") {
+		t.Errorf("risk for an expired certificate = %q", risk)
+	}
+
+	for name, renewal := range map[string]*state.SSLRenewal{
+		"never checked":    nil,
+		"verified":         {Method: certbot.RenewalTimer, DryRun: state.ProbePassed, CertExpiry: now.Add(24 * time.Hour)},
+		"far from expiry":  {DryRun: state.ProbeFailed, CertExpiry: now.Add(60 * 24 * time.Hour)},
+		"expiry not known": {DryRun: state.ProbeFailed},
+	} {
+		if risk := sslRenewalRisk(renewal, now); risk != "" {
+			t.Errorf("%s: risk = %q, want none", name, risk)
+		}
+	}
+}
+
+func TestCheckRenewal(t *testing.T) {
+	server := fakeDomainServer{
+		`echo "timer=`:            {Stdout: "timer=active\nnext=Fri 2026-10-17 05:43:12 UTC 9h left\nexpiry=Jan  5 10:20:30 2027 GMT"},
+		"certbot renew --dry-run": {ExitCode: 1, Stdout: "Challenge failed for domain example.com"},
+	}
+	renewal, err := checkRenewal(server, "example.com", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewal.Method != certbot.RenewalTimer || renewal.NextRun != "Fri 2026-10-17 05:43:12 UTC" {
+		t.Errorf("schedule = %q, %q", renewal.Method, renewal.NextRun)
+	}
+	if renewal.CertExpiry.IsZero() {
+		t.Error("certificate expiry not read")
+	}
+	if renewal.DryRun != state.ProbeFailed || !strings.Contains(renewal.Error, "Challenge failed") || !renewal.Failed() {
+		t.Errorf("dry run = %q, %q; want a failure", renewal.DryRun, renewal.Error)
+	}
+}
+
+func TestCheckRenewal_NotScheduled(t *testing.T) {
+	server := fakeDomainServer{`echo "timer=`: {Stdout: "timer=inactive"}}
+	renewal, err := checkRenewal(server, "example.com", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewal.Failed() || renewal.Error == "" || renewal.DryRun != "" {
+		t.Errorf("renewal = %+v, want an unscheduled failure without a dry run", renewal)
+	}
+
+	if _, err := checkRenewal(fakeDomainServer{`echo "timer=`: {Error: sshpkg.ErrNoPrivilege}}, "example.com", false, false); err == nil {
+		t.Error("checkRenewal() on an unreachable server returned no error")
+	}
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/proxy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl"
+	"lightfold/pkg/state"
+	"strings"
+	"time"
+)
+
+// reservedPathPrefixes are already served by the owning app's nginx config
+var reservedPathPrefixes = []string{"/static", "/media"}
+
+// normalizePathPrefix validates a path prefix and returns it with a leading
+// slash and without a trailing slash
+func normalizePathPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	prefix = strings.TrimRight(prefix, "/")
+
+	if prefix == "" {
+		return "", fmt.Errorf("path prefix cannot be '/'; add the domain without --path to serve the root")
+	}
+	if strings.Contains(prefix, "//") || strings.Contains(prefix, "..") {
+		return "", fmt.Errorf("invalid path prefix: %s", prefix)
+	}
+	for _, char := range prefix {
+		if !((char >= 'a' && char <= 'z') ||
+			(char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9') ||
+			char == '/' || char == '-' || char == '_' || char == '.') {
+			return "", fmt.Errorf("invalid character %q in path prefix %s", char, prefix)
+		}
+	}
+	for _, reserved := range reservedPathPrefixes {
+		if prefix == reserved || strings.HasPrefix(prefix, reserved+"/") {
+			return "", fmt.Errorf("path prefix %s is reserved for the domain owner's %s/ files", prefix, reserved)
+		}
+	}
+
+	return prefix, nil
+}
+
+// findDomainOwner returns the target that serves the root of a domain
+func findDomainOwner(cfg *config.Config, domain string) (config.TargetConfig, string, bool) {
+	for name, target := range cfg.Targets {
+		if target.Domain != nil && target.Domain.Domain == domain && target.Domain.PathPrefix == "" {
+			return target, name, true
+		}
+	}
+	return config.TargetConfig{}, "", false
+}
+
+// resolveRoutePort returns the port a target's service listens on, saving a
+// port discovered on the server so the target config matches what is running
+func resolveRoutePort(cfg *config.Config, target *config.TargetConfig, targetName string, sshExecutor *sshpkg.Executor) (int, error) {
+	port, discovered, err := utils.ResolveTargetPort(target, targetName, sshExecutor)
+	if err != nil {
+		return 0, err
+	}
+
+	if discovered {
+		target.Port = port
+		if err := cfg.SetTarget(targetName, *target); err != nil {
+			return 0, fmt.Errorf("failed to update target config: %w", err)
+		}
+		if err := cfg.SaveConfig(); err != nil {
+			fmt.Printf("Warning: failed to save discovered port for '%s': %v\n", targetName, err)
+		}
+	}
+
+	return port, nil
+}
+
+// proxyPathRoutes returns the registered path routes for a domain in proxy form
+func proxyPathRoutes(serverIP, domain string) []proxy.PathRoute {
+	routes, err := deploy.PathRoutesFor(serverIP, domain)
+	if err != nil {
+		fmt.Printf("Warning: failed to load path routes for %s: %v\n", domain, err)
+		return nil
+	}
+	return routes
+}
+
+// reconfigureDomainOwner regenerates the owning app's nginx config with the path
+// routes currently registered for its domain. Only the extra location blocks change.
+func reconfigureDomainOwner(cfg *config.Config, owner *config.TargetConfig, ownerName string, sshExecutor *sshpkg.Executor) error {
+	providerCfg, err := owner.GetSSHProviderConfig()
+	if err != nil {
+		return err
+	}
+
+	port, err := resolveRoutePort(cfg, owner, ownerName, sshExecutor)
+	if err != nil {
+		return err
+	}
+
+	domain := owner.Domain.Domain
+	appName := resolveAppName(owner, ownerName, sshExecutor)
+	proxyConfig := domainProxyConfig(owner, appName, providerCfg.GetIP(), domain, port)
+
+	if owner.Domain.SSLEnabled {
+		sslManager, err := ssl.GetManager("certbot")
+		if err != nil {
+			return fmt.Errorf("failed to get SSL manager: %w", err)
+		}
+		if certbotMgr, ok := sslManager.(interface{ SetExecutor(*sshpkg.Executor) }); ok {
+			certbotMgr.SetExecutor(sshExecutor)
+		}
+
+		certPath, keyPath, err := sslManager.GetCertificatePath(domain)
+		if err != nil {
+			return fmt.Errorf("failed to locate SSL certificate for %s: %w", domain, err)
+		}
+		proxyConfig.SSLEnabled = true
+		proxyConfig.SSLCertPath = certPath
+		proxyConfig.SSLKeyPath = keyPath
+	}
+
+	proxyManager, err := proxy.GetManager("nginx")
+	if err != nil {
+		return fmt.Errorf("failed to get proxy manager: %w", err)
+	}
+	if nginxMgr, ok := proxyManager.(interface{ SetExecutor(*sshpkg.Executor) }); ok {
+		nginxMgr.SetExecutor(sshExecutor)
+	}
+
+	if err := proxyManager.Configure(proxyConfig); err != nil {
+		return fmt.Errorf("failed to configure proxy for %s: %w", ownerName, err)
+	}
+	if err := keepDomainSiteMaintenance(proxyManager, sshExecutor, proxyConfig.AppName); err != nil {
+		return err
+	}
+
+	return proxyManager.Reload()
+}
+
+// addPathRoute routes domain+prefix to the target through the domain owner's server block
+func addPathRoute(cfg *config.Config, target config.TargetConfig, targetName, domain, prefix string) error {
+	owner, ownerName, found := findDomainOwner(cfg, domain)
+	if !found {
+		return fmt.Errorf("no target serves %s yet; add it first with 'lightfold domain add --domain %s --target <target>'", domain, domain)
+	}
+	if ownerName == targetName {
+		return fmt.Errorf("target '%s' already serves the root of %s", targetName, domain)
+	}
+
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return err
+	}
+	ownerProviderCfg, err := owner.GetSSHProviderConfig()
+	if err != nil {
+		return err
+	}
+	serverIP := providerCfg.GetIP()
+	if ownerProviderCfg.GetIP() != serverIP {
+		return fmt.Errorf("%s is served by '%s' on %s, but '%s' runs on %s; path routes only work between apps on the same server",
+			domain, ownerName, ownerProviderCfg.GetIP(), targetName, serverIP)
+	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	port, err := resolveRoutePort(cfg, &target, targetName, sshExecutor)
+	if err != nil {
+		return err
+	}
+
+	route := state.PathRoute{
+		Domain:     domain,
+		PathPrefix: prefix,
+		TargetName: targetName,
+		Port:       port,
+	}
+	if err := state.RegisterPathRoute(serverIP, route); err != nil {
+		return err
+	}
+
+	if err := reconfigureDomainOwner(cfg, &owner, ownerName, sshExecutor); err != nil {
+		state.UnregisterPathRoute(serverIP, domain, prefix, targetName)
+		return err
+	}
+
+	routeConfig := config.DomainConfig{
+		Domain:     domain,
+		PathPrefix: prefix,
+		SSLEnabled: owner.Domain.SSLEnabled,
+		ProxyType:  "nginx",
+	}
+	replaced := false
+	for i, existing := range target.PathRoutes {
+		if existing.Domain == domain && existing.PathPrefix == prefix {
+			target.PathRoutes[i] = routeConfig
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		target.PathRoutes = append(target.PathRoutes, routeConfig)
+	}
+
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		return fmt.Errorf("failed to update target config: %w", err)
+	}
+	return cfg.SaveConfig()
+}
+
+// removePathRoute drops a target's path route and regenerates the owner's config
+// without it, leaving the owner's own location untouched
+func removePathRoute(cfg *config.Config, target config.TargetConfig, targetName string, route config.DomainConfig) error {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return err
+	}
+	serverIP := providerCfg.GetIP()
+
+	if err := state.UnregisterPathRoute(serverIP, route.Domain, route.PathPrefix, targetName); err != nil {
+		return fmt.Errorf("failed to update server state: %w", err)
+	}
+
+	if owner, ownerName, found := findDomainOwner(cfg, route.Domain); found {
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		defer sshExecutor.Disconnect()
+
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			return fmt.Errorf("failed to connect to server: %w", err)
+		}
+		if err := reconfigureDomainOwner(cfg, &owner, ownerName, sshExecutor); err != nil {
+			return err
+		}
+	}
+
+	var remaining []config.DomainConfig
+	for _, existing := range target.PathRoutes {
+		if existing.Domain == route.Domain && existing.PathPrefix == route.PathPrefix {
+			continue
+		}
+		remaining = append(remaining, existing)
+	}
+	target.PathRoutes = remaining
+
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		return fmt.Errorf("failed to update target config: %w", err)
+	}
+	return cfg.SaveConfig()
+}
+
+// findTargetPathRoute looks up one of the target's path routes by prefix and, optionally, domain
+func findTargetPathRoute(target config.TargetConfig, domain, prefix string) (config.DomainConfig, error) {
+	var matches []config.DomainConfig
+	for _, route := range target.PathRoutes {
+		if route.PathPrefix == prefix && (domain == "" || route.Domain == domain) {
+			matches = append(matches, route)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return config.DomainConfig{}, fmt.Errorf("no path route %s configured for this target", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return config.DomainConfig{}, fmt.Errorf("path %s is routed on several domains; pass --domain to choose one", prefix)
+	}
+}
+
+// printDomainRoutes shows how requests for the target's domains are routed
+func printDomainRoutes(target config.TargetConfig, targetName string) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return
+	}
+	serverIP := providerCfg.GetIP()
+
+	if target.Domain != nil && target.Domain.Domain != "" && target.Domain.PathPrefix == "" {
+		routes, err := state.GetPathRoutesForDomain(serverIP, target.Domain.Domain)
+		if err == nil && len(routes) > 0 {
+			fmt.Printf("\n  %s\n", domainLabelStyle.Render(fmt.Sprintf("Routes for %s", target.Domain.Domain)))
+			fmt.Printf("    %-20s %s\n", "/", domainValueStyle.Render(targetName))
+			for _, route := range routes {
+				fmt.Printf("    %-20s %s %s\n", route.PathPrefix+"/", domainValueStyle.Render(route.TargetName),
+					domainMutedStyle.Render(fmt.Sprintf("(port %d)", route.Port)))
+			}
+		}
+	}
+
+	if len(target.PathRoutes) > 0 {
+		fmt.Printf("\n  %s\n", domainLabelStyle.Render("Path routes served by this target"))
+		for _, route := range target.PathRoutes {
+			fmt.Printf("    %s\n", domainValueStyle.Render(route.Domain+route.PathPrefix+"/"))
+		}
+	}
+}
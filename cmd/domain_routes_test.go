@@ -0,0 +1,37 @@
+package cmd
+
+import "testing"
+
+func TestNormalizePathPrefix(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "/api", want: "/api"},
+		{input: "api", want: "/api"},
+		{input: "/api/", want: "/api"},
+		{input: " /v1/api ", want: "/v1/api"},
+		{input: "/", wantErr: true},
+		{input: "", wantErr: true},
+		{input: "/a//b", wantErr: true},
+		{input: "/../etc", wantErr: true},
+		{input: "/api;", wantErr: true},
+		{input: "/api docs", wantErr: true},
+		{input: "/static", wantErr: true},
+		{input: "/media/uploads", wantErr: true},
+		{input: "/statics", want: "/statics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := normalizePathPrefix(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizePathPrefix(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizePathPrefix(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
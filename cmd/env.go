@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	envDiffTargetFlag string
+	envDiffFile       string
+	envDiffVars       []string
+	envDiffShowValues bool
+	envDiffPrune      bool
+
+	envAddedStyle   = style.Success
+	envChangedStyle = style.WarningText
+	envRemovedStyle = style.ErrorText
+	envMutedStyle   = style.Muted
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Inspect the environment variables on the target server",
+}
+
+var envDiffCmd = &cobra.Command{
+	Use:   "diff [PROJECT_PATH]",
+	Short: "Compare local environment variables with the server's env file",
+	Long: `Compare the locally resolved environment (.env.production, .env.prod or .env,
+plus --env-file and --env) with the env file on the target server.
+
+Values are hidden unless --show-values is passed. Keys only on the server are
+kept by 'lightfold push --env-sync' unless --prune is passed.
+
+Examples:
+  lightfold env diff                      # Diff current directory's target
+  lightfold env diff --target myapp       # Diff named target
+  lightfold env diff --show-values        # Include values in the diff
+  lightfold env diff --env API_URL=https://api.example.com`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+
+		target, targetName := resolveTarget(cfg, envDiffTargetFlag, pathArg)
+
+		if err := target.ProcessDeploymentOptions(envDiffFile, envDiffVars, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		diff, err := diffRemoteEnvironment(&target, targetName, envDiffPrune)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		printEnvDiff(diff, envDiffShowValues)
+	},
+}
+
+// diffRemoteEnvironment compares the target's resolved env with the env file
+// on its primary server
+func diffRemoteEnvironment(target *config.TargetConfig, targetName string, prune bool) (deploy.EnvDiff, error) {
+	if target.Provider == "s3" || target.Provider == "flyio" {
+		return deploy.EnvDiff{}, fmt.Errorf("env diff is only available for SSH-based targets (provider: %s)", target.Provider)
+	}
+
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return deploy.EnvDiff{}, err
+	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return deploy.EnvDiff{}, fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+	}
+
+	appName := resolveAppName(target, targetName, sshExecutor)
+	executor := deploy.NewExecutor(sshExecutor, appName, target.ProjectPath, nil)
+	executor.SetBaseDir(target.RemoteBaseDir())
+
+	remote, err := executor.ReadEnvironmentFile()
+	if err != nil {
+		return deploy.EnvDiff{}, err
+	}
+
+	var local map[string]string
+	if target.Deploy != nil {
+		local = target.Deploy.EnvVars
+	}
+	return deploy.DiffEnv(executor.ReleaseEnvironment(local), remote, prune), nil
+}
+
+func printEnvDiff(diff deploy.EnvDiff, showValues bool) {
+	lines := diff.Lines(showValues)
+	unchanged := diff.Count(deploy.EnvUnchanged)
+
+	if len(lines) == 0 {
+		fmt.Printf("%s\n", envMutedStyle.Render(fmt.Sprintf("Server environment is up to date (%d keys)", unchanged)))
+		return
+	}
+
+	fmt.Println("Environment changes:")
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			fmt.Printf("  %s\n", envAddedStyle.Render(line))
+		case strings.HasPrefix(line, "~"):
+			fmt.Printf("  %s\n", envChangedStyle.Render(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Printf("  %s\n", envRemovedStyle.Render(line))
+		default:
+			fmt.Printf("  %s\n", envMutedStyle.Render(line))
+		}
+	}
+	if unchanged > 0 {
+		fmt.Printf("  %s\n", envMutedStyle.Render(fmt.Sprintf("(%d unchanged)", unchanged)))
+	}
+}
+
+// confirmEnvSync asks before the merged env is written to the server.
+// Non-interactive runs continue.
+func confirmEnvSync() bool {
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return true
+	}
+
+	fmt.Printf("Write these changes to the server? (y/N): ")
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.AddCommand(envDiffCmd)
+
+	envDiffCmd.Flags().StringVar(&envDiffTargetFlag, "target", "", "Target name (defaults to current directory)")
+	envDiffCmd.Flags().StringVar(&envDiffFile, "env-file", "", "Path to .env file")
+	envDiffCmd.Flags().StringArrayVar(&envDiffVars, "env", []string{}, "Environment variables (KEY=VALUE)")
+	envDiffCmd.Flags().BoolVar(&envDiffShowValues, "show-values", false, "Show values in the diff")
+	envDiffCmd.Flags().BoolVar(&envDiffPrune, "prune", false, "Show keys only on the server as removed")
+}
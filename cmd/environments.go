@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"lightfold/pkg/util"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var deployEnvironmentFlag string
+
+// deployEnvironment is an environment from lightfold.yml resolved for one
+// deploy, with defaults merged in and variables substituted
+type deployEnvironment struct {
+	Name       string
+	TargetName string
+	Spec       config.EnvironmentSpec
+	file       *config.ProjectFile
+}
+
+var nonLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// environmentVariables are the ${VAR}s lightfold.yml can reference.
+// GIT_BRANCH is reduced to a DNS label (feature/Login -> feature-login) so it
+// can prefix a domain; it is left out when the project is not a git checkout.
+func environmentVariables(projectPath, name string) map[string]string {
+	vars := map[string]string{"ENV_NAME": name}
+	if branch := getGitBranch(projectPath); branch != "" {
+		vars["GIT_BRANCH"] = branchLabel(branch)
+	}
+	return vars
+}
+
+func getGitBranch(projectPath string) string {
+	cmd := exec.Command("git", "-C", projectPath, "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func branchLabel(branch string) string {
+	label := nonLabelChars.ReplaceAllString(strings.ToLower(branch), "-")
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return strings.Trim(label, "-")
+}
+
+// environmentTargetName is the target an environment deploys to, e.g. myapp-staging
+func environmentTargetName(projectPath, name string) string {
+	return util.GetTargetName(projectPath) + "-" + name
+}
+
+// loadDeployEnvironment reads lightfold.yml from the project and resolves the
+// named environment
+func loadDeployEnvironment(projectPath, name string) (*deployEnvironment, error) {
+	file, found, err := config.LoadProjectFile(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no %s in %s", config.ProjectFileName, projectPath)
+	}
+	spec, err := file.Environment(name, environmentVariables(projectPath, name))
+	if err != nil {
+		return nil, err
+	}
+	return &deployEnvironment{
+		Name:       name,
+		TargetName: environmentTargetName(projectPath, name),
+		Spec:       spec,
+		file:       file,
+	}, nil
+}
+
+// fieldError reports err against the YAML path the field was read from
+func (e *deployEnvironment) fieldError(field string, err error) error {
+	return &config.ProjectFileError{Path: e.file.FieldPath(e.Name, field), Message: err.Error()}
+}
+
+// applyEnvironment writes the environment's builder, port, domain, labels,
+// jobs, build args, protection and env variables to the target. Variables from env_file are applied
+// first so the env map overrides them.
+func applyEnvironment(target *config.TargetConfig, env *deployEnvironment, projectPath string) error {
+	spec := env.Spec
+	if spec.Builder != "" {
+		if err := setBuilder(target, spec.Builder); err != nil {
+			return env.fieldError("builder", err)
+		}
+	}
+	if spec.Port != 0 {
+		if err := setPort(target, strconv.Itoa(spec.Port)); err != nil {
+			return env.fieldError("port", err)
+		}
+	}
+	if spec.Domain != "" {
+		if err := setDomain(target, spec.Domain); err != nil {
+			return env.fieldError("domain", err)
+		}
+	}
+	if len(spec.Labels) > 0 {
+		labels := updateLabels(target.Labels, spec.Labels, nil)
+		if err := config.ValidateLabels(labels); err != nil {
+			return env.fieldError("labels", err)
+		}
+		target.Labels = labels
+	}
+	if spec.Jobs != nil {
+		ensureDeploy(target).Jobs = spec.Jobs
+	}
+	if spec.Protected != nil {
+		target.Protected = *spec.Protected
+	}
+	if len(spec.BuildArgs) > 0 || spec.BuildTarget != "" {
+		deployOptions := ensureDeploy(target)
+		for key, value := range spec.BuildArgs {
+			if deployOptions.BuildArgs == nil {
+				deployOptions.BuildArgs = make(map[string]string)
+			}
+			deployOptions.BuildArgs[key] = value
+		}
+		if spec.BuildTarget != "" {
+			deployOptions.BuildTarget = spec.BuildTarget
+		}
+		if err := config.ValidateBuildOptions(target.Builder, &config.DeploymentOptions{BuildTarget: deployOptions.BuildTarget}); err != nil {
+			return env.fieldError("build_target", err)
+		}
+		if err := config.ValidateBuildOptions(target.Builder, &config.DeploymentOptions{BuildArgs: deployOptions.BuildArgs}); err != nil {
+			return env.fieldError("build_args", err)
+		}
+	}
+
+	if spec.EnvFile == "" && len(spec.Env) == 0 {
+		return nil
+	}
+	deployOptions := ensureDeploy(target)
+	if deployOptions.EnvVars == nil {
+		deployOptions.EnvVars = make(map[string]string)
+	}
+	if spec.EnvFile != "" {
+		envFilePath := spec.EnvFile
+		if !filepath.IsAbs(envFilePath) {
+			envFilePath = filepath.Join(projectPath, envFilePath)
+		}
+		vars, err := util.LoadEnvFile(envFilePath)
+		if err != nil {
+			return env.fieldError("env_file", err)
+		}
+		for key, value := range vars {
+			deployOptions.EnvVars[key] = value
+		}
+	}
+	for key, value := range spec.Env {
+		deployOptions.EnvVars[key] = value
+	}
+	return nil
+}
+
+// checkEnvironmentServer compares the environment's provider, region and size
+// with a target whose server already exists. A different provider is an error;
+// a different region or size only applies to a new server, so it is returned
+// as a note.
+func checkEnvironmentServer(target config.TargetConfig, env *deployEnvironment) ([]string, error) {
+	spec := env.Spec
+	if spec.Provider == "" {
+		return nil, nil
+	}
+	bootstrap, err := findProviderBootstrap(spec.Provider)
+	if err != nil {
+		return nil, env.fieldError("provider", err)
+	}
+	if bootstrap.canonical != target.Provider {
+		return nil, env.fieldError("provider", fmt.Errorf("target %s already runs on %s; destroy it to move to %s", env.TargetName, target.Provider, bootstrap.canonical))
+	}
+
+	fields := map[string]interface{}{}
+	if raw, ok := target.ProviderConfig[target.Provider]; ok {
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("failed to parse provider config: %w", err)
+		}
+	}
+
+	region, _ := fields[providerRegionFields[target.Provider]].(string)
+	size, _ := fields[providerSizeFields[target.Provider]].(string)
+
+	var notes []string
+	for _, check := range []struct{ field, want, current string }{
+		{"region", spec.Region, region},
+		{"size", spec.Size, size},
+	} {
+		if check.want != "" && check.want != check.current {
+			notes = append(notes, fmt.Sprintf("%s: %s is %s but the server was created with %s; it applies when the server is recreated", env.file.FieldPath(env.Name, check.field), check.field, check.want, check.current))
+		}
+	}
+	return notes, nil
+}
+
+// prepareEnvironmentProvisioning sets the provisioning flags createTarget
+// reads from the environment, so the server is created without prompts
+func prepareEnvironmentProvisioning(env *deployEnvironment, projectPath string) error {
+	spec := env.Spec
+	for _, required := range []struct{ field, value string }{
+		{"provider", spec.Provider},
+		{"region", spec.Region},
+		{"size", spec.Size},
+	} {
+		if required.value == "" {
+			return env.fieldError(required.field, fmt.Errorf("required to create target %s", env.TargetName))
+		}
+	}
+	bootstrap, err := findProviderBootstrap(spec.Provider)
+	if err != nil {
+		return env.fieldError("provider", err)
+	}
+
+	// Validate the rest before a server is paid for
+	var scratch config.TargetConfig
+	if err := applyEnvironment(&scratch, env, projectPath); err != nil {
+		return err
+	}
+
+	providerFlag = bootstrap.canonical
+	regionFlag = spec.Region
+	sizeFlag = spec.Size
+	if deployBuilderFlag == "" {
+		deployBuilderFlag = spec.Builder
+	}
+	return nil
+}
+
+// envVarsSettingPrefix is where targetSettingsView lists env variables, masked
+const envVarsSettingPrefix = "deploy.env_vars."
+
+// settingsDiff lists the settings that differ between two views of a target
+// as "key: old → new". Env variables are masked in the views, so they are
+// left to envVarsDiff.
+func settingsDiff(before, after []configSetting) []string {
+	old := make(map[string]string, len(before))
+	for _, s := range before {
+		old[s.Key] = s.Value
+	}
+	current := make(map[string]string, len(after))
+	for _, s := range after {
+		current[s.Key] = s.Value
+	}
+
+	var lines []string
+	for _, s := range after {
+		if strings.HasPrefix(s.Key, envVarsSettingPrefix) {
+			continue
+		}
+		if value, ok := old[s.Key]; !ok {
+			lines = append(lines, fmt.Sprintf("%s: (unset) %s %s", s.Key, style.Arrow(), s.Value))
+		} else if value != s.Value {
+			lines = append(lines, fmt.Sprintf("%s: %s %s %s", s.Key, value, style.Arrow(), s.Value))
+		}
+	}
+	for _, s := range before {
+		if strings.HasPrefix(s.Key, envVarsSettingPrefix) {
+			continue
+		}
+		if _, ok := current[s.Key]; !ok {
+			lines = append(lines, fmt.Sprintf("%s: %s %s (unset)", s.Key, s.Value, style.Arrow()))
+		}
+	}
+	return lines
+}
+
+// envVarsDiff lists env variables that were added or changed, without their
+// values. applyEnvironment never removes variables.
+func envVarsDiff(before, after map[string]string) []string {
+	var lines []string
+	for _, key := range util.SortedKeys(after) {
+		if value, ok := before[key]; !ok {
+			lines = append(lines, envVarsSettingPrefix+key+": added")
+		} else if value != after[key] {
+			lines = append(lines, envVarsSettingPrefix+key+": changed")
+		}
+	}
+	return lines
+}
+
+func deployEnvVars(target config.TargetConfig) map[string]string {
+	if target.Deploy == nil {
+		return nil
+	}
+	return target.Deploy.EnvVars
+}
+
+// syncEnvironmentTarget brings an existing target in line with its
+// environment, showing what changes and asking before saving
+func syncEnvironmentTarget(cfg *config.Config, target *config.TargetConfig, env *deployEnvironment, projectPath string, dryRun bool) error {
+	notes, err := checkEnvironmentServer(*target, env)
+	if err != nil {
+		return err
+	}
+
+	before, err := targetSettingsView(*target)
+	if err != nil {
+		return err
+	}
+	updated := *target
+	if updated.Deploy != nil {
+		deployCopy := *updated.Deploy
+		deployCopy.EnvVars = make(map[string]string, len(target.Deploy.EnvVars))
+		for key, value := range target.Deploy.EnvVars {
+			deployCopy.EnvVars[key] = value
+		}
+		updated.Deploy = &deployCopy
+	}
+	if updated.Domain != nil {
+		domainCopy := *updated.Domain
+		updated.Domain = &domainCopy
+	}
+	if err := applyEnvironment(&updated, env, projectPath); err != nil {
+		return err
+	}
+	after, err := targetSettingsView(updated)
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		fmt.Printf("%s %s\n", pauseWarningStyle.Render("!"), note)
+	}
+	changes := append(settingsDiff(before, after), envVarsDiff(deployEnvVars(*target), deployEnvVars(updated))...)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fmt.Printf("%s\n", deployStepHeaderStyle.Render(fmt.Sprintf("%s changes target '%s':", config.ProjectFileName, env.TargetName)))
+	for _, change := range changes {
+		fmt.Printf("  %s\n", deployMutedStyle.Render(change))
+	}
+	if dryRun {
+		*target = updated
+		return nil
+	}
+	if !confirmEnvironmentChanges() {
+		return fmt.Errorf("target %s was not updated; rerun after reviewing %s", env.TargetName, config.ProjectFileName)
+	}
+
+	*target = updated
+	if err := cfg.SetTarget(env.TargetName, updated); err != nil {
+		return fmt.Errorf("failed to save target config: %w", err)
+	}
+	return cfg.SaveConfig()
+}
+
+func confirmEnvironmentChanges() bool {
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return true
+	}
+
+	fmt.Printf("Apply these changes? (y/N): ")
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+// resolveEnvironmentTarget loads the environment's target for deploy: it
+// syncs an existing one, or prepares provisioning for a new one
+func resolveEnvironmentTarget(cfg *config.Config, projectPath, name string) (*deployEnvironment, config.TargetConfig, bool, error) {
+	env, err := loadDeployEnvironment(projectPath, name)
+	if err != nil {
+		return nil, config.TargetConfig{}, false, err
+	}
+
+	if err := utils.CheckTargetNameCollision(cfg, env.TargetName, projectPath); err != nil {
+		return nil, config.TargetConfig{}, false, err
+	}
+	target, exists := cfg.GetTarget(env.TargetName)
+	if exists && state.IsCreated(env.TargetName) {
+		return env, target, true, syncEnvironmentTarget(cfg, &target, env, projectPath, deployDryRun)
+	}
+	return env, target, exists, prepareEnvironmentProvisioning(env, projectPath)
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestEnvironment resolves an environment from a lightfold.yml body
+func newTestEnvironment(t *testing.T, yaml, name string) *deployEnvironment {
+	t.Helper()
+	file, err := config.ParseProjectFile([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+	spec, err := file.Environment(name, map[string]string{"ENV_NAME": name, "GIT_BRANCH": "main"})
+	if err != nil {
+		t.Fatalf("Environment() error: %v", err)
+	}
+	return &deployEnvironment{Name: name, TargetName: "myapp-" + name, Spec: spec, file: file}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env.staging"), []byte("DATABASE_URL=postgres://db\nLOG_LEVEL=debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := newTestEnvironment(t, `
+defaults:
+  builder: nixpacks
+  env:
+    LOG_LEVEL: info
+environments:
+  staging:
+    port: 8080
+    domain: ${GIT_BRANCH}.staging.example.com
+    env_file: .env.staging
+`, "staging")
+
+	target := newSettingsTarget(t)
+	if err := applyEnvironment(&target, env, dir); err != nil {
+		t.Fatalf("applyEnvironment() error: %v", err)
+	}
+
+	if target.Builder != "nixpacks" || target.Port != 8080 || target.Domain.Domain != "main.staging.example.com" {
+		t.Errorf("target = builder %q, port %d, domain %+v", target.Builder, target.Port, target.Domain)
+	}
+	// env overrides env_file
+	want := map[string]string{"DATABASE_URL": "postgres://db", "LOG_LEVEL": "info"}
+	if !reflect.DeepEqual(target.Deploy.EnvVars, want) {
+		t.Errorf("EnvVars = %v, want %v", target.Deploy.EnvVars, want)
+	}
+}
+
+func TestApplyEnvironmentErrorsPointAtYAMLPath(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		path string
+	}{
+		{"builder from defaults", "defaults:\n  builder: heroku\nenvironments:\n  staging: {}\n", "defaults.builder"},
+		{"builder override", "defaults:\n  builder: native\nenvironments:\n  staging:\n    builder: heroku\n", "environments.staging.builder"},
+		{"domain", "environments:\n  staging:\n    domain: not a domain\n", "environments.staging.domain"},
+		{"missing env_file", "defaults:\n  env_file: .env.missing\nenvironments:\n  staging: {}\n", "defaults.env_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnvironment(t, tt.yaml, "staging")
+			target := newSettingsTarget(t)
+			err := applyEnvironment(&target, env, t.TempDir())
+
+			var fileErr *config.ProjectFileError
+			if !errors.As(err, &fileErr) || fileErr.Path != tt.path {
+				t.Errorf("applyEnvironment() = %v, want an error at %s", err, tt.path)
+			}
+		})
+	}
+}
+
+func TestCheckEnvironmentServer(t *testing.T) {
+	target := newSettingsTarget(t)
+
+	env := newTestEnvironment(t, "defaults:\n  provider: do\n  region: nyc1\nenvironments:\n  staging:\n    size: s-2vcpu-2gb\n", "staging")
+	notes, err := checkEnvironmentServer(target, env)
+	if err != nil {
+		t.Fatalf("checkEnvironmentServer() error: %v", err)
+	}
+	if len(notes) != 1 || !strings.HasPrefix(notes[0], "environments.staging.size: size is s-2vcpu-2gb but the server was created with s-1vcpu-1gb") {
+		t.Errorf("notes = %q, want only the size change", notes)
+	}
+
+	env = newTestEnvironment(t, "defaults:\n  provider: hetzner\nenvironments:\n  staging: {}\n", "staging")
+	_, err = checkEnvironmentServer(target, env)
+	var fileErr *config.ProjectFileError
+	if !errors.As(err, &fileErr) || fileErr.Path != "defaults.provider" || !strings.Contains(err.Error(), "already runs on digitalocean") {
+		t.Errorf("checkEnvironmentServer() = %v, want a provider error at defaults.provider", err)
+	}
+}
+
+func TestPrepareEnvironmentProvisioning(t *testing.T) {
+	defer func(provider, region, size, builder string) {
+		providerFlag, regionFlag, sizeFlag, deployBuilderFlag = provider, region, size, builder
+	}(providerFlag, regionFlag, sizeFlag, deployBuilderFlag)
+
+	env := newTestEnvironment(t, "defaults:\n  provider: hetzner\n  region: nbg1\nenvironments:\n  staging: {}\n", "staging")
+	err := prepareEnvironmentProvisioning(env, t.TempDir())
+	if err == nil || err.Error() != "lightfold.yml: defaults.size: required to create target myapp-staging" {
+		t.Errorf("prepareEnvironmentProvisioning() = %v, want the missing size reported", err)
+	}
+
+	env = newTestEnvironment(t, "defaults:\n  provider: do\n  region: nyc1\n  builder: dockerfile\nenvironments:\n  staging:\n    size: s-1vcpu-1gb\n", "staging")
+	deployBuilderFlag = ""
+	if err := prepareEnvironmentProvisioning(env, t.TempDir()); err != nil {
+		t.Fatalf("prepareEnvironmentProvisioning() error: %v", err)
+	}
+	if providerFlag != "digitalocean" || regionFlag != "nyc1" || sizeFlag != "s-1vcpu-1gb" || deployBuilderFlag != "dockerfile" {
+		t.Errorf("flags = %q %q %q %q", providerFlag, regionFlag, sizeFlag, deployBuilderFlag)
+	}
+}
+
+func TestSettingsDiff(t *testing.T) {
+	before := []configSetting{{Key: "builder", Value: "auto"}, {Key: "domain.domain", Value: "old.example.com"}, {Key: "port", Value: "3000"}}
+	after := []configSetting{{Key: "builder", Value: "nixpacks"}, {Key: "deploy.env_vars.TOKEN", Value: "********"}, {Key: "port", Value: "3000"}}
+
+	want := []string{
+		"builder: auto → nixpacks",
+		"domain.domain: old.example.com → (unset)",
+	}
+	if got := settingsDiff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("settingsDiff() = %q, want %q", got, want)
+	}
+	if got := settingsDiff(before, before); len(got) != 0 {
+		t.Errorf("settingsDiff() of identical settings = %q", got)
+	}
+}
+
+func TestEnvVarsDiff(t *testing.T) {
+	before := map[string]string{"LOG_LEVEL": "debug", "KEEP": "x", "OLD": "y"}
+	after := map[string]string{"LOG_LEVEL": "info", "KEEP": "x", "OLD": "y", "TOKEN": "secret"}
+
+	want := []string{"deploy.env_vars.LOG_LEVEL: changed", "deploy.env_vars.TOKEN: added"}
+	if got := envVarsDiff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("envVarsDiff() = %q, want %q", got, want)
+	}
+	if got := envVarsDiff(nil, nil); len(got) != 0 {
+		t.Errorf("envVarsDiff(nil, nil) = %q", got)
+	}
+}
+
+func TestSyncEnvironmentTargetDryRunLeavesConfig(t *testing.T) {
+	target := newSettingsTarget(t)
+	target.Deploy = &config.DeploymentOptions{EnvVars: map[string]string{"LOG_LEVEL": "debug"}}
+	original := target.Deploy.EnvVars
+
+	env := newTestEnvironment(t, "environments:\n  staging:\n    env:\n      LOG_LEVEL: info\n", "staging")
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{"myapp-staging": target}}
+
+	if err := syncEnvironmentTarget(cfg, &target, env, t.TempDir(), true); err != nil {
+		t.Fatalf("syncEnvironmentTarget() error: %v", err)
+	}
+	if target.Deploy.EnvVars["LOG_LEVEL"] != "info" {
+		t.Errorf("dry run target EnvVars = %v, want the environment applied", target.Deploy.EnvVars)
+	}
+	if original["LOG_LEVEL"] != "debug" || cfg.Targets["myapp-staging"].Deploy.EnvVars["LOG_LEVEL"] != "debug" {
+		t.Errorf("dry run changed the saved target: %v", cfg.Targets["myapp-staging"].Deploy.EnvVars)
+	}
+}
+
+func TestBranchLabel(t *testing.T) {
+	tests := map[string]string{
+		"main":                  "main",
+		"feature/Login-Page":    "feature-login-page",
+		"fix_123":               "fix-123",
+		"/release/":             "release",
+		strings.Repeat("a", 70): strings.Repeat("a", 63),
+	}
+	for branch, want := range tests {
+		if got := branchLabel(branch); got != want {
+			t.Errorf("branchLabel(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var frameworkFlag string
+
+// detectionMargin returns how close the runner-up framework's score may be
+// to the top one before detection asks which framework the app is
+func detectionMargin(cfg *config.Config) float64 {
+	if cfg != nil && cfg.DetectionMargin > 0 {
+		return cfg.DetectionMargin
+	}
+	return detector.DefaultAmbiguityMargin
+}
+
+// applyFrameworkFlag makes --framework the target's framework override
+func applyFrameworkFlag(target *config.TargetConfig) error {
+	if frameworkFlag == "" {
+		return nil
+	}
+	name, err := detector.LookupFramework(frameworkFlag)
+	if err != nil {
+		return err
+	}
+	target.FrameworkOverride = name
+	return nil
+}
+
+// resolveFramework detects the target's app as its framework override, or
+// picks by score. When ask is set and the top candidates score within the
+// detection margin of each other, a terminal user picks one and it becomes
+// the override; without a terminal it fails naming them. The caller saves
+// the target.
+func resolveFramework(cfg *config.Config, target *config.TargetConfig, projectPath string, ask bool) (detector.Detection, error) {
+	detection := detector.DetectAppAs(projectPath, target.AppSubdir(), target.FrameworkOverride)
+	if target.FrameworkOverride != "" {
+		target.Framework = detection.Framework
+		return detection, nil
+	}
+
+	candidates := detection.AmbiguousCandidates(detectionMargin(cfg))
+	if !ask || len(candidates) == 0 {
+		return detection, nil
+	}
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return detection, ambiguousFrameworkError(candidates)
+	}
+
+	name, err := promptFramework(candidates)
+	if err != nil {
+		return detection, err
+	}
+	target.FrameworkOverride = name
+	detection = detector.DetectAppAs(projectPath, target.AppSubdir(), name)
+	target.Framework = detection.Framework
+	return detection, nil
+}
+
+// frameworkFlagValue is how a framework is written after --framework, e.g.
+// "Spring Boot" -> spring-boot
+func frameworkFlagValue(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+func ambiguousFrameworkError(candidates []detector.FrameworkCandidate) error {
+	described := make([]string, len(candidates))
+	for i, c := range candidates {
+		described[i] = fmt.Sprintf("%s (score %.1f: %s)", c.Framework, c.Score, strings.Join(c.Signals, ", "))
+	}
+	return fmt.Errorf("framework detection is ambiguous: %s. Pass --framework %s (or another framework) to choose",
+		strings.Join(described, "; "), frameworkFlagValue(candidates[0].Framework))
+}
+
+// promptFramework asks which of the close candidates the app is, defaulting
+// to the top one. The answer may be a number or a framework name.
+func promptFramework(candidates []detector.FrameworkCandidate) (string, error) {
+	fmt.Println("The project shows signals for more than one framework:")
+	for i, c := range candidates {
+		fmt.Printf("  %d) %-12s score %.1f  %s\n", i+1, c.Framework, c.Score, strings.Join(c.Signals, ", "))
+	}
+	fmt.Printf("Which framework is the app? [1]: ")
+
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return candidates[0].Framework, nil
+	}
+	if n, err := strconv.Atoi(response); err == nil && n >= 1 && n <= len(candidates) {
+		return candidates[n-1].Framework, nil
+	}
+	name, err := detector.LookupFramework(response)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMixedProject writes a project with both Django and Next.js signals
+func writeMixedProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"manage.py":        "#!/usr/bin/env python\n",
+		"requirements.txt": "django==5.0\n",
+		"package.json":     `{"scripts": {"build": "next build"}, "dependencies": {"next": "14.0.0"}}`,
+		"next.config.js":   "module.exports = {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolveFrameworkAmbiguousWithoutTerminal(t *testing.T) {
+	skipInteractive = true
+	defer func() { skipInteractive = false }()
+	dir := writeMixedProject(t)
+
+	target := config.TargetConfig{}
+	_, err := resolveFramework(&config.Config{}, &target, dir, true)
+	if err == nil {
+		t.Fatal("expected an ambiguity error")
+	}
+	for _, want := range []string{"Django", "Next.js", "manage.py", "--framework django"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	if _, err := resolveFramework(&config.Config{}, &target, dir, false); err != nil {
+		t.Errorf("existing targets should not be asked, got %v", err)
+	}
+	if _, err := resolveFramework(&config.Config{DetectionMargin: 0.5}, &target, dir, true); err != nil {
+		t.Errorf("a tighter margin should pick by score, got %v", err)
+	}
+}
+
+func TestResolveFrameworkOverride(t *testing.T) {
+	skipInteractive = true
+	frameworkFlag = "nextjs"
+	defer func() { skipInteractive = false; frameworkFlag = "" }()
+	dir := writeMixedProject(t)
+
+	target := config.TargetConfig{Framework: "Django"}
+	if err := applyFrameworkFlag(&target); err != nil {
+		t.Fatalf("applyFrameworkFlag() error: %v", err)
+	}
+	detection, err := resolveFramework(&config.Config{}, &target, dir, true)
+	if err != nil {
+		t.Fatalf("resolveFramework() error: %v", err)
+	}
+	if detection.Framework != "Next.js" || target.Framework != "Next.js" || target.FrameworkOverride != "Next.js" {
+		t.Errorf("detection %s, target framework %s, override %s; want Next.js for all", detection.Framework, target.Framework, target.FrameworkOverride)
+	}
+
+	frameworkFlag = "cobol"
+	if err := applyFrameworkFlag(&target); err == nil {
+		t.Error("expected an unknown --framework to be rejected")
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsTargetFlag string
+
+	jobsHeaderStyle  = style.Title
+	jobsSuccessStyle = style.Success
+	jobsMutedStyle   = style.Muted
+	jobsValueStyle   = style.Value
+	jobsErrorStyle   = style.ErrorText
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Inspect the scheduled jobs that run next to an app",
+	Long: `Jobs are commands run on the app's server on a cron schedule, set under
+deploy.jobs in the target config or under jobs in lightfold.yml:
+
+  jobs:
+    - name: cleanup
+      schedule: "0 3 * * *"
+      command: node scripts/cleanup.js
+
+Each job becomes a systemd timer and service (e.g. myapp-cleanup.timer) that
+runs the command in the current release directory as the deploy user with the
+app's environment loaded. Schedules are read in UTC unless the job sets a
+timezone. configure and push install changed jobs and remove deleted ones; on
+multi-server targets jobs run on the primary server only.`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list [PROJECT_PATH]",
+	Short: "Show each job's schedule, last run and exit status",
+	Long: `Show each configured job's schedule and, from systemd on the server, when it
+last ran, how it exited and when it runs next.
+
+Examples:
+  lightfold jobs list
+  lightfold jobs list --target myapp`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, jobsTargetFlag, pathArg)
+
+		var jobs []config.Job
+		if target.Deploy != nil {
+			jobs = target.Deploy.Jobs
+		}
+		if len(jobs) == 0 {
+			fmt.Printf("%s\n", jobsMutedStyle.Render(fmt.Sprintf("Target '%s' has no scheduled jobs", targetName)))
+			return
+		}
+		if !target.RequiresSSHDeployment() {
+			fmt.Fprintf(os.Stderr, "%s\n", jobsErrorStyle.Render(fmt.Sprintf("Error: scheduled jobs are not supported on %s", target.Provider)))
+			exit(1)
+		}
+		exitIfPaused(targetName)
+
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", jobsErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		defer sshExecutor.Disconnect()
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", jobsErrorStyle.Render(fmt.Sprintf("Error: failed to connect to %s: %v", providerCfg.GetIP(), err)))
+			exit(1)
+		}
+
+		appName := resolveAppName(&target, targetName, sshExecutor)
+		executor := deploy.NewExecutor(sshExecutor, appName, target.ProjectPath, nil)
+		executor.SetBaseDir(target.RemoteBaseDir())
+		statuses, err := executor.JobStatuses(jobs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", jobsErrorStyle.Render(fmt.Sprintf("Error: failed to read job status: %v", err)))
+			exit(1)
+		}
+
+		fmt.Printf("%s %s\n", jobsHeaderStyle.Render("Jobs for:"), targetName)
+		fmt.Printf("%s %s\n", jobsMutedStyle.Render("Server:"), providerCfg.GetIP())
+		for i, status := range statuses {
+			job := jobs[i]
+			fmt.Printf("\n%s  %s\n", jobsValueStyle.Render(job.Name), jobsMutedStyle.Render(job.Schedule+" ("+job.Zone()+")"))
+			fmt.Printf("  Command:  %s\n", job.Command)
+			fmt.Printf("  Last run: %s\n", formatJobRun(status))
+			if status.NextRun != "" {
+				fmt.Printf("  Next run: %s\n", status.NextRun)
+			}
+		}
+
+		for _, status := range statuses {
+			if !status.Installed {
+				fmt.Printf("\n%s\n", jobsMutedStyle.Render(fmt.Sprintf("Run 'lightfold push --target %s' to install the jobs that are not on the server", targetName)))
+				break
+			}
+		}
+	},
+}
+
+// formatJobRun describes a job's last run and how it exited
+func formatJobRun(status deploy.JobStatus) string {
+	switch {
+	case !status.Installed:
+		return jobsErrorStyle.Render("not installed")
+	case status.LastRun == "":
+		return jobsMutedStyle.Render("never")
+	case status.ExitStatus == "":
+		return status.LastRun + jobsMutedStyle.Render(" (running)")
+	case status.ExitStatus == "0" && status.Result == "success":
+		return status.LastRun + " " + jobsSuccessStyle.Render(style.Check()+" exit 0")
+	case status.ExitStatus == "0":
+		return status.LastRun + " " + jobsErrorStyle.Render(style.Cross()+" "+status.Result)
+	}
+	return status.LastRun + " " + jobsErrorStyle.Render(fmt.Sprintf("%s exit %s", style.Cross(), status.ExitStatus))
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd)
+
+	jobsListCmd.Flags().StringVar(&jobsTargetFlag, "target", "", "Target name (defaults to current directory)")
+}
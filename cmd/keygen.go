@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/ssh"
 	"os"
 
@@ -33,7 +34,7 @@ If no key name is provided, a random name will be generated.`,
 			keyName, err = generateRandomKeyName()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error generating random key name: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		} else {
 			keyName = args[0]
@@ -42,22 +43,22 @@ If no key name is provided, a random name will be generated.`,
 		exists, err := ssh.KeyExists(keyName)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error checking key existence: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if exists {
 			fmt.Fprintf(os.Stderr, "Error: SSH key '%s' already exists\n", keyName)
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Printf("Generating Ed25519 SSH key pair: %s\n", keyName)
 		keyPair, err := ssh.GenerateKeyPair(keyName)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating key pair: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Println("\n✓ SSH key pair generated successfully")
+		fmt.Println("\n" + style.Check() + " SSH key pair generated successfully")
 		fmt.Println()
 		fmt.Printf("Private key: %s\n", keyPair.PrivateKeyPath)
 		fmt.Printf("Public key:  %s\n", keyPair.PublicKeyPath)
@@ -1,16 +1,25 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/detector"
 	"lightfold/pkg/flyctl"
 	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
@@ -19,8 +28,8 @@ var (
 	logsLines      int
 	logsTail       bool
 
-	logsHeaderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	logsMutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	logsHeaderStyle = style.Title
+	logsMutedStyle  = style.Muted
 )
 
 var logsCmd = &cobra.Command{
@@ -49,7 +58,7 @@ Examples:
 
 		if target.Provider == "s3" {
 			fmt.Fprintf(os.Stderr, "Error: Logs are not available for S3 deployments\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Route to fly.io logs for container-based deployments
@@ -57,20 +66,20 @@ Examples:
 			tokens, err := config.LoadTokens()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error loading tokens: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			token := tokens.GetToken("flyio")
 			if token == "" {
 				fmt.Fprintf(os.Stderr, "Error: fly.io API token not found\n")
 				fmt.Fprintf(os.Stderr, "Run 'lightfold config set-token flyio' first\n")
-				os.Exit(1)
+				exit(1)
 			}
 
 			flyioConfig, err := target.GetFlyioConfig()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error getting fly.io config: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			fmt.Printf("%s %s\n", logsHeaderStyle.Render("Logs for:"), targetName)
@@ -82,7 +91,7 @@ Examples:
 			output, err := client.GetLogs(ctx, logsLines, logsTail)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error fetching fly.io logs: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			fmt.Println(output)
@@ -93,48 +102,262 @@ Examples:
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Printf("%s %s\n", logsHeaderStyle.Render("Logs for:"), targetName)
 		fmt.Printf("%s %s\n\n", logsMutedStyle.Render("Server:"), providerCfg.GetIP())
 
-		sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 		defer sshExecutor.Disconnect()
 
 		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
 			fmt.Fprintf(os.Stderr, "Error connecting to server: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
-		appName := strings.ReplaceAll(targetName, "-", "_")
+		appName := resolveAppName(&target, targetName, sshExecutor)
+		detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+		command, sudo := appLogsCommand(appName, target.Framework, detection.Meta["deployment_type"] == "static", logsQuery{Lines: logsLines, Follow: logsTail})
 
-		var logsCmd string
-		if logsTail {
-			logsCmd = fmt.Sprintf("journalctl -u %s -n %d -f", appName, logsLines)
-		} else {
-			logsCmd = fmt.Sprintf("journalctl -u %s -n %d --no-pager", appName, logsLines)
-		}
-
-		result := sshExecutor.Execute(logsCmd)
+		result := streamAppLogs(sshExecutor, command, sudo, os.Stdout)
 		if result.Error != nil {
 			fmt.Fprintf(os.Stderr, "Error fetching logs: %v\n", result.Error)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if result.ExitCode != 0 {
-			if strings.Contains(result.Stderr, "No entries") || strings.Contains(result.Stderr, "not found") {
+			if strings.Contains(result.Stderr, "No entries") || strings.Contains(result.Stderr, "not found") || strings.Contains(result.Stderr, "No such file") {
 				fmt.Printf("%s\n", logsMutedStyle.Render("No logs available yet. The service may not have been deployed."))
-				os.Exit(0)
+				exit(0)
 			}
 			fmt.Fprintf(os.Stderr, "Error: %s\n", result.Stderr)
-			os.Exit(1)
+			exit(1)
 		}
-
-		fmt.Println(result.Stdout)
 	},
 }
 
+// logsQuery selects which of an app's log lines to read
+type logsQuery struct {
+	Lines  int           // Most recent lines to show
+	Follow bool          // Keep streaming new lines
+	For    time.Duration // Stop following after this long; 0 follows until interrupted
+	Since  int64         // Only lines logged from this server time (unix seconds) on; 0 for all
+}
+
+// appLogsCommand returns the command printing the app's logs and whether it
+// needs root: journalctl for services, docker compose for compose projects
+// and the app's nginx logs for static sites, which have no service
+func appLogsCommand(appName, framework string, static bool, q logsQuery) (string, bool) {
+	var command string
+	sudo := false
+	switch {
+	case deploy.IsComposeFramework(framework):
+		args := fmt.Sprintf("logs --tail %d", q.Lines)
+		if q.Since > 0 {
+			args += fmt.Sprintf(" --since %d", q.Since)
+		}
+		if q.Follow {
+			args += " -f"
+		} else {
+			args += " --no-color"
+		}
+		command, sudo = deploy.ComposeCommand(appName, "", "", args), true
+	case static:
+		logs := fmt.Sprintf("/var/log/nginx/%[1]s_access.log /var/log/nginx/%[1]s_error.log", appName)
+		if q.Since > 0 {
+			command = nginxLogsSinceCommand(logs, q.Lines, q.Since, q.Follow)
+		} else if q.Follow {
+			command = fmt.Sprintf("tail -n %d -F %s", q.Lines, logs)
+		} else {
+			command = fmt.Sprintf("tail -n %d %s", q.Lines, logs)
+		}
+		sudo = true
+	default:
+		command = fmt.Sprintf("journalctl -u %s -n %d", appName, q.Lines)
+		if q.Since > 0 {
+			command += fmt.Sprintf(" --since @%d", q.Since)
+		}
+		if q.Follow {
+			command += " -f"
+		} else {
+			command += " --no-pager"
+		}
+	}
+
+	if q.Follow && q.For > 0 {
+		seconds := int64(math.Ceil(q.For.Seconds()))
+		command = fmt.Sprintf("timeout %d %s", seconds, command)
+	}
+	return command, sudo
+}
+
+// nginxLogsSinceProgram prints the lines of nginx access and error logs
+// stamped at or after since, each prefixed with its stamp as
+// "YYYY/MM/DD HH:MM:SS" so both logs merge in order. nginx writes both in
+// server local time. Lines without a stamp take the previous line's.
+const nginxLogsSinceProgram = `BEGIN { split("Jan Feb Mar Apr May Jun Jul Aug Sep Oct Nov Dec", names, " "); for (i = 1; i <= 12; i++) month[names[i]] = sprintf("%02d", i) }
+FNR == 1 { stamp = "" }
+{
+	if (match($0, /^[0-9][0-9][0-9][0-9]\/[0-9][0-9]\/[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]/)) {
+		stamp = substr($0, 1, 19)
+	} else if (match($0, /\[[0-9][0-9]\/[A-Z][a-z][a-z]\/[0-9][0-9][0-9][0-9]:[0-9][0-9]:[0-9][0-9]:[0-9][0-9]/)) {
+		t = substr($0, RSTART + 1, 20)
+		stamp = substr(t, 8, 4) "/" month[substr(t, 4, 3)] "/" substr(t, 1, 2) " " substr(t, 13, 8)
+	}
+	if (stamp != "" && stamp >= since) print stamp " " $0
+}`
+
+// nginxLogsSinceCommand prints the last lines of the nginx logs written
+// from since (unix seconds) on, oldest first, then follows them if asked
+func nginxLogsSinceCommand(logs string, lines int, since int64, follow bool) string {
+	script := fmt.Sprintf(`since=$(date -d @%d '+%%Y/%%m/%%d %%H:%%M:%%S'); awk -v since="$since" %s %s 2>/dev/null | sort -s -k1,2 | tail -n %d | cut -c21-`,
+		since, util.ShellQuote(nginxLogsSinceProgram), logs, lines)
+	if follow {
+		script += "; tail -n 0 -F " + logs
+	}
+	return "sh -c " + util.ShellQuote(script)
+}
+
+// logStreamer runs a command on the server, writing its output as it arrives
+type logStreamer interface {
+	ExecuteWithStreaming(command string, stdoutWriter, stderrWriter io.Writer) *sshpkg.CommandResult
+	ExecuteSudoWithStreaming(command string, stdoutWriter, stderrWriter io.Writer) *sshpkg.CommandResult
+}
+
+// streamAppLogs writes the output of an appLogsCommand to out line by line,
+// colored by severity. Following for a set time ends with timeout's exit
+// status 124, which is not an error.
+func streamAppLogs(server logStreamer, command string, sudo bool, out io.Writer) *sshpkg.CommandResult {
+	writer := &severityWriter{out: out}
+	var result *sshpkg.CommandResult
+	if sudo {
+		result = server.ExecuteSudoWithStreaming(command, writer, nil)
+	} else {
+		result = server.ExecuteWithStreaming(command, writer, nil)
+	}
+	writer.Flush()
+
+	if result.ExitCode == 124 && strings.HasPrefix(command, "timeout ") {
+		result.ExitCode = 0
+	}
+	return result
+}
+
+var (
+	logErrorPattern   = regexp.MustCompile(`\b(ERROR|FATAL|CRITICAL|PANIC)\b|Traceback|(?i:level=(error|fatal))|\[(error|crit|alert|emerg)\]`)
+	logWarningPattern = regexp.MustCompile(`\b(WARN|WARNING)\b|(?i:level=warn)|\[warn\]`)
+)
+
+// logSeverity guesses a log line's severity from the level it mentions:
+// "error", "warning" or "" for anything else
+func logSeverity(line string) string {
+	switch {
+	case logErrorPattern.MatchString(line):
+		return "error"
+	case logWarningPattern.MatchString(line):
+		return "warning"
+	}
+	return ""
+}
+
+// colorizeLogLine highlights lines that mention an error or a warning
+func colorizeLogLine(line string) string {
+	switch logSeverity(line) {
+	case "error":
+		return style.ErrorText.Render(line)
+	case "warning":
+		return style.WarningText.Render(line)
+	}
+	return line
+}
+
+// severityWriter writes complete log lines through colorizeLogLine, holding
+// back a partial line until its newline arrives
+type severityWriter struct {
+	out     io.Writer
+	partial []byte
+}
+
+func (w *severityWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.partial[:i])
+		w.partial = w.partial[i+1:]
+		if _, err := fmt.Fprintln(w.out, colorizeLogLine(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes a final line that had no newline
+func (w *severityWriter) Flush() {
+	if len(w.partial) > 0 {
+		fmt.Fprintln(w.out, colorizeLogLine(string(w.partial)))
+		w.partial = nil
+	}
+}
+
+// deployTailLines is how many earlier lines a post-deploy tail starts with
+const deployTailLines = 20
+
+// failedReleaseLogLines is how many lines are shown from a release that was
+// rolled back
+const failedReleaseLogLines = 100
+
+// tailAfterDeploy streams the app's logs once a deploy succeeded, for d or
+// until Ctrl-C when d is 0
+func tailAfterDeploy(server logStreamer, appName, framework string, static bool, d time.Duration) {
+	if d > 0 {
+		fmt.Printf("\n%s\n", logsMutedStyle.Render(fmt.Sprintf("Streaming logs for %s (Ctrl-C to stop)...", d)))
+	} else {
+		fmt.Printf("\n%s\n", logsMutedStyle.Render("Streaming logs (Ctrl-C to stop)..."))
+	}
+	command, sudo := appLogsCommand(appName, framework, static, logsQuery{Lines: deployTailLines, Follow: true, For: d})
+	result := streamAppLogs(server, command, sudo, os.Stdout)
+	if result.Error != nil || result.ExitCode != 0 {
+		fmt.Printf("Warning: failed to stream logs: %v\n", logsResultError(result))
+	}
+}
+
+// showFailedReleaseLogs prints the last lines the app logged from since, the
+// server time the failed release was switched in, to explain a rollback
+func showFailedReleaseLogs(server logStreamer, appName, framework string, static bool, since int64) {
+	fmt.Fprintf(os.Stderr, "\n%s\n", logsMutedStyle.Render(fmt.Sprintf("Last %d log lines since the failed release went live:", failedReleaseLogLines)))
+	command, sudo := appLogsCommand(appName, framework, static, logsQuery{Lines: failedReleaseLogLines, Since: since})
+	result := streamAppLogs(server, command, sudo, os.Stderr)
+	if result.Error != nil || result.ExitCode != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read logs: %v\n", logsResultError(result))
+	}
+}
+
+// logsResultError describes why a logs command failed
+func logsResultError(result *sshpkg.CommandResult) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	return fmt.Errorf("exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+}
+
+// remoteUnixTime reads the server's clock, so log windows do not depend on
+// the local one. It returns 0 when the clock cannot be read.
+func remoteUnixTime(server utils.CommandRunner) int64 {
+	result := server.Execute("date +%s")
+	if result.Error != nil || result.ExitCode != 0 {
+		return 0
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(result.Stdout), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seconds
+}
+
 func init() {
 	rootCmd.AddCommand(logsCmd)
 
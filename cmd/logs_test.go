@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppLogsCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		framework string
+		static    bool
+		query     logsQuery
+		want      string
+		wantSudo  bool
+	}{
+		{"recent lines", "Next.js", false, logsQuery{Lines: 100}, "journalctl -u myapp -n 100 --no-pager", false},
+		{"follow", "Next.js", false, logsQuery{Lines: 20, Follow: true}, "journalctl -u myapp -n 20 -f", false},
+		{"follow for a while", "Django", false, logsQuery{Lines: 20, Follow: true, For: 90 * time.Second}, "timeout 90 journalctl -u myapp -n 20 -f", false},
+		{"partial seconds round up", "Django", false, logsQuery{Lines: 20, Follow: true, For: 1500 * time.Millisecond}, "timeout 2 journalctl -u myapp -n 20 -f", false},
+		{"failed release window", "Django", false, logsQuery{Lines: 100, Since: 1700000000}, "journalctl -u myapp -n 100 --since @1700000000 --no-pager", false},
+		{"compose", "Docker Compose", false, logsQuery{Lines: 50, Follow: true}, "docker compose -p lightfold-myapp logs --tail 50 -f", true},
+		{"compose window", "Docker Compose", false, logsQuery{Lines: 100, Since: 1700000000}, "docker compose -p lightfold-myapp logs --tail 100 --since 1700000000 --no-color", true},
+		{"static site", "Astro", true, logsQuery{Lines: 20, Follow: true}, "tail -n 20 -F /var/log/nginx/myapp_access.log /var/log/nginx/myapp_error.log", true},
+		{"static site window", "Astro", true, logsQuery{Lines: 100, Since: 1700000000}, nginxLogsSinceCommand("/var/log/nginx/myapp_access.log /var/log/nginx/myapp_error.log", 100, 1700000000, false), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, sudo := appLogsCommand("myapp", tt.framework, tt.static, tt.query)
+			if got != tt.want || sudo != tt.wantSudo {
+				t.Errorf("appLogsCommand() = %q, sudo %v; want %q, sudo %v", got, sudo, tt.want, tt.wantSudo)
+			}
+		})
+	}
+}
+
+func TestNginxLogsSinceCommand(t *testing.T) {
+	dir := t.TempDir()
+	access := filepath.Join(dir, "access.log")
+	errorLog := filepath.Join(dir, "error.log")
+	os.WriteFile(access, []byte(`203.0.113.5 - - [16/Oct/2026:09:59:59 +0000] "GET /old HTTP/1.1" 200 512 "-" "curl"
+203.0.113.5 - - [16/Oct/2026:10:00:02 +0000] "GET / HTTP/1.1" 200 512 "-" "curl"
+203.0.113.5 - - [16/Oct/2026:10:00:05 +0000] "GET /missing HTTP/1.1" 404 153 "-" "curl"
+`), 0644)
+	os.WriteFile(errorLog, []byte(`2026/10/16 09:58:00 [error] 812#812: *1 old failure
+2026/10/16 10:00:03 [error] 812#812: *2 open() "/srv/myapp/current/missing" failed (2: No such file or directory)
+`), 0644)
+
+	since := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC).Unix()
+	run := func(lines int) []string {
+		cmd := exec.Command("sh", "-c", nginxLogsSinceCommand(access+" "+errorLog, lines, since, false))
+		cmd.Env = append(os.Environ(), "TZ=UTC")
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("command failed: %v", err)
+		}
+		return strings.Split(strings.TrimSpace(string(out)), "\n")
+	}
+
+	got := run(100)
+	want := []string{"GET / HTTP", "*2 open()", "GET /missing"}
+	if len(got) != len(want) {
+		t.Fatalf("lines = %q, want the 3 logged from 10:00 on, in order", got)
+	}
+	for i := range want {
+		if !strings.Contains(got[i], want[i]) {
+			t.Errorf("line %d = %q, want it to contain %q", i, got[i], want[i])
+		}
+	}
+	if got := run(1); len(got) != 1 || !strings.Contains(got[0], "GET /missing") || strings.HasPrefix(got[0], "2026/") {
+		t.Errorf("last line = %q, want the newest line without its sort stamp", got)
+	}
+}
+
+func TestLogSeverity(t *testing.T) {
+	tests := map[string]string{
+		"Oct 16 10:00:01 web app[812]: ERROR connection refused":                "error",
+		"Traceback (most recent call last):":                                    "error",
+		`time=2026-10-16T10:00:01Z level=error msg="db down"`:                   "error",
+		"2026/10/16 10:00:01 [crit] 812#812: *1 connect() failed":               "error",
+		"Oct 16 10:00:01 web app[812]: WARNING: deprecated setting":             "warning",
+		`level=warn msg="slow query"`:                                           "warning",
+		"Oct 16 10:00:01 web app[812]: GET /healthz 200 in 3ms":                 "",
+		"Oct 16 10:00:01 web app[812]: handled 0 errors, terrorist-free output": "",
+	}
+	for line, want := range tests {
+		if got := logSeverity(line); got != want {
+			t.Errorf("logSeverity(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestSeverityWriterJoinsPartialLines(t *testing.T) {
+	var out bytes.Buffer
+	w := &severityWriter{out: &out}
+	for _, chunk := range []string{"first li", "ne\nsecond", " line\nthird"} {
+		if _, err := io.WriteString(w, chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != "first line\nsecond line\n" {
+		t.Errorf("before Flush got %q", out.String())
+	}
+	w.Flush()
+	if out.String() != "first line\nsecond line\nthird\n" {
+		t.Errorf("after Flush got %q", out.String())
+	}
+}
+
+// fakeLogStreamer writes canned output to the stream and records the command
+type fakeLogStreamer struct {
+	output   string
+	exitCode int
+	commands []string
+	sudo     bool
+}
+
+func (f *fakeLogStreamer) ExecuteWithStreaming(command string, stdout, stderr io.Writer) *sshpkg.CommandResult {
+	f.commands = append(f.commands, command)
+	io.WriteString(stdout, f.output)
+	return &sshpkg.CommandResult{Stdout: f.output, ExitCode: f.exitCode}
+}
+
+func (f *fakeLogStreamer) ExecuteSudoWithStreaming(command string, stdout, stderr io.Writer) *sshpkg.CommandResult {
+	f.sudo = true
+	return f.ExecuteWithStreaming(command, stdout, stderr)
+}
+
+func TestStreamAppLogs(t *testing.T) {
+	server := &fakeLogStreamer{output: "booted\nlistening", exitCode: 124}
+	var out bytes.Buffer
+	result := streamAppLogs(server, "timeout 60 journalctl -u myapp -n 20 -f", false, &out)
+	if result.ExitCode != 0 {
+		t.Errorf("a timed tail ending should not be an error, got exit code %d", result.ExitCode)
+	}
+	if !strings.Contains(out.String(), "booted\n") || !strings.HasSuffix(out.String(), "listening\n") {
+		t.Errorf("streamed output = %q", out.String())
+	}
+	if server.sudo {
+		t.Error("journalctl should not run through sudo")
+	}
+
+	server = &fakeLogStreamer{exitCode: 124}
+	if result := streamAppLogs(server, "journalctl -u myapp -n 20 --no-pager", false, &out); result.ExitCode != 124 {
+		t.Errorf("exit code 124 from an untimed command = %d, want it kept", result.ExitCode)
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	maintenanceTargetFlag     string
+	maintenanceAllowFlag      []string
+	maintenanceRetryAfterFlag time.Duration
+
+	maintenanceSuccessStyle = style.Success
+	maintenanceMutedStyle   = style.Muted
+	maintenanceValueStyle   = style.Value
+	maintenanceWarningStyle = style.WarningText
+	maintenanceErrorStyle   = style.ErrorText
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Serve a maintenance page instead of the app",
+	Long: `Maintenance mode answers every request with a maintenance page and a 503
+Service Unavailable carrying Retry-After, while the app keeps running behind
+nginx. /healthz stays proxied to the app; set other paths that must keep
+working with deploy.maintenance_allow or --allow.
+
+The page is maintenance.html from the project (or the app's subdirectory)
+when there is one, otherwise a plain bundled page. It is uploaded to the
+app's shared/maintenance directory on the server.
+
+While a target is in maintenance, status shows it and push refuses to deploy
+without --force.`,
+}
+
+var maintenanceOnCmd = &cobra.Command{
+	Use:   "on [PROJECT_PATH]",
+	Short: "Put the app into maintenance mode",
+	Long: `Copy the app's nginx site aside and rewrite it to serve the maintenance page
+with a 503 for every path except the allowed ones. Running it again while in
+maintenance updates the page and the allowed paths.
+
+Examples:
+  lightfold maintenance on --target myapp
+  lightfold maintenance on --allow /healthz,/api/status --retry-after 30m`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target, targetName := resolveMaintenanceTarget(args)
+
+		opts := deploy.MaintenanceOptions{
+			Allow:      maintenanceAllowPaths(&target, maintenanceAllowFlag, cmd.Flags().Changed("allow")),
+			RetryAfter: maintenanceRetryAfterFlag,
+		}
+		if err := deploy.ValidateMaintenancePaths(opts.Allow); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", maintenanceErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		page, pagePath, err := loadMaintenancePage(&target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", maintenanceErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		opts.Page = page
+
+		enabled, err := forEachMaintenanceServer(target, targetName, func(ip string, executor *deploy.Executor) error {
+			if err := executor.EnableMaintenance(opts); err != nil {
+				return err
+			}
+			fmt.Printf("%s %s\n", maintenanceSuccessStyle.Render(style.Check()), maintenanceMutedStyle.Render(fmt.Sprintf("Serving the maintenance page on %s", ip)))
+			return nil
+		})
+		// A server already serving the page keeps push guarded even when a
+		// later server failed
+		if enabled > 0 {
+			if markErr := state.MarkMaintenance(targetName, opts.Allow); markErr != nil {
+				fmt.Printf("%s %s\n", maintenanceWarningStyle.Render(style.Warn()), maintenanceMutedStyle.Render(fmt.Sprintf("Failed to record maintenance mode: %v", markErr)))
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", maintenanceErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			if enabled > 0 {
+				fmt.Fprintf(os.Stderr, "Run 'lightfold maintenance off --target %s' to serve the app again\n", targetName)
+			}
+			exit(1)
+		}
+
+		fmt.Printf("\n%s %s\n", maintenanceWarningStyle.Render("MAINTENANCE"), maintenanceValueStyle.Render(targetName))
+		if pagePath != "" {
+			fmt.Printf("%s %s\n", maintenanceMutedStyle.Render("Page:"), pagePath)
+		} else {
+			fmt.Printf("%s %s\n", maintenanceMutedStyle.Render("Page:"), maintenanceMutedStyle.Render("bundled default (add "+deploy.MaintenancePageFile+" to the project to customise it)"))
+		}
+		fmt.Printf("%s %s\n", maintenanceMutedStyle.Render("Still proxied:"), maintenanceAllowSummary(opts.Allow))
+		fmt.Printf("%s\n", maintenanceMutedStyle.Render(fmt.Sprintf("Run 'lightfold maintenance off --target %s' to serve the app again", targetName)))
+	},
+}
+
+var maintenanceOffCmd = &cobra.Command{
+	Use:   "off [PROJECT_PATH]",
+	Short: "Serve the app again",
+	Long: `Put back the nginx site copied aside by 'maintenance on' and reload nginx.
+If the site was regenerated in between, e.g. by configure, the maintenance
+rules are removed from it instead.
+
+Examples:
+  lightfold maintenance off --target myapp`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target, targetName := resolveMaintenanceTarget(args)
+
+		_, err := forEachMaintenanceServer(target, targetName, func(ip string, executor *deploy.Executor) error {
+			restored, err := executor.DisableMaintenance()
+			if err != nil {
+				return err
+			}
+			message := fmt.Sprintf("Removed the maintenance page on %s", ip)
+			if restored {
+				message = fmt.Sprintf("Restored the nginx site on %s", ip)
+			}
+			fmt.Printf("%s %s\n", maintenanceSuccessStyle.Render(style.Check()), maintenanceMutedStyle.Render(message))
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", maintenanceErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		if err := state.ClearMaintenance(targetName); err != nil {
+			fmt.Printf("%s %s\n", maintenanceWarningStyle.Render(style.Warn()), maintenanceMutedStyle.Render(fmt.Sprintf("Failed to clear maintenance mode: %v", err)))
+		}
+		fmt.Printf("\n%s %s\n", maintenanceSuccessStyle.Render(style.Check()+" Serving the app again on"), maintenanceValueStyle.Render(targetName))
+	},
+}
+
+// resolveMaintenanceTarget resolves the target and exits unless it is served
+// by its own running servers
+func resolveMaintenanceTarget(args []string) (config.TargetConfig, string) {
+	cfg := loadConfigOrExit()
+
+	var pathArg string
+	if len(args) > 0 {
+		pathArg = args[0]
+	}
+	target, targetName := resolveTarget(cfg, maintenanceTargetFlag, pathArg)
+
+	if !target.RequiresSSHDeployment() {
+		fmt.Fprintf(os.Stderr, "%s\n", maintenanceErrorStyle.Render(fmt.Sprintf("Error: maintenance mode is not supported on %s", target.Provider)))
+		exit(1)
+	}
+	exitIfPaused(targetName)
+	return target, targetName
+}
+
+// forEachMaintenanceServer runs fn against every server of the target in
+// turn, stopping at the first error. It returns how many servers fn
+// succeeded on.
+func forEachMaintenanceServer(target config.TargetConfig, targetName string, fn func(ip string, executor *deploy.Executor) error) (int, error) {
+	serverTargets, err := target.ServerTargets()
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, serverTarget := range serverTargets {
+		providerCfg, err := serverTarget.GetSSHProviderConfig()
+		if err != nil {
+			return done, err
+		}
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			return done, fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+		}
+
+		appName := resolveAppName(&serverTarget, targetName, sshExecutor)
+		executor := deploy.NewExecutor(sshExecutor, appName, serverTarget.ProjectPath, nil)
+		executor.SetBaseDir(serverTarget.RemoteBaseDir())
+		err = fn(providerCfg.GetIP(), executor)
+		sshExecutor.Disconnect()
+		if err != nil {
+			return done, fmt.Errorf("%s: %w", providerCfg.GetIP(), err)
+		}
+		done++
+	}
+	return done, nil
+}
+
+// maintenanceAllowPaths returns the paths kept proxied: the --allow flag
+// when given, else deploy.maintenance_allow, else /healthz
+func maintenanceAllowPaths(target *config.TargetConfig, flagPaths []string, flagSet bool) []string {
+	if flagSet {
+		var paths []string
+		for _, p := range flagPaths {
+			paths = append(paths, parseMaintenanceAllow(p)...)
+		}
+		return paths
+	}
+	if target.Deploy != nil && len(target.Deploy.MaintenanceAllow) > 0 {
+		return target.Deploy.MaintenanceAllow
+	}
+	return deploy.DefaultMaintenanceAllow
+}
+
+// loadMaintenancePage reads the project's maintenance.html, looking in the
+// app's subdirectory first. It returns "" when the project has none.
+func loadMaintenancePage(target *config.TargetConfig) (string, string, error) {
+	candidates := []string{filepath.Join(target.ProjectPath, deploy.MaintenancePageFile)}
+	if subdir := target.AppSubdir(); subdir != "" {
+		candidates = append([]string{filepath.Join(target.ProjectPath, subdir, deploy.MaintenancePageFile)}, candidates...)
+	}
+	for _, candidate := range candidates {
+		content, err := os.ReadFile(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %s: %w", candidate, err)
+		}
+		return string(content), candidate, nil
+	}
+	return "", "", nil
+}
+
+func maintenanceAllowSummary(allow []string) string {
+	if len(allow) == 0 {
+		return maintenanceMutedStyle.Render("nothing")
+	}
+	return strings.Join(allow, ", ")
+}
+
+// maintenanceBanner is the status line of a target in maintenance, e.g.
+// "MAINTENANCE since 2024-01-02 15:04 CET (2h ago) by dev@laptop"
+func maintenanceBanner(m *state.Maintenance) string {
+	banner := style.Warn() + " MAINTENANCE"
+	if !m.Since.IsZero() {
+		banner += " since " + timefmt.Human(m.Since)
+	}
+	if m.By != "" {
+		banner += " by " + m.By
+	}
+	return banner
+}
+
+// exitIfMaintenance stops a deploy to a target in maintenance mode unless
+// force is set, in which case it only warns
+func exitIfMaintenance(targetName string, force bool) {
+	if !state.InMaintenance(targetName) {
+		return
+	}
+	if force {
+		fmt.Printf("%s %s\n", maintenanceWarningStyle.Render(style.Warn()), maintenanceWarningStyle.Render(fmt.Sprintf("Target '%s' is in maintenance mode; deploying behind the maintenance page", targetName)))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s target '%s' is in maintenance mode\n", maintenanceErrorStyle.Render("Error:"), targetName)
+	fmt.Fprintf(os.Stderr, "Run 'lightfold maintenance off --target %s' first, or pass --force to deploy behind the maintenance page\n", targetName)
+	exit(1)
+}
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+	maintenanceCmd.AddCommand(maintenanceOnCmd)
+	maintenanceCmd.AddCommand(maintenanceOffCmd)
+
+	maintenanceCmd.PersistentFlags().StringVar(&maintenanceTargetFlag, "target", "", "Target name (defaults to current directory)")
+	maintenanceOnCmd.Flags().StringSliceVar(&maintenanceAllowFlag, "allow", nil, "Paths still proxied to the app, e.g. /healthz,/api/status (defaults to deploy.maintenance_allow, then /healthz)")
+	maintenanceOnCmd.Flags().DurationVar(&maintenanceRetryAfterFlag, "retry-after", deploy.DefaultMaintenanceRetryAfter, "Retry-After sent with the 503")
+}
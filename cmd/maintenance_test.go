@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+)
+
+func TestMaintenanceAllowPaths(t *testing.T) {
+	var target config.TargetConfig
+	if got := maintenanceAllowPaths(&target, nil, false); !reflect.DeepEqual(got, deploy.DefaultMaintenanceAllow) {
+		t.Errorf("default = %v, want %v", got, deploy.DefaultMaintenanceAllow)
+	}
+
+	target.Deploy = &config.DeploymentOptions{MaintenanceAllow: []string{"/api/status"}}
+	if got := maintenanceAllowPaths(&target, nil, false); !reflect.DeepEqual(got, []string{"/api/status"}) {
+		t.Errorf("configured = %v, want the target's paths", got)
+	}
+
+	got := maintenanceAllowPaths(&target, []string{" /healthz", "/ping ", ""}, true)
+	if want := []string{"/healthz", "/ping"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--allow = %v, want %v", got, want)
+	}
+}
+
+func TestLoadMaintenancePage(t *testing.T) {
+	projectPath := t.TempDir()
+	target := config.TargetConfig{ProjectPath: projectPath, Deploy: &config.DeploymentOptions{Subdir: "web"}}
+
+	page, path, err := loadMaintenancePage(&target)
+	if err != nil || page != "" || path != "" {
+		t.Fatalf("loadMaintenancePage() without a page = %q, %q, %v", page, path, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(projectPath, deploy.MaintenancePageFile), []byte("root page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if page, _, _ := loadMaintenancePage(&target); page != "root page" {
+		t.Errorf("loadMaintenancePage() = %q, want the project's page", page)
+	}
+
+	if err := os.MkdirAll(filepath.Join(projectPath, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectPath, "web", deploy.MaintenancePageFile), []byte("app page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if page, path, _ := loadMaintenancePage(&target); page != "app page" || path != filepath.Join(projectPath, "web", deploy.MaintenancePageFile) {
+		t.Errorf("loadMaintenancePage() = %q from %s, want the app subdirectory's page", page, path)
+	}
+}
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/providers"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pauseTargetFlag  string
+	pauseForceFlag   bool
+	resumeTargetFlag string
+
+	pauseSuccessStyle = style.Success
+	pauseMutedStyle   = style.Muted
+	pauseValueStyle   = style.Value
+	pauseWarningStyle = style.WarningText
+	pauseErrorStyle   = style.Error
+)
+
+// powerServer is one of a target's servers, with the per-server target copy
+// used to reach it over SSH
+type powerServer struct {
+	target   config.TargetConfig
+	serverID string
+	ip       string
+	index    int // Index into TargetConfig.Servers; -1 for the primary server
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause [PROJECT_PATH]",
+	Short: "Stop the app and power off the target's servers",
+	Long: `Stop the app service and power off every server of the target through the
+provider API, keeping their disks. The target is marked paused: status shows
+it, push refuses to deploy to it and deploy --all skips it.
+
+Most providers keep billing powered-off servers; AWS stops billing compute
+for stopped instances.
+
+A server other apps are deployed to is not powered off unless --force is
+given, since they would go down with it.
+
+Examples:
+  lightfold pause --target staging
+  lightfold resume --target staging`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, pauseTargetFlag, pathArg)
+
+		if state.IsPaused(targetName) {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Target '%s' is already paused", targetName)))
+			return
+		}
+
+		if err := pauseTarget(target, targetName, pauseForceFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		fmt.Printf("\n%s %s\n", pauseSuccessStyle.Render(style.Check()+" Paused target"), pauseValueStyle.Render(targetName))
+		fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Run 'lightfold resume --target %s' to start it again", targetName)))
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [PROJECT_PATH]",
+	Short: "Power a paused target back on and start the app",
+	Long: `Power on the servers of a paused target, wait for SSH, make sure the app
+service is running and refresh the stored IP in case the provider assigned a
+new one.
+
+Examples:
+  lightfold resume --target staging`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, resumeTargetFlag, pathArg)
+
+		if !state.IsPaused(targetName) {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Target '%s' is not paused", targetName)))
+			return
+		}
+
+		ipChanged, err := resumeTarget(target, targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		fmt.Printf("\n%s %s\n", pauseSuccessStyle.Render(style.Check()+" Resumed target"), pauseValueStyle.Render(targetName))
+		if ipChanged {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Run 'lightfold sync --target %s --fix' to update server state and re-apply the domain", targetName)))
+		}
+	},
+}
+
+// poweredOffStatuses are the provider server statuses of a powered-off server
+var poweredOffStatuses = map[string]bool{"off": true, "stopped": true, "offline": true, "halted": true}
+
+// pauseTarget stops the app and powers off every server of the target, then
+// marks it paused. Servers that are already off, e.g. by a schedule's stop
+// timer, are skipped. Servers shared with other apps are refused unless force.
+func pauseTarget(target config.TargetConfig, targetName string, force bool) error {
+	power, servers, err := targetPowerPlan(target, targetName)
+	if err != nil {
+		return err
+	}
+	if !force {
+		if err := refuseSharedServers(servers, targetName, "pause"); err != nil {
+			return err
+		}
+	}
+	provider := power.(providers.Provider)
+
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	appName := utils.RemoteAppName(&target, targetName)
+	ctx := context.Background()
+
+	for _, server := range servers {
+		if current, err := provider.GetServer(ctx, server.serverID); err == nil && poweredOffStatuses[strings.ToLower(current.Status)] {
+			fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("%s is already powered off", server.ip)))
+			continue
+		}
+
+		if err := stopServerApp(server, appName, &detection); err != nil {
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), pauseMutedStyle.Render(fmt.Sprintf("Could not stop %s on %s before powering off: %v", appName, server.ip, err)))
+		} else {
+			fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("Stopped %s on %s", appName, server.ip)))
+		}
+
+		if err := power.PowerOff(ctx, server.serverID); err != nil {
+			return fmt.Errorf("failed to power off %s: %w", server.ip, err)
+		}
+		fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("Powered off %s", server.ip)))
+	}
+
+	if err := state.MarkPaused(targetName); err != nil {
+		return fmt.Errorf("failed to mark target paused: %w", err)
+	}
+	return nil
+}
+
+// resumeTarget powers the target's servers on unless they are running, makes
+// sure the app runs on each and clears the paused mark. It reports whether a server came back
+// with a new IP.
+func resumeTarget(target config.TargetConfig, targetName string) (bool, error) {
+	power, servers, err := targetPowerPlan(target, targetName)
+	if err != nil {
+		return false, err
+	}
+	provider := power.(providers.Provider)
+
+	ctx := context.Background()
+	ipChanged := false
+	for _, server := range servers {
+		current, err := provider.GetServer(ctx, server.serverID)
+		if err != nil || poweredOffStatuses[strings.ToLower(current.Status)] {
+			if err := power.PowerOn(ctx, server.serverID); err != nil {
+				return ipChanged, fmt.Errorf("failed to power on %s: %w", server.ip, err)
+			}
+		}
+
+		active, err := provider.WaitForActive(ctx, server.serverID, 5*time.Minute)
+		if err != nil {
+			return ipChanged, fmt.Errorf("%s did not come back: %w", server.ip, err)
+		}
+		fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("Powered on %s", server.ip)))
+
+		if active.PrimaryIP() != "" && active.PrimaryIP() != server.ip {
+			if err := recordResumedIP(&target, targetName, server, active.PrimaryIP()); err != nil {
+				return ipChanged, fmt.Errorf("failed to save the new IP of %s: %w", server.ip, err)
+			}
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), pauseMutedStyle.Render(fmt.Sprintf("IP changed: %s %s %s", server.ip, style.Arrow(), active.PrimaryIP())))
+			ipChanged = true
+		}
+	}
+
+	servers, err = targetServers(target, targetName)
+	if err != nil {
+		return ipChanged, err
+	}
+
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	appName := utils.RemoteAppName(&target, targetName)
+	for _, server := range servers {
+		if err := startServerApp(server, appName, &detection); err != nil {
+			return ipChanged, err
+		}
+		fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("%s is running on %s", appName, server.ip)))
+	}
+
+	if err := state.MarkResumed(targetName); err != nil {
+		return ipChanged, fmt.Errorf("failed to clear paused state: %w", err)
+	}
+	return ipChanged, nil
+}
+
+// targetPowerPlan returns the provider that powers the target's servers and
+// the servers to act on, primary first
+func targetPowerPlan(target config.TargetConfig, targetName string) (providers.PowerManager, []powerServer, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+	token := tokens.GetToken(target.Provider)
+	if token == "" {
+		return nil, nil, fmt.Errorf("no API token for provider '%s'; pause needs the provider API to power servers off", target.Provider)
+	}
+
+	provider, err := providers.GetProvider(target.Provider, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	power, ok := provider.(providers.PowerManager)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s does not support powering servers off", provider.DisplayName())
+	}
+
+	servers, err := targetServers(target, targetName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return power, servers, nil
+}
+
+// otherDeployedApps returns the apps on the server deployed by other targets
+func otherDeployedApps(serverState *state.ServerState, targetName string) []state.DeployedApp {
+	var others []state.DeployedApp
+	for _, app := range serverState.DeployedApps {
+		if app.TargetName != targetName {
+			others = append(others, app)
+		}
+	}
+	return others
+}
+
+// refuseSharedServers returns an error naming the first server that other
+// targets' apps also run on, since powering it off takes them down too
+func refuseSharedServers(servers []powerServer, targetName, command string) error {
+	for _, server := range servers {
+		serverState, err := state.GetServerState(server.ip)
+		if err != nil {
+			continue
+		}
+		others := otherDeployedApps(serverState, targetName)
+		if len(others) == 0 {
+			continue
+		}
+		names := make([]string, len(others))
+		for i, app := range others {
+			names[i] = app.TargetName
+		}
+		return fmt.Errorf("%s also runs %s; %s would power them off too (use --force to power off the server anyway)",
+			server.ip, strings.Join(names, ", "), command)
+	}
+	return nil
+}
+
+// targetServers lists the target's servers with their provider server IDs
+func targetServers(target config.TargetConfig, targetName string) ([]powerServer, error) {
+	serverTargets, err := target.ServerTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]powerServer, 0, len(serverTargets))
+	for i, serverTarget := range serverTargets {
+		providerCfg, err := serverTarget.GetSSHProviderConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		server := powerServer{target: serverTarget, ip: providerCfg.GetIP(), index: i - 1}
+		if i == 0 {
+			server.serverID = providerCfg.GetServerID()
+			if server.serverID == "" {
+				server.serverID = state.GetProvisionedID(targetName)
+			}
+		} else {
+			server.serverID = target.Servers[i-1].ServerID
+		}
+		if server.serverID == "" {
+			return nil, fmt.Errorf("server %s has no provider server ID; only provisioned servers can be managed through the provider API", server.ip)
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// recordResumedIP saves the address a server came back with
+func recordResumedIP(target *config.TargetConfig, targetName string, server powerServer, newIP string) error {
+	if server.index < 0 {
+		handler, ok := stateHandlerFor(target.Provider)
+		if !ok {
+			return fmt.Errorf("IP recovery is not supported for provider '%s'", target.Provider)
+		}
+		if err := handler.recoverFunc(target, targetName, server.serverID); err != nil {
+			return err
+		}
+		if target.ServerIP != "" {
+			target.ServerIP = newIP
+		}
+	} else {
+		target.Servers[server.index].IP = newIP
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.SetTarget(targetName, *target); err != nil {
+		return err
+	}
+	return cfg.SaveConfig()
+}
+
+func connectPowerServer(server powerServer, retries int) (*sshpkg.Executor, error) {
+	providerCfg, err := server.target.GetSSHProviderConfig()
+	if err != nil {
+		return nil, err
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := sshExecutor.Connect(retries, 5*time.Second); err != nil {
+		return nil, err
+	}
+	return sshExecutor, nil
+}
+
+func stopServerApp(server powerServer, appName string, detection *detector.Detection) error {
+	sshExecutor, err := connectPowerServer(server, 3)
+	if err != nil {
+		return err
+	}
+	defer sshExecutor.Disconnect()
+
+	executor := deploy.NewExecutor(sshExecutor, appName, server.target.ProjectPath, detection)
+	executor.SetBaseDir(server.target.RemoteBaseDir())
+	return executor.StopService()
+}
+
+// startServerApp waits for SSH on a server that was just powered on and makes
+// sure the app service is running
+func startServerApp(server powerServer, appName string, detection *detector.Detection) error {
+	sshExecutor, err := connectPowerServer(server, 36)
+	if err != nil {
+		return fmt.Errorf("%s is not reachable over SSH: %w", server.ip, err)
+	}
+	defer sshExecutor.Disconnect()
+
+	executor := deploy.NewExecutor(sshExecutor, appName, server.target.ProjectPath, detection)
+	executor.SetBaseDir(server.target.RemoteBaseDir())
+	if running, _ := executor.GetServiceStatus(); running {
+		return nil
+	}
+	if err := executor.StartService(); err != nil {
+		return fmt.Errorf("%s on %s: %w", appName, server.ip, err)
+	}
+	return nil
+}
+
+// exitIfPaused stops commands that need the target's servers running
+func exitIfPaused(targetName string) {
+	if !state.IsPaused(targetName) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s target '%s' is paused\n", pauseErrorStyle.Render("Error:"), targetName)
+	fmt.Fprintf(os.Stderr, "Run 'lightfold resume --target %s' first\n", targetName)
+	exit(1)
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	pauseCmd.Flags().StringVar(&pauseTargetFlag, "target", "", "Target name (defaults to current directory)")
+	pauseCmd.Flags().BoolVar(&pauseForceFlag, "force", false, "Power off servers other apps are deployed to as well")
+	resumeCmd.Flags().StringVar(&resumeTargetFlag, "target", "", "Target name (defaults to current directory)")
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+func TestRefuseSharedServers(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	servers := []powerServer{{ip: "203.0.113.10", serverID: "123", index: -1}}
+	if err := state.RegisterApp("203.0.113.10", state.DeployedApp{TargetName: "staging", AppName: "staging", Port: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := refuseSharedServers(servers, "staging", "pause"); err != nil {
+		t.Errorf("refuseSharedServers() with only the target's app = %v, want nil", err)
+	}
+
+	if err := state.RegisterApp("203.0.113.10", state.DeployedApp{TargetName: "blog", AppName: "blog", Port: 3001}); err != nil {
+		t.Fatal(err)
+	}
+	err := refuseSharedServers(servers, "staging", "pause")
+	if err == nil || !strings.Contains(err.Error(), "also runs blog") || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("refuseSharedServers() on a shared server = %v, want a refusal naming blog", err)
+	}
+}
@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/detector"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssl/certbot"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var previewTargetFlag string
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Manage preview deployments of static sites",
+	Long: `Preview deployments serve a build of a static site next to production, e.g.
+for a pull request. Create or update one with 'lightfold push --preview NAME'.
+
+A preview is served at NAME.preview.<domain> when *.preview.<domain> resolves,
+otherwise at preview.<domain>/NAME/. Previews never touch the current release
+or the production nginx site, and are removed by the first regular push after
+their TTL (--preview-ttl, 7 days by default) runs out.`,
+}
+
+var previewListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the target's preview deployments",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		_, targetName := resolveTarget(cfg, previewTargetFlag, "")
+
+		targetState, err := state.LoadState(targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		if jsonOutput {
+			previews := targetState.Previews
+			if previews == nil {
+				previews = map[string]state.Preview{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(previews)
+			return
+		}
+
+		printPreviews(targetState.Previews)
+	},
+}
+
+var previewRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a preview deployment from the server",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, previewTargetFlag, "")
+
+		if err := removePreviews(target, targetName, []string{args[0]}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render(fmt.Sprintf("Removed preview %s", args[0])))
+	},
+}
+
+func printPreviews(previews map[string]state.Preview) {
+	if len(previews) == 0 {
+		fmt.Printf("%s\n", pushMutedStyle.Render("No preview deployments"))
+		return
+	}
+
+	names := make([]string, 0, len(previews))
+	for name := range previews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	for _, name := range names {
+		preview := previews[name]
+		commit := preview.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		expiry := "never expires"
+		switch {
+		case preview.Expired(now):
+			expiry = "expired"
+		case !preview.ExpiresAt.IsZero():
+			expiry = "expires " + timefmt.HumanAt(preview.ExpiresAt, now)
+		}
+		fmt.Printf("  %s  %s\n", pushValueStyle.Render(name), preview.URL)
+		fmt.Printf("    %s\n", pushMutedStyle.Render(fmt.Sprintf("commit %s, updated %s, %s", commit, timefmt.Relative(preview.UpdatedAt, now), expiry)))
+	}
+}
+
+// previewServer returns the connection details of the server previews live
+// on: the target's primary server
+func previewServer(target config.TargetConfig) (config.ProviderConfig, error) {
+	if target.Provider == "flyio" || target.Provider == "s3" {
+		return nil, fmt.Errorf("preview deployments are only available for SSH-based targets (provider: %s)", target.Provider)
+	}
+	return target.GetSSHProviderConfig()
+}
+
+// pushPreview builds the project as the named preview on the target's primary
+// server and records it in the target state
+func pushPreview(cfg *config.Config, target config.TargetConfig, targetName, name, commit string, ttl time.Duration) error {
+	if err := deploy.ValidatePreviewName(name); err != nil {
+		return err
+	}
+	providerCfg, err := previewServer(target)
+	if err != nil {
+		return err
+	}
+	if target.Domain == nil || target.Domain.Domain == "" || target.Domain.PathPrefix != "" {
+		return fmt.Errorf("preview deployments need a domain; run 'lightfold domain add' first")
+	}
+
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	projectName := utils.RemoteAppName(&target, targetName)
+	packer := deploy.NewExecutor(nil, projectName, target.ProjectPath, &detection)
+	if !packer.SupportsPreviews() {
+		return fmt.Errorf("preview deployments are only supported for static sites (detected %s)", detection.Framework)
+	}
+
+	tmpTarball := fmt.Sprintf("/tmp/lightfold-%s-preview.tar.gz", projectName)
+	packer.SetPackWorkers(cfg.PackWorkers)
+	if err := packer.CreateReleaseTarball(tmpTarball); err != nil {
+		return fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer os.Remove(tmpTarball)
+	fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Creating release tarball..."))
+
+	if !confirmReleaseSecrets(packer.ReleaseSecrets(), nil) {
+		fmt.Println(pushMutedStyle.Render("Push cancelled."))
+		return nil
+	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+	}
+
+	appName := resolveAppName(&target, targetName, sshExecutor)
+	executor := deploy.NewExecutorWithOptions(sshExecutor, appName, target.ProjectPath, &detection, target.Deploy)
+
+	domain := target.Domain.Domain
+	site := deploy.PreviewSite{Name: name, Domain: domain, PathPrefix: !previewWildcardDNS(domain)}
+	if target.Domain.SSLEnabled {
+		site.SSLEnabled = true
+		if !site.PathPrefix {
+			if err := ensurePreviewWildcardCertificate(sshExecutor, target); err != nil {
+				fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), pushMutedStyle.Render(fmt.Sprintf("Serving the preview over HTTP: %v", err)))
+				site.SSLEnabled = false
+			} else {
+				site.CertPath = fmt.Sprintf("/etc/letsencrypt/live/%s/fullchain.pem", deploy.PreviewHost(domain))
+				site.KeyPath = fmt.Sprintf("/etc/letsencrypt/live/%s/privkey.pem", deploy.PreviewHost(domain))
+			}
+		}
+	}
+
+	if err := executor.DeployPreview(tmpTarball, site, target.Deploy.EnvVars); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Uploading and building preview..."))
+
+	// certbot's nginx plugin needs the preview host's site in place first
+	if site.PathPrefix && site.SSLEnabled {
+		if err := ensurePreviewHostCertificate(sshExecutor, target); err != nil {
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), pushMutedStyle.Render(fmt.Sprintf("Serving the preview over HTTP: %v", err)))
+			site.SSLEnabled = false
+		}
+	}
+
+	preview := state.Preview{Commit: commit, URL: site.URL()}
+	if ttl > 0 {
+		preview.ExpiresAt = time.Now().Add(ttl)
+	}
+	if err := state.RecordPreview(targetName, name, preview); err != nil {
+		fmt.Printf("Warning: failed to record preview in state: %v\n", err)
+	}
+
+	fmt.Printf("\n%s %s\n", pushSuccessStyle.Render(style.Check()+" Preview ready:"), pushValueStyle.Render(site.URL()))
+	if site.PathPrefix {
+		fmt.Printf("%s\n", pushMutedStyle.Render(fmt.Sprintf("*.%s does not resolve, so the preview is served under a path; add a wildcard record to give previews their own subdomains", deploy.PreviewHost(domain))))
+	}
+	return nil
+}
+
+// previewWildcardDNS reports whether *.preview.<domain> resolves, by looking
+// up a name no one creates on purpose
+func previewWildcardDNS(domain string) bool {
+	addrs, err := lookupHost(fmt.Sprintf("lightfold-probe-%d.%s", time.Now().UnixNano()%1000000, deploy.PreviewHost(domain)))
+	return err == nil && len(addrs) > 0
+}
+
+// ensurePreviewWildcardCertificate issues the *.preview.<domain> certificate
+// through the target's DNS provider. Without one there is no way to answer the
+// DNS challenge wildcard certificates need.
+func ensurePreviewWildcardCertificate(sshExecutor *sshpkg.Executor, target config.TargetConfig) error {
+	dnsProvider := target.Domain.DNSProvider
+	if dnsProvider == "" {
+		return fmt.Errorf("a wildcard certificate needs a DNS provider; run 'lightfold domain add --domain %s --dns-provider <provider>'", target.Domain.Domain)
+	}
+
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return fmt.Errorf("failed to load tokens: %w", err)
+	}
+	token := tokens.GetToken(dnsProvider)
+	if token == "" {
+		return fmt.Errorf("no API token for %s; run 'lightfold config set-token %s'", dnsProvider, dnsProvider)
+	}
+
+	manager := certbot.NewManager(sshExecutor)
+	manager.SetStaging(target.Domain.SSLStaging)
+	return manager.IssueWildcardCertificate(deploy.PreviewHost(target.Domain.Domain), target.Domain.Email, dnsProvider, token)
+}
+
+// ensurePreviewHostCertificate issues a certificate for preview.<domain>,
+// which serves path prefix previews
+func ensurePreviewHostCertificate(sshExecutor *sshpkg.Executor, target config.TargetConfig) error {
+	host := deploy.PreviewHost(target.Domain.Domain)
+	manager := certbot.NewManager(sshExecutor)
+	manager.SetStaging(target.Domain.SSLStaging)
+
+	if exists, _ := manager.CertificateExists([]string{host}); exists {
+		return nil
+	}
+	return manager.IssueCertificate(host, target.Domain.Email)
+}
+
+// removePreviews deletes previews from the target's primary server and state
+func removePreviews(target config.TargetConfig, targetName string, names []string) error {
+	providerCfg, err := previewServer(target)
+	if err != nil {
+		return err
+	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+	}
+
+	executor := deploy.NewExecutor(sshExecutor, resolveAppName(&target, targetName, sshExecutor), target.ProjectPath, nil)
+	executor.SetBaseDir(target.RemoteBaseDir())
+	for _, name := range names {
+		if err := executor.RemovePreview(name); err != nil {
+			return err
+		}
+		if err := state.RemovePreview(targetName, name); err != nil {
+			fmt.Printf("Warning: failed to update state: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// expirePreviews removes the previews whose TTL ran out. It runs after regular
+// pushes and only warns, so an unreachable preview never fails a deploy.
+func expirePreviews(target config.TargetConfig, targetName string) {
+	expired := state.ExpiredPreviews(targetName, time.Now())
+	if len(expired) == 0 {
+		return
+	}
+
+	if err := removePreviews(target, targetName, expired); err != nil {
+		fmt.Printf("Warning: failed to remove expired previews: %v\n", err)
+		return
+	}
+	fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Removed expired previews: "+strings.Join(expired, ", ")))
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+	previewCmd.AddCommand(previewListCmd)
+	previewCmd.AddCommand(previewRemoveCmd)
+
+	previewCmd.PersistentFlags().StringVar(&previewTargetFlag, "target", "", "Target name (defaults to current directory)")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"os"
+	"strings"
+)
+
+var confirmProtectedFlag string
+
+// confirmProtectedTarget guards push, deploy and destroy of targets marked
+// protected: the target name has to be typed, or passed with
+// --confirm-protected when there is no terminal to type it in
+func confirmProtectedTarget(target config.TargetConfig, targetName string) error {
+	if !target.Protected {
+		return nil
+	}
+
+	if confirmProtectedFlag != "" {
+		if confirmProtectedFlag != targetName {
+			return fmt.Errorf("--confirm-protected %q does not match the target name %q", confirmProtectedFlag, targetName)
+		}
+		return nil
+	}
+
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return fmt.Errorf("target '%s' is protected; pass --confirm-protected %s to run without a terminal", targetName, targetName)
+	}
+
+	fmt.Printf("%s\n", pauseWarningStyle.Render(fmt.Sprintf("%s Target '%s' is protected.", style.Warn(), targetName)))
+	fmt.Printf("Type the target name '%s' to continue: ", targetName)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(response) != targetName {
+		return fmt.Errorf("target name did not match; nothing was changed")
+	}
+	return nil
+}
+
+// exitUnlessProtectedConfirmed exits when confirmProtectedTarget refuses
+func exitUnlessProtectedConfirmed(target config.TargetConfig, targetName string) {
+	if err := confirmProtectedTarget(target, targetName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+}
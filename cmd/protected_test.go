@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"strings"
+	"testing"
+)
+
+func TestConfirmProtectedTarget(t *testing.T) {
+	// Without a terminal to prompt on, the flag is the only way through
+	skipInteractive = true
+	defer func() { confirmProtectedFlag = ""; skipInteractive = false }()
+
+	tests := []struct {
+		name      string
+		protected bool
+		flag      string
+		wantErr   string
+	}{
+		{name: "unprotected target", protected: false},
+		{name: "matching confirmation", protected: true, flag: "prod"},
+		{name: "wrong name", protected: true, flag: "staging", wantErr: `does not match the target name "prod"`},
+		{name: "no terminal", protected: true, wantErr: "--confirm-protected prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmProtectedFlag = tt.flag
+			err := confirmProtectedTarget(config.TargetConfig{Protected: tt.protected}, "prod")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("confirmProtectedTarget() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("confirmProtectedTarget() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"lightfold/cmd/ui/sequential"
 	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
 	sshpkg "lightfold/pkg/ssh"
 	"os"
 	"path/filepath"
@@ -21,9 +22,10 @@ type providerBootstrap struct {
 }
 
 type provisionInputs struct {
-	Region string
-	Size   string
-	Image  string
+	Region  string
+	Size    string
+	Image   string
+	IPStack string
 }
 
 var providerBootstraps = []*providerBootstrap{
@@ -50,6 +52,7 @@ var providerBootstraps = []*providerBootstrap{
 			return &config.DigitalOceanConfig{
 				Region:      opts.Region,
 				Size:        opts.Size,
+				IPStack:     opts.IPStack,
 				SSHKey:      sshKeyPath,
 				SSHKeyName:  sshKeyName,
 				Username:    "deploy",
@@ -80,6 +83,7 @@ var providerBootstraps = []*providerBootstrap{
 			return &config.HetznerConfig{
 				Location:    opts.Region,
 				ServerType:  opts.Size,
+				IPStack:     opts.IPStack,
 				SSHKey:      sshKeyPath,
 				SSHKeyName:  sshKeyName,
 				Username:    "deploy",
@@ -110,6 +114,7 @@ var providerBootstraps = []*providerBootstrap{
 			return &config.VultrConfig{
 				Region:      opts.Region,
 				Plan:        opts.Size,
+				IPStack:     opts.IPStack,
 				SSHKey:      sshKeyPath,
 				SSHKeyName:  sshKeyName,
 				Username:    "deploy",
@@ -221,6 +226,10 @@ func init() {
 }
 
 func findProviderBootstrap(name string) (*providerBootstrap, error) {
+	if providers.IsExternal(name) {
+		return externalProviderBootstrap(name), nil
+	}
+
 	spec, ok := providerAliasMap[strings.ToLower(name)]
 	if !ok {
 		return nil, fmt.Errorf("unsupported provider: %s", name)
@@ -228,6 +237,41 @@ func findProviderBootstrap(name string) (*providerBootstrap, error) {
 	return spec, nil
 }
 
+// externalProviderBootstrap builds the bootstrap for an "exec:" plugin provider.
+// The provider name doubles as its config and token key.
+func externalProviderBootstrap(name string) *providerBootstrap {
+	return &providerBootstrap{
+		canonical:       name,
+		aliases:         []string{name},
+		configKey:       name,
+		tokenKey:        name,
+		defaultUsername: "deploy",
+		fallbackFlow: func(targetName string) (config.ProviderConfig, error) {
+			cfg, err := sequential.RunProvisionExternalFlow(targetName, name)
+			if err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		flagConfigurator: func(opts provisionInputs, sshKeyPath, sshKeyName string) (config.ProviderConfig, error) {
+			if opts.Region == "" {
+				return nil, fmt.Errorf("region is required for %s provisioning", name)
+			}
+			if opts.Size == "" {
+				return nil, fmt.Errorf("size is required for %s provisioning", name)
+			}
+			return &config.ExternalConfig{
+				Region:      opts.Region,
+				Size:        opts.Size,
+				SSHKey:      sshKeyPath,
+				SSHKeyName:  sshKeyName,
+				Username:    "deploy",
+				Provisioned: true,
+			}, nil
+		},
+	}
+}
+
 func ensureProvisionSSHKey(username string) (path string, keyName string, err error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -271,6 +315,9 @@ func (p *providerBootstrap) applyConfig(targetConfig *config.TargetConfig, provi
 	case *config.AWSConfig:
 		targetConfig.Provider = p.canonical
 		return targetConfig.SetProviderConfig(p.configKey, cfg)
+	case *config.ExternalConfig:
+		targetConfig.Provider = p.canonical
+		return targetConfig.SetProviderConfig(p.configKey, cfg)
 	default:
 		return fmt.Errorf("unexpected provider configuration type for %s", p.canonical)
 	}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"testing"
+)
+
+func TestExternalProviderBootstrap(t *testing.T) {
+	bootstrap, err := findProviderBootstrap("exec:mycloud")
+	if err != nil {
+		t.Fatalf("findProviderBootstrap() error: %v", err)
+	}
+	if bootstrap.configKey != "exec:mycloud" || bootstrap.tokenKey != "exec:mycloud" {
+		t.Errorf("config/token keys = %q/%q, want the provider name", bootstrap.configKey, bootstrap.tokenKey)
+	}
+
+	if _, err := bootstrap.flagConfigurator(provisionInputs{Region: "eu-1"}, "/keys/id", "id"); err == nil {
+		t.Error("flagConfigurator() accepted a missing size")
+	}
+
+	providerConfig, err := bootstrap.flagConfigurator(provisionInputs{Region: "eu-1", Size: "small"}, "/keys/id", "id")
+	if err != nil {
+		t.Fatalf("flagConfigurator() error: %v", err)
+	}
+
+	target := &config.TargetConfig{}
+	if err := bootstrap.applyConfig(target, providerConfig); err != nil {
+		t.Fatalf("applyConfig() error: %v", err)
+	}
+	if target.Provider != "exec:mycloud" {
+		t.Errorf("Provider = %q, want exec:mycloud", target.Provider)
+	}
+
+	sshConfig, err := target.GetSSHProviderConfig()
+	if err != nil {
+		t.Fatalf("GetSSHProviderConfig() error: %v", err)
+	}
+	external, ok := sshConfig.(*config.ExternalConfig)
+	if !ok || external.Region != "eu-1" || external.Size != "small" || external.GetUsername() != "deploy" {
+		t.Errorf("GetSSHProviderConfig() = %+v, want the stored external config", sshConfig)
+	}
+
+	if _, err := findProviderBootstrap("exec:"); err == nil {
+		t.Error("findProviderBootstrap() accepted an empty plugin name")
+	}
+}
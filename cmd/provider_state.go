@@ -3,6 +3,7 @@ package cmd
 import (
 	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
 	"lightfold/pkg/state"
 )
 
@@ -69,8 +70,27 @@ var providerStateHandlers = map[string]providerStateHandler{
 	},
 }
 
+// stateHandlerFor returns the state handler for a provider, building one for
+// "exec:" plugin providers
+func stateHandlerFor(provider string) (providerStateHandler, bool) {
+	if providers.IsExternal(provider) {
+		return providerStateHandler{
+			displayName: provider,
+			cfgAccessor: func(target *config.TargetConfig) (config.ProviderConfig, error) {
+				return target.GetExternalConfig()
+			},
+			recoverFunc: func(target *config.TargetConfig, targetName, serverID string) error {
+				return utils.RecoverIPFromProvider(target, targetName, provider, serverID)
+			},
+		}, true
+	}
+
+	handler, ok := providerStateHandlers[provider]
+	return handler, ok
+}
+
 func tryRecoverProviderIP(target *config.TargetConfig, targetName string, targetState *state.TargetState) (bool, string, error) {
-	handler, ok := providerStateHandlers[target.Provider]
+	handler, ok := stateHandlerFor(target.Provider)
 	if !ok {
 		return false, "", nil
 	}
@@ -106,3 +126,37 @@ func tryRecoverProviderIP(target *config.TargetConfig, targetName string, target
 
 	return true, handler.displayName, nil
 }
+
+// refreshChangedIP asks the provider for the current IP of a provisioned server
+// that no longer answers at oldIP. It returns the new IP when it changed; the
+// target config is updated and saved by the recovery handler.
+func refreshChangedIP(target *config.TargetConfig, targetName string, targetState *state.TargetState, oldIP string) (string, bool) {
+	handler, ok := stateHandlerFor(target.Provider)
+	if !ok {
+		return "", false
+	}
+
+	providerCfg, err := handler.cfgAccessor(target)
+	if err != nil || providerCfg == nil || !providerCfg.IsProvisioned() {
+		return "", false
+	}
+
+	serverID := providerCfg.GetServerID()
+	if serverID == "" && targetState != nil {
+		serverID = targetState.ProvisionedID
+	}
+	if serverID == "" {
+		return "", false
+	}
+
+	if err := handler.recoverFunc(target, targetName, serverID); err != nil {
+		return "", false
+	}
+
+	refreshedCfg, err := handler.cfgAccessor(target)
+	if err != nil || refreshedCfg == nil {
+		return "", false
+	}
+	newIP := refreshedCfg.GetIP()
+	return newIP, newIP != "" && newIP != oldIP
+}
@@ -2,16 +2,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
 	"lightfold/pkg/deploy"
 	"lightfold/pkg/detector"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
 	"lightfold/pkg/util"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,17 +23,36 @@ import (
 )
 
 var (
-	pushEnvFile    string
-	pushEnvVars    []string
-	pushSkipBuild  bool
-	pushDryRun     bool
-	pushBranch     string
-	pushTargetFlag string
+	pushEnvFile           string
+	pushEnvVars           []string
+	pushSkipBuild         bool
+	pushSkipMigrations    bool
+	pushRerunFirstDeploy  bool
+	pushForceBuild        bool
+	pushDryRun            bool
+	pushForce             bool
+	pushBranch            string
+	pushTargetFlag        string
+	pushNoDrain           bool
+	pushKeepFailedRelease bool
+	pushEnvSync           bool
+	pushShowValues        bool
+	pushPrune             bool
+	pushPreviewName       string
+	pushPreviewTTL        time.Duration
+	pushWaitForLock       time.Duration
+	pushAfterCurrent      bool
+	pushTail              time.Duration
+	pushTailLogs          bool // --tail was given; a bare --tail streams until Ctrl-C
+	pushArtifact          string
+	pushStartCommand      string
+	pushBuildArgs         []string
+	pushBuildTarget       string
 
 	// Styles for push command (matching bubbletea/deploy)
-	pushSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	pushMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	pushValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+	pushSuccessStyle = style.Success
+	pushMutedStyle   = style.Muted
+	pushValueStyle   = style.Value
 )
 
 var pushCmd = &cobra.Command{
@@ -49,9 +71,27 @@ Examples:
   lightfold push                         # Push current directory
   lightfold push ~/Projects/myapp        # Push specific project
   lightfold push --target myapp          # Push named target
-  lightfold push --dry-run               # Preview deployment`,
+  lightfold push --dry-run               # Preview deployment
+  lightfold push --env-sync              # Review env changes before writing them
+  lightfold push --preview pr-142        # Deploy a static site preview (see 'lightfold preview')
+  lightfold push --wait-for-lock=15m     # Wait for a running deploy instead of taking over
+  lightfold push --tail                  # Stream the app's logs after the push until Ctrl-C
+  lightfold push --tail=2m               # Stream the app's logs for two minutes
+  lightfold push --artifact ./out        # Ship a directory built in CI as the release, as is
+
+With --artifact, the directory is uploaded exactly as it is and nothing is
+built on the server. It has to contain what the start command runs, e.g.
+.next/standalone/server.js for a Next.js standalone build or the app binary
+for Go; --start-command replaces the stored run command and is saved to the
+target.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		pushTailLogs = cmd.Flags().Changed("tail")
+		if pushTail < 0 {
+			fmt.Fprintf(os.Stderr, "Error: --tail must not be negative\n")
+			exit(1)
+		}
+
 		cfg := loadConfigOrExit()
 
 		var pathArg string
@@ -61,33 +101,70 @@ Examples:
 
 		target, targetNameResolved := resolveTarget(cfg, pushTargetFlag, pathArg)
 		projectPath := target.ProjectPath
+		exitIfPaused(targetNameResolved)
+		exitIfBaseDirMoved(targetNameResolved, target)
+		exitIfEnvLocked(cfg, targetNameResolved)
+		artifactDir, err := resolveArtifactDir(target, pushArtifact, pushStartCommand, pushPreviewName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if !pushDryRun && pushPreviewName == "" {
+			exitIfMaintenance(targetNameResolved, pushForce)
+		}
 
 		if !state.IsCreated(targetNameResolved) {
 			fmt.Fprintf(os.Stderr, "Error: Target '%s' has not been created\n", targetNameResolved)
 			fmt.Fprintf(os.Stderr, "Run 'lightfold create --target %s' first\n", targetNameResolved)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Skip configuration check for container providers (fly.io)
 		if target.Provider != "flyio" && !state.IsConfigured(targetNameResolved) {
 			fmt.Fprintf(os.Stderr, "Error: Target '%s' has not been configured\n", targetNameResolved)
 			fmt.Fprintf(os.Stderr, "Run 'lightfold configure --target %s' first\n", targetNameResolved)
-			os.Exit(1)
+			exit(1)
 		}
 
 		currentCommit := getGitCommit(projectPath)
 		lastCommit := state.GetLastCommit(targetNameResolved)
 
-		if currentCommit != "" && currentCommit == lastCommit && !pushDryRun {
+		if pushAlreadyDeployed(currentCommit, lastCommit, artifactDir) {
 			fmt.Printf("No changes detected (commit: %s)\n", currentCommit[:7])
 			fmt.Println("Use --force to push anyway")
-			os.Exit(0)
+			exit(0)
+		}
+
+		if !pushDryRun {
+			exitUnlessProtectedConfirmed(target, targetNameResolved)
 		}
 
 		// Process deployment options
-		if err := target.ProcessDeploymentOptions(pushEnvFile, pushEnvVars, pushSkipBuild); err != nil {
+		// A prebuilt artifact is never built on the server
+		if err := target.ProcessDeploymentOptions(pushEnvFile, pushEnvVars, pushSkipBuild || artifactDir != ""); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
+		}
+		if err := rejectPushBuildOptions(pushBuildArgs, pushBuildTarget, targetNameResolved); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if pushStartCommand != "" {
+			target.Deploy.RunCommands = []string{pushStartCommand}
+		}
+
+		if pushPreviewName != "" {
+			if pushDryRun {
+				fmt.Println("DRY RUN - No changes will be made")
+				fmt.Printf("Target: %s\n", targetNameResolved)
+				fmt.Printf("Would deploy preview: %s\n", pushPreviewName)
+				return
+			}
+			if err := pushPreview(cfg, target, targetNameResolved, pushPreviewName, currentCommit, pushPreviewTTL); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			return
 		}
 
 		if pushDryRun {
@@ -108,6 +185,16 @@ Examples:
 				fmt.Println("2. Set secrets via flyctl")
 				fmt.Println("3. Deploy with fly.io nixpacks (remote build)")
 				fmt.Println("4. Wait for health checks")
+			} else if artifactDir != "" {
+				fmt.Printf("1. Pack artifact %s as is\n", artifactDir)
+				fmt.Println("2. Upload to server")
+				if pushStartCommand != "" {
+					fmt.Printf("3. Start with: %s\n", pushStartCommand)
+				} else {
+					fmt.Println("3. Start with the stored run command (no build)")
+				}
+				fmt.Println("4. Deploy with health check")
+				fmt.Println("5. Auto-rollback on failure")
 			} else {
 				fmt.Println("1. Create release tarball")
 				fmt.Println("2. Upload to server")
@@ -124,52 +211,51 @@ Examples:
 			tokens, err := config.LoadTokens()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error loading tokens: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			token := tokens.GetToken("flyio")
 			if token == "" {
 				fmt.Fprintf(os.Stderr, "Error: fly.io API token not found\n")
 				fmt.Fprintf(os.Stderr, "Run 'lightfold config set-token flyio' first\n")
-				os.Exit(1)
+				exit(1)
 			}
 
 			flyioConfig, err := target.GetFlyioConfig()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error getting fly.io config: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
-			detection := detector.DetectFramework(target.ProjectPath)
+			detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
 			projectName := util.GetTargetName(target.ProjectPath)
 
 			deployer := deploy.NewFlyioDeployer(projectName, target.ProjectPath, targetNameResolved, &detection, flyioConfig, token)
+			deployer.SetLabels(target.Labels)
 
-			fmt.Printf("%s %s\n", pushMutedStyle.Render("→"), pushMutedStyle.Render("Starting fly.io deployment..."))
+			fmt.Printf("%s %s\n", pushMutedStyle.Render(style.Arrow()), pushMutedStyle.Render("Starting fly.io deployment..."))
 
 			if err := deployer.Deploy(ctx, target.Deploy); err != nil {
 				state.MarkPushFailed(targetNameResolved, fmt.Sprintf("fly.io deployment failed: %v", err))
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			// Update state
 			if err := state.ClearPushFailure(targetNameResolved); err != nil {
 				fmt.Printf("Warning: failed to clear push failure in state: %v\n", err)
 			}
-			if err := state.UpdateDeployment(targetNameResolved, currentCommit, time.Now().Format("20060102150405")); err != nil {
+			if err := state.UpdateDeployment(targetNameResolved, currentCommit, timefmt.ReleaseName(time.Now()), ""); err != nil {
 				fmt.Printf("Warning: failed to update state: %v\n", err)
 			}
 
 			fmt.Println()
-			successBox := lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("82")).
+			successBox := style.Box(style.ColorSuccess).
 				Padding(0, 1).
 				Render(
 					lipgloss.JoinVertical(
 						lipgloss.Left,
-						pushSuccessStyle.Render(fmt.Sprintf("✓ Successfully deployed '%s' to fly.io", targetNameResolved)),
+						pushSuccessStyle.Render(fmt.Sprintf("%s Successfully deployed '%s' to fly.io", style.Check(), targetNameResolved)),
 						"",
 						fmt.Sprintf("%s %s", pushMutedStyle.Render("App:"), pushValueStyle.Render(flyioConfig.AppName)),
 						fmt.Sprintf("%s %s", pushMutedStyle.Render("Region:"), pushValueStyle.Render(flyioConfig.Region)),
@@ -184,7 +270,7 @@ Examples:
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Ensure server state is initialized
@@ -197,7 +283,7 @@ Examples:
 			port, err := getOrAllocatePort(&target, targetNameResolved)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error allocating port: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 			target.Port = port
 
@@ -210,108 +296,390 @@ Examples:
 			}
 		}
 
-		detection := detector.DetectFramework(target.ProjectPath)
+		serverTargets, err := target.ServerTargets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
 
-		sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
-		defer sshExecutor.Disconnect()
+		detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+		projectName := utils.RemoteAppName(&target, targetNameResolved)
 
-		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
-			fmt.Fprintf(os.Stderr, "Error connecting to server: %v\n", err)
-			os.Exit(1)
+		// The packer knows the start command, so it checks the artifact
+		// holds its entrypoint before anything is uploaded
+		packer := deploy.NewExecutorWithOptions(nil, projectName, target.ProjectPath, &detection, target.Deploy)
+		packer.SetPackWorkers(cfg.PackWorkers)
+		if artifactDir != "" {
+			if err := packer.ValidateArtifact(artifactDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "Pass --start-command to start the artifact another way\n")
+				exit(1)
+			}
 		}
 
-		projectName := util.GetTargetName(target.ProjectPath)
+		for _, serverTarget := range serverTargets {
+			if err := utils.CheckServerAppCollision(serverTarget.ServerIP, targetNameResolved, utils.RemoteAppName(&serverTarget, targetNameResolved)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		}
 
-		// Use custom deployment options if available
-		var executor *deploy.Executor
-		if target.Deploy != nil && (len(target.Deploy.BuildCommands) > 0 || len(target.Deploy.RunCommands) > 0) {
-			executor = deploy.NewExecutorWithOptions(sshExecutor, projectName, target.ProjectPath, &detection, target.Deploy)
-		} else {
-			executor = deploy.NewExecutor(sshExecutor, projectName, target.ProjectPath, &detection)
+		if wait := lockWait(pushWaitForLock, pushAfterCurrent); wait > 0 {
+			queueOnServers(serverTargets, targetNameResolved, target.ProjectPath, currentCommit, wait)
+		}
+
+		if pushEnvSync {
+			diff, err := diffRemoteEnvironment(&target, targetNameResolved, pushPrune)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+			printEnvDiff(diff, pushShowValues)
+			if diff.Pending() && !confirmEnvSync() {
+				fmt.Println("Push cancelled.")
+				exit(0)
+			}
+			// Server-only keys are carried over unless --prune dropped them
+			target.Deploy.EnvVars = diff.Merged()
 		}
 
 		tmpTarball := fmt.Sprintf("/tmp/lightfold-%s-release.tar.gz", projectName)
-		if err := executor.CreateReleaseTarball(tmpTarball); err != nil {
+		packStep := "Creating release tarball..."
+		if artifactDir != "" {
+			err = packer.CreateArtifactTarball(artifactDir, tmpTarball)
+			packStep = fmt.Sprintf("Packing artifact %s...", artifactDir)
+		} else {
+			err = packer.CreateReleaseTarball(tmpTarball)
+		}
+		if err != nil {
 			state.MarkPushFailed(targetNameResolved, fmt.Sprintf("failed to create tarball: %v", err))
 			fmt.Fprintf(os.Stderr, "Error creating tarball: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 		defer os.Remove(tmpTarball)
-		fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Creating release tarball..."))
-
-		releasePath, err := executor.UploadRelease(tmpTarball)
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render(packStep))
+		tarballSHA256, err := deploy.FileSHA256(tmpTarball)
 		if err != nil {
-			state.MarkPushFailed(targetNameResolved, fmt.Sprintf("failed to upload release: %v", err))
-			fmt.Fprintf(os.Stderr, "Error uploading release: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error checksumming tarball: %v\n", err)
+			exitRemoving(1, tmpTarball)
 		}
-		fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Uploading release to server..."))
 
-		releaseTimestamp := filepath.Base(releasePath)
+		if !confirmReleaseSecrets(packer.ReleaseSecrets(), nil) {
+			fmt.Println(pushMutedStyle.Render("Push cancelled."))
+			os.Remove(tmpTarball)
+			exit(0)
+		}
 
-		if !target.Deploy.SkipBuild {
-			if err := executor.BuildRelease(releasePath); err != nil {
-				state.MarkPushFailed(targetNameResolved, fmt.Sprintf("failed to build release: %v", err))
-				fmt.Fprintf(os.Stderr, "Error building release: %v\n", err)
-				os.Exit(1)
+		// Every server gets the same release name so they stay in lockstep
+		releaseTimestamp := timefmt.ReleaseName(time.Now())
+		checkpoint := state.GetPushCheckpoint(targetNameResolved)
+		resuming := checkpoint != nil && checkpoint.Commit == currentCommit
+		if resuming {
+			releaseTimestamp = checkpoint.Release
+			if checkpoint.TarballSHA256 != "" {
+				tarballSHA256 = checkpoint.TarballSHA256
 			}
-			fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Building app..."))
+			fmt.Printf("%s %s\n", pushMutedStyle.Render(style.Arrow()), pushMutedStyle.Render(fmt.Sprintf("Resuming release %s, interrupted during %s", releaseTimestamp, checkpoint.Step)))
 		}
 
-		if len(target.Deploy.EnvVars) > 0 {
-			if err := executor.WriteEnvironmentFile(target.Deploy.EnvVars); err != nil {
-				state.MarkPushFailed(targetNameResolved, fmt.Sprintf("failed to write environment file: %v", err))
-				fmt.Fprintf(os.Stderr, "Error writing environment: %v\n", err)
-				os.Exit(1)
+		var deployed []config.TargetConfig
+		for i, serverTarget := range serverTargets {
+			if len(serverTargets) > 1 {
+				serverCfg, _ := serverTarget.GetSSHProviderConfig()
+				fmt.Printf("\n%s %s\n", pushValueStyle.Render(fmt.Sprintf("Server %d/%d:", i+1, len(serverTargets))), pushMutedStyle.Render(serverCfg.GetIP()))
 			}
-			fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Configuring environment variables..."))
-		}
 
-		if err := executor.DeployWithHealthCheck(releasePath, target.Port, 5, 3*time.Second); err != nil {
-			state.MarkPushFailed(targetNameResolved, fmt.Sprintf("deployment failed: %v", err))
-			fmt.Fprintf(os.Stderr, "Error during deployment: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Deploying and running health checks..."))
+			if err := pushToServer(serverTarget, targetNameResolved, &detection, tmpTarball, releaseTimestamp, currentCommit, cfg.KeepReleasesFor(target), resuming, i == 0); err != nil {
+				var superseded *deploy.SupersededError
+				if errors.As(err, &superseded) {
+					exitSuperseded(targetNameResolved, releaseTimestamp, currentCommit, superseded, tmpTarball)
+				}
 
-		if err := executor.CleanupOldReleases(cfg.NumReleases); err != nil {
-			fmt.Printf("Warning: failed to cleanup old releases: %v\n", err)
+				var interrupted *interruptedPushError
+				if errors.As(err, &interrupted) {
+					state.MarkPushInterrupted(targetNameResolved, err.Error(), state.PushCheckpoint{
+						Release:       releaseTimestamp,
+						Commit:        currentCommit,
+						Step:          interrupted.step,
+						ServerIP:      interrupted.serverIP,
+						TarballSHA256: tarballSHA256,
+					})
+				} else {
+					state.MarkPushFailed(targetNameResolved, err.Error())
+				}
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				printMigrationBackoutWarning(err)
+				if interrupted != nil {
+					fmt.Fprintf(os.Stderr, "The uploaded release was kept. Run 'lightfold push --target %s' to resume from the %s step\n", targetNameResolved, interrupted.step)
+				}
+				if len(deployed) > 0 {
+					fmt.Println("Rolling back servers that already switched to the new release...")
+					rollbackServers(deployed, targetNameResolved, &detection, "")
+				}
+				exitRemoving(1, tmpTarball)
+			}
+			deployed = append(deployed, serverTarget)
 		}
-		fmt.Printf("%s %s\n", pushSuccessStyle.Render("✓"), pushMutedStyle.Render("Cleaning up old releases..."))
 
 		// Clear any previous push failure and update deployment state
 		if err := state.ClearPushFailure(targetNameResolved); err != nil {
 			fmt.Printf("Warning: failed to clear push failure in state: %v\n", err)
 		}
-		if err := state.UpdateDeployment(targetNameResolved, currentCommit, releaseTimestamp); err != nil {
+		if err := state.UpdateDeployment(targetNameResolved, currentCommit, releaseTimestamp, tarballSHA256); err != nil {
 			fmt.Printf("Warning: failed to update state: %v\n", err)
 		}
-
-		// Register app with server state
-		if err := registerAppWithServer(&target, targetNameResolved, target.Port, target.Framework); err != nil {
-			fmt.Printf("Warning: failed to register app with server: %v\n", err)
+		recordBaseDir(targetNameResolved, target)
+		if pushStartCommand != "" {
+			if err := saveStartCommand(targetNameResolved, pushStartCommand); err != nil {
+				fmt.Printf("Warning: failed to save the start command: %v\n", err)
+			}
 		}
 
+		expirePreviews(target, targetNameResolved)
+
 		fmt.Println()
 
-		successBox := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("82")).
+		serverLine := providerCfg.GetIP()
+		if len(serverTargets) > 1 {
+			serverLine = fmt.Sprintf("%s (+%d more)", serverLine, len(serverTargets)-1)
+		}
+
+		successBox := style.Box(style.ColorSuccess).
 			Padding(0, 1).
 			Render(
 				lipgloss.JoinVertical(
 					lipgloss.Left,
-					pushSuccessStyle.Render(fmt.Sprintf("✓ Successfully deployed '%s'", targetNameResolved)),
+					pushSuccessStyle.Render(fmt.Sprintf("%s Successfully deployed '%s'", style.Check(), targetNameResolved)),
 					"",
-					fmt.Sprintf("%s %s", pushMutedStyle.Render("Server:"), pushValueStyle.Render(providerCfg.GetIP())),
+					fmt.Sprintf("%s %s", pushMutedStyle.Render("Server:"), pushValueStyle.Render(serverLine)),
 					fmt.Sprintf("%s %s", pushMutedStyle.Render("Release:"), pushValueStyle.Render(releaseTimestamp)),
 				),
 			)
 
 		fmt.Println(successBox)
+
+		if pushTailLogs {
+			tailPrimaryServer(serverTargets[0], targetNameResolved, &detection, pushTail)
+		}
 	},
 }
 
+// tailPrimaryServer streams the app's logs from the target's primary server
+// after a push
+func tailPrimaryServer(target config.TargetConfig, targetName string, detection *detector.Detection, d time.Duration) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		fmt.Printf("Warning: failed to stream logs: %v\n", err)
+		return
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		fmt.Printf("Warning: failed to stream logs: %v\n", err)
+		return
+	}
+
+	appName := resolveAppName(&target, targetName, sshExecutor)
+	tailAfterDeploy(sshExecutor, appName, detection.Framework, detection.Meta["deployment_type"] == "static", d)
+}
+
+// pushAlreadyDeployed reports whether push should stop because the commit is
+// the one last deployed. --force, dry runs and previews always go ahead, and
+// so do prebuilt artifacts, which change without a commit.
+func pushAlreadyDeployed(currentCommit, lastCommit, artifactDir string) bool {
+	return currentCommit != "" && currentCommit == lastCommit && !pushDryRun && !pushForce && pushPreviewName == "" && artifactDir == ""
+}
+
+// rejectPushBuildOptions refuses --build-arg and --build-target. Push builds
+// on the server with the framework's own commands; the dockerfile and nixpacks
+// builders that take them only run when deploy or configure set the server up.
+func rejectPushBuildOptions(buildArgs []string, buildTarget, targetName string) error {
+	if len(buildArgs) == 0 && buildTarget == "" {
+		return nil
+	}
+	return fmt.Errorf("push does not run the dockerfile or nixpacks builder, so --build-arg and --build-target would be ignored\n  Rebuild with them: lightfold deploy --target %s --force --build-arg KEY=VALUE", targetName)
+}
+
+// exitSuperseded records a push that gave way to a newer push of the same
+// target and exits cleanly. Servers that already switched are left alone: the
+// newer push switches them again.
+func exitSuperseded(targetName, releaseTimestamp, commit string, superseded *deploy.SupersededError, paths ...string) {
+	if err := state.MarkPushSuperseded(targetName, state.SupersededPush{
+		Release: releaseTimestamp,
+		Commit:  commit,
+		By:      superseded.Commit,
+	}); err != nil {
+		fmt.Printf("Warning: failed to update state: %v\n", err)
+	}
+	fmt.Printf("%s %s\n", pushMutedStyle.Render(style.Skipped()), pushMutedStyle.Render(fmt.Sprintf("Stopped before switching releases: %v", superseded)))
+	exitRemoving(0, paths...)
+}
+
+// interruptedPushError is a push whose connection dropped during a step that
+// is not re-run automatically. The release stays on the server for resuming.
+type interruptedPushError struct {
+	step     string
+	serverIP string
+	err      error
+}
+
+func (e *interruptedPushError) Error() string {
+	return fmt.Sprintf("%s on %s interrupted: %v", e.step, e.serverIP, e.err)
+}
+
+func (e *interruptedPushError) Unwrap() error {
+	return e.err
+}
+
+// pushToServer uploads, builds and switches one server to the release. The
+// symlink only moves once this server's own health check passes. When
+// resuming, a release already uploaded under the same name is reused.
+// Scheduled jobs and first-deploy commands run on the primary server only, so
+// they run once per target.
+func pushToServer(target config.TargetConfig, targetName string, detection *detector.Detection, tarball, releaseTimestamp, commit string, numReleases int, resume, primary bool) error {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return err
+	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+	}
+
+	appName := resolveAppName(&target, targetName, sshExecutor)
+	adoptAppName(&target, targetName, appName, sshExecutor)
+
+	// Use custom deployment options if available
+	var executor *deploy.Executor
+	if target.Deploy != nil && (len(target.Deploy.BuildCommands) > 0 || len(target.Deploy.RunCommands) > 0) {
+		executor = deploy.NewExecutorWithOptions(sshExecutor, appName, target.ProjectPath, detection, target.Deploy)
+	} else {
+		executor = deploy.NewExecutor(sshExecutor, appName, target.ProjectPath, detection)
+	}
+	if target.Deploy != nil {
+		executor.SetDrainSeconds(target.Deploy.DrainSeconds)
+	}
+	executor.SetNoDrain(pushNoDrain)
+	executor.SetBaseDir(target.RemoteBaseDir())
+	executor.ApplyServerTuning(providerCfg.GetIP(), target.Deploy)
+	executor.SetMigrationOptions(target.Deploy, pushSkipMigrations)
+	if primary {
+		executor.SetFirstDeployOptions(target.Deploy, pushRerunFirstDeploy)
+	}
+	executor.SetAssetOptions(target.Assets)
+	executor.SetBuildMemoryOptions(target.Builder, target.Deploy, pushForceBuild)
+	setProxyHealthCheck(executor, target, targetName, providerCfg.GetIP())
+	if !target.Deploy.SkipBuild {
+		if err := checkBuildMemory(executor, targetName); err != nil {
+			return err
+		}
+	}
+
+	// Claim the target so an older push still running cannot switch after us
+	if err := executor.AcquireLease(commit); err != nil {
+		return err
+	}
+	defer executor.ReleaseLease()
+
+	releasePath, uploaded := "", false
+	if resume {
+		releasePath, uploaded = executor.UploadedRelease(releaseTimestamp)
+	}
+	if uploaded {
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Reusing release already on the server..."))
+	} else {
+		releasePath, err = executor.UploadReleaseAs(tarball, releaseTimestamp)
+		if err != nil {
+			return fmt.Errorf("failed to upload release: %w", err)
+		}
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Uploading release to server..."))
+	}
+
+	if !target.Deploy.SkipBuild {
+		if err := executor.BuildRelease(releasePath); err != nil {
+			if sshpkg.IsConnectionLost(err) {
+				return &interruptedPushError{step: "build", serverIP: providerCfg.GetIP(), err: err}
+			}
+			discardFailedRelease(executor, releasePath, pushKeepFailedRelease)
+			return fmt.Errorf("failed to build release: %w", err)
+		}
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Building app..."))
+
+		if err := uploadReleaseAssets(executor, releasePath); err != nil {
+			discardFailedRelease(executor, releasePath, pushKeepFailedRelease)
+			return err
+		}
+	}
+
+	// A pruning env sync also writes an empty env file, dropping every server key
+	if releaseEnv := executor.ReleaseEnvironment(target.Deploy.EnvVars); len(releaseEnv) > 0 || (pushEnvSync && pushPrune) {
+		if err := executor.ReplaceEnvironmentFile(releaseEnv); err != nil {
+			discardFailedRelease(executor, releasePath, pushKeepFailedRelease)
+			return fmt.Errorf("failed to write environment file: %w", err)
+		}
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Configuring environment variables..."))
+	}
+
+	// A start command given with --artifact replaces the one in the unit
+	if pushStartCommand != "" {
+		executor.ResolvePortExposure(target.Domain != nil && target.Domain.Domain != "", target.Port)
+		if err := executor.GenerateSystemdUnitWithPort(releasePath, target.Port); err != nil {
+			discardFailedRelease(executor, releasePath, pushKeepFailedRelease)
+			return err
+		}
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Updating the service's start command..."))
+	}
+
+	var switchedAt int64
+	if pushTailLogs {
+		switchedAt = remoteUnixTime(sshExecutor)
+	}
+	err = executor.DeployWithHealthCheck(releasePath, target.Port, 5, 3*time.Second)
+	recordDeployHealth(executor, targetName)
+	recordFirstDeploy(executor, targetName)
+	if err != nil {
+		if sshpkg.IsConnectionLost(err) {
+			return &interruptedPushError{step: "restart", serverIP: providerCfg.GetIP(), err: err}
+		}
+		var superseded *deploy.SupersededError
+		if errors.As(err, &superseded) {
+			discardFailedRelease(executor, releasePath, pushKeepFailedRelease)
+			return err
+		}
+		if pushTailLogs && errors.Is(err, deploy.ErrRolledBack) {
+			showFailedReleaseLogs(sshExecutor, appName, detection.Framework, detection.Meta["deployment_type"] == "static", switchedAt)
+		}
+		return fmt.Errorf("deployment failed: %w", err)
+	}
+	fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Deploying and running health checks..."))
+	fmt.Printf("  %s\n", pushMutedStyle.Render("Health: "+executor.DeployHealth().Summary()))
+
+	var jobs []config.Job
+	if primary {
+		jobs = target.Deploy.Jobs
+	}
+	if synced, err := executor.SyncJobs(jobs); err != nil {
+		fmt.Printf("Warning: failed to update scheduled jobs: %v\n", err)
+	} else if synced.Changed() {
+		fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render(fmt.Sprintf("Updating scheduled jobs (%s)...", synced.Summary())))
+	}
+
+	if err := executor.CleanupOldReleases(numReleases); err != nil {
+		fmt.Printf("Warning: failed to cleanup old releases: %v\n", err)
+	}
+	fmt.Printf("%s %s\n", pushSuccessStyle.Render(style.Check()), pushMutedStyle.Render("Cleaning up old releases..."))
+
+	// Register app with server state
+	if err := registerAppWithServer(&target, targetName, target.Port, target.Framework); err != nil {
+		fmt.Printf("Warning: failed to register app with server: %v\n", err)
+	}
+
+	return nil
+}
+
 func getGitCommit(projectPath string) string {
 	cmd := exec.Command("git", "-C", projectPath, "rev-parse", "HEAD")
 	output, err := cmd.Output()
@@ -328,6 +696,27 @@ func init() {
 	pushCmd.Flags().StringVar(&pushEnvFile, "env-file", "", "Path to .env file")
 	pushCmd.Flags().StringArrayVar(&pushEnvVars, "env", []string{}, "Environment variables (KEY=VALUE)")
 	pushCmd.Flags().BoolVar(&pushSkipBuild, "skip-build", false, "Skip build step")
+	pushCmd.Flags().StringArrayVar(&pushBuildArgs, "build-arg", []string{}, "Refused: push builds with the framework's commands; pass build args to 'deploy --force'")
+	pushCmd.Flags().StringVar(&pushBuildTarget, "build-target", "", "Refused: push builds with the framework's commands; pass the stage to 'deploy --force'")
+	pushCmd.Flags().BoolVar(&pushSkipMigrations, "skip-migrations", false, "Deploy without running database migrations")
+	pushCmd.Flags().BoolVar(&pushRerunFirstDeploy, "rerun-first-deploy", false, "Run deploy.first_deploy_commands again although they succeeded before")
+	pushCmd.Flags().BoolVar(&pushForceBuild, "force-build", false, "Build even when the server has too little memory for the framework")
 	pushCmd.Flags().BoolVar(&pushDryRun, "dry-run", false, "Show what would be done without executing")
+	pushCmd.Flags().BoolVar(&pushForce, "force", false, "Push even when the commit is already deployed, or to a target in maintenance mode")
 	pushCmd.Flags().StringVar(&pushBranch, "branch", "main", "Git branch to deploy")
+	pushCmd.Flags().BoolVar(&pushNoDrain, "no-drain", false, "Restart without waiting for in-flight connections to drain")
+	pushCmd.Flags().BoolVar(&pushKeepFailedRelease, "keep-failed-release", false, "Keep the release directory on the server when the push fails before going live (for debugging)")
+	pushCmd.Flags().BoolVar(&pushEnvSync, "env-sync", false, "Diff local and server environment variables and confirm before writing them")
+	pushCmd.Flags().BoolVar(&pushShowValues, "show-values", false, "Show values in the --env-sync diff")
+	pushCmd.Flags().BoolVar(&pushPrune, "prune", false, "With --env-sync, remove keys that are only on the server")
+	pushCmd.Flags().DurationVar(&pushWaitForLock, "wait-for-lock", 0, "Wait up to this long for a deploy already running on the server instead of taking over (bare flag waits 30m; use --wait-for-lock=1h)")
+	pushCmd.Flags().Lookup("wait-for-lock").NoOptDefVal = defaultLockWait.String()
+	pushCmd.Flags().DurationVar(&pushTail, "tail", 0, "Stream the app's logs after a successful push for this long (bare flag streams until Ctrl-C); after a rollback, show the failed release's logs")
+	pushCmd.Flags().Lookup("tail").NoOptDefVal = "0s"
+	pushCmd.Flags().BoolVar(&pushAfterCurrent, "after-current", false, "Queue behind a deploy already running on the server (same as --wait-for-lock)")
+	pushCmd.Flags().StringVar(&confirmProtectedFlag, "confirm-protected", "", "Confirm pushing to a protected target by passing its name (required without a terminal)")
+	pushCmd.Flags().StringVar(&pushPreviewName, "preview", "", "Deploy as a named preview (static sites only) instead of to production")
+	pushCmd.Flags().StringVar(&pushArtifact, "artifact", "", "Deploy this prebuilt directory as the release, as is, without building (e.g. a CI build output)")
+	pushCmd.Flags().StringVar(&pushStartCommand, "start-command", "", "With --artifact, the command the service starts the release with; saved as the target's run command")
+	pushCmd.Flags().DurationVar(&pushPreviewTTL, "preview-ttl", 7*24*time.Hour, "Remove the preview on the first push after this long (0 keeps it)")
 }
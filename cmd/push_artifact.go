@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"path/filepath"
+)
+
+// resolveArtifactDir checks the --artifact and --start-command flags against
+// the target and returns the artifact directory as an absolute path, or ""
+// for a normal push
+func resolveArtifactDir(target config.TargetConfig, artifact, startCommand, previewName string) (string, error) {
+	if artifact == "" {
+		if startCommand != "" {
+			return "", fmt.Errorf("--start-command is only used with --artifact")
+		}
+		return "", nil
+	}
+	if previewName != "" {
+		return "", fmt.Errorf("--artifact cannot be combined with --preview")
+	}
+	if target.Provider == "flyio" || !target.RequiresSSHDeployment() {
+		return "", fmt.Errorf("--artifact is not supported on %s", target.Provider)
+	}
+	return filepath.Abs(artifact)
+}
+
+// saveStartCommand stores the start command an artifact was pushed with as
+// the target's run command, so configure and later pushes start it the same
+// way. The config is reloaded so push-only options such as skip_build are
+// not saved with it.
+func saveStartCommand(targetName, startCommand string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	target, ok := cfg.GetTarget(targetName)
+	if !ok {
+		return fmt.Errorf("target '%s' not found", targetName)
+	}
+	ensureDeploy(&target).RunCommands = []string{startCommand}
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		return err
+	}
+	return cfg.SaveConfig()
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lightfold/pkg/config"
+)
+
+func TestResolveArtifactDir(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	vps := config.TargetConfig{Provider: "hetzner"}
+	tests := []struct {
+		name         string
+		target       config.TargetConfig
+		artifact     string
+		startCommand string
+		preview      string
+		wantErr      string
+	}{
+		{name: "normal push", target: vps},
+		{name: "artifact", target: vps, artifact: "out", startCommand: "node server.js"},
+		{name: "start command alone", target: vps, startCommand: "node server.js", wantErr: "only used with --artifact"},
+		{name: "preview", target: vps, artifact: "out", preview: "pr-1", wantErr: "--preview"},
+		{name: "fly.io", target: config.TargetConfig{Provider: "flyio"}, artifact: "out", wantErr: "not supported on flyio"},
+		{name: "s3", target: config.TargetConfig{Provider: "s3"}, artifact: "out", wantErr: "not supported on s3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := resolveArtifactDir(tt.target, tt.artifact, tt.startCommand, tt.preview)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveArtifactDir() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveArtifactDir() error: %v", err)
+			}
+			if tt.artifact == "" && dir != "" {
+				t.Errorf("resolveArtifactDir() = %q for a normal push", dir)
+			}
+			if tt.artifact != "" && (!filepath.IsAbs(dir) || filepath.Base(dir) != tt.artifact) {
+				t.Errorf("resolveArtifactDir() = %q, want an absolute path to %s", dir, tt.artifact)
+			}
+		})
+	}
+}
+
+func TestSaveStartCommand(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{"web": {Provider: "hetzner"}}}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveStartCommand("web", "./bin/api --port $PORT"); err != nil {
+		t.Fatalf("saveStartCommand() error: %v", err)
+	}
+	saved, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := saved.GetTarget("web")
+	if target.Deploy == nil || !reflect.DeepEqual(target.Deploy.RunCommands, []string{"./bin/api --port $PORT"}) || target.Deploy.SkipBuild {
+		t.Errorf("saved deploy options = %+v, want only the run command", target.Deploy)
+	}
+}
+
+func TestPushAlreadyDeployed(t *testing.T) {
+	defer func() { pushForce = false }()
+
+	if !pushAlreadyDeployed("abc1234", "abc1234", "") {
+		t.Error("pushing the deployed commit again should stop")
+	}
+	if pushAlreadyDeployed("abc1234", "abc1234", "/work/out") {
+		t.Error("an artifact push should not compare commits")
+	}
+	if pushAlreadyDeployed("def5678", "abc1234", "") || pushAlreadyDeployed("", "", "") {
+		t.Error("a new commit, or a project outside git, should be pushed")
+	}
+	pushForce = true
+	if pushAlreadyDeployed("abc1234", "abc1234", "") {
+		t.Error("--force should push the deployed commit again")
+	}
+}
+
+func TestRejectPushBuildOptions(t *testing.T) {
+	if err := rejectPushBuildOptions(nil, "", "myapp"); err != nil {
+		t.Errorf("rejectPushBuildOptions() without flags = %v", err)
+	}
+	for _, tt := range []struct {
+		args   []string
+		target string
+	}{{args: []string{"COMMIT_SHA=abc"}}, {target: "runtime"}} {
+		err := rejectPushBuildOptions(tt.args, tt.target, "myapp")
+		if err == nil || !strings.Contains(err.Error(), "lightfold deploy --target myapp --force") {
+			t.Errorf("rejectPushBuildOptions(%v, %q) = %v, want a pointer to deploy", tt.args, tt.target, err)
+		}
+	}
+}
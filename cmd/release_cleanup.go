@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/deploy"
+	"os"
+)
+
+// discardFailedRelease removes a release whose build or setup failed before it
+// went live, unless keep (--keep-failed-release) leaves it for debugging
+func discardFailedRelease(executor *deploy.Executor, releasePath string, keep bool) {
+	mutedStyle := style.Muted
+	if keep {
+		fmt.Println(mutedStyle.Render(fmt.Sprintf("Keeping failed release %s for inspection", releasePath)))
+		return
+	}
+	if err := executor.RemoveFailedRelease(releasePath); err != nil {
+		fmt.Printf("Warning: failed to remove failed release: %v\n", err)
+	}
+}
+
+// exitRemoving deletes local temporary files and exits. os.Exit skips deferred
+// calls, so paths removed by defer would otherwise be left in /tmp.
+func exitRemoving(code int, paths ...string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	exit(code)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/util"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmReleaseSecrets warns about files in the release tarball and build
+// args that look like credentials and, when interactive, asks whether to
+// upload them anyway. Non-interactive runs continue after the warning.
+func confirmReleaseSecrets(files, buildArgs []string) bool {
+	if len(files) == 0 && len(buildArgs) == 0 {
+		return true
+	}
+
+	warningStyle := style.Warning
+	mutedStyle := style.Muted
+
+	var lines []string
+	if len(files) > 0 {
+		lines = append(lines, warningStyle.Render(style.Warn()+" The release tarball includes files that may hold secrets"), "")
+		for _, file := range files {
+			lines = append(lines, "  "+file)
+		}
+		lines = append(lines,
+			"",
+			mutedStyle.Render("They will be readable by the deploy user on the server."),
+			mutedStyle.Render("Move them out of the project, or keep values in .env.production,"),
+			mutedStyle.Render("which is read locally and written to the server's env file instead."),
+		)
+	}
+	if len(buildArgs) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, warningStyle.Render(style.Warn()+" Build args that may hold secrets"), "")
+		for _, name := range buildArgs {
+			lines = append(lines, "  --build-arg "+name)
+		}
+		lines = append(lines,
+			"",
+			mutedStyle.Render("Build args can end up in the image history and build logs."),
+			mutedStyle.Render("Pass secrets the app needs at runtime as env variables instead."),
+		)
+	}
+
+	box := style.Box(style.ColorWarning).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	fmt.Printf("\n%s\n", box)
+
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return true
+	}
+
+	fmt.Printf("Upload them anyway? (y/N): ")
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y"
+}
+
+// secretBuildArgs lists the build args whose names look like credentials,
+// using the same markers config show masks values by
+func secretBuildArgs(options *config.DeploymentOptions) []string {
+	if options == nil {
+		return nil
+	}
+	var names []string
+	for _, name := range util.SortedKeys(options.BuildArgs) {
+		lower := strings.ToLower(name)
+		for _, marker := range secretKeyMarkers {
+			if strings.Contains(lower, marker) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"reflect"
+	"testing"
+)
+
+func TestSecretBuildArgs(t *testing.T) {
+	options := &config.DeploymentOptions{BuildArgs: map[string]string{
+		"COMMIT_SHA":      "abc123",
+		"NPM_TOKEN":       "npm_x",
+		"DB_PASSWORD":     "hunter2",
+		"AWS_ACCESS_KEY":  "AKIA",
+		"NODE_VERSION":    "20",
+		"SENTRY_SECRET":   "s",
+		"NEXT_PUBLIC_URL": "https://example.com",
+	}}
+	want := []string{"AWS_ACCESS_KEY", "DB_PASSWORD", "NPM_TOKEN", "SENTRY_SECRET"}
+	if got := secretBuildArgs(options); !reflect.DeepEqual(got, want) {
+		t.Errorf("secretBuildArgs() = %v, want %v", got, want)
+	}
+	if got := secretBuildArgs(nil); got != nil {
+		t.Errorf("secretBuildArgs(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	releasesTargetFlag string
+
+	releasesHeaderStyle  = style.Title
+	releasesSuccessStyle = style.Success
+	releasesMutedStyle   = style.Muted
+	releasesValueStyle   = style.Value
+	releasesErrorStyle   = style.ErrorText
+	releasesWarningStyle = style.WarningText
+)
+
+var releasesCmd = &cobra.Command{
+	Use:   "releases [PROJECT_PATH]",
+	Short: "List the releases on the server and check they are unchanged",
+	Long: `List the releases kept on the target's server, newest first, with the branch,
+commit and checksum each was deployed with.
+
+Every upload is checked with SHA-256 on the server before it is extracted, and
+the checksum of each file it shipped is recorded in the release directory.
+This command checks those files again, so a release edited on the server by
+hand shows the files that changed. Files created after the upload, such as
+build output, are not checked.
+
+Examples:
+  lightfold releases
+  lightfold releases --target myapp
+  lightfold releases --json`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, releasesTargetFlag, pathArg)
+
+		if !target.RequiresSSHDeployment() {
+			fmt.Fprintf(os.Stderr, "%s\n", releasesErrorStyle.Render(fmt.Sprintf("Error: releases are not kept on %s", target.Provider)))
+			exit(1)
+		}
+
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", releasesErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		defer sshExecutor.Disconnect()
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", releasesErrorStyle.Render(fmt.Sprintf("Error: failed to connect to %s: %v", providerCfg.GetIP(), err)))
+			exit(1)
+		}
+
+		appName := resolveAppName(&target, targetName, sshExecutor)
+		executor := deploy.NewExecutor(sshExecutor, appName, target.ProjectPath, nil)
+		executor.SetBaseDir(target.RemoteBaseDir())
+		names, _ := executor.ListReleases()
+		integrity, err := executor.VerifyReleases(names)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", releasesErrorStyle.Render(fmt.Sprintf("Error: failed to check releases: %v", err)))
+			exit(1)
+		}
+		current, _ := executor.GetCurrentRelease()
+
+		var history []state.DeploymentRecord
+		if targetState, err := state.LoadState(targetName); err == nil {
+			history = targetState.Deployments
+		}
+		reports := releaseReports(integrity, history, path.Base(current))
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(reports)
+			return
+		}
+
+		fmt.Printf("%s %s\n", releasesHeaderStyle.Render("Releases for:"), targetName)
+		fmt.Printf("%s %s\n", releasesMutedStyle.Render("Server:"), providerCfg.GetIP())
+		if len(reports) == 0 {
+			fmt.Printf("\n%s\n", releasesMutedStyle.Render("No releases on the server"))
+			return
+		}
+		for _, report := range reports {
+			printReleaseReport(report)
+		}
+	},
+}
+
+// releaseReport is one release on the server next to its deploy record
+type releaseReport struct {
+	Release       string    `json:"release"`
+	Current       bool      `json:"current"`
+	Branch        string    `json:"branch,omitempty"`
+	Commit        string    `json:"commit,omitempty"`
+	Subject       string    `json:"subject,omitempty"`
+	DeployedBy    string    `json:"deployed_by,omitempty"`
+	DeployedAt    time.Time `json:"deployed_at,omitempty"`
+	TarballSHA256 string    `json:"tarball_sha256,omitempty"`
+	// RecordedSHA256 is the checksum in the deploy history, when it differs
+	// from the one in the release's manifest
+	RecordedSHA256 string   `json:"recorded_sha256,omitempty"`
+	Checked        bool     `json:"checked"`
+	Modified       []string `json:"modified,omitempty"`
+}
+
+// releaseReports pairs each release on the server with the newest deploy
+// history entry for it. The source in the release's manifest wins over the
+// history, which only knows the deploys run from this machine.
+func releaseReports(integrity []deploy.ReleaseIntegrity, history []state.DeploymentRecord, current string) []releaseReport {
+	records := map[string]state.DeploymentRecord{}
+	for _, record := range history {
+		records[record.Release] = record
+	}
+
+	reports := make([]releaseReport, 0, len(integrity))
+	for _, release := range integrity {
+		report := releaseReport{
+			Release:       release.Release,
+			Current:       release.Release == current,
+			Branch:        release.Source.Branch,
+			Commit:        release.Source.Commit,
+			Subject:       release.Source.Subject,
+			DeployedBy:    release.Source.DeployedBy,
+			TarballSHA256: release.TarballSHA256,
+			Checked:       release.Checked,
+			Modified:      release.Modified,
+		}
+		if record, ok := records[release.Release]; ok {
+			if report.Commit == "" {
+				report.Commit = record.Commit
+			}
+			if report.DeployedBy == "" {
+				report.DeployedBy = record.DeployedBy
+			}
+			report.DeployedAt = record.DeployedAt.UTC()
+			if record.TarballSHA256 != "" && record.TarballSHA256 != release.TarballSHA256 {
+				report.RecordedSHA256 = record.TarballSHA256
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// shortChecksum abbreviates a SHA-256 for display
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
+
+// shortCommit abbreviates a commit hash for display
+func shortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// maxSubjectWidth is how much of a commit subject release lists show
+const maxSubjectWidth = 50
+
+// releaseSource describes a release's branch and commit subject:
+// "main: Fix the login redirect"
+func releaseSource(branch, subject string) string {
+	subject = shortSubject(subject)
+	switch {
+	case branch == "":
+		return subject
+	case subject == "":
+		return branch
+	}
+	return branch + ": " + subject
+}
+
+// shortSubject cuts a commit subject to maxSubjectWidth
+func shortSubject(subject string) string {
+	if runes := []rune(subject); len(runes) > maxSubjectWidth {
+		return string(runes[:maxSubjectWidth-1]) + "…"
+	}
+	return subject
+}
+
+// releaseFilesStatus describes whether the files a release shipped changed
+func releaseFilesStatus(report releaseReport) string {
+	switch {
+	case !report.Checked:
+		return releasesMutedStyle.Render("not recorded (uploaded before checksums were kept)")
+	case len(report.Modified) == 0:
+		return releasesSuccessStyle.Render(style.Check() + " unchanged since upload")
+	}
+	return releasesErrorStyle.Render(fmt.Sprintf("%s %d changed since upload: %s", style.Cross(), len(report.Modified), strings.Join(report.Modified, ", ")))
+}
+
+func printReleaseReport(report releaseReport) {
+	name := releasesValueStyle.Render(timefmt.ReleaseAt(report.Release, report.DeployedAt))
+	if report.Current {
+		name += " " + releasesSuccessStyle.Render("(current)")
+	}
+	fmt.Printf("\n%s\n", name)
+
+	if report.Branch != "" || report.Subject != "" {
+		fmt.Printf("  Source:   %s\n", releaseSource(report.Branch, report.Subject))
+	}
+	if report.Commit != "" || report.DeployedBy != "" {
+		deployed := shortCommit(report.Commit)
+		if report.DeployedBy != "" {
+			deployed = strings.TrimSpace(deployed + " by " + report.DeployedBy)
+		}
+		if !report.DeployedAt.IsZero() {
+			deployed += releasesMutedStyle.Render(", " + timefmt.Human(report.DeployedAt))
+		}
+		fmt.Printf("  Deployed: %s\n", deployed)
+	}
+
+	switch {
+	case report.TarballSHA256 == "":
+		fmt.Printf("  Tarball:  %s\n", releasesMutedStyle.Render("no checksum recorded"))
+	case report.RecordedSHA256 != "":
+		fmt.Printf("  Tarball:  sha256 %s %s\n", shortChecksum(report.TarballSHA256),
+			releasesWarningStyle.Render(fmt.Sprintf("%s deploy history recorded %s", style.Warn(), shortChecksum(report.RecordedSHA256))))
+	default:
+		fmt.Printf("  Tarball:  sha256 %s\n", shortChecksum(report.TarballSHA256))
+	}
+	fmt.Printf("  Files:    %s\n", releaseFilesStatus(report))
+}
+
+func init() {
+	rootCmd.AddCommand(releasesCmd)
+
+	releasesCmd.Flags().StringVar(&releasesTargetFlag, "target", "", "Target name (defaults to current directory)")
+}
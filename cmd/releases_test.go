@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/state"
+)
+
+func TestReleaseReports(t *testing.T) {
+	deployedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	integrity := []deploy.ReleaseIntegrity{
+		{Release: "20240102000000", TarballSHA256: "aaa", Checked: true},
+		{Release: "20240101000000", TarballSHA256: "bbb", Checked: true, Modified: []string{"app.py"}},
+		{Release: "20231231000000", Source: deploy.ReleaseSource{Commit: "fedcba9", Branch: "hotfix/login", Subject: "Fix the login redirect", DeployedBy: "ci@runner"}},
+		{Release: "20231230000000"},
+	}
+	history := []state.DeploymentRecord{
+		{Release: "20240101000000", Commit: "old", TarballSHA256: "bbb"},
+		{Release: "20240101000000", Commit: "redeploy", TarballSHA256: "ccc"},
+		{Release: "20240102000000", Commit: "abc1234", DeployedAt: deployedAt, DeployedBy: "dev@laptop", TarballSHA256: "aaa"},
+	}
+
+	got := releaseReports(integrity, history, "20240102000000")
+	want := []releaseReport{
+		{Release: "20240102000000", Current: true, Commit: "abc1234", DeployedBy: "dev@laptop", DeployedAt: deployedAt, TarballSHA256: "aaa", Checked: true},
+		{Release: "20240101000000", Commit: "redeploy", TarballSHA256: "bbb", RecordedSHA256: "ccc", Checked: true, Modified: []string{"app.py"}},
+		{Release: "20231231000000", Branch: "hotfix/login", Commit: "fedcba9", Subject: "Fix the login redirect", DeployedBy: "ci@runner"},
+		{Release: "20231230000000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("releaseReports() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestReleaseSource(t *testing.T) {
+	tests := []struct {
+		branch, subject, want string
+	}{
+		{"main", "Fix the login redirect", "main: Fix the login redirect"},
+		{"main", "", "main"},
+		{"", "Fix the login redirect", "Fix the login redirect"},
+		{"", "", ""},
+		{"main", "Rework the session store so expired sessions are purged nightly", "main: Rework the session store so expired sessions are …"},
+	}
+	for _, tt := range tests {
+		if got := releaseSource(tt.branch, tt.subject); got != tt.want {
+			t.Errorf("releaseSource(%q, %q) = %q, want %q", tt.branch, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -2,26 +2,31 @@ package cmd
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
 	"lightfold/pkg/deploy"
 	"lightfold/pkg/detector"
 	sshpkg "lightfold/pkg/ssh"
-	"lightfold/pkg/util"
+	"lightfold/pkg/timefmt"
 	"os"
+	"path"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
 var (
 	rollbackTargetFlag string
 	rollbackForce      bool
+	rollbackBranchFlag string
 
-	rollbackHeaderStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	rollbackSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	rollbackErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-	rollbackMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	rollbackValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+	rollbackHeaderStyle  = style.Title
+	rollbackSuccessStyle = style.Success
+	rollbackErrorStyle   = style.ErrorText
+	rollbackMutedStyle   = style.Muted
+	rollbackValueStyle   = style.Value
 )
 
 var rollbackCmd = &cobra.Command{
@@ -30,14 +35,17 @@ var rollbackCmd = &cobra.Command{
 	Long: `Instantly rollback to the previous release.
 
 This command:
-- Detects the previous release from /srv/<app>/releases/
-- Switches the current symlink to the previous release
+- Lists the releases in /srv/<app>/releases/ (or deploy.base_dir) with the
+  branch, commit and deployer each came from
+- Switches the current symlink to the release before the current one, or to
+  the newest release deployed from --branch
 - Restarts the systemd service
 - Verifies the rollback with a health check
 
 Examples:
   lightfold rollback                    # Rollback current directory
   lightfold rollback --target myapp     # Rollback named target
+  lightfold rollback --branch main      # Back to the newest release from main
   lightfold rollback --force            # Skip confirmation prompt`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -53,27 +61,52 @@ Examples:
 
 		if target.Provider == "s3" {
 			fmt.Fprintf(os.Stderr, "Error: Rollback is not supported for S3 deployments\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if providerCfg.GetIP() == "" {
 			fmt.Fprintf(os.Stderr, "Error: No server IP found in configuration\n")
-			os.Exit(1)
+			exit(1)
+		}
+
+		serverTargets, err := target.ServerTargets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		// Servers of a target share release names, so the first one decides
+		releases, sources, current, err := loadRollbackReleases(serverTargets[0], targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		release, err := rollbackChoice(releases, sources, current, rollbackBranchFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
 		}
 
 		// Confirmation prompt unless --force is used
 		if !rollbackForce {
 			fmt.Printf("%s\n", rollbackHeaderStyle.Render("Rollback Confirmation"))
-			fmt.Printf("%s\n\n", rollbackMutedStyle.Render("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"))
+			fmt.Printf("%s\n\n", rollbackMutedStyle.Render(style.Rule(51)))
 			fmt.Printf("Target:  %s\n", rollbackValueStyle.Render(targetName))
 			fmt.Printf("Server:  %s\n", rollbackValueStyle.Render(providerCfg.GetIP()))
-			fmt.Printf("\n%s\n", rollbackMutedStyle.Render("This will rollback to the previous release and restart the service."))
+			for _, server := range target.Servers {
+				fmt.Printf("         %s\n", rollbackValueStyle.Render(server.IP))
+			}
+			fmt.Printf("\nReleases:\n")
+			for _, line := range rollbackListLines(releases, sources, current, release) {
+				fmt.Println(line)
+			}
+			fmt.Printf("\n%s\n", rollbackMutedStyle.Render(fmt.Sprintf("This will rollback to release %s and restart the service.", release)))
 			fmt.Printf("\n%s", rollbackMutedStyle.Render("Continue? (y/N): "))
 
 			var response string
@@ -82,29 +115,156 @@ Examples:
 
 			if response != "y" && response != "yes" {
 				fmt.Println(rollbackMutedStyle.Render("Rollback cancelled."))
-				os.Exit(0)
+				exit(0)
 			}
 			fmt.Println()
 		}
 
-		fmt.Printf("%s %s\n\n", rollbackHeaderStyle.Render("Rolling back:"), targetName)
+		fmt.Printf("%s %s\n", rollbackHeaderStyle.Render("Rolling back:"), targetName)
+		fmt.Printf("%s %s\n\n", rollbackMutedStyle.Render("To release:"), timefmt.Release(release))
 
-		projectName := util.GetTargetName(projectPath)
+		detection := detector.DetectFrameworkAs(projectPath, target.FrameworkOverride)
+		if failed := rollbackServers(serverTargets, targetName, &detection, release); failed > 0 {
+			fmt.Fprintf(os.Stderr, "%s\n", rollbackErrorStyle.Render(fmt.Sprintf("%s Rollback failed on %d of %d servers", style.Cross(), failed, len(serverTargets))))
+			exit(1)
+		}
 
-		fmt.Printf("Connecting to server at %s...\n", providerCfg.GetIP())
-		sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
-		defer sshExecutor.Disconnect()
+		fmt.Printf("\n%s\n", rollbackSuccessStyle.Render(style.Check()+" Successfully rolled back to release "+release))
+	},
+}
 
-		detection := detector.DetectFramework(target.ProjectPath)
-		executor := deploy.NewExecutor(sshExecutor, projectName, projectPath, &detection)
+// loadRollbackReleases lists a server's releases, newest first, with where
+// each came from and the name of the current one
+func loadRollbackReleases(serverTarget config.TargetConfig, targetName string) ([]string, map[string]deploy.ReleaseSource, string, error) {
+	providerCfg, err := serverTarget.GetSSHProviderConfig()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	defer sshExecutor.Disconnect()
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to connect to %s: %w", providerCfg.GetIP(), err)
+	}
 
-		if err := executor.RollbackToPreviousRelease(); err != nil {
-			fmt.Fprintf(os.Stderr, "%s\n", rollbackErrorStyle.Render(fmt.Sprintf("✗ Rollback failed: %v", err)))
-			os.Exit(1)
+	appName := resolveAppName(&serverTarget, targetName, sshExecutor)
+	executor := deploy.NewExecutor(sshExecutor, appName, serverTarget.ProjectPath, nil)
+	executor.SetBaseDir(serverTarget.RemoteBaseDir())
+	releases, err := executor.ListReleases()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to list releases: %w", err)
+	}
+	sources, err := executor.ReleaseSources(releases)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to read releases: %w", err)
+	}
+	current, _ := executor.GetCurrentRelease()
+	if current != "" {
+		current = path.Base(current)
+	}
+	return releases, sources, current, nil
+}
+
+// rollbackChoice picks the release to roll back to from releases listed
+// newest first: the one before the current release, or with a branch the
+// newest release other than the current one deployed from that branch. When
+// current is not one of the releases (nothing linked, or a link to a release
+// pruned by hand) the newest release is the one to go back to.
+func rollbackChoice(releases []string, sources map[string]deploy.ReleaseSource, current, branch string) (string, error) {
+	if branch != "" {
+		for _, release := range releases {
+			if release != current && sources[release].Branch == branch {
+				return release, nil
+			}
 		}
+		return "", fmt.Errorf("no release from branch %s to roll back to (releases deployed before branches were recorded have none)", branch)
+	}
 
-		fmt.Printf("\n%s\n", rollbackSuccessStyle.Render("✓ Successfully rolled back to previous release"))
-	},
+	if !slices.Contains(releases, current) && len(releases) > 0 {
+		return releases[0], nil
+	}
+	for i, release := range releases {
+		if release == current && i+1 < len(releases) {
+			return releases[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("no previous release available for rollback")
+}
+
+// rollbackListLines shows the releases with their branch, commit, deployer
+// and commit subject, marking the current release and the one chosen
+func rollbackListLines(releases []string, sources map[string]deploy.ReleaseSource, current, chosen string) []string {
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	branchWidth, deployerWidth := len("-"), len("-")
+	for _, release := range releases {
+		branchWidth = max(branchWidth, len(sources[release].Branch))
+		deployerWidth = max(deployerWidth, len(sources[release].DeployedBy))
+	}
+
+	lines := make([]string, 0, len(releases))
+	for _, release := range releases {
+		source := sources[release]
+		marker := "  "
+		if release == chosen {
+			marker = style.Arrow() + " "
+		}
+		line := fmt.Sprintf("  %s%s  %-*s  %-7s  %-*s  %s", marker, release,
+			branchWidth, orDash(source.Branch), orDash(shortCommit(source.Commit)),
+			deployerWidth, orDash(source.DeployedBy), shortSubject(source.Subject))
+		line = strings.TrimRight(line, " ")
+		switch release {
+		case current:
+			line += " " + rollbackMutedStyle.Render("(current)")
+		case chosen:
+			line = rollbackValueStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// rollbackServers rolls each server back to release, or to its previous
+// release when release is empty, and returns the number of servers that
+// failed. All servers are attempted.
+func rollbackServers(serverTargets []config.TargetConfig, targetName string, detection *detector.Detection, release string) int {
+	failed := 0
+	for _, serverTarget := range serverTargets {
+		providerCfg, err := serverTarget.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", rollbackErrorStyle.Render(fmt.Sprintf("%s %v", style.Cross(), err)))
+			failed++
+			continue
+		}
+
+		fmt.Printf("Connecting to server at %s...\n", providerCfg.GetIP())
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", rollbackErrorStyle.Render(fmt.Sprintf("%s Rollback failed on %s: %v", style.Cross(), providerCfg.GetIP(), err)))
+			failed++
+			continue
+		}
+
+		appName := resolveAppName(&serverTarget, targetName, sshExecutor)
+		executor := deploy.NewExecutor(sshExecutor, appName, serverTarget.ProjectPath, detection)
+		executor.SetBaseDir(serverTarget.RemoteBaseDir())
+
+		rollback := executor.RollbackToPreviousRelease
+		if release != "" {
+			rollback = func() error { return executor.RollbackToRelease(release) }
+		}
+		if err := rollback(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", rollbackErrorStyle.Render(fmt.Sprintf("%s Rollback failed on %s: %v", style.Cross(), providerCfg.GetIP(), err)))
+			failed++
+		} else if len(serverTargets) > 1 {
+			fmt.Printf("%s %s\n", rollbackSuccessStyle.Render(style.Check()), rollbackMutedStyle.Render(fmt.Sprintf("Rolled back %s", providerCfg.GetIP())))
+		}
+		sshExecutor.Disconnect()
+	}
+	return failed
 }
 
 func init() {
@@ -112,4 +272,5 @@ func init() {
 
 	rollbackCmd.Flags().StringVar(&rollbackTargetFlag, "target", "", "Target name (defaults to current directory)")
 	rollbackCmd.Flags().BoolVar(&rollbackForce, "force", false, "Skip confirmation prompt")
+	rollbackCmd.Flags().StringVar(&rollbackBranchFlag, "branch", "", "Roll back to the newest release deployed from this git branch")
 }
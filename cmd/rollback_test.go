@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"lightfold/pkg/deploy"
+)
+
+// Releases newest first: a hotfix is live on top of two deploys from main
+var (
+	rollbackReleases = []string{"20240104000000", "20240103000000", "20240102000000", "20240101000000"}
+	rollbackSources  = map[string]deploy.ReleaseSource{
+		"20240104000000": {Commit: "d4d4d4d4d4", Branch: "hotfix/login", Subject: "Fix the login redirect", DeployedBy: "alice@laptop"},
+		"20240103000000": {Commit: "c3c3c3c3c3", Branch: "main", Subject: "Add the billing page", DeployedBy: "ci@runner"},
+		"20240102000000": {Commit: "b2b2b2b2b2", Branch: "main", Subject: "Upgrade Django", DeployedBy: "ci@runner"},
+		"20240101000000": {},
+	}
+)
+
+func TestRollbackChoice(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		branch  string
+		want    string
+		wantErr string
+	}{
+		{name: "previous release", current: "20240104000000", want: "20240103000000"},
+		{name: "previous after an earlier rollback", current: "20240102000000", want: "20240101000000"},
+		{name: "newest from main", current: "20240104000000", branch: "main", want: "20240103000000"},
+		{name: "skips the current release", current: "20240103000000", branch: "main", want: "20240102000000"},
+		{name: "newer release from the branch", current: "20240102000000", branch: "hotfix/login", want: "20240104000000"},
+		{name: "unknown branch", current: "20240104000000", branch: "staging", wantErr: "no release from branch staging"},
+		{name: "oldest is live", current: "20240101000000", wantErr: "no previous release"},
+		{name: "nothing linked", current: "", want: "20240104000000"},
+		{name: "current not listed", current: "20240105000000", want: "20240104000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rollbackChoice(rollbackReleases, rollbackSources, tt.current, tt.branch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("rollbackChoice() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("rollbackChoice() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := rollbackChoice(nil, nil, "", ""); err == nil {
+		t.Error("rollbackChoice() without releases should fail")
+	}
+}
+
+func TestRollbackListLines(t *testing.T) {
+	lines := rollbackListLines(rollbackReleases, rollbackSources, "20240104000000", "20240103000000")
+	if len(lines) != len(rollbackReleases) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(rollbackReleases))
+	}
+
+	for i, want := range [][]string{
+		{"20240104000000", "hotfix/login", "d4d4d4d", "alice@laptop", "Fix the login redirect", "(current)"},
+		{"20240103000000", "main", "c3c3c3c", "ci@runner", "Add the billing page"},
+		{"20240102000000", "main", "b2b2b2b", "Upgrade Django"},
+		{"20240101000000", "-"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i], field) {
+				t.Errorf("line %d should show %q: %q", i, field, lines[i])
+			}
+		}
+	}
+	if strings.Contains(lines[1], "(current)") || strings.Contains(lines[2], "(current)") {
+		t.Errorf("only the live release is current:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// Columns line up whatever the branch and deployer lengths
+	column := func(line, field string) int {
+		return utf8.RuneCountInString(line[:strings.Index(line, field)])
+	}
+	if column(lines[0], "d4d4d4d") != column(lines[1], "c3c3c3c") || column(lines[0], "alice") != column(lines[2], "ci@runner") {
+		t.Errorf("commit column is not aligned:\n%s", strings.Join(lines, "\n"))
+	}
+}
@@ -3,13 +3,19 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
+	"lightfold/pkg/debuglog"
 	"lightfold/pkg/detector"
+	"lightfold/pkg/selfupdate"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/timefmt"
 	"lightfold/pkg/util"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
@@ -18,8 +24,14 @@ const Version = "0.1.3"
 var (
 	jsonOutput      bool
 	skipInteractive bool
-
-	logoStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
+	debugFlag       bool
+	noColorFlag     bool
+	utcFlag         bool
+	// sudoPasswordPrompt asks for the deploy user's sudo password on servers
+	// where sudo needs one, for every command that runs sudo
+	sudoPasswordPrompt bool
+
+	logoStyle = style.Title
 )
 
 const Logo = `
@@ -39,16 +51,87 @@ Lightfold automatically identifies web frameworks and generates optimal build an
 
 Supports 15+ popular frameworks including Next.js, Astro, Django, FastAPI, Express.js, and more.
 Advanced package manager detection for JavaScript/TypeScript, Python, PHP, Ruby, Go, Java, C#, and Elixir.`,
-	Version: Version,
-	Args:    cobra.MaximumNArgs(1),
-	Run:     runRootCommand,
+	Version:          Version,
+	Args:             cobra.MaximumNArgs(1),
+	PersistentPreRun: setupCommand,
+	Run:              runDefaultCommand,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	defer recoverCrash()
+	crash.SetHandler(handleCrash)
+	registerFlagCompletions(rootCmd)
+	// Help and usage print before setupCommand runs
+	style.Configure(false)
+	if style.Plain() {
+		rootCmd.Long = strings.TrimPrefix(rootCmd.Long, Logo)
+	}
+
+	err := rootCmd.Execute()
+	sshpkg.ClosePool()
+	crash.StopCapture()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+}
+
+// exit ends the process once output teed into crash dumps has been written;
+// os.Exit on its own would drop what is still in the tee
+func exit(code int) {
+	crash.Exit(code)
+}
+
+// setupCommand runs before every command. All SSH executors of one invocation
+// share a connection per server; Execute closes them when the command returns.
+func setupCommand(cmd *cobra.Command, args []string) {
+	crashCommand = cmd
+	style.Configure(noColorFlag)
+	crash.CaptureOutput()
+	timefmt.SetUTC(utcFlag)
+	sshpkg.EnablePooling()
+	if sudoPasswordPrompt {
+		sshpkg.SetSudoPasswordPrompt(promptSudoPassword)
 	}
+	selfupdate.RemoveReplacedExecutable()
+	setupDebugLogging(cmd, args)
+}
+
+// setupDebugLogging turns on API and SSH tracing for --debug. Command arguments
+// are not logged since some commands take tokens as arguments.
+func setupDebugLogging(cmd *cobra.Command, args []string) {
+	if !debugFlag {
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: debug logging disabled: %v\n", err)
+		return
+	}
+
+	logPath := filepath.Join(home, config.LocalConfigDir, "debug.log")
+	if err := debuglog.Enable(logPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: debug logging disabled: %v\n", err)
+		return
+	}
+
+	sshpkg.SetDefaultTraceHook(traceSSHCommand)
+	debuglog.Log("command_start", "command", cmd.CommandPath(), "version", Version)
+	fmt.Fprintf(os.Stderr, "Debug log: %s\n", logPath)
+}
+
+func traceSSHCommand(trace sshpkg.CommandTrace) {
+	attrs := []any{
+		"host", trace.Host,
+		"command", debuglog.RedactCommand(trace.Command),
+		"duration_ms", trace.Duration.Milliseconds(),
+		"exit_code", trace.ExitCode,
+	}
+	if trace.Err != nil {
+		attrs = append(attrs, "error", trace.Err.Error())
+	}
+	debuglog.Log("ssh_command", attrs...)
 }
 
 func runRootCommand(cmd *cobra.Command, args []string) {
@@ -64,12 +147,12 @@ func runRootCommand(cmd *cobra.Command, args []string) {
 	info, err := os.Stat(projectPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Cannot access path '%s': %v\n", projectPath, err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	if !info.IsDir() {
 		fmt.Fprintf(os.Stderr, "Error: Path '%s' is not a directory\n", projectPath)
-		os.Exit(1)
+		exit(1)
 	}
 
 	if jsonOutput || skipInteractive || !isTerminal() {
@@ -80,7 +163,11 @@ func runRootCommand(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fmt.Printf("%s\n", logoStyle.Render(Logo))
+	if style.Plain() {
+		fmt.Printf("%s\n\n", logoStyle.Render("LIGHTFOLD"))
+	} else {
+		fmt.Printf("%s\n", logoStyle.Render(Logo))
+	}
 
 	detectionResult := detector.DetectFramework(projectPath)
 
@@ -88,10 +175,10 @@ func runRootCommand(cmd *cobra.Command, args []string) {
 }
 
 func showDetectionResults(detection detector.Detection) {
-	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
-	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	signalStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#40BDA3"))
-	successCheckStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
+	labelStyle := style.Label
+	valueStyle := style.Text
+	signalStyle := style.Fg("#40BDA3")
+	successCheckStyle := style.Success
 
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -99,9 +186,7 @@ func showDetectionResults(detection detector.Detection) {
 	}
 	targetName := util.GetTargetName(cwd)
 
-	frameworkBox := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#01FAC6")).
+	frameworkBox := style.Box(style.ColorBrand).
 		Padding(1, 2).
 		Width(60)
 
@@ -122,7 +207,7 @@ func showDetectionResults(detection detector.Detection) {
 		content.WriteString(labelStyle.Render("Detection signals:"))
 		content.WriteString("\n")
 		for _, signal := range detection.Signals {
-			content.WriteString(successCheckStyle.Render("  ✓ "))
+			content.WriteString(successCheckStyle.Render("  " + style.Check() + " "))
 			content.WriteString(signalStyle.Render(signal))
 			content.WriteString("\n")
 		}
@@ -144,8 +229,8 @@ func showDetectionResults(detection detector.Detection) {
 
 	fmt.Printf("%s\n\n", frameworkBox.Render(content.String()))
 
-	deployStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6"))
-	normalStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	deployStyle := style.Brand
+	normalStyle := style.Text
 	fmt.Printf("%s%s%s\n",
 		normalStyle.Render("Run "),
 		deployStyle.Render("'lightfold deploy'"),
@@ -162,10 +247,15 @@ func isTerminal() bool {
 
 func init() {
 	rootCmd.SetVersionTemplate("lightfold version {{.Version}}\n")
+	config.CLIVersion = Version
 
 	rootCmd.AddCommand(detectCmd)
 	rootCmd.AddCommand(autoDeployCmd)
 
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output results as JSON (disables interactive mode)")
 	rootCmd.PersistentFlags().BoolVar(&skipInteractive, "no-interactive", false, "Skip interactive prompts (for CI/automation)")
+	rootCmd.PersistentFlags().BoolVar(&noColorFlag, "no-color", false, "Disable colors and unicode symbols in output (also set by NO_COLOR or a non-terminal stdout)")
+	rootCmd.PersistentFlags().BoolVar(&utcFlag, "utc", false, "Show times in UTC instead of the local time zone")
+	rootCmd.PersistentFlags().BoolVar(&sudoPasswordPrompt, "sudo-password-prompt", false, "Prompt for the deploy user's sudo password when sudo on the server needs one (kept in memory for this command only)")
+	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "Write provider API calls and SSH commands to ~/.lightfold/debug.log (secrets redacted)")
 }
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/detector"
+	runtimepkg "lightfold/pkg/runtime"
+	"lightfold/pkg/state"
+	"sort"
+	"strings"
+)
+
+// runtimeMatrixRow is one runtime version on a server and the apps requiring it
+type runtimeMatrixRow struct {
+	Runtime string
+	Version string // "any" when the apps pin no version
+	Apps    []string
+	Note    string
+}
+
+// runtimeMatrix groups the runtime versions apps on a server require. Node.js
+// majors other apps do not share are noted as installed side by side; other
+// runtimes come from the distribution, so differing pins share one version.
+func runtimeMatrix(uses []state.RuntimeUse) []runtimeMatrixRow {
+	byVersion := map[[2]string][]string{}
+	pinned := map[string]map[string]bool{}
+	for _, use := range uses {
+		key := [2]string{string(use.Runtime), use.Version}
+		byVersion[key] = append(byVersion[key], use.TargetName)
+		if use.Version != "" {
+			if pinned[string(use.Runtime)] == nil {
+				pinned[string(use.Runtime)] = map[string]bool{}
+			}
+			pinned[string(use.Runtime)][use.Version] = true
+		}
+	}
+
+	rows := make([]runtimeMatrixRow, 0, len(byVersion))
+	for key, apps := range byVersion {
+		sort.Strings(apps)
+		row := runtimeMatrixRow{Runtime: key[0], Version: key[1], Apps: apps}
+		if row.Version == "" {
+			row.Version = "any"
+		}
+		if len(pinned[key[0]]) > 1 && key[1] != "" {
+			if key[0] == string(runtimepkg.RuntimeNodeJS) {
+				row.Note = "side by side unless it is the default node"
+			} else {
+				row.Note = "conflicting pins share the distribution version"
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Runtime != rows[j].Runtime {
+			return rows[i].Runtime < rows[j].Runtime
+		}
+		return rows[i].Version < rows[j].Version
+	})
+	return rows
+}
+
+// printRuntimeMatrix shows the runtime versions apps on a shared server
+// require, including the app being configured, before anything is installed.
+// Servers hosting only this app print nothing.
+func printRuntimeMatrix(serverIP, targetName string, detection detector.Detection) {
+	serverState, err := state.GetServerState(serverIP)
+	if err != nil {
+		return
+	}
+	uses := serverState.OtherRuntimeUses(targetName)
+	if len(uses) == 0 {
+		return
+	}
+
+	rt := runtimepkg.GetRuntimeForDetection(detection.Language, detection.Framework)
+	if rt != runtimepkg.RuntimeUnknown {
+		uses = append(uses, state.RuntimeUse{
+			TargetName: targetName,
+			Runtime:    state.Runtime(rt),
+			Version:    runtimepkg.RequiredVersion(rt, detection.Meta["runtime_version"]),
+		})
+	}
+
+	headerStyle := style.Header
+	mutedStyle := style.Muted
+	fmt.Printf("%s\n", headerStyle.Render(fmt.Sprintf("Runtimes on %s:", serverIP)))
+	for _, row := range runtimeMatrix(uses) {
+		line := fmt.Sprintf("  %-8s %-5s %s", row.Runtime, row.Version, strings.Join(row.Apps, ", "))
+		if row.Note != "" {
+			line += " (" + row.Note + ")"
+		}
+		fmt.Printf("%s\n", mutedStyle.Render(line))
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"lightfold/pkg/state"
+	"reflect"
+	"testing"
+)
+
+func TestRuntimeMatrix(t *testing.T) {
+	rows := runtimeMatrix([]state.RuntimeUse{
+		{TargetName: "app-b", Runtime: "nodejs", Version: "22"},
+		{TargetName: "app-a", Runtime: "nodejs", Version: "20"},
+		{TargetName: "docs", Runtime: "nodejs"},
+		{TargetName: "blog", Runtime: "nodejs", Version: "22"},
+		{TargetName: "api", Runtime: "python", Version: "3.12"},
+	})
+
+	want := []runtimeMatrixRow{
+		{Runtime: "nodejs", Version: "20", Apps: []string{"app-a"}, Note: "side by side unless it is the default node"},
+		{Runtime: "nodejs", Version: "22", Apps: []string{"app-b", "blog"}, Note: "side by side unless it is the default node"},
+		{Runtime: "nodejs", Version: "any", Apps: []string{"docs"}},
+		{Runtime: "python", Version: "3.12", Apps: []string{"api"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("runtimeMatrix() = %+v, want %+v", rows, want)
+	}
+}
+
+func TestRuntimeMatrixConflictingDistroPins(t *testing.T) {
+	rows := runtimeMatrix([]state.RuntimeUse{
+		{TargetName: "api", Runtime: "python", Version: "3.12"},
+		{TargetName: "worker", Runtime: "python", Version: "3.10"},
+	})
+	for _, row := range rows {
+		if row.Note != "conflicting pins share the distribution version" {
+			t.Errorf("row %+v should note the shared distribution version", row)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/state"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleTargetFlag   string
+	scheduleStopFlag     string
+	scheduleStartFlag    string
+	scheduleTimezoneFlag string
+	scheduleForceFlag    bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Power a target's servers off and on at set times",
+	Long: `Stop non-production targets outside working hours to save cost.
+
+A schedule has a stop and a start time as cron expressions (minute hour day
+month weekday) in an IANA time zone. At the stop time a systemd timer on each
+server stops the app and powers the server off. Servers cannot power
+themselves back on, so 'lightfold schedule run' does the start through the
+provider API: run it from cron or CI every few minutes. It also pauses
+targets whose stop time passed while they were still running.
+
+Examples:
+  lightfold schedule set --target staging --stop "0 20 * * 1-5" --start "0 8 * * 1-5" --timezone Europe/Berlin
+  lightfold schedule run
+  lightfold schedule remove --target staging`,
+}
+
+var scheduleSetCmd = &cobra.Command{
+	Use:   "set [PROJECT_PATH]",
+	Short: "Set a target's stop and start times",
+	Long: `Store the schedule in the target config and install the stop timer on the
+target's servers. The time zone is required so the schedule does not depend
+on where lightfold runs; it follows DST changes of the zone.
+
+Schedules use the pause and resume mechanics, so only providers that can
+power servers off and on through their API are supported.
+
+Examples:
+  lightfold schedule set --target staging --stop "0 20 * * 1-5" --start "0 8 * * 1-5" --timezone Europe/Berlin
+  lightfold schedule set --target demo --stop "30 18 * * *" --timezone America/New_York`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, scheduleTargetFlag, pathArg)
+		exitIfPaused(targetName)
+
+		schedule := &config.PowerSchedule{Stop: scheduleStopFlag, Start: scheduleStartFlag, Timezone: scheduleTimezoneFlag}
+		if err := schedule.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		onCalendar := ""
+		if schedule.Stop != "" {
+			stop, _ := config.ParseCron(schedule.Stop)
+			var err error
+			if onCalendar, err = stop.OnCalendar(schedule.Timezone); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+				exit(1)
+			}
+		}
+
+		_, servers, err := targetPowerPlan(target, targetName)
+		if err == nil && onCalendar != "" && !scheduleForceFlag {
+			err = refuseSharedServers(servers, targetName, "the stop timer")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		appName := utils.RemoteAppName(&target, targetName)
+		for _, server := range servers {
+			err := withStopTimer(server, appName, func(executor *deploy.Executor) error {
+				if onCalendar == "" {
+					return executor.RemoveStopTimer()
+				}
+				return executor.InstallStopTimer(onCalendar)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", pauseErrorStyle.Render("Error:"), server.ip, err)
+				exit(1)
+			}
+			if onCalendar != "" {
+				fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("Installed stop timer on %s (%s)", server.ip, onCalendar)))
+			}
+		}
+
+		target.Schedule = schedule
+		if err := saveScheduleTarget(cfg, targetName, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+		// Only stop and start times from now on count
+		if err := state.RecordScheduleCheck(targetName, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed to save state: %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		status := scheduleStatus(schedule, time.Now())
+		fmt.Printf("\n%s %s\n", pauseSuccessStyle.Render(style.Check()+" Scheduled target"), pauseValueStyle.Render(targetName))
+		fmt.Printf("%s\n", pauseMutedStyle.Render(status.Describe()))
+		if status.NextAction != "" {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Next %s: %s", status.NextAction, formatStatusTime(status.NextAt))))
+		}
+		if schedule.Start != "" {
+			fmt.Printf("%s\n", pauseMutedStyle.Render("Run 'lightfold schedule run' from cron or CI every few minutes to power the servers back on"))
+		}
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove [PROJECT_PATH]",
+	Short: "Remove a target's schedule and its stop timer",
+	Long: `Remove the stop timer from the target's servers and the schedule from the
+target config. A paused target must be resumed first so its servers can be
+reached.
+
+Examples:
+  lightfold schedule remove --target staging`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+		target, targetName := resolveTarget(cfg, scheduleTargetFlag, pathArg)
+
+		if target.Schedule == nil {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Target '%s' has no schedule", targetName)))
+			return
+		}
+		exitIfPaused(targetName)
+
+		servers, err := targetServers(target, targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		appName := utils.RemoteAppName(&target, targetName)
+		for _, server := range servers {
+			if err := withStopTimer(server, appName, (*deploy.Executor).RemoveStopTimer); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", pauseErrorStyle.Render("Error:"), server.ip, err)
+				exit(1)
+			}
+			fmt.Printf("%s %s\n", pauseSuccessStyle.Render(style.Check()), pauseMutedStyle.Render(fmt.Sprintf("Removed stop timer from %s", server.ip)))
+		}
+
+		target.Schedule = nil
+		if err := saveScheduleTarget(cfg, targetName, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", pauseErrorStyle.Render("Error:"), err)
+			exit(1)
+		}
+
+		fmt.Printf("\n%s %s\n", pauseSuccessStyle.Render(style.Check()+" Removed schedule of"), pauseValueStyle.Render(targetName))
+	},
+}
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Apply the stop and start times that passed since the last run",
+	Long: `Pause or resume every scheduled target whose stop or start time passed since
+the last run, using the provider API. Only the latest time counts, and a
+target paused or resumed by hand stays that way until its next scheduled
+time. Meant for cron or CI; exits non-zero when a target failed, which is
+retried on the next run.
+
+Examples:
+  lightfold schedule run
+  lightfold schedule run --target staging
+  */5 * * * * lightfold schedule run   # crontab entry`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		targetNames := scheduledTargets(cfg, scheduleTargetFlag)
+		if len(targetNames) == 0 {
+			fmt.Printf("%s\n", pauseMutedStyle.Render("No scheduled targets"))
+			return
+		}
+
+		failed := false
+		for _, targetName := range targetNames {
+			if err := runTargetSchedule(cfg.Targets[targetName], targetName, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", pauseErrorStyle.Render("Error:"), targetName, err)
+				failed = true
+			}
+		}
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+// scheduledTargets returns the named target, or all targets with a schedule
+func scheduledTargets(cfg *config.Config, targetName string) []string {
+	if targetName != "" {
+		target, ok := cfg.Targets[targetName]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s target '%s' not found\n", pauseErrorStyle.Render("Error:"), targetName)
+			exit(1)
+		}
+		if target.Schedule == nil {
+			fmt.Fprintf(os.Stderr, "%s target '%s' has no schedule\n", pauseErrorStyle.Render("Error:"), targetName)
+			exit(1)
+		}
+		return []string{targetName}
+	}
+
+	names := []string{}
+	for name, target := range cfg.Targets {
+		if target.Schedule != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runTargetSchedule pauses or resumes the target for the latest stop or start
+// time since the last check. The check is only recorded when the action
+// succeeded, so a failure is retried on the next run.
+func runTargetSchedule(target config.TargetConfig, targetName string, now time.Time) error {
+	targetState, err := state.LoadState(targetName)
+	if err != nil {
+		return err
+	}
+	since := targetState.ScheduleCheckedAt
+	if since.IsZero() {
+		since = now.Add(-24 * time.Hour)
+	}
+
+	action, at, err := target.Schedule.LastEvent(since, now)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case action == "":
+		fmt.Printf("%s %s\n", pauseValueStyle.Render(targetName), pauseMutedStyle.Render("nothing due"))
+	case action == config.ScheduleStop && targetState.Paused:
+		fmt.Printf("%s %s\n", pauseValueStyle.Render(targetName), pauseMutedStyle.Render("already paused"))
+	case action == config.ScheduleStop:
+		fmt.Printf("%s %s\n", pauseValueStyle.Render(targetName), pauseMutedStyle.Render("stopping, scheduled at "+at.Format("2006-01-02 15:04 MST")))
+		// Shared servers were refused when the schedule was set; the stop
+		// timer powers the server off regardless
+		if err := pauseTarget(target, targetName, true); err != nil {
+			return err
+		}
+	default:
+		fmt.Printf("%s %s\n", pauseValueStyle.Render(targetName), pauseMutedStyle.Render("starting, scheduled at "+at.Format("2006-01-02 15:04 MST")))
+		ipChanged, err := resumeTarget(target, targetName)
+		if err != nil {
+			return err
+		}
+		if ipChanged {
+			fmt.Printf("%s\n", pauseMutedStyle.Render(fmt.Sprintf("Run 'lightfold sync --target %s --fix' to update server state and re-apply the domain", targetName)))
+		}
+	}
+
+	return state.RecordScheduleCheck(targetName, now)
+}
+
+// withStopTimer connects to a server and runs fn with an executor for the app
+func withStopTimer(server powerServer, appName string, fn func(*deploy.Executor) error) error {
+	sshExecutor, err := connectPowerServer(server, 3)
+	if err != nil {
+		return err
+	}
+	defer sshExecutor.Disconnect()
+
+	executor := deploy.NewExecutor(sshExecutor, appName, server.target.ProjectPath, nil)
+	executor.SetBaseDir(server.target.RemoteBaseDir())
+	return fn(executor)
+}
+
+func saveScheduleTarget(cfg *config.Config, targetName string, target config.TargetConfig) error {
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		return err
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleSetCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+	scheduleCmd.AddCommand(scheduleRunCmd)
+
+	for _, c := range []*cobra.Command{scheduleSetCmd, scheduleRemoveCmd, scheduleRunCmd} {
+		c.Flags().StringVar(&scheduleTargetFlag, "target", "", "Target name (defaults to current directory)")
+	}
+	scheduleRunCmd.Flags().Lookup("target").Usage = "Only run this target's schedule (defaults to all scheduled targets)"
+
+	scheduleSetCmd.Flags().StringVar(&scheduleStopFlag, "stop", "", "Cron expression of the times to stop, e.g. \"0 20 * * 1-5\"")
+	scheduleSetCmd.Flags().StringVar(&scheduleStartFlag, "start", "", "Cron expression of the times to start, e.g. \"0 8 * * 1-5\"")
+	scheduleSetCmd.Flags().BoolVar(&scheduleForceFlag, "force", false, "Install the stop timer on servers other apps are deployed to as well")
+	scheduleSetCmd.Flags().StringVar(&scheduleTimezoneFlag, "timezone", "", "IANA time zone of the times, e.g. Europe/Berlin (required)")
+	scheduleSetCmd.MarkFlagRequired("timezone")
+}
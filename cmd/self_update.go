@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/selfupdate"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// selfUpdateTimeout bounds the release lookup and download
+const selfUpdateTimeout = 5 * time.Minute
+
+var selfUpdateForce bool
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Replace this lightfold binary with the latest release",
+	Long: `Download the latest lightfold release for this platform from GitHub, verify
+it against the release's checksums.txt and replace the running binary.
+
+The binary is replaced atomically, so an interrupted update leaves the old one
+in place. Binaries installed with Homebrew are updated with 'brew upgrade'.
+
+Examples:
+  lightfold self-update
+  lightfold self-update --force   # Reinstall even when already up to date`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSelfUpdate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+func runSelfUpdate() error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the lightfold binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+	if strings.Contains(filepath.ToSlash(exePath), "/Cellar/") {
+		return fmt.Errorf("lightfold was installed with Homebrew; run 'brew upgrade lightfold' instead")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfUpdateTimeout)
+	defer cancel()
+
+	checker := selfupdate.NewChecker()
+	release, err := checker.Latest(ctx, false)
+	if err != nil {
+		return err
+	}
+	if !release.NewerThan(Version) && !selfUpdateForce {
+		fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render(fmt.Sprintf("lightfold %s is the latest release", Version)))
+		return nil
+	}
+
+	fmt.Printf("%s\n", deployMutedStyle.Render(fmt.Sprintf("Downloading lightfold %s for %s/%s...", release.Version(), runtime.GOOS, runtime.GOARCH)))
+	binary, err := checker.DownloadBinary(ctx, release, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.ReplaceExecutable(exePath, binary); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", deploySuccessStyle.Render(style.Check()), deployMutedStyle.Render(fmt.Sprintf("Updated %s from %s to %s", exePath, Version, release.Version())))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateForce, "force", false, "Reinstall the latest release even when already up to date")
+}
@@ -2,20 +2,27 @@ package cmd
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
 	"os"
 	"time"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
 var (
-	serverHeaderStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	serverLabelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	serverValueStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	serverMutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	serverErrorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	serverTargetFlag string
+	serverKeyFlag    string
+
+	serverHeaderStyle  = style.Title
+	serverLabelStyle   = style.Header
+	serverValueStyle   = style.Value
+	serverMutedStyle   = style.Faint
+	serverErrorStyle   = style.ErrorText
+	serverSuccessStyle = style.Success
 )
 
 // serverCmd represents the server command
@@ -30,7 +37,10 @@ deploying multiple applications to a single server.
 
 Examples:
   lightfold server list              # List all servers and their apps
-  lightfold server show <server-ip>  # Show detailed info for a server`,
+  lightfold server list --label team=payments
+  lightfold server show <server-ip>  # Show detailed info for a server
+  lightfold server add-key --target myapp --key ~/.ssh/teammate.pub
+  lightfold server attach --target myapp --ip 203.0.113.20`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Default to list if no subcommand provided
 		cmd.Help()
@@ -45,13 +55,29 @@ var serverListCmd = &cobra.Command{
 the applications deployed to each server.
 
 This provides a server-centric view, which is particularly useful when
-managing multiple applications on shared infrastructure.`,
+managing multiple applications on shared infrastructure. --label key=value
+lists only servers carrying that label, as recorded when they were
+provisioned or retagged.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		selector, err := config.ParseLabels(serverListLabels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
 		// Get all servers from state files
 		servers, err := state.ListAllServers()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error loading servers: %v", err)))
-			os.Exit(1)
+			exit(1)
+		}
+
+		if len(selector) > 0 {
+			servers = filterServersByLabels(servers, selector)
+			if len(servers) == 0 {
+				fmt.Println(serverMutedStyle.Render(fmt.Sprintf("No servers labeled %s.", formatLabels(selector))))
+				return
+			}
 		}
 
 		if len(servers) == 0 {
@@ -62,7 +88,7 @@ managing multiple applications on shared infrastructure.`,
 		}
 
 		fmt.Printf("%s\n", serverHeaderStyle.Render(fmt.Sprintf("Servers (%d):", len(servers))))
-		fmt.Println(serverMutedStyle.Render("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"))
+		fmt.Println(serverMutedStyle.Render(style.Rule(51)))
 
 		for _, serverIP := range servers {
 			serverState, err := state.GetServerState(serverIP)
@@ -81,6 +107,9 @@ managing multiple applications on shared infrastructure.`,
 			if serverState.RootDomain != "" {
 				fmt.Printf("  Domain:     %s\n", serverValueStyle.Render(serverState.RootDomain))
 			}
+			if len(serverState.Labels) > 0 {
+				fmt.Printf("  Labels:     %s\n", serverValueStyle.Render(formatLabels(serverState.Labels)))
+			}
 
 			appCount := len(serverState.DeployedApps)
 			fmt.Printf("  Apps:       %s\n", serverValueStyle.Render(fmt.Sprintf("%d deployed", appCount)))
@@ -88,7 +117,7 @@ managing multiple applications on shared infrastructure.`,
 			if appCount > 0 {
 				fmt.Printf("\n  %s\n", serverLabelStyle.Render("Deployed Applications:"))
 				for _, app := range serverState.DeployedApps {
-					fmt.Printf("    • %s", serverValueStyle.Render(app.TargetName))
+					fmt.Printf("    %s %s", style.Bullet(), serverValueStyle.Render(app.TargetName))
 					if app.Framework != "" {
 						fmt.Printf(" (%s)", serverMutedStyle.Render(app.Framework))
 					}
@@ -101,7 +130,7 @@ managing multiple applications on shared infrastructure.`,
 					fmt.Println()
 
 					if !app.LastDeploy.IsZero() {
-						fmt.Printf("      Last deployed: %s\n", serverMutedStyle.Render(app.LastDeploy.Format("2006-01-02 15:04")))
+						fmt.Printf("      Last deployed: %s\n", serverMutedStyle.Render(timefmt.Human(app.LastDeploy)))
 					}
 				}
 			}
@@ -117,7 +146,7 @@ managing multiple applications on shared infrastructure.`,
 			// Check for conflicts
 			conflicts, err := state.DetectPortConflicts(serverIP)
 			if err == nil && len(conflicts) > 0 {
-				fmt.Printf("\n  %s\n", serverErrorStyle.Render("⚠ Port Conflicts Detected:"))
+				fmt.Printf("\n  %s\n", serverErrorStyle.Render(style.Warn()+" Port Conflicts Detected:"))
 				for _, conflict := range conflicts {
 					fmt.Printf("    %s\n", serverErrorStyle.Render(conflict))
 				}
@@ -148,18 +177,18 @@ var serverShowCmd = &cobra.Command{
 		serverState, err := state.GetServerState(serverIP)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error loading server state: %v", err)))
-			os.Exit(1)
+			exit(1)
 		}
 
 		if !state.ServerStateExists(serverIP) {
 			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Server %s not found", serverIP)))
 			fmt.Fprintf(os.Stderr, "\nRun 'lightfold server list' to see all servers\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Display server information
 		fmt.Printf("%s %s\n", serverHeaderStyle.Render("Server:"), serverLabelStyle.Render(serverIP))
-		fmt.Printf("%s\n\n", serverMutedStyle.Render("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"))
+		fmt.Printf("%s\n\n", serverMutedStyle.Render(style.Rule(51)))
 
 		fmt.Printf("%s\n", serverHeaderStyle.Render("Configuration:"))
 		if serverState.Provider != "" {
@@ -175,10 +204,10 @@ var serverShowCmd = &cobra.Command{
 			fmt.Printf("  Root Domain: %s\n", serverValueStyle.Render(serverState.RootDomain))
 		}
 		if !serverState.CreatedAt.IsZero() {
-			fmt.Printf("  Created:     %s\n", serverValueStyle.Render(serverState.CreatedAt.Format("2006-01-02 15:04:05")))
+			fmt.Printf("  Created:     %s\n", serverValueStyle.Render(timefmt.Human(serverState.CreatedAt)))
 		}
 		if !serverState.UpdatedAt.IsZero() {
-			fmt.Printf("  Updated:     %s\n", serverValueStyle.Render(serverState.UpdatedAt.Format("2006-01-02 15:04:05")))
+			fmt.Printf("  Updated:     %s\n", serverValueStyle.Render(timefmt.Human(serverState.UpdatedAt)))
 		}
 		fmt.Println()
 
@@ -227,10 +256,9 @@ var serverShowCmd = &cobra.Command{
 					fmt.Printf("     Domain:    %s\n", serverValueStyle.Render(app.Domain))
 				}
 				if !app.LastDeploy.IsZero() {
-					timeAgo := formatTimeAgo(time.Since(app.LastDeploy))
 					fmt.Printf("     Deployed:  %s (%s)\n",
-						serverValueStyle.Render(app.LastDeploy.Format("2006-01-02 15:04")),
-						serverMutedStyle.Render(timeAgo))
+						serverValueStyle.Render(timefmt.Absolute(app.LastDeploy)),
+						serverMutedStyle.Render(timefmt.Relative(app.LastDeploy, time.Now())))
 				}
 			}
 			fmt.Println()
@@ -241,7 +269,7 @@ var serverShowCmd = &cobra.Command{
 		if len(targetsOnServer) > 0 {
 			fmt.Printf("%s\n", serverHeaderStyle.Render(fmt.Sprintf("Associated Targets (%d):", len(targetsOnServer))))
 			for targetName, target := range targetsOnServer {
-				fmt.Printf("  • %s", serverValueStyle.Render(targetName))
+				fmt.Printf("  %s %s", style.Bullet(), serverValueStyle.Render(targetName))
 				if target.Framework != "" {
 					fmt.Printf(" (%s)", serverMutedStyle.Render(target.Framework))
 				}
@@ -257,33 +285,184 @@ var serverShowCmd = &cobra.Command{
 	},
 }
 
-// formatTimeAgo formats a duration into a human-readable "time ago" string
-func formatTimeAgo(d time.Duration) string {
-	if d < time.Minute {
-		return "just now"
-	} else if d < time.Hour {
-		minutes := int(d.Minutes())
-		if minutes == 1 {
-			return "1 minute ago"
+// serverAddKeyCmd authorizes an extra public key for the deploy user on a target's server
+var serverAddKeyCmd = &cobra.Command{
+	Use:   "add-key",
+	Short: "Authorize an additional SSH public key on a target's server",
+	Long: `Append a public key (e.g. a teammate's) to the deploy user's authorized_keys.
+
+The key is recorded in the server state and the target configuration so that
+it is re-applied automatically when the server is rebuilt.
+
+Examples:
+  lightfold server add-key --target myapp --key ~/.ssh/teammate.pub
+  lightfold server add-key --target myapp --key "ssh-ed25519 AAAA... alice@laptop"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		publicKey, err := sshpkg.ResolveAuthorizedKey(serverKeyFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		providerCfg, sshExecutor := connectTargetServerOrExit(target, targetName)
+		defer sshExecutor.Disconnect()
+
+		if err := sshExecutor.AddAuthorizedKey(publicKey); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
 		}
-		return fmt.Sprintf("%d minutes ago", minutes)
-	} else if d < 24*time.Hour {
-		hours := int(d.Hours())
-		if hours == 1 {
-			return "1 hour ago"
+
+		if err := state.AddAuthorizedKey(providerCfg.GetIP(), publicKey); err != nil {
+			fmt.Printf("Warning: failed to record key in server state: %v\n", err)
 		}
-		return fmt.Sprintf("%d hours ago", hours)
-	} else {
-		days := int(d.Hours() / 24)
-		if days == 1 {
-			return "1 day ago"
+
+		keys := appendUniqueKey(providerCfg.GetAuthorizedKeys(), publicKey)
+		saveTargetAuthorizedKeys(cfg, &target, targetName, keys)
+
+		fmt.Printf("%s Authorized key on %s for user %s\n",
+			serverSuccessStyle.Render(style.Check()),
+			serverValueStyle.Render(providerCfg.GetIP()),
+			serverValueStyle.Render(providerCfg.GetUsername()))
+	},
+}
+
+// serverRemoveKeyCmd revokes a previously added public key
+var serverRemoveKeyCmd = &cobra.Command{
+	Use:   "remove-key",
+	Short: "Remove an additional SSH public key from a target's server",
+	Long: `Remove a public key from the deploy user's authorized_keys and forget it in
+the server state and target configuration.
+
+The Lightfold deploy key itself cannot be removed with this command.
+
+Examples:
+  lightfold server remove-key --target myapp --key ~/.ssh/teammate.pub`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		publicKey, err := sshpkg.ResolveAuthorizedKey(serverKeyFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
 		}
-		return fmt.Sprintf("%d days ago", days)
+
+		if deployKey, err := sshpkg.LoadPublicKey(providerCfg.GetSSHKey() + ".pub"); err == nil && sshpkg.SameAuthorizedKey(deployKey, publicKey) {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render("Error: refusing to remove the Lightfold deploy key"))
+			exit(1)
+		}
+
+		_, sshExecutor := connectTargetServerOrExit(target, targetName)
+		defer sshExecutor.Disconnect()
+
+		if err := sshExecutor.RemoveAuthorizedKey(publicKey); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		if err := state.RemoveAuthorizedKey(providerCfg.GetIP(), publicKey); err != nil {
+			fmt.Printf("Warning: failed to update server state: %v\n", err)
+		}
+
+		var keys []string
+		for _, key := range providerCfg.GetAuthorizedKeys() {
+			if resolved, err := sshpkg.ResolveAuthorizedKey(key); err == nil && sshpkg.SameAuthorizedKey(resolved, publicKey) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		saveTargetAuthorizedKeys(cfg, &target, targetName, keys)
+
+		fmt.Printf("%s Removed key from %s\n", serverSuccessStyle.Render(style.Check()), serverValueStyle.Render(providerCfg.GetIP()))
+	},
+}
+
+// connectTargetServerOrExit opens an SSH connection to the target's server or exits
+func connectTargetServerOrExit(target config.TargetConfig, targetName string) (config.ProviderConfig, *sshpkg.Executor) {
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+		exit(1)
+	}
+
+	if providerCfg.GetIP() == "" {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: target '%s' has no server IP yet", targetName)))
+		exit(1)
 	}
+
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error connecting to server: %v", err)))
+		exit(1)
+	}
+
+	return providerCfg, sshExecutor
+}
+
+func saveTargetAuthorizedKeys(cfg *config.Config, target *config.TargetConfig, targetName string, keys []string) {
+	if err := target.SetAuthorizedKeys(keys); err != nil {
+		fmt.Printf("Warning: failed to update target config: %v\n", err)
+		return
+	}
+	cfg.SetTarget(targetName, *target)
+	if err := cfg.SaveConfig(); err != nil {
+		fmt.Printf("Warning: failed to save config: %v\n", err)
+	}
+}
+
+func appendUniqueKey(keys []string, publicKey string) []string {
+	for _, key := range keys {
+		if resolved, err := sshpkg.ResolveAuthorizedKey(key); err == nil && sshpkg.SameAuthorizedKey(resolved, publicKey) {
+			return keys
+		}
+	}
+	return append(keys, publicKey)
 }
 
 func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.AddCommand(serverListCmd)
 	serverCmd.AddCommand(serverShowCmd)
+	serverCmd.AddCommand(serverAddKeyCmd)
+	serverCmd.AddCommand(serverRemoveKeyCmd)
+	serverCmd.AddCommand(serverAttachCmd)
+	serverCmd.AddCommand(serverDetachCmd)
+	serverCmd.AddCommand(serverLoadBalancerCmd)
+	serverCmd.AddCommand(serverSnapshotCmd)
+	serverCmd.AddCommand(serverRestoreCmd)
+
+	for _, c := range []*cobra.Command{serverAddKeyCmd, serverRemoveKeyCmd} {
+		c.Flags().StringVar(&serverTargetFlag, "target", "", "Target name (defaults to current directory)")
+		c.Flags().StringVar(&serverKeyFlag, "key", "", "Public key file path or literal public key (required)")
+		c.MarkFlagRequired("key")
+	}
+
+	for _, c := range []*cobra.Command{serverAttachCmd, serverDetachCmd, serverLoadBalancerCmd, serverSnapshotCmd, serverRestoreCmd} {
+		c.Flags().StringVar(&serverTargetFlag, "target", "", "Target name (defaults to current directory)")
+	}
+	for _, c := range []*cobra.Command{serverAttachCmd, serverDetachCmd} {
+		c.Flags().StringVar(&serverIPFlag, "ip", "", "Server IP address (required)")
+		c.MarkFlagRequired("ip")
+	}
+	serverAttachCmd.Flags().StringVar(&serverUserFlag, "user", "", "SSH username (defaults to the primary server's)")
+	serverAttachCmd.Flags().StringVar(&serverSSHKeyFlag, "ssh-key", "", "SSH private key path (defaults to the primary server's)")
+	serverAttachCmd.Flags().StringVar(&serverIDFlag, "server-id", "", "Provider server ID, needed to add the server to a load balancer")
+	serverLoadBalancerCmd.Flags().StringVar(&serverRegionFlag, "region", "", "Load balancer region (defaults to the primary server's)")
+	serverLoadBalancerCmd.Flags().BoolVar(&serverDeleteLBFlag, "delete", false, "Delete the target's load balancer")
+	serverSnapshotCmd.Flags().StringVar(&serverSnapshotNameFlag, "name", "", "Snapshot name (defaults to lightfold-<target>-<timestamp>)")
+	serverSnapshotCmd.Flags().BoolVar(&serverSnapshotListFlag, "list", false, "List the server's snapshots instead of taking one")
+	serverRestoreCmd.Flags().StringVar(&serverRestoreIDFlag, "snapshot", "", "Provider snapshot ID to restore (required)")
+	serverRestoreCmd.MarkFlagRequired("snapshot")
+	serverRestoreCmd.Flags().BoolVar(&serverRestoreYesFlag, "yes", false, "Restore without asking (required in non-interactive runs)")
 }
@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/runtime"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverCleanupForceFlag bool
+	serverCleanupYesFlag   bool
+
+	// cleanupNamePattern guards names discovered on the server before they are
+	// used in paths and shell commands
+	cleanupNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// certbotCronEntry is the renewal job lightfold adds when certbot's systemd
+// timer is unavailable
+const certbotCronEntry = "certbot renew --quiet"
+
+// serverCleanupCmd removes what lightfold installed from a server that is kept
+var serverCleanupCmd = &cobra.Command{
+	Use:   "cleanup <server-ip>",
+	Short: "Remove everything lightfold installed from a server you keep",
+	Long: `Strip a server of everything lightfold set up, without destroying it.
+
+The plan is built from the local server state, the targets on the server and
+what is found on disk: app services and their drop-ins, nginx sites, app
+trees under /srv and the targets' deploy.base_dir, logrotate files,
+certificates, runtimes lightfold installed, side-by-side Node.js versions,
+/etc/lightfold markers and lightfold's certbot cron entries.
+The base OS, nginx itself and anything else on the server are left untouched.
+
+Cleanup refuses to run while an app on the server is still active; stop or
+destroy the apps first, or pass --force. The server is removed from the local
+server state afterwards. Without a terminal, or with --json, pass --yes to
+confirm the removal up front.
+
+Examples:
+  lightfold server cleanup 203.0.113.10
+  lightfold server cleanup 203.0.113.10 --yes --no-interactive
+  lightfold server cleanup 203.0.113.10 --user deploy --ssh-key ~/.ssh/id_ed25519`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serverIP := args[0]
+		cfg := loadConfigOrExit()
+
+		serverState, err := state.GetServerState(serverIP)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error loading server state: %v", err)))
+			exit(1)
+		}
+		targets := cfg.GetTargetsByServerIP(serverIP)
+
+		sshExecutor, sshUser, err := serverCleanupExecutor(serverIP, targets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		if err := sshExecutor.Connect(3, 10*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error connecting to %s: %v", serverIP, err)))
+			exit(1)
+		}
+		defer sshExecutor.Disconnect()
+
+		inventory := discoverServerInventory(sshExecutor, registeredAppNames(serverState, targets), cronUsers(sshUser), serverBaseDirs(targets))
+		if len(inventory.Active) > 0 && !serverCleanupForceFlag {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: apps still running on %s: %s", serverIP, strings.Join(inventory.Active, ", "))))
+			fmt.Fprintf(os.Stderr, "\nDestroy or migrate them first, or re-run with --force to remove them anyway\n")
+			exit(1)
+		}
+
+		steps := serverCleanupSteps(sshExecutor, inventory, registeredDomains(serverState), serverState.InstalledRuntimes)
+		if len(steps) == 0 {
+			fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Nothing lightfold-managed found on %s", serverIP)))
+			clearServerState(serverIP)
+			return
+		}
+
+		fmt.Printf("\n%s\n\n", destroyWarningStyle.Render(fmt.Sprintf("%s  This removes the following from %s:", style.Warn(), serverIP)))
+		printDestroyPlan(steps)
+		fmt.Println()
+
+		confirmed, err := confirmServerCleanup(serverIP, serverCleanupYesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		if !confirmed {
+			fmt.Println("Cancelled.")
+			return
+		}
+
+		fmt.Println()
+		report := runDestroyPlan(serverIP, steps, true)
+		fmt.Println()
+		printDestroyChecklist(report)
+		fmt.Println()
+
+		removed, skipped, failed := report.counts()
+		if failed > 0 {
+			fmt.Printf("%s\n", destroyWarningStyle.Render(fmt.Sprintf("%s Cleanup of %s incomplete: %d removed, %d skipped, %d failed", style.Warn(), serverIP, removed, skipped, failed)))
+			fmt.Printf("%s\n", destroyMutedStyle.Render(fmt.Sprintf("Fix the failures above and re-run: lightfold server cleanup %s", serverIP)))
+			exit(1)
+		}
+
+		clearServerState(serverIP)
+		fmt.Printf("%s Cleaned up %s (%d removed)\n", serverSuccessStyle.Render(style.Check()), serverValueStyle.Render(serverIP), removed)
+		if len(targets) > 0 {
+			names := make([]string, 0, len(targets))
+			for name := range targets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Targets still pointing at this server: %s", strings.Join(names, ", "))))
+		}
+	},
+}
+
+// serverInventory is what lightfold left on a server
+type serverInventory struct {
+	Apps   []string // apps from the server state, the config and the base directories
+	Active []string // apps whose service is running
+	Paths  map[string]bool
+	// Dirs are the app directories found under the base directories; apps
+	// missing here live in /srv/<app>
+	Dirs map[string]string
+	// CronUsers have lightfold's certbot renewal job in their crontab
+	CronUsers []string
+	Jobs      map[string][]string // Scheduled job timers per app
+}
+
+// appDir returns where app is deployed on the server
+func (i serverInventory) appDir(app string) string {
+	if dir, ok := i.Dirs[app]; ok {
+		return dir
+	}
+	return config.AppDir("", app)
+}
+
+// appPaths lists the files lightfold writes for app, deployed to appDir
+func appPaths(app, appDir string) []string {
+	return []string{
+		"/etc/systemd/system/" + app + ".service",
+		"/etc/systemd/system/" + app + ".service.d",
+		"/etc/nginx/sites-available/" + app,
+		"/etc/nginx/sites-available/" + app + ".conf",
+		"/etc/nginx/sites-enabled/" + app,
+		"/etc/nginx/sites-enabled/" + app + ".conf",
+		appDir,
+		"/etc/logrotate.d/" + app,
+		"/etc/systemd/system/" + deploy.StopTimerName(app) + ".timer",
+		"/etc/systemd/system/" + deploy.StopTimerName(app) + ".service",
+	}
+}
+
+// sharedPaths are the server-wide files and directories lightfold owns
+var sharedPaths = []string{
+	runtime.NodeVersionsDir,
+	config.RemoteLightfoldDir,
+	"/var/log/lightfold-update.log",
+}
+
+// serverBaseDirs returns the directories apps are deployed under on a server:
+// /srv and every deploy.base_dir of the targets on it
+func serverBaseDirs(targets map[string]config.TargetConfig) []string {
+	dirs := []string{config.RemoteAppBaseDir}
+	for _, target := range targets {
+		dirs = append(dirs, target.RemoteBaseDir())
+	}
+	return uniqueSorted(dirs)
+}
+
+// registeredAppNames returns the apps the server state and config know on the server
+func registeredAppNames(serverState *state.ServerState, targets map[string]config.TargetConfig) []string {
+	names := []string{}
+	for _, app := range serverState.DeployedApps {
+		names = append(names, app.AppName)
+	}
+	for name, target := range targets {
+		names = append(names, utils.RemoteAppName(&target, name))
+	}
+	return names
+}
+
+func registeredDomains(serverState *state.ServerState) []string {
+	domains := []string{}
+	for _, app := range serverState.DeployedApps {
+		if app.Domain != "" && cleanupNamePattern.MatchString(app.Domain) {
+			domains = append(domains, app.Domain)
+		}
+	}
+	return uniqueSorted(domains)
+}
+
+// cronUsers are the users whose crontab may hold the certbot fallback: root,
+// the deploy user and the user lightfold connects as
+func cronUsers(sshUser string) []string {
+	users := []string{"root", config.DefaultDeployUser}
+	if cleanupNamePattern.MatchString(sshUser) {
+		users = append(users, sshUser)
+	}
+	return uniqueSorted(users)
+}
+
+// discoverServerInventory adds the app trees found under baseDirs to apps and
+// finds which of the files lightfold writes exist on the server
+func discoverServerInventory(runner sshpkg.SudoRunner, apps, users, baseDirs []string) serverInventory {
+	inventory := serverInventory{Paths: map[string]bool{}, Jobs: map[string][]string{}, Dirs: map[string]string{}}
+
+	var globs []string
+	for _, dir := range baseDirs {
+		globs = append(globs, dir+"/*/")
+	}
+	found := runner.ExecuteSudo(fmt.Sprintf(`for d in %s; do [ -d "${d}releases" ] && echo "${d%%/}"; done; true`, strings.Join(globs, " ")))
+	for _, line := range strings.Split(found.Stdout, "\n") {
+		dir := strings.TrimSpace(line)
+		if dir == "" {
+			continue
+		}
+		app := path.Base(dir)
+		apps = append(apps, app)
+		if _, ok := inventory.Dirs[app]; !ok {
+			inventory.Dirs[app] = dir
+		}
+	}
+
+	timers := runner.ExecuteSudo(fmt.Sprintf("grep -H '^%s=' /etc/systemd/system/*.timer 2>/dev/null; true", deploy.JobAppKey))
+	for _, line := range strings.Split(timers.Stdout, "\n") {
+		unitPath, marker, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		app := strings.TrimPrefix(marker, deploy.JobAppKey+"=")
+		apps = append(apps, app)
+		inventory.Jobs[app] = append(inventory.Jobs[app], strings.TrimSuffix(path.Base(unitPath), ".timer"))
+	}
+
+	for _, app := range uniqueSorted(apps) {
+		if cleanupNamePattern.MatchString(app) {
+			inventory.Apps = append(inventory.Apps, app)
+		}
+	}
+
+	candidates := append([]string{}, sharedPaths...)
+	for _, app := range inventory.Apps {
+		candidates = append(candidates, appPaths(app, inventory.appDir(app))...)
+	}
+	existing := runner.ExecuteSudo(fmt.Sprintf(`for f in %s; do [ -e "$f" ] && echo "$f"; done; true`, strings.Join(candidates, " ")))
+	for _, path := range strings.Fields(existing.Stdout) {
+		inventory.Paths[path] = true
+	}
+
+	if len(inventory.Apps) > 0 {
+		result := runner.ExecuteSudo("systemctl is-active " + strings.Join(inventory.Apps, " "))
+		for i, status := range strings.Fields(result.Stdout) {
+			if i < len(inventory.Apps) && (status == "active" || status == "activating" || status == "reloading") {
+				inventory.Active = append(inventory.Active, inventory.Apps[i])
+			}
+		}
+	}
+
+	for _, user := range users {
+		if runner.ExecuteSudo(fmt.Sprintf("crontab -u %s -l 2>/dev/null | grep -qF '%s'", user, certbotCronEntry)).ExitCode == 0 {
+			inventory.CronUsers = append(inventory.CronUsers, user)
+		}
+	}
+	return inventory
+}
+
+// serverCleanupSteps turns the inventory into the cleanup plan. Only what
+// exists on the server is planned, except runtimes and certificates, whose
+// removal is a no-op when they are already gone.
+func serverCleanupSteps(runner sshpkg.SudoRunner, inventory serverInventory, domains []string, runtimes []state.Runtime) []*destroyStep {
+	var steps []*destroyStep
+	existing := func(paths ...string) []string {
+		var out []string
+		for _, path := range paths {
+			if inventory.Paths[path] {
+				out = append(out, path)
+			}
+		}
+		return out
+	}
+	remove := func(command string) func() (string, error) {
+		return func() (string, error) {
+			result := runner.ExecuteSudo(command)
+			if result.Error != nil {
+				return "", result.Error
+			}
+			if result.ExitCode != 0 {
+				return "", fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+			}
+			return "", nil
+		}
+	}
+
+	for _, app := range inventory.Apps {
+		paths := appPaths(app, inventory.appDir(app))
+		if units := existing(paths[0], paths[1]); len(units) > 0 {
+			steps = append(steps, plannedStep("service", fmt.Sprintf("Service %s", app),
+				remove(fmt.Sprintf("systemctl disable --now %s 2>/dev/null; rm -rf %s && systemctl daemon-reload", app, strings.Join(units, " ")))))
+		}
+		if sites := existing(paths[2:6]...); len(sites) > 0 {
+			steps = append(steps, plannedStep("nginx", fmt.Sprintf("Nginx site %s", app),
+				remove(fmt.Sprintf("rm -f %s && (! command -v nginx >/dev/null || (nginx -t && systemctl reload nginx))", strings.Join(sites, " ")))))
+		}
+		if dirs := existing(paths[6]); len(dirs) > 0 {
+			steps = append(steps, plannedStep("app_dir", fmt.Sprintf("App directory %s", dirs[0]), remove("rm -rf "+dirs[0])))
+		}
+		if files := existing(paths[7]); len(files) > 0 {
+			steps = append(steps, plannedStep("logrotate", fmt.Sprintf("Logrotate config %s", files[0]), remove("rm -f "+files[0])))
+		}
+		if units := existing(paths[8:10]...); len(units) > 0 {
+			steps = append(steps, plannedStep("schedule", fmt.Sprintf("Stop schedule timer of %s", app),
+				remove(fmt.Sprintf("systemctl disable --now %s.timer 2>/dev/null; rm -f %s && systemctl daemon-reload", deploy.StopTimerName(app), strings.Join(units, " ")))))
+		}
+		if jobs := inventory.Jobs[app]; len(jobs) > 0 {
+			steps = append(steps, plannedStep("jobs", fmt.Sprintf("Scheduled jobs %s", strings.Join(jobs, ", ")),
+				remove(deploy.RemoveJobsCommand(app))))
+		}
+	}
+
+	for _, domain := range domains {
+		steps = append(steps, plannedStep("certificate", fmt.Sprintf("SSL certificate %s", domain),
+			remove(fmt.Sprintf("! command -v certbot >/dev/null || certbot delete --cert-name %s --non-interactive 2>/dev/null || true", domain))))
+	}
+
+	for _, rt := range runtimes {
+		rt := runtime.Runtime(rt)
+		steps = append(steps, plannedStep("runtime", fmt.Sprintf("Runtime %s", rt), func() (string, error) {
+			return "", runtime.RemoveRuntime(runner, rt)
+		}))
+	}
+
+	for _, user := range inventory.CronUsers {
+		steps = append(steps, plannedStep("cron", fmt.Sprintf("Certbot renewal job in %s's crontab", user),
+			remove(fmt.Sprintf("crontab -u %[1]s -l | grep -vF '%[2]s' | crontab -u %[1]s -", user, certbotCronEntry))))
+	}
+
+	for _, path := range existing(sharedPaths...) {
+		steps = append(steps, plannedStep("shared", path, remove("rm -rf "+path)))
+	}
+	return steps
+}
+
+// serverCleanupExecutor connects with the flags when given, otherwise as the
+// first target on the server does
+func serverCleanupExecutor(serverIP string, targets map[string]config.TargetConfig) (*sshpkg.Executor, string, error) {
+	if serverUserFlag != "" && serverSSHKeyFlag != "" {
+		return sshpkg.NewExecutor(serverIP, "22", serverUserFlag, serverSSHKeyFlag), serverUserFlag, nil
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := targets[name]
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil || target.Provider == "flyio" {
+			continue
+		}
+		return sshpkg.NewExecutorFromConfig(providerCfg), providerCfg.GetUsername(), nil
+	}
+	return nil, "", fmt.Errorf("no target uses %s; pass --user and --ssh-key to connect", serverIP)
+}
+
+// confirmServerCleanup asks before anything is removed. Non-interactive runs
+// fail unless --yes confirmed the cleanup up front.
+func confirmServerCleanup(serverIP string, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return false, fmt.Errorf("cleanup removes lightfold's files from %s; pass --yes to confirm without a prompt", serverIP)
+	}
+
+	fmt.Printf("Remove these from %s? (y/N): ", serverIP)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y", nil
+}
+
+func clearServerState(serverIP string) {
+	if !state.ServerStateExists(serverIP) {
+		return
+	}
+	if err := state.DeleteServerState(serverIP); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Warning: failed to clear server state: %v", err)))
+	}
+}
+
+func uniqueSorted(values []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func init() {
+	serverCmd.AddCommand(serverCleanupCmd)
+	serverCleanupCmd.Flags().BoolVar(&serverCleanupForceFlag, "force", false, "Remove apps that are still running")
+	serverCleanupCmd.Flags().BoolVar(&serverCleanupYesFlag, "yes", false, "Clean up without asking (required in non-interactive runs)")
+	serverCleanupCmd.Flags().StringVar(&serverUserFlag, "user", "", "SSH username (defaults to a target on the server)")
+	serverCleanupCmd.Flags().StringVar(&serverSSHKeyFlag, "ssh-key", "", "SSH private key path (defaults to a target on the server)")
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"lightfold/pkg/state"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverServerInventory(t *testing.T) {
+	server := fakeDomainServer{
+		"for d in":             {Stdout: "/srv/blog\n/opt/apps/web\n/srv/bad name\n"},
+		"for f in":             {Stdout: "/opt/lightfold/node\n/etc/systemd/system/web.service\n/etc/nginx/sites-available/web.conf\n/opt/apps/web\n/srv/blog\n"},
+		"systemctl is-active":  {Stdout: "inactive\ninactive\nactive\n", ExitCode: 3},
+		"grep -H":              {Stdout: "/etc/systemd/system/web-cleanup.timer:X-Lightfold-App=web\n/etc/systemd/system/old-report.timer:X-Lightfold-App=old\n"},
+		"crontab -u deploy -l": {},
+		"crontab -u root -l":   {ExitCode: 1},
+	}
+
+	inventory := discoverServerInventory(server, []string{"web"}, []string{"deploy", "root"}, []string{"/opt/apps", "/srv"})
+
+	if want := []string{"blog", "old", "web"}; !reflect.DeepEqual(inventory.Apps, want) {
+		t.Errorf("Apps = %v, want %v", inventory.Apps, want)
+	}
+	if want := []string{"web"}; !reflect.DeepEqual(inventory.Active, want) {
+		t.Errorf("Active = %v, want %v", inventory.Active, want)
+	}
+	if want := []string{"deploy"}; !reflect.DeepEqual(inventory.CronUsers, want) {
+		t.Errorf("CronUsers = %v, want %v", inventory.CronUsers, want)
+	}
+	if want := map[string][]string{"web": {"web-cleanup"}, "old": {"old-report"}}; !reflect.DeepEqual(inventory.Jobs, want) {
+		t.Errorf("Jobs = %v, want %v", inventory.Jobs, want)
+	}
+	if !inventory.Paths["/opt/apps/web"] || inventory.Paths["/etc/lightfold"] {
+		t.Errorf("Paths = %v", inventory.Paths)
+	}
+	if inventory.appDir("web") != "/opt/apps/web" || inventory.appDir("old") != "/srv/old" {
+		t.Errorf("app directories = %v", inventory.Dirs)
+	}
+}
+
+func TestServerCleanupSteps(t *testing.T) {
+	inventory := serverInventory{
+		Apps: []string{"blog", "web"},
+		Paths: map[string]bool{
+			"/etc/systemd/system/web.service":     true,
+			"/etc/nginx/sites-available/web.conf": true,
+			"/etc/nginx/sites-enabled/web.conf":   true,
+			"/srv/web":                            true,
+			"/srv/blog":                           true,
+			"/etc/lightfold":                      true,
+		},
+		CronUsers: []string{"deploy"},
+		Jobs:      map[string][]string{"web": {"web-cleanup"}},
+	}
+
+	steps := serverCleanupSteps(fakeDomainServer{}, inventory, []string{"web.example.com"}, []state.Runtime{"nodejs"})
+
+	var got []string
+	for _, step := range steps {
+		got = append(got, step.Description)
+	}
+	want := []string{
+		"App directory /srv/blog",
+		"Service web",
+		"Nginx site web",
+		"App directory /srv/web",
+		"Scheduled jobs web-cleanup",
+		"SSL certificate web.example.com",
+		"Runtime nodejs",
+		"Certbot renewal job in deploy's crontab",
+		"/etc/lightfold",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+}
+
+func TestServerCleanupStepFailure(t *testing.T) {
+	server := fakeDomainServer{"rm -rf /srv/web": {ExitCode: 1, Stderr: "rm: cannot remove '/srv/web': Device or resource busy\n"}}
+	inventory := serverInventory{Apps: []string{"web"}, Paths: map[string]bool{"/srv/web": true}}
+
+	report := runDestroyPlan("203.0.113.10", serverCleanupSteps(server, inventory, nil, nil), true)
+
+	if _, _, failed := report.counts(); failed != 1 || report.Steps[0].Detail != "rm: cannot remove '/srv/web': Device or resource busy" {
+		t.Errorf("steps = %+v, want the removal to fail with stderr", report.Steps[0])
+	}
+}
+
+func TestConfirmServerCleanup_NonInteractiveNeedsYes(t *testing.T) {
+	skipInteractive = true
+	defer func() { skipInteractive = false }()
+
+	if confirmed, err := confirmServerCleanup("203.0.113.10", false); confirmed || err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("confirmServerCleanup() without --yes = %v, %v; want an error naming --yes", confirmed, err)
+	}
+	if confirmed, err := confirmServerCleanup("203.0.113.10", true); !confirmed || err != nil {
+		t.Errorf("confirmServerCleanup() with --yes = %v, %v; want confirmed", confirmed, err)
+	}
+}
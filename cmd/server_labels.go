@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/state"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverLabelFlags   []string
+	serverUnlabelFlags []string
+	serverListLabels   []string
+)
+
+var serverRetagCmd = &cobra.Command{
+	Use:   "retag",
+	Short: "Apply the target's labels to its existing servers",
+	Long: `Update the target's labels and apply them to its servers through the
+provider API: DigitalOcean, Vultr and Linode tags (key:value), Hetzner labels
+and AWS instance tags. Labels removed from the target are removed from the
+servers; tags and labels set outside lightfold are kept.
+
+Without --label or --unset the labels already in the config are reapplied,
+e.g. after editing lightfold.yml. fly.io labels are machine metadata, applied
+on the next deploy. BYOS servers keep their labels in the local state only.
+
+Examples:
+  lightfold server retag --target myapp --label team=payments --label env=prod
+  lightfold server retag --target myapp --unset env
+  lightfold server retag --target myapp`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		set, err := config.ParseLabels(serverLabelFlags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		labels := updateLabels(target.Labels, set, serverUnlabelFlags)
+		if err := config.ValidateLabels(labels); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		target.Labels = labels
+		if err := cfg.SetTarget(targetName, target); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		if err := cfg.SaveConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: failed to save config: %v", err)))
+			exit(1)
+		}
+
+		if err := retagTarget(target, targetName); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+	},
+}
+
+// updateLabels returns labels with set applied and the keys in unset removed,
+// or nil when no label is left
+func updateLabels(labels, set map[string]string, unset []string) map[string]string {
+	updated := make(map[string]string, len(labels)+len(set))
+	for key, value := range labels {
+		updated[key] = value
+	}
+	for key, value := range set {
+		updated[key] = value
+	}
+	for _, key := range unset {
+		delete(updated, strings.TrimSpace(key))
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return updated
+}
+
+// retagTarget applies the target's labels to each of its servers and records
+// them in the server state
+func retagTarget(target config.TargetConfig, targetName string) error {
+	labelText := formatLabels(target.Labels)
+	if labelText == "" {
+		labelText = "no labels"
+	}
+
+	switch target.Provider {
+	case "flyio":
+		fmt.Printf("%s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render(fmt.Sprintf("Saved %s; fly.io applies them as machine metadata on the next deploy", labelText)))
+		return nil
+	case "byos":
+		servers, err := target.ServerTargets()
+		if err != nil {
+			return err
+		}
+		for _, serverTarget := range servers {
+			providerCfg, err := serverTarget.GetSSHProviderConfig()
+			if err != nil {
+				return err
+			}
+			if err := state.SetServerLabels(providerCfg.GetIP(), target.Labels); err != nil {
+				return fmt.Errorf("failed to record labels for %s: %w", providerCfg.GetIP(), err)
+			}
+		}
+		fmt.Printf("%s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render(fmt.Sprintf("Recorded %s locally (BYOS servers have no provider labels)", labelText)))
+		return nil
+	}
+
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return fmt.Errorf("failed to load tokens: %w", err)
+	}
+	token := tokens.GetToken(target.Provider)
+	if token == "" {
+		return fmt.Errorf("no API token for %s; run 'lightfold config set-token %s'", target.Provider, target.Provider)
+	}
+	provider, err := providers.GetProvider(target.Provider, token)
+	if err != nil {
+		return err
+	}
+	labeler, ok := provider.(providers.Labeler)
+	if !ok {
+		return fmt.Errorf("%s does not support labels", provider.DisplayName())
+	}
+
+	servers, err := targetServers(target, targetName)
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		var previous map[string]string
+		if serverState, err := state.GetServerState(server.ip); err == nil {
+			previous = serverState.Labels
+		}
+		if err := labeler.SetLabels(context.Background(), server.serverID, target.Labels, previous); err != nil {
+			return fmt.Errorf("failed to label %s: %w", server.ip, err)
+		}
+		if err := state.SetServerLabels(server.ip, target.Labels); err != nil {
+			return fmt.Errorf("failed to record labels for %s: %w", server.ip, err)
+		}
+		fmt.Printf("%s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render(fmt.Sprintf("Labeled %s: %s", server.ip, labelText)))
+	}
+	return nil
+}
+
+// formatLabels renders labels as sorted key=value pairs
+func formatLabels(labels map[string]string) string {
+	keys := providers.LabelKeys(labels)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// filterServersByLabels returns the servers whose recorded labels carry every
+// label of selector
+func filterServersByLabels(serverIPs []string, selector map[string]string) []string {
+	if len(selector) == 0 {
+		return serverIPs
+	}
+	matched := []string{}
+	for _, serverIP := range serverIPs {
+		serverState, err := state.GetServerState(serverIP)
+		if err == nil && config.MatchLabels(serverState.Labels, selector) {
+			matched = append(matched, serverIP)
+		}
+	}
+	return matched
+}
+
+func init() {
+	serverCmd.AddCommand(serverRetagCmd)
+	serverRetagCmd.Flags().StringVar(&serverTargetFlag, "target", "", "Target name (defaults to current directory)")
+	serverRetagCmd.Flags().StringArrayVar(&serverLabelFlags, "label", nil, "Label to set as key=value (repeatable)")
+	serverRetagCmd.Flags().StringArrayVar(&serverUnlabelFlags, "unset", nil, "Label key to remove (repeatable)")
+
+	serverListCmd.Flags().StringArrayVar(&serverListLabels, "label", nil, "Only list servers with this key=value label (repeatable)")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"lightfold/pkg/state"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateLabels(t *testing.T) {
+	current := map[string]string{"team": "payments", "env": "staging"}
+	got := updateLabels(current, map[string]string{"env": "prod", "owner": "ops"}, []string{"team"})
+	if want := map[string]string{"env": "prod", "owner": "ops"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("updateLabels() = %v, want %v", got, want)
+	}
+	if current["env"] != "staging" {
+		t.Error("updateLabels() modified the current labels")
+	}
+	if got := updateLabels(current, nil, []string{"team", "env"}); got != nil {
+		t.Errorf("updateLabels() removing every label = %v, want nil", got)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	if got := formatLabels(map[string]string{"team": "payments", "env": "prod"}); got != "env=prod, team=payments" {
+		t.Errorf("formatLabels() = %q", got)
+	}
+	if got := formatLabels(nil); got != "" {
+		t.Errorf("formatLabels(nil) = %q, want empty", got)
+	}
+}
+
+func TestFilterServersByLabels(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := state.SetServerLabels("192.0.2.1", map[string]string{"team": "payments", "env": "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.SetServerLabels("192.0.2.2", map[string]string{"team": "search"}); err != nil {
+		t.Fatal(err)
+	}
+	servers := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+
+	if got := filterServersByLabels(servers, map[string]string{"team": "payments"}); !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+		t.Errorf("filterServersByLabels(team=payments) = %v", got)
+	}
+	if got := filterServersByLabels(servers, map[string]string{"env": "staging"}); len(got) != 0 {
+		t.Errorf("filterServersByLabels(env=staging) = %v, want none", got)
+	}
+	if got := filterServersByLabels(servers, nil); !reflect.DeepEqual(got, servers) {
+		t.Errorf("filterServersByLabels() without a selector = %v, want every server", got)
+	}
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/providers"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverIPFlag       string
+	serverUserFlag     string
+	serverSSHKeyFlag   string
+	serverIDFlag       string
+	serverRegionFlag   string
+	serverDeleteLBFlag bool
+)
+
+// serverAttachCmd adds an extra server to a target so pushes fan out to it
+var serverAttachCmd = &cobra.Command{
+	Use:   "attach",
+	Short: "Add another server to a target",
+	Long: `Add an existing server to a target. Pushes deploy to every server of the target
+in turn; each server only switches to the new release after its own health check
+passes, and a failure rolls back the servers that already switched.
+
+The server is configured (runtime, nginx, service) right away. The SSH user and
+key default to the target's primary server.
+
+Examples:
+  lightfold server attach --target myapp --ip 203.0.113.20
+  lightfold server attach --target myapp --ip 203.0.113.20 --server-id 412345678`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		primaryCfg, err := target.GetSSHProviderConfig()
+		if err != nil || target.Provider == "flyio" {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: target '%s' does not deploy over SSH", targetName)))
+			exit(1)
+		}
+
+		if serverIPFlag == primaryCfg.GetIP() || findAttachedServer(target, serverIPFlag) != -1 {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %s is already a server of '%s'", serverIPFlag, targetName)))
+			exit(1)
+		}
+
+		server := config.ServerConfig{
+			ServerID: serverIDFlag,
+			IP:       serverIPFlag,
+			Username: serverUserFlag,
+			SSHKey:   serverSSHKeyFlag,
+		}
+		if server.Username == "" {
+			server.Username = primaryCfg.GetUsername()
+		}
+		if server.SSHKey == "" {
+			server.SSHKey = primaryCfg.GetSSHKey()
+		}
+
+		sshExecutor := sshpkg.NewExecutor(server.IP, "22", server.Username, server.SSHKey)
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error connecting to %s: %v", server.IP, err)))
+			exit(1)
+		}
+		sshExecutor.Disconnect()
+
+		serverTarget, err := target.ForServer(server)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		fmt.Printf("%s %s\n", serverMutedStyle.Render(style.Arrow()), serverMutedStyle.Render(fmt.Sprintf("Configuring %s...", server.IP)))
+		if err := configureTarget(serverTarget, targetName, false); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error configuring %s: %v", server.IP, err)))
+			exit(1)
+		}
+
+		target.Servers = append(target.Servers, server)
+		saveTargetOrExit(cfg, target, targetName)
+
+		fmt.Printf("%s Attached %s to '%s' (%d servers)\n",
+			serverSuccessStyle.Render(style.Check()), serverValueStyle.Render(server.IP), targetName, len(target.Servers)+1)
+		fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Deploy to it with: lightfold push --target %s", targetName)))
+	},
+}
+
+// serverDetachCmd removes an extra server from a target
+var serverDetachCmd = &cobra.Command{
+	Use:   "detach",
+	Short: "Remove an extra server from a target",
+	Long: `Stop deploying a target to one of its extra servers. The server itself and the
+app on it are left untouched. The primary server cannot be detached.
+
+Examples:
+  lightfold server detach --target myapp --ip 203.0.113.20`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		index := findAttachedServer(target, serverIPFlag)
+		if index == -1 {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %s is not an extra server of '%s'", serverIPFlag, targetName)))
+			exit(1)
+		}
+
+		target.Servers = append(target.Servers[:index], target.Servers[index+1:]...)
+		saveTargetOrExit(cfg, target, targetName)
+
+		fmt.Printf("%s Detached %s from '%s'\n", serverSuccessStyle.Render(style.Check()), serverValueStyle.Render(serverIPFlag), targetName)
+		if target.LoadBalancer != nil {
+			fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Update the load balancer with: lightfold server load-balancer --target %s", targetName)))
+		}
+	},
+}
+
+// serverLoadBalancerCmd creates or updates the provider load balancer in front of a target's servers
+var serverLoadBalancerCmd = &cobra.Command{
+	Use:   "load-balancer",
+	Short: "Put a target's servers behind a provider load balancer",
+	Long: `Create a provider load balancer for a multi-server target, or update an existing
+one to match the target's current servers. Traffic on port 80 is forwarded to
+nginx on each server, and the load balancer health check uses the app's
+healthcheck path.
+
+Every server needs a provider server ID: the primary gets one when lightfold
+provisions it; pass --server-id when attaching extra servers.
+
+Currently supported on DigitalOcean.
+
+Examples:
+  lightfold server load-balancer --target myapp
+  lightfold server load-balancer --target myapp --delete`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		tokens, err := config.LoadTokens()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error loading tokens: %v", err)))
+			exit(1)
+		}
+		token := tokens.GetToken(target.Provider)
+		if token == "" {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: no API token for %s; run 'lightfold config set-token %s'", target.Provider, target.Provider)))
+			exit(1)
+		}
+
+		provider, err := providers.GetProvider(target.Provider, token)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		lbProvider, ok := provider.(providers.LoadBalancerProvider)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: load balancers are not supported for %s", provider.DisplayName())))
+			exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		if serverDeleteLBFlag {
+			if target.LoadBalancer == nil {
+				fmt.Printf("%s\n", serverMutedStyle.Render("No load balancer configured"))
+				return
+			}
+			if err := lbProvider.DeleteLoadBalancer(ctx, target.LoadBalancer.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			target.LoadBalancer = nil
+			saveTargetOrExit(cfg, target, targetName)
+			fmt.Printf("%s Deleted load balancer for '%s'\n", serverSuccessStyle.Render(style.Check()), targetName)
+			return
+		}
+
+		lbConfig, err := loadBalancerConfigForTarget(target, targetName, serverRegionFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		lb, err := lbProvider.EnsureLoadBalancer(ctx, lbConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		target.LoadBalancer = &config.LoadBalancerConfig{
+			ID:     lb.ID,
+			Name:   lb.Name,
+			IP:     lb.IP,
+			Region: lbConfig.Region,
+		}
+		saveTargetOrExit(cfg, target, targetName)
+
+		ip := lb.IP
+		if ip == "" {
+			ip = "pending (check again with lightfold status)"
+		}
+		fmt.Printf("%s Load balancer %s forwards to %d servers\n",
+			serverSuccessStyle.Render(style.Check()), serverValueStyle.Render(lb.Name), len(lbConfig.ServerIDs))
+		fmt.Printf("  IP: %s\n", serverValueStyle.Render(ip))
+	},
+}
+
+// loadBalancerConfigForTarget builds the load balancer request for all of a target's servers
+func loadBalancerConfigForTarget(target config.TargetConfig, targetName, region string) (providers.LoadBalancerConfig, error) {
+	primaryCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		return providers.LoadBalancerConfig{}, err
+	}
+
+	var serverIDs []string
+	if primaryCfg.GetServerID() == "" {
+		return providers.LoadBalancerConfig{}, fmt.Errorf("primary server %s has no provider server ID", primaryCfg.GetIP())
+	}
+	serverIDs = append(serverIDs, primaryCfg.GetServerID())
+	for _, server := range target.Servers {
+		if server.ServerID == "" {
+			return providers.LoadBalancerConfig{}, fmt.Errorf("server %s has no provider server ID; re-attach it with --server-id", server.IP)
+		}
+		serverIDs = append(serverIDs, server.ServerID)
+	}
+
+	if region == "" {
+		var providerFields struct {
+			Region string `json:"region"`
+		}
+		json.Unmarshal(target.ProviderConfig[target.Provider], &providerFields)
+		region = providerFields.Region
+	}
+	if region == "" {
+		return providers.LoadBalancerConfig{}, fmt.Errorf("could not determine the region; pass --region")
+	}
+
+	healthPath := "/"
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	if path, ok := detection.Healthcheck["path"].(string); ok && path != "" {
+		healthPath = path
+	}
+
+	lbConfig := providers.LoadBalancerConfig{
+		Name:            targetName + "-lb",
+		Region:          region,
+		ServerIDs:       serverIDs,
+		HealthCheckPath: healthPath,
+	}
+	if target.LoadBalancer != nil {
+		lbConfig.ID = target.LoadBalancer.ID
+		if target.LoadBalancer.Name != "" {
+			lbConfig.Name = target.LoadBalancer.Name
+		}
+	}
+	return lbConfig, nil
+}
+
+func findAttachedServer(target config.TargetConfig, ip string) int {
+	for i, server := range target.Servers {
+		if server.IP == ip {
+			return i
+		}
+	}
+	return -1
+}
+
+func saveTargetOrExit(cfg *config.Config, target config.TargetConfig, targetName string) {
+	if err := cfg.SetTarget(targetName, target); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error updating target config: %v", err)))
+		exit(1)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error saving config: %v", err)))
+		exit(1)
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var serverReclaimAllFlag bool
+
+// serverReclaimCmd frees disk space on the target's server
+var serverReclaimCmd = &cobra.Command{
+	Use:   "reclaim",
+	Short: "Free disk space on the target's server",
+	Long: `Break down what fills the target's server disk and run the cleanups you pick:
+pruning each app's releases beyond its target's keep_releases, vacuuming the systemd journal to
+` + journalVacuumSize + `, apt-get clean and docker system prune. Shared static and media
+files are app data and are never removed.
+
+Without a terminal, pass --all to run every suggested cleanup.
+
+Examples:
+  lightfold server reclaim --target myapp
+  lightfold server reclaim --target myapp --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+		if !target.RequiresSSHDeployment() {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %s targets have no server to clean", target.Provider)))
+			exit(1)
+		}
+		exitIfPaused(targetName)
+
+		providerCfg, err := target.GetSSHProviderConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error connecting to %s: %v", providerCfg.GetIP(), err)))
+			exit(1)
+		}
+		defer sshExecutor.Disconnect()
+
+		serverTargets := cfg.GetTargetsByServerIP(providerCfg.GetIP())
+		keepReleases := releaseKeepFor(cfg, serverTargets)
+		before, err := collectDiskBreakdown(sshExecutor, keepReleases, serverBaseDirs(serverTargets))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		fmt.Printf("%s %s\n", serverHeaderStyle.Render("Disk on"), serverValueStyle.Render(providerCfg.GetIP()))
+		fmt.Printf("  %s\n", serverMutedStyle.Render(fmt.Sprintf("%s of %s used", formatMemory(before.UsedBytes), formatMemory(before.SizeBytes))))
+
+		items := before.Reclaimable()
+		if len(items) == 0 {
+			fmt.Printf("\n%s\n", serverMutedStyle.Render("Nothing to reclaim"))
+			return
+		}
+
+		fmt.Println()
+		for i, item := range items {
+			fmt.Printf("  %d. %-28s %10s  %s\n", i+1, item.Label(), formatMemory(item.Bytes), serverMutedStyle.Render(style.Arrow()+" "+item.Reclaim))
+		}
+
+		selected := items
+		if !serverReclaimAllFlag {
+			if jsonOutput || skipInteractive || !isTerminal() {
+				fmt.Fprintf(os.Stderr, "\n%s\n", serverErrorStyle.Render("Error: pass --all to run the cleanups without a terminal"))
+				exit(1)
+			}
+			fmt.Printf("\nReclaim which? (e.g. 1,3 or all; Enter to cancel): ")
+			response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			picks, err := parseReclaimSelection(response, len(items))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			if len(picks) == 0 {
+				fmt.Println("Cancelled")
+				return
+			}
+			selected = nil
+			for _, pick := range picks {
+				selected = append(selected, items[pick])
+			}
+		}
+
+		fmt.Println()
+		failed := 0
+		for _, item := range selected {
+			if err := reclaimDiskItem(sshExecutor, item, keepReleases); err != nil {
+				fmt.Printf("%s %s\n", serverErrorStyle.Render(style.Cross()), serverErrorStyle.Render(fmt.Sprintf("%s: %v", item.Label(), err)))
+				failed++
+				continue
+			}
+			fmt.Printf("%s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render(fmt.Sprintf("%s: %s", item.Label(), item.Reclaim)))
+		}
+
+		if after, err := collectDiskBreakdown(sshExecutor, keepReleases, serverBaseDirs(serverTargets)); err == nil {
+			freed := max(before.UsedBytes-after.UsedBytes, 0)
+			fmt.Printf("\n%s %s\n", serverSuccessStyle.Render("Freed"), serverValueStyle.Render(formatMemory(freed)))
+			fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("%s of %s used", formatMemory(after.UsedBytes), formatMemory(after.SizeBytes))))
+		}
+		if failed > 0 {
+			exit(1)
+		}
+	},
+}
+
+// parseReclaimSelection reads the cleanups picked at the prompt, e.g. "1,3",
+// "2 3" or "all", as indexes into n items. Empty input picks nothing.
+func parseReclaimSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if strings.EqualFold(input, "all") {
+		picks := make([]int, n)
+		for i := range picks {
+			picks[i] = i
+		}
+		return picks, nil
+	}
+
+	var picks []int
+	seen := map[int]bool{}
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 1 || number > n {
+			return nil, fmt.Errorf("%q is not a cleanup between 1 and %d", field, n)
+		}
+		if !seen[number] {
+			seen[number] = true
+			picks = append(picks, number-1)
+		}
+	}
+	return picks, nil
+}
+
+// reclaimDiskItem runs the cleanup suggested for item. Releases are pruned
+// the way a deploy of the app's target prunes them, to its keep count and
+// never below the current and previous release.
+func reclaimDiskItem(server *sshpkg.Executor, item DiskUsageItem, keepReleases releaseKeep) error {
+	switch item.Kind {
+	case "releases":
+		executor := deploy.NewExecutor(server, item.App, "", nil)
+		executor.SetBaseDir(item.BaseDir)
+		return executor.CleanupOldReleases(keepReleases(item.App))
+	case "journal", "apt_cache", "docker":
+		return runReclaimCommand(server, item.Reclaim)
+	}
+	return fmt.Errorf("no cleanup for %s", item.Kind)
+}
+
+func runReclaimCommand(server sshpkg.SudoRunner, command string) error {
+	result := server.ExecuteSudo(command)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s failed: %s", command, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+func init() {
+	serverCmd.AddCommand(serverReclaimCmd)
+	serverReclaimCmd.Flags().StringVar(&serverTargetFlag, "target", "", "Target name (defaults to current directory)")
+	serverReclaimCmd.Flags().BoolVar(&serverReclaimAllFlag, "all", false, "Run every suggested cleanup without asking")
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serverSnapshotNameFlag string
+	serverSnapshotListFlag bool
+	serverRestoreIDFlag    string
+	serverRestoreYesFlag   bool
+)
+
+var serverSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot the target's server through the provider API",
+	Long: `Take a provider snapshot of the target's primary server and wait for it to
+finish. The snapshot ID is recorded in the server state so it can be restored
+with 'lightfold server restore'.
+
+Supported on DigitalOcean, Hetzner Cloud and Vultr. Providers bill snapshot
+storage separately.
+
+Examples:
+  lightfold server snapshot --target myapp
+  lightfold server snapshot --target myapp --name before-upgrade
+  lightfold server snapshot --target myapp --list`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		snapshots, server, err := targetSnapshotManager(target, targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		if serverSnapshotListFlag {
+			listed, err := snapshots.ListSnapshots(context.Background(), server.serverID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+				exit(1)
+			}
+			printSnapshots(listed)
+			return
+		}
+
+		snapshot, err := takeSnapshot(snapshots, target, targetName, server, serverSnapshotNameFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Restore with 'lightfold server restore --target %s --snapshot %s'", targetName, snapshot.ID)))
+	},
+}
+
+var serverRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Rebuild the target's server from a provider snapshot",
+	Long: `Rebuild the target's primary server from a provider snapshot, wait for SSH to
+come back and sync the local state with the restored server. The server keeps
+its provider ID; anything written to its disk after the snapshot is lost.
+
+List snapshots with 'lightfold server snapshot --list'.
+
+Examples:
+  lightfold server restore --target myapp --snapshot 123456789`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+		target, targetName := resolveTarget(cfg, serverTargetFlag, "")
+
+		snapshots, server, err := targetSnapshotManager(target, targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		confirmed, err := confirmRestore(server.ip, serverRestoreIDFlag, serverRestoreYesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		if !confirmed {
+			fmt.Println("Restore cancelled.")
+			return
+		}
+
+		ctx := context.Background()
+		fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Rebuilding %s from snapshot %s...", server.ip, serverRestoreIDFlag)))
+		if err := snapshots.RestoreSnapshot(ctx, server.serverID, serverRestoreIDFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		provider := snapshots.(providers.Provider)
+		active, err := provider.WaitForActive(ctx, server.serverID, 10*time.Minute)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %s did not come back: %v", server.ip, err)))
+			exit(1)
+		}
+		fmt.Printf("%s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render(fmt.Sprintf("Restored %s from snapshot %s", server.ip, serverRestoreIDFlag)))
+
+		if active.PrimaryIP() != "" && active.PrimaryIP() != server.ip {
+			if err := recordResumedIP(&target, targetName, server, active.PrimaryIP()); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: failed to save the new IP of %s: %v", server.ip, err)))
+				exit(1)
+			}
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), serverMutedStyle.Render(fmt.Sprintf("IP changed: %s %s %s", server.ip, style.Arrow(), active.PrimaryIP())))
+		}
+
+		servers, err := targetServers(target, targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", serverErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+		detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+		appName := utils.RemoteAppName(&target, targetName)
+		if err := startServerApp(servers[0], appName, &detection); err != nil {
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), serverMutedStyle.Render(fmt.Sprintf("App is not running after the restore: %v", err)))
+		}
+
+		fmt.Println()
+		if _, err := syncTarget(target, targetName, cfg, false); err != nil {
+			fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), serverMutedStyle.Render(fmt.Sprintf("Sync after restore failed: %v", err)))
+			fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Run 'lightfold sync --target %s' once the server is reachable", targetName)))
+			return
+		}
+		fmt.Printf("\n%s %s\n", serverSuccessStyle.Render(style.Check()+" Restored target"), serverValueStyle.Render(targetName))
+	},
+}
+
+// targetSnapshotManager returns the provider that snapshots the target's
+// servers and the target's primary server
+func targetSnapshotManager(target config.TargetConfig, targetName string) (providers.SnapshotManager, powerServer, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, powerServer{}, fmt.Errorf("failed to load tokens: %w", err)
+	}
+	token := tokens.GetToken(target.Provider)
+	if token == "" {
+		return nil, powerServer{}, fmt.Errorf("no API token for %s; run 'lightfold config set-token %s'", target.Provider, target.Provider)
+	}
+
+	provider, err := providers.GetProvider(target.Provider, token)
+	if err != nil {
+		return nil, powerServer{}, err
+	}
+	snapshots, ok := provider.(providers.SnapshotManager)
+	if !ok {
+		return nil, powerServer{}, providers.ErrSnapshotsUnsupported(target.Provider)
+	}
+
+	servers, err := targetServers(target, targetName)
+	if err != nil {
+		return nil, powerServer{}, err
+	}
+	return snapshots, servers[0], nil
+}
+
+// takeSnapshot snapshots server, printing progress, and records the snapshot
+// in the server state
+func takeSnapshot(snapshots providers.SnapshotManager, target config.TargetConfig, targetName string, server powerServer, name string) (*providers.Snapshot, error) {
+	if name == "" {
+		name = fmt.Sprintf("lightfold-%s-%s", targetName, time.Now().Format("20060102-150405"))
+	}
+
+	fmt.Printf("%s\n", serverMutedStyle.Render(fmt.Sprintf("Snapshotting %s as %s...", server.ip, name)))
+	started := time.Now()
+	lastPercent := -2
+	snapshot, err := snapshots.Snapshot(context.Background(), server.serverID, name, func(percent int) {
+		if jsonOutput || (percent >= 0 && percent == lastPercent) {
+			return
+		}
+		lastPercent = percent
+		if percent < 0 {
+			fmt.Printf("\r  %s", serverMutedStyle.Render(fmt.Sprintf("waiting for the provider (%s)", time.Since(started).Round(time.Second))))
+		} else {
+			fmt.Printf("\r  %s", serverMutedStyle.Render(fmt.Sprintf("%d%%", percent)))
+		}
+	})
+	if !jsonOutput && lastPercent != -2 {
+		fmt.Println()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := state.RecordSnapshot(server.ip, state.Snapshot{
+		ID:        snapshot.ID,
+		Name:      snapshot.Name,
+		Provider:  target.Provider,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		fmt.Printf("%s %s\n", pauseWarningStyle.Render(style.Warn()), serverMutedStyle.Render(fmt.Sprintf("Failed to record snapshot in server state: %v", err)))
+	}
+
+	fmt.Printf("%s %s %s\n", serverSuccessStyle.Render(style.Check()), serverMutedStyle.Render("Snapshot ready:"), serverValueStyle.Render(snapshot.ID))
+	return snapshot, nil
+}
+
+func printSnapshots(snapshots []providers.Snapshot) {
+	if len(snapshots) == 0 {
+		fmt.Printf("%s\n", serverMutedStyle.Render("No snapshots found"))
+		return
+	}
+
+	for _, snapshot := range snapshots {
+		details := []string{snapshot.Status}
+		if !snapshot.CreatedAt.IsZero() {
+			details = append(details, timefmt.Relative(snapshot.CreatedAt, time.Now()))
+		}
+		if snapshot.SizeGB > 0 {
+			details = append(details, fmt.Sprintf("%.1f GB", snapshot.SizeGB))
+		}
+		fmt.Printf("  %s  %s %s\n", serverValueStyle.Render(snapshot.ID), snapshot.Name, serverMutedStyle.Render("("+strings.Join(details, ", ")+")"))
+	}
+}
+
+// confirmRestore asks before the server's disk is replaced. Non-interactive
+// runs fail unless --yes confirmed the restore up front.
+func confirmRestore(ip, snapshotID string, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return false, fmt.Errorf("restoring %s from snapshot %s replaces its disk; pass --yes to confirm without a prompt", ip, snapshotID)
+	}
+
+	fmt.Printf("Rebuild %s from snapshot %s? Data written since the snapshot is lost. (y/N): ", ip, snapshotID)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(response)) == "y", nil
+}
+
+// offerConfigureSnapshot offers to snapshot the server before 'configure
+// --force' reconfigures it. It is skipped when the provider has no snapshot
+// support and defaults to no in non-interactive runs.
+func offerConfigureSnapshot(target config.TargetConfig, targetName string) error {
+	if jsonOutput || skipInteractive || !isTerminal() {
+		return nil
+	}
+
+	snapshots, server, err := targetSnapshotManager(target, targetName)
+	if err != nil {
+		return nil
+	}
+
+	fmt.Printf("Snapshot %s before reconfiguring? (y/N): ", server.ip)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(response)) != "y" {
+		return nil
+	}
+
+	if _, err := takeSnapshot(snapshots, target, targetName, server, ""); err != nil {
+		return fmt.Errorf("snapshot failed (pass --no-snapshot to reconfigure without one): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfirmRestore_NonInteractiveNeedsYes(t *testing.T) {
+	skipInteractive = true
+	defer func() { skipInteractive = false }()
+
+	if confirmed, err := confirmRestore("203.0.113.10", "snap-1", false); confirmed || err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("confirmRestore() without --yes = %v, %v; want an error naming --yes", confirmed, err)
+	}
+	if confirmed, err := confirmRestore("203.0.113.10", "snap-1", true); !confirmed || err != nil {
+		t.Errorf("confirmRestore() with --yes = %v, %v; want confirmed", confirmed, err)
+	}
+}
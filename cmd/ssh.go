@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"io"
 	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
 	"lightfold/pkg/util"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
@@ -45,7 +45,7 @@ Examples:
 			cwd, err := os.Getwd()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: Cannot determine current directory: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 
 			// Try to infer target name from directory
@@ -63,7 +63,7 @@ Examples:
 				fmt.Fprintf(os.Stderr, "Error: No target found for current directory\n")
 				fmt.Fprintf(os.Stderr, "\nRun 'lightfold status' to list all configured targets, or specify a target:\n")
 				fmt.Fprintf(os.Stderr, "  lightfold ssh --target <name>\n")
-				os.Exit(1)
+				exit(1)
 			}
 		} else {
 			targetName = sshTargetFlag
@@ -73,13 +73,13 @@ Examples:
 		if target.Provider == "s3" {
 			fmt.Fprintf(os.Stderr, "Error: Target '%s' uses S3 provider, which does not support SSH\n", targetName)
 			fmt.Fprintf(os.Stderr, "\nS3 targets are for static site deployments only.\n")
-			os.Exit(1)
+			exit(1)
 		}
 
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Cannot get SSH configuration: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		ip := providerCfg.GetIP()
@@ -97,70 +97,43 @@ Examples:
 				fmt.Fprintf(os.Stderr, "\nThe target may not be fully provisioned. Check status:\n")
 				fmt.Fprintf(os.Stderr, "  lightfold status --target %s\n", targetName)
 			}
-			os.Exit(1)
+			exit(1)
 		}
 
 		if username == "" {
 			fmt.Fprintf(os.Stderr, "Error: Target '%s' does not have a username configured\n", targetName)
 			fmt.Fprintf(os.Stderr, "\nCheck your target configuration:\n")
 			fmt.Fprintf(os.Stderr, "  lightfold status --target %s\n", targetName)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if sshKey == "" {
 			fmt.Fprintf(os.Stderr, "Error: Target '%s' does not have an SSH key configured\n", targetName)
 			fmt.Fprintf(os.Stderr, "\nCheck your target configuration:\n")
 			fmt.Fprintf(os.Stderr, "  lightfold status --target %s\n", targetName)
-			os.Exit(1)
+			exit(1)
 		}
 
 		if sshCommandFlag != "" {
-			if err := executeSSHCommand(ip, username, sshKey, sshCommandFlag); err != nil {
+			if err := executeSSHCommand(sshpkg.NewExecutorFromConfig(providerCfg), sshCommandFlag); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: SSH command failed: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		} else {
-			if err := connectInteractiveSSH(ip, username, sshKey); err != nil {
+			if err := connectInteractiveSSH(sshpkg.NewExecutorFromConfig(providerCfg)); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: SSH connection failed: %v\n", err)
 				fmt.Fprintf(os.Stderr, "\nTroubleshooting:\n")
 				fmt.Fprintf(os.Stderr, "  1. Verify the server is running and reachable\n")
 				fmt.Fprintf(os.Stderr, "  2. Check your SSH key has correct permissions (chmod 600 %s)\n", sshKey)
 				fmt.Fprintf(os.Stderr, "  3. Verify network connectivity to %s\n", ip)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 	},
 }
 
-func executeSSHCommand(host, username, keyPath, command string) error {
-	if keyPath[:2] == "~/" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
-		keyPath = filepath.Join(home, keyPath[2:])
-	}
-
-	keyBytes, err := os.ReadFile(keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to read SSH key: %w", err)
-	}
-
-	signer, err := ssh.ParsePrivateKey(keyBytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse SSH key: %w", err)
-	}
-
-	sshConfig := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	addr := fmt.Sprintf("%s:%s", host, config.DefaultSSHPort)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+func executeSSHCommand(executor *sshpkg.Executor, command string) error {
+	client, err := executor.Dial()
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -178,7 +151,7 @@ func executeSSHCommand(host, username, keyPath, command string) error {
 
 	if err := session.Run(command); err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
-			os.Exit(exitErr.ExitStatus())
+			exit(exitErr.ExitStatus())
 		}
 		return err
 	}
@@ -186,35 +159,8 @@ func executeSSHCommand(host, username, keyPath, command string) error {
 	return nil
 }
 
-func connectInteractiveSSH(host, username, keyPath string) error {
-	if keyPath[:2] == "~/" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
-		}
-		keyPath = filepath.Join(home, keyPath[2:])
-	}
-
-	keyBytes, err := os.ReadFile(keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to read SSH key: %w", err)
-	}
-
-	signer, err := ssh.ParsePrivateKey(keyBytes)
-	if err != nil {
-		return fmt.Errorf("failed to parse SSH key: %w", err)
-	}
-
-	sshConfig := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	addr := fmt.Sprintf("%s:%s", host, config.DefaultSSHPort)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+func connectInteractiveSSH(executor *sshpkg.Executor) error {
+	client, err := executor.Dial()
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -3,6 +3,7 @@
 package cmd
 
 import (
+	"lightfold/pkg/crash"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,12 +16,12 @@ import (
 func setupWindowChangeHandler(session *ssh.Session, fd int) {
 	sigwinch := make(chan os.Signal, 1)
 	signal.Notify(sigwinch, syscall.SIGWINCH)
-	go func() {
+	crash.Go(func() {
 		for range sigwinch {
 			w, h, err := term.GetSize(fd)
 			if err == nil {
 				session.WindowChange(h, w)
 			}
 		}
-	}()
+	})
 }
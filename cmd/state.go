@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/state"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var stateTargetFlag string
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and repair local target state",
+	Long: `Local state lives in ~/.lightfold/state/<target>.json. Every write keeps the
+previous version as <target>.json.bak, and a state file that fails to parse is
+restored from it automatically with a warning.`,
+}
+
+var stateRepairCmd = &cobra.Command{
+	Use:   "repair [PROJECT_PATH]",
+	Short: "Rebuild a corrupt state file from the server",
+	Long: `Rebuild a target's state when both its state file and backup are corrupt.
+The corrupt file is moved to <target>.json.corrupt and the state is synced
+from the server, as 'lightfold sync' does. A readable state is left alone.
+
+Examples:
+  lightfold state repair --target myapp`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigOrExit()
+
+		var pathArg string
+		if len(args) > 0 {
+			pathArg = args[0]
+		}
+
+		target, targetName := resolveTarget(cfg, stateTargetFlag, pathArg)
+
+		_, err := state.LoadState(targetName)
+		if err == nil {
+			fmt.Printf("%s %s\n", style.Success.Render(style.Check()), style.Muted.Render(fmt.Sprintf("State for '%s' is readable; nothing to repair", targetName)))
+			return
+		}
+		var corrupt *state.CorruptStateError
+		if !errors.As(err, &corrupt) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		corruptPath, err := state.SetAsideCorruptState(targetName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("%s %s\n\n", style.Muted.Render(style.Info()), style.Muted.Render("Moved the corrupt state file to "+corruptPath))
+
+		fmt.Printf("%s %s\n\n", style.Header.Render("Rebuilding state for:"), style.Value.Render(targetName))
+		if _, err := syncTarget(target, targetName, cfg, false); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s %v\n", style.ErrorText.Render(style.Cross()+" Repair failed:"), err)
+			exit(1)
+		}
+
+		fmt.Printf("\n%s\n", style.Success.Render(fmt.Sprintf("%s Rebuilt state for '%s'", style.Check(), targetName)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateRepairCmd)
+
+	stateCmd.PersistentFlags().StringVar(&stateTargetFlag, "target", "", "Target name (defaults to current directory)")
+}
@@ -3,56 +3,119 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/providers/flyio"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
 var (
-	statusTargetFlag string
-	statusJSONFlag   bool
-
-	statusHeaderStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	statusLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-	statusValueStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
-	statusMutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	statusSuccessStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	statusErrorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	statusTargetFlag   string
+	statusJSONFlag     bool
+	statusMetricsFlag  bool
+	statusDiskFlag     bool
+	statusWatchFlag    bool
+	statusNoRemoteFlag bool
+	statusInterval     time.Duration
+
+	statusHeaderStyle  = style.Title
+	statusLabelStyle   = style.Header
+	statusValueStyle   = style.Value
+	statusMutedStyle   = style.Faint
+	statusSuccessStyle = style.Success
+	statusErrorStyle   = style.ErrorText
+	statusWarningStyle = style.WarningText
 )
 
 // StatusOutput represents the JSON structure for status output
 type StatusOutput struct {
-	Target          string             `json:"target"`
-	ProjectPath     string             `json:"project_path"`
-	Framework       string             `json:"framework"`
-	Provider        string             `json:"provider"`
-	Created         bool               `json:"created"`
-	Configured      bool               `json:"configured"`
-	CreateFailed    bool               `json:"create_failed,omitempty"`
-	CreateError     string             `json:"create_error,omitempty"`
-	ConfigureFailed bool               `json:"configure_failed,omitempty"`
-	ConfigureError  string             `json:"configure_error,omitempty"`
-	PushFailed      bool               `json:"push_failed,omitempty"`
-	PushError       string             `json:"push_error,omitempty"`
-	LastFailure     string             `json:"last_failure,omitempty"`
-	LastCommit      string             `json:"last_commit,omitempty"`
-	LastDeploy      string             `json:"last_deploy,omitempty"`
-	LastRelease     string             `json:"last_release,omitempty"`
-	ServerIP        string             `json:"server_ip,omitempty"`
-	ServerID        string             `json:"server_id,omitempty"`
-	ServiceStatus   string             `json:"service_status,omitempty"`
-	ServiceUptime   string             `json:"service_uptime,omitempty"`
-	CurrentRelease  string             `json:"current_release,omitempty"`
-	DiskUsage       string             `json:"disk_usage,omitempty"`
-	ServerUptime    string             `json:"server_uptime,omitempty"`
-	HealthCheck     *HealthCheckStatus `json:"health_check,omitempty"`
+	Target          string              `json:"target"`
+	ProjectPath     string              `json:"project_path"`
+	Framework       string              `json:"framework"`
+	Provider        string              `json:"provider"`
+	ProviderType    string              `json:"provider_type"` // Shape of the remote fields: ssh, flyio or s3
+	Protected       bool                `json:"protected,omitempty"`
+	Created         bool                `json:"created"`
+	Configured      bool                `json:"configured"`
+	CreateFailed    bool                `json:"create_failed,omitempty"`
+	CreateError     string              `json:"create_error,omitempty"`
+	ConfigureFailed bool                `json:"configure_failed,omitempty"`
+	ConfigureError  string              `json:"configure_error,omitempty"`
+	PushFailed      bool                `json:"push_failed,omitempty"`
+	PushError       string              `json:"push_error,omitempty"`
+	Paused          bool                `json:"paused,omitempty"`
+	PausedAt        string              `json:"paused_at,omitempty"`
+	Schedule        *ScheduleStatus     `json:"schedule,omitempty"`
+	LastFailure     string              `json:"last_failure,omitempty"`
+	LastSuperseded  string              `json:"last_superseded,omitempty"` // Release that gave way to a newer push
+	LastCommit      string              `json:"last_commit,omitempty"`
+	LastDeploy      string              `json:"last_deploy,omitempty"`
+	LastDeployBy    string              `json:"last_deploy_by,omitempty"` // Local user@host that ran the last deploy
+	LastRelease     string              `json:"last_release,omitempty"`
+	ServerIP        string              `json:"server_ip,omitempty"`
+	ServerIPv6      string              `json:"server_ipv6,omitempty"`
+	ServerID        string              `json:"server_id,omitempty"`
+	ServiceStatus   string              `json:"service_status,omitempty"`
+	ServiceUptime   string              `json:"service_uptime,omitempty"`
+	CurrentRelease  string              `json:"current_release,omitempty"`
+	DiskUsage       string              `json:"disk_usage,omitempty"`
+	ServerUptime    string              `json:"server_uptime,omitempty"`
+	Privilege       string              `json:"privilege,omitempty"`    // How privileged commands run: root, sudo or none
+	RemoteError     string              `json:"remote_error,omitempty"` // Why the server could not be read
+	HealthCheck     *HealthCheckStatus  `json:"health_check,omitempty"`
+	DeployHealth    *state.DeployHealth `json:"deploy_health,omitempty"` // App and nginx health checks of the last deploy
+	Process         *ProcessMetrics     `json:"process,omitempty"`
+	LoadBalancerIP  string              `json:"load_balancer_ip,omitempty"`
+	Servers         []ServerStatus      `json:"servers,omitempty"`
+	Domain          string              `json:"domain,omitempty"`
+	DomainDrift     string              `json:"domain_drift,omitempty"` // Why the domain config is not on the current server
+	Maintenance     *state.Maintenance  `json:"maintenance,omitempty"`  // Set while the maintenance page is served
+	Disk            *DiskBreakdown      `json:"disk,omitempty"`         // What uses the disk, with --disk or when it is nearly full
+	Flyio           *flyio.AppStatus    `json:"flyio,omitempty"`        // Machines and release of a fly.io app
+	// SSLRenewalRisk is set when the certificate expires soon and the last
+	// renewal verification failed
+	SSLRenewalRisk string `json:"ssl_renewal_risk,omitempty"`
+}
+
+// ServerStatus is the per-server state of a multi-server target
+type ServerStatus struct {
+	IP             string `json:"ip"`
+	ServiceStatus  string `json:"service_status,omitempty"`
+	CurrentRelease string `json:"current_release,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ScheduleStatus is a target's power schedule and the next time it acts
+type ScheduleStatus struct {
+	Stop       string `json:"stop,omitempty"`
+	Start      string `json:"start,omitempty"`
+	Timezone   string `json:"timezone"`
+	NextAction string `json:"next_action,omitempty"`
+	NextAt     string `json:"next_at,omitempty"`
+}
+
+// Describe renders the schedule on one line, e.g.
+// "stop 0 20 * * 1-5, start 0 8 * * 1-5 (Europe/Berlin)"
+func (s ScheduleStatus) Describe() string {
+	parts := []string{}
+	if s.Stop != "" {
+		parts = append(parts, "stop "+s.Stop)
+	}
+	if s.Start != "" {
+		parts = append(parts, "start "+s.Start)
+	}
+	return strings.Join(parts, ", ") + " (" + s.Timezone + ")"
 }
 
 // HealthCheckStatus represents health check information
@@ -76,7 +139,12 @@ Examples:
   lightfold status .                  # Status for current directory
   lightfold status ~/Projects/myapp   # Status for specific project
   lightfold status --target myapp     # Status for named target
-  lightfold status --json             # JSON output`,
+  lightfold status --json             # JSON output
+  lightfold status --no-remote        # Local state only, without connecting to servers
+  lightfold status --target myapp --metrics  # Include app memory/CPU usage
+  lightfold status --target myapp --disk     # What fills the server's disk
+  lightfold status --target myapp --watch    # Refresh every 5s, highlighting changes
+  lightfold status --watch --interval 30s    # Watch all targets`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := loadConfigOrExit()
@@ -86,106 +154,174 @@ Examples:
 			pathArg = args[0]
 		}
 
+		if statusWatchFlag && statusInterval < time.Second {
+			fmt.Fprintf(os.Stderr, "%s\n", statusErrorStyle.Render("Error: --interval must be at least 1s"))
+			exit(1)
+		}
+
 		// If no flag and no path arg, show all targets
 		if statusTargetFlag == "" && pathArg == "" {
+			if statusWatchFlag {
+				watchStatus(cfg, "", statusInterval)
+				return
+			}
 			showAllTargets(cfg)
 			return
 		}
 
 		_, targetName := resolveTarget(cfg, statusTargetFlag, pathArg)
+		if statusWatchFlag {
+			watchStatus(cfg, targetName, statusInterval)
+			return
+		}
 		showTargetDetail(cfg, targetName)
 	},
 }
 
 func showAllTargets(cfg *config.Config) {
+	printAllTargets(os.Stdout, cfg, collectTargetSummaries(cfg), nil)
+}
+
+func printAllTargets(w io.Writer, cfg *config.Config, summaries map[string]StatusOutput, changes map[string]statusChanges) {
 	if len(cfg.Targets) == 0 {
-		fmt.Println(statusMutedStyle.Render("No targets configured yet!"))
-		fmt.Printf("\n%s\n", statusMutedStyle.Render("Deploy your first project:"))
-		fmt.Printf("  %s  %s\n", statusValueStyle.Render("lightfold deploy"), statusMutedStyle.Render("# in project directory"))
-		fmt.Printf("  %s\n", statusValueStyle.Render("lightfold deploy --target myapp"))
+		fmt.Fprintln(w, statusMutedStyle.Render("No targets configured yet!"))
+		fmt.Fprintf(w, "\n%s\n", statusMutedStyle.Render("Deploy your first project:"))
+		fmt.Fprintf(w, "  %s  %s\n", statusValueStyle.Render("lightfold deploy"), statusMutedStyle.Render("# in project directory"))
+		fmt.Fprintf(w, "  %s\n", statusValueStyle.Render("lightfold deploy --target myapp"))
 
 		return
 	}
 
-	fmt.Printf("%s\n", statusHeaderStyle.Render(fmt.Sprintf("Configured Targets (%d):", len(cfg.Targets))))
-	fmt.Println(statusMutedStyle.Render("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"))
+	fmt.Fprintf(w, "%s\n", statusHeaderStyle.Render(fmt.Sprintf("Configured Targets (%d):", len(cfg.Targets))))
+	fmt.Fprintln(w, statusMutedStyle.Render(style.Rule(51)))
+
+	targetNames := make([]string, 0, len(cfg.Targets))
+	for targetName := range cfg.Targets {
+		targetNames = append(targetNames, targetName)
+	}
+	sort.Strings(targetNames)
 
-	for targetName, target := range cfg.Targets {
-		fmt.Printf("\n%s\n", statusLabelStyle.Render(targetName))
+	for _, targetName := range targetNames {
+		target := cfg.Targets[targetName]
+		fmt.Fprintf(w, "\n%s\n", statusLabelStyle.Render(targetName))
 
-		targetState, err := state.LoadState(targetName)
-		if err != nil {
-			fmt.Printf("  %s\n", statusErrorStyle.Render(fmt.Sprintf("Error loading state: %v", err)))
+		summary, ok := summaries[targetName]
+		if !ok {
+			fmt.Fprintf(w, "  %s\n", statusErrorStyle.Render("Error loading state"))
 			continue
 		}
+		changed := changes[targetName]
 
-		fmt.Printf("  Provider:    %s\n", statusValueStyle.Render(target.Provider))
-		fmt.Printf("  Framework:   %s\n", statusValueStyle.Render(target.Framework))
+		fmt.Fprintf(w, "  Provider:    %s\n", statusValueStyle.Render(target.Provider))
+		fmt.Fprintf(w, "  Framework:   %s\n", statusValueStyle.Render(target.Framework))
+		if summary.Protected {
+			fmt.Fprintf(w, "  Protected:   %s\n", statusWarningStyle.Render("Yes"))
+		}
+		if summary.Paused {
+			fmt.Fprintf(w, "  Status:      %s%s\n", statusWarningStyle.Render(style.Paused()+" Paused since "+formatStatusTime(summary.PausedAt)), changed.mark("paused"))
+		} else if changed["paused"] {
+			fmt.Fprintf(w, "  Status:      %s%s\n", statusSuccessStyle.Render(style.Play()+" Resumed"), changed.mark("paused"))
+		}
+		if summary.Maintenance != nil {
+			fmt.Fprintf(w, "  %s%s\n", statusWarningStyle.Render(maintenanceBanner(summary.Maintenance)), changed.mark("maintenance"))
+		}
+		if summary.Schedule != nil {
+			fmt.Fprintf(w, "  Schedule:    %s\n", statusMutedStyle.Render(summary.Schedule.Describe()))
+		}
+		if summary.SSLRenewalRisk != "" {
+			fmt.Fprintf(w, "  SSL:         %s\n", statusErrorStyle.Render(style.Warn()+" "+summary.SSLRenewalRisk))
+		}
 
 		if target.Provider == "flyio" {
 			if flyConfig, err := target.GetFlyioConfig(); err == nil && flyConfig.AppName != "" {
-				fmt.Printf("  App Name:    %s\n", statusValueStyle.Render(flyConfig.AppName))
+				fmt.Fprintf(w, "  App Name:    %s\n", statusValueStyle.Render(flyConfig.AppName))
 			}
 		}
 
-		providerCfg, _ := target.GetAnyProviderConfig()
-		var ip string
-		var hasIP bool
-		if providerCfg != nil {
-			ip = providerCfg.GetIP()
-			hasIP = ip != ""
+		if summary.ServerIP != "" {
+			fmt.Fprintf(w, "  IP:          %s%s\n", statusValueStyle.Render(summary.ServerIP), changed.mark("server_ip"))
 		}
-		if ip != "" {
-			fmt.Printf("  IP:          %s\n", statusValueStyle.Render(ip))
+		if summary.ServerIPv6 != "" {
+			fmt.Fprintf(w, "  IPv6:        %s\n", statusValueStyle.Render(summary.ServerIPv6))
 		}
 
-		if !targetState.LastDeploy.IsZero() {
-			fmt.Printf("  Last Deploy: %s\n", statusValueStyle.Render(targetState.LastDeploy.Format("2006-01-02 15:04")))
+		if summary.LastDeploy != "" {
+			fmt.Fprintf(w, "  Last Deploy: %s%s\n", statusValueStyle.Render(formatStatusTime(summary.LastDeploy)), changed.mark("last_deploy"))
 		}
+		printSummaryService(w, summary, changed)
 
-		fmt.Printf("\n  %s\n", statusLabelStyle.Render("Pipeline:"))
+		fmt.Fprintf(w, "\n  %s\n", statusLabelStyle.Render("Pipeline:"))
 
-		fmt.Printf("    %s\n", statusSuccessStyle.Render("✓ Detect"))
+		fmt.Fprintf(w, "    %s\n", statusSuccessStyle.Render(style.Check()+" Detect"))
 
-		if targetState.Created || hasIP {
-			fmt.Printf("    %s\n", statusSuccessStyle.Render("✓ Create"))
-		} else if targetState.CreateFailed {
-			fmt.Printf("    %s\n", statusErrorStyle.Render("✗ Create (failed)"))
+		if summary.Created || summary.ServerIP != "" {
+			fmt.Fprintf(w, "    %s%s\n", statusSuccessStyle.Render(style.Check()+" Create"), changed.mark("created"))
+		} else if summary.CreateFailed {
+			fmt.Fprintf(w, "    %s%s\n", statusErrorStyle.Render(style.Cross()+" Create (failed)"), changed.mark("created"))
 		} else {
-			fmt.Printf("    %s\n", statusMutedStyle.Render("[ ] Create"))
+			fmt.Fprintf(w, "    %s\n", statusMutedStyle.Render("[ ] Create"))
 		}
 
-		if targetState.Configured {
-			fmt.Printf("    %s\n", statusSuccessStyle.Render("✓ Configure"))
-		} else if targetState.ConfigureFailed {
-			fmt.Printf("    %s\n", statusErrorStyle.Render("✗ Configure (failed)"))
+		if summary.Configured {
+			fmt.Fprintf(w, "    %s%s\n", statusSuccessStyle.Render(style.Check()+" Configure"), changed.mark("configured"))
+		} else if summary.ConfigureFailed {
+			fmt.Fprintf(w, "    %s%s\n", statusErrorStyle.Render(style.Cross()+" Configure (failed)"), changed.mark("configured"))
 		} else {
-			fmt.Printf("    %s\n", statusMutedStyle.Render("[ ] Configure"))
+			fmt.Fprintf(w, "    %s\n", statusMutedStyle.Render("[ ] Configure"))
 		}
 
-		if !targetState.LastDeploy.IsZero() {
-			fmt.Printf("    %s\n", statusSuccessStyle.Render("✓ Push"))
-		} else if targetState.PushFailed {
-			fmt.Printf("    %s\n", statusErrorStyle.Render("✗ Push (failed)"))
+		if summary.LastDeploy != "" {
+			fmt.Fprintf(w, "    %s%s\n", statusSuccessStyle.Render(style.Check()+" Push"), changed.mark("push"))
+		} else if summary.PushFailed {
+			fmt.Fprintf(w, "    %s%s\n", statusErrorStyle.Render(style.Cross()+" Push (failed)"), changed.mark("push"))
 		} else {
-			fmt.Printf("    %s\n", statusMutedStyle.Render("[ ] Push"))
+			fmt.Fprintf(w, "    %s\n", statusMutedStyle.Render("[ ] Push"))
 		}
 	}
 
-	fmt.Printf("\n%s\n", statusMutedStyle.Render("For detailed status: lightfold status --target <name>"))
+	fmt.Fprintf(w, "\n%s\n", statusMutedStyle.Render("For detailed status: lightfold status --target <name>"))
+}
+
+// printSummaryService shows the service state and release read from the
+// target's server, or why it could not be read
+func printSummaryService(w io.Writer, summary StatusOutput, changed statusChanges) {
+	switch {
+	case summary.RemoteError != "" && summary.ProviderType == "flyio":
+		fmt.Fprintf(w, "  Service:     %s\n", statusWarningStyle.Render(style.Warn()+" "+summary.RemoteError+"; local state only"))
+		return
+	case summary.RemoteError != "":
+		fmt.Fprintf(w, "  Service:     %s\n", statusErrorStyle.Render(style.Cross()+" unreachable: "+summary.RemoteError))
+		return
+	case summary.ServiceStatus == "":
+		return
+	case summary.ServiceStatus == "active":
+		service := style.Check() + " active"
+		if summary.ServiceUptime != "" {
+			service += " for " + summary.ServiceUptime
+		}
+		fmt.Fprintf(w, "  Service:     %s%s\n", statusSuccessStyle.Render(service), changed.mark("service"))
+	default:
+		fmt.Fprintf(w, "  Service:     %s%s\n", statusErrorStyle.Render(style.Cross()+" "+summary.ServiceStatus), changed.mark("service"))
+	}
+	if summary.CurrentRelease != "" {
+		fmt.Fprintf(w, "  Release:     %s%s\n", statusValueStyle.Render(timefmt.Release(summary.CurrentRelease)), changed.mark("release"))
+	}
+	if summary.DiskUsage != "" {
+		fmt.Fprintf(w, "  Disk:        %s%s\n", statusValueStyle.Render(summary.DiskUsage+" used"), changed.mark("disk"))
+	}
 }
 
 func showTargetDetail(cfg *config.Config, targetName string) {
 	target, exists := cfg.GetTarget(targetName)
 	if !exists {
 		fmt.Fprintf(os.Stderr, "%s\n", statusErrorStyle.Render(fmt.Sprintf("Error: Target '%s' not found", targetName)))
-		os.Exit(1)
+		exit(1)
 	}
 
 	targetState, err := state.LoadState(targetName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", statusErrorStyle.Render(fmt.Sprintf("Error loading state: %v", err)))
-		os.Exit(1)
+		exit(1)
 	}
 
 	// Collect status data
@@ -196,108 +332,164 @@ func showTargetDetail(cfg *config.Config, targetName string) {
 		jsonData, err := json.MarshalIndent(statusData, "", "  ")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 		fmt.Println(string(jsonData))
 		return
 	}
 
-	// Human-readable output
-	fmt.Printf("%s %s\n", statusHeaderStyle.Render("Target:"), statusLabelStyle.Render(targetName))
-	fmt.Printf("%s\n\n", statusMutedStyle.Render("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"))
+	printTargetDetail(os.Stdout, target, targetName, targetState, statusData, nil)
+}
+
+// printTargetDetail renders one target's status; changes marks the fields
+// that differ from the previous sample in watch mode
+// releaseDeployedAt is when the target state says release was deployed: the
+// last deploy time when it is the last release, else zero
+func releaseDeployedAt(targetState *state.TargetState, release string) time.Time {
+	if targetState == nil || release != targetState.LastRelease {
+		return time.Time{}
+	}
+	return targetState.LastDeploy
+}
 
-	fmt.Printf("%s\n", statusHeaderStyle.Render("Configuration:"))
-	fmt.Printf("  Project:   %s\n", statusValueStyle.Render(target.ProjectPath))
-	fmt.Printf("  Framework: %s\n", statusValueStyle.Render(target.Framework))
-	fmt.Printf("  Provider:  %s\n", statusValueStyle.Render(target.Provider))
-	fmt.Println()
+func printTargetDetail(w io.Writer, target config.TargetConfig, targetName string, targetState *state.TargetState, statusData StatusOutput, changes statusChanges) {
+	fmt.Fprintf(w, "%s %s\n", statusHeaderStyle.Render("Target:"), statusLabelStyle.Render(targetName))
+	fmt.Fprintf(w, "%s\n\n", statusMutedStyle.Render(style.Rule(51)))
+
+	if statusData.Maintenance != nil {
+		fmt.Fprintf(w, "%s%s\n", statusWarningStyle.Render(maintenanceBanner(statusData.Maintenance)), changes.mark("maintenance"))
+		fmt.Fprintf(w, "%s\n\n", statusMutedStyle.Render(fmt.Sprintf("Visitors get the maintenance page; run 'lightfold maintenance off --target %s' to serve the app", targetName)))
+	}
+
+	fmt.Fprintf(w, "%s\n", statusHeaderStyle.Render("Configuration:"))
+	fmt.Fprintf(w, "  Project:   %s\n", statusValueStyle.Render(target.ProjectPath))
+	fmt.Fprintf(w, "  Framework: %s\n", statusValueStyle.Render(target.Framework))
+	fmt.Fprintf(w, "  Provider:  %s\n", statusValueStyle.Render(target.Provider))
+	if target.Protected {
+		fmt.Fprintf(w, "  Protected: %s\n", statusWarningStyle.Render("Yes (push, deploy and destroy ask for the target name)"))
+	}
+	if statusData.Domain != "" {
+		fmt.Fprintf(w, "  Domain:    %s\n", statusValueStyle.Render(statusData.Domain))
+		if statusData.DomainDrift != "" {
+			fmt.Fprintf(w, "  %s\n", statusErrorStyle.Render(style.Warn()+" "+statusData.DomainDrift))
+			fmt.Fprintf(w, "  %s\n", statusMutedStyle.Render(fmt.Sprintf("Run 'lightfold sync --target %s --fix' to re-apply nginx and SSL", targetName)))
+		}
+		if statusData.SSLRenewalRisk != "" {
+			fmt.Fprintf(w, "  %s\n", statusErrorStyle.Render(style.Warn()+" "+statusData.SSLRenewalRisk))
+			fmt.Fprintf(w, "  %s\n", statusMutedStyle.Render(fmt.Sprintf("Run 'lightfold domain verify-renewal --target %s' to check and fix renewal", targetName)))
+		}
+	}
+	fmt.Fprintln(w)
 
-	fmt.Printf("%s\n", statusHeaderStyle.Render("State:"))
+	fmt.Fprintf(w, "%s\n", statusHeaderStyle.Render("State:"))
 	if targetState.Created {
-		fmt.Printf("  Created:    %s\n", statusSuccessStyle.Render("✓ Yes"))
+		fmt.Fprintf(w, "  Created:    %s\n", statusSuccessStyle.Render(style.Check()+" Yes"))
 	} else if targetState.CreateFailed {
-		fmt.Printf("  Created:    %s\n", statusErrorStyle.Render("✗ Failed"))
+		fmt.Fprintf(w, "  Created:    %s\n", statusErrorStyle.Render(style.Cross()+" Failed"))
 		if targetState.CreateError != "" {
 			errorMsg := targetState.CreateError
 			if len(errorMsg) > 80 {
 				errorMsg = errorMsg[:77] + "..."
 			}
-			fmt.Printf("  Error:      %s\n", statusMutedStyle.Render(errorMsg))
+			fmt.Fprintf(w, "  Error:      %s\n", statusMutedStyle.Render(errorMsg))
 		}
 	} else {
-		fmt.Printf("  Created:    %s\n", statusMutedStyle.Render("✗ No"))
+		fmt.Fprintf(w, "  Created:    %s\n", statusMutedStyle.Render(style.Cross()+" No"))
 	}
 	if targetState.Configured {
-		fmt.Printf("  Configured: %s\n", statusSuccessStyle.Render("✓ Yes"))
+		fmt.Fprintf(w, "  Configured: %s\n", statusSuccessStyle.Render(style.Check()+" Yes"))
 	} else if targetState.ConfigureFailed {
-		fmt.Printf("  Configured: %s\n", statusErrorStyle.Render("✗ Failed"))
+		fmt.Fprintf(w, "  Configured: %s\n", statusErrorStyle.Render(style.Cross()+" Failed"))
 		if targetState.ConfigureError != "" {
 			errorMsg := targetState.ConfigureError
 			if len(errorMsg) > 80 {
 				errorMsg = errorMsg[:77] + "..."
 			}
-			fmt.Printf("  Error:      %s\n", statusMutedStyle.Render(errorMsg))
+			fmt.Fprintf(w, "  Error:      %s\n", statusMutedStyle.Render(errorMsg))
 		}
 	} else {
-		fmt.Printf("  Configured: %s\n", statusMutedStyle.Render("✗ No"))
+		fmt.Fprintf(w, "  Configured: %s\n", statusMutedStyle.Render(style.Cross()+" No"))
+	}
+	if targetState.Paused {
+		fmt.Fprintf(w, "  Paused:     %s%s\n", statusWarningStyle.Render(style.Paused()+" Since "+timefmt.Human(targetState.PausedAt)), changes.mark("paused"))
+	}
+	if statusData.Schedule != nil {
+		fmt.Fprintf(w, "  Schedule:   %s\n", statusValueStyle.Render(statusData.Schedule.Describe()))
+		if statusData.Schedule.NextAction != "" {
+			fmt.Fprintf(w, "  Next %s: %s\n", statusData.Schedule.NextAction, statusMutedStyle.Render(formatStatusTime(statusData.Schedule.NextAt)))
+		}
 	}
 	if targetState.ProvisionedID != "" {
-		fmt.Printf("  Server ID:  %s\n", statusValueStyle.Render(targetState.ProvisionedID))
+		fmt.Fprintf(w, "  Server ID:  %s\n", statusValueStyle.Render(targetState.ProvisionedID))
 	}
 	if targetState.LastCommit != "" {
 		commitShort := targetState.LastCommit
 		if len(commitShort) > 7 {
 			commitShort = commitShort[:7]
 		}
-		fmt.Printf("  Last Commit: %s\n", statusValueStyle.Render(commitShort))
+		fmt.Fprintf(w, "  Last Commit: %s\n", statusValueStyle.Render(commitShort))
 	}
 	if !targetState.LastDeploy.IsZero() {
-		fmt.Printf("  Last Deploy: %s\n", statusValueStyle.Render(targetState.LastDeploy.Format("2006-01-02 15:04:05")))
+		fmt.Fprintf(w, "  Last Deploy: %s%s\n", statusValueStyle.Render(timefmt.Human(targetState.LastDeploy)), changes.mark("last_deploy"))
+		if statusData.LastDeployBy != "" {
+			fmt.Fprintf(w, "  Deployed By: %s\n", statusValueStyle.Render(statusData.LastDeployBy))
+		}
+		if statusData.DeployHealth != nil {
+			fmt.Fprintf(w, "  Deploy Checks: %s\n", statusValueStyle.Render(statusData.DeployHealth.Summary()))
+		}
 	} else if targetState.PushFailed {
-		fmt.Printf("  Last Deploy: %s\n", statusErrorStyle.Render("✗ Failed"))
+		fmt.Fprintf(w, "  Last Deploy: %s\n", statusErrorStyle.Render(style.Cross()+" Failed"))
 		if targetState.PushError != "" {
 			errorMsg := targetState.PushError
 			if len(errorMsg) > 80 {
 				errorMsg = errorMsg[:77] + "..."
 			}
-			fmt.Printf("  Error:      %s\n", statusMutedStyle.Render(errorMsg))
+			fmt.Fprintf(w, "  Error:      %s\n", statusMutedStyle.Render(errorMsg))
 		}
 	}
 	if targetState.LastRelease != "" {
-		fmt.Printf("  Last Release: %s\n", statusValueStyle.Render(targetState.LastRelease))
+		fmt.Fprintf(w, "  Last Release: %s%s\n", statusValueStyle.Render(timefmt.ReleaseAt(targetState.LastRelease, releaseDeployedAt(targetState, targetState.LastRelease))), changes.mark("last_release"))
 	}
 	if !targetState.LastFailure.IsZero() {
-		fmt.Printf("  Last Failure: %s\n", statusErrorStyle.Render(targetState.LastFailure.Format("2006-01-02 15:04:05")))
+		fmt.Fprintf(w, "  Last Failure: %s\n", statusErrorStyle.Render(timefmt.Human(targetState.LastFailure)))
 	}
-	fmt.Println()
+	if superseded := targetState.LastSuperseded; superseded != nil {
+		fmt.Fprintf(w, "  Superseded: %s\n", statusMutedStyle.Render(fmt.Sprintf("release %s gave way to a newer push (%s)", timefmt.ReleaseAt(superseded.Release, superseded.At), timefmt.Human(superseded.At))))
+	}
+	fmt.Fprintln(w)
 
-	if targetState.Created {
-		fmt.Printf("%s\n", statusHeaderStyle.Render("Server Status:"))
+	if targetState.Created && target.Provider == "flyio" {
+		printFlyioStatus(w, target, statusData, changes)
+	} else if targetState.Created {
+		fmt.Fprintf(w, "%s\n", statusHeaderStyle.Render("Server Status:"))
 
 		if target.Provider == "s3" {
-			fmt.Printf("  Type: %s\n", statusValueStyle.Render("S3 (static site)"))
+			fmt.Fprintf(w, "  Type: %s\n", statusValueStyle.Render("S3 (static site)"))
 			s3Config, _ := target.GetS3Config()
-			fmt.Printf("  Bucket: %s\n", statusValueStyle.Render(s3Config.Bucket))
-			fmt.Printf("  Region: %s\n", statusValueStyle.Render(s3Config.Region))
-			fmt.Println()
+			fmt.Fprintf(w, "  Bucket: %s\n", statusValueStyle.Render(s3Config.Bucket))
+			fmt.Fprintf(w, "  Region: %s\n", statusValueStyle.Render(s3Config.Region))
+			fmt.Fprintln(w)
 			return
 		}
 
 		providerCfg, err := target.GetSSHProviderConfig()
 		if err != nil {
-			fmt.Printf("  %s\n", statusErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			fmt.Fprintf(w, "  %s\n", statusErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
 			return
 		}
 
-		fmt.Printf("  IP:        %s\n", statusValueStyle.Render(providerCfg.GetIP()))
-		fmt.Printf("  Username:  %s\n", statusValueStyle.Render(providerCfg.GetUsername()))
+		fmt.Fprintf(w, "  IP:        %s%s\n", statusValueStyle.Render(providerCfg.GetIP()), changes.mark("server_ip"))
+		if ipv6 := extraIPv6(providerCfg); ipv6 != "" {
+			fmt.Fprintf(w, "  IPv6:      %s\n", statusValueStyle.Render(ipv6))
+		}
+		fmt.Fprintf(w, "  Username:  %s\n", statusValueStyle.Render(providerCfg.GetUsername()))
 
 		// Show server context if available
 		if target.ServerIP != "" {
 			serverState, err := state.GetServerState(target.ServerIP)
 			if err == nil && len(serverState.DeployedApps) > 1 {
-				fmt.Printf("\n  %s\n", statusHeaderStyle.Render("Server Context:"))
-				fmt.Printf("  This server hosts %s\n", statusValueStyle.Render(fmt.Sprintf("%d applications", len(serverState.DeployedApps))))
+				fmt.Fprintf(w, "\n  %s\n", statusHeaderStyle.Render("Server Context:"))
+				fmt.Fprintf(w, "  This server hosts %s\n", statusValueStyle.Render(fmt.Sprintf("%d applications", len(serverState.DeployedApps))))
 
 				// List other apps on same server
 				otherApps := []string{}
@@ -307,102 +499,130 @@ func showTargetDetail(cfg *config.Config, targetName string) {
 					}
 				}
 				if len(otherApps) > 0 {
-					fmt.Printf("  Other apps: %s\n", statusMutedStyle.Render(strings.Join(otherApps, ", ")))
+					fmt.Fprintf(w, "  Other apps: %s\n", statusMutedStyle.Render(strings.Join(otherApps, ", ")))
 				}
 
 				// Show port allocation
 				if target.Port > 0 {
-					fmt.Printf("  This app's port: %s\n", statusValueStyle.Render(fmt.Sprintf("%d", target.Port)))
+					fmt.Fprintf(w, "  This app's port: %s\n", statusValueStyle.Render(fmt.Sprintf("%d", target.Port)))
 				}
 			}
 		}
 
-		fmt.Println()
-		if providerCfg.GetIP() != "" {
-			sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
-			defer sshExecutor.Disconnect()
-
-			appName := strings.ReplaceAll(targetName, "-", "_")
-			result := sshExecutor.Execute(fmt.Sprintf("systemctl is-active %s 2>/dev/null || echo 'not-found'", appName))
-			if result.ExitCode == 0 {
-				status := strings.TrimSpace(result.Stdout)
-				if status == "active" {
-					fmt.Printf("  Service:   %s\n", statusSuccessStyle.Render("✓ Active"))
-				} else if status == "not-found" {
-					fmt.Printf("  Service:   %s\n", statusMutedStyle.Render("- Not configured"))
-				} else {
-					fmt.Printf("  Service:   %s\n", statusErrorStyle.Render(fmt.Sprintf("✗ %s", status)))
-				}
-			} else {
-				fmt.Printf("  Service:   %s\n", statusMutedStyle.Render("? Unable to check"))
+		fmt.Fprintln(w)
+		if targetState.Paused {
+			fmt.Fprintf(w, "  Server:    %s\n", statusWarningStyle.Render(style.Paused()+" Powered off"))
+		} else if statusData.RemoteError != "" {
+			fmt.Fprintf(w, "  Server:    %s\n", statusErrorStyle.Render(style.Cross()+" Unreachable: "+statusData.RemoteError))
+		} else if statusNoRemoteFlag {
+			fmt.Fprintf(w, "  Service:   %s\n", statusMutedStyle.Render("- Not checked (--no-remote)"))
+		} else if providerCfg.GetIP() != "" {
+			switch status := statusData.ServiceStatus; status {
+			case "active":
+				fmt.Fprintf(w, "  Service:   %s%s\n", statusSuccessStyle.Render(style.Check()+" Active"), changes.mark("service"))
+			case "not-found":
+				fmt.Fprintf(w, "  Service:   %s%s\n", statusMutedStyle.Render("- Not configured"), changes.mark("service"))
+			case "":
+				fmt.Fprintf(w, "  Service:   %s%s\n", statusMutedStyle.Render("? Unable to check"), changes.mark("service"))
+			default:
+				fmt.Fprintf(w, "  Service:   %s%s\n", statusErrorStyle.Render(fmt.Sprintf("%s %s", style.Cross(), status)), changes.mark("service"))
 			}
 
 			// Get service uptime
 			if statusData.ServiceStatus == "active" && statusData.ServiceUptime != "" {
-				fmt.Printf("  Uptime:    %s\n", statusValueStyle.Render(statusData.ServiceUptime))
+				fmt.Fprintf(w, "  Uptime:    %s\n", statusValueStyle.Render(statusData.ServiceUptime))
 			}
 
-			result = sshExecutor.Execute(fmt.Sprintf("readlink -f %s/%s/current 2>/dev/null || echo 'none'", config.RemoteAppBaseDir, appName))
-			if result.ExitCode == 0 {
-				currentRelease := strings.TrimSpace(result.Stdout)
-				if currentRelease != "none" && currentRelease != "" {
-					releaseTimestamp := strings.TrimPrefix(currentRelease, fmt.Sprintf("%s/%s/releases/", config.RemoteAppBaseDir, appName))
-					fmt.Printf("  Current:   %s\n", statusValueStyle.Render(releaseTimestamp))
+			if statusData.Process != nil {
+				process := statusData.Process
+				memory := formatMemory(process.RSSBytes) + " RSS"
+				if process.MemoryCurrentBytes > 0 {
+					memory += fmt.Sprintf(" (cgroup %s)", formatMemory(process.MemoryCurrentBytes))
+				}
+				fmt.Fprintf(w, "  Memory:    %s\n", statusValueStyle.Render(memory))
+				fmt.Fprintf(w, "  CPU:       %s\n", statusValueStyle.Render(fmt.Sprintf("%.1f%%", process.CPUPercent)))
+				fmt.Fprintf(w, "  Processes: %s\n", statusValueStyle.Render(fmt.Sprintf("%d across %s", process.Processes, strings.Join(process.Units, ", "))))
+			}
+
+			if statusData.ServiceStatus != "" {
+				if statusData.CurrentRelease != "" {
+					fmt.Fprintf(w, "  Current:   %s%s\n", statusValueStyle.Render(timefmt.ReleaseAt(statusData.CurrentRelease, releaseDeployedAt(targetState, statusData.CurrentRelease))), changes.mark("release"))
 				} else {
-					fmt.Printf("  Current:   %s\n", statusMutedStyle.Render("- No release deployed"))
+					fmt.Fprintf(w, "  Current:   %s%s\n", statusMutedStyle.Render("- No release deployed"), changes.mark("release"))
 				}
 			}
 
-			result = sshExecutor.Execute("df -h / | tail -1 | awk '{print $5}'")
-			if result.ExitCode == 0 {
-				diskUsage := strings.TrimSpace(result.Stdout)
-				fmt.Printf("  Disk:      %s\n", statusValueStyle.Render(diskUsage+" used"))
+			if statusData.DiskUsage != "" {
+				usage := statusData.DiskUsage + " used"
+				if statusData.Disk != nil && statusData.Disk.SizeBytes > 0 {
+					usage += fmt.Sprintf(" (%s of %s)", formatMemory(statusData.Disk.UsedBytes), formatMemory(statusData.Disk.SizeBytes))
+				}
+				fmt.Fprintf(w, "  Disk:      %s%s\n", statusValueStyle.Render(usage), changes.mark("disk"))
+				if statusData.Disk != nil {
+					printDiskBreakdown(w, statusData.Disk, targetName)
+				}
 			}
 
-			result = sshExecutor.Execute("uptime -p 2>/dev/null || uptime | awk '{print $3, $4}'")
-			if result.ExitCode == 0 {
-				uptime := strings.TrimSpace(result.Stdout)
-				fmt.Printf("  Server:    %s\n", statusValueStyle.Render(uptime))
+			if statusData.ServerUptime != "" {
+				fmt.Fprintf(w, "  Server:    %s\n", statusValueStyle.Render(statusData.ServerUptime))
+			}
+
+			switch statusData.Privilege {
+			case "root":
+				fmt.Fprintf(w, "  Privilege: %s\n", statusValueStyle.Render("root (commands run without sudo)"))
+			case "sudo":
+				fmt.Fprintf(w, "  Privilege: %s\n", statusValueStyle.Render("sudo"))
+			case "none":
+				fmt.Fprintf(w, "  Privilege: %s\n", statusErrorStyle.Render(style.Cross()+" not root and sudo is not installed"))
 			}
 
 			if statusData.HealthCheck != nil {
-				fmt.Printf("\n%s\n", statusHeaderStyle.Render("Health Check:"))
+				fmt.Fprintf(w, "\n%s\n", statusHeaderStyle.Render("Health Check:"))
 				if statusData.HealthCheck.Status == "healthy" {
-					fmt.Printf("  Status:    %s\n", statusSuccessStyle.Render(fmt.Sprintf("✓ Healthy (HTTP %d)", statusData.HealthCheck.HTTPCode)))
-					fmt.Printf("  Response:  %s\n", statusValueStyle.Render(fmt.Sprintf("%dms", statusData.HealthCheck.ResponseTime)))
+					fmt.Fprintf(w, "  Status:    %s%s\n", statusSuccessStyle.Render(fmt.Sprintf("%s Healthy (HTTP %d)", style.Check(), statusData.HealthCheck.HTTPCode)), changes.mark("health"))
+					fmt.Fprintf(w, "  Response:  %s\n", statusValueStyle.Render(fmt.Sprintf("%dms", statusData.HealthCheck.ResponseTime)))
 				} else {
-					fmt.Printf("  Status:    %s\n", statusErrorStyle.Render("✗ Unhealthy"))
+					fmt.Fprintf(w, "  Status:    %s%s\n", statusErrorStyle.Render(style.Cross()+" Unhealthy"), changes.mark("health"))
 					if statusData.HealthCheck.Error != "" {
-						fmt.Printf("  Error:     %s\n", statusMutedStyle.Render(statusData.HealthCheck.Error))
+						fmt.Fprintf(w, "  Error:     %s\n", statusMutedStyle.Render(statusData.HealthCheck.Error))
 					}
 				}
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
+
+		if len(statusData.Servers) > 0 {
+			printServerStatuses(w, statusData, changes)
+		}
 	}
 
-	if targetState.CreateFailed {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold deploy --target %s", targetName)))
+	if targetState.Paused {
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Resume:"), statusValueStyle.Render(fmt.Sprintf("lightfold resume --target %s", targetName)))
+	} else if targetState.CreateFailed {
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold deploy --target %s", targetName)))
 	} else if !targetState.Created {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Next:"), statusValueStyle.Render(fmt.Sprintf("lightfold create --target %s", targetName)))
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Next:"), statusValueStyle.Render(fmt.Sprintf("lightfold create --target %s", targetName)))
 	} else if targetState.ConfigureFailed {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold configure --target %s --force", targetName)))
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold configure --target %s --force", targetName)))
 	} else if !targetState.Configured {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Next:"), statusValueStyle.Render(fmt.Sprintf("lightfold configure --target %s", targetName)))
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Next:"), statusValueStyle.Render(fmt.Sprintf("lightfold configure --target %s", targetName)))
 	} else if targetState.PushFailed {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold push --target %s", targetName)))
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Retry:"), statusValueStyle.Render(fmt.Sprintf("lightfold push --target %s", targetName)))
 	} else {
-		fmt.Printf("%s %s\n", statusLabelStyle.Render("Ready to deploy:"), statusValueStyle.Render(fmt.Sprintf("lightfold push --target %s", targetName)))
+		fmt.Fprintf(w, "%s %s\n", statusLabelStyle.Render("Ready to deploy:"), statusValueStyle.Render(fmt.Sprintf("lightfold push --target %s", targetName)))
 	}
 }
 
-// collectStatusData gathers all status information for a target
-func collectStatusData(cfg *config.Config, targetName string, target config.TargetConfig, targetState *state.TargetState) StatusOutput {
+// summarizeTarget fills the status fields that come from local config and state
+func summarizeTarget(targetName string, target config.TargetConfig, targetState *state.TargetState) StatusOutput {
 	statusData := StatusOutput{
 		Target:          targetName,
 		ProjectPath:     target.ProjectPath,
 		Framework:       target.Framework,
 		Provider:        target.Provider,
+		ProviderType:    statusProviderType(target),
+		Protected:       target.Protected,
+		LastDeployBy:    targetState.LastDeployedBy(),
 		Created:         targetState.Created,
 		Configured:      targetState.Configured,
 		CreateFailed:    targetState.CreateFailed,
@@ -417,16 +637,77 @@ func collectStatusData(cfg *config.Config, targetName string, target config.Targ
 	}
 
 	if !targetState.LastDeploy.IsZero() {
-		statusData.LastDeploy = targetState.LastDeploy.Format(time.RFC3339)
+		statusData.LastDeploy = timefmt.JSON(targetState.LastDeploy)
 	}
 
 	if !targetState.LastFailure.IsZero() {
-		statusData.LastFailure = targetState.LastFailure.Format(time.RFC3339)
+		statusData.LastFailure = timefmt.JSON(targetState.LastFailure)
+	}
+
+	if targetState.LastSuperseded != nil {
+		statusData.LastSuperseded = targetState.LastSuperseded.Release
+	}
+
+	if targetState.LastDeployHealth != nil {
+		health := *targetState.LastDeployHealth
+		health.CheckedAt = health.CheckedAt.UTC()
+		statusData.DeployHealth = &health
+	}
+
+	if targetState.Paused {
+		statusData.Paused = true
+		statusData.PausedAt = timefmt.JSON(targetState.PausedAt)
+	}
+
+	if targetState.Maintenance != nil {
+		maintenance := *targetState.Maintenance
+		maintenance.Since = maintenance.Since.UTC()
+		statusData.Maintenance = &maintenance
+	}
+
+	if target.Schedule != nil {
+		statusData.Schedule = scheduleStatus(target.Schedule, time.Now())
+	}
+
+	if target.Domain != nil && target.Domain.Domain != "" && target.Domain.PathPrefix == "" {
+		statusData.Domain = target.Domain.Domain
+		serverID, serverIP := domainServerIdentity(target, targetState)
+		if targetState.DomainDrifted(serverID, serverIP) {
+			statusData.DomainDrift = fmt.Sprintf("domain config was applied to %s, but the server is now %s", targetState.DomainServerIP, serverIP)
+		}
+		statusData.SSLRenewalRisk = sslRenewalRisk(targetState.SSLRenewal, time.Now())
 	}
 
+	// fly.io apps are reached by hostname; a shared or missing IPv4 says nothing
+	if providerCfg, err := target.GetAnyProviderConfig(); err == nil && providerCfg != nil && target.Provider != "flyio" {
+		statusData.ServerIP = providerCfg.GetIP()
+		statusData.ServerIPv6 = extraIPv6(providerCfg)
+	}
+
+	return statusData
+}
+
+// scheduleStatus summarizes a power schedule with its next stop or start
+func scheduleStatus(schedule *config.PowerSchedule, now time.Time) *ScheduleStatus {
+	status := &ScheduleStatus{Stop: schedule.Stop, Start: schedule.Start, Timezone: schedule.Timezone}
+	if action, at, err := schedule.NextEvent(now); err == nil && action != "" {
+		status.NextAction = action
+		status.NextAt = timefmt.JSON(at)
+	}
+	return status
+}
+
+// collectStatusData gathers all status information for a target
+func collectStatusData(cfg *config.Config, targetName string, target config.TargetConfig, targetState *state.TargetState) StatusOutput {
+	statusData := summarizeTarget(targetName, target, targetState)
+
 	if target.Provider == "s3" {
 		return statusData
 	}
+	if target.Provider == "flyio" {
+		collectFlyioStatus(&statusData, target, targetState, true)
+		return statusData
+	}
 
 	providerCfg, err := target.GetSSHProviderConfig()
 	if err != nil {
@@ -434,51 +715,54 @@ func collectStatusData(cfg *config.Config, targetName string, target config.Targ
 	}
 
 	statusData.ServerIP = providerCfg.GetIP()
+	statusData.ServerIPv6 = extraIPv6(providerCfg)
+	if targetState.Paused {
+		return statusData
+	}
 
-	if providerCfg.GetIP() == "" {
+	if len(target.Servers) > 0 && !statusNoRemoteFlag {
+		statusData.Servers = collectServerStatuses(target, targetName)
+		if target.LoadBalancer != nil {
+			statusData.LoadBalancerIP = target.LoadBalancer.IP
+		}
+	}
+
+	if providerCfg.GetIP() == "" || statusNoRemoteFlag {
 		return statusData
 	}
 
-	sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	if err := sshExecutor.Connect(1, 2*time.Second); err != nil {
+		statusData.RemoteError = err.Error()
+		return statusData
+	}
 	defer sshExecutor.Disconnect()
 
-	appName := strings.ReplaceAll(targetName, "-", "_")
+	appName := resolveAppName(&target, targetName, sshExecutor)
 
-	result := sshExecutor.Execute(fmt.Sprintf("systemctl is-active %s 2>/dev/null || echo 'not-found'", appName))
-	if result.ExitCode == 0 {
-		statusData.ServiceStatus = strings.TrimSpace(result.Stdout)
+	isCompose := deploy.IsComposeFramework(target.Framework)
+	if probe, err := probeStatus(sshExecutor, appName, target.RemoteBaseDir()); err == nil {
+		probe.apply(&statusData, isCompose)
 	}
-
-	if statusData.ServiceStatus == "active" {
-		result = sshExecutor.Execute(fmt.Sprintf("systemctl show -p ActiveEnterTimestamp %s 2>/dev/null | cut -d= -f2", appName))
-		if result.ExitCode == 0 && result.Stdout != "" {
-			timestampStr := strings.TrimSpace(result.Stdout)
-			if timestampStr != "" && timestampStr != "n/a" {
-				activeTime, err := time.Parse("Mon 2006-01-02 15:04:05 MST", timestampStr)
-				if err == nil {
-					uptime := time.Since(activeTime)
-					statusData.ServiceUptime = formatUptime(uptime)
-				}
-			}
-		}
+	if isCompose {
+		statusData.ServiceStatus = composeServiceStatus(sshExecutor, appName)
 	}
 
-	result = sshExecutor.Execute(fmt.Sprintf("readlink -f %s/%s/current 2>/dev/null || echo 'none'", config.RemoteAppBaseDir, appName))
-	if result.ExitCode == 0 {
-		currentRelease := strings.TrimSpace(result.Stdout)
-		if currentRelease != "none" && currentRelease != "" {
-			statusData.CurrentRelease = strings.TrimPrefix(currentRelease, fmt.Sprintf("%s/%s/releases/", config.RemoteAppBaseDir, appName))
-		}
+	if privilege := sshExecutor.Privilege(); privilege != sshpkg.PrivilegeUnknown {
+		statusData.Privilege = privilege.String()
 	}
 
-	result = sshExecutor.Execute("df -h / | tail -1 | awk '{print $5}'")
-	if result.ExitCode == 0 {
-		statusData.DiskUsage = strings.TrimSpace(result.Stdout)
+	if statusMetricsFlag && statusData.ServiceStatus == "active" {
+		statusData.Process = collectProcessMetrics(sshExecutor, appName)
 	}
 
-	result = sshExecutor.Execute("uptime -p 2>/dev/null || uptime | awk '{print $3, $4}'")
-	if result.ExitCode == 0 {
-		statusData.ServerUptime = strings.TrimSpace(result.Stdout)
+	// du walks every release, so watch mode only breaks the disk down on request
+	nearlyFull := !statusWatchFlag && diskUsagePercent(statusData.DiskUsage) >= diskBreakdownThreshold
+	if statusDiskFlag || nearlyFull {
+		serverTargets := cfg.GetTargetsByServerIP(providerCfg.GetIP())
+		if breakdown, err := collectDiskBreakdown(sshExecutor, releaseKeepFor(cfg, serverTargets), serverBaseDirs(serverTargets)); err == nil {
+			statusData.Disk = breakdown
+		}
 	}
 
 	if statusData.ServiceStatus == "active" {
@@ -489,6 +773,55 @@ func collectStatusData(cfg *config.Config, targetName string, target config.Targ
 	return statusData
 }
 
+// printServerStatuses shows one line per server and flags servers out of lockstep
+func printServerStatuses(w io.Writer, statusData StatusOutput, changes statusChanges) {
+	fmt.Fprintf(w, "%s%s\n", statusHeaderStyle.Render("Servers:"), changes.mark("servers"))
+	if statusData.LoadBalancerIP != "" {
+		fmt.Fprintf(w, "  Load balancer: %s\n", statusValueStyle.Render(statusData.LoadBalancerIP))
+	}
+
+	releases := make(map[string]bool)
+	for _, server := range statusData.Servers {
+		if server.Error != "" {
+			fmt.Fprintf(w, "  %-16s %s\n", server.IP, statusErrorStyle.Render(style.Cross()+" "+server.Error))
+			continue
+		}
+
+		service := statusErrorStyle.Render(style.Cross() + " " + server.ServiceStatus)
+		if server.ServiceStatus == "active" {
+			service = statusSuccessStyle.Render(style.Check() + " active")
+		}
+		release := server.CurrentRelease
+		if release == "" {
+			release = "-"
+		}
+		releases[release] = true
+		fmt.Fprintf(w, "  %-16s %s  %s\n", server.IP, service, statusValueStyle.Render(release))
+	}
+
+	if len(releases) > 1 {
+		fmt.Fprintf(w, "  %s\n", statusErrorStyle.Render("Servers are on different releases; run lightfold push or lightfold rollback to realign"))
+	}
+	fmt.Fprintln(w)
+}
+
+// composeServiceStatus maps the state of a compose project's containers to systemd-style status
+func composeServiceStatus(runner sshpkg.SudoRunner, appName string) string {
+	result := runner.ExecuteSudo(deploy.ComposeCommand(appName, "", "", "ps --status running -q"))
+	if result.ExitCode != 0 {
+		return ""
+	}
+	if strings.TrimSpace(result.Stdout) != "" {
+		return "active"
+	}
+
+	result = runner.ExecuteSudo(deploy.ComposeCommand(appName, "", "", "ps -a -q"))
+	if result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != "" {
+		return "inactive"
+	}
+	return "not-found"
+}
+
 // performHealthCheck performs an HTTP health check and returns the status
 func performHealthCheck(sshExecutor *sshpkg.Executor) HealthCheckStatus {
 	healthCheck := HealthCheckStatus{
@@ -546,4 +879,9 @@ func init() {
 
 	statusCmd.Flags().StringVar(&statusTargetFlag, "target", "", "Target name (optional - shows all targets if omitted)")
 	statusCmd.Flags().BoolVar(&statusJSONFlag, "json", false, "Output status in JSON format")
+	statusCmd.Flags().BoolVar(&statusMetricsFlag, "metrics", false, "Include memory/CPU usage of the app process")
+	statusCmd.Flags().BoolVar(&statusDiskFlag, "disk", false, "Break down the server's disk use and suggest cleanups (shown anyway from 90% used)")
+	statusCmd.Flags().BoolVar(&statusNoRemoteFlag, "no-remote", false, "Show local state only, without connecting to servers")
+	statusCmd.Flags().BoolVar(&statusWatchFlag, "watch", false, "Refresh status periodically until interrupted")
+	statusCmd.Flags().DurationVar(&statusInterval, "interval", 5*time.Second, "Refresh interval for --watch")
 }
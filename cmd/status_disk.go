@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// diskBreakdownThreshold is the root filesystem use, in percent, from
+	// which status shows the disk breakdown without --disk
+	diskBreakdownThreshold = 90
+
+	// diskReclaimMinBytes is the smallest cache worth suggesting a cleanup for
+	diskReclaimMinBytes = 50 * 1024 * 1024
+
+	// journalVacuumSize is what journalctl --vacuum-size trims the journal to
+	journalVacuumSize  = "100M"
+	journalVacuumBytes = 100 * 1024 * 1024
+
+	// diskTopConsumers bounds how many consumers status lists
+	diskTopConsumers = 8
+)
+
+// DiskBreakdown is what takes up the root filesystem of a server
+type DiskBreakdown struct {
+	SizeBytes int64           `json:"size_bytes"`
+	UsedBytes int64           `json:"used_bytes"`
+	Items     []DiskUsageItem `json:"items"` // Largest first
+}
+
+// DiskUsageItem is one consumer of disk space on a server
+type DiskUsageItem struct {
+	Kind     string `json:"kind"` // releases, static, media, journal, apt_cache or docker
+	App      string `json:"app,omitempty"`
+	Bytes    int64  `json:"bytes"`
+	Releases int    `json:"releases,omitempty"` // Releases kept in the app's releases directory
+	Reclaim  string `json:"reclaim,omitempty"`  // How the space can be freed; empty for app data
+	BaseDir  string `json:"base_dir,omitempty"` // Directory the app is deployed under
+}
+
+// Label names the item, e.g. "myapp releases (7)"
+func (i DiskUsageItem) Label() string {
+	switch i.Kind {
+	case "releases":
+		return fmt.Sprintf("%s releases (%d)", i.App, i.Releases)
+	case "static", "media":
+		return fmt.Sprintf("%s shared/%s", i.App, i.Kind)
+	case "apt_cache":
+		return "apt cache"
+	}
+	return i.Kind
+}
+
+// diskUsageScript returns one shell command printing "<kind> <app> <bytes>
+// [releases]" lines for every app's releases and shared static and media
+// directories under each of baseDirs, which a "base <dir>" line precedes, then
+// the journal, the apt cache and docker's data. A "disk <size> <used>" line
+// for the root filesystem comes first.
+func diskUsageScript(baseDirs []string) string {
+	lines := []string{"echo \"disk $(df -B1 --output=size,used / | tail -1)\""}
+	for _, base := range baseDirs {
+		lines = append(lines, fmt.Sprintf("echo \"base %[1]s\"; for d in %[1]s/*/releases; do [ -d $d ] || continue; a=$(basename $(dirname $d)); echo \"releases $a $(du -sb $d | cut -f1) $(ls -1 $d | wc -l)\"; for s in static media; do if [ -d %[1]s/$a/shared/$s ]; then echo \"$s $a $(du -sb %[1]s/$a/shared/$s | cut -f1)\"; fi; done; done", base))
+	}
+	return "sh -c '" + strings.Join(append(lines,
+		"if [ -d /var/log/journal ]; then echo \"journal - $(du -sb /var/log/journal | cut -f1)\"; fi",
+		"if [ -d /var/cache/apt ]; then echo \"apt_cache - $(du -sb /var/cache/apt | cut -f1)\"; fi",
+		"if command -v docker >/dev/null && [ -d /var/lib/docker ]; then echo \"docker - $(du -sb /var/lib/docker | cut -f1)\"; fi",
+		"true",
+	), "; ") + "'"
+}
+
+// releaseKeep returns how many releases a deploy keeps for an app
+type releaseKeep func(app string) int
+
+// releaseKeepFor maps the server apps of targets to their keep count; apps no
+// target claims keep the global keep_releases
+func releaseKeepFor(cfg *config.Config, targets map[string]config.TargetConfig) releaseKeep {
+	counts := make(map[string]int, len(targets))
+	for name, target := range targets {
+		counts[utils.RemoteAppName(&target, name)] = cfg.KeepReleasesFor(target)
+	}
+	return func(app string) int {
+		if count, ok := counts[app]; ok {
+			return count
+		}
+		return cfg.NumReleases
+	}
+}
+
+// collectDiskBreakdown runs diskUsageScript on the server for the apps under
+// baseDirs. keepReleases is how many releases a deploy keeps of each app,
+// used to suggest pruning.
+func collectDiskBreakdown(server sshpkg.SudoRunner, keepReleases releaseKeep, baseDirs []string) (*DiskBreakdown, error) {
+	result := server.ExecuteSudo(diskUsageScript(baseDirs))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("disk usage check failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return parseDiskBreakdown(result.Stdout, keepReleases), nil
+}
+
+// parseDiskBreakdown reads the output of diskUsageScript, orders the items
+// largest first and fills in how each can be reclaimed
+func parseDiskBreakdown(output string, keepReleases releaseKeep) *DiskBreakdown {
+	breakdown := &DiskBreakdown{}
+	var baseDir string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "base" {
+			baseDir = fields[1]
+			continue
+		}
+		if len(fields) < 3 {
+			continue
+		}
+		if fields[0] == "disk" {
+			breakdown.SizeBytes, _ = strconv.ParseInt(fields[1], 10, 64)
+			breakdown.UsedBytes, _ = strconv.ParseInt(fields[2], 10, 64)
+			continue
+		}
+
+		bytes, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		item := DiskUsageItem{Kind: fields[0], Bytes: bytes}
+		if fields[1] != "-" {
+			// App names come from the server and end up in rm -rf paths
+			if !cleanupNamePattern.MatchString(fields[1]) {
+				continue
+			}
+			item.App = fields[1]
+			item.BaseDir = baseDir
+		}
+		if item.Kind == "releases" && len(fields) > 3 {
+			item.Releases, _ = strconv.Atoi(fields[3])
+		}
+		item.Reclaim = diskReclaim(item, keepReleases(item.App))
+		breakdown.Items = append(breakdown.Items, item)
+	}
+
+	sort.SliceStable(breakdown.Items, func(i, j int) bool {
+		return breakdown.Items[i].Bytes > breakdown.Items[j].Bytes
+	})
+	return breakdown
+}
+
+// diskReclaim describes how the item's space can be freed, or "" when there
+// is nothing lightfold can safely remove. Shared static and media files are
+// app data and never suggested.
+func diskReclaim(item DiskUsageItem, keepReleases int) string {
+	switch item.Kind {
+	case "releases":
+		// The current release and the one before it are always kept
+		if item.Releases > max(keepReleases, 2) {
+			return fmt.Sprintf("prune to the newest %d releases", max(keepReleases, 2))
+		}
+	case "journal":
+		if item.Bytes > journalVacuumBytes {
+			return "journalctl --vacuum-size=" + journalVacuumSize
+		}
+	case "apt_cache":
+		if item.Bytes >= diskReclaimMinBytes {
+			return "apt-get clean"
+		}
+	case "docker":
+		if item.Bytes >= diskReclaimMinBytes {
+			return "docker system prune -f"
+		}
+	}
+	return ""
+}
+
+// Reclaimable returns the items that have a cleanup, largest first
+func (b *DiskBreakdown) Reclaimable() []DiskUsageItem {
+	var items []DiskUsageItem
+	for _, item := range b.Items {
+		if item.Reclaim != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// diskUsagePercent reads the percentage from the status probe's disk use,
+// e.g. "92%", returning -1 when it is not a number
+func diskUsagePercent(usage string) int {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(usage), "%"))
+	if err != nil {
+		return -1
+	}
+	return percent
+}
+
+// printDiskBreakdown lists the largest consumers under the Disk line of the
+// detail view, with the cleanup for each
+func printDiskBreakdown(w io.Writer, breakdown *DiskBreakdown, targetName string) {
+	if len(breakdown.Items) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "  Largest:\n")
+	for i, item := range breakdown.Items {
+		if i == diskTopConsumers {
+			break
+		}
+		line := fmt.Sprintf("    %-28s %10s", item.Label(), formatMemory(item.Bytes))
+		if item.Reclaim != "" {
+			line += "  " + statusMutedStyle.Render(style.Arrow()+" "+item.Reclaim)
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(breakdown.Reclaimable()) > 0 {
+		fmt.Fprintf(w, "  %s\n", statusMutedStyle.Render(fmt.Sprintf("Run 'lightfold server reclaim --target %s' to reclaim space", targetName)))
+	}
+}
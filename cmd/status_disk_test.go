@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"lightfold/pkg/config"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskBreakdown(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	output := strings.Join([]string{
+		"disk 42949672960 39728447488",
+		"releases web 13207024435 7",
+		"media web 2147483648",
+		"releases api 524288000 3",
+		"static api 10485760",
+		"journal - 3328599654",
+		"apt_cache - 20971520",
+		"docker - 5368709120",
+		"releases bad;name 1 9",
+		"",
+	}, "\n")
+
+	cfg := &config.Config{NumReleases: 5}
+	keep := releaseKeepFor(cfg, map[string]config.TargetConfig{
+		"api": {Deploy: &config.DeploymentOptions{KeepReleases: 1}},
+	})
+	breakdown := parseDiskBreakdown(output, keep)
+	if breakdown.SizeBytes != 40*gib || breakdown.UsedBytes != 39728447488 {
+		t.Errorf("disk = %d of %d", breakdown.UsedBytes, breakdown.SizeBytes)
+	}
+
+	var labels []string
+	for _, item := range breakdown.Items {
+		labels = append(labels, item.Label())
+	}
+	want := []string{"web releases (7)", "docker", "journal", "web shared/media", "api releases (3)", "apt cache", "api shared/static"}
+	if strings.Join(labels, ", ") != strings.Join(want, ", ") {
+		t.Errorf("items = %v, want largest first %v", labels, want)
+	}
+
+	reclaims := map[string]string{}
+	for _, item := range breakdown.Reclaimable() {
+		reclaims[item.Label()] = item.Reclaim
+	}
+	wantReclaims := map[string]string{
+		"web releases (7)": "prune to the newest 5 releases",
+		"api releases (3)": "prune to the newest 2 releases",
+		"docker":           "docker system prune -f",
+		"journal":          "journalctl --vacuum-size=100M",
+	}
+	if len(reclaims) != len(wantReclaims) {
+		t.Errorf("reclaimable = %v, want %v", reclaims, wantReclaims)
+	}
+	for label, reclaim := range wantReclaims {
+		if reclaims[label] != reclaim {
+			t.Errorf("%s reclaim = %q, want %q", label, reclaims[label], reclaim)
+		}
+	}
+}
+
+func TestDiskReclaimKeepsTwoReleases(t *testing.T) {
+	if got := diskReclaim(DiskUsageItem{Kind: "releases", Releases: 2}, 0); got != "" {
+		t.Errorf("diskReclaim() with two releases = %q, want none", got)
+	}
+	if got := diskReclaim(DiskUsageItem{Kind: "releases", Releases: 3}, 1); got != "prune to the newest 2 releases" {
+		t.Errorf("diskReclaim() = %q", got)
+	}
+}
+
+func TestDiskUsagePercent(t *testing.T) {
+	for usage, want := range map[string]int{"92%": 92, " 7% ": 7, "": -1, "n/a": -1} {
+		if got := diskUsagePercent(usage); got != want {
+			t.Errorf("diskUsagePercent(%q) = %d, want %d", usage, got, want)
+		}
+	}
+}
+
+func TestParseCleanSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{input: "\n"},
+		{input: "all\n", want: []int{0, 1, 2}},
+		{input: "1,3\n", want: []int{0, 2}},
+		{input: "3 1, 3", want: []int{2, 0}},
+		{input: "4", wantErr: true},
+		{input: "0", wantErr: true},
+		{input: "one", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseReclaimSelection(tt.input, 3)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReclaimSelection(%q) error = %v", tt.input, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseReclaimSelection(%q) = %v, want %v", tt.input, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseReclaimSelection(%q) = %v, want %v", tt.input, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/providers/flyio"
+	"lightfold/pkg/state"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// flyStatusClient is the part of the fly.io client status uses
+type flyStatusClient interface {
+	AppStatus(ctx context.Context, appName string) (*flyio.AppStatus, error)
+}
+
+var (
+	// newFlyStatusClient builds the fly.io client; replaced in tests
+	newFlyStatusClient = func(token string) flyStatusClient { return flyio.NewClient(token) }
+
+	// flyHealthClient requests the health path on the app's public hostname
+	flyHealthClient = &http.Client{Timeout: 10 * time.Second}
+
+	flyStatusTimeout = 15 * time.Second
+)
+
+// statusProviderType tells the JSON consumers which shape a target's status
+// has: "ssh" for servers, "flyio" for fly.io apps, "s3" for buckets
+func statusProviderType(target config.TargetConfig) string {
+	switch target.Provider {
+	case "flyio", "s3":
+		return target.Provider
+	}
+	return "ssh"
+}
+
+// collectFlyioStatus reads the target's fly.io app from the machines API.
+// Without a token or when the API cannot be reached it leaves the local
+// state with a note in RemoteError. withHealth also requests the health
+// path on the app's hostname.
+func collectFlyioStatus(statusData *StatusOutput, target config.TargetConfig, targetState *state.TargetState, withHealth bool) {
+	if !targetState.Created || statusNoRemoteFlag {
+		return
+	}
+
+	flyioConfig, err := target.GetFlyioConfig()
+	if err != nil || flyioConfig.AppName == "" {
+		statusData.RemoteError = "no fly.io app recorded for this target"
+		return
+	}
+	tokens, err := config.LoadTokens()
+	if err != nil || tokens.GetToken("flyio") == "" {
+		statusData.RemoteError = "fly.io API token not found (run 'lightfold config set-token flyio')"
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flyStatusTimeout)
+	defer cancel()
+	app, err := newFlyStatusClient(tokens.GetToken("flyio")).AppStatus(ctx, flyioConfig.AppName)
+	if err != nil {
+		statusData.RemoteError = "fly.io API unreachable: " + err.Error()
+		return
+	}
+
+	statusData.Flyio = app
+	statusData.ServiceStatus = flyServiceStatus(app)
+	if app.Release > 0 {
+		statusData.CurrentRelease = fmt.Sprintf("v%d", app.Release)
+	}
+	if withHealth && app.Started() {
+		healthCheck := flyioHealthCheck("https://" + app.Hostname + flyHealthPath(target))
+		statusData.HealthCheck = &healthCheck
+	}
+}
+
+// flyServiceStatus maps the app's machines to the systemd-style service
+// states the summary view shows
+func flyServiceStatus(app *flyio.AppStatus) string {
+	switch {
+	case len(app.Machines) == 0:
+		return "not-found"
+	case app.Started():
+		return "active"
+	}
+	return app.Machines[0].State
+}
+
+// flyHealthPath is the detected health check path, "/" by default
+func flyHealthPath(target config.TargetConfig) string {
+	detection := detector.DetectAppAs(target.ProjectPath, target.AppSubdir(), target.FrameworkOverride)
+	if p, ok := detection.Healthcheck["path"].(string); ok && p != "" {
+		return path.Join("/", p)
+	}
+	return "/"
+}
+
+// flyioHealthCheck requests url over the public internet, the only way to
+// reach a fly.io app without SSH
+func flyioHealthCheck(url string) HealthCheckStatus {
+	healthCheck := HealthCheckStatus{Status: "unhealthy"}
+
+	startTime := time.Now()
+	resp, err := flyHealthClient.Get(url)
+	if err != nil {
+		healthCheck.Error = err.Error()
+		return healthCheck
+	}
+	resp.Body.Close()
+
+	healthCheck.HTTPCode = resp.StatusCode
+	healthCheck.ResponseTime = time.Since(startTime).Milliseconds()
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		healthCheck.Status = "healthy"
+	} else {
+		healthCheck.Error = fmt.Sprintf("%s answered %d", url, resp.StatusCode)
+	}
+	return healthCheck
+}
+
+// printFlyioStatus shows the app's machines, release and health in place of
+// the server section, which does not apply to fly.io
+func printFlyioStatus(w io.Writer, target config.TargetConfig, statusData StatusOutput, changes statusChanges) {
+	fmt.Fprintf(w, "%s\n", statusHeaderStyle.Render("fly.io App:"))
+
+	app := statusData.Flyio
+	if app == nil {
+		if flyioConfig, err := target.GetFlyioConfig(); err == nil && flyioConfig.AppName != "" {
+			fmt.Fprintf(w, "  App:       %s\n", statusValueStyle.Render(flyioConfig.AppName))
+		}
+		switch {
+		case statusNoRemoteFlag:
+			fmt.Fprintf(w, "  Machines:  %s\n", statusMutedStyle.Render("- Not checked (--no-remote)"))
+		case statusData.RemoteError != "":
+			fmt.Fprintf(w, "  %s\n", statusWarningStyle.Render(style.Warn()+" "+statusData.RemoteError))
+			fmt.Fprintf(w, "  %s\n", statusMutedStyle.Render("Showing local state only"))
+		}
+		fmt.Fprintln(w)
+		return
+	}
+
+	fmt.Fprintf(w, "  App:       %s\n", statusValueStyle.Render(app.App))
+	fmt.Fprintf(w, "  Hostname:  %s\n", statusValueStyle.Render(app.Hostname))
+	if app.Release > 0 {
+		release := fmt.Sprintf("v%d", app.Release)
+		if app.LastDeploy != "" {
+			release += ", " + formatStatusTime(app.LastDeploy)
+		}
+		fmt.Fprintf(w, "  Release:   %s%s\n", statusValueStyle.Render(release), changes.mark("release"))
+	}
+	if app.Image != "" {
+		fmt.Fprintf(w, "  Image:     %s\n", statusValueStyle.Render(app.Image))
+	}
+
+	if len(app.Machines) == 0 {
+		fmt.Fprintf(w, "  Machines:  %s%s\n", statusMutedStyle.Render("- None (deploy with lightfold push)"), changes.mark("service"))
+	} else {
+		fmt.Fprintf(w, "  Machines:%s\n", changes.mark("service"))
+	}
+	for _, machine := range app.Machines {
+		machineState := statusErrorStyle.Render(style.Cross() + " " + machine.State)
+		if machine.State == "started" {
+			machineState = statusSuccessStyle.Render(style.Check() + " started")
+		}
+		line := fmt.Sprintf("    %-16s %s  %s", machine.ID, statusValueStyle.Render(machine.Region), machineState)
+		if machine.Size != "" {
+			line += "  " + statusMutedStyle.Render(machine.Size)
+		}
+		fmt.Fprintln(w, line)
+		for _, check := range machine.Checks {
+			checkLine := fmt.Sprintf("%s %s", check.Name, check.Status)
+			if check.Status != "passing" && check.Output != "" {
+				checkLine += ": " + strings.TrimSpace(check.Output)
+			}
+			checkStyle := statusErrorStyle
+			switch check.Status {
+			case "passing":
+				checkStyle = statusMutedStyle
+			case "warning":
+				checkStyle = statusWarningStyle
+			}
+			fmt.Fprintf(w, "      %s\n", checkStyle.Render(checkLine))
+		}
+	}
+
+	if statusData.HealthCheck != nil {
+		fmt.Fprintf(w, "\n%s\n", statusHeaderStyle.Render("Health Check:"))
+		if statusData.HealthCheck.Status == "healthy" {
+			fmt.Fprintf(w, "  Status:    %s%s\n", statusSuccessStyle.Render(fmt.Sprintf("%s Healthy (HTTP %d)", style.Check(), statusData.HealthCheck.HTTPCode)), changes.mark("health"))
+			fmt.Fprintf(w, "  Response:  %s\n", statusValueStyle.Render(fmt.Sprintf("%dms", statusData.HealthCheck.ResponseTime)))
+		} else {
+			fmt.Fprintf(w, "  Status:    %s%s\n", statusErrorStyle.Render(style.Cross()+" Unhealthy"), changes.mark("health"))
+			if statusData.HealthCheck.Error != "" {
+				fmt.Fprintf(w, "  Error:     %s\n", statusMutedStyle.Render(statusData.HealthCheck.Error))
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
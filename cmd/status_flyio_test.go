@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers/flyio"
+	"lightfold/pkg/state"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeFlyStatus returns a fixed app status or error
+type fakeFlyStatus struct {
+	app *flyio.AppStatus
+	err error
+}
+
+func (f *fakeFlyStatus) AppStatus(ctx context.Context, appName string) (*flyio.AppStatus, error) {
+	return f.app, f.err
+}
+
+func setupFlyioStatusTest(t *testing.T, fake *fakeFlyStatus, withToken bool) config.TargetConfig {
+	t.Helper()
+
+	originalClient, originalHealthClient := newFlyStatusClient, flyHealthClient
+	newFlyStatusClient = func(token string) flyStatusClient { return fake }
+	t.Cleanup(func() {
+		newFlyStatusClient, flyHealthClient = originalClient, originalHealthClient
+	})
+
+	if withToken {
+		tokens, err := config.LoadTokens()
+		if err != nil {
+			t.Fatalf("LoadTokens() error: %v", err)
+		}
+		tokens.SetToken("flyio", "fly-token")
+		if err := tokens.SaveTokens(); err != nil {
+			t.Fatalf("SaveTokens() error: %v", err)
+		}
+	}
+
+	target := config.TargetConfig{ProjectPath: t.TempDir(), Framework: "Next.js", Provider: "flyio"}
+	target.SetProviderConfig("flyio", &config.FlyioConfig{AppName: "myapp-123", Provisioned: true})
+	return target
+}
+
+func TestCollectFlyioStatus(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	fake := &fakeFlyStatus{app: &flyio.AppStatus{
+		App:      "myapp-123",
+		Hostname: strings.TrimPrefix(server.URL, "https://"),
+		Release:  7,
+		Machines: []flyio.MachineStatus{{ID: "148ed599c14189", State: "started", Region: "iad", Size: "shared-cpu-1x, 256MB"}},
+	}}
+	target := setupFlyioStatusTest(t, fake, true)
+	flyHealthClient = server.Client()
+
+	statusData := summarizeTarget("myapp", target, &state.TargetState{Created: true})
+	collectFlyioStatus(&statusData, target, &state.TargetState{Created: true}, true)
+
+	if statusData.ProviderType != "flyio" {
+		t.Errorf("ProviderType = %q, want flyio", statusData.ProviderType)
+	}
+	if statusData.RemoteError != "" {
+		t.Fatalf("Unexpected RemoteError: %s", statusData.RemoteError)
+	}
+	if statusData.Flyio == nil || statusData.ServiceStatus != "active" || statusData.CurrentRelease != "v7" {
+		t.Errorf("Expected the app status to be recorded, got %+v", statusData)
+	}
+	if statusData.HealthCheck == nil || statusData.HealthCheck.Status != "healthy" {
+		t.Errorf("Expected a healthy health check, got %+v", statusData.HealthCheck)
+	}
+}
+
+func TestCollectFlyioStatus_DegradesToLocalState(t *testing.T) {
+	tests := []struct {
+		name      string
+		fake      *fakeFlyStatus
+		withToken bool
+		want      string
+	}{
+		{name: "no token", fake: &fakeFlyStatus{}, want: "token not found"},
+		{name: "api error", fake: &fakeFlyStatus{err: errors.New("connection refused")}, withToken: true, want: "fly.io API unreachable: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupTestEnv(t)
+			defer cleanup()
+
+			target := setupFlyioStatusTest(t, tt.fake, tt.withToken)
+			statusData := summarizeTarget("myapp", target, &state.TargetState{Created: true})
+			collectFlyioStatus(&statusData, target, &state.TargetState{Created: true}, true)
+
+			if !strings.Contains(statusData.RemoteError, tt.want) {
+				t.Errorf("RemoteError = %q, want it to contain %q", statusData.RemoteError, tt.want)
+			}
+			if statusData.Flyio != nil || statusData.HealthCheck != nil {
+				t.Errorf("Expected local state only, got %+v", statusData)
+			}
+
+			var buf bytes.Buffer
+			printFlyioStatus(&buf, target, statusData, nil)
+			if out := buf.String(); !strings.Contains(out, "myapp-123") || !strings.Contains(out, "Showing local state only") {
+				t.Errorf("Expected the app name and a local state note, got:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestFlyioHealthCheck_Unhealthy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	originalHealthClient := flyHealthClient
+	flyHealthClient = server.Client()
+	defer func() { flyHealthClient = originalHealthClient }()
+
+	healthCheck := flyioHealthCheck(server.URL + "/health")
+	if healthCheck.Status != "unhealthy" || healthCheck.HTTPCode != http.StatusBadGateway {
+		t.Errorf("Expected an unhealthy 502, got %+v", healthCheck)
+	}
+}
+
+func TestPrintFlyioStatus(t *testing.T) {
+	statusData := StatusOutput{
+		Flyio: &flyio.AppStatus{
+			App:        "myapp-123",
+			Hostname:   "myapp-123.fly.dev",
+			Release:    7,
+			LastDeploy: "2026-03-01T12:00:00Z",
+			Image:      "registry.fly.io/myapp-123:deployment-01",
+			Machines: []flyio.MachineStatus{
+				{ID: "148ed599c14189", State: "started", Region: "iad", Size: "shared-cpu-1x, 256MB",
+					Checks: []flyio.CheckStatus{{Name: "servicecheck-00-http-3000", Status: "critical", Output: "connection refused\n"}}},
+				{ID: "3d8d9e5c2e4589", State: "stopped", Region: "lhr"},
+			},
+		},
+		HealthCheck: &HealthCheckStatus{Status: "healthy", HTTPCode: 200, ResponseTime: 42},
+	}
+
+	var buf bytes.Buffer
+	printFlyioStatus(&buf, config.TargetConfig{Provider: "flyio"}, statusData, statusChanges{"release": true})
+	out := buf.String()
+
+	for _, want := range []string{"myapp-123.fly.dev", "v7", "registry.fly.io/myapp-123:deployment-01", "148ed599c14189", "shared-cpu-1x, 256MB", "servicecheck-00-http-3000 critical: connection refused", "stopped", "Healthy (HTTP 200)", "changed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestStatusProviderType(t *testing.T) {
+	for provider, want := range map[string]string{"flyio": "flyio", "s3": "s3", "hetzner": "ssh", "byos": "ssh"} {
+		if got := statusProviderType(config.TargetConfig{Provider: provider}); got != want {
+			t.Errorf("statusProviderType(%q) = %q, want %q", provider, got, want)
+		}
+	}
+}
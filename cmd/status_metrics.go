@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	sshpkg "lightfold/pkg/ssh"
+	"strconv"
+	"strings"
+)
+
+// ProcessMetrics represents resource usage of the app's systemd units
+type ProcessMetrics struct {
+	Units              []string `json:"units"`
+	MainPIDs           []int    `json:"main_pids"`
+	Processes          int      `json:"processes"`
+	RSSBytes           int64    `json:"rss_bytes"`
+	CPUPercent         float64  `json:"cpu_percent"`
+	MemoryCurrentBytes int64    `json:"memory_current_bytes,omitempty"`
+}
+
+// unitProperties holds the systemctl show values used for metrics
+type unitProperties struct {
+	MainPID       int
+	MemoryCurrent int64
+}
+
+// collectProcessMetrics gathers PID, RSS/CPU and cgroup memory for the app service
+// and any companion units (e.g. myapp-worker.service). Returns nil when nothing is running.
+func collectProcessMetrics(sshExecutor *sshpkg.Executor, appName string) *ProcessMetrics {
+	units := []string{appName}
+
+	result := sshExecutor.Execute(fmt.Sprintf("systemctl list-units --type=service --state=active --no-legend --plain '%s-*.service' 2>/dev/null | awk '{print $1}'", appName))
+	if result.ExitCode == 0 {
+		for _, line := range strings.Split(result.Stdout, "\n") {
+			unit := strings.TrimSuffix(strings.TrimSpace(line), ".service")
+			if unit != "" {
+				units = append(units, unit)
+			}
+		}
+	}
+
+	metrics := &ProcessMetrics{}
+	for _, unit := range units {
+		result := sshExecutor.Execute(fmt.Sprintf("systemctl show -p MainPID -p MemoryCurrent %s 2>/dev/null", unit))
+		if result.ExitCode != 0 {
+			continue
+		}
+
+		props := parseUnitProperties(result.Stdout)
+		if props.MainPID == 0 {
+			continue
+		}
+
+		metrics.Units = append(metrics.Units, unit)
+		metrics.MainPIDs = append(metrics.MainPIDs, props.MainPID)
+		metrics.MemoryCurrentBytes += props.MemoryCurrent
+
+		// Include direct children so pre-fork servers (gunicorn, puma) report all workers
+		result = sshExecutor.Execute(fmt.Sprintf("ps -o rss=,pcpu= -p %d --ppid %d 2>/dev/null", props.MainPID, props.MainPID))
+		if result.ExitCode != 0 {
+			continue
+		}
+
+		count, rssKiB, cpu := parsePSUsage(result.Stdout)
+		metrics.Processes += count
+		metrics.RSSBytes += rssKiB * 1024
+		metrics.CPUPercent += cpu
+	}
+
+	if len(metrics.MainPIDs) == 0 {
+		return nil
+	}
+
+	return metrics
+}
+
+// parseUnitProperties parses "Key=Value" lines from systemctl show
+func parseUnitProperties(output string) unitProperties {
+	var props unitProperties
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "MainPID":
+			if pid, err := strconv.Atoi(value); err == nil {
+				props.MainPID = pid
+			}
+		case "MemoryCurrent":
+			// systemd reports "[not set]" or UINT64_MAX when memory accounting is disabled
+			if bytes, err := strconv.ParseInt(value, 10, 64); err == nil && bytes > 0 {
+				props.MemoryCurrent = bytes
+			}
+		}
+	}
+
+	return props
+}
+
+// parsePSUsage sums "rss pcpu" lines from ps, returning process count, RSS in KiB and CPU percent
+func parsePSUsage(output string) (count int, rssKiB int64, cpu float64) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		rss, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		pcpu, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+
+		count++
+		rssKiB += rss
+		cpu += pcpu
+	}
+
+	return count, rssKiB, cpu
+}
+
+// formatMemory formats a byte count as MiB (or GiB for large values)
+func formatMemory(bytes int64) string {
+	const mib = 1024 * 1024
+	if bytes >= 1024*mib {
+		return fmt.Sprintf("%.2f GiB", float64(bytes)/(1024*mib))
+	}
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/mib)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import "testing"
+
+func TestParseUnitProperties(t *testing.T) {
+	props := parseUnitProperties("MainPID=1234\nMemoryCurrent=52428800\n")
+	if props.MainPID != 1234 {
+		t.Errorf("Expected MainPID 1234, got %d", props.MainPID)
+	}
+	if props.MemoryCurrent != 52428800 {
+		t.Errorf("Expected MemoryCurrent 52428800, got %d", props.MemoryCurrent)
+	}
+
+	props = parseUnitProperties("MainPID=0\nMemoryCurrent=[not set]\n")
+	if props.MainPID != 0 || props.MemoryCurrent != 0 {
+		t.Errorf("Expected empty properties for inactive unit, got %+v", props)
+	}
+
+	props = parseUnitProperties("MainPID=42\nMemoryCurrent=18446744073709551615\n")
+	if props.MemoryCurrent != 0 {
+		t.Errorf("Expected MemoryCurrent to be ignored when accounting is disabled, got %d", props.MemoryCurrent)
+	}
+}
+
+func TestParsePSUsage(t *testing.T) {
+	count, rss, cpu := parsePSUsage(" 20480  1.5\n 10240  0.5\n\ngarbage\n")
+	if count != 2 {
+		t.Errorf("Expected 2 processes, got %d", count)
+	}
+	if rss != 30720 {
+		t.Errorf("Expected 30720 KiB RSS, got %d", rss)
+	}
+	if cpu != 2.0 {
+		t.Errorf("Expected 2.0%% CPU, got %.1f", cpu)
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{52428800, "50.0 MiB"},
+		{1572864, "1.5 MiB"},
+		{2147483648, "2.00 GiB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMemory(tt.bytes); got != tt.expected {
+			t.Errorf("formatMemory(%d) = %q, expected %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusWorkers bounds how many targets or servers status queries at once
+const statusWorkers = 8
+
+// statusServer is a connected server that status reads from
+type statusServer interface {
+	Execute(command string) *sshpkg.CommandResult
+	ExecuteSudo(command string) *sshpkg.CommandResult
+	Disconnect() error
+}
+
+// dialStatusServer connects to a server for the all-targets and multi-server
+// views. A single attempt bounded by config.StatusDialTimeout keeps an
+// unreachable server from holding up the others; tests replace it with fake
+// servers.
+var dialStatusServer = func(providerCfg config.ProviderConfig) (statusServer, error) {
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
+	sshExecutor.SetDialTimeout(config.StatusDialTimeout)
+	if err := sshExecutor.Connect(0, 0); err != nil {
+		return nil, err
+	}
+	return sshExecutor, nil
+}
+
+// forEachParallel calls fn for every index in [0, n) with at most workers
+// calls running at once, and returns when all of them are done
+func forEachParallel(n, workers int, fn func(i int)) {
+	var wg sync.WaitGroup
+	queue := make(chan int)
+	for w := 0; w < min(n, workers); w++ {
+		wg.Add(1)
+		crash.Go(func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		})
+	}
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// statusProbe is the state of an app on its server, read in one round trip
+type statusProbe struct {
+	Service     string // systemctl is-active, "not-found" without a unit
+	ActiveSince string // systemd ActiveEnterTimestamp
+	Release     string // Current release name
+	Disk        string // Root filesystem use, e.g. 42%
+	Uptime      string // Server uptime
+	// Maintenance is the server's maintenance marker, nil when it serves the app
+	Maintenance *state.Maintenance
+}
+
+// statusProbeScript returns one shell command printing key=value lines for
+// the app's service state, current release, disk use, server uptime and
+// maintenance marker. appDir is the app's directory on the server.
+func statusProbeScript(appName, appDir string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("s=$(systemctl is-active %s 2>/dev/null); echo \"service=${s:-not-found}\"", appName),
+		fmt.Sprintf("echo \"active_since=$(systemctl show -p ActiveEnterTimestamp %s 2>/dev/null | cut -d= -f2)\"", appName),
+		fmt.Sprintf("echo \"release=$(readlink -f %s/current 2>/dev/null)\"", appDir),
+		"echo \"disk=$(df -h / | tail -1 | awk '{print $5}')\"",
+		"echo \"uptime=$(uptime -p 2>/dev/null || uptime | awk '{print $3, $4}')\"",
+		fmt.Sprintf("m=%s/%s; if [ -f $m ]; then echo \"maintenance=$(tr -d '\\n' < $m)\"; fi", appDir, deploy.MaintenanceMarkerFile),
+	}, "; ")
+}
+
+// parseStatusProbe reads the output of statusProbeScript
+func parseStatusProbe(output, appDir string) statusProbe {
+	var probe statusProbe
+	releasesDir := appDir + "/releases/"
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "service":
+			probe.Service = value
+		case "active_since":
+			if value != "n/a" {
+				probe.ActiveSince = value
+			}
+		case "release":
+			probe.Release = strings.TrimPrefix(value, releasesDir)
+		case "disk":
+			probe.Disk = value
+		case "uptime":
+			probe.Uptime = value
+		case "maintenance":
+			probe.Maintenance = &state.Maintenance{}
+			json.Unmarshal([]byte(value), probe.Maintenance)
+		}
+	}
+	return probe
+}
+
+// apply copies the probe into statusData. Compose apps report their service
+// state through docker instead of systemd.
+func (p statusProbe) apply(statusData *StatusOutput, compose bool) {
+	if !compose {
+		statusData.ServiceStatus = p.Service
+		if p.Service == "active" && p.ActiveSince != "" {
+			if activeTime, err := time.Parse("Mon 2006-01-02 15:04:05 MST", p.ActiveSince); err == nil {
+				statusData.ServiceUptime = formatUptime(time.Since(activeTime))
+			}
+		}
+	}
+	statusData.CurrentRelease = p.Release
+	statusData.DiskUsage = p.Disk
+	statusData.ServerUptime = p.Uptime
+	// The server's marker also shows maintenance turned on from another machine
+	if p.Maintenance != nil && statusData.Maintenance == nil {
+		statusData.Maintenance = p.Maintenance
+	}
+}
+
+// probeStatus runs the status probe on a connected server for the app
+// deployed under baseDir
+func probeStatus(server utils.CommandRunner, appName, baseDir string) (statusProbe, error) {
+	appDir := config.AppDir(baseDir, appName)
+	result := server.Execute(statusProbeScript(appName, appDir))
+	if result.Error != nil {
+		return statusProbe{}, result.Error
+	}
+	if result.ExitCode != 0 {
+		return statusProbe{}, fmt.Errorf("status probe failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	return parseStatusProbe(result.Stdout, appDir), nil
+}
+
+// collectTargetSummaries reads the local state of every target for the
+// summary view and, unless --no-remote is set, the service state of each
+// target's server. Targets are collected in parallel.
+func collectTargetSummaries(cfg *config.Config) map[string]StatusOutput {
+	names := make([]string, 0, len(cfg.Targets))
+	for targetName := range cfg.Targets {
+		names = append(names, targetName)
+	}
+	sort.Strings(names)
+
+	results := make([]*StatusOutput, len(names))
+	forEachParallel(len(names), statusWorkers, func(i int) {
+		target := cfg.Targets[names[i]]
+		targetState, err := state.LoadState(names[i])
+		if err != nil {
+			return
+		}
+		summary := summarizeTarget(names[i], target, targetState)
+		if !statusNoRemoteFlag {
+			collectRemoteSummary(&summary, target, names[i], targetState)
+		}
+		results[i] = &summary
+	})
+
+	summaries := make(map[string]StatusOutput, len(names))
+	for i, summary := range results {
+		if summary != nil {
+			summaries[names[i]] = *summary
+		}
+	}
+	return summaries
+}
+
+// collectRemoteSummary adds the service state, release, disk use and uptime
+// of the target's server, or why the server could not be read
+func collectRemoteSummary(statusData *StatusOutput, target config.TargetConfig, targetName string, targetState *state.TargetState) {
+	if targetState.Paused || !targetState.Created {
+		return
+	}
+	if target.Provider == "flyio" {
+		collectFlyioStatus(statusData, target, targetState, false)
+		return
+	}
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil || providerCfg.GetIP() == "" {
+		return
+	}
+
+	server, err := dialStatusServer(providerCfg)
+	if err != nil {
+		statusData.RemoteError = err.Error()
+		return
+	}
+	defer server.Disconnect()
+
+	appName := utils.RemoteAppName(&target, targetName)
+	probe, err := probeStatus(server, appName, target.RemoteBaseDir())
+	if err != nil {
+		statusData.RemoteError = err.Error()
+		return
+	}
+	compose := deploy.IsComposeFramework(target.Framework)
+	probe.apply(statusData, compose)
+	if compose {
+		statusData.ServiceStatus = composeServiceStatus(server, appName)
+	}
+}
+
+// collectServerStatuses checks the service and current release on every
+// server of a multi-server target, in parallel
+func collectServerStatuses(target config.TargetConfig, targetName string) []ServerStatus {
+	serverTargets, err := target.ServerTargets()
+	if err != nil {
+		return nil
+	}
+
+	statuses := make([]*ServerStatus, len(serverTargets))
+	forEachParallel(len(serverTargets), statusWorkers, func(i int) {
+		serverTarget := serverTargets[i]
+		providerCfg, err := serverTarget.GetSSHProviderConfig()
+		if err != nil {
+			return
+		}
+		serverStatus := &ServerStatus{IP: providerCfg.GetIP()}
+		statuses[i] = serverStatus
+
+		server, err := dialStatusServer(providerCfg)
+		if err != nil {
+			serverStatus.Error = "unreachable: " + err.Error()
+			return
+		}
+		defer server.Disconnect()
+
+		probe, err := probeStatus(server, utils.RemoteAppName(&serverTarget, targetName), serverTarget.RemoteBaseDir())
+		if err != nil {
+			serverStatus.Error = err.Error()
+			return
+		}
+		serverStatus.ServiceStatus = probe.Service
+		serverStatus.CurrentRelease = probe.Release
+	})
+
+	result := make([]ServerStatus, 0, len(statuses))
+	for _, serverStatus := range statuses {
+		if serverStatus != nil {
+			result = append(result, *serverStatus)
+		}
+	}
+	return result
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const statusTestLatency = 100 * time.Millisecond
+
+// slowStatusServer answers the status probe after a delay, like a remote
+// server over SSH
+type slowStatusServer struct {
+	calls *atomic.Int32
+}
+
+func (s slowStatusServer) Execute(command string) *sshpkg.CommandResult {
+	s.calls.Add(1)
+	time.Sleep(statusTestLatency)
+	_, appDir, _ := strings.Cut(command, "readlink -f ")
+	appDir, _, _ = strings.Cut(appDir, "/current")
+	return &sshpkg.CommandResult{Stdout: "service=active\nactive_since=n/a\nrelease=" + appDir + "/releases/20240101000000\ndisk=42%\nuptime=up 3 days\n"}
+}
+
+func (s slowStatusServer) ExecuteSudo(command string) *sshpkg.CommandResult {
+	return s.Execute(command)
+}
+
+func (s slowStatusServer) Disconnect() error { return nil }
+
+// useSlowStatusServers replaces the status dialer; servers in down fail to
+// connect after the same delay
+func useSlowStatusServers(t *testing.T, down ...string) *atomic.Int32 {
+	t.Helper()
+	calls := &atomic.Int32{}
+	original := dialStatusServer
+	dialStatusServer = func(providerCfg config.ProviderConfig) (statusServer, error) {
+		for _, ip := range down {
+			if providerCfg.GetIP() == ip {
+				time.Sleep(statusTestLatency)
+				return nil, errors.New("dial tcp " + ip + ":22: i/o timeout")
+			}
+		}
+		return slowStatusServer{calls: calls}, nil
+	}
+	t.Cleanup(func() { dialStatusServer = original })
+	return calls
+}
+
+func statusTestConfig(t *testing.T, count int) *config.Config {
+	t.Helper()
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{}}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("app%02d", i)
+		target := config.TargetConfig{Provider: "hetzner", Framework: "Express.js"}
+		if err := target.SetProviderConfig("hetzner", &config.HetznerConfig{IP: fmt.Sprintf("203.0.113.%d", i+1), Username: "deploy", Provisioned: true}); err != nil {
+			t.Fatal(err)
+		}
+		cfg.Targets[name] = target
+		if err := state.MarkCreated(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+func TestParseStatusProbe(t *testing.T) {
+	output := "service=failed\nactive_since=\nrelease=/srv/web/releases/20240102030405\ndisk=87%\nuptime=up 2 weeks, 1 day\n"
+	probe := parseStatusProbe(output, "/srv/web")
+	want := statusProbe{Service: "failed", Release: "20240102030405", Disk: "87%", Uptime: "up 2 weeks, 1 day"}
+	if probe != want {
+		t.Errorf("parseStatusProbe() = %+v, want %+v", probe, want)
+	}
+
+	if probe := parseStatusProbe("service=not-found\nactive_since=n/a\nrelease=\n", "web"); probe.Release != "" || probe.ActiveSince != "" {
+		t.Errorf("parseStatusProbe() without a release = %+v", probe)
+	}
+}
+
+func TestParseStatusProbeMaintenance(t *testing.T) {
+	output := "service=active\nmaintenance={\"since\":\"2024-01-02T03:04:05Z\",\"by\":\"dev@laptop\"}\n"
+	probe := parseStatusProbe(output, "/srv/web")
+	if probe.Maintenance == nil || probe.Maintenance.By != "dev@laptop" || probe.Maintenance.Since.IsZero() {
+		t.Fatalf("parseStatusProbe() maintenance = %+v", probe.Maintenance)
+	}
+
+	// The marker alone marks a target that only another machine put into maintenance
+	var statusData StatusOutput
+	probe.apply(&statusData, false)
+	if statusData.Maintenance != probe.Maintenance {
+		t.Errorf("apply() maintenance = %+v, want the server's marker", statusData.Maintenance)
+	}
+	if !strings.Contains(statusProbeScript("web", "/srv/web"), "/srv/web/.lightfold-maintenance") {
+		t.Errorf("status probe does not read the maintenance marker: %s", statusProbeScript("web", "/srv/web"))
+	}
+}
+
+func TestCollectTargetSummariesInParallel(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg := statusTestConfig(t, 12)
+	calls := useSlowStatusServers(t, "203.0.113.5")
+
+	start := time.Now()
+	summaries := collectTargetSummaries(cfg)
+	elapsed := time.Since(start)
+
+	// 12 targets at 100ms each take 1.2s one after another; 8 workers need two rounds
+	if elapsed > 6*statusTestLatency {
+		t.Errorf("collectTargetSummaries() took %s, want the targets collected in parallel", elapsed)
+	}
+	if got := calls.Load(); got != 11 {
+		t.Errorf("status commands run = %d, want one round trip per reachable server", got)
+	}
+
+	if len(summaries) != 12 {
+		t.Fatalf("got %d summaries, want 12", len(summaries))
+	}
+	down := summaries["app04"]
+	if !strings.Contains(down.RemoteError, "i/o timeout") || down.ServiceStatus != "" {
+		t.Errorf("unreachable target = %+v, want its error", down)
+	}
+	up := summaries["app05"]
+	if up.RemoteError != "" || up.ServiceStatus != "active" || up.CurrentRelease != "20240101000000" || up.DiskUsage != "42%" {
+		t.Errorf("reachable target = %+v", up)
+	}
+}
+
+func TestCollectTargetSummariesNoRemote(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg := statusTestConfig(t, 3)
+	calls := useSlowStatusServers(t)
+	statusNoRemoteFlag = true
+	defer func() { statusNoRemoteFlag = false }()
+
+	start := time.Now()
+	summaries := collectTargetSummaries(cfg)
+	if elapsed := time.Since(start); elapsed >= statusTestLatency {
+		t.Errorf("collectTargetSummaries() with --no-remote took %s", elapsed)
+	}
+	if calls.Load() != 0 || len(summaries) != 3 || summaries["app00"].ServerIP != "203.0.113.1" {
+		t.Errorf("summaries = %+v after %d remote calls, want local state only", summaries, calls.Load())
+	}
+}
+
+func TestCollectServerStatusesInParallel(t *testing.T) {
+	target := config.TargetConfig{Provider: "hetzner"}
+	if err := target.SetProviderConfig("hetzner", &config.HetznerConfig{IP: "198.51.100.1", Username: "deploy", Provisioned: true}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 4; i++ {
+		target.Servers = append(target.Servers, config.ServerConfig{IP: fmt.Sprintf("198.51.100.%d", i), Username: "deploy"})
+	}
+	useSlowStatusServers(t, "198.51.100.2")
+
+	start := time.Now()
+	statuses := collectServerStatuses(target, "web")
+	if elapsed := time.Since(start); elapsed > 3*statusTestLatency {
+		t.Errorf("collectServerStatuses() took %s, want the servers checked in parallel", elapsed)
+	}
+
+	if len(statuses) != 4 || statuses[0].IP != "198.51.100.1" {
+		t.Fatalf("statuses = %+v, want one per server in order", statuses)
+	}
+	if !strings.HasPrefix(statuses[1].Error, "unreachable") {
+		t.Errorf("down server = %+v, want unreachable", statuses[1])
+	}
+	if statuses[2].ServiceStatus != "active" || statuses[2].CurrentRelease != "20240101000000" {
+		t.Errorf("reachable server = %+v", statuses[2])
+	}
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	ansiAltScreen     = "\033[?1049h"
+	ansiMainScreen    = "\033[?1049l"
+	ansiHideCursor    = "\033[?25l"
+	ansiShowCursor    = "\033[?25h"
+	ansiClearToOrigin = "\033[H\033[2J"
+)
+
+var statusChangedStyle = style.Warning
+
+// statusChanges holds the fields that differ from the previous watch sample
+type statusChanges map[string]bool
+
+// mark returns a marker appended to a field's line when it changed
+func (c statusChanges) mark(field string) string {
+	if !c[field] {
+		return ""
+	}
+	return " " + statusChangedStyle.Render(style.Pointer()+" changed")
+}
+
+// diffStatus compares two samples of a target's status. The first sample
+// (prev == nil) has nothing to compare against.
+func diffStatus(prev *StatusOutput, cur StatusOutput) statusChanges {
+	if prev == nil {
+		return nil
+	}
+
+	changes := statusChanges{}
+	set := func(field string, changed bool) {
+		if changed {
+			changes[field] = true
+		}
+	}
+
+	set("service", prev.ServiceStatus != cur.ServiceStatus)
+	set("release", prev.CurrentRelease != cur.CurrentRelease)
+	set("health", healthState(prev.HealthCheck) != healthState(cur.HealthCheck))
+	set("last_deploy", prev.LastDeploy != cur.LastDeploy)
+	set("last_release", prev.LastRelease != cur.LastRelease)
+	set("paused", prev.Paused != cur.Paused)
+	set("maintenance", (prev.Maintenance == nil) != (cur.Maintenance == nil))
+	set("server_ip", prev.ServerIP != cur.ServerIP)
+	set("disk", prev.DiskUsage != cur.DiskUsage)
+	set("created", prev.Created != cur.Created || prev.CreateFailed != cur.CreateFailed)
+	set("configured", prev.Configured != cur.Configured || prev.ConfigureFailed != cur.ConfigureFailed)
+	set("push", prev.LastDeploy != cur.LastDeploy || prev.PushFailed != cur.PushFailed)
+	set("servers", !sameServers(prev.Servers, cur.Servers))
+
+	return changes
+}
+
+func healthState(health *HealthCheckStatus) string {
+	if health == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", health.Status, health.HTTPCode)
+}
+
+func sameServers(a, b []ServerStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatStatusTime reformats an RFC 3339 timestamp from StatusOutput for display
+func formatStatusTime(value string) string {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return timefmt.Human(parsed)
+}
+
+// watchStatus re-collects status every interval and redraws it in place until
+// interrupted. The command's SSH pool is enabled once, before the first
+// sample, so each server is dialed once and reused by every refresh.
+func watchStatus(cfg *config.Config, targetName string, interval time.Duration) {
+	sshpkg.EnablePooling()
+
+	redraw := isTerminal() && !statusJSONFlag
+	if redraw {
+		fmt.Print(ansiAltScreen + ansiHideCursor)
+	}
+	restore := func() {
+		if redraw {
+			fmt.Print(ansiShowCursor + ansiMainScreen)
+		}
+	}
+	defer restore()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sampler := newStatusSampler(cfg, targetName)
+	for {
+		frame, err := sampler.next()
+		if err != nil {
+			restore()
+			fmt.Fprintf(os.Stderr, "%s\n", statusErrorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			exit(1)
+		}
+
+		if redraw {
+			header := statusMutedStyle.Render(fmt.Sprintf("Every %s · %s · Ctrl-C to exit", interval, time.Now().Format("15:04:05")))
+			fmt.Print(ansiClearToOrigin + header + "\n\n" + frame)
+		} else {
+			fmt.Print(frame)
+		}
+
+		select {
+		case <-interrupts:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// statusSampler collects successive samples for watch mode and remembers the
+// previous one so changed fields can be highlighted
+type statusSampler struct {
+	cfg        *config.Config
+	targetName string
+	prevTarget *StatusOutput
+	prevAll    map[string]StatusOutput
+}
+
+func newStatusSampler(cfg *config.Config, targetName string) *statusSampler {
+	return &statusSampler{cfg: cfg, targetName: targetName}
+}
+
+// next collects one sample and renders it
+func (s *statusSampler) next() (string, error) {
+	var buf bytes.Buffer
+
+	// Deploys and pause/resume in other terminals change the config too
+	if fresh, err := config.LoadConfig(); err == nil {
+		s.cfg = fresh
+	}
+
+	if s.targetName == "" {
+		summaries := collectTargetSummaries(s.cfg)
+		changes := make(map[string]statusChanges, len(summaries))
+		if s.prevAll != nil {
+			for name, summary := range summaries {
+				if prev, ok := s.prevAll[name]; ok {
+					changes[name] = diffStatus(&prev, summary)
+				}
+			}
+		}
+		s.prevAll = summaries
+
+		if statusJSONFlag {
+			return encodeStatusLine(summaries)
+		}
+		printAllTargets(&buf, s.cfg, summaries, changes)
+		return buf.String(), nil
+	}
+
+	target, exists := s.cfg.GetTarget(s.targetName)
+	if !exists {
+		return "", fmt.Errorf("target '%s' not found", s.targetName)
+	}
+	targetState, err := state.LoadState(s.targetName)
+	if err != nil {
+		return "", fmt.Errorf("failed to load state: %w", err)
+	}
+
+	statusData := collectStatusData(s.cfg, s.targetName, target, targetState)
+	changes := diffStatus(s.prevTarget, statusData)
+	s.prevTarget = &statusData
+
+	if statusJSONFlag {
+		return encodeStatusLine(statusData)
+	}
+	printTargetDetail(&buf, target, s.targetName, targetState, statusData, changes)
+	return buf.String(), nil
+}
+
+// encodeStatusLine renders a sample as one line of JSON, so --watch --json
+// streams newline-delimited samples
+func encodeStatusLine(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+func TestDiffStatus(t *testing.T) {
+	if changes := diffStatus(nil, StatusOutput{ServiceStatus: "active"}); len(changes) != 0 {
+		t.Errorf("Expected no changes for the first sample, got %v", changes)
+	}
+
+	prev := StatusOutput{
+		ServiceStatus:  "active",
+		CurrentRelease: "20240101000000",
+		HealthCheck:    &HealthCheckStatus{Status: "healthy", HTTPCode: 200, ResponseTime: 40},
+		DiskUsage:      "40%",
+	}
+
+	same := prev
+	same.HealthCheck = &HealthCheckStatus{Status: "healthy", HTTPCode: 200, ResponseTime: 95}
+	if changes := diffStatus(&prev, same); len(changes) != 0 {
+		t.Errorf("Expected response time alone not to count as a change, got %v", changes)
+	}
+
+	cur := prev
+	cur.ServiceStatus = "failed"
+	cur.CurrentRelease = "20240102000000"
+	cur.HealthCheck = &HealthCheckStatus{Status: "unhealthy", HTTPCode: 502}
+	changes := diffStatus(&prev, cur)
+	for _, field := range []string{"service", "release", "health"} {
+		if !changes[field] {
+			t.Errorf("Expected %s to be marked as changed", field)
+		}
+	}
+	if changes["disk"] {
+		t.Error("Expected unchanged disk usage not to be marked")
+	}
+}
+
+func TestStatusChangesMark(t *testing.T) {
+	var none statusChanges
+	if mark := none.mark("service"); mark != "" {
+		t.Errorf("Expected no marker from nil changes, got %q", mark)
+	}
+
+	changes := statusChanges{"service": true}
+	if !strings.Contains(changes.mark("service"), "changed") {
+		t.Errorf("Expected a change marker, got %q", changes.mark("service"))
+	}
+	if mark := changes.mark("release"); mark != "" {
+		t.Errorf("Expected no marker for an unchanged field, got %q", mark)
+	}
+}
+
+func TestPrintAllTargets_MarksChanges(t *testing.T) {
+	cfg := &config.Config{Targets: map[string]config.TargetConfig{
+		"api": {Provider: "hetzner", Framework: "Express.js"},
+		"web": {Provider: "vultr", Framework: "Next.js"},
+	}}
+	summaries := map[string]StatusOutput{
+		"api": summarizeTarget("api", cfg.Targets["api"], &state.TargetState{Paused: true}),
+		"web": summarizeTarget("web", cfg.Targets["web"], &state.TargetState{}),
+	}
+
+	var buf bytes.Buffer
+	printAllTargets(&buf, cfg, summaries, map[string]statusChanges{"api": {"paused": true}})
+	out := buf.String()
+
+	if strings.Count(out, "changed") != 1 {
+		t.Errorf("Expected exactly one change marker, got:\n%s", out)
+	}
+	if strings.Index(out, "api") > strings.Index(out, "web") {
+		t.Errorf("Expected targets in name order, got:\n%s", out)
+	}
+}
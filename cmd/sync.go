@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/timefmt"
 	"os"
 
 	"github.com/charmbracelet/lipgloss"
@@ -10,6 +12,7 @@ import (
 
 var (
 	syncTargetFlag string
+	syncFixFlag    bool
 )
 
 var syncCmd = &cobra.Command{
@@ -29,11 +32,14 @@ The sync command will:
 - Recover server IP from provider API (if needed)
 - Update deployment information (current release, commit, etc.)
 - Preserve user-supplied configuration (domain, env vars, etc.)
+- Warn when the domain's nginx config and certificate are not on the current
+  server (e.g. after a rebuild or an IP change) and offer to re-apply them
 
 Examples:
   lightfold sync                    # Sync current directory
   lightfold sync ~/Projects/myapp   # Sync specific project
-  lightfold sync --target myapp     # Sync named target`,
+  lightfold sync --target myapp     # Sync named target
+  lightfold sync --target myapp --fix  # Also re-apply the domain after an IP change`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := loadConfigOrExit()
@@ -45,15 +51,15 @@ Examples:
 
 		target, targetName := resolveTarget(cfg, syncTargetFlag, pathArg)
 
-		headerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86")).Bold(true)
-		valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+		headerStyle := style.Header
+		valueStyle := style.Value
 		fmt.Printf("%s %s\n\n", headerStyle.Render("Syncing target:"), valueStyle.Render(targetName))
 
-		syncedState, err := syncTarget(target, targetName, cfg)
+		syncedState, err := syncTarget(target, targetName, cfg, syncFixFlag)
 		if err != nil {
-			errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-			fmt.Fprintf(os.Stderr, "\n%s %v\n", errorStyle.Render("✗ Sync failed:"), err)
-			os.Exit(1)
+			errorStyle := style.ErrorText
+			fmt.Fprintf(os.Stderr, "\n%s %v\n", errorStyle.Render(style.Cross()+" Sync failed:"), err)
+			exit(1)
 		}
 
 		// Skip summary card for S3 (syncedState will be nil)
@@ -64,8 +70,8 @@ Examples:
 		fmt.Println()
 
 		// Display synced state summary in a card
-		successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-		mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+		successStyle := style.Success
+		mutedStyle := style.Muted
 
 		// Get provider config for IP
 		providerCfg, _ := target.GetSSHProviderConfig()
@@ -76,7 +82,7 @@ Examples:
 
 		// Build summary lines
 		summaryLines := []string{
-			successStyle.Render(fmt.Sprintf("✓ Successfully synced '%s'", targetName)),
+			successStyle.Render(fmt.Sprintf("%s Successfully synced '%s'", style.Check(), targetName)),
 			"",
 		}
 
@@ -86,13 +92,13 @@ Examples:
 		}
 
 		// Add created/configured state
-		createdStatus := "✗ No"
+		createdStatus := style.Cross() + " No"
 		if syncedState.Created {
-			createdStatus = "✓ Yes"
+			createdStatus = style.Check() + " Yes"
 		}
-		configuredStatus := "✗ No"
+		configuredStatus := style.Cross() + " No"
 		if syncedState.Configured {
-			configuredStatus = "✓ Yes"
+			configuredStatus = style.Check() + " Yes"
 		}
 		summaryLines = append(summaryLines,
 			fmt.Sprintf("%s %s", mutedStyle.Render("Created:"), valueStyle.Render(createdStatus)),
@@ -113,12 +119,10 @@ Examples:
 		}
 
 		if !syncedState.LastDeploy.IsZero() {
-			summaryLines = append(summaryLines, fmt.Sprintf("%s %s", mutedStyle.Render("Last Deploy:"), valueStyle.Render(syncedState.LastDeploy.Format("2006-01-02 15:04"))))
+			summaryLines = append(summaryLines, fmt.Sprintf("%s %s", mutedStyle.Render("Last Deploy:"), valueStyle.Render(timefmt.Human(syncedState.LastDeploy))))
 		}
 
-		successBox := lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("82")).
+		successBox := style.Box(style.ColorSuccess).
 			Padding(0, 1).
 			Render(lipgloss.JoinVertical(lipgloss.Left, summaryLines...))
 
@@ -130,4 +134,5 @@ Examples:
 func init() {
 	rootCmd.AddCommand(syncCmd)
 	syncCmd.Flags().StringVar(&syncTargetFlag, "target", "", "Target name to sync")
+	syncCmd.Flags().BoolVar(&syncFixFlag, "fix", false, "Reconfigure nginx and re-issue the certificate when the domain is not set up on the current server")
 }
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// targetCmd groups commands that manage targets as a whole
+var targetCmd = &cobra.Command{
+	Use:   "target",
+	Short: "Manage deployment targets",
+	Long: `Manage deployment targets as a whole.
+
+Examples:
+  lightfold target import --target shop --ip 203.0.113.10 --ssh-key ~/.ssh/id_ed25519 --user ubuntu`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(targetCmd)
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lightfold/cmd/ui/style"
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"lightfold/pkg/util"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importTargetFlag  string
+	importAppFlag     string
+	importIPFlag      string
+	importSSHKeyFlag  string
+	importUserFlag    string
+	importSSHHostFlag string
+	importPortFlag    int
+	importDomainFlag  string
+)
+
+var targetImportCmd = &cobra.Command{
+	Use:   "import [PROJECT_PATH]",
+	Short: "Adopt an app already deployed to a server by hand",
+	Long: `Adopt an app that was set up on a server by hand, so lightfold manages it
+without deploying from scratch.
+
+Import reads the app's systemd unit (<app>.service) and nginx site (named
+<app>) over SSH and infers the directory it runs from, its port, environment
+files and domain. The inferred configuration is shown for confirmation or
+editing, then saved as a new target marked created and configured, with the
+running release as its last deploy.
+
+The next push deploys over the app in lightfold's layout, <base>/<app>/releases
+and current, under the directory the app already lives in (deploy.base_dir),
+and replaces the unit and nginx site with lightfold's. The variables of the
+unit's environment files are saved to deploy.env_vars.
+
+Examples:
+  lightfold target import --target shop --ip 203.0.113.10 --ssh-key ~/.ssh/id_ed25519 --user ubuntu
+  lightfold target import ./blog --ssh-host prod --app blog
+  lightfold target import --target shop --ssh-host prod --port 3000 --no-interactive`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectPath := "."
+		if len(args) > 0 {
+			projectPath = args[0]
+		}
+		projectPath, err := util.ValidateProjectPath(projectPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+
+		targetName := importTargetFlag
+		if targetName == "" {
+			targetName = util.GetTargetName(projectPath)
+		}
+
+		cfg := loadConfigOrExit()
+		if _, exists := cfg.GetTarget(targetName); exists || state.IsCreated(targetName) {
+			fmt.Fprintf(os.Stderr, "Error: target '%s' already exists; import into a new target name with --target\n", targetName)
+			exit(1)
+		}
+
+		byosConfig, err := newBYOSConfig(importIPFlag, importSSHKeyFlag, importUserFlag, importSSHHostFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		target := config.TargetConfig{ProjectPath: projectPath, Provider: "byos"}
+		if err := target.SetProviderConfig("byos", byosConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		detection, err := resolveFramework(cfg, &target, projectPath, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		target.Framework = detection.Framework
+
+		appName := utils.RemoteAppName(&target, targetName)
+		if importAppFlag != "" {
+			if !cleanupNamePattern.MatchString(importAppFlag) {
+				fmt.Fprintf(os.Stderr, "Error: invalid app name %q\n", importAppFlag)
+				exit(1)
+			}
+			if importAppFlag != appName {
+				target.AppName = importAppFlag
+				appName = importAppFlag
+			}
+		}
+
+		sshExecutor := sshpkg.NewExecutorFromConfig(byosConfig)
+		defer sshExecutor.Disconnect()
+		if err := sshExecutor.Connect(3, 2*time.Second); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to connect to server: %v\n", err)
+			exit(1)
+		}
+
+		imported, err := deploy.ScanImport(sshExecutor, appName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if importPortFlag != 0 {
+			imported.Port = importPortFlag
+		}
+		if importDomainFlag != "" {
+			setImportDomain(imported, importDomainFlag)
+		}
+
+		printImport(os.Stdout, imported, targetName)
+		if jsonOutput || skipInteractive || !isTerminal() {
+			if err := applyImport(&target, imported); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exit(1)
+			}
+		} else if !confirmImport(bufio.NewReader(os.Stdin), &target, imported, targetName) {
+			fmt.Println("Import cancelled")
+			return
+		}
+
+		if err := saveImport(cfg, &target, targetName, imported); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		if result := writeCreatedMarker(sshExecutor); result.Error != nil || result.ExitCode != 0 {
+			fmt.Printf("Warning: failed to write the created marker on the server\n")
+		}
+
+		fmt.Printf("\n%s %s\n", style.Success.Render(style.Check()), style.Muted.Render(fmt.Sprintf("Imported %s as target '%s'", appName, targetName)))
+		fmt.Printf("Run 'lightfold push --target %s' to deploy over it.\n", targetName)
+	},
+}
+
+// setImportDomain changes the imported domain; the certificate found only
+// covers the one the site served
+func setImportDomain(imported *deploy.ImportedApp, domain string) {
+	if domain != imported.Domain {
+		imported.SSL = false
+	}
+	imported.Domain = domain
+}
+
+// printImport shows the configuration inferred for the app and what the next
+// push changes about it
+func printImport(w io.Writer, imported *deploy.ImportedApp, targetName string) {
+	fmt.Fprintf(w, "\n%s\n", serverHeaderStyle.Render(fmt.Sprintf("Import %s as target '%s'", imported.AppName, targetName)))
+	row := func(label, value string) {
+		fmt.Fprintf(w, "  %-13s %s\n", serverLabelStyle.Render(label+":"), serverValueStyle.Render(value))
+	}
+
+	if imported.Unit != nil {
+		row("Unit", imported.Unit.File)
+	}
+	if imported.Site != nil {
+		row("Nginx site", imported.Site.File)
+	}
+	row("Runs from", imported.CodeDir)
+	row("App dir", imported.AppDir)
+	if imported.Release != "" {
+		row("Release", timefmt.Release(imported.Release))
+	}
+	switch {
+	case imported.Static():
+		row("Port", "- (static site)")
+	case imported.Port == 0:
+		row("Port", "unknown")
+	default:
+		row("Port", strconv.Itoa(imported.Port))
+	}
+	domain := "-"
+	if imported.Domain != "" {
+		domain = imported.Domain
+		if imported.SSL {
+			domain += " (SSL)"
+		}
+	}
+	row("Domain", domain)
+	if len(imported.EnvVars) > 0 {
+		names := make([]string, 0, len(imported.EnvVars))
+		for name := range imported.EnvVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		row("Env vars", strings.Join(names, ", "))
+	}
+
+	for _, warning := range imported.Warnings {
+		fmt.Fprintf(w, "  %s\n", style.WarningText.Render(style.Warn()+" "+warning))
+	}
+	fmt.Fprintln(w)
+}
+
+// confirmImport asks to import with the inferred configuration, letting the
+// user edit the port, domain and base directory first. It applies the
+// accepted configuration to the target.
+func confirmImport(reader *bufio.Reader, target *config.TargetConfig, imported *deploy.ImportedApp, targetName string) bool {
+	for {
+		err := applyImport(target, imported)
+		if err != nil {
+			fmt.Printf("%s\n", serverErrorStyle.Render(err.Error()))
+			fmt.Print("Edit the settings? (Y/n): ")
+		} else {
+			fmt.Print("Import with these settings? (Y/n/e to edit): ")
+		}
+		response, _ := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(response)) {
+		case "", "y", "yes":
+			if err == nil {
+				return true
+			}
+		case "e", "edit":
+		default:
+			return false
+		}
+
+		editImport(reader, imported)
+		printImport(os.Stdout, imported, targetName)
+	}
+}
+
+// editImport prompts for the port, domain and base directory, keeping the
+// current value on an empty answer
+func editImport(reader *bufio.Reader, imported *deploy.ImportedApp) {
+	ask := func(label, current string) string {
+		fmt.Printf("%s [%s]: ", label, current)
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer != "" {
+			return answer
+		}
+		return current
+	}
+
+	if !imported.Static() {
+		if port, err := strconv.Atoi(ask("Port", strconv.Itoa(imported.Port))); err == nil {
+			imported.Port = port
+		}
+	}
+	if domain := ask("Domain (- for none)", imported.Domain); domain == "-" {
+		setImportDomain(imported, "")
+	} else {
+		setImportDomain(imported, domain)
+	}
+	imported.BaseDir = ask("Base directory", imported.BaseDir)
+	imported.AppDir = config.AppDir(imported.BaseDir, imported.AppName)
+}
+
+// applyImport writes the imported configuration into the target
+func applyImport(target *config.TargetConfig, imported *deploy.ImportedApp) error {
+	if !imported.Static() {
+		if imported.Port < 1 || imported.Port > 65535 {
+			return fmt.Errorf("could not infer the port %s listens on; pass --port", imported.AppName)
+		}
+		target.Port = imported.Port
+	}
+	if err := setBaseDir(target, imported.BaseDir); err != nil {
+		return err
+	}
+
+	target.Domain = nil
+	if imported.Domain != "" {
+		if !isValidDomain(imported.Domain) {
+			return fmt.Errorf("invalid domain %q", imported.Domain)
+		}
+		target.Domain = &config.DomainConfig{Domain: imported.Domain, SSLEnabled: imported.SSL, ProxyType: "nginx"}
+		if imported.SSL {
+			target.Domain.SSLManager = "certbot"
+		}
+	}
+
+	if len(imported.EnvVars) > 0 {
+		ensureDeploy(target).EnvVars = imported.EnvVars
+	}
+	return nil
+}
+
+// saveImport saves the target, marks it created and configured with the
+// running release, and registers the app and its port with the server
+func saveImport(cfg *config.Config, target *config.TargetConfig, targetName string, imported *deploy.ImportedApp) error {
+	if err := utils.UpdateServerStateFromTarget(target, targetName); err != nil {
+		return fmt.Errorf("failed to update server state: %w", err)
+	}
+	if err := utils.RegisterAppWithServer(target, targetName, target.Port, target.Framework); err != nil {
+		return fmt.Errorf("failed to register app with server: %w", err)
+	}
+
+	if err := cfg.SetTarget(targetName, *target); err != nil {
+		return fmt.Errorf("failed to save target config: %w", err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if err := state.RecordImport(targetName, imported.Release, target.RemoteBaseDir(), imported.DeployedAt); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+	if imported.SSL {
+		if err := state.MarkSSLConfigured(targetName); err != nil {
+			return fmt.Errorf("failed to update state: %w", err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	targetCmd.AddCommand(targetImportCmd)
+
+	targetImportCmd.Flags().StringVar(&importTargetFlag, "target", "", "Name of the new target (defaults to current directory name)")
+	targetImportCmd.Flags().StringVar(&importAppFlag, "app", "", "Name of the app's systemd unit and nginx site (defaults to the target's app name)")
+	targetImportCmd.Flags().StringVar(&importIPFlag, "ip", "", "Server IP address")
+	targetImportCmd.Flags().StringVar(&importSSHKeyFlag, "ssh-key", "", "SSH private key path")
+	targetImportCmd.Flags().StringVar(&importUserFlag, "user", "", "SSH username (default root)")
+	targetImportCmd.Flags().StringVar(&importSSHHostFlag, "ssh-host", "", "Host alias from ~/.ssh/config")
+	targetImportCmd.Flags().IntVar(&importPortFlag, "port", 0, "Port the app listens on, when it cannot be inferred")
+	targetImportCmd.Flags().StringVar(&importDomainFlag, "domain", "", "Domain the app is served on, instead of the nginx site's")
+}
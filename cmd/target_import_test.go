@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newImportedApp() *deploy.ImportedApp {
+	return &deploy.ImportedApp{
+		AppName:    "shop",
+		Unit:       &deploy.SystemdUnit{File: "/etc/systemd/system/shop.service", WorkingDirectory: "/opt/shop/current"},
+		CodeDir:    "/opt/shop/current",
+		AppDir:     "/opt/shop",
+		BaseDir:    "/opt",
+		Release:    "20240301120000",
+		DeployedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Port:       3000,
+		Domain:     "shop.example.com",
+		SSL:        true,
+		EnvVars:    map[string]string{"NODE_ENV": "production"},
+	}
+}
+
+func TestApplyImport(t *testing.T) {
+	target := newSettingsTarget(t)
+	if err := applyImport(&target, newImportedApp()); err != nil {
+		t.Fatalf("applyImport() error: %v", err)
+	}
+	if target.Port != 3000 || target.RemoteBaseDir() != "/opt" {
+		t.Errorf("Port = %d, RemoteBaseDir() = %s, want 3000 and /opt", target.Port, target.RemoteBaseDir())
+	}
+	if target.Domain == nil || target.Domain.Domain != "shop.example.com" || !target.Domain.SSLEnabled || target.Domain.SSLManager != "certbot" {
+		t.Errorf("Domain = %+v, want shop.example.com with certbot SSL", target.Domain)
+	}
+	if target.Deploy.EnvVars["NODE_ENV"] != "production" {
+		t.Errorf("EnvVars = %v, want NODE_ENV", target.Deploy.EnvVars)
+	}
+
+	imported := newImportedApp()
+	imported.Port = 0
+	if err := applyImport(&target, imported); err == nil || !strings.Contains(err.Error(), "--port") {
+		t.Errorf("applyImport() without a port error = %v, want one naming --port", err)
+	}
+
+	imported = newImportedApp()
+	imported.BaseDir = "/"
+	if err := applyImport(&target, imported); err == nil {
+		t.Error("applyImport() should reject / as the base directory")
+	}
+}
+
+func TestConfirmImport_Edit(t *testing.T) {
+	target := newSettingsTarget(t)
+	imported := newImportedApp()
+	imported.Port = 0
+
+	// The missing port makes the first answer edit: port, domain, base dir
+	reader := bufio.NewReader(strings.NewReader("\n4000\nwww.example.com\n/srv\ny\n"))
+	if !confirmImport(reader, &target, imported, "shop") {
+		t.Fatal("confirmImport() = false, want true")
+	}
+	if target.Port != 4000 || target.Domain.Domain != "www.example.com" || target.Domain.SSLEnabled {
+		t.Errorf("target = port %d, domain %+v, want 4000 and www.example.com without SSL", target.Port, target.Domain)
+	}
+	if target.Deploy.BaseDir != "" || imported.AppDir != "/srv/shop" {
+		t.Errorf("BaseDir = %q, AppDir = %q, want the default /srv/shop", target.Deploy.BaseDir, imported.AppDir)
+	}
+
+	if confirmImport(bufio.NewReader(strings.NewReader("n\n")), &target, newImportedApp(), "shop") {
+		t.Error("confirmImport() = true after answering n")
+	}
+}
+
+func TestSaveImport(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := newSettingsTarget(t)
+	imported := newImportedApp()
+	if err := applyImport(&target, imported); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveImport(cfg, &target, "shop", imported); err != nil {
+		t.Fatalf("saveImport() error: %v", err)
+	}
+
+	targetState, err := state.LoadState("shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !targetState.Created || !targetState.Configured || targetState.LastRelease != "20240301120000" || !targetState.SSLConfigured {
+		t.Errorf("state = %+v, want created, configured, SSL and the imported release", targetState)
+	}
+	if err := checkBaseDir("shop", target); err != nil {
+		t.Errorf("checkBaseDir() after import = %v, want nil", err)
+	}
+
+	app, err := state.GetAppFromServer("203.0.113.10", "shop")
+	if err != nil || app.Port != 3000 {
+		t.Errorf("server app = %+v, %v, want shop on port 3000", app, err)
+	}
+	saved, _ := config.LoadConfig()
+	if _, ok := saved.GetTarget("shop"); !ok {
+		t.Error("target shop was not saved")
+	}
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"strings"
 	"time"
 
@@ -122,9 +123,7 @@ func (m animationModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m animationModel) View() string {
 	var s strings.Builder
 
-	headerStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("82")).
+	headerStyle := style.Success.Bold(true).
 		Align(lipgloss.Center).
 		Width(60)
 
@@ -132,8 +131,7 @@ func (m animationModel) View() string {
 	s.WriteString(headerStyle.Render(successMessages[msgIndex]))
 	s.WriteString("\n")
 
-	rocketStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("226")). // Yellow
+	rocketStyle := style.Fg("226").
 		Align(lipgloss.Center).
 		Width(60)
 
@@ -145,8 +143,7 @@ func (m animationModel) View() string {
 
 	s.WriteString(rocketStyle.Render(rocketWithSparkles))
 
-	footerStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("245")).
+	footerStyle := style.Muted.
 		Align(lipgloss.Center).
 		Width(60).
 		MarginTop(2)
@@ -194,12 +191,15 @@ func ShowRocketAnimation() error {
 }
 
 func ShowQuickSuccess() {
-	successStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("82"))
+	successStyle := style.Success.Bold(true)
+
+	message := "🚀 Deployment successful!"
+	if style.Plain() {
+		message = style.Check() + " Deployment successful!"
+	}
 
 	fmt.Println()
-	fmt.Println(successStyle.Render("🚀 Deployment successful!"))
+	fmt.Println(successStyle.Render(message))
 	fmt.Println("Your application is now live!")
 	fmt.Println()
 }
@@ -0,0 +1,224 @@
+package tui
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// logViewportHeight is the number of output lines shown while a step runs
+	logViewportHeight = 12
+
+	// failureLogLines is how much output is expanded when a deployment fails
+	failureLogLines = 200
+)
+
+// logLine is one line of command output and the step that produced it
+type logLine struct {
+	step string
+	text string
+}
+
+// appendLog buffers a line of command output under the current step. When the
+// user has scrolled up the view stays on the same lines as new output arrives.
+func (m *progressModel) appendLog(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	step := m.currentStep
+	if len(m.stepHistory) > 0 {
+		step = m.stepHistory[len(m.stepHistory)-1].description
+	}
+	m.logLines = append(m.logLines, logLine{step: step, text: text})
+
+	if m.logOffset > 0 {
+		m.logOffset++
+	}
+}
+
+// scrollLog moves the viewport by delta lines (positive scrolls back in time)
+func (m *progressModel) scrollLog(delta int) {
+	m.logOffset += delta
+
+	maxOffset := len(m.logLines) - logViewportHeight
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+	if m.logOffset > maxOffset {
+		m.logOffset = maxOffset
+	}
+	if m.logOffset < 0 {
+		m.logOffset = 0
+	}
+}
+
+// handleLogKey applies the log viewport key bindings and reports whether the key was used
+func (m *progressModel) handleLogKey(key string) bool {
+	if key == "l" {
+		m.showLogs = !m.showLogs
+		return true
+	}
+	if !m.showLogs {
+		return false
+	}
+
+	switch key {
+	case "up", "k":
+		m.scrollLog(1)
+	case "down", "j":
+		m.scrollLog(-1)
+	case "pgup":
+		m.scrollLog(logViewportHeight)
+	case "pgdown":
+		m.scrollLog(-logViewportHeight)
+	case "home", "g":
+		m.scrollLog(len(m.logLines))
+	case "end", "G":
+		m.logOffset = 0
+	default:
+		return false
+	}
+	return true
+}
+
+// visibleLogLines returns the window of output the viewport currently shows
+func (m progressModel) visibleLogLines() []logLine {
+	end := len(m.logLines) - m.logOffset
+	start := end - logViewportHeight
+	if start < 0 {
+		start = 0
+	}
+	return m.logLines[start:end]
+}
+
+// renderLogView renders the running viewport, or a hint to open it
+func (m progressModel) renderLogView() string {
+	mutedStyle := style.Muted
+
+	if !m.showLogs {
+		if len(m.logLines) == 0 {
+			return ""
+		}
+		return mutedStyle.Render(fmt.Sprintf("Press l to show build output (%d lines)", len(m.logLines))) + "\n\n"
+	}
+
+	visible := m.visibleLogLines()
+	end := len(m.logLines) - m.logOffset
+	header := fmt.Sprintf("Build output — lines %d-%d of %d (↑/↓ PgUp/PgDn scroll, l hide)", end-len(visible)+1, end, len(m.logLines))
+	if len(visible) == 0 {
+		header = "Build output — no output yet (l hide)"
+	}
+
+	return mutedStyle.Render(header) + "\n" + m.renderLogBox(visible, false) + "\n\n"
+}
+
+// renderFailureLog expands the tail of the output after a failed deployment,
+// highlighting the lines that look like errors
+func (m progressModel) renderFailureLog() string {
+	if len(m.logLines) == 0 {
+		return ""
+	}
+
+	lines := m.logLines
+	if len(lines) > failureLogLines {
+		lines = lines[len(lines)-failureLogLines:]
+	}
+
+	mutedStyle := style.Muted
+	header := fmt.Sprintf("Last %d lines of output:", len(lines))
+	return mutedStyle.Render(header) + "\n" + m.renderLogBox(lines, true) + "\n\n"
+}
+
+func (m progressModel) renderLogBox(lines []logLine, highlight bool) string {
+	stepStyle := style.Header
+	textStyle := style.Fg("250")
+	errorStyle := style.Fg("203").Bold(true)
+
+	maxWidth := m.termWidth - 4
+	if maxWidth < 40 {
+		maxWidth = 116
+	}
+
+	var out []string
+	lastStep := ""
+	for _, line := range lines {
+		if line.step != lastStep {
+			out = append(out, stepStyle.Render("▸ "+truncateLogLine(line.step, maxWidth)))
+			lastStep = line.step
+		}
+		text := truncateLogLine(line.text, maxWidth)
+		if highlight && isErrorLine(line.text) {
+			out = append(out, errorStyle.Render(text))
+		} else {
+			out = append(out, textStyle.Render(text))
+		}
+	}
+
+	return style.Box(style.ColorFaint).
+		BorderTop(false).BorderRight(false).BorderBottom(false).
+		PaddingLeft(1).
+		Render(strings.Join(out, "\n"))
+}
+
+func truncateLogLine(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// isErrorLine reports whether a line of build output looks like an error
+func isErrorLine(text string) bool {
+	lower := strings.ToLower(text)
+	for _, marker := range []string{"error", "err!", "failed", "fatal", "traceback", "exception", "panic:"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBuildLog saves the buffered output to ~/.lightfold/logs/<target>/ and
+// returns the file path
+func writeBuildLog(targetName string, lines []logLine) (string, error) {
+	if len(lines) == 0 {
+		return "", nil
+	}
+	if targetName == "" {
+		targetName = "default"
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	logDir := filepath.Join(homeDir, config.LocalConfigDir, "logs", filepath.Base(targetName))
+	if err := os.MkdirAll(logDir, config.PermDirectory); err != nil {
+		return "", fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	var b strings.Builder
+	lastStep := ""
+	for _, line := range lines {
+		if line.step != lastStep {
+			fmt.Fprintf(&b, "==> %s\n", line.step)
+			lastStep = line.step
+		}
+		b.WriteString(line.text)
+		b.WriteString("\n")
+	}
+
+	logPath := filepath.Join(logDir, fmt.Sprintf("build-%s.log", time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(logPath, []byte(b.String()), config.PermConfigFile); err != nil {
+		return "", fmt.Errorf("failed to write build log: %w", err)
+	}
+	return logPath, nil
+}
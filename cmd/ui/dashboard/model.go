@@ -0,0 +1,335 @@
+// Package dashboard is the target overview bare `lightfold` opens in a
+// terminal: a table of every target whose service state is read in the
+// background, with keys to act on the selected target.
+package dashboard
+
+import (
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/timefmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Row is one target in the table
+type Row struct {
+	Target     string
+	Provider   string
+	Framework  string
+	ServerIP   string
+	Domain     string
+	URL        string // Where "open" points the browser, empty when unknown
+	LastDeploy time.Time
+	Service    string // Service state, e.g. active, failed, paused
+	Uptime     string // How long the service has been active
+	Err        string // Why the server could not be read
+	Loading    bool   // The service state is being read
+}
+
+// ActionKind is a command the dashboard runs for the selected target
+type ActionKind int
+
+const (
+	ActionStatus ActionKind = iota
+	ActionPush
+	ActionLogs
+)
+
+func (k ActionKind) String() string {
+	switch k {
+	case ActionPush:
+		return "push"
+	case ActionLogs:
+		return "logs"
+	default:
+		return "status"
+	}
+}
+
+// Action is a command to run for a target
+type Action struct {
+	Kind   ActionKind
+	Target string
+}
+
+// ActionDoneMsg reports that an action returned. Run's command sends it.
+type ActionDoneMsg struct {
+	Action Action
+	Err    error
+}
+
+// Options are what the dashboard reads rows with and acts through
+type Options struct {
+	// Rows are the targets with their local state; their service state is
+	// read with Fetch
+	Rows []Row
+	// Fetch reads a target's local state and service state again. It runs in
+	// the background, once per row.
+	Fetch func(target string) Row
+	// Run returns the command that runs an action, usually through
+	// tea.ExecProcess so it gets the terminal
+	Run func(Action) tea.Cmd
+	// Open opens a URL in the browser
+	Open func(url string) error
+	// Now is the clock deploy ages are measured against
+	Now func() time.Time
+}
+
+// rowMsg carries a row read by Fetch
+type rowMsg struct {
+	row Row
+}
+
+// Model is the dashboard's bubbletea model
+type Model struct {
+	opts    Options
+	rows    []Row
+	cursor  int
+	spinner spinner.Model
+	notice  string
+}
+
+// New returns a dashboard over the rows in opts
+func New(opts Options) Model {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	s := spinner.New()
+	s.Spinner = style.Spinner()
+	s.Style = style.Brand
+
+	rows := make([]Row, len(opts.Rows))
+	copy(rows, opts.Rows)
+	return Model{opts: opts, rows: rows, spinner: s}
+}
+
+// Rows returns the rows as currently shown
+func (m Model) Rows() []Row {
+	return m.rows
+}
+
+// Selected returns the row under the cursor
+func (m Model) Selected() (Row, bool) {
+	if len(m.rows) == 0 {
+		return Row{}, false
+	}
+	return m.rows[m.cursor], true
+}
+
+// Notice returns the line shown under the table, e.g. why an action failed
+func (m Model) Notice() string {
+	return m.notice
+}
+
+func (m Model) Init() tea.Cmd {
+	cmds := []tea.Cmd{m.spinner.Tick}
+	for i := range m.rows {
+		if cmd := m.fetch(i); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// fetch marks row i as loading and returns the command reading it again
+func (m *Model) fetch(i int) tea.Cmd {
+	if m.opts.Fetch == nil {
+		return nil
+	}
+	m.rows[i].Loading = true
+	fetch, target := m.opts.Fetch, m.rows[i].Target
+	return func() tea.Msg {
+		return rowMsg{row: fetch(target)}
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+
+	case rowMsg:
+		for i := range m.rows {
+			if m.rows[i].Target == msg.row.Target {
+				m.rows[i] = msg.row
+				m.rows[i].Loading = false
+			}
+		}
+		return m, nil
+
+	case ActionDoneMsg:
+		m.notice = ""
+		if msg.Err != nil {
+			m.notice = fmt.Sprintf("%s %s failed: %v", msg.Action.Kind, msg.Action.Target, msg.Err)
+		}
+		// A push changes the deploy and the service state
+		if msg.Action.Kind == ActionPush {
+			for i := range m.rows {
+				if m.rows[i].Target == msg.Action.Target {
+					return m, m.fetch(i)
+				}
+			}
+		}
+		return m, nil
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+		return m, nil
+	case "down", "j":
+		if m.cursor < len(m.rows)-1 {
+			m.cursor++
+		}
+		return m, nil
+	case "r":
+		m.notice = ""
+		var cmds []tea.Cmd
+		for i := range m.rows {
+			cmds = append(cmds, m.fetch(i))
+		}
+		return m, tea.Batch(cmds...)
+	}
+
+	row, ok := m.Selected()
+	if !ok {
+		return m, nil
+	}
+	switch msg.String() {
+	case "enter", "s":
+		return m, m.run(ActionStatus, row.Target)
+	case "p":
+		return m, m.run(ActionPush, row.Target)
+	case "l":
+		return m, m.run(ActionLogs, row.Target)
+	case "o":
+		m.notice = m.open(row)
+	}
+	return m, nil
+}
+
+// open opens the row's URL in the browser and returns the notice to show
+func (m Model) open(row Row) string {
+	if row.URL == "" {
+		return fmt.Sprintf("%s has no URL to open yet", row.Target)
+	}
+	if m.opts.Open == nil {
+		return ""
+	}
+	if err := m.opts.Open(row.URL); err != nil {
+		return fmt.Sprintf("Could not open %s: %v", row.URL, err)
+	}
+	return "Opened " + row.URL
+}
+
+func (m Model) run(kind ActionKind, target string) tea.Cmd {
+	if m.opts.Run == nil {
+		return nil
+	}
+	return m.opts.Run(Action{Kind: kind, Target: target})
+}
+
+var columns = []string{"TARGET", "PROVIDER", "FRAMEWORK", "SERVER", "SERVICE", "DEPLOYED", "DOMAIN"}
+
+func (m Model) View() string {
+	var b strings.Builder
+	b.WriteString(style.Badge.Render(" LIGHTFOLD ") + " " + style.Muted.Render(fmt.Sprintf("%d targets", len(m.rows))) + "\n\n")
+
+	if len(m.rows) == 0 {
+		b.WriteString(style.Muted.Render("No targets yet. Run 'lightfold deploy' in a project to create one.") + "\n")
+	} else {
+		cells := make([][]string, len(m.rows))
+		for i, row := range m.rows {
+			cells[i] = []string{row.Target, row.Provider, row.Framework, orDash(row.ServerIP), m.serviceCell(row), deployAge(row.LastDeploy, m.opts.Now()), orDash(row.Domain)}
+		}
+		widths := make([]int, len(columns))
+		for c, title := range columns {
+			widths[c] = lipgloss.Width(title)
+			for _, cell := range cells {
+				widths[c] = max(widths[c], lipgloss.Width(cell[c]))
+			}
+		}
+
+		header := make([]string, len(columns))
+		for c, title := range columns {
+			header[c] = pad(title, widths[c])
+		}
+		b.WriteString("  " + style.Header.Render(strings.Join(header, "  ")) + "\n")
+
+		for i := range m.rows {
+			line := make([]string, len(columns))
+			for c, cell := range cells[i] {
+				line[c] = pad(cell, widths[c])
+			}
+			prefix := "  "
+			if i == m.cursor {
+				prefix = style.Brand.Render(style.Play() + " ")
+				line[0] = style.Title.Render(line[0])
+			}
+			b.WriteString(prefix + strings.Join(line, "  ") + "\n")
+		}
+	}
+
+	if m.notice != "" {
+		b.WriteString("\n" + style.WarningText.Render(m.notice) + "\n")
+	}
+	keys := []string{"up/down select", "enter status", "p push", "l logs", "o open", "r refresh", "q quit"}
+	b.WriteString("\n" + style.Hint.Render(strings.Join(keys, " "+style.Bullet()+" ")) + "\n")
+	return b.String()
+}
+
+// serviceCell is the service column of a row. Each row being read shows its
+// own spinner.
+func (m Model) serviceCell(row Row) string {
+	switch {
+	case row.Loading:
+		return m.spinner.View() + " " + style.Muted.Render("checking")
+	case row.Err != "":
+		return style.ErrorText.Render("unreachable")
+	case row.Service == "active" && row.Uptime != "":
+		return style.Success.Render("active " + row.Uptime)
+	case row.Service == "active":
+		return style.Success.Render("active")
+	case row.Service == "" || row.Service == "paused":
+		return style.Muted.Render(orDash(row.Service))
+	}
+	return style.WarningText.Render(row.Service)
+}
+
+// deployAge is how long ago the target was last deployed
+func deployAge(at, now time.Time) string {
+	if at.IsZero() {
+		return "never"
+	}
+	return timefmt.Relative(at, now)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func pad(s string, width int) string {
+	if gap := width - lipgloss.Width(s); gap > 0 {
+		return s + strings.Repeat(" ", gap)
+	}
+	return s
+}
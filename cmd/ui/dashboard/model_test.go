@@ -0,0 +1,235 @@
+package dashboard
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var testNow = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func press(m Model, s string) (Model, tea.Cmd) {
+	updated, cmd := m.Update(key(s))
+	return updated.(Model), cmd
+}
+
+// runCmds runs a command and the commands of the batches it returns, and
+// collects the messages that are not spinner ticks
+func runCmds(cmd tea.Cmd) []tea.Msg {
+	var msgs []tea.Msg
+	queue := []tea.Cmd{cmd}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if next == nil {
+			continue
+		}
+		switch msg := next().(type) {
+		case tea.BatchMsg:
+			queue = append(queue, msg...)
+		case rowMsg, ActionDoneMsg:
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func testRows() []Row {
+	return []Row{
+		{Target: "api", Provider: "hetzner", Framework: "FastAPI", ServerIP: "203.0.113.10", LastDeploy: testNow.Add(-3 * time.Hour)},
+		{Target: "web", Provider: "digitalocean", Framework: "Next.js", ServerIP: "203.0.113.20", Domain: "example.com", URL: "https://example.com"},
+	}
+}
+
+func TestActionKeys(t *testing.T) {
+	tests := []struct {
+		keys   []string
+		want   Action
+		noCall bool
+	}{
+		{keys: []string{"enter"}, want: Action{Kind: ActionStatus, Target: "api"}},
+		{keys: []string{"s"}, want: Action{Kind: ActionStatus, Target: "api"}},
+		{keys: []string{"down", "p"}, want: Action{Kind: ActionPush, Target: "web"}},
+		{keys: []string{"j", "l"}, want: Action{Kind: ActionLogs, Target: "web"}},
+		{keys: []string{"j", "j", "k", "p"}, want: Action{Kind: ActionPush, Target: "api"}},
+		{keys: []string{"x"}, noCall: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.keys, " "), func(t *testing.T) {
+			var got []Action
+			m := New(Options{Rows: testRows(), Run: func(a Action) tea.Cmd {
+				got = append(got, a)
+				return func() tea.Msg { return ActionDoneMsg{Action: a} }
+			}})
+
+			var cmd tea.Cmd
+			for _, k := range tt.keys {
+				m, cmd = press(m, k)
+			}
+			if tt.noCall {
+				if len(got) != 0 || cmd != nil {
+					t.Errorf("key %v ran %v", tt.keys, got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("actions = %v, want %v", got, tt.want)
+			}
+			if cmd == nil {
+				t.Error("the action's command was not returned")
+			}
+		})
+	}
+}
+
+func TestOpenKey(t *testing.T) {
+	var opened []string
+	m := New(Options{Rows: testRows(), Open: func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}})
+
+	m, _ = press(m, "o")
+	if len(opened) != 0 || !strings.Contains(m.Notice(), "no URL") {
+		t.Errorf("opening a row without a URL: opened %v, notice %q", opened, m.Notice())
+	}
+
+	m, _ = press(m, "j")
+	m, _ = press(m, "o")
+	if len(opened) != 1 || opened[0] != "https://example.com" {
+		t.Errorf("opened = %v, want https://example.com", opened)
+	}
+
+	m.opts.Open = func(string) error { return errors.New("no browser") }
+	m, _ = press(m, "o")
+	if !strings.Contains(m.Notice(), "no browser") {
+		t.Errorf("notice = %q, want the open error", m.Notice())
+	}
+}
+
+func TestQuitKeys(t *testing.T) {
+	for _, k := range []string{"q", "esc", "ctrl+c"} {
+		msg := key(k)
+		switch k {
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "ctrl+c":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlC}
+		}
+		_, cmd := New(Options{Rows: testRows()}).Update(msg)
+		if cmd == nil {
+			t.Fatalf("%s returned no command", k)
+		}
+		if _, ok := cmd().(tea.QuitMsg); !ok {
+			t.Errorf("%s did not quit", k)
+		}
+	}
+}
+
+func TestAsyncRowUpdates(t *testing.T) {
+	fetched := map[string]Row{
+		"api": {Target: "api", Provider: "hetzner", Service: "active", Uptime: "2h 5m"},
+		"web": {Target: "web", Provider: "digitalocean", Err: "dial tcp: i/o timeout"},
+	}
+	m := New(Options{Rows: testRows(), Now: func() time.Time { return testNow }, Fetch: func(target string) Row {
+		return fetched[target]
+	}})
+
+	cmd := m.Init()
+	for _, row := range m.Rows() {
+		if !row.Loading {
+			t.Errorf("%s should be loading until its service state arrives", row.Target)
+		}
+	}
+	if view := m.View(); strings.Count(view, "checking") != 2 {
+		t.Errorf("every loading row should show a spinner:\n%s", view)
+	}
+
+	msgs := runCmds(cmd)
+	if len(msgs) != 2 {
+		t.Fatalf("got %d row messages, want 2", len(msgs))
+	}
+
+	// The first row arrives while the second is still loading
+	updated, _ := m.Update(msgs[0])
+	m = updated.(Model)
+	loading := 0
+	for _, row := range m.Rows() {
+		if row.Loading {
+			loading++
+		}
+	}
+	if loading != 1 {
+		t.Errorf("%d rows loading after one update, want 1", loading)
+	}
+
+	updated, _ = m.Update(msgs[1])
+	m = updated.(Model)
+	view := m.View()
+	for _, want := range []string{"active 2h 5m", "unreachable"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view should show %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "checking") {
+		t.Errorf("no row should be loading:\n%s", view)
+	}
+}
+
+func TestPushRefreshesRow(t *testing.T) {
+	fetches := 0
+	m := New(Options{Rows: testRows(), Fetch: func(target string) Row {
+		fetches++
+		return Row{Target: target, Service: "active", LastDeploy: testNow}
+	}})
+
+	updated, cmd := m.Update(ActionDoneMsg{Action: Action{Kind: ActionLogs, Target: "api"}})
+	if cmd != nil {
+		t.Error("logs should not read the row again")
+	}
+
+	updated, cmd = updated.Update(ActionDoneMsg{Action: Action{Kind: ActionPush, Target: "api"}, Err: errors.New("exit status 1")})
+	m = updated.(Model)
+	if !m.Rows()[0].Loading || m.Rows()[1].Loading {
+		t.Error("a push should reload only its own row")
+	}
+	if !strings.Contains(m.Notice(), "push api failed") {
+		t.Errorf("notice = %q, want the push failure", m.Notice())
+	}
+
+	for _, msg := range runCmds(cmd) {
+		updated, _ = m.Update(msg)
+		m = updated.(Model)
+	}
+	if fetches != 1 || m.Rows()[0].Service != "active" || m.Rows()[0].LastDeploy != testNow {
+		t.Errorf("row after push = %+v, fetches %d", m.Rows()[0], fetches)
+	}
+}
+
+func TestDeployAge(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{want: "never"},
+		{at: testNow.Add(-3 * time.Hour), want: "3h ago"},
+	}
+	for _, tt := range tests {
+		if got := deployAge(tt.at, testNow); got != tt.want {
+			t.Errorf("deployAge(%v) = %q, want %q", tt.at, got, tt.want)
+		}
+	}
+}
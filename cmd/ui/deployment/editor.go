@@ -2,22 +2,22 @@ package deployment
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/detector"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	titleStyle        = lipgloss.NewStyle().Background(lipgloss.Color("#01FAC6")).Foreground(lipgloss.Color("#030303")).Bold(true).Padding(0, 1, 0)
-	focusedStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(1).Foreground(lipgloss.Color("170")).Bold(true)
-	descriptionStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#40BDA3"))
-	helpStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
-	successStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("46")).Bold(true)
-	errorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	mutedStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	titleStyle        = style.Badge.Padding(0, 1, 0)
+	focusedStyle      = style.Title
+	selectedItemStyle = style.Value.Bold(true).PaddingLeft(1)
+	descriptionStyle  = style.Fg("#40BDA3")
+	helpStyle         = style.Fg("241")
+	successStyle      = style.Fg("46").Bold(true)
+	errorStyle        = style.Error
+	mutedStyle        = style.Hint
 )
 
 type EditorMode int
@@ -249,9 +249,7 @@ func (m model) renderReview() string {
 	s.WriteString("\n\n")
 
 	// Framework info box
-	frameworkBox := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#01FAC6")).
+	frameworkBox := style.Box(style.ColorBrand).
 		Padding(1, 2).
 		Width(60)
 
@@ -313,9 +311,7 @@ func (m model) renderEdit(isBuild bool) string {
 
 		if m.editingIndex == i {
 			// Show editing state
-			editBox := lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("#01FAC6")).
+			editBox := style.Box(style.ColorBrand).
 				Padding(0, 1).
 				Width(50)
 			s.WriteString(cursor)
@@ -399,8 +395,8 @@ func (m model) renderSuccess() string {
 	s.WriteString(successStyle.Render("Deployment configuration saved"))
 	s.WriteString("\n\n")
 
-	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
-	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	labelStyle := style.Label
+	valueStyle := style.Text
 
 	s.WriteString(labelStyle.Render("Build Commands:"))
 	s.WriteString("\n")
@@ -3,7 +3,9 @@ package tui
 import (
 	"context"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
 	"lightfold/pkg/deploy"
 	"strings"
 	"time"
@@ -30,6 +32,10 @@ type progressModel struct {
 	skipInit          bool
 	completionMessage string
 	alternateText     bool // For alternating step descriptions
+	logLines          []logLine
+	showLogs          bool
+	logOffset         int // Lines scrolled back from the newest output
+	termWidth         int
 }
 
 type stepHistoryItem struct {
@@ -86,6 +92,12 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+c", "q":
 			return m, tea.Quit
 		}
+		m.handleLogKey(msg.String())
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.termWidth = msg.Width
+		return m, nil
 
 	case spinner.TickMsg:
 		var cmd tea.Cmd
@@ -94,16 +106,24 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case stepUpdateMsg:
 		if msg.step.Progress == -1 {
+			m.appendLog(msg.step.Description)
 			return m, nil
 		}
 
 		m.progress = float64(msg.step.Progress)
 		m.currentStep = msg.step.Description
 
-		if len(m.stepHistory) > 0 && m.stepHistory[len(m.stepHistory)-1].status == "in_progress" {
-			m.stepHistory[len(m.stepHistory)-1].status = "success"
+		// A step reporting again (e.g. elapsed time while connecting) updates its line
+		if len(m.stepHistory) > 0 {
+			last := &m.stepHistory[len(m.stepHistory)-1]
+			if last.status == "in_progress" && last.name == msg.step.Name {
+				last.description = msg.step.Description
+				return m, nil
+			}
 		}
 
+		m.finishLastStep("success")
+
 		m.stepHistory = append(m.stepHistory, stepHistoryItem{
 			name:        msg.step.Name,
 			description: msg.step.Description,
@@ -111,9 +131,7 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 
 		if msg.step.Progress >= 100 {
-			if len(m.stepHistory) > 0 {
-				m.stepHistory[len(m.stepHistory)-1].status = "success"
-			}
+			m.finishLastStep("success")
 		}
 
 		var cmd tea.Cmd
@@ -173,8 +191,11 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			m.err = msg.err
 			m.completed = true
-			if len(m.stepHistory) > 0 && m.stepHistory[len(m.stepHistory)-1].status == "in_progress" {
-				m.stepHistory[len(m.stepHistory)-1].status = "error"
+			m.showLogs = true
+			m.logOffset = 0
+			m.finishLastStep("error")
+			if style.Plain() {
+				fmt.Print(m.renderFailureLog())
 			}
 			return m, tea.Quit
 		}
@@ -187,8 +208,9 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.currentStep = "Deployment complete!"
 		}
-		if len(m.stepHistory) > 0 && m.stepHistory[len(m.stepHistory)-1].status == "in_progress" {
-			m.stepHistory[len(m.stepHistory)-1].status = "success"
+		m.finishLastStep("success")
+		if style.Plain() {
+			return m, tea.Quit
 		}
 		m.countdownSecs = 3
 		return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
@@ -211,30 +233,54 @@ func (m progressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// finishLastStep settles the step in progress. Without a renderer in plain
+// mode, the step is printed as it finishes instead.
+func (m *progressModel) finishLastStep(status string) {
+	if len(m.stepHistory) == 0 || m.stepHistory[len(m.stepHistory)-1].status != "in_progress" {
+		return
+	}
+	last := &m.stepHistory[len(m.stepHistory)-1]
+	last.status = status
+	if style.Plain() {
+		icon := style.Check()
+		if status == "error" {
+			icon = style.Cross()
+		}
+		fmt.Printf("%s %s\n", icon, last.description)
+	}
+}
+
+// newProgressProgram runs the progress view, or in plain mode only its model:
+// redrawing a bar makes no sense in a CI log or without colors
+func newProgressProgram(m progressModel) *tea.Program {
+	if style.Plain() {
+		return tea.NewProgram(m, tea.WithoutRenderer(), tea.WithInput(nil))
+	}
+	return tea.NewProgram(m)
+}
+
 func (m progressModel) View() string {
 	var s strings.Builder
 
 	if !m.completed {
 	} else {
 		if m.err != nil {
-			headerStyle := lipgloss.NewStyle().
-				Bold(true).
-				Foreground(lipgloss.Color("196"))
+			headerStyle := style.Error
 			s.WriteString(headerStyle.Render("Deployment failed!"))
 			s.WriteString("\n\n")
-			errorStyle := lipgloss.NewStyle().
-				Foreground(lipgloss.Color("203"))
+			errorStyle := style.Fg("203")
 			s.WriteString(errorStyle.Render(fmt.Sprintf("Error: %v", m.err)))
 			s.WriteString("\n\n")
+			s.WriteString(m.renderFailureLog())
 		}
 		// Don't show completion message for successful completions
 	}
 
 	if len(m.stepHistory) > 0 {
-		successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-		inProgressStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("226"))
-		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-		mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+		successStyle := style.Success
+		inProgressStyle := style.Fg("226")
+		errorStyle := style.ErrorText
+		mutedStyle := style.Muted
 
 		for _, step := range m.stepHistory {
 			if !m.completed && step.status == "success" {
@@ -245,13 +291,13 @@ func (m progressModel) View() string {
 			var iconStyle lipgloss.Style
 			switch step.status {
 			case "success":
-				icon = "✓"
+				icon = style.Check()
 				iconStyle = successStyle
 			case "in_progress":
 				icon = m.spinner.View()
 				iconStyle = inProgressStyle
 			case "error":
-				icon = "✗"
+				icon = style.Cross()
 				iconStyle = errorStyle
 			}
 			s.WriteString(iconStyle.Render(fmt.Sprintf("%s ", icon)))
@@ -269,6 +315,10 @@ func (m progressModel) View() string {
 	barWidth := m.width - 10
 	filledWidth := int((progressPercent / 100) * float64(barWidth))
 
+	filled, empty := "█", "░"
+	if style.Plain() {
+		filled, empty = "#", "-"
+	}
 	colors := []string{"129", "63", "39", "33", "45", "51", "50", "49", "48", "47", "46", "82"}
 
 	var bar strings.Builder
@@ -278,22 +328,25 @@ func (m progressModel) View() string {
 			colorIndex = len(colors) - 1
 		}
 
-		colorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(colors[colorIndex]))
-		bar.WriteString(colorStyle.Render("█"))
+		colorStyle := style.Fg(colors[colorIndex])
+		bar.WriteString(colorStyle.Render(filled))
 	}
 
-	emptyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	emptyStyle := style.Faint
 	for i := filledWidth; i < barWidth; i++ {
-		bar.WriteString(emptyStyle.Render("░"))
+		bar.WriteString(emptyStyle.Render(empty))
 	}
 
 	s.WriteString(bar.String())
 	s.WriteString(fmt.Sprintf(" %.0f%%", progressPercent))
 	s.WriteString("\n\n")
 
+	if !m.completed {
+		s.WriteString(m.renderLogView())
+	}
+
 	if m.completed && m.err == nil {
-		countdownStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("245"))
+		countdownStyle := style.Muted
 		s.WriteString(countdownStyle.Render(fmt.Sprintf("Exiting in %d seconds...", m.countdownSecs)))
 	}
 
@@ -343,8 +396,8 @@ func (m progressModel) startDeployment() tea.Cmd {
 
 func ShowDeploymentProgress() error {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	s.Spinner = style.Spinner()
+	s.Style = style.Label
 
 	m := progressModel{
 		progress:    0,
@@ -353,15 +406,15 @@ func ShowDeploymentProgress() error {
 		spinner:     s,
 	}
 
-	p := tea.NewProgram(m)
+	p := newProgressProgram(m)
 	_, err := p.Run()
 	return err
 }
 
 func ShowDeploymentProgressWithOrchestrator(ctx context.Context, orchestrator *deploy.Orchestrator) error {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	s.Spinner = style.Spinner()
+	s.Style = style.Label
 
 	m := progressModel{
 		progress:     0,
@@ -372,7 +425,7 @@ func ShowDeploymentProgressWithOrchestrator(ctx context.Context, orchestrator *d
 		spinner:      s,
 	}
 
-	p := tea.NewProgram(m)
+	p := newProgressProgram(m)
 	m.program = p
 
 	orchestrator.SetProgressCallback(func(step deploy.DeploymentStep) {
@@ -386,8 +439,11 @@ func ShowDeploymentProgressWithOrchestrator(ctx context.Context, orchestrator *d
 		return err
 	}
 
-	if final, ok := finalModel.(progressModel); ok && final.err != nil {
-		return final.err
+	if final, ok := finalModel.(progressModel); ok {
+		saveBuildLog(orchestrator.TargetName(), final)
+		if final.err != nil {
+			return final.err
+		}
 	}
 
 	return nil
@@ -395,8 +451,8 @@ func ShowDeploymentProgressWithOrchestrator(ctx context.Context, orchestrator *d
 
 func ShowConfigurationProgressWithOrchestrator(ctx context.Context, orchestrator *deploy.Orchestrator, providerCfg config.ProviderConfig) error {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	s.Spinner = style.Spinner()
+	s.Style = style.Label
 
 	m := progressModel{
 		progress:          0,
@@ -409,7 +465,7 @@ func ShowConfigurationProgressWithOrchestrator(ctx context.Context, orchestrator
 		completionMessage: "",
 	}
 
-	p := tea.NewProgram(m)
+	p := newProgressProgram(m)
 	m.program = p
 
 	orchestrator.SetProgressCallback(func(step deploy.DeploymentStep) {
@@ -418,18 +474,21 @@ func ShowConfigurationProgressWithOrchestrator(ctx context.Context, orchestrator
 		}
 	})
 
-	go func() {
+	crash.Go(func() {
 		result, err := orchestrator.ConfigureServer(ctx, providerCfg)
 		p.Send(deployResultMsg{result: result, err: err})
-	}()
+	})
 
 	finalModel, err := p.Run()
 	if err != nil {
 		return err
 	}
 
-	if final, ok := finalModel.(progressModel); ok && final.err != nil {
-		return final.err
+	if final, ok := finalModel.(progressModel); ok {
+		saveBuildLog(orchestrator.TargetName(), final)
+		if final.err != nil {
+			return final.err
+		}
 	}
 
 	return nil
@@ -437,8 +496,8 @@ func ShowConfigurationProgressWithOrchestrator(ctx context.Context, orchestrator
 
 func ShowProvisioningProgressWithOrchestrator(ctx context.Context, orchestrator *deploy.Orchestrator) (*deploy.DeploymentResult, error) {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	s.Spinner = style.Spinner()
+	s.Style = style.Label
 
 	m := progressModel{
 		progress:          0,
@@ -451,7 +510,7 @@ func ShowProvisioningProgressWithOrchestrator(ctx context.Context, orchestrator
 		completionMessage: "",
 	}
 
-	p := tea.NewProgram(m)
+	p := newProgressProgram(m)
 	m.program = p
 
 	orchestrator.SetProgressCallback(func(step deploy.DeploymentStep) {
@@ -460,10 +519,10 @@ func ShowProvisioningProgressWithOrchestrator(ctx context.Context, orchestrator
 		}
 	})
 
-	go func() {
+	crash.Go(func() {
 		result, err := orchestrator.Deploy(ctx)
 		p.Send(deployResultMsg{result: result, err: err})
-	}()
+	})
 
 	finalModel, err := p.Run()
 	if err != nil {
@@ -471,6 +530,7 @@ func ShowProvisioningProgressWithOrchestrator(ctx context.Context, orchestrator
 	}
 
 	if final, ok := finalModel.(progressModel); ok {
+		saveBuildLog(orchestrator.TargetName(), final)
 		if final.err != nil {
 			return nil, final.err
 		}
@@ -479,3 +539,14 @@ func ShowProvisioningProgressWithOrchestrator(ctx context.Context, orchestrator
 
 	return nil, fmt.Errorf("unexpected model type")
 }
+
+// saveBuildLog persists the output buffered during a run, pointing at the file
+// when the run failed
+func saveBuildLog(targetName string, final progressModel) {
+	logPath, err := writeBuildLog(targetName, final.logLines)
+	if err != nil || logPath == "" || final.err == nil {
+		return
+	}
+	mutedStyle := style.Muted
+	fmt.Printf("%s\n", mutedStyle.Render("Full build log: "+logPath))
+}
@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/pkg/deploy"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func sendAll(t *testing.T, m progressModel, msgs ...tea.Msg) progressModel {
+	t.Helper()
+	for _, msg := range msgs {
+		updated, _ := m.Update(msg)
+		next, ok := updated.(progressModel)
+		if !ok {
+			t.Fatalf("Update returned %T, want progressModel", updated)
+		}
+		m = next
+	}
+	return m
+}
+
+func stepMsg(name, description string, progress int) tea.Msg {
+	return stepUpdateMsg{step: deploy.DeploymentStep{Name: name, Description: description, Progress: progress}}
+}
+
+func outputMsg(line string) tea.Msg {
+	return stepMsg("command_output", line, -1)
+}
+
+func newTestProgressModel() progressModel {
+	return progressModel{maxProgress: 100, width: 60, skipInit: true}
+}
+
+func TestProgressModelBuffersOutputPerStep(t *testing.T) {
+	m := sendAll(t, newTestProgressModel(),
+		stepMsg("install_packages", "Installing packages...", 20),
+		outputMsg("  Reading package lists..."),
+		outputMsg("  "),
+		stepMsg("build_app", "Building app...", 50),
+		outputMsg("  > next build"),
+	)
+
+	if len(m.logLines) != 2 {
+		t.Fatalf("logLines = %+v, want 2 non-empty lines", m.logLines)
+	}
+	if m.logLines[0].step != "Installing packages..." || m.logLines[0].text != "Reading package lists..." {
+		t.Errorf("first line = %+v", m.logLines[0])
+	}
+	if m.logLines[1].step != "Building app..." {
+		t.Errorf("second line step = %q, want the build step", m.logLines[1].step)
+	}
+	if m.progress != 50 {
+		t.Errorf("output lines changed progress to %v", m.progress)
+	}
+}
+
+func TestProgressModelLogToggleAndScroll(t *testing.T) {
+	m := sendAll(t, newTestProgressModel(), stepMsg("build_app", "Building app...", 50))
+	for i := 1; i <= 40; i++ {
+		m = sendAll(t, m, outputMsg(fmt.Sprintf("line %d", i)))
+	}
+
+	if strings.Contains(m.View(), "line 40") {
+		t.Error("output shown before the log viewport was opened")
+	}
+	if !strings.Contains(m.View(), "Press l to show build output") {
+		t.Error("missing hint to open the log viewport")
+	}
+
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if !m.showLogs {
+		t.Fatal("l did not open the log viewport")
+	}
+	view := m.View()
+	if !strings.Contains(view, "line 40") || strings.Count(view, "line ") != logViewportHeight {
+		t.Errorf("viewport should show the newest %d lines:\n%s", logViewportHeight, view)
+	}
+
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyPgUp})
+	visible := m.visibleLogLines()
+	if visible[len(visible)-1].text != "line 28" {
+		t.Errorf("after PgUp last visible = %q, want line 28", visible[len(visible)-1].text)
+	}
+
+	// New output keeps the scrolled position
+	m = sendAll(t, m, outputMsg("line 41"))
+	visible = m.visibleLogLines()
+	if visible[len(visible)-1].text != "line 28" {
+		t.Errorf("after new output last visible = %q, want line 28", visible[len(visible)-1].text)
+	}
+
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyHome})
+	if m.visibleLogLines()[0].text != "line 1" {
+		t.Errorf("home did not scroll to the first line")
+	}
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyUp})
+	if m.visibleLogLines()[0].text != "line 1" {
+		t.Errorf("scrolled past the first line")
+	}
+
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyEnd}, tea.KeyMsg{Type: tea.KeyDown})
+	visible = m.visibleLogLines()
+	if m.logOffset != 0 || visible[len(visible)-1].text != "line 41" {
+		t.Errorf("end should follow the newest output, offset = %d", m.logOffset)
+	}
+
+	m = sendAll(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if m.showLogs {
+		t.Error("l did not close the log viewport")
+	}
+}
+
+func TestProgressModelExpandsLogOnFailure(t *testing.T) {
+	m := sendAll(t, newTestProgressModel(), stepMsg("build_app", "Building app...", 50))
+	for i := 1; i <= 250; i++ {
+		m = sendAll(t, m, outputMsg(fmt.Sprintf("compiling module %d", i)))
+	}
+	m = sendAll(t, m, outputMsg("npm ERR! Build failed with exit code 1"))
+
+	updated, cmd := m.Update(deployResultMsg{err: errors.New("build failed")})
+	m = updated.(progressModel)
+
+	if cmd == nil {
+		t.Error("failure should quit the program")
+	}
+	if !m.showLogs || m.logOffset != 0 {
+		t.Errorf("failure should open the viewport at the newest output, showLogs=%v offset=%d", m.showLogs, m.logOffset)
+	}
+
+	view := m.View()
+	if !strings.Contains(view, "Last 200 lines of output") {
+		t.Errorf("failure view missing expanded log header:\n%s", view)
+	}
+	if got := strings.Count(view, "compiling module "); got != failureLogLines-1 {
+		t.Errorf("failure view holds %d build lines, want the last %d lines including the error", got, failureLogLines)
+	}
+	if !strings.Contains(view, "compiling module 52") {
+		t.Error("failure view missing the oldest line of the last 200")
+	}
+	if !strings.Contains(view, "npm ERR! Build failed") {
+		t.Error("failure view missing the error line")
+	}
+	if !isErrorLine("npm ERR! Build failed with exit code 1") || isErrorLine("compiling module 3") {
+		t.Error("isErrorLine misclassified output")
+	}
+}
+
+func TestWriteBuildLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	lines := []logLine{
+		{step: "Installing packages...", text: "Reading package lists..."},
+		{step: "Building app...", text: "> next build"},
+		{step: "Building app...", text: "Compiled successfully"},
+	}
+
+	logPath, err := writeBuildLog("myapp", lines)
+	if err != nil {
+		t.Fatalf("writeBuildLog() error: %v", err)
+	}
+	if filepath.Dir(logPath) != filepath.Join(home, ".lightfold", "logs", "myapp") {
+		t.Errorf("log path = %s", logPath)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "==> Installing packages...\nReading package lists...\n==> Building app...\n> next build\nCompiled successfully\n"
+	if string(data) != want {
+		t.Errorf("log contents = %q, want %q", data, want)
+	}
+
+	if logPath, err := writeBuildLog("myapp", nil); err != nil || logPath != "" {
+		t.Errorf("empty buffer wrote %q, %v", logPath, err)
+	}
+}
+
+func TestProgressModelUpdatesRepeatedStepInPlace(t *testing.T) {
+	m := sendAll(t, newTestProgressModel(),
+		stepMsg("connect_ssh", "Connecting to server at 1.2.3.4...", 10),
+		stepMsg("connect_ssh", "Connecting to server at 1.2.3.4: waiting for the SSH port to open (4s)...", 10),
+		stepMsg("connect_ssh", "Connecting to server at 1.2.3.4: waiting for the SSH port to open (9s)...", 10),
+	)
+
+	if len(m.stepHistory) != 1 {
+		t.Fatalf("stepHistory = %+v, want one connect step", m.stepHistory)
+	}
+	if got := m.stepHistory[0].description; !strings.Contains(got, "(9s)") {
+		t.Errorf("description = %q, want the latest elapsed time", got)
+	}
+	if m.stepHistory[0].status != "in_progress" {
+		t.Errorf("status = %q, want in_progress", m.stepHistory[0].status)
+	}
+}
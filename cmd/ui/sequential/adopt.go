@@ -0,0 +1,142 @@
+package sequential
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/state"
+	"strings"
+)
+
+// adoptableProviders lists providers offered for server adoption, in display order
+var adoptableProviders = []struct {
+	name  string
+	label string
+}{
+	{"digitalocean", "DigitalOcean"},
+	{"hetzner", "Hetzner"},
+	{"vultr", "Vultr"},
+	{"linode", "Linode"},
+}
+
+// CreateAdoptProviderStep creates a step for choosing the provider account to adopt a server from
+func CreateAdoptProviderStep(id string) Step {
+	var names, labels []string
+	for _, p := range adoptableProviders {
+		provider, err := providers.GetProvider(p.name, "")
+		if err != nil || !providers.SupportsServerListing(provider) {
+			continue
+		}
+		names = append(names, p.name)
+		labels = append(labels, p.label)
+	}
+
+	return NewStep(id, "Adopt Server From").
+		Type(StepTypeSelect).
+		Options(names...).
+		OptionLabels(labels...).
+		Required().
+		Build()
+}
+
+// CreateAdoptTokenStep creates the API token step for the provider being adopted from
+func CreateAdoptTokenStep(id, providerName string) Step {
+	switch providerName {
+	case "hetzner":
+		return CreateHetznerAPITokenStep(id)
+	case "vultr":
+		return CreateVultrAPITokenStep(id)
+	case "linode":
+		return CreateLinodeAPITokenStep(id)
+	default:
+		return CreateAPITokenStep(id)
+	}
+}
+
+// CreateAdoptServerStep lists servers on the provider account so one can be adopted
+func CreateAdoptServerStep(id, providerName, token string) Step {
+	builder := NewStep(id, "Select Server to Adopt").
+		Type(StepTypeSelect).
+		Required()
+
+	servers, err := listAdoptableServers(providerName, token)
+	if err != nil {
+		return builder.Description(fmt.Sprintf("Could not list servers: %v", err)).Build()
+	}
+	if len(servers) == 0 {
+		return builder.Description("No servers found on this account").Build()
+	}
+
+	var ids, labels, descs []string
+	for _, server := range servers {
+		ids = append(ids, server.ID)
+		labels = append(labels, adoptServerLabel(server))
+		descs = append(descs, adoptServerDescription(server))
+	}
+
+	return builder.
+		DefaultValue(ids[0]).
+		Options(ids...).
+		OptionLabels(labels...).
+		OptionDescriptions(descs...).
+		Build()
+}
+
+func listAdoptableServers(providerName, token string) ([]providers.Server, error) {
+	provider, err := providers.GetProvider(providerName, token)
+	if err != nil {
+		return nil, err
+	}
+
+	lister, ok := provider.(providers.ServerLister)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support listing servers", provider.DisplayName())
+	}
+
+	return lister.ListServers(context.Background())
+}
+
+func adoptServerLabel(server providers.Server) string {
+	name := server.Name
+	if name == "" {
+		name = server.ID
+	}
+	if server.PrimaryIP() == "" {
+		return fmt.Sprintf("%s (no public IP)", name)
+	}
+	return fmt.Sprintf("%s (%s)", name, server.PrimaryIP())
+}
+
+func adoptServerDescription(server providers.Server) string {
+	var parts []string
+	for _, part := range []string{server.Region, server.Size, server.Status} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if server.PrimaryIP() != "" && state.ServerStateExists(server.PrimaryIP()) {
+		parts = append(parts, "already known to lightfold")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// adoptSteps returns the steps that follow the adopt provider (and token) selection
+func adoptSteps(providerName, token string) []Step {
+	return []Step{
+		CreateAdoptServerStep("server_id", providerName, token),
+		CreateSSHKeyStep("ssh_key"),
+		CreateUsernameStep("username", "root"),
+	}
+}
+
+func buildAdoptConfig(results map[string]string, flow *DynamicProviderFlow) map[string]string {
+	sshKeyPath, sshKeyName := flow.GetSSHKeyInfo("ssh_key")
+
+	return map[string]string{
+		"provider":     results["adopt_provider"],
+		"server_id":    results["server_id"],
+		"ssh_key":      sshKeyPath,
+		"ssh_key_name": sshKeyName,
+		"username":     results["username"],
+	}
+}
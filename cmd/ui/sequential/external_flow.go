@@ -0,0 +1,158 @@
+package sequential
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/ssh"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// CreateExternalAPITokenStep asks for the token passed to an "exec:" provider plugin
+func CreateExternalAPITokenStep(id, displayName string) Step {
+	return NewStep(id, fmt.Sprintf("%s API Token", displayName)).
+		Type(StepTypePassword).
+		Placeholder("Token passed to the provider plugin").
+		Required().
+		Validate(ValidateRequired).
+		Build()
+}
+
+// CreateExternalRegionStep lists the plugin's regions, falling back to free text
+// when the plugin cannot list them
+func CreateExternalRegionStep(id string, provider providers.Provider) Step {
+	regions, err := provider.GetRegions(context.Background())
+	if err != nil || len(regions) == 0 {
+		return NewStep(id, fmt.Sprintf("%s Region", provider.DisplayName())).
+			Type(StepTypeText).
+			Required().
+			Validate(ValidateRequired).
+			Build()
+	}
+
+	var regionIDs []string
+	var regionDescs []string
+	for _, region := range regions {
+		regionIDs = append(regionIDs, region.ID)
+		regionDescs = append(regionDescs, region.Location)
+	}
+
+	return NewStep(id, fmt.Sprintf("%s Region", provider.DisplayName())).
+		Type(StepTypeSelect).
+		DefaultValue(regionIDs[0]).
+		Options(regionIDs...).
+		OptionDescriptions(regionDescs...).
+		Required().
+		Build()
+}
+
+// CreateExternalSizeStep is filled with the plugin's sizes for the selected
+// region when the flow reaches it
+func CreateExternalSizeStep(id, displayName string) Step {
+	return NewStep(id, fmt.Sprintf("%s Size", displayName)).
+		Type(StepTypeText).
+		Required().
+		Validate(ValidateRequired).
+		Build()
+}
+
+// RunProvisionExternalFlow collects the token, region and size for an "exec:"
+// plugin provider. The provider name is used as the token key.
+func RunProvisionExternalFlow(projectName, providerName string) (*config.ExternalConfig, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+
+	provider, err := providers.GetProvider(providerName, "")
+	if err != nil {
+		return nil, err
+	}
+	displayName := provider.DisplayName()
+
+	activeToken := tokens.GetToken(providerName)
+	if activeToken == "" {
+		tokenFlow := NewFlow(fmt.Sprintf("Configure %s", displayName), []Step{
+			CreateExternalAPITokenStep("api_token", displayName),
+		})
+		tokenFlow.SetProjectName(projectName)
+
+		p := tea.NewProgram(tokenFlow)
+		tokenModel, err := p.Run()
+		if err != nil {
+			return nil, err
+		}
+
+		tokenFinal := tokenModel.(FlowModel)
+		if tokenFinal.Cancelled {
+			return nil, fmt.Errorf("provisioning cancelled")
+		}
+
+		activeToken = tokenFinal.GetResults()["api_token"]
+		tokens.SetToken(providerName, activeToken)
+		if err := tokens.SaveTokens(); err != nil {
+			return nil, fmt.Errorf("failed to save API token: %w", err)
+		}
+	}
+
+	provider, err = providers.GetProvider(providerName, activeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := NewFlow(fmt.Sprintf("Provision %s Server", displayName), []Step{
+		CreateExternalRegionStep("region", provider),
+		CreateExternalSizeStep("size", displayName),
+	})
+	flow.SetProjectName(projectName)
+	flow.SetSizeProvider(providerName, activeToken)
+
+	p := tea.NewProgram(flow)
+	finalModel, err := p.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	final := finalModel.(FlowModel)
+	if final.Cancelled {
+		return nil, fmt.Errorf("provisioning cancelled")
+	}
+	if !final.Completed {
+		return nil, fmt.Errorf("provisioning not completed")
+	}
+
+	results := final.GetResults()
+
+	keyName := ssh.GetKeyName(projectName)
+	exists, err := ssh.KeyExists(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check SSH key existence: %w", err)
+	}
+
+	var keyPath string
+	if !exists {
+		keyPair, err := ssh.GenerateKeyPair(keyName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate SSH key pair: %w", err)
+		}
+		keyPath = keyPair.PrivateKeyPath
+	} else {
+		keysDir, err := ssh.GetKeysDirectory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get keys directory: %w", err)
+		}
+		keyPath = filepath.Join(keysDir, keyName)
+	}
+
+	return &config.ExternalConfig{
+		Username:    "deploy",
+		SSHKey:      keyPath,
+		SSHKeyName:  keyName,
+		Region:      extractID(results["region"]),
+		Size:        extractID(results["size"]),
+		Provisioned: true,
+	}, nil
+}
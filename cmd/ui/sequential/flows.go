@@ -218,6 +218,7 @@ func RunProvisionHetznerFlow(projectName string) (*config.HetznerConfig, error)
 
 	flow := NewFlow("Provision Hetzner Cloud Server", dynamicSteps)
 	flow.SetProjectName(projectName)
+	flow.SetSizeProvider("hetzner", activeToken)
 
 	p := tea.NewProgram(flow)
 	finalModel, err := p.Run()
@@ -338,6 +339,7 @@ func RunProvisionVultrFlow(projectName string) (*config.VultrConfig, error) {
 
 	flow := NewFlow("Provision Vultr Instance", dynamicSteps)
 	flow.SetProjectName(projectName)
+	flow.SetSizeProvider("vultr", activeToken)
 
 	p := tea.NewProgram(flow)
 	finalModel, err := p.Run()
@@ -499,6 +501,7 @@ func CreateProvisionFlyioFlow(projectName string) *FlowModel {
 
 	flow := NewFlow("Configure fly.io Deployment", steps)
 	flow.SetProjectName(projectName)
+	flow.SetSizeProvider("flyio", activeToken)
 	return flow
 }
 
@@ -0,0 +1,355 @@
+package sequential
+
+import (
+	"context"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/providers"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// OptionsLoader fetches a select step's options, usually from a provider API.
+// results holds the answers given so far, so loaders can depend on earlier
+// steps such as the region.
+type OptionsLoader func(ctx context.Context, results map[string]string) (StepOptions, error)
+
+// StepOptions are the choices an OptionsLoader produced
+type StepOptions struct {
+	Values []string
+	Descs  []string
+}
+
+type loadStatus int
+
+const (
+	loadLoading loadStatus = iota + 1
+	loadFailed
+	loadEmpty
+	loadDone
+)
+
+// stepLoad tracks the options load of one step
+type stepLoad struct {
+	status loadStatus
+	err    error
+	inputs string // Earlier answers the options were loaded for
+	seq    int    // Identifies the request, so results of superseded loads are dropped
+}
+
+type startLoadMsg struct{}
+
+type optionsLoadedMsg struct {
+	step    int
+	seq     int
+	options StepOptions
+	err     error
+}
+
+type spinnerTickMsg struct{}
+
+const optionsLoadTimeout = 30 * time.Second
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var loadingStyle = style.Hint
+
+func spinnerTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
+		return spinnerTickMsg{}
+	})
+}
+
+// loaderFor returns the loader of a step. Size steps without their own loader
+// are refreshed for the selected region when the flow knows the size provider.
+func (m *FlowModel) loaderFor(index int) OptionsLoader {
+	step := m.Steps[index]
+	if step.Loader != nil {
+		return step.Loader
+	}
+	if !isSizeStep(step.ID) || m.selectedRegion() == "" {
+		return nil
+	}
+
+	provider := m.sizeProvider(step.ID)
+	if provider == nil {
+		return nil
+	}
+	return providerSizes(provider, m.selectedRegion())
+}
+
+// inputsBefore summarizes the answers given before a step, so its options are
+// reloaded when an earlier choice changes
+func (m *FlowModel) inputsBefore(index int) string {
+	var b strings.Builder
+	for i := 0; i < index && i < len(m.Steps); i++ {
+		if stepState, exists := m.StepStates[i]; exists {
+			b.WriteString(stepState.Value)
+		} else {
+			b.WriteString(m.Steps[i].Value)
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// loadCurrentStep starts loading the current step's options when they are
+// missing or were loaded for different earlier answers
+func (m *FlowModel) loadCurrentStep() tea.Cmd {
+	index := m.CurrentStep
+	if index >= len(m.Steps) {
+		return nil
+	}
+	loader := m.loaderFor(index)
+	if loader == nil {
+		return nil
+	}
+
+	inputs := m.inputsBefore(index)
+	if load, exists := m.Loads[index]; exists && load.inputs == inputs {
+		switch load.status {
+		case loadDone:
+			return nil
+		case loadLoading:
+			return spinnerTick()
+		}
+	}
+
+	if m.Offline {
+		m.useFallback(index)
+		m.Loads[index] = stepLoad{status: loadDone, inputs: inputs}
+		return nil
+	}
+
+	m.loadSeq++
+	seq := m.loadSeq
+	m.Loads[index] = stepLoad{status: loadLoading, inputs: inputs, seq: seq}
+	results := m.GetResults()
+
+	load := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), optionsLoadTimeout)
+		defer cancel()
+		options, err := loader(ctx, results)
+		return optionsLoadedMsg{step: index, seq: seq, options: options, err: err}
+	}
+	return tea.Batch(load, spinnerTick())
+}
+
+func (m FlowModel) handleOptionsLoaded(msg optionsLoadedMsg) (FlowModel, tea.Cmd) {
+	load, exists := m.Loads[msg.step]
+	if !exists || load.seq != msg.seq || msg.step >= len(m.Steps) {
+		return m, nil
+	}
+
+	switch {
+	case msg.err != nil:
+		load.status = loadFailed
+		load.err = msg.err
+	case len(msg.options.Values) == 0:
+		load.status = loadEmpty
+	default:
+		m.applyOptions(msg.step, msg.options)
+		load.status = loadDone
+	}
+	m.Loads[msg.step] = load
+
+	return m, nil
+}
+
+// applyOptions fills a step with loaded options, keeping the previous choice when it is still offered
+func (m *FlowModel) applyOptions(index int, options StepOptions) {
+	step := m.Steps[index]
+	if stepState, exists := m.StepStates[index]; exists {
+		step = stepState
+	}
+
+	step.Type = StepTypeSelect
+	step.Options = options.Values
+	step.OptionDescs = options.Descs
+	step.OptionLabels = nil
+	step.Cursor = 0
+	for i, value := range options.Values {
+		if value == step.Value {
+			step.Cursor = i
+		}
+	}
+	step.Value = options.Values[step.Cursor]
+
+	m.Steps[index] = step
+	m.StepStates[index] = step
+}
+
+// useFallback swaps in a step's static options; only done in offline mode
+func (m *FlowModel) useFallback(index int) {
+	step := m.Steps[index]
+	if step.Fallback == nil {
+		return
+	}
+	fallback := step.Fallback()
+	m.Steps[index] = fallback
+	m.StepStates[index] = fallback
+}
+
+// currentLoad returns the load state of the current step while it blocks input
+func (m *FlowModel) currentLoad() (stepLoad, bool) {
+	load, exists := m.Loads[m.CurrentStep]
+	if !exists || load.status == loadDone {
+		return stepLoad{}, false
+	}
+	return load, true
+}
+
+// handleLoadKey handles keys while the current step is loading or failed to load
+func (m FlowModel) handleLoadKey(msg tea.KeyMsg, load stepLoad) (FlowModel, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.Cancelled = true
+		return m, tea.Quit
+
+	case "left":
+		return m.goBack()
+
+	case "r":
+		if load.status == loadFailed || load.status == loadEmpty {
+			delete(m.Loads, m.CurrentStep)
+			cmd := m.loadCurrentStep()
+			return m, cmd
+		}
+
+	case "o":
+		if load.status == loadFailed || load.status == loadEmpty {
+			m.Offline = true
+			delete(m.Loads, m.CurrentStep)
+			cmd := m.loadCurrentStep()
+			return m, cmd
+		}
+	}
+
+	return m, nil
+}
+
+func (m FlowModel) renderLoadState(load stepLoad) string {
+	switch load.status {
+	case loadLoading:
+		frame := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+		return loadingStyle.Render(frame + " Loading options...")
+	case loadFailed:
+		return errorStyle.Render(fmt.Sprintf("Could not load options: %v", load.err))
+	case loadEmpty:
+		return errorStyle.Render("No options available for the choices above")
+	}
+	return ""
+}
+
+func (m FlowModel) renderLoadHelp(load stepLoad) string {
+	help := "Loading..."
+	if load.status == loadFailed || load.status == loadEmpty {
+		help = "r: Retry • o: Use offline list"
+	}
+	if len(m.History) > 0 {
+		help += " • ←: Back"
+	}
+	help += " • Esc: Cancel"
+
+	return helpStyle.Render(help)
+}
+
+// providerLoader builds a loader that creates the provider client when the
+// step is reached, so a bad token surfaces as a retryable load error
+func providerLoader(providerName, token string, load func(ctx context.Context, provider providers.Provider, results map[string]string) (StepOptions, error)) OptionsLoader {
+	return func(ctx context.Context, results map[string]string) (StepOptions, error) {
+		provider, err := providers.GetProvider(providerName, token)
+		if err != nil {
+			return StepOptions{}, err
+		}
+		return load(ctx, provider, results)
+	}
+}
+
+func loadRegions(ctx context.Context, provider providers.Provider, _ map[string]string) (StepOptions, error) {
+	regions, err := provider.GetRegions(ctx)
+	if err != nil {
+		return StepOptions{}, err
+	}
+
+	var options StepOptions
+	for _, region := range regions {
+		desc := region.Location
+		if desc == "" {
+			desc = region.ID
+		}
+		options.Values = append(options.Values, region.ID)
+		options.Descs = append(options.Descs, desc)
+	}
+	return options, nil
+}
+
+// loadSizes lists sizes for region, or for the region chosen earlier in the flow when empty
+func loadSizes(region string, describe func(providers.Size) string) func(context.Context, providers.Provider, map[string]string) (StepOptions, error) {
+	return func(ctx context.Context, provider providers.Provider, results map[string]string) (StepOptions, error) {
+		sizeRegion := region
+		if sizeRegion == "" {
+			sizeRegion = regionFromResults(results)
+		}
+
+		sizes, err := provider.GetSizes(ctx, sizeRegion)
+		if err != nil {
+			return StepOptions{}, err
+		}
+
+		var options StepOptions
+		for _, size := range sizes {
+			options.Values = append(options.Values, size.ID)
+			options.Descs = append(options.Descs, describe(size))
+		}
+		return options, nil
+	}
+}
+
+// providerSizes lists a provider's sizes for a region, smallest first, described by name
+func providerSizes(provider providers.Provider, region string) OptionsLoader {
+	return func(ctx context.Context, results map[string]string) (StepOptions, error) {
+		return loadSizes(region, sizeName)(ctx, sortedByMemory{provider}, results)
+	}
+}
+
+// sortedByMemory wraps a provider so GetSizes returns sizes smallest first
+type sortedByMemory struct {
+	providers.Provider
+}
+
+func (p sortedByMemory) GetSizes(ctx context.Context, region string) ([]providers.Size, error) {
+	sizes, err := p.Provider.GetSizes(ctx, region)
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i].Memory < sizes[j].Memory
+	})
+	return sizes, err
+}
+
+// regionsByID wraps a provider so GetRegions returns regions sorted by ID
+type regionsByID struct {
+	providers.Provider
+}
+
+func (p regionsByID) GetRegions(ctx context.Context) ([]providers.Region, error) {
+	regions, err := p.Provider.GetRegions(ctx)
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].ID < regions[j].ID
+	})
+	return regions, err
+}
+
+func sizeName(size providers.Size) string {
+	return size.Name
+}
+
+// regionFromResults returns the region chosen in a flow (Hetzner calls it a location)
+func regionFromResults(results map[string]string) string {
+	if region := results["region"]; region != "" {
+		return extractID(region)
+	}
+	return extractID(results["location"])
+}
@@ -1,20 +1,23 @@
 package sequential
 
 import (
+	"context"
 	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/providers"
 	"lightfold/pkg/providers/digitalocean"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	titleStyle     = lipgloss.NewStyle().Background(lipgloss.Color("#01FAC6")).Foreground(lipgloss.Color("#030303")).Bold(true).Padding(0, 1, 0)
-	focusedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6")).Bold(true)
-	helpStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
-	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
-	progressStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
-	completedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("46"))
+	titleStyle     = style.Badge.Padding(0, 1, 0)
+	focusedStyle   = style.Title
+	helpStyle      = style.Fg("241")
+	errorStyle     = style.Error
+	progressStyle  = style.Hint
+	completedStyle = style.Fg("46")
 )
 
 type StepType string
@@ -40,6 +43,9 @@ type Step struct {
 	OptionLabels []string // Display labels for each option (for StepTypeSelect)
 	OptionDescs  []string // Descriptions for each option (for StepTypeSelect)
 	Cursor       int      // Current cursor position for StepTypeSelect
+
+	Loader   OptionsLoader // Loads Options in the background once the step is reached
+	Fallback func() Step   // Static version of the step, used only in offline mode
 }
 
 type FlowModel struct {
@@ -53,6 +59,20 @@ type FlowModel struct {
 	StepStates  map[int]Step
 	SSHHandlers map[int]*SSHKeyHandler
 	ProjectName string
+
+	// SizeProviderName and SizeProviderToken let the flow refresh size options
+	// for the selected region; when no token is set, the flow's api_token step is used.
+	SizeProviderName  string
+	SizeProviderToken string
+
+	// Loads tracks background option loads per step. Offline is set when the
+	// user chooses static option lists after a load failed.
+	Loads        map[int]stepLoad
+	Offline      bool
+	loadSeq      int
+	spinnerFrame int
+	// checking is the seq of the size availability check in flight, 0 when none
+	checking int
 }
 
 func NewFlow(title string, steps []Step) *FlowModel {
@@ -73,9 +93,16 @@ func NewFlow(title string, steps []Step) *FlowModel {
 		History:     []int{},
 		StepStates:  stepStates,
 		SSHHandlers: sshHandlers,
+		Loads:       make(map[int]stepLoad),
 	}
 }
 
+// SetSizeProvider configures which provider is queried for region-specific sizes
+func (m *FlowModel) SetSizeProvider(name, token string) {
+	m.SizeProviderName = name
+	m.SizeProviderToken = token
+}
+
 func (m *FlowModel) SetProjectName(projectName string) {
 	m.ProjectName = projectName
 	for _, handler := range m.SSHHandlers {
@@ -84,13 +111,30 @@ func (m *FlowModel) SetProjectName(projectName string) {
 }
 
 func (m FlowModel) Init() tea.Cmd {
-	return nil
+	// Init cannot update the model, so the first step's load starts on the next message
+	return func() tea.Msg { return startLoadMsg{} }
 }
 
 func (m FlowModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		return m.handleKeyPress(msg)
+	case startLoadMsg:
+		cmd := m.loadCurrentStep()
+		return m, cmd
+	case optionsLoadedMsg:
+		return m.handleOptionsLoaded(msg)
+	case sizeCheckedMsg:
+		return m.handleSizeChecked(msg)
+	case spinnerTickMsg:
+		if m.checking != 0 {
+			m.spinnerFrame++
+			return m, spinnerTick()
+		}
+		if load, loading := m.currentLoad(); loading && load.status == loadLoading {
+			m.spinnerFrame++
+			return m, spinnerTick()
+		}
 	}
 	return m, nil
 }
@@ -108,6 +152,13 @@ func (m FlowModel) View() string {
 }
 
 func (m FlowModel) handleKeyPress(msg tea.KeyMsg) (FlowModel, tea.Cmd) {
+	if m.checking != 0 {
+		return m.handleCheckKey(msg)
+	}
+	if load, blocked := m.currentLoad(); blocked {
+		return m.handleLoadKey(msg, load)
+	}
+
 	currentStep := m.getCurrentStep()
 
 	switch msg.String() {
@@ -197,9 +248,19 @@ func (m FlowModel) handleEnter() (FlowModel, tea.Cmd) {
 	}
 
 	m.Error = nil
-
 	m.StepStates[m.CurrentStep] = currentStep
 
+	if isSizeStep(currentStep.ID) && !m.Offline {
+		if cmd := m.checkSelectedSize(currentStep.ID, currentStep.Value); cmd != nil {
+			return m, cmd
+		}
+	}
+
+	return m.advance()
+}
+
+// advance moves past the current step, whose answer was accepted
+func (m FlowModel) advance() (FlowModel, tea.Cmd) {
 	if m.CurrentStep >= len(m.Steps)-1 {
 		m.Completed = true
 		return m, tea.Quit
@@ -208,11 +269,8 @@ func (m FlowModel) handleEnter() (FlowModel, tea.Cmd) {
 	m.History = append(m.History, m.CurrentStep)
 	m.CurrentStep++
 
-	if m.CurrentStep < len(m.Steps) && m.Steps[m.CurrentStep].ID == "size" {
-		m.updateSizeStepIfNeeded()
-	}
-
-	return m, nil
+	cmd := m.loadCurrentStep()
+	return m, cmd
 }
 
 func (m FlowModel) handleBackspace() (FlowModel, tea.Cmd) {
@@ -397,13 +455,29 @@ func (m FlowModel) renderCurrentStep() string {
 	}
 	s += "\n"
 
-	s += m.renderInput(currentStep) + "\n\n"
+	load, blocked := m.currentLoad()
+	switch {
+	case m.checking != 0:
+		frame := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
+		s += loadingStyle.Render(frame+" Checking the size is available in "+m.selectedRegion()+"...") + "\n\n"
+	case blocked:
+		s += m.renderLoadState(load) + "\n\n"
+	default:
+		s += m.renderInput(currentStep) + "\n\n"
+	}
 
 	if m.Error != nil {
 		s += errorStyle.Render("Error: "+m.Error.Error()) + "\n\n"
 	}
 
-	s += m.renderHelp()
+	switch {
+	case m.checking != 0:
+		s += helpStyle.Render("Checking... • Esc: Cancel")
+	case blocked:
+		s += m.renderLoadHelp(load)
+	default:
+		s += m.renderHelp()
+	}
 
 	return s
 }
@@ -424,19 +498,17 @@ func (m FlowModel) renderInput(step Step) string {
 }
 
 func (m FlowModel) renderTextInput(step Step) string {
-	inputStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#01FAC6")).
+	inputStyle := style.Box(style.ColorBrand).
 		Padding(0, 1).
 		Width(50)
 
 	value := step.Value
 	if step.Type == StepTypePassword && value != "" {
-		value = lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Render(fmt.Sprintf("[%d characters]", len(value)))
+		value = style.Hint.Render(fmt.Sprintf("[%d characters]", len(value)))
 	}
 
 	if value == "" && step.Placeholder != "" {
-		value = lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Render(step.Placeholder)
+		value = style.Hint.Render(step.Placeholder)
 	}
 
 	value += "│"
@@ -490,7 +562,7 @@ func (m FlowModel) renderSelectInput(step Step) string {
 
 	// Show scroll indicator at top if there are items above
 	if start > 0 {
-		s += lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Render(fmt.Sprintf("   ↑ %d more above...\n\n", start))
+		s += style.Hint.Render(fmt.Sprintf("   ↑ %d more above...\n\n", start))
 	}
 
 	// Render visible items
@@ -502,16 +574,16 @@ func (m FlowModel) renderSelectInput(step Step) string {
 		}
 
 		cursor := "  "
-		titleColor := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+		titleColor := style.Text
 
 		if i == step.Cursor {
 			cursor = focusedStyle.Render(">")
-			titleColor = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Bold(true)
+			titleColor = style.Value.Bold(true)
 		}
 
 		// Render label + optional description
 		if step.OptionDescs != nil && i < len(step.OptionDescs) && step.OptionDescs[i] != "" {
-			descColor := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+			descColor := style.Hint
 			s += fmt.Sprintf("%s %s %s\n", cursor, titleColor.Render(label), descColor.Render("- "+step.OptionDescs[i]))
 		} else {
 			s += fmt.Sprintf("%s %s\n", cursor, titleColor.Render(label))
@@ -520,7 +592,7 @@ func (m FlowModel) renderSelectInput(step Step) string {
 
 	// Show scroll indicator at bottom if there are items below
 	if end < totalItems {
-		s += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Render(fmt.Sprintf("   ↓ %d more below...", totalItems-end))
+		s += "\n" + style.Hint.Render(fmt.Sprintf("   ↓ %d more below...", totalItems-end))
 	}
 
 	return s
@@ -557,7 +629,7 @@ func (m FlowModel) renderHelp() string {
 }
 
 func (m FlowModel) renderCompleted() string {
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	mutedStyle := style.Muted
 
 	var lines []string
 
@@ -606,9 +678,7 @@ func (m FlowModel) renderCompleted() string {
 		}
 	}
 
-	mutedBox := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("245")).
+	mutedBox := style.Box(style.ColorMuted).
 		Padding(0, 1, 0, 1)
 
 	return "\n" + mutedBox.Render(mutedStyle.Render(content))
@@ -645,18 +715,105 @@ func (m FlowModel) GetSSHKeyInfo(stepID string) (filePath, keyName string) {
 	return "", ""
 }
 
-func (m *FlowModel) updateSizeStepIfNeeded() {
+// isSizeStep reports whether a step ID holds a provider size selection
+func isSizeStep(id string) bool {
+	switch id {
+	case "size", "server_type", "plan", "instance_type":
+		return true
+	}
+	return false
+}
+
+// selectedRegion returns the region chosen earlier in the flow (Hetzner calls it a location)
+func (m *FlowModel) selectedRegion() string {
+	return regionFromResults(m.GetResults())
+}
+
+// sizeProvider builds the provider client used for size lookups, if enough is known.
+// Flows without a configured provider fall back to DigitalOcean's "size" step.
+func (m *FlowModel) sizeProvider(stepID string) providers.Provider {
 	results := m.GetResults()
 
-	apiToken, hasToken := results["api_token"]
-	region, hasRegion := results["region"]
+	token := m.SizeProviderToken
+	if token == "" {
+		token = results["api_token"]
+	}
+	if token == "" {
+		return nil
+	}
+
+	if m.SizeProviderName == "" {
+		if stepID != "size" {
+			return nil
+		}
+		return digitalocean.NewClient(token)
+	}
+
+	provider, err := providers.GetProvider(m.SizeProviderName, token)
+	if err != nil {
+		return nil
+	}
+	return provider
+}
+
+// sizeCheckedMsg carries the result of a size availability check
+type sizeCheckedMsg struct {
+	step int
+	seq  int
+	err  error
+}
+
+const sizeCheckTimeout = 15 * time.Second
+
+// validateSizeForRegion is swapped out by tests
+var validateSizeForRegion = providers.ValidateSizeForRegion
+
+// checkSelectedSize starts re-checking the chosen size against the selected
+// region in the background, so the user is re-prompted here instead of failing
+// later during provisioning. It returns nil when there is nothing to check.
+func (m *FlowModel) checkSelectedSize(stepID, size string) tea.Cmd {
+	region := m.selectedRegion()
+	if region == "" {
+		return nil
+	}
+
+	provider := m.sizeProvider(stepID)
+	if provider == nil {
+		return nil
+	}
+
+	m.loadSeq++
+	seq := m.loadSeq
+	m.checking = seq
+	step := m.CurrentStep
 
-	if !hasToken || !hasRegion || apiToken == "" || region == "" {
-		return
+	check := func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), sizeCheckTimeout)
+		defer cancel()
+		return sizeCheckedMsg{step: step, seq: seq, err: validateSizeForRegion(ctx, provider, region, extractID(size))}
 	}
+	return tea.Batch(check, spinnerTick())
+}
 
-	client := digitalocean.NewClient(apiToken)
+func (m FlowModel) handleSizeChecked(msg sizeCheckedMsg) (FlowModel, tea.Cmd) {
+	if msg.seq != m.checking || msg.step != m.CurrentStep {
+		return m, nil
+	}
+	m.checking = 0
+
+	if msg.err != nil {
+		m.Error = msg.err
+		return m, nil
+	}
+	return m.advance()
+}
 
-	if err := m.UpdateStepWithDynamicSizes("size", client, region); err != nil {
+// handleCheckKey handles keys while the size check runs
+func (m FlowModel) handleCheckKey(msg tea.KeyMsg) (FlowModel, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		m.Cancelled = true
+		return m, tea.Quit
 	}
+	return m, nil
 }
@@ -0,0 +1,268 @@
+package sequential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lightfold/pkg/providers"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runLoad executes the load command returned by the model and feeds its result back
+func runLoad(t *testing.T, m FlowModel, cmd tea.Cmd) FlowModel {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a load command")
+	}
+
+	msgs := []tea.Msg{cmd()}
+	for len(msgs) > 0 {
+		msg := msgs[0]
+		msgs = msgs[1:]
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			for _, c := range msg {
+				if c != nil {
+					msgs = append(msgs, c())
+				}
+			}
+		case optionsLoadedMsg:
+			updated, _ := m.Update(msg)
+			m = updated.(FlowModel)
+		}
+	}
+	return m
+}
+
+func key(s string) tea.KeyMsg {
+	if s == "enter" {
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func press(m FlowModel, s string) (FlowModel, tea.Cmd) {
+	updated, cmd := m.Update(key(s))
+	return updated.(FlowModel), cmd
+}
+
+func start(m FlowModel) (FlowModel, tea.Cmd) {
+	updated, cmd := m.Update(startLoadMsg{})
+	return updated.(FlowModel), cmd
+}
+
+func TestFlowModel_LoadErrorThenRetry(t *testing.T) {
+	calls := 0
+	failing := true
+	loader := func(ctx context.Context, _ map[string]string) (StepOptions, error) {
+		calls++
+		if failing {
+			return StepOptions{}, errors.New("connection refused")
+		}
+		return StepOptions{Values: []string{"fra", "ams"}, Descs: []string{"Frankfurt", "Amsterdam"}}, nil
+	}
+
+	step := NewStep("region", "Region").Type(StepTypeSelect).LoadOptions(loader).Required().Build()
+	m, cmd := start(*NewFlow("Test", []Step{step}))
+	if load, _ := m.currentLoad(); load.status != loadLoading {
+		t.Fatalf("Expected loading state, got %v", load.status)
+	}
+
+	m = runLoad(t, m, cmd)
+	load, blocked := m.currentLoad()
+	if !blocked || load.status != loadFailed {
+		t.Fatalf("Expected failed state, got %v", load.status)
+	}
+	if len(m.getCurrentStep().Options) != 0 {
+		t.Error("Expected no options to be substituted after a failed load")
+	}
+
+	// Enter must not advance past a failed step
+	m, _ = press(m, "enter")
+	if m.Completed {
+		t.Fatal("Expected enter to be ignored while the load has failed")
+	}
+
+	failing = false
+	m, cmd = press(m, "r")
+	m = runLoad(t, m, cmd)
+	if calls != 2 {
+		t.Errorf("Expected retry to call the loader again, got %d calls", calls)
+	}
+	if _, blocked := m.currentLoad(); blocked {
+		t.Fatal("Expected the retried load to succeed")
+	}
+
+	current := m.getCurrentStep()
+	if len(current.Options) != 2 || current.Value != "fra" {
+		t.Errorf("Expected loaded options with the first selected, got %v (%q)", current.Options, current.Value)
+	}
+
+	m, _ = press(m, "enter")
+	if !m.Completed || m.GetResults()["region"] != "fra" {
+		t.Errorf("Expected the flow to complete with region fra, got %v", m.GetResults())
+	}
+}
+
+func TestFlowModel_StaleLoadIgnored(t *testing.T) {
+	loader := func(ctx context.Context, _ map[string]string) (StepOptions, error) {
+		return StepOptions{}, errors.New("timeout")
+	}
+	step := NewStep("region", "Region").Type(StepTypeSelect).LoadOptions(loader).Build()
+	m, cmd := start(*NewFlow("Test", []Step{step}))
+	m = runLoad(t, m, cmd)
+
+	m, _ = press(m, "r")
+	staleSeq := m.Loads[0].seq - 1
+
+	updated, _ := m.Update(optionsLoadedMsg{step: 0, seq: staleSeq, options: StepOptions{Values: []string{"old"}}})
+	m = updated.(FlowModel)
+	if load, _ := m.currentLoad(); load.status != loadLoading {
+		t.Errorf("Expected a stale result to be ignored, got status %v", load.status)
+	}
+}
+
+func TestFlowModel_EmptyResultIsNotAnError(t *testing.T) {
+	loader := func(ctx context.Context, _ map[string]string) (StepOptions, error) {
+		return StepOptions{}, nil
+	}
+	step := NewStep("plan", "Plan").Type(StepTypeSelect).LoadOptions(loader).Build()
+	m, cmd := start(*NewFlow("Test", []Step{step}))
+	m = runLoad(t, m, cmd)
+
+	load, blocked := m.currentLoad()
+	if !blocked || load.status != loadEmpty || load.err != nil {
+		t.Errorf("Expected an empty state without error, got %v (%v)", load.status, load.err)
+	}
+}
+
+func TestFlowModel_OfflineUsesFallback(t *testing.T) {
+	loader := func(ctx context.Context, _ map[string]string) (StepOptions, error) {
+		return StepOptions{}, errors.New("unauthorized")
+	}
+	fallback := func() Step {
+		return NewStep("region", "Region").Type(StepTypeSelect).Options("nyc1", "sfo3").DefaultValue("nyc1").Build()
+	}
+	steps := []Step{
+		NewStep("region", "Region").Type(StepTypeSelect).LoadOptions(loader).OfflineFallback(fallback).Build(),
+		NewStep("plan", "Plan").Type(StepTypeSelect).LoadOptions(loader).OfflineFallback(func() Step {
+			return NewStep("plan", "Plan").Type(StepTypeSelect).Options("small").Build()
+		}).Build(),
+	}
+
+	m, cmd := start(*NewFlow("Test", steps))
+	m = runLoad(t, m, cmd)
+
+	m, _ = press(m, "o")
+	if !m.Offline {
+		t.Fatal("Expected offline mode after pressing o")
+	}
+	if _, blocked := m.currentLoad(); blocked {
+		t.Fatal("Expected the fallback to unblock the step")
+	}
+	if got := m.getCurrentStep().Options; len(got) != 2 || got[0] != "nyc1" {
+		t.Errorf("Expected fallback options, got %v", got)
+	}
+
+	// Later steps go straight to their fallback without calling the API
+	m, cmd = press(m, "enter")
+	if cmd != nil {
+		t.Error("Expected no load command in offline mode")
+	}
+	if got := m.getCurrentStep().Options; len(got) != 1 || got[0] != "small" {
+		t.Errorf("Expected the next step's fallback options, got %v", got)
+	}
+}
+
+func TestFlowModel_ReloadsWhenEarlierAnswerChanges(t *testing.T) {
+	var regions []string
+	loader := func(ctx context.Context, results map[string]string) (StepOptions, error) {
+		regions = append(regions, results["region"])
+		return StepOptions{Values: []string{results["region"] + "-small"}}, nil
+	}
+	steps := []Step{
+		NewStep("region", "Region").Type(StepTypeSelect).Options("fra", "ams").Build(),
+		NewStep("plan", "Plan").Type(StepTypeSelect).LoadOptions(loader).Build(),
+	}
+
+	m, _ := start(*NewFlow("Test", steps))
+	m, cmd := press(m, "enter")
+	m = runLoad(t, m, cmd)
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	m = updated.(FlowModel)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = updated.(FlowModel)
+	m, cmd = press(m, "enter")
+	m = runLoad(t, m, cmd)
+
+	if len(regions) != 2 || regions[1] != "ams" {
+		t.Errorf("Expected a reload for the new region, got loads for %v", regions)
+	}
+	if got := m.getCurrentStep().Value; got != "ams-small" {
+		t.Errorf("Expected options for ams, got %q", got)
+	}
+}
+
+func TestFlowModel_SizeCheckRunsInBackground(t *testing.T) {
+	available := false
+	original := validateSizeForRegion
+	validateSizeForRegion = func(ctx context.Context, _ providers.Provider, region, size string) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected the size check to have a timeout")
+		}
+		if available {
+			return nil
+		}
+		return fmt.Errorf("size %s is not available in %s", size, region)
+	}
+	t.Cleanup(func() { validateSizeForRegion = original })
+
+	steps := []Step{
+		NewStep("region", "Region").Type(StepTypeSelect).Options("nyc1").DefaultValue("nyc1").Build(),
+		NewStep("size", "Size").Type(StepTypeSelect).Options("s-1vcpu-1gb").Build(),
+		NewStep("name", "Name").DefaultValue("web").Build(),
+	}
+	flow := NewFlow("Test", steps)
+	flow.SetSizeProvider("", "token")
+	m := *flow
+	m.CurrentStep = 1
+
+	m, cmd := press(m, "enter")
+	if m.checking == 0 || m.CurrentStep != 1 {
+		t.Fatal("Expected Enter to start a size check without leaving the step")
+	}
+	if m, _ = press(m, "enter"); m.CurrentStep != 1 {
+		t.Fatal("Expected keys to be ignored while the size is checked")
+	}
+
+	m = runSizeCheck(t, m, cmd)
+	if m.checking != 0 || m.CurrentStep != 1 || m.Error == nil {
+		t.Fatalf("Expected the step to stay with an error, got step %d, error %v", m.CurrentStep, m.Error)
+	}
+
+	available = true
+	m, cmd = press(m, "enter")
+	m = runSizeCheck(t, m, cmd)
+	if m.CurrentStep != 2 || m.Error != nil {
+		t.Errorf("Expected an available size to advance, got step %d, error %v", m.CurrentStep, m.Error)
+	}
+}
+
+// runSizeCheck executes the size check command and feeds its result back
+func runSizeCheck(t *testing.T, m FlowModel, cmd tea.Cmd) FlowModel {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a size check command")
+	}
+	for _, c := range cmd().(tea.BatchMsg) {
+		if msg, ok := c().(sizeCheckedMsg); ok {
+			updated, _ := m.Update(msg)
+			return updated.(FlowModel)
+		}
+	}
+	t.Fatal("Expected a size check result")
+	return m
+}
@@ -21,6 +21,7 @@ func CreateProviderSelectionStep(id string) Step {
 			"flyio",
 			"byos",
 			"existing",
+			"adopt",
 		).
 		OptionLabels(
 			"DigitalOcean",
@@ -31,6 +32,7 @@ func CreateProviderSelectionStep(id string) Step {
 			"fly.io",
 			"BYOS",
 			"Existing server",
+			"Adopt cloud server",
 		).
 		OptionDescriptions(
 			"",
@@ -41,6 +43,7 @@ func CreateProviderSelectionStep(id string) Step {
 			"Docker deployment only",
 			"Bring your own server",
 			"Deploy to existing server",
+			"Use a server created outside lightfold",
 		).
 		Required().
 		Build()
@@ -116,6 +119,10 @@ func RunProviderSelectionWithConfigFlow(projectName string) (provider string, cf
 		existingConfig := buildExistingServerConfig(results)
 		return "existing", existingConfig, nil
 
+	case "adopt":
+		adoptConfig := buildAdoptConfig(results, final)
+		return "adopt", adoptConfig, nil
+
 	default:
 		return "", nil, fmt.Errorf("unsupported provider: %s", selectedProvider)
 	}
@@ -196,7 +203,7 @@ func (m *DynamicProviderFlow) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		awsAtSecretKeyStep := m.ProviderForDynamic == "aws" && m.NeedsDynamicSteps && m.CurrentStep == m.TokenStepIndex+1
-		otherAtTokenStep := m.ProviderForDynamic != "aws" && m.ProviderForDynamic != "existing" && m.NeedsDynamicSteps && m.CurrentStep == m.TokenStepIndex
+		otherAtTokenStep := m.ProviderForDynamic != "aws" && m.ProviderForDynamic != "existing" && m.ProviderForDynamic != "adopt" && m.NeedsDynamicSteps && m.CurrentStep == m.TokenStepIndex
 
 		if awsAtSecretKeyStep || otherAtTokenStep {
 			currentStep := m.getCurrentStep()
@@ -257,6 +264,7 @@ func (m *DynamicProviderFlow) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					tokens.SetToken("aws", credJSON)
 					tokens.SaveTokens()
+					m.SizeProviderToken = credJSON
 
 					newSteps = []Step{
 						CreateAWSRegionStepDynamic("region", credJSON),
@@ -290,6 +298,9 @@ func (m *DynamicProviderFlow) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.Completed = false
 			}
 		}
+		if m.NeedsDynamicSteps && m.ProviderForDynamic == "adopt" && m.CurrentStep == m.TokenStepIndex {
+			m.handleAdoptStep()
+		}
 	}
 
 	updatedModel, cmd := m.FlowModel.Update(msg)
@@ -319,11 +330,17 @@ func (m *DynamicProviderFlow) clearProviderSteps() {
 		}
 		m.StepStates = newStepStates
 		m.SSHHandlers = newSSHHandlers
+		for i := range m.Loads {
+			if i >= len(m.Steps) {
+				delete(m.Loads, i)
+			}
+		}
 	}
 	m.ProviderSelected = false
 	m.NeedsDynamicSteps = false
 	m.ProviderForDynamic = ""
 	m.TokenStepIndex = 0
+	m.SetSizeProvider("", "")
 }
 
 func (m *DynamicProviderFlow) addProviderSteps(provider string) error {
@@ -458,11 +475,29 @@ func (m *DynamicProviderFlow) addProviderSteps(provider string) error {
 		m.NeedsDynamicSteps = true
 		m.ProviderForDynamic = "existing"
 
+	case "adopt":
+		newSteps = []Step{
+			CreateAdoptProviderStep("adopt_provider"),
+		}
+		m.NeedsDynamicSteps = true
+		m.ProviderForDynamic = "adopt"
+
 	default:
 		return fmt.Errorf("unsupported provider: %s", provider)
 	}
 
-	m.Steps = append(m.Steps, newSteps...)
+	if provider != "byos" && provider != "existing" && provider != "adopt" {
+		m.SetSizeProvider(provider, tokens.GetToken(provider))
+	}
+
+	m.appendSteps(newSteps...)
+
+	return nil
+}
+
+// appendSteps adds steps to the flow, registering SSH key handlers where needed
+func (m *DynamicProviderFlow) appendSteps(steps ...Step) {
+	m.Steps = append(m.Steps, steps...)
 
 	for i := len(m.StepStates); i < len(m.Steps); i++ {
 		m.StepStates[i] = m.Steps[i]
@@ -470,8 +505,49 @@ func (m *DynamicProviderFlow) addProviderSteps(provider string) error {
 			m.SSHHandlers[i] = NewSSHKeyHandler(m.ProjectName)
 		}
 	}
+}
 
-	return nil
+// handleAdoptStep adds the adoption steps once the provider (and its token, if missing) is known
+func (m *DynamicProviderFlow) handleAdoptStep() {
+	currentStep := m.getCurrentStep()
+	tokens, _ := config.LoadTokens()
+
+	switch currentStep.ID {
+	case "adopt_provider":
+		if currentStep.Cursor < 0 || currentStep.Cursor >= len(currentStep.Options) {
+			return
+		}
+		adoptProvider := currentStep.Options[currentStep.Cursor]
+
+		// Drop steps from a previous provider choice before adding new ones
+		for i := m.CurrentStep + 1; i < len(m.Steps); i++ {
+			delete(m.StepStates, i)
+			delete(m.SSHHandlers, i)
+			delete(m.Loads, i)
+		}
+		m.Steps = m.Steps[:m.CurrentStep+1]
+
+		if token := tokens.GetToken(adoptProvider); token != "" {
+			m.appendSteps(adoptSteps(adoptProvider, token)...)
+			m.NeedsDynamicSteps = false
+		} else {
+			m.appendSteps(CreateAdoptTokenStep("api_token", adoptProvider))
+			m.TokenStepIndex = m.CurrentStep + 1
+		}
+		m.Completed = false
+
+	case "api_token":
+		if currentStep.Value == "" {
+			return
+		}
+		adoptProvider := m.GetResults()["adopt_provider"]
+		tokens.SetToken(adoptProvider, currentStep.Value)
+		tokens.SaveTokens()
+
+		m.appendSteps(adoptSteps(adoptProvider, currentStep.Value)...)
+		m.NeedsDynamicSteps = false
+		m.Completed = false
+	}
 }
 
 func buildDigitalOceanConfig(results map[string]string, _ *DynamicProviderFlow, projectName string) *config.DigitalOceanConfig {
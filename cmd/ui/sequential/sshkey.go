@@ -2,14 +2,13 @@ package sequential
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"lightfold/pkg/config"
-
-	"github.com/charmbracelet/lipgloss"
 )
 
 type SSHKeyMode string
@@ -183,7 +182,7 @@ func (h *SSHKeyHandler) GetKeyName() string {
 func (h *SSHKeyHandler) RenderSSHKeyInput(value string) string {
 	var s strings.Builder
 
-	modeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Italic(true)
+	modeStyle := style.Hint.Italic(true)
 
 	switch h.Mode {
 	case SSHKeyModeFile:
@@ -194,9 +193,7 @@ func (h *SSHKeyHandler) RenderSSHKeyInput(value string) string {
 		s.WriteString(modeStyle.Render("🔑 SSH Key Input") + "\n")
 	}
 
-	inputStyle := lipgloss.NewStyle().
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#01FAC6")).
+	inputStyle := style.Box(style.ColorBrand).
 		Padding(0, 1).
 		Width(60)
 
@@ -209,7 +206,7 @@ func (h *SSHKeyHandler) RenderSSHKeyInput(value string) string {
 	}
 
 	if displayValue == "" {
-		displayValue = lipgloss.NewStyle().Foreground(lipgloss.Color("243")).Render("Enter file path or paste SSH key")
+		displayValue = style.Hint.Render("Enter file path or paste SSH key")
 	}
 
 	displayValue += "│"
@@ -217,7 +214,7 @@ func (h *SSHKeyHandler) RenderSSHKeyInput(value string) string {
 	s.WriteString(inputStyle.Render(displayValue))
 	s.WriteString("\n\n")
 
-	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	helpStyle := style.Fg("241")
 	s.WriteString(helpStyle.Render("• Enter file path (e.g., ~/.ssh/id_rsa)"))
 	s.WriteString("\n")
 	s.WriteString(helpStyle.Render("• Or paste SSH private key content"))
@@ -11,7 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -76,6 +75,18 @@ func (b *StepBuilder) OptionDescriptions(descs ...string) *StepBuilder {
 	return b
 }
 
+// LoadOptions makes the step load its options in the background once it is reached
+func (b *StepBuilder) LoadOptions(loader OptionsLoader) *StepBuilder {
+	b.step.Loader = loader
+	return b
+}
+
+// OfflineFallback sets the static step used when the user switches to offline mode
+func (b *StepBuilder) OfflineFallback(fn func() Step) *StepBuilder {
+	b.step.Fallback = fn
+	return b
+}
+
 func (b *StepBuilder) Build() Step {
 	return b.step
 }
@@ -333,34 +344,10 @@ func CreateSizeStep(id string) Step {
 }
 
 func CreateDynamicSizeStep(id string, provider providers.Provider, region string) Step {
-	ctx := context.Background()
-
-	apiSizes, err := provider.GetSizes(ctx, region)
-	if err != nil {
-		return CreateSizeStep(id)
-	}
-
-	sort.Slice(apiSizes, func(i, j int) bool {
-		return apiSizes[i].Memory < apiSizes[j].Memory
-	})
-
-	var sizes []string
-	var sizeDescs []string
-	for _, size := range apiSizes {
-		sizes = append(sizes, size.ID)
-		sizeDescs = append(sizeDescs, size.Name)
-	}
-
-	defaultValue := ""
-	if len(sizes) > 0 {
-		defaultValue = sizes[0]
-	}
-
 	return NewStep(id, "Droplet Size").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(sizes...).
-		OptionDescriptions(sizeDescs...).
+		LoadOptions(providerSizes(provider, region)).
+		OfflineFallback(func() Step { return CreateSizeStep(id) }).
 		Required().
 		Build()
 }
@@ -397,7 +384,7 @@ func CreateHetznerLocationStep(id string) Step {
 }
 
 func CreateHetznerServerTypeStep(id string) Step {
-	serverTypes := []string{"cpx11", "cpx21", "cpx31", "cpx41", "cx22", "cx32", "cx42", "cx52"}
+	serverTypes := []string{"cpx11", "cpx21", "cpx31", "cpx41", "cx22", "cx32", "cx42", "cx52", "cax11", "cax21", "cax31"}
 	serverTypeDescs := []string{
 		"2 vCPU, 2 GB RAM, 40 GB SSD (AMD)",
 		"3 vCPUs, 4 GB RAM, 80 GB SSD (AMD)",
@@ -407,6 +394,9 @@ func CreateHetznerServerTypeStep(id string) Step {
 		"4 vCPUs, 8 GB RAM, 80 GB SSD (Intel)",
 		"8 vCPUs, 16 GB RAM, 160 GB SSD (Intel)",
 		"16 vCPUs, 32 GB RAM, 320 GB SSD (Intel)",
+		"2 vCPUs, 4 GB RAM, 40 GB SSD (ARM64)",
+		"4 vCPUs, 8 GB RAM, 80 GB SSD (ARM64)",
+		"8 vCPUs, 16 GB RAM, 160 GB SSD (ARM64)",
 	}
 
 	return NewStep(id, "Server Type").
@@ -418,179 +408,58 @@ func CreateHetznerServerTypeStep(id string) Step {
 		Build()
 }
 
-// CreateHetznerLocationStepDynamic creates a Hetzner location step with dynamic API data
+// CreateHetznerLocationStepDynamic creates a Hetzner location step loaded from the API
 func CreateHetznerLocationStepDynamic(id, token string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("hetzner", token)
-	if err != nil {
-		return CreateHetznerLocationStep(id)
-	}
-
-	regions, err := provider.GetRegions(ctx)
-	if err != nil || len(regions) == 0 {
-		return CreateHetznerLocationStep(id)
-	}
-
-	var locationIDs []string
-	var locationDescs []string
-	for _, region := range regions {
-		locationIDs = append(locationIDs, region.ID)
-		locationDescs = append(locationDescs, region.Location)
-	}
-
 	return NewStep(id, "Hetzner Location").
 		Type(StepTypeSelect).
-		DefaultValue(locationIDs[0]).
-		Options(locationIDs...).
-		OptionDescriptions(locationDescs...).
+		LoadOptions(providerLoader("hetzner", token, loadRegions)).
+		OfflineFallback(func() Step { return CreateHetznerLocationStep(id) }).
 		Required().
 		Build()
 }
 
-// CreateHetznerServerTypeStepDynamic creates a Hetzner server type step with dynamic API data
+// CreateHetznerServerTypeStepDynamic creates a Hetzner server type step loaded from the API.
+// An empty location uses the location chosen earlier in the flow.
 func CreateHetznerServerTypeStepDynamic(id, token, location string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("hetzner", token)
-	if err != nil {
-		return CreateHetznerServerTypeStep(id)
-	}
-
-	sizes, err := provider.GetSizes(ctx, location)
-	if err != nil || len(sizes) == 0 {
-		return CreateHetznerServerTypeStep(id)
-	}
-
-	var sizeIDs []string
-	var sizeDescs []string
-	for _, size := range sizes {
-		sizeIDs = append(sizeIDs, size.ID)
-		desc := fmt.Sprintf("%d vCPU, %d GB RAM, %d GB SSD", size.VCPUs, size.Memory/1024, size.Disk)
-		if size.PriceMonthly > 0 {
-			desc = fmt.Sprintf("%s (€%.2f/mo)", desc, size.PriceMonthly)
-		}
-		sizeDescs = append(sizeDescs, desc)
-	}
-
-	defaultValue := "cx11"
-	if len(sizeIDs) > 0 {
-		defaultValue = sizeIDs[0]
-	}
-
 	return NewStep(id, "Server Type").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(sizeIDs...).
-		OptionDescriptions(sizeDescs...).
+		LoadOptions(providerLoader("hetzner", token, loadSizes(location, describeHetznerSize))).
+		OfflineFallback(func() Step { return CreateHetznerServerTypeStep(id) }).
 		Required().
 		Build()
 }
 
-func CreateDynamicHetznerLocationStep(id string, provider providers.Provider) Step {
-	ctx := context.Background()
-
-	locations, err := provider.GetRegions(ctx)
-	if err != nil {
-		return CreateHetznerLocationStep(id)
-	}
-
-	var locationIDs []string
-	var locationDescs []string
-	for _, location := range locations {
-		locationIDs = append(locationIDs, location.ID)
-		locationDescs = append(locationDescs, location.Location)
+func describeHetznerSize(size providers.Size) string {
+	desc := fmt.Sprintf("%d vCPU, %d GB RAM, %d GB SSD", size.VCPUs, size.Memory/1024, size.Disk)
+	if size.Architecture == providers.ArchARM64 {
+		desc += ", ARM64"
 	}
-
-	defaultValue := "nbg1"
-	if len(locationIDs) > 0 {
-		defaultValue = locationIDs[0]
+	if size.PriceMonthly > 0 {
+		desc = fmt.Sprintf("%s (€%.2f/mo)", desc, size.PriceMonthly)
 	}
+	return desc
+}
 
+func CreateDynamicHetznerLocationStep(id string, provider providers.Provider) Step {
 	return NewStep(id, "Hetzner Location").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(locationIDs...).
-		OptionDescriptions(locationDescs...).
+		LoadOptions(func(ctx context.Context, results map[string]string) (StepOptions, error) {
+			return loadRegions(ctx, provider, results)
+		}).
+		OfflineFallback(func() Step { return CreateHetznerLocationStep(id) }).
 		Required().
 		Build()
 }
 
-func CreateDynamicHetznerServerTypeStep(id string, provider providers.Provider) Step {
-	ctx := context.Background()
-
-	serverTypes, err := provider.GetSizes(ctx, "")
-	if err != nil {
-		return CreateHetznerServerTypeStep(id)
-	}
-
-	sort.Slice(serverTypes, func(i, j int) bool {
-		return serverTypes[i].Memory < serverTypes[j].Memory
-	})
-
-	var typeIDs []string
-	var typeDescs []string
-	for _, st := range serverTypes {
-		typeIDs = append(typeIDs, st.ID)
-		typeDescs = append(typeDescs, st.Name)
-	}
-
-	defaultValue := "cx11"
-	if len(typeIDs) > 0 {
-		defaultValue = typeIDs[0]
-	}
-
+func CreateDynamicHetznerServerTypeStep(id string, provider providers.Provider, location string) Step {
 	return NewStep(id, "Server Type").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(typeIDs...).
-		OptionDescriptions(typeDescs...).
+		LoadOptions(providerSizes(provider, location)).
+		OfflineFallback(func() Step { return CreateHetznerServerTypeStep(id) }).
 		Required().
 		Build()
 }
 
-func (m *FlowModel) UpdateStepWithDynamicSizes(stepID string, provider providers.Provider, region string) error {
-	ctx := context.Background()
-
-	stepIndex := -1
-	for i, step := range m.Steps {
-		if step.ID == stepID {
-			stepIndex = i
-			break
-		}
-	}
-
-	if stepIndex == -1 {
-		return fmt.Errorf("step %s not found", stepID)
-	}
-
-	apiSizes, err := provider.GetSizes(ctx, region)
-	if err != nil {
-		return err
-	}
-
-	sort.Slice(apiSizes, func(i, j int) bool {
-		return apiSizes[i].Memory < apiSizes[j].Memory
-	})
-
-	var sizes []string
-	var sizeDescs []string
-	for _, size := range apiSizes {
-		sizes = append(sizes, size.ID)
-		sizeDescs = append(sizeDescs, size.Name)
-	}
-
-	step := m.Steps[stepIndex]
-	step.Options = sizes
-	step.OptionDescs = sizeDescs
-	if len(sizes) > 0 {
-		step.Value = sizes[0]
-	}
-
-	m.Steps[stepIndex] = step
-	m.StepStates[stepIndex] = step
-
-	return nil
-}
-
 func CreateVultrAPITokenStep(id string) Step {
 	return NewStep(id, "Vultr API Token").
 		Type(StepTypePassword).
@@ -650,77 +519,34 @@ func CreateVultrPlanStep(id string) Step {
 		Build()
 }
 
-// CreateVultrRegionStepDynamic creates region step with dynamic API data
+// CreateVultrRegionStepDynamic creates region step loaded from the API
 func CreateVultrRegionStepDynamic(id, token string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("vultr", token)
-	if err != nil {
-		return CreateVultrRegionStep(id)
-	}
-
-	regions, err := provider.GetRegions(ctx)
-	if err != nil {
-		return CreateVultrRegionStep(id)
-	}
-
-	if len(regions) == 0 {
-		return CreateVultrRegionStep(id)
-	}
-
-	var regionIDs []string
-	var regionDescs []string
-	for _, region := range regions {
-		regionIDs = append(regionIDs, region.ID)
-		regionDescs = append(regionDescs, region.Location)
-	}
-
 	return NewStep(id, "Vultr Region").
 		Type(StepTypeSelect).
-		DefaultValue(regionIDs[0]).
-		Options(regionIDs...).
-		OptionDescriptions(regionDescs...).
+		LoadOptions(providerLoader("vultr", token, loadRegions)).
+		OfflineFallback(func() Step { return CreateVultrRegionStep(id) }).
 		Required().
 		Build()
 }
 
-// CreateVultrPlanStepDynamic creates plan step with dynamic API data
+// CreateVultrPlanStepDynamic creates plan step loaded from the API
 func CreateVultrPlanStepDynamic(id, token, region string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("vultr", token)
-	if err != nil {
-		return CreateVultrPlanStep(id)
-	}
-
-	sizes, err := provider.GetSizes(ctx, region)
-	if err != nil || len(sizes) == 0 {
-		return CreateVultrPlanStep(id)
-	}
-
-	var planIDs []string
-	var planDescs []string
-	for _, size := range sizes {
-		planIDs = append(planIDs, size.ID)
-		desc := fmt.Sprintf("%d vCPU, %d GB RAM, %d GB SSD", size.VCPUs, size.Memory/1024, size.Disk)
-		if size.PriceMonthly > 0 {
-			desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
-		}
-		planDescs = append(planDescs, desc)
-	}
-
-	defaultValue := "vc2-1c-1gb"
-	if len(planIDs) > 0 {
-		defaultValue = planIDs[0]
-	}
-
 	return NewStep(id, "Instance Plan").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(planIDs...).
-		OptionDescriptions(planDescs...).
+		LoadOptions(providerLoader("vultr", token, loadSizes(region, describeVultrPlan))).
+		OfflineFallback(func() Step { return CreateVultrPlanStep(id) }).
 		Required().
 		Build()
 }
 
+func describeVultrPlan(size providers.Size) string {
+	desc := fmt.Sprintf("%d vCPU, %d GB RAM, %d GB SSD", size.VCPUs, size.Memory/1024, size.Disk)
+	if size.PriceMonthly > 0 {
+		desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
+	}
+	return desc
+}
+
 // CreateFlyioAPITokenStep creates an API token step for fly.io
 func CreateFlyioAPITokenStep(id string) Step {
 	return NewStep(id, "fly.io API Token").
@@ -731,31 +557,12 @@ func CreateFlyioAPITokenStep(id string) Step {
 		Build()
 }
 
-// CreateFlyioRegionStepDynamic creates region step with dynamic API data
+// CreateFlyioRegionStepDynamic creates region step loaded from the API
 func CreateFlyioRegionStepDynamic(id, token string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("flyio", token)
-	if err != nil {
-		return createFlyioRegionStepStatic(id)
-	}
-
-	regions, err := provider.GetRegions(ctx)
-	if err != nil || len(regions) == 0 {
-		return createFlyioRegionStepStatic(id)
-	}
-
-	var regionIDs []string
-	var regionDescs []string
-	for _, region := range regions {
-		regionIDs = append(regionIDs, region.ID)
-		regionDescs = append(regionDescs, region.Location)
-	}
-
 	return NewStep(id, "fly.io Region").
 		Type(StepTypeSelect).
-		DefaultValue(regionIDs[0]).
-		Options(regionIDs...).
-		OptionDescriptions(regionDescs...).
+		LoadOptions(providerLoader("flyio", token, loadRegions)).
+		OfflineFallback(func() Step { return createFlyioRegionStepStatic(id) }).
 		Required().
 		Build()
 }
@@ -792,44 +599,25 @@ func createFlyioRegionStepStatic(id string) Step {
 		Build()
 }
 
-// CreateFlyioSizeStepDynamic creates machine size step with dynamic API data
+// CreateFlyioSizeStepDynamic creates machine size step loaded from the API
 func CreateFlyioSizeStepDynamic(id, token, region string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("flyio", token)
-	if err != nil {
-		return createFlyioSizeStepStatic(id)
-	}
-
-	sizes, err := provider.GetSizes(ctx, region)
-	if err != nil || len(sizes) == 0 {
-		return createFlyioSizeStepStatic(id)
-	}
-
-	var sizeIDs []string
-	var sizeDescs []string
-	for _, size := range sizes {
-		sizeIDs = append(sizeIDs, size.ID)
-		desc := fmt.Sprintf("%d vCPU, %d MB RAM", size.VCPUs, size.Memory)
-		if size.PriceMonthly > 0 {
-			desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
-		}
-		sizeDescs = append(sizeDescs, desc)
-	}
-
-	defaultValue := "shared-cpu-1x"
-	if len(sizeIDs) > 0 {
-		defaultValue = sizeIDs[0]
-	}
-
 	return NewStep(id, "Machine Size").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(sizeIDs...).
-		OptionDescriptions(sizeDescs...).
+		LoadOptions(providerLoader("flyio", token, loadSizes(region, describeMemoryMB))).
+		OfflineFallback(func() Step { return createFlyioSizeStepStatic(id) }).
 		Required().
 		Build()
 }
 
+// describeMemoryMB describes sizes whose memory is small enough to read best in MB
+func describeMemoryMB(size providers.Size) string {
+	desc := fmt.Sprintf("%d vCPU, %d MB RAM", size.VCPUs, size.Memory)
+	if size.PriceMonthly > 0 {
+		desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
+	}
+	return desc
+}
+
 // createFlyioSizeStepStatic creates machine size step with static fallback data
 func createFlyioSizeStepStatic(id string) Step {
 	sizes := []struct {
@@ -961,31 +749,12 @@ func CreateLinodeAPITokenStep(id string) Step {
 		Build()
 }
 
-// CreateLinodeRegionStepDynamic creates region step with dynamic API data
+// CreateLinodeRegionStepDynamic creates region step loaded from the API
 func CreateLinodeRegionStepDynamic(id, token string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("linode", token)
-	if err != nil {
-		return createLinodeRegionStepStatic(id)
-	}
-
-	regions, err := provider.GetRegions(ctx)
-	if err != nil || len(regions) == 0 {
-		return createLinodeRegionStepStatic(id)
-	}
-
-	var regionIDs []string
-	var regionDescs []string
-	for _, region := range regions {
-		regionIDs = append(regionIDs, region.ID)
-		regionDescs = append(regionDescs, region.Location)
-	}
-
 	return NewStep(id, "Linode Region").
 		Type(StepTypeSelect).
-		DefaultValue(regionIDs[0]).
-		Options(regionIDs...).
-		OptionDescriptions(regionDescs...).
+		LoadOptions(providerLoader("linode", token, loadRegions)).
+		OfflineFallback(func() Step { return createLinodeRegionStepStatic(id) }).
 		Required().
 		Build()
 }
@@ -1022,40 +791,12 @@ func createLinodeRegionStepStatic(id string) Step {
 		Build()
 }
 
-// CreateLinodePlanStepDynamic creates plan step with dynamic API data
+// CreateLinodePlanStepDynamic creates plan step loaded from the API
 func CreateLinodePlanStepDynamic(id, token, region string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("linode", token)
-	if err != nil {
-		return createLinodePlanStepStatic(id)
-	}
-
-	sizes, err := provider.GetSizes(ctx, region)
-	if err != nil || len(sizes) == 0 {
-		return createLinodePlanStepStatic(id)
-	}
-
-	var planIDs []string
-	var planDescs []string
-	for _, size := range sizes {
-		planIDs = append(planIDs, size.ID)
-		desc := fmt.Sprintf("%d vCPU, %d MB RAM", size.VCPUs, size.Memory)
-		if size.PriceMonthly > 0 {
-			desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
-		}
-		planDescs = append(planDescs, desc)
-	}
-
-	defaultValue := "g6-nanode-1"
-	if len(planIDs) > 0 {
-		defaultValue = planIDs[0]
-	}
-
 	return NewStep(id, "Linode Plan").
 		Type(StepTypeSelect).
-		DefaultValue(defaultValue).
-		Options(planIDs...).
-		OptionDescriptions(planDescs...).
+		LoadOptions(providerLoader("linode", token, loadSizes(region, describeMemoryMB))).
+		OfflineFallback(func() Step { return createLinodePlanStepStatic(id) }).
 		Required().
 		Build()
 }
@@ -1124,6 +865,7 @@ func RunProvisionLinodeFlow(projectName string) (*config.LinodeConfig, error) {
 
 	flow := NewFlow("Provision Linode Instance", steps)
 	flow.SetProjectName(projectName)
+	flow.SetSizeProvider("linode", activeToken)
 
 	p := tea.NewProgram(flow)
 	finalModel, err := p.Run()
@@ -1188,39 +930,16 @@ func CreateAWSSecretKeyPromptStep(id string) Step {
 		Build()
 }
 
-// CreateAWSRegionStepDynamic creates region step with dynamic API data
+// CreateAWSRegionStepDynamic creates region step loaded from the API, sorted by region ID
 func CreateAWSRegionStepDynamic(id, token string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("aws", token)
-	if err != nil {
-		return createAWSRegionStepStatic(id)
-	}
-
-	regions, err := provider.GetRegions(ctx)
-	if err != nil || len(regions) == 0 {
-		return createAWSRegionStepStatic(id)
-	}
-
-	sort.Slice(regions, func(i, j int) bool {
-		return regions[i].ID < regions[j].ID
-	})
-
-	var regionIDs []string
-	var regionDescs []string
-	for _, region := range regions {
-		regionIDs = append(regionIDs, region.ID)
-		desc := region.Location
-		if desc == "" {
-			desc = region.ID
-		}
-		regionDescs = append(regionDescs, desc)
+	loadSorted := func(ctx context.Context, provider providers.Provider, results map[string]string) (StepOptions, error) {
+		return loadRegions(ctx, regionsByID{provider}, results)
 	}
 
 	return NewStep(id, "AWS Region").
 		Type(StepTypeSelect).
-		DefaultValue(regionIDs[0]).
-		Options(regionIDs...).
-		OptionDescriptions(regionDescs...).
+		LoadOptions(providerLoader("aws", token, loadSorted)).
+		OfflineFallback(func() Step { return createAWSRegionStepStatic(id) }).
 		Required().
 		Build()
 }
@@ -1247,50 +966,40 @@ func createAWSRegionStepStatic(id string) Step {
 		Build()
 }
 
-// CreateAWSInstanceTypeStepDynamic creates instance type step with dynamic API data
+// CreateAWSInstanceTypeStepDynamic creates instance type step loaded from the API
 func CreateAWSInstanceTypeStepDynamic(id, token, region string) Step {
-	ctx := context.Background()
-	provider, err := providers.GetProvider("aws", token)
-	if err != nil {
-		return createAWSInstanceTypeStepStatic(id)
-	}
-
-	sizes, err := provider.GetSizes(ctx, region)
-	if err != nil || len(sizes) == 0 {
-		return createAWSInstanceTypeStepStatic(id)
-	}
-
-	var typeIDs []string
-	var typeDescs []string
-	for _, size := range sizes {
-		typeIDs = append(typeIDs, size.ID)
-		desc := fmt.Sprintf("%d vCPU, %.1f GB RAM", size.VCPUs, float64(size.Memory)/1024.0)
-		if size.PriceMonthly > 0 {
-			desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
-		}
-		typeDescs = append(typeDescs, desc)
-	}
-
 	return NewStep(id, "Instance Type").
 		Type(StepTypeSelect).
-		DefaultValue(typeIDs[0]).
-		Options(typeIDs...).
-		OptionDescriptions(typeDescs...).
+		LoadOptions(providerLoader("aws", token, loadSizes(region, describeAWSInstanceType))).
+		OfflineFallback(func() Step { return createAWSInstanceTypeStepStatic(id) }).
 		Required().
 		Build()
 }
 
+func describeAWSInstanceType(size providers.Size) string {
+	desc := fmt.Sprintf("%d vCPU, %.1f GB RAM", size.VCPUs, float64(size.Memory)/1024.0)
+	if size.Architecture == providers.ArchARM64 {
+		desc += ", ARM64"
+	}
+	if size.PriceMonthly > 0 {
+		desc = fmt.Sprintf("%s ($%.2f/mo)", desc, size.PriceMonthly)
+	}
+	return desc
+}
+
 // createAWSInstanceTypeStepStatic creates instance type step with static data (fallback)
 func createAWSInstanceTypeStepStatic(id string) Step {
 	types := []string{
 		"t3.micro", "t3.small", "t3.medium", "t3.large",
 		"t3a.micro", "t3a.small", "t3a.medium", "t3a.large",
 		"m5.large", "m5.xlarge",
+		"t4g.small", "t4g.medium",
 	}
 	typeDescs := []string{
 		"2 vCPU, 1 GB RAM (~$7/mo)", "2 vCPU, 2 GB RAM (~$15/mo)", "2 vCPU, 4 GB RAM (~$30/mo)", "2 vCPU, 8 GB RAM (~$60/mo)",
 		"2 vCPU, 1 GB RAM (~$6/mo)", "2 vCPU, 2 GB RAM (~$13/mo)", "2 vCPU, 4 GB RAM (~$27/mo)", "2 vCPU, 8 GB RAM (~$54/mo)",
 		"2 vCPU, 8 GB RAM (~$70/mo)", "4 vCPU, 16 GB RAM (~$140/mo)",
+		"2 vCPU, 2 GB RAM, ARM64 (~$12/mo)", "2 vCPU, 4 GB RAM, ARM64 (~$25/mo)",
 	}
 
 	return NewStep(id, "Instance Type").
@@ -1354,6 +1063,9 @@ func RunProvisionAWSFlow(projectName string) (*config.AWSConfig, error) {
 
 	flow := NewFlow("Provision AWS EC2 Instance", steps)
 	flow.SetProjectName(projectName)
+	if hasExistingToken {
+		flow.SetSizeProvider("aws", activeToken)
+	}
 
 	p := tea.NewProgram(flow)
 	finalModel, err := p.Run()
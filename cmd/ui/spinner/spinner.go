@@ -2,10 +2,10 @@ package spinner
 
 import (
 	"fmt"
+	"lightfold/cmd/ui/style"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 )
 
 type errMsg error
@@ -21,7 +21,7 @@ type model struct {
 func InitialModel(message string) model {
 	s := spinner.New()
 	s.Spinner = spinner.Line
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#01FAC6"))
+	s.Style = style.Brand
 	return model{
 		spinner: s,
 		message: message,
@@ -0,0 +1,144 @@
+// Package style is the palette and symbols commands render their output
+// with. Plain output, for pipes, CI logs, NO_COLOR, TERM=dumb and --no-color,
+// drops colors and replaces box drawing, spinners and unicode symbols with
+// ASCII.
+package style
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Palette colors
+const (
+	ColorBrand   = "#01FAC6"
+	ColorHeader  = "86"
+	ColorSuccess = "82"
+	ColorMuted   = "245"
+	ColorValue   = "170"
+	ColorText    = "252"
+	ColorWarning = "214"
+	ColorError   = "196"
+	ColorFaint   = "240"
+	ColorHint    = "243"
+)
+
+// Styles commands share. Colors are dropped when they render in plain mode.
+var (
+	Title       = Fg(ColorBrand).Bold(true)
+	Brand       = Fg(ColorBrand)
+	Header      = Fg(ColorHeader).Bold(true)
+	Label       = Fg(ColorHeader)
+	Success     = Fg(ColorSuccess)
+	Muted       = Fg(ColorMuted)
+	Value       = Fg(ColorValue)
+	Text        = Fg(ColorText)
+	Warning     = Fg(ColorWarning).Bold(true)
+	WarningText = Fg(ColorWarning)
+	Error       = Fg(ColorError).Bold(true)
+	ErrorText   = Fg(ColorError)
+	Faint       = Fg(ColorFaint)
+	Hint        = Fg(ColorHint)
+	// Badge is dark text on the brand color, for screen titles
+	Badge = lipgloss.NewStyle().Background(lipgloss.Color(ColorBrand)).Foreground(lipgloss.Color("#030303")).Bold(true)
+)
+
+var plain bool
+
+// Fg returns a style with a foreground color, for the few places a color
+// outside the named styles is needed
+func Fg(color string) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+}
+
+// Box returns a bordered style with the border in color
+func Box(color string) lipgloss.Style {
+	return lipgloss.NewStyle().Border(Border()).BorderForeground(lipgloss.Color(color))
+}
+
+// Configure picks plain or styled output for stdout. noColor is --no-color.
+func Configure(noColor bool) {
+	Apply(noColor, termenv.NewOutput(os.Stdout).ColorProfile())
+}
+
+// Apply sets up output for a terminal with the given color profile; a pipe
+// reports termenv.Ascii. Output is plain for --no-color, NO_COLOR, TERM=dumb
+// and terminals without colors.
+func Apply(noColor bool, profile termenv.Profile) {
+	plain = noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || profile == termenv.Ascii
+	if plain {
+		profile = termenv.Ascii
+	}
+	lipgloss.SetColorProfile(profile)
+}
+
+// Plain reports whether output is plain ASCII without colors
+func Plain() bool {
+	return plain
+}
+
+func symbol(fancy, ascii string) string {
+	if plain {
+		return ascii
+	}
+	return fancy
+}
+
+// Check marks a step that succeeded
+func Check() string { return symbol("✓", "[ok]") }
+
+// Cross marks a step that failed
+func Cross() string { return symbol("✗", "[error]") }
+
+// Warn marks a warning
+func Warn() string { return symbol("⚠", "[warn]") }
+
+// Info marks a note
+func Info() string { return symbol("ℹ", "[info]") }
+
+// Paused marks a paused target
+func Paused() string { return symbol("⏸", "[paused]") }
+
+// Skipped marks a step that did not run
+func Skipped() string { return symbol("⊘", "[skip]") }
+
+// Waiting marks a step waiting on another
+func Waiting() string { return symbol("⧗", "[wait]") }
+
+// Pending marks a step still to come
+func Pending() string { return symbol("○", "[ ]") }
+
+// Play marks something running again
+func Play() string { return symbol("▶", ">") }
+
+// Pointer points back at a value that changed
+func Pointer() string { return symbol("◀", "<-") }
+
+// Arrow separates a before and an after
+func Arrow() string { return symbol("→", "->") }
+
+// Bullet starts a list item
+func Bullet() string { return symbol("•", "*") }
+
+// Rule returns a horizontal line width characters wide
+func Rule(width int) string { return strings.Repeat(symbol("━", "-"), width) }
+
+// Border returns the border boxes are drawn with
+func Border() lipgloss.Border {
+	if plain {
+		return lipgloss.ASCIIBorder()
+	}
+	return lipgloss.RoundedBorder()
+}
+
+// Spinner returns the spinner shown while commands wait
+func Spinner() spinner.Spinner {
+	if plain {
+		return spinner.Line
+	}
+	return spinner.Dot
+}
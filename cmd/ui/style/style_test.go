@@ -0,0 +1,67 @@
+package style
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// render formats a success line and a box the way commands print them
+func render() string {
+	return Success.Render(Check()+" Deployed") + "\n" + Box(ColorSuccess).Render(Arrow()+" done")
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm-256color")
+	defer Apply(false, termenv.ANSI256)
+
+	tests := []struct {
+		name      string
+		noColor   bool
+		profile   termenv.Profile
+		env       map[string]string
+		wantPlain bool
+	}{
+		{name: "terminal", profile: termenv.ANSI256},
+		{name: "not a terminal", profile: termenv.Ascii, wantPlain: true},
+		{name: "--no-color", noColor: true, profile: termenv.ANSI256, wantPlain: true},
+		{name: "NO_COLOR", profile: termenv.ANSI256, env: map[string]string{"NO_COLOR": "1"}, wantPlain: true},
+		{name: "TERM=dumb", profile: termenv.ANSI256, env: map[string]string{"TERM": "dumb"}, wantPlain: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			Apply(tt.noColor, tt.profile)
+			if Plain() != tt.wantPlain {
+				t.Fatalf("Plain() = %v, want %v", Plain(), tt.wantPlain)
+			}
+
+			out := render()
+			if tt.wantPlain {
+				if strings.Contains(out, "\x1b[") {
+					t.Errorf("plain output has escape codes: %q", out)
+				}
+				for _, want := range []string{"[ok] Deployed", "-> done", "+"} {
+					if !strings.Contains(out, want) {
+						t.Errorf("plain output %q is missing %q", out, want)
+					}
+				}
+				for _, r := range out {
+					if r > 127 {
+						t.Fatalf("plain output has non-ASCII %q: %q", r, out)
+					}
+				}
+				return
+			}
+			for _, want := range []string{"\x1b[", "✓", "→", "╭"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("terminal output %q is missing %q", out, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"slices"
+	"strings"
+)
+
+// RemoteAppName returns the app name used on the server: the systemd unit, the
+// remote app directory (/srv/<app>) and the nginx site. It follows the target
+// name, so two targets of one project directory (myapp-staging, myapp-prod)
+// never share a directory. A pinned or adopted name (target.AppName) wins;
+// targets created while the name followed the project directory keep that
+// name through a config migration.
+func RemoteAppName(target *config.TargetConfig, targetName string) string {
+	if target.AppName != "" {
+		return target.AppName
+	}
+	return util.AppNameFromTarget(targetName)
+}
+
+// CommandRunner runs a command on a server
+type CommandRunner interface {
+	Execute(command string) *sshpkg.CommandResult
+}
+
+// legacyAppNames returns the underscore names older releases used for the app,
+// excluding the current name
+func legacyAppNames(target *config.TargetConfig, targetName string) []string {
+	appName := RemoteAppName(target, targetName)
+	var names []string
+	for _, name := range []string{util.LegacyAppName(appName), util.LegacyAppName(targetName)} {
+		if name != appName && name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DetectLegacyAppName looks for a deployment of the target under one of its
+// legacy underscore names. It returns the legacy name when only that
+// deployment exists on the server, so the caller can adopt it instead of
+// deploying a second copy next to it.
+func DetectLegacyAppName(runner CommandRunner, target *config.TargetConfig, targetName string) (string, bool) {
+	if target.AppName != "" {
+		return "", false
+	}
+
+	legacy := legacyAppNames(target, targetName)
+	if len(legacy) == 0 {
+		return "", false
+	}
+
+	candidates := append([]string{RemoteAppName(target, targetName)}, legacy...)
+	var checks []string
+	for _, name := range candidates {
+		checks = append(checks, fmt.Sprintf("[ -d %s ] && echo %s", config.AppDir(target.RemoteBaseDir(), name), name))
+	}
+	result := runner.Execute(strings.Join(checks, "; ") + "; true")
+	if result.Error != nil || result.ExitCode != 0 {
+		return "", false
+	}
+
+	found := make(map[string]bool)
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		found[strings.TrimSpace(line)] = true
+	}
+	if found[candidates[0]] {
+		return "", false
+	}
+	for _, name := range legacy {
+		if found[name] {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+// fakeDirRunner answers the directory checks of DetectLegacyAppName from a
+// set of existing /srv/<app> directories
+type fakeDirRunner map[string]bool
+
+func (f fakeDirRunner) Execute(command string) *sshpkg.CommandResult {
+	var out []string
+	for _, check := range strings.Split(command, "; ") {
+		fields := strings.Fields(check)
+		if len(fields) < 3 || fields[0] != "[" {
+			continue
+		}
+		if f[strings.TrimPrefix(fields[2], config.RemoteAppBaseDir+"/")] {
+			out = append(out, fields[len(fields)-1])
+		}
+	}
+	return &sshpkg.CommandResult{Stdout: strings.Join(out, "\n")}
+}
+
+func TestRemoteAppName_HyphenatedTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target config.TargetConfig
+		want   string
+	}{
+		{"from target name", config.TargetConfig{ProjectPath: "/home/dev/my-app"}, "my-app"},
+		{"not the project directory", config.TargetConfig{ProjectPath: "/home/dev/shop"}, "my-app"},
+		{"without project path", config.TargetConfig{}, "my-app"},
+		{"adopted legacy name", config.TargetConfig{ProjectPath: "/home/dev/my-app", AppName: "my_app"}, "my_app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RemoteAppName(&tt.target, "my-app"); got != tt.want {
+				t.Errorf("RemoteAppName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteAppName_SameProjectTwoTargets(t *testing.T) {
+	staging := config.TargetConfig{ProjectPath: "/home/dev/api"}
+	prod := config.TargetConfig{ProjectPath: "/home/dev/api"}
+	if a, b := RemoteAppName(&staging, "api-staging"), RemoteAppName(&prod, "api-prod"); a == b {
+		t.Errorf("targets of one project share app name %q", a)
+	}
+}
+
+func TestDetectLegacyAppName(t *testing.T) {
+	target := &config.TargetConfig{ProjectPath: "/home/dev/my-app"}
+
+	tests := []struct {
+		name       string
+		dirs       fakeDirRunner
+		wantLegacy string
+	}{
+		{"only legacy deployment", fakeDirRunner{"my_app": true}, "my_app"},
+		{"current deployment", fakeDirRunner{"my-app": true}, ""},
+		{"both deployments", fakeDirRunner{"my-app": true, "my_app": true}, ""},
+		{"nothing deployed", fakeDirRunner{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legacy, found := DetectLegacyAppName(tt.dirs, target, "my-app")
+			if legacy != tt.wantLegacy || found != (tt.wantLegacy != "") {
+				t.Errorf("DetectLegacyAppName() = %q, %v; want %q", legacy, found, tt.wantLegacy)
+			}
+		})
+	}
+
+	if _, found := DetectLegacyAppName(fakeDirRunner{"myapp": true}, &config.TargetConfig{ProjectPath: "/srv/myapp"}, "myapp"); found {
+		t.Error("a name without hyphens has no legacy form")
+	}
+	if _, found := DetectLegacyAppName(fakeDirRunner{"my_app": true}, &config.TargetConfig{AppName: "my_app"}, "my-app"); found {
+		t.Error("an already adopted name should not be detected again")
+	}
+}
+
+func TestRegisterAppWithServer_HyphenatedTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	target := &config.TargetConfig{ProjectPath: "/home/dev/my-app", ServerIP: "203.0.113.10"}
+	if err := RegisterAppWithServer(target, "my-app", 3000, "Express.js"); err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := state.GetAppFromServer("203.0.113.10", "my-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.AppName != RemoteAppName(target, "my-app") {
+		t.Errorf("registered AppName = %q, want %q", app.AppName, RemoteAppName(target, "my-app"))
+	}
+}
@@ -3,18 +3,41 @@ package utils
 import (
 	"fmt"
 	"lightfold/pkg/config"
+	"lightfold/pkg/deploy"
 	"lightfold/pkg/detector"
+	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
-	"strconv"
-	"strings"
 	"time"
 )
 
-// GetOrAllocatePort gets the port for a target, allocating one if necessary
-func GetOrAllocatePort(target *config.TargetConfig, targetName string) (int, error) {
+// GetOrAllocatePort gets the port for a target, allocating one if necessary.
+// When runner is set, a newly allocated port is also reserved on the server,
+// so lightfold runs on other machines cannot hand it out too.
+func GetOrAllocatePort(target *config.TargetConfig, targetName string, runner sshpkg.SudoRunner) (int, error) {
+	allocate := state.AllocatePort
+	if runner != nil {
+		appName := RemoteAppName(target, targetName)
+		allocate = func(serverIP string) (int, error) {
+			return state.AllocatePortWith(serverIP, func(port int) (bool, error) {
+				return deploy.ReservePort(runner, port, appName)
+			})
+		}
+	}
+	port, _, err := resolvePort(target, targetName, allocate)
+	return port, err
+}
+
+// PlanPort returns the port a deploy would use and where it comes from
+// ("configured", "registered", "allocated" or "detected") without reserving
+// a new port in server state
+func PlanPort(target *config.TargetConfig, targetName string) (int, string, error) {
+	return resolvePort(target, targetName, state.PeekPort)
+}
+
+func resolvePort(target *config.TargetConfig, targetName string, allocate func(serverIP string) (int, error)) (int, string, error) {
 	// If port is already set in target config, use it
 	if target.Port > 0 {
-		return target.Port, nil
+		return target.Port, "configured", nil
 	}
 
 	// If ServerIP is set, use server state for port allocation
@@ -22,19 +45,19 @@ func GetOrAllocatePort(target *config.TargetConfig, targetName string) (int, err
 		// Check if app is already registered with a port
 		app, err := state.GetAppFromServer(target.ServerIP, targetName)
 		if err == nil && app.Port > 0 {
-			return app.Port, nil
+			return app.Port, "registered", nil
 		}
 
 		// Allocate a new port
-		port, err := state.AllocatePort(target.ServerIP)
+		port, err := allocate(target.ServerIP)
 		if err != nil {
-			return 0, fmt.Errorf("failed to allocate port: %w", err)
+			return 0, "", fmt.Errorf("failed to allocate port: %w", err)
 		}
-		return port, nil
+		return port, "allocated", nil
 	}
 
 	// Fallback to default port detection
-	return ExtractPortFromTarget(target, target.ProjectPath), nil
+	return ExtractPortFromTarget(target, target.ProjectPath), "detected", nil
 }
 
 // RegisterAppWithServer registers an app in the server state
@@ -43,9 +66,14 @@ func RegisterAppWithServer(target *config.TargetConfig, targetName string, port
 		return nil // Not using server state
 	}
 
+	appName := RemoteAppName(target, targetName)
+	if err := CheckServerAppCollision(target.ServerIP, targetName, appName); err != nil {
+		return err
+	}
+
 	app := state.DeployedApp{
 		TargetName: targetName,
-		AppName:    targetName,
+		AppName:    appName,
 		Port:       port,
 		Framework:  framework,
 		LastDeploy: time.Now(),
@@ -59,48 +87,54 @@ func RegisterAppWithServer(target *config.TargetConfig, targetName string, port
 	return state.RegisterApp(target.ServerIP, app)
 }
 
-// ExtractPortFromTarget extracts the port from target config or detection
-func ExtractPortFromTarget(target *config.TargetConfig, projectPath string) int {
-	detection := detector.DetectFramework(projectPath)
+// CheckServerAppCollision returns an error if a different target on the server already
+// deploys an app with the same name, which would share (and overwrite) its remote directory
+func CheckServerAppCollision(serverIP, targetName, appName string) error {
+	if serverIP == "" || !state.ServerStateExists(serverIP) {
+		return nil
+	}
 
-	for _, runCmd := range detection.RunPlan {
-		if strings.Contains(runCmd, "-port") || strings.Contains(runCmd, "--port") {
-			parts := strings.Fields(runCmd)
-			for i, part := range parts {
-				if (part == "-port" || part == "--port") && i+1 < len(parts) {
-					if port, err := strconv.Atoi(parts[i+1]); err == nil {
-						return port
-					}
-				}
-			}
+	serverState, err := state.GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	for _, app := range serverState.DeployedApps {
+		if app.TargetName == targetName {
+			continue
 		}
+		if app.AppName == appName || (app.AppName == "" && app.TargetName == appName) {
+			return fmt.Errorf("app '%s' on server %s is already deployed by target '%s'\nUse a different target name or deploy it to a different server",
+				appName, serverIP, app.TargetName)
+		}
+	}
 
-		if strings.Contains(runCmd, "--bind") || strings.Contains(runCmd, "--listen") {
-			parts := strings.Fields(runCmd)
-			for i, part := range parts {
-				if (part == "--bind" || part == "--listen") && i+1 < len(parts) {
-					bindAddr := parts[i+1]
-					if colonIdx := strings.LastIndex(bindAddr, ":"); colonIdx != -1 {
-						portStr := bindAddr[colonIdx+1:]
-						if port, err := strconv.Atoi(portStr); err == nil {
-							return port
-						}
-					}
-				}
-			}
+	return nil
+}
+
+// ExtractPortFromTarget estimates the port from the run plan or the framework's
+// default. It is a local guess; use ResolveTargetPort when the server is reachable.
+func ExtractPortFromTarget(target *config.TargetConfig, projectPath string) int {
+	detection := detector.DetectAppAs(projectPath, target.AppSubdir(), target.FrameworkOverride)
+
+	for _, runCmd := range detection.RunPlan {
+		if port, ok := PortFromCommand(runCmd, nil); ok {
+			return port
 		}
 	}
 
 	switch detection.Framework {
 	case "Next.js", "Nuxt.js", "Remix", "SvelteKit", "Astro":
 		return 3000
-	case "Django", "Flask", "FastAPI":
+	case "Django", "FastAPI":
+		return 8000
+	case "Flask":
 		return 5000
 	case "Go", "Fiber":
 		return 8080
 	case "Express.js":
 		return 3000
-	case "Ruby on Rails":
+	case "Rails", "Ruby on Rails":
 		return 3000
 	default:
 		return 3000
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"strconv"
+	"strings"
+)
+
+// PortFromCommand returns the port a start command binds to. $PORT and ${PORT}
+// references are resolved from env.
+func PortFromCommand(command string, env map[string]string) (int, bool) {
+	parts := strings.Fields(command)
+	for i, part := range parts {
+		flag, value, hasValue := strings.Cut(part, "=")
+		if !hasValue && i+1 < len(parts) {
+			value = parts[i+1]
+		}
+
+		switch flag {
+		case "--port", "-port", "-p":
+			if port, ok := parsePortValue(value, env); ok {
+				return port, true
+			}
+		case "--bind", "-b", "--listen", "-l":
+			if port, ok := parsePortValue(portFromAddress(value), env); ok {
+				return port, true
+			}
+		case "PORT":
+			// Inline assignment, e.g. `env PORT=4000 node server.js`
+			if hasValue {
+				if port, ok := parsePortValue(value, env); ok {
+					return port, true
+				}
+			}
+		case "runserver":
+			// Django's development server: runserver [addr:]port
+			if i+1 < len(parts) {
+				if port, ok := parsePortValue(portFromAddress(parts[i+1]), env); ok {
+					return port, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// PortFromSystemdUnit returns the port a service listens on, from `systemctl cat`
+// output. A port in ExecStart wins; otherwise the unit's PORT environment variable is used.
+func PortFromSystemdUnit(unit string) (int, bool) {
+	env := make(map[string]string)
+	var execStart string
+
+	for _, line := range strings.Split(unit, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "Environment":
+			for _, assignment := range strings.Fields(value) {
+				name, envValue, ok := strings.Cut(strings.Trim(assignment, `"'`), "=")
+				if ok {
+					env[name] = envValue
+				}
+			}
+		case "ExecStart":
+			// Drop-ins reset ExecStart with an empty assignment; the last one wins
+			execStart = strings.TrimLeft(strings.TrimSpace(value), "-@+!:")
+		}
+	}
+
+	if port, ok := PortFromCommand(execStart, env); ok {
+		return port, true
+	}
+	return parsePortValue(env["PORT"], env)
+}
+
+// ListeningPortForPID returns the TCP port a process listens on, from `ss -tlnpH` output
+func ListeningPortForPID(ssOutput string, pid int) (int, bool) {
+	pidMarker := fmt.Sprintf("pid=%d,", pid)
+	for _, line := range strings.Split(ssOutput, "\n") {
+		if !strings.Contains(line, pidMarker) {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		if port, ok := parsePortValue(portFromAddress(fields[3]), nil); ok {
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+// isPortListening reports whether `ss -tlnH` output has a listener on port
+func isPortListening(ssOutput string, port int) bool {
+	for _, line := range strings.Split(ssOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		if p, ok := parsePortValue(portFromAddress(fields[3]), nil); ok && p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverServicePort finds the port the app's systemd service is listening on.
+// The unit file is checked first, falling back to the sockets held by its main PID.
+func DiscoverServicePort(runner sshpkg.SudoRunner, serviceName string) (int, error) {
+	listeners := runner.ExecuteSudo("ss -tlnpH")
+	if listeners.Error != nil || listeners.ExitCode != 0 {
+		return 0, fmt.Errorf("failed to list listening ports: %s", strings.TrimSpace(listeners.Stderr))
+	}
+
+	unit := runner.ExecuteSudo(fmt.Sprintf("systemctl cat %s", serviceName))
+	if unit.Error != nil || unit.ExitCode != 0 {
+		return 0, fmt.Errorf("service %s is not installed on the server", serviceName)
+	}
+
+	unitPort, hasUnitPort := PortFromSystemdUnit(unit.Stdout)
+	if hasUnitPort && isPortListening(listeners.Stdout, unitPort) {
+		return unitPort, nil
+	}
+
+	pidResult := runner.ExecuteSudo(fmt.Sprintf("systemctl show -p MainPID --value %s", serviceName))
+	if pid, err := strconv.Atoi(strings.TrimSpace(pidResult.Stdout)); err == nil && pid > 0 {
+		if port, ok := ListeningPortForPID(listeners.Stdout, pid); ok {
+			return port, nil
+		}
+	}
+
+	if hasUnitPort {
+		return 0, fmt.Errorf("service %s is configured for port %d but nothing is listening on it", serviceName, unitPort)
+	}
+	return 0, fmt.Errorf("no listening port found for service %s", serviceName)
+}
+
+// ResolveTargetPort returns the port nginx should proxy to for the target and
+// checks that something is listening on it. When the target has no port
+// recorded it is discovered from the server and discovered is true, so the
+// caller can persist it.
+func ResolveTargetPort(target *config.TargetConfig, targetName string, runner sshpkg.SudoRunner) (port int, discovered bool, err error) {
+	redeployHint := fmt.Sprintf("redeploy first with: lightfold push --target %s", targetName)
+
+	if target.Port > 0 {
+		listeners := runner.ExecuteSudo(fmt.Sprintf("ss -tlnH 'sport = :%d'", target.Port))
+		if listeners.Error != nil || listeners.ExitCode != 0 {
+			return 0, false, fmt.Errorf("failed to check port %d: %s", target.Port, strings.TrimSpace(listeners.Stderr))
+		}
+		if !isPortListening(listeners.Stdout, target.Port) {
+			return 0, false, fmt.Errorf("nothing is listening on port %d for '%s'; %s", target.Port, targetName, redeployHint)
+		}
+		return target.Port, false, nil
+	}
+
+	port, err = DiscoverServicePort(runner, RemoteAppName(target, targetName))
+	if err != nil {
+		return 0, false, fmt.Errorf("%w; %s", err, redeployHint)
+	}
+	return port, true, nil
+}
+
+// portFromAddress returns the port part of host:port, [::]:port or tcp://host:port
+func portFromAddress(address string) string {
+	if idx := strings.LastIndex(address, ":"); idx != -1 {
+		return address[idx+1:]
+	}
+	return address
+}
+
+func parsePortValue(value string, env map[string]string) (int, bool) {
+	value = strings.Trim(value, `"'`)
+	switch value {
+	case "$PORT", "${PORT}":
+		value = env["PORT"]
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
@@ -0,0 +1,183 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+)
+
+func TestPortFromCommand(t *testing.T) {
+	env := map[string]string{"PORT": "3001"}
+
+	tests := []struct {
+		name    string
+		command string
+		want    int
+		wantOK  bool
+	}{
+		{"gunicorn bind with env", "/srv/app/venv/bin/gunicorn app.wsgi:application --bind 0.0.0.0:$PORT --workers 2", 3001, true},
+		{"gunicorn hardcoded bind", "gunicorn myproject.wsgi:application --bind 0.0.0.0:8000", 8000, true},
+		{"gunicorn short bind", "gunicorn -b 127.0.0.1:8001 app:app", 8001, true},
+		{"uvicorn port", "uvicorn main:app --host 0.0.0.0 --port $PORT", 3001, true},
+		{"uvicorn braced env", "uvicorn main:app --host 0.0.0.0 --port ${PORT}", 3001, true},
+		{"port with equals", "node server.js --port=4000", 4000, true},
+		{"go single dash", "/srv/app/current/app -port 9090", 9090, true},
+		{"rails short flag", "bundle exec rails server -b 0.0.0.0 -p 3005", 3005, true},
+		{"puma listen", "puma --listen tcp://0.0.0.0:3002", 3002, true},
+		{"django runserver", "python manage.py runserver 0.0.0.0:8000", 8000, true},
+		{"inline env", "/usr/bin/env PORT=4321 node server.js", 4321, true},
+		{"next standalone uses env only", "node server.js", 0, false},
+		{"unresolved env", "uvicorn main:app --port $APP_PORT", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := utils.PortFromCommand(tt.command, env)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("PortFromCommand(%q) = %d, %v; want %d, %v", tt.command, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPortFromSystemdUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		unit   string
+		want   int
+		wantOK bool
+	}{
+		{
+			name: "next standalone reads PORT",
+			unit: `# /etc/systemd/system/web.service
+[Service]
+Environment=NODE_ENV=production
+Environment=PORT=3000
+ExecStart=/usr/bin/node server.js`,
+			want: 3000, wantOK: true,
+		},
+		{
+			name: "hardcoded bind wins over PORT",
+			unit: `[Service]
+Environment=PORT=3001
+ExecStart=/srv/app/venv/bin/gunicorn app.wsgi:application --bind 0.0.0.0:8000`,
+			want: 8000, wantOK: true,
+		},
+		{
+			name: "quoted multi-assignment environment",
+			unit: `[Service]
+Environment="NODE_ENV=production" "PORT=3004"
+ExecStart=/srv/app/venv/bin/uvicorn main:app --host 0.0.0.0 --port $PORT`,
+			want: 3004, wantOK: true,
+		},
+		{
+			name: "drop-in overrides ExecStart",
+			unit: `[Service]
+Environment=PORT=3001
+ExecStart=/usr/bin/node server.js
+
+# /etc/systemd/system/web.service.d/override.conf
+[Service]
+ExecStart=
+ExecStart=-/usr/bin/node server.js --port 4000`,
+			want: 4000, wantOK: true,
+		},
+		{
+			name: "no port information",
+			unit: `[Service]
+ExecStart=/usr/bin/node server.js`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := utils.PortFromSystemdUnit(tt.unit)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("PortFromSystemdUnit() = %d, %v; want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestListeningPortForPID(t *testing.T) {
+	output := `LISTEN 0 511 0.0.0.0:80 0.0.0.0:* users:(("nginx",pid=812,fd=6))
+LISTEN 0 2048 0.0.0.0:8000 0.0.0.0:* users:(("gunicorn",pid=1234,fd=5))
+LISTEN 0 511 [::]:3000 [::]:* users:(("node",pid=12345,fd=20))`
+
+	if port, ok := utils.ListeningPortForPID(output, 1234); !ok || port != 8000 {
+		t.Errorf("expected port 8000 for pid 1234, got %d, %v", port, ok)
+	}
+	if port, ok := utils.ListeningPortForPID(output, 12345); !ok || port != 3000 {
+		t.Errorf("expected port 3000 for pid 12345, got %d, %v", port, ok)
+	}
+	if _, ok := utils.ListeningPortForPID(output, 999); ok {
+		t.Error("expected no port for unknown pid")
+	}
+}
+
+type fakeSudoRunner map[string]*sshpkg.CommandResult
+
+func (f fakeSudoRunner) ExecuteSudo(command string) *sshpkg.CommandResult {
+	for prefix, result := range f {
+		if strings.HasPrefix(command, prefix) {
+			return result
+		}
+	}
+	return &sshpkg.CommandResult{ExitCode: 1}
+}
+
+func TestResolveTargetPort(t *testing.T) {
+	target := &config.TargetConfig{}
+
+	t.Run("discovers port from unit", func(t *testing.T) {
+		runner := fakeSudoRunner{
+			"ss -tlnpH":     {Stdout: `LISTEN 0 2048 0.0.0.0:8000 0.0.0.0:* users:(("gunicorn",pid=1234,fd=5))`},
+			"systemctl cat": {Stdout: "[Service]\nEnvironment=PORT=3001\nExecStart=/srv/web/venv/bin/gunicorn --bind 0.0.0.0:8000 app:app"},
+		}
+		port, discovered, err := utils.ResolveTargetPort(target, "web", runner)
+		if err != nil || port != 8000 || !discovered {
+			t.Errorf("got %d, %v, %v; want 8000, true, nil", port, discovered, err)
+		}
+	})
+
+	t.Run("falls back to main PID", func(t *testing.T) {
+		runner := fakeSudoRunner{
+			"ss -tlnpH":                 {Stdout: `LISTEN 0 511 [::]:3000 [::]:* users:(("node",pid=4321,fd=20))`},
+			"systemctl cat":             {Stdout: "[Service]\nExecStart=/usr/bin/npm start"},
+			"systemctl show -p MainPID": {Stdout: "4321\n"},
+		}
+		port, _, err := utils.ResolveTargetPort(target, "web", runner)
+		if err != nil || port != 3000 {
+			t.Errorf("got %d, %v; want 3000", port, err)
+		}
+	})
+
+	t.Run("refuses when nothing listens", func(t *testing.T) {
+		runner := fakeSudoRunner{
+			"ss -tlnpH":     {Stdout: ""},
+			"systemctl cat": {Stdout: "[Service]\nEnvironment=PORT=3001\nExecStart=/usr/bin/node server.js"},
+		}
+		_, _, err := utils.ResolveTargetPort(target, "web", runner)
+		if err == nil || !strings.Contains(err.Error(), "lightfold push --target web") {
+			t.Errorf("expected a redeploy hint, got %v", err)
+		}
+	})
+
+	t.Run("verifies configured port", func(t *testing.T) {
+		configured := &config.TargetConfig{Port: 3002}
+		runner := fakeSudoRunner{"ss -tlnH": {Stdout: ""}}
+		if _, _, err := utils.ResolveTargetPort(configured, "web", runner); err == nil {
+			t.Error("expected an error when the configured port has no listener")
+		}
+
+		runner = fakeSudoRunner{"ss -tlnH": {Stdout: "LISTEN 0 511 127.0.0.1:3002 0.0.0.0:*"}}
+		port, discovered, err := utils.ResolveTargetPort(configured, "web", runner)
+		if err != nil || port != 3002 || discovered {
+			t.Errorf("got %d, %v, %v; want 3002, false, nil", port, discovered, err)
+		}
+	})
+}
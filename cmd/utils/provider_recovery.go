@@ -3,12 +3,11 @@ package utils
 import (
 	"context"
 	"fmt"
+	"lightfold/cmd/ui/style"
 	"lightfold/pkg/config"
 	"lightfold/pkg/providers"
 	"lightfold/pkg/providers/flyio"
 	"strings"
-
-	"github.com/charmbracelet/lipgloss"
 )
 
 // RecoverIPFromProvider is a generic function to recover IP from any provider
@@ -32,15 +31,19 @@ func RecoverIPFromProvider(target *config.TargetConfig, targetName, providerName
 	if err != nil {
 		return fmt.Errorf("failed to fetch server info: %w", err)
 	}
+	if server.PrimaryIP() == "" {
+		// Stopped servers without a static IP have no public address; keep the recorded one
+		return fmt.Errorf("server %s has no public IP (status: %s)", serverID, server.Status)
+	}
 
 	// Update provider config with recovered IP and server ID
-	if err := updateProviderConfigWithIP(target, providerName, server.PublicIPv4, serverID); err != nil {
+	if err := updateProviderConfigWithIP(target, providerName, server.PrimaryIP(), serverID); err != nil {
 		return fmt.Errorf("failed to update provider config: %w", err)
 	}
 
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render(fmt.Sprintf("Recovered IP: %s", server.PublicIPv4)))
+	successStyle := style.Success
+	mutedStyle := style.Muted
+	fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(fmt.Sprintf("Recovered IP: %s", server.PrimaryIP())))
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -60,6 +63,16 @@ func RecoverIPFromProvider(target *config.TargetConfig, targetName, providerName
 
 // updateProviderConfigWithIP updates the provider-specific config with IP and server ID
 func updateProviderConfigWithIP(target *config.TargetConfig, providerName, ip, serverID string) error {
+	if providers.IsExternal(providerName) {
+		externalConfig, err := target.GetExternalConfig()
+		if err != nil {
+			return err
+		}
+		externalConfig.IP = ip
+		externalConfig.ServerID = serverID
+		return target.SetProviderConfig(providerName, externalConfig)
+	}
+
 	switch providerName {
 	case "digitalocean":
 		doConfig, err := target.GetDigitalOceanConfig()
@@ -138,17 +151,17 @@ func RecoverIPFromFlyio(target *config.TargetConfig, targetName, machineID strin
 		return fmt.Errorf("failed to get fly.io client")
 	}
 
-	mutedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-	warningStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
-	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("82"))
+	mutedStyle := style.Muted
+	warningStyle := style.WarningText
+	successStyle := style.Success
 
 	ipAddress, err := flyioClient.GetAppIP(context.Background(), flyioConfig.AppName)
 	if err != nil {
 		fmt.Println()
-		fmt.Printf("%s %s\n", warningStyle.Render("⚠"), warningStyle.Render("Unable to fetch IP automatically from fly.io API"))
+		fmt.Printf("%s %s\n", warningStyle.Render(style.Warn()), warningStyle.Render("Unable to fetch IP automatically from fly.io API"))
 		fmt.Printf("  %s\n", mutedStyle.Render("This is a known fly.io API propagation issue."))
 		fmt.Println()
-		linkStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("33")).Underline(true)
+		linkStyle := style.Fg("33").Underline(true)
 		fmt.Printf("  Please get your app's IP address from:\n")
 		fmt.Printf("  %s\n", linkStyle.Render(fmt.Sprintf("https://fly.io/apps/%s", flyioConfig.AppName)))
 		fmt.Println()
@@ -173,7 +186,7 @@ func RecoverIPFromFlyio(target *config.TargetConfig, targetName, machineID strin
 		}
 
 		ipAddress = userIP
-		fmt.Printf("  %s\n", successStyle.Render(fmt.Sprintf("✓ Using IP: %s", ipAddress)))
+		fmt.Printf("  %s\n", successStyle.Render(fmt.Sprintf("%s Using IP: %s", style.Check(), ipAddress)))
 		fmt.Println()
 	}
 
@@ -181,7 +194,7 @@ func RecoverIPFromFlyio(target *config.TargetConfig, targetName, machineID strin
 	flyioConfig.MachineID = machineID
 	target.SetProviderConfig("flyio", flyioConfig)
 
-	fmt.Printf("%s %s\n", successStyle.Render("✓"), mutedStyle.Render(fmt.Sprintf("Recovered IP: %s", ipAddress)))
+	fmt.Printf("%s %s\n", successStyle.Render(style.Check()), mutedStyle.Render(fmt.Sprintf("Recovered IP: %s", ipAddress)))
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector"
+	"lightfold/pkg/providers"
 	installers "lightfold/pkg/runtime/installers"
 	sshpkg "lightfold/pkg/ssh"
 	"lightfold/pkg/state"
@@ -77,6 +78,7 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 				SSHKey:      sshKey,
 				Username:    "deploy",
 				Provisioned: false, // Not provisioned by this target
+				Adopted:     serverState.Adopted,
 			}
 			target.SetProviderConfig("digitalocean", doConfig)
 		case "hetzner":
@@ -86,6 +88,7 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 				SSHKey:      sshKey,
 				Username:    "deploy",
 				Provisioned: false,
+				Adopted:     serverState.Adopted,
 			}
 			target.SetProviderConfig("hetzner", hetznerConfig)
 		case "vultr":
@@ -95,6 +98,7 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 				SSHKey:      sshKey,
 				Username:    "deploy",
 				Provisioned: false,
+				Adopted:     serverState.Adopted,
 			}
 			target.SetProviderConfig("vultr", vultrConfig)
 		case "linode":
@@ -104,6 +108,7 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 				SSHKey:      sshKey,
 				Username:    "deploy",
 				Provisioned: false,
+				Adopted:     serverState.Adopted,
 			}
 			target.SetProviderConfig("linode", linodeConfig)
 		case "byos":
@@ -116,7 +121,18 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 			}
 			target.SetProviderConfig("byos", doConfig)
 		default:
-			return fmt.Errorf("unsupported provider: %s", serverState.Provider)
+			if !providers.IsExternal(serverState.Provider) {
+				return fmt.Errorf("unsupported provider: %s", serverState.Provider)
+			}
+			externalConfig := &config.ExternalConfig{
+				IP:          serverIP,
+				ServerID:    serverState.ServerID,
+				SSHKey:      sshKey,
+				Username:    "deploy",
+				Provisioned: false,
+				Adopted:     serverState.Adopted,
+			}
+			target.SetProviderConfig(serverState.Provider, externalConfig)
 		}
 	}
 
@@ -132,13 +148,98 @@ func SetupTargetWithExistingServer(target *config.TargetConfig, serverIP string,
 	return nil
 }
 
-// CheckIfRuntimeNeeded determines if a runtime needs to be installed for the current app
-// Returns true if the runtime is missing and needs installation
-func CheckIfRuntimeNeeded(sshExecutor *sshpkg.Executor, projectPath string) bool {
-	detection := detector.DetectFramework(projectPath)
+// SetupTargetWithAdoptedServer configures a target to deploy to a server that was
+// created outside lightfold. The server is recorded as adopted, not provisioned,
+// so destroy leaves it running unless explicitly asked to delete it.
+func SetupTargetWithAdoptedServer(target *config.TargetConfig, providerName string, server *providers.Server, sshKey, sshKeyName, username string) error {
+	serverIP := server.PrimaryIP()
+	if serverIP == "" {
+		return fmt.Errorf("server %s has no public IP address", server.ID)
+	}
+	if username == "" {
+		username = "root"
+	}
+
+	var providerCfg interface{}
+	switch providerName {
+	case "digitalocean":
+		providerCfg = &config.DigitalOceanConfig{
+			DropletID:  server.ID,
+			IP:         serverIP,
+			IPv6:       server.PublicIPv6,
+			SSHKey:     sshKey,
+			SSHKeyName: sshKeyName,
+			Username:   username,
+			Region:     server.Region,
+			Size:       server.Size,
+			Adopted:    true,
+		}
+	case "hetzner":
+		providerCfg = &config.HetznerConfig{
+			ServerID:   server.ID,
+			IP:         serverIP,
+			IPv6:       server.PublicIPv6,
+			SSHKey:     sshKey,
+			SSHKeyName: sshKeyName,
+			Username:   username,
+			Location:   server.Region,
+			ServerType: server.Size,
+			Arch:       server.Architecture,
+			Adopted:    true,
+		}
+	case "vultr":
+		providerCfg = &config.VultrConfig{
+			InstanceID: server.ID,
+			IP:         serverIP,
+			IPv6:       server.PublicIPv6,
+			SSHKey:     sshKey,
+			SSHKeyName: sshKeyName,
+			Username:   username,
+			Region:     server.Region,
+			Plan:       server.Size,
+			Adopted:    true,
+		}
+	case "linode":
+		providerCfg = &config.LinodeConfig{
+			InstanceID: server.ID,
+			IP:         serverIP,
+			IPv6:       server.PublicIPv6,
+			SSHKey:     sshKey,
+			SSHKeyName: sshKeyName,
+			Username:   username,
+			Region:     server.Region,
+			Plan:       server.Size,
+			Adopted:    true,
+		}
+	default:
+		if !providers.IsExternal(providerName) {
+			return fmt.Errorf("adopting servers is not supported for provider: %s", providerName)
+		}
+		providerCfg = &config.ExternalConfig{
+			ServerID:   server.ID,
+			IP:         serverIP,
+			SSHKey:     sshKey,
+			SSHKeyName: sshKeyName,
+			Username:   username,
+			Region:     server.Region,
+			Size:       server.Size,
+			Adopted:    true,
+		}
+	}
+
+	target.Provider = providerName
+	target.ServerIP = serverIP
+	return target.SetProviderConfig(providerName, providerCfg)
+}
+
+// CheckIfRuntimeNeeded determines if a runtime needs to be installed for the current app,
+// detected as framework when set. Returns true if the runtime is missing and needs installation
+func CheckIfRuntimeNeeded(sshExecutor *sshpkg.Executor, projectPath, framework string) bool {
+	detection := detector.DetectFrameworkAs(projectPath, framework)
 
 	ctx := &installers.Context{
 		SSH:       sshExecutor,
+		Server:    sshExecutor.Host,
 		Detection: &detection,
 	}
 
@@ -176,6 +277,10 @@ func UpdateServerStateFromTarget(target *config.TargetConfig, targetName string)
 	if serverState.Provider == "" {
 		serverState.Provider = target.Provider
 		serverState.ServerID = providerCfg.GetServerID()
+		serverState.Adopted = providerCfg.IsAdopted()
+		if providerCfg.IsProvisioned() {
+			serverState.Labels = target.Labels // Applied at provisioning
+		}
 
 		// Determine proxy type from domain config or default
 		if target.Domain != nil && target.Domain.ProxyType != "" {
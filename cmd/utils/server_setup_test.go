@@ -0,0 +1,51 @@
+package utils_test
+
+import (
+	"testing"
+
+	"lightfold/cmd/utils"
+	"lightfold/pkg/config"
+	"lightfold/pkg/providers"
+)
+
+func TestSetupTargetWithAdoptedServer(t *testing.T) {
+	target := config.TargetConfig{Framework: "Django"}
+	server := &providers.Server{
+		ID:         "12345",
+		Name:       "legacy-web",
+		PublicIPv4: "203.0.113.10",
+		Region:     "fsn1",
+		Size:       "cx22",
+	}
+
+	if err := utils.SetupTargetWithAdoptedServer(&target, "hetzner", server, "/keys/id_ed25519", "id_ed25519", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if target.Provider != "hetzner" || target.ServerIP != "203.0.113.10" {
+		t.Fatalf("expected hetzner target on 203.0.113.10, got %s on %s", target.Provider, target.ServerIP)
+	}
+
+	hetznerCfg, err := target.GetHetznerConfig()
+	if err != nil {
+		t.Fatalf("failed to read hetzner config: %v", err)
+	}
+	if !hetznerCfg.IsAdopted() || hetznerCfg.IsProvisioned() {
+		t.Errorf("expected adopted, non-provisioned config, got adopted=%v provisioned=%v", hetznerCfg.Adopted, hetznerCfg.Provisioned)
+	}
+	if hetznerCfg.ServerID != "12345" || hetznerCfg.Location != "fsn1" || hetznerCfg.ServerType != "cx22" {
+		t.Errorf("server metadata not recorded: %+v", hetznerCfg)
+	}
+	if hetznerCfg.Username != "root" {
+		t.Errorf("expected default username root, got %s", hetznerCfg.Username)
+	}
+}
+
+func TestSetupTargetWithAdoptedServer_RequiresPublicIP(t *testing.T) {
+	target := config.TargetConfig{}
+	server := &providers.Server{ID: "42"}
+
+	if err := utils.SetupTargetWithAdoptedServer(&target, "digitalocean", server, "/keys/id", "id", "root"); err == nil {
+		t.Fatal("expected error for server without public IP")
+	}
+}
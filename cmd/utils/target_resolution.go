@@ -3,8 +3,10 @@ package utils
 import (
 	"fmt"
 	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
 	"lightfold/pkg/util"
 	"os"
+	"path/filepath"
 )
 
 // LoadConfigOrExit loads the config or exits on error
@@ -12,7 +14,7 @@ func LoadConfigOrExit() *config.Config {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-		os.Exit(1)
+		crash.Exit(1)
 	}
 	return cfg
 }
@@ -23,7 +25,7 @@ func LoadTargetOrExit(cfg *config.Config, targetName string) config.TargetConfig
 	if !exists {
 		fmt.Fprintf(os.Stderr, "Error: Target '%s' not found\n", targetName)
 		fmt.Fprintf(os.Stderr, "\nRun 'lightfold status' to list all configured targets\n")
-		os.Exit(1)
+		crash.Exit(1)
 	}
 	return target
 }
@@ -66,7 +68,29 @@ func ResolveTargetOrExit(cfg *config.Config, targetFlag string, pathArg string)
 	target, targetName, err := ResolveTarget(cfg, targetFlag, pathArg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		crash.Exit(1)
 	}
 	return target, targetName
 }
+
+// CheckTargetNameCollision returns an error if the target name is already used by a
+// different project directory (e.g. two folders both named "api")
+func CheckTargetNameCollision(cfg *config.Config, targetName, projectPath string) error {
+	existing, exists := cfg.GetTarget(targetName)
+	if !exists || existing.ProjectPath == "" {
+		return nil
+	}
+
+	absPath, err := filepath.Abs(projectPath)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Clean(existing.ProjectPath) == filepath.Clean(absPath) {
+		return nil
+	}
+
+	suggestion := util.SanitizeHostname(targetName + "-" + filepath.Base(filepath.Dir(absPath)))
+	return fmt.Errorf("target '%s' already exists for %s\nUse a distinct name for this project, e.g. --target %s",
+		targetName, existing.ProjectPath, suggestion)
+}
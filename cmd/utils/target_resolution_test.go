@@ -3,10 +3,12 @@ package utils_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"lightfold/cmd/utils"
 	"lightfold/pkg/config"
+	"lightfold/pkg/state"
 	"lightfold/pkg/util"
 )
 
@@ -140,3 +142,77 @@ func TestResolveTarget_FallsBackToSanitizedName(t *testing.T) {
 		t.Fatalf("expected framework to remain Next.js, got %s", target.Framework)
 	}
 }
+
+func TestCheckTargetNameCollision(t *testing.T) {
+	root := t.TempDir()
+	firstAPI := filepath.Join(root, "platform", "api")
+	secondAPI := filepath.Join(root, "billing", "api")
+	for _, dir := range []string{firstAPI, secondAPI} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create project dir: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		Targets: map[string]config.TargetConfig{
+			"api": {ProjectPath: firstAPI, Provider: "byos"},
+		},
+	}
+
+	if err := utils.CheckTargetNameCollision(cfg, "api", firstAPI); err != nil {
+		t.Fatalf("expected no collision for the same project, got %v", err)
+	}
+
+	err := utils.CheckTargetNameCollision(cfg, "api", secondAPI)
+	if err == nil {
+		t.Fatal("expected collision for same name with different project path")
+	}
+	if !strings.Contains(err.Error(), "--target api-billing") {
+		t.Fatalf("expected disambiguation suggestion, got %v", err)
+	}
+
+	if err := utils.CheckTargetNameCollision(cfg, "api-billing", secondAPI); err != nil {
+		t.Fatalf("expected distinct target name to be accepted, got %v", err)
+	}
+}
+
+func TestCheckServerAppCollision(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	serverState := &state.ServerState{
+		ServerIP: "192.168.1.10",
+		DeployedApps: []state.DeployedApp{
+			{TargetName: "api", AppName: "api", Port: 3000},
+		},
+	}
+	if err := state.SaveServerState(serverState); err != nil {
+		t.Fatalf("failed to save server state: %v", err)
+	}
+
+	// Same app name from a different target on the same server
+	if err := utils.CheckServerAppCollision("192.168.1.10", "api-billing", "api"); err == nil {
+		t.Fatal("expected collision for same app name on one server")
+	}
+
+	// Redeploying the owning target is fine
+	if err := utils.CheckServerAppCollision("192.168.1.10", "api", "api"); err != nil {
+		t.Fatalf("expected no collision for owning target, got %v", err)
+	}
+
+	// Same app name on a different server is fine
+	if err := utils.CheckServerAppCollision("192.168.1.20", "api-billing", "api"); err != nil {
+		t.Fatalf("expected no collision across servers, got %v", err)
+	}
+
+	// A second project directory named api deploys under its target name
+	target := &config.TargetConfig{ProjectPath: "/work/billing/api", ServerIP: "192.168.1.10"}
+	if err := utils.RegisterAppWithServer(target, "api-billing", 3001, "Django"); err != nil {
+		t.Fatalf("expected the target name to keep the apps apart, got %v", err)
+	}
+
+	// A target pinned to another target's app name is still refused
+	pinned := &config.TargetConfig{ProjectPath: "/work/other/api", ServerIP: "192.168.1.10", AppName: "api"}
+	if err := utils.RegisterAppWithServer(pinned, "api-other", 3002, "Django"); err == nil {
+		t.Fatal("expected RegisterAppWithServer to reject duplicate app name")
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/cmd/ui/style"
+	"lightfold/pkg/selfupdate"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCheckFlag bool
+
+// versionInfo is `version --json` output
+type versionInfo struct {
+	Version         string `json:"version"`
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"update_available"`
+	ReleaseURL      string `json:"release_url,omitempty"`
+	CheckDisabled   bool   `json:"check_disabled,omitempty"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the lightfold version",
+	Long: `Print the lightfold version.
+
+With --check, also look up the latest release on GitHub and say whether an
+update is available. The result is cached for a day in ~/.lightfold and the
+check gives up after 2 seconds. Set ` + selfupdate.DisableEnv + ` to skip it on
+machines without access to GitHub.
+
+Examples:
+  lightfold version
+  lightfold version --check
+  lightfold version --check --json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		info := versionInfo{Version: Version}
+		var checkErr error
+		if versionCheckFlag {
+			if selfupdate.Disabled() {
+				info.CheckDisabled = true
+			} else if release, err := selfupdate.NewChecker().Latest(context.Background(), true); err != nil {
+				checkErr = err
+			} else {
+				info.Latest = release.Version()
+				info.UpdateAvailable = release.NewerThan(Version)
+				info.ReleaseURL = release.URL
+			}
+		}
+
+		if jsonOutput {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(info)
+		} else {
+			printVersionInfo(info)
+		}
+
+		if checkErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", checkErr)
+			exit(1)
+		}
+	},
+}
+
+func printVersionInfo(info versionInfo) {
+	fmt.Printf("lightfold version %s\n", info.Version)
+	if !versionCheckFlag {
+		return
+	}
+
+	mutedStyle := style.Muted
+	switch {
+	case info.CheckDisabled:
+		fmt.Printf("%s\n", mutedStyle.Render(fmt.Sprintf("Update check skipped (%s is set)", selfupdate.DisableEnv)))
+	case info.UpdateAvailable:
+		updateStyle := style.Warning
+		fmt.Printf("%s\n", updateStyle.Render(fmt.Sprintf("Update available: %s", info.Latest)))
+		fmt.Printf("%s\n", mutedStyle.Render(fmt.Sprintf("Run 'lightfold self-update' to install it (%s)", info.ReleaseURL)))
+	case info.Latest != "":
+		fmt.Printf("%s\n", mutedStyle.Render(fmt.Sprintf("Up to date (latest release is %s)", info.Latest)))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&versionCheckFlag, "check", false, "Check GitHub for a newer release")
+}
@@ -20,6 +20,10 @@ type Builder interface {
 	// NeedsNginx returns true if nginx reverse proxy setup is required
 	// Returns false if the builder's output already includes a web server
 	NeedsNginx() bool
+
+	// Version returns the version of the tool the builder builds with (e.g.
+	// "1.29.1" for nixpacks), or "" when it cannot be determined
+	Version(ctx context.Context, opts *BuildOptions) (string, error)
 }
 
 // BuildOptions contains all parameters needed for a build operation
@@ -29,6 +33,21 @@ type BuildOptions struct {
 	ReleasePath string              // Remote release directory path
 	EnvVars     map[string]string   // Environment variables for build
 	SSHExecutor *sshpkg.Executor    // SSH connection to remote server
+	// RequiredVersion pins the build tool version; builds fail when another
+	// version is installed (nixpacks only)
+	RequiredVersion string
+	AutoInstall     bool // Install RequiredVersion when it is missing
+	Port            int  // Host port the app is served on
+	// BindAddress is the host address the app's port is published on
+	// (dockerfile only); "" or 0.0.0.0 publishes it on every interface
+	BindAddress string
+	// ContainerPort overrides the port the app listens on inside its
+	// container (dockerfile only; otherwise read from the Dockerfile)
+	ContainerPort int
+	// BuildArgs are docker build --build-arg values, or nixpacks --env values
+	// that are also exported to the install and build commands
+	BuildArgs   map[string]string
+	BuildTarget string // Dockerfile stage to build (dockerfile only)
 }
 
 // BuildResult contains the output of a build operation
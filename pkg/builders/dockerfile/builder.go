@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"lightfold/pkg/builders"
 	"lightfold/pkg/config"
+	"lightfold/pkg/util"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -44,6 +47,15 @@ func (d *DockerfileBuilder) NeedsNginx() bool {
 	return false
 }
 
+// Version returns the local Docker client version that builds the image
+func (d *DockerfileBuilder) Version(ctx context.Context, opts *builders.BuildOptions) (string, error) {
+	output, err := exec.CommandContext(ctx, "docker", "version", "--format", "{{.Client.Version}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get docker version: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func (d *DockerfileBuilder) Build(ctx context.Context, opts *builders.BuildOptions) (*builders.BuildResult, error) {
 	dockerfilePath := filepath.Join(opts.ProjectPath, "Dockerfile")
 	if _, err := os.Stat(dockerfilePath); os.IsNotExist(err) {
@@ -59,13 +71,30 @@ func (d *DockerfileBuilder) Build(ctx context.Context, opts *builders.BuildOptio
 		}, fmt.Errorf("failed to extract app name from release path: %s", opts.ReleasePath)
 	}
 
+	ports, err := ReadPorts(opts.ProjectPath)
+	if err != nil {
+		return &builders.BuildResult{
+			Success: false,
+		}, fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+	containerPort, err := ContainerPort(ports, opts.ContainerPort, opts.EnvVars)
+	if err != nil {
+		return &builders.BuildResult{
+			Success: false,
+		}, err
+	}
+	hostPort := opts.Port
+	if hostPort == 0 {
+		hostPort = config.DefaultApplicationPort
+	}
+
 	imageName := fmt.Sprintf("lightfold-%s:latest", appName)
 	tarballPath := filepath.Join(os.TempDir(), fmt.Sprintf("lightfold-%s-image.tar", appName))
 
 	var buildLog strings.Builder
 
 	buildLog.WriteString(fmt.Sprintf("Building Docker image: %s\n", imageName))
-	buildCmd := exec.CommandContext(ctx, "docker", "build", "-t", imageName, opts.ProjectPath)
+	buildCmd := exec.CommandContext(ctx, "docker", dockerBuildArgs(imageName, opts)...)
 	buildCmd.Dir = opts.ProjectPath
 
 	buildOutput, err := buildCmd.CombinedOutput()
@@ -102,8 +131,8 @@ func (d *DockerfileBuilder) Build(ctx context.Context, opts *builders.BuildOptio
 
 		installCmds := []string{
 			"curl -fsSL https://get.docker.com -o /tmp/get-docker.sh",
-			"sudo sh /tmp/get-docker.sh",
-			"sudo usermod -aG docker deploy",
+			"sh /tmp/get-docker.sh",
+			"usermod -aG docker deploy",
 			"rm /tmp/get-docker.sh",
 		}
 
@@ -149,13 +178,8 @@ func (d *DockerfileBuilder) Build(ctx context.Context, opts *builders.BuildOptio
 	ssh.Execute(fmt.Sprintf("rm %s", remoteTarballPath))
 
 	if len(opts.EnvVars) > 0 {
-		var envContent strings.Builder
-		for key, value := range opts.EnvVars {
-			envContent.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-		}
-
 		envPath := fmt.Sprintf("%s/.env", opts.ReleasePath)
-		if err := ssh.WriteRemoteFile(envPath, envContent.String(), config.PermEnvFile); err != nil {
+		if err := ssh.WriteRemoteFileAtomic(envPath, util.FormatDotenv(opts.EnvVars), config.PermEnvFile); err != nil {
 			return &builders.BuildResult{
 				Success:  false,
 				BuildLog: buildLog.String(),
@@ -166,30 +190,8 @@ func (d *DockerfileBuilder) Build(ctx context.Context, opts *builders.BuildOptio
 		buildLog.WriteString(fmt.Sprintf("\nWrote environment variables to %s\n", envPath))
 	}
 
-	// We'll create a simple run script that can be used by systemd
-	port := extractPortFromEnv(opts.EnvVars)
-	if port == "" {
-		port = fmt.Sprintf("%d", config.DefaultApplicationPort)
-	}
-
-	runScript := fmt.Sprintf(`#!/bin/bash
-# Lightfold Docker container run script
-CONTAINER_NAME="lightfold-%s"
-IMAGE_NAME="%s"
-RELEASE_PATH="%s"
-
-# Stop and remove existing container if running
-docker stop $CONTAINER_NAME 2>/dev/null || true
-docker rm $CONTAINER_NAME 2>/dev/null || true
-
-# Run new container
-docker run -d \
-  --name $CONTAINER_NAME \
-  --restart unless-stopped \
-  -p %s:3000 \
-  --env-file $RELEASE_PATH/.env \
-  $IMAGE_NAME
-`, appName, imageName, opts.ReleasePath, port)
+	buildLog.WriteString(fmt.Sprintf("\nPublishing container port %d on host port %d\n", containerPort, hostPort))
+	runScript := dockerRunScript(appName, imageName, opts.ReleasePath, opts.BindAddress, hostPort, containerPort, opts.EnvVars)
 
 	runScriptPath := fmt.Sprintf("%s/docker-run.sh", opts.ReleasePath)
 	if err := ssh.WriteRemoteFile(runScriptPath, runScript, 0755); err != nil {
@@ -212,15 +214,68 @@ docker run -d \
 	}, nil
 }
 
-// extractAppName extracts the app name from the release path
+// dockerBuildArgs returns the docker build arguments for the image, with
+// build args in key order and the stage to build when one is set
+func dockerBuildArgs(imageName string, opts *builders.BuildOptions) []string {
+	args := []string{"build", "-t", imageName}
+	keys := make([]string, 0, len(opts.BuildArgs))
+	for key := range opts.BuildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--build-arg", key+"="+opts.BuildArgs[key])
+	}
+	if opts.BuildTarget != "" {
+		args = append(args, "--target", opts.BuildTarget)
+	}
+	return append(args, opts.ProjectPath)
+}
+
+// dockerRunScript renders the script systemd starts the container with. The
+// container port is published on the host port nginx and the health check
+// use, on bindAddress only unless it is every interface: docker's own
+// iptables rules bypass ufw. Apps that read PORT get the container port
+// unless the environment sets PORT itself.
+func dockerRunScript(appName, imageName, releasePath, bindAddress string, hostPort, containerPort int, envVars map[string]string) string {
+	publish := fmt.Sprintf("%d:%d", hostPort, containerPort)
+	if bindAddress != "" && bindAddress != "0.0.0.0" {
+		publish = bindAddress + ":" + publish
+	}
+	portEnv := ""
+	if _, ok := envVars["PORT"]; !ok {
+		portEnv = fmt.Sprintf("  -e PORT=%d \\\n", containerPort)
+	}
+
+	return fmt.Sprintf(`#!/bin/bash
+# Lightfold Docker container run script
+CONTAINER_NAME="lightfold-%s"
+IMAGE_NAME="%s"
+RELEASE_PATH="%s"
+
+# Stop and remove existing container if running
+docker stop $CONTAINER_NAME 2>/dev/null || true
+docker rm $CONTAINER_NAME 2>/dev/null || true
+
+# Run new container
+docker run -d \
+  --name $CONTAINER_NAME \
+  --restart unless-stopped \
+  -p %s \
+  --env-file $RELEASE_PATH/.env \
+%s  $IMAGE_NAME
+`, appName, imageName, releasePath, publish, portEnv)
+}
+
+// extractAppName extracts the app name from the release path, whatever the
+// base directory
 // Example: /srv/myapp/releases/20240101120000 -> myapp
 func extractAppName(releasePath string) string {
-	parts := strings.Split(releasePath, "/")
-	if len(parts) >= 3 {
-		// Path format: /srv/{appname}/releases/{timestamp}
-		return parts[2]
+	i := strings.LastIndex(releasePath, "/releases/")
+	if i <= 0 {
+		return ""
 	}
-	return ""
+	return path.Base(releasePath[:i])
 }
 
 // extractPortFromEnv tries to find PORT env var, defaults to ""
@@ -2,6 +2,7 @@ package dockerfile
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"lightfold/pkg/builders"
@@ -82,3 +83,81 @@ func TestDockerfileBuilder_Build_DockerfileExists(t *testing.T) {
 	// Note: Full integration testing is covered by end-to-end tests
 	// Unit test just verifies Dockerfile existence checking in TestDockerfileBuilder_Build_MissingDockerfile
 }
+
+func TestDockerRunScript_PublishesContainerPort(t *testing.T) {
+	script := dockerRunScript("myapp", "lightfold-myapp:latest", "/srv/myapp/releases/20240101120000", "127.0.0.1", 3001, 8080, nil)
+
+	for _, want := range []string{
+		`CONTAINER_NAME="lightfold-myapp"`,
+		"  -p 127.0.0.1:3001:8080 \\\n",
+		"  -e PORT=8080 \\\n",
+		"  --env-file $RELEASE_PATH/.env \\\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("run script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestDockerRunScript_KeepsPortFromEnv(t *testing.T) {
+	script := dockerRunScript("myapp", "lightfold-myapp:latest", "/srv/myapp/releases/1", "127.0.0.1", 3001, 8080, map[string]string{"PORT": "8080"})
+
+	if strings.Contains(script, "-e PORT=") {
+		t.Errorf("run script should leave PORT to the env file:\n%s", script)
+	}
+	if !strings.Contains(script, "-p 127.0.0.1:3001:8080") {
+		t.Errorf("run script missing port mapping:\n%s", script)
+	}
+}
+
+func TestDockerRunScript_BindAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		bindAddress string
+		want        string
+	}{
+		{"behind nginx", "127.0.0.1", "  -p 127.0.0.1:3001:8080 \\\n"},
+		{"exposed port", "0.0.0.0", "  -p 3001:8080 \\\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := dockerRunScript("myapp", "lightfold-myapp:latest", "/srv/myapp/releases/1", tt.bindAddress, 3001, 8080, nil)
+			if !strings.Contains(script, tt.want) {
+				t.Errorf("run script missing %q:\n%s", tt.want, script)
+			}
+		})
+	}
+}
+
+func TestDockerBuildArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		buildArgs   map[string]string
+		buildTarget string
+		want        string
+	}{
+		{"plain", nil, "", "build -t lightfold-myapp:latest /project"},
+		{"args in key order", map[string]string{"NODE_ENV": "production", "COMMIT_SHA": "abc123"}, "", "build -t lightfold-myapp:latest --build-arg COMMIT_SHA=abc123 --build-arg NODE_ENV=production /project"},
+		{"target", nil, "runtime", "build -t lightfold-myapp:latest --target runtime /project"},
+		{"args and target", map[string]string{"COMMIT_SHA": "abc123"}, "runtime", "build -t lightfold-myapp:latest --build-arg COMMIT_SHA=abc123 --target runtime /project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := dockerBuildArgs("lightfold-myapp:latest", &builders.BuildOptions{
+				ProjectPath: "/project",
+				BuildArgs:   tt.buildArgs,
+				BuildTarget: tt.buildTarget,
+			})
+			if got := strings.Join(args, " "); got != tt.want {
+				t.Errorf("dockerBuildArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A value with spaces stays one argument
+	args := dockerBuildArgs("img", &builders.BuildOptions{ProjectPath: "/project", BuildArgs: map[string]string{"OPTS": "--a=1 --b"}})
+	if args[4] != "OPTS=--a=1 --b" {
+		t.Errorf("build arg = %q, want it as a single argument", args[4])
+	}
+}
@@ -0,0 +1,167 @@
+package dockerfile
+
+import (
+	"bufio"
+	"fmt"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PortLabel is the Dockerfile label naming the port the app listens on, for
+// images that expose several ports or none:
+//
+//	LABEL lightfold.port=8080
+const PortLabel = "lightfold.port"
+
+// Ports are the ports a Dockerfile declares
+type Ports struct {
+	Exposed []int // EXPOSE ports in order, without duplicates
+	Label   int   // PortLabel, 0 when unset
+}
+
+// ParsePorts reads the EXPOSE instructions and the PortLabel label of a
+// Dockerfile. Ports given as build arguments or ranges cannot be resolved
+// locally and are skipped, as are UDP ports.
+func ParsePorts(content string) Ports {
+	var ports Ports
+	seen := map[int]bool{}
+
+	for _, line := range dockerfileInstructions(content) {
+		instruction, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(instruction) {
+		case "EXPOSE":
+			for _, field := range strings.Fields(args) {
+				portSpec, proto, _ := strings.Cut(field, "/")
+				if proto != "" && !strings.EqualFold(proto, "tcp") {
+					continue
+				}
+				port, err := strconv.Atoi(portSpec)
+				if err != nil || port < 1 || port > 65535 || seen[port] {
+					continue
+				}
+				seen[port] = true
+				ports.Exposed = append(ports.Exposed, port)
+			}
+		case "LABEL":
+			for _, pair := range labelPairs(args) {
+				key, value, _ := strings.Cut(pair, "=")
+				if strings.Trim(key, `"'`) != PortLabel {
+					continue
+				}
+				if port, err := strconv.Atoi(strings.Trim(value, `"'`)); err == nil && port > 0 && port <= 65535 {
+					ports.Label = port
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// ReadPorts parses the Dockerfile at the root of the project
+func ReadPorts(projectPath string) (Ports, error) {
+	content, err := os.ReadFile(filepath.Join(projectPath, "Dockerfile"))
+	if err != nil {
+		return Ports{}, err
+	}
+	return ParsePorts(string(content)), nil
+}
+
+// dockerfileInstructions joins continuation lines and drops comments and blank lines
+func dockerfileInstructions(content string) []string {
+	var instructions []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		if instruction := strings.TrimSpace(current.String()); instruction != "" {
+			instructions = append(instructions, instruction)
+		}
+		current.Reset()
+	}
+	if instruction := strings.TrimSpace(current.String()); instruction != "" {
+		instructions = append(instructions, instruction)
+	}
+	return instructions
+}
+
+// labelPairs splits LABEL arguments into key=value pairs, keeping quoted
+// values with spaces together
+func labelPairs(args string) []string {
+	var pairs []string
+	var current strings.Builder
+	var quote rune
+
+	for _, r := range args {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				pairs = append(pairs, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		pairs = append(pairs, current.String())
+	}
+	return pairs
+}
+
+// MultiplePortsError reports a Dockerfile exposing several ports without
+// saying which one the app serves on
+type MultiplePortsError struct {
+	Ports []int
+}
+
+func (e *MultiplePortsError) Error() string {
+	ports := make([]string, len(e.Ports))
+	for i, port := range e.Ports {
+		ports[i] = strconv.Itoa(port)
+	}
+	return fmt.Sprintf("the Dockerfile exposes ports %s; choose the one the app serves on with --container-port or 'LABEL %s=<port>'",
+		strings.Join(ports, ", "), PortLabel)
+}
+
+// ContainerPort returns the port the app listens on inside the container:
+// the configured override, then the PortLabel label, then the only EXPOSE
+// port. Without either it falls back to PORT from the app's environment and
+// then the default application port.
+func ContainerPort(ports Ports, override int, envVars map[string]string) (int, error) {
+	switch {
+	case override > 0:
+		return override, nil
+	case ports.Label > 0:
+		return ports.Label, nil
+	case len(ports.Exposed) == 1:
+		return ports.Exposed[0], nil
+	case len(ports.Exposed) > 1:
+		return 0, &MultiplePortsError{Ports: ports.Exposed}
+	}
+
+	if port, err := strconv.Atoi(extractPortFromEnv(envVars)); err == nil && port > 0 {
+		return port, nil
+	}
+	return config.DefaultApplicationPort, nil
+}
@@ -0,0 +1,97 @@
+package dockerfile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       Ports
+	}{
+		{
+			name:       "single expose",
+			dockerfile: "FROM golang:1.22\nEXPOSE 8080\nCMD [\"./app\"]\n",
+			want:       Ports{Exposed: []int{8080}},
+		},
+		{
+			name:       "protocols, duplicates and lowercase",
+			dockerfile: "FROM node:20\nexpose 3000/tcp 9229\nEXPOSE 3000 53/udp\n",
+			want:       Ports{Exposed: []int{3000, 9229}},
+		},
+		{
+			name:       "build arguments are skipped",
+			dockerfile: "FROM node:20\nARG PORT=4000\nEXPOSE ${PORT}\n",
+			want:       Ports{},
+		},
+		{
+			name:       "continuation lines and comments",
+			dockerfile: "FROM nginx\n# EXPOSE 9999\nEXPOSE 80 \\\n  443\n",
+			want:       Ports{Exposed: []int{80, 443}},
+		},
+		{
+			name:       "port label",
+			dockerfile: "FROM python:3.12\nLABEL maintainer=\"Jane Doe\" lightfold.port=\"8000\"\nEXPOSE 8000 9000\n",
+			want:       Ports{Exposed: []int{8000, 9000}, Label: 8000},
+		},
+		{
+			name:       "quoted label key",
+			dockerfile: "FROM python:3.12\nLABEL \"lightfold.port\"=5000\n",
+			want:       Ports{Label: 5000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePorts(tt.dockerfile); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePorts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    Ports
+		override int
+		env      map[string]string
+		want     int
+	}{
+		{"override wins", Ports{Exposed: []int{8080}, Label: 9000}, 7000, nil, 7000},
+		{"label over expose", Ports{Exposed: []int{8080, 9090}, Label: 9090}, 0, nil, 9090},
+		{"single expose", Ports{Exposed: []int{8080}}, 0, map[string]string{"PORT": "5000"}, 8080},
+		{"PORT from env", Ports{}, 0, map[string]string{"PORT": "5000"}, 5000},
+		{"default", Ports{}, 0, nil, 3000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContainerPort(tt.ports, tt.override, tt.env)
+			if err != nil || got != tt.want {
+				t.Errorf("ContainerPort() = %d, %v; want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerPort_MultipleExposed(t *testing.T) {
+	_, err := ContainerPort(Ports{Exposed: []int{8080, 9090}}, 0, nil)
+
+	var multiple *MultiplePortsError
+	if !errors.As(err, &multiple) {
+		t.Fatalf("ContainerPort() error = %v, want MultiplePortsError", err)
+	}
+	if !reflect.DeepEqual(multiple.Ports, []int{8080, 9090}) {
+		t.Errorf("MultiplePortsError.Ports = %v, want [8080 9090]", multiple.Ports)
+	}
+	for _, want := range []string{"8080, 9090", "--container-port", "lightfold.port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
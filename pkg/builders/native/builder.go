@@ -6,6 +6,8 @@ import (
 	"lightfold/pkg/builders"
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector"
+	"lightfold/pkg/runtime"
+	"lightfold/pkg/util"
 	"strings"
 )
 
@@ -31,6 +33,41 @@ func (n *NativeBuilder) NeedsNginx() bool {
 	return true
 }
 
+// runtimeVersionCommands print the version of the runtime a language builds with
+var runtimeVersionCommands = map[string]string{
+	"JavaScript/TypeScript": "node --version",
+	"Python":                "python3 --version",
+	"Go":                    "go version",
+	"Ruby":                  "ruby --version",
+	"PHP":                   "php --version",
+	"Java":                  "java -version 2>&1",
+	"Rust":                  "rustc --version",
+	"Elixir":                "elixir --version",
+	"C#":                    "dotnet --version",
+}
+
+// Version returns the version of the runtime the app builds with on the
+// server, or "" when it cannot be determined
+func (n *NativeBuilder) Version(ctx context.Context, opts *builders.BuildOptions) (string, error) {
+	if opts == nil || opts.SSHExecutor == nil || opts.Detection == nil {
+		return "", nil
+	}
+	cmd, ok := runtimeVersionCommands[opts.Detection.Language]
+	if !ok {
+		return "", nil
+	}
+
+	result := opts.SSHExecutor.Execute(cmd)
+	if result.Error != nil || result.ExitCode != 0 {
+		return "", nil
+	}
+	output := strings.TrimSpace(result.Stdout)
+	if line, _, found := strings.Cut(output, "\n"); found {
+		output = line
+	}
+	return output, nil
+}
+
 func (n *NativeBuilder) Build(ctx context.Context, opts *builders.BuildOptions) (*builders.BuildResult, error) {
 	if opts.Detection == nil {
 		return &builders.BuildResult{Success: true}, nil
@@ -46,22 +83,21 @@ func (n *NativeBuilder) Build(ctx context.Context, opts *builders.BuildOptions)
 	envVars := opts.EnvVars
 
 	if len(envVars) > 0 {
-		var envContent strings.Builder
-		for key, value := range envVars {
-			envContent.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-		}
-
 		envPath := fmt.Sprintf("%s/.env", releasePath)
-		if err := ssh.WriteRemoteFile(envPath, envContent.String(), config.PermEnvFile); err != nil {
+		if err := ssh.WriteRemoteFileAtomic(envPath, util.FormatDotenv(envVars), config.PermEnvFile); err != nil {
 			return nil, fmt.Errorf("failed to write .env for build: %w", err)
 		}
 
 		ssh.ExecuteSudo(fmt.Sprintf("chown deploy:deploy %s", envPath))
 	}
 
+	// Compose images are built by `docker compose up --build` during deploy
+	if opts.Detection.Meta["deployment_type"] == "docker-compose" {
+		return &builders.BuildResult{Success: true}, nil
+	}
+
 	if opts.Detection.Language == "Python" {
-		appName := getAppName(releasePath)
-		venvPath := fmt.Sprintf("%s/%s/shared/venv", config.RemoteAppBaseDir, appName)
+		venvPath := appDir(releasePath) + "/shared/venv"
 		result := ssh.ExecuteSudo(fmt.Sprintf("python3 -m venv %s", venvPath))
 		if result.Error != nil || result.ExitCode != 0 {
 			return nil, fmt.Errorf("failed to create venv: %s", result.Stderr)
@@ -76,9 +112,10 @@ func (n *NativeBuilder) Build(ctx context.Context, opts *builders.BuildOptions)
 			continue
 		}
 
-		buildCmd := adjustBuildCommand(cmd, releasePath, opts.Detection)
 		pathPrefix := getPackageManagerPath(opts.Detection)
-		fullCmd := fmt.Sprintf("cd %s && %s%s", releasePath, pathPrefix, buildCmd)
+		buildCmd := runtime.EnsureToolCommand(ssh.Host, adjustBuildCommand(cmd, releasePath, opts.Detection), pathPrefix)
+		cacheEnv := detector.MonorepoCacheEnvPrefix(opts.Detection, envVars)
+		fullCmd := fmt.Sprintf("cd %s && %s%s%s", releasePath, pathPrefix, cacheEnv, buildCmd)
 
 		result := ssh.Execute(fullCmd)
 		buildLog.WriteString(result.Stdout)
@@ -111,6 +148,10 @@ func (n *NativeBuilder) Build(ctx context.Context, opts *builders.BuildOptions)
 				BuildLog: buildLog.String(),
 			}, fmt.Errorf("build command failed '%s' (exit code %d): %s", cmd, result.ExitCode, errorOutput)
 		}
+
+		if tool := runtime.CommandTool(cmd); tool != "" {
+			runtime.RecordToolInstalled(ssh.Host, tool, true)
+		}
 	}
 
 	return &builders.BuildResult{
@@ -122,20 +163,19 @@ func (n *NativeBuilder) Build(ctx context.Context, opts *builders.BuildOptions)
 
 // Helper functions
 
-func getAppName(releasePath string) string {
-	// Extract app name from /srv/<app>/releases/<timestamp>
-	parts := strings.Split(releasePath, "/")
-	if len(parts) >= 3 {
-		return parts[2]
+// appDir returns the app's directory from its release path:
+// <base>/<app>/releases/<timestamp> -> <base>/<app>
+func appDir(releasePath string) string {
+	if i := strings.LastIndex(releasePath, "/releases/"); i > 0 {
+		return releasePath[:i]
 	}
-	return "app"
+	return config.AppDir("", "app")
 }
 
 func adjustBuildCommand(cmd, releasePath string, detection *detector.Detection) string {
 	// Handle Python venv activation
 	if detection != nil && detection.Language == "Python" {
-		appName := getAppName(releasePath)
-		venvPath := fmt.Sprintf("%s/%s/shared/venv", config.RemoteAppBaseDir, appName)
+		venvPath := appDir(releasePath) + "/shared/venv"
 		if strings.Contains(cmd, "pip install") || strings.Contains(cmd, "poetry install") || strings.Contains(cmd, "uv") {
 			return fmt.Sprintf("source %s/bin/activate && %s", venvPath, cmd)
 		}
@@ -143,24 +183,10 @@ func adjustBuildCommand(cmd, releasePath string, detection *detector.Detection)
 	return cmd
 }
 
+// getPackageManagerPath returns the PATH prefix build commands run behind
 func getPackageManagerPath(detection *detector.Detection) string {
 	if detection == nil {
 		return ""
 	}
-
-	pm, ok := detection.Meta["package_manager"]
-	if !ok {
-		return ""
-	}
-
-	switch pm {
-	case "bun":
-		return "export PATH=$HOME/.bun/bin:$PATH && "
-	case "poetry":
-		return "export PATH=$HOME/.local/bin:$PATH && "
-	case "uv":
-		return "export PATH=$HOME/.cargo/bin:$PATH && "
-	default:
-		return ""
-	}
+	return runtime.BuildPathPrefix(detection.Language, detection.Meta["package_manager"], detection.Meta["runtime_version"])
 }
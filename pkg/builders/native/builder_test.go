@@ -71,7 +71,7 @@ func TestNativeBuilder_Build_NilDetection(t *testing.T) {
 	}
 }
 
-func TestGetAppName(t *testing.T) {
+func TestAppDir(t *testing.T) {
 	tests := []struct {
 		name        string
 		releasePath string
@@ -80,30 +80,35 @@ func TestGetAppName(t *testing.T) {
 		{
 			name:        "standard release path",
 			releasePath: "/srv/myapp/releases/20240101120000",
-			want:        "myapp",
+			want:        "/srv/myapp",
 		},
 		{
 			name:        "nested app path",
 			releasePath: "/srv/my-cool-app/releases/20240101120000",
-			want:        "my-cool-app",
+			want:        "/srv/my-cool-app",
+		},
+		{
+			name:        "custom base directory",
+			releasePath: "/opt/apps/myapp/releases/20240101120000",
+			want:        "/opt/apps/myapp",
 		},
 		{
 			name:        "short path",
 			releasePath: "/srv",
-			want:        "app",
+			want:        "/srv/app",
 		},
 		{
 			name:        "empty path",
 			releasePath: "",
-			want:        "app",
+			want:        "/srv/app",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getAppName(tt.releasePath)
+			got := appDir(tt.releasePath)
 			if got != tt.want {
-				t.Errorf("getAppName(%q) = %q, want %q", tt.releasePath, got, tt.want)
+				t.Errorf("appDir(%q) = %q, want %q", tt.releasePath, got, tt.want)
 			}
 		})
 	}
@@ -176,17 +181,17 @@ func TestGetPackageManagerPath(t *testing.T) {
 		{
 			name:           "bun",
 			packageManager: "bun",
-			want:           "export PATH=$HOME/.bun/bin:$PATH && ",
+			want:           `export PATH="$HOME/.bun/bin:$PATH" && `,
 		},
 		{
 			name:           "poetry",
 			packageManager: "poetry",
-			want:           "export PATH=$HOME/.local/bin:$PATH && ",
+			want:           `export PATH="$HOME/.local/bin:$PATH" && `,
 		},
 		{
 			name:           "uv",
 			packageManager: "uv",
-			want:           "export PATH=$HOME/.cargo/bin:$PATH && ",
+			want:           `export PATH="$HOME/.local/bin:$PATH" && `,
 		},
 		{
 			name:           "npm",
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"lightfold/pkg/builders"
 	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
 	"strings"
 )
 
@@ -18,6 +20,7 @@ import (
 // 3. Determine the start command
 type NixpacksBuilder struct {
 	planData *NixpacksPlan
+	version  string // version of the nixpacks binary the last build used
 }
 
 // NixpacksPlan represents the nixpacks plan.json structure
@@ -58,6 +61,68 @@ func (n *NixpacksBuilder) NeedsNginx() bool {
 	return true
 }
 
+// Version returns the nixpacks version the last build used, or otherwise the
+// version a build would use on the server
+func (n *NixpacksBuilder) Version(ctx context.Context, opts *builders.BuildOptions) (string, error) {
+	if n.version != "" {
+		return n.version, nil
+	}
+	if opts == nil || opts.SSHExecutor == nil {
+		return "", nil
+	}
+	_, version, _ := selectBinary(binaryProbe(opts.SSHExecutor), normalizeVersion(opts.RequiredVersion))
+	return version, nil
+}
+
+// binaryProbe runs `<bin> --version` on the server for selectBinary
+func binaryProbe(ssh *sshpkg.Executor) func(bin string) string {
+	return func(bin string) string {
+		result := ssh.Execute(bin + " --version 2>/dev/null")
+		if result.Error != nil || result.ExitCode != 0 {
+			return ""
+		}
+		return parseVersion(result.Stdout)
+	}
+}
+
+// ensureBinary returns the nixpacks binary to build with, installing nixpacks
+// when it is missing. A pinned version is only installed with AutoInstall;
+// otherwise a different installed version fails the build.
+func (n *NixpacksBuilder) ensureBinary(ssh *sshpkg.Executor, opts *builders.BuildOptions, buildLog *strings.Builder) (string, error) {
+	probe := binaryProbe(ssh)
+	required := normalizeVersion(opts.RequiredVersion)
+
+	bin, version, ok := selectBinary(probe, required)
+	if !ok {
+		var installCmd string
+		switch {
+		case required == "":
+			buildLog.WriteString("==> Installing nixpacks via curl...\n")
+			installCmd = "curl -sSL https://nixpacks.com/install.sh | bash"
+		case opts.AutoInstall:
+			buildLog.WriteString(fmt.Sprintf("==> Installing nixpacks %s into %s...\n", required, ToolsDir))
+			installCmd = InstallCommand(required)
+		default:
+			return "", &VersionMismatchError{Installed: version, Required: required}
+		}
+
+		installResult := ssh.Execute(installCmd)
+		buildLog.WriteString(installResult.Stdout)
+		buildLog.WriteString(installResult.Stderr)
+		if installResult.ExitCode != 0 {
+			return "", fmt.Errorf("failed to install nixpacks: %s", installResult.Stderr)
+		}
+
+		if bin, version, ok = selectBinary(probe, required); !ok {
+			return "", fmt.Errorf("nixpacks was installed but cannot be run on the server")
+		}
+	}
+
+	buildLog.WriteString(fmt.Sprintf("    ✓ nixpacks %s (%s)\n", version, bin))
+	n.version = version
+	return bin, nil
+}
+
 func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions) (*builders.BuildResult, error) {
 	ssh := opts.SSHExecutor
 	var buildLog strings.Builder
@@ -96,26 +161,16 @@ func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions
 	}
 
 	buildLog.WriteString("==> Checking nixpacks installation...\n")
-	checkResult := ssh.Execute("which nixpacks")
-	if checkResult.ExitCode != 0 {
-		buildLog.WriteString("==> Installing nixpacks via curl...\n")
-		installCmd := "curl -sSL https://nixpacks.com/install.sh | bash"
-		installResult := ssh.Execute(installCmd)
-		buildLog.WriteString(installResult.Stdout)
-		buildLog.WriteString(installResult.Stderr)
-
-		if installResult.ExitCode != 0 {
-			return &builders.BuildResult{
-				Success:  false,
-				BuildLog: buildLog.String(),
-			}, fmt.Errorf("failed to install nixpacks: %s", installResult.Stderr)
-		}
-
-		ssh.Execute("export PATH=$HOME/.nixpacks/bin:$PATH")
+	nixpacksBin, err := n.ensureBinary(ssh, opts, &buildLog)
+	if err != nil {
+		return &builders.BuildResult{
+			Success:  false,
+			BuildLog: buildLog.String(),
+		}, err
 	}
 
 	buildLog.WriteString("==> Generating nixpacks build plan...\n")
-	planCmd := fmt.Sprintf("cd %s && $HOME/.nixpacks/bin/nixpacks plan . --format json 2>/dev/null || nixpacks plan . --format json", opts.ReleasePath)
+	planCmd := planCommand(opts.ReleasePath, nixpacksBin, opts.BuildArgs)
 	planResult := ssh.Execute(planCmd)
 	buildLog.WriteString(planResult.Stdout)
 
@@ -171,7 +226,7 @@ func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions
 		}
 
 		for _, cmd := range plan.Phases.Install.Commands {
-			fullCmd := fmt.Sprintf("cd %s && %s", opts.ReleasePath, cmd)
+			fullCmd := phaseCommand(opts.ReleasePath, cmd, opts.BuildArgs)
 			result := ssh.Execute(fullCmd)
 			buildLog.WriteString(fmt.Sprintf("    $ %s\n", cmd))
 			buildLog.WriteString(result.Stdout)
@@ -189,7 +244,7 @@ func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions
 	if plan.Phases.Build != nil && len(plan.Phases.Build.Commands) > 0 {
 		buildLog.WriteString("==> Running build commands...\n")
 		for _, cmd := range plan.Phases.Build.Commands {
-			fullCmd := fmt.Sprintf("cd %s && %s", opts.ReleasePath, cmd)
+			fullCmd := phaseCommand(opts.ReleasePath, cmd, opts.BuildArgs)
 			result := ssh.Execute(fullCmd)
 			buildLog.WriteString(fmt.Sprintf("    $ %s\n", cmd))
 			buildLog.WriteString(result.Stdout)
@@ -213,13 +268,8 @@ func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions
 	}
 
 	if len(opts.EnvVars) > 0 {
-		var envContent strings.Builder
-		for key, value := range opts.EnvVars {
-			envContent.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-		}
-
 		envPath := fmt.Sprintf("%s/.env", opts.ReleasePath)
-		if err := ssh.WriteRemoteFile(envPath, envContent.String(), config.PermEnvFile); err != nil {
+		if err := ssh.WriteRemoteFileAtomic(envPath, util.FormatDotenv(opts.EnvVars), config.PermEnvFile); err != nil {
 			return &builders.BuildResult{
 				Success:  false,
 				BuildLog: buildLog.String(),
@@ -329,3 +379,26 @@ func (n *NixpacksBuilder) Build(ctx context.Context, opts *builders.BuildOptions
 		StartCommand:  startCommand,
 	}, nil
 }
+
+// planCommand returns the nixpacks plan command, passing each build arg as
+// --env so NIXPACKS_* settings and variables the plan depends on apply
+func planCommand(releasePath, bin string, buildArgs map[string]string) string {
+	var command strings.Builder
+	fmt.Fprintf(&command, "cd %s && %s plan . --format json", releasePath, bin)
+	for _, key := range util.SortedKeys(buildArgs) {
+		fmt.Fprintf(&command, " --env %s", util.ShellQuote(key+"="+buildArgs[key]))
+	}
+	return command.String()
+}
+
+// phaseCommand returns an install or build command run in the release, with
+// the build args exported to it
+func phaseCommand(releasePath, cmd string, buildArgs map[string]string) string {
+	var command strings.Builder
+	fmt.Fprintf(&command, "cd %s && ", releasePath)
+	for _, key := range util.SortedKeys(buildArgs) {
+		fmt.Fprintf(&command, "export %s=%s && ", key, util.ShellQuote(buildArgs[key]))
+	}
+	command.WriteString(cmd)
+	return command.String()
+}
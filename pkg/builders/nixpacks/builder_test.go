@@ -236,3 +236,34 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestPlanCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildArgs map[string]string
+		want      string
+	}{
+		{"no args", nil, "cd /srv/app/releases/1 && nixpacks plan . --format json"},
+		{"args in key order", map[string]string{"NIXPACKS_NODE_VERSION": "20", "COMMIT_SHA": "abc123"}, "cd /srv/app/releases/1 && nixpacks plan . --format json --env 'COMMIT_SHA=abc123' --env 'NIXPACKS_NODE_VERSION=20'"},
+		{"quoted value", map[string]string{"GREETING": "it's here"}, `cd /srv/app/releases/1 && nixpacks plan . --format json --env 'GREETING=it'\''s here'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planCommand("/srv/app/releases/1", "nixpacks", tt.buildArgs); got != tt.want {
+				t.Errorf("planCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhaseCommand(t *testing.T) {
+	if got, want := phaseCommand("/srv/app/releases/1", "npm run build", nil), "cd /srv/app/releases/1 && npm run build"; got != want {
+		t.Errorf("phaseCommand() = %q, want %q", got, want)
+	}
+
+	got := phaseCommand("/srv/app/releases/1", "npm ci && npm run build", map[string]string{"SENTRY_RELEASE": "abc123", "API_URL": "https://api.example.com"})
+	want := "cd /srv/app/releases/1 && export API_URL='https://api.example.com' && export SENTRY_RELEASE='abc123' && npm ci && npm run build"
+	if got != want {
+		t.Errorf("phaseCommand() = %q, want %q", got, want)
+	}
+}
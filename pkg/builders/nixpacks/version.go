@@ -0,0 +1,93 @@
+package nixpacks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolsDir is where lightfold installs pinned build tools on the server
+const ToolsDir = "$HOME/.lightfold/tools"
+
+var (
+	versionPinPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+	versionPattern    = regexp.MustCompile(`\d+\.\d+\.\d+`)
+)
+
+// defaultBinaries are where the nixpacks install script leaves nixpacks
+var defaultBinaries = []string{"nixpacks", "$HOME/.nixpacks/bin/nixpacks"}
+
+// ValidateVersion checks a nixpacks version pin such as 1.29.1
+func ValidateVersion(version string) error {
+	if !versionPinPattern.MatchString(version) {
+		return fmt.Errorf("invalid nixpacks version %q: use a release version such as 1.29.1", version)
+	}
+	return nil
+}
+
+func normalizeVersion(version string) string {
+	return strings.TrimPrefix(strings.TrimSpace(version), "v")
+}
+
+// parseVersion reads the version from `nixpacks --version` output ("nixpacks 1.29.1")
+func parseVersion(output string) string {
+	return versionPattern.FindString(output)
+}
+
+// pinnedBinary is where InstallCommand puts a pinned version
+func pinnedBinary(version string) string {
+	return fmt.Sprintf("%s/nixpacks-%s/nixpacks", ToolsDir, version)
+}
+
+// InstallCommand downloads a nixpacks release into ToolsDir on the server,
+// next to any other pinned versions
+func InstallCommand(version string) string {
+	version = normalizeVersion(version)
+	dir := fmt.Sprintf("%s/nixpacks-%s", ToolsDir, version)
+	return fmt.Sprintf(`mkdir -p %s && curl -fsSL "https://github.com/railwayapp/nixpacks/releases/download/v%s/nixpacks-v%s-$(uname -m)-unknown-linux-musl.tar.gz" | tar -xz -C %s nixpacks`,
+		dir, version, version, dir)
+}
+
+// selectBinary finds the nixpacks binary to build with. probe returns the
+// version of a binary, or "" when it is not installed. Without a required
+// version the first installed binary is used; with one, the pinned install
+// comes first and other installs only count when their version matches.
+// When nothing matches, version is the first installed version found.
+func selectBinary(probe func(bin string) string, required string) (bin, version string, ok bool) {
+	candidates := defaultBinaries
+	if required != "" {
+		candidates = append([]string{pinnedBinary(required)}, defaultBinaries...)
+	}
+
+	installed := ""
+	for _, candidate := range candidates {
+		v := probe(candidate)
+		if v == "" {
+			continue
+		}
+		if installed == "" {
+			installed = v
+		}
+		if required == "" || v == required {
+			return candidate, v, true
+		}
+	}
+	return "", installed, false
+}
+
+// VersionMismatchError reports a server without the nixpacks version the
+// target is pinned to
+type VersionMismatchError struct {
+	Installed string // "" when nixpacks is not installed
+	Required  string
+}
+
+func (e *VersionMismatchError) Error() string {
+	installed := "nixpacks is not installed"
+	if e.Installed != "" {
+		installed = "nixpacks " + e.Installed + " is installed"
+	}
+	return fmt.Sprintf("nixpacks %s is required but %s on the server\n"+
+		"  Install it with: lightfold deploy --auto-install-builder\n"+
+		"  or on the server: %s", e.Required, installed, InstallCommand(e.Required))
+}
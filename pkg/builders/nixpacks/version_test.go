@@ -0,0 +1,93 @@
+package nixpacks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]string{
+		"nixpacks 1.29.1\n": "1.29.1",
+		"nixpacks 1.21.0":   "1.21.0",
+		"":                  "",
+		"command not found": "",
+	}
+	for output, want := range tests {
+		if got := parseVersion(output); got != want {
+			t.Errorf("parseVersion(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	for _, v := range []string{"1.29.1", "v1.29.1"} {
+		if err := ValidateVersion(v); err != nil {
+			t.Errorf("ValidateVersion(%q) = %v, want nil", v, err)
+		}
+	}
+	for _, v := range []string{"latest", "1.29", "1.29.1; rm -rf /"} {
+		if err := ValidateVersion(v); err == nil {
+			t.Errorf("ValidateVersion(%q) = nil, want error", v)
+		}
+	}
+}
+
+func TestSelectBinary(t *testing.T) {
+	installed := map[string]string{
+		"nixpacks":             "1.21.0",
+		pinnedBinary("1.29.1"): "1.29.1",
+	}
+	probe := func(bin string) string { return installed[bin] }
+
+	tests := []struct {
+		name        string
+		required    string
+		wantBin     string
+		wantVersion string
+		wantOK      bool
+	}{
+		{"unpinned uses PATH", "", "nixpacks", "1.21.0", true},
+		{"pinned install preferred", "1.29.1", pinnedBinary("1.29.1"), "1.29.1", true},
+		{"PATH install matches pin", "1.21.0", "nixpacks", "1.21.0", true},
+		{"mismatch reports installed", "1.30.0", "", "1.21.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin, version, ok := selectBinary(probe, tt.required)
+			if bin != tt.wantBin || version != tt.wantVersion || ok != tt.wantOK {
+				t.Errorf("selectBinary(%q) = %q, %q, %v; want %q, %q, %v", tt.required, bin, version, ok, tt.wantBin, tt.wantVersion, tt.wantOK)
+			}
+		})
+	}
+
+	if _, version, ok := selectBinary(func(string) string { return "" }, "1.29.1"); ok || version != "" {
+		t.Errorf("selectBinary without nixpacks = %q, %v; want \"\", false", version, ok)
+	}
+}
+
+func TestInstallCommand(t *testing.T) {
+	cmd := InstallCommand("v1.29.1")
+	for _, want := range []string{
+		"mkdir -p $HOME/.lightfold/tools/nixpacks-1.29.1",
+		"releases/download/v1.29.1/nixpacks-v1.29.1-$(uname -m)-unknown-linux-musl.tar.gz",
+		"tar -xz -C $HOME/.lightfold/tools/nixpacks-1.29.1 nixpacks",
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("InstallCommand() = %q, missing %q", cmd, want)
+		}
+	}
+}
+
+func TestVersionMismatchError(t *testing.T) {
+	err := (&VersionMismatchError{Installed: "1.21.0", Required: "1.29.1"}).Error()
+	for _, want := range []string{"nixpacks 1.29.1 is required", "nixpacks 1.21.0 is installed", "--auto-install-builder", InstallCommand("1.29.1")} {
+		if !strings.Contains(err, want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+
+	err = (&VersionMismatchError{Required: "1.29.1"}).Error()
+	if !strings.Contains(err, "nixpacks is not installed") {
+		t.Errorf("error %q should say nixpacks is not installed", err)
+	}
+}
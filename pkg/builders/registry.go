@@ -5,6 +5,7 @@ import (
 	"lightfold/pkg/detector"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -53,24 +54,50 @@ func ListAvailableBuilders() []string {
 	return available
 }
 
+// ListBuilders returns the names of all registered builders in sorted order,
+// without checking whether their tooling is installed
+func ListBuilders() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // AutoSelectBuilder determines the best builder for a project
 // Priority order:
-// 1. Dockerfile exists → use "dockerfile" (if available, else fallback to nixpacks/native)
-// 2. Node/Python + nixpacks available → use "nixpacks"
-// 3. Fallback → use "native"
+// 1. Docker Compose project → use "native" (images are built on the server)
+// 2. Dockerfile exists → use "dockerfile" (if available, else fallback to nixpacks/native)
+// 3. Node/Python + nixpacks available → use "nixpacks"
+// 4. Fallback → use "native"
 func AutoSelectBuilder(projectPath string, detection *detector.Detection) (string, error) {
+	name, _, err := AutoSelectBuilderWithReason(projectPath, detection)
+	return name, err
+}
+
+// AutoSelectBuilderWithReason is AutoSelectBuilder that also explains the choice
+func AutoSelectBuilderWithReason(projectPath string, detection *detector.Detection) (string, string, error) {
+	// Compose projects build their images on the server via docker compose
+	if detection != nil && detection.Meta["deployment_type"] == "docker-compose" {
+		return "native", "docker compose project builds on the server", nil
+	}
+
 	// Check for Dockerfile
 	dockerfilePath := filepath.Join(projectPath, "Dockerfile")
 	if _, err := os.Stat(dockerfilePath); err == nil {
 		if builder, err := GetBuilder("dockerfile"); err == nil && builder.IsAvailable() {
-			return "dockerfile", nil
+			return "dockerfile", "Dockerfile found", nil
 		}
 		// Dockerfile exists but builder not available - fallback to nixpacks if possible
 		if detection != nil {
 			lang := strings.ToLower(detection.Language)
 			if lang == "javascript" || lang == "typescript" || lang == "python" {
 				if builder, err := GetBuilder("nixpacks"); err == nil && builder.IsAvailable() {
-					return "nixpacks", nil
+					return "nixpacks", "Dockerfile found but docker is unavailable; nixpacks supports " + detection.Language, nil
 				}
 			}
 		}
@@ -81,11 +108,11 @@ func AutoSelectBuilder(projectPath string, detection *detector.Detection) (strin
 		lang := strings.ToLower(detection.Language)
 		if lang == "javascript" || lang == "typescript" || lang == "python" {
 			if builder, err := GetBuilder("nixpacks"); err == nil && builder.IsAvailable() {
-				return "nixpacks", nil
+				return "nixpacks", "nixpacks is installed and supports " + detection.Language, nil
 			}
 		}
 	}
 
 	// Fallback to native
-	return "native", nil
+	return "native", "default builder", nil
 }
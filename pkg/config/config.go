@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"lightfold/pkg/providers"
+	"lightfold/pkg/util"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type ProviderConfig interface {
@@ -14,117 +17,266 @@ type ProviderConfig interface {
 	GetUsername() string
 	GetSSHKey() string
 	IsProvisioned() bool
-	GetServerID() string // Returns the cloud provider's server/instance/machine ID
+	IsAdopted() bool             // True for servers created outside lightfold and adopted into a target
+	GetServerID() string         // Returns the cloud provider's server/instance/machine ID
+	GetAuthorizedKeys() []string // Extra public keys authorized for the deploy user
+}
+
+// IPv6Config is implemented by provider configs that record a public IPv6 address
+type IPv6Config interface {
+	GetIPv6() string
+}
+
+// PublicAddresses returns a provider config's public IPv4 and IPv6 addresses.
+// IPv6-only servers store their IPv6 address in IP, so either may be empty.
+func PublicAddresses(pc ProviderConfig) (ipv4, ipv6 string) {
+	if ip := net.ParseIP(pc.GetIP()); ip != nil && ip.To4() == nil {
+		ipv6 = pc.GetIP()
+	} else {
+		ipv4 = pc.GetIP()
+	}
+	if v6, ok := pc.(IPv6Config); ok && v6.GetIPv6() != "" {
+		ipv6 = v6.GetIPv6()
+	}
+	return ipv4, ipv6
 }
 
 type DigitalOceanConfig struct {
-	DropletID   string `json:"droplet_id,omitempty"` // For provisioned droplets
-	IP          string `json:"ip"`
-	SSHKey      string `json:"ssh_key"`
-	SSHKeyName  string `json:"ssh_key_name,omitempty"`
-	Username    string `json:"username"`
-	Region      string `json:"region,omitempty"`
-	Size        string `json:"size,omitempty"`
-	Provisioned bool   `json:"provisioned,omitempty"`
-}
-
-func (d *DigitalOceanConfig) GetIP() string       { return d.IP }
-func (d *DigitalOceanConfig) GetUsername() string { return d.Username }
-func (d *DigitalOceanConfig) GetSSHKey() string   { return d.SSHKey }
-func (d *DigitalOceanConfig) IsProvisioned() bool { return d.Provisioned }
-func (d *DigitalOceanConfig) GetServerID() string { return d.DropletID }
+	DropletID      string   `json:"droplet_id,omitempty"` // For provisioned droplets
+	IP             string   `json:"ip"`
+	IPv6           string   `json:"ipv6,omitempty"`
+	SSHKey         string   `json:"ssh_key"`
+	SSHKeyName     string   `json:"ssh_key_name,omitempty"`
+	Username       string   `json:"username"`
+	Region         string   `json:"region,omitempty"`
+	Size           string   `json:"size,omitempty"`
+	IPStack        string   `json:"ip_stack,omitempty"` // dual or ipv4-only; empty keeps the provider default
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	Adopted        bool     `json:"adopted,omitempty"`         // Created outside lightfold; kept on destroy unless --delete-server
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"` // Extra team public keys (paths or literal keys)
+}
+
+func (d *DigitalOceanConfig) GetIP() string               { return d.IP }
+func (d *DigitalOceanConfig) GetIPv6() string             { return d.IPv6 }
+func (d *DigitalOceanConfig) GetUsername() string         { return d.Username }
+func (d *DigitalOceanConfig) GetSSHKey() string           { return d.SSHKey }
+func (d *DigitalOceanConfig) IsProvisioned() bool         { return d.Provisioned }
+func (d *DigitalOceanConfig) IsAdopted() bool             { return d.Adopted }
+func (d *DigitalOceanConfig) GetServerID() string         { return d.DropletID }
+func (d *DigitalOceanConfig) GetAuthorizedKeys() []string { return d.AuthorizedKeys }
 
 type HetznerConfig struct {
-	ServerID    string `json:"server_id,omitempty"`
-	IP          string `json:"ip"`
-	SSHKey      string `json:"ssh_key"`
-	SSHKeyName  string `json:"ssh_key_name,omitempty"`
-	Username    string `json:"username"`
-	Location    string `json:"location,omitempty"`
-	ServerType  string `json:"server_type,omitempty"`
-	Provisioned bool   `json:"provisioned,omitempty"`
-}
-
-func (h *HetznerConfig) GetIP() string       { return h.IP }
-func (h *HetznerConfig) GetUsername() string { return h.Username }
-func (h *HetznerConfig) GetSSHKey() string   { return h.SSHKey }
-func (h *HetznerConfig) IsProvisioned() bool { return h.Provisioned }
-func (h *HetznerConfig) GetServerID() string { return h.ServerID }
+	ServerID       string   `json:"server_id,omitempty"`
+	IP             string   `json:"ip"`
+	IPv6           string   `json:"ipv6,omitempty"`
+	SSHKey         string   `json:"ssh_key"`
+	SSHKeyName     string   `json:"ssh_key_name,omitempty"`
+	Username       string   `json:"username"`
+	Location       string   `json:"location,omitempty"`
+	ServerType     string   `json:"server_type,omitempty"`
+	Arch           string   `json:"arch,omitempty"`     // x86_64 or arm64 (CAX types)
+	IPStack        string   `json:"ip_stack,omitempty"` // dual, ipv4-only or ipv6-only
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	Adopted        bool     `json:"adopted,omitempty"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+func (h *HetznerConfig) GetIP() string               { return h.IP }
+func (h *HetznerConfig) GetIPv6() string             { return h.IPv6 }
+func (h *HetznerConfig) GetUsername() string         { return h.Username }
+func (h *HetznerConfig) GetSSHKey() string           { return h.SSHKey }
+func (h *HetznerConfig) IsProvisioned() bool         { return h.Provisioned }
+func (h *HetznerConfig) IsAdopted() bool             { return h.Adopted }
+func (h *HetznerConfig) GetServerID() string         { return h.ServerID }
+func (h *HetznerConfig) GetAuthorizedKeys() []string { return h.AuthorizedKeys }
 
 type VultrConfig struct {
-	InstanceID  string `json:"instance_id,omitempty"` // For provisioned instances
-	IP          string `json:"ip"`
-	SSHKey      string `json:"ssh_key"`
-	SSHKeyName  string `json:"ssh_key_name,omitempty"`
-	Username    string `json:"username"`
-	Region      string `json:"region,omitempty"`
-	Plan        string `json:"plan,omitempty"` // Vultr uses "plan" instead of "size"
-	Provisioned bool   `json:"provisioned,omitempty"`
-}
-
-func (v *VultrConfig) GetIP() string       { return v.IP }
-func (v *VultrConfig) GetUsername() string { return v.Username }
-func (v *VultrConfig) GetSSHKey() string   { return v.SSHKey }
-func (v *VultrConfig) IsProvisioned() bool { return v.Provisioned }
-func (v *VultrConfig) GetServerID() string { return v.InstanceID }
+	InstanceID     string   `json:"instance_id,omitempty"` // For provisioned instances
+	IP             string   `json:"ip"`
+	IPv6           string   `json:"ipv6,omitempty"`
+	SSHKey         string   `json:"ssh_key"`
+	SSHKeyName     string   `json:"ssh_key_name,omitempty"`
+	Username       string   `json:"username"`
+	Region         string   `json:"region,omitempty"`
+	Plan           string   `json:"plan,omitempty"`     // Vultr uses "plan" instead of "size"
+	IPStack        string   `json:"ip_stack,omitempty"` // dual, ipv4-only or ipv6-only
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	Adopted        bool     `json:"adopted,omitempty"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+func (v *VultrConfig) GetIP() string               { return v.IP }
+func (v *VultrConfig) GetIPv6() string             { return v.IPv6 }
+func (v *VultrConfig) GetUsername() string         { return v.Username }
+func (v *VultrConfig) GetSSHKey() string           { return v.SSHKey }
+func (v *VultrConfig) IsProvisioned() bool         { return v.Provisioned }
+func (v *VultrConfig) IsAdopted() bool             { return v.Adopted }
+func (v *VultrConfig) GetServerID() string         { return v.InstanceID }
+func (v *VultrConfig) GetAuthorizedKeys() []string { return v.AuthorizedKeys }
 
 type FlyioConfig struct {
-	MachineID      string `json:"machine_id,omitempty"`
-	AppName        string `json:"app_name,omitempty"`        // fly.io requires app context
-	OrganizationID string `json:"organization_id,omitempty"` // fly.io organization ID
-	IP             string `json:"ip"`
-	SSHKey         string `json:"ssh_key"`
-	SSHKeyName     string `json:"ssh_key_name,omitempty"`
-	Username       string `json:"username"`
-	Region         string `json:"region,omitempty"`
-	Size           string `json:"size,omitempty"`
-	Provisioned    bool   `json:"provisioned,omitempty"`
-}
-
-func (f *FlyioConfig) GetIP() string       { return f.IP }
-func (f *FlyioConfig) GetUsername() string { return f.Username }
-func (f *FlyioConfig) GetSSHKey() string   { return f.SSHKey }
-func (f *FlyioConfig) IsProvisioned() bool { return f.Provisioned }
-func (f *FlyioConfig) GetServerID() string { return f.MachineID }
+	MachineID      string   `json:"machine_id,omitempty"`
+	AppName        string   `json:"app_name,omitempty"`        // fly.io requires app context
+	OrganizationID string   `json:"organization_id,omitempty"` // fly.io organization ID
+	IP             string   `json:"ip"`
+	SSHKey         string   `json:"ssh_key"`
+	SSHKeyName     string   `json:"ssh_key_name,omitempty"`
+	Username       string   `json:"username"`
+	Region         string   `json:"region,omitempty"`
+	Size           string   `json:"size,omitempty"`
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+func (f *FlyioConfig) GetIP() string               { return f.IP }
+func (f *FlyioConfig) GetUsername() string         { return f.Username }
+func (f *FlyioConfig) GetSSHKey() string           { return f.SSHKey }
+func (f *FlyioConfig) IsProvisioned() bool         { return f.Provisioned }
+func (f *FlyioConfig) IsAdopted() bool             { return false }
+func (f *FlyioConfig) GetServerID() string         { return f.MachineID }
+func (f *FlyioConfig) GetAuthorizedKeys() []string { return f.AuthorizedKeys }
 
 type LinodeConfig struct {
-	InstanceID  string `json:"instance_id,omitempty"` // For provisioned instances
-	IP          string `json:"ip"`
-	SSHKey      string `json:"ssh_key"`
-	SSHKeyName  string `json:"ssh_key_name,omitempty"`
-	Username    string `json:"username"`
-	Region      string `json:"region,omitempty"`
-	Plan        string `json:"plan,omitempty"` // Linode uses "plan" or "type"
-	Provisioned bool   `json:"provisioned,omitempty"`
-	RootPass    string `json:"root_pass,omitempty"` // Generated root password for emergency access
-}
-
-func (l *LinodeConfig) GetIP() string       { return l.IP }
-func (l *LinodeConfig) GetUsername() string { return l.Username }
-func (l *LinodeConfig) GetSSHKey() string   { return l.SSHKey }
-func (l *LinodeConfig) IsProvisioned() bool { return l.Provisioned }
-func (l *LinodeConfig) GetServerID() string { return l.InstanceID }
+	InstanceID     string   `json:"instance_id,omitempty"` // For provisioned instances
+	IP             string   `json:"ip"`
+	IPv6           string   `json:"ipv6,omitempty"`
+	SSHKey         string   `json:"ssh_key"`
+	SSHKeyName     string   `json:"ssh_key_name,omitempty"`
+	Username       string   `json:"username"`
+	Region         string   `json:"region,omitempty"`
+	Plan           string   `json:"plan,omitempty"` // Linode uses "plan" or "type"
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	Adopted        bool     `json:"adopted,omitempty"`
+	RootPass       string   `json:"root_pass,omitempty"` // Generated root password for emergency access
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+func (l *LinodeConfig) GetIP() string               { return l.IP }
+func (l *LinodeConfig) GetIPv6() string             { return l.IPv6 }
+func (l *LinodeConfig) GetUsername() string         { return l.Username }
+func (l *LinodeConfig) GetSSHKey() string           { return l.SSHKey }
+func (l *LinodeConfig) IsProvisioned() bool         { return l.Provisioned }
+func (l *LinodeConfig) IsAdopted() bool             { return l.Adopted }
+func (l *LinodeConfig) GetServerID() string         { return l.InstanceID }
+func (l *LinodeConfig) GetAuthorizedKeys() []string { return l.AuthorizedKeys }
 
 type AWSConfig struct {
-	InstanceID      string `json:"instance_id,omitempty"` // EC2 instance ID
-	IP              string `json:"ip"`
-	SSHKey          string `json:"ssh_key"`
-	SSHKeyName      string `json:"ssh_key_name,omitempty"`
-	Username        string `json:"username"`
-	Region          string `json:"region,omitempty"`
-	InstanceType    string `json:"instance_type,omitempty"` // e.g., "t3.small"
-	Provisioned     bool   `json:"provisioned,omitempty"`
-	ElasticIP       string `json:"elastic_ip,omitempty"`        // Allocation ID if EIP used
-	SecurityGroupID string `json:"security_group_id,omitempty"` // Security group ID for cleanup
-	VpcID           string `json:"vpc_id,omitempty"`            // VPC ID
-	SubnetID        string `json:"subnet_id,omitempty"`         // Subnet ID
+	InstanceID      string   `json:"instance_id,omitempty"` // EC2 instance ID
+	IP              string   `json:"ip"`
+	IPv6            string   `json:"ipv6,omitempty"`
+	SSHKey          string   `json:"ssh_key"`
+	SSHKeyName      string   `json:"ssh_key_name,omitempty"`
+	Username        string   `json:"username"`
+	Region          string   `json:"region,omitempty"`
+	InstanceType    string   `json:"instance_type,omitempty"` // e.g., "t3.small"
+	Arch            string   `json:"arch,omitempty"`          // x86_64 or arm64 (Graviton types)
+	Provisioned     bool     `json:"provisioned,omitempty"`
+	Adopted         bool     `json:"adopted,omitempty"`
+	ElasticIP       string   `json:"elastic_ip,omitempty"`        // Allocation ID if EIP used
+	SecurityGroupID string   `json:"security_group_id,omitempty"` // Security group ID for cleanup
+	VpcID           string   `json:"vpc_id,omitempty"`            // VPC ID
+	SubnetID        string   `json:"subnet_id,omitempty"`         // Subnet ID
+	AuthorizedKeys  []string `json:"authorized_keys,omitempty"`
+}
+
+func (a *AWSConfig) GetIP() string               { return a.IP }
+func (a *AWSConfig) GetIPv6() string             { return a.IPv6 }
+func (a *AWSConfig) GetUsername() string         { return a.Username }
+func (a *AWSConfig) GetSSHKey() string           { return a.SSHKey }
+func (a *AWSConfig) IsProvisioned() bool         { return a.Provisioned }
+func (a *AWSConfig) IsAdopted() bool             { return a.Adopted }
+func (a *AWSConfig) GetServerID() string         { return a.InstanceID }
+func (a *AWSConfig) GetAuthorizedKeys() []string { return a.AuthorizedKeys }
+
+// ExternalConfig is the provider config for "exec:" plugin providers
+type ExternalConfig struct {
+	ServerID       string            `json:"server_id,omitempty"`
+	IP             string            `json:"ip"`
+	SSHKey         string            `json:"ssh_key"`
+	SSHKeyName     string            `json:"ssh_key_name,omitempty"`
+	Username       string            `json:"username"`
+	Region         string            `json:"region,omitempty"`
+	Size           string            `json:"size,omitempty"`
+	Provisioned    bool              `json:"provisioned,omitempty"`
+	Adopted        bool              `json:"adopted,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"` // Server metadata returned by the plugin
+	AuthorizedKeys []string          `json:"authorized_keys,omitempty"`
+}
+
+func (e *ExternalConfig) GetIP() string               { return e.IP }
+func (e *ExternalConfig) GetUsername() string         { return e.Username }
+func (e *ExternalConfig) GetSSHKey() string           { return e.SSHKey }
+func (e *ExternalConfig) IsProvisioned() bool         { return e.Provisioned }
+func (e *ExternalConfig) IsAdopted() bool             { return e.Adopted }
+func (e *ExternalConfig) GetServerID() string         { return e.ServerID }
+func (e *ExternalConfig) GetAuthorizedKeys() []string { return e.AuthorizedKeys }
+
+// SSHHostConfig is implemented by provider configs that can read their
+// connection settings from an ssh_config Host alias
+type SSHHostConfig interface {
+	GetSSHHost() string   // Host alias in ~/.ssh/config, empty when not used
+	GetSSHPort() string   // Port from the alias, empty for the default
+	GetProxyJump() string // ProxyJump from the alias, empty for a direct connection
+}
+
+// BYOSConfig is the provider config of a server lightfold did not create
+type BYOSConfig struct {
+	IP       string `json:"ip"`
+	SSHKey   string `json:"ssh_key"`
+	Username string `json:"username"`
+	// SSHHost is a Host alias from ~/.ssh/config. HostName, User, Port,
+	// IdentityFile and ProxyJump are read from it every time they are used,
+	// so edits to the ssh config take effect; IP, SSHKey and Username override
+	// it when set.
+	SSHHost        string   `json:"ssh_host,omitempty"`
+	Provisioned    bool     `json:"provisioned,omitempty"`
+	AuthorizedKeys []string `json:"authorized_keys,omitempty"`
+}
+
+// sshHost resolves the alias; a config that cannot be read leaves every
+// setting but the HostName empty
+func (b *BYOSConfig) sshHost() SSHHostSettings {
+	if b.SSHHost == "" {
+		return SSHHostSettings{}
+	}
+	settings, _ := ResolveSSHHost(b.SSHHost)
+	return settings
+}
+
+func (b *BYOSConfig) GetIP() string {
+	if b.IP != "" {
+		return b.IP
+	}
+	return b.sshHost().HostName
+}
+
+func (b *BYOSConfig) GetUsername() string {
+	if b.Username != "" {
+		return b.Username
+	}
+	if user := b.sshHost().User; user != "" {
+		return user
+	}
+	if b.SSHHost != "" {
+		return "root"
+	}
+	return ""
+}
+
+func (b *BYOSConfig) GetSSHKey() string {
+	if b.SSHKey != "" {
+		return b.SSHKey
+	}
+	return b.sshHost().IdentityFile
 }
 
-func (a *AWSConfig) GetIP() string       { return a.IP }
-func (a *AWSConfig) GetUsername() string { return a.Username }
-func (a *AWSConfig) GetSSHKey() string   { return a.SSHKey }
-func (a *AWSConfig) IsProvisioned() bool { return a.Provisioned }
-func (a *AWSConfig) GetServerID() string { return a.InstanceID }
+func (b *BYOSConfig) GetSSHHost() string          { return b.SSHHost }
+func (b *BYOSConfig) GetSSHPort() string          { return b.sshHost().Port }
+func (b *BYOSConfig) GetProxyJump() string        { return b.sshHost().ProxyJump }
+func (b *BYOSConfig) IsProvisioned() bool         { return b.Provisioned }
+func (b *BYOSConfig) IsAdopted() bool             { return false }
+func (b *BYOSConfig) GetServerID() string         { return "" }
+func (b *BYOSConfig) GetAuthorizedKeys() []string { return b.AuthorizedKeys }
 
 type S3Config struct {
 	Bucket    string `json:"bucket"`
@@ -133,19 +285,74 @@ type S3Config struct {
 	SecretKey string `json:"secret_key,omitempty"`
 }
 
-func (s *S3Config) GetIP() string       { return "" }
-func (s *S3Config) GetUsername() string { return "" }
-func (s *S3Config) GetSSHKey() string   { return "" }
-func (s *S3Config) IsProvisioned() bool { return false }
-func (s *S3Config) GetServerID() string { return "" }
+func (s *S3Config) GetIP() string               { return "" }
+func (s *S3Config) GetUsername() string         { return "" }
+func (s *S3Config) GetSSHKey() string           { return "" }
+func (s *S3Config) IsProvisioned() bool         { return false }
+func (s *S3Config) IsAdopted() bool             { return false }
+func (s *S3Config) GetServerID() string         { return "" }
+func (s *S3Config) GetAuthorizedKeys() []string { return nil }
+
+// ServerConfig is an extra server a target deploys to in lockstep with the
+// server in its provider config
+type ServerConfig struct {
+	ServerID string `json:"server_id,omitempty"` // Provider's server ID, used to attach the server to a load balancer
+	IP       string `json:"ip"`
+	SSHKey   string `json:"ssh_key"`
+	Username string `json:"username"`
+}
+
+func (s *ServerConfig) GetIP() string               { return s.IP }
+func (s *ServerConfig) GetUsername() string         { return s.Username }
+func (s *ServerConfig) GetSSHKey() string           { return s.SSHKey }
+func (s *ServerConfig) IsProvisioned() bool         { return false }
+func (s *ServerConfig) IsAdopted() bool             { return true }
+func (s *ServerConfig) GetServerID() string         { return s.ServerID }
+func (s *ServerConfig) GetAuthorizedKeys() []string { return nil }
+
+// LoadBalancerConfig records the provider load balancer in front of a multi-server target
+type LoadBalancerConfig struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	Region string `json:"region,omitempty"`
+}
 
 type DeploymentOptions struct {
-	SkipBuild     bool              `json:"skip_build,omitempty"`
-	EnvVars       map[string]string `json:"env_vars,omitempty"`
-	BuildCommand  string            `json:"build_command,omitempty"`
-	RunCommand    string            `json:"run_command,omitempty"`
-	BuildCommands []string          `json:"build_commands,omitempty"`
-	RunCommands   []string          `json:"run_commands,omitempty"`
+	SkipBuild            bool              `json:"skip_build,omitempty"`
+	EnvVars              map[string]string `json:"env_vars,omitempty"`
+	BuildCommand         string            `json:"build_command,omitempty"`
+	RunCommand           string            `json:"run_command,omitempty"`
+	BuildCommands        []string          `json:"build_commands,omitempty"`
+	RunCommands          []string          `json:"run_commands,omitempty"`
+	DrainSeconds         int               `json:"drain_seconds,omitempty"`           // Time allowed for in-flight connections to finish after SIGTERM on restart
+	Workers              int               `json:"workers,omitempty"`                 // Gunicorn/uvicorn worker processes; 0 sizes from the server's CPUs and memory
+	Threads              int               `json:"threads,omitempty"`                 // Gunicorn threads per worker, or libuv threadpool size for Node
+	MaxRequests          int               `json:"max_requests,omitempty"`            // Requests a worker serves before it is recycled
+	StaticPaths          map[string]string `json:"static_paths,omitempty"`            // URL prefix -> directory served by nginx, relative to the app directory; replaces the framework defaults
+	DisableStaticPaths   bool              `json:"disable_static_paths,omitempty"`    // Proxy every request to the app, even static and media URLs
+	AllowLockfileChanges bool              `json:"allow_lockfile_changes,omitempty"`  // Install with plain npm/yarn/pnpm/bun install instead of the frozen-lockfile variants
+	BuildOutputDirs      []BuildOutputDir  `json:"build_output_dirs,omitempty"`       // Static sites: subdirectories of the build output served under their own URL prefix
+	MigrationCommand     string            `json:"migration_command,omitempty"`       // Runs in the new release after the build, before it goes live; replaces the framework default
+	SkipMigrations       bool              `json:"skip_migrations,omitempty"`         // Never run migrations on deploy, including the framework default
+	SkipBuildMemoryCheck bool              `json:"skip_build_memory_check,omitempty"` // Build on servers with less memory than the framework needs without warning
+	Subdir               string            `json:"subdir,omitempty"`                  // Monorepo app directory relative to the project, e.g. apps/web; Turborepo and Nx builds are scoped to it
+	ProxyHealthCheck     *bool             `json:"proxy_health_check,omitempty"`      // Also health check through nginx after deploy; unset checks when the target has a domain
+	Jobs                 []Job             `json:"jobs,omitempty"`                    // Scheduled commands run as systemd timers next to the app
+	MaintenanceAllow     []string          `json:"maintenance_allow,omitempty"`       // Paths still proxied to the app in maintenance mode; empty allows /healthz
+	ExposePort           bool              `json:"expose_port,omitempty"`             // Bind the app on all interfaces for direct access on its port; otherwise it only listens on 127.0.0.1 behind nginx
+	BuildArgs            map[string]string `json:"build_args,omitempty"`              // docker build --build-arg values (dockerfile), or nixpacks --env values exported to the build phases
+	BuildTarget          string            `json:"build_target,omitempty"`            // Multi-stage Dockerfile stage to build (docker build --target); dockerfile builder only
+	FirstDeployCommands  []string          `json:"first_deploy_commands,omitempty"`   // Run once in the release after the app's first healthy deploy, e.g. to seed data; a marker in shared/ keeps them from running again
+	BaseDir              string            `json:"base_dir,omitempty"`                // Directory apps are deployed under on the server instead of /srv, e.g. /opt/apps
+	KeepReleases         int               `json:"keep_releases,omitempty"`           // Releases kept on the server after a deploy; 0 uses the global keep_releases
+}
+
+// BuildOutputDir serves one subdirectory of a static site's build output under
+// a URL prefix, e.g. a locale built to dist/de served at /de/
+type BuildOutputDir struct {
+	PathPrefix string `json:"path_prefix"`
+	Dir        string `json:"dir"` // Relative to the build output
 }
 
 type DomainConfig struct {
@@ -154,20 +361,192 @@ type DomainConfig struct {
 	Subdomain  string `json:"subdomain,omitempty"`   // Subdomain: app
 	SSLEnabled bool   `json:"ssl_enabled,omitempty"`
 	SSLManager string `json:"ssl_manager,omitempty"` // "certbot", "caddy", etc.
+	SSLStaging bool   `json:"ssl_staging,omitempty"` // Certificate from the Let's Encrypt staging environment (not browser-trusted)
 	ProxyType  string `json:"proxy_type,omitempty"`  // "nginx", "caddy", etc.
 	Email      string `json:"email,omitempty"`       // Email for SSL certificate registration
+	PathPrefix string `json:"path_prefix,omitempty"` // Served under this prefix on another target's domain: /api
+	// DNSProvider is the DNS host certbot solves DNS-01 challenges with, which
+	// enables the *.preview.<domain> wildcard certificate: "digitalocean", "cloudflare"
+	DNSProvider string `json:"dns_provider,omitempty"`
+	// RedirectFrom is the www or apex counterpart of Domain, which is served
+	// only to answer with a 301 to Domain: www.example.com
+	RedirectFrom string `json:"redirect_from,omitempty"`
+	// HTTP2 serves the HTTPS site over HTTP/2 next to HTTP/1.1; unset is on
+	HTTP2 *bool `json:"http2,omitempty"`
+	// HTTP3 also serves it over QUIC on UDP 443, advertised with an Alt-Svc
+	// header. nginx needs the http_v3_module, from 1.25 on.
+	HTTP3 bool `json:"http3,omitempty"`
+}
+
+// HTTP2Enabled reports whether the HTTPS site is served over HTTP/2
+func (d *DomainConfig) HTTP2Enabled() bool {
+	return d.HTTP2 == nil || *d.HTTP2
+}
+
+// Names returns the domain followed by the name redirected to it, if any,
+// which is the list the certificate has to cover
+func (d *DomainConfig) Names() []string {
+	if d.RedirectFrom == "" {
+		return []string{d.Domain}
+	}
+	return []string{d.Domain, d.RedirectFrom}
 }
 
 type TargetConfig struct {
-	ProjectPath    string                     `json:"project_path"`
-	Framework      string                     `json:"framework"`
-	Provider       string                     `json:"provider"`
-	Builder        string                     `json:"builder,omitempty"`
-	ServerIP       string                     `json:"server_ip,omitempty"`
-	Port           int                        `json:"port,omitempty"`
-	ProviderConfig map[string]json.RawMessage `json:"provider_config"`
-	Deploy         *DeploymentOptions         `json:"deploy,omitempty"`
-	Domain         *DomainConfig              `json:"domain,omitempty"`
+	ProjectPath string `json:"project_path"`
+	Framework   string `json:"framework"`
+	// FrameworkOverride is the framework chosen with --framework or at the
+	// ambiguity prompt; detection runs its plan instead of picking by score
+	FrameworkOverride string `json:"framework_override,omitempty"`
+	Provider          string `json:"provider"`
+	Builder           string `json:"builder,omitempty"`
+	// NixpacksVersion pins the nixpacks release the nixpacks builder runs, e.g. "1.29.1"
+	NixpacksVersion string                     `json:"nixpacks_version,omitempty"`
+	ServerIP        string                     `json:"server_ip,omitempty"`
+	Port            int                        `json:"port,omitempty"`
+	ContainerPort   int                        `json:"container_port,omitempty"` // Port the app listens on inside its container (dockerfile builder)
+	ProviderConfig  map[string]json.RawMessage `json:"provider_config"`
+	Deploy          *DeploymentOptions         `json:"deploy,omitempty"`
+	Domain          *DomainConfig              `json:"domain,omitempty"`
+	PathRoutes      []DomainConfig             `json:"path_routes,omitempty"`    // Path prefixes this target serves on other targets' domains
+	UserDataFile    string                     `json:"user_data_file,omitempty"` // Extra cloud-init snippet merged in at provisioning
+	Servers         []ServerConfig             `json:"servers,omitempty"`        // Extra servers deployed in lockstep with the primary
+	LoadBalancer    *LoadBalancerConfig        `json:"load_balancer,omitempty"`
+	SSH             *SSHOptions                `json:"ssh,omitempty"`
+	Assets          *AssetsConfig              `json:"assets,omitempty"`
+	Schedule        *PowerSchedule             `json:"schedule,omitempty"`  // Powers non-production servers off and on at set times
+	Labels          map[string]string          `json:"labels,omitempty"`    // Cost allocation labels applied to the target's cloud resources
+	AppName         string                     `json:"app_name,omitempty"`  // Server app name adopted from a deployment under a legacy name
+	Protected       bool                       `json:"protected,omitempty"` // Push, deploy and destroy require typing the target name
+}
+
+// SSHOptions tune SSH connections to a target's servers for flaky networks.
+// Zero values use the defaults.
+type SSHOptions struct {
+	KeepAliveSeconds   int `json:"keepalive_seconds,omitempty"`    // Interval between keepalive requests; -1 disables them
+	KeepAliveMaxMissed int `json:"keepalive_max_missed,omitempty"` // Unanswered keepalives before the connection is dropped
+	ReconnectAttempts  int `json:"reconnect_attempts,omitempty"`   // Redials of a dropped connection; -1 disables reconnecting
+}
+
+// AssetsConfig uploads the framework's static assets to an S3 bucket after
+// each build, so a CDN in front of the bucket serves them instead of the server
+type AssetsConfig struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region,omitempty"`
+	Prefix          string `json:"prefix,omitempty"`            // Key prefix inside the bucket, e.g. myapp
+	PublicURL       string `json:"public_url"`                  // URL the bucket is served from, e.g. https://d111.cloudfront.net
+	ContinueOnError bool   `json:"continue_on_error,omitempty"` // Deploy even when the upload fails
+}
+
+// AppSubdir returns the monorepo app directory the target deploys, or "" for
+// the whole project
+func (t *TargetConfig) AppSubdir() string {
+	if t.Deploy == nil {
+		return ""
+	}
+	return t.Deploy.Subdir
+}
+
+// RemoteBaseDir returns the directory the target's app is deployed under on
+// the server: deploy.base_dir, or RemoteAppBaseDir when it is not set
+func (t *TargetConfig) RemoteBaseDir() string {
+	if t.Deploy == nil {
+		return RemoteAppBaseDir
+	}
+	return AppDir(t.Deploy.BaseDir, "")
+}
+
+// AppDir returns the directory appName is deployed to under baseDir, which
+// defaults to RemoteAppBaseDir: /srv/<app>. An empty appName returns the base
+// directory itself.
+func AppDir(baseDir, appName string) string {
+	baseDir = strings.TrimRight(baseDir, "/")
+	if baseDir == "" {
+		baseDir = RemoteAppBaseDir
+	}
+	if appName == "" {
+		return baseDir
+	}
+	return baseDir + "/" + appName
+}
+
+// ProxyHealthCheckHost returns the Host the post-deploy health check sends
+// through nginx: the domain, or serverIP when the check is switched on for a
+// target without one. It is "" when the check is off, the default without a
+// domain.
+func (t *TargetConfig) ProxyHealthCheckHost(serverIP string) string {
+	var enabled *bool
+	if t.Deploy != nil {
+		enabled = t.Deploy.ProxyHealthCheck
+	}
+	if enabled != nil && !*enabled {
+		return ""
+	}
+	if t.Domain != nil && t.Domain.Domain != "" {
+		return t.Domain.Domain
+	}
+	if enabled != nil {
+		return serverIP
+	}
+	return ""
+}
+
+// serverIDKeys are the provider config keys that hold the provider's server ID
+var serverIDKeys = []string{"droplet_id", "server_id", "instance_id", "machine_id"}
+
+// ForServer returns a copy of the target whose SSH provider config points at
+// one of its extra servers, so per-server steps can reuse single-server code
+func (t *TargetConfig) ForServer(server ServerConfig) (TargetConfig, error) {
+	raw, ok := t.ProviderConfig[t.Provider]
+	if !ok {
+		return TargetConfig{}, fmt.Errorf("no configuration found for provider: %s", t.Provider)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return TargetConfig{}, fmt.Errorf("failed to parse provider config: %w", err)
+	}
+
+	for _, key := range serverIDKeys {
+		delete(fields, key)
+	}
+	fields["ip"] = server.IP
+	if server.SSHKey != "" {
+		fields["ssh_key"] = server.SSHKey
+	}
+	if server.Username != "" {
+		fields["username"] = server.Username
+	}
+	fields["provisioned"] = false
+	fields["adopted"] = true
+
+	clone := *t
+	clone.ProviderConfig = make(map[string]json.RawMessage, len(t.ProviderConfig))
+	for name, value := range t.ProviderConfig {
+		clone.ProviderConfig[name] = value
+	}
+	if err := clone.SetProviderConfig(t.Provider, fields); err != nil {
+		return TargetConfig{}, err
+	}
+	if t.ServerIP != "" {
+		clone.ServerIP = server.IP
+	}
+	clone.Servers = nil
+
+	return clone, nil
+}
+
+// ServerTargets returns one target per server, the primary server first
+func (t *TargetConfig) ServerTargets() ([]TargetConfig, error) {
+	targets := []TargetConfig{*t}
+	for _, server := range t.Servers {
+		serverTarget, err := t.ForServer(server)
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", server.IP, err)
+		}
+		targets = append(targets, serverTarget)
+	}
+	return targets, nil
 }
 
 func (t *TargetConfig) GetProviderConfig(provider string, target interface{}) error {
@@ -205,6 +584,15 @@ func (t *TargetConfig) GetDigitalOceanConfig() (*DigitalOceanConfig, error) {
 	return &config, nil
 }
 
+// GetBYOSConfig returns the config of a server brought by the user
+func (t *TargetConfig) GetBYOSConfig() (*BYOSConfig, error) {
+	var config BYOSConfig
+	if err := t.GetProviderConfig("byos", &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
 func (t *TargetConfig) GetHetznerConfig() (*HetznerConfig, error) {
 	var config HetznerConfig
 	if err := t.GetProviderConfig("hetzner", &config); err != nil {
@@ -253,8 +641,23 @@ func (t *TargetConfig) GetAWSConfig() (*AWSConfig, error) {
 	return &config, nil
 }
 
+// GetExternalConfig returns the config of an "exec:" plugin provider
+func (t *TargetConfig) GetExternalConfig() (*ExternalConfig, error) {
+	var config ExternalConfig
+	if err := t.GetProviderConfig(t.Provider, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
 func (t *TargetConfig) GetSSHProviderConfig() (ProviderConfig, error) {
+	if providers.IsExternal(t.Provider) {
+		return t.GetExternalConfig()
+	}
+
 	switch t.Provider {
+	case "byos":
+		return t.GetBYOSConfig()
 	case "digitalocean":
 		return t.GetDigitalOceanConfig()
 	case "hetzner":
@@ -275,7 +678,13 @@ func (t *TargetConfig) GetSSHProviderConfig() (ProviderConfig, error) {
 }
 
 func (t *TargetConfig) GetAnyProviderConfig() (ProviderConfig, error) {
+	if providers.IsExternal(t.Provider) {
+		return t.GetExternalConfig()
+	}
+
 	switch t.Provider {
+	case "byos":
+		return t.GetBYOSConfig()
 	case "digitalocean":
 		return t.GetDigitalOceanConfig()
 	case "hetzner":
@@ -295,6 +704,71 @@ func (t *TargetConfig) GetAnyProviderConfig() (ProviderConfig, error) {
 	}
 }
 
+// SetAuthorizedKeys replaces the extra authorized keys on the target's SSH provider config
+func (t *TargetConfig) SetAuthorizedKeys(keys []string) error {
+	switch t.Provider {
+	case "byos":
+		cfg, err := t.GetBYOSConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("byos", cfg)
+	case "digitalocean":
+		cfg, err := t.GetDigitalOceanConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("digitalocean", cfg)
+	case "hetzner":
+		cfg, err := t.GetHetznerConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("hetzner", cfg)
+	case "vultr":
+		cfg, err := t.GetVultrConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("vultr", cfg)
+	case "flyio":
+		cfg, err := t.GetFlyioConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("flyio", cfg)
+	case "linode":
+		cfg, err := t.GetLinodeConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("linode", cfg)
+	case "aws":
+		cfg, err := t.GetAWSConfig()
+		if err != nil {
+			return err
+		}
+		cfg.AuthorizedKeys = keys
+		return t.SetProviderConfig("aws", cfg)
+	default:
+		if providers.IsExternal(t.Provider) {
+			cfg, err := t.GetExternalConfig()
+			if err != nil {
+				return err
+			}
+			cfg.AuthorizedKeys = keys
+			return t.SetProviderConfig(t.Provider, cfg)
+		}
+		return fmt.Errorf("provider %s does not support authorized keys", t.Provider)
+	}
+}
+
 // RequiresSSHDeployment returns true if this target uses SSH-based deployment
 // Uses the provider's SupportsSSH() method for polymorphic dispatch
 func (t *TargetConfig) RequiresSSHDeployment() bool {
@@ -321,8 +795,54 @@ func (t *TargetConfig) RequiresSSHDeployment() bool {
 }
 
 type Config struct {
-	Targets     map[string]TargetConfig `json:"targets"`
-	NumReleases int                     `json:"keep_releases,omitempty"`
+	SchemaVersion   int                     `json:"schema_version"`    // ConfigSchema version the file is written in
+	Version         string                  `json:"version,omitempty"` // lightfold version that last wrote the file
+	Targets         map[string]TargetConfig `json:"targets"`
+	NumReleases     int                     `json:"keep_releases,omitempty"`
+	PackWorkers     int                     `json:"pack_workers,omitempty"`     // Files read in parallel when packing a release; 0 uses GOMAXPROCS
+	DetectionMargin float64                 `json:"detection_margin,omitempty"` // Score points the runner-up framework may trail the top one by and still ask; 0 uses the default
+	Telemetry       *TelemetryConfig        `json:"telemetry,omitempty"`
+	EnvEncryption   *EnvEncryptionConfig    `json:"env_encryption,omitempty"` // Set when env values are stored encrypted
+
+	sealedEnv map[string]sealedEnvValue // Ciphertexts of the decrypted env values, by target and name
+	envErr    error                     // Why env values are still encrypted after loading
+}
+
+// TelemetryConfig opts in to sending crash reports. Crash dumps are always
+// written locally; they are only sent when Enabled is set.
+type TelemetryConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint,omitempty"` // URL crash reports are POSTed to
+}
+
+// TelemetryEndpoint returns where crash reports are sent, or "" when the user
+// has not opted in
+func (c *Config) TelemetryEndpoint() string {
+	if c.Telemetry == nil || !c.Telemetry.Enabled {
+		return ""
+	}
+	return c.Telemetry.Endpoint
+}
+
+// CLIVersion is the running lightfold version, stamped into config.json on
+// save. The cmd package sets it.
+var CLIVersion string
+
+var newerConfigWarning sync.Once
+
+// warnNewerConfig warns once per run when config.json was written by a
+// lightfold from a later release line, whose settings this version may
+// misread or drop on save
+func warnNewerConfig(writtenBy string) {
+	if writtenBy == "" || CLIVersion == "" {
+		return
+	}
+	if util.ReleaseLine(writtenBy) == util.ReleaseLine(CLIVersion) || util.CompareVersions(writtenBy, CLIVersion) <= 0 {
+		return
+	}
+	newerConfigWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "Warning: %s was written by lightfold %s, newer than this lightfold %s; run 'lightfold self-update' before changing it\n", GetConfigPath(), writtenBy, CLIVersion)
+	})
 }
 
 func GetConfigPath() string {
@@ -343,8 +863,9 @@ func LoadConfig() (*Config, error) {
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return &Config{
-			Targets:     make(map[string]TargetConfig),
-			NumReleases: DefaultNumReleases,
+			SchemaVersion: ConfigSchema.Current(),
+			Targets:       make(map[string]TargetConfig),
+			NumReleases:   DefaultNumReleases,
 		}, nil
 	}
 
@@ -353,6 +874,16 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	data, migrated, err := ConfigSchema.Migrate(configPath, data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if err := WriteFileAtomic(configPath, data); err != nil {
+			return nil, fmt.Errorf("failed to write migrated config file: %w", err)
+		}
+	}
+
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
@@ -366,6 +897,10 @@ func LoadConfig() (*Config, error) {
 		config.NumReleases = DefaultNumReleases
 	}
 
+	config.openEnv()
+
+	warnNewerConfig(config.Version)
+
 	return &config, nil
 }
 
@@ -377,18 +912,41 @@ func (c *Config) SaveConfig() error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	c.SchemaVersion = ConfigSchema.Current()
+	if CLIVersion != "" {
+		c.Version = CLIVersion
+	}
+
+	saved := *c
+	if c.EnvEncryption != nil {
+		targets, err := c.sealedTargets()
+		if err != nil {
+			return err
+		}
+		saved.Targets = targets
+	}
+
+	data, err := json.MarshalIndent(&saved, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, PermConfigFile); err != nil {
+	if err := WriteFileAtomic(configPath, data); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
 
+// KeepReleasesFor returns how many releases a deploy of target keeps: its
+// deploy.keep_releases, else the global keep_releases
+func (c *Config) KeepReleasesFor(target TargetConfig) int {
+	if target.Deploy != nil && target.Deploy.KeepReleases > 0 {
+		return target.Deploy.KeepReleases
+	}
+	return c.NumReleases
+}
+
 func (c *Config) GetTarget(targetName string) (TargetConfig, bool) {
 	target, exists := c.Targets[targetName]
 	return target, exists
@@ -276,6 +276,29 @@ func TestProviderConfigInterface(t *testing.T) {
 	}
 }
 
+func TestPublicAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   ProviderConfig
+		wantIPv4 string
+		wantIPv6 string
+	}{
+		{"IPv4 only", &DigitalOceanConfig{IP: "203.0.113.10"}, "203.0.113.10", ""},
+		{"dual stack", &HetznerConfig{IP: "203.0.113.10", IPv6: "2001:db8::1"}, "203.0.113.10", "2001:db8::1"},
+		{"IPv6 only", &VultrConfig{IP: "2001:db8::1", IPv6: "2001:db8::1"}, "", "2001:db8::1"},
+		{"IPv6 literal without recorded IPv6", &ServerConfig{IP: "2001:db8::2"}, "", "2001:db8::2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipv4, ipv6 := PublicAddresses(tt.config)
+			if ipv4 != tt.wantIPv4 || ipv6 != tt.wantIPv6 {
+				t.Errorf("PublicAddresses() = (%q, %q), want (%q, %q)", ipv4, ipv6, tt.wantIPv4, tt.wantIPv6)
+			}
+		})
+	}
+}
+
 func TestFindTargetByPath(t *testing.T) {
 	testHome, cleanup := setupTestConfigDir(t)
 	defer cleanup()
@@ -642,3 +665,80 @@ func TestDeploymentOptionsPersistence(t *testing.T) {
 		t.Error("Expected run command in config file")
 	}
 }
+
+func TestServerTargets(t *testing.T) {
+	target := TargetConfig{
+		Provider: "digitalocean",
+		ServerIP: "192.168.1.1",
+		Port:     3001,
+		Servers: []ServerConfig{
+			{IP: "192.168.1.2", ServerID: "222"},
+			{IP: "192.168.1.3", Username: "admin", SSHKey: "/keys/other"},
+		},
+	}
+	target.SetProviderConfig("digitalocean", &DigitalOceanConfig{
+		DropletID:   "111",
+		IP:          "192.168.1.1",
+		SSHKey:      "/keys/deploy",
+		Username:    "deploy",
+		Region:      "nyc1",
+		Provisioned: true,
+	})
+
+	serverTargets, err := target.ServerTargets()
+	if err != nil {
+		t.Fatalf("ServerTargets() error: %v", err)
+	}
+	if len(serverTargets) != 3 {
+		t.Fatalf("expected 3 server targets, got %d", len(serverTargets))
+	}
+
+	primary, _ := serverTargets[0].GetDigitalOceanConfig()
+	if primary.IP != "192.168.1.1" || primary.DropletID != "111" {
+		t.Errorf("primary server changed: %+v", primary)
+	}
+
+	second, _ := serverTargets[1].GetDigitalOceanConfig()
+	if second.IP != "192.168.1.2" || second.Username != "deploy" || second.SSHKey != "/keys/deploy" {
+		t.Errorf("extra server should inherit SSH settings, got %+v", second)
+	}
+	if second.DropletID != "" || second.Provisioned {
+		t.Errorf("extra server must not carry the primary's droplet, got %+v", second)
+	}
+	if second.Region != "nyc1" {
+		t.Errorf("expected region to be kept, got %q", second.Region)
+	}
+	if serverTargets[1].ServerIP != "192.168.1.2" || serverTargets[1].Port != 3001 {
+		t.Errorf("unexpected server target: ServerIP=%s Port=%d", serverTargets[1].ServerIP, serverTargets[1].Port)
+	}
+
+	third, _ := serverTargets[2].GetDigitalOceanConfig()
+	if third.Username != "admin" || third.SSHKey != "/keys/other" {
+		t.Errorf("extra server overrides not applied: %+v", third)
+	}
+
+	// The original target must not be modified
+	original, _ := target.GetDigitalOceanConfig()
+	if original.IP != "192.168.1.1" {
+		t.Errorf("ForServer modified the original target: %+v", original)
+	}
+}
+
+func TestSaveConfigStampsVersion(t *testing.T) {
+	_, cleanup := setupTestConfigDir(t)
+	defer cleanup()
+	defer func(v string) { CLIVersion = v }(CLIVersion)
+	CLIVersion = "1.4.0"
+
+	cfg := &Config{Targets: map[string]TargetConfig{}}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error: %v", err)
+	}
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if loaded.Version != "1.4.0" {
+		t.Errorf("Version = %q, want 1.4.0", loaded.Version)
+	}
+}
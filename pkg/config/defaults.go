@@ -10,12 +10,34 @@ const (
 	// DefaultCloudInitTimeout is the timeout for waiting for cloud-init to complete
 	DefaultCloudInitTimeout = 300 * time.Second // 5 minutes
 
+	// DefaultServerReadyTimeout is how long configure waits for cloud-init and
+	// then the apt/dpkg locks on a freshly provisioned server
+	DefaultServerReadyTimeout = 10 * time.Minute
+
+	// AptUnattendedUpgradeTypical is how long unattended-upgrades usually
+	// holds the apt locks on a fresh server
+	AptUnattendedUpgradeTypical = 15 * time.Minute
+
+	// AptStaleHolderAge is how long unattended-upgrades has to have run before
+	// --kill-stale-apt stops it
+	AptStaleHolderAge = 30 * time.Minute
+
 	// DefaultSSHTimeout is the default timeout for SSH connections
 	DefaultSSHTimeout = 30 * time.Second
 
+	// StatusDialTimeout is how long status waits for a server to accept a
+	// connection before reporting it unreachable
+	StatusDialTimeout = 5 * time.Second
+
 	// DefaultSSHConnectionTimeout is the timeout for establishing SSH connections
 	DefaultSSHConnectionTimeout = 3 * time.Minute
 
+	// DefaultSSHKeepAliveInterval is how often keepalive requests are sent on SSH connections
+	DefaultSSHKeepAliveInterval = 15 * time.Second
+
+	// DefaultSSHReconnectDelay is the base delay between SSH reconnect attempts
+	DefaultSSHReconnectDelay = 2 * time.Second
+
 	// DefaultTarballUploadTimeout is the timeout for uploading tarballs to remote servers
 	DefaultTarballUploadTimeout = 5 * time.Minute
 
@@ -37,6 +59,12 @@ const (
 	// DefaultAptMaxRetries is the maximum number of retries for APT operations
 	DefaultAptMaxRetries = 3
 
+	// DefaultSSHKeepAliveMaxMissed is how many unanswered keepalives close an SSH connection
+	DefaultSSHKeepAliveMaxMissed = 3
+
+	// DefaultSSHReconnectAttempts is how many times a dropped SSH connection is redialed
+	DefaultSSHReconnectAttempts = 4
+
 	// DefaultHealthCheckMaxRetries is the maximum number of health check retries
 	DefaultHealthCheckMaxRetries = 5
 )
@@ -78,6 +106,9 @@ const (
 
 	// LocalKeysDir is the directory name for SSH keys
 	LocalKeysDir = "keys"
+
+	// LocalLogsDir is the directory name for command reports (e.g. destroy)
+	LocalLogsDir = "logs"
 )
 
 // Path Constants - Remote Server
@@ -91,6 +122,10 @@ const (
 	// RemoteConfiguredMarker is the filename for the "configured" marker
 	RemoteConfiguredMarker = "configured"
 
+	// RemotePortsDir holds a reservation file per allocated port, named for
+	// the port and containing the app name
+	RemotePortsDir = RemoteLightfoldDir + "/ports"
+
 	// RemoteAppBaseDir is the base directory for deployed applications
 	RemoteAppBaseDir = "/srv"
 )
@@ -121,6 +156,10 @@ const (
 	// DefaultWorkerCount is the default number of workers for WSGI/ASGI servers
 	DefaultWorkerCount = 2
 
+	// WorkerMemoryMB is the memory budgeted per WSGI/ASGI worker when sizing the
+	// default worker count from the server's RAM
+	WorkerMemoryMB = 128
+
 	// DefaultDeployUser is the default system user for deployments
 	DefaultDeployUser = "deploy"
 )
@@ -143,6 +182,8 @@ var DefaultIgnorePatterns = []string{
 	"venv",
 	"env",
 	".env",
+	".env.*",
+	"secrets/*.json",
 	".DS_Store",
 	"Thumbs.db",
 }
@@ -38,5 +38,59 @@ func (t *TargetConfig) ProcessDeploymentOptions(envFile string, envVars []string
 	// Set skip build flag
 	t.Deploy.SkipBuild = skipBuild
 
+	// Reject bad jobs here so an invalid schedule never reaches the server
+	if err := ValidateJobs(t.Deploy.Jobs); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ProcessBuildOptions applies --build-arg KEY=VALUE flags over the target's
+// saved build args and --build-target over its build target, then checks
+// them against the target's builder
+func (t *TargetConfig) ProcessBuildOptions(buildArgs []string, buildTarget string) error {
+	if t.Deploy == nil {
+		t.Deploy = &DeploymentOptions{}
+	}
+
+	for _, buildArg := range buildArgs {
+		parts := util.SplitEnvVar(buildArg)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid build arg format '%s', expected KEY=VALUE", buildArg)
+		}
+		if t.Deploy.BuildArgs == nil {
+			t.Deploy.BuildArgs = make(map[string]string)
+		}
+		t.Deploy.BuildArgs[parts[0]] = parts[1]
+	}
+	if buildTarget != "" {
+		t.Deploy.BuildTarget = buildTarget
+	}
+
+	return ValidateBuildOptions(t.Builder, t.Deploy)
+}
+
+// ValidateBuildOptions rejects build args and targets the builder cannot
+// use: only dockerfile builds have stages, and the native builder runs the
+// framework's own commands without either
+func ValidateBuildOptions(builder string, options *DeploymentOptions) error {
+	if options == nil {
+		return nil
+	}
+	if builder == "" {
+		builder = "native"
+	}
+	for key := range options.BuildArgs {
+		if !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid build arg name '%s'", key)
+		}
+	}
+	if options.BuildTarget != "" && builder != "dockerfile" {
+		return fmt.Errorf("build target '%s' needs the dockerfile builder (target uses %s)", options.BuildTarget, builder)
+	}
+	if len(options.BuildArgs) > 0 && builder != "dockerfile" && builder != "nixpacks" {
+		return fmt.Errorf("build args need the dockerfile or nixpacks builder (target uses %s)", builder)
+	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -396,3 +397,101 @@ NO_QUOTES=plain_value`
 		t.Errorf("Expected %v, got %v", expected, target.Deploy.EnvVars)
 	}
 }
+
+func TestProxyHealthCheckHost(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name   string
+		target TargetConfig
+		want   string
+	}{
+		{"default without domain", TargetConfig{}, ""},
+		{"default with domain", TargetConfig{Domain: &DomainConfig{Domain: "example.com"}}, "example.com"},
+		{"enabled without domain", TargetConfig{Deploy: &DeploymentOptions{ProxyHealthCheck: &on}}, "203.0.113.10"},
+		{"disabled with domain", TargetConfig{Domain: &DomainConfig{Domain: "example.com"}, Deploy: &DeploymentOptions{ProxyHealthCheck: &off}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.ProxyHealthCheckHost("203.0.113.10"); got != tt.want {
+				t.Errorf("ProxyHealthCheckHost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessBuildOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      TargetConfig
+		buildArgs   []string
+		buildTarget string
+		wantArgs    map[string]string
+		wantTarget  string
+		wantErr     string
+	}{
+		{
+			name:        "dockerfile args and target",
+			config:      TargetConfig{Builder: "dockerfile"},
+			buildArgs:   []string{"COMMIT_SHA=abc123", "OPTS=a=b"},
+			buildTarget: "runtime",
+			wantArgs:    map[string]string{"COMMIT_SHA": "abc123", "OPTS": "a=b"},
+			wantTarget:  "runtime",
+		},
+		{
+			name:      "flags override saved args",
+			config:    TargetConfig{Builder: "nixpacks", Deploy: &DeploymentOptions{BuildArgs: map[string]string{"A": "1", "B": "2"}}},
+			buildArgs: []string{"B=3"},
+			wantArgs:  map[string]string{"A": "1", "B": "3"},
+		},
+		{
+			name:       "saved target kept without the flag",
+			config:     TargetConfig{Builder: "dockerfile", Deploy: &DeploymentOptions{BuildTarget: "runtime"}},
+			wantTarget: "runtime",
+		},
+		{
+			name:      "malformed arg",
+			config:    TargetConfig{Builder: "dockerfile"},
+			buildArgs: []string{"COMMIT_SHA"},
+			wantErr:   "expected KEY=VALUE",
+		},
+		{
+			name:      "invalid arg name",
+			config:    TargetConfig{Builder: "dockerfile"},
+			buildArgs: []string{"COMMIT-SHA=abc"},
+			wantErr:   "invalid build arg name",
+		},
+		{
+			name:        "target needs dockerfile",
+			config:      TargetConfig{Builder: "nixpacks"},
+			buildTarget: "runtime",
+			wantErr:     "needs the dockerfile builder (target uses nixpacks)",
+		},
+		{
+			name:      "args need dockerfile or nixpacks",
+			config:    TargetConfig{},
+			buildArgs: []string{"A=1"},
+			wantErr:   "(target uses native)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ProcessBuildOptions(tt.buildArgs, tt.buildTarget)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ProcessBuildOptions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessBuildOptions() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.config.Deploy.BuildArgs, tt.wantArgs) {
+				t.Errorf("BuildArgs = %v, want %v", tt.config.Deploy.BuildArgs, tt.wantArgs)
+			}
+			if tt.config.Deploy.BuildTarget != tt.wantTarget {
+				t.Errorf("BuildTarget = %q, want %q", tt.config.Deploy.BuildTarget, tt.wantTarget)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// EncryptedEnvPrefix marks an env value stored as ciphertext in config.json
+const EncryptedEnvPrefix = "lightfold:enc:v1:"
+
+// EnvKeyVar holds the base64 env encryption key where the configured key
+// source is unavailable, e.g. the keychain on a headless CI runner
+const EnvKeyVar = "LIGHTFOLD_ENV_KEY"
+
+// Env encryption key sources
+const (
+	EnvKeySourceKeychain = "keychain" // Random key kept in the OS keychain
+	EnvKeySourceSSH      = "ssh"      // Key derived from an SSH private key
+)
+
+// envKeySize is the AES-256 key length
+const envKeySize = 32
+
+// keychainService and keychainAccount name the keychain entry holding the key
+const (
+	keychainService = "lightfold"
+	keychainAccount = "env-encryption"
+)
+
+// EnvEncryptionConfig turns on encryption of the env values stored in config.json
+type EnvEncryptionConfig struct {
+	KeySource string `json:"key_source"`        // "keychain" or "ssh"
+	SSHKey    string `json:"ssh_key,omitempty"` // Private key the encryption key is derived from, for "ssh"
+}
+
+// Describe names where the key comes from, for messages
+func (e *EnvEncryptionConfig) Describe() string {
+	if e.KeySource == EnvKeySourceSSH {
+		return fmt.Sprintf("derived from %s", e.SSHKey)
+	}
+	return "OS keychain"
+}
+
+// sealedEnvValue is a decrypted env value and the ciphertext it came from,
+// kept so saving an unchanged value does not rewrite it
+type sealedEnvValue struct {
+	plain  string
+	cipher string
+}
+
+func sealedEnvKey(targetName, name string) string {
+	return targetName + "\x00" + name
+}
+
+// IsEncryptedEnvValue reports whether a stored env value is ciphertext
+func IsEncryptedEnvValue(value string) bool {
+	return strings.HasPrefix(value, EncryptedEnvPrefix)
+}
+
+// sealEnvValue encrypts value with AES-256-GCM. The variable name is
+// authenticated with it, so ciphertexts cannot be swapped between variables.
+func sealEnvValue(key []byte, name, value string) (string, error) {
+	aead, err := envAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return EncryptedEnvPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openEnvValue decrypts a value written by sealEnvValue
+func openEnvValue(key []byte, name, value string) (string, error) {
+	aead, err := envAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedEnvPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed ciphertext")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("wrong key or tampered value")
+	}
+	return string(plain), nil
+}
+
+func envAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// envKeyCache keeps resolved keys for the run, so loading the config again
+// does not ask the keychain again
+var envKeyCache = struct {
+	sync.Mutex
+	keys map[string][]byte
+}{keys: map[string][]byte{}}
+
+// resolveEnvKey returns the key for enc, falling back to EnvKeyVar when the
+// key source fails. With create set, a missing keychain key is generated.
+// A nil enc only accepts EnvKeyVar.
+func resolveEnvKey(enc *EnvEncryptionConfig, create bool) ([]byte, error) {
+	cacheKey := ""
+	if enc != nil {
+		cacheKey = enc.KeySource + "\x00" + enc.SSHKey
+	}
+	envKeyCache.Lock()
+	defer envKeyCache.Unlock()
+	if key, ok := envKeyCache.keys[cacheKey]; ok {
+		return key, nil
+	}
+
+	var key []byte
+	var err error
+	switch {
+	case enc == nil:
+		err = fmt.Errorf("env encryption is not configured")
+	case enc.KeySource == EnvKeySourceKeychain:
+		key, err = keychainEnvKey(create)
+	case enc.KeySource == EnvKeySourceSSH:
+		key, err = sshEnvKey(enc.SSHKey)
+	default:
+		err = fmt.Errorf("unknown env key source %q", enc.KeySource)
+	}
+	if err != nil {
+		if os.Getenv(EnvKeyVar) == "" {
+			return nil, fmt.Errorf("%w; set %s to the key printed by 'lightfold config encrypt-env --print-key' where this is unavailable", err, EnvKeyVar)
+		}
+		if key, err = envKeyFromEnvironment(); err != nil {
+			return nil, err
+		}
+	}
+
+	envKeyCache.keys[cacheKey] = key
+	return key, nil
+}
+
+// envKeyFromEnvironment decodes the key in EnvKeyVar
+func envKeyFromEnvironment() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv(EnvKeyVar)))
+	if err != nil || len(key) != envKeySize {
+		return nil, fmt.Errorf("%s must be a base64-encoded %d-byte key, as printed by 'lightfold config encrypt-env --print-key'", EnvKeyVar, envKeySize)
+	}
+	return key, nil
+}
+
+// sshEnvKey derives the key from an SSH private key. Passphrase-protected
+// keys cannot be read without a prompt, so they are refused.
+func sshEnvKey(keyPath string) ([]byte, error) {
+	if strings.HasPrefix(keyPath, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			keyPath = filepath.Join(home, keyPath[2:])
+		}
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key for env encryption: %w", err)
+	}
+	raw, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("SSH key %s is passphrase-protected; env encryption needs a key without a passphrase", keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", keyPath, err)
+	}
+	if k, ok := raw.(*ed25519.PrivateKey); ok {
+		raw = *k
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported SSH key %s: %w", keyPath, err)
+	}
+	return hkdf.Key(sha256.New, der, nil, "lightfold env encryption", envKeySize)
+}
+
+// errKeychainNoKey is returned by a keychain without the key
+var errKeychainNoKey = errors.New("no env encryption key in the OS keychain")
+
+// keyStore reads and writes the env encryption key in the OS keychain
+type keyStore interface {
+	Get() (string, error)
+	Set(value string) error
+}
+
+// envKeyStore is the keychain; tests replace it
+var envKeyStore keyStore = systemKeychain{}
+
+// keychainEnvKey reads the key from the keychain, generating and storing a
+// new one when create is set and there is none
+func keychainEnvKey(create bool) ([]byte, error) {
+	stored, err := envKeyStore.Get()
+	if errors.Is(err, errKeychainNoKey) && create {
+		key := make([]byte, envKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate env encryption key: %w", err)
+		}
+		if err := envKeyStore.Set(base64.StdEncoding.EncodeToString(key)); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stored))
+	if err != nil || len(key) != envKeySize {
+		return nil, fmt.Errorf("the env encryption key in the OS keychain is malformed")
+	}
+	return key, nil
+}
+
+// systemKeychain uses the macOS keychain through security(1) and the Secret
+// Service (GNOME Keyring, KWallet) through secret-tool(1)
+type systemKeychain struct{}
+
+func (systemKeychain) Get() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	case "linux", "freebsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount)
+	default:
+		return "", fmt.Errorf("the OS keychain is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if execErr := new(exec.Error); errors.As(err, &execErr) {
+		return "", fmt.Errorf("the OS keychain is unavailable: %s not found", execErr.Name)
+	}
+	if err != nil || strings.TrimSpace(string(out)) == "" {
+		// Both tools exit non-zero when the entry does not exist
+		return "", errKeychainNoKey
+	}
+	return string(out), nil
+}
+
+func (systemKeychain) Set(value string) error {
+	cmd, err := keychainSetCommand(runtime.GOOS, value)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store the env encryption key in the OS keychain: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keychainSetCommand stores value in the keychain of goos. The value goes in
+// on stdin, never on the command line where ps shows it: security(1) prompts
+// for the password, twice, when -w comes last without one.
+func keychainSetCommand(goos, value string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	switch goos {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", keychainAccount, "-w")
+		cmd.Stdin = strings.NewReader(value + "\n" + value + "\n")
+	case "linux", "freebsd":
+		cmd = exec.Command("secret-tool", "store", "--label=lightfold env encryption key", "service", keychainService, "account", keychainAccount)
+		cmd.Stdin = strings.NewReader(value)
+	default:
+		return nil, fmt.Errorf("the OS keychain is not supported on %s", goos)
+	}
+	return cmd, nil
+}
+
+// openEnv decrypts the stored env values in place. When a value cannot be
+// decrypted it stays ciphertext and EnvError says why, so commands that do
+// not need the values keep working.
+func (c *Config) openEnv() {
+	var key []byte
+	for targetName, target := range c.Targets {
+		if target.Deploy == nil {
+			continue
+		}
+		for name, value := range target.Deploy.EnvVars {
+			if !IsEncryptedEnvValue(value) {
+				continue
+			}
+			if key == nil {
+				var err error
+				if key, err = resolveEnvKey(c.EnvEncryption, false); err != nil {
+					c.envErr = fmt.Errorf("encrypted env values could not be decrypted: %w", err)
+					return
+				}
+			}
+			plain, err := openEnvValue(key, name, value)
+			if err != nil {
+				c.envErr = fmt.Errorf("env value %s of target '%s' could not be decrypted: %w", name, targetName, err)
+				continue
+			}
+			target.Deploy.EnvVars[name] = plain
+			if c.sealedEnv == nil {
+				c.sealedEnv = make(map[string]sealedEnvValue)
+			}
+			c.sealedEnv[sealedEnvKey(targetName, name)] = sealedEnvValue{plain: plain, cipher: value}
+		}
+	}
+}
+
+// sealedTargets returns a copy of the targets with every env value encrypted,
+// for writing to disk. The in-memory values are left as they are.
+func (c *Config) sealedTargets() (map[string]TargetConfig, error) {
+	var key []byte
+	targets := make(map[string]TargetConfig, len(c.Targets))
+	for targetName, target := range c.Targets {
+		if target.Deploy != nil && len(target.Deploy.EnvVars) > 0 {
+			deploy := *target.Deploy
+			deploy.EnvVars = make(map[string]string, len(target.Deploy.EnvVars))
+			for name, value := range target.Deploy.EnvVars {
+				if IsEncryptedEnvValue(value) {
+					deploy.EnvVars[name] = value
+					continue
+				}
+				if sealed, ok := c.sealedEnv[sealedEnvKey(targetName, name)]; ok && sealed.plain == value {
+					deploy.EnvVars[name] = sealed.cipher
+					continue
+				}
+				if key == nil {
+					var err error
+					if key, err = resolveEnvKey(c.EnvEncryption, false); err != nil {
+						return nil, fmt.Errorf("refusing to store env values unencrypted: %w", err)
+					}
+				}
+				sealed, err := sealEnvValue(key, name, value)
+				if err != nil {
+					return nil, err
+				}
+				deploy.EnvVars[name] = sealed
+			}
+			target.Deploy = &deploy
+		}
+		targets[targetName] = target
+	}
+	return targets, nil
+}
+
+// EnvError explains why some env values are still encrypted after loading,
+// or is nil when all of them were decrypted
+func (c *Config) EnvError() error {
+	return c.envErr
+}
+
+// CheckTargetEnv returns an error when a target's env values could not be
+// decrypted, for commands about to send them to a server
+func (c *Config) CheckTargetEnv(targetName string) error {
+	target, ok := c.Targets[targetName]
+	if !ok || target.Deploy == nil {
+		return nil
+	}
+	for _, value := range target.Deploy.EnvVars {
+		if IsEncryptedEnvValue(value) {
+			if c.envErr != nil {
+				return c.envErr
+			}
+			return fmt.Errorf("env values of target '%s' are encrypted and could not be decrypted", targetName)
+		}
+	}
+	return nil
+}
+
+// SetEnvEncryption switches how env values are stored, resolving (and for
+// the keychain, creating) the key. nil stores them as plaintext again. Every
+// value must have been decrypted first, since they are re-encrypted on save.
+func (c *Config) SetEnvEncryption(enc *EnvEncryptionConfig) error {
+	if c.envErr != nil {
+		return c.envErr
+	}
+	if enc != nil {
+		if _, err := resolveEnvKey(enc, true); err != nil {
+			return err
+		}
+	}
+	c.EnvEncryption = enc
+	c.sealedEnv = nil
+	return nil
+}
+
+// ExportEnvKey returns the base64 key for enc, the value EnvKeyVar takes
+func ExportEnvKey(enc *EnvEncryptionConfig) (string, error) {
+	key, err := resolveEnvKey(enc, false)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// memoryKeychain stands in for the OS keychain
+type memoryKeychain struct {
+	value string
+}
+
+func (k *memoryKeychain) Get() (string, error) {
+	if k.value == "" {
+		return "", errKeychainNoKey
+	}
+	return k.value, nil
+}
+
+func (k *memoryKeychain) Set(value string) error {
+	k.value = value
+	return nil
+}
+
+func resetEnvKeyCache() {
+	envKeyCache.Lock()
+	envKeyCache.keys = map[string][]byte{}
+	envKeyCache.Unlock()
+}
+
+func useMemoryKeychain(t *testing.T) *memoryKeychain {
+	keychain := &memoryKeychain{}
+	original := envKeyStore
+	envKeyStore = keychain
+	resetEnvKeyCache()
+	t.Cleanup(func() {
+		envKeyStore = original
+		resetEnvKeyCache()
+	})
+	return keychain
+}
+
+func newEnvConfig() *Config {
+	return &Config{
+		NumReleases: DefaultNumReleases,
+		Targets: map[string]TargetConfig{
+			"shop": {Provider: "byos", Deploy: &DeploymentOptions{EnvVars: map[string]string{"DB_PASSWORD": "hunter2", "NODE_ENV": "production"}}},
+		},
+	}
+}
+
+func readConfigFile(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEnvEncryptionRoundTrip(t *testing.T) {
+	_, cleanup := setupTestConfigDir(t)
+	defer cleanup()
+	useMemoryKeychain(t)
+
+	cfg := newEnvConfig()
+	if err := cfg.SetEnvEncryption(&EnvEncryptionConfig{KeySource: EnvKeySourceKeychain}); err != nil {
+		t.Fatalf("SetEnvEncryption() error: %v", err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig() error: %v", err)
+	}
+	if cfg.Targets["shop"].Deploy.EnvVars["DB_PASSWORD"] != "hunter2" {
+		t.Error("SaveConfig() changed the in-memory value")
+	}
+
+	raw := readConfigFile(t)
+	if strings.Contains(raw, "hunter2") || strings.Contains(raw, "production") {
+		t.Errorf("config.json holds plaintext env values:\n%s", raw)
+	}
+	if strings.Count(raw, EncryptedEnvPrefix) != 2 {
+		t.Errorf("config.json should hold 2 encrypted values:\n%s", raw)
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if got := loaded.Targets["shop"].Deploy.EnvVars["DB_PASSWORD"]; got != "hunter2" {
+		t.Errorf("DB_PASSWORD = %q, want it decrypted", got)
+	}
+	if loaded.EnvError() != nil || loaded.CheckTargetEnv("shop") != nil {
+		t.Errorf("EnvError() = %v, want nil", loaded.EnvError())
+	}
+
+	// Unchanged values keep their ciphertext
+	if err := loaded.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if again := readConfigFile(t); again != raw {
+		t.Error("saving unchanged values rewrote their ciphertext")
+	}
+}
+
+func TestEnvEncryptionMigrateAndDisable(t *testing.T) {
+	_, cleanup := setupTestConfigDir(t)
+	defer cleanup()
+	useMemoryKeychain(t)
+
+	if err := newEnvConfig().SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(readConfigFile(t), "hunter2") {
+		t.Fatal("config without encryption should store plaintext")
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetEnvEncryption(&EnvEncryptionConfig{KeySource: EnvKeySourceKeychain}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(readConfigFile(t), "hunter2") {
+		t.Error("migrated config still holds plaintext")
+	}
+
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetEnvEncryption(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	raw := readConfigFile(t)
+	if !strings.Contains(raw, "hunter2") || strings.Contains(raw, EncryptedEnvPrefix) {
+		t.Errorf("disabling encryption should store plaintext again:\n%s", raw)
+	}
+}
+
+func TestEnvEncryptionKeyUnavailable(t *testing.T) {
+	_, cleanup := setupTestConfigDir(t)
+	defer cleanup()
+	keychain := useMemoryKeychain(t)
+
+	cfg := newEnvConfig()
+	enc := &EnvEncryptionConfig{KeySource: EnvKeySourceKeychain}
+	if err := cfg.SetEnvEncryption(enc); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ExportEnvKey(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SaveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	raw := readConfigFile(t)
+
+	// A CI runner without the keychain entry
+	keychain.value = ""
+	resetEnvKeyCache()
+	t.Setenv(EnvKeyVar, "")
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() without the key error: %v", err)
+	}
+	if !IsEncryptedEnvValue(loaded.Targets["shop"].Deploy.EnvVars["DB_PASSWORD"]) {
+		t.Error("values that cannot be decrypted should stay encrypted")
+	}
+	if err := loaded.CheckTargetEnv("shop"); err == nil || !strings.Contains(err.Error(), EnvKeyVar) {
+		t.Errorf("CheckTargetEnv() = %v, want an error naming %s", err, EnvKeyVar)
+	}
+	if err := loaded.SaveConfig(); err != nil || readConfigFile(t) != raw {
+		t.Errorf("saving without the key should keep the ciphertext, error: %v", err)
+	}
+	loaded.Targets["shop"].Deploy.EnvVars["API_KEY"] = "new"
+	if err := loaded.SaveConfig(); err == nil {
+		t.Error("SaveConfig() should refuse to store a new value unencrypted")
+	}
+
+	t.Setenv(EnvKeyVar, "not-a-key")
+	resetEnvKeyCache()
+	if loaded, _ := LoadConfig(); loaded.EnvError() == nil || !strings.Contains(loaded.EnvError().Error(), "base64") {
+		t.Errorf("EnvError() with a malformed %s = %v", EnvKeyVar, loaded.EnvError())
+	}
+
+	t.Setenv(EnvKeyVar, exported)
+	resetEnvKeyCache()
+	loaded, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Targets["shop"].Deploy.EnvVars["DB_PASSWORD"]; got != "hunter2" {
+		t.Errorf("DB_PASSWORD with %s = %q, want hunter2", EnvKeyVar, got)
+	}
+}
+
+func TestEnvValueBoundToName(t *testing.T) {
+	key := make([]byte, envKeySize)
+	sealed, err := sealEnvValue(key, "DB_PASSWORD", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := openEnvValue(key, "DB_PASSWORD", sealed); err != nil || plain != "hunter2" {
+		t.Errorf("openEnvValue() = %q, %v, want hunter2", plain, err)
+	}
+	if _, err := openEnvValue(key, "API_KEY", sealed); err == nil {
+		t.Error("a value moved to another variable should not decrypt")
+	}
+}
+
+func TestSSHEnvKey(t *testing.T) {
+	dir := t.TempDir()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := sshEnvKey(keyPath)
+	if err != nil {
+		t.Fatalf("sshEnvKey() error: %v", err)
+	}
+	second, _ := sshEnvKey(keyPath)
+	if len(first) != envKeySize || string(first) != string(second) {
+		t.Errorf("sshEnvKey() should derive the same %d-byte key each time", envKeySize)
+	}
+
+	block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := filepath.Join(dir, "id_protected")
+	if err := os.WriteFile(protected, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sshEnvKey(protected); err == nil || !strings.Contains(err.Error(), "passphrase") {
+		t.Errorf("sshEnvKey() for a protected key error = %v, want a passphrase error", err)
+	}
+}
+
+func TestKeychainSetCommandKeepsKeyOffCommandLine(t *testing.T) {
+	const key = "c2VjcmV0LWtleS1ieXRlcy1mb3ItdGhlLXRlc3QtMDEyMzQ1Ng=="
+	for _, goos := range []string{"darwin", "linux"} {
+		t.Run(goos, func(t *testing.T) {
+			cmd, err := keychainSetCommand(goos, key)
+			if err != nil {
+				t.Fatalf("keychainSetCommand() error: %v", err)
+			}
+			for _, arg := range cmd.Args {
+				if strings.Contains(arg, key) {
+					t.Errorf("key is on the command line: %v", cmd.Args)
+				}
+			}
+			stdin, err := io.ReadAll(cmd.Stdin)
+			if err != nil || !strings.HasPrefix(string(stdin), key) {
+				t.Errorf("stdin = %q, want the key", stdin)
+			}
+		})
+	}
+
+	if _, err := keychainSetCommand("windows", key); err == nil {
+		t.Error("expected an error for an unsupported OS")
+	}
+}
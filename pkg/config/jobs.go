@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultJobTimezone is the zone job schedules are read in when none is set
+const DefaultJobTimezone = "UTC"
+
+var jobNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,38}[a-z0-9])?$`)
+
+// Job is a command run on the app's server on a cron schedule, in the current
+// release directory as the deploy user with the app's environment loaded
+type Job struct {
+	Name     string `json:"name"`               // Part of the unit names, e.g. cleanup -> myapp-cleanup.timer
+	Schedule string `json:"schedule"`           // Five-field cron expression
+	Command  string `json:"command"`            // Shell command, e.g. node scripts/cleanup.js
+	Timezone string `json:"timezone,omitempty"` // IANA zone the schedule is read in; defaults to UTC
+}
+
+// Zone returns the job's time zone, UTC when unset
+func (j Job) Zone() string {
+	if j.Timezone == "" {
+		return DefaultJobTimezone
+	}
+	return j.Timezone
+}
+
+// OnCalendar returns the job's schedule as a systemd OnCalendar value
+func (j Job) OnCalendar() (string, error) {
+	cron, err := ParseCron(j.Schedule)
+	if err != nil {
+		return "", err
+	}
+	return cron.OnCalendar(j.Zone())
+}
+
+// Validate checks the name, command, time zone and that the schedule is a
+// cron expression the server's timers can run
+func (j Job) Validate() error {
+	if !jobNamePattern.MatchString(j.Name) {
+		return fmt.Errorf("invalid job name %q: use up to 40 lowercase letters, digits and hyphens", j.Name)
+	}
+	if strings.TrimSpace(j.Command) == "" {
+		return fmt.Errorf("job %s: command is required", j.Name)
+	}
+	if strings.ContainsAny(j.Command, "\r\n") {
+		return fmt.Errorf("job %s: command must be a single line", j.Name)
+	}
+	if _, err := LoadTimezone(j.Zone()); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+	if _, err := j.OnCalendar(); err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+	return nil
+}
+
+// ValidateJobs checks every job and that no two share a name
+func ValidateJobs(jobs []Job) error {
+	seen := map[string]bool{}
+	for _, job := range jobs {
+		if err := job.Validate(); err != nil {
+			return err
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("job %s is defined more than once", job.Name)
+		}
+		seen[job.Name] = true
+	}
+	return nil
+}
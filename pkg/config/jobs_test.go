@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJobValidate(t *testing.T) {
+	valid := []Job{
+		{Name: "cleanup", Schedule: "0 3 * * *", Command: "node scripts/cleanup.js"},
+		{Name: "weekly-report", Schedule: "30 6 * * mon", Command: "python manage.py report", Timezone: "Europe/Berlin"},
+		{Name: "q1", Schedule: "*/15 * * * *", Command: "echo $DATABASE_URL"},
+	}
+	for _, job := range valid {
+		if err := job.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", job, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		job  Job
+		want string
+	}{
+		{"uppercase name", Job{Name: "Cleanup", Schedule: "0 3 * * *", Command: "x"}, "invalid job name"},
+		{"name with underscore", Job{Name: "clean_up", Schedule: "0 3 * * *", Command: "x"}, "invalid job name"},
+		{"name too long", Job{Name: strings.Repeat("a", 41), Schedule: "0 3 * * *", Command: "x"}, "invalid job name"},
+		{"no command", Job{Name: "cleanup", Schedule: "0 3 * * *", Command: "  "}, "command is required"},
+		{"multi-line command", Job{Name: "cleanup", Schedule: "0 3 * * *", Command: "a\nb"}, "single line"},
+		{"too few fields", Job{Name: "cleanup", Schedule: "0 3 * *", Command: "x"}, "want 5 fields"},
+		{"out of range", Job{Name: "cleanup", Schedule: "0 24 * * *", Command: "x"}, "invalid cron expression"},
+		{"not cron", Job{Name: "cleanup", Schedule: "@daily", Command: "x"}, "invalid cron expression"},
+		{"day and weekday", Job{Name: "cleanup", Schedule: "0 3 1 * mon", Command: "x"}, "not supported on the server"},
+		{"offset time zone", Job{Name: "cleanup", Schedule: "0 3 * * *", Command: "x", Timezone: "+02:00"}, "invalid time zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.job.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJobsDuplicateNames(t *testing.T) {
+	jobs := []Job{
+		{Name: "cleanup", Schedule: "0 3 * * *", Command: "x"},
+		{Name: "cleanup", Schedule: "0 4 * * *", Command: "y"},
+	}
+	if err := ValidateJobs(jobs); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("ValidateJobs() = %v, want a duplicate name error", err)
+	}
+	if err := ValidateJobs(nil); err != nil {
+		t.Errorf("ValidateJobs(nil) = %v", err)
+	}
+}
+
+func TestJobOnCalendar(t *testing.T) {
+	job := Job{Name: "cleanup", Schedule: "0 3 * * *", Command: "x"}
+	if got, err := job.OnCalendar(); err != nil || got != "*-*-* 03:00:00 UTC" {
+		t.Errorf("OnCalendar() = %q, %v", got, err)
+	}
+	job.Timezone = "Europe/Berlin"
+	job.Schedule = "30 6 * * mon-fri"
+	if got, err := job.OnCalendar(); err != nil || got != "Mon,Tue,Wed,Thu,Fri *-*-* 06:30:00 Europe/Berlin" {
+		t.Errorf("OnCalendar() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxLabels is how many labels a target can carry; Linode allows 50 tags per
+// resource and the others more
+const MaxLabels = 20
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidateLabel checks a label. Keys use letters, digits, '-', '_' and '.' and
+// start and end with a letter or digit, so they fit every provider; values
+// are up to 63 printable characters and are adjusted per provider.
+func ValidateLabel(key, value string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q: use up to 63 letters, digits, '-', '_' and '.', starting and ending with a letter or digit", key)
+	}
+	if len(value) > 63 {
+		return fmt.Errorf("label %s: value is longer than 63 characters", key)
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("label %s: value contains a non-printable character", key)
+		}
+	}
+	return nil
+}
+
+// ValidateLabels checks every label and the label count
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("%d labels set, at most %d are allowed", len(labels), MaxLabels)
+	}
+	for key, value := range labels {
+		if err := ValidateLabel(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseLabels parses key=value arguments such as --label team=payments
+func ParseLabels(args []string) (map[string]string, error) {
+	labels := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", arg)
+		}
+		key = strings.TrimSpace(key)
+		if err := ValidateLabel(key, value); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// MatchLabels reports whether labels carry every key and value of selector
+func MatchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if got, ok := labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
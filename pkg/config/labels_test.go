@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateLabel(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{"team", "payments", false},
+		{"cost-center", "R&D 42", false},
+		{"app.tier", "", false},
+		{"", "x", true},
+		{"-team", "x", true},
+		{"team_", "x", true},
+		{"team name", "x", true},
+		{"team/name", "x", true},
+		{strings.Repeat("k", 64), "x", true},
+		{"team", strings.Repeat("v", 64), true},
+		{"team", "pay\nments", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateLabel(tt.key, tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateLabel(%q, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+		}
+	}
+
+	labels := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		labels[strings.Repeat("k", i+1)] = "v"
+	}
+	if err := ValidateLabels(labels); err == nil {
+		t.Errorf("ValidateLabels() with %d labels succeeded, want an error", len(labels))
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"team=payments", "env=", "url=a=b"})
+	if err != nil {
+		t.Fatalf("ParseLabels() error = %v", err)
+	}
+	if want := map[string]string{"team": "payments", "env": "", "url": "a=b"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("ParseLabels() = %v, want %v", labels, want)
+	}
+
+	for _, arg := range []string{"team", "=payments", "bad key=x"} {
+		if _, err := ParseLabels([]string{arg}); err == nil {
+			t.Errorf("ParseLabels(%q) succeeded, want an error", arg)
+		}
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"team": "payments", "env": "prod"}
+	tests := []struct {
+		selector map[string]string
+		want     bool
+	}{
+		{nil, true},
+		{map[string]string{"team": "payments"}, true},
+		{map[string]string{"team": "payments", "env": "prod"}, true},
+		{map[string]string{"team": "search"}, false},
+		{map[string]string{"owner": ""}, false},
+	}
+	for _, tt := range tests {
+		if got := MatchLabels(labels, tt.selector); got != tt.want {
+			t.Errorf("MatchLabels(%v) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"lightfold/pkg/util"
+	"strings"
+)
+
+// ConfigSchema is the migration history of config.json. Append new
+// migrations; never edit or reorder released ones.
+var ConfigSchema = Schema{
+	Name: "config",
+	Migrations: []Migration{
+		{Description: "unify num_releases into keep_releases", Apply: migrateReleaseRetention},
+		{Description: "normalize target ports, domains and deploy options", Apply: migrateTargetFields},
+		{Description: "normalize provider names and provider config keys", Apply: migrateProviderConfigKeys},
+		{Description: "pin the project directory app name of existing targets", Apply: migrateProjectAppNames},
+	},
+}
+
+// legacyProviderConfigKeys maps provider config keys of early releases to
+// the keys the provider configs read today
+var legacyProviderConfigKeys = map[string]string{
+	"ip_address":   "ip",
+	"ssh_key_path": "ssh_key",
+	"user":         "username",
+}
+
+// legacyDeployFields were set on the target before deploy options existed
+var legacyDeployFields = []string{"build_command", "run_command", "env_vars"}
+
+// migrateReleaseRetention folds the num_releases key of early releases into
+// keep_releases and fills in the default when neither is set
+func migrateReleaseRetention(doc map[string]interface{}) {
+	RenameField(doc, "num_releases", "keep_releases")
+	if count, ok := IntField(doc, "keep_releases"); !ok || count <= 0 {
+		doc["keep_releases"] = DefaultNumReleases
+	} else {
+		doc["keep_releases"] = count
+	}
+	if _, ok := ObjectField(doc, "targets"); !ok {
+		doc["targets"] = map[string]interface{}{}
+	}
+}
+
+// migrateTargetFields converts ports stored as strings, drops zero ports so
+// they read as unset, wraps plain-string domains and moves build and run
+// settings set on the target itself into its deploy options
+func migrateTargetFields(doc map[string]interface{}) {
+	targets, _ := ObjectField(doc, "targets")
+	for _, value := range targets {
+		target, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{"port", "container_port"} {
+			if _, set := target[key]; !set {
+				continue
+			}
+			if port, ok := IntField(target, key); ok && port > 0 {
+				target[key] = port
+			} else {
+				delete(target, key)
+			}
+		}
+
+		if domain, ok := target["domain"].(string); ok {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				target["domain"] = map[string]interface{}{"domain": domain}
+			} else {
+				delete(target, "domain")
+			}
+		}
+
+		for _, key := range legacyDeployFields {
+			value, set := target[key]
+			if !set {
+				continue
+			}
+			delete(target, key)
+			deploy, ok := ObjectField(target, "deploy")
+			if !ok {
+				deploy = map[string]interface{}{}
+				target["deploy"] = deploy
+			}
+			if _, exists := deploy[key]; !exists {
+				deploy[key] = value
+			}
+		}
+	}
+}
+
+// migrateProviderConfigKeys lowercases provider names, which early releases
+// stored as typed, and renames legacy provider config keys
+func migrateProviderConfigKeys(doc map[string]interface{}) {
+	targets, _ := ObjectField(doc, "targets")
+	for _, value := range targets {
+		target, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if provider, ok := target["provider"].(string); ok {
+			target["provider"] = strings.ToLower(strings.TrimSpace(provider))
+		}
+
+		providerConfigs, ok := ObjectField(target, "provider_config")
+		if !ok {
+			continue
+		}
+		normalized := map[string]interface{}{}
+		for name, raw := range providerConfigs {
+			key := strings.ToLower(strings.TrimSpace(name))
+			if _, exists := normalized[key]; exists && key != name {
+				continue
+			}
+			if providerConfig, ok := raw.(map[string]interface{}); ok {
+				for from, to := range legacyProviderConfigKeys {
+					RenameField(providerConfig, from, to)
+				}
+			}
+			normalized[key] = raw
+		}
+		target["provider_config"] = normalized
+	}
+}
+
+// migrateProjectAppNames keeps existing deployments where they are. The
+// server app name used to follow the project directory and now follows the
+// target name, so targets whose two names differ get the directory name saved
+// as app_name.
+func migrateProjectAppNames(doc map[string]interface{}) {
+	targets, _ := ObjectField(doc, "targets")
+	for name, value := range targets {
+		target, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if appName, _ := target["app_name"].(string); appName != "" {
+			continue
+		}
+		projectPath, _ := target["project_path"].(string)
+		if projectPath == "" {
+			continue
+		}
+		if appName := util.GetTargetName(projectPath); appName != util.AppNameFromTarget(name) {
+			target["app_name"] = appName
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// decodeFixture decodes a JSON fixture the way Schema.Migrate does
+func decodeFixture(t *testing.T, fixture string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(fixture)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	return doc
+}
+
+// assertMigration applies one migration to the old fixture and compares the
+// result with the expected one, as JSON
+func assertMigration(t *testing.T, migration Migration, old, want string) {
+	t.Helper()
+	doc := decodeFixture(t, old)
+	migration.Apply(doc)
+
+	got, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotDoc, wantDoc := decodeFixture(t, string(got)), decodeFixture(t, want); !reflect.DeepEqual(gotDoc, wantDoc) {
+		t.Errorf("%s:\ngot  %s\nwant %s", migration.Description, got, want)
+	}
+}
+
+func TestConfigMigrationReleaseRetention(t *testing.T) {
+	assertMigration(t, ConfigSchema.Migrations[0],
+		`{"targets": {"web": {"provider": "byos"}}, "num_releases": 5}`,
+		`{"targets": {"web": {"provider": "byos"}}, "keep_releases": 5}`)
+	assertMigration(t, ConfigSchema.Migrations[0],
+		`{"targets": null, "num_releases": 0}`,
+		`{"targets": {}, "keep_releases": 2}`)
+	assertMigration(t, ConfigSchema.Migrations[0],
+		`{"targets": {}, "num_releases": 9, "keep_releases": "3"}`,
+		`{"targets": {}, "keep_releases": 3}`)
+}
+
+func TestConfigMigrationTargetFields(t *testing.T) {
+	assertMigration(t, ConfigSchema.Migrations[1],
+		`{"targets": {
+			"web": {"port": "3001", "domain": "example.com", "build_command": "npm run build", "env_vars": {"A": "1"}},
+			"api": {"port": 0, "container_port": "8080", "domain": " ", "run_command": "./api", "deploy": {"run_command": "./server"}}
+		}}`,
+		`{"targets": {
+			"web": {"port": 3001, "domain": {"domain": "example.com"}, "deploy": {"build_command": "npm run build", "env_vars": {"A": "1"}}},
+			"api": {"container_port": 8080, "deploy": {"run_command": "./server"}}
+		}}`)
+}
+
+func TestConfigMigrationProviderConfigKeys(t *testing.T) {
+	assertMigration(t, ConfigSchema.Migrations[2],
+		`{"targets": {"web": {"provider": "DigitalOcean", "provider_config": {
+			"DigitalOcean": {"ip_address": "203.0.113.10", "ssh_key_path": "~/.ssh/id", "user": "deploy"}
+		}}, "box": {"provider": "byos", "provider_config": {
+			"byos": {"ip": "203.0.113.20", "user": "root", "username": "deploy"}
+		}}}}`,
+		`{"targets": {"web": {"provider": "digitalocean", "provider_config": {
+			"digitalocean": {"ip": "203.0.113.10", "ssh_key": "~/.ssh/id", "username": "deploy"}
+		}}, "box": {"provider": "byos", "provider_config": {
+			"byos": {"ip": "203.0.113.20", "username": "deploy"}
+		}}}}`)
+}
+
+func TestConfigMigrationProjectAppNames(t *testing.T) {
+	assertMigration(t, ConfigSchema.Migrations[3],
+		`{"targets": {
+			"api-prod": {"project_path": "/home/dev/api"},
+			"my_app": {"project_path": "/home/dev/my-app"},
+			"shop": {"project_path": "/home/dev/store", "app_name": "store_v1"},
+			"byos": {"provider": "byos"}
+		}}`,
+		`{"targets": {
+			"api-prod": {"project_path": "/home/dev/api", "app_name": "api"},
+			"my_app": {"project_path": "/home/dev/my-app"},
+			"shop": {"project_path": "/home/dev/store", "app_name": "store_v1"},
+			"byos": {"provider": "byos"}
+		}}`)
+}
+
+func TestSchemaMigrate(t *testing.T) {
+	old := []byte(`{"num_releases": 4, "targets": {"web": {"provider": "BYOS", "port": "3000", "provider_config": {"BYOS": {"ip_address": "203.0.113.10"}}}}}`)
+
+	data, migrated, err := ConfigSchema.Migrate("config.json", old)
+	if err != nil || !migrated {
+		t.Fatalf("Migrate() = %v, %v; want a migration", migrated, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	target := cfg.Targets["web"]
+	byos, err := target.GetBYOSConfig()
+	if cfg.SchemaVersion != ConfigSchema.Current() || cfg.NumReleases != 4 || target.Port != 3000 || err != nil || byos.IP != "203.0.113.10" {
+		t.Errorf("migrated config = %s", data)
+	}
+
+	if again, migrated, err := ConfigSchema.Migrate("config.json", data); err != nil || migrated || !bytes.Equal(again, data) {
+		t.Errorf("migrating a current file = %v, %v; want it unchanged", migrated, err)
+	}
+
+	var newer *NewerSchemaError
+	if _, _, err := ConfigSchema.Migrate("config.json", []byte(`{"schema_version": 99}`)); !errors.As(err, &newer) || newer.Version != 99 {
+		t.Errorf("Migrate() of a newer file = %v, want a NewerSchemaError", err)
+	}
+}
+
+func TestLoadConfigMigratesFile(t *testing.T) {
+	home, cleanup := setupTestConfigDir(t)
+	defer cleanup()
+
+	path := filepath.Join(home, LocalConfigDir, LocalConfigFile)
+	if err := os.MkdirAll(filepath.Dir(path), PermDirectory); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"num_releases": 3, "targets": {"web": {"provider": "byos", "domain": "example.com"}}}`), PermConfigFile); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.NumReleases != 3 || cfg.Targets["web"].Domain == nil || cfg.Targets["web"].Domain.Domain != "example.com" {
+		t.Errorf("LoadConfig() = %+v", cfg)
+	}
+
+	written, _ := os.ReadFile(path)
+	var onDisk map[string]interface{}
+	if err := json.Unmarshal(written, &onDisk); err != nil || onDisk[SchemaVersionKey] != float64(ConfigSchema.Current()) {
+		t.Errorf("migrated file on disk = %s", written)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"schema_version": 99, "targets": {}}`), PermConfigFile); err != nil {
+		t.Fatal(err)
+	}
+	var newer *NewerSchemaError
+	if _, err := LoadConfig(); !errors.As(err, &newer) {
+		t.Errorf("LoadConfig() of a newer file = %v, want a NewerSchemaError", err)
+	}
+}
@@ -0,0 +1,559 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ProjectFileName is the file in a project describing its environments
+const ProjectFileName = "lightfold.yml"
+
+// EnvironmentSpec is the settings of one environment in lightfold.yml. Empty
+// fields inherit from the file's defaults.
+type EnvironmentSpec struct {
+	Provider string
+	Region   string
+	Size     string
+	Domain   string
+	EnvFile  string // Relative to the project
+	Builder  string
+	Port     int
+	Env      map[string]string
+	Labels   map[string]string // Cost allocation labels for the target's cloud resources
+	Jobs     []Job             // Scheduled commands; nil when the spec sets none
+
+	// BuildArgs and BuildTarget are the build_args and build_target of the
+	// target's deploy options
+	BuildArgs   map[string]string
+	BuildTarget string
+
+	// Protected marks the target protected; nil when the spec does not say,
+	// so an environment can unset what the defaults set
+	Protected *bool
+}
+
+// ProjectFile is a parsed lightfold.yml:
+//
+//	defaults:
+//	  provider: hetzner
+//	  region: nbg1
+//	environments:
+//	  staging:
+//	    size: cx22
+//	    domain: ${GIT_BRANCH}.staging.example.com
+//	  production:
+//	    size: cx32
+//	    domain: example.com
+//	    labels:
+//	      team: payments
+//	    build_args:
+//	      SENTRY_RELEASE: ${GIT_BRANCH}
+//	    build_target: runtime
+//	    protected: true
+//	    jobs:
+//	      - name: cleanup
+//	        schedule: "0 3 * * *"
+//	        command: node scripts/cleanup.js
+type ProjectFile struct {
+	Defaults     EnvironmentSpec
+	Environments map[string]EnvironmentSpec
+}
+
+// ProjectFileError is an invalid value in lightfold.yml, with the YAML path to it
+type ProjectFileError struct {
+	Path    string // e.g. environments.staging.port
+	Message string
+}
+
+func (e *ProjectFileError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", ProjectFileName, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", ProjectFileName, e.Path, e.Message)
+}
+
+var (
+	environmentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	envKeyPattern          = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	variablePattern        = regexp.MustCompile(`\$\{([^}]*)\}`)
+)
+
+// environmentFields are the keys an environment or the defaults can set
+var environmentFields = []string{"provider", "region", "size", "domain", "env_file", "builder", "port", "env", "labels", "jobs", "build_args", "build_target", "protected"}
+
+// LoadProjectFile reads lightfold.yml from the project. It reports false
+// without an error when the project has none.
+func LoadProjectFile(projectPath string) (*ProjectFile, bool, error) {
+	data, err := os.ReadFile(filepath.Join(projectPath, ProjectFileName))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", ProjectFileName, err)
+	}
+	file, err := ParseProjectFile(data)
+	if err != nil {
+		return nil, true, err
+	}
+	return file, true, nil
+}
+
+// ParseProjectFile parses and validates lightfold.yml
+func ParseProjectFile(data []byte) (*ProjectFile, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, &ProjectFileError{Message: fmt.Sprintf("invalid YAML: %v", err)}
+	}
+
+	top, err := yamlMap(raw, "")
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ProjectFile{Environments: map[string]EnvironmentSpec{}}
+	for _, key := range sortedYAMLKeys(top) {
+		switch key {
+		case "defaults":
+			if file.Defaults, err = parseEnvironmentSpec(top[key], "defaults"); err != nil {
+				return nil, err
+			}
+		case "environments":
+			environments, err := yamlMap(top[key], "environments")
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range sortedYAMLKeys(environments) {
+				path := "environments." + name
+				if !environmentNamePattern.MatchString(name) {
+					return nil, &ProjectFileError{Path: path, Message: "environment names use lowercase letters, digits and hyphens"}
+				}
+				spec, err := parseEnvironmentSpec(environments[name], path)
+				if err != nil {
+					return nil, err
+				}
+				file.Environments[name] = spec
+			}
+		default:
+			return nil, &ProjectFileError{Path: key, Message: "unknown key (expected defaults or environments)"}
+		}
+	}
+
+	if len(file.Environments) == 0 {
+		return nil, &ProjectFileError{Path: "environments", Message: "at least one environment is required"}
+	}
+	return file, nil
+}
+
+func parseEnvironmentSpec(raw interface{}, path string) (EnvironmentSpec, error) {
+	var spec EnvironmentSpec
+	if raw == nil {
+		return spec, nil
+	}
+	fields, err := yamlMap(raw, path)
+	if err != nil {
+		return spec, err
+	}
+
+	for _, key := range sortedYAMLKeys(fields) {
+		fieldPath := path + "." + key
+		value := fields[key]
+		switch key {
+		case "provider", "region", "size", "domain", "env_file", "builder", "build_target":
+			s, ok := value.(string)
+			if !ok {
+				return spec, &ProjectFileError{Path: fieldPath, Message: "expected a string"}
+			}
+			switch key {
+			case "provider":
+				spec.Provider = s
+			case "region":
+				spec.Region = s
+			case "size":
+				spec.Size = s
+			case "domain":
+				spec.Domain = s
+			case "env_file":
+				spec.EnvFile = s
+			case "builder":
+				spec.Builder = s
+			case "build_target":
+				spec.BuildTarget = s
+			}
+		case "port":
+			port, ok := value.(int)
+			if !ok || port < 1 || port > 65535 {
+				return spec, &ProjectFileError{Path: fieldPath, Message: "expected a port between 1 and 65535"}
+			}
+			spec.Port = port
+		case "env", "build_args":
+			env, err := yamlMap(value, fieldPath)
+			if err != nil {
+				return spec, err
+			}
+			values := make(map[string]string, len(env))
+			for _, name := range sortedYAMLKeys(env) {
+				if !envKeyPattern.MatchString(name) {
+					return spec, &ProjectFileError{Path: fieldPath + "." + name, Message: "invalid environment variable name"}
+				}
+				s, err := yamlScalar(env[name], fieldPath+"."+name)
+				if err != nil {
+					return spec, err
+				}
+				values[name] = s
+			}
+			if key == "env" {
+				spec.Env = values
+			} else {
+				spec.BuildArgs = values
+			}
+		case "labels":
+			labels, err := yamlMap(value, fieldPath)
+			if err != nil {
+				return spec, err
+			}
+			spec.Labels = make(map[string]string, len(labels))
+			for _, name := range sortedYAMLKeys(labels) {
+				s, err := yamlScalar(labels[name], fieldPath+"."+name)
+				if err != nil {
+					return spec, err
+				}
+				if err := ValidateLabel(name, s); err != nil {
+					return spec, &ProjectFileError{Path: fieldPath + "." + name, Message: err.Error()}
+				}
+				spec.Labels[name] = s
+			}
+		case "jobs":
+			if spec.Jobs, err = parseJobs(value, fieldPath); err != nil {
+				return spec, err
+			}
+		case "protected":
+			protected, ok := value.(bool)
+			if !ok {
+				return spec, &ProjectFileError{Path: fieldPath, Message: "expected true or false"}
+			}
+			spec.Protected = &protected
+		default:
+			return spec, &ProjectFileError{Path: fieldPath, Message: fmt.Sprintf("unknown key (expected one of %s)", strings.Join(environmentFields, ", "))}
+		}
+	}
+	return spec, nil
+}
+
+// jobFields are the keys a job in lightfold.yml can set
+var jobFields = []string{"name", "schedule", "command", "timezone"}
+
+// parseJobs reads a list of jobs. An empty list is kept non-nil so an
+// environment can clear the jobs it inherits.
+func parseJobs(raw interface{}, path string) ([]Job, error) {
+	if raw == nil {
+		return []Job{}, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, &ProjectFileError{Path: path, Message: "expected a list of jobs"}
+	}
+
+	jobs := make([]Job, 0, len(items))
+	seen := map[string]bool{}
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		fields, err := yamlMap(item, itemPath)
+		if err != nil {
+			return nil, err
+		}
+		var job Job
+		for _, key := range sortedYAMLKeys(fields) {
+			s, ok := fields[key].(string)
+			if !ok {
+				return nil, &ProjectFileError{Path: itemPath + "." + key, Message: "expected a string"}
+			}
+			switch key {
+			case "name":
+				job.Name = s
+			case "schedule":
+				job.Schedule = s
+			case "command":
+				job.Command = s
+			case "timezone":
+				job.Timezone = s
+			default:
+				return nil, &ProjectFileError{Path: itemPath + "." + key, Message: fmt.Sprintf("unknown key (expected one of %s)", strings.Join(jobFields, ", "))}
+			}
+		}
+		if err := job.Validate(); err != nil {
+			return nil, &ProjectFileError{Path: itemPath, Message: err.Error()}
+		}
+		if seen[job.Name] {
+			return nil, &ProjectFileError{Path: itemPath + ".name", Message: fmt.Sprintf("job %s is defined more than once", job.Name)}
+		}
+		seen[job.Name] = true
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// yamlMap checks that a YAML value is a mapping with string keys
+func yamlMap(raw interface{}, path string) (map[string]interface{}, error) {
+	if raw == nil {
+		return map[string]interface{}{}, nil
+	}
+	m, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, &ProjectFileError{Path: path, Message: "expected a mapping"}
+	}
+	result := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		name, ok := key.(string)
+		if !ok {
+			return nil, &ProjectFileError{Path: path, Message: fmt.Sprintf("key %v is not a string", key)}
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// yamlScalar reads an env value; numbers and booleans are kept as written
+func yamlScalar(raw interface{}, path string) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", &ProjectFileError{Path: path, Message: "expected a string, number or boolean"}
+}
+
+func sortedYAMLKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvironmentNames returns the file's environments, sorted
+func (f *ProjectFile) EnvironmentNames() []string {
+	names := make([]string, 0, len(f.Environments))
+	for name := range f.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environment returns the named environment's settings over the defaults,
+// with ${VAR} references in the domain and env values replaced from vars
+func (f *ProjectFile) Environment(name string, vars map[string]string) (EnvironmentSpec, error) {
+	override, ok := f.Environments[name]
+	if !ok {
+		return EnvironmentSpec{}, &ProjectFileError{Path: "environments", Message: fmt.Sprintf("no environment %q (available: %s)", name, strings.Join(f.EnvironmentNames(), ", "))}
+	}
+
+	defaults, err := SubstituteEnvironment(f.Defaults, vars, "defaults")
+	if err != nil {
+		return EnvironmentSpec{}, err
+	}
+	override, err = SubstituteEnvironment(override, vars, "environments."+name)
+	if err != nil {
+		return EnvironmentSpec{}, err
+	}
+	return MergeEnvironment(defaults, override), nil
+}
+
+// MergeEnvironment layers override over base: fields override sets win, and
+// env variables, build args, labels and jobs are merged with override's
+// values winning
+func MergeEnvironment(base, override EnvironmentSpec) EnvironmentSpec {
+	merged := EnvironmentSpec{
+		Provider: firstNonEmpty(override.Provider, base.Provider),
+		Region:   firstNonEmpty(override.Region, base.Region),
+		Size:     firstNonEmpty(override.Size, base.Size),
+		Domain:   firstNonEmpty(override.Domain, base.Domain),
+		EnvFile:  firstNonEmpty(override.EnvFile, base.EnvFile),
+		Builder:  firstNonEmpty(override.Builder, base.Builder),
+		Port:     base.Port,
+
+		BuildTarget: firstNonEmpty(override.BuildTarget, base.BuildTarget),
+	}
+	if override.Port != 0 {
+		merged.Port = override.Port
+	}
+	merged.Protected = base.Protected
+	if override.Protected != nil {
+		merged.Protected = override.Protected
+	}
+
+	merged.Env = mergeMaps(base.Env, override.Env)
+	merged.Labels = mergeMaps(base.Labels, override.Labels)
+	merged.BuildArgs = mergeMaps(base.BuildArgs, override.BuildArgs)
+	merged.Jobs = mergeJobs(base.Jobs, override.Jobs)
+	return merged
+}
+
+// mergeJobs returns base's jobs with override's jobs of the same name in their
+// place and its other jobs after them. An empty, non-nil override clears them.
+func mergeJobs(base, override []Job) []Job {
+	if override == nil {
+		return base
+	}
+	if len(override) == 0 {
+		return override
+	}
+	byName := make(map[string]Job, len(override))
+	for _, job := range override {
+		byName[job.Name] = job
+	}
+	merged := make([]Job, 0, len(base)+len(override))
+	for _, job := range base {
+		if replacement, ok := byName[job.Name]; ok {
+			job = replacement
+			delete(byName, job.Name)
+		}
+		merged = append(merged, job)
+	}
+	for _, job := range override {
+		if _, ok := byName[job.Name]; ok {
+			merged = append(merged, job)
+		}
+	}
+	return merged
+}
+
+// mergeMaps returns a new map of base's entries with override's over them,
+// or nil when both are empty
+func mergeMaps(base, override map[string]string) map[string]string {
+	if len(base)+len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// SubstituteEnvironment replaces ${VAR} references in the domain, env values,
+// build arg values and label values of spec. path is the spec's YAML path, used in errors.
+func SubstituteEnvironment(spec EnvironmentSpec, vars map[string]string, path string) (EnvironmentSpec, error) {
+	var err error
+	if spec.Domain, err = SubstituteVariables(spec.Domain, vars, path+".domain"); err != nil {
+		return spec, err
+	}
+	if spec.Env != nil {
+		env := make(map[string]string, len(spec.Env))
+		for key, value := range spec.Env {
+			if env[key], err = SubstituteVariables(value, vars, path+".env."+key); err != nil {
+				return spec, err
+			}
+		}
+		spec.Env = env
+	}
+	if spec.BuildArgs != nil {
+		buildArgs := make(map[string]string, len(spec.BuildArgs))
+		for key, value := range spec.BuildArgs {
+			if buildArgs[key], err = SubstituteVariables(value, vars, path+".build_args."+key); err != nil {
+				return spec, err
+			}
+		}
+		spec.BuildArgs = buildArgs
+	}
+	if spec.Labels != nil {
+		labels := make(map[string]string, len(spec.Labels))
+		for key, value := range spec.Labels {
+			if labels[key], err = SubstituteVariables(value, vars, path+".labels."+key); err != nil {
+				return spec, err
+			}
+		}
+		spec.Labels = labels
+	}
+	return spec, nil
+}
+
+// SubstituteVariables replaces ${NAME} in s with vars[NAME]. Unknown names
+// are an error rather than an empty string, so a typo cannot produce a
+// domain like ".staging.example.com".
+func SubstituteVariables(s string, vars map[string]string, path string) (string, error) {
+	var unknown *string
+	result := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := vars[name]
+		if !ok && unknown == nil {
+			unknown = &name
+		}
+		return value
+	})
+	if unknown != nil {
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", &ProjectFileError{Path: path, Message: fmt.Sprintf("unknown variable ${%s} (available: %s)", *unknown, strings.Join(names, ", "))}
+	}
+	return result, nil
+}
+
+// FieldPath returns the YAML path a field of the named environment comes
+// from: the environment when it sets the field, otherwise the defaults
+func (f *ProjectFile) FieldPath(name, field string) string {
+	override := f.Environments[name]
+	set := false
+	switch field {
+	case "provider":
+		set = override.Provider != ""
+	case "region":
+		set = override.Region != ""
+	case "size":
+		set = override.Size != ""
+	case "domain":
+		set = override.Domain != ""
+	case "env_file":
+		set = override.EnvFile != ""
+	case "builder":
+		set = override.Builder != ""
+	case "port":
+		set = override.Port != 0
+	case "labels":
+		set = len(override.Labels) > 0
+	case "jobs":
+		set = override.Jobs != nil
+	case "build_target":
+		set = override.BuildTarget != ""
+	case "build_args":
+		set = len(override.BuildArgs) > 0
+	case "protected":
+		set = override.Protected != nil
+	default:
+		_, set = override.Env[strings.TrimPrefix(field, "env.")]
+	}
+	if set {
+		return "environments." + name + "." + field
+	}
+	return "defaults." + field
+}
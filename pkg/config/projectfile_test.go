@@ -0,0 +1,450 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleProjectFile = `
+defaults:
+  provider: hetzner
+  region: nbg1
+  size: cx22
+  builder: nixpacks
+  env:
+    LOG_LEVEL: info
+    APP_ENV: ${ENV_NAME}
+environments:
+  staging:
+    domain: ${GIT_BRANCH}.staging.example.com
+    env_file: .env.staging
+  production:
+    size: cx32
+    domain: example.com
+    port: 8080
+    labels:
+      team: payments
+    jobs:
+      - name: cleanup
+        schedule: "0 3 * * *"
+        command: node scripts/cleanup.js
+    env:
+      LOG_LEVEL: warn
+      WORKERS: 4
+      DEBUG: false
+`
+
+func TestParseProjectFile(t *testing.T) {
+	file, err := ParseProjectFile([]byte(sampleProjectFile))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+
+	wantDefaults := EnvironmentSpec{
+		Provider: "hetzner", Region: "nbg1", Size: "cx22", Builder: "nixpacks",
+		Env: map[string]string{"LOG_LEVEL": "info", "APP_ENV": "${ENV_NAME}"},
+	}
+	if !reflect.DeepEqual(file.Defaults, wantDefaults) {
+		t.Errorf("Defaults = %+v, want %+v", file.Defaults, wantDefaults)
+	}
+	wantProduction := EnvironmentSpec{
+		Size: "cx32", Domain: "example.com", Port: 8080,
+		Env:    map[string]string{"LOG_LEVEL": "warn", "WORKERS": "4", "DEBUG": "false"},
+		Labels: map[string]string{"team": "payments"},
+		Jobs:   []Job{{Name: "cleanup", Schedule: "0 3 * * *", Command: "node scripts/cleanup.js"}},
+	}
+	if !reflect.DeepEqual(file.Environments["production"], wantProduction) {
+		t.Errorf("production = %+v, want %+v", file.Environments["production"], wantProduction)
+	}
+	if got := file.EnvironmentNames(); !reflect.DeepEqual(got, []string{"production", "staging"}) {
+		t.Errorf("EnvironmentNames() = %v", got)
+	}
+}
+
+func TestParseProjectFileEmptyEnvironment(t *testing.T) {
+	file, err := ParseProjectFile([]byte("defaults:\n  provider: do\nenvironments:\n  preview:\n"))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+	if spec, ok := file.Environments["preview"]; !ok || !reflect.DeepEqual(spec, EnvironmentSpec{}) {
+		t.Errorf("preview = %+v, %v; want an empty environment", spec, ok)
+	}
+}
+
+func TestParseProjectFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		path string
+		msg  string
+	}{
+		{"invalid yaml", "environments: [", "", "invalid YAML"},
+		{"not a mapping", "- staging\n", "", "expected a mapping"},
+		{"unknown top-level key", "environment:\n  staging: {}\n", "environment", "unknown key"},
+		{"no environments", "defaults:\n  provider: do\n", "environments", "at least one environment"},
+		{"environments not a mapping", "environments: staging\n", "environments", "expected a mapping"},
+		{"bad environment name", "environments:\n  Staging_1: {}\n", "environments.Staging_1", "lowercase"},
+		{"environment not a mapping", "environments:\n  staging: cx22\n", "environments.staging", "expected a mapping"},
+		{"unknown field", "environments:\n  staging:\n    sise: cx22\n", "environments.staging.sise", "unknown key"},
+		{"unknown default field", "defaults:\n  zone: nbg1\nenvironments:\n  staging: {}\n", "defaults.zone", "unknown key"},
+		{"string field not a string", "environments:\n  staging:\n    size: [cx22]\n", "environments.staging.size", "expected a string"},
+		{"port not a number", "environments:\n  staging:\n    port: http\n", "environments.staging.port", "between 1 and 65535"},
+		{"port out of range", "environments:\n  staging:\n    port: 70000\n", "environments.staging.port", "between 1 and 65535"},
+		{"env not a mapping", "environments:\n  staging:\n    env: [A=1]\n", "environments.staging.env", "expected a mapping"},
+		{"bad env name", "environments:\n  staging:\n    env:\n      1BAD: x\n", "environments.staging.env.1BAD", "invalid environment variable name"},
+		{"env value not a scalar", "environments:\n  staging:\n    env:\n      HOSTS: [a, b]\n", "environments.staging.env.HOSTS", "expected a string, number or boolean"},
+		{"non-string key", "environments:\n  staging:\n    env:\n      1: x\n", "environments.staging.env", "is not a string"},
+		{"labels not a mapping", "environments:\n  staging:\n    labels: [team]\n", "environments.staging.labels", "expected a mapping"},
+		{"bad label key", "environments:\n  staging:\n    labels:\n      -team: x\n", "environments.staging.labels.-team", "invalid label key"},
+		{"jobs not a list", "environments:\n  staging:\n    jobs:\n      cleanup: x\n", "environments.staging.jobs", "expected a list of jobs"},
+		{"unknown job key", "environments:\n  staging:\n    jobs:\n      - name: cleanup\n        cron: x\n", "environments.staging.jobs[0].cron", "unknown key"},
+		{"invalid job schedule", "environments:\n  staging:\n    jobs:\n      - name: cleanup\n        schedule: 0 25 * * *\n        command: x\n", "environments.staging.jobs[0]", "invalid cron expression"},
+		{"build_args not a mapping", "environments:\n  staging:\n    build_args: [A=1]\n", "environments.staging.build_args", "expected a mapping"},
+		{"bad build arg name", "environments:\n  staging:\n    build_args:\n      COMMIT-SHA: x\n", "environments.staging.build_args.COMMIT-SHA", "invalid environment variable name"},
+		{"build_target not a string", "environments:\n  staging:\n    build_target: [runtime]\n", "environments.staging.build_target", "expected a string"},
+		{"duplicate job", "environments:\n  staging:\n    jobs:\n      - {name: a, schedule: '0 3 * * *', command: x}\n      - {name: a, schedule: '0 4 * * *', command: z}\n", "environments.staging.jobs[1].name", "more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseProjectFile([]byte(tt.yaml))
+			var fileErr *ProjectFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("ParseProjectFile() = %v, want a ProjectFileError", err)
+			}
+			if fileErr.Path != tt.path || !strings.Contains(fileErr.Message, tt.msg) {
+				t.Errorf("error = %q at %q, want %q at %q", fileErr.Message, fileErr.Path, tt.msg, tt.path)
+			}
+		})
+	}
+}
+
+func TestProjectFileErrorMessage(t *testing.T) {
+	err := &ProjectFileError{Path: "environments.staging.port", Message: "expected a port between 1 and 65535"}
+	if got, want := err.Error(), "lightfold.yml: environments.staging.port: expected a port between 1 and 65535"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	err = &ProjectFileError{Message: "invalid YAML"}
+	if got, want := err.Error(), "lightfold.yml: invalid YAML"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestMergeEnvironment(t *testing.T) {
+	base := EnvironmentSpec{
+		Provider: "hetzner", Region: "nbg1", Size: "cx22", Domain: "base.example.com",
+		EnvFile: ".env", Builder: "nixpacks", Port: 3000,
+		Env: map[string]string{"A": "base", "B": "base"},
+	}
+
+	tests := []struct {
+		name     string
+		base     EnvironmentSpec
+		override EnvironmentSpec
+		want     EnvironmentSpec
+	}{
+		{"empty override inherits everything", base, EnvironmentSpec{}, base},
+		{"empty base takes the override", EnvironmentSpec{}, base, base},
+		{"both empty", EnvironmentSpec{}, EnvironmentSpec{}, EnvironmentSpec{}},
+		{
+			"override wins field by field",
+			base,
+			EnvironmentSpec{Provider: "do", Region: "fra1", Size: "s-2vcpu-2gb", Domain: "example.com", EnvFile: ".env.prod", Builder: "dockerfile", Port: 8080},
+			EnvironmentSpec{Provider: "do", Region: "fra1", Size: "s-2vcpu-2gb", Domain: "example.com", EnvFile: ".env.prod", Builder: "dockerfile", Port: 8080, Env: map[string]string{"A": "base", "B": "base"}},
+		},
+		{
+			"labels merge with override values winning",
+			EnvironmentSpec{Labels: map[string]string{"team": "payments", "env": "base"}},
+			EnvironmentSpec{Labels: map[string]string{"env": "production"}},
+			EnvironmentSpec{Labels: map[string]string{"team": "payments", "env": "production"}},
+		},
+		{
+			"jobs merge by name with override jobs winning",
+			EnvironmentSpec{Jobs: []Job{{Name: "cleanup", Schedule: "0 3 * * *"}, {Name: "report", Schedule: "0 6 * * 1"}}},
+			EnvironmentSpec{Jobs: []Job{{Name: "digest", Schedule: "0 7 * * *"}, {Name: "cleanup", Schedule: "0 4 * * *"}}},
+			EnvironmentSpec{Jobs: []Job{{Name: "cleanup", Schedule: "0 4 * * *"}, {Name: "report", Schedule: "0 6 * * 1"}, {Name: "digest", Schedule: "0 7 * * *"}}},
+		},
+		{
+			"empty jobs list clears inherited jobs",
+			EnvironmentSpec{Jobs: []Job{{Name: "cleanup", Schedule: "0 3 * * *"}}},
+			EnvironmentSpec{Jobs: []Job{}},
+			EnvironmentSpec{Jobs: []Job{}},
+		},
+		{
+			"env maps merge with override values winning",
+			base,
+			EnvironmentSpec{Env: map[string]string{"B": "override", "C": "override"}},
+			EnvironmentSpec{Provider: "hetzner", Region: "nbg1", Size: "cx22", Domain: "base.example.com", EnvFile: ".env", Builder: "nixpacks", Port: 3000, Env: map[string]string{"A": "base", "B": "override", "C": "override"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeEnvironment(tt.base, tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeEnvironment() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeEnvironmentDoesNotModifyInputs(t *testing.T) {
+	base := EnvironmentSpec{Env: map[string]string{"A": "base"}}
+	override := EnvironmentSpec{Env: map[string]string{"A": "override"}}
+	merged := MergeEnvironment(base, override)
+	merged.Env["B"] = "new"
+
+	if !reflect.DeepEqual(base.Env, map[string]string{"A": "base"}) || !reflect.DeepEqual(override.Env, map[string]string{"A": "override"}) {
+		t.Errorf("inputs changed: base %v, override %v", base.Env, override.Env)
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	vars := map[string]string{"ENV_NAME": "staging", "GIT_BRANCH": "feature-login"}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"example.com", "example.com"},
+		{"${GIT_BRANCH}.staging.example.com", "feature-login.staging.example.com"},
+		{"${ENV_NAME}-${GIT_BRANCH}", "staging-feature-login"},
+		{"${ENV_NAME}${ENV_NAME}", "stagingstaging"},
+		{"$ENV_NAME and $$ stay", "$ENV_NAME and $$ stay"},
+		{"unterminated ${ENV_NAME", "unterminated ${ENV_NAME"},
+	}
+	for _, tt := range tests {
+		got, err := SubstituteVariables(tt.in, vars, "environments.staging.domain")
+		if err != nil || got != tt.want {
+			t.Errorf("SubstituteVariables(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSubstituteVariablesUnknown(t *testing.T) {
+	for _, in := range []string{"${BRANCH}.example.com", "${}", "${env_name}"} {
+		_, err := SubstituteVariables(in, map[string]string{"ENV_NAME": "staging", "GIT_BRANCH": "main"}, "environments.staging.domain")
+		var fileErr *ProjectFileError
+		if !errors.As(err, &fileErr) {
+			t.Fatalf("SubstituteVariables(%q) = %v, want a ProjectFileError", in, err)
+		}
+		if fileErr.Path != "environments.staging.domain" || !strings.Contains(fileErr.Message, "available: ENV_NAME, GIT_BRANCH") {
+			t.Errorf("SubstituteVariables(%q) error = %v", in, err)
+		}
+	}
+}
+
+func TestSubstituteEnvironment(t *testing.T) {
+	spec := EnvironmentSpec{
+		Provider: "${ENV_NAME}", // Only the domain and env values are substituted
+		Domain:   "${ENV_NAME}.example.com",
+		Env:      map[string]string{"APP_ENV": "${ENV_NAME}", "PLAIN": "x"},
+		Labels:   map[string]string{"env": "${ENV_NAME}"},
+	}
+	got, err := SubstituteEnvironment(spec, map[string]string{"ENV_NAME": "qa"}, "environments.qa")
+	if err != nil {
+		t.Fatalf("SubstituteEnvironment() error: %v", err)
+	}
+	want := EnvironmentSpec{
+		Provider: "${ENV_NAME}",
+		Domain:   "qa.example.com",
+		Env:      map[string]string{"APP_ENV": "qa", "PLAIN": "x"},
+		Labels:   map[string]string{"env": "qa"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SubstituteEnvironment() = %+v, want %+v", got, want)
+	}
+	if spec.Env["APP_ENV"] != "${ENV_NAME}" {
+		t.Errorf("SubstituteEnvironment() modified its input: %v", spec.Env)
+	}
+
+	_, err = SubstituteEnvironment(EnvironmentSpec{Env: map[string]string{"URL": "${HOST}"}}, nil, "defaults")
+	var fileErr *ProjectFileError
+	if !errors.As(err, &fileErr) || fileErr.Path != "defaults.env.URL" {
+		t.Errorf("SubstituteEnvironment() = %v, want an error at defaults.env.URL", err)
+	}
+}
+
+func TestProjectFileEnvironment(t *testing.T) {
+	file, err := ParseProjectFile([]byte(sampleProjectFile))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+	vars := map[string]string{"GIT_BRANCH": "main"}
+
+	staging, err := file.Environment("staging", withEnvName(vars, "staging"))
+	if err != nil {
+		t.Fatalf("Environment(staging) error: %v", err)
+	}
+	wantStaging := EnvironmentSpec{
+		Provider: "hetzner", Region: "nbg1", Size: "cx22", Builder: "nixpacks",
+		Domain: "main.staging.example.com", EnvFile: ".env.staging",
+		Env: map[string]string{"LOG_LEVEL": "info", "APP_ENV": "staging"},
+	}
+	if !reflect.DeepEqual(staging, wantStaging) {
+		t.Errorf("Environment(staging) = %+v, want %+v", staging, wantStaging)
+	}
+
+	production, err := file.Environment("production", withEnvName(vars, "production"))
+	if err != nil {
+		t.Fatalf("Environment(production) error: %v", err)
+	}
+	wantProduction := EnvironmentSpec{
+		Provider: "hetzner", Region: "nbg1", Size: "cx32", Builder: "nixpacks",
+		Domain: "example.com", Port: 8080,
+		Env:    map[string]string{"LOG_LEVEL": "warn", "APP_ENV": "production", "WORKERS": "4", "DEBUG": "false"},
+		Labels: map[string]string{"team": "payments"},
+		Jobs:   []Job{{Name: "cleanup", Schedule: "0 3 * * *", Command: "node scripts/cleanup.js"}},
+	}
+	if !reflect.DeepEqual(production, wantProduction) {
+		t.Errorf("Environment(production) = %+v, want %+v", production, wantProduction)
+	}
+}
+
+func withEnvName(vars map[string]string, name string) map[string]string {
+	result := map[string]string{"ENV_NAME": name}
+	for key, value := range vars {
+		result[key] = value
+	}
+	return result
+}
+
+func TestProjectFileEnvironmentBuildOptions(t *testing.T) {
+	file, err := ParseProjectFile([]byte(`
+defaults:
+  builder: dockerfile
+  build_target: runtime
+  build_args:
+    NODE_VERSION: 20
+    SENTRY_ENVIRONMENT: ${ENV_NAME}
+environments:
+  staging:
+    build_target: debug
+  production:
+    build_args:
+      NODE_VERSION: 22
+`))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+
+	staging, err := file.Environment("staging", map[string]string{"ENV_NAME": "staging"})
+	if err != nil {
+		t.Fatalf("Environment(staging) error: %v", err)
+	}
+	if staging.BuildTarget != "debug" || !reflect.DeepEqual(staging.BuildArgs, map[string]string{"NODE_VERSION": "20", "SENTRY_ENVIRONMENT": "staging"}) {
+		t.Errorf("Environment(staging) build target %q, args %v", staging.BuildTarget, staging.BuildArgs)
+	}
+
+	production, err := file.Environment("production", map[string]string{"ENV_NAME": "production"})
+	if err != nil {
+		t.Fatalf("Environment(production) error: %v", err)
+	}
+	if production.BuildTarget != "runtime" || !reflect.DeepEqual(production.BuildArgs, map[string]string{"NODE_VERSION": "22", "SENTRY_ENVIRONMENT": "production"}) {
+		t.Errorf("Environment(production) build target %q, args %v", production.BuildTarget, production.BuildArgs)
+	}
+
+	if got := file.FieldPath("staging", "build_target"); got != "environments.staging.build_target" {
+		t.Errorf("FieldPath(staging, build_target) = %q", got)
+	}
+	if got := file.FieldPath("staging", "build_args"); got != "defaults.build_args" {
+		t.Errorf("FieldPath(staging, build_args) = %q", got)
+	}
+}
+
+func TestProjectFileEnvironmentProtected(t *testing.T) {
+	file, err := ParseProjectFile([]byte(`
+defaults:
+  protected: true
+environments:
+  staging:
+    protected: false
+  production: {}
+`))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+
+	for name, want := range map[string]bool{"staging": false, "production": true} {
+		spec, err := file.Environment(name, nil)
+		if err != nil {
+			t.Fatalf("Environment(%s) error: %v", name, err)
+		}
+		if spec.Protected == nil || *spec.Protected != want {
+			t.Errorf("Environment(%s).Protected = %v, want %v", name, spec.Protected, want)
+		}
+	}
+	if got := file.FieldPath("staging", "protected"); got != "environments.staging.protected" {
+		t.Errorf("FieldPath(staging, protected) = %q", got)
+	}
+
+	if _, err := ParseProjectFile([]byte("environments:\n  production:\n    protected: yes please\n")); err == nil || !strings.Contains(err.Error(), "environments.production.protected: expected true or false") {
+		t.Errorf("ParseProjectFile() with a string protected = %v", err)
+	}
+}
+
+func TestProjectFileEnvironmentErrors(t *testing.T) {
+	file, err := ParseProjectFile([]byte(sampleProjectFile))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+
+	_, err = file.Environment("qa", map[string]string{"ENV_NAME": "qa", "GIT_BRANCH": "main"})
+	if err == nil || !strings.Contains(err.Error(), `no environment "qa" (available: production, staging)`) {
+		t.Errorf("Environment(qa) = %v, want the available environments listed", err)
+	}
+
+	// Outside a git checkout there is no GIT_BRANCH for staging's domain
+	_, err = file.Environment("staging", map[string]string{"ENV_NAME": "staging"})
+	var fileErr *ProjectFileError
+	if !errors.As(err, &fileErr) || fileErr.Path != "environments.staging.domain" {
+		t.Errorf("Environment(staging) = %v, want an error at environments.staging.domain", err)
+	}
+}
+
+func TestProjectFileFieldPath(t *testing.T) {
+	file, err := ParseProjectFile([]byte(sampleProjectFile))
+	if err != nil {
+		t.Fatalf("ParseProjectFile() error: %v", err)
+	}
+	tests := []struct {
+		env, field, want string
+	}{
+		{"production", "size", "environments.production.size"},
+		{"production", "port", "environments.production.port"},
+		{"production", "region", "defaults.region"},
+		{"production", "env.LOG_LEVEL", "environments.production.env.LOG_LEVEL"},
+		{"production", "env.APP_ENV", "defaults.env.APP_ENV"},
+		{"staging", "env_file", "environments.staging.env_file"},
+		{"staging", "builder", "defaults.builder"},
+		{"staging", "port", "defaults.port"},
+	}
+	for _, tt := range tests {
+		if got := file.FieldPath(tt.env, tt.field); got != tt.want {
+			t.Errorf("FieldPath(%s, %s) = %q, want %q", tt.env, tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestLoadProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	if _, found, err := LoadProjectFile(dir); found || err != nil {
+		t.Errorf("LoadProjectFile() without a file = %v, %v; want not found", found, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(sampleProjectFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, found, err := LoadProjectFile(dir)
+	if err != nil || !found || len(file.Environments) != 2 {
+		t.Errorf("LoadProjectFile() = %+v, %v, %v", file, found, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectFileName), []byte("environments: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := LoadProjectFile(dir); !found || err == nil {
+		t.Errorf("LoadProjectFile() with no environments = %v, %v; want found with an error", found, err)
+	}
+}
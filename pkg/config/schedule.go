@@ -0,0 +1,325 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule actions
+const (
+	ScheduleStop  = "stop"
+	ScheduleStart = "start"
+)
+
+// scheduleSearchDays bounds how far schedule lookups search for the previous
+// or next matching time, enough for yearly expressions
+const scheduleSearchDays = 366
+
+// PowerSchedule powers a target's servers off and back on at cron times in an
+// explicit IANA time zone, so DST changes and the machine running lightfold
+// do not shift them
+type PowerSchedule struct {
+	Stop     string `json:"stop,omitempty"`  // Cron expression of the times the servers power off
+	Start    string `json:"start,omitempty"` // Cron expression of the times the servers power back on
+	Timezone string `json:"timezone"`        // IANA zone name, e.g. Europe/Berlin
+}
+
+// Validate checks both cron expressions and the time zone
+func (s *PowerSchedule) Validate() error {
+	if s.Stop == "" && s.Start == "" {
+		return fmt.Errorf("schedule needs a stop or a start time")
+	}
+	if _, err := s.Location(); err != nil {
+		return err
+	}
+	for _, expr := range []string{s.Stop, s.Start} {
+		if expr == "" {
+			continue
+		}
+		if _, err := ParseCron(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Location loads the schedule's time zone. Offsets and "Local" are refused so
+// the schedule means the same thing on every machine.
+func (s *PowerSchedule) Location() (*time.Location, error) {
+	return LoadTimezone(s.Timezone)
+}
+
+// LoadTimezone loads an IANA time zone name, refusing offsets and "Local"
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" || !strings.Contains(name, "/") && name != "UTC" {
+		return nil, fmt.Errorf("invalid time zone %q: use an IANA zone name such as Europe/Berlin or UTC", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// LastEvent returns the latest stop or start time in (since, now]. The action
+// is "" when neither expression matched in that window. A start wins over a
+// stop at the same minute.
+func (s *PowerSchedule) LastEvent(since, now time.Time) (string, time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	action, at := "", time.Time{}
+	for _, event := range []struct{ action, expr string }{{ScheduleStop, s.Stop}, {ScheduleStart, s.Start}} {
+		if event.expr == "" {
+			continue
+		}
+		cron, err := ParseCron(event.expr)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		prev := cron.Prev(now.In(loc))
+		if prev.IsZero() || !prev.After(since) {
+			continue
+		}
+		if !prev.Before(at) {
+			action, at = event.action, prev
+		}
+	}
+	return action, at, nil
+}
+
+// NextEvent returns the first stop or start time after now
+func (s *PowerSchedule) NextEvent(now time.Time) (string, time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	action, at := "", time.Time{}
+	for _, event := range []struct{ action, expr string }{{ScheduleStop, s.Stop}, {ScheduleStart, s.Start}} {
+		if event.expr == "" {
+			continue
+		}
+		cron, err := ParseCron(event.expr)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		next := cron.Next(now.In(loc))
+		if next.IsZero() {
+			continue
+		}
+		if at.IsZero() || next.Before(at) {
+			action, at = event.action, next
+		}
+	}
+	return action, at, nil
+}
+
+// CronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week
+type CronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // Names for values starting at min, e.g. jan for 1
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// ParseCron parses a standard five-field cron expression. Fields take *,
+// values, ranges, steps and lists; months and weekdays also take three-letter
+// names, and 7 is Sunday like 0.
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields (minute hour day month weekday), got %d", expr, len(fields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &CronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     fields[2] == "*" || strings.HasPrefix(fields[2], "*/"),
+		anyWeekday: fields[4] == "*" || strings.HasPrefix(fields[4], "*/"),
+	}, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			step = n
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		default:
+			value, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			low, high = value, value
+			if step > 1 {
+				high = f.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q: want %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Matches reports whether the minute of t, in t's location, is in the schedule
+func (c *CronSchedule) Matches(t time.Time) bool {
+	return c.matchesDay(t) && c.hours&(1<<t.Hour()) != 0 && c.minutes&(1<<t.Minute()) != 0
+}
+
+// matchesDay applies cron's rule that a day matches either the day of month or
+// the weekday when both are restricted
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	if c.months&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	day := c.days&(1<<t.Day()) != 0
+	weekday := c.weekdays&(1<<int(t.Weekday())) != 0
+	if !c.anyDay && !c.anyWeekday {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Prev returns the latest matching minute at or before t, or the zero time
+// when there is none within a year
+func (c *CronSchedule) Prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	limit := t.AddDate(0, 0, -scheduleSearchDays)
+	for t.After(limit) {
+		if !c.matchesDay(t) {
+			// Jump to the last minute of the previous day
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if c.Matches(t) {
+			return t
+		}
+		t = t.Add(-time.Minute)
+	}
+	return time.Time{}
+}
+
+// Next returns the first matching minute after t, or the zero time when there
+// is none within a year
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(0, 0, scheduleSearchDays)
+	for t.Before(limit) {
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.Matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+var systemdWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// OnCalendar converts the schedule to a systemd OnCalendar value in the zone.
+// systemd requires both the day of month and the weekday to match, so
+// expressions restricting both are refused.
+func (c *CronSchedule) OnCalendar(zone string) (string, error) {
+	if !c.anyDay && !c.anyWeekday {
+		return "", fmt.Errorf("schedules restricting both the day of month and the weekday are not supported on the server")
+	}
+
+	list := func(set uint64, min, max int, format func(int) string) string {
+		all := true
+		values := []string{}
+		for v := min; v <= max; v++ {
+			if set&(1<<v) != 0 {
+				values = append(values, format(v))
+			} else {
+				all = false
+			}
+		}
+		if all {
+			return "*"
+		}
+		return strings.Join(values, ",")
+	}
+	number := func(v int) string { return fmt.Sprintf("%02d", v) }
+
+	calendar := fmt.Sprintf("*-%s-%s %s:%s:00",
+		list(c.months, 1, 12, number), list(c.days, 1, 31, number),
+		list(c.hours, 0, 23, number), list(c.minutes, 0, 59, number))
+	if weekdays := list(c.weekdays, 0, 6, func(v int) string { return systemdWeekdays[v] }); weekdays != "*" {
+		calendar = weekdays + " " + calendar
+	}
+	return calendar + " " + zone, nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{"0 20 * * 1-5", "*/15 8-18 * * mon-fri", "0 0 1,15 * *", "30 6 * jan-mar 0,7"}
+	for _, expr := range valid {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("ParseCron(%q) error = %v", expr, err)
+		}
+	}
+
+	invalid := []string{"", "0 20 * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "5-1 * * * *", "*/0 * * * *", "0 0 * * funday"}
+	for _, expr := range invalid {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"0 20 * * 1-5", time.Date(2024, 3, 15, 20, 0, 0, 0, berlin), true},  // Friday
+		{"0 20 * * 1-5", time.Date(2024, 3, 16, 20, 0, 0, 0, berlin), false}, // Saturday
+		{"0 20 * * 1-5", time.Date(2024, 3, 15, 20, 1, 0, 0, berlin), false},
+		{"*/15 * * * *", time.Date(2024, 3, 15, 9, 45, 0, 0, berlin), true},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, berlin), true}, // Sunday as 7
+		// Day of month or weekday when both are restricted
+		{"0 0 1 * mon", time.Date(2024, 3, 1, 0, 0, 0, 0, berlin), true},
+		{"0 0 1 * mon", time.Date(2024, 3, 4, 0, 0, 0, 0, berlin), true},
+		{"0 0 1 * mon", time.Date(2024, 3, 5, 0, 0, 0, 0, berlin), false},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cron.Matches(tt.at); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestPowerScheduleLastEvent(t *testing.T) {
+	schedule := &PowerSchedule{Stop: "0 20 * * 1-5", Start: "0 8 * * 1-5", Timezone: "Europe/Berlin"}
+	berlin, _ := schedule.Location()
+
+	// Friday 21:00 Berlin: the stop at 20:00 is due
+	now := time.Date(2024, 3, 15, 21, 0, 0, 0, berlin)
+	action, at, err := schedule.LastEvent(now.Add(-2*time.Hour), now)
+	if err != nil || action != ScheduleStop || !at.Equal(time.Date(2024, 3, 15, 20, 0, 0, 0, berlin)) {
+		t.Errorf("LastEvent() = %q, %s, %v; want stop at 20:00", action, at, err)
+	}
+
+	// Checked after the stop: nothing new
+	if action, _, _ := schedule.LastEvent(now.Add(-30*time.Minute), now); action != "" {
+		t.Errorf("LastEvent() = %q, want nothing due", action)
+	}
+
+	// Monday 09:00 after a weekend without runs: only the start counts
+	monday := time.Date(2024, 3, 18, 9, 0, 0, 0, berlin)
+	if action, _, _ := schedule.LastEvent(now, monday); action != ScheduleStart {
+		t.Errorf("LastEvent() = %q, want start", action)
+	}
+
+	// The zone, not the caller's offset, decides: 07:30 UTC is 08:30 in Berlin
+	// after the switch to summer time on 2024-03-31
+	utc := time.Date(2024, 4, 1, 6, 30, 0, 0, time.UTC)
+	action, at, _ = schedule.LastEvent(utc.Add(-time.Hour), utc)
+	if action != ScheduleStart || !at.Equal(time.Date(2024, 4, 1, 8, 0, 0, 0, berlin)) {
+		t.Errorf("LastEvent() across DST = %q, %s; want start at 08:00 CEST", action, at)
+	}
+}
+
+func TestPowerScheduleNextEvent(t *testing.T) {
+	schedule := &PowerSchedule{Stop: "0 20 * * 1-5", Start: "0 8 * * 1-5", Timezone: "America/New_York"}
+	ny, _ := schedule.Location()
+
+	action, at, err := schedule.NextEvent(time.Date(2024, 3, 16, 12, 0, 0, 0, ny)) // Saturday
+	if err != nil || action != ScheduleStart || !at.Equal(time.Date(2024, 3, 18, 8, 0, 0, 0, ny)) {
+		t.Errorf("NextEvent() = %q, %s, %v; want start Monday 08:00", action, at, err)
+	}
+}
+
+func TestPowerScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule PowerSchedule
+		wantErr  bool
+	}{
+		{"valid", PowerSchedule{Stop: "0 20 * * 1-5", Start: "0 8 * * 1-5", Timezone: "Europe/Berlin"}, false},
+		{"stop only", PowerSchedule{Stop: "0 20 * * *", Timezone: "UTC"}, false},
+		{"no times", PowerSchedule{Timezone: "UTC"}, true},
+		{"no zone", PowerSchedule{Stop: "0 20 * * *"}, true},
+		{"local zone", PowerSchedule{Stop: "0 20 * * *", Timezone: "Local"}, true},
+		{"offset", PowerSchedule{Stop: "0 20 * * *", Timezone: "+02:00"}, true},
+		{"unknown zone", PowerSchedule{Stop: "0 20 * * *", Timezone: "Mars/Olympus"}, true},
+		{"bad cron", PowerSchedule{Stop: "0 25 * * *", Timezone: "UTC"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronOnCalendar(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{"0 20 * * 1-5", "Mon,Tue,Wed,Thu,Fri *-*-* 20:00:00 Europe/Berlin", false},
+		{"30 18 * * *", "*-*-* 18:30:00 Europe/Berlin", false},
+		{"0,30 8 1,15 * *", "*-*-01,15 08:00,30:00 Europe/Berlin", false},
+		{"0 0 1 * mon", "", true},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := cron.OnCalendar("Europe/Berlin")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q.OnCalendar() = %q, %v; want %q", tt.expr, got, err, tt.want)
+		}
+	}
+}
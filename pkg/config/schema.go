@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SchemaVersionKey is the field every versioned lightfold file stores its
+// schema version in. Files written before versioning have none and are
+// version 0.
+const SchemaVersionKey = "schema_version"
+
+// Migration upgrades a decoded file by one schema version. It works on the
+// raw JSON document so it can read fields the current structs no longer have.
+type Migration struct {
+	Description string
+	Apply       func(doc map[string]interface{})
+}
+
+// Schema is the migration history of one kind of file. Migrations[i]
+// upgrades version i to i+1, so the current version is len(Migrations).
+type Schema struct {
+	Name       string
+	Migrations []Migration
+}
+
+// Current returns the schema version files are written with
+func (s Schema) Current() int {
+	return len(s.Migrations)
+}
+
+// NewerSchemaError is a file written by a lightfold with a newer schema than
+// this one understands. Loading it could silently drop settings on save.
+type NewerSchemaError struct {
+	Path      string
+	Name      string
+	Version   int
+	Supported int
+}
+
+func (e *NewerSchemaError) Error() string {
+	return fmt.Sprintf("%s was written with %s schema version %d, but this lightfold only understands up to version %d; run 'lightfold self-update' before using it",
+		e.Path, e.Name, e.Version, e.Supported)
+}
+
+// Migrate upgrades data step by step to the current schema version and
+// stamps it with that version. It returns data unchanged, and false, when
+// the file is already current or is not a JSON object.
+func (s Schema) Migrate(path string, data []byte) ([]byte, bool, error) {
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return data, false, nil
+	}
+
+	version, _ := intValue(doc[SchemaVersionKey])
+	if version > s.Current() {
+		return nil, false, &NewerSchemaError{Path: path, Name: s.Name, Version: version, Supported: s.Current()}
+	}
+	if version == s.Current() {
+		return data, false, nil
+	}
+
+	for _, migration := range s.Migrations[version:] {
+		migration.Apply(doc)
+	}
+	doc[SchemaVersionKey] = s.Current()
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode migrated %s: %w", s.Name, err)
+	}
+	return migrated, true, nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path, flushes it
+// to disk and renames it over path, so readers never see a partial file
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), PermConfigFile); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// intValue reads a JSON number, or a number written as a string by older
+// releases
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// IntField reads doc[key] as a number, accepting numbers stored as strings
+func IntField(doc map[string]interface{}, key string) (int, bool) {
+	return intValue(doc[key])
+}
+
+// ObjectField returns doc[key] when it is a JSON object
+func ObjectField(doc map[string]interface{}, key string) (map[string]interface{}, bool) {
+	obj, ok := doc[key].(map[string]interface{})
+	return obj, ok
+}
+
+// RenameField moves doc[from] to doc[to] unless to is already set
+func RenameField(doc map[string]interface{}, from, to string) {
+	value, ok := doc[from]
+	if !ok {
+		return
+	}
+	delete(doc, from)
+	if _, exists := doc[to]; !exists {
+		doc[to] = value
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// SSHHostSettings are the connection settings ssh_config gives a Host alias.
+// Fields the config does not set are empty.
+type SSHHostSettings struct {
+	HostName     string
+	User         string
+	Port         string
+	IdentityFile string
+	ProxyJump    string
+}
+
+// sshConfigPath returns the user's ssh_config; tests point it at a fixture
+var sshConfigPath = func() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".ssh", "config")
+}
+
+// ResolveSSHHost reads the settings for a Host alias from ~/.ssh/config,
+// following Include directives and Match host blocks. As with ssh, the first
+// value found for a keyword wins, and a HostName defaults to the alias.
+func ResolveSSHHost(alias string) (SSHHostSettings, error) {
+	settings := SSHHostSettings{HostName: alias}
+
+	path := sshConfigPath()
+	f, err := os.Open(path)
+	if err != nil {
+		return settings, fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return settings, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	get := func(key string) (string, error) {
+		value, err := cfg.Get(alias, key)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s for %s from %s: %w", key, alias, path, err)
+		}
+		return value, nil
+	}
+	for _, field := range []struct {
+		key  string
+		dest *string
+	}{
+		{"HostName", &settings.HostName},
+		{"User", &settings.User},
+		{"Port", &settings.Port},
+		{"IdentityFile", &settings.IdentityFile},
+		{"ProxyJump", &settings.ProxyJump},
+	} {
+		value, err := get(field.key)
+		if err != nil {
+			return settings, err
+		}
+		if value != "" {
+			*field.dest = value
+		}
+	}
+
+	settings.HostName = strings.ReplaceAll(settings.HostName, "%h", alias)
+	if strings.EqualFold(settings.ProxyJump, "none") {
+		settings.ProxyJump = ""
+	}
+	return settings, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSSHConfigFixture writes an ssh_config with an included file and
+// points sshConfigPath at it
+func writeSSHConfigFixture(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "conf.d")
+	if err := os.MkdirAll(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	included := `Host db-prod
+    HostName 10.0.2.15
+    User postgres
+    ProxyJump bastion
+`
+	if err := os.WriteFile(filepath.Join(confDir, "prod.conf"), []byte(included), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	main := `Include ` + filepath.Join(confDir, "*.conf") + `
+
+Host myserver-prod
+    HostName 203.0.113.10
+    User deploy
+    Port 2222
+    IdentityFile ~/.ssh/prod_ed25519
+    ProxyJump jump@bastion.example.com:2200
+
+Host bastion
+    HostName bastion.example.com
+    User jump
+
+Host *.internal
+    HostName %h.example.net
+
+Match host staging-*
+    User staging
+    Port 2200
+
+Host staging-web
+    HostName 198.51.100.7
+    User ignored
+    ProxyJump none
+
+Host *
+    IdentityFile ~/.ssh/id_default
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	original := sshConfigPath
+	sshConfigPath = func() string { return path }
+	t.Cleanup(func() { sshConfigPath = original })
+}
+
+func TestResolveSSHHost(t *testing.T) {
+	writeSSHConfigFixture(t)
+
+	tests := []struct {
+		alias string
+		want  SSHHostSettings
+	}{
+		{"myserver-prod", SSHHostSettings{HostName: "203.0.113.10", User: "deploy", Port: "2222", IdentityFile: "~/.ssh/prod_ed25519", ProxyJump: "jump@bastion.example.com:2200"}},
+		// From the included file
+		{"db-prod", SSHHostSettings{HostName: "10.0.2.15", User: "postgres", IdentityFile: "~/.ssh/id_default", ProxyJump: "bastion"}},
+		// The Match block comes first, so its User wins over the Host block's
+		{"staging-web", SSHHostSettings{HostName: "198.51.100.7", User: "staging", Port: "2200", IdentityFile: "~/.ssh/id_default"}},
+		{"api.internal", SSHHostSettings{HostName: "api.internal.example.net", IdentityFile: "~/.ssh/id_default"}},
+		// Unknown aliases connect to the alias itself
+		{"unknown", SSHHostSettings{HostName: "unknown", IdentityFile: "~/.ssh/id_default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got, err := ResolveSSHHost(tt.alias)
+			if err != nil {
+				t.Fatalf("ResolveSSHHost() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveSSHHost(%q) = %+v, want %+v", tt.alias, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSSHHostMissingConfig(t *testing.T) {
+	original := sshConfigPath
+	sshConfigPath = func() string { return filepath.Join(t.TempDir(), "config") }
+	defer func() { sshConfigPath = original }()
+
+	got, err := ResolveSSHHost("myserver")
+	if err == nil {
+		t.Error("ResolveSSHHost() without a config succeeded")
+	}
+	if got.HostName != "myserver" {
+		t.Errorf("HostName = %q, want the alias", got.HostName)
+	}
+}
+
+func TestBYOSConfigSSHHost(t *testing.T) {
+	writeSSHConfigFixture(t)
+
+	aliased := &BYOSConfig{SSHHost: "myserver-prod"}
+	if aliased.GetIP() != "203.0.113.10" || aliased.GetUsername() != "deploy" || aliased.GetSSHKey() != "~/.ssh/prod_ed25519" {
+		t.Errorf("alias config = %s %s %s", aliased.GetIP(), aliased.GetUsername(), aliased.GetSSHKey())
+	}
+	if aliased.GetSSHPort() != "2222" || aliased.GetProxyJump() != "jump@bastion.example.com:2200" {
+		t.Errorf("alias port/jump = %s %s", aliased.GetSSHPort(), aliased.GetProxyJump())
+	}
+
+	// Explicit fields win over the alias
+	explicit := &BYOSConfig{SSHHost: "myserver-prod", IP: "192.0.2.1", Username: "root", SSHKey: "~/.ssh/other"}
+	if explicit.GetIP() != "192.0.2.1" || explicit.GetUsername() != "root" || explicit.GetSSHKey() != "~/.ssh/other" {
+		t.Errorf("explicit config = %s %s %s", explicit.GetIP(), explicit.GetUsername(), explicit.GetSSHKey())
+	}
+
+	target := TargetConfig{Provider: "byos"}
+	if err := target.SetProviderConfig("byos", aliased); err != nil {
+		t.Fatal(err)
+	}
+	providerCfg, err := target.GetSSHProviderConfig()
+	if err != nil {
+		t.Fatalf("GetSSHProviderConfig() error: %v", err)
+	}
+	if hostCfg, ok := providerCfg.(SSHHostConfig); !ok || hostCfg.GetSSHHost() != "myserver-prod" {
+		t.Errorf("GetSSHProviderConfig() = %#v, want the alias kept", providerCfg)
+	}
+}
@@ -0,0 +1,124 @@
+package crash
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxPartialLine caps how much of an unterminated line is buffered for Record
+const maxPartialLine = 4096
+
+// capture tees one of os.Stdout and os.Stderr through the recorder
+type capture struct {
+	stream   **os.File
+	original *os.File
+	writer   *os.File
+	done     chan struct{}
+}
+
+var (
+	captureMu sync.Mutex
+	captures  []*capture
+)
+
+// CaptureOutput tees os.Stdout and os.Stderr through Record, so dumps hold
+// the last lines a command printed rather than only the progress recorded
+// explicitly. Streams attached to a terminal are left alone: prompts,
+// spinners and full-screen views need the terminal itself. Output still in
+// the tee is lost if the process exits before StopCapture.
+func CaptureOutput() {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	if captures != nil {
+		return
+	}
+	captures = []*capture{}
+	for _, stream := range []**os.File{&os.Stdout, &os.Stderr} {
+		if isCharDevice(*stream) {
+			continue
+		}
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			continue
+		}
+		c := &capture{stream: stream, original: *stream, writer: writer, done: make(chan struct{})}
+		go c.copy(reader)
+		*stream = writer
+		captures = append(captures, c)
+	}
+}
+
+// StopCapture puts the original streams back and waits until everything
+// written to the tee has reached them
+func StopCapture() {
+	captureMu.Lock()
+	defer captureMu.Unlock()
+	for _, c := range captures {
+		*c.stream = c.original
+		c.writer.Close()
+		<-c.done
+	}
+	captures = nil
+}
+
+func (c *capture) copy(reader *os.File) {
+	defer close(c.done)
+	defer reader.Close()
+	lines := &lineRecorder{}
+	io.Copy(io.MultiWriter(c.original, lines), reader)
+	lines.flush()
+}
+
+func isCharDevice(f *os.File) bool {
+	if f == nil {
+		return true
+	}
+	info, err := f.Stat()
+	return err != nil || info.Mode()&os.ModeCharDevice != 0
+}
+
+// lineRecorder passes each complete line written to it to Record
+type lineRecorder struct {
+	partial []byte
+}
+
+func (r *lineRecorder) Write(p []byte) (int, error) {
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.record(data[:i])
+		data = data[i+1:]
+	}
+	if len(data) > maxPartialLine {
+		r.record(data)
+		data = nil
+	}
+	r.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (r *lineRecorder) flush() {
+	if len(r.partial) > 0 {
+		r.record(r.partial)
+		r.partial = nil
+	}
+}
+
+func (r *lineRecorder) record(line []byte) {
+	if text := strings.TrimRight(string(line), "\r"); strings.TrimSpace(text) != "" {
+		Record(text)
+	}
+}
+
+// Exit stops the capture, so buffered output reaches the terminal or file
+// before the process ends, and exits with code
+func Exit(code int) {
+	StopCapture()
+	os.Exit(code)
+}
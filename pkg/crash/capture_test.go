@@ -0,0 +1,49 @@
+package crash
+
+import (
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestCaptureOutputRecordsPrintedLines(t *testing.T) {
+	resetRecent()
+	defer resetRecent()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = original }()
+
+	CaptureOutput()
+	if os.Stdout == writer {
+		StopCapture()
+		t.Fatal("CaptureOutput left a piped stdout untouched")
+	}
+	os.Stdout.WriteString("Building app...\r\n")
+	os.Stdout.WriteString("error: npm run build ")
+	os.Stdout.WriteString("exited with code 1\n\n")
+	os.Stdout.WriteString("no trailing newline")
+	StopCapture()
+
+	if os.Stdout != writer {
+		t.Error("StopCapture did not restore stdout")
+	}
+	writer.Close()
+	passed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Building app...\r\nerror: npm run build exited with code 1\n\nno trailing newline"
+	if string(passed) != want {
+		t.Errorf("original stdout got %q, want %q", passed, want)
+	}
+	lines := Recent()
+	if !slices.Equal(lines, []string{"Building app...", "error: npm run build exited with code 1", "no trailing newline"}) {
+		t.Errorf("Recent() = %q", lines)
+	}
+}
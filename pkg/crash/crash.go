@@ -0,0 +1,128 @@
+// Package crash writes local crash dumps when a command panics and, when the
+// user opted in, sends them to a telemetry endpoint. Dumps hold the command,
+// sanitized flags, versions, the stack and the last output lines; never
+// positional arguments, env values, tokens or file contents.
+package crash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MaxOutputLines is how many recent output lines a dump keeps
+const MaxOutputLines = 50
+
+var (
+	mu     sync.Mutex
+	recent []string
+)
+
+// Record keeps line as recent progress or command output for a crash dump
+func Record(line string) {
+	mu.Lock()
+	defer mu.Unlock()
+	recent = append(recent, line)
+	if len(recent) > MaxOutputLines {
+		recent = recent[len(recent)-MaxOutputLines:]
+	}
+}
+
+// Recent returns the recorded lines, oldest first
+func Recent() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), recent...)
+}
+
+// Report is the content of a crash dump
+type Report struct {
+	Time      time.Time         `json:"time"`
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Command   string            `json:"command"`
+	Flags     map[string]string `json:"flags,omitempty"`
+	Panic     string            `json:"panic"`
+	Stack     string            `json:"stack"`
+	Output    []string          `json:"output,omitempty"`
+}
+
+// NewReport builds a sanitized report of a panic. flags are the values of the
+// flags set on the command line by name; secrets are values known to be
+// sensitive, such as env values from config, scrubbed from every field.
+func NewReport(version, command string, flags map[string][]string, panicValue interface{}, stack []byte, secrets []string) Report {
+	sanitizer := NewSanitizer(append(secrets, flagSecrets(flags)...)...)
+
+	report := Report{
+		Time:      time.Now().UTC(),
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Command:   command,
+		Panic:     sanitizer.Line(fmt.Sprint(panicValue)),
+		Stack:     sanitizer.Text(string(stack)),
+	}
+	if len(flags) > 0 {
+		report.Flags = make(map[string]string, len(flags))
+		for name, values := range flags {
+			report.Flags[name] = sanitizer.Flag(name, values)
+		}
+	}
+	for _, line := range Recent() {
+		report.Output = append(report.Output, sanitizer.Line(line))
+	}
+	return report
+}
+
+// WriteDump writes the report to dir as <timestamp>.json, readable only by
+// the user, and returns its path
+func WriteDump(dir string, report Report) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create crash directory: %w", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, report.Time.Format("20060102-150405.000")+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write crash dump: %w", err)
+	}
+	return path, nil
+}
+
+// Send POSTs the report as JSON to endpoint
+func Send(ctx context.Context, endpoint string, report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lightfold/"+report.Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
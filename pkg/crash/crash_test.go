@@ -0,0 +1,135 @@
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func resetRecent() {
+	mu.Lock()
+	recent = nil
+	mu.Unlock()
+}
+
+func TestReportNeverContainsEnvSecrets(t *testing.T) {
+	resetRecent()
+	defer resetRecent()
+
+	flags := map[string][]string{
+		"env":        {"API_KEY=sk_live_abc123", "DATABASE_URL=postgres://app:hunter22@db/app"},
+		"env-file":   {".env.production"},
+		"target":     {"web"},
+		"token":      {"dop_v1_0123456789"},
+		"skip-build": {"true"},
+	}
+	Record("Building app...")
+	Record("  error: could not connect with key sk_live_abc123")
+	Record("  DATABASE_URL=postgres://app:hunter22@db/app npm run migrate")
+	Record("  using config value from-config-secret")
+
+	report := NewReport("0.1.3", "lightfold push", flags,
+		fmt.Errorf("unexpected response for sk_live_abc123"), []byte("goroutine 1 [running]:\nmain.main()"),
+		[]string{"from-config-secret"})
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(data)
+	for _, secret := range []string{"sk_live_abc123", "hunter22", "postgres://app", "dop_v1_0123456789", "from-config-secret"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("crash dump contains secret %q:\n%s", secret, dump)
+		}
+	}
+
+	if report.Flags["env"] != "API_KEY=[REDACTED],DATABASE_URL=[REDACTED]" {
+		t.Errorf("env flag = %q, want the keys only", report.Flags["env"])
+	}
+	if report.Flags["target"] != "web" || report.Flags["env-file"] != ".env.production" || report.Flags["skip-build"] != "true" {
+		t.Errorf("flags = %v, want non-secret values kept", report.Flags)
+	}
+	if report.Flags["token"] != redacted {
+		t.Errorf("token flag = %q, want it redacted", report.Flags["token"])
+	}
+	if report.Command != "lightfold push" || len(report.Output) != 4 || report.Output[0] != "Building app..." {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestRecordKeepsLastLines(t *testing.T) {
+	resetRecent()
+	defer resetRecent()
+
+	for i := 0; i < MaxOutputLines+10; i++ {
+		Record(fmt.Sprintf("line %d", i))
+	}
+	lines := Recent()
+	if len(lines) != MaxOutputLines || lines[0] != "line 10" || lines[len(lines)-1] != fmt.Sprintf("line %d", MaxOutputLines+9) {
+		t.Errorf("Recent() = %d lines from %q to %q", len(lines), lines[0], lines[len(lines)-1])
+	}
+}
+
+func TestSanitizerIgnoresShortSecrets(t *testing.T) {
+	s := NewSanitizer("1", "abc", "longer-secret")
+	if got := s.Text("retry 1 of abc with longer-secret"); got != "retry 1 of abc with [REDACTED]" {
+		t.Errorf("Text() = %q", got)
+	}
+}
+
+func TestWriteDump(t *testing.T) {
+	dir := t.TempDir()
+	report := NewReport("0.1.3", "lightfold status", nil, "boom", []byte("stack"), nil)
+
+	path, err := WriteDump(dir+"/crashes", report)
+	if err != nil {
+		t.Fatalf("WriteDump() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("dump mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	data, _ := os.ReadFile(path)
+	var loaded Report
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Panic != "boom" || loaded.Command != "lightfold status" {
+		t.Errorf("dump = %s, %v", data, err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var received Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	report := NewReport("0.1.3", "lightfold push", nil, "boom", nil, nil)
+	if err := Send(context.Background(), server.URL, report); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if received.Panic != "boom" || received.Version != "0.1.3" {
+		t.Errorf("received = %+v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := Send(context.Background(), failing.URL, report); err == nil {
+		t.Error("Send() to a failing endpoint should return an error")
+	}
+}
@@ -0,0 +1,42 @@
+package crash
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	handlerMu sync.Mutex
+	handler   func(panicValue interface{}, stack []byte)
+)
+
+// SetHandler installs the function Go hands a recovered panic and its stack
+// to, the one that writes the crash dump for the main goroutine
+func SetHandler(h func(panicValue interface{}, stack []byte)) {
+	handlerMu.Lock()
+	defer handlerMu.Unlock()
+	handler = h
+}
+
+// Go runs fn on a new goroutine. A deferred recover only catches panics of
+// its own goroutine, so a panic in fn is recovered here and handed to the
+// handler; without one it panics again as before. Panics from several
+// goroutines are handled one at a time.
+func Go(fn func()) {
+	go func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			stack := debug.Stack()
+			handlerMu.Lock()
+			defer handlerMu.Unlock()
+			if handler == nil {
+				panic(r)
+			}
+			handler(r, stack)
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,83 @@
+package crash
+
+import (
+	"lightfold/pkg/debuglog"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// minSecretLength keeps short values such as "1" or "true" from being
+// scrubbed out of every line
+const minSecretLength = 4
+
+// Sanitizer removes known secret values and credential-looking text
+type Sanitizer struct {
+	secrets []string
+}
+
+// NewSanitizer returns a sanitizer that scrubs the given values, longest
+// first so a secret containing another is removed whole
+func NewSanitizer(secrets ...string) *Sanitizer {
+	s := &Sanitizer{}
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength && !seen[secret] {
+			seen[secret] = true
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+	sort.Slice(s.secrets, func(i, j int) bool { return len(s.secrets[i]) > len(s.secrets[j]) })
+	return s
+}
+
+// Text scrubs the known secret values
+func (s *Sanitizer) Text(text string) string {
+	for _, secret := range s.secrets {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// Line scrubs the known secret values and redacts NAME=value assignments and
+// lines that look like they carry a credential
+func (s *Sanitizer) Line(line string) string {
+	return debuglog.RedactCommand(s.Text(line))
+}
+
+// Flag renders a flag's values for a dump. Flags named like credentials are
+// redacted whole; KEY=VALUE values such as --env keep only the key.
+func (s *Sanitizer) Flag(name string, values []string) string {
+	if debuglog.IsSensitive(name) {
+		return redacted
+	}
+
+	clean := make([]string, 0, len(values))
+	for _, value := range values {
+		if key, _, ok := strings.Cut(value, "="); ok {
+			clean = append(clean, key+"="+redacted)
+			continue
+		}
+		if debuglog.IsSensitive(value) {
+			clean = append(clean, redacted)
+			continue
+		}
+		clean = append(clean, s.Text(value))
+	}
+	return strings.Join(clean, ",")
+}
+
+// flagSecrets returns the values of KEY=VALUE flag values, so an env value
+// passed with --env is also scrubbed where it shows up in output
+func flagSecrets(flags map[string][]string) []string {
+	secrets := []string{}
+	for _, values := range flags {
+		for _, value := range values {
+			if _, secret, ok := strings.Cut(value, "="); ok {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,74 @@
+// Package debuglog writes structured debug traces of provider API calls and SSH
+// commands to a local file. It is off unless Enable is called (lightfold --debug).
+// Secret values are redacted before anything is written.
+package debuglog
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	mu     sync.RWMutex
+	logger *slog.Logger
+	file   io.Closer
+)
+
+// Enable starts appending JSON log lines to path, creating it with 0600 permissions
+func Enable(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create debug log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open debug log: %w", err)
+	}
+
+	EnableWriter(f)
+
+	mu.Lock()
+	file = f
+	mu.Unlock()
+	return nil
+}
+
+// EnableWriter sends debug logs to w
+func EnableWriter(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	logger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// Disable stops debug logging and closes the log file
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+		file = nil
+	}
+	logger = nil
+}
+
+// Enabled reports whether debug logging is on
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger != nil
+}
+
+// Log writes one event. Callers must pass values that are already redacted.
+func Log(event string, attrs ...any) {
+	mu.RLock()
+	l := logger
+	mu.RUnlock()
+	if l == nil {
+		return
+	}
+	l.Debug(event, attrs...)
+}
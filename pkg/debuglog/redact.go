@@ -0,0 +1,95 @@
+package debuglog
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveWords mark a header, query parameter or command as carrying a secret
+var sensitiveWords = []string{
+	"authorization", "token", "secret", "password", "passwd", "credential",
+	"api_key", "apikey", "api-key", "private", "cookie", "signature", "bearer",
+}
+
+// envAssignment matches NAME=value (optionally quoted) as written in shell commands
+var envAssignment = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|[^\s;&|]*)`)
+
+// sensitiveAssignment matches NAME=value with the rest of its command segment,
+// up to the next ; & | or newline outside quotes, since an unquoted value may
+// run on past a space
+var sensitiveAssignment = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|[^;&|\n"']+)*`)
+
+// IsSensitive reports whether s names or looks like a credential
+func IsSensitive(s string) bool {
+	lower := strings.ToLower(s)
+	for _, word := range sensitiveWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return strings.Contains(lower, "-----begin")
+}
+
+// RedactHeaders returns headers for logging with credential-bearing values replaced
+func RedactHeaders(headers http.Header) map[string]string {
+	result := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if IsSensitive(name) {
+			result[name] = redacted
+			continue
+		}
+		result[name] = strings.Join(values, ", ")
+	}
+	return result
+}
+
+// RedactURL returns the URL with credentials and sensitive query values removed
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	clean.User = nil
+
+	query := clean.Query()
+	for key := range query {
+		if IsSensitive(key) {
+			query.Set(key, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// RedactCommand prepares a shell command for logging. Assignments to a
+// sensitive NAME are hidden through the end of their command segment. Commands
+// that look like they carry a token or key keep only the program name;
+// otherwise the values of NAME=value assignments are hidden, since env var
+// values are often secrets.
+func RedactCommand(command string) string {
+	command = sensitiveAssignment.ReplaceAllStringFunc(command, func(match string) string {
+		name := sensitiveAssignment.FindStringSubmatch(match)[1]
+		if !IsSensitive(name) {
+			return match
+		}
+		return name + "=" + redacted
+	})
+
+	if IsSensitive(command) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return ""
+		}
+		program := fields[0]
+		if program == "sudo" && len(fields) > 1 {
+			program = "sudo " + fields[1]
+		}
+		return program + " " + redacted
+	}
+
+	return envAssignment.ReplaceAllString(command, "${1}="+redacted)
+}
@@ -0,0 +1,85 @@
+package debuglog
+
+import (
+	"net/http"
+	"time"
+)
+
+// requestIDHeaders are the headers providers use to identify a request to support
+var requestIDHeaders = []string{
+	"X-Request-Id",
+	"X-Correlation-Id",
+	"X-Amzn-Requestid",
+	"X-Amz-Request-Id",
+	"X-Linode-Request-Id",
+	"Fly-Request-Id",
+}
+
+// Transport logs each request made through it when debug logging is enabled
+type Transport struct {
+	Provider string
+	Base     http.RoundTripper
+}
+
+// NewTransport wraps base (http.DefaultTransport when nil) for the named provider
+func NewTransport(provider string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Provider: provider, Base: base}
+}
+
+// HTTPClient returns an HTTP client whose requests are traced for the named provider
+func HTTPClient(provider string) *http.Client {
+	return &http.Client{Transport: NewTransport(provider, nil)}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.Base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+
+	attrs := []any{
+		"provider", t.Provider,
+		"method", req.Method,
+		"url", RedactURL(req.URL),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"request_headers", RedactHeaders(req.Header),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode)
+		for _, header := range requestIDHeaders {
+			if value := resp.Header.Get(header); value != "" {
+				attrs = append(attrs, "request_id", value)
+				break
+			}
+		}
+	}
+
+	Log("provider_api", attrs...)
+	return resp, err
+}
+
+// Doer is the client interface SDKs such as aws-sdk-go-v2 accept in place of *http.Client
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type doerTransport struct {
+	doer Doer
+}
+
+func (d doerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return d.doer.Do(req)
+}
+
+// WrapDoer returns a client that traces requests and then sends them through doer
+func WrapDoer(provider string, doer Doer) *http.Client {
+	return &http.Client{Transport: NewTransport(provider, doerTransport{doer: doer})}
+}
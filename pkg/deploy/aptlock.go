@@ -0,0 +1,211 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aptLockFiles are the locks apt and dpkg take; any process holding one
+// makes package installs fail
+var aptLockFiles = []string{"/var/lib/dpkg/lock-frontend", "/var/lib/dpkg/lock", "/var/lib/apt/lists/lock"}
+
+// aptLockProbe prints "apt: free", or "apt: locked" followed by lsof's field
+// output for the lock holders ("lsof: p1234", "lsof: cunattended-upgr") and
+// a "proc: <pid> <seconds running> <command line>" line for each of them.
+// lsof only sees other users' locks as root, so the probe runs privileged.
+var aptLockProbe = fmt.Sprintf(`out=$(lsof -F pc %s 2>/dev/null); `+
+	`if [ -n "$out" ]; then echo "apt: locked"; echo "$out" | sed 's/^/lsof: /'; `+
+	`for p in $(echo "$out" | sed -n 's/^p//p' | sort -u); do echo "proc: $p $(ps -o etimes=,args= -p $p 2>/dev/null)"; done; `+
+	`else echo "apt: free"; fi`, strings.Join(aptLockFiles, " "))
+
+// AptLockHolder is a process holding an apt or dpkg lock
+type AptLockHolder struct {
+	PID     int
+	Command string        // Command name as lsof reports it, truncated to 15 characters
+	Args    string        // Full command line, when ps could read it
+	Running time.Duration // How long the process has been running
+}
+
+// Name is the holder's service name for the ones lightfold knows about,
+// otherwise its command name
+func (h AptLockHolder) Name() string {
+	text := h.Args + " " + h.Command
+	switch {
+	case strings.Contains(text, "unattended-upgr"):
+		return "unattended-upgrades"
+	case strings.Contains(text, "cloud-init"):
+		return "cloud-init"
+	}
+	return h.Command
+}
+
+func (h AptLockHolder) String() string {
+	s := fmt.Sprintf("%s (pid %d", h.Name(), h.PID)
+	if h.Running > 0 {
+		s += ", running " + h.Running.Round(time.Second).String()
+	}
+	return s + ")"
+}
+
+// ExpectedWait says how long the holder usually keeps the locks, for the
+// holders whose behavior is known
+func (h AptLockHolder) ExpectedWait() string {
+	switch h.Name() {
+	case "unattended-upgrades":
+		if left := config.AptUnattendedUpgradeTypical - h.Running; left > time.Minute {
+			return fmt.Sprintf("security updates usually finish within %s, about %s left; kernel updates can take longer", config.AptUnattendedUpgradeTypical, left.Round(time.Minute))
+		}
+		return "security updates usually finish within " + config.AptUnattendedUpgradeTypical.String() + "; kernel updates can take longer"
+	case "cloud-init":
+		return "first boot setup, usually done within " + config.DefaultCloudInitTimeout.String()
+	case "apt", "apt-get", "dpkg", "aptitude":
+		return "another package operation, released when it finishes"
+	}
+	return ""
+}
+
+// stale reports whether the holder is unattended-upgrades and has run past
+// config.AptStaleHolderAge, so --kill-stale-apt may stop it
+func (h AptLockHolder) stale() bool {
+	return h.Name() == "unattended-upgrades" && h.Running >= config.AptStaleHolderAge
+}
+
+// parseAptLockHolders reads the lock holders from aptLockProbe's output
+func parseAptLockHolders(output string) []AptLockHolder {
+	var holders []AptLockHolder
+	index := map[int]int{}
+	holder := func(pid int) *AptLockHolder {
+		if i, ok := index[pid]; ok {
+			return &holders[i]
+		}
+		index[pid] = len(holders)
+		holders = append(holders, AptLockHolder{PID: pid})
+		return &holders[len(holders)-1]
+	}
+
+	current := -1
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "lsof: "):
+			field := strings.TrimPrefix(line, "lsof: ")
+			if field == "" {
+				continue
+			}
+			switch field[0] {
+			case 'p':
+				if pid, err := strconv.Atoi(field[1:]); err == nil {
+					current = pid
+					holder(pid)
+				}
+			case 'c':
+				if current >= 0 {
+					holder(current).Command = field[1:]
+				}
+			}
+		case strings.HasPrefix(line, "proc: "):
+			fields := strings.Fields(strings.TrimPrefix(line, "proc: "))
+			if len(fields) == 0 {
+				continue
+			}
+			pid, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			h := holder(pid)
+			if len(fields) > 1 {
+				if seconds, err := strconv.Atoi(fields[1]); err == nil {
+					h.Running = time.Duration(seconds) * time.Second
+				}
+			}
+			if len(fields) > 2 {
+				h.Args = strings.Join(fields[2:], " ")
+			}
+		}
+	}
+	return holders
+}
+
+// aptLockAction is what the server readiness wait does about held locks
+type aptLockAction int
+
+const (
+	aptLockWait aptLockAction = iota
+	aptLockStopStale
+)
+
+// decideAptLock picks the next step while locks are held: stop a stale
+// unattended-upgrades when --kill-stale-apt allows it and it was not stopped
+// already, otherwise keep waiting until the deadline
+func decideAptLock(holders []AptLockHolder, killStale bool, stopped map[int]bool) (aptLockAction, AptLockHolder) {
+	if killStale {
+		for _, h := range holders {
+			if h.stale() && !stopped[h.PID] {
+				return aptLockStopStale, h
+			}
+		}
+	}
+	return aptLockWait, AptLockHolder{}
+}
+
+// stopStaleAptCommand stops unattended-upgrades the way its service does,
+// with SIGTERM so it finishes the package it is on, waits up to a minute for
+// the process to exit, then lets dpkg finish any interrupted configuration.
+// It is one shell, run with ExecuteSudo so it works as root and with a sudo
+// password alike.
+func stopStaleAptCommand(pid int) string {
+	script := fmt.Sprintf("systemctl stop unattended-upgrades 2>/dev/null; kill -TERM %[1]d 2>/dev/null; "+
+		"for i in $(seq 1 30); do [ -d /proc/%[1]d ] || break; sleep 2; done; "+
+		"if [ -d /proc/%[1]d ]; then echo 'still running'; exit 1; fi; "+
+		"DEBIAN_FRONTEND=noninteractive dpkg --configure -a", pid)
+	return "sh -c " + util.ShellQuote(script)
+}
+
+// AptLockError is why the server readiness wait stopped while apt/dpkg locks
+// were still held
+type AptLockError struct {
+	Holders   []AptLockHolder
+	Waited    time.Duration
+	KillStale bool // --kill-stale-apt was given
+}
+
+func (e *AptLockError) Error() string {
+	var b strings.Builder
+	b.WriteString("apt locks still held")
+	if e.Waited > 0 {
+		b.WriteString(" after " + e.Waited.Round(time.Second).String())
+	}
+	if len(e.Holders) > 0 {
+		held := make([]string, len(e.Holders))
+		for i, h := range e.Holders {
+			held[i] = h.String()
+			if wait := h.ExpectedWait(); wait != "" {
+				held[i] += ": " + wait
+			}
+		}
+		b.WriteString(" by " + strings.Join(held, "; "))
+	}
+	if e.Waited == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\nNext steps:\n  - wait a few minutes and run the command again, or wait longer with --apt-wait (e.g. --apt-wait 30m)")
+	unattended := false
+	for _, h := range e.Holders {
+		unattended = unattended || h.Name() == "unattended-upgrades"
+	}
+	switch {
+	case unattended && !e.KillStale:
+		fmt.Fprintf(&b, "\n  - rerun with --kill-stale-apt to stop unattended-upgrades once it has run for %s", config.AptStaleHolderAge)
+	case unattended:
+		fmt.Fprintf(&b, "\n  - --kill-stale-apt only stops unattended-upgrades once it has run for %s", config.AptStaleHolderAge)
+	}
+	if len(e.Holders) > 0 {
+		fmt.Fprintf(&b, "\n  - check it on the server: ps -o pid,etime,args -p %d", e.Holders[0].PID)
+	}
+	return b.String()
+}
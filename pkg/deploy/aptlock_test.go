@@ -0,0 +1,175 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/ssh"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const unattendedLockOutput = `status: done
+apt: locked
+lsof: p1432
+lsof: cunattended-upgr
+lsof: f4
+lsof: p1432
+lsof: cunattended-upgr
+lsof: f6
+proc: 1432    2400 /usr/bin/python3 /usr/bin/unattended-upgrade --download-only
+`
+
+func TestParseAptLockHolders(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []AptLockHolder
+	}{
+		{
+			name:   "unattended-upgrades on two locks",
+			output: unattendedLockOutput,
+			want: []AptLockHolder{{
+				PID:     1432,
+				Command: "unattended-upgr",
+				Args:    "/usr/bin/python3 /usr/bin/unattended-upgrade --download-only",
+				Running: 40 * time.Minute,
+			}},
+		},
+		{
+			name:   "apt-get and dpkg",
+			output: "apt: locked\nlsof: p200\nlsof: capt-get\nlsof: f3\nlsof: p201\nlsof: cdpkg\nlsof: f4\nproc: 200 65 apt-get install -y nginx\nproc: 201 12 /usr/bin/dpkg --configure -a\n",
+			want: []AptLockHolder{
+				{PID: 200, Command: "apt-get", Args: "apt-get install -y nginx", Running: 65 * time.Second},
+				{PID: 201, Command: "dpkg", Args: "/usr/bin/dpkg --configure -a", Running: 12 * time.Second},
+			},
+		},
+		{
+			name:   "process exited before ps ran",
+			output: "apt: locked\nlsof: p77\nlsof: ccloud-init\nproc: 77 \n",
+			want:   []AptLockHolder{{PID: 77, Command: "cloud-init"}},
+		},
+		{name: "free", output: "status: done\napt: free\n"},
+		{name: "garbage", output: "apt: locked\nlsof: pabc\nproc: xyz 10 foo\nlsof: \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAptLockHolders(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAptLockHolders() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAptLockHolderDescription(t *testing.T) {
+	holder := AptLockHolder{PID: 1432, Command: "unattended-upgr", Running: 5 * time.Minute}
+	if got := holder.String(); got != "unattended-upgrades (pid 1432, running 5m0s)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := holder.ExpectedWait(); !strings.Contains(got, "about 10m0s left") {
+		t.Errorf("ExpectedWait() = %q, want the time left", got)
+	}
+	holder.Running = time.Hour
+	if got := holder.ExpectedWait(); strings.Contains(got, "left") || !strings.Contains(got, "kernel updates") {
+		t.Errorf("ExpectedWait() past the usual time = %q", got)
+	}
+	if got := (AptLockHolder{PID: 9, Command: "python3", Args: "/usr/bin/python3 /usr/bin/cloud-init modules"}).Name(); got != "cloud-init" {
+		t.Errorf("Name() = %q, want cloud-init", got)
+	}
+	if got := (AptLockHolder{PID: 9, Command: "packagekitd"}).ExpectedWait(); got != "" {
+		t.Errorf("ExpectedWait() of an unknown holder = %q", got)
+	}
+}
+
+func TestDecideAptLock(t *testing.T) {
+	stale := AptLockHolder{PID: 10, Command: "unattended-upgr", Running: config.AptStaleHolderAge + time.Minute}
+	fresh := AptLockHolder{PID: 11, Command: "unattended-upgr", Running: time.Minute}
+	oldApt := AptLockHolder{PID: 12, Command: "apt-get", Running: 2 * time.Hour}
+
+	tests := []struct {
+		name      string
+		holders   []AptLockHolder
+		killStale bool
+		stopped   map[int]bool
+		want      aptLockAction
+		wantPID   int
+	}{
+		{name: "waits without the flag", holders: []AptLockHolder{stale}, want: aptLockWait},
+		{name: "stops a stale unattended-upgrades", holders: []AptLockHolder{oldApt, stale}, killStale: true, want: aptLockStopStale, wantPID: 10},
+		{name: "leaves a fresh unattended-upgrades", holders: []AptLockHolder{fresh}, killStale: true, want: aptLockWait},
+		{name: "never stops other package managers", holders: []AptLockHolder{oldApt}, killStale: true, want: aptLockWait},
+		{name: "stops it once", holders: []AptLockHolder{stale}, killStale: true, stopped: map[int]bool{10: true}, want: aptLockWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopped := tt.stopped
+			if stopped == nil {
+				stopped = map[int]bool{}
+			}
+			action, holder := decideAptLock(tt.holders, tt.killStale, stopped)
+			if action != tt.want || (action == aptLockStopStale && holder.PID != tt.wantPID) {
+				t.Errorf("decideAptLock() = %v, pid %d; want %v, pid %d", action, holder.PID, tt.want, tt.wantPID)
+			}
+		})
+	}
+}
+
+// commandLog records commands and answers the readiness probe from a script
+type commandLog struct {
+	fakeProbeRunner
+	commands []string
+}
+
+func (r *commandLog) Execute(command string) *ssh.CommandResult {
+	r.commands = append(r.commands, command)
+	return &ssh.CommandResult{}
+}
+
+func (r *commandLog) ExecuteSudo(command string) *ssh.CommandResult {
+	r.commands = append(r.commands, "sudo "+command)
+	if command != serverReadyCommand {
+		return &ssh.CommandResult{}
+	}
+	return r.fakeProbeRunner.ExecuteSudo(command)
+}
+
+func TestWaitForServerReady_TimeoutNamesHolder(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	runner := &fakeProbeRunner{outputs: []string{unattendedLockOutput}}
+	var reports []string
+
+	err := waitForServerReady(runner, clock, time.Minute, 2*time.Minute, false, func(line string) { reports = append(reports, line) })
+	if err == nil {
+		t.Fatal("waitForServerReady() should time out")
+	}
+	for _, want := range []string{"apt locks still held after 2m0s", "unattended-upgrades (pid 1432, running 40m0s)", "--apt-wait", "--kill-stale-apt", "ps -o pid,etime,args -p 1432"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q:\n%v", want, err)
+		}
+	}
+	if len(reports) == 0 || !strings.Contains(reports[0], "held by unattended-upgrades (pid 1432") {
+		t.Errorf("progress should name the holder: %v", reports)
+	}
+}
+
+func TestWaitForServerReady_KillStaleApt(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	runner := &commandLog{fakeProbeRunner: fakeProbeRunner{outputs: []string{unattendedLockOutput, "status: done\napt: free\n"}}}
+
+	if err := waitForServerReady(runner, clock, time.Minute, 10*time.Minute, true, nil); err != nil {
+		t.Fatalf("waitForServerReady() = %v", err)
+	}
+	want := []string{"sudo " + serverReadyCommand, "sudo " + stopStaleAptCommand(1432), "sudo " + serverReadyCommand}
+	if !reflect.DeepEqual(runner.commands, want) {
+		t.Errorf("commands = %q, want probe, stop, probe", runner.commands)
+	}
+	if strings.Contains(serverReadyProbe, "sudo") {
+		t.Errorf("probe should leave privileges to ExecuteSudo: %s", serverReadyProbe)
+	}
+	if stop := stopStaleAptCommand(1432); strings.Contains(stop, "sudo") || !strings.HasPrefix(stop, "sh -c ") {
+		t.Errorf("stop command should be one shell leaving privileges to ExecuteSudo: %s", stop)
+	}
+	if !strings.Contains(stopStaleAptCommand(1432), "systemctl stop unattended-upgrades") || !strings.Contains(stopStaleAptCommand(1432), "dpkg --configure -a") {
+		t.Errorf("stop command should stop the service and finish dpkg: %s", stopStaleAptCommand(1432))
+	}
+}
@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// artifactScriptExtensions mark a start command argument as a script the
+// release has to contain
+var artifactScriptExtensions = []string{".js", ".mjs", ".cjs", ".py", ".rb"}
+
+// artifactPackageRunners start the app through package.json scripts
+var artifactPackageRunners = map[string]bool{"npm": true, "pnpm": true, "yarn": true, "bun": true}
+
+// ArtifactEntrypoint returns the file, relative to the release, that the
+// app's start command runs, and whether the command runs it directly so it
+// has to be executable. It is "" when the command names no file inside the
+// release, e.g. gunicorn wsgi:application. Static sites need the index.html
+// of their build output instead.
+func (e *Executor) ArtifactEntrypoint() (string, bool) {
+	if e.isStaticSite() {
+		return path.Join(e.staticBuildOutput(), "index.html"), false
+	}
+
+	current := fmt.Sprintf("%s/current/", e.AppDir())
+	for i, field := range strings.Fields(e.baseExecStartCommand()) {
+		switch {
+		case i == 0 && artifactPackageRunners[path.Base(field)]:
+			return "package.json", false
+		case strings.HasPrefix(field, current):
+			return path.Clean(strings.TrimPrefix(field, current)), i == 0
+		case strings.HasPrefix(field, "./"):
+			return path.Clean(field), i == 0
+		case !strings.HasPrefix(field, "/") && !strings.HasPrefix(field, "-") && hasArtifactScriptExtension(field):
+			return path.Clean(field), false
+		}
+	}
+	return "", false
+}
+
+func hasArtifactScriptExtension(field string) bool {
+	for _, ext := range artifactScriptExtensions {
+		if strings.HasSuffix(field, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateArtifact checks a prebuilt artifact directory before it is packed:
+// it has to be a non-empty directory holding the entrypoint the app's start
+// command runs, executable when the command runs it directly
+func (e *Executor) ValidateArtifact(artifactDir string) error {
+	if e.isComposeProject() {
+		return fmt.Errorf("compose projects are built on the server and cannot be pushed as an artifact")
+	}
+
+	info, err := os.Stat(artifactDir)
+	if err != nil {
+		return fmt.Errorf("artifact %s: %w", artifactDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("artifact %s is not a directory", artifactDir)
+	}
+	if entries, err := os.ReadDir(artifactDir); err != nil {
+		return fmt.Errorf("artifact %s: %w", artifactDir, err)
+	} else if len(entries) == 0 {
+		return fmt.Errorf("artifact %s is empty", artifactDir)
+	}
+
+	entrypoint, executable := e.ArtifactEntrypoint()
+	if entrypoint == "" {
+		return nil
+	}
+	entryInfo, err := os.Stat(filepath.Join(artifactDir, filepath.FromSlash(entrypoint)))
+	if err != nil {
+		return fmt.Errorf("artifact %s has no %s, which the start command runs: %s", artifactDir, entrypoint, e.baseExecStartCommand())
+	}
+	if executable && entryInfo.Mode()&0111 == 0 {
+		return fmt.Errorf("artifact entrypoint %s is not executable", entrypoint)
+	}
+	return nil
+}
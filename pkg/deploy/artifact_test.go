@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+)
+
+func TestCreateArtifactTarball_PacksEverything(t *testing.T) {
+	artifactDir := t.TempDir()
+	writePackTree(t, artifactDir, map[string][]byte{
+		"server.js":                  []byte("require('next')"),
+		".next/static/chunk.js":      []byte("chunk"),
+		"node_modules/next/index.js": []byte("next"),
+	})
+
+	exec := NewExecutor(nil, "myapp", t.TempDir(), nil)
+	tarballPath := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := exec.CreateArtifactTarball(artifactDir, tarballPath); err != nil {
+		t.Fatalf("CreateArtifactTarball() error: %v", err)
+	}
+
+	_, contents := readTarball(t, tarballPath)
+	for _, want := range []string{"server.js", ".next/static/chunk.js", "node_modules/next/index.js"} {
+		if _, ok := contents[want]; !ok {
+			t.Errorf("artifact tarball missing %s, which project tarballs ignore", want)
+		}
+	}
+}
+
+func TestArtifactEntrypoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		detection      *detector.Detection
+		runCommands    []string
+		wantEntrypoint string
+		wantExecutable bool
+	}{
+		{name: "next standalone", detection: &detector.Detection{Framework: "Next.js", Language: "JavaScript/TypeScript", RunPlan: []string{"node .next/standalone/server.js"}}, wantEntrypoint: ".next/standalone/server.js"},
+		{name: "next start script", detection: &detector.Detection{Framework: "Next.js", Language: "JavaScript/TypeScript", RunPlan: []string{"npm run start"}}, wantEntrypoint: "package.json"},
+		{name: "go binary", detection: &detector.Detection{Framework: "Go", Language: "Go"}, wantEntrypoint: "app", wantExecutable: true},
+		{name: "django", detection: &detector.Detection{Framework: "Django", Language: "Python"}},
+		{name: "static site", detection: &detector.Detection{Framework: "Vite", Meta: map[string]string{"deployment_type": "static", "build_output": "dist/"}}, wantEntrypoint: "dist/index.html"},
+		{name: "start command", detection: &detector.Detection{Framework: "Next.js", Language: "JavaScript/TypeScript"}, runCommands: []string{"node server.js"}, wantEntrypoint: "server.js"},
+		{name: "start command binary", detection: &detector.Detection{Framework: "Go", Language: "Go"}, runCommands: []string{"./bin/api --port $PORT"}, wantEntrypoint: "bin/api", wantExecutable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := NewExecutorWithOptions(nil, "myapp", "", tt.detection, &config.DeploymentOptions{RunCommands: tt.runCommands})
+			entrypoint, executable := exec.ArtifactEntrypoint()
+			if entrypoint != tt.wantEntrypoint || executable != tt.wantExecutable {
+				t.Errorf("ArtifactEntrypoint() = %q, %v, want %q, %v", entrypoint, executable, tt.wantEntrypoint, tt.wantExecutable)
+			}
+		})
+	}
+}
+
+func TestValidateArtifact(t *testing.T) {
+	standalone := &detector.Detection{Framework: "Next.js", Language: "JavaScript/TypeScript", RunPlan: []string{"node .next/standalone/server.js"}}
+	exec := NewExecutor(nil, "myapp", "", standalone)
+
+	artifactDir := t.TempDir()
+	writePackTree(t, artifactDir, map[string][]byte{"server.js": []byte("")})
+	err := exec.ValidateArtifact(artifactDir)
+	if err == nil || !strings.Contains(err.Error(), "has no .next/standalone/server.js") {
+		t.Errorf("ValidateArtifact() without the entrypoint error = %v", err)
+	}
+
+	writePackTree(t, artifactDir, map[string][]byte{".next/standalone/server.js": []byte("")})
+	if err := exec.ValidateArtifact(artifactDir); err != nil {
+		t.Errorf("ValidateArtifact() error: %v", err)
+	}
+
+	if err := exec.ValidateArtifact(t.TempDir()); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("ValidateArtifact() of an empty directory error = %v", err)
+	}
+	if err := exec.ValidateArtifact(filepath.Join(artifactDir, "server.js")); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("ValidateArtifact() of a file error = %v", err)
+	}
+}
+
+func TestValidateArtifact_BinaryMustBeExecutable(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", "", &detector.Detection{Framework: "Go", Language: "Go"})
+	artifactDir := t.TempDir()
+	binary := filepath.Join(artifactDir, "app")
+	if err := os.WriteFile(binary, []byte("ELF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := exec.ValidateArtifact(artifactDir); err == nil || !strings.Contains(err.Error(), "not executable") {
+		t.Errorf("ValidateArtifact() of a non-executable binary error = %v", err)
+	}
+	if err := os.Chmod(binary, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.ValidateArtifact(artifactDir); err != nil {
+		t.Errorf("ValidateArtifact() error: %v", err)
+	}
+}
+
+func TestValidateArtifact_RejectsCompose(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", "", &detector.Detection{Framework: "Docker Compose", Meta: map[string]string{"deployment_type": "docker-compose"}})
+	if err := exec.ValidateArtifact(t.TempDir()); err == nil || !strings.Contains(err.Error(), "compose") {
+		t.Errorf("ValidateArtifact() for compose error = %v", err)
+	}
+}
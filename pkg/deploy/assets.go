@@ -0,0 +1,235 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/objectstore"
+	"lightfold/pkg/util"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+)
+
+// frameworkAssetDir is where a framework's build leaves its content-hashed
+// assets, relative to the release, and the URL path the app links them under
+type frameworkAssetDir struct {
+	dir     string
+	urlPath string
+	envVar  string   // Set for the build so the app links assets from the bucket
+	mutable []string // Files under dir that keep their name across builds
+}
+
+// frameworkAssetDirs lists the frameworks whose assets can be served from a
+// CDN. ASSET_PREFIX is read by the usual next.config.js line
+// `assetPrefix: process.env.ASSET_PREFIX`; Nuxt reads NUXT_APP_CDN_URL itself.
+var frameworkAssetDirs = map[string]frameworkAssetDir{
+	"Next.js": {dir: ".next/static", urlPath: "_next/static", envVar: "ASSET_PREFIX"},
+	"Nuxt.js": {dir: ".output/public/_nuxt", urlPath: "_nuxt", envVar: "NUXT_APP_CDN_URL", mutable: []string{"builds/latest.json"}},
+}
+
+const (
+	immutableAssetCacheControl = "public, max-age=31536000, immutable"
+	mutableAssetCacheControl   = "public, max-age=60"
+)
+
+// AssetUploadError is an asset upload that failed. Deploys stop on it unless
+// the target's assets config sets continue_on_error.
+type AssetUploadError struct {
+	Destination string
+	Err         error
+}
+
+func (e *AssetUploadError) Error() string {
+	return fmt.Sprintf("asset upload to %s failed: %v", e.Destination, e.Err)
+}
+
+func (e *AssetUploadError) Unwrap() error {
+	return e.Err
+}
+
+// AssetUpload is what UploadAssets did. Err is set when the upload failed
+// and the target continues on asset errors.
+type AssetUpload struct {
+	Destination string
+	Result      objectstore.SyncResult
+	Err         error
+}
+
+// newAssetStore connects to the bucket assets are uploaded to
+func newAssetStore(ctx context.Context, assets *config.AssetsConfig) (objectstore.Store, error) {
+	tokens, err := config.LoadTokens()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokens: %w", err)
+	}
+	return objectstore.NewS3Store(ctx, assets.Bucket, assets.Region, tokens.GetToken("aws"))
+}
+
+// assetSource returns the directory holding the framework's hashed assets.
+// Next.js keeps them in static/ under its build output, standalone or not;
+// static exports are served by nginx and have nothing to offload.
+func assetSource(detection *detector.Detection) (frameworkAssetDir, error) {
+	if detection == nil {
+		return frameworkAssetDir{}, fmt.Errorf("no framework detected")
+	}
+	source, ok := frameworkAssetDirs[detection.Framework]
+	if !ok {
+		frameworks := make([]string, 0, len(frameworkAssetDirs))
+		for framework := range frameworkAssetDirs {
+			frameworks = append(frameworks, framework)
+		}
+		sort.Strings(frameworks)
+		return frameworkAssetDir{}, fmt.Errorf("asset upload supports %s, not %s", strings.Join(frameworks, " and "), detection.Framework)
+	}
+
+	if detection.Framework == "Next.js" {
+		if detection.Meta["export"] == "static" {
+			return frameworkAssetDir{}, fmt.Errorf("static Next.js exports are served by nginx; asset upload is for server-rendered apps")
+		}
+		if output := strings.Trim(detection.Meta["build_output"], "/"); output != "" {
+			source.dir = path.Join(strings.TrimSuffix(output, "/standalone"), "static")
+		}
+	}
+	return source, nil
+}
+
+// AssetBaseURL is the URL the app links its assets under: the public URL
+// followed by the key prefix
+func AssetBaseURL(assets *config.AssetsConfig) string {
+	base := strings.TrimRight(assets.PublicURL, "/")
+	if prefix := strings.Trim(assets.Prefix, "/"); prefix != "" {
+		base += "/" + prefix
+	}
+	return base
+}
+
+// AssetDestination names the bucket and prefix for messages, e.g. s3://assets/myapp
+func AssetDestination(assets *config.AssetsConfig) string {
+	return strings.TrimRight("s3://"+path.Join(assets.Bucket, strings.Trim(assets.Prefix, "/")), "/")
+}
+
+// SetAssetOptions turns on uploading the framework's assets after each build
+func (e *Executor) SetAssetOptions(assets *config.AssetsConfig) {
+	if assets != nil && assets.Bucket != "" {
+		e.assets = assets
+	}
+}
+
+// AssetEnvironment returns the variable pointing the build at the asset URL,
+// e.g. ASSET_PREFIX for Next.js. It is empty without an assets config.
+func (e *Executor) AssetEnvironment() map[string]string {
+	if e.assets == nil || e.assets.PublicURL == "" {
+		return nil
+	}
+	source, err := assetSource(e.detection)
+	if err != nil || source.envVar == "" {
+		return nil
+	}
+	return map[string]string{source.envVar: AssetBaseURL(e.assets)}
+}
+
+// UploadAssets copies the built assets from the release to the bucket,
+// skipping files already stored with the same content. It returns nil
+// without an assets config.
+func (e *Executor) UploadAssets(ctx context.Context, releasePath string) (*AssetUpload, error) {
+	if e.assets == nil {
+		return nil, nil
+	}
+
+	upload := &AssetUpload{Destination: AssetDestination(e.assets)}
+	result, err := e.uploadAssets(ctx, releasePath)
+	upload.Result = result
+	if err != nil {
+		err = &AssetUploadError{Destination: upload.Destination, Err: err}
+		if !e.assets.ContinueOnError {
+			return nil, err
+		}
+		upload.Err = err
+	}
+	return upload, nil
+}
+
+func (e *Executor) uploadAssets(ctx context.Context, releasePath string) (objectstore.SyncResult, error) {
+	if e.assets.PublicURL == "" {
+		return objectstore.SyncResult{}, fmt.Errorf("assets.public_url is not set, so the app cannot link to the bucket")
+	}
+	source, err := assetSource(e.detection)
+	if err != nil {
+		return objectstore.SyncResult{}, err
+	}
+
+	dir := path.Join(releasePath, source.dir)
+	result := e.ssh.Execute(fmt.Sprintf("test -d %s && tar -C %s -czf - .", util.ShellQuote(dir), util.ShellQuote(dir)))
+	if result.Error != nil {
+		return objectstore.SyncResult{}, result.Error
+	}
+	if result.ExitCode != 0 {
+		return objectstore.SyncResult{}, fmt.Errorf("%s was not found in the release; the build did not produce it", source.dir)
+	}
+
+	objects, err := assetObjects([]byte(result.Stdout), source, e.assets)
+	if err != nil {
+		return objectstore.SyncResult{}, err
+	}
+
+	store, err := newAssetStore(ctx, e.assets)
+	if err != nil {
+		return objectstore.SyncResult{}, err
+	}
+	return objectstore.Sync(ctx, store, objects)
+}
+
+// assetObjects turns a gzipped tarball of the asset directory into the
+// objects to store. Hashed files are cached for a year; files that keep their
+// name across builds for a minute.
+func assetObjects(tarball []byte, source frameworkAssetDir, assets *config.AssetsConfig) ([]objectstore.Object, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assets: %w", err)
+	}
+	defer gz.Close()
+
+	mutable := make(map[string]bool, len(source.mutable))
+	for _, name := range source.mutable {
+		mutable[name] = true
+	}
+	keyPrefix := path.Join(strings.Trim(assets.Prefix, "/"), source.urlPath)
+
+	var objects []objectstore.Object
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read assets: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset %s: %w", header.Name, err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		cacheControl := immutableAssetCacheControl
+		if mutable[name] {
+			cacheControl = mutableAssetCacheControl
+		}
+		objects = append(objects, objectstore.Object{
+			Key:          path.Join(keyPrefix, name),
+			Body:         body,
+			ContentType:  mime.TypeByExtension(path.Ext(name)),
+			CacheControl: cacheControl,
+		})
+	}
+	return objects, nil
+}
@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/objectstore"
+	"strings"
+	"testing"
+)
+
+func TestAssetSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection detector.Detection
+		wantDir   string
+		wantErr   string
+	}{
+		{name: "next default", detection: detector.Detection{Framework: "Next.js", Meta: map[string]string{"build_output": ".next/"}}, wantDir: ".next/static"},
+		{name: "next standalone", detection: detector.Detection{Framework: "Next.js", Meta: map[string]string{"build_output": ".next/standalone/"}}, wantDir: ".next/static"},
+		{name: "next without meta", detection: detector.Detection{Framework: "Next.js"}, wantDir: ".next/static"},
+		{name: "nuxt", detection: detector.Detection{Framework: "Nuxt.js"}, wantDir: ".output/public/_nuxt"},
+		{name: "next export", detection: detector.Detection{Framework: "Next.js", Meta: map[string]string{"export": "static"}}, wantErr: "served by nginx"},
+		{name: "unsupported", detection: detector.Detection{Framework: "Django"}, wantErr: "supports Next.js and Nuxt.js, not Django"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := assetSource(&tt.detection)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("assetSource() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || source.dir != tt.wantDir {
+				t.Errorf("assetSource() = %q, %v, want %q", source.dir, err, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestAssetBaseURLAndDestination(t *testing.T) {
+	assets := &config.AssetsConfig{Bucket: "assets", Prefix: "/myapp/", PublicURL: "https://d111.cloudfront.net/"}
+	if got := AssetBaseURL(assets); got != "https://d111.cloudfront.net/myapp" {
+		t.Errorf("AssetBaseURL() = %s", got)
+	}
+	if got := AssetDestination(assets); got != "s3://assets/myapp" {
+		t.Errorf("AssetDestination() = %s", got)
+	}
+
+	assets.Prefix = ""
+	if got := AssetBaseURL(assets); got != "https://d111.cloudfront.net" {
+		t.Errorf("AssetBaseURL() without prefix = %s", got)
+	}
+	if got := AssetDestination(assets); got != "s3://assets" {
+		t.Errorf("AssetDestination() without prefix = %s", got)
+	}
+}
+
+func TestReleaseEnvironmentIncludesAssetPrefix(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", t.TempDir(), &detector.Detection{Framework: "Next.js"})
+	if env := exec.ReleaseEnvironment(nil); env["ASSET_PREFIX"] != "" {
+		t.Errorf("ASSET_PREFIX = %q without an assets config", env["ASSET_PREFIX"])
+	}
+
+	exec.SetAssetOptions(&config.AssetsConfig{Bucket: "assets", Prefix: "myapp", PublicURL: "https://cdn.example.com"})
+	if env := exec.ReleaseEnvironment(nil); env["ASSET_PREFIX"] != "https://cdn.example.com/myapp" {
+		t.Errorf("ASSET_PREFIX = %q, want the bucket URL", env["ASSET_PREFIX"])
+	}
+	if env := exec.ReleaseEnvironment(map[string]string{"ASSET_PREFIX": "https://other"}); env["ASSET_PREFIX"] != "https://other" {
+		t.Errorf("ASSET_PREFIX = %q, want the target's own value", env["ASSET_PREFIX"])
+	}
+}
+
+// assetTarball builds the gzipped tarball `tar -C dir -czf - .` would send
+func assetTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestAssetObjects(t *testing.T) {
+	tarball := assetTarball(t, map[string]string{
+		"chunks/main-3f2a1b.js": "console.log(1)",
+		"builds/latest.json":    "{}",
+	})
+	source := frameworkAssetDirs["Nuxt.js"]
+	objects, err := assetObjects(tarball, source, &config.AssetsConfig{Prefix: "myapp"})
+	if err != nil {
+		t.Fatalf("assetObjects() error: %v", err)
+	}
+
+	byKey := map[string]objectstore.Object{}
+	for _, obj := range objects {
+		byKey[obj.Key] = obj
+	}
+	if len(byKey) != 2 {
+		t.Fatalf("objects = %v, want the two files", byKey)
+	}
+	js := byKey["myapp/_nuxt/chunks/main-3f2a1b.js"]
+	if js.CacheControl != immutableAssetCacheControl || !strings.Contains(js.ContentType, "javascript") || string(js.Body) != "console.log(1)" {
+		t.Errorf("hashed asset = %+v", js)
+	}
+	if latest := byKey["myapp/_nuxt/builds/latest.json"]; latest.CacheControl != mutableAssetCacheControl {
+		t.Errorf("builds/latest.json cache control = %q, want it cached briefly", latest.CacheControl)
+	}
+}
+
+func TestUploadAssetsContinueOnError(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", t.TempDir(), &detector.Detection{Framework: "Django"})
+	exec.SetAssetOptions(&config.AssetsConfig{Bucket: "assets", PublicURL: "https://cdn.example.com"})
+	_, err := exec.UploadAssets(context.Background(), "/srv/myapp/releases/1")
+	var uploadErr *AssetUploadError
+	if !errors.As(err, &uploadErr) || uploadErr.Destination != "s3://assets" {
+		t.Fatalf("UploadAssets() error = %v, want an AssetUploadError", err)
+	}
+
+	exec.assets.ContinueOnError = true
+	upload, err := exec.UploadAssets(context.Background(), "/srv/myapp/releases/1")
+	if err != nil || upload == nil || upload.Err == nil {
+		t.Errorf("UploadAssets() = %+v, %v, want the failure reported without an error", upload, err)
+	}
+
+	if upload, err := NewExecutor(nil, "myapp", t.TempDir(), nil).UploadAssets(context.Background(), "/srv"); upload != nil || err != nil {
+		t.Errorf("UploadAssets() without an assets config = %+v, %v", upload, err)
+	}
+}
@@ -0,0 +1,61 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppDir_Default(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", t.TempDir(), nil)
+	if got := exec.AppDir(); got != "/srv/myapp" {
+		t.Errorf("AppDir() = %q, want /srv/myapp", got)
+	}
+
+	exec = NewExecutorWithOptions(nil, "myapp", t.TempDir(), nil, &config.DeploymentOptions{BaseDir: "/opt/apps/"})
+	if got := exec.AppDir(); got != "/opt/apps/myapp" {
+		t.Errorf("AppDir() with base_dir = %q, want /opt/apps/myapp", got)
+	}
+}
+
+// TestBaseDir_NoHardcodedSrv runs the deploy steps that touch the app
+// directory with a custom base and checks nothing they run or upload still
+// points at /srv
+func TestBaseDir_NoHardcodedSrv(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	exec.SetBaseDir("/opt/apps")
+	exec.detection = &detector.Detection{Framework: "Django", Language: "Python", RunPlan: []string{"gunicorn app.wsgi"}}
+	answerChecksum(server, tarballChecksum)
+
+	releasePath := "/opt/apps/myapp/releases/20240101000000"
+	exec.SetupDirectoryStructure()
+	exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000")
+	exec.WriteEnvironmentFile(map[string]string{"KEY": "value"})
+	exec.GenerateSystemdUnitWithPort(releasePath, 3000)
+	exec.GenerateNginxConfig(3000, "example.com")
+	exec.CleanupOldReleases(5)
+	exec.SetMigrationOptions(&config.DeploymentOptions{MigrationCommand: "python manage.py migrate"}, false)
+	exec.RunMigrations(releasePath)
+	exec.SyncJobs([]config.Job{{Name: "cleanup", Schedule: "0 3 * * *", Command: "python manage.py cleanup"}})
+	exec.EnableMaintenance(MaintenanceOptions{RetryAfter: time.Minute})
+	exec.RollbackToPreviousRelease()
+
+	commands, inputs := server.Commands(), server.Inputs()
+	if len(commands) == 0 {
+		t.Fatal("no commands were run")
+	}
+	sawAppDir := false
+	for i, command := range commands {
+		if strings.Contains(command, "/srv") || strings.Contains(inputs[i], "/srv") {
+			t.Errorf("command %q (input %q) still uses /srv", command, inputs[i])
+		}
+		if strings.Contains(command, "/opt/apps/myapp") || strings.Contains(inputs[i], "/opt/apps/myapp") {
+			sawAppDir = true
+		}
+	}
+	if !sawAppDir {
+		t.Error("no command used /opt/apps/myapp")
+	}
+}
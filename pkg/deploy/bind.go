@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ExposedBindAddress is what apps bind when they are reached directly on
+// their port instead of through nginx
+const ExposedBindAddress = "0.0.0.0"
+
+// bindHosts are the listen hosts rewritten to the app's bind address: the
+// wildcard and loopback ones, plus "" for a bare :port. A specific interface
+// address in a start command is left alone.
+var bindHosts = map[string]bool{"": true, "0.0.0.0": true, "127.0.0.1": true, "localhost": true, "::": true, "[::]": true, "::1": true, "[::1]": true}
+
+// bindFlag matches a listen flag and its value: gunicorn --bind/-b host:port,
+// uvicorn and jekyll --host, hugo --bind, rails -b, puma -b tcp://host:port
+// and next start --hostname
+var bindFlag = regexp.MustCompile(`(^|\s)(--bind|-b|--host|--hostname)(=|\s+)(\S+)`)
+
+// BindAddress is the address the app listens on: loopback while nginx
+// fronts it, all interfaces only when the target exposes the app port
+func (e *Executor) BindAddress() string {
+	if e.ExposesPort() {
+		return ExposedBindAddress
+	}
+	return config.DefaultBindAddress
+}
+
+// ExposesPort reports whether the target opted into direct port access or
+// ResolvePortExposure found it is reached on its port
+func (e *Executor) ExposesPort() bool {
+	return e.deployOptions != nil && e.deployOptions.ExposePort || e.directAccess
+}
+
+// ResolvePortExposure works out whether a target without deploy.expose_port
+// is reached directly on port. Apps bound every interface before the option
+// existed, and two setups depend on it: an app sharing its server without a
+// domain, which nginx does not route to, and an app whose port is open in
+// the firewall. Both keep binding all interfaces. Call it after
+// ApplyServerTuning, which counts the apps on the server.
+func (e *Executor) ResolvePortExposure(hasDomain bool, port int) {
+	e.directAccess = false
+	if e.ExposesPort() {
+		return
+	}
+	e.directAccess = !hasDomain && e.serverApps > 1 || e.firewallAllows(port)
+}
+
+// firewallAllows reports whether ufw is active with a rule allowing port.
+// Without an active firewall nothing was opened on purpose, so the app stays
+// on loopback.
+func (e *Executor) firewallAllows(port int) bool {
+	if e.ssh == nil || port <= 0 {
+		return false
+	}
+	result := e.ssh.ExecuteSudo("ufw status")
+	if result.Error != nil || result.ExitCode != 0 {
+		return false
+	}
+	return ufwAllowsPort(result.Stdout, port)
+}
+
+// ufwAllowsPort finds an ALLOW rule for port, any protocol or tcp, in the
+// output of 'ufw status'
+func ufwAllowsPort(status string, port int) bool {
+	p := strconv.Itoa(port)
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !slices.Contains(fields, "ALLOW") {
+			continue
+		}
+		if fields[0] == p || fields[0] == p+"/tcp" {
+			return true
+		}
+	}
+	return false
+}
+
+// bindStartCommand points the listen flags of a start command at address,
+// keeping the port each one names
+func bindStartCommand(command, address string) string {
+	return bindFlag.ReplaceAllStringFunc(command, func(match string) string {
+		parts := bindFlag.FindStringSubmatch(match)
+		return parts[1] + parts[2] + parts[3] + bindValue(parts[4], address)
+	})
+}
+
+// bindValue rewrites a listen value, "host", "host:port" or
+// "scheme://host:port", when its host is a wildcard or loopback one
+func bindValue(value, address string) string {
+	scheme, rest := "", value
+	if i := strings.Index(rest, "://"); i >= 0 {
+		scheme, rest = rest[:i+3], rest[i+3:]
+	}
+	if rest != "" && bindHosts[rest] {
+		return scheme + address
+	}
+	if i := strings.LastIndex(rest, ":"); i >= 0 && bindHosts[rest[:i]] {
+		return scheme + address + rest[i:]
+	}
+	return value
+}
+
+// bindAssignment rewrites a HOST, HOSTNAME (or PREFIX_HOST) environment
+// assignment the same way as a listen flag
+func bindAssignment(assignment, address string) string {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok || !(strings.HasSuffix(name, "HOST") || strings.HasSuffix(name, "HOSTNAME")) || value == "" || !bindHosts[value] {
+		return assignment
+	}
+	return name + "=" + address
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"testing"
+)
+
+func TestGetExecStartCommand_BindAddress(t *testing.T) {
+	venv := "/srv/test-app/shared/venv/bin"
+	tests := []struct {
+		name      string
+		framework string
+		language  string
+		runPlan   []string
+		expose    bool
+		want      string
+	}{
+		{name: "django fallback", framework: "Django", language: "Python", want: venv + "/gunicorn --bind 127.0.0.1:$PORT --workers 2 wsgi:application"},
+		{name: "django fallback exposed", framework: "Django", language: "Python", expose: true, want: venv + "/gunicorn --bind 0.0.0.0:$PORT --workers 2 wsgi:application"},
+		{name: "fastapi fallback exposed", framework: "FastAPI", language: "Python", expose: true, want: venv + "/uvicorn main:app --host 0.0.0.0 --port $PORT --workers 2"},
+		{name: "flask plan binding all interfaces", framework: "Flask", language: "Python", runPlan: []string{"gunicorn --bind 0.0.0.0:$PORT --workers 2 app:app"}, want: venv + "/gunicorn --bind 127.0.0.1:$PORT --workers 2 app:app"},
+		{name: "gunicorn short flag", framework: "Flask", language: "Python", runPlan: []string{"gunicorn -b=0.0.0.0:8000 app:app"}, want: venv + "/gunicorn -b=127.0.0.1:8000 app:app --workers 2"},
+		{name: "gunicorn bare port", framework: "Flask", language: "Python", runPlan: []string{"gunicorn --bind :$PORT app:app"}, expose: true, want: venv + "/gunicorn --bind 0.0.0.0:$PORT app:app --workers 2"},
+		{name: "django asgi uvicorn", framework: "Django", language: "Python", runPlan: []string{"uvicorn mysite.asgi:application --host 0.0.0.0 --port $PORT"}, want: venv + "/uvicorn mysite.asgi:application --host 127.0.0.1 --port $PORT --workers 2"},
+		{name: "uvicorn localhost exposed", framework: "FastAPI", language: "Python", runPlan: []string{"uvicorn main:app --host=localhost --port $PORT"}, expose: true, want: venv + "/uvicorn main:app --host=0.0.0.0 --port $PORT --workers 2"},
+		{name: "rails", framework: "Rails", language: "Ruby", runPlan: []string{"bundle exec rails server -b 0.0.0.0 -p $PORT"}, want: "bundle exec rails server -b 127.0.0.1 -p $PORT"},
+		{name: "puma tcp url", framework: "Rails", language: "Ruby", runPlan: []string{"bundle exec puma -b tcp://0.0.0.0:$PORT"}, want: "bundle exec puma -b tcp://127.0.0.1:$PORT"},
+		{name: "puma unix socket kept", framework: "Rails", language: "Ruby", runPlan: []string{"bundle exec puma -b unix:///tmp/puma.sock"}, want: "bundle exec puma -b unix:///tmp/puma.sock"},
+		{name: "jekyll", framework: "Jekyll", language: "Ruby", runPlan: []string{"bundle exec jekyll serve --host 0.0.0.0"}, want: "bundle exec jekyll serve --host 127.0.0.1"},
+		{name: "hugo", framework: "Hugo", language: "Go", runPlan: []string{"hugo server --bind 0.0.0.0 --port $PORT"}, want: "hugo server --bind 127.0.0.1 --port $PORT"},
+		{name: "ipv6 wildcard", framework: "Flask", language: "Python", runPlan: []string{"gunicorn --bind [::]:$PORT app:app"}, want: venv + "/gunicorn --bind 127.0.0.1:$PORT app:app --workers 2"},
+		{name: "specific address kept", framework: "Flask", language: "Python", runPlan: []string{"gunicorn --bind 10.0.0.5:$PORT app:app"}, expose: true, want: venv + "/gunicorn --bind 10.0.0.5:$PORT app:app --workers 2"},
+		{name: "go without a bind flag", framework: "Go", language: "Go", want: "/srv/test-app/current/app --port $PORT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection := &detector.Detection{Framework: tt.framework, Language: tt.language, RunPlan: tt.runPlan}
+			exec := NewExecutorWithOptions(nil, "test-app", "/path", detection, &config.DeploymentOptions{ExposePort: tt.expose})
+			exec.SetProcessTuning(0, 0, nil)
+			exec.workers = 2
+
+			if got := exec.getExecStartCommand(); got != tt.want {
+				t.Errorf("getExecStartCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartEnvironment_BindAddress(t *testing.T) {
+	detection := &detector.Detection{
+		Framework: "SvelteKit",
+		Language:  "JavaScript/TypeScript",
+		Meta:      map[string]string{"start_env": "HOST=127.0.0.1 PORT=$PORT APP_HOST=0.0.0.0"},
+	}
+
+	exec := NewExecutor(nil, "test-app", "/path", detection)
+	if got, want := exec.startEnvironment(3001), "\nEnvironment=HOST=127.0.0.1\nEnvironment=APP_HOST=127.0.0.1"; got != want {
+		t.Errorf("startEnvironment() = %q, want %q", got, want)
+	}
+
+	exposed := NewExecutorWithOptions(nil, "test-app", "/path", detection, &config.DeploymentOptions{ExposePort: true})
+	if got, want := exposed.startEnvironment(3001), "\nEnvironment=HOST=0.0.0.0\nEnvironment=APP_HOST=0.0.0.0"; got != want {
+		t.Errorf("startEnvironment() exposed = %q, want %q", got, want)
+	}
+}
+
+func TestSetStaticPathOptions_CarriesExposePort(t *testing.T) {
+	exec := NewExecutor(nil, "test-app", "/path", &detector.Detection{Framework: "Django", Language: "Python"})
+	exec.SetStaticPathOptions(&config.DeploymentOptions{ExposePort: true})
+	if got := exec.BindAddress(); got != ExposedBindAddress {
+		t.Errorf("BindAddress() = %q, want %q", got, ExposedBindAddress)
+	}
+}
+
+func TestStartEnvironment_NextStandaloneHostname(t *testing.T) {
+	detection := &detector.Detection{
+		Framework: "Next.js",
+		Language:  "JavaScript/TypeScript",
+		Meta:      map[string]string{"output_mode": "standalone"},
+	}
+
+	exec := NewExecutor(nil, "test-app", "/path", detection)
+	if got, want := exec.startEnvironment(3000), "\nEnvironment=HOSTNAME=127.0.0.1"; got != want {
+		t.Errorf("startEnvironment() = %q, want %q", got, want)
+	}
+
+	detection.Meta["output_mode"] = "default"
+	if got := exec.startEnvironment(3000); got != "" {
+		t.Errorf("startEnvironment() without standalone = %q, want none", got)
+	}
+}
+
+func TestResolvePortExposure(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverApps int
+		hasDomain  bool
+		ufw        string
+		want       bool
+	}{
+		{name: "single app", serverApps: 1, want: false},
+		{name: "multi-app without domain", serverApps: 2, want: true},
+		{name: "multi-app with domain", serverApps: 2, hasDomain: true, want: false},
+		{name: "port open in firewall", serverApps: 1, hasDomain: true, ufw: "Status: active\n\nTo                         Action      From\n--                         ------      ----\n22/tcp                     ALLOW       Anywhere\n3001/tcp                   ALLOW       Anywhere\n", want: true},
+		{name: "other port open", serverApps: 1, ufw: "Status: active\n\n22/tcp ALLOW Anywhere\n30010/tcp ALLOW Anywhere\n", want: false},
+		{name: "firewall inactive", serverApps: 1, ufw: "Status: inactive\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, server := connectRecording(t, "test-app")
+			server.Replies = map[string][]string{"ufw status": {tt.ufw}}
+			exec.serverApps = tt.serverApps
+
+			exec.ResolvePortExposure(tt.hasDomain, 3001)
+			if got := exec.ExposesPort(); got != tt.want {
+				t.Errorf("ExposesPort() = %v, want %v", got, tt.want)
+			}
+			if wantAddress := map[bool]string{true: ExposedBindAddress, false: config.DefaultBindAddress}[tt.want]; exec.BindAddress() != wantAddress {
+				t.Errorf("BindAddress() = %q, want %q", exec.BindAddress(), wantAddress)
+			}
+		})
+	}
+}
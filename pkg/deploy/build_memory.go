@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+)
+
+// BuildMemoryRequirement is the memory a framework's production build needs.
+// Builds on servers below RecommendedMB often get killed; below MinimumMB
+// they practically always do.
+type BuildMemoryRequirement struct {
+	RecommendedMB int
+	MinimumMB     int // 0 when builds are never refused
+}
+
+// buildMemoryRequirements are the frameworks whose builds run out of memory
+// on small servers. Frameworks without an entry build in the memory any
+// server has.
+var buildMemoryRequirements = map[string]BuildMemoryRequirement{
+	"Next.js":    {RecommendedMB: 2048, MinimumMB: 1024},
+	"Nuxt.js":    {RecommendedMB: 2048, MinimumMB: 1024},
+	"Angular":    {RecommendedMB: 2048, MinimumMB: 1024},
+	"Gatsby":     {RecommendedMB: 4096, MinimumMB: 2048},
+	"Docusaurus": {RecommendedMB: 2048},
+	"Remix":      {RecommendedMB: 1024},
+	"Astro":      {RecommendedMB: 1024},
+	"Svelte":     {RecommendedMB: 1024},
+	"Vue.js":     {RecommendedMB: 1024},
+}
+
+// hasMemory reports whether a server reporting memoryMB has requiredMB. The
+// kernel keeps part of the plan's memory for itself, so a 1 GB server
+// reports around 960 MB in /proc/meminfo; 10% is allowed for that.
+func hasMemory(memoryMB, requiredMB int) bool {
+	return memoryMB >= requiredMB*9/10
+}
+
+// BuildMemoryShortfall is a server with less memory than its app's framework
+// needs to build
+type BuildMemoryShortfall struct {
+	Framework string
+	MemoryMB  int
+	BuildMemoryRequirement
+}
+
+// Blocking reports whether the build is all but certain to run out of memory
+func (s *BuildMemoryShortfall) Blocking() bool {
+	return s.MinimumMB > 0 && !hasMemory(s.MemoryMB, s.MinimumMB)
+}
+
+func (s *BuildMemoryShortfall) String() string {
+	return fmt.Sprintf("building %s needs about %d MB of memory but the server has %d MB", s.Framework, s.RecommendedMB, s.MemoryMB)
+}
+
+// CheckBuildMemory compares a server's memory with what the framework needs
+// to build. It returns nil when the memory suffices, is not known, or the
+// framework has no requirement.
+func CheckBuildMemory(framework string, memoryMB int) *BuildMemoryShortfall {
+	requirement, ok := buildMemoryRequirements[framework]
+	if !ok || memoryMB <= 0 || hasMemory(memoryMB, requirement.RecommendedMB) {
+		return nil
+	}
+	return &BuildMemoryShortfall{Framework: framework, MemoryMB: memoryMB, BuildMemoryRequirement: requirement}
+}
+
+// BuildMemoryError refuses a build the server does not have the memory to
+// finish. --force-build runs it anyway.
+type BuildMemoryError struct {
+	Shortfall *BuildMemoryShortfall
+}
+
+func (e *BuildMemoryError) Error() string {
+	return fmt.Sprintf("%s; rerun with --force-build to build anyway", e.Shortfall)
+}
+
+// SetBuildMemoryOptions sets whether builds on servers short of memory run
+// anyway (--force-build). The check is skipped when the target turns it off
+// and for the dockerfile builder, which builds the image on this machine.
+func (e *Executor) SetBuildMemoryOptions(builder string, opts *config.DeploymentOptions, force bool) {
+	e.forceBuild = force
+	e.skipBuildMemoryCheck = builder == "dockerfile" || (opts != nil && opts.SkipBuildMemoryCheck)
+}
+
+// CheckBuildMemory checks the server's memory, recorded by
+// ApplyServerTuning, before the remote build starts. It returns the
+// shortfall to warn about, and a BuildMemoryError when the build cannot
+// succeed and is not forced.
+func (e *Executor) CheckBuildMemory() (*BuildMemoryShortfall, error) {
+	if e.skipBuildMemoryCheck || e.detection == nil {
+		return nil, nil
+	}
+	shortfall := CheckBuildMemory(e.detection.Framework, e.memoryMB)
+	if shortfall == nil {
+		return nil, nil
+	}
+	if shortfall.Blocking() && !e.forceBuild {
+		return shortfall, &BuildMemoryError{Shortfall: shortfall}
+	}
+	return shortfall, nil
+}
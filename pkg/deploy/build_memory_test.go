@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"errors"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"testing"
+)
+
+func TestCheckBuildMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		framework string
+		memoryMB  int
+		shortfall bool
+		blocking  bool
+	}{
+		{"next on 512 MB", "Next.js", 481, true, true},
+		{"next on 1 GB", "Next.js", 961, true, false},
+		{"next on 2 GB", "Next.js", 1963, false, false},
+		{"gatsby on 2 GB", "Gatsby", 1963, true, false},
+		{"gatsby on 1 GB", "Gatsby", 961, true, true},
+		{"docusaurus is never blocked", "Docusaurus", 481, true, false},
+		{"framework without requirement", "Django", 481, false, false},
+		{"memory unknown", "Next.js", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortfall := CheckBuildMemory(tt.framework, tt.memoryMB)
+			if (shortfall != nil) != tt.shortfall {
+				t.Fatalf("CheckBuildMemory(%q, %d) = %v, want shortfall %v", tt.framework, tt.memoryMB, shortfall, tt.shortfall)
+			}
+			if shortfall != nil && shortfall.Blocking() != tt.blocking {
+				t.Errorf("Blocking() = %v, want %v", shortfall.Blocking(), tt.blocking)
+			}
+		})
+	}
+}
+
+func TestBuildMemoryRequirementsAreConsistent(t *testing.T) {
+	for framework, requirement := range buildMemoryRequirements {
+		if requirement.RecommendedMB <= 0 || requirement.MinimumMB > requirement.RecommendedMB {
+			t.Errorf("%s: minimum %d MB, recommended %d MB", framework, requirement.MinimumMB, requirement.RecommendedMB)
+		}
+	}
+}
+
+func TestExecutorCheckBuildMemory(t *testing.T) {
+	newExecutor := func() *Executor {
+		e := NewExecutor(nil, "myapp", t.TempDir(), &detector.Detection{Framework: "Next.js"})
+		e.SetProcessTuning(1, 481, nil)
+		return e
+	}
+
+	e := newExecutor()
+	e.SetBuildMemoryOptions("native", nil, false)
+	var memoryErr *BuildMemoryError
+	if _, err := e.CheckBuildMemory(); !errors.As(err, &memoryErr) {
+		t.Errorf("CheckBuildMemory() = %v, want a BuildMemoryError", err)
+	}
+
+	e.SetBuildMemoryOptions("native", nil, true)
+	if shortfall, err := e.CheckBuildMemory(); err != nil || shortfall == nil {
+		t.Errorf("forced CheckBuildMemory() = %v, %v; want the shortfall without an error", shortfall, err)
+	}
+
+	for _, tt := range []struct {
+		builder string
+		opts    *config.DeploymentOptions
+	}{
+		{"dockerfile", nil},
+		{"native", &config.DeploymentOptions{SkipBuildMemoryCheck: true}},
+	} {
+		e.SetBuildMemoryOptions(tt.builder, tt.opts, false)
+		if shortfall, err := e.CheckBuildMemory(); shortfall != nil || err != nil {
+			t.Errorf("CheckBuildMemory() with builder %s = %v, %v; want it skipped", tt.builder, shortfall, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/ssh/sshtest"
+)
+
+// connectRecording returns an executor whose SSH commands go to a recording server
+func connectRecording(t *testing.T, appName string, failOn ...string) (*Executor, *sshtest.Server) {
+	t.Helper()
+	server := &sshtest.Server{FailOn: failOn}
+	sshpkg.UsePool(sshpkg.NewPool(server.Dial))
+	t.Cleanup(func() { sshpkg.ClosePool() })
+
+	sshExecutor := sshpkg.NewExecutor("203.0.113.20", "22", "deploy", sshtest.WriteKey(t))
+	if err := sshExecutor.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	t.Cleanup(func() { sshExecutor.Disconnect() })
+
+	return NewExecutor(sshExecutor, appName, t.TempDir(), nil), server
+}
+
+// tarballChecksum is the SHA-256 of the tarball writeLocalTarball writes
+const tarballChecksum = "db4b4d0d1cb480bf9aeea253771c00febe627f236765fa37d6a5614f079a3aa0"
+
+func writeLocalTarball(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := os.WriteFile(path, []byte("tarball"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// answerChecksum makes the server's sha256sum print each digest in turn
+func answerChecksum(server *sshtest.Server, digests ...string) {
+	outputs := make([]string, len(digests))
+	for i, digest := range digests {
+		outputs[i] = digest + "  /tmp/lightfold-myapp-release.tar.gz\n"
+	}
+	server.Replies = map[string][]string{"sha256sum /tmp/": outputs}
+}
+
+func TestUploadRelease_CleansUpFailedExtraction(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "tar -xzf")
+	answerChecksum(server, tarballChecksum)
+
+	if _, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000"); err == nil {
+		t.Fatal("UploadReleaseAs() should fail when extraction fails")
+	}
+
+	for _, want := range []string{
+		"rm -f /tmp/lightfold-myapp-release.tar.gz",
+		"rm -rf /srv/myapp/releases/20240101000000",
+	} {
+		if !server.Ran(want) {
+			t.Errorf("expected cleanup command %q, got %v", want, server.Commands())
+		}
+	}
+}
+
+func TestUploadRelease_CleansUpFailedPermissions(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "chmod 750")
+	answerChecksum(server, tarballChecksum)
+
+	if _, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000"); err == nil {
+		t.Fatal("UploadReleaseAs() should fail when restricting permissions fails")
+	}
+	if !server.Ran("rm -rf /srv/myapp/releases/20240101000000") {
+		t.Errorf("expected the half-prepared release to be removed, got %v", server.Commands())
+	}
+}
+
+func TestUploadRelease_KeepsSuccessfulRelease(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	answerChecksum(server, tarballChecksum)
+
+	if _, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000"); err != nil {
+		t.Fatalf("UploadReleaseAs() error: %v", err)
+	}
+	if server.Ran("rm -rf /srv/myapp/releases/20240101000000") {
+		t.Error("a successful upload should not remove its release")
+	}
+}
+
+func TestRemoveFailedRelease(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+
+	if err := exec.RemoveFailedRelease("/srv/myapp/releases/20240101000000"); err != nil {
+		t.Fatalf("RemoveFailedRelease() error: %v", err)
+	}
+	if !server.Ran("rm -rf /srv/myapp/releases/20240101000000") {
+		t.Errorf("expected the release to be removed, got %v", server.Commands())
+	}
+
+	for _, path := range []string{"/srv/myapp", "/srv/myapp/releases/", "/srv/other/releases/1", "/srv/myapp/releases/../shared"} {
+		if err := exec.RemoveFailedRelease(path); err == nil {
+			t.Errorf("RemoveFailedRelease(%q) should refuse paths outside the app's releases", path)
+		}
+	}
+}
+
+func TestSweepStaleTempFiles(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+
+	exec.SweepStaleTempFiles()
+	if !server.Ran("find /tmp -maxdepth 1 -name 'lightfold-*' -mmin +1440") {
+		t.Errorf("expected a sweep of day-old /tmp/lightfold-* files, got %v", server.Commands())
+	}
+}
@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/detector"
+	"strings"
+)
+
+// DefaultComposeFile is used when detection did not record which compose file was found
+const DefaultComposeFile = "docker-compose.yml"
+
+// IsComposeDetection returns true if the detected app is deployed with Docker Compose
+func IsComposeDetection(detection *detector.Detection) bool {
+	if detection == nil || detection.Meta == nil {
+		return false
+	}
+	return detection.Meta["deployment_type"] == "docker-compose"
+}
+
+// IsComposeFramework returns true if a target's framework is deployed with Docker Compose
+func IsComposeFramework(framework string) bool {
+	return framework == "Docker Compose"
+}
+
+// ComposeProjectName returns the compose project name for an app.
+// The name is stable across releases so each `up` replaces the previous release's containers.
+func ComposeProjectName(appName string) string {
+	name := strings.ToLower(strings.ReplaceAll(appName, "_", "-"))
+	return "lightfold-" + name
+}
+
+// ComposeCommand builds a docker compose command scoped to the app's project.
+// When dir is empty the command runs without a compose file (ps, logs, stop work by project name).
+func ComposeCommand(appName, dir, composeFile, args string) string {
+	project := ComposeProjectName(appName)
+	if dir == "" {
+		return fmt.Sprintf("docker compose -p %s %s", project, args)
+	}
+	return fmt.Sprintf("cd %s && docker compose -p %s -f %s %s", dir, project, composeFile, args)
+}
+
+func (e *Executor) isComposeProject() bool {
+	return IsComposeDetection(e.detection)
+}
+
+func (e *Executor) composeFile() string {
+	if e.detection != nil && e.detection.Meta != nil {
+		if file := e.detection.Meta["compose_file"]; file != "" {
+			return file
+		}
+	}
+	return DefaultComposeFile
+}
+
+func (e *Executor) currentReleaseDir() string {
+	return fmt.Sprintf("%s/current", e.AppDir())
+}
+
+// prepareComposeEnv makes sure the release has a .env next to the compose file.
+// Shared env vars are used when the build step did not write one, and PORT is
+// added so compose files can publish "${PORT}:<container port>".
+func (e *Executor) prepareComposeEnv(releasePath string, port int) error {
+	envPath := fmt.Sprintf("%s/.env", releasePath)
+	sharedEnv := e.sharedEnvFile().Path
+
+	cmd := fmt.Sprintf("(test -f %s || (test -f %s && cp %s %s) || touch %s) && (grep -q '^PORT=' %s || echo 'PORT=%d' >> %s) && chmod 600 %s",
+		envPath, sharedEnv, sharedEnv, envPath, envPath, envPath, port, envPath, envPath)
+	result := e.ssh.ExecuteSudo(cmd)
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to prepare compose .env", result)
+	}
+
+	return nil
+}
+
+// ComposeUp builds and starts the compose project from the given release directory
+func (e *Executor) ComposeUp(releaseDir string) error {
+	result := e.ssh.ExecuteSudo(ComposeCommand(e.appName, releaseDir, e.composeFile(), "up -d --build --remove-orphans 2>&1"))
+	if result.Error != nil || result.ExitCode != 0 {
+		e.sendOutput(result.Stdout, 15)
+		return formatSSHError("docker compose up failed", result)
+	}
+	e.sendOutput(result.Stdout, 5)
+	return nil
+}
+
+// ComposeStop stops the compose project's containers without removing them
+func (e *Executor) ComposeStop() error {
+	result := e.ssh.ExecuteSudo(ComposeCommand(e.appName, "", "", "stop"))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("docker compose stop failed", result)
+	}
+	return nil
+}
+
+// ComposeRunning returns true if any container of the compose project is running
+func (e *Executor) ComposeRunning() bool {
+	result := e.ssh.ExecuteSudo(ComposeCommand(e.appName, "", "", "ps --status running -q"))
+	return result.ExitCode == 0 && strings.TrimSpace(result.Stdout) != ""
+}
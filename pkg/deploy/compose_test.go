@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"lightfold/pkg/detector"
+	"testing"
+)
+
+func TestComposeProjectName(t *testing.T) {
+	// Executor (my-app) and cmd (my_app) spellings must map to the same project
+	if got := ComposeProjectName("my-app"); got != "lightfold-my-app" {
+		t.Errorf("ComposeProjectName(my-app) = %q, want lightfold-my-app", got)
+	}
+	if got := ComposeProjectName("My_App"); got != "lightfold-my-app" {
+		t.Errorf("ComposeProjectName(My_App) = %q, want lightfold-my-app", got)
+	}
+}
+
+func TestComposeCommand(t *testing.T) {
+	got := ComposeCommand("api", "/srv/api/releases/1", "compose.yaml", "up -d --build")
+	want := "cd /srv/api/releases/1 && docker compose -p lightfold-api -f compose.yaml up -d --build"
+	if got != want {
+		t.Errorf("ComposeCommand() = %q, want %q", got, want)
+	}
+
+	got = ComposeCommand("api", "", "", "logs --tail 50")
+	want = "docker compose -p lightfold-api logs --tail 50"
+	if got != want {
+		t.Errorf("ComposeCommand() without dir = %q, want %q", got, want)
+	}
+}
+
+func TestExecutorComposeFile(t *testing.T) {
+	exec := NewExecutor(nil, "api", "/tmp/api", &detector.Detection{
+		Meta: map[string]string{"deployment_type": "docker-compose", "compose_file": "compose.yaml"},
+	})
+	if !exec.isComposeProject() {
+		t.Fatal("expected compose project")
+	}
+	if exec.composeFile() != "compose.yaml" {
+		t.Errorf("composeFile() = %q, want compose.yaml", exec.composeFile())
+	}
+
+	exec = NewExecutor(nil, "api", "/tmp/api", &detector.Detection{Meta: map[string]string{"deployment_type": "docker-compose"}})
+	if exec.composeFile() != DefaultComposeFile {
+		t.Errorf("composeFile() = %q, want %q", exec.composeFile(), DefaultComposeFile)
+	}
+
+	exec = NewExecutor(nil, "api", "/tmp/api", &detector.Detection{Meta: map[string]string{"deployment_type": "static"}})
+	if exec.isComposeProject() {
+		t.Error("static site should not be treated as a compose project")
+	}
+}
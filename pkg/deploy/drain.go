@@ -0,0 +1,190 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultStopTimeout matches systemd's own default when no drain period is configured
+	defaultStopTimeout = "90s"
+
+	drainPollInterval   = 2 * time.Second
+	drainReportInterval = 10 * time.Second
+	// drainStartupGrace covers starting the new process once the old one has exited
+	drainStartupGrace = 30 * time.Second
+)
+
+// SetDrainSeconds sets how long the old process may keep serving in-flight
+// connections after SIGTERM when the service is restarted. Zero keeps systemd's default.
+func (e *Executor) SetDrainSeconds(seconds int) {
+	e.drainSeconds = seconds
+}
+
+// SetNoDrain makes restarts skip the drain period, for quick iterations
+func (e *Executor) SetNoDrain(noDrain bool) {
+	e.noDrain = noDrain
+}
+
+// restartReleaseService restarts the service onto a newly switched release,
+// draining the previous process when a drain period is configured.
+//
+// Releases switch by symlink and share the app's port, so the old process is
+// drained in place by systemd's stop timeout. There is no port-switching
+// blue/green mode to keep an old process serving next to the new one.
+func (e *Executor) restartReleaseService() error {
+	if e.isComposeProject() {
+		return e.RestartService()
+	}
+	if e.drainSeconds <= 0 {
+		if err := e.removeStopTimeout(); err != nil {
+			return err
+		}
+		return e.RestartService()
+	}
+	if e.noDrain {
+		return e.restartWithoutDrain()
+	}
+	return e.restartWithDrain()
+}
+
+// stopTimeout returns the TimeoutStopSec value for the systemd unit
+func (e *Executor) stopTimeout() string {
+	if e.drainSeconds > 0 {
+		return fmt.Sprintf("%ds", e.drainSeconds)
+	}
+	return defaultStopTimeout
+}
+
+// applyStopTimeout installs a drop-in with the drain timeout so units generated
+// before drain_seconds was configured pick it up without a full reconfigure
+func (e *Executor) applyStopTimeout() error {
+	dropInPath := drainDropInPath(e.appName)
+	dropInDir := path.Dir(dropInPath)
+	content := fmt.Sprintf("[Service]\nKillSignal=SIGTERM\nTimeoutStopSec=%s\n", e.stopTimeout())
+
+	result := e.ssh.ExecuteSudo("mkdir -p " + dropInDir)
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to create drain drop-in directory", result)
+	}
+	dropIn := sshpkg.RemoteFile{Path: dropInPath, Mode: config.PermConfigFile, Owner: "root:root"}
+	if err := e.ssh.InstallFile(dropIn, content); err != nil {
+		return fmt.Errorf("failed to write drain drop-in: %w", err)
+	}
+
+	result = e.ssh.ExecuteSudo("systemctl daemon-reload")
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to install drain drop-in", result)
+	}
+
+	return nil
+}
+
+// drainDropInPath is the systemd drop-in holding an app's drain timeout
+func drainDropInPath(appName string) string {
+	return fmt.Sprintf("/etc/systemd/system/%s.service.d/drain.conf", appName)
+}
+
+// removeDrainDropInCommand deletes the drain drop-in, reloading systemd only
+// when there was one, so the unit's own stop timeout applies again
+func removeDrainDropInCommand(appName string) string {
+	path := util.ShellQuote(drainDropInPath(appName))
+	return "sh -c " + util.ShellQuote(fmt.Sprintf("if [ -e %s ]; then rm -f %s && systemctl daemon-reload; fi", path, path))
+}
+
+// removeStopTimeout drops the drain timeout of an earlier deploy once
+// drain_seconds is back to 0
+func (e *Executor) removeStopTimeout() error {
+	result := e.ssh.ExecuteSudo(removeDrainDropInCommand(e.appName))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to remove drain drop-in", result)
+	}
+	return nil
+}
+
+// restartWithDrain restarts the service and waits while systemd lets the old
+// process finish its connections, reporting progress until the new process is up
+func (e *Executor) restartWithDrain() error {
+	if err := e.applyStopTimeout(); err != nil {
+		return err
+	}
+
+	oldPID, _ := e.serviceMainPID()
+	e.notify(fmt.Sprintf("Draining connections on the previous release (up to %ds)...", e.drainSeconds))
+
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl --no-block restart %s", e.appName))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to restart service: %s", result.Stderr)
+	}
+
+	start := time.Now()
+	deadline := start.Add(time.Duration(e.drainSeconds)*time.Second + drainStartupGrace)
+	lastReport := start
+
+	for time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+
+		pid, activeState := e.serviceMainPID()
+		switch {
+		case activeState == "active" && pid != 0 && pid != oldPID:
+			e.notify(fmt.Sprintf("Previous release stopped after %ds", int(time.Since(start).Seconds())))
+			return nil
+		case activeState == "failed":
+			return fmt.Errorf("service failed while restarting")
+		}
+
+		if time.Since(lastReport) >= drainReportInterval {
+			e.notify(fmt.Sprintf("Still draining (%ds elapsed)...", int(time.Since(start).Seconds())))
+			lastReport = time.Now()
+		}
+	}
+
+	return fmt.Errorf("service did not come back within the %ds drain period", e.drainSeconds)
+}
+
+// restartWithoutDrain kills the old process immediately instead of waiting out
+// a configured stop timeout
+func (e *Executor) restartWithoutDrain() error {
+	e.ssh.ExecuteSudo(fmt.Sprintf("systemctl kill --signal=SIGKILL %s", e.appName))
+
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl restart %s", e.appName))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to restart service: %s", result.Stderr)
+	}
+	return nil
+}
+
+// serviceMainPID returns the service's main PID and ActiveState
+func (e *Executor) serviceMainPID() (int, string) {
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl show -p MainPID -p ActiveState %s", e.appName))
+	if result.Error != nil || result.ExitCode != 0 {
+		return 0, ""
+	}
+	return parseMainPIDAndState(result.Stdout)
+}
+
+func parseMainPIDAndState(output string) (int, string) {
+	var pid int
+	var activeState string
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "MainPID":
+			pid, _ = strconv.Atoi(value)
+		case "ActiveState":
+			activeState = value
+		}
+	}
+
+	return pid, activeState
+}
@@ -0,0 +1,62 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStopTimeout(t *testing.T) {
+	exec := NewExecutor(nil, "test-app", "/path/to/project", nil)
+	if got := exec.stopTimeout(); got != defaultStopTimeout {
+		t.Errorf("expected default stop timeout %s, got %s", defaultStopTimeout, got)
+	}
+
+	exec.SetDrainSeconds(120)
+	if got := exec.stopTimeout(); got != "120s" {
+		t.Errorf("expected 120s, got %s", got)
+	}
+
+	// --no-drain only changes how restarts happen, not the unit's timeout
+	exec.SetNoDrain(true)
+	if got := exec.stopTimeout(); got != "120s" {
+		t.Errorf("expected 120s with no-drain, got %s", got)
+	}
+}
+
+func TestRemoveDrainDropInCommand(t *testing.T) {
+	want := `sh -c 'if [ -e '\''/etc/systemd/system/my-app.service.d/drain.conf'\'' ]; then rm -f '\''/etc/systemd/system/my-app.service.d/drain.conf'\'' && systemctl daemon-reload; fi'`
+	if got := removeDrainDropInCommand("my-app"); got != want {
+		t.Errorf("removeDrainDropInCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSystemdTemplateStopSettings(t *testing.T) {
+	for _, placeholder := range []string{"KillSignal={{KILL_SIGNAL}}", "TimeoutStopSec={{TIMEOUT_STOP_SEC}}"} {
+		if !strings.Contains(systemdTemplate, placeholder) {
+			t.Errorf("systemd template missing %s", placeholder)
+		}
+	}
+}
+
+func TestParseMainPIDAndState(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		wantPID   int
+		wantState string
+	}{
+		{"active", "MainPID=4321\nActiveState=active\n", 4321, "active"},
+		{"deactivating", "ActiveState=deactivating\nMainPID=99", 99, "deactivating"},
+		{"stopped", "MainPID=0\nActiveState=inactive", 0, "inactive"},
+		{"empty", "", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pid, state := parseMainPIDAndState(tt.output)
+			if pid != tt.wantPID || state != tt.wantState {
+				t.Errorf("got (%d, %q), want (%d, %q)", pid, state, tt.wantPID, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvChangeKind describes how one key differs between the local and remote env
+type EnvChangeKind string
+
+const (
+	// EnvAdded keys are set locally but missing on the server
+	EnvAdded EnvChangeKind = "added"
+	// EnvChanged keys are set on both sides with different values
+	EnvChanged EnvChangeKind = "changed"
+	// EnvUnchanged keys have the same value on both sides
+	EnvUnchanged EnvChangeKind = "unchanged"
+	// EnvKept keys are only on the server and are preserved
+	EnvKept EnvChangeKind = "kept"
+	// EnvRemoved keys are only on the server and are dropped (prune)
+	EnvRemoved EnvChangeKind = "removed"
+)
+
+// EnvChange is the state of one key in an EnvDiff
+type EnvChange struct {
+	Key    string
+	Kind   EnvChangeKind
+	Local  string
+	Remote string
+}
+
+// EnvDiff compares the locally resolved env with the server's shared env file
+type EnvDiff struct {
+	Changes []EnvChange
+}
+
+// DiffEnv compares local against remote, sorted by key. Keys only on the
+// server are kept unless prune is set.
+func DiffEnv(local, remote map[string]string, prune bool) EnvDiff {
+	keys := make(map[string]bool, len(local)+len(remote))
+	for key := range local {
+		keys[key] = true
+	}
+	for key := range remote {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var diff EnvDiff
+	for _, key := range sorted {
+		localValue, inLocal := local[key]
+		remoteValue, inRemote := remote[key]
+
+		change := EnvChange{Key: key, Local: localValue, Remote: remoteValue}
+		switch {
+		case inLocal && !inRemote:
+			change.Kind = EnvAdded
+		case !inLocal && prune:
+			change.Kind = EnvRemoved
+		case !inLocal:
+			change.Kind = EnvKept
+		case localValue != remoteValue:
+			change.Kind = EnvChanged
+		default:
+			change.Kind = EnvUnchanged
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	return diff
+}
+
+// Pending reports whether writing Merged() would change the server's env file
+func (d EnvDiff) Pending() bool {
+	for _, change := range d.Changes {
+		switch change.Kind {
+		case EnvAdded, EnvChanged, EnvRemoved:
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of keys with the given kind
+func (d EnvDiff) Count(kind EnvChangeKind) int {
+	count := 0
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Merged returns the env to write: every local value plus the server-only
+// keys that are kept
+func (d EnvDiff) Merged() map[string]string {
+	merged := make(map[string]string, len(d.Changes))
+	for _, change := range d.Changes {
+		switch change.Kind {
+		case EnvAdded, EnvChanged, EnvUnchanged:
+			merged[change.Key] = change.Local
+		case EnvKept:
+			merged[change.Key] = change.Remote
+		}
+	}
+	return merged
+}
+
+// Lines renders the diff one key per line, leaving out unchanged keys. Values
+// are masked unless showValues is set.
+func (d EnvDiff) Lines(showValues bool) []string {
+	var lines []string
+	for _, change := range d.Changes {
+		switch change.Kind {
+		case EnvAdded:
+			if showValues {
+				lines = append(lines, fmt.Sprintf("+ %s=%s", change.Key, displayEnvValue(change.Local)))
+			} else {
+				lines = append(lines, fmt.Sprintf("+ %s", change.Key))
+			}
+		case EnvChanged:
+			if showValues {
+				lines = append(lines, fmt.Sprintf("~ %s=%s (server: %s)", change.Key, displayEnvValue(change.Local), displayEnvValue(change.Remote)))
+			} else {
+				lines = append(lines, fmt.Sprintf("~ %s (value changed)", change.Key))
+			}
+		case EnvRemoved:
+			lines = append(lines, fmt.Sprintf("- %s (only on server, removed)", change.Key))
+		case EnvKept:
+			lines = append(lines, fmt.Sprintf("  %s (only on server, kept)", change.Key))
+		}
+	}
+	return lines
+}
+
+// displayEnvValue quotes values whose whitespace or control characters would
+// otherwise be invisible or break the line
+func displayEnvValue(value string) string {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, "\n\r\t\"") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// parseRemoteEnvironment parses the server's env file. It was written by
+// util.FormatDotenv, so references are not expanded again.
+func parseRemoteEnvironment(content string) map[string]string {
+	envVars, _ := util.ParseDotenv(content, false)
+	return envVars
+}
+
+// ReadEnvironmentFile returns the variables in the app's shared env file on
+// the server, or an empty map when the file does not exist yet
+func (e *Executor) ReadEnvironmentFile() (map[string]string, error) {
+	envPath := e.sharedEnvFile().Path
+	result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("sh -c 'cat %s 2>/dev/null || true'", envPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		return nil, formatSSHError("failed to read environment file", result)
+	}
+	return parseRemoteEnvironment(result.Stdout), nil
+}
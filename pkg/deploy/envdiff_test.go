@@ -0,0 +1,174 @@
+package deploy
+
+import (
+	"lightfold/pkg/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		local       map[string]string
+		remote      map[string]string
+		prune       bool
+		wantKinds   map[string]EnvChangeKind
+		wantMerged  map[string]string
+		wantPending bool
+	}{
+		{
+			name:        "identical",
+			local:       map[string]string{"A": "1"},
+			remote:      map[string]string{"A": "1"},
+			wantKinds:   map[string]EnvChangeKind{"A": EnvUnchanged},
+			wantMerged:  map[string]string{"A": "1"},
+			wantPending: false,
+		},
+		{
+			name:        "added and changed",
+			local:       map[string]string{"A": "1", "B": "new"},
+			remote:      map[string]string{"B": "old"},
+			wantKinds:   map[string]EnvChangeKind{"A": EnvAdded, "B": EnvChanged},
+			wantMerged:  map[string]string{"A": "1", "B": "new"},
+			wantPending: true,
+		},
+		{
+			name:        "server-only key kept by default",
+			local:       map[string]string{"A": "1"},
+			remote:      map[string]string{"A": "1", "SECRET": "s"},
+			wantKinds:   map[string]EnvChangeKind{"A": EnvUnchanged, "SECRET": EnvKept},
+			wantMerged:  map[string]string{"A": "1", "SECRET": "s"},
+			wantPending: false,
+		},
+		{
+			name:        "server-only key removed with prune",
+			local:       map[string]string{"A": "1"},
+			remote:      map[string]string{"A": "1", "SECRET": "s"},
+			prune:       true,
+			wantKinds:   map[string]EnvChangeKind{"A": EnvUnchanged, "SECRET": EnvRemoved},
+			wantMerged:  map[string]string{"A": "1"},
+			wantPending: true,
+		},
+		{
+			name:        "empty value differs from missing",
+			local:       map[string]string{"A": ""},
+			remote:      map[string]string{},
+			wantKinds:   map[string]EnvChangeKind{"A": EnvAdded},
+			wantMerged:  map[string]string{"A": ""},
+			wantPending: true,
+		},
+		{
+			name:        "nothing on the server yet",
+			local:       map[string]string{"A": "1"},
+			remote:      nil,
+			wantKinds:   map[string]EnvChangeKind{"A": EnvAdded},
+			wantMerged:  map[string]string{"A": "1"},
+			wantPending: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffEnv(tt.local, tt.remote, tt.prune)
+
+			kinds := make(map[string]EnvChangeKind)
+			for _, change := range diff.Changes {
+				kinds[change.Key] = change.Kind
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) {
+				t.Errorf("kinds = %v, want %v", kinds, tt.wantKinds)
+			}
+			if got := diff.Merged(); !reflect.DeepEqual(got, tt.wantMerged) {
+				t.Errorf("Merged() = %v, want %v", got, tt.wantMerged)
+			}
+			if diff.Pending() != tt.wantPending {
+				t.Errorf("Pending() = %v, want %v", diff.Pending(), tt.wantPending)
+			}
+		})
+	}
+}
+
+// TestDiffEnv_QuotedValuesRoundTrip checks that values written to the server
+// by util.FormatDotenv read back unchanged, so they do not show up as changed
+func TestDiffEnv_QuotedValuesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"spaces", "hello world"},
+		{"leading and trailing spaces", "  padded  "},
+		{"double quotes", `say "hi"`},
+		{"single quotes", "it's"},
+		{"hash", "abc#def"},
+		{"hash after space", "abc #def"},
+		{"equals sign", "a=b=c"},
+		{"backslash", `C:\path\to`},
+		{"escaped newline text", `line1\nline2`},
+		{"real newline", "line1\nline2"},
+		{"dollar reference", "${HOME}/app"},
+		{"bare dollar", "pa$$word"},
+		{"empty", ""},
+		{"json", `{"key": "value", "n": 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := map[string]string{"VALUE": tt.value}
+			remote := parseRemoteEnvironment(util.FormatDotenv(local))
+
+			diff := DiffEnv(local, remote, false)
+			if diff.Pending() {
+				t.Errorf("value %q read back as %q", tt.value, remote["VALUE"])
+			}
+		})
+	}
+}
+
+func TestEnvDiffLines(t *testing.T) {
+	diff := DiffEnv(
+		map[string]string{"ADDED": "secret-a", "CHANGED": "secret-new", "SAME": "x", "MULTI": "a\nb"},
+		map[string]string{"CHANGED": "secret-old", "SAME": "x", "MULTI": "a\nb", "KEPT": "secret-k"},
+		false,
+	)
+
+	masked := strings.Join(diff.Lines(false), "\n")
+	for _, want := range []string{"+ ADDED", "~ CHANGED (value changed)", "  KEPT (only on server, kept)"} {
+		if !strings.Contains(masked, want) {
+			t.Errorf("masked diff missing %q:\n%s", want, masked)
+		}
+	}
+	if strings.Contains(masked, "secret") {
+		t.Errorf("masked diff shows values:\n%s", masked)
+	}
+	if strings.Contains(masked, "SAME") || strings.Contains(masked, "MULTI") {
+		t.Errorf("unchanged keys should be left out:\n%s", masked)
+	}
+
+	shown := strings.Join(diff.Lines(true), "\n")
+	for _, want := range []string{"+ ADDED=secret-a", "~ CHANGED=secret-new (server: secret-old)"} {
+		if !strings.Contains(shown, want) {
+			t.Errorf("diff with values missing %q:\n%s", want, shown)
+		}
+	}
+
+	pruned := strings.Join(DiffEnv(nil, map[string]string{"KEPT": "v"}, true).Lines(false), "\n")
+	if pruned != "- KEPT (only on server, removed)" {
+		t.Errorf("pruned diff = %q", pruned)
+	}
+}
+
+func TestDisplayEnvValue(t *testing.T) {
+	tests := map[string]string{
+		"plain":   "plain",
+		"":        `""`,
+		" padded": `" padded"`,
+		"a\nb":    `"a\nb"`,
+		`say "x"`: `"say \"x\""`,
+	}
+	for value, want := range tests {
+		if got := displayEnvValue(value); got != want {
+			t.Errorf("displayEnvValue(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -1,18 +1,24 @@
 package deploy
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	_ "embed"
+	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
 	"lightfold/pkg/detector"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/proxy/nginx"
+	runtimepkg "lightfold/pkg/runtime"
 	installers "lightfold/pkg/runtime/installers"
 	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/timefmt"
+	"lightfold/pkg/util"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -38,6 +44,67 @@ type Executor struct {
 	deployOptions  *config.DeploymentOptions
 	outputCallback OutputCallback
 	startCommand   string
+	drainSeconds   int
+	noDrain        bool
+	packWorkers    int
+	releaseDigest  string
+	releaseSecrets []string
+	workers        int
+	threads        int
+	maxRequests    int
+	memoryMB       int
+	serverApps     int
+	// directAccess is set when ResolvePortExposure infers the app is reached
+	// on its own port
+	directAccess bool
+	lease        *Lease
+	leaseFile    leaseFile
+	deployedFile leaseFile
+	// rewritten are the backed-up files this executor replaced, restored when
+	// a deploy rolls back
+	rewritten []string
+	// sharedRuntimes are the runtime versions other apps on the server
+	// require, which runtime installs must leave in place
+	sharedRuntimes []state.RuntimeUse
+	// migrationCommand replaces the framework's migration command; migrated
+	// records that this deploy ran it
+	migrationCommand string
+	skipMigrations   bool
+	migrated         bool
+	// forceBuild runs builds the server lacks the memory for
+	forceBuild           bool
+	skipBuildMemoryCheck bool
+	// assets is where the framework's built assets are uploaded
+	assets *config.AssetsConfig
+	// proxyHost is the Host the health check sends through nginx after the
+	// app's own check passes; empty skips it
+	proxyHost string
+	health    state.DeployHealth
+	// deployPending is set while a deploy has switched releases but not yet
+	// passed its health checks; old releases are not pruned meanwhile
+	deployPending bool
+	// uploadedChecksum is the verified SHA-256 of the last tarball uploaded
+	uploadedChecksum string
+	// redirectFrom is the www or apex name the nginx site redirects to the
+	// domain
+	redirectFrom string
+	// disableHTTP2 and http3 are the protocols of the domain's HTTPS site
+	disableHTTP2 bool
+	http3        bool
+	// pathRoutes are other apps served under path prefixes on the domain
+	pathRoutes []proxy.PathRoute
+	// firstDeployCommands run once after the first healthy deploy;
+	// rerunFirstDeploy runs them even though the marker says they did
+	firstDeployCommands []string
+	rerunFirstDeploy    bool
+	firstDeploy         *state.FirstDeployRun
+	// baseDir is the directory the app is deployed under; empty means
+	// config.RemoteAppBaseDir
+	baseDir string
+	// aptWait bounds the wait for the apt/dpkg locks; killStaleApt stops an
+	// unattended-upgrades stuck holding them
+	aptWait      time.Duration
+	killStaleApt bool
 }
 
 // NewExecutor creates a new deployment executor
@@ -58,14 +125,57 @@ func NewExecutorWithOptions(sshExecutor *sshpkg.Executor, appName, projectPath s
 		projectPath:   projectPath,
 		detection:     detection,
 		deployOptions: deployOptions,
+		baseDir:       baseDirOf(deployOptions),
 	}
 }
 
+func baseDirOf(opts *config.DeploymentOptions) string {
+	if opts == nil {
+		return ""
+	}
+	return opts.BaseDir
+}
+
+// SetBaseDir sets the directory the app is deployed under (deploy.base_dir)
+// when the executor was created without deployment options
+func (e *Executor) SetBaseDir(dir string) {
+	e.baseDir = dir
+}
+
+// AppDir returns the app's directory on the server, e.g. /srv/<app>
+func (e *Executor) AppDir() string {
+	return config.AppDir(e.baseDir, e.appName)
+}
+
 // SetOutputCallback sets the callback for streaming command output
 func (e *Executor) SetOutputCallback(callback OutputCallback) {
 	e.outputCallback = callback
 }
 
+// SetSharedRuntimes records the runtime versions other apps on the server require
+func (e *Executor) SetSharedRuntimes(uses []state.RuntimeUse) {
+	e.sharedRuntimes = uses
+}
+
+// SetDomainRedirect sets the www or apex name the nginx site answers with a
+// 301 to the target's domain
+func (e *Executor) SetDomainRedirect(from string) {
+	e.redirectFrom = from
+}
+
+// SetDomainProtocols sets the HTTP/2 and HTTP/3 settings configure renders
+// the domain's HTTPS site with
+func (e *Executor) SetDomainProtocols(disableHTTP2, http3 bool) {
+	e.disableHTTP2 = disableHTTP2
+	e.http3 = http3
+}
+
+// SetPathRoutes sets the path prefixes on the app's domain that nginx proxies
+// to other apps on the server
+func (e *Executor) SetPathRoutes(routes []proxy.PathRoute) {
+	e.pathRoutes = routes
+}
+
 func (e *Executor) SetStartCommand(cmd string) {
 	e.startCommand = cmd
 }
@@ -86,11 +196,23 @@ func (e *Executor) sendOutput(output string, lastNLines int) {
 
 	for i := start; i < len(lines); i++ {
 		if strings.TrimSpace(lines[i]) != "" {
+			crash.Record(strings.TrimSpace(lines[i]))
 			e.outputCallback("  " + strings.TrimSpace(lines[i]))
 		}
 	}
 }
 
+// notify reports a progress or warning message through the callback when set,
+// otherwise prints it directly
+func (e *Executor) notify(msg string) {
+	crash.Record(msg)
+	if e.outputCallback != nil {
+		e.outputCallback("  " + msg)
+	} else {
+		fmt.Println(msg)
+	}
+}
+
 // formatSSHError creates a detailed error message from SSH command result
 func formatSSHError(operation string, result *sshpkg.CommandResult) error {
 	var details []string
@@ -113,44 +235,6 @@ func formatSSHError(operation string, result *sshpkg.CommandResult) error {
 	return fmt.Errorf("%s", operation)
 }
 
-// WaitForAptLock waits for apt/dpkg locks to be released (cloud-init might be running)
-func (e *Executor) WaitForAptLock(maxRetries int, retryDelay time.Duration) error {
-	// First, wait for cloud-init to finish completely
-	if e.outputCallback != nil {
-		e.outputCallback("  Waiting for cloud-init to complete...")
-	}
-	cloudInitCmd := fmt.Sprintf("timeout %d cloud-init status --wait 2>/dev/null || echo 'done'", int(config.DefaultCloudInitTimeout.Seconds()))
-	result := e.ssh.Execute(cloudInitCmd)
-	if result.Error != nil {
-		// cloud-init command not available or failed, continue anyway
-		if e.outputCallback != nil {
-			e.outputCallback("  cloud-init status check failed, proceeding...")
-		}
-	}
-
-	// Now wait for apt locks to be released
-	checkCmd := "sudo lsof /var/lib/dpkg/lock-frontend 2>/dev/null || sudo lsof /var/lib/apt/lists/lock 2>/dev/null"
-
-	for i := 0; i < maxRetries; i++ {
-		result := e.ssh.Execute(checkCmd)
-
-		// If no output, locks are free
-		if result.ExitCode == 1 || result.Stdout == "" {
-			return nil
-		}
-
-		// Locks are held, wait and retry
-		if i < maxRetries-1 {
-			if i == 0 && e.outputCallback != nil {
-				e.outputCallback("  Waiting for apt locks to be released...")
-			}
-			time.Sleep(retryDelay)
-		}
-	}
-
-	return fmt.Errorf("apt/dpkg locks still held after %d retries", maxRetries)
-}
-
 // InstallBasePackages installs required system packages
 func (e *Executor) InstallBasePackages() error {
 	e.ssh.ExecuteSudo("rm -f /var/lib/apt/lists/*_Commands-* 2>/dev/null || true")
@@ -200,9 +284,11 @@ func (e *Executor) InstallBasePackages() error {
 
 		ctx := &installers.Context{
 			SSH:       e.ssh,
+			Server:    e.serverAddress(),
 			Detection: e.detection,
 			Output:    e.outputCallback,
 			Tail:      tailFn,
+			Shared:    e.sharedRuntimes,
 		}
 
 		if err := installers.EnsureRuntimeInstalled(ctx); err != nil {
@@ -215,7 +301,7 @@ func (e *Executor) InstallBasePackages() error {
 
 // SetupDirectoryStructure creates the deployment directory structure
 func (e *Executor) SetupDirectoryStructure() error {
-	appPath := fmt.Sprintf("%s/%s", config.RemoteAppBaseDir, e.appName)
+	appPath := e.AppDir()
 
 	directories := []string{
 		appPath,
@@ -228,13 +314,13 @@ func (e *Executor) SetupDirectoryStructure() error {
 	}
 
 	for _, dir := range directories {
-		result := e.ssh.ExecuteSudo(fmt.Sprintf("mkdir -p %s", dir))
+		result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("mkdir -p %s", dir))
 		if result.Error != nil || result.ExitCode != 0 {
 			return fmt.Errorf("failed to create directory %s: %s", dir, result.Stderr)
 		}
 	}
 
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("chown -R deploy:deploy %s", appPath))
+	result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("chown -R deploy:deploy %s", appPath))
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to set ownership: %s", result.Stderr)
 	}
@@ -242,88 +328,16 @@ func (e *Executor) SetupDirectoryStructure() error {
 	return nil
 }
 
-func (e *Executor) CreateReleaseTarball(outputPath string) error {
-	tarFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create tarball: %w", err)
-	}
-	defer tarFile.Close()
-
-	gzipWriter := gzip.NewWriter(tarFile)
-	defer gzipWriter.Close()
-
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer tarWriter.Close()
-
-	// Use default ignore patterns from config
-	ignorePatterns := config.DefaultIgnorePatterns
-
-	err = filepath.WalkDir(e.projectPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(e.projectPath, path)
-		if err != nil {
-			return err
-		}
-
-		if relPath == "." {
-			return nil
-		}
-
-		for _, pattern := range ignorePatterns {
-			if matched, _ := filepath.Match(pattern, d.Name()); matched {
-				if d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			if strings.Contains(relPath, "/"+pattern+"/") || strings.HasPrefix(relPath, pattern+"/") {
-				if d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			return err
-		}
-
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-		if !d.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			if _, err := io.Copy(tarWriter, file); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	return err
+func (e *Executor) UploadRelease(tarballPath string) (string, error) {
+	return e.UploadReleaseAs(tarballPath, timefmt.ReleaseName(time.Now()))
 }
 
-func (e *Executor) UploadRelease(tarballPath string) (string, error) {
-	timestamp := time.Now().Format("20060102150405")
-	releasePath := fmt.Sprintf("%s/%s/releases/%s", config.RemoteAppBaseDir, e.appName, timestamp)
+// UploadReleaseAs uploads the tarball as the release with the given timestamp, so
+// servers of a multi-server target share release names
+func (e *Executor) UploadReleaseAs(tarballPath, timestamp string) (string, error) {
+	releasePath := fmt.Sprintf("%s/releases/%s", e.AppDir(), timestamp)
 
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("mkdir -p %s", releasePath))
+	result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("mkdir -p %s", releasePath))
 	if result.Error != nil {
 		return "", fmt.Errorf("failed to create release directory: %w", result.Error)
 	}
@@ -335,26 +349,96 @@ func (e *Executor) UploadRelease(tarballPath string) (string, error) {
 		return "", fmt.Errorf("failed to create release directory (exit code %d): %s", result.ExitCode, errMsg)
 	}
 
-	remoteTarball := fmt.Sprintf("/tmp/%s-release.tar.gz", e.appName)
-	if err := e.ssh.UploadFile(tarballPath, remoteTarball); err != nil {
-		return "", fmt.Errorf("failed to upload tarball: %w", err)
+	checksum, err := FileSHA256(tarballPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum tarball: %w", err)
+	}
+
+	remoteTarball := fmt.Sprintf("/tmp/lightfold-%s-release.tar.gz", e.appName)
+	if err := e.uploadVerified(tarballPath, remoteTarball, checksum); err != nil {
+		e.discardUpload(remoteTarball, releasePath)
+		return "", err
 	}
 
-	result = e.ssh.ExecuteSudo(fmt.Sprintf("tar -xzf %s -C %s", remoteTarball, releasePath))
+	result = e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("tar -xzf %s -C %s", remoteTarball, releasePath))
 	if result.Error != nil || result.ExitCode != 0 {
+		e.discardUpload(remoteTarball, releasePath)
 		return "", fmt.Errorf("failed to extract tarball: %s", result.Stderr)
 	}
 
-	e.ssh.Execute(fmt.Sprintf("rm %s", remoteTarball))
-	// Set ownership to deploy user (service runs as deploy)
-	result = e.ssh.ExecuteSudo(fmt.Sprintf("chown -R deploy:deploy %s", releasePath))
+	manifestCmd, err := releaseManifestCommand(releasePath, ReleaseManifest{TarballSHA256: checksum, UploadedAt: time.Now().UTC(), ReleaseSource: e.releaseSource()})
+	if err != nil {
+		e.discardUpload(remoteTarball, releasePath)
+		return "", err
+	}
+	result = e.ssh.ExecuteSudoIdempotent(manifestCmd)
 	if result.Error != nil || result.ExitCode != 0 {
-		return "", fmt.Errorf("failed to set ownership: %s", result.Stderr)
+		e.discardUpload(remoteTarball, releasePath)
+		return "", formatSSHError("failed to record release checksums", result)
+	}
+	e.uploadedChecksum = checksum
+
+	e.ssh.Execute(fmt.Sprintf("rm %s", remoteTarball))
+	if err := e.restrictReleasePermissions(releasePath); err != nil {
+		e.discardUpload(remoteTarball, releasePath)
+		return "", err
 	}
 
 	return releasePath, nil
 }
 
+// UploadedRelease returns the path of a release uploaded by an earlier,
+// interrupted push when it is still on the server
+func (e *Executor) UploadedRelease(timestamp string) (string, bool) {
+	releasePath := fmt.Sprintf("%s/releases/%s", e.AppDir(), timestamp)
+	result := e.ssh.ExecuteIdempotent(fmt.Sprintf("test -d %s", releasePath))
+	return releasePath, result.Error == nil && result.ExitCode == 0
+}
+
+// discardUpload removes what a failed upload left behind: the remote tarball
+// and the half-extracted release directory
+func (e *Executor) discardUpload(remoteTarball, releasePath string) {
+	for _, cmd := range failedUploadCleanupCommands(remoteTarball, releasePath) {
+		e.ssh.ExecuteSudoIdempotent(cmd)
+	}
+}
+
+func failedUploadCleanupCommands(remoteTarball, releasePath string) []string {
+	return []string{
+		fmt.Sprintf("rm -f %s", remoteTarball),
+		fmt.Sprintf("rm -rf %s", releasePath),
+	}
+}
+
+// RemoveFailedRelease deletes a release that never went live. It refuses to
+// touch anything outside the app's releases directory or the current release.
+func (e *Executor) RemoveFailedRelease(releasePath string) error {
+	releasesDir := fmt.Sprintf("%s/releases/", e.AppDir())
+	name := strings.TrimPrefix(releasePath, releasesDir)
+	if name == releasePath || name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("refusing to remove %s: not a release of %s", releasePath, e.appName)
+	}
+
+	if current, _ := e.GetCurrentRelease(); current == releasePath {
+		return fmt.Errorf("refusing to remove %s: it is the current release", releasePath)
+	}
+
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("rm -rf %s", releasePath))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to remove release %s: %s", name, result.Stderr)
+	}
+	return nil
+}
+
+// staleTempSweepCommand deletes lightfold's /tmp files (release tarballs, env
+// and image uploads) that failed deploys left behind more than a day ago
+const staleTempSweepCommand = "find /tmp -maxdepth 1 -name 'lightfold-*' -mmin +1440 -exec rm -rf {} +"
+
+// SweepStaleTempFiles removes leftovers of earlier failed deploys from /tmp
+func (e *Executor) SweepStaleTempFiles() {
+	e.ssh.ExecuteSudoIdempotent(staleTempSweepCommand)
+}
+
 func (e *Executor) BuildRelease(releasePath string) error {
 	return e.BuildReleaseWithEnv(releasePath, nil)
 }
@@ -390,33 +474,32 @@ func (e *Executor) isStaticSite() bool {
 	return ok && deploymentType == "static"
 }
 
-func (e *Executor) BuildReleaseWithEnv(releasePath string, envVars map[string]string) error {
-	buildPlan := e.getBuildPlan()
-	if len(buildPlan) == 0 {
-		return nil
-	}
-
-	// Load local .env file and merge with provided envVars
-	localEnvVars := e.LoadLocalEnvFile()
-	if envVars == nil {
-		envVars = make(map[string]string)
-	}
-	// Merge: provided envVars take precedence over local .env
-	for key, value := range localEnvVars {
-		if _, exists := envVars[key]; !exists {
-			envVars[key] = value
+// ReleaseEnvironment merges the local .env file into envVars, which take
+// precedence. Local .env files are kept out of the tarball, so this is how
+// their values reach the release.
+func (e *Executor) ReleaseEnvironment(envVars map[string]string) map[string]string {
+	merged := e.LoadLocalEnvFile()
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	for key, value := range e.AssetEnvironment() {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
 		}
 	}
+	for key, value := range envVars {
+		merged[key] = value
+	}
+	return merged
+}
+
+func (e *Executor) BuildReleaseWithEnv(releasePath string, envVars map[string]string) error {
+	envVars = e.ReleaseEnvironment(envVars)
 
 	// Write .env file to release directory BEFORE building (needed for Next.js NEXT_PUBLIC_* vars)
 	if len(envVars) > 0 {
-		var envContent strings.Builder
-		for key, value := range envVars {
-			envContent.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-		}
-
 		envPath := fmt.Sprintf("%s/.env", releasePath)
-		if err := e.ssh.WriteRemoteFile(envPath, envContent.String(), config.PermEnvFile); err != nil {
+		if err := e.ssh.WriteRemoteFileAtomic(envPath, util.FormatDotenv(envVars), config.PermEnvFile); err != nil {
 			return fmt.Errorf("failed to write .env for build: %w", err)
 		}
 
@@ -424,8 +507,18 @@ func (e *Executor) BuildReleaseWithEnv(releasePath string, envVars map[string]st
 		e.ssh.ExecuteSudo(fmt.Sprintf("chown deploy:deploy %s", envPath))
 	}
 
+	buildPlan := e.getBuildPlan()
+	if len(buildPlan) == 0 {
+		return nil
+	}
+
+	// Compose images are built by `docker compose up --build` during deploy
+	if e.isComposeProject() {
+		return nil
+	}
+
 	if e.detection != nil && e.detection.Language == "Python" {
-		venvPath := fmt.Sprintf("%s/%s/shared/venv", config.RemoteAppBaseDir, e.appName)
+		venvPath := fmt.Sprintf("%s/shared/venv", e.AppDir())
 		result := e.ssh.ExecuteSudo(fmt.Sprintf("python3 -m venv %s", venvPath))
 		if result.Error != nil || result.ExitCode != 0 {
 			return fmt.Errorf("failed to create venv: %s", result.Stderr)
@@ -442,9 +535,16 @@ func (e *Executor) BuildReleaseWithEnv(releasePath string, envVars map[string]st
 		buildCmd := e.adjustBuildCommand(cmd, releasePath)
 
 		pathPrefix := e.getPackageManagerPath()
-		fullCmd := fmt.Sprintf("cd %s && %s%s", releasePath, pathPrefix, buildCmd)
+		cacheEnv := detector.MonorepoCacheEnvPrefix(e.detection, envVars)
+		fullCmd := fmt.Sprintf("cd %s && %s%s%s", releasePath, pathPrefix, cacheEnv, buildCmd)
 
 		result := e.ssh.Execute(fullCmd)
+		// A build may have been half done when the connection dropped, so it
+		// is not re-run behind the caller's back
+		if sshpkg.IsConnectionLost(result.Error) {
+			e.sendOutput(result.Stdout, 15)
+			return fmt.Errorf("build command '%s' interrupted: %w", cmd, result.Error)
+		}
 		if result.Error != nil || result.ExitCode != 0 {
 			e.sendOutput(result.Stdout, 15)
 			e.sendOutput(result.Stderr, 15)
@@ -469,26 +569,51 @@ func (e *Executor) BuildReleaseWithEnv(releasePath string, envVars map[string]st
 			return fmt.Errorf("build command failed '%s' (exit code %d): %s", cmd, result.ExitCode, errorOutput)
 		}
 
+		if tool := runtimepkg.CommandTool(cmd); tool != "" {
+			runtimepkg.RecordToolInstalled(e.serverAddress(), tool, true)
+		}
 		e.sendOutput(result.Stdout, 5)
 	}
 
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("chown -R deploy:deploy %s", releasePath))
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to set ownership to deploy: %s", result.Stderr)
+	return e.restrictReleasePermissions(releasePath)
+}
+
+// releasePermissionCommands hands the release to the deploy user and closes it
+// to other users whatever modes the tarball carried. The www-data group keeps
+// nginx able to serve static files from it.
+func releasePermissionCommands(releasePath string) []string {
+	return []string{
+		fmt.Sprintf("chown -R deploy:www-data %s 2>/dev/null || chown -R deploy:deploy %s", releasePath, releasePath),
+		fmt.Sprintf("chmod 750 %s", releasePath),
+		fmt.Sprintf("chmod -R o-rwx %s", releasePath),
 	}
+}
 
+func (e *Executor) restrictReleasePermissions(releasePath string) error {
+	for _, cmd := range releasePermissionCommands(releasePath) {
+		result := e.ssh.ExecuteSudoIdempotent(cmd)
+		if result.Error != nil || result.ExitCode != 0 {
+			return fmt.Errorf("failed to set release permissions: %s", result.Stderr)
+		}
+	}
 	return nil
 }
 
 // LoadLocalEnvFile searches for and loads environment variables from local .env files
 // Priority order: .env.production -> .env.prod -> .env
+// Lines that cannot be parsed are skipped with a warning.
 func (e *Executor) LoadLocalEnvFile() map[string]string {
 	envFilePath := e.FindEnvFile()
 	if envFilePath == "" {
 		return nil
 	}
 
-	return ParseEnvFile(envFilePath)
+	envVars, warnings := ParseEnvFileWithWarnings(envFilePath)
+	for _, warning := range warnings {
+		e.notify(fmt.Sprintf("Warning: skipped %s %s", filepath.Base(envFilePath), warning))
+	}
+
+	return envVars
 }
 
 // FindEnvFile searches for .env files in priority order
@@ -512,175 +637,79 @@ func (e *Executor) FindEnvFile() string {
 
 // ParseEnvFile reads and parses a .env file into a map
 func ParseEnvFile(path string) map[string]string {
+	envVars, _ := ParseEnvFileWithWarnings(path)
+	return envVars
+}
+
+// ParseEnvFileWithWarnings parses a .env file, expanding references to earlier keys,
+// and also returns the lines that were skipped
+func ParseEnvFileWithWarnings(path string) (map[string]string, []util.DotenvWarning) {
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
 
-	envVars := make(map[string]string)
-	lines := strings.Split(string(content), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-
-			// Remove quotes if present
-			value = strings.Trim(value, `"'`)
-
-			envVars[key] = value
-		}
-	}
-
-	return envVars
+	return util.ParseDotenv(string(content), true)
 }
 
-// getPackageManagerPath returns the PATH prefix needed for package managers
+// getPackageManagerPath returns the PATH prefix build commands run behind
 func (e *Executor) getPackageManagerPath() string {
 	if e.detection == nil {
 		return ""
 	}
-
-	if e.detection.Language == "JavaScript/TypeScript" {
-		basePath := "export PATH=\"/usr/bin:$PATH\" && export NODE=\"/usr/bin/node\" && hash -r && "
-
-		pm, ok := e.detection.Meta["package_manager"]
-		if !ok {
-			return basePath
-		}
-
-		switch pm {
-		case "bun":
-			return basePath + "export PATH=\"$HOME/.bun/bin:$PATH\" && "
-		case "pnpm":
-			return basePath + "export PNPM_HOME=\"$HOME/.local/share/pnpm\" && export PATH=\"$PNPM_HOME:$PATH\" && "
-		case "yarn":
-			return basePath
-		default:
-			return basePath
-		}
-	}
-
-	pm, ok := e.detection.Meta["package_manager"]
-	if !ok {
-		return ""
-	}
-
-	switch pm {
-	case "poetry", "uv", "pipenv":
-		return "export PATH=\"$HOME/.local/bin:$PATH\" && "
-	default:
-		return ""
-	}
+	return runtimepkg.BuildPathPrefix(e.detection.Language, e.detection.Meta["package_manager"], e.detection.Meta["runtime_version"])
 }
 
+// adjustBuildCommand installs the tool a build command runs when it may be
+// missing and points pip at the app's virtualenv
 func (e *Executor) adjustBuildCommand(cmd string, _ string) string {
 	if e.detection == nil {
 		return cmd
 	}
-
-	if pm, ok := e.detection.Meta["package_manager"]; ok {
-		switch pm {
-		case "bun":
-			if strings.Contains(cmd, "bun") {
-				return "(command -v bun >/dev/null 2>&1 || curl -fsSL https://bun.sh/install | bash) && " + cmd
-			}
-		case "pnpm":
-			if strings.Contains(cmd, "pnpm") {
-				return "npm install -g pnpm && " + cmd
-			}
-		case "poetry":
-			if strings.Contains(cmd, "poetry") {
-				return "pip3 install poetry && " + cmd
-			}
-		case "uv":
-			if strings.Contains(cmd, "uv") {
-				return "pip3 install uv && " + cmd
-			}
-		case "pipenv":
-			if strings.Contains(cmd, "pipenv") {
-				return "pip3 install pipenv && " + cmd
-			}
-		}
+	cmd = e.withStaticRoot(cmd)
+	if e.deployOptions != nil && e.deployOptions.AllowLockfileChanges {
+		cmd = detector.UnfreezeJSInstallCommand(cmd)
 	}
 
-	switch e.detection.Language {
-	case "Python":
-		if strings.Contains(cmd, "pip install") {
-			venvPath := fmt.Sprintf("%s/%s/shared/venv", config.RemoteAppBaseDir, e.appName)
-			return strings.Replace(cmd, "pip install", fmt.Sprintf("%s/bin/pip install", venvPath), 1)
-		}
-		if strings.Contains(cmd, "poetry install") {
-			return "pip3 install poetry && poetry install"
-		}
-		if strings.Contains(cmd, "uv") {
-			return "pip3 install uv && " + cmd
-		}
-
-	case "JavaScript/TypeScript":
-		if strings.Contains(cmd, "pnpm install") {
-			return "npm install -g pnpm && " + cmd
-		}
-		if strings.Contains(cmd, "bun install") {
-			return "(command -v bun >/dev/null 2>&1 || curl -fsSL https://bun.sh/install | bash) && " + cmd
-		}
-		if strings.Contains(cmd, "npm install") || strings.Contains(cmd, "yarn install") {
-			return cmd
-		}
-
-	case "Go":
-		return cmd
+	if runtimepkg.CommandTool(cmd) != "" {
+		return runtimepkg.EnsureToolCommand(e.serverAddress(), cmd, e.getPackageManagerPath())
+	}
 
-	case "Ruby":
-		if strings.Contains(cmd, "bundle install") {
-			return "gem install bundler && " + cmd
-		}
+	if e.detection.Language == "Python" && strings.Contains(cmd, "pip install") {
+		venvPath := fmt.Sprintf("%s/shared/venv", e.AppDir())
+		return strings.Replace(cmd, "pip install", fmt.Sprintf("%s/bin/pip install", venvPath), 1)
 	}
 
 	return cmd
 }
 
+// serverAddress keys per-server caches; it is empty without a connection
+func (e *Executor) serverAddress() string {
+	if e.ssh == nil {
+		return ""
+	}
+	return e.ssh.Host
+}
+
 func (e *Executor) WriteEnvironmentFile(envVars map[string]string) error {
 	if len(envVars) == 0 {
 		return nil
 	}
+	return e.ReplaceEnvironmentFile(envVars)
+}
 
-	var envContent strings.Builder
-	for key, value := range envVars {
-		envContent.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-	}
-
-	tmpEnvPath := "/tmp/lightfold.env"
-	if err := e.ssh.WriteRemoteFile(tmpEnvPath, envContent.String(), config.PermEnvFile); err != nil {
+// ReplaceEnvironmentFile writes envVars as the shared env file even when there
+// are none, so `push --env-sync --prune` can empty it
+func (e *Executor) ReplaceEnvironmentFile(envVars map[string]string) error {
+	if err := e.installFile(e.sharedEnvFile(), util.FormatDotenv(envVars)); err != nil {
 		return fmt.Errorf("failed to write environment file: %w", err)
 	}
-
-	finalEnvPath := fmt.Sprintf("%s/%s/shared/env/.env", config.RemoteAppBaseDir, e.appName)
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("mv %s %s", tmpEnvPath, finalEnvPath))
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to move env file to final location: %s", result.Stderr)
-	}
-
-	result = e.ssh.ExecuteSudo(fmt.Sprintf("chown deploy:deploy %s", finalEnvPath))
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to set env file ownership: %s", result.Stderr)
-	}
-
 	return nil
 }
 
 func (e *Executor) GetCurrentRelease() (string, error) {
-	currentLink := fmt.Sprintf("%s/%s/current", config.RemoteAppBaseDir, e.appName)
-	result := e.ssh.Execute(fmt.Sprintf("readlink -f %s", currentLink))
+	currentLink := fmt.Sprintf("%s/current", e.AppDir())
+	result := e.ssh.ExecuteIdempotent(fmt.Sprintf("readlink -f %s", currentLink))
 	if result.Error != nil || result.ExitCode != 0 {
 		return "", nil
 	}
@@ -688,8 +717,8 @@ func (e *Executor) GetCurrentRelease() (string, error) {
 }
 
 func (e *Executor) ListReleases() ([]string, error) {
-	releasesPath := fmt.Sprintf("%s/%s/releases", config.RemoteAppBaseDir, e.appName)
-	result := e.ssh.Execute(fmt.Sprintf("ls -1t %s", releasesPath))
+	releasesPath := fmt.Sprintf("%s/releases", e.AppDir())
+	result := e.ssh.ExecuteIdempotent(fmt.Sprintf("ls -1t %s", releasesPath))
 	if result.Error != nil || result.ExitCode != 0 {
 		return []string{}, nil
 	}
@@ -703,20 +732,25 @@ func (e *Executor) ListReleases() ([]string, error) {
 	return releases, nil
 }
 
+// CleanupOldReleases deletes releases beyond the newest keepCount. The linked
+// release and the one before it are always kept so a failed deploy has a
+// release to roll back to.
 func (e *Executor) CleanupOldReleases(keepCount int) error {
+	if e.deployPending {
+		return fmt.Errorf("not pruning releases before the deploy passed its health checks")
+	}
+
 	releases, err := e.ListReleases()
 	if err != nil {
 		return err
 	}
-
-	if len(releases) <= keepCount {
-		return nil
+	current, err := e.GetCurrentRelease()
+	if err != nil {
+		return err
 	}
 
-	toDelete := releases[keepCount:]
-
-	for _, release := range toDelete {
-		releasePath := fmt.Sprintf("%s/%s/releases/%s", config.RemoteAppBaseDir, e.appName, release)
+	for _, release := range releasesToPrune(releases, keepCount, current) {
+		releasePath := fmt.Sprintf("%s/releases/%s", e.AppDir(), release)
 		result := e.ssh.ExecuteSudo(fmt.Sprintf("rm -rf %s", releasePath))
 		if result.Error != nil || result.ExitCode != 0 {
 			return fmt.Errorf("failed to delete release %s: %s", release, result.Stderr)
@@ -726,67 +760,131 @@ func (e *Executor) CleanupOldReleases(keepCount int) error {
 	return nil
 }
 
+// releasesToPrune returns the releases, listed newest first, beyond the newest
+// keepCount. The current release and the one before it are never pruned,
+// whatever keepCount is.
+func releasesToPrune(releases []string, keepCount int, current string) []string {
+	protected := map[string]bool{}
+	if current != "" {
+		current = path.Base(current)
+		protected[current] = true
+		for i, release := range releases {
+			if release == current && i+1 < len(releases) {
+				protected[releases[i+1]] = true
+			}
+		}
+	}
+
+	if keepCount < 0 {
+		keepCount = 0
+	}
+	prune := []string{}
+	for i, release := range releases {
+		if i >= keepCount && !protected[release] {
+			prune = append(prune, release)
+		}
+	}
+	return prune
+}
+
 func (e *Executor) GenerateSystemdUnit(releasePath string) error {
 	return e.GenerateSystemdUnitWithPort(releasePath, config.DefaultApplicationPort)
 }
 
 func (e *Executor) GenerateSystemdUnitWithPort(releasePath string, port int) error {
-	// Static sites don't need a systemd service (nginx serves files directly)
-	if e.isStaticSite() {
+	// Static sites don't need a systemd service (nginx serves files directly),
+	// and compose projects are supervised by Docker
+	if e.isStaticSite() || e.isComposeProject() {
 		return nil
 	}
 
 	execStart := e.getExecStartCommand()
 
 	data := map[string]string{
-		"APP_NAME":   e.appName,
-		"EXEC_START": execStart,
-		"PORT":       fmt.Sprintf("%d", port),
+		"APP_NAME":          e.appName,
+		"APP_DIR":           e.AppDir(),
+		"EXEC_START":        execStart,
+		"PORT":              fmt.Sprintf("%d", port),
+		"EXTRA_ENVIRONMENT": e.runtimeEnvironment() + e.startEnvironment(port) + e.tuningEnvironment() + e.staticEnvironmentLines(),
+		"KILL_SIGNAL":       "SIGTERM",
+		"TIMEOUT_STOP_SEC":  e.stopTimeout(),
 	}
 
-	tmpPath := fmt.Sprintf("/tmp/%s.service", e.appName)
-	if err := e.ssh.RenderAndWriteTemplate(systemdTemplate, data, tmpPath, config.PermConfigFile); err != nil {
-		return fmt.Errorf("failed to write systemd unit to temp: %w", err)
+	if err := e.installFile(e.systemdUnitFile(), sshpkg.RenderTemplate(systemdTemplate, data)); err != nil {
+		return fmt.Errorf("failed to write systemd unit: %w", err)
 	}
 
-	unitPath := fmt.Sprintf("/etc/systemd/system/%s.service", e.appName)
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("mv %s %s", tmpPath, unitPath))
+	result := e.ssh.ExecuteSudo("systemctl daemon-reload")
 	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to move systemd unit to /etc: %s", result.Stderr)
+		return fmt.Errorf("failed to reload systemd: %s", result.Stderr)
 	}
 
-	result = e.ssh.ExecuteSudo(fmt.Sprintf("chown root:root %s", unitPath))
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to set systemd unit ownership: %s", result.Stderr)
-	}
+	return nil
+}
 
-	result = e.ssh.ExecuteSudo("systemctl daemon-reload")
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to reload systemd: %s", result.Stderr)
+// startEnvironment renders the extra Environment= lines an SSR adapter's server needs
+// (e.g. HOST for adapter-node and remix-serve) from the detected start_env, and
+// HOSTNAME for Next.js standalone builds, whose server.js listens on all
+// interfaces without it. A custom start command brings its own environment, so
+// nothing is added for it.
+func (e *Executor) startEnvironment(port int) string {
+	if e.startCommand != "" || e.detection == nil || e.detection.Meta == nil {
+		return ""
 	}
 
-	return nil
+	var lines []string
+	for _, assignment := range strings.Fields(e.detection.Meta["start_env"]) {
+		if assignment == "PORT=$PORT" {
+			continue
+		}
+		assignment = strings.ReplaceAll(assignment, "$PORT", fmt.Sprintf("%d", port))
+		assignment = bindAssignment(assignment, e.BindAddress())
+		lines = append(lines, "\nEnvironment="+assignment)
+	}
+	if e.detection.Framework == "Next.js" && e.detection.Meta["output_mode"] == "standalone" {
+		lines = append(lines, "\nEnvironment=HOSTNAME="+e.BindAddress())
+	}
+	return strings.Join(lines, "")
 }
 
-// adjustPackageManagerPath replaces package manager commands with full paths
-// This is necessary because systemd doesn't execute with user's shell environment
-// Only replaces if the command starts with the package manager name
-func adjustPackageManagerPath(runCommand, packageManager string) string {
-	// Get package manager path from config
-	fullPath := config.GetPackageManagerPath(packageManager)
-	if fullPath == packageManager {
-		// No specific path configured for this package manager
-		return runCommand
+// nodePathDir returns the directory of the Node.js major the app pins, or ""
+// when it runs on the server's default node
+func (e *Executor) nodePathDir() string {
+	if e.detection == nil || e.detection.Language != "JavaScript/TypeScript" {
+		return ""
 	}
+	return runtimepkg.NodePathDir(e.detection.Meta["runtime_version"])
+}
 
-	if strings.HasPrefix(runCommand, packageManager+" ") {
-		return strings.Replace(runCommand, packageManager+" ", fullPath+" ", 1)
+// runtimeEnvironment puts the app's pinned Node.js major ahead of the default
+// node on the service's PATH, so apps sharing a server each run their own version
+func (e *Executor) runtimeEnvironment() string {
+	dir := e.nodePathDir()
+	if dir == "" {
+		return ""
 	}
+	return "\nEnvironment=PATH=" + dir + ":" + runtimepkg.SystemPath
+}
 
-	return runCommand
+// nodeCommand returns how the app's entrypoint is started with node. Yarn
+// Plug'n'Play installs have no node_modules, so node runs through yarn to
+// load the .pnp.cjs resolver. Apps pinning a major look node up on their PATH.
+func (e *Executor) nodeCommand() string {
+	if e.detection != nil && e.detection.Meta["yarn_linker"] == "pnp" {
+		return runtimepkg.BinaryPath("yarn") + " node"
+	}
+	if e.nodePathDir() != "" {
+		return "/usr/bin/env node"
+	}
+	return runtimepkg.BinaryPath("node")
 }
 
 func (e *Executor) getExecStartCommand() string {
+	userSupplied := e.startCommand == "" && e.deployOptions != nil && len(e.deployOptions.RunCommands) > 0
+	return bindStartCommand(e.tuneStartCommand(e.baseExecStartCommand(), userSupplied), e.BindAddress())
+}
+
+func (e *Executor) baseExecStartCommand() string {
 	if e.startCommand != "" {
 		return e.startCommand
 	}
@@ -796,12 +894,21 @@ func (e *Executor) getExecStartCommand() string {
 		runCommand := runPlan[0]
 
 		if e.detection != nil && e.detection.Language == "JavaScript/TypeScript" {
-			pm := e.detection.Meta["package_manager"]
-			return adjustPackageManagerPath(runCommand, pm)
+			// Detected SSR servers are started directly; user-supplied run commands are left as written
+			if e.deployOptions == nil || len(e.deployOptions.RunCommands) == 0 {
+				if strings.HasPrefix(runCommand, "node ") {
+					return strings.Replace(runCommand, "node ", e.nodeCommand()+" ", 1)
+				}
+				// Binaries installed by the app (e.g. remix-serve) live in the release's node_modules
+				if strings.HasPrefix(runCommand, "remix-serve ") {
+					return fmt.Sprintf("%s/current/node_modules/.bin/%s", e.AppDir(), runCommand)
+				}
+			}
+			return runtimepkg.ExecStartCommand(runCommand)
 		}
 
 		if e.detection != nil && e.detection.Language == "Python" {
-			venvBin := fmt.Sprintf("%s/%s/shared/venv/bin", config.RemoteAppBaseDir, e.appName)
+			venvBin := fmt.Sprintf("%s/shared/venv/bin", e.AppDir())
 			if strings.Contains(runCommand, "uvicorn ") {
 				return strings.Replace(runCommand, "uvicorn ", venvBin+"/uvicorn ", 1)
 			}
@@ -819,22 +926,22 @@ func (e *Executor) getExecStartCommand() string {
 
 	framework := e.detection.Framework
 	language := e.detection.Language
-	appPath := fmt.Sprintf("%s/%s", config.RemoteAppBaseDir, e.appName)
+	appPath := e.AppDir()
 
 	switch language {
 	case "Python":
 		venvBin := fmt.Sprintf("%s/shared/venv/bin", appPath)
 		switch framework {
 		case "Django":
-			return fmt.Sprintf("%s/gunicorn --bind %s:$PORT --workers %d wsgi:application", venvBin, config.DefaultBindAddress, config.DefaultWorkerCount)
+			return fmt.Sprintf("%s/gunicorn --bind %s:$PORT --workers %d wsgi:application", venvBin, config.DefaultBindAddress, e.workerCount())
 		case "FastAPI":
-			return fmt.Sprintf("%s/uvicorn main:app --host %s --port $PORT --workers %d", venvBin, config.DefaultBindAddress, config.DefaultWorkerCount)
+			return fmt.Sprintf("%s/uvicorn main:app --host %s --port $PORT --workers %d", venvBin, config.DefaultBindAddress, e.workerCount())
 		case "Flask":
-			return fmt.Sprintf("%s/gunicorn --bind %s:$PORT --workers %d app:app", venvBin, config.DefaultBindAddress, config.DefaultWorkerCount)
+			return fmt.Sprintf("%s/gunicorn --bind %s:$PORT --workers %d app:app", venvBin, config.DefaultBindAddress, e.workerCount())
 		}
 
 	case "JavaScript/TypeScript":
-		nodePath := config.GetPackageManagerPath("node")
+		nodePath := e.nodeCommand()
 		switch framework {
 		case "Next.js":
 			return fmt.Sprintf("%s %s/current/.next/standalone/server.js", nodePath, appPath)
@@ -859,55 +966,135 @@ func (e *Executor) getExecStartCommand() string {
 // GenerateNginxConfig creates an nginx configuration (reverse proxy for SSR or static file server for static sites)
 // If domain is empty, nginx configuration is skipped (app listens directly on port)
 func (e *Executor) GenerateNginxConfig(port int, domain string) error {
+	// Read before the app's site is rewritten, which may be where certbot
+	// installed the certificate
+	certPath, keyPath := e.domainCertificate(domain)
+
+	template, data := e.nginxTemplateData(port, domain)
+
+	staticPaths := e.StaticPaths()
+	// Static sites have no app to fall back to for files missing on disk
+	fallbackPort := port
+	if e.isStaticSite() {
+		fallbackPort = 0
+	}
+	data["STATIC_LOCATIONS"] = nginx.StaticLocations(staticPaths, fallbackPort)
+	if len(staticPaths) > 0 {
+		if err := e.ConfigureStaticPathPermissions(staticPaths); err != nil {
+			return err
+		}
+	}
+
+	site, err := e.keepMaintenance(e.nginxSiteFile().Path, sshpkg.RenderTemplate(template, data))
+	if err != nil {
+		return fmt.Errorf("failed to keep maintenance mode in the nginx config: %w", err)
+	}
+	if err := e.installFile(e.nginxSiteFile(), site); err != nil {
+		return fmt.Errorf("failed to write nginx config: %w", err)
+	}
+
+	symlinkCmd := fmt.Sprintf("ln -sf /etc/nginx/sites-available/%s /etc/nginx/sites-enabled/%s", e.appName, e.appName)
+	result := e.ssh.ExecuteSudo(symlinkCmd)
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to enable nginx site: %s", result.Stderr)
+	}
+
+	// Only remove default site if we have a domain configured
+	e.ssh.ExecuteSudo("rm -f /etc/nginx/sites-enabled/default")
+
+	if certPath != "" {
+		return e.generateDomainSite(port, domain, certPath, keyPath)
+	}
+	return nil
+}
+
+// domainCertificate returns the certificate of the HTTPS site domain add
+// installed for domain, looking in the domain site and then in the app's own
+// site. Domains without one are left to domain add.
+func (e *Executor) domainCertificate(domain string) (certPath, keyPath string) {
+	if domain == "" {
+		return "", ""
+	}
+	for _, path := range []string{e.domainSiteFile().Path, e.nginxSiteFile().Path} {
+		site := e.ssh.ExecuteSudo("cat " + path)
+		if site.Error != nil || site.ExitCode != 0 || !slices.Contains(nginx.ServerNames(site.Stdout), domain) {
+			continue
+		}
+		if certPath, keyPath = nginx.CertificatePaths(site.Stdout); certPath != "" && keyPath != "" {
+			return certPath, keyPath
+		}
+	}
+	return "", ""
+}
+
+// generateDomainSite renders the domain's HTTPS site through the nginx proxy
+// manager, as domain add does, so configure keeps HTTPS with the domain's
+// HTTP/2 and HTTP/3 settings and the app's current port and routes
+func (e *Executor) generateDomainSite(port int, domain, certPath, keyPath string) error {
+	proxyConfig := proxy.ProxyConfig{
+		Domain:       domain,
+		Port:         port,
+		AppName:      e.appName,
+		SSLEnabled:   true,
+		SSLCertPath:  certPath,
+		SSLKeyPath:   keyPath,
+		PathRoutes:   e.pathRoutes,
+		StaticPaths:  e.StaticPaths(),
+		RedirectFrom: e.redirectFrom,
+		DisableHTTP2: e.disableHTTP2,
+		HTTP3:        e.http3,
+	}
+	proxyConfig.StaticRoot, proxyConfig.OutputDirs = StaticSiteFor(e.detection, e.AppDir(), e.deployOptions)
+
+	manager := nginx.NewManager(e.ssh)
+	domainSite := e.domainSiteFile()
+	domainSite.Backup = true
+	site, err := e.keepMaintenance(domainSite.Path, manager.SiteConfig(proxyConfig))
+	if err != nil {
+		return fmt.Errorf("failed to keep maintenance mode in the HTTPS site: %w", err)
+	}
+	if err := e.installFile(domainSite, site); err != nil {
+		return fmt.Errorf("failed to write the HTTPS site: %w", err)
+	}
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("ln -sf %s %s", domainSite.Path, manager.GetEnabledPath(e.appName)))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to enable the HTTPS site: %s", result.Stderr)
+	}
+	return nil
+}
+
+// nginxTemplateData picks the nginx template for the app and fills in everything
+// but the static path locations. Static sites get the file-serving template
+// rooted at their build output instead of a reverse proxy.
+func (e *Executor) nginxTemplateData(port int, domain string) (string, map[string]string) {
 	data := map[string]string{
 		"APP_NAME": e.appName,
+		"APP_DIR":  e.AppDir(),
 		"PORT":     fmt.Sprintf("%d", port),
 	}
+	data["PATH_ROUTES"] = nginx.PathRouteLocations(e.pathRoutes)
 
 	// If no domain, use default_server to catch all requests
 	if domain == "" {
 		data["SERVER_NAME"] = "_"
 		data["DEFAULT_SERVER"] = "default_server"
+		data["REDIRECT_SERVER"] = ""
 	} else {
 		data["SERVER_NAME"] = domain
 		data["DEFAULT_SERVER"] = ""
+		data["REDIRECT_SERVER"] = nginx.RedirectServer(e.redirectFrom, domain)
 	}
 
 	// Use different templates for static vs SSR sites
 	template := nginxTemplate
 	if e.isStaticSite() {
 		template = nginxStaticTemplate
-		// Get build output directory from detection metadata
-		buildOutput := "dist/"
-		if e.detection != nil && e.detection.Meta != nil {
-			if output, ok := e.detection.Meta["build_output"]; ok {
-				buildOutput = output
-			}
-		}
-		data["BUILD_OUTPUT"] = buildOutput
-	}
-
-	tmpPath := fmt.Sprintf("/tmp/nginx-%s.conf", e.appName)
-	if err := e.ssh.RenderAndWriteTemplate(template, data, tmpPath, config.PermConfigFile); err != nil {
-		return fmt.Errorf("failed to write nginx config to temp: %w", err)
-	}
-
-	configPath := fmt.Sprintf("/etc/nginx/sites-available/%s", e.appName)
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("mv %s %s", tmpPath, configPath))
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to move nginx config to /etc: %s", result.Stderr)
-	}
-
-	symlinkCmd := fmt.Sprintf("ln -sf /etc/nginx/sites-available/%s /etc/nginx/sites-enabled/%s", e.appName, e.appName)
-	result = e.ssh.ExecuteSudo(symlinkCmd)
-	if result.Error != nil || result.ExitCode != 0 {
-		return fmt.Errorf("failed to enable nginx site: %s", result.Stderr)
+		dirs := e.buildOutputDirs()
+		data["BUILD_OUTPUT"] = staticSiteRoot(e.staticBuildOutput(), dirs)
+		data["OUTPUT_LOCATIONS"] = outputDirLocations(e.AppDir(), e.staticBuildOutput(), dirs)
 	}
 
-	// Only remove default site if we have a domain configured
-	e.ssh.ExecuteSudo("rm -f /etc/nginx/sites-enabled/default")
-
-	return nil
+	return template, data
 }
 
 func (e *Executor) TestNginxConfig() error {
@@ -927,8 +1114,8 @@ func (e *Executor) ReloadNginx() error {
 }
 
 func (e *Executor) EnableService() error {
-	// Static sites don't have a systemd service
-	if e.isStaticSite() {
+	// Static sites and compose projects don't have a systemd service
+	if e.isStaticSite() || e.isComposeProject() {
 		return nil
 	}
 
@@ -940,6 +1127,10 @@ func (e *Executor) EnableService() error {
 }
 
 func (e *Executor) StartService() error {
+	if e.isComposeProject() {
+		return e.ComposeUp(e.currentReleaseDir())
+	}
+
 	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl start %s", e.appName))
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to start service: %s", result.Stderr)
@@ -948,7 +1139,14 @@ func (e *Executor) StartService() error {
 }
 
 func (e *Executor) RestartService() error {
+	if e.isComposeProject() {
+		return e.ComposeUp(e.currentReleaseDir())
+	}
+
 	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl restart %s", e.appName))
+	if sshpkg.IsConnectionLost(result.Error) {
+		return fmt.Errorf("restart interrupted: %w", result.Error)
+	}
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to restart service: %s", result.Stderr)
 	}
@@ -956,6 +1154,10 @@ func (e *Executor) RestartService() error {
 }
 
 func (e *Executor) StopService() error {
+	if e.isComposeProject() {
+		return e.ComposeStop()
+	}
+
 	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl stop %s", e.appName))
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to stop service: %s", result.Stderr)
@@ -964,21 +1166,25 @@ func (e *Executor) StopService() error {
 }
 
 func (e *Executor) GetServiceStatus() (bool, error) {
+	if e.isComposeProject() {
+		return e.ComposeRunning(), nil
+	}
+
 	result := e.ssh.Execute(fmt.Sprintf("systemctl is-active %s", e.appName))
 	isActive := result.ExitCode == 0 && strings.TrimSpace(result.Stdout) == "active"
 	return isActive, nil
 }
 
 func (e *Executor) SwitchRelease(releasePath string) error {
-	currentLink := fmt.Sprintf("%s/%s/current", config.RemoteAppBaseDir, e.appName)
-	tempLink := fmt.Sprintf("%s/%s/current.tmp", config.RemoteAppBaseDir, e.appName)
+	currentLink := fmt.Sprintf("%s/current", e.AppDir())
+	tempLink := fmt.Sprintf("%s/current.tmp", e.AppDir())
 
-	result := e.ssh.ExecuteSudo(fmt.Sprintf("ln -sf %s %s", releasePath, tempLink))
+	result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("ln -sf %s %s", releasePath, tempLink))
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to create temp symlink: %s", result.Stderr)
 	}
 
-	result = e.ssh.ExecuteSudo(fmt.Sprintf("mv -Tf %s %s", tempLink, currentLink))
+	result = e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("mv -Tf %s %s", tempLink, currentLink))
 	if result.Error != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to switch release: %s", result.Stderr)
 	}
@@ -986,55 +1192,21 @@ func (e *Executor) SwitchRelease(releasePath string) error {
 	return nil
 }
 
+// PerformHealthCheck requests the detected health path from the app on its
+// port until it answers with the expected status
 func (e *Executor) PerformHealthCheck(port int, maxRetries int, retryDelay time.Duration) error {
 	if e.detection == nil || e.detection.Healthcheck == nil {
+		e.health.App = state.HealthProbe{Status: state.ProbeSkipped, Detail: "no health check for the framework"}
 		return nil
 	}
 
-	healthPath := "/"
-	expectedStatus := 200
-	timeout := int(config.DefaultHealthCheckTimeout.Seconds())
-
-	if path, ok := e.detection.Healthcheck["path"].(string); ok {
-		healthPath = path
-	}
-	if expect, ok := e.detection.Healthcheck["expect"].(int); ok {
-		expectedStatus = expect
-	}
-	if expectFloat, ok := e.detection.Healthcheck["expect"].(float64); ok {
-		expectedStatus = int(expectFloat)
-	}
-	if timeoutSec, ok := e.detection.Healthcheck["timeout_seconds"].(int); ok {
-		timeout = timeoutSec
-	}
-	if timeoutFloat, ok := e.detection.Healthcheck["timeout_seconds"].(float64); ok {
-		timeout = int(timeoutFloat)
-	}
-
+	healthPath, expectedStatus, timeout := e.healthCheckSettings()
 	url := fmt.Sprintf("http://%s:%d%s", config.DefaultBindAddress, port, healthPath)
 	curlCmd := fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --max-time %d %s", timeout, url)
 
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			time.Sleep(retryDelay)
-		}
-
-		result := e.ssh.Execute(curlCmd)
-		if result.Error != nil {
-			lastErr = fmt.Errorf("health check failed (attempt %d/%d): %w", attempt+1, maxRetries, result.Error)
-			continue
-		}
-
-		statusCode := strings.TrimSpace(result.Stdout)
-		if statusCode == fmt.Sprintf("%d", expectedStatus) {
-			return nil
-		}
-
-		lastErr = fmt.Errorf("health check failed (attempt %d/%d): expected status %d, got %s", attempt+1, maxRetries, expectedStatus, statusCode)
-	}
-
-	return lastErr
+	var err error
+	e.health.App, err = e.probeHealth("health check", curlCmd, url, expectedStatus, maxRetries, retryDelay)
+	return err
 }
 
 // RollbackToPreviousRelease rolls back to the previous release
@@ -1049,7 +1221,7 @@ func (e *Executor) RollbackToPreviousRelease() error {
 	}
 
 	previousRelease := releases[len(releases)-2]
-	previousPath := fmt.Sprintf("%s/%s/releases/%s", config.RemoteAppBaseDir, e.appName, previousRelease)
+	previousPath := fmt.Sprintf("%s/releases/%s", e.AppDir(), previousRelease)
 
 	if err := e.StopService(); err != nil {
 		return fmt.Errorf("failed to stop service during rollback: %w", err)
@@ -1068,7 +1240,7 @@ func (e *Executor) RollbackToPreviousRelease() error {
 
 // RollbackToRelease rolls back to a specific release by timestamp
 func (e *Executor) RollbackToRelease(timestamp string) error {
-	releasePath := fmt.Sprintf("%s/%s/releases/%s", config.RemoteAppBaseDir, e.appName, timestamp)
+	releasePath := fmt.Sprintf("%s/releases/%s", e.AppDir(), timestamp)
 
 	result := e.ssh.Execute(fmt.Sprintf("test -d %s", releasePath))
 	if result.ExitCode != 0 {
@@ -1091,11 +1263,33 @@ func (e *Executor) RollbackToRelease(timestamp string) error {
 }
 
 func (e *Executor) DeployWithHealthCheck(releasePath string, port int, healthCheckRetries int, healthCheckDelay time.Duration) error {
+	// A deploy started after this one owns the switch; stop before touching current
+	if err := e.CheckLease(); err != nil {
+		return err
+	}
+
+	// Catch a build that left out a directory nginx serves before it goes live
+	if err := checkOutputDirs(e.ssh.ExecuteSudo, releasePath, e.staticBuildOutput(), e.buildOutputDirs()); err != nil {
+		return err
+	}
+
+	// Migrations run against the built release while the current one still
+	// serves; a deploy that took over meanwhile owns the switch
+	if err := e.RunMigrations(releasePath); err != nil {
+		return err
+	}
+	if e.migrated {
+		if err := e.CheckLease(); err != nil {
+			return err
+		}
+	}
+
 	currentRelease, err := e.GetCurrentRelease()
 	if err != nil {
 		return fmt.Errorf("failed to get current release: %w", err)
 	}
 
+	e.deployPending = true
 	if err := e.SwitchRelease(releasePath); err != nil {
 		return fmt.Errorf("failed to switch release: %w", err)
 	}
@@ -1103,32 +1297,114 @@ func (e *Executor) DeployWithHealthCheck(releasePath string, port int, healthChe
 	// Static sites don't need systemd services or health checks
 	if e.isStaticSite() {
 		// Just reload nginx to pick up the new release
-		return e.ReloadNginx()
+		if err := e.ReloadNginx(); err != nil {
+			return err
+		}
+		e.health = state.DeployHealth{App: state.HealthProbe{Status: state.ProbeSkipped, Detail: "static site"}, CheckedAt: time.Now().UTC()}
+		if err := e.PerformProxyHealthCheck(healthCheckRetries, healthCheckDelay); err != nil {
+			previous := e.rollbackTarget(currentRelease, releasePath)
+			if previous == "" {
+				return fmt.Errorf("proxy health check failed and no previous release to rollback to: %w", err)
+			}
+			e.SwitchRelease(previous)
+			e.restoreRewrittenFiles()
+			e.ReloadNginx()
+			return fmt.Errorf("proxy health check failed, %w %s: %w", ErrRolledBack, path.Base(previous), err)
+		}
+		e.deployPending = false
+		e.recordDeployed()
+		return nil
+	}
+
+	if e.isComposeProject() {
+		if err := e.prepareComposeEnv(releasePath, port); err != nil {
+			if previous := e.rollbackTarget(currentRelease, releasePath); previous != "" {
+				e.SwitchRelease(previous)
+				e.restoreRewrittenFiles()
+			}
+			return err
+		}
 	}
 
 	// For SSR apps, manage systemd service and health checks
+	// (compose projects route start/restart/stop to docker compose)
 	isFirstDeploy := currentRelease == ""
 	if isFirstDeploy {
 		if err := e.StartService(); err != nil {
-			return fmt.Errorf("failed to start service: %w", err)
+			return e.migrationBackout(fmt.Errorf("failed to start service: %w", err))
 		}
 	} else {
-		if err := e.RestartService(); err != nil {
-			e.SwitchRelease(currentRelease)
-			e.RestartService()
-			return fmt.Errorf("failed to restart service: %w", err)
+		if err := e.restartReleaseService(); err != nil {
+			if previous := e.rollbackTarget(currentRelease, releasePath); previous != "" {
+				e.SwitchRelease(previous)
+				e.restoreRewrittenFiles()
+				e.RestartService()
+			}
+			return e.migrationBackout(fmt.Errorf("failed to restart service: %w", err))
 		}
 	}
 
+	e.health = state.DeployHealth{CheckedAt: time.Now().UTC()}
 	if err := e.PerformHealthCheck(port, healthCheckRetries, healthCheckDelay); err != nil {
-		if currentRelease != "" {
-			e.StopService()
-			e.SwitchRelease(currentRelease)
-			e.StartService()
-			return fmt.Errorf("health check failed, rolled back to previous release: %w", err)
-		}
-		return fmt.Errorf("health check failed and no previous release to rollback to: %w", err)
+		e.health.Proxy = state.HealthProbe{Status: state.ProbeSkipped, Detail: "app health check failed"}
+		return e.rollBackFailedCheck(currentRelease, releasePath, "health check", err)
+	}
+	// The app answers on its port; make sure users reach it through nginx
+	if err := e.PerformProxyHealthCheck(healthCheckRetries, healthCheckDelay); err != nil {
+		return e.rollBackFailedCheck(currentRelease, releasePath, "proxy health check", err)
 	}
 
+	e.deployPending = false
+	e.recordDeployed()
+	e.runFirstDeploy(releasePath, isFirstDeploy)
 	return nil
 }
+
+// ErrRolledBack marks a deploy whose release failed its health check after
+// going live and was switched back to the previous release
+var ErrRolledBack = errors.New("rolled back to release")
+
+// rollBackFailedCheck switches back to the previous release and restores the
+// files the deploy replaced, nginx site included, after a failed health check
+func (e *Executor) rollBackFailedCheck(currentRelease, failedRelease, check string, err error) error {
+	previous := e.rollbackTarget(currentRelease, failedRelease)
+	if previous == "" {
+		return e.migrationBackout(fmt.Errorf("%s failed and no previous release to rollback to: %w", check, err))
+	}
+	e.StopService()
+	e.SwitchRelease(previous)
+	e.restoreRewrittenFiles()
+	e.StartService()
+	return e.migrationBackout(fmt.Errorf("%s failed, %w %s: %w", check, ErrRolledBack, path.Base(previous), err))
+}
+
+// rollbackTarget returns the release directory a failed deploy switches back
+// to. The release linked before the deploy may have been pruned since, which
+// would leave current dangling, so it is checked on the server first.
+func (e *Executor) rollbackTarget(currentRelease, failedRelease string) string {
+	releases, _ := e.ListReleases()
+	releasesDir := fmt.Sprintf("%s/releases", e.AppDir())
+	return rollbackRelease(currentRelease, failedRelease, releasesDir, releases, func(dir string) bool {
+		result := e.ssh.ExecuteIdempotent("test -d " + util.ShellQuote(dir))
+		return result.Error == nil && result.ExitCode == 0
+	})
+}
+
+// rollbackRelease picks the previous release when its directory still exists,
+// otherwise the newest remaining release other than the failed one. "" means
+// there is nothing to roll back to.
+func rollbackRelease(previous, failed, releasesDir string, releases []string, exists func(dir string) bool) string {
+	if previous != "" && previous != failed && exists(previous) {
+		return previous
+	}
+	for _, release := range releases {
+		dir := releasesDir + "/" + release
+		if release == path.Base(failed) || dir == previous {
+			continue
+		}
+		if exists(dir) {
+			return dir
+		}
+	}
+	return ""
+}
@@ -3,6 +3,8 @@ package deploy
 import (
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector"
+	runtimepkg "lightfold/pkg/runtime"
+	"lightfold/pkg/state"
 	"os"
 	"path/filepath"
 	"strings"
@@ -41,7 +43,7 @@ func TestAdjustBuildCommand(t *testing.T) {
 			language:  "Python",
 			cmd:       "poetry install",
 			appName:   "myapp",
-			wantMatch: "pip3 install poetry",
+			wantMatch: "command -v poetry >/dev/null 2>&1 || curl -sSL https://install.python-poetry.org",
 		},
 		{
 			name:      "node npm install",
@@ -89,6 +91,72 @@ func TestAdjustBuildCommand(t *testing.T) {
 	}
 }
 
+func TestAdjustBuildCommand_AllowLockfileChanges(t *testing.T) {
+	detection := &detector.Detection{Language: "JavaScript/TypeScript", Meta: map[string]string{"package_manager": "npm"}}
+
+	exec := NewExecutor(nil, "myapp", "", detection)
+	if got := exec.adjustBuildCommand("npm ci", ""); got != "npm ci" {
+		t.Errorf("adjustBuildCommand() = %q, want the frozen install by default", got)
+	}
+
+	exec = NewExecutorWithOptions(nil, "myapp", "", detection, &config.DeploymentOptions{AllowLockfileChanges: true})
+	tests := map[string]string{
+		"npm ci":                           "npm install",
+		"yarn install --immutable":         "yarn install",
+		"pnpm install --frozen-lockfile":   "pnpm install",
+		"bun install --frozen-lockfile -p": "bun install -p",
+		"npm run build":                    "npm run build",
+	}
+	for cmd, want := range tests {
+		if got := exec.adjustBuildCommand(cmd, ""); !strings.HasSuffix(got, want) || strings.Contains(got, "frozen") {
+			t.Errorf("adjustBuildCommand(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestExecStartCommand_YarnPnP(t *testing.T) {
+	detection := &detector.Detection{
+		Framework: "Express.js",
+		Language:  "JavaScript/TypeScript",
+		RunPlan:   []string{"node server.js"},
+		Meta:      map[string]string{"package_manager": "yarn-berry", "yarn_linker": "pnp"},
+	}
+
+	got := NewExecutor(nil, "myapp", "", detection).getExecStartCommand()
+	if !strings.HasPrefix(got, runtimepkg.BinaryPath("yarn")+" node server.js") {
+		t.Errorf("getExecStartCommand() = %q, want node run through yarn for Plug'n'Play", got)
+	}
+
+	detection.Meta["yarn_linker"] = "node-modules"
+	got = NewExecutor(nil, "myapp", "", detection).getExecStartCommand()
+	if !strings.HasPrefix(got, runtimepkg.BinaryPath("node")+" server.js") {
+		t.Errorf("getExecStartCommand() = %q, want node run directly with node_modules", got)
+	}
+}
+
+func TestExecStartCommand_PinnedNode(t *testing.T) {
+	detection := &detector.Detection{
+		Framework: "Express.js",
+		Language:  "JavaScript/TypeScript",
+		RunPlan:   []string{"node server.js"},
+		Meta:      map[string]string{"package_manager": "npm", "runtime_version": "22"},
+	}
+
+	exec := NewExecutor(nil, "myapp", "", detection)
+	if got := exec.getExecStartCommand(); !strings.HasPrefix(got, "/usr/bin/env node server.js") {
+		t.Errorf("getExecStartCommand() = %q, want node looked up on the app's PATH", got)
+	}
+	want := "\nEnvironment=PATH=/opt/lightfold/node/22/bin:" + runtimepkg.SystemPath
+	if got := exec.runtimeEnvironment(); got != want {
+		t.Errorf("runtimeEnvironment() = %q, want %q", got, want)
+	}
+
+	delete(detection.Meta, "runtime_version")
+	if got := NewExecutor(nil, "myapp", "", detection).runtimeEnvironment(); got != "" {
+		t.Errorf("runtimeEnvironment() without a pin = %q, want none", got)
+	}
+}
+
 func TestAdjustBuildCommand_NoDetection(t *testing.T) {
 	exec := NewExecutor(nil, "test-app", "", nil)
 	cmd := "npm install"
@@ -266,6 +334,24 @@ func TestWriteEnvironmentFile_Empty(t *testing.T) {
 	}
 }
 
+func TestReplaceEnvironmentFile_WritesEmptyFile(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+
+	if err := exec.WriteEnvironmentFile(nil); err != nil {
+		t.Fatal(err)
+	}
+	if server.Ran("/srv/myapp/shared/env/.env") {
+		t.Fatal("WriteEnvironmentFile() with no variables touched the env file")
+	}
+
+	if err := exec.ReplaceEnvironmentFile(nil); err != nil {
+		t.Fatalf("ReplaceEnvironmentFile() error: %v", err)
+	}
+	if !server.Ran("/srv/myapp/shared/env/.env") {
+		t.Errorf("ReplaceEnvironmentFile() did not write the env file; ran %v", server.Commands())
+	}
+}
+
 func TestReleasePathGeneration(t *testing.T) {
 	appName := "test-app"
 	timestamp := "20240115123045"
@@ -421,6 +507,55 @@ func TestGetExecStartCommand_JavaScript(t *testing.T) {
 	}
 }
 
+func TestGetExecStartCommand_SSRAdapters(t *testing.T) {
+	tests := []struct {
+		name    string
+		runPlan string
+		want    string
+	}{
+		{"sveltekit adapter-node", "node build/index.js", "/usr/bin/node build/index.js"},
+		{"nuxt", "node .output/server/index.mjs", "/usr/bin/node .output/server/index.mjs"},
+		{"remix-serve", "remix-serve build/server/index.js", "/srv/test-app/current/node_modules/.bin/remix-serve build/server/index.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection := &detector.Detection{
+				Framework: "SvelteKit",
+				Language:  "JavaScript/TypeScript",
+				RunPlan:   []string{tt.runPlan},
+				Meta:      map[string]string{"package_manager": "npm"},
+			}
+			exec := NewExecutor(nil, "test-app", "/path", detection)
+			if result := exec.getExecStartCommand(); result != tt.want {
+				t.Errorf("getExecStartCommand() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartEnvironment(t *testing.T) {
+	detection := &detector.Detection{
+		Language: "JavaScript/TypeScript",
+		Meta:     map[string]string{"start_env": "APP_HOST=127.0.0.1 APP_PORT=$PORT PORT=$PORT"},
+	}
+	exec := NewExecutor(nil, "test-app", "/path", detection)
+
+	want := "\nEnvironment=APP_HOST=127.0.0.1\nEnvironment=APP_PORT=3001"
+	if got := exec.startEnvironment(3001); got != want {
+		t.Errorf("startEnvironment() = %q, want %q", got, want)
+	}
+
+	exec.SetStartCommand("node server.js")
+	if got := exec.startEnvironment(3001); got != "" {
+		t.Errorf("startEnvironment() with custom start command = %q, want empty", got)
+	}
+
+	if !strings.Contains(systemdTemplate, "Environment=PORT={{PORT}}{{EXTRA_ENVIRONMENT}}") {
+		t.Error("systemd template missing {{EXTRA_ENVIRONMENT}} after PORT")
+	}
+}
+
 func TestGetExecStartCommand_Go(t *testing.T) {
 	detection := &detector.Detection{
 		Framework: "Go HTTP",
@@ -1001,3 +1136,173 @@ func TestGetExecStartCommand_UsesFirstRunCommand(t *testing.T) {
 		t.Errorf("getExecStartCommand() = %v, want first run command './migrate.sh'", cmd)
 	}
 }
+
+func TestProxyHealthCommand(t *testing.T) {
+	command, url := proxyHealthCommand("203.0.113.10", "/health", 30)
+	if url != "http://203.0.113.10:80/health" {
+		t.Errorf("url = %q", url)
+	}
+	if !strings.Contains(command, "-H 'Host: 203.0.113.10'") || !strings.Contains(command, "'http://127.0.0.1:80/health'") {
+		t.Errorf("IP command = %q, want the server IP as Host on 127.0.0.1:80", command)
+	}
+
+	command, url = proxyHealthCommand("example.com", "/", 10)
+	if url != "http://example.com/" {
+		t.Errorf("url = %q", url)
+	}
+	for _, want := range []string{"--max-time 10", "--resolve example.com:80:127.0.0.1", "--resolve example.com:443:127.0.0.1", "-L", "'http://example.com/'"} {
+		if !strings.Contains(command, want) {
+			t.Errorf("domain command = %q, missing %q", command, want)
+		}
+	}
+}
+
+func TestPerformProxyHealthCheck_Skipped(t *testing.T) {
+	exec := NewExecutor(nil, "test-app", "/path", &detector.Detection{Framework: "Express.js"})
+	if err := exec.PerformProxyHealthCheck(3, time.Millisecond); err != nil || exec.DeployHealth().Proxy.Status != state.ProbeSkipped {
+		t.Errorf("without a host: %v, %+v; want skipped", err, exec.DeployHealth().Proxy)
+	}
+
+	exec.SetProxyHealthCheck("example.com")
+	if err := exec.PerformProxyHealthCheck(3, time.Millisecond); err != nil || exec.DeployHealth().Proxy.Status != state.ProbeSkipped {
+		t.Errorf("without a detected health check: %v, %+v; want skipped", err, exec.DeployHealth().Proxy)
+	}
+}
+
+func TestReleasesToPrune(t *testing.T) {
+	releases := []string{"20240105000000", "20240104000000", "20240103000000", "20240102000000", "20240101000000"}
+	const dir = "/srv/myapp/releases/"
+
+	tests := []struct {
+		name      string
+		keepCount int
+		current   string
+		want      []string
+	}{
+		{"keep count", 3, dir + "20240105000000", []string{"20240102000000", "20240101000000"}},
+		{"keep 1 still keeps the previous release", 1, dir + "20240105000000", []string{"20240103000000", "20240102000000", "20240101000000"}},
+		{"keep 0", 0, dir + "20240105000000", []string{"20240103000000", "20240102000000", "20240101000000"}},
+		{"rolled back to an older release", 1, dir + "20240103000000", []string{"20240104000000", "20240101000000"}},
+		{"no current release", 2, "", []string{"20240103000000", "20240102000000", "20240101000000"}},
+		{"fewer releases than keep count", 10, dir + "20240105000000", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := releasesToPrune(releases, tt.keepCount, tt.current)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("releasesToPrune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackRelease(t *testing.T) {
+	const dir = "/srv/myapp/releases"
+	releases := []string{"20240104000000", "20240103000000", "20240102000000"}
+	existing := func(dirs ...string) func(string) bool {
+		return func(d string) bool {
+			for _, name := range dirs {
+				if d == dir+"/"+name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	failed := dir + "/20240104000000"
+
+	tests := []struct {
+		name     string
+		previous string
+		exists   func(string) bool
+		want     string
+	}{
+		{"previous release exists", dir + "/20240103000000", existing("20240104000000", "20240103000000", "20240102000000"), dir + "/20240103000000"},
+		{"previous release pruned", dir + "/20240101000000", existing("20240104000000", "20240103000000", "20240102000000"), dir + "/20240103000000"},
+		{"previous release pruned, newest remaining is older", dir + "/20240103000000", existing("20240104000000", "20240102000000"), dir + "/20240102000000"},
+		{"only the failed release is left", dir + "/20240103000000", existing("20240104000000"), ""},
+		{"first deploy", "", existing("20240104000000"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollbackRelease(tt.previous, failed, dir, releases, tt.exists); got != tt.want {
+				t.Errorf("rollbackRelease() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanupOldReleases_RefusesBeforeHealthChecks(t *testing.T) {
+	exec := NewExecutor(nil, "test-app", "/path", nil)
+	exec.deployPending = true
+	if err := exec.CleanupOldReleases(1); err == nil {
+		t.Error("CleanupOldReleases() during an unverified deploy should fail")
+	}
+}
+
+func TestGenerateNginxConfig_KeepsHTTPSSite(t *testing.T) {
+	certbotSite := `server {
+  listen 443 ssl;
+  server_name example.com;
+  ssl_certificate /etc/letsencrypt/live/example.com/fullchain.pem;
+  ssl_certificate_key /etc/letsencrypt/live/example.com/privkey.pem;
+}
+`
+	exec, server := connectRecording(t, "myapp")
+	exec.SetDomainProtocols(false, true)
+	server.Replies = map[string][]string{
+		"cat /etc/nginx/sites-available/myapp.conf": {certbotSite},
+		// Another app holds reuseport on the IPv4 QUIC listener
+		"reuseport": {"/etc/nginx/sites-enabled/blog.conf:  listen 443 quic reuseport;\n"},
+	}
+
+	if err := exec.GenerateNginxConfig(3000, "example.com"); err != nil {
+		t.Fatalf("GenerateNginxConfig() error: %v", err)
+	}
+
+	written := map[string]string{}
+	commands, inputs := server.Commands(), server.Inputs()
+	for i, command := range commands {
+		if strings.HasPrefix(command, "scp -t /tmp/lightfold-upload") {
+			written[command] = inputs[i]
+		}
+	}
+	var appSite, domainSite string
+	for command, content := range written {
+		switch {
+		case strings.HasSuffix(command, "sites-available-myapp.conf"):
+			domainSite = content
+		case strings.HasSuffix(command, "sites-available-myapp"):
+			appSite = content
+		}
+	}
+	if appSite == "" || strings.Contains(appSite, "443") {
+		t.Errorf("expected the app's HTTP site, got %q", appSite)
+	}
+	for _, want := range []string{
+		"listen 443 ssl http2;",
+		"listen 443 quic;",
+		"listen [::]:443 quic reuseport;",
+		"Alt-Svc",
+		"ssl_certificate /etc/letsencrypt/live/example.com/fullchain.pem;",
+		"proxy_pass http://127.0.0.1:3000",
+	} {
+		if !strings.Contains(domainSite, want) {
+			t.Errorf("expected %q in the HTTPS site, got:\n%s", want, domainSite)
+		}
+	}
+	if !server.Ran("ln -sf /etc/nginx/sites-available/myapp.conf /etc/nginx/sites-enabled/myapp.conf") {
+		t.Errorf("expected the HTTPS site enabled, got %v", commands)
+	}
+}
+
+func TestGenerateNginxConfig_NoCertificate(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+
+	if err := exec.GenerateNginxConfig(3000, "example.com"); err != nil {
+		t.Fatalf("GenerateNginxConfig() error: %v", err)
+	}
+	if server.Ran("myapp.conf /etc/nginx/sites-enabled") {
+		t.Errorf("a domain without a certificate should be left to domain add, got %v", server.Commands())
+	}
+}
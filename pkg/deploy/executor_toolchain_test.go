@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	runtimepkg "lightfold/pkg/runtime"
+	"testing"
+)
+
+// The toolchain cases compare the commands the executor generates with what it
+// generated before package manager handling moved into pkg/runtime. Where the
+// output changed on purpose the old command is kept in a comment.
+
+const (
+	jsPath     = `export PATH="/usr/bin:$PATH" && export NODE="/usr/bin/node" && hash -r && `
+	pnpmPath   = `export PNPM_HOME="$HOME/.local/share/pnpm" && export PATH="$PNPM_HOME:$PATH" && `
+	bunPath    = `export PATH="$HOME/.bun/bin:$PATH" && `
+	localPath  = `export PATH="$HOME/.local/bin:$PATH" && `
+	ensureYarn = "(command -v yarn >/dev/null 2>&1 || npm install -g yarn) && "
+	ensurePnpm = "(command -v pnpm >/dev/null 2>&1 || npm install -g pnpm) && "
+	ensureBun  = "(command -v bun >/dev/null 2>&1 || curl -fsSL https://bun.sh/install | bash) && "
+	ensureUV   = "(command -v uv >/dev/null 2>&1 || curl -LsSf https://astral.sh/uv/install.sh | sh) && "
+)
+
+func TestToolchainCompatibility_BuildCommands(t *testing.T) {
+	tests := []struct {
+		language       string
+		packageManager string
+		cmd            string
+		wantPath       string
+		wantCommand    string
+	}{
+		// npm ships with node and is never wrapped
+		{"JavaScript/TypeScript", "npm", "npm ci", jsPath, "npm ci"},
+		{"JavaScript/TypeScript", "npm", "npm run build", jsPath, "npm run build"},
+
+		// Was: bare "yarn build"; yarn was only installed by the runtime installer
+		{"JavaScript/TypeScript", "yarn", "yarn build", jsPath, ensureYarn + "yarn build"},
+		{"JavaScript/TypeScript", "yarn-berry", "yarn install --immutable", jsPath, ensureYarn + "yarn install --immutable"},
+
+		{"JavaScript/TypeScript", "pnpm", "pnpm install --frozen-lockfile", jsPath + pnpmPath, ensurePnpm + "pnpm install --frozen-lockfile"},
+		// Was: bare "pnpm run build"; only install commands were wrapped
+		{"JavaScript/TypeScript", "pnpm", "pnpm run build", jsPath + pnpmPath, ensurePnpm + "pnpm run build"},
+		// Was: no PNPM_HOME exports when pnpm was not the detected package manager
+		{"JavaScript/TypeScript", "npm", "pnpm install --frozen-lockfile", jsPath, pnpmPath + ensurePnpm + "pnpm install --frozen-lockfile"},
+
+		{"JavaScript/TypeScript", "bun", "bun install", jsPath + bunPath, ensureBun + "bun install"},
+		// Was: bare "bun run build"
+		{"JavaScript/TypeScript", "bun", "bun run build", jsPath + bunPath, ensureBun + "bun run build"},
+
+		{"Python", "", "pip install -r requirements.txt", "", "/srv/myapp/shared/venv/bin/pip install -r requirements.txt"},
+		{"Python", "pip", "pip install -r requirements.txt", "", "/srv/myapp/shared/venv/bin/pip install -r requirements.txt"},
+		// Was: "pip3 install poetry && poetry install", reinstalling poetry on every deploy
+		{"Python", "poetry", "poetry install", localPath, "(command -v poetry >/dev/null 2>&1 || curl -sSL https://install.python-poetry.org | python3 -) && poetry install"},
+		// Was: "pip3 install uv && uv sync"
+		{"Python", "uv", "uv sync", localPath, ensureUV + "uv sync"},
+		// Was: "uv /srv/myapp/shared/venv/bin/pip install ...", a broken rewrite of uv's pip
+		{"Python", "", "uv pip install -r requirements.txt", "", localPath + ensureUV + "uv pip install -r requirements.txt"},
+		// Was: "pip3 install pipenv && pipenv install --deploy"
+		{"Python", "pipenv", "pipenv install --deploy", localPath, "(command -v pipenv >/dev/null 2>&1 || pip3 install --user pipenv) && pipenv install --deploy"},
+
+		// Was: "gem install bundler && bundle install"
+		{"Ruby", "", "bundle install", "", "(command -v bundle >/dev/null 2>&1 || gem install bundler) && bundle install"},
+
+		{"Go", "", "go build -o app", "", "go build -o app"},
+		{"PHP", "composer", "composer install --no-dev", "", "composer install --no-dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language+"/"+tt.packageManager+"/"+tt.cmd, func(t *testing.T) {
+			detection := &detector.Detection{
+				Language: tt.language,
+				Meta:     map[string]string{"package_manager": tt.packageManager},
+			}
+			exec := NewExecutor(nil, "myapp", "", detection)
+
+			if got := exec.getPackageManagerPath(); got != tt.wantPath {
+				t.Errorf("getPackageManagerPath() = %q, want %q", got, tt.wantPath)
+			}
+			if got := exec.adjustBuildCommand(tt.cmd, "/srv/myapp/releases/20240101000000"); got != tt.wantCommand {
+				t.Errorf("adjustBuildCommand(%q) = %q, want %q", tt.cmd, got, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestToolchainCompatibility_ExecStart(t *testing.T) {
+	tests := []struct {
+		packageManager string
+		runCommand     string
+		want           string
+	}{
+		{"npm", "npm start", "/usr/bin/npm start"},
+		{"bun", "bun run start", "/home/deploy/.bun/bin/bun run start"},
+		// Was: /home/deploy/.local/share/pnpm/pnpm, where the installer never put pnpm
+		{"pnpm", "pnpm start", "/usr/local/bin/pnpm start"},
+		// Was: /usr/bin/yarn; npm installs it next to node in /usr/local/bin
+		{"yarn", "yarn start", "/usr/local/bin/yarn start"},
+		// Was: "yarn start"; only the detected package manager was rewritten
+		{"npm", "yarn start", "/usr/local/bin/yarn start"},
+		{"", "npm start", "/usr/bin/npm start"},
+		{"npm", "node server.js", "/usr/bin/node server.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.packageManager+"/"+tt.runCommand, func(t *testing.T) {
+			detection := &detector.Detection{
+				Language: "JavaScript/TypeScript",
+				RunPlan:  []string{tt.runCommand},
+				Meta:     map[string]string{"package_manager": tt.packageManager},
+			}
+			exec := NewExecutor(nil, "myapp", "", detection)
+
+			if got := exec.getExecStartCommand(); got != tt.want {
+				t.Errorf("getExecStartCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// User-supplied run commands get the same package manager paths
+	exec := NewExecutorWithOptions(nil, "myapp", "", &detector.Detection{Language: "JavaScript/TypeScript"},
+		&config.DeploymentOptions{RunCommands: []string{"pnpm start"}})
+	if got := exec.getExecStartCommand(); got != runtimepkg.BinaryPath("pnpm")+" start" {
+		t.Errorf("getExecStartCommand() with run commands = %q, want the pnpm bin path", got)
+	}
+}
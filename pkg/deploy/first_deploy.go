@@ -0,0 +1,119 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"lightfold/pkg/util"
+	"path"
+	"strings"
+	"time"
+)
+
+// FirstDeployMarker is the file in /srv/<app>/shared written once the app's
+// first-deploy commands succeeded; while it exists they are not run again
+const FirstDeployMarker = ".lightfold-first-deploy"
+
+// firstDeployPending is written next to the marker when a first deploy starts
+// the commands, so the deploys after a failed run retry them although they
+// are no longer the first
+const firstDeployPending = ".lightfold-first-deploy.pending"
+
+// SetFirstDeployOptions sets the commands run once after the app's first
+// healthy deploy. rerun (--rerun-first-deploy) runs them again although the
+// marker says they succeeded.
+func (e *Executor) SetFirstDeployOptions(opts *config.DeploymentOptions, rerun bool) {
+	e.firstDeployCommands = nil
+	e.rerunFirstDeploy = rerun
+	if opts == nil {
+		return
+	}
+	for _, command := range opts.FirstDeployCommands {
+		if command = strings.TrimSpace(command); command != "" {
+			e.firstDeployCommands = append(e.firstDeployCommands, command)
+		}
+	}
+}
+
+// FirstDeployResult is the outcome of the first-deploy commands this deploy
+// ran, nil when it ran none
+func (e *Executor) FirstDeployResult() *state.FirstDeployRun {
+	return e.firstDeploy
+}
+
+func (e *Executor) firstDeployFile(name string) string {
+	return fmt.Sprintf("%s/shared/%s", e.AppDir(), name)
+}
+
+// firstDeployMarkers reads whether the commands succeeded before (done) and
+// whether an earlier run is still owed (pending)
+func (e *Executor) firstDeployMarkers() (done, pending bool) {
+	result := e.ssh.ExecuteIdempotent(fmt.Sprintf("test -f %s && echo done; test -f %s && echo pending; true",
+		e.firstDeployFile(FirstDeployMarker), e.firstDeployFile(firstDeployPending)))
+	for _, field := range strings.Fields(result.Stdout) {
+		switch field {
+		case "done":
+			done = true
+		case "pending":
+			pending = true
+		}
+	}
+	return done, pending
+}
+
+// runFirstDeploy runs the first-deploy commands in a release that passed its
+// health checks: on the app's first deploy, on every deploy after a failed
+// run, and with --rerun-first-deploy. Their output goes to the build log. A
+// failure leaves the release live and the marker unwritten, so the next
+// deploy tries again.
+func (e *Executor) runFirstDeploy(releasePath string, isFirstDeploy bool) {
+	if len(e.firstDeployCommands) == 0 {
+		return
+	}
+	done, pending := e.firstDeployMarkers()
+	switch {
+	case e.rerunFirstDeploy:
+	case done:
+		return
+	case !isFirstDeploy && !pending:
+		e.notify("Skipping first-deploy commands: the app was deployed before they were set (use --rerun-first-deploy to run them)")
+		return
+	}
+
+	run := &state.FirstDeployRun{Release: path.Base(releasePath), Status: state.FirstDeployFailed, RanAt: time.Now()}
+	e.firstDeploy = run
+
+	pendingPath := e.firstDeployFile(firstDeployPending)
+	if result := e.ssh.Execute("touch " + pendingPath); result.Error != nil || result.ExitCode != 0 {
+		run.Error = "could not write " + pendingPath
+		e.notify("First-deploy commands not run: " + run.Error)
+		return
+	}
+
+	command := strings.Join(e.firstDeployCommands, " && ")
+	e.notify(fmt.Sprintf("Running first-deploy commands: %s", command))
+	result := e.ssh.Execute(fmt.Sprintf("cd %s && sh -c %s 2>&1", releasePath, util.ShellQuote(e.migrationPathPrefix()+command)))
+	e.sendOutput(result.Stdout, strings.Count(result.Stdout, "\n")+1)
+
+	switch {
+	case result.Error != nil:
+		run.Error = result.Error.Error()
+	case result.ExitCode != 0:
+		run.Error = fmt.Sprintf("exit code %d: %s", result.ExitCode, lastOutputLine(result.Stdout))
+	}
+	if run.Error != "" {
+		e.notify(fmt.Sprintf("First-deploy commands failed (%s); the release is live and the next deploy runs them again", run.Error))
+		return
+	}
+
+	marker := e.firstDeployFile(FirstDeployMarker)
+	record := e.ssh.Execute(fmt.Sprintf("printf '%%s %%s\\n' %s %s > %s && rm -f %s",
+		util.ShellQuote(run.Release), run.RanAt.UTC().Format(time.RFC3339), marker, pendingPath))
+	if record.Error != nil || record.ExitCode != 0 {
+		run.Error = "the commands succeeded but " + marker + " could not be written, so they will run again"
+		e.notify("First-deploy commands: " + run.Error)
+		return
+	}
+	run.Status = state.FirstDeploySucceeded
+	e.notify("First-deploy commands succeeded")
+}
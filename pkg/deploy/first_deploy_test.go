@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+const seedCommand = "bin/rails runner db/seed.rb"
+
+func seedOptions() *config.DeploymentOptions {
+	return &config.DeploymentOptions{FirstDeployCommands: []string{seedCommand, " "}}
+}
+
+func TestDeployWithHealthCheck_FailedSeedIsRetriedNextDeploy(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "db/seed.rb")
+	exec.detection = railsDetection()
+	exec.SetMigrationOptions(nil, true)
+	exec.SetFirstDeployOptions(seedOptions(), false)
+
+	if err := exec.DeployWithHealthCheck("/srv/myapp/releases/20240101000000", 3000, 1, 0); err != nil {
+		t.Fatalf("DeployWithHealthCheck() error: %v, want the release live despite the failed seed", err)
+	}
+	run := exec.FirstDeployResult()
+	if run == nil || run.Status != state.FirstDeployFailed || !strings.Contains(run.Error, "exit code 1") {
+		t.Fatalf("FirstDeployResult() = %+v, want a failed run", run)
+	}
+	seed := server.Index("sh -c '" + seedCommand)
+	if seed < 0 || seed < server.Index("ln -sf") {
+		t.Errorf("the seed should run after the switch: %v", server.Commands())
+	}
+	if !server.Ran("touch /srv/myapp/shared/.lightfold-first-deploy.pending") {
+		t.Errorf("a first deploy should leave the pending marker: %v", server.Commands())
+	}
+	if server.Ran("> /srv/myapp/shared/.lightfold-first-deploy ") {
+		t.Errorf("a failed seed must not write the marker: %v", server.Commands())
+	}
+
+	// The next deploy is not the first, but the pending marker makes it retry
+	exec, server = connectRecording(t, "myapp")
+	server.Replies = map[string][]string{
+		"readlink -f":  {"/srv/myapp/releases/20240101000000\n"},
+		"echo pending": {"pending\n"},
+	}
+	exec.detection = railsDetection()
+	exec.SetMigrationOptions(nil, true)
+	exec.SetFirstDeployOptions(seedOptions(), false)
+
+	if err := exec.DeployWithHealthCheck("/srv/myapp/releases/20240102000000", 3000, 1, 0); err != nil {
+		t.Fatalf("DeployWithHealthCheck() error: %v", err)
+	}
+	run = exec.FirstDeployResult()
+	if run == nil || run.Status != state.FirstDeploySucceeded || run.Release != "20240102000000" {
+		t.Fatalf("FirstDeployResult() = %+v, want a successful retry", run)
+	}
+	if !server.Ran("cd /srv/myapp/releases/20240102000000 && sh -c '" + seedCommand) {
+		t.Errorf("the retry should run in the new release: %v", server.Commands())
+	}
+	if !server.Ran("> /srv/myapp/shared/.lightfold-first-deploy && rm -f /srv/myapp/shared/.lightfold-first-deploy.pending") {
+		t.Errorf("a successful seed should write the marker and drop the pending one: %v", server.Commands())
+	}
+}
+
+func TestRunFirstDeploy_Markers(t *testing.T) {
+	tests := []struct {
+		name          string
+		markers       string
+		isFirstDeploy bool
+		rerun         bool
+		wantRun       bool
+	}{
+		{name: "first deploy", isFirstDeploy: true, wantRun: true},
+		{name: "already seeded", markers: "done\n", wantRun: false},
+		{name: "already seeded, rerun", markers: "done\n", rerun: true, wantRun: true},
+		{name: "deployed before the commands were set", wantRun: false},
+		{name: "earlier seed failed", markers: "pending\n", wantRun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, server := connectRecording(t, "myapp")
+			server.Replies = map[string][]string{"echo pending": {tt.markers}}
+			exec.SetFirstDeployOptions(seedOptions(), tt.rerun)
+
+			exec.runFirstDeploy("/srv/myapp/releases/20240101000000", tt.isFirstDeploy)
+
+			if got := server.Ran(seedCommand); got != tt.wantRun {
+				t.Errorf("ran the seed = %v, want %v: %v", got, tt.wantRun, server.Commands())
+			}
+			if got := exec.FirstDeployResult() != nil; got != tt.wantRun {
+				t.Errorf("FirstDeployResult() recorded = %v, want %v", got, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestRunFirstDeploy_NoCommands(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	exec.SetFirstDeployOptions(&config.DeploymentOptions{}, true)
+
+	exec.runFirstDeploy("/srv/myapp/releases/20240101000000", true)
+
+	if len(server.Commands()) != 0 || exec.FirstDeployResult() != nil {
+		t.Errorf("nothing should run without first-deploy commands: %v", server.Commands())
+	}
+}
@@ -21,6 +21,7 @@ type FlyioDeployer struct {
 	detection   *detector.Detection
 	flyConfig   *config.FlyioConfig
 	token       string
+	labels      map[string]string
 	callback    ProgressCallback
 }
 
@@ -41,6 +42,11 @@ func (d *FlyioDeployer) SetProgressCallback(callback ProgressCallback) {
 	d.callback = callback
 }
 
+// SetLabels sets the target's labels, applied as machine metadata
+func (d *FlyioDeployer) SetLabels(labels map[string]string) {
+	d.labels = labels
+}
+
 // Deploy executes a full fly.io deployment using native nixpacks
 func (d *FlyioDeployer) Deploy(ctx context.Context, deployOpts *config.DeploymentOptions) error {
 	// Step 1: Check flyctl availability
@@ -87,6 +93,7 @@ func (d *FlyioDeployer) Deploy(ctx context.Context, deployOpts *config.Deploymen
 		Region:      d.flyConfig.Region,
 		RemoteOnly:  true,
 		UseNixpacks: true, // Use fly.io's native nixpacks - no Dockerfile needed!
+		Metadata:    flyio.LabelMetadata(d.labels),
 	})
 
 	if err != nil {
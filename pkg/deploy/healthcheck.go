@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"lightfold/pkg/util"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetProxyHealthCheck makes deploys also request the health path through
+// nginx with host as the Host, after the app's own check passes. A failure
+// rolls the deploy back like a failing app check. Empty skips it.
+func (e *Executor) SetProxyHealthCheck(host string) {
+	e.proxyHost = host
+}
+
+// DeployHealth returns the results of the last deploy's health checks
+func (e *Executor) DeployHealth() state.DeployHealth {
+	return e.health
+}
+
+// healthCheckSettings returns the detected health path, expected status and
+// request timeout in seconds, with the defaults for anything not detected
+func (e *Executor) healthCheckSettings() (path string, expect int, timeout int) {
+	path, expect, timeout = "/", 200, int(config.DefaultHealthCheckTimeout.Seconds())
+	if e.detection == nil || e.detection.Healthcheck == nil {
+		return path, expect, timeout
+	}
+	healthcheck := e.detection.Healthcheck
+
+	if p, ok := healthcheck["path"].(string); ok {
+		path = p
+	}
+	if v, ok := healthcheck["expect"].(int); ok {
+		expect = v
+	}
+	if v, ok := healthcheck["expect"].(float64); ok {
+		expect = int(v)
+	}
+	if v, ok := healthcheck["timeout_seconds"].(int); ok {
+		timeout = v
+	}
+	if v, ok := healthcheck["timeout_seconds"].(float64); ok {
+		timeout = int(v)
+	}
+	return path, expect, timeout
+}
+
+// PerformProxyHealthCheck requests the health path through nginx on the
+// server, so a deploy fails when the app is up but the site is not, e.g.
+// after a bad server_name change
+func (e *Executor) PerformProxyHealthCheck(maxRetries int, retryDelay time.Duration) error {
+	if e.proxyHost == "" {
+		e.health.Proxy = state.HealthProbe{Status: state.ProbeSkipped, Detail: "not enabled"}
+		return nil
+	}
+	// Without a detected health path only static sites have a known-good URL
+	if !e.isStaticSite() && (e.detection == nil || e.detection.Healthcheck == nil) {
+		e.health.Proxy = state.HealthProbe{Status: state.ProbeSkipped, Detail: "no health check for the framework"}
+		return nil
+	}
+
+	healthPath, expectedStatus, timeout := e.healthCheckSettings()
+	curlCmd, url := proxyHealthCommand(e.proxyHost, healthPath, timeout)
+
+	var err error
+	e.health.Proxy, err = e.probeHealth("proxy health check", curlCmd, url, expectedStatus, maxRetries, retryDelay)
+	return err
+}
+
+// proxyHealthCommand builds the curl request for the health path through
+// nginx on the server itself. A domain is pinned to 127.0.0.1 for HTTP and
+// HTTPS so the redirect to HTTPS is followed locally; a server IP is sent as
+// the Host header to port 80.
+func proxyHealthCommand(host, healthPath string, timeout int) (command, url string) {
+	base := fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --max-time %d", timeout)
+	if net.ParseIP(host) != nil {
+		url = "http://" + net.JoinHostPort(host, "80") + healthPath
+		return fmt.Sprintf("%s -H %s %s", base, util.ShellQuote("Host: "+host), util.ShellQuote("http://127.0.0.1:80"+healthPath)), url
+	}
+	url = "http://" + host + healthPath
+	return fmt.Sprintf("%s -k -L --max-redirs 3 --resolve %s:80:127.0.0.1 --resolve %s:443:127.0.0.1 %s", base, host, host, util.ShellQuote(url)), url
+}
+
+// probeHealth runs a curl health request until it returns expectedStatus or
+// the retries run out
+func (e *Executor) probeHealth(name, curlCmd, url string, expectedStatus, maxRetries int, retryDelay time.Duration) (state.HealthProbe, error) {
+	probe := state.HealthProbe{Status: state.ProbeFailed, URL: url}
+
+	var lastErr error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryDelay)
+		}
+
+		result := e.ssh.Execute(curlCmd)
+		if result.Error != nil {
+			lastErr = fmt.Errorf("%s failed (attempt %d/%d): %w", name, attempt+1, maxRetries, result.Error)
+			continue
+		}
+
+		statusCode := strings.TrimSpace(result.Stdout)
+		probe.HTTPCode, _ = strconv.Atoi(statusCode)
+		if statusCode == strconv.Itoa(expectedStatus) {
+			probe.Status = state.ProbePassed
+			return probe, nil
+		}
+
+		lastErr = fmt.Errorf("%s failed (attempt %d/%d): expected status %d, got %s", name, attempt+1, maxRetries, expectedStatus, statusCode)
+	}
+
+	if lastErr != nil {
+		probe.Detail = lastErr.Error()
+	}
+	return probe, lastErr
+}
@@ -0,0 +1,538 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/timefmt"
+	"lightfold/pkg/util"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemdUnit is what an app's systemd unit says about how it runs
+type SystemdUnit struct {
+	File             string // Path `systemctl cat` printed for the unit
+	WorkingDirectory string
+	ExecStart        string
+	User             string
+	EnvironmentFiles []string          // Without the "-" that makes a file optional
+	Environment      map[string]string // Environment= assignments, later ones winning
+}
+
+// NginxSite is what an app's nginx site says about how it is served
+type NginxSite struct {
+	File        string
+	ServerNames []string // server_name entries other than _ and localhost, in order
+	ProxyPort   int      // Local port proxied to, preferring location /; 0 when none
+	ProxySocket string   // Unix socket proxied to instead of a port
+	Root        string   // root of a site serving files
+	Certificate string   // ssl_certificate of the HTTPS server
+}
+
+// ImportedApp is the configuration inferred for an app deployed by hand,
+// from its systemd unit and nginx site
+type ImportedApp struct {
+	AppName    string
+	Unit       *SystemdUnit // nil for a static site nginx serves alone
+	Site       *NginxSite   // nil when nginx does not front the app
+	CodeDir    string       // Directory the app runs from: the unit's WorkingDirectory or the site's root
+	AppDir     string       // <base>/<app> lightfold deploys to
+	BaseDir    string
+	Release    string // Name of the running release
+	DeployedAt time.Time
+	Port       int
+	Domain     string
+	SSL        bool // The site serves a certbot certificate for Domain
+	EnvVars    map[string]string
+	Warnings   []string
+}
+
+// Static reports whether nginx serves the app's files without a unit
+func (a *ImportedApp) Static() bool {
+	return a.Unit == nil
+}
+
+// ParseSystemdUnit reads the [Service] section of a unit as `systemctl cat`
+// prints it: comments are skipped, lines ending in a backslash continue, and
+// drop-ins after the unit override it. An empty assignment resets a list
+// setting, as in systemd.
+func ParseSystemdUnit(content string) SystemdUnit {
+	unit := SystemdUnit{Environment: map[string]string{}}
+	section := ""
+	for _, line := range joinContinuations(content) {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "# /") && unit.File == "":
+			// systemctl cat heads each file with its path
+			unit.File = strings.TrimPrefix(line, "# ")
+			continue
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "[Service]" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "WorkingDirectory":
+			unit.WorkingDirectory = strings.TrimPrefix(value, "-")
+		case "ExecStart":
+			// Prefixes such as - and @ change how systemd runs the command;
+			// continued lines leave runs of spaces
+			unit.ExecStart = strings.Join(strings.Fields(strings.TrimLeft(value, "-@:+!")), " ")
+		case "User":
+			unit.User = value
+		case "EnvironmentFile":
+			if value == "" {
+				unit.EnvironmentFiles = nil
+			} else {
+				unit.EnvironmentFiles = append(unit.EnvironmentFiles, strings.TrimPrefix(value, "-"))
+			}
+		case "Environment":
+			if value == "" {
+				unit.Environment = map[string]string{}
+			}
+			for _, word := range splitUnitWords(value) {
+				if name, v, ok := strings.Cut(word, "="); ok && name != "" {
+					unit.Environment[name] = v
+				}
+			}
+		}
+	}
+	return unit
+}
+
+// joinContinuations splits content into lines, joining those that end in a
+// backslash with the next
+func joinContinuations(content string) []string {
+	var lines []string
+	current := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(trimmed, "\\") {
+			current += strings.TrimSuffix(trimmed, "\\") + " "
+			continue
+		}
+		lines = append(lines, current+trimmed)
+		current = ""
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// splitUnitWords splits an Environment= value into its assignments, which
+// may be quoted as a whole: "A=1 2" 'B=3' C=4
+func splitUnitWords(value string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(value):
+			i++
+			word.WriteByte(value[i])
+		case quote != 0:
+			word.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// nginxDirective is one simple directive with the blocks it sits in, e.g.
+// proxy_pass in [server location]
+type nginxDirective struct {
+	blocks []string
+	name   string
+	args   []string
+}
+
+// parseNginxDirectives tokenizes an nginx config into its simple directives.
+// Comments are dropped and quotes removed; blocks are named by their
+// directive, with the arguments kept for upstream and location.
+func parseNginxDirectives(content string) []nginxDirective {
+	var directives []nginxDirective
+	var blocks []string
+	var words []string
+	var word strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '#':
+			flush()
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(content[i+1:], c)
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			word.WriteString(content[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == ';':
+			flush()
+			if len(words) > 0 {
+				directives = append(directives, nginxDirective{blocks: append([]string(nil), blocks...), name: words[0], args: words[1:]})
+			}
+			words = nil
+		case c == '{':
+			flush()
+			blocks = append(blocks, strings.Join(words, " "))
+			words = nil
+		case c == '}':
+			flush()
+			words = nil
+			if len(blocks) > 0 {
+				blocks = blocks[:len(blocks)-1]
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	return directives
+}
+
+// ParseNginxSite reads an nginx site: the names it answers for, where it
+// proxies to, or the root it serves files from. Upstream blocks are
+// followed to their first server, and a proxy_pass in location / wins over
+// the ones in other locations.
+func ParseNginxSite(content string) NginxSite {
+	var site NginxSite
+	directives := parseNginxDirectives(content)
+
+	upstreams := map[string]string{}
+	for _, d := range directives {
+		if len(d.blocks) > 0 && d.name == "server" && len(d.args) > 0 {
+			if name, ok := strings.CutPrefix(d.blocks[len(d.blocks)-1], "upstream "); ok {
+				if _, seen := upstreams[name]; !seen {
+					upstreams[name] = d.args[0]
+				}
+			}
+		}
+	}
+
+	proxyRoot := false
+	seen := map[string]bool{}
+	for _, d := range directives {
+		if !inNginxServer(d.blocks) || len(d.args) == 0 {
+			continue
+		}
+		switch d.name {
+		case "server_name":
+			for _, name := range d.args {
+				if name != "_" && name != "localhost" && !seen[name] {
+					seen[name] = true
+					site.ServerNames = append(site.ServerNames, name)
+				}
+			}
+		case "root":
+			if site.Root == "" || len(d.blocks) == 1 {
+				site.Root = d.args[0]
+			}
+		case "ssl_certificate":
+			site.Certificate = d.args[0]
+		case "proxy_pass":
+			inRoot := len(d.blocks) > 1 && d.blocks[len(d.blocks)-1] == "location /"
+			if (site.ProxyPort != 0 || site.ProxySocket != "") && (proxyRoot || !inRoot) {
+				continue
+			}
+			port, socket := proxyTarget(d.args[0], upstreams)
+			if port != 0 || socket != "" {
+				site.ProxyPort, site.ProxySocket, proxyRoot = port, socket, inRoot
+			}
+		}
+	}
+	return site
+}
+
+// inNginxServer reports whether a directive sits in a server block
+func inNginxServer(blocks []string) bool {
+	for _, block := range blocks {
+		if block == "server" {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyTarget returns the port or unix socket a proxy_pass address reaches,
+// following upstream names
+func proxyTarget(address string, upstreams map[string]string) (int, string) {
+	for _, scheme := range []string{"http://unix:", "https://unix:"} {
+		if rest, ok := strings.CutPrefix(address, scheme); ok {
+			socket, _, _ := strings.Cut(rest, ":")
+			return 0, socket
+		}
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return 0, ""
+	}
+	if server, ok := upstreams[u.Host]; ok {
+		if socket, ok := strings.CutPrefix(server, "unix:"); ok {
+			return 0, socket
+		}
+		return hostPort(server), ""
+	}
+	if u.Port() == "" {
+		return 0, ""
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port, ""
+}
+
+// hostPort reads the port of host:port, [::1]:port or a bare port
+func hostPort(address string) int {
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		address = address[i+1:]
+	}
+	port, err := strconv.Atoi(address)
+	if err != nil || port < 1 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+// execStartPortPattern finds the port in the flags app servers listen with:
+// --port 8000, --port=8000, -p 8000, --bind 0.0.0.0:8000, -b :8000
+var execStartPortPattern = regexp.MustCompile(`(?:--port[= ]|-p |--bind[= ]|-b |--listen[= ]|-l )\S*?(\d+)(?:\s|$)`)
+
+// unitPort is the port the unit's app listens on: its PORT environment,
+// else the port in ExecStart's flags
+func unitPort(unit SystemdUnit) int {
+	if port := hostPort(unit.Environment["PORT"]); port != 0 {
+		return port
+	}
+	if match := execStartPortPattern.FindStringSubmatch(unit.ExecStart); match != nil {
+		return hostPort(match[1])
+	}
+	return 0
+}
+
+// importLayout infers the app directory from the directory the app runs
+// from: /opt/shop/releases/20240101/ and /opt/shop/current both live in
+// /opt/shop, whose release is named by the first; any other directory is
+// taken as the app directory itself
+func importLayout(codeDir string) (appDir, release string) {
+	codeDir = path.Clean(codeDir)
+	if i := strings.LastIndex(codeDir, "/releases/"); i > 0 {
+		return codeDir[:i], strings.SplitN(codeDir[i+len("/releases/"):], "/", 2)[0]
+	}
+	if i := strings.LastIndex(codeDir+"/", "/current/"); i > 0 {
+		return codeDir[:i], ""
+	}
+	return codeDir, ""
+}
+
+// InferImport works out the target configuration of appName from its unit
+// and site, either of which may be nil. What lightfold will do differently
+// from the hand-made setup is listed in Warnings.
+func InferImport(appName string, unit *SystemdUnit, site *NginxSite) (*ImportedApp, error) {
+	app := &ImportedApp{AppName: appName, Unit: unit, Site: site, EnvVars: map[string]string{}}
+	switch {
+	case unit != nil && unit.WorkingDirectory != "":
+		app.CodeDir = unit.WorkingDirectory
+	case unit == nil && site != nil && site.Root != "":
+		app.CodeDir = site.Root
+	case unit != nil:
+		return nil, fmt.Errorf("unit %s sets no WorkingDirectory, so the app's directory is unknown", unitName(unit, appName))
+	default:
+		return nil, fmt.Errorf("nginx site %s neither proxies to a port nor serves a root directory", site.File)
+	}
+	if !path.IsAbs(app.CodeDir) {
+		return nil, fmt.Errorf("app directory %s is not an absolute path", app.CodeDir)
+	}
+
+	dir, release := importLayout(app.CodeDir)
+	app.Release = release
+	app.BaseDir = path.Dir(dir)
+	app.AppDir = config.AppDir(app.BaseDir, appName)
+	if path.Base(dir) != appName {
+		app.Warnings = append(app.Warnings, fmt.Sprintf("the app lives in %s, but lightfold keeps it in %s: the next push deploys there and leaves %s in place", dir, app.AppDir, dir))
+	}
+
+	if unit != nil {
+		app.Port = unitPort(*unit)
+		for name, value := range unit.Environment {
+			if name != "PORT" {
+				app.EnvVars[name] = value
+			}
+		}
+		if unit.User != "" && unit.User != "deploy" {
+			app.Warnings = append(app.Warnings, fmt.Sprintf("the unit runs as %s; lightfold's unit runs the app as deploy", unit.User))
+		}
+	}
+	if site != nil {
+		if site.ProxyPort != 0 {
+			if app.Port != 0 && app.Port != site.ProxyPort {
+				app.Warnings = append(app.Warnings, fmt.Sprintf("nginx proxies to port %d while the unit sets %d; using nginx's", site.ProxyPort, app.Port))
+			}
+			app.Port = site.ProxyPort
+		}
+		if site.ProxySocket != "" {
+			app.Warnings = append(app.Warnings, fmt.Sprintf("nginx proxies to the unix socket %s; lightfold proxies to the app's port instead", site.ProxySocket))
+		}
+		if len(site.ServerNames) > 0 {
+			app.Domain = site.ServerNames[0]
+			if len(site.ServerNames) > 1 {
+				app.Warnings = append(app.Warnings, fmt.Sprintf("nginx also answers for %s; lightfold's site serves %s only", strings.Join(site.ServerNames[1:], ", "), app.Domain))
+			}
+		}
+		if site.Certificate != "" {
+			if strings.HasPrefix(site.Certificate, "/etc/letsencrypt/live/") {
+				app.SSL = app.Domain != ""
+			} else {
+				app.Warnings = append(app.Warnings, fmt.Sprintf("the certificate %s is not certbot's; run 'lightfold domain add' to issue one lightfold renews", site.Certificate))
+			}
+		}
+	}
+	return app, nil
+}
+
+func unitName(unit *SystemdUnit, appName string) string {
+	if unit.File != "" {
+		return unit.File
+	}
+	return appName + ".service"
+}
+
+// importScanMarker separates the unit from the site in importScanScript's output
+const importScanMarker = "==> lightfold-import site <=="
+
+// importScanScript prints the app's unit with its drop-ins, then the first
+// nginx site named after the app, headed by its path
+func importScanScript(appName string) string {
+	name := util.ShellQuote(appName)
+	return fmt.Sprintf(`systemctl cat %s.service 2>/dev/null
+echo '%s'
+for f in /etc/nginx/sites-available/%s /etc/nginx/sites-available/%s.conf /etc/nginx/conf.d/%s.conf /etc/nginx/sites-enabled/%s; do
+  if [ -f "$f" ]; then echo "# $f"; cat "$f"; break; fi
+done`, name, importScanMarker, name, name, name, name)
+}
+
+// importInspectScript resolves the current release of appDir and the
+// modification time of codeDir, and checks for the deploy user
+func importInspectScript(appDir, codeDir string) string {
+	return fmt.Sprintf(`echo "current=$(readlink -f %s 2>/dev/null)"
+echo "mtime=$(stat -L -c %%Y %s 2>/dev/null)"
+id -u deploy >/dev/null 2>&1 && echo "deploy_user=yes"
+true`, util.ShellQuote(appDir+"/current"), util.ShellQuote(codeDir))
+}
+
+// ScanImport reads appName's systemd unit and nginx site from the server
+// and infers how it is deployed: the release running, when it was deployed
+// (the directory's modification time when it is not a timestamped release)
+// and the variables of its environment files.
+func ScanImport(runner sshpkg.SudoRunner, appName string) (*ImportedApp, error) {
+	result := runner.ExecuteSudo(importScanScript(appName))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to read the app's unit and nginx site: %w", result.Error)
+	}
+	unitOutput, siteOutput, _ := strings.Cut(result.Stdout, importScanMarker+"\n")
+
+	var unit *SystemdUnit
+	if strings.TrimSpace(unitOutput) != "" {
+		parsed := ParseSystemdUnit(unitOutput)
+		unit = &parsed
+	}
+	var site *NginxSite
+	if strings.TrimSpace(siteOutput) != "" {
+		parsed := ParseNginxSite(siteOutput)
+		parsed.File = strings.TrimPrefix(strings.SplitN(siteOutput, "\n", 2)[0], "# ")
+		site = &parsed
+	}
+	if unit == nil && site == nil {
+		return nil, fmt.Errorf("found neither a systemd unit %s.service nor an nginx site named %s on the server", appName, appName)
+	}
+
+	app, err := InferImport(appName, unit, site)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, _ := importLayout(app.CodeDir)
+	inspect := runner.ExecuteSudo(importInspectScript(dir, app.CodeDir))
+	if inspect.Error != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", app.CodeDir, inspect.Error)
+	}
+	values := map[string]string{}
+	for _, line := range strings.Split(inspect.Stdout, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = value
+		}
+	}
+	if current := values["current"]; app.Release == "" && strings.Contains(current, "/releases/") {
+		_, app.Release = importLayout(current)
+	}
+	if mtime, err := strconv.ParseInt(values["mtime"], 10, 64); err == nil {
+		app.DeployedAt = time.Unix(mtime, 0)
+	}
+	if app.Release == "" && !app.DeployedAt.IsZero() {
+		app.Release = timefmt.ReleaseName(app.DeployedAt)
+	}
+	if values["deploy_user"] != "yes" {
+		app.Warnings = append(app.Warnings, "the server has no deploy user, which lightfold's releases and unit belong to; create it with 'sudo useradd --create-home deploy' before the next push")
+	}
+
+	if unit != nil {
+		for _, file := range unit.EnvironmentFiles {
+			read := runner.ExecuteSudo("cat -- " + util.ShellQuote(file))
+			if read.Error != nil || read.ExitCode != 0 {
+				app.Warnings = append(app.Warnings, fmt.Sprintf("could not read the environment file %s", file))
+				continue
+			}
+			envVars, _ := util.ParseDotenv(read.Stdout, false)
+			for name, value := range envVars {
+				app.EnvVars[name] = value
+			}
+		}
+		if app.Port == 0 {
+			app.Port = hostPort(app.EnvVars["PORT"])
+		}
+		delete(app.EnvVars, "PORT")
+	}
+	return app, nil
+}
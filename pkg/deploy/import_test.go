@@ -0,0 +1,372 @@
+package deploy
+
+import (
+	sshpkg "lightfold/pkg/ssh"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nodeUnit is a hand-written unit for a Node app run from a current symlink
+const nodeUnit = `# /etc/systemd/system/shop.service
+[Unit]
+Description=Shop storefront
+After=network.target
+
+[Service]
+Type=simple
+User=www-data
+WorkingDirectory=/opt/shop/current
+EnvironmentFile=-/opt/shop/shared/.env
+Environment=NODE_ENV=production PORT=3000
+ExecStart=/usr/bin/node server.js
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// certbotSite is a site as certbot --nginx leaves it: the HTTPS server plus
+// a redirect server for port 80
+const certbotSite = `server {
+    server_name shop.example.com www.shop.example.com;
+
+    location / {
+        proxy_pass http://localhost:3000;
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-Proto $scheme;
+    }
+
+    location /api/ {
+        proxy_pass http://127.0.0.1:4000/;
+    }
+
+    listen 443 ssl; # managed by Certbot
+    ssl_certificate /etc/letsencrypt/live/shop.example.com/fullchain.pem; # managed by Certbot
+    ssl_certificate_key /etc/letsencrypt/live/shop.example.com/privkey.pem; # managed by Certbot
+    include /etc/letsencrypt/options-ssl-nginx.conf; # managed by Certbot
+}
+server {
+    if ($host = shop.example.com) {
+        return 301 https://$host$request_uri;
+    } # managed by Certbot
+
+    listen 80;
+    server_name shop.example.com www.shop.example.com;
+    return 404; # managed by Certbot
+}
+`
+
+// gunicornUnit is a Django unit with continuation lines and a drop-in, as
+// systemctl cat prints it
+const gunicornUnit = `# /etc/systemd/system/blog.service
+[Unit]
+Description=gunicorn daemon for blog
+
+[Service]
+User=deploy
+Group=www-data
+WorkingDirectory=/home/deploy/blog
+ExecStart=/home/deploy/blog/venv/bin/gunicorn \
+          --access-logfile - \
+          --workers 3 \
+          --bind 127.0.0.1:8000 \
+          blog.wsgi:application
+
+# /etc/systemd/system/blog.service.d/override.conf
+[Service]
+Environment="DJANGO_SETTINGS_MODULE=blog.settings.production" "SECRET_KEY=a b"
+EnvironmentFile=/etc/blog/env
+`
+
+// upstreamSite proxies through an upstream block and serves static files
+const upstreamSite = `upstream blog_app {
+    server 127.0.0.1:8000 fail_timeout=0;
+}
+
+server {
+    listen 80;
+    server_name _;
+
+    location /static/ {
+        alias /home/deploy/blog/static/;
+    }
+
+    location / {
+        include proxy_params;
+        proxy_pass http://blog_app;
+    }
+}
+`
+
+func TestParseSystemdUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    SystemdUnit
+	}{
+		{
+			name:    "node unit",
+			content: nodeUnit,
+			want: SystemdUnit{
+				File:             "/etc/systemd/system/shop.service",
+				WorkingDirectory: "/opt/shop/current",
+				ExecStart:        "/usr/bin/node server.js",
+				User:             "www-data",
+				EnvironmentFiles: []string{"/opt/shop/shared/.env"},
+				Environment:      map[string]string{"NODE_ENV": "production", "PORT": "3000"},
+			},
+		},
+		{
+			name:    "continuations and drop-in",
+			content: gunicornUnit,
+			want: SystemdUnit{
+				File:             "/etc/systemd/system/blog.service",
+				WorkingDirectory: "/home/deploy/blog",
+				ExecStart:        "/home/deploy/blog/venv/bin/gunicorn --access-logfile - --workers 3 --bind 127.0.0.1:8000 blog.wsgi:application",
+				User:             "deploy",
+				EnvironmentFiles: []string{"/etc/blog/env"},
+				Environment:      map[string]string{"DJANGO_SETTINGS_MODULE": "blog.settings.production", "SECRET_KEY": "a b"},
+			},
+		},
+		{
+			name: "drop-in resets ExecStart and environment files",
+			content: `[Service]
+ExecStart=-/usr/local/bin/api --port=9000
+EnvironmentFile=/etc/api/one
+WorkingDirectory=-/srv/api/releases/20240301120000
+[Service]
+ExecStart=
+ExecStart=/usr/local/bin/api --port=9100
+EnvironmentFile=
+EnvironmentFile=-/etc/api/two
+; a comment
+[Install]
+Environment=IGNORED=1
+`,
+			want: SystemdUnit{
+				WorkingDirectory: "/srv/api/releases/20240301120000",
+				ExecStart:        "/usr/local/bin/api --port=9100",
+				EnvironmentFiles: []string{"/etc/api/two"},
+				Environment:      map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSystemdUnit(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSystemdUnit() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNginxSite(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    NginxSite
+	}{
+		{
+			name:    "certbot managed",
+			content: certbotSite,
+			want: NginxSite{
+				ServerNames: []string{"shop.example.com", "www.shop.example.com"},
+				ProxyPort:   3000,
+				Certificate: "/etc/letsencrypt/live/shop.example.com/fullchain.pem",
+			},
+		},
+		{
+			name:    "upstream block",
+			content: upstreamSite,
+			want:    NginxSite{ProxyPort: 8000},
+		},
+		{
+			name: "unix socket",
+			content: `server {
+    listen 80;
+    server_name blog.example.org;
+    location / {
+        proxy_pass http://unix:/run/gunicorn.sock;
+    }
+}`,
+			want: NginxSite{ServerNames: []string{"blog.example.org"}, ProxySocket: "/run/gunicorn.sock"},
+		},
+		{
+			name: "static site",
+			content: `server {
+  listen 80 default_server;
+  server_name "docs.example.com";
+  root /var/www/docs/current/dist;
+  index index.html;
+  location / {
+    try_files $uri $uri/ /index.html; # SPA fallback
+  }
+  location /assets/ {
+    root /var/www/cdn;
+    expires 1y;
+  }
+}`,
+			want: NginxSite{ServerNames: []string{"docs.example.com"}, Root: "/var/www/docs/current/dist"},
+		},
+		{
+			name: "api location before location /",
+			content: `server {
+    server_name app.example.com;
+    location /api { proxy_pass http://127.0.0.1:5000; }
+    location / { proxy_pass http://[::1]:3001/; }
+}`,
+			want: NginxSite{ServerNames: []string{"app.example.com"}, ProxyPort: 3001},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseNginxSite(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNginxSite() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitPort(t *testing.T) {
+	tests := []struct {
+		execStart string
+		env       map[string]string
+		want      int
+	}{
+		{"/usr/bin/node server.js", map[string]string{"PORT": "3000"}, 3000},
+		{"/usr/local/bin/uvicorn main:app --host 0.0.0.0 --port 8080", nil, 8080},
+		{"/usr/local/bin/api --port=9100", nil, 9100},
+		{"bundle exec puma -C config/puma.rb -p 9292", nil, 9292},
+		{"gunicorn --workers 3 --bind 127.0.0.1:8000 app.wsgi", nil, 8000},
+		{"gunicorn -b unix:/run/app1.sock app.wsgi", nil, 0},
+		{"/usr/bin/node server.js", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := unitPort(SystemdUnit{ExecStart: tt.execStart, Environment: tt.env}); got != tt.want {
+			t.Errorf("unitPort(%q, %v) = %d, want %d", tt.execStart, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestImportLayout(t *testing.T) {
+	tests := []struct {
+		codeDir, appDir, release string
+	}{
+		{"/opt/shop/current", "/opt/shop", ""},
+		{"/opt/shop/current/public", "/opt/shop", ""},
+		{"/var/www/api/releases/20240301120000", "/var/www/api", "20240301120000"},
+		{"/var/www/api/releases/20240301120000/web/", "/var/www/api", "20240301120000"},
+		{"/home/deploy/blog", "/home/deploy/blog", ""},
+	}
+	for _, tt := range tests {
+		appDir, release := importLayout(tt.codeDir)
+		if appDir != tt.appDir || release != tt.release {
+			t.Errorf("importLayout(%q) = %q, %q, want %q, %q", tt.codeDir, appDir, release, tt.appDir, tt.release)
+		}
+	}
+}
+
+func TestInferImport(t *testing.T) {
+	unit := ParseSystemdUnit(nodeUnit)
+	site := ParseNginxSite(certbotSite)
+	app, err := InferImport("shop", &unit, &site)
+	if err != nil {
+		t.Fatalf("InferImport() error: %v", err)
+	}
+	if app.AppDir != "/opt/shop" || app.BaseDir != "/opt" || app.Port != 3000 || app.Domain != "shop.example.com" || !app.SSL {
+		t.Errorf("InferImport() = dir %s, base %s, port %d, domain %s, ssl %v", app.AppDir, app.BaseDir, app.Port, app.Domain, app.SSL)
+	}
+	if !reflect.DeepEqual(app.EnvVars, map[string]string{"NODE_ENV": "production"}) {
+		t.Errorf("EnvVars = %v, want NODE_ENV only", app.EnvVars)
+	}
+	warnings := strings.Join(app.Warnings, "\n")
+	for _, want := range []string{"runs as www-data", "also answers for www.shop.example.com"} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("Warnings = %q, want one about %q", warnings, want)
+		}
+	}
+
+	blog := ParseSystemdUnit(gunicornUnit)
+	app, err = InferImport("myblog", &blog, nil)
+	if err != nil {
+		t.Fatalf("InferImport() error: %v", err)
+	}
+	if app.AppDir != "/home/deploy/myblog" || app.Port != 8000 || app.Domain != "" {
+		t.Errorf("InferImport() = dir %s, port %d, domain %q", app.AppDir, app.Port, app.Domain)
+	}
+	if !strings.Contains(strings.Join(app.Warnings, "\n"), "lives in /home/deploy/blog") {
+		t.Errorf("Warnings = %q, want one about the directory name", app.Warnings)
+	}
+
+	docs := ParseNginxSite(`server { server_name docs.example.com; root /var/www/docs/current; }`)
+	app, err = InferImport("docs", nil, &docs)
+	if err != nil || !app.Static() || app.AppDir != "/var/www/docs" || app.Port != 0 {
+		t.Errorf("static InferImport() = %+v, %v", app, err)
+	}
+
+	if _, err := InferImport("api", &SystemdUnit{ExecStart: "/usr/bin/api"}, nil); err == nil {
+		t.Error("InferImport() without WorkingDirectory should fail")
+	}
+}
+
+// importServer answers the import scripts by the start of the command
+type importServer map[string]string
+
+func (s importServer) ExecuteSudo(command string) *sshpkg.CommandResult {
+	for prefix, stdout := range s {
+		if strings.HasPrefix(command, prefix) {
+			return &sshpkg.CommandResult{Stdout: stdout}
+		}
+	}
+	return &sshpkg.CommandResult{ExitCode: 1}
+}
+
+func TestScanImport(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	unixTime := strconv.FormatInt(mtime.Unix(), 10)
+	server := importServer{
+		"systemctl cat":                  nodeUnit + importScanMarker + "\n# /etc/nginx/sites-available/shop\n" + certbotSite,
+		"echo \"current=":                "current=/opt/shop/releases/v42\nmtime=" + unixTime + "\ndeploy_user=yes\n",
+		"cat -- '/opt/shop/shared/.env'": "DATABASE_URL=postgres://localhost/shop\nPORT=3999\n",
+	}
+
+	app, err := ScanImport(server, "shop")
+	if err != nil {
+		t.Fatalf("ScanImport() error: %v", err)
+	}
+	if app.Site == nil || app.Site.File != "/etc/nginx/sites-available/shop" {
+		t.Errorf("Site = %+v, want the sites-available file", app.Site)
+	}
+	if app.Release != "v42" || !app.DeployedAt.Equal(mtime) {
+		t.Errorf("Release = %q, DeployedAt = %v, want v42 at %v", app.Release, app.DeployedAt, mtime)
+	}
+	want := map[string]string{"NODE_ENV": "production", "DATABASE_URL": "postgres://localhost/shop"}
+	if !reflect.DeepEqual(app.EnvVars, want) {
+		t.Errorf("EnvVars = %v, want %v", app.EnvVars, want)
+	}
+	if strings.Contains(strings.Join(app.Warnings, "\n"), "no deploy user") {
+		t.Errorf("Warnings = %q, want no deploy user warning", app.Warnings)
+	}
+
+	// Without a current symlink the release is named after the directory's mtime
+	server["echo \"current="] = "current=\nmtime=" + unixTime + "\n"
+	app, err = ScanImport(server, "shop")
+	if err != nil {
+		t.Fatalf("ScanImport() error: %v", err)
+	}
+	if app.Release != mtime.UTC().Format("20060102150405") {
+		t.Errorf("Release = %q, want %s", app.Release, mtime.UTC().Format("20060102150405"))
+	}
+	if !strings.Contains(strings.Join(app.Warnings, "\n"), "no deploy user") {
+		t.Errorf("Warnings = %q, want a deploy user warning", app.Warnings)
+	}
+
+	if _, err := ScanImport(importServer{"systemctl cat": importScanMarker + "\n"}, "shop"); err == nil || !strings.Contains(err.Error(), "found neither") {
+		t.Errorf("ScanImport() without unit or site error = %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lightfold/pkg/util"
+	"os"
+	"strings"
+	"time"
+)
+
+// UploadAttempts is how many times a release tarball is uploaded before a
+// checksum mismatch fails the deploy
+const UploadAttempts = 3
+
+// Files written into every release directory right after extraction
+const (
+	// ReleaseManifestFile holds the verified SHA-256 of the uploaded tarball
+	// and the commit the release was built from
+	ReleaseManifestFile = ".lightfold-release.json"
+	// ReleaseChecksumsFile lists the SHA-256 of every file the tarball
+	// shipped, in sha256sum format, so the release can be checked later
+	ReleaseChecksumsFile = ".lightfold-files.sha256"
+)
+
+// ChecksumMismatchError reports an upload whose copy on the server never
+// matched the local tarball
+type ChecksumMismatchError struct {
+	Path     string // Remote path of the tarball
+	Expected string // SHA-256 of the local tarball
+	Got      string // SHA-256 the server computed on the last attempt
+	Attempts int
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	got := e.Got
+	if got == "" {
+		got = "no checksum"
+	}
+	return fmt.Sprintf("checksum mismatch for %s after %d upload attempts: expected sha256 %s, server has %s (the connection may be corrupting transfers)",
+		e.Path, e.Attempts, e.Expected, got)
+}
+
+// ReleaseManifest is the content of ReleaseManifestFile
+type ReleaseManifest struct {
+	TarballSHA256 string    `json:"tarball_sha256"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	ReleaseSource
+}
+
+// ReleaseSource is where a release came from. The git fields are empty for
+// projects that are not a git checkout and for releases uploaded before they
+// were recorded.
+type ReleaseSource struct {
+	Commit     string `json:"commit,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Subject    string `json:"subject,omitempty"`     // First line of the commit message
+	DeployedBy string `json:"deployed_by,omitempty"` // Local user@host that uploaded it
+}
+
+// releaseSource reads the project's checked out commit for the manifest
+func (e *Executor) releaseSource() ReleaseSource {
+	rev := util.GetGitRevision(e.projectPath)
+	return ReleaseSource{Commit: rev.Commit, Branch: rev.Branch, Subject: rev.Subject, DeployedBy: util.LocalIdentity()}
+}
+
+// FileSHA256 returns the hex SHA-256 of a local file, as sha256sum prints it
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// parseSHA256Sum returns the digest from a line of sha256sum output
+func parseSHA256Sum(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// uploadVerified uploads the tarball and compares the server's SHA-256 of it
+// with checksum before anything extracts it. A mismatch uploads it again, up
+// to UploadAttempts times.
+func (e *Executor) uploadVerified(tarballPath, remoteTarball, checksum string) error {
+	got := ""
+	for attempt := 1; attempt <= UploadAttempts; attempt++ {
+		if err := e.ssh.UploadFile(tarballPath, remoteTarball); err != nil {
+			return fmt.Errorf("failed to upload tarball: %w", err)
+		}
+
+		result := e.ssh.ExecuteIdempotent("sha256sum " + remoteTarball)
+		if result.Error != nil {
+			return fmt.Errorf("failed to checksum uploaded tarball: %w", result.Error)
+		}
+		got = parseSHA256Sum(result.Stdout)
+		if result.ExitCode == 0 && got == checksum {
+			return nil
+		}
+		if attempt < UploadAttempts {
+			e.sendOutput(fmt.Sprintf("Uploaded tarball does not match (sha256 %s), uploading again (attempt %d of %d)", got, attempt+1, UploadAttempts), 1)
+		}
+	}
+	return &ChecksumMismatchError{Path: remoteTarball, Expected: checksum, Got: got, Attempts: UploadAttempts}
+}
+
+// releaseManifestCommand records the tarball's checksum and the checksum of
+// every file it shipped inside the freshly extracted release. It runs as one
+// shell so the redirects into the root-owned release are privileged too.
+func releaseManifestCommand(releasePath string, manifest ReleaseManifest) (string, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	script := fmt.Sprintf("cd %s && find . -type f ! -name '.lightfold-*' -print0 | sort -z | xargs -0 -r sha256sum > %s && printf '%%s\\n' %s > %s",
+		releasePath, ReleaseChecksumsFile, util.ShellQuote(string(data)), ReleaseManifestFile)
+	return "sh -c " + util.ShellQuote(script), nil
+}
+
+// ReleaseIntegrity is whether a release on the server still holds the files
+// its tarball shipped
+type ReleaseIntegrity struct {
+	Release       string
+	TarballSHA256 string        // From the release manifest, "" when it has none
+	Source        ReleaseSource // From the release manifest
+	Checked       bool          // False for releases uploaded before checksums were recorded
+	Modified      []string      // Shipped files changed or deleted since the upload
+}
+
+// Intact reports whether the release was checked and nothing it shipped changed
+func (r ReleaseIntegrity) Intact() bool {
+	return r.Checked && len(r.Modified) == 0
+}
+
+// releaseIntegrityScript prints, for every release, a "release" line, its
+// manifest, the files failing their checksum and "checked" when it has a
+// checksum list. Files added after the upload, such as build output, are not
+// in the list and do not count.
+func releaseIntegrityScript(releasesDir string, releases []string) string {
+	parts := make([]string, 0, len(releases))
+	for _, release := range releases {
+		dir := releasesDir + "/" + release
+		parts = append(parts, releaseManifestLines(releasesDir, release)+fmt.Sprintf(
+			`; if [ -f %[1]s/%[2]s ]; then (cd %[1]s && sha256sum --quiet -c %[2]s 2>/dev/null | sed 's/^/failed /'); echo checked; fi`,
+			dir, ReleaseChecksumsFile))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// releaseManifestLines prints a release's "release" and "manifest" lines
+func releaseManifestLines(releasesDir, release string) string {
+	return fmt.Sprintf(`echo "release %[1]s"; echo "manifest $(tr -d '\n' < %[2]s/%[1]s/%[3]s 2>/dev/null)"`,
+		release, releasesDir, ReleaseManifestFile)
+}
+
+// parseReleaseIntegrity reads the output of releaseIntegrityScript
+func parseReleaseIntegrity(output string) []ReleaseIntegrity {
+	var results []ReleaseIntegrity
+	for _, line := range strings.Split(output, "\n") {
+		kind, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if kind == "release" {
+			results = append(results, ReleaseIntegrity{Release: rest})
+			continue
+		}
+		if len(results) == 0 {
+			continue
+		}
+		current := &results[len(results)-1]
+		switch kind {
+		case "manifest":
+			var manifest ReleaseManifest
+			if json.Unmarshal([]byte(rest), &manifest) == nil {
+				current.TarballSHA256 = manifest.TarballSHA256
+				current.Source = manifest.ReleaseSource
+			}
+		case "failed":
+			if name, _, ok := strings.Cut(rest, ": FAILED"); ok {
+				current.Modified = append(current.Modified, strings.TrimPrefix(name, "./"))
+			}
+		case "checked":
+			current.Checked = true
+		}
+	}
+	return results
+}
+
+// VerifyReleases checks every release on the server against the checksums
+// recorded when it was uploaded, in one round trip. Releases are readable by
+// the app user only, so the check runs as root.
+func (e *Executor) VerifyReleases(releases []string) ([]ReleaseIntegrity, error) {
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	releasesDir := fmt.Sprintf("%s/releases", e.AppDir())
+	result := e.ssh.ExecuteSudo("sh -c " + util.ShellQuote(releaseIntegrityScript(releasesDir, releases)))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return parseReleaseIntegrity(result.Stdout), nil
+}
+
+// ReleaseSources reads where each release came from out of its manifest, in
+// one round trip, as root like VerifyReleases. Releases uploaded before
+// sources were recorded map to an empty ReleaseSource.
+func (e *Executor) ReleaseSources(releases []string) (map[string]ReleaseSource, error) {
+	if len(releases) == 0 {
+		return map[string]ReleaseSource{}, nil
+	}
+	releasesDir := fmt.Sprintf("%s/releases", e.AppDir())
+	lines := make([]string, 0, len(releases))
+	for _, release := range releases {
+		lines = append(lines, releaseManifestLines(releasesDir, release))
+	}
+	result := e.ssh.ExecuteSudoIdempotent("sh -c " + util.ShellQuote(strings.Join(lines, "; ")))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	sources := make(map[string]ReleaseSource, len(releases))
+	for _, release := range parseReleaseIntegrity(result.Stdout) {
+		sources[release.Release] = release.Source
+	}
+	return sources, nil
+}
+
+// UploadedChecksum returns the verified SHA-256 of the last tarball uploaded,
+// or "" before any upload
+func (e *Executor) UploadedChecksum() string {
+	return e.uploadedChecksum
+}
@@ -0,0 +1,197 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSHA256(t *testing.T) {
+	got, err := FileSHA256(writeLocalTarball(t))
+	if err != nil {
+		t.Fatalf("FileSHA256() error: %v", err)
+	}
+	if got != tarballChecksum {
+		t.Errorf("FileSHA256() = %s, want %s", got, tarballChecksum)
+	}
+}
+
+func TestUploadRelease_VerifiesChecksumBeforeExtracting(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	answerChecksum(server, tarballChecksum)
+
+	releasePath, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000")
+	if err != nil {
+		t.Fatalf("UploadReleaseAs() error: %v", err)
+	}
+
+	upload := server.Index("scp -t /tmp/lightfold-myapp-release.tar.gz")
+	verify := server.Index("sha256sum /tmp/lightfold-myapp-release.tar.gz")
+	extract := server.Index("tar -xzf /tmp/lightfold-myapp-release.tar.gz")
+	manifest := server.Index(ReleaseManifestFile)
+	if upload < 0 || verify < 0 || extract < 0 || manifest < 0 {
+		t.Fatalf("expected upload, sha256sum, extraction and manifest commands, got %v", server.Commands())
+	}
+	if !(upload < verify && verify < extract && extract < manifest) {
+		t.Errorf("expected upload, then sha256sum, then extraction, then manifest; got %v", server.Commands())
+	}
+	if !server.Ran("cd " + releasePath + " && find . -type f") {
+		t.Errorf("expected the shipped files to be checksummed inside the release, got %v", server.Commands())
+	}
+	if !server.Ran(tarballChecksum) {
+		t.Errorf("expected the manifest to record the tarball checksum, got %v", server.Commands())
+	}
+	if got := exec.UploadedChecksum(); got != tarballChecksum {
+		t.Errorf("UploadedChecksum() = %q, want %q", got, tarballChecksum)
+	}
+}
+
+func TestUploadRelease_RetriesOnChecksumMismatch(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	answerChecksum(server, strings.Repeat("0", 64), tarballChecksum)
+
+	if _, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000"); err != nil {
+		t.Fatalf("UploadReleaseAs() error: %v", err)
+	}
+	uploads := 0
+	for _, command := range server.Commands() {
+		if strings.HasPrefix(command, "scp -t /tmp/lightfold-myapp-release.tar.gz") {
+			uploads++
+		}
+	}
+	if uploads != 2 {
+		t.Errorf("expected 2 uploads after one mismatch, got %d: %v", uploads, server.Commands())
+	}
+	if !server.Ran("tar -xzf") {
+		t.Error("expected the verified upload to be extracted")
+	}
+}
+
+func TestUploadRelease_GivesUpAfterRepeatedMismatch(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	bad := strings.Repeat("f", 64)
+	answerChecksum(server, bad)
+
+	_, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000")
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("UploadReleaseAs() error = %v, want ChecksumMismatchError", err)
+	}
+	if mismatch.Attempts != UploadAttempts || mismatch.Expected != tarballChecksum || mismatch.Got != bad {
+		t.Errorf("unexpected mismatch error: %+v", mismatch)
+	}
+	if server.Ran("tar -xzf") {
+		t.Error("a tarball that never matched must not be extracted")
+	}
+	if !server.Ran("rm -rf /srv/myapp/releases/20240101000000") {
+		t.Errorf("expected the release directory to be removed, got %v", server.Commands())
+	}
+}
+
+func TestParseReleaseIntegrity(t *testing.T) {
+	output := `release 20240102000000
+manifest {"tarball_sha256":"abc123","uploaded_at":"2024-01-02T00:00:00Z","commit":"0123abc","branch":"hotfix/login","subject":"Fix the login redirect","deployed_by":"alice@laptop"}
+failed ./app.py: FAILED
+failed ./templates/index.html: FAILED open or read
+checked
+release 20240101000000
+manifest {"tarball_sha256":"def456","uploaded_at":"2024-01-01T00:00:00Z"}
+checked
+release 20231231000000
+manifest
+`
+	got := parseReleaseIntegrity(output)
+	want := []ReleaseIntegrity{
+		{
+			Release:       "20240102000000",
+			TarballSHA256: "abc123",
+			Source:        ReleaseSource{Commit: "0123abc", Branch: "hotfix/login", Subject: "Fix the login redirect", DeployedBy: "alice@laptop"},
+			Checked:       true,
+			Modified:      []string{"app.py", "templates/index.html"},
+		},
+		{Release: "20240101000000", TarballSHA256: "def456", Checked: true},
+		{Release: "20231231000000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseReleaseIntegrity() = %+v, want %+v", got, want)
+	}
+	if got[0].Intact() || !got[1].Intact() || got[2].Intact() {
+		t.Errorf("Intact() = %v %v %v, want false true false", got[0].Intact(), got[1].Intact(), got[2].Intact())
+	}
+}
+
+func TestReleaseManifest_WrittenAsRoot(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	answerChecksum(server, tarballChecksum)
+
+	if _, err := exec.UploadReleaseAs(writeLocalTarball(t), "20240101000000"); err != nil {
+		t.Fatalf("UploadReleaseAs() error: %v", err)
+	}
+	if !server.Ran("sudo -n sh -c 'cd /srv/myapp/releases/20240101000000 && find .") {
+		t.Errorf("manifest should be written in one privileged shell, got %v", server.Commands())
+	}
+
+	if _, err := exec.VerifyReleases([]string{"20240101000000"}); err != nil {
+		t.Fatalf("VerifyReleases() error: %v", err)
+	}
+	if _, err := exec.ReleaseSources([]string{"20240101000000"}); err != nil {
+		t.Fatalf("ReleaseSources() error: %v", err)
+	}
+	commands := server.Commands()
+	for _, command := range commands[len(commands)-2:] {
+		if !strings.HasPrefix(command, "sudo -n sh -c ") {
+			t.Errorf("release manifests should be read as root, got %q", command)
+		}
+	}
+}
+
+func TestReleaseIntegrityScript(t *testing.T) {
+	script := releaseIntegrityScript("/srv/myapp/releases", []string{"20240102000000"})
+	for _, want := range []string{
+		`echo "release 20240102000000"`,
+		"/srv/myapp/releases/20240102000000/" + ReleaseManifestFile,
+		"cd /srv/myapp/releases/20240102000000 && sha256sum --quiet -c " + ReleaseChecksumsFile,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestReleaseManifest_RecordsSource(t *testing.T) {
+	manifest := ReleaseManifest{
+		TarballSHA256: "abc123",
+		ReleaseSource: ReleaseSource{Commit: "0123abc", Branch: "main", Subject: "Don't break the \"quotes\"", DeployedBy: "ci@runner"},
+	}
+	releasePath := t.TempDir()
+	os.WriteFile(filepath.Join(releasePath, "index.html"), []byte("hello"), 0644)
+	command, err := releaseManifestCommand(releasePath, manifest)
+	if err != nil {
+		t.Fatalf("releaseManifestCommand() error: %v", err)
+	}
+	if out, err := exec.Command("sh", "-c", command).CombinedOutput(); err != nil {
+		t.Fatalf("manifest command failed: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(filepath.Join(releasePath, ReleaseManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written ReleaseManifest
+	if err := json.Unmarshal(data, &written); err != nil || written.ReleaseSource != manifest.ReleaseSource || written.TarballSHA256 != "abc123" {
+		t.Errorf("manifest = %s, want the source and checksum recorded", data)
+	}
+	if sums, _ := os.ReadFile(filepath.Join(releasePath, ReleaseChecksumsFile)); !strings.Contains(string(sums), "./index.html") {
+		t.Errorf("checksums = %q, want the shipped file", sums)
+	}
+
+	// Projects outside git leave the source out of the manifest
+	command, _ = releaseManifestCommand("/srv/myapp/releases/20240102000000", ReleaseManifest{TarballSHA256: "abc123"})
+	if strings.Contains(command, "branch") || strings.Contains(command, "commit") {
+		t.Errorf("empty source should not be written:\n%s", command)
+	}
+}
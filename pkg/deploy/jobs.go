@@ -0,0 +1,264 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	runtimepkg "lightfold/pkg/runtime"
+	sshpkg "lightfold/pkg/ssh"
+	"sort"
+	"strings"
+)
+
+// JobAppKey marks the job units lightfold wrote and the app they belong to.
+// systemd ignores keys starting with X-.
+const JobAppKey = "X-Lightfold-App"
+
+const systemdDir = "/etc/systemd/system"
+
+// JobUnitName returns the name of a job's systemd timer and service, e.g.
+// myapp-cleanup
+func JobUnitName(appName, jobName string) string {
+	return appName + "-" + jobName
+}
+
+// systemdQuote quotes a command line for an Exec= setting. $ and % are
+// doubled so systemd passes them through to the shell unexpanded.
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
+
+// jobUnits renders the service that runs a job in the current release with
+// the app's shared env, and the timer that starts it at the OnCalendar times.
+// appPath is the app's directory on the server.
+func jobUnits(appName, appPath string, job config.Job, onCalendar, environment string) (service, timer string) {
+	service = fmt.Sprintf(`[Unit]
+Description=%[1]s job %[2]s
+%[3]s=%[1]s
+
+[Service]
+Type=oneshot
+WorkingDirectory=%[4]s/current
+EnvironmentFile=-%[4]s/shared/env/.env%[5]s
+ExecStart=/bin/sh -c %[6]s
+User=%[7]s
+Group=%[7]s
+StandardOutput=journal
+StandardError=journal
+`, appName, job.Name, JobAppKey, appPath, environment, systemdQuote(job.Command), config.DefaultDeployUser)
+
+	timer = fmt.Sprintf(`[Unit]
+Description=%[1]s job %[2]s schedule (%[4]s)
+%[3]s=%[1]s
+
+[Timer]
+OnCalendar=%[5]s
+
+[Install]
+WantedBy=timers.target
+`, appName, job.Name, JobAppKey, job.Schedule, onCalendar)
+	return service, timer
+}
+
+// jobEnvironment puts the app's runtime on the job's PATH: the Python venv or
+// the pinned Node.js major
+func (e *Executor) jobEnvironment() string {
+	if e.detection != nil && e.detection.Language == "Python" {
+		return fmt.Sprintf("\nEnvironment=PATH=%s/shared/venv/bin:%s", e.AppDir(), runtimepkg.SystemPath)
+	}
+	return e.runtimeEnvironment()
+}
+
+// JobSync is what SyncJobs changed on the server
+type JobSync struct {
+	Installed []string // Jobs whose units were written or rewritten
+	Removed   []string // Jobs no longer configured whose units were deleted
+}
+
+// Changed reports whether any job units were written or removed
+func (s *JobSync) Changed() bool {
+	return len(s.Installed)+len(s.Removed) > 0
+}
+
+// Summary describes the change, e.g. "installed cleanup; removed report"
+func (s *JobSync) Summary() string {
+	parts := []string{}
+	if len(s.Installed) > 0 {
+		parts = append(parts, "installed "+strings.Join(s.Installed, ", "))
+	}
+	if len(s.Removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(s.Removed, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// SyncJobs makes the app's job timers match jobs. Units that are unchanged
+// are left alone, changed ones are rewritten and their timers restarted, and
+// timers of jobs no longer configured are removed. The jobs are validated
+// again first, so a bad schedule never reaches systemd.
+func (e *Executor) SyncJobs(jobs []config.Job) (*JobSync, error) {
+	if err := config.ValidateJobs(jobs); err != nil {
+		return nil, err
+	}
+
+	installed, err := e.installedJobUnits()
+	if err != nil {
+		return nil, err
+	}
+
+	synced := &JobSync{}
+	wanted := map[string]bool{}
+	for _, job := range jobs {
+		name := JobUnitName(e.appName, job.Name)
+		wanted[name] = true
+
+		onCalendar, _ := job.OnCalendar()
+		service, timer := jobUnits(e.appName, e.AppDir(), job, onCalendar, e.jobEnvironment())
+		changed := false
+		for _, unit := range []struct{ path, content string }{
+			{fmt.Sprintf("%s/%s.service", systemdDir, name), service},
+			{fmt.Sprintf("%s/%s.timer", systemdDir, name), timer},
+		} {
+			current := e.ssh.Execute("cat " + unit.path)
+			if current.ExitCode == 0 && current.Stdout == unit.content {
+				continue
+			}
+			if current.ExitCode == 0 && !installed[name] {
+				return nil, fmt.Errorf("job %s: %s already exists and was not written by lightfold for %s", job.Name, unit.path, e.appName)
+			}
+			file := sshpkg.RemoteFile{Path: unit.path, Mode: config.PermConfigFile, Owner: "root:root"}
+			if err := e.ssh.InstallFile(file, unit.content); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", unit.path, err)
+			}
+			changed = true
+		}
+		if changed {
+			synced.Installed = append(synced.Installed, job.Name)
+		}
+	}
+
+	for name := range installed {
+		if !wanted[name] {
+			synced.Removed = append(synced.Removed, strings.TrimPrefix(name, e.appName+"-"))
+		}
+	}
+	sort.Strings(synced.Removed)
+	if !synced.Changed() {
+		return synced, nil
+	}
+
+	commands := []string{}
+	for _, jobName := range synced.Removed {
+		name := JobUnitName(e.appName, jobName)
+		commands = append(commands, fmt.Sprintf("(systemctl disable --now %[2]s.timer 2>/dev/null; rm -f %[1]s/%[2]s.timer %[1]s/%[2]s.service)", systemdDir, name))
+	}
+	commands = append(commands, "systemctl daemon-reload")
+	for _, jobName := range synced.Installed {
+		name := JobUnitName(e.appName, jobName)
+		commands = append(commands, fmt.Sprintf("systemctl enable %[1]s.timer && systemctl restart %[1]s.timer", name))
+	}
+	result := e.ssh.ExecuteSudo(strings.Join(commands, " && "))
+	if result.Error != nil || result.ExitCode != 0 {
+		return nil, formatSSHError("failed to reload job timers", result)
+	}
+	return synced, nil
+}
+
+// installedJobUnits returns the names of the job units on the server that
+// belong to the app
+func (e *Executor) installedJobUnits() (map[string]bool, error) {
+	result := e.ssh.Execute(fmt.Sprintf("grep -lx '%s=%s' %s/*.timer 2>/dev/null; true", JobAppKey, e.appName, systemdDir))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	names := map[string]bool{}
+	for _, path := range strings.Fields(result.Stdout) {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, systemdDir+"/"), ".timer")
+		names[name] = true
+	}
+	return names, nil
+}
+
+// RemoveJobsCommand returns a shell command that disables and deletes every
+// job unit of the app. It succeeds when there are none.
+func RemoveJobsCommand(appName string) string {
+	return fmt.Sprintf(`for t in $(grep -lx '%[1]s=%[2]s' %[3]s/*.timer 2>/dev/null); do u=$(basename "$t" .timer); systemctl disable --now "$u.timer" 2>/dev/null; rm -f "%[3]s/$u.timer" "%[3]s/$u.service"; done; systemctl daemon-reload`, JobAppKey, appName, systemdDir)
+}
+
+// JobStatus is a job's timer and its last run, as systemd reports them
+type JobStatus struct {
+	Name       string
+	Schedule   string
+	Installed  bool
+	Active     bool   // The timer is running
+	LastRun    string // When the timer last fired, "" if never
+	NextRun    string
+	ExitStatus string // Exit code of the last run, "" if it has not finished one
+	Result     string // systemd result of the last run, e.g. success or exit-code
+}
+
+// jobStatusScript prints key=value properties of every job's timer and
+// service, each job starting with a job= line
+func jobStatusScript(appName string, jobs []config.Job) string {
+	parts := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		name := JobUnitName(appName, job.Name)
+		parts = append(parts, fmt.Sprintf(
+			"echo job=%[1]s; systemctl show %[2]s.timer -p LoadState -p ActiveState -p LastTriggerUSec -p NextElapseUSecRealtime; systemctl show %[2]s.service -p ExecMainStatus -p ExecMainExitTimestamp -p Result",
+			job.Name, name))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseJobStatuses reads the output of jobStatusScript. systemctl prints
+// properties in its own order, so each job's are collected before use.
+func parseJobStatuses(output string, jobs []config.Job) []JobStatus {
+	props := map[string]map[string]string{}
+	var current map[string]string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if key == "job" {
+			current = map[string]string{}
+			props[value] = current
+			continue
+		}
+		if current != nil && value != "n/a" {
+			current[key] = value
+		}
+	}
+
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		p := props[job.Name]
+		status := JobStatus{
+			Name:      job.Name,
+			Schedule:  job.Schedule,
+			Installed: p["LoadState"] == "loaded",
+			Active:    p["ActiveState"] == "active",
+			LastRun:   p["LastTriggerUSec"],
+			NextRun:   p["NextElapseUSecRealtime"],
+		}
+		if p["ExecMainExitTimestamp"] != "" {
+			status.ExitStatus = p["ExecMainStatus"]
+			status.Result = p["Result"]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// JobStatuses reads the schedule, last run and exit status of every job from
+// systemd in one round trip
+func (e *Executor) JobStatuses(jobs []config.Job) ([]JobStatus, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	result := e.ssh.Execute(jobStatusScript(e.appName, jobs))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return parseJobStatuses(result.Stdout, jobs), nil
+}
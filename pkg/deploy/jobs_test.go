@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"strings"
+	"testing"
+)
+
+func TestJobUnits(t *testing.T) {
+	job := config.Job{Name: "cleanup", Schedule: "0 3 * * *", Command: `node scripts/cleanup.js --older-than "30 days" > /tmp/out-$(date +%F).log`}
+	service, timer := jobUnits("myapp", "/srv/myapp", job, "*-*-* 03:00:00 UTC", "")
+
+	for _, want := range []string{
+		"Type=oneshot\n",
+		"WorkingDirectory=/srv/myapp/current\n",
+		"EnvironmentFile=-/srv/myapp/shared/env/.env\n",
+		`ExecStart=/bin/sh -c "node scripts/cleanup.js --older-than \"30 days\" > /tmp/out-$$(date +%%F).log"` + "\n",
+		"User=deploy\n",
+		"X-Lightfold-App=myapp\n",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service unit missing %q:\n%s", want, service)
+		}
+	}
+	for _, want := range []string{"OnCalendar=*-*-* 03:00:00 UTC\n", "WantedBy=timers.target\n", "X-Lightfold-App=myapp\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+	if strings.Contains(timer, "Persistent=") {
+		t.Errorf("timer should not catch up on missed runs:\n%s", timer)
+	}
+	if JobUnitName("myapp", "cleanup") != "myapp-cleanup" {
+		t.Errorf("JobUnitName() = %q", JobUnitName("myapp", "cleanup"))
+	}
+}
+
+func TestJobEnvironment(t *testing.T) {
+	python := NewExecutor(nil, "myapp", "", &detector.Detection{Language: "Python", Framework: "Django"})
+	service, _ := jobUnits("myapp", "/srv/myapp", config.Job{Name: "sessions", Command: "python manage.py clearsessions"}, "daily", python.jobEnvironment())
+	if !strings.Contains(service, "\nEnvironment=PATH=/srv/myapp/shared/venv/bin:") {
+		t.Errorf("Python job should run with the venv on PATH:\n%s", service)
+	}
+
+	node := NewExecutor(nil, "myapp", "", &detector.Detection{Language: "JavaScript/TypeScript", Meta: map[string]string{}})
+	if env := node.jobEnvironment(); env != "" {
+		t.Errorf("unpinned Node job environment = %q, want none", env)
+	}
+}
+
+func TestParseJobStatuses(t *testing.T) {
+	jobs := []config.Job{
+		{Name: "cleanup", Schedule: "0 3 * * *"},
+		{Name: "report", Schedule: "0 6 * * 1"},
+		{Name: "digest", Schedule: "0 7 * * *"},
+		{Name: "missing", Schedule: "0 8 * * *"},
+	}
+	output := strings.Join([]string{
+		"job=cleanup",
+		"ActiveState=active",
+		"LastTriggerUSec=Thu 2026-10-15 03:00:00 UTC",
+		"NextElapseUSecRealtime=Fri 2026-10-16 03:00:00 UTC",
+		"LoadState=loaded",
+		"Result=success",
+		"ExecMainExitTimestamp=Thu 2026-10-15 03:00:04 UTC",
+		"ExecMainStatus=0",
+		"job=report",
+		"LoadState=loaded",
+		"ActiveState=active",
+		"LastTriggerUSec=Mon 2026-10-12 06:00:00 UTC",
+		"NextElapseUSecRealtime=Mon 2026-10-19 06:00:00 UTC",
+		"ExecMainStatus=2",
+		"ExecMainExitTimestamp=Mon 2026-10-12 06:00:09 UTC",
+		"Result=exit-code",
+		"job=digest",
+		"LoadState=loaded",
+		"ActiveState=active",
+		"LastTriggerUSec=n/a",
+		"NextElapseUSecRealtime=Fri 2026-10-16 07:00:00 UTC",
+		"ExecMainStatus=0",
+		"ExecMainExitTimestamp=",
+		"Result=success",
+		"job=missing",
+		"LoadState=not-found",
+		"ActiveState=inactive",
+	}, "\n")
+
+	statuses := parseJobStatuses(output, jobs)
+
+	if len(statuses) != 4 {
+		t.Fatalf("got %d statuses, want 4", len(statuses))
+	}
+	if s := statuses[0]; !s.Installed || !s.Active || s.LastRun != "Thu 2026-10-15 03:00:00 UTC" || s.ExitStatus != "0" || s.Result != "success" || s.NextRun != "Fri 2026-10-16 03:00:00 UTC" {
+		t.Errorf("cleanup = %+v", s)
+	}
+	if s := statuses[1]; s.ExitStatus != "2" || s.Result != "exit-code" || s.Schedule != "0 6 * * 1" {
+		t.Errorf("report = %+v", s)
+	}
+	if s := statuses[2]; s.LastRun != "" || s.ExitStatus != "" {
+		t.Errorf("digest = %+v, want never run", s)
+	}
+	if s := statuses[3]; s.Installed || s.Active {
+		t.Errorf("missing = %+v, want not installed", s)
+	}
+}
@@ -0,0 +1,302 @@
+package deploy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LeaseFileName is the file in /srv/<app> naming the deploy allowed to switch
+// the current release. The deploy that started last holds it.
+const LeaseFileName = ".lightfold-lease"
+
+// DeployedFileName is the file in /srv/<app> holding the lease of the last
+// deploy that switched the current release
+const DeployedFileName = ".lightfold-deployed"
+
+// StaleLeaseAge is how long a lease may be held before waiting deploys treat it
+// as left behind by a deploy that was killed
+const StaleLeaseAge = 2 * time.Hour
+
+// leasePollInterval is how often a waiting deploy checks the lease
+var leasePollInterval = 5 * time.Second
+
+// Lease is one deploy's claim on the target
+type Lease struct {
+	Token     string
+	Commit    string
+	StartedAt time.Time
+	Holder    string // user@host that started the deploy
+}
+
+func (l Lease) String() string {
+	commit := l.Commit
+	if commit == "" {
+		commit = "-"
+	}
+	content := fmt.Sprintf("%s %s %s", l.Token, commit, l.StartedAt.UTC().Format(time.RFC3339))
+	if l.Holder != "" {
+		content += " " + l.Holder
+	}
+	return content
+}
+
+// parseLease reads the lease file contents:
+// "<token> <commit> <started_at> [<holder>]"
+func parseLease(content string) (Lease, bool) {
+	fields := strings.Fields(content)
+	if len(fields) < 2 {
+		return Lease{}, false
+	}
+
+	lease := Lease{Token: fields[0], Commit: fields[1]}
+	if lease.Commit == "-" {
+		lease.Commit = ""
+	}
+	if len(fields) > 2 {
+		lease.StartedAt, _ = time.Parse(time.RFC3339, fields[2])
+	}
+	if len(fields) > 3 {
+		lease.Holder = fields[3]
+	}
+	return lease, true
+}
+
+// SupersededError reports a deploy that lost its lease to a deploy started
+// after it. The superseded deploy stops before switching the current release.
+type SupersededError struct {
+	// Commit is the commit of the deploy holding the lease; empty when that
+	// deploy already finished
+	Commit string
+}
+
+func (e *SupersededError) Error() string {
+	if e.Commit == "" {
+		return "superseded by a newer deploy"
+	}
+	commit := e.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return fmt.Sprintf("superseded by a newer deploy of commit %s", commit)
+}
+
+// leaseFile stores the lease; remoteLeaseFile keeps it on the server
+type leaseFile interface {
+	read() (string, error)
+	write(content string) error
+	// remove deletes the lease if it still names token
+	remove(token string) error
+}
+
+type remoteLeaseFile struct {
+	ssh  *sshpkg.Executor
+	path string
+}
+
+func (f *remoteLeaseFile) read() (string, error) {
+	result := f.ssh.ExecuteIdempotent(fmt.Sprintf("cat %s 2>/dev/null || true", f.path))
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return result.Stdout, nil
+}
+
+func (f *remoteLeaseFile) write(content string) error {
+	result := f.ssh.ExecuteSudoIdempotent(fmt.Sprintf("sh -c \"echo '%s' > %s\"", content, f.path))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s", strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
+
+func (f *remoteLeaseFile) remove(token string) error {
+	result := f.ssh.ExecuteSudoIdempotent(fmt.Sprintf("sh -c \"grep -qsF '%s' %s && rm -f %s || true\"", token, f.path, f.path))
+	return result.Error
+}
+
+func (e *Executor) leaseStore() leaseFile {
+	if e.leaseFile == nil {
+		e.leaseFile = &remoteLeaseFile{
+			ssh:  e.ssh,
+			path: fmt.Sprintf("%s/%s", e.AppDir(), LeaseFileName),
+		}
+	}
+	return e.leaseFile
+}
+
+func (e *Executor) deployedStore() leaseFile {
+	if e.deployedFile == nil {
+		e.deployedFile = &remoteLeaseFile{
+			ssh:  e.ssh,
+			path: fmt.Sprintf("%s/%s", e.AppDir(), DeployedFileName),
+		}
+	}
+	return e.deployedFile
+}
+
+var (
+	unsafeCommitChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+	unsafeHolderChars = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+)
+
+// leaseHolder names who is deploying, e.g. alice@laptop or runner@ci-host
+func leaseHolder() string {
+	return unsafeHolderChars.ReplaceAllString(util.LocalIdentity(), "")
+}
+
+// AcquireLease claims the target for this deploy of commit, taking over from
+// any deploy already running. Call it when the push starts.
+func (e *Executor) AcquireLease(commit string) error {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate lease token: %w", err)
+	}
+
+	lease := Lease{
+		Token:     hex.EncodeToString(token),
+		Commit:    unsafeCommitChars.ReplaceAllString(commit, ""),
+		StartedAt: time.Now(),
+		Holder:    leaseHolder(),
+	}
+	if err := e.leaseStore().write(lease.String()); err != nil {
+		return fmt.Errorf("failed to record deploy lease: %w", err)
+	}
+
+	e.lease = &lease
+	return nil
+}
+
+// CheckLease returns a *SupersededError when a deploy started after this one
+// has taken the lease. Without a lease it always succeeds.
+func (e *Executor) CheckLease() error {
+	if e.lease == nil {
+		return nil
+	}
+
+	content, err := e.leaseStore().read()
+	if err != nil {
+		return fmt.Errorf("failed to read deploy lease: %w", err)
+	}
+
+	holder, ok := parseLease(content)
+	if !ok {
+		// The newer deploy already finished and released its lease
+		return &SupersededError{}
+	}
+	if holder.Token != e.lease.Token {
+		return &SupersededError{Commit: holder.Commit}
+	}
+	return nil
+}
+
+// ReleaseLease removes the lease if this deploy still holds it
+func (e *Executor) ReleaseLease() {
+	if e.lease == nil {
+		return
+	}
+	e.leaseStore().remove(e.lease.Token)
+	e.lease = nil
+}
+
+// HeldLease returns the lease of the deploy currently running on the target,
+// if any
+func (e *Executor) HeldLease() (Lease, bool, error) {
+	content, err := e.leaseStore().read()
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("failed to read deploy lease: %w", err)
+	}
+	lease, ok := parseLease(content)
+	return lease, ok, nil
+}
+
+// recordDeployed notes that this deploy switched the current release, so
+// deploys queued behind it can tell that a newer commit went out
+func (e *Executor) recordDeployed() {
+	if e.lease == nil {
+		return
+	}
+	e.deployedStore().write(e.lease.String())
+}
+
+// WaitForLease blocks while another deploy holds the target's lease, calling
+// report with the holder on every poll. A lease older than StaleLeaseAge is
+// ignored. It only reads from the server, so cancelling ctx leaves nothing
+// behind.
+//
+// When a deploy switched the release while this one waited and isNewer
+// reports its commit as newer than this one's, WaitForLease returns a
+// *SupersededError: the queued deploy would only roll the target back.
+func (e *Executor) WaitForLease(ctx context.Context, timeout time.Duration, isNewer func(deployedCommit string) bool, report func(holder Lease)) error {
+	before, _ := e.deployedStore().read()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		holder, held, err := e.HeldLease()
+		if err != nil {
+			return err
+		}
+		if !held || time.Since(holder.StartedAt) > StaleLeaseAge {
+			break
+		}
+		if report != nil {
+			report(holder)
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s", timeout, describeLease(holder))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(leasePollInterval):
+		}
+	}
+
+	after, err := e.deployedStore().read()
+	if err != nil {
+		return fmt.Errorf("failed to read last deploy: %w", err)
+	}
+	deployed, ok := parseLease(after)
+	if !ok || after == before || deployed.Commit == "" || isNewer == nil {
+		return nil
+	}
+	if isNewer(deployed.Commit) {
+		return &SupersededError{Commit: deployed.Commit}
+	}
+	return nil
+}
+
+// describeLease names the deploy holding a lease for waiting output
+func describeLease(lease Lease) string {
+	commit := lease.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	description := "the deploy"
+	if commit != "" {
+		description += " of commit " + commit
+	}
+	if lease.Holder != "" {
+		description += " by " + lease.Holder
+	}
+	return description
+}
+
+// WaitingMessage describes the deploy a queued deploy is waiting for
+func WaitingMessage(holder Lease, now time.Time) string {
+	message := "Waiting for " + describeLease(holder)
+	if !holder.StartedAt.IsZero() {
+		message += fmt.Sprintf(", running for %s", now.Sub(holder.StartedAt).Round(time.Second))
+	}
+	return message
+}
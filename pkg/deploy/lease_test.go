@@ -0,0 +1,245 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryLeaseFile is the lease file shared by two deploys of the same target
+type memoryLeaseFile struct {
+	content string
+}
+
+func (f *memoryLeaseFile) read() (string, error) { return f.content, nil }
+
+func (f *memoryLeaseFile) write(content string) error {
+	f.content = content
+	return nil
+}
+
+func (f *memoryLeaseFile) remove(token string) error {
+	if strings.Contains(f.content, token) {
+		f.content = ""
+	}
+	return nil
+}
+
+func newLeasedExecutors(file *memoryLeaseFile) (*Executor, *Executor) {
+	older := NewExecutor(nil, "myapp", "", nil)
+	older.leaseFile = file
+	newer := NewExecutor(nil, "myapp", "", nil)
+	newer.leaseFile = file
+	return older, newer
+}
+
+func TestLease_NewerDeployWins(t *testing.T) {
+	file := &memoryLeaseFile{}
+	older, newer := newLeasedExecutors(file)
+
+	if err := older.AcquireLease("aaaaaaaaaaaa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := newer.AcquireLease("bbbbbbbbbbbb"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The older deploy reaches the switch first but must not switch. It stops
+	// before DeployWithHealthCheck touches the server (ssh is nil here).
+	err := older.DeployWithHealthCheck("/srv/myapp/releases/1", 3000, 1, time.Millisecond)
+	var superseded *SupersededError
+	if !errors.As(err, &superseded) {
+		t.Fatalf("older DeployWithHealthCheck() error = %v, want *SupersededError", err)
+	}
+	if superseded.Commit != "bbbbbbbbbbbb" {
+		t.Errorf("superseded by %q, want bbbbbbbbbbbb", superseded.Commit)
+	}
+	if !strings.Contains(err.Error(), "bbbbbbb") {
+		t.Errorf("Error() = %q, want the newer commit", err.Error())
+	}
+
+	// The older deploy's cleanup must not drop the newer deploy's lease
+	older.ReleaseLease()
+	if err := newer.CheckLease(); err != nil {
+		t.Errorf("newer CheckLease() = %v, want nil", err)
+	}
+}
+
+func TestLease_NewerDeployFinishesFirst(t *testing.T) {
+	file := &memoryLeaseFile{}
+	older, newer := newLeasedExecutors(file)
+
+	older.AcquireLease("aaaaaaaaaaaa")
+	newer.AcquireLease("bbbbbbbbbbbb")
+
+	if err := newer.CheckLease(); err != nil {
+		t.Fatalf("newer CheckLease() = %v, want nil", err)
+	}
+	newer.ReleaseLease()
+
+	var superseded *SupersededError
+	if err := older.CheckLease(); !errors.As(err, &superseded) {
+		t.Fatalf("older CheckLease() = %v, want *SupersededError after the newer deploy finished", err)
+	}
+}
+
+func TestLease_SequentialDeploys(t *testing.T) {
+	file := &memoryLeaseFile{}
+	first, second := newLeasedExecutors(file)
+
+	first.AcquireLease("aaaaaaaaaaaa")
+	if err := first.CheckLease(); err != nil {
+		t.Fatalf("first CheckLease() = %v, want nil", err)
+	}
+	first.ReleaseLease()
+	if file.content != "" {
+		t.Errorf("lease file = %q after release, want empty", file.content)
+	}
+
+	second.AcquireLease("bbbbbbbbbbbb")
+	if err := second.CheckLease(); err != nil {
+		t.Fatalf("second CheckLease() = %v, want nil", err)
+	}
+}
+
+func TestLease_NoLeaseAlwaysPasses(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", "", nil)
+	exec.leaseFile = &memoryLeaseFile{content: "someone-else abc 2026-01-01T00:00:00Z"}
+	if err := exec.CheckLease(); err != nil {
+		t.Errorf("CheckLease() without a lease = %v, want nil", err)
+	}
+}
+
+func TestParseLease(t *testing.T) {
+	lease := Lease{Token: "tok", StartedAt: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	got, ok := parseLease(lease.String() + "\n")
+	if !ok || got.Token != "tok" || got.Commit != "" || !got.StartedAt.Equal(lease.StartedAt) {
+		t.Errorf("parseLease(%q) = %+v, %v", lease.String(), got, ok)
+	}
+
+	lease.Holder = "runner@ci-host"
+	if got, ok := parseLease(lease.String()); !ok || got.Holder != "runner@ci-host" {
+		t.Errorf("parseLease(%q) holder = %q", lease.String(), got.Holder)
+	}
+
+	if _, ok := parseLease(""); ok {
+		t.Error("parseLease(\"\") should report no lease")
+	}
+}
+
+func newQueuedExecutor(t *testing.T, lease, deployed *memoryLeaseFile) *Executor {
+	t.Helper()
+	interval := leasePollInterval
+	leasePollInterval = time.Millisecond
+	t.Cleanup(func() { leasePollInterval = interval })
+
+	exec := NewExecutor(nil, "myapp", "", nil)
+	exec.leaseFile = lease
+	exec.deployedFile = deployed
+	return exec
+}
+
+func TestWaitForLease_NoRunningDeploy(t *testing.T) {
+	exec := newQueuedExecutor(t, &memoryLeaseFile{}, &memoryLeaseFile{})
+
+	err := exec.WaitForLease(context.Background(), time.Minute, nil, func(Lease) {
+		t.Error("report called without a running deploy")
+	})
+	if err != nil {
+		t.Errorf("WaitForLease() = %v, want nil", err)
+	}
+}
+
+func TestWaitForLease_RunsAfterCurrentDeploy(t *testing.T) {
+	lease, deployed := &memoryLeaseFile{}, &memoryLeaseFile{}
+	running := newQueuedExecutor(t, lease, deployed)
+	running.AcquireLease("aaaaaaaaaaaa")
+	queued := newQueuedExecutor(t, lease, deployed)
+
+	polls := 0
+	report := func(holder Lease) {
+		if holder.Commit != "aaaaaaaaaaaa" {
+			t.Errorf("holder commit = %q, want aaaaaaaaaaaa", holder.Commit)
+		}
+		if polls++; polls == 3 {
+			running.recordDeployed()
+			running.ReleaseLease()
+		}
+	}
+	isNewer := func(commit string) bool { return false }
+
+	if err := queued.WaitForLease(context.Background(), time.Minute, isNewer, report); err != nil {
+		t.Fatalf("WaitForLease() = %v, want nil once the running deploy finished", err)
+	}
+	if polls != 3 {
+		t.Errorf("reported %d polls, want 3", polls)
+	}
+}
+
+func TestWaitForLease_NewerCommitDeployedWhileWaiting(t *testing.T) {
+	lease, deployed := &memoryLeaseFile{}, &memoryLeaseFile{}
+	running := newQueuedExecutor(t, lease, deployed)
+	running.AcquireLease("bbbbbbbbbbbb")
+	queued := newQueuedExecutor(t, lease, deployed)
+
+	report := func(Lease) {
+		running.recordDeployed()
+		running.ReleaseLease()
+	}
+	isNewer := func(commit string) bool { return commit == "bbbbbbbbbbbb" }
+
+	err := queued.WaitForLease(context.Background(), time.Minute, isNewer, report)
+	var superseded *SupersededError
+	if !errors.As(err, &superseded) || superseded.Commit != "bbbbbbbbbbbb" {
+		t.Fatalf("WaitForLease() = %v, want *SupersededError by bbbbbbbbbbbb", err)
+	}
+}
+
+func TestWaitForLease_NewerCommitDeployedBeforeWaiting(t *testing.T) {
+	// A deploy that finished before this one started waiting is not a reason to stop
+	deployed := &memoryLeaseFile{content: Lease{Token: "old", Commit: "bbbbbbbbbbbb", StartedAt: time.Now()}.String()}
+	queued := newQueuedExecutor(t, &memoryLeaseFile{}, deployed)
+
+	if err := queued.WaitForLease(context.Background(), time.Minute, func(string) bool { return true }, nil); err != nil {
+		t.Errorf("WaitForLease() = %v, want nil", err)
+	}
+}
+
+func TestWaitForLease_TimeoutAndCancel(t *testing.T) {
+	lease := &memoryLeaseFile{content: Lease{Token: "tok", Commit: "aaaaaaaaaaaa", StartedAt: time.Now(), Holder: "alice@laptop"}.String()}
+	queued := newQueuedExecutor(t, lease, &memoryLeaseFile{})
+
+	err := queued.WaitForLease(context.Background(), 5*time.Millisecond, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "alice@laptop") {
+		t.Errorf("WaitForLease() = %v, want a timeout naming the holder", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := queued.WaitForLease(ctx, time.Minute, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForLease() after cancel = %v, want context.Canceled", err)
+	}
+	if !strings.Contains(lease.content, "tok") {
+		t.Error("waiting must not touch the running deploy's lease")
+	}
+}
+
+func TestWaitForLease_IgnoresStaleLease(t *testing.T) {
+	lease := &memoryLeaseFile{content: Lease{Token: "tok", StartedAt: time.Now().Add(-StaleLeaseAge - time.Minute)}.String()}
+	queued := newQueuedExecutor(t, lease, &memoryLeaseFile{})
+
+	if err := queued.WaitForLease(context.Background(), time.Minute, nil, nil); err != nil {
+		t.Errorf("WaitForLease() with a stale lease = %v, want nil", err)
+	}
+}
+
+func TestWaitingMessage(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	holder := Lease{Commit: "abcdef123456", Holder: "alice@laptop", StartedAt: now.Add(-90 * time.Second)}
+	want := "Waiting for the deploy of commit abcdef1 by alice@laptop, running for 1m30s"
+	if got := WaitingMessage(holder, now); got != want {
+		t.Errorf("WaitingMessage() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,373 @@
+package deploy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/proxy"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"lightfold/pkg/util"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//go:embed templates/maintenance.html
+var maintenancePageTemplate string
+
+// DefaultMaintenanceRetryAfter is the Retry-After sent with the maintenance
+// page unless another is given
+const DefaultMaintenanceRetryAfter = 10 * time.Minute
+
+// DefaultMaintenanceAllow are the paths still proxied to the app during
+// maintenance when the target lists none, so monitoring sees the app itself
+var DefaultMaintenanceAllow = []string{"/healthz"}
+
+// MaintenancePageFile is the project file served instead of the bundled
+// maintenance page
+const MaintenancePageFile = "maintenance.html"
+
+// MaintenanceMarkerFile, in the app directory on the server, holds the JSON
+// state.Maintenance record while the app is in maintenance
+const MaintenanceMarkerFile = ".lightfold-maintenance"
+
+// acmeChallengePath always stays reachable so certificate renewals keep working
+const acmeChallengePath = "/.well-known/acme-challenge"
+
+// The maintenance block is inserted into the nginx site between these lines
+const (
+	maintenanceBegin = "  # lightfold maintenance begin"
+	maintenanceEnd   = "  # lightfold maintenance end"
+)
+
+var serverBlockPattern = regexp.MustCompile(`^\s*server\s*\{\s*$`)
+
+// MaintenanceOptions is how `lightfold maintenance on` serves the page
+type MaintenanceOptions struct {
+	Allow      []string      // Paths still proxied to the app
+	RetryAfter time.Duration // Sent as Retry-After with the 503
+	Page       string        // HTML of the page; empty uses the bundled one
+}
+
+// ValidateMaintenancePaths checks paths can be matched in the nginx config
+func ValidateMaintenancePaths(paths []string) error {
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") || strings.Trim(p, "/") == "" || strings.ContainsAny(p, " \t\n\"'{};$\\") {
+			return fmt.Errorf("invalid maintenance path %q: paths start with /, are not / itself and have no spaces, quotes, braces, semicolons, $ or backslashes", p)
+		}
+	}
+	return nil
+}
+
+// maintenanceAllowPattern matches the allowed paths and anything below them
+func maintenanceAllowPattern(allow []string) string {
+	alternatives := []string{regexp.QuoteMeta(acmeChallengePath)}
+	for _, p := range allow {
+		alternatives = append(alternatives, regexp.QuoteMeta(strings.TrimRight(p, "/")))
+	}
+	return fmt.Sprintf("^(%s)(/|$)", strings.Join(alternatives, "|"))
+}
+
+// maintenanceBlock returns the server-level nginx directives that answer
+// every request outside allow with the page in pageDir and a 503
+func maintenanceBlock(pageDir string, allow []string, retryAfter time.Duration) string {
+	return strings.Join([]string{
+		maintenanceBegin,
+		"  set $lightfold_maintenance 1;",
+		fmt.Sprintf("  if ($uri ~ \"%s\") {", maintenanceAllowPattern(allow)),
+		"    set $lightfold_maintenance 0;",
+		"  }",
+		"  if ($lightfold_maintenance) {",
+		"    return 503;",
+		"  }",
+		"  error_page 503 @lightfold_maintenance;",
+		"  location @lightfold_maintenance {",
+		"    root " + pageDir + ";",
+		fmt.Sprintf("    add_header Retry-After %d always;", int(retryAfter.Seconds())),
+		"    add_header Cache-Control \"no-store\" always;",
+		"    rewrite ^ /" + MaintenancePageFile + " break;",
+		"  }",
+		maintenanceEnd,
+	}, "\n")
+}
+
+// hasMaintenance reports whether an nginx site has the maintenance block
+func hasMaintenance(site string) bool {
+	return strings.Contains(site, maintenanceBegin)
+}
+
+// insertMaintenance adds the maintenance block at the top of every server
+// block of the site that has locations. Server blocks without any, such as
+// certbot's HTTP to HTTPS redirect, are left to redirect.
+func insertMaintenance(site, block string) (string, error) {
+	lines := strings.Split(site, "\n")
+	out := make([]string, 0, len(lines))
+	inserted := false
+	for i, line := range lines {
+		out = append(out, line)
+		if !serverBlockPattern.MatchString(line) || !serverBlockHasLocation(lines[i:]) {
+			continue
+		}
+		out = append(out, block)
+		inserted = true
+	}
+	if !inserted {
+		return "", fmt.Errorf("no server block with locations found in the nginx site")
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// serverBlockHasLocation reports whether the block opened on lines[0] has a
+// location before it closes
+func serverBlockHasLocation(lines []string) bool {
+	depth := 0
+	for i, line := range lines {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		if i > 0 && strings.HasPrefix(strings.TrimSpace(line), "location") {
+			return true
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// stripMaintenance removes the maintenance block from an nginx site
+func stripMaintenance(site string) string {
+	lines := strings.Split(site, "\n")
+	out := make([]string, 0, len(lines))
+	inBlock := false
+	for _, line := range lines {
+		switch {
+		case line == maintenanceBegin:
+			inBlock = true
+		case line == maintenanceEnd:
+			inBlock = false
+		case !inBlock:
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// maintenanceBlockOf returns the maintenance block of an nginx site, or ""
+func maintenanceBlockOf(site string) string {
+	start := strings.Index(site, maintenanceBegin)
+	end := strings.Index(site, maintenanceEnd)
+	if start < 0 || end < start {
+		return ""
+	}
+	return site[start : end+len(maintenanceEnd)]
+}
+
+// maintenanceBackupPath is where the site is kept while maintenance is on
+func maintenanceBackupPath(sitePath string) string {
+	return sitePath + ".pre-maintenance"
+}
+
+func (e *Executor) maintenanceDir() string {
+	return fmt.Sprintf("%s/shared/maintenance", e.AppDir())
+}
+
+// maintenanceMarkerPath is the server's record that the app is in maintenance
+func (e *Executor) maintenanceMarkerPath() string {
+	return fmt.Sprintf("%s/%s", e.AppDir(), MaintenanceMarkerFile)
+}
+
+// EnableMaintenance copies the nginx site aside, uploads the maintenance page
+// to shared/maintenance and rewrites the site to answer every request outside
+// the allowed paths with it and a 503. Turning it on again only updates the
+// page and settings. A site that fails nginx -t is put back.
+func (e *Executor) EnableMaintenance(opts MaintenanceOptions) error {
+	if err := ValidateMaintenancePaths(opts.Allow); err != nil {
+		return err
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = DefaultMaintenanceRetryAfter
+	}
+
+	site := e.nginxSiteFile()
+	current := e.ssh.ExecuteSudo("cat " + site.Path)
+	if current.Error != nil || current.ExitCode != 0 {
+		return fmt.Errorf("no nginx site at %s; maintenance mode needs the app served through nginx", site.Path)
+	}
+	backup := maintenanceBackupPath(site.Path)
+	base := current.Stdout
+	if hasMaintenance(base) {
+		base = stripMaintenance(base)
+	} else {
+		result := e.ssh.ExecuteSudo(fmt.Sprintf("cp -p %s %s", site.Path, backup))
+		if result.Error != nil || result.ExitCode != 0 {
+			return formatSSHError("failed to back up the nginx site", result)
+		}
+	}
+
+	rewritten, err := insertMaintenance(base, maintenanceBlock(e.maintenanceDir(), opts.Allow, opts.RetryAfter))
+	if err != nil {
+		return err
+	}
+
+	page := opts.Page
+	if page == "" {
+		page = sshpkg.RenderTemplate(maintenancePageTemplate, map[string]string{"APP_NAME": e.appName})
+	}
+	for _, cmd := range staticPermissionCommands(e.AppDir(), []proxy.StaticPath{{Dir: e.maintenanceDir()}}) {
+		result := e.ssh.ExecuteSudo(cmd)
+		if result.Error != nil || result.ExitCode != 0 {
+			return formatSSHError("failed to prepare the maintenance page directory", result)
+		}
+	}
+	pageFile := sshpkg.RemoteFile{Path: e.maintenanceDir() + "/" + MaintenancePageFile, Mode: 0640, Owner: "deploy:www-data"}
+	if err := e.ssh.InstallFile(pageFile, page); err != nil {
+		return err
+	}
+
+	if err := e.ssh.InstallFile(sshpkg.RemoteFile{Path: site.Path, Mode: site.Mode, Owner: site.Owner}, rewritten); err != nil {
+		return err
+	}
+	if err := e.TestNginxConfig(); err != nil {
+		e.ssh.InstallFile(sshpkg.RemoteFile{Path: site.Path, Mode: site.Mode, Owner: site.Owner}, current.Stdout)
+		return fmt.Errorf("the maintenance config was rejected, restored the site: %w", err)
+	}
+	if err := e.ReloadNginx(); err != nil {
+		return err
+	}
+
+	marker, err := json.Marshal(state.Maintenance{Since: time.Now().UTC(), By: util.LocalIdentity(), Allow: opts.Allow})
+	if err != nil {
+		return err
+	}
+	return e.ssh.InstallFile(sshpkg.RemoteFile{Path: e.maintenanceMarkerPath(), Mode: config.PermConfigFile, Owner: "deploy:deploy"}, string(marker))
+}
+
+// DisableMaintenance puts back the nginx site copied aside by
+// EnableMaintenance and removes the server's marker. When the site was
+// rewritten since, e.g. by configure, only the maintenance block is removed.
+// It reports whether the copied site was restored.
+func (e *Executor) DisableMaintenance() (bool, error) {
+	site := e.nginxSiteFile()
+	backup := maintenanceBackupPath(site.Path)
+
+	current := e.ssh.ExecuteSudo("cat " + site.Path)
+	if current.Error != nil || current.ExitCode != 0 {
+		return false, fmt.Errorf("no nginx site at %s", site.Path)
+	}
+
+	restored := false
+	lifted := false
+	if hasMaintenance(current.Stdout) {
+		var err error
+		if restored, err = e.liftMaintenance(site, current.Stdout); err != nil {
+			return false, err
+		}
+		lifted = true
+	}
+	// The domain site carries the block too once KeepMaintenanceIn put it there
+	domainSite := e.domainSiteFile()
+	if domain := e.ssh.ExecuteSudo("cat " + domainSite.Path); domain.Error == nil && domain.ExitCode == 0 && hasMaintenance(domain.Stdout) {
+		if _, err := e.liftMaintenance(domainSite, domain.Stdout); err != nil {
+			return restored, err
+		}
+		lifted = true
+	}
+	if lifted {
+		if err := e.TestNginxConfig(); err != nil {
+			return restored, err
+		}
+		if err := e.ReloadNginx(); err != nil {
+			return restored, err
+		}
+	}
+
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("rm -f %s %s %s", backup, maintenanceBackupPath(domainSite.Path), e.maintenanceMarkerPath()))
+	if result.Error != nil || result.ExitCode != 0 {
+		return restored, formatSSHError("failed to remove the maintenance marker", result)
+	}
+	return restored, nil
+}
+
+// liftMaintenance moves the copy of site saved when maintenance went on back
+// in place, or strips the block from current when there is no copy. It
+// reports whether the copy was restored.
+func (e *Executor) liftMaintenance(site sshpkg.RemoteFile, current string) (bool, error) {
+	backup := maintenanceBackupPath(site.Path)
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("test -f %s", backup))
+	if result.Error == nil && result.ExitCode == 0 {
+		result = e.ssh.ExecuteSudo(fmt.Sprintf("mv %s %s", backup, site.Path))
+		if result.Error != nil || result.ExitCode != 0 {
+			return false, formatSSHError("failed to restore the nginx site", result)
+		}
+		return true, nil
+	}
+	return false, e.ssh.InstallFile(sshpkg.RemoteFile{Path: site.Path, Mode: site.Mode, Owner: site.Owner}, stripMaintenance(current))
+}
+
+// KeepMaintenanceIn puts the maintenance block of the app's site into the
+// domain site the nginx proxy manager just wrote, so adding a domain during
+// maintenance does not end it. The freshly written site is saved as the copy
+// DisableMaintenance restores. Nothing changes when maintenance is off; a
+// site nginx rejects with the block is put back without it.
+func (e *Executor) KeepMaintenanceIn(sitePath string) error {
+	app := e.ssh.ExecuteSudo("cat " + e.nginxSiteFile().Path)
+	if app.Error != nil || app.ExitCode != 0 {
+		return nil
+	}
+	block := maintenanceBlockOf(app.Stdout)
+	if block == "" {
+		return nil
+	}
+	current := e.ssh.ExecuteSudo("cat " + sitePath)
+	if current.Error != nil || current.ExitCode != 0 {
+		return formatSSHError("failed to read "+sitePath, current)
+	}
+	if hasMaintenance(current.Stdout) {
+		return nil
+	}
+	withBlock, err := insertMaintenance(current.Stdout, block)
+	if err != nil {
+		return err
+	}
+
+	site := sshpkg.RemoteFile{Path: sitePath, Mode: config.PermConfigFile, Owner: "root:root"}
+	backup := sshpkg.RemoteFile{Path: maintenanceBackupPath(sitePath), Mode: config.PermConfigFile, Owner: "root:root"}
+	if err := e.ssh.InstallFile(backup, current.Stdout); err != nil {
+		return err
+	}
+	if err := e.ssh.InstallFile(site, withBlock); err != nil {
+		return err
+	}
+	if err := e.TestNginxConfig(); err != nil {
+		e.ssh.InstallFile(site, current.Stdout)
+		return fmt.Errorf("the maintenance block was rejected in %s, left it out: %w", sitePath, err)
+	}
+	return nil
+}
+
+// keepMaintenance carries the maintenance block of the site on the server
+// over to a newly rendered site, so configure does not end maintenance. The
+// rendered site replaces the copy DisableMaintenance restores.
+func (e *Executor) keepMaintenance(sitePath, rendered string) (string, error) {
+	current := e.ssh.ExecuteSudo("cat " + sitePath)
+	if current.Error != nil || current.ExitCode != 0 {
+		return rendered, nil
+	}
+	block := maintenanceBlockOf(current.Stdout)
+	if block == "" {
+		return rendered, nil
+	}
+	withBlock, err := insertMaintenance(rendered, block)
+	if err != nil {
+		return rendered, nil
+	}
+	backup := sshpkg.RemoteFile{Path: maintenanceBackupPath(sitePath), Mode: config.PermConfigFile, Owner: "root:root"}
+	if err := e.ssh.InstallFile(backup, rendered); err != nil {
+		return "", err
+	}
+	return withBlock, nil
+}
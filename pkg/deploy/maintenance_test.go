@@ -0,0 +1,208 @@
+package deploy
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const maintenanceTestSite = `server {
+  listen 80;
+  server_name example.com;
+  return 301 https://$host$request_uri;
+}
+
+server {
+  listen 443 ssl;
+  server_name example.com;
+
+  location / {
+    proxy_pass http://127.0.0.1:3000;
+  }
+}
+`
+
+func TestInsertMaintenance_SkipsRedirectBlocks(t *testing.T) {
+	block := maintenanceBlock("/srv/myapp/shared/maintenance", []string{"/healthz"}, 10*time.Minute)
+	site, err := insertMaintenance(maintenanceTestSite, block)
+	if err != nil {
+		t.Fatalf("insertMaintenance() error: %v", err)
+	}
+	if strings.Count(site, maintenanceBegin) != 1 {
+		t.Fatalf("expected the block in the HTTPS server only:\n%s", site)
+	}
+	if !strings.Contains(site, "server {\n"+block+"\n  listen 443 ssl;") {
+		t.Errorf("expected the block at the top of the HTTPS server:\n%s", site)
+	}
+	if !strings.Contains(site, "add_header Retry-After 600 always;") || !strings.Contains(site, "root /srv/myapp/shared/maintenance;") {
+		t.Errorf("block missing Retry-After or the page root:\n%s", site)
+	}
+	if !hasMaintenance(site) || stripMaintenance(site) != maintenanceTestSite {
+		t.Errorf("stripMaintenance() did not restore the site:\n%s", stripMaintenance(site))
+	}
+	if maintenanceBlockOf(site) != block {
+		t.Errorf("maintenanceBlockOf() = %q, want %q", maintenanceBlockOf(site), block)
+	}
+
+	if _, err := insertMaintenance("server {\n  return 301 https://example.com;\n}\n", block); err == nil {
+		t.Error("insertMaintenance() should fail without a server block that has locations")
+	}
+}
+
+func TestMaintenanceAllowPattern(t *testing.T) {
+	pattern := regexp.MustCompile(maintenanceAllowPattern([]string{"/healthz", "/api/status/"}))
+	for path, allowed := range map[string]bool{
+		"/healthz":                           true,
+		"/healthz/db":                        true,
+		"/api/status":                        true,
+		"/.well-known/acme-challenge/abc":    true,
+		"/":                                  false,
+		"/healthzz":                          false,
+		"/api/statuses":                      false,
+		"/login":                             false,
+		"/static/healthz":                    false,
+		"/.well-known/acme-challenge-please": false,
+	} {
+		if got := pattern.MatchString(path); got != allowed {
+			t.Errorf("%s allowed = %v, want %v", path, got, allowed)
+		}
+	}
+}
+
+func TestValidateMaintenancePaths(t *testing.T) {
+	if err := ValidateMaintenancePaths([]string{"/healthz", "/api/v1/status"}); err != nil {
+		t.Errorf("ValidateMaintenancePaths() error: %v", err)
+	}
+	for _, bad := range []string{"healthz", "/", "/a b", "/a\";", "/{x}", "/$uri"} {
+		if err := ValidateMaintenancePaths([]string{bad}); err == nil {
+			t.Errorf("ValidateMaintenancePaths(%q) should fail", bad)
+		}
+	}
+}
+
+func TestEnableMaintenance_BacksUpSiteAndWritesMarker(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {maintenanceTestSite}}
+
+	if err := exec.EnableMaintenance(MaintenanceOptions{Allow: []string{"/healthz"}}); err != nil {
+		t.Fatalf("EnableMaintenance() error: %v", err)
+	}
+
+	backup := server.Index("cp -p /etc/nginx/sites-available/myapp /etc/nginx/sites-available/myapp.pre-maintenance")
+	test := server.Index("nginx -t")
+	marker := server.Index("/srv/myapp/" + MaintenanceMarkerFile)
+	if backup < 0 || test < 0 || marker < 0 {
+		t.Fatalf("expected backup, nginx -t and marker commands, got %v", server.Commands())
+	}
+	if !(backup < test && test < marker) {
+		t.Errorf("expected the site backed up, then tested, then the marker written; got %v", server.Commands())
+	}
+	if !server.Ran("/srv/myapp/shared/maintenance/" + MaintenancePageFile) {
+		t.Errorf("expected the page uploaded to shared/maintenance, got %v", server.Commands())
+	}
+}
+
+func TestEnableMaintenance_RestoresSiteRejectedByNginx(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "nginx -t")
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {maintenanceTestSite}}
+
+	if err := exec.EnableMaintenance(MaintenanceOptions{}); err == nil {
+		t.Fatal("EnableMaintenance() should fail when nginx rejects the config")
+	}
+	if server.Ran(MaintenanceMarkerFile) {
+		t.Error("the marker must not be written when the config was rejected")
+	}
+}
+
+func TestDisableMaintenance_RestoresBackup(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	site, _ := insertMaintenance(maintenanceTestSite, maintenanceBlock("/srv/myapp/shared/maintenance", nil, time.Minute))
+	// The app's site is in maintenance, the domain site is not
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {site, maintenanceTestSite}}
+
+	restored, err := exec.DisableMaintenance()
+	if err != nil {
+		t.Fatalf("DisableMaintenance() error: %v", err)
+	}
+	if !restored || !server.Ran("mv /etc/nginx/sites-available/myapp.pre-maintenance /etc/nginx/sites-available/myapp") {
+		t.Errorf("expected the backup moved back, got %v", server.Commands())
+	}
+	if !server.Ran("rm -f /etc/nginx/sites-available/myapp.pre-maintenance /etc/nginx/sites-available/myapp.conf.pre-maintenance /srv/myapp/" + MaintenanceMarkerFile) {
+		t.Errorf("expected the backup and marker removed, got %v", server.Commands())
+	}
+}
+
+func TestKeepMaintenance_CarriesBlockIntoNewSite(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	block := maintenanceBlock("/srv/myapp/shared/maintenance", []string{"/healthz"}, time.Minute)
+	site, _ := insertMaintenance(maintenanceTestSite, block)
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {site}}
+
+	rendered := strings.Replace(maintenanceTestSite, "3000", "3001", 1)
+	got, err := exec.keepMaintenance("/etc/nginx/sites-available/myapp", rendered)
+	if err != nil {
+		t.Fatalf("keepMaintenance() error: %v", err)
+	}
+	if maintenanceBlockOf(got) != block || !strings.Contains(got, "127.0.0.1:3001") {
+		t.Errorf("keepMaintenance() = %s", got)
+	}
+	if !server.Ran("/etc/nginx/sites-available/myapp.pre-maintenance") {
+		t.Errorf("expected the rendered site saved as the backup, got %v", server.Commands())
+	}
+}
+
+func TestKeepMaintenanceIn_DomainSite(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	block := maintenanceBlock("/srv/myapp/shared/maintenance", []string{"/healthz"}, time.Minute)
+	appSite, _ := insertMaintenance(maintenanceTestSite, block)
+	// The app's site is read first, then the domain site
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {appSite, maintenanceTestSite}}
+
+	if err := exec.KeepMaintenanceIn("/etc/nginx/sites-available/myapp.conf"); err != nil {
+		t.Fatalf("KeepMaintenanceIn() error: %v", err)
+	}
+	inputs := server.Inputs()
+	written := ""
+	for i, command := range server.Commands() {
+		if strings.Contains(inputs[i], maintenanceBegin) {
+			written = command
+		}
+	}
+	if !strings.Contains(written, "myapp.conf") {
+		t.Errorf("expected the block written into the domain site, got %v", server.Commands())
+	}
+	if !server.Ran("/etc/nginx/sites-available/myapp.conf.pre-maintenance") || !server.Ran("nginx -t") {
+		t.Errorf("expected the domain site saved as the copy and tested, got %v", server.Commands())
+	}
+}
+
+func TestDisableMaintenance_LiftsDomainSite(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "test -f /etc/nginx/sites-available/myapp.conf.pre-maintenance")
+	site, _ := insertMaintenance(maintenanceTestSite, maintenanceBlock("/srv/myapp/shared/maintenance", nil, time.Minute))
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {site, site}}
+
+	if _, err := exec.DisableMaintenance(); err != nil {
+		t.Fatalf("DisableMaintenance() error: %v", err)
+	}
+	commands, inputs := server.Commands(), server.Inputs()
+	stripped := false
+	for i, command := range commands {
+		stripped = stripped || (strings.Contains(command, "myapp.conf") && strings.Contains(inputs[i], "proxy_pass") && !strings.Contains(inputs[i], maintenanceBegin))
+	}
+	if !stripped {
+		t.Errorf("expected the block stripped from the domain site without a copy, got %v", commands)
+	}
+}
+
+func TestKeepMaintenanceIn_NothingWhenOff(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	server.Replies = map[string][]string{"cat /etc/nginx/sites-available/myapp": {maintenanceTestSite}}
+
+	if err := exec.KeepMaintenanceIn("/etc/nginx/sites-available/myapp.conf"); err != nil {
+		t.Fatalf("KeepMaintenanceIn() error: %v", err)
+	}
+	if server.Ran("pre-maintenance") || server.Ran("nginx -t") {
+		t.Errorf("a site outside maintenance should be left alone, got %v", server.Commands())
+	}
+}
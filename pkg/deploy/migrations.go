@@ -0,0 +1,134 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"strings"
+	"time"
+)
+
+// MigrationLockFile is the file in /srv/<app>/shared a deploy holds with flock
+// while it migrates, so concurrent deploys never run migrations at once
+const MigrationLockFile = "migrate.lock"
+
+// migrationLockTimeout is how long a deploy waits for another deploy's
+// migrations to finish
+const migrationLockTimeout = 10 * time.Minute
+
+// migrationLockBusy is the exit status flock reports when the wait times out
+const migrationLockBusy = 75
+
+// MigrationBackoutError reports a deploy that failed after its migrations ran.
+// The previous release is serving again, but migrations are never reverted
+// automatically, so the schema may need backing out by hand.
+type MigrationBackoutError struct {
+	Command string
+	Err     error
+}
+
+func (e *MigrationBackoutError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MigrationBackoutError) Unwrap() error {
+	return e.Err
+}
+
+// SetMigrationOptions sets the target's migration command, which replaces the
+// framework default. Migrations are skipped when the target says so or skip
+// is set (--skip-migrations).
+func (e *Executor) SetMigrationOptions(opts *config.DeploymentOptions, skip bool) {
+	e.skipMigrations = skip
+	if opts == nil {
+		return
+	}
+	e.migrationCommand = strings.TrimSpace(opts.MigrationCommand)
+	e.skipMigrations = skip || opts.SkipMigrations
+}
+
+// MigrationCommand returns the command a deploy migrates with: the target's
+// own, otherwise the framework default. It is empty when migrations are
+// skipped or the app has none.
+func (e *Executor) MigrationCommand() string {
+	if e.skipMigrations || e.isStaticSite() || e.isComposeProject() {
+		return ""
+	}
+	if e.migrationCommand != "" {
+		return e.migrationCommand
+	}
+	if e.detection != nil {
+		return e.detection.Meta["migration_command"]
+	}
+	return ""
+}
+
+// migrationLockPath is the lock deploys of the app migrate under
+func migrationLockPath(appDir string) string {
+	return fmt.Sprintf("%s/shared/%s", appDir, MigrationLockFile)
+}
+
+// migrationScript runs command in the release under the app's migration
+// lock. flock waits for a deploy already migrating and exits with
+// migrationLockBusy when it gives up; the lock is released when the command
+// exits or the connection drops. stderr is merged so the log keeps the
+// output in order.
+func migrationScript(appDir, releasePath, pathPrefix, command string) string {
+	return fmt.Sprintf("cd %s && flock -w %d -E %d %s sh -c %s 2>&1",
+		releasePath, int(migrationLockTimeout.Seconds()), migrationLockBusy, migrationLockPath(appDir), util.ShellQuote(pathPrefix+command))
+}
+
+// migrationPathPrefix is the build's PATH prefix, with the app's virtualenv
+// first for Python so manage.py runs with its dependencies
+func (e *Executor) migrationPathPrefix() string {
+	prefix := e.getPackageManagerPath()
+	if e.detection != nil && e.detection.Language == "Python" {
+		prefix = fmt.Sprintf(`export PATH="%s/shared/venv/bin:$PATH" && `, e.AppDir()) + prefix
+	}
+	return prefix
+}
+
+// RunMigrations runs the migration command in a built release before it goes
+// live. Its full output goes to the build log. A failure leaves the current
+// release serving.
+func (e *Executor) RunMigrations(releasePath string) error {
+	command := e.MigrationCommand()
+	if command == "" {
+		return nil
+	}
+
+	e.notify(fmt.Sprintf("Running migrations: %s", command))
+	result := e.ssh.Execute(migrationScript(e.AppDir(), releasePath, e.migrationPathPrefix(), command))
+	e.sendOutput(result.Stdout, strings.Count(result.Stdout, "\n")+1)
+
+	switch {
+	case sshpkg.IsConnectionLost(result.Error):
+		return fmt.Errorf("migrations interrupted and may have partly run; the current release is still live: %w", result.Error)
+	case result.Error != nil:
+		return fmt.Errorf("failed to run migrations: %w", result.Error)
+	case result.ExitCode == migrationLockBusy:
+		return fmt.Errorf("another deploy of %s is still running migrations (waited %s); the current release is still live", e.appName, migrationLockTimeout)
+	case result.ExitCode != 0:
+		return fmt.Errorf("migrations failed (exit code %d); the current release is still live: %s", result.ExitCode, lastOutputLine(result.Stdout))
+	}
+
+	e.migrated = true
+	return nil
+}
+
+// migrationBackout marks a failure after the switch of a deploy that ran
+// migrations
+func (e *Executor) migrationBackout(err error) error {
+	var backout *MigrationBackoutError
+	if err == nil || !e.migrated || errors.As(err, &backout) {
+		return err
+	}
+	return &MigrationBackoutError{Command: e.MigrationCommand(), Err: err}
+}
+
+func lastOutputLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"errors"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"strings"
+	"testing"
+	"time"
+)
+
+func railsDetection() *detector.Detection {
+	return &detector.Detection{
+		Framework: "Rails",
+		Language:  "Ruby",
+		BuildPlan: []string{"bundle exec rails assets:precompile"},
+		RunPlan:   []string{"bundle exec puma -C config/puma.rb"},
+		Meta:      map[string]string{"migration_command": "bundle exec rails db:migrate"},
+	}
+}
+
+func TestMigrationCommand(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", "", railsDetection())
+	if got := exec.MigrationCommand(); got != "bundle exec rails db:migrate" {
+		t.Errorf("MigrationCommand() = %q, want the framework default", got)
+	}
+
+	exec.SetMigrationOptions(&config.DeploymentOptions{MigrationCommand: " bin/rails db:migrate:primary "}, false)
+	if got := exec.MigrationCommand(); got != "bin/rails db:migrate:primary" {
+		t.Errorf("MigrationCommand() = %q, want the target's command", got)
+	}
+
+	exec.SetMigrationOptions(&config.DeploymentOptions{MigrationCommand: "bin/rails db:migrate"}, true)
+	if got := exec.MigrationCommand(); got != "" {
+		t.Errorf("MigrationCommand() with --skip-migrations = %q", got)
+	}
+	exec.SetMigrationOptions(&config.DeploymentOptions{SkipMigrations: true}, false)
+	if got := exec.MigrationCommand(); got != "" {
+		t.Errorf("MigrationCommand() with skip_migrations = %q", got)
+	}
+
+	static := &detector.Detection{Framework: "Hugo", Meta: map[string]string{"deployment_type": "static"}}
+	exec = NewExecutor(nil, "site", "", static)
+	exec.SetMigrationOptions(&config.DeploymentOptions{MigrationCommand: "true"}, false)
+	if got := exec.MigrationCommand(); got != "" {
+		t.Errorf("MigrationCommand() for a static site = %q", got)
+	}
+}
+
+func TestMigrationScript(t *testing.T) {
+	got := migrationScript("/srv/myapp", "/srv/myapp/releases/20240101000000", `export PATH="/usr/bin:$PATH" && `, "echo 'it''s done'")
+	want := `cd /srv/myapp/releases/20240101000000 && flock -w 600 -E 75 /srv/myapp/shared/migrate.lock sh -c 'export PATH="/usr/bin:$PATH" && echo '\''it'\'''\''s done'\''' 2>&1`
+	if got != want {
+		t.Errorf("migrationScript() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDeployWithHealthCheck_MigratesAfterBuildBeforeSwitch(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	exec.detection = railsDetection()
+	releasePath := "/srv/myapp/releases/20240101000000"
+
+	if err := exec.BuildReleaseWithEnv(releasePath, nil); err != nil {
+		t.Fatalf("BuildReleaseWithEnv() error: %v", err)
+	}
+	if server.Ran("db:migrate") {
+		t.Fatalf("the build ran migrations: %v", server.Commands())
+	}
+	if err := exec.DeployWithHealthCheck(releasePath, 3000, 1, 0); err != nil {
+		t.Fatalf("DeployWithHealthCheck() error: %v", err)
+	}
+
+	build := server.Index("assets:precompile")
+	migrate := server.Index("flock -w 600 -E 75 /srv/myapp/shared/migrate.lock sh -c 'bundle exec rails db:migrate'")
+	switchRelease := server.Index("ln -sf " + releasePath)
+	if build < 0 || migrate < 0 || switchRelease < 0 {
+		t.Fatalf("missing build, migration or switch in %v", server.Commands())
+	}
+	if !(build < migrate && migrate < switchRelease) {
+		t.Errorf("want build < migrate < switch, got %d, %d, %d", build, migrate, switchRelease)
+	}
+	if !strings.HasPrefix(server.Commands()[migrate], "cd "+releasePath+" && ") {
+		t.Errorf("migration should run in the new release: %q", server.Commands()[migrate])
+	}
+}
+
+func TestDeployWithHealthCheck_MigrationFailureKeepsCurrentRelease(t *testing.T) {
+	exec, server := connectRecording(t, "myapp", "db:migrate")
+	exec.detection = railsDetection()
+
+	err := exec.DeployWithHealthCheck("/srv/myapp/releases/20240101000000", 3000, 1, 0)
+	if err == nil || !strings.Contains(err.Error(), "migrations failed (exit code 1); the current release is still live") {
+		t.Fatalf("DeployWithHealthCheck() = %v, want the migration failure", err)
+	}
+	var backout *MigrationBackoutError
+	if errors.As(err, &backout) {
+		t.Error("a failed migration changed nothing live, so it needs no backout")
+	}
+	if server.Ran("ln -sf") || server.Ran("systemctl") {
+		t.Errorf("a failed migration must stop the deploy before the switch: %v", server.Commands())
+	}
+}
+
+func TestDeployWithHealthCheck_HealthFailureAfterMigrationsWarnsBackout(t *testing.T) {
+	exec, _ := connectRecording(t, "myapp")
+	detection := railsDetection()
+	detection.Healthcheck = map[string]any{"path": "/up"}
+	exec.detection = detection
+
+	err := exec.DeployWithHealthCheck("/srv/myapp/releases/20240101000000", 3000, 1, time.Millisecond)
+	var backout *MigrationBackoutError
+	if !errors.As(err, &backout) || backout.Command != "bundle exec rails db:migrate" {
+		t.Fatalf("DeployWithHealthCheck() = %v, want a MigrationBackoutError", err)
+	}
+	if !strings.Contains(err.Error(), "health check failed") {
+		t.Errorf("error = %v, want the health check failure", err)
+	}
+
+	// Without migrations the same failure needs no backout
+	exec, _ = connectRecording(t, "myapp")
+	exec.detection = detection
+	exec.SetMigrationOptions(nil, true)
+	err = exec.DeployWithHealthCheck("/srv/myapp/releases/20240101000000", 3000, 1, time.Millisecond)
+	if err == nil || errors.As(err, &backout) {
+		t.Errorf("DeployWithHealthCheck() with --skip-migrations = %v, want a plain health check failure", err)
+	}
+}
@@ -8,12 +8,14 @@ import (
 	_ "lightfold/pkg/builders/native"
 	_ "lightfold/pkg/builders/nixpacks"
 	"lightfold/pkg/config"
+	"lightfold/pkg/crash"
 	"lightfold/pkg/detector"
 	"lightfold/pkg/firewall"
 	"lightfold/pkg/providers"
 	_ "lightfold/pkg/providers/aws"
 	"lightfold/pkg/providers/cloudinit"
 	_ "lightfold/pkg/providers/digitalocean"
+	_ "lightfold/pkg/providers/external"
 	_ "lightfold/pkg/providers/flyio"
 	_ "lightfold/pkg/providers/hetzner"
 	_ "lightfold/pkg/providers/linode"
@@ -23,6 +25,8 @@ import (
 	"lightfold/pkg/state"
 	"lightfold/pkg/util"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -54,6 +58,13 @@ type Orchestrator struct {
 	targetName       string
 	tokens           config.TokenConfig
 	progressCallback ProgressCallback
+	sudoPassword     string
+	autoInstall      bool
+	skipMigrations   bool
+	rerunFirstDeploy bool
+	forceBuild       bool
+	aptWait          time.Duration
+	killStaleApt     bool
 }
 
 // GetOrchestrator creates a new deployment orchestrator
@@ -77,6 +88,46 @@ func (o *Orchestrator) SetProgressCallback(callback ProgressCallback) {
 	o.progressCallback = callback
 }
 
+// SetSudoPassword supplies the deploy user's sudo password for this session only
+// TargetName returns the name of the target being deployed
+func (o *Orchestrator) TargetName() string {
+	return o.targetName
+}
+
+func (o *Orchestrator) SetSudoPassword(password string) {
+	o.sudoPassword = password
+}
+
+// SetAutoInstallBuilder lets the builder install the pinned version of its
+// tool on the server when another version is installed
+func (o *Orchestrator) SetAutoInstallBuilder(autoInstall bool) {
+	o.autoInstall = autoInstall
+}
+
+// SetSkipMigrations deploys without running migrations (--skip-migrations)
+func (o *Orchestrator) SetSkipMigrations(skip bool) {
+	o.skipMigrations = skip
+}
+
+// SetRerunFirstDeploy runs the first-deploy commands again although they
+// succeeded before (--rerun-first-deploy)
+func (o *Orchestrator) SetRerunFirstDeploy(rerun bool) {
+	o.rerunFirstDeploy = rerun
+}
+
+// SetForceBuild builds on servers with too little memory for the framework
+// (--force-build)
+func (o *Orchestrator) SetForceBuild(force bool) {
+	o.forceBuild = force
+}
+
+// SetAptLockOptions bounds the wait for apt/dpkg locks (--apt-wait) and lets
+// it stop a stale unattended-upgrades (--kill-stale-apt)
+func (o *Orchestrator) SetAptLockOptions(wait time.Duration, killStale bool) {
+	o.aptWait = wait
+	o.killStaleApt = killStale
+}
+
 func (o *Orchestrator) Deploy(ctx context.Context) (*DeploymentResult, error) {
 	if !providers.IsRegistered(o.config.Provider) {
 		return nil, fmt.Errorf("unknown provider: %s", o.config.Provider)
@@ -99,6 +150,14 @@ func (o *Orchestrator) checkExistingServer() error {
 		return nil
 	}
 
+	// Adopted servers already exist by definition; deploys go straight to them
+	if providerCfg.IsAdopted() {
+		if providerCfg.GetIP() == "" {
+			return fmt.Errorf("adopted server %s has no IP address recorded", providerCfg.GetServerID())
+		}
+		return nil
+	}
+
 	if providerCfg.IsProvisioned() && providerCfg.GetServerID() != "" && providerCfg.GetIP() != "" {
 		return fmt.Errorf("server already provisioned (ID: %s, IP: %s). Use a different command to redeploy or destroy the existing server first",
 			providerCfg.GetServerID(),
@@ -155,19 +214,20 @@ func (o *Orchestrator) provisionServer(ctx context.Context, token string) (*Depl
 		return nil, fmt.Errorf("failed to initialize provider: %w", err)
 	}
 
-	if err := client.ValidateCredentials(ctx); err != nil {
-		return nil, fmt.Errorf("failed to validate credentials: %w", err)
-	}
-
 	region, size, sshKeyPath, username, sshKeyName, err := o.getProvisioningParams()
 	if err != nil {
 		return nil, err
 	}
+	if err := providers.ValidateIPStack(o.config.Provider, o.ipStack()); err != nil {
+		return nil, err
+	}
 
 	var uploadedKey *providers.SSHKey
 	var publicKey string
 	var userData string
 
+	// Cloud-init is generated before any API call so problems with a user
+	// data file surface without touching the provider account
 	if client.SupportsSSH() {
 		publicKeyPath := sshKeyPath + ".pub"
 		publicKey, err = sshpkg.LoadPublicKey(publicKeyPath)
@@ -175,6 +235,27 @@ func (o *Orchestrator) provisionServer(ctx context.Context, token string) (*Depl
 			return nil, fmt.Errorf("failed to load public key: %w", err)
 		}
 
+		o.notifyProgress(DeploymentStep{
+			Name:        "generate_cloudinit",
+			Description: "Generating cloud-init configuration...",
+			Progress:    10,
+		})
+
+		userData, err = o.buildUserData(username, publicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.ValidateCredentials(ctx); err != nil {
+		return nil, fmt.Errorf("failed to validate credentials: %w", err)
+	}
+
+	if err := providers.ValidateSizeForRegion(ctx, client, region, size); err != nil {
+		return nil, err
+	}
+
+	if client.SupportsSSH() {
 		o.notifyProgress(DeploymentStep{
 			Name:        "upload_ssh_key",
 			Description: fmt.Sprintf("Uploading SSH key to %s...", client.DisplayName()),
@@ -189,17 +270,6 @@ func (o *Orchestrator) provisionServer(ctx context.Context, token string) (*Depl
 		if err != nil {
 			return nil, fmt.Errorf("failed to upload SSH key: %w", err)
 		}
-
-		o.notifyProgress(DeploymentStep{
-			Name:        "generate_cloudinit",
-			Description: "Generating cloud-init configuration...",
-			Progress:    40,
-		})
-
-		userData, err = cloudinit.GenerateWebAppUserData(username, publicKey, o.projectName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
-		}
 	}
 
 	o.notifyProgress(DeploymentStep{
@@ -246,9 +316,11 @@ func (o *Orchestrator) provisionServer(ctx context.Context, token string) (*Depl
 		SSHKeys:           []string{},
 		UserData:          userData,
 		Tags:              []string{"lightfold", "auto-provisioned", o.projectName},
+		Labels:            o.config.Labels,
 		BackupsEnabled:    false,
 		MonitoringEnabled: true,
 		Metadata:          metadata,
+		IPStack:           o.ipStack(),
 	}
 
 	if uploadedKey != nil {
@@ -296,17 +368,48 @@ func (o *Orchestrator) provisionServer(ctx context.Context, token string) (*Depl
 
 	o.notifyProgress(DeploymentStep{
 		Name:        "complete",
-		Description: fmt.Sprintf("Provisioning complete! (Server IP: %s)", server.PublicIPv4),
+		Description: fmt.Sprintf("Provisioning complete! (Server IP: %s)", server.PrimaryIP()),
 		Progress:    100,
 	})
 
 	result.Success = true
 	result.Server = server
-	result.Message = fmt.Sprintf("Successfully provisioned server at %s", server.PublicIPv4)
+	result.Message = fmt.Sprintf("Successfully provisioned server at %s", server.PrimaryIP())
 
 	return result, nil
 }
 
+// buildUserData generates the server's cloud-init, merging in the target's user
+// data file and checking the provider's size limit
+func (o *Orchestrator) buildUserData(username, publicKey string) (string, error) {
+	teamKeys, err := o.collectAuthorizedKeys()
+	if err != nil {
+		return "", fmt.Errorf("failed to load authorized keys: %w", err)
+	}
+
+	userData, err := cloudinit.GenerateWebAppUserData(username, publicKey, o.projectName, config.AppDir(o.config.RemoteBaseDir(), o.projectName), teamKeys...)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate cloud-init: %w", err)
+	}
+
+	if o.config.UserDataFile != "" {
+		snippet, err := cloudinit.LoadSnippet(o.config.UserDataFile)
+		if err != nil {
+			return "", err
+		}
+		userData, err = cloudinit.MergeSnippet(userData, snippet)
+		if err != nil {
+			return "", fmt.Errorf("failed to merge user data file: %w", err)
+		}
+	}
+
+	if err := cloudinit.ValidateUserDataSize(o.config.Provider, userData); err != nil {
+		return "", err
+	}
+
+	return userData, nil
+}
+
 func (o *Orchestrator) deployS3(ctx context.Context) (*DeploymentResult, error) {
 	return &DeploymentResult{
 		Success: false,
@@ -332,7 +435,7 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 		Progress:    5,
 	})
 
-	detection := detector.DetectFramework(o.projectPath)
+	detection := detector.DetectAppAs(o.projectPath, o.config.AppSubdir(), o.config.FrameworkOverride)
 
 	o.notifyProgress(DeploymentStep{
 		Name:        "connect_ssh",
@@ -340,17 +443,51 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 		Progress:    10,
 	})
 
-	sshExecutor := sshpkg.NewExecutor(providerCfg.GetIP(), "22", providerCfg.GetUsername(), providerCfg.GetSSHKey())
+	sshExecutor := sshpkg.NewExecutorFromConfig(providerCfg)
 	defer sshExecutor.Disconnect()
 
-	if err := sshExecutor.Connect(17, 10*time.Second); err != nil {
-		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+	reportConnect := func(elapsed time.Duration, status string) {
+		o.notifyProgress(DeploymentStep{
+			Name:        "connect_ssh",
+			Description: fmt.Sprintf("Connecting to server at %s: %s (%s)...", providerCfg.GetIP(), status, elapsed.Round(time.Second)),
+			Progress:    10,
+		})
+	}
+	if err := sshExecutor.WaitUntilReachable(config.DefaultSSHConnectionTimeout, reportConnect); err != nil {
+		return nil, err
+	}
+
+	if o.sudoPassword != "" {
+		sshExecutor.SetSudoPassword(o.sudoPassword)
+	}
+	if err := sshpkg.CheckSudo(sshExecutor, providerCfg.GetUsername()); err != nil {
+		return nil, err
 	}
 
 	markerCheck := sshExecutor.Execute(fmt.Sprintf("test -f %s/%s && echo 'configured'", config.RemoteLightfoldDir, config.RemoteConfiguredMarker))
 	isConfigured := markerCheck.ExitCode == 0 && strings.TrimSpace(markerCheck.Stdout) == "configured"
 
 	executor := NewExecutor(sshExecutor, o.projectName, o.projectPath, &detection)
+	if o.config.Deploy != nil {
+		executor.SetDrainSeconds(o.config.Deploy.DrainSeconds)
+	}
+	executor.ApplyServerTuning(providerCfg.GetIP(), o.config.Deploy)
+	executor.SetStaticPathOptions(o.config.Deploy)
+	executor.SetBaseDir(o.config.RemoteBaseDir())
+	executor.SetMigrationOptions(o.config.Deploy, o.skipMigrations)
+	executor.SetFirstDeployOptions(o.config.Deploy, o.rerunFirstDeploy)
+	executor.SetAssetOptions(o.config.Assets)
+	executor.SetAptLockOptions(o.aptWait, o.killStaleApt)
+	if serverState, err := state.GetServerState(providerCfg.GetIP()); err == nil {
+		executor.SetSharedRuntimes(serverState.OtherRuntimeUses(o.targetName))
+	}
+	if o.config.Domain != nil {
+		routes, err := PathRoutesFor(providerCfg.GetIP(), o.config.Domain.Domain)
+		if err != nil {
+			fmt.Printf("Warning: failed to load path routes for %s: %v\n", o.config.Domain.Domain, err)
+		}
+		executor.SetPathRoutes(routes)
+	}
 
 	executor.SetOutputCallback(func(line string) {
 		if o.progressCallback != nil {
@@ -365,6 +502,7 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 	if err := o.prepareBaseSystem(executor, providerCfg, &detection, isConfigured); err != nil {
 		return nil, err
 	}
+	executor.SweepStaleTempFiles()
 
 	tmpTarball, releasePath, err := o.prepareReleaseArtifacts(executor)
 	if err != nil {
@@ -373,8 +511,13 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 	defer os.Remove(tmpTarball)
 
 	envVars := make(map[string]string)
-	if o.config.Deploy != nil && o.config.Deploy.EnvVars != nil {
-		envVars = o.config.Deploy.EnvVars
+	for key, value := range executor.AssetEnvironment() {
+		envVars[key] = value
+	}
+	if o.config.Deploy != nil {
+		for key, value := range o.config.Deploy.EnvVars {
+			envVars[key] = value
+		}
 	}
 
 	builderName := o.config.Builder
@@ -388,14 +531,32 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 	}
 
 	skipBuild := o.config.Deploy != nil && o.config.Deploy.SkipBuild
+	if !skipBuild {
+		executor.SetBuildMemoryOptions(builderName, o.config.Deploy, o.forceBuild)
+		shortfall, err := executor.CheckBuildMemory()
+		if err != nil {
+			return nil, err
+		}
+		if shortfall != nil {
+			executor.notify(fmt.Sprintf("Warning: %s; the build may run out of memory", shortfall))
+		}
+	}
 	if err := o.runBuildPhase(ctx, executor, &detection, releasePath, envVars, builder, skipBuild); err != nil {
 		return nil, err
 	}
+	if !skipBuild {
+		if err := o.uploadAssetsPhase(ctx, executor, releasePath); err != nil {
+			return nil, err
+		}
+	}
 
 	port, err := o.configureProcessPhase(executor, releasePath, envVars, builder, &detection)
 	if err != nil {
 		return nil, err
 	}
+	if builder.NeedsNginx() {
+		executor.SetProxyHealthCheck(o.config.ProxyHealthCheckHost(providerCfg.GetIP()))
+	}
 
 	if err := o.deployPhase(executor, releasePath, port, isConfigured); err != nil {
 		return nil, err
@@ -407,12 +568,12 @@ func (o *Orchestrator) ConfigureServer(ctx context.Context, providerCfg config.P
 	return result, nil
 }
 
-func registerRuntimeForServer(providerCfg config.ProviderConfig, detection *detector.Detection) {
+func registerRuntimeForServer(providerCfg config.ProviderConfig, targetName string, detection *detector.Detection) {
 	if detection == nil || detection.Language == "" {
 		return
 	}
 
-	runtimeType := runtimepkg.GetRuntimeFromLanguage(detection.Language)
+	runtimeType := runtimepkg.GetRuntimeForDetection(detection.Language, detection.Framework)
 	if runtimeType == runtimepkg.RuntimeUnknown {
 		return
 	}
@@ -420,6 +581,14 @@ func registerRuntimeForServer(providerCfg config.ProviderConfig, detection *dete
 	if err := state.RegisterRuntime(providerCfg.GetIP(), state.Runtime(runtimeType)); err != nil {
 		fmt.Printf("Warning: failed to register runtime in server state: %v\n", err)
 	}
+	use := state.RuntimeUse{
+		TargetName: targetName,
+		Runtime:    state.Runtime(runtimeType),
+		Version:    runtimepkg.RequiredVersion(runtimeType, detection.Meta["runtime_version"]),
+	}
+	if err := state.RegisterRuntimeUse(providerCfg.GetIP(), use); err != nil {
+		fmt.Printf("Warning: failed to register runtime version in server state: %v\n", err)
+	}
 }
 
 func (o *Orchestrator) prepareBaseSystem(executor *Executor, providerCfg config.ProviderConfig, detection *detector.Detection, isConfigured bool) error {
@@ -429,7 +598,7 @@ func (o *Orchestrator) prepareBaseSystem(executor *Executor, providerCfg config.
 		Progress:    15,
 	})
 
-	if err := executor.WaitForAptLock(30, 10*time.Second); err != nil {
+	if err := executor.WaitForServerReady(); err != nil {
 		return fmt.Errorf("failed to acquire apt lock: %w", err)
 	}
 
@@ -444,7 +613,7 @@ func (o *Orchestrator) prepareBaseSystem(executor *Executor, providerCfg config.
 			return fmt.Errorf("failed to install packages: %w", err)
 		}
 
-		registerRuntimeForServer(providerCfg, detection)
+		registerRuntimeForServer(providerCfg, o.targetName, detection)
 
 		o.notifyProgress(DeploymentStep{
 			Name:        "setup_directories",
@@ -468,7 +637,7 @@ func (o *Orchestrator) prepareBaseSystem(executor *Executor, providerCfg config.
 		return fmt.Errorf("failed to install runtime dependencies: %w", err)
 	}
 
-	registerRuntimeForServer(providerCfg, detection)
+	registerRuntimeForServer(providerCfg, o.targetName, detection)
 
 	o.notifyProgress(DeploymentStep{
 		Name:        "skip_initial_setup",
@@ -486,10 +655,17 @@ func (o *Orchestrator) prepareReleaseArtifacts(executor *Executor) (string, stri
 		Progress:    40,
 	})
 
+	if cfg, err := config.LoadConfig(); err == nil {
+		executor.SetPackWorkers(cfg.PackWorkers)
+	}
+
 	tmpTarball := fmt.Sprintf("/tmp/lightfold-%s-release.tar.gz", o.projectName)
 	if err := executor.CreateReleaseTarball(tmpTarball); err != nil {
 		return "", "", fmt.Errorf("failed to create tarball: %w", err)
 	}
+	if secrets := executor.ReleaseSecrets(); len(secrets) > 0 {
+		executor.notify(fmt.Sprintf("Warning: release includes files that may hold secrets: %s", strings.Join(secrets, ", ")))
+	}
 
 	o.notifyProgress(DeploymentStep{
 		Name:        "upload_release",
@@ -499,6 +675,7 @@ func (o *Orchestrator) prepareReleaseArtifacts(executor *Executor) (string, stri
 
 	releasePath, err := executor.UploadRelease(tmpTarball)
 	if err != nil {
+		os.Remove(tmpTarball)
 		return "", "", fmt.Errorf("failed to upload release: %w", err)
 	}
 
@@ -516,13 +693,26 @@ func (o *Orchestrator) runBuildPhase(ctx context.Context, executor *Executor, de
 		Progress:    60,
 	})
 
-	buildResult, err := builder.Build(ctx, &builders.BuildOptions{
-		ProjectPath: o.projectPath,
-		Detection:   detection,
-		ReleasePath: releasePath,
-		EnvVars:     envVars,
-		SSHExecutor: executor.ssh,
-	})
+	buildOpts := &builders.BuildOptions{
+		ProjectPath:     o.projectPath,
+		Detection:       detection,
+		ReleasePath:     releasePath,
+		EnvVars:         envVars,
+		SSHExecutor:     executor.ssh,
+		RequiredVersion: o.config.NixpacksVersion,
+		AutoInstall:     o.autoInstall,
+		Port:            o.applicationPort(detection),
+		ContainerPort:   o.config.ContainerPort,
+	}
+	// Docker publishes ports past ufw, so a container nginx fronts is only
+	// published on loopback
+	executor.ResolvePortExposure(o.config.Domain != nil && o.config.Domain.Domain != "", buildOpts.Port)
+	buildOpts.BindAddress = executor.BindAddress()
+	if o.config.Deploy != nil {
+		buildOpts.BuildArgs = o.config.Deploy.BuildArgs
+		buildOpts.BuildTarget = o.config.Deploy.BuildTarget
+	}
+	buildResult, err := builder.Build(ctx, buildOpts)
 
 	debugMsg := fmt.Sprintf("Build completed: err=%v, result=%v", err != nil, buildResult != nil)
 	if buildResult != nil {
@@ -548,17 +738,51 @@ func (o *Orchestrator) runBuildPhase(ctx context.Context, executor *Executor, de
 		executor.SetStartCommand(detection.RunPlan[0])
 	}
 
-	if err := state.UpdateBuilder(o.targetName, builder.Name()); err != nil {
+	version, err := builder.Version(ctx, buildOpts)
+	if err != nil {
+		fmt.Printf("Warning: failed to get %s version: %v\n", builder.Name(), err)
+	}
+	if err := state.UpdateBuilder(o.targetName, builder.Name(), version); err != nil {
 		fmt.Printf("Warning: failed to update builder in state: %v\n", err)
 	}
 
 	return nil
 }
 
-func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath string, envVars map[string]string, builder builders.Builder, detection *detector.Detection) (int, error) {
+// uploadAssetsPhase copies the built assets to the target's asset bucket
+func (o *Orchestrator) uploadAssetsPhase(ctx context.Context, executor *Executor, releasePath string) error {
+	if o.config.Assets == nil || o.config.Assets.Bucket == "" {
+		return nil
+	}
+
+	o.notifyProgress(DeploymentStep{
+		Name:        "upload_assets",
+		Description: fmt.Sprintf("Uploading assets to %s...", AssetDestination(o.config.Assets)),
+		Progress:    65,
+	})
+
+	upload, err := executor.UploadAssets(ctx, releasePath)
+	if err != nil {
+		return err
+	}
+	if upload.Err != nil {
+		executor.notify(fmt.Sprintf("Warning: %v; pages link assets the bucket may not have until an upload succeeds", upload.Err))
+		return nil
+	}
+	executor.notify(fmt.Sprintf("Assets: %s", upload.Result))
+	return nil
+}
+
+// applicationPort returns the port the app is served on, saving the default
+// to the target config when none is set
+func (o *Orchestrator) applicationPort(detection *detector.Detection) int {
 	port := o.config.Port
 	if port == 0 {
 		port = config.DefaultApplicationPort
+		// Compose projects with a fixed published port are proxied to that port
+		if composePort, err := strconv.Atoi(detection.Meta["compose_port"]); err == nil && IsComposeDetection(detection) {
+			port = composePort
+		}
 		o.config.Port = port
 		cfg, err := config.LoadConfig()
 		if err == nil {
@@ -571,6 +795,11 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 			}
 		}
 	}
+	return port
+}
+
+func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath string, envVars map[string]string, builder builders.Builder, detection *detector.Detection) (int, error) {
+	port := o.applicationPort(detection)
 	var domain string
 	if o.config.Domain != nil {
 		domain = o.config.Domain.Domain
@@ -582,7 +811,7 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 		Progress:    65,
 	})
 
-	if err := executor.WriteEnvironmentFile(envVars); err != nil {
+	if err := executor.WriteEnvironmentFile(executor.ReleaseEnvironment(envVars)); err != nil {
 		return 0, fmt.Errorf("failed to write environment: %w", err)
 	}
 
@@ -592,6 +821,7 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 		Progress:    70,
 	})
 
+	executor.ResolvePortExposure(domain != "", port)
 	if err := executor.GenerateSystemdUnitWithPort(releasePath, port); err != nil {
 		return 0, fmt.Errorf("failed to generate systemd unit: %w", err)
 	}
@@ -600,6 +830,22 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 		return 0, fmt.Errorf("failed to enable service: %w", err)
 	}
 
+	var jobs []config.Job
+	if o.config.Deploy != nil {
+		jobs = o.config.Deploy.Jobs
+	}
+	synced, err := executor.SyncJobs(jobs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to configure jobs: %w", err)
+	}
+	if synced.Changed() {
+		o.notifyProgress(DeploymentStep{
+			Name:        "configure_jobs",
+			Description: fmt.Sprintf("Scheduling jobs: %s...", synced.Summary()),
+			Progress:    72,
+		})
+	}
+
 	if builder.NeedsNginx() {
 		o.notifyProgress(DeploymentStep{
 			Name:        "configure_nginx",
@@ -607,11 +853,24 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 			Progress:    75,
 		})
 
+		if o.config.Domain != nil {
+			executor.SetDomainRedirect(o.config.Domain.RedirectFrom)
+			executor.SetDomainProtocols(!o.config.Domain.HTTP2Enabled(), o.config.Domain.HTTP3)
+		}
 		if err := executor.GenerateNginxConfig(port, domain); err != nil {
 			return 0, fmt.Errorf("failed to generate nginx config: %w", err)
 		}
 
 		if err := executor.TestNginxConfig(); err != nil {
+			// Put the last working sites back so nginx keeps serving and reloads
+			if domainSite := executor.domainSiteFile().Path; slices.Contains(executor.rewritten, domainSite) {
+				if restoreErr := executor.RestoreBackup(domainSite); restoreErr != nil {
+					fmt.Printf("Warning: failed to restore previous HTTPS site: %v\n", restoreErr)
+				}
+			}
+			if restoreErr := executor.RestoreBackup(executor.nginxSiteFile().Path); restoreErr != nil {
+				fmt.Printf("Warning: failed to restore previous nginx config: %v\n", restoreErr)
+			}
 			return 0, fmt.Errorf("nginx config test failed: %w", err)
 		}
 
@@ -641,15 +900,32 @@ func (o *Orchestrator) configureProcessPhase(executor *Executor, releasePath str
 }
 
 func (o *Orchestrator) deployPhase(executor *Executor, releasePath string, port int, isConfigured bool) error {
+	description := "Deploying application with health check..."
+	if executor.MigrationCommand() != "" {
+		description = "Running migrations, then deploying with health check..."
+	}
 	o.notifyProgress(DeploymentStep{
 		Name:        "deploy_app",
-		Description: "Deploying application with health check...",
+		Description: description,
 		Progress:    85,
 	})
 
-	if err := executor.DeployWithHealthCheck(releasePath, port, config.DefaultHealthCheckMaxRetries, config.DefaultHealthCheckRetryDelay); err != nil {
+	err := executor.DeployWithHealthCheck(releasePath, port, config.DefaultHealthCheckMaxRetries, config.DefaultHealthCheckRetryDelay)
+	health := executor.DeployHealth()
+	if !health.CheckedAt.IsZero() {
+		if recordErr := state.RecordDeployHealth(o.targetName, health); recordErr != nil {
+			fmt.Printf("Warning: failed to record health checks: %v\n", recordErr)
+		}
+	}
+	if run := executor.FirstDeployResult(); run != nil {
+		if recordErr := state.RecordFirstDeploy(o.targetName, *run); recordErr != nil {
+			fmt.Printf("Warning: failed to record first-deploy commands: %v\n", recordErr)
+		}
+	}
+	if err != nil {
 		return fmt.Errorf("deployment failed: %w", err)
 	}
+	executor.notify("Health: " + health.Summary())
 
 	o.notifyProgress(DeploymentStep{
 		Name:        "cleanup",
@@ -663,7 +939,7 @@ func (o *Orchestrator) deployPhase(executor *Executor, releasePath string, port
 		cfg = &config.Config{NumReleases: config.DefaultNumReleases}
 	}
 
-	if err := executor.CleanupOldReleases(cfg.NumReleases); err != nil {
+	if err := executor.CleanupOldReleases(cfg.KeepReleasesFor(o.config)); err != nil {
 		fmt.Printf("Warning: failed to cleanup old releases: %v\n", err)
 	}
 
@@ -674,13 +950,10 @@ func (o *Orchestrator) deployPhase(executor *Executor, releasePath string, port
 	})
 
 	if !isConfigured {
-		executor.ssh.Execute(fmt.Sprintf("sudo mkdir -p %s", config.RemoteLightfoldDir))
-		markerResult := executor.ssh.Execute(fmt.Sprintf("echo 'configured' | sudo tee %s/%s > /dev/null", config.RemoteLightfoldDir, config.RemoteConfiguredMarker))
-		if markerResult.Error != nil {
-			return fmt.Errorf("failed to write configured marker: %w", markerResult.Error)
-		}
-		if markerResult.ExitCode != 0 {
-			return fmt.Errorf("failed to write configured marker: command exited with code %d, stderr: %s", markerResult.ExitCode, markerResult.Stderr)
+		executor.ssh.ExecuteSudo(fmt.Sprintf("mkdir -p %s", config.RemoteLightfoldDir))
+		marker := sshpkg.RemoteFile{Path: fmt.Sprintf("%s/%s", config.RemoteLightfoldDir, config.RemoteConfiguredMarker), Mode: config.PermConfigFile}
+		if err := executor.ssh.InstallFile(marker, "configured\n"); err != nil {
+			return fmt.Errorf("failed to write configured marker: %w", err)
 		}
 
 		o.notifyProgress(DeploymentStep{
@@ -700,13 +973,61 @@ func (o *Orchestrator) deployToServer(ctx context.Context, providerCfg config.Pr
 }
 
 func (o *Orchestrator) notifyProgress(step DeploymentStep) {
+	crash.Record(step.Description)
 	if o.progressCallback != nil {
 		o.progressCallback(step)
 	}
 }
 
+// collectAuthorizedKeys gathers team keys from the provider config and, when the target is
+// being rebuilt, keys previously added to its server with 'lightfold server add-key'
+func (o *Orchestrator) collectAuthorizedKeys() ([]string, error) {
+	var entries []string
+
+	if providerCfg, err := o.config.GetSSHProviderConfig(); err == nil {
+		entries = append(entries, providerCfg.GetAuthorizedKeys()...)
+	}
+
+	if o.config.ServerIP != "" && state.ServerStateExists(o.config.ServerIP) {
+		if serverState, err := state.GetServerState(o.config.ServerIP); err == nil {
+			entries = append(entries, serverState.AuthorizedKeys...)
+		}
+	}
+
+	return sshpkg.ResolveAuthorizedKeys(entries)
+}
+
 // getProvisioningParams extracts provisioning parameters from provider-specific config
+// ipStack returns the IP stack requested in the provider config, or "" for the
+// provider default
+func (o *Orchestrator) ipStack() string {
+	switch o.config.Provider {
+	case "digitalocean":
+		if doConfig, err := o.config.GetDigitalOceanConfig(); err == nil {
+			return doConfig.IPStack
+		}
+	case "hetzner":
+		if hetznerConfig, err := o.config.GetHetznerConfig(); err == nil {
+			return hetznerConfig.IPStack
+		}
+	case "vultr":
+		if vultrConfig, err := o.config.GetVultrConfig(); err == nil {
+			return vultrConfig.IPStack
+		}
+	}
+	return ""
+}
+
 func (o *Orchestrator) getProvisioningParams() (region, size, sshKeyPath, username, sshKeyName string, err error) {
+	if providers.IsExternal(o.config.Provider) {
+		externalConfig, e := o.config.GetExternalConfig()
+		if e != nil {
+			err = fmt.Errorf("failed to get %s config: %w", o.config.Provider, e)
+			return
+		}
+		return externalConfig.Region, externalConfig.Size, externalConfig.SSHKey, externalConfig.Username, externalConfig.SSHKeyName, nil
+	}
+
 	switch o.config.Provider {
 	case "digitalocean":
 		doConfig, e := o.config.GetDigitalOceanConfig()
@@ -781,13 +1102,27 @@ func (o *Orchestrator) getProvisioningParams() (region, size, sshKeyPath, userna
 }
 
 func (o *Orchestrator) updateProviderConfigWithServerInfo(server *providers.Server) error {
+	if providers.IsExternal(o.config.Provider) {
+		externalConfig, err := o.config.GetExternalConfig()
+		if err != nil {
+			return err
+		}
+		externalConfig.IP = server.PrimaryIP()
+		externalConfig.ServerID = server.ID
+		if len(server.Metadata) > 0 {
+			externalConfig.Metadata = server.Metadata
+		}
+		return o.config.SetProviderConfig(o.config.Provider, externalConfig)
+	}
+
 	switch o.config.Provider {
 	case "digitalocean":
 		doConfig, err := o.config.GetDigitalOceanConfig()
 		if err != nil {
 			return err
 		}
-		doConfig.IP = server.PublicIPv4
+		doConfig.IP = server.PrimaryIP()
+		doConfig.IPv6 = server.PublicIPv6
 		doConfig.DropletID = server.ID
 		return o.config.SetProviderConfig("digitalocean", doConfig)
 	case "hetzner":
@@ -795,15 +1130,20 @@ func (o *Orchestrator) updateProviderConfigWithServerInfo(server *providers.Serv
 		if err != nil {
 			return err
 		}
-		hetznerConfig.IP = server.PublicIPv4
+		hetznerConfig.IP = server.PrimaryIP()
+		hetznerConfig.IPv6 = server.PublicIPv6
 		hetznerConfig.ServerID = server.ID
+		if server.Architecture != "" {
+			hetznerConfig.Arch = server.Architecture
+		}
 		return o.config.SetProviderConfig("hetzner", hetznerConfig)
 	case "vultr":
 		vultrConfig, err := o.config.GetVultrConfig()
 		if err != nil {
 			return err
 		}
-		vultrConfig.IP = server.PublicIPv4
+		vultrConfig.IP = server.PrimaryIP()
+		vultrConfig.IPv6 = server.PublicIPv6
 		vultrConfig.InstanceID = server.ID
 		return o.config.SetProviderConfig("vultr", vultrConfig)
 	case "flyio":
@@ -827,8 +1167,12 @@ func (o *Orchestrator) updateProviderConfigWithServerInfo(server *providers.Serv
 		if err != nil {
 			return err
 		}
-		awsConfig.IP = server.PublicIPv4
+		awsConfig.IP = server.PrimaryIP()
+		awsConfig.IPv6 = server.PublicIPv6
 		awsConfig.InstanceID = server.ID
+		if server.Architecture != "" {
+			awsConfig.Arch = server.Architecture
+		}
 
 		// Extract metadata for cleanup (critical for destroy flow)
 		if sgID, ok := server.Metadata["security_group_id"]; ok {
@@ -842,6 +1186,9 @@ func (o *Orchestrator) updateProviderConfigWithServerInfo(server *providers.Serv
 		}
 		if eipAlloc, ok := server.Metadata["elastic_ip_allocation_id"]; ok {
 			awsConfig.ElasticIP = eipAlloc
+		} else if awsConfig.ElasticIP == "allocate" {
+			// Allocation failed; don't leave the request marker where destroy expects an allocation ID
+			awsConfig.ElasticIP = ""
 		}
 
 		return o.config.SetProviderConfig("aws", awsConfig)
@@ -850,7 +1197,8 @@ func (o *Orchestrator) updateProviderConfigWithServerInfo(server *providers.Serv
 		if err != nil {
 			return err
 		}
-		linodeConfig.IP = server.PublicIPv4
+		linodeConfig.IP = server.PrimaryIP()
+		linodeConfig.IPv6 = server.PublicIPv6
 		linodeConfig.InstanceID = server.ID
 
 		if rootPass, ok := server.Metadata["root_pass"]; ok {
@@ -887,11 +1235,12 @@ func (o *Orchestrator) deployFlyio(ctx context.Context, token string) (*Deployme
 		Progress:    10,
 	})
 
-	detection := detector.DetectFramework(o.projectPath)
+	detection := detector.DetectAppAs(o.projectPath, o.config.AppSubdir(), o.config.FrameworkOverride)
 
 	// Create fly.io deployer
 	deployer := NewFlyioDeployer(o.projectName, o.projectPath, o.targetName, &detection, flyioConfig, token)
 	deployer.SetProgressCallback(o.progressCallback)
+	deployer.SetLabels(o.config.Labels)
 
 	// Get deployment options
 	deployOpts := o.config.Deploy
@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"path"
+	"strings"
+)
+
+// buildOutputDirs returns the build output subdirectories a static site serves
+// under their own URL prefixes
+func (e *Executor) buildOutputDirs() []config.BuildOutputDir {
+	if !e.isStaticSite() || e.deployOptions == nil {
+		return nil
+	}
+	return e.deployOptions.BuildOutputDirs
+}
+
+// outputDirPath joins a mapped directory onto the build output, relative to
+// the release
+func outputDirPath(buildOutput, dir string) string {
+	return path.Join(strings.Trim(buildOutput, "/"), strings.Trim(dir, "/"))
+}
+
+// staticSiteRoot returns the root of a static site relative to the release:
+// the build output, or the directory mapped to / when there is one
+func staticSiteRoot(buildOutput string, dirs []config.BuildOutputDir) string {
+	for _, d := range dirs {
+		if normalizeURLPrefix(d.PathPrefix) == "/" {
+			return outputDirPath(buildOutput, d.Dir) + "/"
+		}
+	}
+	return buildOutput
+}
+
+// outputDirPaths returns the mapped build output directories other than the
+// one mapped to /, which is the site root instead
+func outputDirPaths(appDir, buildOutput string, dirs []config.BuildOutputDir) []proxy.StaticPath {
+	var paths []proxy.StaticPath
+	for _, d := range dirs {
+		prefix := normalizeURLPrefix(d.PathPrefix)
+		if prefix == "/" {
+			continue
+		}
+		paths = append(paths, proxy.StaticPath{
+			URLPrefix: prefix,
+			Dir:       fmt.Sprintf("%s/current/%s", appDir, outputDirPath(buildOutput, d.Dir)),
+		})
+	}
+	return paths
+}
+
+// outputDirLocations renders a location per mapped build output directory,
+// see nginx.OutputDirLocations. It renders nothing when there are no mappings.
+func outputDirLocations(appDir, buildOutput string, dirs []config.BuildOutputDir) string {
+	return nginx.OutputDirLocations(outputDirPaths(appDir, buildOutput, dirs))
+}
+
+// StaticSiteFor returns the directory a static site is served from and its
+// build output directories served under their own URL prefixes, for nginx
+// sites written outside a deploy (domain add). The root is empty when the
+// detected app is not a static site.
+func StaticSiteFor(detection *detector.Detection, appDir string, opts *config.DeploymentOptions) (string, []proxy.StaticPath) {
+	if detection == nil || detection.Meta["deployment_type"] != "static" {
+		return "", nil
+	}
+	buildOutput := "dist/"
+	if output, ok := detection.Meta["build_output"]; ok {
+		buildOutput = output
+	}
+	var dirs []config.BuildOutputDir
+	if opts != nil {
+		dirs = opts.BuildOutputDirs
+	}
+	return fmt.Sprintf("%s/current/%s", appDir, staticSiteRoot(buildOutput, dirs)), outputDirPaths(appDir, buildOutput, dirs)
+}
+
+// missingOutputDirsCommand prints each mapped directory that is not in the release
+func missingOutputDirsCommand(releasePath, buildOutput string, dirs []config.BuildOutputDir) string {
+	quoted := make([]string, len(dirs))
+	for i, d := range dirs {
+		quoted[i] = "'" + outputDirPath(buildOutput, d.Dir) + "'"
+	}
+	return fmt.Sprintf(`cd %s && for dir in %s; do test -d "$dir" || echo "$dir"; done`, releasePath, strings.Join(quoted, " "))
+}
+
+// checkOutputDirs fails when directories the nginx site serves are missing
+// from the built release, listing every missing one
+func checkOutputDirs(run func(command string) *sshpkg.CommandResult, releasePath, buildOutput string, dirs []config.BuildOutputDir) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	result := run(missingOutputDirsCommand(releasePath, buildOutput, dirs))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to check the build output", result)
+	}
+
+	missing := strings.Fields(result.Stdout)
+	if len(missing) > 0 {
+		return fmt.Errorf("build output is missing configured directories: %s (check deploy.build_output_dirs)", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"strings"
+	"testing"
+)
+
+// renderStaticSite renders the nginx site of an Astro static build with the
+// given build output directories
+func renderStaticSite(t *testing.T, dirs []config.BuildOutputDir) string {
+	t.Helper()
+	detection := &detector.Detection{
+		Framework: "Astro",
+		Language:  "JavaScript/TypeScript",
+		Meta:      map[string]string{"build_output": "dist/", "deployment_type": "static"},
+	}
+	executor := NewExecutorWithOptions(nil, "myapp", "/tmp/myapp", detection, &config.DeploymentOptions{BuildOutputDirs: dirs})
+
+	template, data := executor.nginxTemplateData(3000, "")
+	data["STATIC_LOCATIONS"] = ""
+	return sshpkg.RenderTemplate(template, data)
+}
+
+func TestStaticSiteWithoutOutputDirs(t *testing.T) {
+	conf := renderStaticSite(t, nil)
+
+	if !strings.Contains(conf, "root /srv/myapp/current/dist/;") {
+		t.Errorf("config does not serve the whole build output:\n%s", conf)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(conf), "add_header Cache-Control \"public, immutable\";\n  }\n\n}") {
+		t.Errorf("config without build output directories changed:\n%s", conf)
+	}
+	if strings.Contains(conf, "alias") || strings.Contains(conf, "{{") {
+		t.Errorf("unexpected locations or placeholders:\n%s", conf)
+	}
+}
+
+func TestStaticSiteWithOneOutputDir(t *testing.T) {
+	conf := renderStaticSite(t, []config.BuildOutputDir{{PathPrefix: "/de", Dir: "de"}})
+
+	for _, want := range []string{
+		"root /srv/myapp/current/dist/;",
+		"  location = /de { return 301 /de/; }\n",
+		"  location ^~ /de/ {\n    alias /srv/myapp/current/dist/de/;\n    try_files $uri $uri/ /de/index.html =404;\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q:\n%s", want, conf)
+		}
+	}
+}
+
+func TestStaticSiteWithLocaleOutputDirs(t *testing.T) {
+	conf := renderStaticSite(t, []config.BuildOutputDir{
+		{PathPrefix: "/", Dir: "en"},
+		{PathPrefix: "/de/", Dir: "de"},
+		{PathPrefix: "/fr/", Dir: "locales/fr"},
+	})
+
+	for _, want := range []string{
+		"root /srv/myapp/current/dist/en/;",
+		"alias /srv/myapp/current/dist/de/;\n    try_files $uri $uri/ /de/index.html =404;",
+		"alias /srv/myapp/current/dist/locales/fr/;\n    try_files $uri $uri/ /fr/index.html =404;",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "location ^~ // {") || strings.Count(conf, "location ^~") != 2 {
+		t.Errorf("the directory mapped to / should be the root, not a location:\n%s", conf)
+	}
+	if strings.Count(conf, "server {") != 1 || strings.Count(conf, "{") != strings.Count(conf, "}") {
+		t.Errorf("unbalanced config:\n%s", conf)
+	}
+}
+
+func TestCheckOutputDirs(t *testing.T) {
+	dirs := []config.BuildOutputDir{{PathPrefix: "/de/", Dir: "de"}, {PathPrefix: "/fr/", Dir: "fr"}, {PathPrefix: "/it/", Dir: "it"}}
+
+	var command string
+	missing := func(cmd string) *sshpkg.CommandResult {
+		command = cmd
+		return &sshpkg.CommandResult{Stdout: "dist/fr\ndist/it\n"}
+	}
+	err := checkOutputDirs(missing, "/srv/myapp/releases/20250101", "dist/", dirs)
+	if err == nil || !strings.Contains(err.Error(), "dist/fr, dist/it") {
+		t.Errorf("checkOutputDirs() = %v, want the missing directories listed", err)
+	}
+	if !strings.Contains(command, "cd /srv/myapp/releases/20250101 && for dir in 'dist/de' 'dist/fr' 'dist/it';") {
+		t.Errorf("command = %q", command)
+	}
+
+	present := func(string) *sshpkg.CommandResult { return &sshpkg.CommandResult{} }
+	if err := checkOutputDirs(present, "/srv/myapp/releases/20250101", "dist/", dirs); err != nil {
+		t.Errorf("checkOutputDirs() = %v, want nil when every directory exists", err)
+	}
+
+	unused := func(string) *sshpkg.CommandResult {
+		t.Error("checked the release without build output directories")
+		return &sshpkg.CommandResult{}
+	}
+	if err := checkOutputDirs(unused, "/srv/myapp/releases/20250101", "dist/", nil); err != nil {
+		t.Errorf("checkOutputDirs() = %v, want nil", err)
+	}
+}
+
+// TestStaticSiteFor_DomainSite checks the site domain add writes for a static
+// site serves the same root and output directories as the deploy's
+func TestStaticSiteFor_DomainSite(t *testing.T) {
+	detection := &detector.Detection{Framework: "Astro", Meta: map[string]string{"build_output": "dist/", "deployment_type": "static"}}
+	opts := &config.DeploymentOptions{BuildOutputDirs: []config.BuildOutputDir{{PathPrefix: "/", Dir: "en"}, {PathPrefix: "/de", Dir: "de"}}}
+
+	root, dirs := StaticSiteFor(detection, "/srv/myapp", opts)
+	if root != "/srv/myapp/current/dist/en/" {
+		t.Errorf("root = %q, want the directory mapped to /", root)
+	}
+
+	manager := nginx.NewManager(nil)
+	for _, ssl := range []bool{false, true} {
+		conf := manager.GenerateConfig(proxy.ProxyConfig{
+			Domain: "example.com", Port: 3000, AppName: "myapp", StaticRoot: root, OutputDirs: dirs,
+			SSLEnabled: ssl, SSLCertPath: "/etc/cert.pem", SSLKeyPath: "/etc/key.pem",
+		})
+		for _, want := range []string{
+			"root /srv/myapp/current/dist/en/;",
+			"  location ^~ /de/ {\n    alias /srv/myapp/current/dist/de/;\n    try_files $uri $uri/ /de/index.html =404;\n",
+		} {
+			if !strings.Contains(conf, want) {
+				t.Errorf("ssl=%v: config missing %q:\n%s", ssl, want, conf)
+			}
+		}
+		if strings.Contains(conf, "proxy_pass") {
+			t.Errorf("ssl=%v: static site proxies to the app:\n%s", ssl, conf)
+		}
+	}
+
+	if root, dirs := StaticSiteFor(&detector.Detection{Framework: "Next.js"}, "/srv/myapp", opts); root != "" || dirs != nil {
+		t.Errorf("StaticSiteFor() of a server app = %q, %v", root, dirs)
+	}
+}
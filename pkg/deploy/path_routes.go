@@ -0,0 +1,28 @@
+package deploy
+
+import (
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/state"
+)
+
+// PathRoutesFor returns the path routes registered on a domain in proxy form,
+// so every generator of the owning app's nginx site renders them
+func PathRoutesFor(serverIP, domain string) ([]proxy.PathRoute, error) {
+	if domain == "" {
+		return nil, nil
+	}
+	routes, err := state.GetPathRoutesForDomain(serverIP, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var proxyRoutes []proxy.PathRoute
+	for _, route := range routes {
+		proxyRoutes = append(proxyRoutes, proxy.PathRoute{
+			PathPrefix: route.PathPrefix,
+			Port:       route.Port,
+			AppName:    route.TargetName,
+		})
+	}
+	return proxyRoutes, nil
+}
@@ -0,0 +1,176 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PortReservationGrace is how long a reservation is kept while nothing is
+// installed for its app, so a target created from another machine keeps its
+// port until its first deploy
+const PortReservationGrace = 24 * time.Hour
+
+// PortReservation is a port reserved for an app on a server
+type PortReservation struct {
+	Port       int
+	AppName    string
+	ReservedAt time.Time
+	Installed  bool // The app has a systemd unit or nginx site on the server
+}
+
+// portReservationPath is the reservation file for port
+func portReservationPath(port int) string {
+	return fmt.Sprintf("%s/%d", config.RemotePortsDir, port)
+}
+
+// ReservePort claims port for appName on the server. It returns false when
+// another app holds the port; claiming a port the app already holds succeeds.
+func ReservePort(runner sshpkg.SudoRunner, port int, appName string) (bool, error) {
+	file := portReservationPath(port)
+	// noclobber makes the write fail when the file exists, so two machines
+	// reserving the same port cannot both succeed
+	script := fmt.Sprintf(`mkdir -p %s && if (set -C; printf '%%s\n' %s > %s) 2>/dev/null; then echo reserved; else echo "held=$(cat %s)"; fi`,
+		config.RemotePortsDir, util.ShellQuote(appName), file, file)
+	result := runner.ExecuteSudo("sh -c " + util.ShellQuote(script))
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to reserve port %d on the server: %w", port, result.Error)
+	}
+	if result.ExitCode != 0 {
+		return false, fmt.Errorf("failed to reserve port %d on the server: %s", port, strings.TrimSpace(result.Stderr))
+	}
+
+	output := lastOutputLine(result.Stdout)
+	if output == "reserved" {
+		return true, nil
+	}
+	return strings.TrimPrefix(output, "held=") == appName, nil
+}
+
+// portReservationsScript prints each reservation as "port app mtime installed"
+func portReservationsScript() string {
+	return fmt.Sprintf(`for f in %s/*; do
+  [ -f "$f" ] || continue
+  app=$(cat "$f")
+  installed=no
+  if [ -f "/etc/systemd/system/$app.service" ] || [ -f "/etc/nginx/sites-available/$app" ] || [ -f "/etc/nginx/sites-available/$app.conf" ]; then installed=yes; fi
+  echo "$(basename "$f") $app $(stat -c %%Y "$f") $installed"
+done`, config.RemotePortsDir)
+}
+
+// ListPortReservations reads the ports reserved on the server
+func ListPortReservations(runner sshpkg.SudoRunner) ([]PortReservation, error) {
+	result := runner.ExecuteSudo("sh -c " + util.ShellQuote(portReservationsScript()))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list port reservations: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to list port reservations: %s", strings.TrimSpace(result.Stderr))
+	}
+	return parsePortReservations(result.Stdout), nil
+}
+
+// parsePortReservations parses portReservationsScript's output, skipping
+// lines that are not a reservation
+func parsePortReservations(output string) []PortReservation {
+	var reservations []PortReservation
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		port, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		mtime, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		reservations = append(reservations, PortReservation{
+			Port:       port,
+			AppName:    fields[1],
+			ReservedAt: time.Unix(mtime, 0),
+			Installed:  fields[3] == "yes",
+		})
+	}
+	return reservations
+}
+
+// StalePortReservations returns the reservations of apps that are neither
+// installed on the server nor known, once the grace period has passed.
+// known reports whether an app belongs to a target lightfold still manages.
+func StalePortReservations(reservations []PortReservation, known func(appName string) bool, now time.Time) []PortReservation {
+	var stale []PortReservation
+	for _, r := range reservations {
+		if r.Installed || known(r.AppName) || now.Sub(r.ReservedAt) < PortReservationGrace {
+			continue
+		}
+		stale = append(stale, r)
+	}
+	return stale
+}
+
+// backfillPortReservationsScript reserves, for each app with a systemd unit,
+// the PORT its unit sets, printing each port it reserved. Held ports are
+// skipped the same way ReservePort refuses them.
+func backfillPortReservationsScript(appNames []string) string {
+	quoted := make([]string, len(appNames))
+	for i, name := range appNames {
+		quoted[i] = util.ShellQuote(name)
+	}
+	return fmt.Sprintf(`mkdir -p %s
+for app in %s; do
+  unit="/etc/systemd/system/$app.service"
+  [ -f "$unit" ] || continue
+  port=$(sed -n 's/^Environment=PORT=\([0-9][0-9]*\).*/\1/p' "$unit" | head -n 1)
+  [ -n "$port" ] || continue
+  if (set -C; printf '%%s\n' "$app" > %s/"$port") 2>/dev/null; then echo "$port"; fi
+done`, config.RemotePortsDir, strings.Join(quoted, " "), config.RemotePortsDir)
+}
+
+// BackfillPortReservations reserves the ports of installed apps deployed
+// before reservations existed, reading each port from the app's unit, so
+// allocations from other machines skip them. It returns the ports reserved.
+func BackfillPortReservations(runner sshpkg.SudoRunner, appNames []string) ([]int, error) {
+	if len(appNames) == 0 {
+		return nil, nil
+	}
+	result := runner.ExecuteSudo("sh -c " + util.ShellQuote(backfillPortReservationsScript(appNames)))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to reserve installed ports: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to reserve installed ports: %s", strings.TrimSpace(result.Stderr))
+	}
+	var ports []int
+	for _, line := range strings.Fields(result.Stdout) {
+		if port, err := strconv.Atoi(line); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// releasePortScript removes the reservation of port when appName holds it, so
+// a port another app has reserved since is left alone
+func releasePortScript(port int, appName string) string {
+	file := portReservationPath(port)
+	return fmt.Sprintf(`if [ "$(cat %s 2>/dev/null)" = %s ]; then rm -f %s; fi`, file, util.ShellQuote(appName), file)
+}
+
+// ReleasePortReservation removes appName's reservation of port from the server
+func ReleasePortReservation(runner sshpkg.SudoRunner, port int, appName string) error {
+	result := runner.ExecuteSudo("sh -c " + util.ShellQuote(releasePortScript(port, appName)))
+	if result.Error != nil {
+		return fmt.Errorf("failed to release port %d: %w", port, result.Error)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to release port %d: %s", port, strings.TrimSpace(result.Stderr))
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sshpkg "lightfold/pkg/ssh"
+)
+
+// reservationServer answers the reservation script with the app holding the
+// port, or reserves it when nobody does
+type reservationServer struct {
+	holders  map[int]string
+	commands []string
+}
+
+func (s *reservationServer) ExecuteSudo(command string) *sshpkg.CommandResult {
+	s.commands = append(s.commands, command)
+	for port, app := range s.holders {
+		if strings.Contains(command, portReservationPath(port)+")") {
+			return &sshpkg.CommandResult{Stdout: "held=" + app + "\n"}
+		}
+	}
+	return &sshpkg.CommandResult{Stdout: "reserved\n"}
+}
+
+func TestReservePort(t *testing.T) {
+	server := &reservationServer{holders: map[int]string{3000: "blog"}}
+
+	tests := []struct {
+		port int
+		app  string
+		want bool
+	}{
+		{3000, "shop", false},
+		{3000, "blog", true},
+		{3001, "shop", true},
+	}
+	for _, tt := range tests {
+		got, err := ReservePort(server, tt.port, tt.app)
+		if err != nil {
+			t.Fatalf("ReservePort(%d, %s) error: %v", tt.port, tt.app, err)
+		}
+		if got != tt.want {
+			t.Errorf("ReservePort(%d, %s) = %v, want %v", tt.port, tt.app, got, tt.want)
+		}
+	}
+	if !strings.Contains(server.commands[0], "set -C") {
+		t.Errorf("reservation %q should not overwrite an existing file", server.commands[0])
+	}
+}
+
+func TestStalePortReservations(t *testing.T) {
+	now := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	old := strconv.FormatInt(now.Add(-2*PortReservationGrace).Unix(), 10)
+	recent := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	output := strings.Join([]string{
+		"3000 blog " + old + " yes",    // installed
+		"3001 shop " + old + " no",     // known to a local target
+		"3002 gone " + old + " no",     // destroyed
+		"3003 fresh " + recent + " no", // reserved by another machine
+		"garbage",
+	}, "\n")
+
+	reservations := parsePortReservations(output)
+	if len(reservations) != 4 {
+		t.Fatalf("parsePortReservations() = %d reservations, want 4", len(reservations))
+	}
+
+	known := func(app string) bool { return app == "shop" }
+	stale := StalePortReservations(reservations, known, now)
+	if len(stale) != 1 || stale[0].Port != 3002 || stale[0].AppName != "gone" {
+		t.Errorf("StalePortReservations() = %+v, want only port 3002", stale)
+	}
+}
+
+func TestReleasePortScript(t *testing.T) {
+	ports := t.TempDir()
+	for port, app := range map[string]string{"3000": "shop\n", "3001": "blog\n"} {
+		if err := os.WriteFile(filepath.Join(ports, port), []byte(app), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, port := range []int{3000, 3001, 3002} {
+		script := strings.ReplaceAll(releasePortScript(port, "shop"), config.RemotePortsDir, ports)
+		if output, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+			t.Fatalf("script failed: %v\n%s", err, output)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(ports, "3000")); !os.IsNotExist(err) {
+		t.Errorf("shop's reservation of 3000 should be released, stat error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(ports, "3001")); string(got) != "blog\n" {
+		t.Errorf("port 3001 held by %q, want blog's reservation kept", got)
+	}
+}
+
+func TestBackfillPortReservationsScript(t *testing.T) {
+	root := t.TempDir()
+	units, ports := filepath.Join(root, "units"), filepath.Join(root, "ports")
+	for _, dir := range []string{units, ports} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for app, unit := range map[string]string{
+		"shop": "[Service]\nEnvironment=PORT=3001\nEnvironment=HOST=127.0.0.1\n",
+		"blog": "[Service]\nEnvironment=PORT=3000\n",
+		"cron": "[Service]\nExecStart=/bin/true\n",
+	} {
+		if err := os.WriteFile(filepath.Join(units, app+".service"), []byte(unit), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(ports, "3000"), []byte("wiki\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	script := backfillPortReservationsScript([]string{"blog", "cron", "gone", "shop"})
+	script = strings.ReplaceAll(script, "/etc/systemd/system", units)
+	script = strings.ReplaceAll(script, config.RemotePortsDir, ports)
+	output, err := exec.Command("sh", "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, output)
+	}
+
+	if got := strings.Fields(string(output)); len(got) != 1 || got[0] != "3001" {
+		t.Errorf("reserved %v, want [3001]", got)
+	}
+	for port, want := range map[string]string{"3000": "wiki\n", "3001": "shop\n"} {
+		if got, _ := os.ReadFile(filepath.Join(ports, port)); string(got) != want {
+			t.Errorf("port %s held by %q, want %q", port, got, want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"regexp"
+	"strings"
+)
+
+// previewNamePattern keeps preview names usable as a DNS label and a directory
+var previewNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidatePreviewName checks that name can be a preview's subdomain and directory
+func ValidatePreviewName(name string) error {
+	if !previewNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid preview name %q: use lowercase letters, digits and hyphens (e.g. pr-142)", name)
+	}
+	return nil
+}
+
+// PreviewHost returns the host previews hang off: preview.<domain>
+func PreviewHost(domain string) string {
+	return "preview." + domain
+}
+
+// PreviewSite describes how a preview deployment is served. Previews get their
+// own nginx sites and never touch the production site or current symlink.
+type PreviewSite struct {
+	Name   string
+	Domain string // Production domain
+	// PathPrefix serves the preview at preview.<domain>/<name>/ instead of
+	// <name>.preview.<domain>, for domains without wildcard DNS
+	PathPrefix bool
+	SSLEnabled bool
+	// CertPath and KeyPath are the *.preview.<domain> wildcard certificate for
+	// subdomain previews. Path prefix previews get a certificate for the
+	// preview host from certbot's nginx plugin instead.
+	CertPath string
+	KeyPath  string
+}
+
+// URL returns where the preview is served
+func (s PreviewSite) URL() string {
+	scheme := "http"
+	if s.SSLEnabled {
+		scheme = "https"
+	}
+	if s.PathPrefix {
+		return fmt.Sprintf("%s://%s/%s/", scheme, PreviewHost(s.Domain), s.Name)
+	}
+	return fmt.Sprintf("%s://%s.%s", scheme, s.Name, PreviewHost(s.Domain))
+}
+
+func (e *Executor) previewsDir() string {
+	return fmt.Sprintf("%s/previews", e.AppDir())
+}
+
+// PreviewPath returns the directory a preview is deployed to
+func (e *Executor) PreviewPath(name string) string {
+	return e.previewsDir() + "/" + name
+}
+
+// previewSitePath is the nginx site of a subdomain preview
+func (e *Executor) previewSitePath(name string) string {
+	return fmt.Sprintf("/etc/nginx/sites-available/%s-preview-%s", e.appName, name)
+}
+
+// previewHostSitePath is the nginx site serving path prefix previews
+func (e *Executor) previewHostSitePath() string {
+	return fmt.Sprintf("/etc/nginx/sites-available/%s-previews", e.appName)
+}
+
+// previewLocationsDir holds one location file per path prefix preview, all
+// included by the preview host site
+func (e *Executor) previewLocationsDir() string {
+	return fmt.Sprintf("/etc/nginx/lightfold-previews/%s", e.appName)
+}
+
+// SupportsPreviews reports whether the app can have preview deployments. Only
+// static sites can: a preview is its build output served by nginx.
+func (e *Executor) SupportsPreviews() bool {
+	return e.isStaticSite()
+}
+
+// staticBuildOutput is the directory nginx serves a static site from, relative
+// to the release
+func (e *Executor) staticBuildOutput() string {
+	if e.detection != nil && e.detection.Meta != nil {
+		if output, ok := e.detection.Meta["build_output"]; ok {
+			return output
+		}
+	}
+	return "dist/"
+}
+
+// DeployPreview uploads and builds the tarball as a preview, then points the
+// preview's nginx site at it. The build runs in a staging directory so a
+// preview that is pushed again keeps serving until the new build is done.
+func (e *Executor) DeployPreview(tarballPath string, site PreviewSite, envVars map[string]string) error {
+	if !e.isStaticSite() {
+		return fmt.Errorf("preview deployments are only supported for static sites")
+	}
+	if err := ValidatePreviewName(site.Name); err != nil {
+		return err
+	}
+
+	previewPath := e.PreviewPath(site.Name)
+	stagingPath := previewPath + ".new"
+
+	for _, cmd := range []string{
+		fmt.Sprintf("rm -rf %s", stagingPath),
+		fmt.Sprintf("mkdir -p %s", stagingPath),
+		fmt.Sprintf("chown deploy:www-data %s 2>/dev/null || chown deploy:deploy %s", e.previewsDir(), e.previewsDir()),
+		fmt.Sprintf("chmod 750 %s", e.previewsDir()),
+	} {
+		result := e.ssh.ExecuteSudoIdempotent(cmd)
+		if result.Error != nil || result.ExitCode != 0 {
+			return fmt.Errorf("failed to prepare preview directory: %s", result.Stderr)
+		}
+	}
+
+	checksum, err := FileSHA256(tarballPath)
+	if err != nil {
+		return fmt.Errorf("failed to checksum tarball: %w", err)
+	}
+	remoteTarball := fmt.Sprintf("/tmp/lightfold-%s-preview-%s.tar.gz", e.appName, site.Name)
+	if err := e.uploadVerified(tarballPath, remoteTarball, checksum); err != nil {
+		e.discardUpload(remoteTarball, stagingPath)
+		return err
+	}
+	result := e.ssh.ExecuteSudoIdempotent(fmt.Sprintf("tar -xzf %s -C %s", remoteTarball, stagingPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		e.discardUpload(remoteTarball, stagingPath)
+		return fmt.Errorf("failed to extract tarball: %s", result.Stderr)
+	}
+	e.ssh.Execute(fmt.Sprintf("rm -f %s", remoteTarball))
+	if err := e.restrictReleasePermissions(stagingPath); err != nil {
+		e.discardUpload(remoteTarball, stagingPath)
+		return err
+	}
+
+	if err := e.BuildReleaseWithEnv(stagingPath, envVars); err != nil {
+		e.ssh.ExecuteSudo(fmt.Sprintf("rm -rf %s", stagingPath))
+		return fmt.Errorf("failed to build preview: %w", err)
+	}
+
+	result = e.ssh.ExecuteSudo(previewSwapCommand(previewPath, stagingPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to activate preview: %s", result.Stderr)
+	}
+
+	if err := e.writePreviewNginx(site); err != nil {
+		return err
+	}
+	if err := e.TestNginxConfig(); err != nil {
+		e.removePreviewNginx(site.Name)
+		return err
+	}
+	return e.ReloadNginx()
+}
+
+// previewSwapCommand moves a finished build into place, replacing the
+// preview's previous build
+func previewSwapCommand(previewPath, stagingPath string) string {
+	return fmt.Sprintf("rm -rf %[1]s.old && { test ! -e %[1]s || mv %[1]s %[1]s.old; } && mv %[2]s %[1]s && rm -rf %[1]s.old", previewPath, stagingPath)
+}
+
+func (e *Executor) writePreviewNginx(site PreviewSite) error {
+	root := strings.TrimSuffix(e.PreviewPath(site.Name)+"/"+strings.TrimPrefix(e.staticBuildOutput(), "/"), "/")
+
+	if !site.PathPrefix {
+		return e.installNginxFile(previewSiteConfig(e.appName, root, site), e.previewSitePath(site.Name), true)
+	}
+
+	// The host site is only written once: certbot adds its certificate to it
+	if result := e.ssh.ExecuteSudo("test -f " + e.previewHostSitePath()); result.ExitCode != 0 {
+		if err := e.installNginxFile(previewHostConfig(e.appName, e.previewLocationsDir(), site.Domain), e.previewHostSitePath(), true); err != nil {
+			return err
+		}
+	}
+	result := e.ssh.ExecuteSudo("mkdir -p " + e.previewLocationsDir())
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to create %s: %s", e.previewLocationsDir(), result.Stderr)
+	}
+	return e.installNginxFile(previewLocationConfig(root, site.Name), e.previewLocationsDir()+"/"+site.Name+".conf", false)
+}
+
+// installNginxFile writes an nginx config file as root, enabling it as a site
+// when enable is set
+func (e *Executor) installNginxFile(content, path string, enable bool) error {
+	if err := e.ssh.InstallFile(sshpkg.RemoteFile{Path: path, Mode: config.PermConfigFile, Owner: "root:root"}, content); err != nil {
+		return fmt.Errorf("failed to write nginx config: %w", err)
+	}
+	if !enable {
+		return nil
+	}
+
+	name := path[strings.LastIndex(path, "/")+1:]
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("ln -sf %s /etc/nginx/sites-enabled/%s", path, name))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to enable nginx site: %s", result.Stderr)
+	}
+	return nil
+}
+
+// removePreviewNginx deletes the preview's nginx site or location file. The
+// preview host site stays for other path prefix previews.
+func (e *Executor) removePreviewNginx(name string) {
+	sitePath := e.previewSitePath(name)
+	e.ssh.ExecuteSudo(fmt.Sprintf("rm -f /etc/nginx/sites-enabled/%s %s %s/%s.conf",
+		sitePath[strings.LastIndex(sitePath, "/")+1:], sitePath, e.previewLocationsDir(), name))
+}
+
+// RemovePreview deletes a preview's nginx config and files
+func (e *Executor) RemovePreview(name string) error {
+	if err := ValidatePreviewName(name); err != nil {
+		return err
+	}
+
+	e.removePreviewNginx(name)
+	if err := e.TestNginxConfig(); err != nil {
+		return err
+	}
+	if err := e.ReloadNginx(); err != nil {
+		return err
+	}
+
+	previewPath := e.PreviewPath(name)
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("rm -rf %s %s.new %s.old", previewPath, previewPath, previewPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to remove preview %s: %s", name, result.Stderr)
+	}
+	return nil
+}
+
+// previewSiteConfig renders the nginx site of a subdomain preview
+func previewSiteConfig(appName, root string, site PreviewSite) string {
+	host := site.Name + "." + PreviewHost(site.Domain)
+	body := fmt.Sprintf(`  access_log /var/log/nginx/%s-preview_access.log;
+  error_log  /var/log/nginx/%s-preview_error.log;
+
+  # Previews are not for search engines
+  add_header X-Robots-Tag "noindex, nofollow" always;
+
+  root %s;
+  index index.html;
+
+  location / {
+    try_files $uri $uri/ /index.html =404;
+  }
+}
+`, appName, appName, root)
+
+	if !site.SSLEnabled || site.CertPath == "" {
+		return fmt.Sprintf(`server {
+  listen 80;
+  listen [::]:80;
+  server_name %s;
+
+%s`, host, body)
+	}
+
+	return fmt.Sprintf(`server {
+  listen 80;
+  listen [::]:80;
+  server_name %s;
+  return 301 https://$host$request_uri;
+}
+
+server {
+  listen 443 ssl http2;
+  listen [::]:443 ssl http2;
+  server_name %s;
+
+  ssl_certificate %s;
+  ssl_certificate_key %s;
+  ssl_protocols TLSv1.2 TLSv1.3;
+  ssl_ciphers HIGH:!aNULL:!MD5;
+
+%s`, host, host, site.CertPath, site.KeyPath, body)
+}
+
+// previewHostConfig renders the site serving path prefix previews at
+// preview.<domain>/<name>/
+func previewHostConfig(appName, locationsDir, domain string) string {
+	return fmt.Sprintf(`server {
+  listen 80;
+  listen [::]:80;
+  server_name %s;
+
+  access_log /var/log/nginx/%s-preview_access.log;
+  error_log  /var/log/nginx/%s-preview_error.log;
+
+  # Previews are not for search engines
+  add_header X-Robots-Tag "noindex, nofollow" always;
+
+  include %s/*.conf;
+
+  location / {
+    return 404;
+  }
+}
+`, PreviewHost(domain), appName, appName, locationsDir)
+}
+
+// previewLocationConfig renders the location serving one path prefix preview
+func previewLocationConfig(root, name string) string {
+	return fmt.Sprintf(`location = /%[1]s { return 301 /%[1]s/; }
+location /%[1]s/ {
+  alias %[2]s/;
+  index index.html;
+  try_files $uri $uri/ /%[1]s/index.html =404;
+}
+`, name, root)
+}
@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"lightfold/pkg/detector"
+	"strings"
+	"testing"
+)
+
+func TestValidatePreviewName(t *testing.T) {
+	for _, name := range []string{"pr-142", "main", "a", "feature-1-2"} {
+		if err := ValidatePreviewName(name); err != nil {
+			t.Errorf("ValidatePreviewName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "PR-142", "-pr", "pr-", "pr_142", "pr.142", "../etc", strings.Repeat("a", 64)} {
+		if err := ValidatePreviewName(name); err == nil {
+			t.Errorf("ValidatePreviewName(%q) should fail", name)
+		}
+	}
+}
+
+func TestPreviewSiteURL(t *testing.T) {
+	tests := []struct {
+		site PreviewSite
+		want string
+	}{
+		{PreviewSite{Name: "pr-142", Domain: "example.com"}, "http://pr-142.preview.example.com"},
+		{PreviewSite{Name: "pr-142", Domain: "example.com", SSLEnabled: true}, "https://pr-142.preview.example.com"},
+		{PreviewSite{Name: "pr-142", Domain: "example.com", PathPrefix: true}, "http://preview.example.com/pr-142/"},
+	}
+	for _, tt := range tests {
+		if got := tt.site.URL(); got != tt.want {
+			t.Errorf("URL() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestPreviewPaths(t *testing.T) {
+	detection := &detector.Detection{Meta: map[string]string{"deployment_type": "static", "build_output": "out/"}}
+	exec := NewExecutor(nil, "myapp", "", detection)
+
+	if !exec.SupportsPreviews() {
+		t.Error("static sites should support previews")
+	}
+	if NewExecutor(nil, "myapp", "", &detector.Detection{Meta: map[string]string{}}).SupportsPreviews() {
+		t.Error("apps with a server process should not support previews")
+	}
+
+	if got := exec.PreviewPath("pr-142"); got != "/srv/myapp/previews/pr-142" {
+		t.Errorf("PreviewPath() = %q", got)
+	}
+	// Preview configs are separate files, so the production site is never rewritten
+	for _, path := range []string{exec.previewSitePath("pr-142"), exec.previewHostSitePath()} {
+		if path == "/etc/nginx/sites-available/myapp" {
+			t.Errorf("preview config path %q is the production site", path)
+		}
+	}
+}
+
+func TestPreviewSiteConfig(t *testing.T) {
+	root := "/srv/myapp/previews/pr-142/dist"
+
+	conf := previewSiteConfig("myapp", root, PreviewSite{Name: "pr-142", Domain: "example.com"})
+	for _, want := range []string{
+		"server_name pr-142.preview.example.com;",
+		"root /srv/myapp/previews/pr-142/dist;",
+		`add_header X-Robots-Tag "noindex, nofollow" always;`,
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("previewSiteConfig() missing %q:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "443") || strings.Contains(conf, "current") {
+		t.Errorf("HTTP preview config should not listen on 443 or serve the current release:\n%s", conf)
+	}
+
+	// SSL without a wildcard certificate falls back to HTTP
+	if conf := previewSiteConfig("myapp", root, PreviewSite{Name: "pr-142", Domain: "example.com", SSLEnabled: true}); strings.Contains(conf, "443") {
+		t.Errorf("preview config without a certificate should be HTTP only:\n%s", conf)
+	}
+
+	ssl := previewSiteConfig("myapp", root, PreviewSite{
+		Name: "pr-142", Domain: "example.com", SSLEnabled: true,
+		CertPath: "/etc/letsencrypt/live/preview.example.com/fullchain.pem",
+		KeyPath:  "/etc/letsencrypt/live/preview.example.com/privkey.pem",
+	})
+	for _, want := range []string{
+		"return 301 https://$host$request_uri;",
+		"listen 443 ssl http2;",
+		"ssl_certificate /etc/letsencrypt/live/preview.example.com/fullchain.pem;",
+	} {
+		if !strings.Contains(ssl, want) {
+			t.Errorf("SSL previewSiteConfig() missing %q:\n%s", want, ssl)
+		}
+	}
+}
+
+func TestPreviewPathPrefixConfig(t *testing.T) {
+	host := previewHostConfig("myapp", "/etc/nginx/lightfold-previews/myapp", "example.com")
+	for _, want := range []string{
+		"server_name preview.example.com;",
+		"include /etc/nginx/lightfold-previews/myapp/*.conf;",
+	} {
+		if !strings.Contains(host, want) {
+			t.Errorf("previewHostConfig() missing %q:\n%s", want, host)
+		}
+	}
+
+	location := previewLocationConfig("/srv/myapp/previews/pr-142/dist", "pr-142")
+	for _, want := range []string{
+		"location = /pr-142 { return 301 /pr-142/; }",
+		"alias /srv/myapp/previews/pr-142/dist/;",
+		"try_files $uri $uri/ /pr-142/index.html =404;",
+	} {
+		if !strings.Contains(location, want) {
+			t.Errorf("previewLocationConfig() missing %q:\n%s", want, location)
+		}
+	}
+}
+
+func TestPreviewSwapCommand(t *testing.T) {
+	got := previewSwapCommand("/srv/myapp/previews/pr-142", "/srv/myapp/previews/pr-142.new")
+	want := "rm -rf /srv/myapp/previews/pr-142.old && { test ! -e /srv/myapp/previews/pr-142 || mv /srv/myapp/previews/pr-142 /srv/myapp/previews/pr-142.old; } && mv /srv/myapp/previews/pr-142.new /srv/myapp/previews/pr-142 && rm -rf /srv/myapp/previews/pr-142.old"
+	if got != want {
+		t.Errorf("previewSwapCommand() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// turboPruneArgs prunes a Turborepo workspace to one app and the packages it
+// depends on. The positional scope is what `--scope=<app>` became in turbo
+// 1.x and the only form turbo 2 accepts.
+func turboPruneArgs(app, outDir string) []string {
+	return []string{"turbo", "prune", app, "--out-dir=" + outDir}
+}
+
+// packRoot returns the directory the release is packed from: the output of
+// `turbo prune` for an app of a Turborepo workspace, the project otherwise.
+// A workspace that cannot be pruned is packed whole with a warning. cleanup
+// removes the pruned copy.
+func (e *Executor) packRoot() (root string, cleanup func()) {
+	noop := func() {}
+	if e.detection == nil || e.detection.Meta["monorepo_tool"] != "turborepo" {
+		return e.projectPath, noop
+	}
+	app := e.detection.Meta["monorepo_app"]
+	if app == "" || strings.HasPrefix(app, "./") {
+		return e.projectPath, noop
+	}
+
+	outDir, err := os.MkdirTemp("", "lightfold-prune-*")
+	if err != nil {
+		return e.projectPath, noop
+	}
+	cmd := exec.Command("npx", turboPruneArgs(app, outDir)...)
+	cmd.Dir = e.projectPath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(outDir)
+		e.notify(fmt.Sprintf("Warning: turbo prune failed, uploading the whole workspace: %s", firstLine(string(output), err)))
+		return e.projectPath, noop
+	}
+	return outDir, func() { os.RemoveAll(outDir) }
+}
+
+func firstLine(output string, err error) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(output), "\n"); line != "" {
+		return line
+	}
+	return err.Error()
+}
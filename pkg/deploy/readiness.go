@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+	"lightfold/pkg/util"
+	"strings"
+	"time"
+)
+
+// serverReadyProbe reports cloud-init's status and whether apt/dpkg locks are
+// held, and by what, in one round trip, so the lock wait runs alongside the
+// cloud-init wait
+var serverReadyProbe = `cloud-init status 2>/dev/null || echo "status: unavailable"; ` + aptLockProbe
+
+// serverReadyCommand runs serverReadyProbe as root, through sudo when not
+// connected as root
+var serverReadyCommand = "sh -c " + util.ShellQuote(serverReadyProbe)
+
+// serverReadyBackoff starts with quick probes for servers that are ready in
+// seconds and backs off for the slow first boots
+var serverReadyBackoff = sshpkg.Backoff{Initial: 2 * time.Second, Max: 15 * time.Second}
+
+// commandRunner runs a command on the server. It is satisfied by *ssh.Executor.
+type commandRunner interface {
+	Execute(command string) *sshpkg.CommandResult
+	ExecuteSudo(command string) *sshpkg.CommandResult
+}
+
+// parseServerReadiness reads serverReadyProbe's output
+func parseServerReadiness(output string) (cloudInit string, aptLocked bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "status:") && cloudInit == "":
+			cloudInit = strings.TrimSpace(strings.TrimPrefix(line, "status:"))
+		case line == "apt: locked":
+			aptLocked = true
+		}
+	}
+	return cloudInit, aptLocked
+}
+
+// SetAptLockOptions sets how long the server readiness wait waits for the
+// apt/dpkg locks (--apt-wait, zero for config.DefaultServerReadyTimeout) and
+// whether it stops a stale unattended-upgrades holding them (--kill-stale-apt)
+func (e *Executor) SetAptLockOptions(wait time.Duration, killStale bool) {
+	e.aptWait = wait
+	e.killStaleApt = killStale
+}
+
+// WaitForServerReady waits for cloud-init to finish and the apt/dpkg locks to
+// be released, probing both together with exponential backoff. cloud-init is
+// given up on after config.DefaultCloudInitTimeout, as before; the locks have
+// to be free within config.DefaultServerReadyTimeout or --apt-wait.
+func (e *Executor) WaitForServerReady() error {
+	timeout := e.aptWait
+	if timeout <= 0 {
+		timeout = config.DefaultServerReadyTimeout
+	}
+	return waitForServerReady(e.ssh, sshpkg.SystemClock, config.DefaultCloudInitTimeout, timeout, e.killStaleApt, e.outputCallback)
+}
+
+func waitForServerReady(runner commandRunner, clock sshpkg.Clock, cloudInitTimeout, timeout time.Duration, killStale bool, report func(string)) error {
+	lastStatus := ""
+	progress := func(attempt int, elapsed time.Duration, status string) {
+		if report != nil && (status != lastStatus || attempt%4 == 0) {
+			report(fmt.Sprintf("  Initializing server: %s (%s)", status, elapsed.Round(time.Second)))
+		}
+		lastStatus = status
+	}
+
+	stopped := map[int]bool{}
+	err := sshpkg.PollUntil(clock, timeout, serverReadyBackoff, func(attempt int, elapsed time.Duration) (bool, error) {
+		result := runner.ExecuteSudo(serverReadyCommand)
+		if result.Error != nil {
+			return false, result.Error
+		}
+
+		cloudInit, aptLocked := parseServerReadiness(result.Stdout)
+		cloudInitBusy := cloudInit == "running" || cloudInit == "not started" || cloudInit == "not run"
+		if cloudInitBusy && elapsed >= cloudInitTimeout {
+			cloudInitBusy = false
+		}
+		if !cloudInitBusy && !aptLocked {
+			return true, nil
+		}
+		if cloudInitBusy {
+			status := "waiting for cloud-init to complete"
+			progress(attempt, elapsed, status)
+			return false, fmt.Errorf("%s", status)
+		}
+
+		holders := parseAptLockHolders(result.Stdout)
+		if action, holder := decideAptLock(holders, killStale, stopped); action == aptLockStopStale {
+			stopped[holder.PID] = true
+			progress(attempt, elapsed, fmt.Sprintf("stopping %s, stuck for over %s (--kill-stale-apt)", holder, config.AptStaleHolderAge))
+			if stop := runner.ExecuteSudo(stopStaleAptCommand(holder.PID)); stop.Error != nil || stop.ExitCode != 0 {
+				progress(attempt, elapsed, fmt.Sprintf("could not stop %s: %s", holder, strings.TrimSpace(stop.Stdout+stop.Stderr)))
+			}
+			return false, &AptLockError{Holders: holders}
+		}
+
+		status := "waiting for apt locks to be released"
+		if len(holders) > 0 {
+			status = "waiting for apt locks held by " + holders[0].String()
+			if wait := holders[0].ExpectedWait(); wait != "" {
+				status += "; " + wait
+			}
+		}
+		progress(attempt, elapsed, status)
+		return false, &AptLockError{Holders: holders}
+	})
+
+	// Give up with what held the locks and what to do about it
+	var timeoutErr *sshpkg.PollTimeoutError
+	var lockErr *AptLockError
+	if errors.As(err, &timeoutErr) && errors.As(err, &lockErr) {
+		return fmt.Errorf("server not ready: %w", &AptLockError{Holders: lockErr.Holders, Waited: timeoutErr.Elapsed, KillStale: killStale})
+	}
+	if err != nil {
+		return fmt.Errorf("server not ready: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"lightfold/pkg/ssh"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+// fakeProbeRunner answers the readiness probe from a script of outputs,
+// repeating the last one
+type fakeProbeRunner struct {
+	outputs []string
+	calls   int
+}
+
+func (r *fakeProbeRunner) Execute(command string) *ssh.CommandResult {
+	return &ssh.CommandResult{}
+}
+
+func (r *fakeProbeRunner) ExecuteSudo(command string) *ssh.CommandResult {
+	output := r.outputs[len(r.outputs)-1]
+	if r.calls < len(r.outputs) {
+		output = r.outputs[r.calls]
+	}
+	r.calls++
+	return &ssh.CommandResult{Stdout: output}
+}
+
+func TestParseServerReadiness(t *testing.T) {
+	tests := []struct {
+		output        string
+		wantCloudInit string
+		wantLocked    bool
+	}{
+		{"status: running\napt: locked\n", "running", true},
+		{"status: done\napt: free\n", "done", false},
+		{"status: error\nstatus: unavailable\napt: free\n", "error", false},
+		{"status: unavailable\napt: locked\n", "unavailable", true},
+	}
+	for _, tt := range tests {
+		cloudInit, locked := parseServerReadiness(tt.output)
+		if cloudInit != tt.wantCloudInit || locked != tt.wantLocked {
+			t.Errorf("parseServerReadiness(%q) = %q, %v; want %q, %v", tt.output, cloudInit, locked, tt.wantCloudInit, tt.wantLocked)
+		}
+	}
+}
+
+func TestWaitForServerReady_ReadyServerReturnsImmediately(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	runner := &fakeProbeRunner{outputs: []string{"status: done\napt: free\n"}}
+
+	if err := waitForServerReady(runner, clock, 5*time.Minute, 10*time.Minute, false, nil); err != nil {
+		t.Fatalf("waitForServerReady() = %v", err)
+	}
+	if len(clock.sleeps) != 0 || runner.calls != 1 {
+		t.Errorf("ready server slept %v over %d probes, want one probe and no sleep", clock.sleeps, runner.calls)
+	}
+}
+
+func TestWaitForServerReady_BackoffSchedule(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	runner := &fakeProbeRunner{outputs: []string{
+		"status: running\napt: locked\n",
+		"status: running\napt: locked\n",
+		"status: running\napt: free\n",
+		"status: done\napt: locked\n",
+		"status: done\napt: locked\n",
+		"status: done\napt: free\n",
+	}}
+	var reports []string
+
+	if err := waitForServerReady(runner, clock, 5*time.Minute, 10*time.Minute, false, func(line string) { reports = append(reports, line) }); err != nil {
+		t.Fatalf("waitForServerReady() = %v", err)
+	}
+
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 15 * time.Second, 15 * time.Second}
+	if !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+	if len(reports) == 0 || !strings.Contains(reports[0], "cloud-init") || !strings.Contains(reports[len(reports)-1], "apt locks") {
+		t.Errorf("reports = %v, want cloud-init then apt lock progress", reports)
+	}
+	if !strings.Contains(reports[0], "(0s)") {
+		t.Errorf("first report %q should include the elapsed time", reports[0])
+	}
+}
+
+func TestWaitForServerReady_GivesUpOnCloudInitButNotLocks(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	start := clock.now
+
+	// cloud-init never finishes: it is ignored after its own timeout
+	runner := &fakeProbeRunner{outputs: []string{"status: running\napt: free\n"}}
+	if err := waitForServerReady(runner, clock, time.Minute, 10*time.Minute, false, nil); err != nil {
+		t.Fatalf("waitForServerReady() = %v, want cloud-init to be given up on", err)
+	}
+	if elapsed := clock.now.Sub(start); elapsed < time.Minute || elapsed > time.Minute+15*time.Second {
+		t.Errorf("gave up on cloud-init after %v, want just past 1m", elapsed)
+	}
+
+	// Locks that are never released fail at the overall deadline
+	clock = &fakeClock{now: time.Unix(0, 0)}
+	start = clock.now
+	runner = &fakeProbeRunner{outputs: []string{"status: done\napt: locked\n"}}
+	err := waitForServerReady(runner, clock, time.Minute, 2*time.Minute, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "apt locks") {
+		t.Fatalf("waitForServerReady() = %v, want apt lock timeout", err)
+	}
+	if elapsed := clock.now.Sub(start); elapsed != 2*time.Minute {
+		t.Errorf("waited %v, want exactly the 2m deadline", elapsed)
+	}
+}
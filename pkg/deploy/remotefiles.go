@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"slices"
+	"strings"
+)
+
+// sharedEnvFile is the app's environment file, shared by all releases
+func (e *Executor) sharedEnvFile() sshpkg.RemoteFile {
+	return sshpkg.RemoteFile{
+		Path:   fmt.Sprintf("%s/shared/env/.env", e.AppDir()),
+		Mode:   config.PermEnvFile,
+		Owner:  "deploy:deploy",
+		Backup: true,
+	}
+}
+
+// systemdUnitFile is the app's systemd service unit
+func (e *Executor) systemdUnitFile() sshpkg.RemoteFile {
+	return sshpkg.RemoteFile{
+		Path:   fmt.Sprintf("/etc/systemd/system/%s.service", e.appName),
+		Mode:   config.PermConfigFile,
+		Owner:  "root:root",
+		Backup: true,
+	}
+}
+
+// nginxSiteFile is the app's production nginx site
+func (e *Executor) nginxSiteFile() sshpkg.RemoteFile {
+	return sshpkg.RemoteFile{
+		Path:   fmt.Sprintf("/etc/nginx/sites-available/%s", e.appName),
+		Mode:   config.PermConfigFile,
+		Owner:  "root:root",
+		Backup: true,
+	}
+}
+
+// domainSiteFile is the site the nginx proxy manager writes when a domain is
+// added, next to the app's own site
+func (e *Executor) domainSiteFile() sshpkg.RemoteFile {
+	return sshpkg.RemoteFile{
+		Path:  new(nginx.Manager).GetConfigPath(e.appName),
+		Mode:  config.PermConfigFile,
+		Owner: "root:root",
+	}
+}
+
+// installFile atomically replaces a file on the server, remembering files with
+// backups so a rollback can restore them. Only the first write of a file backs
+// it up, so the backup stays the version from before this deploy even when the
+// file is written again (e.g. the nginx site once for the app, once for SSL).
+func (e *Executor) installFile(f sshpkg.RemoteFile, content string) error {
+	if slices.Contains(e.rewritten, f.Path) {
+		f.Backup = false
+	}
+	if err := e.ssh.InstallFile(f, content); err != nil {
+		return err
+	}
+	if f.Backup {
+		e.rewritten = append(e.rewritten, f.Path)
+	}
+	return nil
+}
+
+// RestoreBackup puts back the version of path that the last write of it
+// replaced, for the env file, systemd unit and nginx site. Restored units and
+// sites are reloaded.
+func (e *Executor) RestoreBackup(path string) error {
+	if err := e.ssh.RestoreBackup(path); err != nil {
+		return err
+	}
+
+	switch {
+	case path == e.systemdUnitFile().Path:
+		result := e.ssh.ExecuteSudo("systemctl daemon-reload")
+		if result.Error != nil || result.ExitCode != 0 {
+			return fmt.Errorf("failed to reload systemd: %s", result.Stderr)
+		}
+	case strings.HasPrefix(path, "/etc/nginx/"):
+		if err := e.TestNginxConfig(); err != nil {
+			return err
+		}
+		return e.ReloadNginx()
+	}
+	return nil
+}
+
+// restoreRewrittenFiles restores the files this executor replaced when a
+// deploy rolls back to the previous release, each from the backup its first
+// write took. Files written for the first time have no backup and stay as
+// they are.
+func (e *Executor) restoreRewrittenFiles() {
+	for i := len(e.rewritten) - 1; i >= 0; i-- {
+		e.RestoreBackup(e.rewritten[i])
+	}
+	e.rewritten = nil
+}
@@ -0,0 +1,82 @@
+package deploy
+
+import (
+	sshpkg "lightfold/pkg/ssh"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallFile_KeepsTheFirstBackup(t *testing.T) {
+	exec, server := connectRecording(t, "myapp")
+	site := exec.nginxSiteFile()
+
+	for _, content := range []string{"first", "second"} {
+		if err := exec.installFile(site, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups := 0
+	for _, command := range server.Commands() {
+		if strings.Contains(command, "cp -pf /etc/nginx/sites-available/myapp /etc/nginx/sites-available/myapp.bak") {
+			backups++
+		}
+	}
+	if got := backups; got != 1 {
+		t.Errorf("the site was backed up %d times, want once so the backup is the pre-deploy version", got)
+	}
+	if len(exec.rewritten) != 1 {
+		t.Errorf("rewritten = %v, want the site once", exec.rewritten)
+	}
+}
+
+func TestRemoteFiles_InstallCommands(t *testing.T) {
+	exec := NewExecutor(nil, "myapp", "", nil)
+
+	tests := []struct {
+		name string
+		file sshpkg.RemoteFile
+		want string
+	}{
+		{
+			name: "env file",
+			file: exec.sharedEnvFile(),
+			want: "install -m 0600 -o deploy -g deploy /tmp/lightfold-upload-srv-myapp-shared-env-.env /srv/myapp/shared/env/.env.lightfold-new && " +
+				"rm -f /tmp/lightfold-upload-srv-myapp-shared-env-.env && " +
+				"sync /srv/myapp/shared/env/.env.lightfold-new && " +
+				"{ test ! -e /srv/myapp/shared/env/.env || cp -pf /srv/myapp/shared/env/.env /srv/myapp/shared/env/.env.bak; } && " +
+				"mv -Tf /srv/myapp/shared/env/.env.lightfold-new /srv/myapp/shared/env/.env",
+		},
+		{
+			name: "systemd unit",
+			file: exec.systemdUnitFile(),
+			want: "install -m 0644 -o root -g root /tmp/lightfold-upload-etc-systemd-system-myapp.service /etc/systemd/system/myapp.service.lightfold-new && " +
+				"rm -f /tmp/lightfold-upload-etc-systemd-system-myapp.service && " +
+				"sync /etc/systemd/system/myapp.service.lightfold-new && " +
+				"{ test ! -e /etc/systemd/system/myapp.service || cp -pf /etc/systemd/system/myapp.service /etc/systemd/system/myapp.service.bak; } && " +
+				"mv -Tf /etc/systemd/system/myapp.service.lightfold-new /etc/systemd/system/myapp.service",
+		},
+		{
+			name: "nginx site",
+			file: exec.nginxSiteFile(),
+			want: "install -m 0644 -o root -g root /tmp/lightfold-upload-etc-nginx-sites-available-myapp /etc/nginx/sites-available/myapp.lightfold-new && " +
+				"rm -f /tmp/lightfold-upload-etc-nginx-sites-available-myapp && " +
+				"sync /etc/nginx/sites-available/myapp.lightfold-new && " +
+				"{ test ! -e /etc/nginx/sites-available/myapp || cp -pf /etc/nginx/sites-available/myapp /etc/nginx/sites-available/myapp.bak; } && " +
+				"mv -Tf /etc/nginx/sites-available/myapp.lightfold-new /etc/nginx/sites-available/myapp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sshpkg.InstallFileCommand(tt.file, sshpkg.UploadPath(tt.file.Path)); got != tt.want {
+				t.Errorf("install command =\n%s\nwant\n%s", got, tt.want)
+			}
+			// The rename is only atomic when staging and destination share a directory
+			if filepath.Dir(sshpkg.StagingPath(tt.file.Path)) != filepath.Dir(tt.file.Path) {
+				t.Errorf("staging path %s is not next to %s", sshpkg.StagingPath(tt.file.Path), tt.file.Path)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
+)
+
+// StopTimerName returns the name of the systemd timer and service that stop
+// an app and power its server off on schedule
+func StopTimerName(appName string) string {
+	return "lightfold-stop-" + appName
+}
+
+// stopTimerUnits renders the service and timer that stop the app and power
+// the server off at the OnCalendar times. The server cannot power itself back
+// on; `lightfold schedule run` does that through the provider API.
+func stopTimerUnits(appName, onCalendar string) (service, timer string) {
+	service = fmt.Sprintf(`[Unit]
+Description=Stop %[1]s and power off on the lightfold schedule
+
+[Service]
+Type=oneshot
+ExecStartPre=-/bin/systemctl stop %[1]s
+ExecStart=/bin/systemctl poweroff
+`, appName)
+
+	timer = fmt.Sprintf(`[Unit]
+Description=lightfold stop schedule for %s
+
+[Timer]
+OnCalendar=%s
+
+[Install]
+WantedBy=timers.target
+`, appName, onCalendar)
+	return service, timer
+}
+
+// InstallStopTimer installs and starts the timer that stops the app and
+// powers the server off at the OnCalendar times
+func (e *Executor) InstallStopTimer(onCalendar string) error {
+	name := StopTimerName(e.appName)
+	service, timer := stopTimerUnits(e.appName, onCalendar)
+
+	files := []struct {
+		path, content string
+	}{
+		{"/etc/systemd/system/" + name + ".service", service},
+		{"/etc/systemd/system/" + name + ".timer", timer},
+	}
+	for _, file := range files {
+		unit := sshpkg.RemoteFile{Path: file.path, Mode: config.PermConfigFile, Owner: "root:root"}
+		if err := e.ssh.InstallFile(unit, file.content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.path, err)
+		}
+	}
+
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl daemon-reload && systemctl enable %s.timer && systemctl restart %s.timer", name, name))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to start the stop timer", result)
+	}
+	return nil
+}
+
+// RemoveStopTimer disables and deletes the stop timer. It is a no-op when the
+// timer is not installed.
+func (e *Executor) RemoveStopTimer() error {
+	name := StopTimerName(e.appName)
+	result := e.ssh.ExecuteSudo(fmt.Sprintf("systemctl disable --now %[1]s.timer 2>/dev/null; rm -f /etc/systemd/system/%[1]s.timer /etc/systemd/system/%[1]s.service && systemctl daemon-reload", name))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatSSHError("failed to remove the stop timer", result)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStopTimerUnits(t *testing.T) {
+	service, timer := stopTimerUnits("myapp", "Mon,Tue,Wed,Thu,Fri *-*-* 20:00:00 Europe/Berlin")
+
+	for _, want := range []string{"ExecStartPre=-/bin/systemctl stop myapp\n", "ExecStart=/bin/systemctl poweroff\n", "Type=oneshot\n"} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service unit missing %q:\n%s", want, service)
+		}
+	}
+	for _, want := range []string{"OnCalendar=Mon,Tue,Wed,Thu,Fri *-*-* 20:00:00 Europe/Berlin\n", "WantedBy=timers.target\n"} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer unit missing %q:\n%s", want, timer)
+		}
+	}
+	if StopTimerName("myapp") != "lightfold-stop-myapp" {
+		t.Errorf("StopTimerName() = %q", StopTimerName("myapp"))
+	}
+}
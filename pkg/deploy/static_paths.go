@@ -0,0 +1,197 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/proxy"
+	"path"
+	"sort"
+	"strings"
+)
+
+// frameworkStaticPath is a URL prefix a framework expects the web server to
+// serve from disk, with the directory relative to /srv/<app>
+type frameworkStaticPath struct {
+	urlPrefix string
+	dir       string
+	envVar    string // Set on the service and collectstatic so the app writes to dir
+}
+
+// frameworkStaticPaths lists the directories each framework leaves for nginx.
+// Django collects into shared/ so every release serves the same files; Rails
+// precompiles into the release's own public/ directory.
+var frameworkStaticPaths = map[string][]frameworkStaticPath{
+	"Django": {
+		{urlPrefix: "/static/", dir: "shared/static", envVar: "STATIC_ROOT"},
+		{urlPrefix: "/media/", dir: "shared/media", envVar: "MEDIA_ROOT"},
+	},
+	"Rails": {
+		{urlPrefix: "/assets/", dir: "current/public/assets"},
+		{urlPrefix: "/packs/", dir: "current/public/packs"},
+	},
+}
+
+// StaticPathsFor returns the URL prefixes nginx serves from disk for the app.
+// Paths in opts replace the framework defaults, and DisableStaticPaths turns
+// them off so every request reaches the app. appPath is the app's directory
+// on the server, e.g. /srv/<app>.
+func StaticPathsFor(framework, appPath string, opts *config.DeploymentOptions) []proxy.StaticPath {
+	if opts != nil && opts.DisableStaticPaths {
+		return nil
+	}
+
+	var paths []proxy.StaticPath
+
+	if opts != nil && len(opts.StaticPaths) > 0 {
+		for urlPrefix, dir := range opts.StaticPaths {
+			paths = append(paths, proxy.StaticPath{
+				URLPrefix: normalizeURLPrefix(urlPrefix),
+				Dir:       resolveStaticDir(appPath, dir),
+			})
+		}
+	} else {
+		for _, p := range frameworkStaticPaths[framework] {
+			paths = append(paths, proxy.StaticPath{URLPrefix: p.urlPrefix, Dir: resolveStaticDir(appPath, p.dir)})
+		}
+	}
+
+	sort.Slice(paths, func(i, j int) bool { return paths[i].URLPrefix < paths[j].URLPrefix })
+	return paths
+}
+
+func normalizeURLPrefix(urlPrefix string) string {
+	urlPrefix = "/" + strings.Trim(urlPrefix, "/")
+	if urlPrefix == "/" {
+		return urlPrefix
+	}
+	return urlPrefix + "/"
+}
+
+func resolveStaticDir(appPath, dir string) string {
+	if path.IsAbs(dir) {
+		return path.Clean(dir)
+	}
+	return path.Join(appPath, dir)
+}
+
+// staticPathEnvironment returns the environment variables that point the app
+// at its shared static directories, e.g. STATIC_ROOT for Django
+func (e *Executor) staticPathEnvironment() map[string]string {
+	if e.detection == nil || (e.deployOptions != nil && e.deployOptions.DisableStaticPaths) {
+		return nil
+	}
+
+	appPath := e.AppDir()
+	env := make(map[string]string)
+	for _, p := range frameworkStaticPaths[e.detection.Framework] {
+		if p.envVar != "" {
+			env[p.envVar] = resolveStaticDir(appPath, p.dir)
+		}
+	}
+	return env
+}
+
+// staticEnvironmentLines renders staticPathEnvironment as systemd Environment= lines
+func (e *Executor) staticEnvironmentLines() string {
+	env := e.staticPathEnvironment()
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\nEnvironment=%s=%s", key, env[key])
+	}
+	return b.String()
+}
+
+// withStaticRoot points collectstatic at the shared static directory so every
+// release serves the same collected files
+func (e *Executor) withStaticRoot(cmd string) string {
+	if !strings.Contains(cmd, "collectstatic") || strings.Contains(cmd, "STATIC_ROOT=") {
+		return cmd
+	}
+	staticRoot, ok := e.staticPathEnvironment()["STATIC_ROOT"]
+	if !ok {
+		return cmd
+	}
+	return fmt.Sprintf("STATIC_ROOT=%s %s", staticRoot, cmd)
+}
+
+// staticPermissionCommands returns the commands that let nginx (www-data) read
+// the static directories: directories under shared/ are created and handed to
+// the www-data group with setgid so files the app writes later stay readable,
+// and every parent directory gets the execute bit nginx needs to reach them
+func staticPermissionCommands(appPath string, paths []proxy.StaticPath) []string {
+	sharedPath := appPath + "/shared/"
+
+	var commands []string
+	parents := make(map[string]bool)
+	for _, p := range paths {
+		dir := p.Dir
+		if strings.HasPrefix(dir, sharedPath) {
+			commands = append(commands,
+				fmt.Sprintf("mkdir -p %s", dir),
+				fmt.Sprintf("chown -R deploy:www-data %s", dir),
+				fmt.Sprintf("chmod -R g+rX %s", dir),
+				fmt.Sprintf("find %s -type d -exec chmod g+s {} +", dir),
+			)
+		} else {
+			commands = append(commands, fmt.Sprintf("if [ -d %s ]; then chmod -R o+rX %s; fi", dir, dir))
+		}
+
+		for parent := path.Dir(dir); parent != "/" && parent != "."; parent = path.Dir(parent) {
+			parents[parent] = true
+		}
+	}
+
+	dirs := make([]string, 0, len(parents))
+	for dir := range parents {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		commands = append(commands, fmt.Sprintf("if [ -d %s ]; then chmod o+x %s; fi", dir, dir))
+	}
+	return commands
+}
+
+// StaticPaths returns the directories nginx serves for this app
+func (e *Executor) StaticPaths() []proxy.StaticPath {
+	if e.isStaticSite() && (e.deployOptions == nil || len(e.deployOptions.StaticPaths) == 0) {
+		return nil
+	}
+	framework := ""
+	if e.detection != nil {
+		framework = e.detection.Framework
+	}
+	return StaticPathsFor(framework, e.AppDir(), e.deployOptions)
+}
+
+// SetStaticPathOptions sets the target's static path overrides, build
+// output directories and port exposure when the executor was created without
+// deployment options
+func (e *Executor) SetStaticPathOptions(opts *config.DeploymentOptions) {
+	if e.deployOptions != nil || opts == nil {
+		return
+	}
+	e.deployOptions = &config.DeploymentOptions{
+		StaticPaths:        opts.StaticPaths,
+		DisableStaticPaths: opts.DisableStaticPaths,
+		BuildOutputDirs:    opts.BuildOutputDirs,
+		ExposePort:         opts.ExposePort,
+	}
+}
+
+// ConfigureStaticPathPermissions gives nginx read access to the static directories
+func (e *Executor) ConfigureStaticPathPermissions(paths []proxy.StaticPath) error {
+	for _, cmd := range staticPermissionCommands(e.AppDir(), paths) {
+		result := e.ssh.ExecuteSudo(cmd)
+		if result.Error != nil || result.ExitCode != 0 {
+			return formatSSHError("failed to set static directory permissions", result)
+		}
+	}
+	return nil
+}
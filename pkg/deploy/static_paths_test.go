@@ -0,0 +1,210 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/proxy/nginx"
+	sshpkg "lightfold/pkg/ssh"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func renderNginxTemplate(template string, locations string) string {
+	return strings.NewReplacer("{{STATIC_LOCATIONS}}", locations, "{{PATH_ROUTES}}", "", "{{APP_NAME}}", "myapp").Replace(template)
+}
+
+func TestStaticPathsForFramework(t *testing.T) {
+	django := StaticPathsFor("Django", "/srv/myapp", nil)
+	want := []proxy.StaticPath{
+		{URLPrefix: "/media/", Dir: "/srv/myapp/shared/media"},
+		{URLPrefix: "/static/", Dir: "/srv/myapp/shared/static"},
+	}
+	if len(django) != len(want) || django[0] != want[0] || django[1] != want[1] {
+		t.Errorf("Django static paths = %+v, want %+v", django, want)
+	}
+
+	rails := StaticPathsFor("Rails", "/srv/myapp", nil)
+	if len(rails) != 2 || rails[0].URLPrefix != "/assets/" || rails[0].Dir != "/srv/myapp/current/public/assets" {
+		t.Errorf("Rails static paths = %+v", rails)
+	}
+
+	if paths := StaticPathsFor("Next.js", "/srv/myapp", nil); len(paths) != 0 {
+		t.Errorf("Next.js static paths = %+v, want none", paths)
+	}
+}
+
+func TestStaticPathsForOverrides(t *testing.T) {
+	opts := &config.DeploymentOptions{StaticPaths: map[string]string{
+		"uploads":  "shared/uploads",
+		"/assets/": "/var/www/assets/",
+	}}
+	paths := StaticPathsFor("Django", "/srv/myapp", opts)
+	want := []proxy.StaticPath{
+		{URLPrefix: "/assets/", Dir: "/var/www/assets"},
+		{URLPrefix: "/uploads/", Dir: "/srv/myapp/shared/uploads"},
+	}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("overridden static paths = %+v, want %+v", paths, want)
+	}
+
+	opts.DisableStaticPaths = true
+	if paths := StaticPathsFor("Django", "/srv/myapp", opts); paths != nil {
+		t.Errorf("disabled static paths = %+v, want none", paths)
+	}
+}
+
+func TestNginxTemplateStaticLocations(t *testing.T) {
+	conf := renderNginxTemplate(nginxTemplate, nginx.StaticLocations(StaticPathsFor("Django", "/srv/myapp", nil), 8000))
+
+	// Files missing on disk (WhiteNoise, collectstatic not run yet) reach the app
+	for _, want := range []string{
+		"  location /media/  { alias /srv/myapp/shared/media/; try_files $uri @app; }\n",
+		"  location /static/ { alias /srv/myapp/shared/static/; try_files $uri @app; }\n  location @app {\n    proxy_pass http://127.0.0.1:8000;\n",
+		"  }\n\n  location / {",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("rendered config missing %q:\n%s", want, conf)
+		}
+	}
+
+	plain := renderNginxTemplate(nginxTemplate, nginx.StaticLocations(nil, 8000))
+	if strings.Contains(plain, "alias") || !strings.Contains(plain, "error_log  /var/log/nginx/myapp_error.log;\n\n  location / {") {
+		t.Errorf("config without static paths should only proxy:\n%s", plain)
+	}
+
+	static := renderNginxTemplate(nginxStaticTemplate, "")
+	if strings.Contains(static, "/shared/static") {
+		t.Errorf("static site config should not alias shared directories:\n%s", static)
+	}
+
+	// Without an app there is nothing to fall back to
+	if locations := nginx.StaticLocations(StaticPathsFor("Django", "/srv/myapp", nil), 0); strings.Contains(locations, "@app") {
+		t.Errorf("static locations without a port should not fall back:\n%s", locations)
+	}
+}
+
+func TestStaticPermissionCommands(t *testing.T) {
+	paths := []proxy.StaticPath{
+		{URLPrefix: "/assets/", Dir: "/srv/myapp/current/public/assets"},
+		{URLPrefix: "/media/", Dir: "/srv/myapp/shared/media"},
+	}
+	got := staticPermissionCommands("/srv/myapp", paths)
+	want := []string{
+		"if [ -d /srv/myapp/current/public/assets ]; then chmod -R o+rX /srv/myapp/current/public/assets; fi",
+		"mkdir -p /srv/myapp/shared/media",
+		"chown -R deploy:www-data /srv/myapp/shared/media",
+		"chmod -R g+rX /srv/myapp/shared/media",
+		"find /srv/myapp/shared/media -type d -exec chmod g+s {} +",
+		"if [ -d /srv ]; then chmod o+x /srv; fi",
+		"if [ -d /srv/myapp ]; then chmod o+x /srv/myapp; fi",
+		"if [ -d /srv/myapp/current ]; then chmod o+x /srv/myapp/current; fi",
+		"if [ -d /srv/myapp/current/public ]; then chmod o+x /srv/myapp/current/public; fi",
+		"if [ -d /srv/myapp/shared ]; then chmod o+x /srv/myapp/shared; fi",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("staticPermissionCommands() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if cmds := staticPermissionCommands("/srv/myapp", nil); len(cmds) != 0 {
+		t.Errorf("no static paths produced commands: %v", cmds)
+	}
+}
+
+func TestDjangoCollectstaticTargetsSharedStatic(t *testing.T) {
+	detection := &detector.Detection{
+		Framework: "Django",
+		Language:  "Python",
+		Meta:      map[string]string{"package_manager": "poetry"},
+	}
+	exec := NewExecutor(nil, "myapp", "/path", detection)
+
+	got := exec.adjustBuildCommand("poetry run python manage.py collectstatic --noinput", "")
+	if !strings.Contains(got, "STATIC_ROOT=/srv/myapp/shared/static poetry run python manage.py collectstatic") {
+		t.Errorf("adjustBuildCommand() = %q, want collectstatic into shared/static", got)
+	}
+
+	wantEnv := "\nEnvironment=MEDIA_ROOT=/srv/myapp/shared/media\nEnvironment=STATIC_ROOT=/srv/myapp/shared/static"
+	if got := exec.staticEnvironmentLines(); got != wantEnv {
+		t.Errorf("staticEnvironmentLines() = %q, want %q", got, wantEnv)
+	}
+
+	exec.SetStaticPathOptions(&config.DeploymentOptions{DisableStaticPaths: true})
+	if got := exec.adjustBuildCommand("python manage.py collectstatic --noinput", ""); strings.Contains(got, "STATIC_ROOT") {
+		t.Errorf("disabled static paths still set STATIC_ROOT: %q", got)
+	}
+}
+
+func TestStaticGeneratorServedByNginx(t *testing.T) {
+	detection := detector.DetectFrameworkFS(fstest.MapFS{
+		"gatsby-config.js":   {Data: []byte("module.exports = {}")},
+		"package.json":       {Data: []byte(`{"dependencies": {"gatsby": "^5.0.0"}}`)},
+		"src/pages/index.js": {Data: []byte("export default function Home() {}")},
+	})
+	if detection.Framework != "Gatsby" {
+		t.Fatalf("Framework = %s, want Gatsby", detection.Framework)
+	}
+
+	// No SSH connection: creating a unit would fail, so nil means it was skipped
+	executor := NewExecutor(nil, "myapp", "/tmp/myapp", &detection)
+	if err := executor.GenerateSystemdUnitWithPort("/srv/myapp/releases/1", 3000); err != nil {
+		t.Errorf("GenerateSystemdUnitWithPort() error = %v, want no unit for a static site", err)
+	}
+
+	template, data := executor.nginxTemplateData(3000, "")
+	if template != nginxStaticTemplate {
+		t.Fatal("Gatsby should use the static nginx template")
+	}
+	conf := template
+	for key, value := range data {
+		conf = strings.ReplaceAll(conf, "{{"+key+"}}", value)
+	}
+	if !strings.Contains(conf, "root /srv/myapp/current/public/;") {
+		t.Errorf("nginx config does not serve public/:\n%s", conf)
+	}
+	if strings.Contains(conf, "proxy_pass") {
+		t.Errorf("static nginx config should not proxy to an app server:\n%s", conf)
+	}
+}
+
+func TestNginxTemplateData_DomainRedirect(t *testing.T) {
+	executor := NewExecutor(nil, "myapp", "/tmp/myapp", &detector.Detection{Framework: "Django", Language: "Python"})
+	executor.SetDomainRedirect("www.example.com")
+
+	template, data := executor.nginxTemplateData(3000, "example.com")
+	conf := sshpkg.RenderTemplate(template, data)
+	if !strings.HasPrefix(conf, "# Redirect www.example.com to example.com\nserver {") ||
+		!strings.Contains(conf, "server_name www.example.com;\n  return 301 $scheme://example.com$request_uri;") {
+		t.Errorf("expected a redirect server ahead of the site:\n%s", conf)
+	}
+	if strings.Count(conf, "proxy_pass") != 1 || !strings.Contains(conf, "server_name example.com;") {
+		t.Errorf("expected the app proxied for the domain only:\n%s", conf)
+	}
+
+	// Without a domain there is nothing to redirect to
+	template, data = executor.nginxTemplateData(3000, "")
+	if conf := sshpkg.RenderTemplate(template, data); strings.Contains(conf, "return 301") || strings.Contains(conf, "{{REDIRECT_SERVER}}") {
+		t.Errorf("expected no redirect without a domain:\n%s", conf)
+	}
+}
+
+func TestNginxTemplateData_PathRoutes(t *testing.T) {
+	executor := NewExecutor(nil, "myapp", "/tmp/myapp", &detector.Detection{Framework: "Django", Language: "Python"})
+	executor.SetPathRoutes([]proxy.PathRoute{{PathPrefix: "/api", Port: 3001, AppName: "api"}})
+
+	template, data := executor.nginxTemplateData(3000, "example.com")
+	conf := sshpkg.RenderTemplate(template, data)
+	if !strings.Contains(conf, "  location /api/ {\n    proxy_pass http://127.0.0.1:3001/;") {
+		t.Errorf("expected the path route in the regenerated site:\n%s", conf)
+	}
+	if strings.Index(conf, "location /api/") > strings.Index(conf, "location / {") {
+		t.Errorf("path route should come before the catch-all location:\n%s", conf)
+	}
+}
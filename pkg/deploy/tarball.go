@@ -0,0 +1,325 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"lightfold/pkg/config"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// packReadAheadLimit is the largest file workers read into memory ahead of the
+// tar writer; bigger files are streamed by the writer itself
+const packReadAheadLimit = 4 << 20
+
+// secretFilePatterns match file names that usually hold credentials and should
+// not be shipped inside a release
+var secretFilePatterns = []string{
+	".env*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"*.jks",
+	"*.keystore",
+	"*.tfstate",
+	"id_rsa",
+	"id_dsa",
+	"id_ecdsa",
+	"id_ed25519",
+	".netrc",
+	".npmrc",
+	".pypirc",
+	".htpasswd",
+	"credentials.json",
+	"*credentials*.json",
+	"service-account*.json",
+	"secrets.json",
+}
+
+// packEntry is one file or directory of the release tarball
+type packEntry struct {
+	path   string
+	header *tar.Header
+}
+
+// packedFile is the content a worker read for a regular file
+type packedFile struct {
+	data []byte // nil when the file is too large to read ahead
+	sum  [sha256.Size]byte
+	err  error
+}
+
+// SetPackWorkers sets how many files are read in parallel while packing the
+// release; zero or less uses GOMAXPROCS
+func (e *Executor) SetPackWorkers(workers int) {
+	e.packWorkers = workers
+}
+
+// ReleaseDigest returns the content digest of the last tarball created. It
+// covers entry names, modes and file contents but not timestamps, so it is the
+// same for unchanged sources across runs.
+func (e *Executor) ReleaseDigest() string {
+	return e.releaseDigest
+}
+
+// ReleaseSecrets returns the files in the last tarball created that look like
+// they hold credentials, relative to the project root
+func (e *Executor) ReleaseSecrets() []string {
+	return e.releaseSecrets
+}
+
+// CreateReleaseTarball packs the project, or the pruned workspace of a
+// Turborepo app, into a gzipped tarball. Files are read and hashed by a worker
+// pool while a single writer adds them in walk order, so the archive layout
+// and digest do not depend on scheduling.
+func (e *Executor) CreateReleaseTarball(outputPath string) error {
+	root, cleanup := e.packRoot()
+	defer cleanup()
+
+	return e.writeTarball(root, config.DefaultIgnorePatterns, outputPath)
+}
+
+// CreateArtifactTarball packs a prebuilt artifact directory as the release,
+// exactly as it is: no ignore patterns apply and nothing is pruned
+func (e *Executor) CreateArtifactTarball(artifactDir, outputPath string) error {
+	return e.writeTarball(artifactDir, nil, outputPath)
+}
+
+// writeTarball packs root into a gzipped tarball at outputPath, skipping
+// files matching ignorePatterns
+func (e *Executor) writeTarball(root string, ignorePatterns []string, outputPath string) error {
+	entries, err := collectPackEntries(root, ignorePatterns)
+	if err != nil {
+		return err
+	}
+
+	workers := e.packWorkers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	tarFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer tarFile.Close()
+
+	buffered := bufio.NewWriterSize(tarFile, 1<<20)
+	gzipWriter := gzip.NewWriter(buffered)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	done := make(chan struct{})
+	defer close(done)
+	results, release := readPackEntries(entries, workers, done)
+
+	digest := sha256.New()
+	for i, entry := range entries {
+		if err := tarWriter.WriteHeader(entry.header); err != nil {
+			return err
+		}
+
+		var sum [sha256.Size]byte
+		if entry.header.Typeflag == tar.TypeReg {
+			packed := <-results[i]
+			release()
+			if packed.err != nil {
+				return packed.err
+			}
+			sum = packed.sum
+			if packed.data != nil {
+				if _, err := tarWriter.Write(packed.data); err != nil {
+					return err
+				}
+			} else if sum, err = streamPackEntry(tarWriter, entry.path); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(digest, "%s\x00%o\x00%d\x00%s\x00%x\n", entry.header.Name, entry.header.Mode, entry.header.Size, entry.header.Linkname, sum)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	e.releaseDigest = hex.EncodeToString(digest.Sum(nil))
+	e.releaseSecrets = likelySecrets(entries)
+	return nil
+}
+
+// likelySecrets lists the regular files whose names match secretFilePatterns
+func likelySecrets(entries []packEntry) []string {
+	var secrets []string
+	for _, entry := range entries {
+		if entry.header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Base(entry.header.Name)
+		for _, pattern := range secretFilePatterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				secrets = append(secrets, entry.header.Name)
+				break
+			}
+		}
+	}
+	return secrets
+}
+
+// collectPackEntries walks the project in lexical order and returns the
+// entries to archive, skipping ignored files and directories
+func collectPackEntries(projectPath string, ignorePatterns []string) ([]packEntry, error) {
+	var entries []packEntry
+
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		if isPackIgnored(relPath, d.Name(), ignorePatterns) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+
+		entries = append(entries, packEntry{path: path, header: header})
+		return nil
+	})
+
+	return entries, err
+}
+
+func isPackIgnored(relPath, name string, ignorePatterns []string) bool {
+	for _, pattern := range ignorePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if strings.Contains(pattern, "/") {
+			if matched, _ := path.Match(pattern, filepath.ToSlash(relPath)); matched {
+				return true
+			}
+		}
+		if strings.Contains(relPath, "/"+pattern+"/") || strings.HasPrefix(relPath, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// readPackEntries reads and hashes regular files on a worker pool. Each file's
+// result arrives on its own channel; the writer calls release after taking a
+// result so at most a fixed window of files is held in memory.
+func readPackEntries(entries []packEntry, workers int, done <-chan struct{}) ([]chan packedFile, func()) {
+	results := make([]chan packedFile, len(entries))
+	for i := range results {
+		results[i] = make(chan packedFile, 1)
+	}
+
+	window := make(chan struct{}, workers*4)
+	jobs := make(chan int)
+
+	go func() {
+		defer close(jobs)
+		for i, entry := range entries {
+			if entry.header.Typeflag != tar.TypeReg {
+				continue
+			}
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- readPackFile(entries[i])
+			}
+		}()
+	}
+
+	return results, func() { <-window }
+}
+
+func readPackFile(entry packEntry) packedFile {
+	if entry.header.Size > packReadAheadLimit {
+		return packedFile{}
+	}
+
+	data, err := os.ReadFile(entry.path)
+	if err != nil {
+		return packedFile{err: err}
+	}
+	if int64(len(data)) != entry.header.Size {
+		return packedFile{err: fmt.Errorf("%s changed while packing the release", entry.header.Name)}
+	}
+	return packedFile{data: data, sum: sha256.Sum256(data)}
+}
+
+// streamPackEntry copies a large file into the archive while hashing it
+func streamPackEntry(tarWriter *tar.Writer, path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	file, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tarWriter, hash), file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
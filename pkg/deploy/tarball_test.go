@@ -0,0 +1,274 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePackTree(t testing.TB, dir string, files map[string][]byte) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTarball(t *testing.T, path string) ([]string, map[string][]byte) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	var names []string
+	contents := map[string][]byte{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[header.Name] = data
+		}
+	}
+	return names, contents
+}
+
+func TestCreateReleaseTarball_ContentsAndOrder(t *testing.T) {
+	projectDir := t.TempDir()
+	large := bytes.Repeat([]byte("0123456789abcdef"), packReadAheadLimit/16+1024)
+	files := map[string][]byte{
+		"main.go":             []byte("package main"),
+		"src/app.js":          []byte("console.log('hello')"),
+		"src/lib/util.js":     []byte("module.exports = {}"),
+		"assets/video.bin":    large,
+		"node_modules/pkg.js": []byte("ignored"),
+		"web/.git/HEAD":       []byte("ignored"),
+	}
+	writePackTree(t, projectDir, files)
+
+	exec := NewExecutor(nil, "test-app", projectDir, nil)
+	exec.SetPackWorkers(3)
+	tarballPath := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := exec.CreateReleaseTarball(tarballPath); err != nil {
+		t.Fatalf("CreateReleaseTarball() error = %v", err)
+	}
+
+	names, contents := readTarball(t, tarballPath)
+	want := []string{"assets", "assets/video.bin", "main.go", "src", "src/app.js", "src/lib", "src/lib/util.js", "web"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+	for _, name := range []string{"main.go", "src/app.js", "src/lib/util.js", "assets/video.bin"} {
+		if !bytes.Equal(contents[name], files[name]) {
+			t.Errorf("%s content mismatch (%d bytes, want %d)", name, len(contents[name]), len(files[name]))
+		}
+	}
+}
+
+func TestCreateReleaseTarball_KeepsSecretsOut(t *testing.T) {
+	projectDir := t.TempDir()
+	writePackTree(t, projectDir, map[string][]byte{
+		"main.go":                 []byte("package main"),
+		".env.production":         []byte("DATABASE_URL=postgres://prod\nSECRET_KEY=abc\n"),
+		".env.development":        []byte("DEBUG=1\n"),
+		"secrets/gcp.json":        []byte("{}"),
+		"config/master.key":       []byte("deadbeef"),
+		"deploy/service-acct.pem": []byte("-----BEGIN"),
+	})
+
+	exec := NewExecutor(nil, "test-app", projectDir, nil)
+	tarballPath := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := exec.CreateReleaseTarball(tarballPath); err != nil {
+		t.Fatalf("CreateReleaseTarball() error = %v", err)
+	}
+
+	_, contents := readTarball(t, tarballPath)
+	for _, name := range []string{".env.production", ".env.development", "secrets/gcp.json"} {
+		if _, ok := contents[name]; ok {
+			t.Errorf("tarball contains %s", name)
+		}
+	}
+
+	wantSecrets := []string{"config/master.key", "deploy/service-acct.pem"}
+	if got := exec.ReleaseSecrets(); strings.Join(got, ",") != strings.Join(wantSecrets, ",") {
+		t.Errorf("ReleaseSecrets() = %v, want %v", got, wantSecrets)
+	}
+
+	env := exec.ReleaseEnvironment(map[string]string{"SECRET_KEY": "override"})
+	if env["DATABASE_URL"] != "postgres://prod" || env["SECRET_KEY"] != "override" {
+		t.Errorf("ReleaseEnvironment() = %v, want .env.production values with explicit overrides", env)
+	}
+}
+
+func TestReleasePermissionCommands(t *testing.T) {
+	commands := strings.Join(releasePermissionCommands("/srv/app/releases/1"), "\n")
+	for _, want := range []string{"chmod 750 /srv/app/releases/1", "chmod -R o-rwx /srv/app/releases/1"} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("release permission commands missing %q:\n%s", want, commands)
+		}
+	}
+}
+
+func TestCreateReleaseTarball_DigestIsDeterministic(t *testing.T) {
+	projectDir := t.TempDir()
+	files := map[string][]byte{}
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("pkg%d/file%d.txt", i%7, i)] = []byte(strings.Repeat(fmt.Sprint(i), i+1))
+	}
+	writePackTree(t, projectDir, files)
+
+	digests := map[string]bool{}
+	for _, workers := range []int{1, 4, 16, 0} {
+		exec := NewExecutor(nil, "test-app", projectDir, nil)
+		exec.SetPackWorkers(workers)
+		if err := exec.CreateReleaseTarball(filepath.Join(t.TempDir(), "release.tar.gz")); err != nil {
+			t.Fatalf("CreateReleaseTarball(workers=%d) error = %v", workers, err)
+		}
+		if exec.ReleaseDigest() == "" {
+			t.Fatalf("ReleaseDigest() empty for workers=%d", workers)
+		}
+		digests[exec.ReleaseDigest()] = true
+	}
+	if len(digests) != 1 {
+		t.Errorf("digest differs across worker counts: %v", digests)
+	}
+
+	writePackTree(t, projectDir, map[string][]byte{"pkg0/file0.txt": []byte("changed")})
+	exec := NewExecutor(nil, "test-app", projectDir, nil)
+	if err := exec.CreateReleaseTarball(filepath.Join(t.TempDir(), "release.tar.gz")); err != nil {
+		t.Fatalf("CreateReleaseTarball() error = %v", err)
+	}
+	if digests[exec.ReleaseDigest()] {
+		t.Error("digest did not change after editing a file")
+	}
+}
+
+func TestCreateReleaseTarball_MissingProject(t *testing.T) {
+	exec := NewExecutor(nil, "test-app", filepath.Join(t.TempDir(), "missing"), nil)
+	if err := exec.CreateReleaseTarball(filepath.Join(t.TempDir(), "release.tar.gz")); err == nil {
+		t.Error("expected error for missing project directory")
+	}
+}
+
+// createReleaseTarballSequential is the single-threaded packer the worker pool
+// replaced, kept for the benchmark comparison
+func createReleaseTarballSequential(projectPath, outputPath string) error {
+	tarFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close()
+
+	gzipWriter := gzip.NewWriter(tarFile)
+	defer gzipWriter.Close()
+
+	tarWriter := tar.NewWriter(gzipWriter)
+	defer tarWriter.Close()
+
+	return filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if isPackIgnored(relPath, d.Name(), config.DefaultIgnorePatterns) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(tarWriter, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func benchmarkPackTree(b *testing.B) string {
+	b.Helper()
+	projectDir := b.TempDir()
+	files := map[string][]byte{}
+	for i := 0; i < 2000; i++ {
+		content := bytes.Repeat([]byte(fmt.Sprintf("line %d of a synthetic source file\n", i)), 64+i%256)
+		files[fmt.Sprintf("src/module%d/file%d.js", i%50, i)] = content
+	}
+	writePackTree(b, projectDir, files)
+	return projectDir
+}
+
+func BenchmarkCreateReleaseTarball(b *testing.B) {
+	projectDir := benchmarkPackTree(b)
+	outputPath := filepath.Join(b.TempDir(), "release.tar.gz")
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := createReleaseTarballSequential(projectDir, outputPath); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		exec := NewExecutor(nil, "bench-app", projectDir, nil)
+		for i := 0; i < b.N; i++ {
+			if err := exec.CreateReleaseTarball(outputPath); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package deploy
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/state"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultWorkers sizes the WSGI/ASGI worker count for a server: the usual
+// 2×CPU+1, capped so every worker fits in memory. Unknown resources fall back
+// to config.DefaultWorkerCount.
+func DefaultWorkers(cpuCount, memoryMB int) int {
+	if cpuCount <= 0 {
+		return config.DefaultWorkerCount
+	}
+
+	workers := 2*cpuCount + 1
+	if memoryMB > 0 {
+		if fit := memoryMB / config.WorkerMemoryMB; fit < workers {
+			workers = fit
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// SetProcessTuning sets the worker, thread and recycling numbers templated
+// into the start command. Values in opts win over the ones derived from the
+// server's resources.
+func (e *Executor) SetProcessTuning(cpuCount, memoryMB int, opts *config.DeploymentOptions) {
+	e.workers = DefaultWorkers(cpuCount, memoryMB)
+	e.threads = 0
+	e.maxRequests = 0
+	e.memoryMB = memoryMB
+
+	if opts != nil {
+		if opts.Workers > 0 {
+			e.workers = opts.Workers
+		}
+		e.threads = opts.Threads
+		e.maxRequests = opts.MaxRequests
+	}
+}
+
+// ApplyServerTuning loads the server's CPU count and memory from server state,
+// asking the server once when they have not been recorded yet, and sets the
+// process tuning from them and opts
+func (e *Executor) ApplyServerTuning(serverIP string, opts *config.DeploymentOptions) {
+	var cpuCount, memoryMB int
+	e.serverApps = 1
+	if serverState, err := state.GetServerState(serverIP); err == nil {
+		cpuCount, memoryMB = serverState.CPUCount, serverState.MemoryMB
+		e.serverApps = serverAppCount(serverState, e.appName)
+	}
+
+	if cpuCount == 0 && e.ssh != nil {
+		if cpus, memory, err := e.DetectServerResources(); err == nil {
+			cpuCount, memoryMB = cpus, memory
+			if err := state.UpdateServerResources(serverIP, cpus, memory); err != nil {
+				e.notify(fmt.Sprintf("Warning: failed to record server resources: %v", err))
+			}
+		}
+	}
+
+	e.SetProcessTuning(cpuCount, memoryMB, opts)
+}
+
+// serverAppCount counts the apps sharing the server, including appName when it
+// is not deployed yet
+func serverAppCount(serverState *state.ServerState, appName string) int {
+	count := len(serverState.DeployedApps)
+	for _, app := range serverState.DeployedApps {
+		if app.AppName == appName {
+			return count
+		}
+	}
+	return count + 1
+}
+
+// nodeHeapMB is the V8 heap limit for a Node server: three quarters of the
+// memory, split evenly between the apps on the server so they cannot grow
+// past it together
+func (e *Executor) nodeHeapMB() int {
+	apps := e.serverApps
+	if apps < 1 {
+		apps = 1
+	}
+	return e.memoryMB * 3 / 4 / apps
+}
+
+// DetectServerResources returns the server's vCPU count and total memory in MB
+func (e *Executor) DetectServerResources() (int, int, error) {
+	result := e.ssh.Execute("nproc && awk '/^MemTotal:/ {print int($2/1024)}' /proc/meminfo")
+	if result.Error != nil || result.ExitCode != 0 {
+		return 0, 0, formatSSHError("failed to read server resources", result)
+	}
+
+	fields := strings.Fields(result.Stdout)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected server resources output: %q", result.Stdout)
+	}
+
+	cpuCount, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid CPU count %q", fields[0])
+	}
+	memoryMB, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid memory size %q", fields[1])
+	}
+	return cpuCount, memoryMB, nil
+}
+
+// workerCount is the worker count for generated Python start commands
+func (e *Executor) workerCount() int {
+	if e.workers > 0 {
+		return e.workers
+	}
+	return config.DefaultWorkerCount
+}
+
+var (
+	gunicornCommand = regexp.MustCompile(`(^|[\s/])gunicorn\s`)
+	uvicornCommand  = regexp.MustCompile(`(^|[\s/])uvicorn\s`)
+)
+
+// tuneStartCommand templates the worker settings into a gunicorn or uvicorn
+// command. Generated commands have their flags replaced; commands the user
+// wrote only gain the flags they leave out.
+func (e *Executor) tuneStartCommand(command string, userSupplied bool) string {
+	if e.workers == 0 {
+		return command
+	}
+
+	switch {
+	case gunicornCommand.MatchString(command):
+		command = setCommandFlag(command, []string{"--workers", "-w"}, e.workers, !userSupplied)
+		command = setCommandFlag(command, []string{"--threads"}, e.threads, !userSupplied)
+		command = setCommandFlag(command, []string{"--max-requests"}, e.maxRequests, !userSupplied)
+	case uvicornCommand.MatchString(command):
+		command = setCommandFlag(command, []string{"--workers"}, e.workers, !userSupplied)
+		command = setCommandFlag(command, []string{"--limit-max-requests"}, e.maxRequests, !userSupplied)
+	}
+	return command
+}
+
+// setCommandFlag sets a numeric flag on a command line. An existing flag
+// (under any of its names) is overwritten only when replace is set; a zero
+// value leaves the command as is.
+func setCommandFlag(command string, names []string, value int, replace bool) string {
+	if value <= 0 {
+		return command
+	}
+
+	for _, name := range names {
+		flag := regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(name) + `(=|\s+)\S+`)
+		if flag.MatchString(command) {
+			if !replace {
+				return command
+			}
+			return flag.ReplaceAllString(command, fmt.Sprintf("${1}%s${2}%d", name, value))
+		}
+	}
+
+	return fmt.Sprintf("%s %s %d", command, names[0], value)
+}
+
+// tuningEnvironment renders the Environment= lines that carry the tuning to
+// Node servers. Values from the app's .env file still take precedence because
+// systemd applies EnvironmentFile= after Environment=.
+func (e *Executor) tuningEnvironment() string {
+	if e.detection == nil || e.detection.Language != "JavaScript/TypeScript" {
+		return ""
+	}
+
+	var lines []string
+	if e.memoryMB > 0 {
+		lines = append(lines, fmt.Sprintf("\nEnvironment=NODE_OPTIONS=--max-old-space-size=%d", e.nodeHeapMB()))
+	}
+	if e.threads > 0 {
+		lines = append(lines, fmt.Sprintf("\nEnvironment=UV_THREADPOOL_SIZE=%d", e.threads))
+	}
+	return strings.Join(lines, "")
+}
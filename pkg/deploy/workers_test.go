@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"lightfold/pkg/config"
+	"lightfold/pkg/detector"
+	"testing"
+)
+
+func TestDefaultWorkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		cpus     int
+		memoryMB int
+		want     int
+	}{
+		{"unknown resources", 0, 0, config.DefaultWorkerCount},
+		{"4 vCPU 8GB", 4, 8192, 9},
+		{"1 vCPU 512MB capped by memory", 1, 512, 3},
+		{"1 vCPU 256MB", 1, 256, 2},
+		{"tiny server keeps one worker", 1, 64, 1},
+		{"cpu only", 2, 0, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultWorkers(tt.cpus, tt.memoryMB); got != tt.want {
+				t.Errorf("DefaultWorkers(%d, %d) = %d, want %d", tt.cpus, tt.memoryMB, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetExecStartCommand_ProcessTuning(t *testing.T) {
+	tests := []struct {
+		name      string
+		framework string
+		runPlan   []string
+		userRun   []string
+		opts      *config.DeploymentOptions
+		want      string
+	}{
+		{
+			name:      "django fallback sized from server",
+			framework: "Django",
+			want:      "/srv/test-app/shared/venv/bin/gunicorn --bind 127.0.0.1:$PORT --workers 9 wsgi:application",
+		},
+		{
+			name:      "fastapi fallback with recycling",
+			framework: "FastAPI",
+			opts:      &config.DeploymentOptions{MaxRequests: 1000},
+			want:      "/srv/test-app/shared/venv/bin/uvicorn main:app --host 127.0.0.1 --port $PORT --workers 9 --limit-max-requests 1000",
+		},
+		{
+			name:      "detected gunicorn flags replaced",
+			framework: "Flask",
+			runPlan:   []string{"gunicorn --bind 0.0.0.0:$PORT --workers 2 app:app"},
+			opts:      &config.DeploymentOptions{Workers: 3, Threads: 4},
+			want:      "/srv/test-app/shared/venv/bin/gunicorn --bind 127.0.0.1:$PORT --workers 3 app:app --threads 4",
+		},
+		{
+			name:      "user command keeps its own workers",
+			framework: "Flask",
+			userRun:   []string{"gunicorn -w 6 app:app"},
+			opts:      &config.DeploymentOptions{Threads: 2},
+			want:      "/srv/test-app/shared/venv/bin/gunicorn -w 6 app:app --threads 2",
+		},
+		{
+			name:      "uvicorn worker class is gunicorn",
+			framework: "FastAPI",
+			runPlan:   []string{"gunicorn -k uvicorn.workers.UvicornWorker main:app --workers=2"},
+			opts:      &config.DeploymentOptions{Workers: 5},
+			want:      "/srv/test-app/shared/venv/bin/gunicorn -k uvicorn.workers.UvicornWorker main:app --workers=5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection := &detector.Detection{Framework: tt.framework, Language: "Python", RunPlan: tt.runPlan}
+			var exec *Executor
+			if tt.userRun != nil {
+				exec = NewExecutorWithOptions(nil, "test-app", "/path", detection, &config.DeploymentOptions{RunCommands: tt.userRun})
+			} else {
+				exec = NewExecutor(nil, "test-app", "/path", detection)
+			}
+			exec.SetProcessTuning(4, 8192, tt.opts)
+
+			if got := exec.getExecStartCommand(); got != tt.want {
+				t.Errorf("getExecStartCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTuningEnvironment(t *testing.T) {
+	node := NewExecutor(nil, "test-app", "/path", &detector.Detection{Framework: "Next.js", Language: "JavaScript/TypeScript"})
+	node.SetProcessTuning(2, 1024, &config.DeploymentOptions{Threads: 8})
+
+	want := "\nEnvironment=NODE_OPTIONS=--max-old-space-size=768\nEnvironment=UV_THREADPOOL_SIZE=8"
+	if got := node.tuningEnvironment(); got != want {
+		t.Errorf("tuningEnvironment() = %q, want %q", got, want)
+	}
+
+	// Three apps on the server share the heap budget
+	node.serverApps = 3
+	want = "\nEnvironment=NODE_OPTIONS=--max-old-space-size=256\nEnvironment=UV_THREADPOOL_SIZE=8"
+	if got := node.tuningEnvironment(); got != want {
+		t.Errorf("tuningEnvironment() on a shared server = %q, want %q", got, want)
+	}
+
+	python := NewExecutor(nil, "test-app", "/path", &detector.Detection{Framework: "Django", Language: "Python"})
+	python.SetProcessTuning(2, 1024, nil)
+	if got := python.tuningEnvironment(); got != "" {
+		t.Errorf("tuningEnvironment() for Python = %q, want empty", got)
+	}
+}
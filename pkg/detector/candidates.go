@@ -0,0 +1,122 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"lightfold/pkg/detector/detectors"
+)
+
+// DefaultAmbiguityMargin is how close, in score points, the runner-up must be
+// to the top framework for detection to count as ambiguous
+const DefaultAmbiguityMargin = 1.0
+
+// overrideSignal is the signal a framework chosen by override reports first
+const overrideSignal = "framework set by override"
+
+// normalizeFramework reduces a framework name to the form overrides are
+// matched in: lowercase letters and digits without a trailing "js", so
+// "Next.js", "nextjs" and "next" all match
+func normalizeFramework(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+	if trimmed := strings.TrimSuffix(normalized, "js"); trimmed != "" {
+		return trimmed
+	}
+	return normalized
+}
+
+// LookupFramework returns the name detection knows framework by, e.g.
+// "django" -> "Django", "nextjs" -> "Next.js"
+func LookupFramework(framework string) (string, error) {
+	want := normalizeFramework(framework)
+	for _, known := range detectors.Frameworks {
+		if normalizeFramework(known.Name) == want {
+			return known.Name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown framework %q. Known frameworks: %s", framework, strings.Join(FrameworkNames(), ", "))
+}
+
+// FrameworkNames lists the frameworks detection can pick
+func FrameworkNames() []string {
+	names := make([]string, len(detectors.Frameworks))
+	for i, known := range detectors.Frameworks {
+		names[i] = known.Name
+	}
+	return names
+}
+
+// forcedCandidate returns the candidate for framework with the signals the
+// project showed for it, or its plan with none when it showed none
+func forcedCandidate(ranked []Candidate, framework, dominantLang string) (Candidate, bool) {
+	want := normalizeFramework(framework)
+	for _, c := range ranked {
+		if normalizeFramework(c.Name) == want {
+			c.Signals = append([]string{overrideSignal}, c.Signals...)
+			return c, true
+		}
+	}
+	for _, known := range detectors.Frameworks {
+		if normalizeFramework(known.Name) == want {
+			if known.Language == "" {
+				known.Language = dominantLang
+			}
+			known.Signals = []string{overrideSignal}
+			return known, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// frameworkCandidates converts ranked candidates for Detection.Candidates
+func frameworkCandidates(ranked []Candidate) []FrameworkCandidate {
+	if len(ranked) == 0 {
+		return nil
+	}
+	out := make([]FrameworkCandidate, len(ranked))
+	for i, c := range ranked {
+		out[i] = FrameworkCandidate{
+			Framework:  c.Name,
+			Language:   c.Language,
+			Score:      c.Score,
+			Confidence: clamp(c.Score/6.0, 0, 1),
+			Signals:    c.Signals,
+		}
+	}
+	return out
+}
+
+// AmbiguousCandidates returns the candidates scoring within margin of the top
+// one when there are at least two, and nil when the pick is clear or was made
+// by override. Docker candidates never count: a compose file or Dockerfile is
+// an explicit choice of how to build, not a competing guess.
+func (d Detection) AmbiguousCandidates(margin float64) []FrameworkCandidate {
+	if d.Overridden || len(d.Candidates) == 0 || isDockerFramework(d.Candidates[0].Framework) {
+		return nil
+	}
+	var near []FrameworkCandidate
+	for _, c := range d.Candidates {
+		if isDockerFramework(c.Framework) {
+			continue
+		}
+		if len(near) > 0 && near[0].Score-c.Score > margin {
+			break
+		}
+		near = append(near, c)
+	}
+	if len(near) < 2 {
+		return nil
+	}
+	return near
+}
+
+func isDockerFramework(name string) bool {
+	return name == "Docker Compose" || name == "Generic Docker"
+}
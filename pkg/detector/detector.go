@@ -12,6 +12,13 @@ import (
 
 // DetectFrameworkFS detects the framework from a filesystem abstraction
 func DetectFrameworkFS(fsys fs.FS) Detection {
+	return detectFrameworkFS(fsys, "")
+}
+
+// detectFrameworkFS detects the framework, or when framework names one,
+// skips the heuristic pick and runs that framework's plan. An unknown
+// framework falls back to the heuristic pick.
+func detectFrameworkFS(fsys fs.FS, framework string) Detection {
 	reader := NewFSReader(fsys)
 
 	allFiles, extCounts, err := reader.ScanTree()
@@ -33,7 +40,14 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 	cands = append(cands, detectors.DetectCSharp(reader, allFiles)...)
 	cands = append(cands, detectors.DetectDocker(reader, dominantLanguage(extCounts))...)
 
-	if len(cands) == 0 {
+	ranked := rankCandidates(cands)
+	var best Candidate
+	overridden := false
+	if framework != "" {
+		best, overridden = forcedCandidate(ranked, framework, dominantLanguage(extCounts))
+	}
+
+	if len(cands) == 0 && !overridden {
 		lang := dominantLanguage(extCounts)
 		meta := map[string]string{"note": "Fell back to generic. Provide custom commands."}
 
@@ -45,6 +59,7 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 		for k, v := range monorepoMeta {
 			meta[k] = v
 		}
+		detectLibrary(reader, meta)
 
 		out := Detection{
 			Framework:   "Unknown",
@@ -60,7 +75,9 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 		return out
 	}
 
-	best := pickBest(cands)
+	if !overridden {
+		best = ranked[0]
+	}
 
 	// Call the plan function - it takes the plans.FSReader interface
 	var build, run []string
@@ -81,6 +98,8 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 			Healthcheck: map[string]any{"path": "/", "expect": 200, "timeout_seconds": 30},
 			EnvSchema:   []string{},
 			Meta:        map[string]string{},
+			Candidates:  frameworkCandidates(ranked),
+			Overridden:  overridden,
 		}
 	}
 
@@ -88,6 +107,11 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 		meta["runtime_version"] = runtimeVersion
 	}
 
+	if best.Language == "JavaScript/TypeScript" {
+		detectPackageManagerPin(reader, meta)
+		detectLibrary(reader, meta)
+	}
+
 	monorepoMeta := detectMonorepo(reader)
 	for k, v := range monorepoMeta {
 		meta[k] = v
@@ -103,6 +127,8 @@ func DetectFrameworkFS(fsys fs.FS) Detection {
 		Healthcheck: health,
 		EnvSchema:   env,
 		Meta:        meta,
+		Candidates:  frameworkCandidates(ranked),
+		Overridden:  overridden,
 	}
 	return out
 }
@@ -112,6 +138,12 @@ func DetectFramework(root string) Detection {
 	return DetectFrameworkFS(os.DirFS(root))
 }
 
+// DetectFrameworkAs detects the project at root as framework, or by score
+// when framework is empty
+func DetectFrameworkAs(root, framework string) Detection {
+	return detectFrameworkFS(os.DirFS(root), framework)
+}
+
 func DetectAndPrint(root string) {
 	detection := DetectFramework(root)
 	emitJSON(detection)
@@ -1,6 +1,7 @@
 package detector
 
 import (
+	"strings"
 	"testing"
 	"testing/fstest"
 )
@@ -161,7 +162,7 @@ func TestDetectFrameworkFS_JSPackageManagerPriority(t *testing.T) {
 				"yarn.lock":      {Data: []byte(""), Mode: 0o644},
 			},
 			wantPM:      "bun",
-			wantInstall: "bun install",
+			wantInstall: "bun install --frozen-lockfile",
 		},
 		{
 			name: "yarn berry preference",
@@ -179,7 +180,7 @@ func TestDetectFrameworkFS_JSPackageManagerPriority(t *testing.T) {
 				"yarn.lock":      {Data: []byte(""), Mode: 0o644},
 			},
 			wantPM:      "pnpm",
-			wantInstall: "pnpm install",
+			wantInstall: "pnpm install --frozen-lockfile",
 		},
 		{
 			name: "yarn classic fallback",
@@ -187,7 +188,24 @@ func TestDetectFrameworkFS_JSPackageManagerPriority(t *testing.T) {
 				"yarn.lock": {Data: []byte(""), Mode: 0o644},
 			},
 			wantPM:      "yarn",
-			wantInstall: "yarn install",
+			wantInstall: "yarn install --frozen-lockfile",
+		},
+		{
+			name: "yarn berry with lockfile",
+			extraFiles: map[string]*fstest.MapFile{
+				".yarnrc.yml": {Data: []byte("nodeLinker: node-modules"), Mode: 0o644},
+				"yarn.lock":   {Data: []byte(""), Mode: 0o644},
+			},
+			wantPM:      "yarn-berry",
+			wantInstall: "yarn install --immutable",
+		},
+		{
+			name: "npm with package-lock",
+			extraFiles: map[string]*fstest.MapFile{
+				"package-lock.json": {Data: []byte("{}"), Mode: 0o644},
+			},
+			wantPM:      "npm",
+			wantInstall: "npm ci",
 		},
 		{
 			name:        "npm default",
@@ -223,3 +241,185 @@ func TestDetectFrameworkFS_JSPackageManagerPriority(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectFrameworkFS_MigrationsRunOutsideTheBuild(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{
+			name: "Rails",
+			fsys: fstest.MapFS{
+				"Gemfile":               {Data: []byte("gem 'rails'\n")},
+				"Gemfile.lock":          {Data: []byte("rails (7.1.0)\n")},
+				"config/routes.rb":      {Data: []byte("")},
+				"config/application.rb": {Data: []byte("")},
+				"bin/rails":             {Data: []byte("")},
+			},
+			want: "bundle exec rails db:migrate",
+		},
+		{
+			name: "Laravel",
+			fsys: fstest.MapFS{
+				"artisan":       {Data: []byte("")},
+				"composer.json": {Data: []byte(`{"require": {"laravel/framework": "^10.0"}}`)},
+				"composer.lock": {Data: []byte("{}")},
+			},
+			want: "php artisan migrate --force",
+		},
+		{
+			name: "Django",
+			fsys: fstest.MapFS{
+				"manage.py":        {Data: []byte("import django\n")},
+				"requirements.txt": {Data: []byte("django==5.0\n")},
+			},
+			want: "python manage.py migrate --noinput",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection := DetectFrameworkFS(tt.fsys)
+			if detection.Framework != tt.name {
+				t.Fatalf("detected %s, want %s", detection.Framework, tt.name)
+			}
+			if got := detection.Meta["migration_command"]; got != tt.want {
+				t.Errorf("migration_command = %q, want %q", got, tt.want)
+			}
+			for _, step := range detection.BuildPlan {
+				if strings.Contains(step, "migrate") {
+					t.Errorf("build plan still migrates: %q", step)
+				}
+			}
+		})
+	}
+}
+
+// mixedFixture is a Django backend with a Next.js frontend at the same root
+func mixedFixture() fstest.MapFS {
+	return fstest.MapFS{
+		"manage.py":        {Data: []byte("#!/usr/bin/env python\n"), Mode: 0o644},
+		"requirements.txt": {Data: []byte("django==5.0\ngunicorn\n"), Mode: 0o644},
+		"package.json": {
+			Data: []byte(`{"scripts": {"build": "next build"}, "dependencies": {"next": "14.0.0"}}`),
+			Mode: 0o644,
+		},
+		"next.config.js": {Data: []byte("module.exports = {}\n"), Mode: 0o644},
+	}
+}
+
+func TestDetectFrameworkFS_RanksCandidates(t *testing.T) {
+	detection := DetectFrameworkFS(mixedFixture())
+
+	if detection.Framework != "Django" {
+		t.Fatalf("expected Django to score highest, got %s", detection.Framework)
+	}
+	if len(detection.Candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", detection.Candidates)
+	}
+	if detection.Candidates[0].Framework != "Django" || detection.Candidates[1].Framework != "Next.js" {
+		t.Errorf("candidates ranked %s, %s; want Django, Next.js", detection.Candidates[0].Framework, detection.Candidates[1].Framework)
+	}
+	if detection.Candidates[0].Score < detection.Candidates[1].Score {
+		t.Errorf("candidates not ranked by score: %+v", detection.Candidates)
+	}
+	if len(detection.Candidates[1].Signals) == 0 {
+		t.Error("runner-up should keep its signals")
+	}
+
+	ambiguous := detection.AmbiguousCandidates(DefaultAmbiguityMargin)
+	if len(ambiguous) != 2 {
+		t.Errorf("AmbiguousCandidates(%v) = %+v, want both candidates", DefaultAmbiguityMargin, ambiguous)
+	}
+	if got := detection.AmbiguousCandidates(0.5); got != nil {
+		t.Errorf("AmbiguousCandidates(0.5) = %+v, want nil", got)
+	}
+}
+
+func TestDetectFrameworkFS_ClearWinnerIsNotAmbiguous(t *testing.T) {
+	fsys := mixedFixture()
+	delete(fsys, "next.config.js")
+	delete(fsys, "package.json")
+
+	detection := DetectFrameworkFS(fsys)
+	if got := detection.AmbiguousCandidates(DefaultAmbiguityMargin); got != nil {
+		t.Errorf("AmbiguousCandidates() = %+v for a plain Django project, want nil", got)
+	}
+}
+
+func TestAmbiguousCandidates_IgnoresDocker(t *testing.T) {
+	composePicked := Detection{Framework: "Docker Compose", Candidates: []FrameworkCandidate{
+		{Framework: "Docker Compose", Score: 5},
+		{Framework: "Next.js", Score: 4.5},
+		{Framework: "Remix", Score: 4.5},
+	}}
+	if got := composePicked.AmbiguousCandidates(DefaultAmbiguityMargin); got != nil {
+		t.Errorf("AmbiguousCandidates() = %+v with Docker Compose picked, want nil", got)
+	}
+
+	dockerfileNearby := Detection{Framework: "Go", Candidates: []FrameworkCandidate{
+		{Framework: "Go", Score: 4.5},
+		{Framework: "Generic Docker", Score: 4},
+	}}
+	if got := dockerfileNearby.AmbiguousCandidates(DefaultAmbiguityMargin); got != nil {
+		t.Errorf("AmbiguousCandidates() = %+v with a Dockerfile runner-up, want nil", got)
+	}
+}
+
+func TestDetectFrameworkAs(t *testing.T) {
+	tests := []struct {
+		name      string
+		framework string
+		want      string
+		signal    string
+	}{
+		{"runner-up by flag name", "nextjs", "Next.js", "package.json has next"},
+		{"case-insensitive", "DJANGO", "Django", "manage.py"},
+		{"framework without signals still runs its plan", "flask", "Flask", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detection := detectFrameworkFS(mixedFixture(), tt.framework)
+			if detection.Framework != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, detection.Framework)
+			}
+			if !detection.Overridden {
+				t.Error("expected Overridden to be set")
+			}
+			if detection.Signals[0] != overrideSignal {
+				t.Errorf("first signal = %q, want %q", detection.Signals[0], overrideSignal)
+			}
+			if tt.signal != "" && !strings.Contains(strings.Join(detection.Signals, ","), tt.signal) {
+				t.Errorf("signals %v missing %q", detection.Signals, tt.signal)
+			}
+			if len(detection.BuildPlan) == 0 || len(detection.RunPlan) == 0 {
+				t.Errorf("expected %s's plan to run, got build %v run %v", tt.want, detection.BuildPlan, detection.RunPlan)
+			}
+			if detection.AmbiguousCandidates(DefaultAmbiguityMargin) != nil {
+				t.Error("an overridden detection should never be ambiguous")
+			}
+		})
+	}
+}
+
+func TestLookupFramework(t *testing.T) {
+	for input, want := range map[string]string{
+		"django":       "Django",
+		"Next.js":      "Next.js",
+		"next":         "Next.js",
+		"express":      "Express.js",
+		"spring-boot":  "Spring Boot",
+		"asp.net-core": "ASP.NET Core",
+		"nestjs":       "NestJS",
+	} {
+		got, err := LookupFramework(input)
+		if err != nil || got != want {
+			t.Errorf("LookupFramework(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	if _, err := LookupFramework("cobol"); err == nil || !strings.Contains(err.Error(), "Django") {
+		t.Errorf("LookupFramework(cobol) error = %v, want one listing known frameworks", err)
+	}
+}
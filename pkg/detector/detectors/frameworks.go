@@ -0,0 +1,45 @@
+package detectors
+
+import (
+	"lightfold/pkg/detector/plans"
+)
+
+// Frameworks lists every framework the detectors can pick, with the plan each
+// one runs. A framework chosen by override runs its plan even when the project
+// shows none of its signals. Generic Docker takes the project's dominant
+// language.
+var Frameworks = []Candidate{
+	{Name: "Django", Language: "Python", Plan: plans.DjangoPlan},
+	{Name: "Flask", Language: "Python", Plan: plans.FlaskPlan},
+	{Name: "FastAPI", Language: "Python", Plan: plans.FastAPIPlan},
+	{Name: "Next.js", Language: "JavaScript/TypeScript", Plan: plans.NextPlan},
+	{Name: "Remix", Language: "JavaScript/TypeScript", Plan: plans.RemixPlan},
+	{Name: "Nuxt.js", Language: "JavaScript/TypeScript", Plan: plans.NuxtPlan},
+	{Name: "Astro", Language: "JavaScript/TypeScript", Plan: plans.AstroPlan},
+	{Name: "Gatsby", Language: "JavaScript/TypeScript", Plan: plans.GatsbyPlan},
+	{Name: "Svelte", Language: "JavaScript/TypeScript", Plan: plans.SveltePlan},
+	{Name: "Vue.js", Language: "JavaScript/TypeScript", Plan: plans.VuePlan},
+	{Name: "Angular", Language: "TypeScript", Plan: plans.AngularPlan},
+	{Name: "NestJS", Language: "TypeScript", Plan: plans.NestJSPlan},
+	{Name: "tRPC", Language: "TypeScript", Plan: plans.TRPCPlan},
+	{Name: "Eleventy", Language: "JavaScript/TypeScript", Plan: plans.EleventyPlan},
+	{Name: "Docusaurus", Language: "JavaScript/TypeScript", Plan: plans.DocusaurusPlan},
+	{Name: "Fastify", Language: "JavaScript/TypeScript", Plan: plans.FastifyPlan},
+	{Name: "Express.js", Language: "JavaScript/TypeScript", Plan: plans.ExpressPlan},
+	{Name: "Gin", Language: "Go", Plan: plans.GinPlan},
+	{Name: "Echo", Language: "Go", Plan: plans.EchoPlan},
+	{Name: "Fiber", Language: "Go", Plan: plans.FiberPlan},
+	{Name: "Hugo", Language: "Go", Plan: plans.HugoPlan},
+	{Name: "Go", Language: "Go", Plan: plans.GoPlan},
+	{Name: "Laravel", Language: "PHP", Plan: plans.LaravelPlan},
+	{Name: "Symfony", Language: "PHP", Plan: plans.SymfonyPlan},
+	{Name: "Rails", Language: "Ruby", Plan: plans.RailsPlan},
+	{Name: "Jekyll", Language: "Ruby", Plan: plans.JekyllPlan},
+	{Name: "Spring Boot", Language: "Java", Plan: plans.SpringBootPlan},
+	{Name: "Phoenix", Language: "Elixir", Plan: plans.PhoenixPlan},
+	{Name: "Actix-web", Language: "Rust", Plan: plans.ActixPlan},
+	{Name: "Axum", Language: "Rust", Plan: plans.AxumPlan},
+	{Name: "ASP.NET Core", Language: "C#", Plan: plans.AspNetPlan},
+	{Name: "Docker Compose", Language: "Container", Plan: plans.DockerComposePlan},
+	{Name: "Generic Docker", Plan: plans.DockerPlan},
+}
@@ -20,6 +20,10 @@ func GetJSInstallCommand(pm string) string {
 	return packagemanagers.GetJSInstallCommand(pm)
 }
 
+func UnfreezeJSInstallCommand(cmd string) string {
+	return packagemanagers.UnfreezeJSInstallCommand(cmd)
+}
+
 func GetJSBuildCommand(pm string) string {
 	return packagemanagers.GetJSBuildCommand(pm)
 }
@@ -1,6 +1,9 @@
 package detector
 
 import (
+	"lightfold/pkg/detector/helpers"
+	"lightfold/pkg/detector/packagemanagers"
+	"sort"
 	"strings"
 )
 
@@ -63,21 +66,17 @@ func clamp(x, lo, hi float64) float64 {
 	return x
 }
 
-// pickBest selects the best candidate from the list
-func pickBest(cands []Candidate) Candidate {
-	best := cands[0]
-	for _, c := range cands[1:] {
-		if c.Score > best.Score {
-			best = c
-			continue
+// rankCandidates orders candidates from the highest score down. Ties keep
+// detector order, except that Generic Docker loses to any framework.
+func rankCandidates(cands []Candidate) []Candidate {
+	ranked := append([]Candidate(nil), cands...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
 		}
-		if c.Score == best.Score {
-			if best.Name == "Generic Docker" && c.Name != "Generic Docker" {
-				best = c
-			}
-		}
-	}
-	return best
+		return ranked[i].Name != "Generic Docker" && ranked[j].Name == "Generic Docker"
+	})
+	return ranked
 }
 
 // detectRuntimeVersion detects runtime version from version files
@@ -135,3 +134,25 @@ func detectMonorepo(fs *FSReader) map[string]string {
 
 	return result
 }
+
+// detectPackageManagerPin records the package manager version pinned in
+// package.json and, for yarn berry, the linker installs use
+func detectPackageManagerPin(fs *FSReader, meta map[string]string) {
+	if spec := packagemanagers.PackageManagerSpec(fs); spec != "" {
+		meta["package_manager_spec"] = spec
+	}
+	if meta["package_manager"] == "yarn-berry" {
+		meta["yarn_linker"] = packagemanagers.YarnLinker(fs)
+	}
+}
+
+// detectLibrary marks a JavaScript package that is published rather than
+// deployed, so deploy can refuse it
+func detectLibrary(fs *FSReader, meta map[string]string) {
+	if !fs.Has("package.json") {
+		return
+	}
+	if reason := helpers.DetectLibrary(fs, helpers.ParsePackageJSON(fs)); reason != "" {
+		meta["library"] = reason
+	}
+}
@@ -0,0 +1,213 @@
+package helpers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ServerStart describes how an SSR framework's production server is started
+type ServerStart struct {
+	Command string   // e.g. "node build/index.js"; empty means use the package start script
+	Server  string   // "adapter-node", "remix-serve", "astro-node", "nitro" or "custom"
+	Env     []string // KEY=VALUE pairs the server reads for its listen address; $PORT is the app port
+}
+
+var (
+	adapterImportPattern = regexp.MustCompile(`(?:from|require\()\s*['"](@sveltejs/adapter-[a-z-]+)['"]`)
+	adapterOutPattern    = regexp.MustCompile(`\bout\s*:\s*['"]([^'"]+)['"]`)
+	adapterPagesPattern  = regexp.MustCompile(`\bpages\s*:\s*['"]([^'"]+)['"]`)
+	envPrefixPattern     = regexp.MustCompile(`\benvPrefix\s*:\s*['"]([^'"]+)['"]`)
+	serverBuildPattern   = regexp.MustCompile(`\bserverBuildPath\s*:\s*['"]([^'"]+)['"]`)
+	buildDirPattern      = regexp.MustCompile(`\bbuildDirectory\s*:\s*['"]([^'"]+)['"]`)
+	astroOutputPattern   = regexp.MustCompile(`\boutput\s*:\s*['"](static|server|hybrid)['"]`)
+	astroAdapterPattern  = regexp.MustCompile(`(?:from|require\()\s*['"]@astrojs/(node|vercel|netlify|cloudflare)[^'"]*['"]`)
+	astroModePattern     = regexp.MustCompile(`\bmode\s*:\s*['"](standalone|middleware)['"]`)
+)
+
+// customServerFiles are entry points of hand-written Node servers that wrap a framework's handler
+var customServerFiles = []string{"server.js", "server.mjs", "server.cjs", "server/index.js", "server/index.mjs"}
+
+// SvelteConfig holds the adapter settings read from svelte.config.js
+type SvelteConfig struct {
+	Adapter   string // e.g. "@sveltejs/adapter-node"
+	Out       string // adapter-node output directory
+	EnvPrefix string // adapter-node env var prefix
+	Pages     string // adapter-static output directory
+}
+
+// ParseSvelteConfig reads the adapter import and the adapter-node and
+// adapter-static options from svelte.config.js
+func ParseSvelteConfig(fs FSReader) SvelteConfig {
+	config := SvelteConfig{Out: "build", Pages: "build"}
+
+	for _, configFile := range []string{"svelte.config.js", "svelte.config.mjs", "svelte.config.ts"} {
+		content := readFile(fs, configFile)
+		if content == "" {
+			continue
+		}
+		if match := adapterImportPattern.FindStringSubmatch(content); match != nil {
+			config.Adapter = match[1]
+		}
+		if match := adapterOutPattern.FindStringSubmatch(content); match != nil {
+			config.Out = strings.TrimSuffix(strings.TrimPrefix(match[1], "./"), "/")
+		}
+		if match := adapterPagesPattern.FindStringSubmatch(content); match != nil {
+			config.Pages = strings.TrimSuffix(strings.TrimPrefix(match[1], "./"), "/")
+		}
+		if match := envPrefixPattern.FindStringSubmatch(content); match != nil {
+			config.EnvPrefix = match[1]
+		}
+		break
+	}
+
+	return config
+}
+
+// AstroConfig holds the output mode and adapter read from astro.config.*
+type AstroConfig struct {
+	Found   bool   // an astro.config file exists
+	Output  string // "static", "server" or "hybrid"; Astro defaults to static
+	Adapter string // "node", "vercel", "netlify", "cloudflare" or "" when none is imported
+	Mode    string // @astrojs/node mode: "standalone" or "middleware"
+}
+
+// ParseAstroConfig reads the output mode and adapter from astro.config.mjs/.ts/.js
+func ParseAstroConfig(fs FSReader) AstroConfig {
+	config := AstroConfig{Output: "static"}
+
+	for _, configFile := range []string{"astro.config.mjs", "astro.config.ts", "astro.config.js", "astro.config.mts", "astro.config.cjs"} {
+		content := readFile(fs, configFile)
+		if content == "" {
+			continue
+		}
+		config.Found = true
+		if match := astroOutputPattern.FindStringSubmatch(content); match != nil {
+			config.Output = match[1]
+		}
+		if match := astroAdapterPattern.FindStringSubmatch(content); match != nil {
+			config.Adapter = match[1]
+		}
+		if config.Adapter == "node" {
+			config.Mode = "standalone"
+			if match := astroModePattern.FindStringSubmatch(content); match != nil {
+				config.Mode = match[1]
+			}
+		}
+		break
+	}
+
+	return config
+}
+
+// RemixServerBuildPath returns the server bundle remix-serve should load. Vite-based
+// projects build to build/server/index.js; the classic compiler defaults to
+// build/index.js unless remix.config.js overrides serverBuildPath.
+func RemixServerBuildPath(fs FSReader) string {
+	for _, viteConfig := range []string{"vite.config.ts", "vite.config.js", "vite.config.mjs"} {
+		content := readFile(fs, viteConfig)
+		if !strings.Contains(content, "@remix-run/dev") {
+			continue
+		}
+		if match := buildDirPattern.FindStringSubmatch(content); match != nil {
+			return strings.TrimSuffix(strings.TrimPrefix(match[1], "./"), "/") + "/server/index.js"
+		}
+		return "build/server/index.js"
+	}
+
+	for _, configFile := range []string{"remix.config.js", "remix.config.mjs", "remix.config.cjs"} {
+		if match := serverBuildPattern.FindStringSubmatch(readFile(fs, configFile)); match != nil {
+			return strings.TrimPrefix(match[1], "./")
+		}
+	}
+
+	return "build/index.js"
+}
+
+// DetectServerStart works out the production start command for SvelteKit, Remix,
+// Astro and Nuxt from the adapter in use. Preview servers such as `vite preview`
+// are never returned since they are not meant for production traffic.
+func DetectServerStart(fs FSReader, pkg PackageJSON, framework string) ServerStart {
+	allDeps := mergeDeps(pkg.Dependencies, pkg.DevDeps)
+	hostEnv := "HOST=127.0.0.1"
+
+	if entry := customServerEntry(fs); entry != "" && hasCustomServerDeps(allDeps, framework) {
+		return ServerStart{Command: "node " + entry, Server: "custom"}
+	}
+
+	switch framework {
+	case "sveltekit":
+		svelteConfig := ParseSvelteConfig(fs)
+		if svelteConfig.Adapter != "" && svelteConfig.Adapter != "@sveltejs/adapter-node" {
+			return ServerStart{}
+		}
+		if svelteConfig.Adapter == "" && allDeps["@sveltejs/adapter-node"] == "" {
+			return ServerStart{}
+		}
+		prefix := svelteConfig.EnvPrefix
+		return ServerStart{
+			Command: "node " + svelteConfig.Out + "/index.js",
+			Server:  "adapter-node",
+			Env:     []string{prefix + "HOST=127.0.0.1", prefix + "PORT=$PORT"},
+		}
+
+	case "remix":
+		if allDeps["@remix-run/serve"] == "" {
+			return ServerStart{}
+		}
+		return ServerStart{
+			Command: "remix-serve " + RemixServerBuildPath(fs),
+			Server:  "remix-serve",
+			Env:     []string{hostEnv},
+		}
+
+	case "astro":
+		astroConfig := ParseAstroConfig(fs)
+		usesNode := astroConfig.Adapter == "node" || (!astroConfig.Found && allDeps["@astrojs/node"] != "")
+		if !usesNode {
+			return ServerStart{}
+		}
+		// Middleware mode only exports a handler; the app's own server must load it
+		if astroConfig.Mode == "middleware" {
+			if entry := customServerEntry(fs); entry != "" {
+				return ServerStart{Command: "node " + entry, Server: "custom"}
+			}
+			return ServerStart{}
+		}
+		return ServerStart{
+			Command: "node ./dist/server/entry.mjs",
+			Server:  "astro-node",
+			Env:     []string{hostEnv},
+		}
+
+	case "nuxt":
+		return ServerStart{
+			Command: "node .output/server/index.mjs",
+			Server:  "nitro",
+			Env:     []string{hostEnv},
+		}
+	}
+
+	return ServerStart{}
+}
+
+func customServerEntry(fs FSReader) string {
+	for _, file := range customServerFiles {
+		if fileExists(fs, file) {
+			return file
+		}
+	}
+	return ""
+}
+
+// hasCustomServerDeps reports whether the project wires the framework into its own
+// Node server (Express, Polka, Fastify) rather than using the adapter's server
+func hasCustomServerDeps(allDeps map[string]string, framework string) bool {
+	switch framework {
+	case "remix":
+		return allDeps["@remix-run/express"] != ""
+	case "sveltekit":
+		return allDeps["@sveltejs/adapter-node"] != "" &&
+			(allDeps["express"] != "" || allDeps["polka"] != "" || allDeps["fastify"] != "")
+	}
+	return false
+}
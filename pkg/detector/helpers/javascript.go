@@ -2,9 +2,16 @@ package helpers
 
 import (
 	"encoding/json"
+	"regexp"
 	"strings"
 )
 
+// viteLibPattern matches the build.lib option that puts Vite into library mode
+var viteLibPattern = regexp.MustCompile(`\blib\s*:\s*\{`)
+
+// appStartScripts are the package.json scripts that serve a built app
+var appStartScripts = []string{"start", "start:prod", "start:production", "serve", "preview"}
+
 // FSReader provides filesystem operations for helper functions
 type FSReader interface {
 	Has(path string) bool
@@ -24,6 +31,7 @@ type PackageJSON struct {
 	Scripts      map[string]string `json:"scripts"`
 	Dependencies map[string]string `json:"dependencies"`
 	DevDeps      map[string]string `json:"devDependencies"`
+	Private      *bool             `json:"private"`
 }
 
 // FrameworkAdapter represents detected adapter information
@@ -175,6 +183,28 @@ func DetectFrameworkAdapter(pkg PackageJSON, framework string) FrameworkAdapter
 	return adapter
 }
 
+// DetectLibrary reports why the project is a package to publish rather than
+// an app to deploy: Vite library mode (build.lib in vite.config), or
+// "private": false in package.json with no script that serves the build.
+// It returns "" for apps.
+func DetectLibrary(fs FSReader, pkg PackageJSON) string {
+	for _, configFile := range []string{"vite.config.js", "vite.config.ts", "vite.config.mjs", "vite.config.mts", "vite.config.cjs"} {
+		if viteLibPattern.MatchString(readFile(fs, configFile)) {
+			return configFile + " builds in library mode (build.lib)"
+		}
+	}
+
+	if pkg.Private == nil || *pkg.Private {
+		return ""
+	}
+	for _, script := range appStartScripts {
+		if pkg.Scripts[script] != "" {
+			return ""
+		}
+	}
+	return `package.json sets "private": false and has no start or preview script`
+}
+
 func GetProductionStartScript(pkg PackageJSON) string {
 	priorities := []string{
 		"start:prod",
@@ -0,0 +1,168 @@
+package detector
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"lightfold/pkg/detector/packagemanagers"
+	"lightfold/pkg/util"
+)
+
+// monorepoCacheEnv lists the variables each tool reads its remote cache
+// settings from, sorted. They are passed to the build when the target sets them.
+var monorepoCacheEnv = map[string][]string{
+	"turborepo": {"TURBO_API", "TURBO_REMOTE_CACHE_SIGNATURE_KEY", "TURBO_TEAM", "TURBO_TEAMID", "TURBO_TOKEN"},
+	"nx":        {"NX_CLOUD_ACCESS_TOKEN", "NX_CLOUD_API"},
+}
+
+// DetectAppFS detects one app of a monorepo: the framework comes from subdir,
+// the package manager and monorepo tool from the workspace root. The build
+// installs the workspace and builds only the app and the packages it depends
+// on. An empty subdir detects the whole project.
+func DetectAppFS(fsys fs.FS, subdir string) Detection {
+	return detectAppFS(fsys, subdir, "")
+}
+
+// detectAppFS detects the app in subdir as framework, or by score when
+// framework is empty
+func detectAppFS(fsys fs.FS, subdir, framework string) Detection {
+	subdir = strings.Trim(path.Clean(strings.ReplaceAll(subdir, "\\", "/")), "/")
+	if subdir == "" || subdir == "." {
+		return detectFrameworkFS(fsys, framework)
+	}
+
+	appFS, err := fs.Sub(fsys, subdir)
+	if err != nil {
+		return detectFrameworkFS(fsys, framework)
+	}
+	detection := detectFrameworkFS(appFS, framework)
+	if detection.Meta == nil {
+		detection.Meta = map[string]string{}
+	}
+
+	root := NewFSReader(fsys)
+	for k, v := range detectMonorepo(root) {
+		detection.Meta[k] = v
+	}
+	tool := detection.Meta["monorepo_tool"]
+	app := workspaceAppName(root, subdir, tool)
+	detection.Meta["monorepo_app"] = app
+	detection.Meta["monorepo_subdir"] = subdir
+	if output := detection.Meta["build_output"]; output != "" {
+		detection.Meta["build_output"] = path.Join(subdir, output) + "/"
+	}
+
+	if detection.Language != "JavaScript/TypeScript" || tool == "" {
+		return detection
+	}
+
+	pm := packagemanagers.DetectJS(root)
+	detection.Meta["package_manager"] = pm
+	detection.BuildPlan = []string{
+		packagemanagers.JSInstallCommand(root, pm),
+		MonorepoBuildCommand(tool, pm, app),
+	}
+	if detection.Meta["deployment_type"] != "static" && len(detection.RunPlan) > 0 {
+		detection.RunPlan = []string{WorkspaceRunCommand(pm, app, "start")}
+	}
+	return detection
+}
+
+// DetectApp detects the app in subdir of a local monorepo, or the whole
+// project when subdir is empty
+func DetectApp(root, subdir string) Detection {
+	return DetectAppFS(os.DirFS(root), subdir)
+}
+
+// DetectAppAs detects the app in subdir of a local project as framework, the
+// target's framework override; an empty framework picks by score
+func DetectAppAs(root, subdir, framework string) Detection {
+	return detectAppFS(os.DirFS(root), subdir, framework)
+}
+
+// workspaceAppName returns the name the monorepo tool knows the app in subdir
+// by: the Nx project name or the package name, falling back to the directory
+// for Turborepo filters and the directory's base name otherwise
+func workspaceAppName(root *FSReader, subdir, tool string) string {
+	var manifest struct {
+		Name string `json:"name"`
+	}
+	if tool == "nx" {
+		if err := json.Unmarshal([]byte(root.Read(path.Join(subdir, "project.json"))), &manifest); err == nil && manifest.Name != "" {
+			return manifest.Name
+		}
+	}
+	if err := json.Unmarshal([]byte(root.Read(path.Join(subdir, "package.json"))), &manifest); err == nil && manifest.Name != "" {
+		return manifest.Name
+	}
+	if tool == "turborepo" {
+		return "./" + subdir
+	}
+	return path.Base(subdir)
+}
+
+// MonorepoBuildCommand builds one app and its workspace dependencies:
+// `turbo run build --filter=<app>...` for Turborepo, `nx build <app>` for Nx
+// and the app's build script for plain workspaces
+func MonorepoBuildCommand(tool, pm, app string) string {
+	switch tool {
+	case "turborepo":
+		return packageExecCommand(pm) + " turbo run build --filter=" + app + "..."
+	case "nx":
+		return packageExecCommand(pm) + " nx build " + app
+	default:
+		return WorkspaceRunCommand(pm, app, "build")
+	}
+}
+
+// WorkspaceRunCommand runs a package.json script of one workspace package
+// from the workspace root
+func WorkspaceRunCommand(pm, app, script string) string {
+	switch pm {
+	case "bun":
+		return "bun run --filter " + app + " " + script
+	case "pnpm":
+		return "pnpm --filter " + app + " run " + script
+	case "yarn", "yarn-berry":
+		return "yarn workspace " + app + " run " + script
+	default:
+		return "npm run " + script + " --workspace=" + app
+	}
+}
+
+// packageExecCommand runs a binary installed in the workspace
+func packageExecCommand(pm string) string {
+	switch pm {
+	case "bun":
+		return "bunx"
+	case "pnpm":
+		return "pnpm exec"
+	case "yarn", "yarn-berry":
+		return "yarn"
+	default:
+		return "npx"
+	}
+}
+
+// MonorepoCacheEnvPrefix exports the remote cache variables set in env ahead
+// of a build command, e.g. "export TURBO_TEAM='acme' TURBO_TOKEN='…' && ". It
+// is empty outside Turborepo and Nx workspaces and when none of the variables
+// are set.
+func MonorepoCacheEnvPrefix(detection *Detection, env map[string]string) string {
+	if detection == nil || len(env) == 0 {
+		return ""
+	}
+	var assignments []string
+	for _, name := range monorepoCacheEnv[detection.Meta["monorepo_tool"]] {
+		if value := env[name]; value != "" {
+			assignments = append(assignments, name+"="+util.ShellQuote(value))
+		}
+	}
+	if len(assignments) == 0 {
+		return ""
+	}
+	return "export " + strings.Join(assignments, " ") + " && "
+}
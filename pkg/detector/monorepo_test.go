@@ -0,0 +1,108 @@
+package detector
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMonorepoBuildCommand(t *testing.T) {
+	tests := []struct {
+		tool, pm, app string
+		want          string
+	}{
+		{"turborepo", "npm", "web", "npx turbo run build --filter=web..."},
+		{"turborepo", "pnpm", "@acme/web", "pnpm exec turbo run build --filter=@acme/web..."},
+		{"turborepo", "yarn", "./apps/web", "yarn turbo run build --filter=./apps/web..."},
+		{"nx", "npm", "web", "npx nx build web"},
+		{"nx", "bun", "api", "bunx nx build api"},
+		{"pnpm-workspaces", "pnpm", "web", "pnpm --filter web run build"},
+		{"yarn-workspaces", "yarn-berry", "web", "yarn workspace web run build"},
+	}
+	for _, tt := range tests {
+		if got := MonorepoBuildCommand(tt.tool, tt.pm, tt.app); got != tt.want {
+			t.Errorf("MonorepoBuildCommand(%q, %q, %q) = %q, want %q", tt.tool, tt.pm, tt.app, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAppFSTurborepo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json":             {Data: []byte(`{"name": "acme", "workspaces": ["apps/*", "packages/*"]}`)},
+		"pnpm-lock.yaml":           {Data: []byte("lockfileVersion: '9.0'\n")},
+		"turbo.json":               {Data: []byte(`{"tasks": {"build": {}}}`)},
+		"apps/web/package.json":    {Data: []byte(`{"name": "@acme/web", "scripts": {"build": "next build", "start": "next start"}, "dependencies": {"next": "14.0.0"}}`)},
+		"apps/web/next.config.js":  {Data: []byte("module.exports = {}\n")},
+		"packages/ui/package.json": {Data: []byte(`{"name": "@acme/ui"}`)},
+	}
+
+	detection := DetectAppFS(fsys, "apps/web/")
+
+	if detection.Framework != "Next.js" {
+		t.Fatalf("Framework = %q, want Next.js", detection.Framework)
+	}
+	for key, want := range map[string]string{
+		"monorepo_tool":   "turborepo",
+		"monorepo_app":    "@acme/web",
+		"monorepo_subdir": "apps/web",
+		"package_manager": "pnpm",
+	} {
+		if got := detection.Meta[key]; got != want {
+			t.Errorf("Meta[%q] = %q, want %q", key, got, want)
+		}
+	}
+	wantBuild := []string{"pnpm install --frozen-lockfile", "pnpm exec turbo run build --filter=@acme/web..."}
+	if !reflect.DeepEqual(detection.BuildPlan, wantBuild) {
+		t.Errorf("BuildPlan = %q, want %q", detection.BuildPlan, wantBuild)
+	}
+	if want := []string{"pnpm --filter @acme/web run start"}; !reflect.DeepEqual(detection.RunPlan, want) {
+		t.Errorf("RunPlan = %q, want %q", detection.RunPlan, want)
+	}
+}
+
+func TestDetectAppFSNx(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json":          {Data: []byte(`{"name": "acme"}`)},
+		"package-lock.json":     {Data: []byte("{}")},
+		"nx.json":               {Data: []byte("{}")},
+		"apps/api/project.json": {Data: []byte(`{"name": "api"}`)},
+		"apps/api/package.json": {Data: []byte(`{"name": "@acme/api-server", "scripts": {"start": "node dist/main.js"}, "dependencies": {"express": "4.18.0"}}`)},
+	}
+
+	detection := DetectAppFS(fsys, "apps/api")
+
+	if detection.Meta["monorepo_tool"] != "nx" || detection.Meta["monorepo_app"] != "api" {
+		t.Errorf("Meta = %v, want the nx project api", detection.Meta)
+	}
+	wantBuild := []string{"npm ci", "npx nx build api"}
+	if !reflect.DeepEqual(detection.BuildPlan, wantBuild) {
+		t.Errorf("BuildPlan = %q, want %q", detection.BuildPlan, wantBuild)
+	}
+}
+
+func TestDetectAppFSWithoutSubdir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"package.json": {Data: []byte(`{"dependencies": {"next": "14.0.0"}}`)},
+		"turbo.json":   {Data: []byte("{}")},
+	}
+	if got, want := DetectAppFS(fsys, ""), DetectFrameworkFS(fsys); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectAppFS(fsys, \"\") = %+v, want the whole project detected", got)
+	}
+}
+
+func TestMonorepoCacheEnvPrefix(t *testing.T) {
+	env := map[string]string{"TURBO_TOKEN": "it's secret", "TURBO_TEAM": "acme", "NX_CLOUD_ACCESS_TOKEN": "nx", "DATABASE_URL": "postgres://db"}
+
+	turbo := &Detection{Meta: map[string]string{"monorepo_tool": "turborepo"}}
+	if got, want := MonorepoCacheEnvPrefix(turbo, env), `export TURBO_TEAM='acme' TURBO_TOKEN='it'\''s secret' && `; got != want {
+		t.Errorf("turborepo prefix = %q, want %q", got, want)
+	}
+	nx := &Detection{Meta: map[string]string{"monorepo_tool": "nx"}}
+	if got, want := MonorepoCacheEnvPrefix(nx, env), "export NX_CLOUD_ACCESS_TOKEN='nx' && "; got != want {
+		t.Errorf("nx prefix = %q, want %q", got, want)
+	}
+	plain := &Detection{Meta: map[string]string{}}
+	if got := MonorepoCacheEnvPrefix(plain, env); got != "" {
+		t.Errorf("prefix outside a monorepo = %q, want empty", got)
+	}
+}
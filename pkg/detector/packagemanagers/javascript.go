@@ -1,5 +1,10 @@
 package packagemanagers
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // FSReader provides filesystem operations for package manager detection
 type FSReader interface {
 	Has(path string) bool
@@ -23,8 +28,25 @@ func DetectJS(fs FSReader) string {
 	}
 }
 
-// GetJSInstallCommand returns the install command for the given package manager
+// GetJSInstallCommand returns the lockfile-enforcing install command for the
+// given package manager, so a server build installs exactly what was locked
 func GetJSInstallCommand(pm string) string {
+	switch pm {
+	case "bun":
+		return "bun install --frozen-lockfile"
+	case "pnpm":
+		return "pnpm install --frozen-lockfile"
+	case "yarn":
+		return "yarn install --frozen-lockfile"
+	case "yarn-berry":
+		return "yarn install --immutable"
+	default:
+		return "npm ci"
+	}
+}
+
+// GetJSUnfrozenInstallCommand returns the install command that may update the lockfile
+func GetJSUnfrozenInstallCommand(pm string) string {
 	switch pm {
 	case "bun":
 		return "bun install"
@@ -37,6 +59,78 @@ func GetJSInstallCommand(pm string) string {
 	}
 }
 
+// JSInstallCommand picks the frozen install when the project has a lockfile
+// for it to enforce, and the plain install otherwise
+func JSInstallCommand(fs FSReader, pm string) string {
+	if HasJSLockfile(fs, pm) {
+		return GetJSInstallCommand(pm)
+	}
+	return GetJSUnfrozenInstallCommand(pm)
+}
+
+// HasJSLockfile reports whether the project has a lockfile for the package manager
+func HasJSLockfile(fs FSReader, pm string) bool {
+	switch pm {
+	case "bun":
+		return fs.Has("bun.lockb") || fs.Has("bun.lock")
+	case "pnpm":
+		return fs.Has("pnpm-lock.yaml")
+	case "yarn", "yarn-berry":
+		return fs.Has("yarn.lock")
+	default:
+		return fs.Has("package-lock.json") || fs.Has("npm-shrinkwrap.json")
+	}
+}
+
+// UnfreezeJSInstallCommand rewrites a frozen install into its plain form,
+// leaving any other command unchanged
+func UnfreezeJSInstallCommand(cmd string) string {
+	for _, pm := range []string{"bun", "pnpm", "yarn", "yarn-berry", "npm"} {
+		frozen := GetJSInstallCommand(pm)
+		if cmd == frozen || strings.HasPrefix(cmd, frozen+" ") {
+			return GetJSUnfrozenInstallCommand(pm) + strings.TrimPrefix(cmd, frozen)
+		}
+	}
+	return cmd
+}
+
+// PackageManagerSpec returns the "packageManager" field of package.json
+// (e.g. "pnpm@9.1.0"), without any "+sha..." integrity suffix
+func PackageManagerSpec(fs FSReader) string {
+	content := fs.Read("package.json")
+	if content == "" {
+		return ""
+	}
+
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if err := json.Unmarshal([]byte(content), &pkg); err != nil {
+		return ""
+	}
+
+	spec, _, _ := strings.Cut(strings.TrimSpace(pkg.PackageManager), "+")
+	if !strings.Contains(spec, "@") {
+		return ""
+	}
+	return spec
+}
+
+// YarnLinker returns the nodeLinker yarn berry installs with: "pnp" (its
+// default), "node-modules" or "pnpm"
+func YarnLinker(fs FSReader) string {
+	for _, line := range strings.Split(fs.Read(".yarnrc.yml"), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || strings.TrimSpace(key) != "nodeLinker" {
+			continue
+		}
+		if linker := strings.Trim(strings.TrimSpace(value), `"'`); linker != "" {
+			return linker
+		}
+	}
+	return "pnp"
+}
+
 // GetJSBuildCommand returns the build command for the given package manager
 func GetJSBuildCommand(pm string) string {
 	switch pm {
@@ -1,6 +1,7 @@
 package packagemanagers
 
 import (
+	"lightfold/pkg/config"
 	"path/filepath"
 	"strings"
 )
@@ -100,13 +101,13 @@ func DetectDjangoServerType(fs FSReader) string {
 func GetDjangoRunCommand(serverType, projectName string) string {
 	if serverType == "asgi" {
 		if projectName != "" {
-			return "uvicorn " + projectName + ".asgi:application --host 0.0.0.0 --port $PORT"
+			return "uvicorn " + projectName + ".asgi:application --host " + config.DefaultBindAddress + " --port $PORT"
 		}
-		return "uvicorn asgi:application --host 0.0.0.0 --port $PORT"
+		return "uvicorn asgi:application --host " + config.DefaultBindAddress + " --port $PORT"
 	}
 
 	if projectName != "" {
-		return "gunicorn " + projectName + ".wsgi:application --bind 0.0.0.0:$PORT --workers 2"
+		return "gunicorn " + projectName + ".wsgi:application --bind " + config.DefaultBindAddress + ":$PORT --workers 2"
 	}
-	return "gunicorn <yourproject>.wsgi:application --bind 0.0.0.0:$PORT --workers 2"
+	return "gunicorn <yourproject>.wsgi:application --bind " + config.DefaultBindAddress + ":$PORT --workers 2"
 }
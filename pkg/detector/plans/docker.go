@@ -2,6 +2,8 @@ package plans
 
 import (
 	"lightfold/pkg/config"
+	"regexp"
+	"strings"
 )
 
 // DockerPlan returns the build and run plan for Docker-based applications
@@ -18,9 +20,49 @@ func DockerPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[
 // Uses Docker Compose V2 syntax (docker compose) which is the modern standard
 func DockerComposePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	build := []string{"docker compose build"}
-	run := []string{"docker compose up -d"}
+	run := []string{"docker compose up -d --build"}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{}
 	meta := map[string]string{"deployment_type": "docker-compose"}
+
+	for _, name := range composeFiles {
+		if fs.Has(name) {
+			meta["compose_file"] = name
+			if port := composePublishedPort(fs.Read(name)); port != "" {
+				meta["compose_port"] = port
+			}
+			break
+		}
+	}
+
 	return build, run, health, env, meta
 }
+
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// composePortPattern matches short-syntax port entries like "8080:80", "127.0.0.1:8080:80" or 8080:80/tcp
+var composePortPattern = regexp.MustCompile(`^\s*-\s*["']?(?:[\d.]+:)?(\d+):\d+(?:/\w+)?["']?\s*$`)
+
+// composePublishedPort returns the first literal host port published in a compose file.
+// Ports using variable interpolation (e.g. "${PORT}:3000") are skipped.
+func composePublishedPort(content string) string {
+	inPorts := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "ports:" {
+			inPorts = true
+			continue
+		}
+		if !inPorts {
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "-") {
+			inPorts = false
+			continue
+		}
+		if match := composePortPattern.FindStringSubmatch(line); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
@@ -2,6 +2,8 @@ package plans
 
 import (
 	"lightfold/pkg/config"
+	"regexp"
+	"strings"
 )
 
 // GinPlan returns the build and run plan for Gin
@@ -66,10 +68,31 @@ func HugoPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[st
 		"hugo --minify",
 	}
 	run := []string{
-		"hugo server --bind 0.0.0.0 --port $PORT",
+		"hugo server --bind " + config.DefaultBindAddress + " --port $PORT",
 	}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"HUGO_ENV"}
-	meta := map[string]string{"build_output": "public/", "static": "true"}
+	meta := map[string]string{"build_output": "public/", "static": "true", "deployment_type": "static"}
+	if version := detectHugoVersion(fs); version != "" {
+		meta["hugo_version"] = version
+	}
 	return build, run, health, env, meta
 }
+
+// detectHugoVersion reads a pinned Hugo version from .hugo-version or netlify.toml
+func detectHugoVersion(fs FSReader) string {
+	if fs.Has(".hugo-version") {
+		if version := strings.TrimPrefix(strings.TrimSpace(fs.Read(".hugo-version")), "v"); version != "" {
+			return version
+		}
+	}
+
+	if fs.Has("netlify.toml") {
+		re := regexp.MustCompile(`HUGO_VERSION\s*=\s*["']v?([0-9][0-9.]*)["']`)
+		if m := re.FindStringSubmatch(fs.Read("netlify.toml")); len(m) > 1 {
+			return m[1]
+		}
+	}
+
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"lightfold/pkg/config"
 	"lightfold/pkg/detector/helpers"
 	"lightfold/pkg/detector/packagemanagers"
+	"strings"
 )
 
 // NextPlan returns the build and run plan for Next.js
@@ -13,7 +14,7 @@ func NextPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[st
 	pkg := helpers.ParsePackageJSON(fs)
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
@@ -73,16 +74,21 @@ func RemixPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 	adapter := helpers.DetectFrameworkAdapter(pkg, "remix")
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
 	var run []string
 	startScript := helpers.GetProductionStartScript(pkg)
+	start := helpers.DetectServerStart(fs, pkg, "remix")
 
 	switch adapter.Type {
 	case "node":
-		run = []string{packagemanagers.GetRunCommand(pm, startScript)}
+		if start.Command != "" {
+			run = []string{start.Command}
+		} else {
+			run = []string{packagemanagers.GetRunCommand(pm, startScript)}
+		}
 	case "deno":
 		run = []string{"deno run --allow-net --allow-read --allow-env server.ts"}
 	case "cloudflare":
@@ -99,6 +105,7 @@ func RemixPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 		"build_output":    "build/",
 		"adapter":         adapter.Type,
 	}
+	addServerStartMeta(meta, start)
 
 	// Add port detection
 	if detectedPort := helpers.DetectPort(fs, "Remix"); detectedPort != "" {
@@ -115,15 +122,15 @@ func RemixPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 func NuxtPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
-	run := []string{
-		"node .output/server/index.mjs",
-	}
+	start := helpers.DetectServerStart(fs, helpers.ParsePackageJSON(fs), "nuxt")
+	run := []string{start.Command}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"NUXT_PUBLIC_*", "NITRO_*"}
 	meta := map[string]string{"package_manager": pm, "build_output": ".output/"}
+	addServerStartMeta(meta, start)
 
 	// Add port detection
 	if detectedPort := helpers.DetectPort(fs, "Nuxt.js"); detectedPort != "" {
@@ -139,9 +146,21 @@ func AstroPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 	pm := packagemanagers.DetectJS(fs)
 	pkg := helpers.ParsePackageJSON(fs)
 	adapter := helpers.DetectFrameworkAdapter(pkg, "astro")
+	astroConfig := helpers.ParseAstroConfig(fs)
+
+	// The adapter imported in astro.config decides over installed packages;
+	// without one Astro renders every page at build time
+	if astroConfig.Found {
+		if astroConfig.Adapter == "" {
+			adapter = helpers.FrameworkAdapter{Type: "static", RunMode: "static"}
+		} else if astroConfig.Adapter != adapter.Type {
+			adapter = helpers.FrameworkAdapter{Type: astroConfig.Adapter, Package: "@astrojs/" + astroConfig.Adapter, RunMode: "server"}
+		}
+	}
+	start := helpers.DetectServerStart(fs, pkg, "astro")
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
@@ -154,7 +173,11 @@ func AstroPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 		run = []string{"# Static site - serve dist/ with nginx"}
 		health = nil // No health check needed for static sites
 	case "node":
-		run = []string{"node dist/server/entry.mjs"}
+		if start.Command != "" {
+			run = []string{start.Command}
+		} else {
+			run = []string{packagemanagers.GetRunCommand(pm, startScript)}
+		}
 		health = map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	case "vercel", "netlify", "cloudflare":
 		run = []string{"# Deploy to " + adapter.Type}
@@ -172,17 +195,26 @@ func AstroPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 		"adapter":         adapter.Type,
 		"run_mode":        adapter.RunMode,
 	}
+	if astroConfig.Found {
+		meta["output"] = astroConfig.Output
+	}
+	if astroConfig.Mode != "" {
+		meta["adapter_mode"] = astroConfig.Mode
+	}
+	addServerStartMeta(meta, start)
 
 	// Mark static sites explicitly for deployment logic
 	if adapter.RunMode == "static" {
 		meta["deployment_type"] = "static"
 	}
 
-	// Add port detection
-	if detectedPort := helpers.DetectPort(fs, "Astro"); detectedPort != "" {
-		meta["detected_port"] = detectedPort
+	// Static builds are served by nginx, so only SSR builds listen on a port
+	if adapter.RunMode != "static" {
+		if detectedPort := helpers.DetectPort(fs, "Astro"); detectedPort != "" {
+			meta["detected_port"] = detectedPort
+		}
+		meta["default_port"] = helpers.GetDefaultPortForFramework("Astro")
 	}
-	meta["default_port"] = helpers.GetDefaultPortForFramework("Astro")
 
 	AddMonorepoMeta(fs, meta)
 
@@ -193,21 +225,13 @@ func AstroPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 func GatsbyPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
-	run := []string{
-		packagemanagers.GetRunCommand(pm, "serve"),
-	}
+	run := []string{"# Static site - serve public/ with nginx"}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"GATSBY_*, any build-time envs"}
-	meta := map[string]string{"package_manager": pm, "build_output": "public/"}
-
-	// Add port detection
-	if detectedPort := helpers.DetectPort(fs, "Gatsby"); detectedPort != "" {
-		meta["detected_port"] = detectedPort
-	}
-	meta["default_port"] = helpers.GetDefaultPortForFramework("Gatsby")
+	meta := map[string]string{"package_manager": pm, "build_output": "public/", "static": "true", "deployment_type": "static"}
 
 	return build, run, health, env, meta
 }
@@ -217,9 +241,21 @@ func SveltePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[
 	pm := packagemanagers.DetectJS(fs)
 	pkg := helpers.ParsePackageJSON(fs)
 	adapter := helpers.DetectFrameworkAdapter(pkg, "sveltekit")
+	start := helpers.DetectServerStart(fs, pkg, "sveltekit")
+	svelteConfig := helpers.ParseSvelteConfig(fs)
+
+	// svelte.config.js names the adapter in use even when package.json lists
+	// several, or none in a hoisted monorepo
+	if svelteConfig.Adapter == "@sveltejs/adapter-static" {
+		adapter = helpers.FrameworkAdapter{Type: "static", Package: svelteConfig.Adapter, RunMode: "static"}
+	}
+	buildOutput := "build/"
+	if adapter.Type == "static" {
+		buildOutput = svelteConfig.Pages + "/"
+	}
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
@@ -229,10 +265,14 @@ func SveltePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[
 
 	switch adapter.Type {
 	case "static":
-		run = []string{"# Static site - serve build/ with nginx"}
+		run = []string{"# Static site - serve " + buildOutput + " with nginx"}
 		health = nil // No health check needed for static sites
 	case "node":
-		run = []string{"node build"}
+		if start.Command != "" {
+			run = []string{start.Command}
+		} else {
+			run = []string{packagemanagers.GetRunCommand(pm, startScript)}
+		}
 		health = map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	case "vercel", "netlify", "cloudflare":
 		run = []string{"# Deploy to " + adapter.Type}
@@ -243,13 +283,17 @@ func SveltePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[
 	}
 
 	env := []string{"PUBLIC_*, any server-only envs for SvelteKit SSR"}
+	if start.Server == "adapter-node" {
+		env = append(env, "ORIGIN (public URL, needed for form actions)")
+	}
 
 	meta := map[string]string{
 		"package_manager": pm,
-		"build_output":    "build/",
+		"build_output":    buildOutput,
 		"adapter":         adapter.Type,
 		"run_mode":        adapter.RunMode,
 	}
+	addServerStartMeta(meta, start)
 
 	// Mark static sites explicitly for deployment logic
 	if adapter.RunMode == "static" {
@@ -273,7 +317,7 @@ func VuePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[str
 	pkg := helpers.ParsePackageJSON(fs)
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
@@ -308,6 +352,16 @@ func VuePlan(fs FSReader) ([]string, []string, map[string]any, []string, map[str
 	return build, run, health, env, meta
 }
 
+// addServerStartMeta records which server runs an SSR build and the env it needs to listen correctly
+func addServerStartMeta(meta map[string]string, start helpers.ServerStart) {
+	if start.Server != "" {
+		meta["server"] = start.Server
+	}
+	if len(start.Env) > 0 {
+		meta["start_env"] = strings.Join(start.Env, " ")
+	}
+}
+
 func mergeDeps(deps ...map[string]string) map[string]string {
 	merged := make(map[string]string)
 	for _, d := range deps {
@@ -322,7 +376,7 @@ func mergeDeps(deps ...map[string]string) map[string]string {
 func AngularPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 	run := []string{
@@ -345,7 +399,7 @@ func AngularPlan(fs FSReader) ([]string, []string, map[string]any, []string, map
 func NestJSPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 	run := []string{
@@ -371,7 +425,7 @@ func TRPCPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[st
 	pkg := helpers.ParsePackageJSON(fs)
 
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
 
@@ -428,21 +482,13 @@ func TRPCPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[st
 func EleventyPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetRunCommand(pm, "build"),
 	}
-	run := []string{
-		packagemanagers.GetRunCommand(pm, "serve"),
-	}
+	run := []string{"# Static site - serve _site/ with nginx"}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"ELEVENTY_ENV"}
-	meta := map[string]string{"package_manager": pm, "build_output": "_site/", "static": "true"}
-
-	// Add port detection
-	if detectedPort := helpers.DetectPort(fs, "Eleventy"); detectedPort != "" {
-		meta["detected_port"] = detectedPort
-	}
-	meta["default_port"] = helpers.GetDefaultPortForFramework("Eleventy")
+	meta := map[string]string{"package_manager": pm, "build_output": "_site/", "static": "true", "deployment_type": "static"}
 
 	return build, run, health, env, meta
 }
@@ -451,21 +497,13 @@ func EleventyPlan(fs FSReader) ([]string, []string, map[string]any, []string, ma
 func DocusaurusPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 		packagemanagers.GetJSBuildCommand(pm),
 	}
-	run := []string{
-		packagemanagers.GetRunCommand(pm, "serve"),
-	}
+	run := []string{"# Static site - serve build/ with nginx"}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{}
-	meta := map[string]string{"package_manager": pm, "build_output": "build/"}
-
-	// Add port detection
-	if detectedPort := helpers.DetectPort(fs, "Docusaurus"); detectedPort != "" {
-		meta["detected_port"] = detectedPort
-	}
-	meta["default_port"] = helpers.GetDefaultPortForFramework("Docusaurus")
+	meta := map[string]string{"package_manager": pm, "build_output": "build/", "static": "true", "deployment_type": "static"}
 
 	return build, run, health, env, meta
 }
@@ -487,7 +525,7 @@ func FastifyPlan(fs FSReader) ([]string, []string, map[string]any, []string, map
 
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 	}
 	run := []string{
 		packagemanagers.GetJSStartCommand(pm),
@@ -515,7 +553,7 @@ func ExpressPlan(fs FSReader) ([]string, []string, map[string]any, []string, map
 
 	pm := packagemanagers.DetectJS(fs)
 	build := []string{
-		packagemanagers.GetJSInstallCommand(pm),
+		packagemanagers.JSInstallCommand(fs, pm),
 	}
 	run := []string{
 		packagemanagers.GetJSStartCommand(pm),
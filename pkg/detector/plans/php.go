@@ -8,7 +8,6 @@ import (
 func LaravelPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	build := []string{
 		"composer install --no-dev --optimize-autoloader",
-		"php artisan migrate --force",
 		"php artisan config:cache && php artisan route:cache",
 	}
 	run := []string{
@@ -16,7 +15,7 @@ func LaravelPlan(fs FSReader) ([]string, []string, map[string]any, []string, map
 	}
 	health := map[string]any{"path": "/health", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"APP_KEY", "APP_ENV", "DB_CONNECTION/DB_*"}
-	meta := map[string]string{}
+	meta := map[string]string{"migration_command": "php artisan migrate --force"}
 	return build, run, health, env, meta
 }
 
@@ -27,8 +27,9 @@ func DjangoPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[
 		"ALLOWED_HOSTS",
 	}
 	meta := map[string]string{
-		"package_manager": pm,
-		"server_type":     serverType,
+		"package_manager":   pm,
+		"server_type":       serverType,
+		"migration_command": "python manage.py migrate --noinput",
 	}
 	return build, run, health, env, meta
 }
@@ -40,7 +41,7 @@ func FlaskPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 		packagemanagers.GetPythonInstallCommand(pm),
 	}
 	run := []string{
-		"gunicorn --bind 0.0.0.0:$PORT --workers 2 app:app",
+		"gunicorn --bind " + config.DefaultBindAddress + ":$PORT --workers 2 app:app",
 	}
 	health := map[string]any{"path": "/health", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"FLASK_ENV", "FLASK_APP", "DATABASE_URL", "SECRET_KEY"}
@@ -55,7 +56,7 @@ func FastAPIPlan(fs FSReader) ([]string, []string, map[string]any, []string, map
 		packagemanagers.GetPythonInstallCommand(pm),
 	}
 	run := []string{
-		"uvicorn main:app --host 0.0.0.0 --port $PORT",
+		"uvicorn main:app --host " + config.DefaultBindAddress + " --port $PORT",
 	}
 	health := map[string]any{"path": "/health", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"DATABASE_URL", "SECRET_KEY", "DEBUG"}
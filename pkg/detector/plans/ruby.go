@@ -8,7 +8,6 @@ import (
 func RailsPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	build := []string{
 		"bundle install --deployment --without development test",
-		"bundle exec rails db:migrate",
 		"bundle exec rails assets:precompile",
 	}
 	run := []string{
@@ -16,21 +15,22 @@ func RailsPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[s
 	}
 	health := map[string]any{"path": "/up", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"RAILS_ENV", "DATABASE_URL", "SECRET_KEY_BASE"}
-	meta := map[string]string{}
+	meta := map[string]string{"migration_command": "bundle exec rails db:migrate"}
 	return build, run, health, env, meta
 }
 
 // JekyllPlan returns the build and run plan for Jekyll
 func JekyllPlan(fs FSReader) ([]string, []string, map[string]any, []string, map[string]string) {
 	build := []string{
+		"bundle config set --local path vendor/bundle",
 		"bundle install",
-		"bundle exec jekyll build",
+		"JEKYLL_ENV=production bundle exec jekyll build",
 	}
 	run := []string{
-		"bundle exec jekyll serve --host 0.0.0.0",
+		"bundle exec jekyll serve --host " + config.DefaultBindAddress,
 	}
 	health := map[string]any{"path": "/", "expect": config.DefaultHealthCheckStatus, "timeout_seconds": int(config.DefaultHealthCheckTimeout.Seconds())}
 	env := []string{"JEKYLL_ENV"}
-	meta := map[string]string{"build_output": "_site/", "static": "true"}
+	meta := map[string]string{"build_output": "_site/", "static": "true", "deployment_type": "static"}
 	return build, run, health, env, meta
 }
@@ -13,6 +13,20 @@ type Detection struct {
 	Healthcheck map[string]any    `json:"healthcheck"`
 	EnvSchema   []string          `json:"env_schema"`
 	Meta        map[string]string `json:"meta,omitempty"`
+	// Candidates are the frameworks the project showed signals for, highest
+	// score first; the first is the one picked unless Overridden
+	Candidates []FrameworkCandidate `json:"candidates,omitempty"`
+	Overridden bool                 `json:"overridden,omitempty"` // Framework was chosen by override, not by score
+}
+
+// FrameworkCandidate is a framework detection scored, with the signals that
+// scored it
+type FrameworkCandidate struct {
+	Framework  string   `json:"framework"`
+	Language   string   `json:"language"`
+	Score      float64  `json:"score"`
+	Confidence float64  `json:"confidence"`
+	Signals    []string `json:"signals"`
 }
 
 // Candidate is an alias for detectors.Candidate
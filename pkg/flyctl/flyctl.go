@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 )
 
@@ -59,6 +60,7 @@ type DeployOptions struct {
 	Region      string            // fly.io region
 	RemoteOnly  bool              // Use remote builder (default: true)
 	UseNixpacks bool              // Use fly.io's native nixpacks builder (recommended)
+	Metadata    map[string]string // Machine metadata, such as cost allocation labels
 }
 
 // Deploy runs flyctl deploy with remote builder
@@ -82,6 +84,15 @@ func (c *Client) Deploy(ctx context.Context, opts DeployOptions) (string, error)
 		args = append(args, "--primary-region", opts.Region)
 	}
 
+	keys := make([]string, 0, len(opts.Metadata))
+	for key := range opts.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--metadata", key+"="+opts.Metadata[key])
+	}
+
 	args = append(args, "--app", c.appName)
 
 	// Run deploy
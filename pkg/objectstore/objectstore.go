@@ -0,0 +1,116 @@
+// Package objectstore uploads files to object storage such as S3, skipping
+// objects whose content is already stored.
+package objectstore
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// syncWorkers is how many objects are checked and uploaded at once
+const syncWorkers = 8
+
+// Object is a file to store under Key
+type Object struct {
+	Key          string
+	Body         []byte
+	ContentType  string
+	CacheControl string
+}
+
+// Store is a bucket objects are uploaded to
+type Store interface {
+	// ETag returns the stored object's ETag without quotes, or "" when there
+	// is no object under key
+	ETag(ctx context.Context, key string) (string, error)
+	Put(ctx context.Context, obj Object) error
+}
+
+// SyncResult counts the objects a Sync uploaded and the ones it left alone
+type SyncResult struct {
+	Uploaded      int
+	Unchanged     int
+	UploadedBytes int64
+}
+
+func (r SyncResult) String() string {
+	return fmt.Sprintf("%d uploaded (%d bytes), %d unchanged", r.Uploaded, r.UploadedBytes, r.Unchanged)
+}
+
+// ContentETag is the ETag S3 gives an object uploaded in one request: the
+// hex MD5 of its content. Multipart uploads get other ETags, so such
+// objects are uploaded again.
+func ContentETag(body []byte) string {
+	sum := md5.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sync uploads the objects whose stored ETag differs from their content.
+// It stops at the first failure and returns what was done until then.
+func Sync(ctx context.Context, store Store, objects []Object) (SyncResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		result   SyncResult
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	queue := make(chan Object)
+	for i := 0; i < syncWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queue {
+				uploaded, err := syncObject(ctx, store, obj)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				case uploaded:
+					result.Uploaded++
+					result.UploadedBytes += int64(len(obj.Body))
+				default:
+					result.Unchanged++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- obj
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return result, firstErr
+}
+
+func syncObject(ctx context.Context, store Store, obj Object) (bool, error) {
+	etag, err := store.ETag(ctx, obj.Key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", obj.Key, err)
+	}
+	if etag == ContentETag(obj.Body) {
+		return false, nil
+	}
+	if err := store.Put(ctx, obj); err != nil {
+		return false, fmt.Errorf("failed to upload %s: %w", obj.Key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStore keeps objects in memory, optionally failing every upload
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string]Object
+	putErr  error
+}
+
+func (s *memoryStore) ETag(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return "", nil
+	}
+	return ContentETag(obj.Body), nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, obj Object) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[obj.Key] = obj
+	return nil
+}
+
+func TestSyncSkipsUnchangedObjects(t *testing.T) {
+	store := &memoryStore{objects: map[string]Object{
+		"app/a.js": {Key: "app/a.js", Body: []byte("a")},
+		"app/b.js": {Key: "app/b.js", Body: []byte("old")},
+	}}
+	objects := []Object{
+		{Key: "app/a.js", Body: []byte("a")},
+		{Key: "app/b.js", Body: []byte("new")},
+		{Key: "app/c.css", Body: []byte("body{}"), CacheControl: "public, max-age=60"},
+	}
+
+	result, err := Sync(context.Background(), store, objects)
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if result.Uploaded != 2 || result.Unchanged != 1 || result.UploadedBytes != 9 {
+		t.Errorf("Sync() = %+v, want 2 uploaded (9 bytes) and 1 unchanged", result)
+	}
+	if string(store.objects["app/b.js"].Body) != "new" || store.objects["app/c.css"].CacheControl != "public, max-age=60" {
+		t.Errorf("stored objects = %+v", store.objects)
+	}
+
+	result, err = Sync(context.Background(), store, objects)
+	if err != nil || result.Uploaded != 0 || result.Unchanged != 3 {
+		t.Errorf("second Sync() = %+v, %v, want everything unchanged", result, err)
+	}
+}
+
+func TestSyncReportsFailedUpload(t *testing.T) {
+	store := &memoryStore{objects: map[string]Object{}, putErr: errors.New("AccessDenied")}
+	_, err := Sync(context.Background(), store, []Object{{Key: "app/a.js", Body: []byte("a")}})
+	if err == nil || !strings.Contains(err.Error(), "failed to upload app/a.js: AccessDenied") {
+		t.Errorf("Sync() error = %v, want the failed key and cause", err)
+	}
+}
+
+func TestContentETag(t *testing.T) {
+	if got := ContentETag([]byte("hello")); got != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("ContentETag() = %s", got)
+	}
+}
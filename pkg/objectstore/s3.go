@@ -0,0 +1,69 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	awsprovider "lightfold/pkg/providers/aws"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Store stores objects in an S3 bucket
+type S3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Store connects to bucket with AWS credentials in the format the aws
+// provider token uses, or the default credential chain when they are empty.
+// region overrides the region the credentials configure.
+func NewS3Store(ctx context.Context, bucket, region, credentialsJSON string) (*S3Store, error) {
+	awsConfig, err := awsprovider.LoadConfig(ctx, credentialsJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS credentials: %w", err)
+	}
+	if region != "" {
+		awsConfig.Region = region
+	}
+	if awsConfig.Region == "" {
+		return nil, fmt.Errorf("no region for bucket %s; set one in the target's assets config or AWS_REGION", bucket)
+	}
+	return &S3Store{client: s3.NewFromConfig(awsConfig), bucket: bucket}, nil
+}
+
+func (s *S3Store) ETag(ctx context.Context, key string) (string, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.Trim(aws.ToString(out.ETag), `"`), nil
+}
+
+func (s *S3Store) Put(ctx context.Context, obj Object) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(obj.Key),
+		Body:   bytes.NewReader(obj.Body),
+	}
+	if obj.ContentType != "" {
+		input.ContentType = aws.String(obj.ContentType)
+	}
+	if obj.CacheControl != "" {
+		input.CacheControl = aws.String(obj.CacheControl)
+	}
+	_, err := s.client.PutObject(ctx, input)
+	return err
+}
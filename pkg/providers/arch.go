@@ -0,0 +1,23 @@
+package providers
+
+import "strings"
+
+// CPU architectures reported in Size.Architecture and Server.Architecture
+const (
+	ArchX86_64 = "x86_64"
+	ArchARM64  = "arm64"
+)
+
+// NormalizeArch maps the architecture names used by providers, `uname -m` and
+// `dpkg --print-architecture` onto ArchX86_64 or ArchARM64. Unknown names are
+// returned lowercased.
+func NormalizeArch(arch string) string {
+	switch a := strings.ToLower(strings.TrimSpace(arch)); a {
+	case "x86", "x86_64", "x64", "amd64":
+		return ArchX86_64
+	case "arm", "arm64", "aarch64", "arm64v8":
+		return ArchARM64
+	default:
+		return a
+	}
+}
@@ -17,15 +17,21 @@ var (
 	amiCacheMutex sync.RWMutex
 )
 
-// getUbuntu2204AMI resolves the Ubuntu 22.04 LTS AMI for a specific region
+// getUbuntu2204AMI resolves the Ubuntu 22.04 LTS AMI for a specific region and
+// architecture (providers.ArchX86_64 or providers.ArchARM64)
 // Uses SSM Parameter Store for latest AMI lookup with fallback to hardcoded map
-func getUbuntu2204AMI(ctx context.Context, awsConfig aws.Config, region, imageSpec string) (string, error) {
+func getUbuntu2204AMI(ctx context.Context, awsConfig aws.Config, region, imageSpec, arch string) (string, error) {
+	ssmArch := "amd64"
+	if arch == providers.ArchARM64 {
+		ssmArch = "arm64"
+	}
+
 	var ssmParameter string
 
 	if strings.Contains(imageSpec, "24.04") || strings.Contains(imageSpec, "ubuntu-24.04") {
-		ssmParameter = "/aws/service/canonical/ubuntu/server/24.04/stable/current/amd64/hvm/ebs-gp3/ami-id"
+		ssmParameter = fmt.Sprintf("/aws/service/canonical/ubuntu/server/24.04/stable/current/%s/hvm/ebs-gp3/ami-id", ssmArch)
 	} else {
-		ssmParameter = "/aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id"
+		ssmParameter = fmt.Sprintf("/aws/service/canonical/ubuntu/server/22.04/stable/current/%s/hvm/ebs-gp2/ami-id", ssmArch)
 	}
 
 	cacheKey := fmt.Sprintf("%s:%s", region, ssmParameter)
@@ -55,6 +61,20 @@ func getUbuntu2204AMI(ctx context.Context, awsConfig aws.Config, region, imageSp
 		return amiID, nil
 	}
 
+	// The hardcoded fallback only lists x86_64 AMIs
+	if ssmArch != "amd64" {
+		return "", &providers.ProviderError{
+			Provider: "aws",
+			Code:     "ami_not_found",
+			Message:  fmt.Sprintf("Ubuntu %s AMI not found for region %s", ssmArch, region),
+			Details: map[string]interface{}{
+				"region":     region,
+				"image_spec": imageSpec,
+				"error":      "No AMI found in SSM",
+			},
+		}
+	}
+
 	var amiMap map[string]string
 
 	if strings.Contains(imageSpec, "24.04") {
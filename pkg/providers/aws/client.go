@@ -29,6 +29,7 @@ package aws
 import (
 	"context"
 	"fmt"
+	"lightfold/pkg/debuglog"
 	"lightfold/pkg/providers"
 	"time"
 
@@ -37,6 +38,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
 // Register the AWS provider with the global registry
@@ -72,8 +74,43 @@ func NewClient(credentialsJSON string) *Client {
 		}
 	}
 
-	ctx := context.Background()
+	awsConfig, err := loadConfig(context.Background(), creds)
+	if err != nil {
+		return &Client{
+			creds: creds,
+		}
+	}
+
+	return &Client{
+		ec2Client: ec2.NewFromConfig(awsConfig),
+		awsConfig: awsConfig,
+		creds:     creds,
+	}
+}
+
+// LoadConfig returns the SDK configuration for credentials in the format
+// NewClient accepts, falling back to the default credential chain when they
+// are empty. Other AWS services such as S3 share it.
+func LoadConfig(ctx context.Context, credentialsJSON string) (aws.Config, error) {
+	if credentialsJSON == "" {
+		awsConfig, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return aws.Config{}, err
+		}
+		awsConfig.HTTPClient = debuglog.WrapDoer("aws", awsConfig.HTTPClient)
+		return awsConfig, nil
+	}
+
+	creds, err := parseCredentials(credentialsJSON)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	return loadConfig(ctx, creds)
+}
+
+func loadConfig(ctx context.Context, creds AWSCredentials) (aws.Config, error) {
 	var awsConfig aws.Config
+	var err error
 
 	if creds.Profile != "" {
 		awsConfig, err = config.LoadDefaultConfig(ctx,
@@ -90,18 +127,12 @@ func NewClient(credentialsJSON string) *Client {
 	} else {
 		awsConfig, err = config.LoadDefaultConfig(ctx)
 	}
-
 	if err != nil {
-		return &Client{
-			creds: creds,
-		}
+		return aws.Config{}, err
 	}
 
-	return &Client{
-		ec2Client: ec2.NewFromConfig(awsConfig),
-		awsConfig: awsConfig,
-		creds:     creds,
-	}
+	awsConfig.HTTPClient = debuglog.WrapDoer("aws", awsConfig.HTTPClient)
+	return awsConfig, nil
 }
 
 func (c *Client) Name() string {
@@ -133,20 +164,41 @@ func (c *Client) ValidateCredentials(ctx context.Context) error {
 		}
 	}
 
-	// Test credentials by listing regions
-	_, err := c.ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	_, err := c.callerIdentity(ctx)
 	if err != nil {
 		return &providers.ProviderError{
 			Provider: "aws",
 			Code:     "invalid_credentials",
 			Message:  "Invalid AWS credentials",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	return nil
 }
 
+// AccountInfo returns the account ID and ARN the credentials belong to
+func (c *Client) AccountInfo(ctx context.Context) (string, error) {
+	if c.ec2Client == nil {
+		return "", fmt.Errorf("AWS client not initialized")
+	}
+	identity, err := c.callerIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("account %s (%s)", aws.ToString(identity.Account), aws.ToString(identity.Arn)), nil
+}
+
+// callerIdentity asks STS who the credentials belong to; it needs no IAM permissions
+func (c *Client) callerIdentity(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+	cfg := c.awsConfig.Copy()
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+}
+
 func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 	output, err := c.ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{
 		AllRegions: aws.Bool(false),
@@ -157,6 +209,7 @@ func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 			Code:     "list_regions_failed",
 			Message:  "Failed to list AWS regions",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -212,6 +265,33 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 			PriceMonthly: 60.00,
 			PriceHourly:  0.0832,
 		},
+		{
+			ID:           "t4g.small",
+			Name:         "t4g.small (2 vCPUs, 2 GB RAM) - Burstable, Graviton",
+			Memory:       2048,
+			VCPUs:        2,
+			Disk:         8,
+			PriceMonthly: 12.26,
+			PriceHourly:  0.0168,
+		},
+		{
+			ID:           "t4g.medium",
+			Name:         "t4g.medium (2 vCPUs, 4 GB RAM) - Burstable, Graviton",
+			Memory:       4096,
+			VCPUs:        2,
+			Disk:         8,
+			PriceMonthly: 24.53,
+			PriceHourly:  0.0336,
+		},
+		{
+			ID:           "t4g.large",
+			Name:         "t4g.large (2 vCPUs, 8 GB RAM) - Burstable, Graviton",
+			Memory:       8192,
+			VCPUs:        2,
+			Disk:         8,
+			PriceMonthly: 49.06,
+			PriceHourly:  0.0672,
+		},
 		{
 			ID:           "m5.large",
 			Name:         "m5.large (2 vCPUs, 8 GB RAM) - General Purpose",
@@ -230,6 +310,15 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 			PriceMonthly: 140.00,
 			PriceHourly:  0.192,
 		},
+		{
+			ID:           "m7g.large",
+			Name:         "m7g.large (2 vCPUs, 8 GB RAM) - General Purpose, Graviton",
+			Memory:       8192,
+			VCPUs:        2,
+			Disk:         8,
+			PriceMonthly: 59.57,
+			PriceHourly:  0.0816,
+		},
 		{
 			ID:           "c5.large",
 			Name:         "c5.large (2 vCPUs, 4 GB RAM) - Compute Optimized",
@@ -241,6 +330,10 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 		},
 	}
 
+	for i := range sizes {
+		sizes[i].Architecture = instanceTypeArchitecture(sizes[i].ID)
+	}
+
 	return sizes, nil
 }
 
@@ -274,6 +367,7 @@ func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*pro
 			Code:     "upload_ssh_key_failed",
 			Message:  "Failed to upload SSH key to AWS",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -297,10 +391,11 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "region_client_failed",
 			Message:  fmt.Sprintf("Failed to create client for region %s", config.Region),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
-	amiID, err := getUbuntu2204AMI(ctx, c.awsConfig, config.Region, config.Image)
+	amiID, err := getUbuntu2204AMI(ctx, c.awsConfig, config.Region, config.Image, instanceTypeArchitecture(config.Size))
 	if err != nil {
 		return nil, err
 	}
@@ -339,6 +434,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			})
 		}
 	}
+	tagSpecs[0].Tags = append(tagSpecs[0].Tags, labelTags(config.Labels)...)
 
 	var runOutput *ec2.RunInstancesOutput
 	runInput := &ec2.RunInstancesInput{
@@ -384,7 +480,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 	instanceID := aws.ToString(instance.InstanceId)
 
 	// Allocate and associate Elastic IP if requested
-	var eipAllocationID string
+	var eipAllocationID, eipPublicIP string
 	if config.Metadata["elastic_ip"] == "allocate" {
 		// Wait for instance to be running before associating Elastic IP
 		waiter := ec2.NewInstanceRunningWaiter(regionClient)
@@ -395,11 +491,12 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			fmt.Printf("Warning: Failed to wait for instance before EIP allocation: %v\n", err)
 		} else {
 			// Allocate Elastic IP
-			allocationID, err := allocateElasticIP(ctx, regionClient, config.Name)
+			allocationID, publicIP, err := allocateElasticIP(ctx, regionClient, config.Name)
 			if err != nil {
 				fmt.Printf("Warning: Failed to allocate Elastic IP: %v\n", err)
 			} else {
 				eipAllocationID = allocationID
+				eipPublicIP = publicIP
 
 				// Associate Elastic IP with instance
 				_, err = associateElasticIP(ctx, regionClient, instanceID, allocationID)
@@ -408,6 +505,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 					// Try to release the EIP since association failed
 					_ = releaseElasticIP(ctx, regionClient, allocationID)
 					eipAllocationID = ""
+					eipPublicIP = ""
 				} else {
 					// Refresh instance data to get the new public IP
 					refreshedOutput, err := regionClient.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
@@ -430,6 +528,8 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 	server.Metadata["subnet_id"] = subnetID
 	if eipAllocationID != "" {
 		server.Metadata["elastic_ip_allocation_id"] = eipAllocationID
+		// The refreshed instance may still report the ephemeral address
+		server.PublicIPv4 = eipPublicIP
 	}
 
 	return server, nil
@@ -451,6 +551,7 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "get_instance_failed",
 			Message:  "Failed to get EC2 instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -462,7 +563,23 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 		}
 	}
 
-	return convertInstanceToServer(&output.Reservations[0].Instances[0]), nil
+	server := convertInstanceToServer(&output.Reservations[0].Instances[0])
+
+	// The Elastic IP is the address to record: it survives stops, while the
+	// instance's own public IP is released when it stops
+	allocationID, publicIP, err := findElasticIP(ctx, c.ec2Client, serverID)
+	if err == nil && allocationID != "" {
+		server.Metadata["elastic_ip_allocation_id"] = allocationID
+		server.PublicIPv4 = publicIP
+	}
+
+	return server, nil
+}
+
+// ReleaseStaticIP releases an Elastic IP allocated by lightfold. Releasing an
+// address that is already gone is not an error.
+func (c *Client) ReleaseStaticIP(ctx context.Context, allocationID string) error {
+	return releaseElasticIP(ctx, c.ec2Client, allocationID)
 }
 
 // Destroy terminates an EC2 instance and cleans up associated resources.
@@ -489,6 +606,7 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "terminate_instance_failed",
 			Message:  "Failed to terminate EC2 instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -514,6 +632,41 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	return nil
 }
 
+// PowerOff stops the EC2 instance. Stopped instances are not billed for
+// compute, and their public IP is released unless an Elastic IP is attached.
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	_, err := c.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{serverID},
+	})
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "aws",
+			Code:     "stop_instance_failed",
+			Message:  "Failed to stop EC2 instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+// PowerOn starts a stopped EC2 instance
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	_, err := c.ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+		InstanceIds: []string{serverID},
+	})
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "aws",
+			Code:     "start_instance_failed",
+			Message:  "Failed to start EC2 instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
 // WaitForActive waits for an EC2 instance to reach the "running" state.
 // Uses AWS SDK's built-in waiter with exponential backoff polling.
 //
@@ -531,6 +684,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 			Code:     "timeout",
 			Message:  fmt.Sprintf("Timeout waiting for instance to become active (waited %s)", timeout.String()),
 			Details:  map[string]interface{}{"timeout": timeout.String(), "error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -11,8 +11,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
-// allocateElasticIP allocates a new Elastic IP address
-func allocateElasticIP(ctx context.Context, client *ec2.Client, targetName string) (string, error) {
+// allocateElasticIP allocates a new Elastic IP address, returning its allocation ID and public IP
+func allocateElasticIP(ctx context.Context, client *ec2.Client, targetName string) (string, string, error) {
 	output, err := client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
 		Domain: types.DomainTypeVpc,
 		TagSpecifications: []types.TagSpecification{
@@ -28,15 +28,16 @@ func allocateElasticIP(ctx context.Context, client *ec2.Client, targetName strin
 	})
 
 	if err != nil {
-		return "", &providers.ProviderError{
+		return "", "", &providers.ProviderError{
 			Provider: "aws",
 			Code:     "allocate_eip_failed",
 			Message:  "Failed to allocate Elastic IP",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
-	return aws.ToString(output.AllocationId), nil
+	return aws.ToString(output.AllocationId), aws.ToString(output.PublicIp), nil
 }
 
 // associateElasticIP associates an Elastic IP with an EC2 instance
@@ -62,6 +63,36 @@ func associateElasticIP(ctx context.Context, client *ec2.Client, instanceID, all
 	return aws.ToString(output.AssociationId), nil
 }
 
+// findElasticIP returns the Elastic IP associated with an instance, or empty
+// strings when it has none. Stopped instances keep their association, so this
+// is how their address is reported.
+func findElasticIP(ctx context.Context, client *ec2.Client, instanceID string) (allocationID, publicIP string, err error) {
+	output, err := client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("instance-id"),
+				Values: []string{instanceID},
+			},
+		},
+	})
+	if err != nil {
+		return "", "", &providers.ProviderError{
+			Provider: "aws",
+			Code:     "describe_addresses_failed",
+			Message:  "Failed to describe Elastic IPs",
+			Details:  map[string]interface{}{"error": err.Error(), "instance_id": instanceID},
+			Err:      err,
+		}
+	}
+
+	for _, address := range output.Addresses {
+		if address.AllocationId != nil {
+			return aws.ToString(address.AllocationId), aws.ToString(address.PublicIp), nil
+		}
+	}
+	return "", "", nil
+}
+
 // releaseElasticIP releases an Elastic IP address
 // Note: EC2 instance termination automatically disassociates EIPs,
 // so explicit disassociation is not needed before release.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"lightfold/pkg/providers"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -72,20 +73,38 @@ func convertInstanceToServer(instance *types.Instance) *providers.Server {
 	}
 
 	return &providers.Server{
-		ID:          aws.ToString(instance.InstanceId),
-		Name:        instanceName,
-		Status:      string(instance.State.Name),
-		PublicIPv4:  publicIPv4,
-		PrivateIPv4: privateIPv4,
-		Region:      aws.ToString(instance.Placement.AvailabilityZone),
-		Size:        string(instance.InstanceType),
-		Image:       aws.ToString(instance.ImageId),
-		Tags:        tagsList,
-		CreatedAt:   createdAt,
-		Metadata:    metadata,
+		ID:           aws.ToString(instance.InstanceId),
+		Name:         instanceName,
+		Status:       string(instance.State.Name),
+		PublicIPv4:   publicIPv4,
+		PublicIPv6:   aws.ToString(instance.Ipv6Address),
+		PrivateIPv4:  privateIPv4,
+		Region:       aws.ToString(instance.Placement.AvailabilityZone),
+		Size:         string(instance.InstanceType),
+		Image:        aws.ToString(instance.ImageId),
+		Architecture: providers.NormalizeArch(string(instance.Architecture)),
+		Tags:         tagsList,
+		CreatedAt:    createdAt,
+		Metadata:     metadata,
 	}
 }
 
+// instanceTypeArchitecture reports whether an instance type runs on Graviton
+// (arm64) or x86_64. Graviton families carry a "g" after the generation
+// number (t4g, m7g, c6gn, x2gd) apart from the original a1.
+func instanceTypeArchitecture(instanceType string) string {
+	family, _, _ := strings.Cut(strings.ToLower(instanceType), ".")
+	if family == "a1" {
+		return providers.ArchARM64
+	}
+
+	generation := strings.IndexAny(family, "0123456789")
+	if generation >= 0 && strings.Contains(family[generation+1:], "g") {
+		return providers.ArchARM64
+	}
+	return providers.ArchX86_64
+}
+
 func findDefaultVPCAndSubnet(ctx context.Context, client *ec2.Client, region string) (vpcID string, subnetID string, err error) {
 	vpcOutput, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
 		Filters: []types.Filter{
@@ -101,6 +120,7 @@ func findDefaultVPCAndSubnet(ctx context.Context, client *ec2.Client, region str
 			Code:     "describe_vpcs_failed",
 			Message:  "Failed to describe VPCs",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -132,6 +152,7 @@ func findDefaultVPCAndSubnet(ctx context.Context, client *ec2.Client, region str
 			Code:     "describe_subnets_failed",
 			Message:  "Failed to describe subnets",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -0,0 +1,25 @@
+package aws
+
+import (
+	"lightfold/pkg/providers"
+	"testing"
+)
+
+func TestInstanceTypeArchitecture(t *testing.T) {
+	tests := map[string]string{
+		"t3.micro":    providers.ArchX86_64,
+		"m5.large":    providers.ArchX86_64,
+		"g4dn.xlarge": providers.ArchX86_64,
+		"t4g.small":   providers.ArchARM64,
+		"m7g.large":   providers.ArchARM64,
+		"c6gn.large":  providers.ArchARM64,
+		"x2gd.medium": providers.ArchARM64,
+		"a1.medium":   providers.ArchARM64,
+	}
+
+	for instanceType, want := range tests {
+		if got := instanceTypeArchitecture(instanceType); got != want {
+			t.Errorf("instanceTypeArchitecture(%q) = %q, want %q", instanceType, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"context"
+	"lightfold/pkg/providers"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// AWS tag keys are up to 128 characters and values up to 256
+const (
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// reservedTagKeys are the instance tags lightfold sets itself
+var reservedTagKeys = map[string]bool{"Name": true}
+
+// labelTags translates labels to EC2 tags. Tags allow letters, digits,
+// spaces and + - = . _ : / @; other characters become '_'. Labels named like
+// a tag lightfold sets itself are left out.
+func labelTags(labels map[string]string) []types.Tag {
+	tags := make([]types.Tag, 0, len(labels))
+	for _, key := range providers.LabelKeys(labels) {
+		if reservedTagKeys[key] || strings.HasPrefix(strings.ToLower(key), "aws:") {
+			continue
+		}
+		tags = append(tags, types.Tag{
+			Key:   aws.String(cleanTag(key, maxTagKeyLength)),
+			Value: aws.String(cleanTag(labels[key], maxTagValueLength)),
+		})
+	}
+	return tags
+}
+
+func cleanTag(s string, maxLength int) string {
+	s = providers.ReplaceChars(s, func(r rune) bool {
+		return providers.IsASCIIAlnum(r) || strings.ContainsRune(" +-=._:/@", r)
+	}, '_')
+	if len(s) > maxLength {
+		s = s[:maxLength]
+	}
+	return s
+}
+
+// SetLabels tags the instance with labels and deletes the tags of previous
+// labels that are no longer set
+func (c *Client) SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error {
+	tags := labelTags(labels)
+	keep := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		keep[aws.ToString(tag.Key)] = true
+	}
+
+	var removed []types.Tag
+	for _, tag := range labelTags(previous) {
+		if !keep[aws.ToString(tag.Key)] {
+			removed = append(removed, types.Tag{Key: tag.Key})
+		}
+	}
+	if len(removed) > 0 {
+		if _, err := c.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{serverID}, Tags: removed}); err != nil {
+			return retagError(err)
+		}
+	}
+	if len(tags) > 0 {
+		if _, err := c.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{serverID}, Tags: tags}); err != nil {
+			return retagError(err)
+		}
+	}
+	return nil
+}
+
+func retagError(err error) error {
+	return &providers.ProviderError{
+		Provider: "aws",
+		Code:     "retag_failed",
+		Message:  "Failed to update EC2 instance tags",
+		Details:  map[string]interface{}{"error": err.Error()},
+		Err:      err,
+	}
+}
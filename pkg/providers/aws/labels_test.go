@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestLabelTags(t *testing.T) {
+	tags := labelTags(map[string]string{
+		"team":   "payments",
+		"owner":  "ops@example.com",
+		"note":   "R&D (EU)",
+		"Name":   "override",
+		"budget": strings.Repeat("9", 300),
+	})
+
+	got := map[string]string{}
+	for _, tag := range tags {
+		got[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	want := map[string]string{
+		"team":   "payments",
+		"owner":  "ops@example.com",
+		"note":   "R_D _EU_",
+		"budget": strings.Repeat("9", maxTagValueLength),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("labelTags() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("tag %s = %q, want %q", key, got[key], value)
+		}
+	}
+	if _, ok := got["Name"]; ok {
+		t.Error("a Name label must not replace the instance name tag")
+	}
+}
@@ -12,6 +12,25 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
+// ingressRules are the port ranges opened to the internet: SSH for deploys,
+// HTTP/HTTPS for nginx, and the app port range used for direct access when
+// several apps share a server without a domain. A rule the user restricted to
+// some sources on a reused group is kept as is when keepRestricted is set.
+var ingressRules = []struct {
+	fromPort       int32
+	toPort         int32
+	description    string
+	keepRestricted bool
+}{
+	{22, 22, "SSH access", true},
+	{80, 80, "HTTP access", false},
+	{443, 443, "HTTPS access", false},
+	{3000, 9000, "Application ports", false},
+}
+
+// createSecurityGroup creates the target's lightfold security group, or reuses
+// the one left by an earlier provisioning attempt, and makes sure it allows
+// every rule in ingressRules
 func createSecurityGroup(ctx context.Context, client *ec2.Client, vpcID, targetName string) (string, error) {
 	groupName := fmt.Sprintf("lightfold-%s", targetName)
 	description := fmt.Sprintf("Security group for Lightfold deployment: %s", targetName)
@@ -30,7 +49,18 @@ func createSecurityGroup(ctx context.Context, client *ec2.Client, vpcID, targetN
 	})
 
 	if err == nil && len(existingGroups.SecurityGroups) > 0 {
-		return aws.ToString(existingGroups.SecurityGroups[0].GroupId), nil
+		group := existingGroups.SecurityGroups[0]
+		securityGroupID := aws.ToString(group.GroupId)
+		missing, restricted := missingIngressPermissions(group.IpPermissions)
+		for _, rule := range restricted {
+			fmt.Printf("Warning: security group %s only allows %s from some sources; lightfold leaves it restricted, so make sure this machine is one of them\n", groupName, rule)
+		}
+		if len(missing) > 0 {
+			if err := authorizeIngress(ctx, client, securityGroupID, missing); err != nil {
+				return "", err
+			}
+		}
+		return securityGroupID, nil
 	}
 
 	createOutput, err := client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
@@ -55,72 +85,103 @@ func createSecurityGroup(ctx context.Context, client *ec2.Client, vpcID, targetN
 			Code:     "create_security_group_failed",
 			Message:  "Failed to create security group",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	securityGroupID := aws.ToString(createOutput.GroupId)
 
-	_, err = client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(securityGroupID),
-		IpPermissions: []types.IpPermission{
-			{
-				IpProtocol: aws.String("tcp"),
-				FromPort:   aws.Int32(22),
-				ToPort:     aws.Int32(22),
-				IpRanges: []types.IpRange{
-					{
-						CidrIp:      aws.String("0.0.0.0/0"),
-						Description: aws.String("SSH access"),
-					},
-				},
-			},
-			{
-				IpProtocol: aws.String("tcp"),
-				FromPort:   aws.Int32(80),
-				ToPort:     aws.Int32(80),
-				IpRanges: []types.IpRange{
-					{
-						CidrIp:      aws.String("0.0.0.0/0"),
-						Description: aws.String("HTTP access"),
-					},
-				},
-			},
-			{
-				IpProtocol: aws.String("tcp"),
-				FromPort:   aws.Int32(443),
-				ToPort:     aws.Int32(443),
-				IpRanges: []types.IpRange{
-					{
-						CidrIp:      aws.String("0.0.0.0/0"),
-						Description: aws.String("HTTPS access"),
-					},
-				},
-			},
-			{
-				IpProtocol: aws.String("tcp"),
-				FromPort:   aws.Int32(3000),
-				ToPort:     aws.Int32(9000),
-				IpRanges: []types.IpRange{
-					{
-						CidrIp:      aws.String("0.0.0.0/0"),
-						Description: aws.String("Application ports"),
-					},
+	allRules, _ := missingIngressPermissions(nil)
+	if err := authorizeIngress(ctx, client, securityGroupID, allRules); err != nil {
+		_ = deleteSecurityGroup(ctx, client, securityGroupID)
+		return "", err
+	}
+
+	return securityGroupID, nil
+}
+
+// missingIngressPermissions returns the lightfold ingress rules not already
+// open to 0.0.0.0/0 in a group's permissions. Rules marked keepRestricted that
+// the group allows from other sources are not widened; their descriptions are
+// returned in restricted instead.
+func missingIngressPermissions(existing []types.IpPermission) (missing []types.IpPermission, restricted []string) {
+	for _, ingress := range ingressRules {
+		if ingressOpen(existing, ingress.fromPort, ingress.toPort) {
+			continue
+		}
+		if ingress.keepRestricted && ingressAllowed(existing, ingress.fromPort, ingress.toPort) {
+			restricted = append(restricted, fmt.Sprintf("%s (port %d)", ingress.description, ingress.fromPort))
+			continue
+		}
+		missing = append(missing, types.IpPermission{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int32(ingress.fromPort),
+			ToPort:     aws.Int32(ingress.toPort),
+			IpRanges: []types.IpRange{
+				{
+					CidrIp:      aws.String("0.0.0.0/0"),
+					Description: aws.String(ingress.description),
 				},
 			},
-		},
-	})
+		})
+	}
+	return missing, restricted
+}
 
-	if err != nil {
-		_ = deleteSecurityGroup(ctx, client, securityGroupID)
-		return "", &providers.ProviderError{
+// ingressOpen reports whether a single permission already opens the whole port range to 0.0.0.0/0
+func ingressOpen(permissions []types.IpPermission, fromPort, toPort int32) bool {
+	for _, permission := range permissions {
+		if !permissionCovers(permission, fromPort, toPort) {
+			continue
+		}
+		for _, ipRange := range permission.IpRanges {
+			if aws.ToString(ipRange.CidrIp) == "0.0.0.0/0" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ingressAllowed reports whether any permission allows the port range from any
+// source: an address range, an IPv6 range, another security group or a
+// prefix list
+func ingressAllowed(permissions []types.IpPermission, fromPort, toPort int32) bool {
+	for _, permission := range permissions {
+		if !permissionCovers(permission, fromPort, toPort) {
+			continue
+		}
+		if len(permission.IpRanges) > 0 || len(permission.Ipv6Ranges) > 0 || len(permission.UserIdGroupPairs) > 0 || len(permission.PrefixListIds) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionCovers reports whether a TCP or all-traffic permission spans the whole port range
+func permissionCovers(permission types.IpPermission, fromPort, toPort int32) bool {
+	protocol := aws.ToString(permission.IpProtocol)
+	if protocol == "-1" {
+		return true
+	}
+	return protocol == "tcp" && aws.ToInt32(permission.FromPort) <= fromPort && aws.ToInt32(permission.ToPort) >= toPort
+}
+
+func authorizeIngress(ctx context.Context, client *ec2.Client, securityGroupID string, permissions []types.IpPermission) error {
+	_, err := client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(securityGroupID),
+		IpPermissions: permissions,
+	})
+	if err != nil && !strings.Contains(err.Error(), "InvalidPermission.Duplicate") {
+		return &providers.ProviderError{
 			Provider: "aws",
 			Code:     "authorize_ingress_failed",
 			Message:  "Failed to authorize security group ingress rules",
-			Details:  map[string]interface{}{"error": err.Error()},
+			Details:  map[string]interface{}{"error": err.Error(), "security_group_id": securityGroupID},
+			Err:      err,
 		}
 	}
-
-	return securityGroupID, nil
+	return nil
 }
 
 func deleteSecurityGroup(ctx context.Context, client *ec2.Client, securityGroupID string) error {
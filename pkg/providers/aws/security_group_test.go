@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func openRange(protocol string, from, to int32, cidr string) types.IpPermission {
+	return types.IpPermission{
+		IpProtocol: aws.String(protocol),
+		FromPort:   aws.Int32(from),
+		ToPort:     aws.Int32(to),
+		IpRanges:   []types.IpRange{{CidrIp: aws.String(cidr)}},
+	}
+}
+
+func TestMissingIngressPermissions(t *testing.T) {
+	if missing, restricted := missingIngressPermissions(nil); len(missing) != len(ingressRules) || len(restricted) != 0 {
+		t.Fatalf("Expected every rule for a new group, got %d", len(missing))
+	}
+
+	// A reused group that only allows SSH from one address keeps it that way
+	existing := []types.IpPermission{
+		openRange("tcp", 22, 22, "198.51.100.7/32"),
+		openRange("tcp", 80, 80, "0.0.0.0/0"),
+		openRange("tcp", 3000, 3999, "0.0.0.0/0"),
+	}
+	missing, restricted := missingIngressPermissions(existing)
+
+	var ports []int32
+	for _, permission := range missing {
+		ports = append(ports, aws.ToInt32(permission.FromPort))
+	}
+	if len(ports) != 2 || ports[0] != 443 || ports[1] != 3000 {
+		t.Errorf("Expected rules for 443 and 3000-9000, got from-ports %v", ports)
+	}
+	if len(restricted) != 1 || restricted[0] != "SSH access (port 22)" {
+		t.Errorf("Expected SSH reported as restricted, got %v", restricted)
+	}
+
+	// SSH allowed from another security group (e.g. a bastion) counts too
+	fromGroup := []types.IpPermission{{
+		IpProtocol:       aws.String("tcp"),
+		FromPort:         aws.Int32(22),
+		ToPort:           aws.Int32(22),
+		UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-bastion")}},
+	}}
+	if missing, _ := missingIngressPermissions(fromGroup); len(missing) != len(ingressRules)-1 || aws.ToInt32(missing[0].FromPort) == 22 {
+		t.Errorf("Expected SSH from a security group to be kept, got %d missing", len(missing))
+	}
+
+	allTraffic := []types.IpPermission{{IpProtocol: aws.String("-1"), IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}}
+	if missing, restricted := missingIngressPermissions(allTraffic); len(missing) != 0 || len(restricted) != 0 {
+		t.Errorf("Expected an all-traffic rule to cover everything, got %d missing", len(missing))
+	}
+}
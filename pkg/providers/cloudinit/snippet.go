@@ -0,0 +1,254 @@
+package cloudinit
+
+import (
+	"encoding/base64"
+	"fmt"
+	"lightfold/pkg/util"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// setupCompleteMarker is the last runcmd entry; user commands run before it so
+// the server is not reported ready until they finish
+const setupCompleteMarker = "lightfold-setup-complete"
+
+// UserDataSizeLimits holds the user data size limits (in bytes) of providers that enforce one
+var UserDataSizeLimits = map[string]int{
+	"digitalocean": 64 * 1024,
+	"hetzner":      32 * 1024,
+	"aws":          16 * 1024,
+}
+
+// Snippet holds extra cloud-init entries supplied by the user, merged into the
+// generated user data as additional write_files and runcmd entries
+type Snippet struct {
+	WriteFiles []yaml.MapSlice
+	RunCmd     []interface{}
+}
+
+// LoadSnippet reads and validates a cloud-init snippet from a local YAML file
+func LoadSnippet(filePath string) (*Snippet, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user data file: %w", err)
+	}
+
+	snippet, err := ParseSnippet(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user data file %s: %w", filePath, err)
+	}
+	return snippet, nil
+}
+
+// ParseSnippet parses a cloud-init snippet. Only write_files and runcmd are supported.
+func ParseSnippet(data []byte) (*Snippet, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("not valid YAML: %w", err)
+	}
+
+	snippet := &Snippet{}
+	for _, item := range doc {
+		key, _ := item.Key.(string)
+		switch key {
+		case "write_files":
+			files, ok := item.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("write_files must be a list")
+			}
+			for i, file := range files {
+				entry, ok := file.(yaml.MapSlice)
+				if !ok {
+					return nil, fmt.Errorf("write_files entry %d must be a mapping", i+1)
+				}
+				if p, _ := mapValue(entry, "path").(string); !path.IsAbs(p) {
+					return nil, fmt.Errorf("write_files entry %d needs an absolute path", i+1)
+				}
+				snippet.WriteFiles = append(snippet.WriteFiles, entry)
+			}
+		case "runcmd":
+			commands, ok := item.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("runcmd must be a list")
+			}
+			for i, command := range commands {
+				if _, err := commandLine(command); err != nil {
+					return nil, fmt.Errorf("runcmd entry %d: %w", i+1, err)
+				}
+			}
+			snippet.RunCmd = append(snippet.RunCmd, commands...)
+		default:
+			return nil, fmt.Errorf("unsupported key %q (only write_files and runcmd are merged)", key)
+		}
+	}
+
+	return snippet, nil
+}
+
+// MergeSnippet appends the snippet's entries to generated user data and checks
+// that the result is still a valid cloud-config document
+func MergeSnippet(userData string, snippet *Snippet) (string, error) {
+	if snippet == nil || (len(snippet.WriteFiles) == 0 && len(snippet.RunCmd) == 0) {
+		return userData, nil
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(userData), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse generated cloud-init: %w", err)
+	}
+
+	if len(snippet.WriteFiles) > 0 {
+		var files []interface{}
+		if existing, ok := mapValue(doc, "write_files").([]interface{}); ok {
+			files = existing
+		}
+		for _, file := range snippet.WriteFiles {
+			files = append(files, file)
+		}
+		doc = setMapValue(doc, "write_files", files)
+	}
+
+	if len(snippet.RunCmd) > 0 {
+		existing, _ := mapValue(doc, "runcmd").([]interface{})
+
+		// Keep the setup-complete marker last
+		insertAt := len(existing)
+		if insertAt > 0 {
+			if last, ok := existing[insertAt-1].(string); ok && strings.Contains(last, setupCompleteMarker) {
+				insertAt--
+			}
+		}
+
+		commands := make([]interface{}, 0, len(existing)+len(snippet.RunCmd))
+		commands = append(commands, existing[:insertAt]...)
+		commands = append(commands, snippet.RunCmd...)
+		commands = append(commands, existing[insertAt:]...)
+		doc = setMapValue(doc, "runcmd", commands)
+	}
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("failed to render merged cloud-init: %w", err)
+	}
+	merged := "#cloud-config\n" + string(out)
+
+	var check yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(merged), &check); err != nil {
+		return "", fmt.Errorf("merged cloud-init is not valid YAML: %w", err)
+	}
+
+	return merged, nil
+}
+
+// ValidateUserDataSize checks user data against the provider's size limit
+func ValidateUserDataSize(provider, userData string) error {
+	limit, ok := UserDataSizeLimits[provider]
+	if !ok {
+		return nil
+	}
+	if len(userData) > limit {
+		return fmt.Errorf("cloud-init user data is %d bytes, over the %s limit of %d bytes", len(userData), provider, limit)
+	}
+	return nil
+}
+
+// Script renders the snippet as a bash script for servers without cloud-init
+// (BYOS and existing servers). It must run as root.
+func (s *Snippet) Script() (string, error) {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\nset -euo pipefail\n")
+
+	for _, file := range s.WriteFiles {
+		filePath, _ := mapValue(file, "path").(string)
+		content := fmt.Sprint(valueOr(mapValue(file, "content"), ""))
+
+		switch encoding := fmt.Sprint(valueOr(mapValue(file, "encoding"), "")); encoding {
+		case "", "text/plain":
+		case "b64", "base64":
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+			if err != nil {
+				return "", fmt.Errorf("failed to decode %s: %w", filePath, err)
+			}
+			content = string(decoded)
+		default:
+			return "", fmt.Errorf("encoding %q for %s is not supported outside cloud-init", encoding, filePath)
+		}
+
+		redirect := ">"
+		if appendFlag, _ := mapValue(file, "append").(bool); appendFlag {
+			redirect = ">>"
+		}
+
+		permissions := fmt.Sprint(valueOr(mapValue(file, "permissions"), "0644"))
+		owner := fmt.Sprint(valueOr(mapValue(file, "owner"), "root:root"))
+
+		fmt.Fprintf(&b, "mkdir -p %s\n", util.ShellQuote(path.Dir(filePath)))
+		fmt.Fprintf(&b, "echo %s | base64 -d %s %s\n",
+			util.ShellQuote(base64.StdEncoding.EncodeToString([]byte(content))), redirect, util.ShellQuote(filePath))
+		fmt.Fprintf(&b, "chmod %s %s\n", util.ShellQuote(permissions), util.ShellQuote(filePath))
+		fmt.Fprintf(&b, "chown %s %s\n", util.ShellQuote(owner), util.ShellQuote(filePath))
+	}
+
+	for _, command := range s.RunCmd {
+		line, err := commandLine(command)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return b.String(), nil
+}
+
+// commandLine converts a runcmd entry to a shell command. Strings run through
+// the shell as-is; lists are treated as argv, as cloud-init does.
+func commandLine(command interface{}) (string, error) {
+	switch c := command.(type) {
+	case string:
+		return c, nil
+	case []interface{}:
+		if len(c) == 0 {
+			return "", fmt.Errorf("empty command list")
+		}
+		args := make([]string, 0, len(c))
+		for _, arg := range c {
+			switch arg.(type) {
+			case yaml.MapSlice, []interface{}, nil:
+				return "", fmt.Errorf("command arguments must be scalars")
+			}
+			args = append(args, util.ShellQuote(fmt.Sprint(arg)))
+		}
+		return strings.Join(args, " "), nil
+	default:
+		return "", fmt.Errorf("must be a string or a list of arguments")
+	}
+}
+
+func mapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
+
+func valueOr(value, fallback interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	return value
+}
@@ -3,6 +3,7 @@ package cloudinit
 import (
 	"bytes"
 	"fmt"
+	configpkg "lightfold/pkg/config"
 	"strings"
 	"text/template"
 )
@@ -11,7 +12,9 @@ import (
 type UserData struct {
 	Username  string            `json:"username"`
 	PublicKey string            `json:"public_key"`
+	ExtraKeys []string          `json:"extra_keys"`
 	AppName   string            `json:"app_name"`
+	AppDir    string            `json:"app_dir"` // Where the app is deployed; /srv/<app> when empty
 	Packages  []string          `json:"packages"`
 	Commands  []string          `json:"commands"`
 	UFWRules  []string          `json:"ufw_rules"`
@@ -36,6 +39,9 @@ users:
     shell: /bin/bash
     ssh_authorized_keys:
       - {{.PublicKey}}
+{{- range .ExtraKeys}}
+      - {{.}}
+{{- end}}
 
 packages:
 {{- range .Packages}}
@@ -57,12 +63,12 @@ write_files:
 {{- end}}
 
 runcmd:
-  - mkdir -p /srv/{{.AppName}}/releases
-  - mkdir -p /srv/{{.AppName}}/shared/logs
-  - mkdir -p /srv/{{.AppName}}/shared/uploads
-  - mkdir -p /srv/{{.AppName}}/shared/config
-  - mkdir -p /srv/{{.AppName}}/shared/env
-  - chown -R {{.Username}}:{{.Username}} /srv/{{.AppName}}
+  - mkdir -p {{.AppDir}}/releases
+  - mkdir -p {{.AppDir}}/shared/logs
+  - mkdir -p {{.AppDir}}/shared/uploads
+  - mkdir -p {{.AppDir}}/shared/config
+  - mkdir -p {{.AppDir}}/shared/env
+  - chown -R {{.Username}}:{{.Username}} {{.AppDir}}
 
 {{- range .UFWRules}}
   - {{.}}
@@ -90,6 +96,10 @@ func GenerateUserData(config UserData) (string, error) {
 		config.AppName = "app"
 	}
 
+	if config.AppDir == "" {
+		config.AppDir = configpkg.AppDir("", config.AppName)
+	}
+
 	if len(config.Packages) == 0 {
 		config.Packages = getDefaultPackages()
 	}
@@ -118,14 +128,21 @@ func GenerateUserData(config UserData) (string, error) {
 }
 
 // GenerateWebAppUserData creates cloud-init configuration for web applications
-func GenerateWebAppUserData(username, publicKey, appName string) (string, error) {
+// deployed to appDir ("" for /srv/<app>).
+// Any extra keys (e.g. teammates) are authorized for the deploy user alongside publicKey.
+func GenerateWebAppUserData(username, publicKey, appName, appDir string, extraKeys ...string) (string, error) {
+	if appDir == "" {
+		appDir = configpkg.AppDir("", appName)
+	}
 	config := UserData{
 		Username:  username,
 		PublicKey: publicKey,
+		ExtraKeys: dedupeKeys(publicKey, extraKeys),
 		AppName:   appName,
+		AppDir:    appDir,
 		Packages:  getDefaultPackages(),
 		UFWRules:  getDefaultUFWRules(),
-		Commands:  getDefaultCommands(username, appName),
+		Commands:  getDefaultCommands(username, appDir),
 	}
 
 	return GenerateUserData(config)
@@ -191,7 +208,7 @@ func getBasicUFWRules() []string {
 	}
 }
 
-func getDefaultCommands(username, appName string) []string {
+func getDefaultCommands(username, appDir string) []string {
 	return []string{
 		"systemctl enable nginx",
 		"systemctl start nginx",
@@ -207,8 +224,23 @@ func getDefaultCommands(username, appName string) []string {
 		fmt.Sprintf("usermod -aG docker %s", username),
 		"systemctl enable fail2ban",
 		"systemctl start fail2ban",
-		fmt.Sprintf("echo 'export PATH=\"$PATH:/srv/%s/current\"' >> /home/%s/.bashrc", appName, username),
+		fmt.Sprintf("echo 'export PATH=\"$PATH:%s/current\"' >> /home/%s/.bashrc", appDir, username),
+	}
+}
+
+// dedupeKeys drops empty entries and keys already present (including the primary key)
+func dedupeKeys(primary string, keys []string) []string {
+	seen := map[string]bool{strings.TrimSpace(primary): true}
+	var result []string
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
 	}
+	return result
 }
 
 func indentText(indent int, text string) string {
@@ -260,6 +292,7 @@ func ValidateUserData(config UserData) error {
 func AddNginxConfig(config *UserData, domain, appPort string) {
 	nginxConfig := fmt.Sprintf(`server {
     listen 80;
+    listen [::]:80;
     server_name %s;
 
     location / {
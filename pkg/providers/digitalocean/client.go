@@ -36,7 +36,8 @@ func (t *TokenSource) Token() (*oauth2.Token, error) {
 
 func NewClient(token string) *Client {
 	tokenSource := &TokenSource{AccessToken: token}
-	oauthClient := oauth2.NewClient(context.Background(), tokenSource)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, providers.HTTPClient("digitalocean"))
+	oauthClient := oauth2.NewClient(ctx, tokenSource)
 	client := godo.NewClient(oauthClient)
 
 	return &Client{
@@ -73,12 +74,25 @@ func (c *Client) ValidateCredentials(ctx context.Context) error {
 			Code:     "invalid_credentials",
 			Message:  "Invalid DigitalOcean API token",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return providerErr
 	}
 	return nil
 }
 
+// AccountInfo returns the account email, plus the team when the token is team-scoped
+func (c *Client) AccountInfo(ctx context.Context) (string, error) {
+	account, _, err := c.client.Account.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if account.Team != nil && account.Team.Name != "" {
+		return fmt.Sprintf("%s (team %s)", account.Email, account.Team.Name), nil
+	}
+	return account.Email, nil
+}
+
 func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 	doRegions, _, err := c.client.Regions.List(ctx, &godo.ListOptions{})
 	if err != nil {
@@ -87,6 +101,7 @@ func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 			Code:     "list_regions_failed",
 			Message:  "Failed to list DigitalOcean regions",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return nil, providerErr
 	}
@@ -113,6 +128,7 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 			Code:     "list_sizes_failed",
 			Message:  "Failed to list DigitalOcean sizes",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return nil, providerErr
 	}
@@ -120,6 +136,9 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 	var sizes []providers.Size
 	for _, size := range doSizes {
 		if size.Available && size.Memory >= 512 {
+			if region != "" && !containsString(size.Regions, region) {
+				continue
+			}
 			sizes = append(sizes, providers.Size{
 				ID:           size.Slug,
 				Name:         fmt.Sprintf("%s (%d MB RAM, %d vCPUs, %d GB disk)", size.Slug, size.Memory, size.Vcpus, size.Disk),
@@ -128,6 +147,7 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 				Disk:         size.Disk,
 				PriceMonthly: size.PriceMonthly,
 				PriceHourly:  size.PriceHourly,
+				Architecture: providers.ArchX86_64,
 			})
 		}
 	}
@@ -143,6 +163,7 @@ func (c *Client) GetImages(ctx context.Context) ([]providers.Image, error) {
 			Code:     "list_images_failed",
 			Message:  "Failed to list DigitalOcean images",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return nil, providerErr
 	}
@@ -219,6 +240,38 @@ func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*pro
 	}, nil
 }
 
+// DeleteSSHKey removes the account SSH key with the given name
+func (c *Client) DeleteSSHKey(ctx context.Context, name string) (bool, error) {
+	keys, _, err := c.client.Keys.List(ctx, &godo.ListOptions{PerPage: 200})
+	if err != nil {
+		return false, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "list_ssh_keys_failed",
+			Message:  fmt.Sprintf("Failed to list DigitalOcean SSH keys: %v", err),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	for _, k := range keys {
+		if k.Name != name {
+			continue
+		}
+		if _, err := c.client.Keys.DeleteByID(ctx, k.ID); err != nil {
+			return false, &providers.ProviderError{
+				Provider: "digitalocean",
+				Code:     "delete_ssh_key_failed",
+				Message:  fmt.Sprintf("Failed to delete DigitalOcean SSH key %s: %v", name, err),
+				Details:  map[string]interface{}{"error": err.Error(), "name": name},
+				Err:      err,
+			}
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
 func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig) (*providers.Server, error) {
 	var sshKeys []godo.DropletCreateSSHKey
 	for _, keyID := range config.SSHKeys {
@@ -235,9 +288,10 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		Image:      godo.DropletCreateImage{Slug: config.Image},
 		SSHKeys:    sshKeys,
 		UserData:   config.UserData,
-		Tags:       config.Tags,
+		Tags:       providers.WithLabelTags(config.Tags, labelTags(config.Labels)),
 		Backups:    config.BackupsEnabled,
 		Monitoring: config.MonitoringEnabled,
+		IPv6:       config.IPStack == providers.IPStackDual,
 	}
 
 	droplet, _, err := c.client.Droplets.Create(ctx, dropletRequest)
@@ -247,6 +301,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "create_droplet_failed",
 			Message:  "Failed to create DigitalOcean droplet",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return nil, providerErr
 	}
@@ -264,6 +319,7 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "get_droplet_failed",
 			Message:  "Failed to get DigitalOcean droplet",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return nil, providerErr
 	}
@@ -271,6 +327,26 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 	return convertDropletToServer(droplet), nil
 }
 
+func (c *Client) ListServers(ctx context.Context) ([]providers.Server, error) {
+	droplets, _, err := c.client.Droplets.List(ctx, &godo.ListOptions{PerPage: 200})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "list_droplets_failed",
+			Message:  "Failed to list DigitalOcean droplets",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	servers := make([]providers.Server, 0, len(droplets))
+	for i := range droplets {
+		servers = append(servers, *convertDropletToServer(&droplets[i]))
+	}
+
+	return servers, nil
+}
+
 func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	dropletID := getDropletID(serverID)
 
@@ -281,6 +357,7 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "destroy_droplet_failed",
 			Message:  "Failed to destroy DigitalOcean droplet",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return providerErr
 	}
@@ -288,6 +365,33 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	return nil
 }
 
+// PowerOff shuts the droplet down. DigitalOcean keeps billing powered-off droplets.
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	if _, _, err := c.client.DropletActions.PowerOff(ctx, getDropletID(serverID)); err != nil {
+		return &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "power_off_failed",
+			Message:  "Failed to power off DigitalOcean droplet",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	if _, _, err := c.client.DropletActions.PowerOn(ctx, getDropletID(serverID)); err != nil {
+		return &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "power_on_failed",
+			Message:  "Failed to power on DigitalOcean droplet",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
 func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout time.Duration) (*providers.Server, error) {
 	dropletID := getDropletID(serverID)
 	deadline := time.Now().Add(timeout)
@@ -300,6 +404,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 				Code:     "poll_droplet_failed",
 				Message:  "Failed to poll DigitalOcean droplet status",
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 			return nil, providerErr
 		}
@@ -337,6 +442,8 @@ func convertDropletToServer(droplet *godo.Droplet) *providers.Server {
 		}
 	}
 
+	publicIPv6, _ := droplet.PublicIPv6()
+
 	createdAt, err := time.Parse(time.RFC3339, droplet.Created)
 	if err != nil {
 		createdAt = time.Now()
@@ -375,6 +482,7 @@ func convertDropletToServer(droplet *godo.Droplet) *providers.Server {
 		Name:        droplet.Name,
 		Status:      droplet.Status,
 		PublicIPv4:  publicIPv4,
+		PublicIPv6:  publicIPv6,
 		PrivateIPv4: privateIPv4,
 		Region:      regionSlug,
 		Size:        sizeSlug,
@@ -400,3 +508,12 @@ func getKeyID(keyIDStr string) int {
 	}
 	return id
 }
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
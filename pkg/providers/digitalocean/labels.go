@@ -0,0 +1,64 @@
+package digitalocean
+
+import (
+	"context"
+	"lightfold/pkg/providers"
+
+	"github.com/digitalocean/godo"
+)
+
+// maxTagLength is the longest tag name DigitalOcean accepts
+const maxTagLength = 255
+
+// labelTags renders labels as key:value tags. DigitalOcean tag names allow
+// letters, digits, '_', '-' and ':'; other characters become '_'.
+func labelTags(labels map[string]string) []string {
+	return providers.FlatLabels(labels, ":", func(tag string) string {
+		tag = providers.ReplaceChars(tag, func(r rune) bool {
+			return providers.IsASCIIAlnum(r) || r == '_' || r == '-' || r == ':'
+		}, '_')
+		if len(tag) > maxTagLength {
+			tag = tag[:maxTagLength]
+		}
+		return tag
+	})
+}
+
+// SetLabels tags the droplet with labels and untags the previous labels that
+// are no longer set
+func (c *Client) SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error {
+	tags := labelTags(labels)
+	keep := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		keep[tag] = true
+	}
+	resources := []godo.Resource{{ID: serverID, Type: godo.DropletResourceType}}
+
+	for _, tag := range labelTags(previous) {
+		if keep[tag] {
+			continue
+		}
+		if _, err := c.client.Tags.UntagResources(ctx, tag, &godo.UntagResourcesRequest{Resources: resources}); err != nil {
+			return retagError(err)
+		}
+	}
+	for _, tag := range tags {
+		if _, _, err := c.client.Tags.Create(ctx, &godo.TagCreateRequest{Name: tag}); err != nil {
+			return retagError(err)
+		}
+		if _, err := c.client.Tags.TagResources(ctx, tag, &godo.TagResourcesRequest{Resources: resources}); err != nil {
+			return retagError(err)
+		}
+	}
+	return nil
+}
+
+func retagError(err error) error {
+	return &providers.ProviderError{
+		Provider: "digitalocean",
+		Code:     "retag_failed",
+		Message:  "Failed to update DigitalOcean droplet tags",
+		Details:  map[string]interface{}{"error": err.Error()},
+		Err:      err,
+	}
+}
@@ -0,0 +1,33 @@
+package digitalocean
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLabelTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{"key value pairs", map[string]string{"team": "payments", "env": "prod"}, []string{"env:prod", "team:payments"}},
+		{"invalid characters", map[string]string{"cost.center": "R&D 42"}, []string{"cost_center:R_D_42"}},
+		{"colons kept", map[string]string{"owner": "ops:oncall"}, []string{"owner:ops:oncall"}},
+		{"empty value", map[string]string{"billable": ""}, []string{"billable:"}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelTags(tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("labelTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	long := labelTags(map[string]string{"team": strings.Repeat("x", 300)})
+	if len(long[0]) != maxTagLength {
+		t.Errorf("long tag has %d characters, want %d", len(long[0]), maxTagLength)
+	}
+}
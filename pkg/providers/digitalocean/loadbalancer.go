@@ -0,0 +1,94 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+
+	"github.com/digitalocean/godo"
+)
+
+// loadBalancerRequest forwards HTTP on port 80 to nginx on each droplet and
+// health checks the app through nginx
+func loadBalancerRequest(config providers.LoadBalancerConfig) (*godo.LoadBalancerRequest, error) {
+	var dropletIDs []int
+	for _, serverID := range config.ServerIDs {
+		id := getDropletID(serverID)
+		if id == 0 {
+			return nil, fmt.Errorf("invalid droplet ID %q", serverID)
+		}
+		dropletIDs = append(dropletIDs, id)
+	}
+
+	healthPath := config.HealthCheckPath
+	if healthPath == "" {
+		healthPath = "/"
+	}
+
+	return &godo.LoadBalancerRequest{
+		Name:   config.Name,
+		Region: config.Region,
+		ForwardingRules: []godo.ForwardingRule{
+			{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 80},
+		},
+		HealthCheck: &godo.HealthCheck{
+			Protocol:               "http",
+			Port:                   80,
+			Path:                   healthPath,
+			CheckIntervalSeconds:   10,
+			ResponseTimeoutSeconds: 5,
+			HealthyThreshold:       3,
+			UnhealthyThreshold:     3,
+		},
+		DropletIDs: dropletIDs,
+	}, nil
+}
+
+func (c *Client) EnsureLoadBalancer(ctx context.Context, config providers.LoadBalancerConfig) (*providers.LoadBalancer, error) {
+	request, err := loadBalancerRequest(config)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "invalid_load_balancer_config",
+			Message:  "Invalid load balancer configuration",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	var lb *godo.LoadBalancer
+	if config.ID != "" {
+		lb, _, err = c.client.LoadBalancers.Update(ctx, config.ID, request)
+	} else {
+		lb, _, err = c.client.LoadBalancers.Create(ctx, request)
+	}
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "load_balancer_failed",
+			Message:  "Failed to create or update DigitalOcean load balancer",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	return &providers.LoadBalancer{
+		ID:     lb.ID,
+		Name:   lb.Name,
+		IP:     lb.IP,
+		Status: lb.Status,
+	}, nil
+}
+
+func (c *Client) DeleteLoadBalancer(ctx context.Context, id string) error {
+	if _, err := c.client.LoadBalancers.Delete(ctx, id); err != nil {
+		return &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "delete_load_balancer_failed",
+			Message:  "Failed to delete DigitalOcean load balancer",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"strconv"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+// actionPollInterval is how often droplet actions are polled while waiting
+const actionPollInterval = 5 * time.Second
+
+func (c *Client) Snapshot(ctx context.Context, serverID, name string, progress func(percent int)) (*providers.Snapshot, error) {
+	dropletID := getDropletID(serverID)
+
+	action, _, err := c.client.DropletActions.Snapshot(ctx, dropletID, name)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "create_snapshot_failed",
+			Message:  "Failed to create DigitalOcean snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if err := c.waitForAction(ctx, action.ID, progress); err != nil {
+		return nil, err
+	}
+
+	// The snapshot action does not return the image, so look it up by name
+	snapshots, err := c.ListSnapshots(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Name == name {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, &providers.ProviderError{
+		Provider: "digitalocean",
+		Code:     "snapshot_not_found",
+		Message:  fmt.Sprintf("Snapshot %q finished but was not found on droplet %s", name, serverID),
+	}
+}
+
+func (c *Client) ListSnapshots(ctx context.Context, serverID string) ([]providers.Snapshot, error) {
+	images, _, err := c.client.Droplets.Snapshots(ctx, getDropletID(serverID), &godo.ListOptions{PerPage: 200})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "list_snapshots_failed",
+			Message:  "Failed to list DigitalOcean snapshots",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	snapshots := make([]providers.Snapshot, 0, len(images))
+	for _, image := range images {
+		createdAt, _ := time.Parse(time.RFC3339, image.Created)
+		snapshots = append(snapshots, providers.Snapshot{
+			ID:        strconv.Itoa(image.ID),
+			Name:      image.Name,
+			ServerID:  serverID,
+			Status:    "available",
+			SizeGB:    image.SizeGigaBytes,
+			CreatedAt: createdAt,
+		})
+	}
+	return snapshots, nil
+}
+
+func (c *Client) RestoreSnapshot(ctx context.Context, serverID, snapshotID string) error {
+	imageID, err := strconv.Atoi(snapshotID)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "invalid_snapshot_id",
+			Message:  fmt.Sprintf("Invalid snapshot ID: %s", snapshotID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	action, _, err := c.client.DropletActions.RebuildByImageID(ctx, getDropletID(serverID), imageID)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "digitalocean",
+			Code:     "restore_snapshot_failed",
+			Message:  "Failed to rebuild DigitalOcean droplet from snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return c.waitForAction(ctx, action.ID, nil)
+}
+
+// waitForAction polls a droplet action until it completes. DigitalOcean does
+// not report progress, so progress only receives -1.
+func (c *Client) waitForAction(ctx context.Context, actionID int, progress func(percent int)) error {
+	for {
+		action, _, err := c.client.Actions.Get(ctx, actionID)
+		if err != nil {
+			return &providers.ProviderError{
+				Provider: "digitalocean",
+				Code:     "poll_action_failed",
+				Message:  "Failed to poll DigitalOcean action status",
+				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
+			}
+		}
+
+		switch action.Status {
+		case godo.ActionCompleted:
+			return nil
+		case godo.ActionInProgress:
+			if progress != nil {
+				progress(-1)
+			}
+		default:
+			return &providers.ProviderError{
+				Provider: "digitalocean",
+				Code:     "action_failed",
+				Message:  fmt.Sprintf("DigitalOcean %s action ended with status %s", action.Type, action.Status),
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(actionPollInterval):
+		}
+	}
+}
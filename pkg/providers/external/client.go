@@ -0,0 +1,285 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/pkg/providers"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func init() {
+	providers.RegisterExternal(func(name, token string) providers.Provider {
+		return NewClient(name, token)
+	})
+}
+
+// lookPath finds plugin binaries; replaced in tests
+var lookPath = exec.LookPath
+
+// pollInterval is how often WaitForActive polls plugins that do not implement OpWait
+var pollInterval = 5 * time.Second
+
+// Client adapts a lightfold-provider-<name> binary to providers.Provider
+type Client struct {
+	name  string // Full provider name, e.g. "exec:mycloud"
+	token string
+	info  *Info
+}
+
+// NewClient creates a client for an "exec:<name>" provider
+func NewClient(name, token string) *Client {
+	return &Client{name: name, token: token}
+}
+
+// BinaryName returns the executable looked up on PATH for a provider name
+func BinaryName(name string) string {
+	return BinaryPrefix + strings.TrimPrefix(name, providers.ExternalPrefix)
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) DisplayName() string {
+	if info := c.loadInfo(); info != nil && info.DisplayName != "" {
+		return info.DisplayName
+	}
+	return strings.TrimPrefix(c.name, providers.ExternalPrefix)
+}
+
+func (c *Client) SupportsProvisioning() bool {
+	info := c.loadInfo()
+	return info != nil && info.SupportsProvisioning
+}
+
+func (c *Client) SupportsBYOS() bool {
+	info := c.loadInfo()
+	return info != nil && info.SupportsBYOS
+}
+
+// SupportsSSH is always true: plugins hand back servers lightfold deploys to over SSH
+func (c *Client) SupportsSSH() bool {
+	return true
+}
+
+func (c *Client) ValidateCredentials(ctx context.Context) error {
+	_, err := c.call(ctx, Request{Operation: OpValidate})
+	return err
+}
+
+func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
+	resp, err := c.call(ctx, Request{Operation: OpRegions})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Regions, nil
+}
+
+func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size, error) {
+	resp, err := c.call(ctx, Request{Operation: OpSizes, Region: region})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sizes, nil
+}
+
+// GetImages returns the plugin's images, or none when it does not list them
+func (c *Client) GetImages(ctx context.Context) ([]providers.Image, error) {
+	resp, err := c.call(ctx, Request{Operation: OpImages})
+	if isUnsupported(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Images, nil
+}
+
+func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*providers.SSHKey, error) {
+	resp, err := c.call(ctx, Request{Operation: OpUploadKey, KeyName: name, PublicKey: publicKey})
+	if err != nil {
+		return nil, err
+	}
+	if resp.SSHKey == nil {
+		return nil, c.malformed(OpUploadKey, "ssh_key")
+	}
+	return resp.SSHKey, nil
+}
+
+func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig) (*providers.Server, error) {
+	resp, err := c.call(ctx, Request{Operation: OpProvision, Provision: &config})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Server == nil || resp.Server.ID == "" {
+		return nil, c.malformed(OpProvision, "server")
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Server, error) {
+	resp, err := c.call(ctx, Request{Operation: OpGetServer, ServerID: serverID})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Server == nil {
+		return nil, c.malformed(OpGetServer, "server")
+	}
+	return resp.Server, nil
+}
+
+func (c *Client) Destroy(ctx context.Context, serverID string) error {
+	_, err := c.call(ctx, Request{Operation: OpDestroy, ServerID: serverID})
+	return err
+}
+
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	_, err := c.call(ctx, Request{Operation: OpPowerOff, ServerID: serverID})
+	return err
+}
+
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	_, err := c.call(ctx, Request{Operation: OpPowerOn, ServerID: serverID})
+	return err
+}
+
+// WaitForActive lets the plugin block until the server is active. Plugins
+// without OpWait are polled with OpGetServer until the status is "active".
+func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout time.Duration) (*providers.Server, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := c.call(ctx, Request{Operation: OpWait, ServerID: serverID, TimeoutSeconds: int(timeout.Seconds())})
+	if err == nil {
+		if resp.Server == nil {
+			return nil, c.malformed(OpWait, "server")
+		}
+		return resp.Server, nil
+	}
+	if !isUnsupported(err) {
+		return nil, err
+	}
+
+	for {
+		server, err := c.GetServer(ctx, serverID)
+		if err != nil {
+			return nil, err
+		}
+		if server.Status == "active" && server.PublicIPv4 != "" {
+			return server, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &providers.ProviderError{
+				Provider: c.name,
+				Code:     "timeout",
+				Message:  fmt.Sprintf("Timeout waiting for server to become active (waited %s)", timeout.String()),
+				Details:  map[string]interface{}{"timeout": timeout.String()},
+			}
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// loadInfo asks the plugin to describe itself once; nil when it cannot be run
+func (c *Client) loadInfo() *Info {
+	if c.info != nil {
+		return c.info
+	}
+	resp, err := c.call(context.Background(), Request{Operation: OpInfo})
+	if err != nil || resp.Info == nil {
+		return nil
+	}
+	c.info = resp.Info
+	return c.info
+}
+
+// call runs the plugin binary for one operation
+func (c *Client) call(ctx context.Context, req Request) (*Response, error) {
+	binary := BinaryName(c.name)
+	path, err := lookPath(binary)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: c.name,
+			Code:     "plugin_not_found",
+			Message:  fmt.Sprintf("Provider plugin %s not found on PATH", binary),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	req.ProtocolVersion = ProtocolVersion
+	req.Token = c.token
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		details := map[string]interface{}{"error": strings.TrimSpace(stderr.String())}
+		if runErr != nil && details["error"] == "" {
+			details["error"] = runErr.Error()
+		}
+		return nil, &providers.ProviderError{
+			Provider: c.name,
+			Code:     "plugin_failed",
+			Message:  fmt.Sprintf("Provider plugin %s failed during %s", binary, req.Operation),
+			Details:  details,
+		}
+	}
+
+	if resp.ProtocolVersion != ProtocolVersion {
+		return nil, &providers.ProviderError{
+			Provider: c.name,
+			Code:     CodeProtocolMismatch,
+			Message:  fmt.Sprintf("Provider plugin %s speaks protocol version %d, lightfold requires %d", binary, resp.ProtocolVersion, ProtocolVersion),
+			Details:  map[string]interface{}{},
+		}
+	}
+
+	if resp.Error != nil {
+		return nil, &providers.ProviderError{
+			Provider: c.name,
+			Code:     resp.Error.Code,
+			Message:  resp.Error.Message,
+			Details:  map[string]interface{}{"error": strings.TrimSpace(stderr.String())},
+		}
+	}
+	if runErr != nil {
+		return nil, &providers.ProviderError{
+			Provider: c.name,
+			Code:     "plugin_failed",
+			Message:  fmt.Sprintf("Provider plugin %s failed during %s", binary, req.Operation),
+			Details:  map[string]interface{}{"error": runErr.Error()},
+		}
+	}
+
+	return &resp, nil
+}
+
+func (c *Client) malformed(operation, field string) error {
+	return &providers.ProviderError{
+		Provider: c.name,
+		Code:     "malformed_response",
+		Message:  fmt.Sprintf("Provider plugin response to %s is missing %s", operation, field),
+		Details:  map[string]interface{}{},
+	}
+}
+
+func isUnsupported(err error) bool {
+	providerErr, ok := err.(*providers.ProviderError)
+	return ok && providerErr.Code == CodeUnsupported
+}
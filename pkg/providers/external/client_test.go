@@ -0,0 +1,195 @@
+package external
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lightfold/pkg/providers"
+	"lightfold/pkg/providers/external/externaltest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as the fake plugin: a shell wrapper named
+// lightfold-provider-fake re-runs it with fakePluginEnv set
+const fakePluginEnv = "LIGHTFOLD_FAKE_PROVIDER_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakePluginEnv) != "" {
+		os.Exit(runFakePlugin())
+	}
+	os.Exit(m.Run())
+}
+
+// fakeCloud is the fake plugin's state, kept in a file between invocations
+type fakeCloud struct {
+	Servers map[string]*providers.Server `json:"servers"`
+	Polls   map[string]int               `json:"polls"`
+	NextID  int                          `json:"next_id"`
+}
+
+func runFakePlugin() int {
+	mode := os.Getenv("FAKE_PROVIDER_MODE")
+	statePath := os.Getenv("FAKE_PROVIDER_STATE")
+
+	var req Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, "bad request:", err)
+		return 2
+	}
+
+	cloud := fakeCloud{Servers: map[string]*providers.Server{}, Polls: map[string]int{}}
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &cloud)
+	}
+
+	resp := Response{ProtocolVersion: ProtocolVersion}
+	fail := func(code, message string) { resp.Error = &ResponseError{Code: code, Message: message} }
+
+	switch {
+	case mode == "crash":
+		fmt.Fprintln(os.Stderr, "panic: cloud API unreachable")
+		return 1
+	case mode == "future":
+		resp.ProtocolVersion = ProtocolVersion + 1
+	case req.ProtocolVersion != ProtocolVersion:
+		fail(CodeProtocolMismatch, "unsupported protocol version")
+	case req.Token != "fake-token" && req.Operation != OpInfo:
+		fail("invalid_credentials", "token rejected")
+	default:
+		switch req.Operation {
+		case OpInfo:
+			resp.Info = &Info{DisplayName: "Fake Cloud", SupportsProvisioning: true}
+		case OpValidate:
+		case OpRegions:
+			resp.Regions = []providers.Region{{ID: "fk-1", Name: "Fake 1", Location: "Nowhere"}}
+		case OpSizes:
+			resp.Sizes = []providers.Size{{ID: "small", Name: "Small", Memory: 1024, VCPUs: 1}}
+		case OpUploadKey:
+			resp.SSHKey = &providers.SSHKey{ID: "key-1", Name: req.KeyName, PublicKey: req.PublicKey}
+		case OpProvision:
+			cloud.NextID++
+			id := fmt.Sprintf("srv-%d", cloud.NextID)
+			cloud.Servers[id] = &providers.Server{ID: id, Name: req.Provision.Name, Status: "new", Region: req.Provision.Region, Size: req.Provision.Size}
+			resp.Server = cloud.Servers[id]
+		case OpGetServer, OpWait:
+			if req.Operation == OpWait && mode == "no_wait" {
+				fail(CodeUnsupported, "wait is not implemented")
+				break
+			}
+			server, ok := cloud.Servers[req.ServerID]
+			if !ok {
+				fail("not_found", "no such server")
+				break
+			}
+			// Servers come up on the second look
+			cloud.Polls[req.ServerID]++
+			if req.Operation == OpWait || cloud.Polls[req.ServerID] > 1 {
+				server.Status = "active"
+				server.PublicIPv4 = "198.51.100.7"
+			}
+			resp.Server = server
+		case OpDestroy:
+			delete(cloud.Servers, req.ServerID)
+		default:
+			fail(CodeUnsupported, req.Operation+" is not implemented")
+		}
+	}
+
+	if data, err := json.Marshal(cloud); err == nil {
+		_ = os.WriteFile(statePath, data, 0o600)
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(resp)
+	return 0
+}
+
+// installFakePlugin puts lightfold-provider-fake on PATH
+func installFakePlugin(t *testing.T, mode string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec %q \"$@\"\n", fakePluginEnv, os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, BinaryName("exec:fake")), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir)
+	t.Setenv("FAKE_PROVIDER_MODE", mode)
+	t.Setenv("FAKE_PROVIDER_STATE", filepath.Join(dir, "state.json"))
+}
+
+func TestExternalProviderConformance(t *testing.T) {
+	installFakePlugin(t, "")
+
+	provider, err := providers.GetProvider("exec:fake", "fake-token")
+	if err != nil {
+		t.Fatalf("GetProvider() error: %v", err)
+	}
+	if provider.DisplayName() != "Fake Cloud" {
+		t.Errorf("DisplayName() = %q, want the plugin's name", provider.DisplayName())
+	}
+
+	externaltest.Run(t, provider, externaltest.Options{})
+}
+
+func TestExternalProviderPollsWithoutWait(t *testing.T) {
+	installFakePlugin(t, "no_wait")
+	originalInterval := pollInterval
+	pollInterval = time.Millisecond
+	t.Cleanup(func() { pollInterval = originalInterval })
+
+	externaltest.Run(t, NewClient("exec:fake", "fake-token"), externaltest.Options{WaitTimeout: 10 * time.Second})
+}
+
+func TestExternalProviderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		token string
+		code  string
+		text  string
+	}{
+		{name: "plugin error", token: "wrong", code: "invalid_credentials", text: "token rejected"},
+		{name: "protocol mismatch", mode: "future", token: "fake-token", code: CodeProtocolMismatch, text: "protocol version 2"},
+		{name: "crash", mode: "crash", token: "fake-token", code: "plugin_failed", text: "cloud API unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installFakePlugin(t, tt.mode)
+
+			err := NewClient("exec:fake", tt.token).ValidateCredentials(t.Context())
+			var providerErr *providers.ProviderError
+			if !errors.As(err, &providerErr) || providerErr.Code != tt.code {
+				t.Fatalf("ValidateCredentials() error = %v, want code %s", err, tt.code)
+			}
+			if !strings.Contains(err.Error(), tt.text) {
+				t.Errorf("error %q does not mention %q", err, tt.text)
+			}
+		})
+	}
+}
+
+func TestExternalProviderMissingBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	client := NewClient("exec:missing", "token")
+	err := client.ValidateCredentials(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "lightfold-provider-missing not found") {
+		t.Errorf("ValidateCredentials() error = %v, want plugin not found", err)
+	}
+	if client.DisplayName() != "missing" || client.SupportsProvisioning() {
+		t.Errorf("missing plugin should fall back to its name without provisioning support")
+	}
+}
+
+func TestIsExternal(t *testing.T) {
+	for name, want := range map[string]bool{"exec:mycloud": true, "exec:": false, "hetzner": false} {
+		if got := providers.IsExternal(name); got != want {
+			t.Errorf("IsExternal(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package externaltest checks that a provider plugin behaves the way
+// lightfold's provisioning flow expects. Plugin authors can run it from their
+// own tests against an external.Client pointed at their binary.
+package externaltest
+
+import (
+	"context"
+	"lightfold/pkg/providers"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Options tunes the conformance run
+type Options struct {
+	// WaitTimeout bounds WaitForActive; defaults to a minute
+	WaitTimeout time.Duration
+}
+
+// Run walks a provider through the calls lightfold makes when provisioning,
+// recovering and destroying a server, in the same order
+func Run(t *testing.T, provider providers.Provider, opts Options) {
+	t.Helper()
+	if opts.WaitTimeout == 0 {
+		opts.WaitTimeout = time.Minute
+	}
+	ctx := context.Background()
+
+	if !strings.HasPrefix(provider.Name(), providers.ExternalPrefix) {
+		t.Errorf("Name() = %q, want the %q prefix", provider.Name(), providers.ExternalPrefix)
+	}
+	if provider.DisplayName() == "" {
+		t.Error("DisplayName() is empty")
+	}
+	if !provider.SupportsProvisioning() {
+		t.Fatal("SupportsProvisioning() = false; lightfold only uses plugins to provision servers")
+	}
+
+	if err := provider.ValidateCredentials(ctx); err != nil {
+		t.Fatalf("ValidateCredentials() error: %v", err)
+	}
+
+	regions, err := provider.GetRegions(ctx)
+	if err != nil {
+		t.Fatalf("GetRegions() error: %v", err)
+	}
+	if len(regions) == 0 || regions[0].ID == "" {
+		t.Fatalf("GetRegions() = %+v, want at least one region with an ID", regions)
+	}
+	region := regions[0].ID
+
+	sizes, err := provider.GetSizes(ctx, region)
+	if err != nil {
+		t.Fatalf("GetSizes(%q) error: %v", region, err)
+	}
+	if len(sizes) == 0 || sizes[0].ID == "" {
+		t.Fatalf("GetSizes(%q) = %+v, want at least one size with an ID", region, sizes)
+	}
+	if err := providers.ValidateSizeForRegion(ctx, provider, region, sizes[0].ID); err != nil {
+		t.Errorf("ValidateSizeForRegion() rejected a size the plugin listed: %v", err)
+	}
+
+	if _, err := provider.GetImages(ctx); err != nil {
+		t.Errorf("GetImages() error: %v", err)
+	}
+
+	key, err := provider.UploadSSHKey(ctx, "lightfold-conformance", "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIConformance lightfold")
+	if err != nil {
+		t.Fatalf("UploadSSHKey() error: %v", err)
+	}
+	if key.ID == "" {
+		t.Error("UploadSSHKey() returned a key without an ID")
+	}
+
+	server, err := provider.Provision(ctx, providers.ProvisionConfig{
+		Name:     "lightfold-conformance-app",
+		Region:   region,
+		Size:     sizes[0].ID,
+		Image:    providers.GetDefaultImage(provider.Name()),
+		SSHKeys:  []string{key.ID},
+		UserData: "#cloud-config\n",
+		Tags:     []string{"lightfold", "conformance"},
+		Metadata: map[string]string{"managed_by": "lightfold"},
+	})
+	if err != nil {
+		t.Fatalf("Provision() error: %v", err)
+	}
+	if server.ID == "" {
+		t.Fatal("Provision() returned a server without an ID")
+	}
+	t.Cleanup(func() {
+		_ = provider.Destroy(context.Background(), server.ID)
+	})
+
+	active, err := provider.WaitForActive(ctx, server.ID, opts.WaitTimeout)
+	if err != nil {
+		t.Fatalf("WaitForActive() error: %v", err)
+	}
+	if active.ID != server.ID || active.PublicIPv4 == "" {
+		t.Fatalf("WaitForActive() = %+v, want server %s with a public IPv4", active, server.ID)
+	}
+
+	fetched, err := provider.GetServer(ctx, server.ID)
+	if err != nil {
+		t.Fatalf("GetServer() error: %v", err)
+	}
+	if fetched.PublicIPv4 != active.PublicIPv4 {
+		t.Errorf("GetServer() IP = %q, want %q", fetched.PublicIPv4, active.PublicIPv4)
+	}
+
+	if err := provider.Destroy(ctx, server.ID); err != nil {
+		t.Fatalf("Destroy() error: %v", err)
+	}
+	if _, err := provider.GetServer(ctx, server.ID); err == nil {
+		t.Error("GetServer() found the server after Destroy()")
+	}
+}
@@ -0,0 +1,73 @@
+package external
+
+import "lightfold/pkg/providers"
+
+// ProtocolVersion is the plugin protocol version this build speaks. Plugins
+// must answer with the same version; a mismatch fails every operation.
+const ProtocolVersion = 1
+
+// BinaryPrefix is prepended to the plugin name to find the binary on PATH
+const BinaryPrefix = "lightfold-provider-"
+
+// Operations a plugin can be asked to perform. Each runs in a fresh process:
+// lightfold writes one Request to the plugin's stdin and reads one Response
+// from its stdout. Anything the plugin writes to stderr is shown on failure.
+const (
+	OpInfo      = "info"       // Describe the plugin; returns Info
+	OpValidate  = "validate"   // Check the token; returns nothing
+	OpRegions   = "regions"    // Returns Regions
+	OpSizes     = "sizes"      // Sizes available in Request.Region; returns Sizes
+	OpImages    = "images"     // Optional; returns Images
+	OpUploadKey = "upload_key" // Register Request.KeyName/PublicKey; returns SSHKey
+	OpProvision = "provision"  // Create Request.Provision; returns Server
+	OpGetServer = "get_server" // Returns Server for Request.ServerID
+	OpWait      = "wait"       // Optional; block until Request.ServerID is active, returns Server
+	OpDestroy   = "destroy"    // Delete Request.ServerID; returns nothing
+	OpPowerOff  = "power_off"  // Optional; stop Request.ServerID keeping its disk
+	OpPowerOn   = "power_on"   // Optional; start Request.ServerID again
+)
+
+// Error codes with a meaning to lightfold
+const (
+	CodeUnsupported      = "unsupported_operation"
+	CodeProtocolMismatch = "protocol_mismatch"
+)
+
+// Request is written to the plugin's stdin as a single JSON document
+type Request struct {
+	ProtocolVersion int                        `json:"protocol_version"`
+	Operation       string                     `json:"operation"`
+	Token           string                     `json:"token,omitempty"`
+	Region          string                     `json:"region,omitempty"`
+	ServerID        string                     `json:"server_id,omitempty"`
+	TimeoutSeconds  int                        `json:"timeout_seconds,omitempty"`
+	KeyName         string                     `json:"key_name,omitempty"`
+	PublicKey       string                     `json:"public_key,omitempty"`
+	Provision       *providers.ProvisionConfig `json:"provision,omitempty"`
+}
+
+// Response is read from the plugin's stdout. Only the field for the requested
+// operation is set, or Error when it failed.
+type Response struct {
+	ProtocolVersion int                `json:"protocol_version"`
+	Error           *ResponseError     `json:"error,omitempty"`
+	Info            *Info              `json:"info,omitempty"`
+	Regions         []providers.Region `json:"regions,omitempty"`
+	Sizes           []providers.Size   `json:"sizes,omitempty"`
+	Images          []providers.Image  `json:"images,omitempty"`
+	Server          *providers.Server  `json:"server,omitempty"`
+	SSHKey          *providers.SSHKey  `json:"ssh_key,omitempty"`
+}
+
+// ResponseError reports a failed operation
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Info describes the plugin
+type Info struct {
+	DisplayName          string `json:"display_name"`
+	SupportsProvisioning bool   `json:"supports_provisioning"`
+	SupportsBYOS         bool   `json:"supports_byos"`
+}
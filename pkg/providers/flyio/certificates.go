@@ -0,0 +1,123 @@
+package flyio
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"strings"
+	"time"
+
+	"github.com/superfly/fly-go"
+)
+
+// Certificate is the state of a custom hostname's certificate on a fly.io app
+type Certificate struct {
+	Hostname         string
+	Status           string // fly.io client status: "Ready", "Awaiting configuration", ...
+	Issued           bool
+	Configured       bool
+	ExpiresAt        time.Time
+	Records          []DNSRecord // Records the hostname needs at its DNS provider
+	ValidationErrors []string
+}
+
+// DNSRecord is a DNS record the user must create for a custom hostname
+type DNSRecord struct {
+	Type  string
+	Name  string
+	Value string
+}
+
+// AddCertificate adds a custom hostname to the app and requests its certificate
+func (c *Client) AddCertificate(ctx context.Context, appName, hostname string) (*Certificate, error) {
+	cert, _, err := c.apiClient.AddCertificate(ctx, appName, hostname)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "add_certificate_failed",
+			Message:  fmt.Sprintf("Failed to add %s to app '%s': %s", hostname, appName, err.Error()),
+			Details:  map[string]interface{}{"app_name": appName, "hostname": hostname},
+		}
+	}
+	return c.describeCertificate(ctx, appName, cert), nil
+}
+
+// CheckCertificate asks fly.io to re-check the hostname's DNS and returns the
+// live certificate status
+func (c *Client) CheckCertificate(ctx context.Context, appName, hostname string) (*Certificate, error) {
+	cert, _, err := c.apiClient.CheckAppCertificate(ctx, appName, hostname)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "check_certificate_failed",
+			Message:  fmt.Sprintf("Failed to check certificate for %s: %s", hostname, err.Error()),
+			Details:  map[string]interface{}{"app_name": appName, "hostname": hostname},
+		}
+	}
+	return c.describeCertificate(ctx, appName, cert), nil
+}
+
+// DeleteCertificate removes the hostname and its certificate from the app
+func (c *Client) DeleteCertificate(ctx context.Context, appName, hostname string) error {
+	if _, err := c.apiClient.DeleteCertificate(ctx, appName, hostname); err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "404") {
+			return nil
+		}
+		return &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "delete_certificate_failed",
+			Message:  fmt.Sprintf("Failed to remove %s from app '%s': %s", hostname, appName, err.Error()),
+			Details:  map[string]interface{}{"app_name": appName, "hostname": hostname},
+		}
+	}
+	return nil
+}
+
+func (c *Client) describeCertificate(ctx context.Context, appName string, cert *fly.AppCertificate) *Certificate {
+	var ips []fly.IPAddress
+	if cert.IsApex {
+		ips, _ = c.apiClient.GetIPAddresses(ctx, appName)
+	}
+	return newCertificate(cert, appName, ips)
+}
+
+// newCertificate converts fly.io's certificate into the records and status
+// lightfold shows. Apex domains point A/AAAA records at the app's addresses;
+// subdomains CNAME to the app's fly.dev hostname.
+func newCertificate(cert *fly.AppCertificate, appName string, ips []fly.IPAddress) *Certificate {
+	result := &Certificate{
+		Hostname:   cert.Hostname,
+		Status:     cert.ClientStatus,
+		Configured: cert.Configured,
+		Issued:     cert.ClientStatus == "Ready" && len(cert.Issued.Nodes) > 0,
+	}
+
+	for _, node := range cert.Issued.Nodes {
+		if result.ExpiresAt.IsZero() || node.ExpiresAt.Before(result.ExpiresAt) {
+			result.ExpiresAt = node.ExpiresAt
+		}
+	}
+
+	if cert.IsApex {
+		for _, ip := range ips {
+			switch ip.Type {
+			case "v4", "shared_v4":
+				result.Records = append(result.Records, DNSRecord{Type: "A", Name: cert.Hostname, Value: ip.Address})
+			case "v6":
+				result.Records = append(result.Records, DNSRecord{Type: "AAAA", Name: cert.Hostname, Value: ip.Address})
+			}
+		}
+	} else {
+		result.Records = append(result.Records, DNSRecord{Type: "CNAME", Name: cert.Hostname, Value: appName + ".fly.dev"})
+	}
+
+	if cert.DNSValidationHostname != "" && cert.DNSValidationTarget != "" {
+		result.Records = append(result.Records, DNSRecord{Type: "CNAME", Name: cert.DNSValidationHostname, Value: cert.DNSValidationTarget})
+	}
+
+	for _, validationErr := range cert.ValidationErrors {
+		result.ValidationErrors = append(result.ValidationErrors, validationErr.Message)
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package flyio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/superfly/fly-go"
+)
+
+func TestNewCertificateSubdomain(t *testing.T) {
+	cert := &fly.AppCertificate{
+		Hostname:              "app.example.com",
+		ClientStatus:          "Awaiting configuration",
+		DNSValidationHostname: "_acme-challenge.app.example.com",
+		DNSValidationTarget:   "app.example.com.x1y2.flydns.net",
+		ValidationErrors:      []fly.AppCertificateValidationError{{Message: "CNAME not found"}},
+	}
+
+	got := newCertificate(cert, "myapp-123", nil)
+
+	if got.Issued {
+		t.Error("Issued = true for a certificate awaiting configuration")
+	}
+	want := []DNSRecord{
+		{Type: "CNAME", Name: "app.example.com", Value: "myapp-123.fly.dev"},
+		{Type: "CNAME", Name: "_acme-challenge.app.example.com", Value: "app.example.com.x1y2.flydns.net"},
+	}
+	if len(got.Records) != len(want) {
+		t.Fatalf("Records = %+v, want %+v", got.Records, want)
+	}
+	for i := range want {
+		if got.Records[i] != want[i] {
+			t.Errorf("Records[%d] = %+v, want %+v", i, got.Records[i], want[i])
+		}
+	}
+	if len(got.ValidationErrors) != 1 || got.ValidationErrors[0] != "CNAME not found" {
+		t.Errorf("ValidationErrors = %v", got.ValidationErrors)
+	}
+}
+
+func TestNewCertificateApexIssued(t *testing.T) {
+	cert := &fly.AppCertificate{Hostname: "example.com", ClientStatus: "Ready", IsApex: true}
+	expires := time.Date(2027, 1, 10, 0, 0, 0, 0, time.UTC)
+	cert.Issued.Nodes = append(cert.Issued.Nodes, struct {
+		ExpiresAt time.Time
+		Type      string
+	}{ExpiresAt: expires, Type: "rsa"})
+
+	ips := []fly.IPAddress{
+		{Address: "66.241.124.10", Type: "shared_v4"},
+		{Address: "2a09:8280:1::1", Type: "v6"},
+		{Address: "fdaa:0:1::3", Type: "private_v6"},
+	}
+
+	got := newCertificate(cert, "myapp-123", ips)
+
+	if !got.Issued || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("Issued = %v, ExpiresAt = %v, want issued expiring %v", got.Issued, got.ExpiresAt, expires)
+	}
+	want := []DNSRecord{
+		{Type: "A", Name: "example.com", Value: "66.241.124.10"},
+		{Type: "AAAA", Name: "example.com", Value: "2a09:8280:1::1"},
+	}
+	if len(got.Records) != len(want) || got.Records[0] != want[0] || got.Records[1] != want[1] {
+		t.Errorf("Records = %+v, want %+v", got.Records, want)
+	}
+}
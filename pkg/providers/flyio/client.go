@@ -64,11 +64,31 @@ func (c *Client) ValidateCredentials(ctx context.Context) error {
 			Code:     "invalid_credentials",
 			Message:  "Invalid fly.io API token",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	return nil
 }
 
+// AccountInfo returns the user's email and the organizations the token can use
+func (c *Client) AccountInfo(ctx context.Context) (string, error) {
+	user, err := c.apiClient.GetCurrentUser(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	orgs, err := c.apiClient.GetOrganizations(ctx)
+	if err != nil || len(orgs) == 0 {
+		return user.Email, nil
+	}
+
+	slugs := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		slugs = append(slugs, org.Slug)
+	}
+	return fmt.Sprintf("%s (orgs: %s)", user.Email, strings.Join(slugs, ", ")), nil
+}
+
 func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 	regions, _, err := c.apiClient.PlatformRegions(ctx)
 	if err != nil {
@@ -131,6 +151,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "get_org_failed",
 			Message:  "Failed to fetch organizations",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -0,0 +1,23 @@
+package flyio
+
+import (
+	"lightfold/pkg/providers"
+	"strings"
+)
+
+// LabelMetadata translates labels to Fly machine metadata, set on every
+// deploy. Keys allow letters, digits, '-' and '_'; other characters become
+// '_'. Keys starting with fly_ are reserved by Fly and left out.
+func LabelMetadata(labels map[string]string) map[string]string {
+	metadata := make(map[string]string, len(labels))
+	for key, value := range labels {
+		key = providers.ReplaceChars(key, func(r rune) bool {
+			return providers.IsASCIIAlnum(r) || r == '-' || r == '_'
+		}, '_')
+		if strings.HasPrefix(strings.ToLower(key), "fly_") {
+			continue
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
@@ -0,0 +1,14 @@
+package flyio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelMetadata(t *testing.T) {
+	got := LabelMetadata(map[string]string{"team": "payments", "cost.center": "R&D", "fly_process_group": "web"})
+	want := map[string]string{"team": "payments", "cost_center": "R&D"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LabelMetadata() = %v, want %v", got, want)
+	}
+}
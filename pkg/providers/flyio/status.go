@@ -0,0 +1,160 @@
+package flyio
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"sort"
+	"time"
+
+	"github.com/superfly/fly-go"
+	"github.com/superfly/fly-go/flaps"
+	"github.com/superfly/fly-go/tokens"
+)
+
+// AppStatus is the state of a fly.io app as the machines API reports it
+type AppStatus struct {
+	App        string          `json:"app"`
+	Hostname   string          `json:"hostname"`              // Public hostname, e.g. myapp.fly.dev
+	Release    int             `json:"release,omitempty"`     // Version of the current release
+	LastDeploy string          `json:"last_deploy,omitempty"` // When the current release was created (RFC 3339)
+	Image      string          `json:"image,omitempty"`       // Image of the current release
+	Machines   []MachineStatus `json:"machines"`
+}
+
+// MachineStatus is one machine of a fly.io app
+type MachineStatus struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name,omitempty"`
+	State  string        `json:"state"` // started, stopped, suspended, ...
+	Region string        `json:"region"`
+	Size   string        `json:"size,omitempty"` // e.g. shared-cpu-1x, 256MB
+	Image  string        `json:"image,omitempty"`
+	Checks []CheckStatus `json:"checks,omitempty"`
+}
+
+// CheckStatus is the last result of a health check configured on the app
+type CheckStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // passing, warning or critical
+	Output string `json:"output,omitempty"`
+}
+
+// Started reports whether any of the app's machines is running
+func (s *AppStatus) Started() bool {
+	for _, machine := range s.Machines {
+		if machine.State == "started" {
+			return true
+		}
+	}
+	return false
+}
+
+// AppStatus lists the app's machines with their health checks, and the
+// current release. The release is best effort: apps deployed outside
+// lightfold may have none.
+func (c *Client) AppStatus(ctx context.Context, appName string) (*AppStatus, error) {
+	app, err := c.apiClient.GetAppCompact(ctx, appName)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "get_app_failed",
+			Message:  fmt.Sprintf("Failed to get app '%s': %s", appName, err.Error()),
+			Details:  map[string]interface{}{"app_name": appName},
+			Err:      err,
+		}
+	}
+
+	flapsClient, err := flaps.NewWithOptions(ctx, flaps.NewClientOpts{
+		AppName: appName,
+		Tokens:  tokens.Parse(c.token),
+	})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "flaps_client_failed",
+			Message:  fmt.Sprintf("Failed to create flaps client: %s", err.Error()),
+			Err:      err,
+		}
+	}
+	machines, err := flapsClient.List(ctx, "")
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "flyio",
+			Code:     "list_machines_failed",
+			Message:  fmt.Sprintf("Failed to list machines of app '%s': %s", appName, err.Error()),
+			Details:  map[string]interface{}{"app_name": appName},
+			Err:      err,
+		}
+	}
+
+	status := newAppStatus(appName, app.Hostname, machines)
+	if release, err := c.apiClient.GetAppCurrentReleaseMachines(ctx, appName); err == nil && release != nil {
+		status.Release = release.Version
+		status.LastDeploy = release.CreatedAt.UTC().Format(time.RFC3339)
+		status.Image = release.ImageRef
+	}
+	return status, nil
+}
+
+// newAppStatus summarizes the machines of an app, ordered by region and ID
+func newAppStatus(appName, hostname string, machines []*fly.Machine) *AppStatus {
+	if hostname == "" {
+		hostname = appName + ".fly.dev"
+	}
+	status := &AppStatus{App: appName, Hostname: hostname, Machines: []MachineStatus{}}
+	for _, machine := range machines {
+		status.Machines = append(status.Machines, newMachineStatus(machine))
+	}
+	sort.Slice(status.Machines, func(i, j int) bool {
+		a, b := status.Machines[i], status.Machines[j]
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.ID < b.ID
+	})
+	return status
+}
+
+func newMachineStatus(machine *fly.Machine) MachineStatus {
+	status := MachineStatus{
+		ID:     machine.ID,
+		Name:   machine.Name,
+		State:  machine.State,
+		Region: machine.Region,
+	}
+	if machine.ImageRef.Repository != "" {
+		status.Image = machine.FullImageRef()
+	}
+
+	// An unreachable host only has a partial config
+	machineConfig := machine.Config
+	if machineConfig == nil {
+		machineConfig = machine.IncompleteConfig
+	}
+	if machineConfig != nil {
+		status.Size = machineSize(machineConfig.Guest)
+		if status.Image == "" {
+			status.Image = machineConfig.Image
+		}
+	}
+
+	for _, check := range machine.Checks {
+		status.Checks = append(status.Checks, CheckStatus{Name: check.Name, Status: string(check.Status), Output: check.Output})
+	}
+	sort.Slice(status.Checks, func(i, j int) bool { return status.Checks[i].Name < status.Checks[j].Name })
+	return status
+}
+
+// machineSize names a machine's guest the way fly.io sizes are named, with
+// its memory: "shared-cpu-1x, 256MB"
+func machineSize(guest *fly.MachineGuest) string {
+	if guest == nil || guest.CPUs == 0 {
+		return ""
+	}
+	kind := guest.CPUKind
+	if kind == "" {
+		kind = "shared"
+	}
+	return fmt.Sprintf("%s-cpu-%dx, %dMB", kind, guest.CPUs, guest.MemoryMB)
+}
@@ -0,0 +1,69 @@
+package flyio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/superfly/fly-go"
+)
+
+func TestNewAppStatus(t *testing.T) {
+	machines := []*fly.Machine{
+		{ID: "b2", State: "stopped", Region: "lhr"},
+		{
+			ID:       "a1",
+			State:    "started",
+			Region:   "iad",
+			ImageRef: fly.MachineImageRef{Registry: "registry.fly.io", Repository: "myapp-123", Tag: "deployment-01"},
+			Config:   &fly.MachineConfig{Guest: &fly.MachineGuest{CPUKind: "shared", CPUs: 1, MemoryMB: 256}},
+			Checks: []*fly.MachineCheckStatus{
+				{Name: "servicecheck-01", Status: "passing"},
+				{Name: "servicecheck-00", Status: "critical", Output: "connection refused"},
+			},
+		},
+		{ID: "a0", State: "started", Region: "lhr", IncompleteConfig: &fly.MachineConfig{Image: "registry.fly.io/myapp-123:old"}},
+	}
+
+	got := newAppStatus("myapp-123", "", machines)
+
+	if got.Hostname != "myapp-123.fly.dev" {
+		t.Errorf("Hostname = %q, want myapp-123.fly.dev", got.Hostname)
+	}
+	if !got.Started() {
+		t.Error("Started() = false with started machines")
+	}
+	var ids []string
+	for _, machine := range got.Machines {
+		ids = append(ids, machine.ID)
+	}
+	if want := "a1 a0 b2"; strings.Join(ids, " ") != want {
+		t.Errorf("Machines in order %v, want %s", ids, want)
+	}
+
+	first := got.Machines[0]
+	if first.Image != "registry.fly.io/myapp-123:deployment-01" || first.Size != "shared-cpu-1x, 256MB" {
+		t.Errorf("Machines[0] = %+v", first)
+	}
+	if len(first.Checks) != 2 || first.Checks[0].Name != "servicecheck-00" || first.Checks[0].Status != "critical" {
+		t.Errorf("Checks = %+v, want them sorted by name", first.Checks)
+	}
+	if got.Machines[1].Image != "registry.fly.io/myapp-123:old" {
+		t.Errorf("Expected the image of an incomplete config, got %q", got.Machines[1].Image)
+	}
+}
+
+func TestMachineSize(t *testing.T) {
+	tests := []struct {
+		guest *fly.MachineGuest
+		want  string
+	}{
+		{guest: nil, want: ""},
+		{guest: &fly.MachineGuest{CPUKind: "performance", CPUs: 2, MemoryMB: 4096}, want: "performance-cpu-2x, 4096MB"},
+		{guest: &fly.MachineGuest{CPUs: 1, MemoryMB: 512}, want: "shared-cpu-1x, 512MB"},
+	}
+	for _, tt := range tests {
+		if got := machineSize(tt.guest); got != tt.want {
+			t.Errorf("machineSize(%+v) = %q, want %q", tt.guest, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"lightfold/pkg/providers"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -23,7 +24,7 @@ type Client struct {
 }
 
 func NewClient(token string) *Client {
-	client := hcloud.NewClient(hcloud.WithToken(token))
+	client := hcloud.NewClient(hcloud.WithToken(token), hcloud.WithHTTPClient(providers.HTTPClient("hetzner")))
 	return &Client{
 		client: client,
 		token:  token,
@@ -67,6 +68,7 @@ func (c *Client) ValidateCredentials(ctx context.Context) error {
 			Code:     "invalid_credentials",
 			Message:  "Invalid Hetzner Cloud API token",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -81,6 +83,7 @@ func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 			Code:     "list_regions_failed",
 			Message:  "Failed to list Hetzner Cloud locations",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -104,12 +107,16 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 			Code:     "list_sizes_failed",
 			Message:  "Failed to list Hetzner Cloud server types",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	var sizes []providers.Size
 	for _, st := range serverTypes {
 		memoryMB := int(st.Memory * 1024)
+		if region != "" && !availableInLocation(st, region) {
+			continue
+		}
 		if memoryMB >= 512 {
 			priceMonthly := 0.0
 			priceHourly := 0.0
@@ -135,6 +142,7 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 				Disk:         st.Disk,
 				PriceMonthly: priceMonthly,
 				PriceHourly:  priceHourly,
+				Architecture: providers.NormalizeArch(string(st.Architecture)),
 			})
 		}
 	}
@@ -153,6 +161,7 @@ func (c *Client) GetImages(ctx context.Context) ([]providers.Image, error) {
 			Code:     "list_images_failed",
 			Message:  "Failed to list Hetzner Cloud images",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -208,6 +217,7 @@ func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*pro
 			Code:     "upload_ssh_key_failed",
 			Message:  fmt.Sprintf("Failed to upload SSH key to Hetzner Cloud: %v", err),
 			Details:  map[string]interface{}{"error": err.Error(), "name": name},
+			Err:      err,
 		}
 	}
 
@@ -219,6 +229,35 @@ func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*pro
 	}, nil
 }
 
+// DeleteSSHKey removes the project SSH key with the given name
+func (c *Client) DeleteSSHKey(ctx context.Context, name string) (bool, error) {
+	key, _, err := c.client.SSHKey.GetByName(ctx, name)
+	if err != nil {
+		return false, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "get_ssh_key_failed",
+			Message:  fmt.Sprintf("Failed to look up Hetzner Cloud SSH key %s: %v", name, err),
+			Details:  map[string]interface{}{"error": err.Error(), "name": name},
+			Err:      err,
+		}
+	}
+	if key == nil {
+		return false, nil
+	}
+
+	if _, err := c.client.SSHKey.Delete(ctx, key); err != nil {
+		return false, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "delete_ssh_key_failed",
+			Message:  fmt.Sprintf("Failed to delete Hetzner Cloud SSH key %s: %v", name, err),
+			Details:  map[string]interface{}{"error": err.Error(), "name": name},
+			Err:      err,
+		}
+	}
+
+	return true, nil
+}
+
 func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig) (*providers.Server, error) {
 	// Fetch server type
 	serverType, _, err := c.client.ServerType.GetByName(ctx, config.Size)
@@ -228,6 +267,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "invalid_server_type",
 			Message:  fmt.Sprintf("Invalid server type: %s", config.Size),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	if serverType == nil {
@@ -247,6 +287,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "invalid_location",
 			Message:  fmt.Sprintf("Invalid location: %s", config.Region),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	if location == nil {
@@ -258,21 +299,26 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		}
 	}
 
-	// Fetch image (x86 architecture for standard servers)
-	image, _, err := c.client.Image.GetByNameAndArchitecture(ctx, config.Image, hcloud.ArchitectureX86)
+	// Fetch the image built for the server type's architecture (CAX types are ARM)
+	architecture := serverType.Architecture
+	if architecture == "" {
+		architecture = hcloud.ArchitectureX86
+	}
+	image, _, err := c.client.Image.GetByNameAndArchitecture(ctx, config.Image, architecture)
 	if err != nil {
 		return nil, &providers.ProviderError{
 			Provider: "hetzner",
 			Code:     "invalid_image",
 			Message:  fmt.Sprintf("Invalid image: %s", config.Image),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	if image == nil {
 		return nil, &providers.ProviderError{
 			Provider: "hetzner",
 			Code:     "image_not_found",
-			Message:  fmt.Sprintf("Image not found: %s", config.Image),
+			Message:  fmt.Sprintf("Image not found: %s (%s)", config.Image, architecture),
 			Details:  map[string]interface{}{},
 		}
 	}
@@ -286,6 +332,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 				Code:     "invalid_ssh_key",
 				Message:  fmt.Sprintf("Invalid SSH key ID: %s", keyID),
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 		}
 
@@ -296,6 +343,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 				Code:     "ssh_key_not_found",
 				Message:  fmt.Sprintf("SSH key not found: %s", keyID),
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 		}
 		sshKeys = append(sshKeys, key)
@@ -308,7 +356,8 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		Image:      image,
 		SSHKeys:    sshKeys,
 		UserData:   config.UserData,
-		Labels:     convertTagsToLabels(config.Tags),
+		Labels:     provisionLabels(config),
+		PublicNet:  publicNetForStack(config.IPStack),
 	})
 
 	if err != nil {
@@ -317,6 +366,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "create_server_failed",
 			Message:  "Failed to create Hetzner Cloud server",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -331,6 +381,7 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -341,6 +392,7 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "get_server_failed",
 			Message:  "Failed to get Hetzner Cloud server",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -356,6 +408,26 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 	return convertServerToProvider(server), nil
 }
 
+func (c *Client) ListServers(ctx context.Context) ([]providers.Server, error) {
+	hetznerServers, err := c.client.Server.All(ctx)
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "list_servers_failed",
+			Message:  "Failed to list Hetzner Cloud servers",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	servers := make([]providers.Server, 0, len(hetznerServers))
+	for _, server := range hetznerServers {
+		servers = append(servers, *convertServerToProvider(server))
+	}
+
+	return servers, nil
+}
+
 func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	id, err := strconv.ParseInt(serverID, 10, 64)
 	if err != nil {
@@ -364,6 +436,7 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -375,12 +448,62 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "destroy_server_failed",
 			Message:  "Failed to destroy Hetzner Cloud server",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	return nil
 }
 
+// PowerOff cuts power to the server. Hetzner keeps billing stopped servers.
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	id, err := strconv.ParseInt(serverID, 10, 64)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if _, _, err := c.client.Server.Poweroff(ctx, &hcloud.Server{ID: id}); err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "power_off_failed",
+			Message:  "Failed to power off Hetzner Cloud server",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	id, err := strconv.ParseInt(serverID, 10, 64)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if _, _, err := c.client.Server.Poweron(ctx, &hcloud.Server{ID: id}); err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "power_on_failed",
+			Message:  "Failed to power on Hetzner Cloud server",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
 func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout time.Duration) (*providers.Server, error) {
 	id, err := strconv.ParseInt(serverID, 10, 64)
 	if err != nil {
@@ -389,6 +512,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -402,6 +526,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 				Code:     "poll_server_failed",
 				Message:  "Failed to poll Hetzner Cloud server status",
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 		}
 
@@ -425,10 +550,37 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 	}
 }
 
+// publicNetForStack returns the public network options for an IP stack, or nil
+// to keep Hetzner's default of both IPv4 and IPv6
+func publicNetForStack(stack string) *hcloud.ServerCreatePublicNet {
+	switch stack {
+	case providers.IPStackDual:
+		return &hcloud.ServerCreatePublicNet{EnableIPv4: true, EnableIPv6: true}
+	case providers.IPStackIPv4Only:
+		return &hcloud.ServerCreatePublicNet{EnableIPv4: true}
+	case providers.IPStackIPv6Only:
+		return &hcloud.ServerCreatePublicNet{EnableIPv6: true}
+	default:
+		return nil
+	}
+}
+
+// ipv6HostAddress returns the server's address in its /64, which Hetzner
+// configures as ::1 of the network
+func ipv6HostAddress(ipv6 hcloud.ServerPublicNetIPv6) string {
+	if ipv6.IsUnspecified() {
+		return ""
+	}
+	address := make(net.IP, net.IPv6len)
+	copy(address, ipv6.IP.To16())
+	address[net.IPv6len-1] |= 1
+	return address.String()
+}
+
 func convertServerToProvider(server *hcloud.Server) *providers.Server {
 	var publicIPv4, privateIPv4 string
 
-	if server.PublicNet.IPv4.IP != nil {
+	if !server.PublicNet.IPv4.IsUnspecified() {
 		publicIPv4 = server.PublicNet.IPv4.IP.String()
 	}
 
@@ -467,17 +619,19 @@ func convertServerToProvider(server *hcloud.Server) *providers.Server {
 	}
 
 	return &providers.Server{
-		ID:          strconv.FormatInt(server.ID, 10),
-		Name:        server.Name,
-		Status:      string(server.Status),
-		PublicIPv4:  publicIPv4,
-		PrivateIPv4: privateIPv4,
-		Region:      regionName,
-		Size:        server.ServerType.Name,
-		Image:       imageID,
-		Tags:        tags,
-		CreatedAt:   server.Created,
-		Metadata:    metadata,
+		ID:           strconv.FormatInt(server.ID, 10),
+		Name:         server.Name,
+		Status:       string(server.Status),
+		PublicIPv4:   publicIPv4,
+		PublicIPv6:   ipv6HostAddress(server.PublicNet.IPv6),
+		PrivateIPv4:  privateIPv4,
+		Region:       regionName,
+		Size:         server.ServerType.Name,
+		Image:        imageID,
+		Architecture: providers.NormalizeArch(string(server.ServerType.Architecture)),
+		Tags:         tags,
+		CreatedAt:    server.Created,
+		Metadata:     metadata,
 	}
 }
 
@@ -491,6 +645,16 @@ func extractVersionFromImageName(imageName string) string {
 	return ""
 }
 
+// provisionLabels returns the labels a new server gets: its tags, with the
+// target's labels over them
+func provisionLabels(config providers.ProvisionConfig) map[string]string {
+	labels := convertTagsToLabels(config.Tags)
+	for key, value := range labelMap(config.Labels) {
+		labels[key] = value
+	}
+	return labels
+}
+
 func convertTagsToLabels(tags []string) map[string]string {
 	labels := make(map[string]string)
 	for _, tag := range tags {
@@ -503,3 +667,17 @@ func convertTagsToLabels(tags []string) map[string]string {
 	}
 	return labels
 }
+
+// availableInLocation reports whether a server type can be ordered in the given location.
+// Older API responses without location data are treated as available everywhere.
+func availableInLocation(st *hcloud.ServerType, location string) bool {
+	if len(st.Locations) == 0 {
+		return true
+	}
+	for _, loc := range st.Locations {
+		if loc.Location != nil && loc.Location.Name == location {
+			return !loc.IsDeprecated()
+		}
+	}
+	return false
+}
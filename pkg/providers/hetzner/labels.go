@@ -0,0 +1,89 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// maxLabelLength is the longest label key or value Hetzner accepts
+const maxLabelLength = 63
+
+// labelMap translates labels to Hetzner labels. Keys and values allow
+// letters, digits, '-', '_' and '.', and must start and end with a letter or
+// digit; other characters become '_' and the ends are trimmed.
+func labelMap(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if key = cleanLabel(key); key != "" {
+			result[key] = cleanLabel(value)
+		}
+	}
+	return result
+}
+
+func cleanLabel(s string) string {
+	s = providers.ReplaceChars(s, func(r rune) bool {
+		return providers.IsASCIIAlnum(r) || r == '-' || r == '_' || r == '.'
+	}, '_')
+	if len(s) > maxLabelLength {
+		s = s[:maxLabelLength]
+	}
+	return strings.TrimFunc(s, func(r rune) bool { return !providers.IsASCIIAlnum(r) })
+}
+
+// SetLabels replaces the previous labels of the server with labels, keeping
+// labels set outside lightfold
+func (c *Client) SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error {
+	id, err := strconv.ParseInt(serverID, 10, 64)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	server, _, err := c.client.Server.GetByID(ctx, id)
+	if err != nil {
+		return retagError(err)
+	}
+	if server == nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "server_not_found",
+			Message:  fmt.Sprintf("Server %s not found", serverID),
+		}
+	}
+
+	updated := make(map[string]string, len(server.Labels)+len(labels))
+	for key, value := range server.Labels {
+		updated[key] = value
+	}
+	for key := range labelMap(previous) {
+		delete(updated, key)
+	}
+	for key, value := range labelMap(labels) {
+		updated[key] = value
+	}
+	if _, _, err := c.client.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: updated}); err != nil {
+		return retagError(err)
+	}
+	return nil
+}
+
+func retagError(err error) error {
+	return &providers.ProviderError{
+		Provider: "hetzner",
+		Code:     "retag_failed",
+		Message:  "Failed to update Hetzner Cloud server labels",
+		Details:  map[string]interface{}{"error": err.Error()},
+		Err:      err,
+	}
+}
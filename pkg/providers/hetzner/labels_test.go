@@ -0,0 +1,44 @@
+package hetzner
+
+import (
+	"lightfold/pkg/providers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLabelMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   map[string]string
+	}{
+		{"valid", map[string]string{"team": "payments", "cost.center": "r-and-d_1"}, map[string]string{"team": "payments", "cost.center": "r-and-d_1"}},
+		{"invalid characters", map[string]string{"owner": "ops@example.com"}, map[string]string{"owner": "ops_example.com"}},
+		{"spaces and ends trimmed", map[string]string{"note": " R&D team! "}, map[string]string{"note": "R_D_team"}},
+		{"empty value", map[string]string{"billable": ""}, map[string]string{"billable": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelMap(tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("labelMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	long := labelMap(map[string]string{"team": strings.Repeat("x", 70)})
+	if len(long["team"]) != maxLabelLength {
+		t.Errorf("long value has %d characters, want %d", len(long["team"]), maxLabelLength)
+	}
+}
+
+func TestProvisionLabels(t *testing.T) {
+	got := provisionLabels(providers.ProvisionConfig{
+		Tags:   []string{"lightfold", "myapp"},
+		Labels: map[string]string{"team": "payments", "lightfold": "managed"},
+	})
+	want := map[string]string{"lightfold": "managed", "myapp": "true", "team": "payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("provisionLabels() = %v, want %v", got, want)
+	}
+}
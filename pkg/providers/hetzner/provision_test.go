@@ -0,0 +1,148 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"lightfold/pkg/providers"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	caxServerType = `{"id": 45, "name": "cax11", "cores": 2, "memory": 4, "disk": 40, "architecture": "arm", "cpu_type": "shared",
+		"locations": [{"id": 1, "name": "fsn1"}],
+		"prices": [{"location": "fsn1", "price_hourly": {"net": "0.0060", "gross": "0.0071"}, "price_monthly": {"net": "3.79", "gross": "4.51"}}]}`
+	cxServerType = `{"id": 22, "name": "cx22", "cores": 2, "memory": 4, "disk": 40, "architecture": "x86", "cpu_type": "shared",
+		"locations": [{"id": 1, "name": "fsn1"}],
+		"prices": [{"location": "fsn1", "price_hourly": {"net": "0.0060", "gross": "0.0071"}, "price_monthly": {"net": "3.79", "gross": "4.51"}}]}`
+)
+
+// newMockHetznerAPI serves the Hetzner Cloud endpoints used to provision a
+// server and records the architecture of every image lookup
+func newMockHetznerAPI(t *testing.T, imageArchitectures *[]string) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/server_types", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("name") {
+		case "cax11":
+			w.Write([]byte(`{"server_types": [` + caxServerType + `]}`))
+		case "":
+			w.Write([]byte(`{"server_types": [` + cxServerType + `, ` + caxServerType + `]}`))
+		default:
+			w.Write([]byte(`{"server_types": []}`))
+		}
+	})
+	mux.HandleFunc("/locations", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"locations": [{"id": 1, "name": "fsn1", "city": "Falkenstein"}]}`))
+	})
+	mux.HandleFunc("/images", func(w http.ResponseWriter, r *http.Request) {
+		architecture := r.URL.Query().Get("architecture")
+		*imageArchitectures = append(*imageArchitectures, architecture)
+		w.Write([]byte(`{"images": [{"id": 103908070, "name": "ubuntu-22.04", "type": "system", "architecture": "` + architecture + `"}]}`))
+	})
+	mux.HandleFunc("/servers", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name  string `json:"name"`
+			Image int64  `json:"image"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode create request: %v", err)
+		}
+		if body.Image != 103908070 {
+			t.Errorf("created with image %d, want the arm image 103908070", body.Image)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"server": {"id": 4711, "name": "` + body.Name + `", "status": "initializing",
+			"created": "2026-10-16T12:00:00+00:00",
+			"public_net": {"ipv4": {"ip": "203.0.113.20"}},
+			"server_type": ` + caxServerType + `,
+			"datacenter": {"id": 2, "name": "fsn1-dc14", "location": {"id": 1, "name": "fsn1", "city": "Falkenstein"}},
+			"image": {"id": 103908070, "name": "ubuntu-22.04", "architecture": "arm"}},
+			"action": {"id": 1, "command": "create_server", "status": "running"},
+			"next_actions": []}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &Client{
+		client: hcloud.NewClient(hcloud.WithToken("test-token"), hcloud.WithEndpoint(server.URL)),
+		token:  "test-token",
+	}
+}
+
+func TestProvision_ARMServerType(t *testing.T) {
+	var imageArchitectures []string
+	client := newMockHetznerAPI(t, &imageArchitectures)
+
+	sizes, err := client.GetSizes(context.Background(), "fsn1")
+	if err != nil {
+		t.Fatalf("GetSizes() error = %v", err)
+	}
+	archBySize := map[string]string{}
+	for _, size := range sizes {
+		archBySize[size.ID] = size.Architecture
+	}
+	if archBySize["cax11"] != providers.ArchARM64 || archBySize["cx22"] != providers.ArchX86_64 {
+		t.Errorf("size architectures = %v, want cax11=arm64 and cx22=x86_64", archBySize)
+	}
+
+	server, err := client.Provision(context.Background(), providers.ProvisionConfig{
+		Name:   "myapp",
+		Size:   "cax11",
+		Region: "fsn1",
+		Image:  "ubuntu-22.04",
+	})
+	if err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	if len(imageArchitectures) != 1 || imageArchitectures[0] != "arm" {
+		t.Errorf("image lookups used architectures %v, want [arm]", imageArchitectures)
+	}
+	if server.Architecture != providers.ArchARM64 {
+		t.Errorf("server.Architecture = %q, want %q", server.Architecture, providers.ArchARM64)
+	}
+	if server.PublicIPv4 != "203.0.113.20" || server.Size != "cax11" {
+		t.Errorf("server = %+v", server)
+	}
+}
+
+func TestConvertServerToProvider_IPv6(t *testing.T) {
+	_, network, _ := net.ParseCIDR("2001:db8:1234:5678::/64")
+	server := &hcloud.Server{
+		ID:         7,
+		ServerType: &hcloud.ServerType{Name: "cx22", Architecture: hcloud.ArchitectureX86},
+		PublicNet: hcloud.ServerPublicNet{
+			IPv6: hcloud.ServerPublicNetIPv6{IP: network.IP, Network: network},
+		},
+	}
+
+	converted := convertServerToProvider(server)
+	if converted.PublicIPv4 != "" {
+		t.Errorf("PublicIPv4 = %q, want none on an IPv6-only server", converted.PublicIPv4)
+	}
+	if converted.PublicIPv6 != "2001:db8:1234:5678::1" {
+		t.Errorf("PublicIPv6 = %q, want the ::1 host of the /64", converted.PublicIPv6)
+	}
+	if converted.PrimaryIP() != converted.PublicIPv6 {
+		t.Errorf("PrimaryIP() = %q, want the IPv6 address", converted.PrimaryIP())
+	}
+}
+
+func TestPublicNetForStack(t *testing.T) {
+	if publicNetForStack("") != nil {
+		t.Error("empty stack should keep Hetzner's default public network")
+	}
+	if publicNet := publicNetForStack(providers.IPStackIPv6Only); publicNet == nil || publicNet.EnableIPv4 || !publicNet.EnableIPv6 {
+		t.Errorf("ipv6-only public net = %+v", publicNet)
+	}
+	if publicNet := publicNetForStack(providers.IPStackIPv4Only); publicNet == nil || !publicNet.EnableIPv4 || publicNet.EnableIPv6 {
+		t.Errorf("ipv4-only public net = %+v", publicNet)
+	}
+}
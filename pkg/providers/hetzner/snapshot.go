@@ -0,0 +1,151 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func (c *Client) Snapshot(ctx context.Context, serverID, name string, progress func(percent int)) (*providers.Snapshot, error) {
+	id, err := parseServerID(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, _, err := c.client.Server.CreateImage(ctx, &hcloud.Server{ID: id}, &hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Description: hcloud.Ptr(name),
+	})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "create_snapshot_failed",
+			Message:  "Failed to create Hetzner Cloud snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if err := c.waitForAction(ctx, result.Action, progress); err != nil {
+		return nil, err
+	}
+
+	image, _, err := c.client.Image.GetByID(ctx, result.Image.ID)
+	if err != nil || image == nil {
+		// The action finished, so the snapshot exists even if it cannot be re-read
+		image = result.Image
+	}
+	return convertImageToSnapshot(image, serverID), nil
+}
+
+func (c *Client) ListSnapshots(ctx context.Context, serverID string) ([]providers.Snapshot, error) {
+	id, err := parseServerID(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := c.client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		Type: []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+		Sort: []string{"created:desc"},
+	})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "list_snapshots_failed",
+			Message:  "Failed to list Hetzner Cloud snapshots",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	var snapshots []providers.Snapshot
+	for _, image := range images {
+		if image.CreatedFrom == nil || image.CreatedFrom.ID != id {
+			continue
+		}
+		snapshots = append(snapshots, *convertImageToSnapshot(image, serverID))
+	}
+	return snapshots, nil
+}
+
+func (c *Client) RestoreSnapshot(ctx context.Context, serverID, snapshotID string) error {
+	id, err := parseServerID(serverID)
+	if err != nil {
+		return err
+	}
+	imageID, err := strconv.ParseInt(snapshotID, 10, 64)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "invalid_snapshot_id",
+			Message:  fmt.Sprintf("Invalid snapshot ID: %s", snapshotID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	result, _, err := c.client.Server.RebuildWithResult(ctx, &hcloud.Server{ID: id}, hcloud.ServerRebuildOpts{
+		Image: &hcloud.Image{ID: imageID},
+	})
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "restore_snapshot_failed",
+			Message:  "Failed to rebuild Hetzner Cloud server from snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return c.waitForAction(ctx, result.Action, nil)
+}
+
+// waitForAction blocks until action finishes, reporting its progress
+func (c *Client) waitForAction(ctx context.Context, action *hcloud.Action, progress func(percent int)) error {
+	err := c.client.Action.WaitForFunc(ctx, func(update *hcloud.Action) error {
+		if update.Status == hcloud.ActionStatusError {
+			return update.Error()
+		}
+		if progress != nil {
+			progress(update.Progress)
+		}
+		return nil
+	}, action)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "action_failed",
+			Message:  "Hetzner Cloud action did not complete",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+func parseServerID(serverID string) (int64, error) {
+	id, err := strconv.ParseInt(serverID, 10, 64)
+	if err != nil {
+		return 0, &providers.ProviderError{
+			Provider: "hetzner",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return id, nil
+}
+
+func convertImageToSnapshot(image *hcloud.Image, serverID string) *providers.Snapshot {
+	return &providers.Snapshot{
+		ID:        strconv.FormatInt(image.ID, 10),
+		Name:      image.Description,
+		ServerID:  serverID,
+		Status:    string(image.Status),
+		SizeGB:    float64(image.ImageSize),
+		CreatedAt: image.Created,
+	}
+}
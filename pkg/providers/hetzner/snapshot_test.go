@@ -0,0 +1,110 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// newMockSnapshotAPI serves the Hetzner Cloud endpoints used to snapshot and
+// rebuild server 4711. Actions finish on their first poll.
+func newMockSnapshotAPI(t *testing.T, rebuiltImages *[]int64) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers/4711/actions/create_image", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode create_image request: %v", err)
+		}
+		if body.Type != "snapshot" || body.Description != "before-upgrade" {
+			t.Errorf("create_image request = %+v, want a snapshot named before-upgrade", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"image": {"id": 900, "type": "snapshot", "status": "creating", "description": "before-upgrade"},
+			"action": {"id": 10, "command": "create_image", "status": "running", "progress": 0}}`))
+	})
+	mux.HandleFunc("/servers/4711/actions/rebuild", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Image int64 `json:"image"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode rebuild request: %v", err)
+		}
+		*rebuiltImages = append(*rebuiltImages, body.Image)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"action": {"id": 11, "command": "rebuild_server", "status": "running", "progress": 0}}`))
+	})
+	mux.HandleFunc("/actions", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		w.Write([]byte(`{"actions": [{"id": ` + id + `, "status": "success", "progress": 100}]}`))
+	})
+	mux.HandleFunc("/images/900", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"image": {"id": 900, "type": "snapshot", "status": "available", "description": "before-upgrade",
+			"image_size": 2.5, "created": "2026-10-16T12:00:00+00:00", "created_from": {"id": 4711, "name": "myapp"}}}`))
+	})
+	mux.HandleFunc("/images", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"images": [
+			{"id": 900, "type": "snapshot", "status": "available", "description": "before-upgrade", "created_from": {"id": 4711, "name": "myapp"}},
+			{"id": 901, "type": "snapshot", "status": "available", "description": "other", "created_from": {"id": 5000, "name": "other"}}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &Client{
+		client: hcloud.NewClient(
+			hcloud.WithToken("test-token"),
+			hcloud.WithEndpoint(server.URL),
+			hcloud.WithPollOpts(hcloud.PollOpts{BackoffFunc: hcloud.ConstantBackoff(time.Millisecond)}),
+		),
+		token: "test-token",
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	var rebuiltImages []int64
+	client := newMockSnapshotAPI(t, &rebuiltImages)
+	ctx := context.Background()
+
+	var progress []int
+	snapshot, err := client.Snapshot(ctx, "4711", "before-upgrade", func(percent int) {
+		progress = append(progress, percent)
+	})
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if snapshot.ID != "900" || snapshot.Status != "available" || snapshot.ServerID != "4711" || snapshot.SizeGB != 2.5 {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 100 {
+		t.Errorf("progress = %v, want it to end at 100", progress)
+	}
+
+	snapshots, err := client.ListSnapshots(ctx, "4711")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].ID != "900" {
+		t.Errorf("ListSnapshots() = %+v, want only the snapshot taken from server 4711", snapshots)
+	}
+
+	if err := client.RestoreSnapshot(ctx, "4711", "900"); err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	if len(rebuiltImages) != 1 || rebuiltImages[0] != 900 {
+		t.Errorf("rebuilt with images %v, want [900]", rebuiltImages)
+	}
+
+	if err := client.RestoreSnapshot(ctx, "4711", "not-an-id"); err == nil {
+		t.Error("RestoreSnapshot() with an invalid snapshot ID should fail")
+	}
+}
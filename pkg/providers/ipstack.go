@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IP stacks accepted in ProvisionConfig.IPStack. An empty stack keeps the
+// provider's default.
+const (
+	IPStackDual     = "dual"
+	IPStackIPv4Only = "ipv4-only"
+	IPStackIPv6Only = "ipv6-only"
+)
+
+var supportedIPStacks = map[string][]string{
+	"hetzner":      {IPStackDual, IPStackIPv4Only, IPStackIPv6Only},
+	"vultr":        {IPStackDual, IPStackIPv4Only, IPStackIPv6Only},
+	"digitalocean": {IPStackDual, IPStackIPv4Only},
+	"linode":       {IPStackDual},
+	"aws":          {IPStackIPv4Only},
+}
+
+// SupportedIPStacks returns the IP stacks a provider can provision, or nil when
+// it has no IP stack option
+func SupportedIPStacks(provider string) []string {
+	return supportedIPStacks[provider]
+}
+
+// ValidateIPStack checks that the provider can provision servers with stack.
+// An empty stack is always valid.
+func ValidateIPStack(provider, stack string) error {
+	if stack == "" {
+		return nil
+	}
+
+	supported := SupportedIPStacks(provider)
+	for _, s := range supported {
+		if s == stack {
+			return nil
+		}
+	}
+	if len(supported) == 0 {
+		return fmt.Errorf("provider %s does not support choosing an IP stack", provider)
+	}
+	return fmt.Errorf("provider %s does not support IP stack %q (supported: %s)", provider, stack, strings.Join(supported, ", "))
+}
+
+// PrimaryIP returns the address lightfold connects to: the public IPv4, or the
+// public IPv6 on IPv6-only servers
+func (s *Server) PrimaryIP() string {
+	if s.PublicIPv4 != "" {
+		return s.PublicIPv4
+	}
+	return s.PublicIPv6
+}
@@ -0,0 +1,41 @@
+package providers
+
+import "testing"
+
+func TestValidateIPStack(t *testing.T) {
+	tests := []struct {
+		provider string
+		stack    string
+		wantErr  bool
+	}{
+		{"hetzner", "", false},
+		{"hetzner", IPStackIPv6Only, false},
+		{"vultr", IPStackIPv6Only, false},
+		{"digitalocean", IPStackDual, false},
+		{"digitalocean", IPStackIPv6Only, true},
+		{"linode", IPStackIPv4Only, true},
+		{"aws", IPStackIPv4Only, false},
+		{"aws", IPStackDual, true},
+		{"flyio", IPStackDual, true},
+		{"hetzner", "v6", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateIPStack(tt.provider, tt.stack)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateIPStack(%q, %q) error = %v, wantErr %v", tt.provider, tt.stack, err, tt.wantErr)
+		}
+	}
+}
+
+func TestServerPrimaryIP(t *testing.T) {
+	dual := Server{PublicIPv4: "203.0.113.10", PublicIPv6: "2001:db8::1"}
+	if got := dual.PrimaryIP(); got != "203.0.113.10" {
+		t.Errorf("dual-stack PrimaryIP() = %q, want the IPv4 address", got)
+	}
+
+	v6Only := Server{PublicIPv6: "2001:db8::1"}
+	if got := v6Only.PrimaryIP(); got != "2001:db8::1" {
+		t.Errorf("IPv6-only PrimaryIP() = %q, want the IPv6 address", got)
+	}
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Labeler is implemented by providers that can change the labels of an
+// existing server. previous holds the labels lightfold applied before, so
+// labels removed from the config are removed from the server too; labels and
+// tags added outside lightfold are kept.
+type Labeler interface {
+	SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error
+}
+
+// SupportsLabels returns true if the provider can relabel existing servers
+func SupportsLabels(p Provider) bool {
+	_, ok := p.(Labeler)
+	return ok
+}
+
+// LabelKeys returns the keys of labels, sorted so translations are stable
+func LabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FlatLabels renders labels as "key<sep>value" strings for providers whose
+// tags are flat strings. clean rewrites each rendered tag to what the
+// provider accepts; tags it returns empty are dropped.
+func FlatLabels(labels map[string]string, sep string, clean func(string) string) []string {
+	tags := make([]string, 0, len(labels))
+	for _, key := range LabelKeys(labels) {
+		tag := key + sep + labels[key]
+		if clean != nil {
+			tag = clean(tag)
+		}
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ReplaceChars replaces every rune of s that allowed rejects with replacement
+func ReplaceChars(s string, allowed func(rune) bool, replacement rune) string {
+	return strings.Map(func(r rune) rune {
+		if allowed(r) {
+			return r
+		}
+		return replacement
+	}, s)
+}
+
+// IsASCIIAlnum reports whether r is an ASCII letter or digit
+func IsASCIIAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// WithLabelTags returns tags followed by the translated label tags, leaving
+// tags unchanged
+func WithLabelTags(tags, labelTags []string) []string {
+	return append(append([]string(nil), tags...), labelTags...)
+}
+
+// RetagList replaces the previous tags in current with tags, keeping the
+// tags that were set outside lightfold
+func RetagList(current, previous, tags []string) []string {
+	drop := make(map[string]bool, len(previous)+len(tags))
+	for _, tag := range previous {
+		drop[tag] = true
+	}
+	for _, tag := range tags {
+		drop[tag] = true
+	}
+
+	result := make([]string, 0, len(current)+len(tags))
+	for _, tag := range current {
+		if !drop[tag] {
+			result = append(result, tag)
+		}
+	}
+	return append(result, tags...)
+}
@@ -0,0 +1,38 @@
+package providers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlatLabels(t *testing.T) {
+	labels := map[string]string{"team": "payments", "env": "prod", "drop": "x"}
+	tags := FlatLabels(labels, ":", func(tag string) string {
+		if strings.HasPrefix(tag, "drop") {
+			return ""
+		}
+		return tag
+	})
+	if want := []string{"env:prod", "team:payments"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("FlatLabels() = %v, want %v", tags, want)
+	}
+}
+
+func TestRetagList(t *testing.T) {
+	current := []string{"lightfold", "team:payments", "env:staging", "owner:ops"}
+	got := RetagList(current, []string{"team:payments", "env:staging"}, []string{"team:payments", "env:prod"})
+	if want := []string{"lightfold", "owner:ops", "team:payments", "env:prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RetagList() = %v, want %v", got, want)
+	}
+}
+
+func TestWithLabelTagsCopies(t *testing.T) {
+	tags := make([]string, 1, 4)
+	tags[0] = "lightfold"
+	got := WithLabelTags(tags, []string{"team:payments"})
+	got[0] = "changed"
+	if tags[0] != "lightfold" {
+		t.Error("WithLabelTags() must not share the caller's tags")
+	}
+}
@@ -28,7 +28,8 @@ type Client struct {
 
 func NewClient(token string) *Client {
 	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	oauth2Client := oauth2.NewClient(context.Background(), tokenSource)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, providers.HTTPClient("linode"))
+	oauth2Client := oauth2.NewClient(ctx, tokenSource)
 
 	linodeClient := linodego.NewClient(oauth2Client)
 
@@ -58,18 +59,29 @@ func (c *Client) SupportsSSH() bool {
 }
 
 func (c *Client) ValidateCredentials(ctx context.Context) error {
-	_, err := c.client.ListRegions(ctx, &linodego.ListOptions{})
+	// Regions are public, so the profile is used to check the token
+	_, err := c.client.GetProfile(ctx)
 	if err != nil {
 		return &providers.ProviderError{
 			Provider: "linode",
 			Code:     "invalid_credentials",
 			Message:  "Invalid Linode API token",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	return nil
 }
 
+// AccountInfo returns the username and email of the token's profile
+func (c *Client) AccountInfo(ctx context.Context) (string, error) {
+	profile, err := c.client.GetProfile(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s)", profile.Username, profile.Email), nil
+}
+
 func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 	linodeRegions, err := c.client.ListRegions(ctx, &linodego.ListOptions{})
 	if err != nil {
@@ -78,6 +90,7 @@ func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 			Code:     "list_regions_failed",
 			Message:  "Failed to list Linode regions",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -103,6 +116,7 @@ func (c *Client) GetSizes(ctx context.Context, _ string) ([]providers.Size, erro
 			Code:     "list_sizes_failed",
 			Message:  "Failed to list Linode types",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -117,6 +131,7 @@ func (c *Client) GetSizes(ctx context.Context, _ string) ([]providers.Size, erro
 				Disk:         t.Disk,
 				PriceMonthly: float64(t.Price.Monthly),
 				PriceHourly:  float64(t.Price.Hourly),
+				Architecture: providers.ArchX86_64,
 			})
 		}
 	}
@@ -132,6 +147,7 @@ func (c *Client) GetImages(ctx context.Context) ([]providers.Image, error) {
 			Code:     "list_images_failed",
 			Message:  "Failed to list Linode images",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -194,6 +210,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 					Code:     "invalid_ssh_key",
 					Message:  fmt.Sprintf("Invalid SSH key: %s (must be raw public key or numeric ID)", keyStr),
 					Details:  map[string]interface{}{"error": err.Error()},
+					Err:      err,
 				}
 			}
 
@@ -204,6 +221,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 					Code:     "ssh_key_not_found",
 					Message:  fmt.Sprintf("SSH key not found: %s", keyStr),
 					Details:  map[string]interface{}{"error": err.Error()},
+					Err:      err,
 				}
 			}
 			authorizedKeys = append(authorizedKeys, key.SSHKey)
@@ -217,6 +235,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "password_generation_failed",
 			Message:  "Failed to generate root password",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -238,8 +257,8 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		}
 	}
 
-	if len(config.Tags) > 0 {
-		createOpts.Tags = config.Tags
+	if tags := providers.WithLabelTags(config.Tags, labelTags(config.Labels)); len(tags) > 0 {
+		createOpts.Tags = tags
 	}
 
 	instance, err := c.client.CreateInstance(ctx, createOpts)
@@ -249,6 +268,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "create_instance_failed",
 			Message:  "Failed to create Linode instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -263,6 +283,7 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -273,12 +294,33 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "get_instance_failed",
 			Message:  "Failed to get Linode instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	return convertInstanceToServer(instance), nil
 }
 
+func (c *Client) ListServers(ctx context.Context) ([]providers.Server, error) {
+	instances, err := c.client.ListInstances(ctx, &linodego.ListOptions{})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "linode",
+			Code:     "list_instances_failed",
+			Message:  "Failed to list Linode instances",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	servers := make([]providers.Server, 0, len(instances))
+	for i := range instances {
+		servers = append(servers, *convertInstanceToServer(&instances[i]))
+	}
+
+	return servers, nil
+}
+
 func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	instanceID, err := stringToInt(serverID)
 	if err != nil {
@@ -287,6 +329,7 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -297,12 +340,63 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "destroy_instance_failed",
 			Message:  "Failed to destroy Linode instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	return nil
 }
 
+// PowerOff shuts the instance down. Linode keeps billing powered-off instances.
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	instanceID, err := stringToInt(serverID)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "linode",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if err := c.client.ShutdownInstance(ctx, instanceID); err != nil {
+		return &providers.ProviderError{
+			Provider: "linode",
+			Code:     "power_off_failed",
+			Message:  "Failed to shut down Linode instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
+// PowerOn boots the instance with its default configuration profile
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	instanceID, err := stringToInt(serverID)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "linode",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	if err := c.client.BootInstance(ctx, instanceID, 0); err != nil {
+		return &providers.ProviderError{
+			Provider: "linode",
+			Code:     "power_on_failed",
+			Message:  "Failed to boot Linode instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+	return nil
+}
+
 func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout time.Duration) (*providers.Server, error) {
 	instanceID, err := stringToInt(serverID)
 	if err != nil {
@@ -311,6 +405,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 			Code:     "invalid_server_id",
 			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -324,6 +419,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 				Code:     "poll_instance_failed",
 				Message:  "Failed to poll Linode instance status",
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 		}
 
@@ -377,6 +473,7 @@ func convertInstanceToServer(instance *linodego.Instance, rootPassword ...string
 		Name:        instance.Label,
 		Status:      string(instance.Status),
 		PublicIPv4:  publicIPv4,
+		PublicIPv6:  strings.Split(instance.IPv6, "/")[0],
 		PrivateIPv4: "",
 		Region:      instance.Region,
 		Size:        instance.Type,
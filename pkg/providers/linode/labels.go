@@ -0,0 +1,64 @@
+package linode
+
+import (
+	"context"
+	"fmt"
+	"lightfold/pkg/providers"
+
+	"github.com/linode/linodego"
+)
+
+// Linode tags are 3 to 50 characters long
+const (
+	minTagLength = 3
+	maxTagLength = 50
+)
+
+// labelTags renders labels as key:value tags, cut to Linode's length limit.
+// Tags too short for Linode, such as "a:", are left out.
+func labelTags(labels map[string]string) []string {
+	return providers.FlatLabels(labels, ":", func(tag string) string {
+		if len(tag) > maxTagLength {
+			tag = tag[:maxTagLength]
+		}
+		if len(tag) < minTagLength {
+			return ""
+		}
+		return tag
+	})
+}
+
+// SetLabels replaces the previous label tags of the instance with labels,
+// keeping tags set outside lightfold
+func (c *Client) SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error {
+	instanceID, err := stringToInt(serverID)
+	if err != nil {
+		return &providers.ProviderError{
+			Provider: "linode",
+			Code:     "invalid_server_id",
+			Message:  fmt.Sprintf("Invalid server ID: %s", serverID),
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	instance, err := c.client.GetInstance(ctx, instanceID)
+	if err != nil {
+		return retagError(err)
+	}
+	tags := providers.RetagList(instance.Tags, labelTags(previous), labelTags(labels))
+	if _, err := c.client.UpdateInstance(ctx, instanceID, linodego.InstanceUpdateOptions{Tags: &tags}); err != nil {
+		return retagError(err)
+	}
+	return nil
+}
+
+func retagError(err error) error {
+	return &providers.ProviderError{
+		Provider: "linode",
+		Code:     "retag_failed",
+		Message:  "Failed to update Linode instance tags",
+		Details:  map[string]interface{}{"error": err.Error()},
+		Err:      err,
+	}
+}
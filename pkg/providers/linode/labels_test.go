@@ -0,0 +1,19 @@
+package linode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLabelTags(t *testing.T) {
+	got := labelTags(map[string]string{
+		"team":  "payments",
+		"a":     "",
+		"notes": strings.Repeat("x", 60),
+	})
+	want := []string{"notes:" + strings.Repeat("x", maxTagLength-len("notes:")), "team:payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labelTags() = %v, want %v (too short tags dropped, long ones cut)", got, want)
+	}
+}
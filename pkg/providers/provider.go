@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -52,6 +53,116 @@ type Provider interface {
 	UploadSSHKey(ctx context.Context, name, publicKey string) (*SSHKey, error)
 }
 
+// ServerLister is implemented by providers that can list servers on the account.
+// It is used to adopt servers created outside lightfold.
+type ServerLister interface {
+	ListServers(ctx context.Context) ([]Server, error)
+}
+
+// SupportsServerListing returns true if the provider can list existing servers
+func SupportsServerListing(p Provider) bool {
+	_, ok := p.(ServerLister)
+	return ok
+}
+
+// SSHKeyDeleter is implemented by providers that can remove an uploaded SSH key.
+// DeleteSSHKey reports false without an error when no key with that name exists.
+type SSHKeyDeleter interface {
+	DeleteSSHKey(ctx context.Context, name string) (bool, error)
+}
+
+// LoadBalancerProvider is implemented by providers that can put a target's
+// servers behind a managed load balancer
+type LoadBalancerProvider interface {
+	// EnsureLoadBalancer creates the load balancer, or updates it when config.ID is set
+	EnsureLoadBalancer(ctx context.Context, config LoadBalancerConfig) (*LoadBalancer, error)
+	DeleteLoadBalancer(ctx context.Context, id string) error
+}
+
+// SupportsLoadBalancers returns true if the provider can manage load balancers
+func SupportsLoadBalancers(p Provider) bool {
+	_, ok := p.(LoadBalancerProvider)
+	return ok
+}
+
+// PowerManager is implemented by providers that can power a server off and
+// back on while keeping its disk
+type PowerManager interface {
+	PowerOff(ctx context.Context, serverID string) error
+	PowerOn(ctx context.Context, serverID string) error
+}
+
+// SupportsPowerManagement returns true if the provider can power servers off and on
+func SupportsPowerManagement(p Provider) bool {
+	_, ok := p.(PowerManager)
+	return ok
+}
+
+// Snapshot is a provider-side image of a server's disk
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ServerID  string    `json:"server_id,omitempty"`
+	Status    string    `json:"status"`
+	SizeGB    float64   `json:"size_gb,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnapshotManager is implemented by providers that can snapshot a server's
+// disk and rebuild the server from a snapshot in place. Snapshot and
+// RestoreSnapshot block until the provider finishes; progress receives a
+// percentage, or -1 when the provider does not report one.
+type SnapshotManager interface {
+	Snapshot(ctx context.Context, serverID, name string, progress func(percent int)) (*Snapshot, error)
+	ListSnapshots(ctx context.Context, serverID string) ([]Snapshot, error)
+	RestoreSnapshot(ctx context.Context, serverID, snapshotID string) error
+}
+
+// SupportsSnapshots returns true if the provider can snapshot and restore servers
+func SupportsSnapshots(p Provider) bool {
+	_, ok := p.(SnapshotManager)
+	return ok
+}
+
+// ErrSnapshotsUnsupported returns the error reported when a provider has no
+// snapshot support
+func ErrSnapshotsUnsupported(provider string) error {
+	return &ProviderError{
+		Provider: provider,
+		Code:     "snapshots_unsupported",
+		Message:  fmt.Sprintf("%s does not support server snapshots (supported: digitalocean, hetzner, vultr)", provider),
+	}
+}
+
+// StaticIPReleaser is implemented by providers whose static IPs are allocated
+// separately from servers and keep being billed until released
+type StaticIPReleaser interface {
+	ReleaseStaticIP(ctx context.Context, id string) error
+}
+
+// AccountDescriber is implemented by providers whose API reports who a token
+// belongs to. AccountInfo returns a short description such as an email address.
+type AccountDescriber interface {
+	AccountInfo(ctx context.Context) (string, error)
+}
+
+// LoadBalancerConfig describes a load balancer forwarding HTTP to a set of servers
+type LoadBalancerConfig struct {
+	ID              string   `json:"id,omitempty"`
+	Name            string   `json:"name"`
+	Region          string   `json:"region"`
+	ServerIDs       []string `json:"server_ids"`
+	HealthCheckPath string   `json:"health_check_path"`
+}
+
+// LoadBalancer represents a provider load balancer
+type LoadBalancer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	IP     string `json:"ip"`
+	Status string `json:"status"`
+}
+
 // Region represents a geographical region for server deployment
 type Region struct {
 	ID       string `json:"id"`
@@ -68,6 +179,7 @@ type Size struct {
 	Disk         int     `json:"disk"`          // GB
 	PriceMonthly float64 `json:"price_monthly"` // USD
 	PriceHourly  float64 `json:"price_hourly"`  // USD
+	Architecture string  `json:"architecture,omitempty"`
 }
 
 // Image represents an OS image
@@ -80,17 +192,19 @@ type Image struct {
 
 // Server represents a provisioned server
 type Server struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Status      string            `json:"status"`
-	PublicIPv4  string            `json:"public_ipv4"`
-	PrivateIPv4 string            `json:"private_ipv4"`
-	Region      string            `json:"region"`
-	Size        string            `json:"size"`
-	Image       string            `json:"image"`
-	Tags        []string          `json:"tags"`
-	CreatedAt   time.Time         `json:"created_at"`
-	Metadata    map[string]string `json:"metadata"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Status       string            `json:"status"`
+	PublicIPv4   string            `json:"public_ipv4"`
+	PublicIPv6   string            `json:"public_ipv6,omitempty"`
+	PrivateIPv4  string            `json:"private_ipv4"`
+	Region       string            `json:"region"`
+	Size         string            `json:"size"`
+	Image        string            `json:"image"`
+	Architecture string            `json:"architecture,omitempty"`
+	Tags         []string          `json:"tags"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Metadata     map[string]string `json:"metadata"`
 }
 
 // SSHKey represents an SSH key for server access
@@ -110,9 +224,11 @@ type ProvisionConfig struct {
 	SSHKeys           []string          `json:"ssh_keys"`  // SSH key IDs
 	UserData          string            `json:"user_data"` // Cloud-init script
 	Tags              []string          `json:"tags"`
+	Labels            map[string]string `json:"labels,omitempty"` // Key/value labels, translated to the provider's tags or labels
 	Metadata          map[string]string `json:"metadata"`
 	BackupsEnabled    bool              `json:"backups_enabled"`
 	MonitoringEnabled bool              `json:"monitoring_enabled"`
+	IPStack           string            `json:"ip_stack,omitempty"` // IPStackDual, IPStackIPv4Only or IPStackIPv6Only; empty keeps the provider default
 }
 
 // ProvisionResult contains the result of a provisioning operation
@@ -128,9 +244,16 @@ type ProviderError struct {
 	Code     string                 `json:"code"`
 	Message  string                 `json:"message"`
 	Details  map[string]interface{} `json:"details,omitempty"`
+	Err      error                  `json:"-"` // Underlying API error, for errors.As
 }
 
 func (e *ProviderError) Error() string {
+	// A rate limited request is not an API failure worth showing in full
+	var rateLimited *RateLimitedError
+	if errors.As(e.Err, &rateLimited) {
+		return fmt.Sprintf("%s: %v", e.Message, rateLimited)
+	}
+
 	if len(e.Details) == 0 {
 		return e.Message
 	}
@@ -155,3 +278,7 @@ func (e *ProviderError) Error() string {
 
 	return msg
 }
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,246 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"lightfold/pkg/debuglog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitBudget is how long one request waits out rate limiting in total
+	// before it fails with a RateLimitedError
+	RateLimitBudget = 2 * time.Minute
+
+	// MaxConcurrentRequests caps the requests in flight to one provider, shared
+	// by every client of that provider in the process
+	MaxConcurrentRequests = 4
+
+	rateLimitBaseDelay = time.Second
+	rateLimitMaxDelay  = 30 * time.Second
+)
+
+// RateLimitedError is returned when a provider still rate limits a request
+// after RateLimitBudget was spent waiting
+type RateLimitedError struct {
+	Provider   string
+	RetryAfter time.Duration // How long the provider asked to wait next; 0 when it did not say
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.RetryAfter <= 0 {
+		return fmt.Sprintf("%s rate limited, retry in a few minutes", e.Provider)
+	}
+	return fmt.Sprintf("%s rate limited, retry after %s", e.Provider, e.RetryAfter.Round(time.Second))
+}
+
+// HTTPClient returns the HTTP client provider SDKs are built on: requests are
+// traced with --debug and retried when the provider rate limits them
+func HTTPClient(provider string) *http.Client {
+	return &http.Client{Transport: NewRateLimitTransport(provider, debuglog.NewTransport(provider, nil))}
+}
+
+// RateLimitTransport retries requests a provider answers with 429 Too Many
+// Requests, honoring Retry-After and otherwise backing off exponentially with
+// jitter. A rate limited request pauses every request to the same provider.
+type RateLimitTransport struct {
+	Provider string
+	Base     http.RoundTripper
+	Budget   time.Duration
+
+	limiter *providerLimiter
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+	jitter  func(d time.Duration) time.Duration
+}
+
+// NewRateLimitTransport wraps base (http.DefaultTransport when nil) for the named provider
+func NewRateLimitTransport(provider string, base http.RoundTripper) *RateLimitTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitTransport{
+		Provider: provider,
+		Base:     base,
+		Budget:   RateLimitBudget,
+		limiter:  limiterFor(provider),
+		now:      time.Now,
+		sleep:    sleepContext,
+		jitter:   equalJitter,
+	}
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	waited := time.Duration(0)
+
+	for attempt := 0; ; attempt++ {
+		if pause := t.limiter.pause(t.now()); pause > 0 {
+			if waited+pause > t.Budget {
+				return nil, &RateLimitedError{Provider: t.Provider, RetryAfter: pause}
+			}
+			if err := t.sleep(ctx, pause); err != nil {
+				return nil, err
+			}
+			waited += pause
+		}
+
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.limiter.acquire(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := t.Base.RoundTrip(attemptReq)
+		t.limiter.release()
+		if err != nil || !isRateLimited(resp) {
+			return resp, err
+		}
+
+		wait := retryAfter(resp.Header, t.now())
+		if wait <= 0 {
+			wait = t.jitter(backoffDelay(attempt))
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		if !replayable(req) || waited+wait > t.Budget {
+			return nil, &RateLimitedError{Provider: t.Provider, RetryAfter: wait}
+		}
+		t.limiter.pauseUntil(t.now().Add(wait))
+	}
+}
+
+// isRateLimited reports whether the provider turned the request away for
+// rate limiting rather than failing it
+func isRateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "")
+}
+
+// retryAfter reads how long the provider asked to wait: Retry-After in
+// seconds or as a date, or a RateLimit-Reset Unix timestamp (Hetzner,
+// DigitalOcean). It returns 0 when neither is set.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return max(at.Sub(now), 0)
+		}
+	}
+	if value := header.Get("RateLimit-Reset"); value != "" {
+		if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return max(time.Unix(unix, 0).Sub(now), 0)
+		}
+	}
+	return 0
+}
+
+// backoffDelay doubles from rateLimitBaseDelay up to rateLimitMaxDelay
+func backoffDelay(attempt int) time.Duration {
+	delay := rateLimitBaseDelay
+	for i := 0; i < attempt && delay < rateLimitMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, rateLimitMaxDelay)
+}
+
+// equalJitter picks a delay between d/2 and d so parallel callers that were
+// limited together do not retry together
+func equalJitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// replayable reports whether the request body can be sent again
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns the request for the given attempt, with a fresh body
+// for retries
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// providerLimiter is shared by all requests to one provider: it caps the
+// requests in flight and holds back new ones while the provider rate limits
+type providerLimiter struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*providerLimiter{}
+)
+
+func limiterFor(provider string) *providerLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiter, ok := limiters[provider]
+	if !ok {
+		limiter = &providerLimiter{slots: make(chan struct{}, MaxConcurrentRequests)}
+		limiters[provider] = limiter
+	}
+	return limiter
+}
+
+func (l *providerLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *providerLimiter) release() {
+	<-l.slots
+}
+
+// pause returns how long requests have to hold back from now
+func (l *providerLimiter) pause(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(l.resumeAt.Sub(now), 0)
+}
+
+// pauseUntil holds back requests until at, unless they already are held longer
+func (l *providerLimiter) pauseUntil(at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at.After(l.resumeAt) {
+		l.resumeAt = at
+	}
+}
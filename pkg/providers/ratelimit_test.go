@@ -0,0 +1,264 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRateLimitTransport returns a transport with its own limiter and a
+// fake clock that advances only when the transport sleeps
+func newTestRateLimitTransport(base http.RoundTripper) (*RateLimitTransport, *[]time.Duration) {
+	now := time.Unix(1700000000, 0)
+	var sleeps []time.Duration
+
+	t := NewRateLimitTransport("hetzner", base)
+	t.limiter = &providerLimiter{slots: make(chan struct{}, MaxConcurrentRequests)}
+	t.now = func() time.Time { return now }
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	t.jitter = func(d time.Duration) time.Duration { return d }
+	return t, &sleeps
+}
+
+// statusSequence serves the given responses in order, then 200 OK
+func statusSequence(t *testing.T, responses ...func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt32(&calls, 1) - 1
+		if int(i) < len(responses) {
+			responses[i](w)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func tooManyRequests(retryAfter string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error":{"code":"rate_limit_exceeded"}}`)
+	}
+}
+
+func TestRateLimitTransport_RetriesHonoringRetryAfter(t *testing.T) {
+	server, calls := statusSequence(t, tooManyRequests("3"), tooManyRequests(""), tooManyRequests(""))
+	transport, sleeps := newTestRateLimitTransport(nil)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("response = %d %q, want 200 ok", resp.StatusCode, body)
+	}
+	if *calls != 4 {
+		t.Errorf("server saw %d requests, want 4", *calls)
+	}
+	// Retry-After first, then exponential backoff from the attempt number
+	want := []time.Duration{3 * time.Second, 2 * time.Second, 4 * time.Second}
+	if len(*sleeps) != len(want) {
+		t.Fatalf("sleeps = %v, want %v", *sleeps, want)
+	}
+	for i := range want {
+		if (*sleeps)[i] != want[i] {
+			t.Errorf("sleeps = %v, want %v", *sleeps, want)
+			break
+		}
+	}
+}
+
+func TestRateLimitTransport_BudgetExhausted(t *testing.T) {
+	always := make([]func(w http.ResponseWriter), 10)
+	for i := range always {
+		always[i] = tooManyRequests("50")
+	}
+	server, calls := statusSequence(t, always...)
+	transport, sleeps := newTestRateLimitTransport(nil)
+
+	_, err := (&http.Client{Transport: transport}).Get(server.URL)
+
+	var rateLimited *RateLimitedError
+	if !errors.As(err, &rateLimited) {
+		t.Fatalf("Get() = %v, want RateLimitedError", err)
+	}
+	if rateLimited.RetryAfter != 50*time.Second {
+		t.Errorf("RetryAfter = %v, want 50s", rateLimited.RetryAfter)
+	}
+	if !strings.Contains(err.Error(), "hetzner rate limited, retry after 50s") {
+		t.Errorf("error = %q", err)
+	}
+	// Two 50s waits fit the 2m budget, a third does not
+	if *calls != 3 || len(*sleeps) != 2 {
+		t.Errorf("server saw %d requests after sleeps %v, want 3 requests and 2 sleeps", *calls, *sleeps)
+	}
+}
+
+func TestRateLimitTransport_ReplaysRequestBody(t *testing.T) {
+	var bodies []string
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			tooManyRequests("1")(w)
+		}
+	}))
+	defer server.Close()
+	transport, _ := newTestRateLimitTransport(nil)
+
+	resp, err := (&http.Client{Transport: transport}).Post(server.URL, "application/json", strings.NewReader(`{"name":"web"}`))
+	if err != nil {
+		t.Fatalf("Post() = %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != bodies[1] || bodies[1] != `{"name":"web"}` {
+		t.Errorf("bodies = %q, want the same body twice", bodies)
+	}
+}
+
+func TestRateLimitTransport_PassesThroughOtherErrors(t *testing.T) {
+	server, calls := statusSequence(t, func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) })
+	transport, sleeps := newTestRateLimitTransport(nil)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable || *calls != 1 || len(*sleeps) != 0 {
+		t.Errorf("got %d after %d requests and sleeps %v, want the 503 without retrying", resp.StatusCode, *calls, *sleeps)
+	}
+}
+
+func TestRateLimitTransport_SharedPause(t *testing.T) {
+	server, calls := statusSequence(t, tooManyRequests("10"), tooManyRequests("10"))
+	transport, sleeps := newTestRateLimitTransport(nil)
+	// Another client of the same provider was just told to wait
+	transport.limiter.pauseUntil(transport.now().Add(5 * time.Second))
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+
+	if (*sleeps)[0] != 5*time.Second {
+		t.Errorf("first sleep = %v, want the shared 5s pause before the first request", (*sleeps)[0])
+	}
+	if *calls != 3 {
+		t.Errorf("server saw %d requests, want 3", *calls)
+	}
+}
+
+// countingTransport records the most requests it had in flight at once
+type countingTransport struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.peak = max(c.peak, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestRateLimitTransport_LimitsConcurrency(t *testing.T) {
+	base := &countingTransport{}
+	transport, _ := newTestRateLimitTransport(base)
+	client := &http.Client{Transport: transport}
+
+	var wg sync.WaitGroup
+	for range 3 * MaxConcurrentRequests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := client.Get("http://provider.invalid/v1/servers"); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if base.peak > MaxConcurrentRequests {
+		t.Errorf("peak in-flight requests = %d, want at most %d", base.peak, MaxConcurrentRequests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"http date", http.Header{"Retry-After": {now.Add(90 * time.Second).UTC().Format(http.TimeFormat)}}, 90 * time.Second},
+		{"ratelimit reset", http.Header{"Ratelimit-Reset": {"1700000012"}}, 12 * time.Second},
+		{"reset in the past", http.Header{"Ratelimit-Reset": {"1699999990"}}, 0},
+		{"none", http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	want := []time.Duration{1, 2, 4, 8, 16, 30, 30}
+	for attempt, seconds := range want {
+		if got := backoffDelay(attempt); got != seconds*time.Second {
+			t.Errorf("backoffDelay(%d) = %v, want %v", attempt, got, seconds*time.Second)
+		}
+	}
+}
+
+func TestProviderErrorRateLimited(t *testing.T) {
+	err := error(&ProviderError{
+		Provider: "hetzner",
+		Code:     "list_servers_failed",
+		Message:  "Failed to list Hetzner Cloud servers",
+		Details:  map[string]interface{}{"error": "Get \"https://api.hetzner.cloud/v1/servers\": hetzner rate limited, retry after 45s"},
+		Err:      &RateLimitedError{Provider: "hetzner", RetryAfter: 45 * time.Second},
+	})
+
+	var rateLimited *RateLimitedError
+	if !errors.As(err, &rateLimited) {
+		t.Fatal("errors.As() did not find the RateLimitedError")
+	}
+	if got, want := err.Error(), "Failed to list Hetzner Cloud servers: hetzner rate limited, retry after 45s"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
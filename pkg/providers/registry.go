@@ -2,16 +2,25 @@ package providers
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 )
 
+// ExternalPrefix marks provider names served by an external plugin binary,
+// e.g. "exec:mycloud" runs lightfold-provider-mycloud
+const ExternalPrefix = "exec:"
+
 // ProviderFactory creates a new provider instance with the given API token
 type ProviderFactory func(token string) Provider
 
+// ExternalProviderFactory creates a provider for an "exec:" name
+type ExternalProviderFactory func(name, token string) Provider
+
 // Registry manages registered cloud providers
 type Registry struct {
 	mu        sync.RWMutex
 	providers map[string]ProviderFactory
+	external  ExternalProviderFactory
 }
 
 var globalRegistry = &Registry{
@@ -26,11 +35,27 @@ func Register(name string, factory ProviderFactory) {
 	globalRegistry.providers[name] = factory
 }
 
+// RegisterExternal sets the factory that serves "exec:" provider names
+func RegisterExternal(factory ExternalProviderFactory) {
+	globalRegistry.mu.Lock()
+	defer globalRegistry.mu.Unlock()
+	globalRegistry.external = factory
+}
+
+// IsExternal reports whether a provider name refers to an external plugin
+func IsExternal(name string) bool {
+	return strings.HasPrefix(name, ExternalPrefix) && len(name) > len(ExternalPrefix)
+}
+
 // GetProvider creates a provider instance by name with the given token
 func GetProvider(name, token string) (Provider, error) {
 	globalRegistry.mu.RLock()
 	defer globalRegistry.mu.RUnlock()
 
+	if IsExternal(name) && globalRegistry.external != nil {
+		return globalRegistry.external(name, token), nil
+	}
+
 	factory, exists := globalRegistry.providers[name]
 	if !exists {
 		return nil, fmt.Errorf("unknown provider: %s", name)
@@ -55,6 +80,9 @@ func ListProviders() []string {
 func IsRegistered(name string) bool {
 	globalRegistry.mu.RLock()
 	defer globalRegistry.mu.RUnlock()
+	if IsExternal(name) && globalRegistry.external != nil {
+		return true
+	}
 	_, exists := globalRegistry.providers[name]
 	return exists
 }
@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ValidateSizeForRegion checks that the requested size is offered in the given region.
+// Validation is skipped when either value is empty or the provider cannot list sizes,
+// leaving the final word to the provider's create call.
+func ValidateSizeForRegion(ctx context.Context, p Provider, region, size string) error {
+	if region == "" || size == "" {
+		return nil
+	}
+
+	sizes, err := p.GetSizes(ctx, region)
+	if err != nil || len(sizes) == 0 {
+		return nil
+	}
+
+	validSizes := make([]string, 0, len(sizes))
+	for _, s := range sizes {
+		if s.ID == size {
+			return nil
+		}
+		validSizes = append(validSizes, s.ID)
+	}
+
+	return &ProviderError{
+		Provider: p.Name(),
+		Code:     "invalid_size_for_region",
+		Message: fmt.Sprintf("size %q is not available in region %q. Valid sizes: %s",
+			size, region, strings.Join(validSizes, ", ")),
+		Details: map[string]interface{}{
+			"region":      region,
+			"size":        size,
+			"valid_sizes": validSizes,
+		},
+	}
+}
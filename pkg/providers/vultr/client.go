@@ -27,7 +27,7 @@ type Client struct {
 
 func NewClient(token string) *Client {
 	config := &oauth2.Config{}
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, providers.HTTPClient("vultr"))
 	ts := config.TokenSource(ctx, &oauth2.Token{AccessToken: token})
 	httpClient := oauth2.NewClient(ctx, ts)
 
@@ -67,12 +67,22 @@ func (c *Client) ValidateCredentials(ctx context.Context) error {
 			Code:     "invalid_credentials",
 			Message:  "Invalid Vultr API token",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 		return providerErr
 	}
 	return nil
 }
 
+// AccountInfo returns the account email
+func (c *Client) AccountInfo(ctx context.Context) (string, error) {
+	account, _, err := c.client.Account.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return account.Email, nil
+}
+
 func (c *Client) GetRegions(ctx context.Context) ([]providers.Region, error) {
 	vultrRegions, _, _, err := c.client.Region.List(ctx, nil)
 	if err != nil {
@@ -106,6 +116,9 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 	for _, plan := range vultrPlans {
 		// Filter minimum 512MB RAM (same as DO/Hetzner)
 		if plan.RAM >= 512 {
+			if region != "" && len(plan.Locations) > 0 && !containsString(plan.Locations, region) {
+				continue
+			}
 			sizes = append(sizes, providers.Size{
 				ID:           plan.ID,
 				Name:         fmt.Sprintf("%s (%d MB RAM, %d vCPUs, %d GB disk)", plan.ID, plan.RAM, plan.VCPUCount, plan.Disk),
@@ -114,6 +127,7 @@ func (c *Client) GetSizes(ctx context.Context, region string) ([]providers.Size,
 				Disk:         plan.Disk,
 				PriceMonthly: float64(plan.MonthlyCost),
 				PriceHourly:  0, // Vultr doesn't provide hourly in plan list
+				Architecture: providers.ArchX86_64,
 			})
 		}
 	}
@@ -185,6 +199,7 @@ func (c *Client) UploadSSHKey(ctx context.Context, name, publicKey string) (*pro
 			Code:     "upload_ssh_key_failed",
 			Message:  fmt.Sprintf("Failed to upload SSH key to Vultr: %v", err),
 			Details:  map[string]interface{}{"error": err.Error(), "name": name},
+			Err:      err,
 		}
 	}
 
@@ -204,7 +219,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		Label:    config.Name,
 		SSHKeys:  config.SSHKeys,
 		UserData: config.UserData,
-		Tags:     config.Tags,
+		Tags:     providers.WithLabelTags(config.Tags, labelTags(config.Labels)),
 		Backups:  "disabled",
 	}
 
@@ -212,6 +227,14 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 		instanceReq.Backups = "enabled"
 	}
 
+	switch config.IPStack {
+	case providers.IPStackDual:
+		instanceReq.EnableIPv6 = govultr.BoolToBoolPtr(true)
+	case providers.IPStackIPv6Only:
+		instanceReq.EnableIPv6 = govultr.BoolToBoolPtr(true)
+		instanceReq.DisablePublicIPv4 = govultr.BoolToBoolPtr(true)
+	}
+
 	// Convert image ID string to int
 	osID, err := strconv.Atoi(config.Image)
 	if err != nil {
@@ -220,6 +243,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "invalid_image",
 			Message:  fmt.Sprintf("Invalid image ID: %s", config.Image),
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 	instanceReq.OsID = osID
@@ -231,6 +255,7 @@ func (c *Client) Provision(ctx context.Context, config providers.ProvisionConfig
 			Code:     "create_instance_failed",
 			Message:  "Failed to create Vultr instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
@@ -245,12 +270,33 @@ func (c *Client) GetServer(ctx context.Context, serverID string) (*providers.Ser
 			Code:     "get_instance_failed",
 			Message:  "Failed to get Vultr instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
 
 	return convertInstanceToServer(instance), nil
 }
 
+func (c *Client) ListServers(ctx context.Context) ([]providers.Server, error) {
+	instances, _, _, err := c.client.Instance.List(ctx, &govultr.ListOptions{PerPage: 500})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "list_instances_failed",
+			Message:  "Failed to list Vultr instances",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	servers := make([]providers.Server, 0, len(instances))
+	for i := range instances {
+		servers = append(servers, *convertInstanceToServer(&instances[i]))
+	}
+
+	return servers, nil
+}
+
 func (c *Client) Destroy(ctx context.Context, serverID string) error {
 	err := c.client.Instance.Delete(ctx, serverID)
 	if err != nil {
@@ -259,9 +305,37 @@ func (c *Client) Destroy(ctx context.Context, serverID string) error {
 			Code:     "destroy_instance_failed",
 			Message:  "Failed to destroy Vultr instance",
 			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	return nil
+}
+
+// PowerOff halts the instance. Vultr keeps billing halted instances.
+func (c *Client) PowerOff(ctx context.Context, serverID string) error {
+	if err := c.client.Instance.Halt(ctx, serverID); err != nil {
+		return &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "power_off_failed",
+			Message:  "Failed to halt Vultr instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
 		}
 	}
+	return nil
+}
 
+func (c *Client) PowerOn(ctx context.Context, serverID string) error {
+	if err := c.client.Instance.Start(ctx, serverID); err != nil {
+		return &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "power_on_failed",
+			Message:  "Failed to start Vultr instance",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
 	return nil
 }
 
@@ -276,6 +350,7 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 				Code:     "poll_instance_failed",
 				Message:  "Failed to poll Vultr instance status",
 				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
 			}
 		}
 
@@ -302,7 +377,8 @@ func (c *Client) WaitForActive(ctx context.Context, serverID string, timeout tim
 func convertInstanceToServer(instance *govultr.Instance) *providers.Server {
 	var publicIPv4, privateIPv4 string
 
-	if instance.MainIP != "" {
+	// Vultr reports 0.0.0.0 while no public IPv4 is assigned (IPv6-only instances)
+	if instance.MainIP != "" && instance.MainIP != "0.0.0.0" {
 		publicIPv4 = instance.MainIP
 	}
 
@@ -332,6 +408,7 @@ func convertInstanceToServer(instance *govultr.Instance) *providers.Server {
 		Name:        instance.Label,
 		Status:      instance.Status,
 		PublicIPv4:  publicIPv4,
+		PublicIPv6:  instance.V6MainIP,
 		PrivateIPv4: privateIPv4,
 		Region:      instance.Region,
 		Size:        instance.Plan,
@@ -404,3 +481,12 @@ func GetStaticPlans() []providers.Size {
 func GetStaticImages() []providers.Image {
 	return getStaticImages()
 }
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
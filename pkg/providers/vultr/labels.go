@@ -0,0 +1,40 @@
+package vultr
+
+import (
+	"context"
+	"lightfold/pkg/providers"
+	"strings"
+
+	"github.com/vultr/govultr/v3"
+)
+
+// labelTags renders labels as key:value tags. Vultr tags are free text, so
+// only surrounding whitespace is removed.
+func labelTags(labels map[string]string) []string {
+	return providers.FlatLabels(labels, ":", strings.TrimSpace)
+}
+
+// SetLabels replaces the previous label tags of the instance with labels,
+// keeping tags set outside lightfold
+func (c *Client) SetLabels(ctx context.Context, serverID string, labels, previous map[string]string) error {
+	instance, _, err := c.client.Instance.Get(ctx, serverID)
+	if err != nil {
+		return retagError(err)
+	}
+
+	tags := providers.RetagList(instance.Tags, labelTags(previous), labelTags(labels))
+	if _, _, err := c.client.Instance.Update(ctx, serverID, &govultr.InstanceUpdateReq{Tags: tags}); err != nil {
+		return retagError(err)
+	}
+	return nil
+}
+
+func retagError(err error) error {
+	return &providers.ProviderError{
+		Provider: "vultr",
+		Code:     "retag_failed",
+		Message:  "Failed to update Vultr instance tags",
+		Details:  map[string]interface{}{"error": err.Error()},
+		Err:      err,
+	}
+}
@@ -0,0 +1,15 @@
+package vultr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelTags(t *testing.T) {
+	// Vultr tags are free text: characters DigitalOcean rejects are kept
+	got := labelTags(map[string]string{"team": "payments", "cost.center": "R&D 42", "owner": " ops "})
+	want := []string{"cost.center:R&D 42", "owner: ops", "team:payments"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labelTags() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package vultr
+
+import (
+	"context"
+	"lightfold/pkg/providers"
+	"time"
+
+	"github.com/vultr/govultr/v3"
+)
+
+// snapshotPollInterval is how often snapshot and restore status is polled
+const snapshotPollInterval = 5 * time.Second
+
+func (c *Client) Snapshot(ctx context.Context, serverID, name string, progress func(percent int)) (*providers.Snapshot, error) {
+	snapshot, _, err := c.client.Snapshot.Create(ctx, &govultr.SnapshotReq{
+		InstanceID:  serverID,
+		Description: name,
+	})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "create_snapshot_failed",
+			Message:  "Failed to create Vultr snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	for snapshot.Status != "complete" {
+		if progress != nil {
+			progress(-1)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(snapshotPollInterval):
+		}
+
+		snapshot, _, err = c.client.Snapshot.Get(ctx, snapshot.ID)
+		if err != nil {
+			return nil, &providers.ProviderError{
+				Provider: "vultr",
+				Code:     "poll_snapshot_failed",
+				Message:  "Failed to poll Vultr snapshot status",
+				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
+			}
+		}
+	}
+
+	converted := convertSnapshot(snapshot)
+	converted.ServerID = serverID
+	return &converted, nil
+}
+
+// ListSnapshots returns every snapshot on the account. Vultr does not record
+// which instance a snapshot was taken from, so serverID cannot filter them.
+func (c *Client) ListSnapshots(ctx context.Context, serverID string) ([]providers.Snapshot, error) {
+	snapshots, _, _, err := c.client.Snapshot.List(ctx, &govultr.ListOptions{PerPage: 500})
+	if err != nil {
+		return nil, &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "list_snapshots_failed",
+			Message:  "Failed to list Vultr snapshots",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	result := make([]providers.Snapshot, 0, len(snapshots))
+	for i := range snapshots {
+		result = append(result, convertSnapshot(&snapshots[i]))
+	}
+	return result, nil
+}
+
+// RestoreSnapshot restores the instance and waits for Vultr to report it
+// reinstalling and then ready again
+func (c *Client) RestoreSnapshot(ctx context.Context, serverID, snapshotID string) error {
+	if _, err := c.client.Instance.Restore(ctx, serverID, &govultr.RestoreReq{SnapshotID: snapshotID}); err != nil {
+		return &providers.ProviderError{
+			Provider: "vultr",
+			Code:     "restore_snapshot_failed",
+			Message:  "Failed to restore Vultr instance from snapshot",
+			Details:  map[string]interface{}{"error": err.Error()},
+			Err:      err,
+		}
+	}
+
+	// The restore is queued, so the instance can still report "ok" for a
+	// short while before it starts reinstalling
+	started := false
+	startDeadline := time.Now().Add(time.Minute)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(snapshotPollInterval):
+		}
+
+		instance, _, err := c.client.Instance.Get(ctx, serverID)
+		if err != nil {
+			return &providers.ProviderError{
+				Provider: "vultr",
+				Code:     "poll_instance_failed",
+				Message:  "Failed to poll Vultr instance status",
+				Details:  map[string]interface{}{"error": err.Error()},
+				Err:      err,
+			}
+		}
+
+		if instance.ServerStatus != "ok" {
+			started = true
+			continue
+		}
+		if started || time.Now().After(startDeadline) {
+			return nil
+		}
+	}
+}
+
+func convertSnapshot(snapshot *govultr.Snapshot) providers.Snapshot {
+	createdAt, _ := time.Parse(time.RFC3339, snapshot.DateCreated)
+	return providers.Snapshot{
+		ID:        snapshot.ID,
+		Name:      snapshot.Description,
+		Status:    snapshot.Status,
+		SizeGB:    float64(snapshot.Size) / (1 << 30),
+		CreatedAt: createdAt,
+	}
+}
@@ -148,8 +148,8 @@ func (m *Manager) Configure(config proxy.ProxyConfig) error {
 		return fmt.Errorf("failed to create Caddy config directory: %w", result.Error)
 	}
 
-	// Move temp file to final location with sudo
-	moveCmd := fmt.Sprintf("mv %s %s", tmpFile, configPath)
+	// Move temp file into place atomically with sudo
+	moveCmd := ssh.InstallFileCommand(ssh.RemoteFile{Path: configPath, Mode: 0644, Owner: "root:root", Backup: true}, tmpFile)
 	result = m.executor.ExecuteSudo(moveCmd)
 	if result.Error != nil {
 		return fmt.Errorf("failed to write Caddy config: %w", result.Error)
@@ -213,7 +213,7 @@ func (m *Manager) ConfigureMultiApp(configs []proxy.ProxyConfig) error {
 			return fmt.Errorf("failed to write temp config for %s: %w", config.AppName, result.Error)
 		}
 
-		moveCmd := fmt.Sprintf("mv %s %s", tmpFile, configPath)
+		moveCmd := ssh.InstallFileCommand(ssh.RemoteFile{Path: configPath, Mode: 0644, Owner: "root:root", Backup: true}, tmpFile)
 		result = m.executor.ExecuteSudo(moveCmd)
 		if result.Error != nil {
 			return fmt.Errorf("failed to write Caddy config for %s: %w", config.AppName, result.Error)
@@ -0,0 +1,81 @@
+package nginx
+
+import (
+	"fmt"
+	"lightfold/pkg/proxy"
+	"lightfold/pkg/ssh"
+	"strconv"
+	"strings"
+)
+
+// BuildInfo is what nginx -V reports about the nginx installed on a server
+type BuildInfo struct {
+	Version string // e.g. 1.24.0, "" when nginx -V printed no version
+	HTTP2   bool   // Built with the http_v2_module
+	HTTP3   bool   // Built with the http_v3_module of nginx 1.25 or later
+}
+
+// ParseBuildInfo reads the output of nginx -V. HTTP/3 needs the version as
+// well as the module: builds before 1.25 carrying --with-http_v3_module are
+// third-party QUIC patches that take different directives.
+func ParseBuildInfo(output string) BuildInfo {
+	var info BuildInfo
+	var args string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "nginx version:"); ok {
+			if _, version, found := strings.Cut(strings.TrimSpace(rest), "/"); found {
+				info.Version, _, _ = strings.Cut(version, " ")
+			}
+		}
+		if rest, ok := strings.CutPrefix(line, "configure arguments:"); ok {
+			args = " " + rest + " "
+		}
+	}
+	info.HTTP2 = strings.Contains(args, " --with-http_v2_module ")
+	info.HTTP3 = strings.Contains(args, " --with-http_v3_module ") && versionAtLeast(info.Version, 1, 25)
+	return info
+}
+
+// versionAtLeast reports whether a dotted version is major.minor or later
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	gotMajor, err1 := strconv.Atoi(parts[0])
+	gotMinor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
+
+// CheckProtocols explains why this nginx cannot serve the protocols the
+// config of targetName asks for, or returns nil. An unknown build is not held
+// against the config; nginx -t still checks it before the site is enabled.
+func (b BuildInfo) CheckProtocols(config proxy.ProxyConfig, targetName string) error {
+	if b.Version == "" {
+		return nil
+	}
+	if config.HTTP3 && !b.HTTP3 {
+		return fmt.Errorf("nginx %s on the server cannot serve HTTP/3: it needs nginx 1.25 or later built with --with-http_v3_module (the nginx.org mainline packages are). Install one, or turn HTTP/3 off with 'lightfold config set --target %s domain.http3=false'", b.Version, targetName)
+	}
+	if !config.DisableHTTP2 && !b.HTTP2 {
+		return fmt.Errorf("nginx %s on the server was built without --with-http_v2_module, so it cannot serve HTTP/2. Turn HTTP/2 off with 'lightfold config set --target %s domain.http2=false'", b.Version, targetName)
+	}
+	return nil
+}
+
+// DetectBuild runs nginx -V on the server
+func DetectBuild(runner ssh.CommandRunner) (BuildInfo, error) {
+	// nginx -V prints to stderr
+	result := runner.Execute("nginx -V 2>&1")
+	if result.Error != nil {
+		return BuildInfo{}, result.Error
+	}
+	if result.ExitCode != 0 {
+		return BuildInfo{}, fmt.Errorf("nginx -V failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(result.Stdout+result.Stderr))
+	}
+	return ParseBuildInfo(result.Stdout), nil
+}
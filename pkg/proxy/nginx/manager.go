@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"lightfold/pkg/proxy"
 	"lightfold/pkg/ssh"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -76,13 +78,7 @@ func (m *Manager) Configure(config proxy.ProxyConfig) error {
 		return fmt.Errorf("nginx is not installed on the server")
 	}
 
-	// Generate nginx configuration
-	var nginxConfig string
-	if config.SSLEnabled && config.Domain != "" {
-		nginxConfig = m.generateSSLConfig(config)
-	} else {
-		nginxConfig = m.generateHTTPConfig(config)
-	}
+	nginxConfig := m.SiteConfig(config)
 
 	// Write configuration to file
 	configPath := m.GetConfigPath(config.AppName)
@@ -102,8 +98,8 @@ func (m *Manager) Configure(config proxy.ProxyConfig) error {
 		return fmt.Errorf("failed to write temp config (exit code %d): %s", result.ExitCode, result.Stderr)
 	}
 
-	// Move temp file to final location with sudo
-	moveCmd := fmt.Sprintf("mv %s %s", tmpFile, configPath)
+	// Move temp file into place atomically with sudo
+	moveCmd := ssh.InstallFileCommand(ssh.RemoteFile{Path: configPath, Mode: 0644, Owner: "root:root", Backup: true}, tmpFile)
 	result = m.executor.ExecuteSudo(moveCmd)
 	if result.Error != nil {
 		return fmt.Errorf("failed to write nginx config: %w", result.Error)
@@ -113,11 +109,7 @@ func (m *Manager) Configure(config proxy.ProxyConfig) error {
 	}
 
 	// Create symlink to enable the site
-	symlinkCmd := fmt.Sprintf(
-		"ln -sf %s /etc/nginx/sites-enabled/%s.conf",
-		configPath,
-		config.AppName,
-	)
+	symlinkCmd := fmt.Sprintf("ln -sf %s %s", configPath, m.GetEnabledPath(config.AppName))
 	result = m.executor.ExecuteSudo(symlinkCmd)
 	if result.Error != nil {
 		return fmt.Errorf("failed to enable nginx site: %w", result.Error)
@@ -178,13 +170,7 @@ func (m *Manager) ConfigureMultiApp(configs []proxy.ProxyConfig) error {
 			return fmt.Errorf("port cannot be zero for app %s", config.AppName)
 		}
 
-		// Generate nginx configuration
-		var nginxConfig string
-		if config.SSLEnabled && config.Domain != "" {
-			nginxConfig = m.generateSSLConfig(config)
-		} else {
-			nginxConfig = m.generateHTTPConfig(config)
-		}
+		nginxConfig := m.SiteConfig(config)
 
 		// Write configuration to file
 		configPath := m.GetConfigPath(config.AppName)
@@ -201,8 +187,8 @@ func (m *Manager) ConfigureMultiApp(configs []proxy.ProxyConfig) error {
 			return fmt.Errorf("failed to write temp config for %s (exit code %d): %s", config.AppName, result.ExitCode, result.Stderr)
 		}
 
-		// Move temp file to final location with sudo
-		moveCmd := fmt.Sprintf("mv %s %s", tmpFile, configPath)
+		// Move temp file into place atomically with sudo
+		moveCmd := ssh.InstallFileCommand(ssh.RemoteFile{Path: configPath, Mode: 0644, Owner: "root:root", Backup: true}, tmpFile)
 		result = m.executor.ExecuteSudo(moveCmd)
 		if result.Error != nil {
 			return fmt.Errorf("failed to write nginx config for %s: %w", config.AppName, result.Error)
@@ -212,11 +198,7 @@ func (m *Manager) ConfigureMultiApp(configs []proxy.ProxyConfig) error {
 		}
 
 		// Create symlink to enable the site
-		symlinkCmd := fmt.Sprintf(
-			"ln -sf %s /etc/nginx/sites-enabled/%s.conf",
-			configPath,
-			config.AppName,
-		)
+		symlinkCmd := fmt.Sprintf("ln -sf %s %s", configPath, m.GetEnabledPath(config.AppName))
 		result = m.executor.ExecuteSudo(symlinkCmd)
 		if result.Error != nil {
 			return fmt.Errorf("failed to enable nginx site for %s: %w", config.AppName, result.Error)
@@ -243,7 +225,7 @@ func (m *Manager) Remove(appName string) error {
 	}
 
 	// Remove symlink from sites-enabled
-	result := m.executor.ExecuteSudo(fmt.Sprintf("rm -f /etc/nginx/sites-enabled/%s.conf", appName))
+	result := m.executor.ExecuteSudo(fmt.Sprintf("rm -f %s", m.GetEnabledPath(appName)))
 	if result.Error != nil {
 		return fmt.Errorf("failed to remove nginx site link: %w", result.Error)
 	}
@@ -264,6 +246,267 @@ func (m *Manager) GetConfigPath(appName string) string {
 	return fmt.Sprintf("/etc/nginx/sites-available/%s.conf", appName)
 }
 
+// GetEnabledPath returns the path of the symlink that enables the site
+func (m *Manager) GetEnabledPath(appName string) string {
+	return fmt.Sprintf("/etc/nginx/sites-enabled/%s.conf", appName)
+}
+
+// GenerateConfig renders the nginx server configuration for an application,
+// using the HTTPS variant when SSL is enabled for a domain
+func (m *Manager) GenerateConfig(config proxy.ProxyConfig) string {
+	if config.SSLEnabled && config.Domain != "" {
+		return m.generateSSLConfig(config)
+	}
+	return m.generateHTTPConfig(config)
+}
+
+// quicAddresses are the addresses an HTTP/3 site listens for QUIC on
+var quicAddresses = []string{"443", "[::]:443"}
+
+// quicReuseportGrep prints the QUIC listen directives carrying reuseport in
+// every enabled site, each prefixed with its file
+const quicReuseportGrep = `sh -c 'grep -RsHE "^[[:space:]]*listen[^;]*(quic[^;]*reuseport|reuseport[^;]*quic)" /etc/nginx/sites-enabled /etc/nginx/conf.d; true'`
+
+// SiteConfig renders the config Configure installs for an app. An HTTP/3
+// site's QUIC listeners get reuseport on each address no other enabled site
+// holds it on: nginx needs it once per address to hand QUIC packets to the
+// worker owning the connection, and refuses it twice.
+func (m *Manager) SiteConfig(config proxy.ProxyConfig) string {
+	if config.SSLEnabled && config.HTTP3 && m.executor != nil {
+		result := m.executor.Execute(quicReuseportGrep)
+		// Without the other sites, reuseport could be a second one
+		config.QUICReuseport = nil
+		if result.Error == nil && result.ExitCode == 0 {
+			held := ReuseportHolders(result.Stdout)
+			for _, address := range quicAddresses {
+				if holder, ok := held[address]; !ok || holder == m.GetEnabledPath(config.AppName) {
+					config.QUICReuseport = append(config.QUICReuseport, address)
+				}
+			}
+		}
+	}
+	return m.GenerateConfig(config)
+}
+
+// ReuseportHolders reads grep -H output of QUIC listen directives into the
+// site holding reuseport for each address, with "*:443" and "0.0.0.0:443"
+// read as 443
+func ReuseportHolders(output string) map[string]string {
+	held := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		path, directive, found := strings.Cut(line, ":")
+		fields := strings.Fields(directive)
+		if !found || len(fields) < 2 || fields[0] != "listen" {
+			continue
+		}
+		address := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*:"), "0.0.0.0:")
+		if _, ok := held[address]; !ok {
+			held[address] = path
+		}
+	}
+	return held
+}
+
+// PathRouteLocations renders location blocks that proxy path prefixes to other
+// apps. The prefix is stripped before proxying so each app serves from its root.
+func PathRouteLocations(routes []proxy.PathRoute) string {
+	if len(routes) == 0 {
+		return ""
+	}
+
+	sorted := make([]proxy.PathRoute, len(routes))
+	copy(sorted, routes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PathPrefix < sorted[j].PathPrefix
+	})
+
+	var b strings.Builder
+	for _, route := range sorted {
+		prefix := strings.TrimSuffix(route.PathPrefix, "/")
+		fmt.Fprintf(&b, `  # %s
+  location = %s { return 301 %s/$is_args$args; }
+  location %s/ {
+    proxy_pass http://127.0.0.1:%d/;
+    proxy_set_header Host $host;
+    proxy_set_header X-Real-IP $remote_addr;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    proxy_set_header X-Forwarded-Proto $scheme;
+    proxy_set_header X-Forwarded-Prefix %s;
+  }
+
+`, route.AppName, prefix, prefix, prefix, route.Port, prefix)
+	}
+	return b.String()
+}
+
+// OutputDirLocations renders a location per build output directory of a static
+// site, each with its own index.html fallback and asset caching, followed by
+// a blank line
+func OutputDirLocations(dirs []proxy.StaticPath) string {
+	var b strings.Builder
+	for _, d := range dirs {
+		// ^~ keeps the site's asset regex from serving these URLs from the root
+		fmt.Fprintf(&b, `  location = %[1]s { return 301 %[1]s/; }
+  location ^~ %[1]s/ {
+    alias %[2]s/;
+    try_files $uri $uri/ %[1]s/index.html =404;
+
+    location ~* \.(js|css|png|jpg|jpeg|gif|ico|svg|woff|woff2|ttf|eot)$ {
+      expires 1y;
+      add_header Cache-Control "public, immutable";
+    }
+  }
+
+`, strings.TrimSuffix(d.URLPrefix, "/"), strings.TrimSuffix(d.Dir, "/"))
+	}
+	return b.String()
+}
+
+// StaticLocations renders alias location blocks serving each static path from
+// disk, followed by a blank line. It renders nothing when there are no paths.
+// With an app port, files missing on disk fall back to the app through an
+// @app location, so apps that serve their own static files (e.g. Django with
+// WhiteNoise, or before collectstatic ran) keep working.
+func StaticLocations(paths []proxy.StaticPath, port int) string {
+	if len(paths) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, path := range paths {
+		if len(path.URLPrefix) > width {
+			width = len(path.URLPrefix)
+		}
+	}
+
+	fallback := ""
+	if port > 0 {
+		fallback = " try_files $uri @app;"
+	}
+
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "  location %-*s { alias %s/;%s }\n", width, path.URLPrefix, strings.TrimSuffix(path.Dir, "/"), fallback)
+	}
+	if port > 0 {
+		fmt.Fprintf(&b, `  location @app {
+    proxy_pass http://127.0.0.1:%d;
+    proxy_set_header Host $host;
+    proxy_set_header X-Real-IP $remote_addr;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    proxy_set_header X-Forwarded-Proto $scheme;
+  }
+`, port)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func staticLocationsWithComment(paths []proxy.StaticPath, port int) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	return "  # Static files\n" + StaticLocations(paths, port)
+}
+
+// RedirectServer renders an HTTP server block answering from with a 301 to
+// the same URL on to, followed by a blank line. It renders nothing without
+// from.
+func RedirectServer(from, to string) string {
+	if from == "" || to == "" {
+		return ""
+	}
+	return fmt.Sprintf(`# Redirect %s to %s
+server {
+  listen 80;
+  listen [::]:80;
+  server_name %s;
+  return 301 $scheme://%s$request_uri;
+}
+
+`, from, to, from, to)
+}
+
+// redirectSSLServer renders the HTTPS server block answering the redirected
+// name with a 301 to the domain, using the domain's certificate
+func redirectSSLServer(config proxy.ProxyConfig) string {
+	if config.RedirectFrom == "" {
+		return ""
+	}
+	return fmt.Sprintf(`# HTTPS redirect - %s to %s
+server {
+%s  server_name %s;
+
+  ssl_certificate %s;
+  ssl_certificate_key %s;
+  ssl_protocols TLSv1.2 TLSv1.3;
+  return 301 https://%s$request_uri;
+}
+
+`, config.RedirectFrom, config.Domain, sslListen(config, false), config.RedirectFrom, config.SSLCertPath, config.SSLKeyPath, config.Domain)
+}
+
+// sslListen renders the listen directives of an HTTPS server block: TLS on
+// TCP 443 with HTTP/2 unless it is switched off, and with quic a QUIC listener
+// on UDP 443, with reuseport on the addresses in config.QUICReuseport
+func sslListen(config proxy.ProxyConfig, quic bool) string {
+	params := "ssl http2"
+	if config.DisableHTTP2 {
+		params = "ssl"
+	}
+	listen := fmt.Sprintf("  listen 443 %[1]s;\n  listen [::]:443 %[1]s;\n", params)
+	if quic {
+		for _, address := range quicAddresses {
+			reuseport := ""
+			if slices.Contains(config.QUICReuseport, address) {
+				reuseport = " reuseport"
+			}
+			listen += fmt.Sprintf("  listen %s quic%s;\n", address, reuseport)
+		}
+	}
+	return listen
+}
+
+// altSvcHeader tells browsers that connected over TCP that HTTP/3 is offered
+// on UDP 443
+func altSvcHeader(config proxy.ProxyConfig) string {
+	if !config.HTTP3 {
+		return ""
+	}
+	return "  add_header Alt-Svc 'h3=\":443\"; ma=86400' always;\n"
+}
+
+// CertificatePaths reads the ssl_certificate and ssl_certificate_key of a
+// site, such as the ones certbot adds to the server block it installs into
+func CertificatePaths(site string) (certPath, keyPath string) {
+	for _, line := range strings.Split(site, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		value := strings.TrimSuffix(fields[1], ";")
+		switch fields[0] {
+		case "ssl_certificate":
+			certPath = value
+		case "ssl_certificate_key":
+			keyPath = value
+		}
+	}
+	return certPath, keyPath
+}
+
+// ServerNames lists the names the server blocks of a site answer to
+func ServerNames(site string) []string {
+	var names []string
+	for _, line := range strings.Split(site, "\n") {
+		fields := strings.Fields(strings.ReplaceAll(line, ";", " "))
+		if len(fields) > 1 && fields[0] == "server_name" {
+			names = append(names, fields[1:]...)
+		}
+	}
+	return names
+}
+
 // generateHTTPConfig generates HTTP-only nginx configuration
 func (m *Manager) generateHTTPConfig(config proxy.ProxyConfig) string {
 	serverName := "_"
@@ -271,47 +514,98 @@ func (m *Manager) generateHTTPConfig(config proxy.ProxyConfig) string {
 		serverName = config.Domain
 	}
 
-	return fmt.Sprintf(`server {
+	return RedirectServer(config.RedirectFrom, config.Domain) + fmt.Sprintf(`server {
   listen 80;
+  listen [::]:80;
   server_name %s;
 
   access_log /var/log/nginx/%s_access.log;
   error_log  /var/log/nginx/%s_error.log;
 
-  location /static/ { alias /srv/%s/shared/static/; }
-  location /media/  { alias /srv/%s/shared/media/; }
+%s}
+`,
+		serverName,
+		config.AppName,
+		config.AppName,
+		siteLocations(config, false),
+	)
+}
+
+// siteLocations renders the locations of a site: the static site root and its
+// output directories or the proxy to the app, with the static paths and path
+// routes. ssl adds the X-Forwarded-Host header to the proxy.
+func siteLocations(config proxy.ProxyConfig, ssl bool) string {
+	if config.StaticRoot != "" {
+		return fmt.Sprintf(`  root %s;
+  index index.html;
 
   location / {
+    try_files $uri $uri/ /index.html =404;
+  }
+
+  location ~* \.(js|css|png|jpg|jpeg|gif|ico|svg|woff|woff2|ttf|eot)$ {
+    expires 1y;
+    add_header Cache-Control "public, immutable";
+  }
+
+%s%s%s`,
+			config.StaticRoot,
+			OutputDirLocations(config.OutputDirs),
+			StaticLocations(config.StaticPaths, 0),
+			PathRouteLocations(config.PathRoutes),
+		)
+	}
+
+	if !ssl {
+		return fmt.Sprintf(`%s%s  location / {
     proxy_pass http://127.0.0.1:%d;
     proxy_set_header Host $host;
     proxy_set_header X-Real-IP $remote_addr;
     proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
     proxy_set_header X-Forwarded-Proto $scheme;
   }
-}
 `,
-		serverName,
-		config.AppName,
-		config.AppName,
-		config.AppName,
-		config.AppName,
+			StaticLocations(config.StaticPaths, config.Port),
+			PathRouteLocations(config.PathRoutes),
+			config.Port,
+		)
+	}
+	return fmt.Sprintf(`%s%s  # Proxy to application
+  location / {
+    proxy_pass http://127.0.0.1:%d;
+    proxy_set_header Host $host;
+    proxy_set_header X-Real-IP $remote_addr;
+    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+    proxy_set_header X-Forwarded-Proto $scheme;
+    proxy_set_header X-Forwarded-Host $server_name;
+  }
+`,
+		staticLocationsWithComment(config.StaticPaths, config.Port),
+		PathRouteLocations(config.PathRoutes),
 		config.Port,
 	)
 }
 
-// generateSSLConfig generates HTTPS-enabled nginx configuration with HTTP redirect
+// generateSSLConfig generates HTTPS-enabled nginx configuration with HTTP
+// redirect. A redirected name shares the HTTP block, whose $server_name is
+// the domain, so plain HTTP requests for it take a single hop too.
 func (m *Manager) generateSSLConfig(config proxy.ProxyConfig) string {
+	httpNames := config.Domain
+	if config.RedirectFrom != "" {
+		httpNames += " " + config.RedirectFrom
+	}
+
 	return fmt.Sprintf(`# HTTP server - redirect to HTTPS
 server {
   listen 80;
+  listen [::]:80;
   server_name %s;
   return 301 https://$server_name$request_uri;
 }
 
-# HTTPS server
+%s# HTTPS server
 server {
-  listen 443 ssl http2;
-  server_name %s;
+%s  server_name %s;
 
   # SSL configuration
   ssl_certificate %s;
@@ -324,34 +618,22 @@ server {
   add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;
   add_header X-Frame-Options "SAMEORIGIN" always;
   add_header X-Content-Type-Options "nosniff" always;
-
+%s
   # Logging
   access_log /var/log/nginx/%s_access.log;
   error_log  /var/log/nginx/%s_error.log;
 
-  # Static files
-  location /static/ { alias /srv/%s/shared/static/; }
-  location /media/  { alias /srv/%s/shared/media/; }
-
-  # Proxy to application
-  location / {
-    proxy_pass http://127.0.0.1:%d;
-    proxy_set_header Host $host;
-    proxy_set_header X-Real-IP $remote_addr;
-    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-    proxy_set_header X-Forwarded-Proto $scheme;
-    proxy_set_header X-Forwarded-Host $server_name;
-  }
-}
+%s}
 `,
-		config.Domain,
+		httpNames,
+		redirectSSLServer(config),
+		sslListen(config, config.HTTP3),
 		config.Domain,
 		config.SSLCertPath,
 		config.SSLKeyPath,
+		altSvcHeader(config),
 		config.AppName,
 		config.AppName,
-		config.AppName,
-		config.AppName,
-		config.Port,
+		siteLocations(config, true),
 	)
 }
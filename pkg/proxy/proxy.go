@@ -13,6 +13,36 @@ type ProxyConfig struct {
 	SSLEnabled  bool
 	SSLCertPath string
 	SSLKeyPath  string
+	PathRoutes  []PathRoute  // Path prefixes on this domain served by other apps
+	StaticPaths []StaticPath // URL prefixes served straight from disk
+	// StaticRoot serves a static site from this directory instead of proxying
+	// to Port, with OutputDirs (build output subdirectories) under their own
+	// URL prefixes
+	StaticRoot string
+	OutputDirs []StaticPath
+	// RedirectFrom is a second name, the www or apex counterpart of Domain,
+	// answered with a 301 to Domain
+	RedirectFrom string
+	// DisableHTTP2 serves HTTPS over HTTP/1.1 only; HTTP/2 is on by default
+	DisableHTTP2 bool
+	// HTTP3 also listens for QUIC on UDP 443 and advertises it with Alt-Svc
+	HTTP3 bool
+	// QUICReuseport lists the QUIC listen addresses ("443", "[::]:443") that
+	// carry reuseport, which nginx needs on exactly one site per address
+	QUICReuseport []string
+}
+
+// StaticPath serves a URL prefix from a directory on the server, bypassing the app
+type StaticPath struct {
+	URLPrefix string // e.g. /media/ (with trailing slash)
+	Dir       string // Absolute directory, e.g. /srv/app/shared/media
+}
+
+// PathRoute routes a path prefix on one app's domain to another app on the same server
+type PathRoute struct {
+	PathPrefix string // e.g. /api (no trailing slash)
+	Port       int
+	AppName    string
 }
 
 // ProxyManager defines the interface for reverse proxy management
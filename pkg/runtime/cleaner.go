@@ -29,6 +29,11 @@ func CleanupUnusedRuntimes(sshExecutor *ssh.Executor, serverIP, destroyedTargetN
 		}
 	}
 
+	// Side-by-side Node.js versions no remaining app pins are on no app's PATH
+	if requiredRuntimes[RuntimeNodeJS] {
+		sshExecutor.ExecuteSudo(pruneNodeVersionsCommand(serverState.OtherRuntimeUses(destroyedTargetName)))
+	}
+
 	// 4. Nothing to clean up
 	if len(unusedRuntimes) == 0 {
 		return nil
@@ -36,7 +41,7 @@ func CleanupUnusedRuntimes(sshExecutor *ssh.Executor, serverIP, destroyedTargetN
 
 	// 5. Clean up each unused runtime
 	for _, rt := range unusedRuntimes {
-		if err := removeRuntime(sshExecutor, rt); err != nil {
+		if err := RemoveRuntime(sshExecutor, rt); err != nil {
 			// Log warning but don't fail - cleanup is best-effort
 			return fmt.Errorf("failed to remove runtime %s: %w", rt, err)
 		}
@@ -67,11 +72,9 @@ func GetRequiredRuntimesForApps(apps []state.DeployedApp) map[Runtime]bool {
 	for _, app := range apps {
 		// Map framework to language to runtime
 		language := GetLanguageFromFramework(app.Framework)
-		if language != "" {
-			runtime := GetRuntimeFromLanguage(language)
-			if runtime != RuntimeUnknown {
-				required[runtime] = true
-			}
+		runtime := GetRuntimeForDetection(language, app.Framework)
+		if runtime != RuntimeUnknown {
+			required[runtime] = true
 		}
 	}
 
@@ -130,6 +133,7 @@ func GetLanguageFromFramework(framework string) string {
 	rubyFrameworks := map[string]bool{
 		"Rails":   true,
 		"Sinatra": true,
+		"Jekyll":  true,
 	}
 	if rubyFrameworks[framework] {
 		return "Ruby"
@@ -147,8 +151,24 @@ func GetLanguageFromFramework(framework string) string {
 	return ""
 }
 
-// removeRuntime performs the actual removal of a runtime from the server
-func removeRuntime(sshExecutor *ssh.Executor, rt Runtime) error {
+// pruneNodeVersionsCommand removes the directories in NodeVersionsDir of
+// majors none of uses pins
+func pruneNodeVersionsCommand(uses []state.RuntimeUse) string {
+	keep := []string{}
+	for _, use := range uses {
+		if use.Runtime == state.Runtime(RuntimeNodeJS) && use.Version != "" {
+			keep = append(keep, use.Version)
+		}
+	}
+	if len(keep) == 0 {
+		return fmt.Sprintf("rm -rf %s 2>/dev/null || true", NodeVersionsDir)
+	}
+	return fmt.Sprintf(`for dir in %s/*; do case "${dir##*/}" in %s) ;; *) rm -rf "$dir" ;; esac; done 2>/dev/null || true`, NodeVersionsDir, strings.Join(keep, "|"))
+}
+
+// RemoveRuntime removes a runtime's packages, directories and leftovers from
+// the server
+func RemoveRuntime(sshExecutor ssh.SudoRunner, rt Runtime) error {
 	info := GetRuntimeInfo(rt)
 
 	// Remove APT packages
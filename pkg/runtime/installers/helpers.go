@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"lightfold/pkg/providers"
+	"lightfold/pkg/runtime"
 	sshpkg "lightfold/pkg/ssh"
 )
 
@@ -34,10 +36,50 @@ func formatCommandError(operation string, result *sshpkg.CommandResult) error {
 	return fmt.Errorf("%s failed", operation)
 }
 
-func commandAvailable(ctx *Context, command string) (bool, error) {
-	result := ctx.SSH.Execute(fmt.Sprintf("command -v %s >/dev/null 2>&1 && echo 'found' || echo 'not-found'", command))
+// toolAvailable reports whether a registered tool is installed, probing with
+// the tool's PATH exports. Results are cached per server for the run.
+func toolAvailable(ctx *Context, name string) (bool, error) {
+	tool, ok := runtime.ToolFor(name)
+	if !ok {
+		tool = runtime.Tool{Name: name, Binary: name}
+	}
+	if installed, known := runtime.ToolInstalled(ctx.Server, tool.Name); known {
+		return installed, nil
+	}
+
+	result := ctx.SSH.Execute(tool.ProbeCommand() + " && echo 'found' || echo 'not-found'")
 	if result.Error != nil {
 		return false, result.Error
 	}
-	return strings.TrimSpace(result.Stdout) == "found", nil
+	installed := strings.TrimSpace(result.Stdout) == "found"
+	runtime.RecordToolInstalled(ctx.Server, tool.Name, installed)
+	return installed, nil
+}
+
+// installTool runs a registered tool's install command as the SSH user
+func installTool(ctx *Context, name string) error {
+	tool, ok := runtime.ToolFor(name)
+	if !ok || tool.Install == "" {
+		return nil
+	}
+
+	result := ctx.SSH.Execute(tool.Install)
+	if ctx.Tail != nil {
+		ctx.Tail(result, 3)
+	}
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError("failed to install "+tool.Binary, result)
+	}
+	runtime.RecordToolInstalled(ctx.Server, tool.Name, true)
+	return nil
+}
+
+// serverArch returns the server's CPU architecture from `uname -m`, normalized
+// to providers.ArchX86_64 or providers.ArchARM64
+func serverArch(ctx *Context) (string, error) {
+	result := ctx.SSH.Execute("uname -m")
+	if result.Error != nil || result.ExitCode != 0 {
+		return "", formatCommandError("failed to detect server architecture", result)
+	}
+	return providers.NormalizeArch(result.Stdout), nil
 }
@@ -0,0 +1,68 @@
+package installers
+
+import (
+	"fmt"
+	"strings"
+
+	"lightfold/pkg/runtime"
+)
+
+// DefaultHugoVersion is used when the project does not pin a version
+// via .hugo-version or HUGO_VERSION in netlify.toml.
+const DefaultHugoVersion = "0.128.0"
+
+const hugoReleaseURL = "https://github.com/gohugoio/hugo/releases/download/v%s/hugo_extended_%s_linux-%s.deb"
+
+type hugoInstaller struct{}
+
+func init() {
+	Register(&hugoInstaller{})
+}
+
+func (h *hugoInstaller) Runtime() runtime.Runtime {
+	return runtime.RuntimeHugo
+}
+
+func (h *hugoInstaller) IsInstalled(ctx *Context) (bool, error) {
+	result := ctx.SSH.Execute("hugo version 2>/dev/null || echo 'not-found'")
+	if result.Error != nil {
+		return false, result.Error
+	}
+	output := strings.TrimSpace(result.Stdout)
+	if output == "" || output == "not-found" {
+		return false, nil
+	}
+	return strings.Contains(output, "v"+hugoVersion(ctx)), nil
+}
+
+func (h *hugoInstaller) Install(ctx *Context) error {
+	version := hugoVersion(ctx)
+	logOutput(ctx, fmt.Sprintf("  Installing Hugo %s (extended)...", version))
+
+	archResult := ctx.SSH.Execute("dpkg --print-architecture")
+	if archResult.Error != nil || archResult.ExitCode != 0 {
+		return formatCommandError("failed to detect server architecture", archResult)
+	}
+	arch := strings.TrimSpace(archResult.Stdout)
+
+	url := fmt.Sprintf(hugoReleaseURL, version, version, arch)
+	cmd := fmt.Sprintf("curl -fsSL -o /tmp/hugo.deb %s && DEBIAN_FRONTEND=noninteractive dpkg -i /tmp/hugo.deb && rm -f /tmp/hugo.deb", url)
+
+	result := ctx.SSH.ExecuteSudo(fmt.Sprintf("bash -c '%s'", cmd))
+	if ctx.Tail != nil {
+		ctx.Tail(result, 3)
+	}
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError(fmt.Sprintf("failed to install Hugo %s", version), result)
+	}
+	return nil
+}
+
+func hugoVersion(ctx *Context) string {
+	if ctx != nil && ctx.Detection != nil && ctx.Detection.Meta != nil {
+		if version := ctx.Detection.Meta["hugo_version"]; version != "" {
+			return version
+		}
+	}
+	return DefaultHugoVersion
+}
@@ -0,0 +1,79 @@
+package installers
+
+import (
+	"lightfold/pkg/detector"
+	"lightfold/pkg/runtime"
+	"testing"
+)
+
+func TestHugoInstaller_Runtime(t *testing.T) {
+	installer := &hugoInstaller{}
+	if installer.Runtime() != runtime.RuntimeHugo {
+		t.Errorf("Expected runtime %q, got %q", runtime.RuntimeHugo, installer.Runtime())
+	}
+}
+
+func TestHugoInstaller_IsInstalled_MatchesPinnedVersion(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+	installer := &hugoInstaller{}
+
+	ctx := &Context{SSH: mockSSH}
+	installed, err := installer.IsInstalled(ctx)
+	if err != nil {
+		t.Fatalf("IsInstalled returned error: %v", err)
+	}
+	if !installed {
+		t.Error("Expected default Hugo version to be reported as installed")
+	}
+
+	ctx.Detection = &detector.Detection{Framework: "Hugo", Meta: map[string]string{"hugo_version": "0.140.1"}}
+	installed, err = installer.IsInstalled(ctx)
+	if err != nil {
+		t.Fatalf("IsInstalled returned error: %v", err)
+	}
+	if installed {
+		t.Error("Expected a different pinned version to require installation")
+	}
+}
+
+func TestHugoInstaller_Install_UsesPinnedVersionAndArch(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+	installer := &hugoInstaller{}
+
+	ctx := &Context{
+		SSH:       mockSSH,
+		Detection: &detector.Detection{Framework: "Hugo", Meta: map[string]string{"hugo_version": "0.140.1"}},
+	}
+
+	if err := installer.Install(ctx); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+
+	if !mockSSH.hasCommand("v0.140.1/hugo_extended_0.140.1_linux-arm64.deb") {
+		t.Errorf("Expected download of pinned arm64 release, got commands: %v", mockSSH.commands)
+	}
+	if !mockSSH.hasCommand("dpkg -i /tmp/hugo.deb") {
+		t.Error("Expected hugo package to be installed with dpkg")
+	}
+}
+
+func TestEnsureRuntimeInstalled_HugoUsesHugoInstaller(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+	mockSSH.failures["hugo version 2>/dev/null || echo 'not-found'"] = true
+
+	ctx := &Context{
+		SSH:       mockSSH,
+		Detection: &detector.Detection{Framework: "Hugo", Language: "Go"},
+	}
+
+	if err := EnsureRuntimeInstalled(ctx); err != nil {
+		t.Fatalf("EnsureRuntimeInstalled returned error: %v", err)
+	}
+
+	if mockSSH.hasCommand("golang-go") {
+		t.Error("Hugo sites should not install the Go toolchain")
+	}
+	if !mockSSH.hasCommand("hugo_extended_") {
+		t.Error("Expected Hugo release to be installed")
+	}
+}
@@ -2,13 +2,34 @@ package installers
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"lightfold/pkg/providers"
 	"lightfold/pkg/runtime"
+	"lightfold/pkg/state"
+	"lightfold/pkg/util"
 )
 
-const nodeVersionTarget = "v20.11.1"
-const nodeArchiveURL = "https://nodejs.org/dist/v20.11.1/node-v20.11.1-linux-x64.tar.xz"
+// nodeMinimumMajor is the oldest default node kept for apps that do not pin a
+// version; older distro builds are replaced unless another app requires them
+const nodeMinimumMajor = 18
+
+// nodeDistArch maps server architectures to the suffix of Node.js release archives
+var nodeDistArch = map[string]string{
+	providers.ArchX86_64: "x64",
+	providers.ArchARM64:  "arm64",
+}
+
+// nodeArchiveName returns the release archive directory for arch, e.g.
+// node-v20.11.1-linux-arm64
+func nodeArchiveName(release, arch string) (string, error) {
+	distArch, ok := nodeDistArch[arch]
+	if !ok {
+		return "", fmt.Errorf("no Node.js %s build for server architecture %q", release, arch)
+	}
+	return fmt.Sprintf("node-%s-linux-%s", release, distArch), nil
+}
 
 type nodeInstaller struct{}
 
@@ -21,14 +42,13 @@ func (n *nodeInstaller) Runtime() runtime.Runtime {
 }
 
 func (n *nodeInstaller) IsInstalled(ctx *Context) (bool, error) {
-	version := n.currentNodeVersion(ctx)
-	if version == "" {
+	if !n.requiredVersionAvailable(ctx) {
 		return false, nil
 	}
 
 	if ctx.Detection != nil {
 		if pm, ok := ctx.Detection.Meta["package_manager"]; ok && pm != "" && pm != "npm" {
-			installed, err := commandAvailable(ctx, pm)
+			installed, err := toolAvailable(ctx, pm)
 			if err != nil {
 				return false, err
 			}
@@ -41,27 +61,82 @@ func (n *nodeInstaller) IsInstalled(ctx *Context) (bool, error) {
 	return true, nil
 }
 
+// Install makes the node the app needs available without touching versions
+// other apps use. An app pinning a major the default node does not match gets
+// that major in runtime.NodeVersionsDir; the default node is only installed
+// when there is none, or replaced when it is too old and no other app needs it.
 func (n *nodeInstaller) Install(ctx *Context) error {
+	spec := pinnedNodeVersion(ctx)
+	major := runtime.NodeMajor(spec)
 	existingVersion := n.currentNodeVersion(ctx)
-	if strings.HasPrefix(existingVersion, "v20.") {
+	existingMajor := runtime.NodeMajor(existingVersion)
+
+	switch {
+	case existingVersion == "":
+		if err := n.installDefaultNode(ctx, spec); err != nil {
+			return err
+		}
+	case major == 0 && existingMajor < nodeMinimumMajor:
+		if users := nodeUsers(ctx.Shared, existingMajor); len(users) > 0 {
+			logOutput(ctx, fmt.Sprintf("  Keeping Node.js %s, required by %s", existingVersion, strings.Join(users, ", ")))
+			n.linkNodeBinaries(ctx)
+			break
+		}
+		if err := n.installDefaultNode(ctx, spec); err != nil {
+			return err
+		}
+	case major == 0 || major == existingMajor:
 		logOutput(ctx, fmt.Sprintf("  Node.js already installed: %s", existingVersion))
 		n.linkNodeBinaries(ctx)
-		return n.ensurePackageManagers(ctx)
+	default:
+		if version := n.sideBySideVersion(ctx, major); version != "" {
+			logOutput(ctx, fmt.Sprintf("  Node.js already installed: %s at %s", version, runtime.NodeBinDir(major)))
+			break
+		}
+		release, err := runtime.NodeRelease(spec)
+		if err != nil {
+			return err
+		}
+		logOutput(ctx, fmt.Sprintf("  Installing Node.js %s next to the default %s...", release, existingVersion))
+		if err := n.installSideBySide(ctx, release, major); err != nil {
+			return err
+		}
 	}
 
-	logOutput(ctx, fmt.Sprintf("  Installing Node.js %s...", nodeVersionTarget))
-
-	n.removeLegacyNode(ctx)
+	return n.ensurePackageManagers(ctx)
+}
 
-	if err := n.downloadAndInstallNode(ctx); err != nil {
-		return err
+// pinnedNodeVersion returns the app's .nvmrc or .node-version pin
+func pinnedNodeVersion(ctx *Context) string {
+	if ctx.Detection == nil {
+		return ""
 	}
+	return ctx.Detection.Meta["runtime_version"]
+}
 
-	if err := n.ensurePackageManagers(ctx); err != nil {
-		return err
+// nodeUsers returns the other apps that run on the default node when it is
+// the given major: apps pinning that major and apps pinning none
+func nodeUsers(shared []state.RuntimeUse, major int) []string {
+	var users []string
+	for _, use := range shared {
+		if use.Runtime != state.Runtime(runtime.RuntimeNodeJS) {
+			continue
+		}
+		if use.Version == "" || use.Version == strconv.Itoa(major) {
+			users = append(users, use.TargetName)
+		}
 	}
+	return users
+}
 
-	return nil
+// requiredVersionAvailable reports whether the node the app needs is on the server
+func (n *nodeInstaller) requiredVersionAvailable(ctx *Context) bool {
+	existingVersion := n.currentNodeVersion(ctx)
+	major := runtime.NodeMajor(pinnedNodeVersion(ctx))
+	if major == 0 || major == runtime.NodeMajor(existingVersion) {
+		return existingVersion != ""
+	}
+	return n.sideBySideVersion(ctx, major) != ""
 }
 
 func (n *nodeInstaller) currentNodeVersion(ctx *Context) string {
@@ -73,10 +148,31 @@ func (n *nodeInstaller) currentNodeVersion(ctx *Context) string {
 	return version
 }
 
+// sideBySideVersion returns the version installed for major in
+// runtime.NodeVersionsDir, or "" when there is none
+func (n *nodeInstaller) sideBySideVersion(ctx *Context, major int) string {
+	result := ctx.SSH.Execute(fmt.Sprintf("%s/node --version 2>/dev/null || echo 'not-found'", runtime.NodeBinDir(major)))
+	version := strings.TrimSpace(result.Stdout)
+	if version == "not-found" || runtime.NodeMajor(version) != major {
+		return ""
+	}
+	return version
+}
+
+// linkNodeBinaries links node, npm and npx from /usr/local/bin into /usr/bin
 func (n *nodeInstaller) linkNodeBinaries(ctx *Context) {
-	ctx.SSH.ExecuteSudo("ln -sf /usr/local/bin/node /usr/bin/node")
-	ctx.SSH.ExecuteSudo("ln -sf /usr/local/bin/npm /usr/bin/npm")
-	ctx.SSH.ExecuteSudo("ln -sf /usr/local/bin/npx /usr/bin/npx")
+	ctx.SSH.ExecuteSudo("sh -c " + util.ShellQuote(linkNodeBinariesScript("/usr/local/bin", "/usr/bin")))
+}
+
+// linkNodeBinariesScript links each node binary in from into to. A binary
+// missing from from, or resolving into to (a distro node kept for other apps
+// may be reachable through both), is skipped so to never gets a link to itself.
+func linkNodeBinariesScript(from, to string) string {
+	return fmt.Sprintf(`for bin in node npm npx; do
+  [ -e %[1]s/$bin ] || continue
+  case "$(readlink -f %[1]s/$bin)" in %[2]s/*) continue ;; esac
+  ln -sf %[1]s/$bin %[2]s/$bin
+done`, from, to)
 }
 
 func (n *nodeInstaller) removeLegacyNode(ctx *Context) {
@@ -88,25 +184,57 @@ func (n *nodeInstaller) removeLegacyNode(ctx *Context) {
 	ctx.SSH.ExecuteSudo("rm -f /etc/apt/sources.list.d/nodesource.list 2>/dev/null || true")
 }
 
-func (n *nodeInstaller) downloadAndInstallNode(ctx *Context) error {
-	result := ctx.SSH.ExecuteSudo(fmt.Sprintf("curl -fsSL %s -o /tmp/node.tar.xz", nodeArchiveURL))
+// installDefaultNode installs the release for spec as the server's default
+// node in /usr/local, replacing any distro node
+func (n *nodeInstaller) installDefaultNode(ctx *Context, spec string) error {
+	release, err := runtime.NodeRelease(spec)
+	if err != nil {
+		return err
+	}
+	logOutput(ctx, fmt.Sprintf("  Installing Node.js %s...", release))
+
+	n.removeLegacyNode(ctx)
+	return n.downloadAndInstallNode(ctx, release)
+}
+
+// fetchNodeArchive downloads and extracts a release for the server's
+// architecture into /tmp, returning the extracted directory's name
+func (n *nodeInstaller) fetchNodeArchive(ctx *Context, release string) (string, error) {
+	arch, err := serverArch(ctx)
+	if err != nil {
+		return "", err
+	}
+	archive, err := nodeArchiveName(release, arch)
+	if err != nil {
+		return "", err
+	}
+
+	result := ctx.SSH.ExecuteSudo(fmt.Sprintf("curl -fsSL https://nodejs.org/dist/%s/%s.tar.xz -o /tmp/node.tar.xz", release, archive))
 	if result.Error != nil || result.ExitCode != 0 {
-		return formatCommandError("failed to download Node.js", result)
+		return "", formatCommandError("failed to download Node.js", result)
 	}
 
 	result = ctx.SSH.ExecuteSudo("tar -xf /tmp/node.tar.xz -C /tmp")
 	if result.Error != nil || result.ExitCode != 0 {
-		return formatCommandError("failed to extract Node.js", result)
+		return "", formatCommandError("failed to extract Node.js", result)
+	}
+	return archive, nil
+}
+
+func (n *nodeInstaller) downloadAndInstallNode(ctx *Context, release string) error {
+	archive, err := n.fetchNodeArchive(ctx, release)
+	if err != nil {
+		return err
 	}
 
-	result = ctx.SSH.ExecuteSudo("cp -r /tmp/node-v20.11.1-linux-x64/* /usr/local/")
+	result := ctx.SSH.ExecuteSudo(fmt.Sprintf("cp -r /tmp/%s/* /usr/local/", archive))
 	if result.Error != nil || result.ExitCode != 0 {
 		return formatCommandError("failed to install Node.js to /usr/local", result)
 	}
 
 	n.linkNodeBinaries(ctx)
 
-	ctx.SSH.ExecuteSudo("rm -rf /tmp/node-v20.11.1-linux-x64 /tmp/node.tar.xz")
+	ctx.SSH.ExecuteSudo(fmt.Sprintf("rm -rf /tmp/%s /tmp/node.tar.xz", archive))
 
 	versionResult := ctx.SSH.Execute("/usr/bin/node --version")
 	nodeVersion := strings.TrimSpace(versionResult.Stdout)
@@ -121,6 +249,29 @@ func (n *nodeInstaller) downloadAndInstallNode(ctx *Context) error {
 	return nil
 }
 
+// installSideBySide installs a release into the major's directory under
+// runtime.NodeVersionsDir, leaving the default node alone
+func (n *nodeInstaller) installSideBySide(ctx *Context, release string, major int) error {
+	archive, err := n.fetchNodeArchive(ctx, release)
+	if err != nil {
+		return err
+	}
+
+	dir := strings.TrimSuffix(runtime.NodeBinDir(major), "/bin")
+	result := ctx.SSH.ExecuteSudo(fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s && cp -r /tmp/%[2]s/* %[1]s/", dir, archive))
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError("failed to install Node.js to "+dir, result)
+	}
+
+	ctx.SSH.ExecuteSudo(fmt.Sprintf("rm -rf /tmp/%s /tmp/node.tar.xz", archive))
+
+	if version := n.sideBySideVersion(ctx, major); version == "" {
+		return fmt.Errorf("failed to install Node.js %s to %s", release, dir)
+	}
+	logOutput(ctx, fmt.Sprintf("  Node.js installed: %s at %s/node", release, runtime.NodeBinDir(major)))
+	return nil
+}
+
 func (n *nodeInstaller) ensurePackageManagers(ctx *Context) error {
 	if ctx.Detection == nil {
 		return nil
@@ -131,32 +282,77 @@ func (n *nodeInstaller) ensurePackageManagers(ctx *Context) error {
 		return nil
 	}
 
-	switch pm {
-	case "bun":
-		result := ctx.SSH.Execute("curl -fsSL https://bun.sh/install | bash")
-		if ctx.Tail != nil {
-			ctx.Tail(result, 3)
-		}
-		if result.Error != nil || result.ExitCode != 0 {
-			return formatCommandError("failed to install bun", result)
-		}
-	case "pnpm":
-		result := ctx.SSH.ExecuteSudo("npm install -g pnpm")
-		if ctx.Tail != nil {
-			ctx.Tail(result, 3)
-		}
-		if result.Error != nil || result.ExitCode != 0 {
-			return formatCommandError("failed to install pnpm", result)
-		}
-	case "yarn":
-		result := ctx.SSH.ExecuteSudo("npm install -g yarn")
-		if ctx.Tail != nil {
-			ctx.Tail(result, 3)
-		}
-		if result.Error != nil || result.ExitCode != 0 {
-			return formatCommandError("failed to install yarn", result)
-		}
+	if pm == "bun" {
+		return installTool(ctx, pm)
+	}
+
+	err := n.prepareWithCorepack(ctx, pm)
+	if err == nil {
+		runtime.RecordToolInstalled(ctx.Server, pm, true)
+		return nil
+	}
+
+	tool, ok := runtime.ToolFor(pm)
+	if !ok || tool.Install == "" {
+		return err
+	}
+	logOutput(ctx, fmt.Sprintf("  Corepack unavailable (%v), installing %s with npm", err, tool.Binary))
+	// Global npm installs write next to node in /usr/local, so they need root
+	result := ctx.SSH.ExecuteSudo(tool.Install)
+	if ctx.Tail != nil {
+		ctx.Tail(result, 3)
+	}
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError("failed to install "+tool.Binary, result)
 	}
+	runtime.RecordToolInstalled(ctx.Server, pm, true)
 
 	return nil
 }
+
+// prepareWithCorepack enables Node's corepack shims and activates the version
+// pinned in package.json's packageManager field, so builds use the same pnpm
+// or yarn release the lockfile was written with
+func (n *nodeInstaller) prepareWithCorepack(ctx *Context, pm string) error {
+	result := ctx.SSH.ExecuteSudo("corepack enable")
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError("failed to enable corepack", result)
+	}
+
+	spec := corepackSpec(pm, ctx.Detection.Meta["package_manager_spec"])
+	if spec == "" {
+		return nil
+	}
+
+	logOutput(ctx, fmt.Sprintf("  Activating %s with corepack", spec))
+	result = ctx.SSH.Execute(fmt.Sprintf("COREPACK_ENABLE_DOWNLOAD_PROMPT=0 corepack prepare %s --activate", spec))
+	if ctx.Tail != nil {
+		ctx.Tail(result, 3)
+	}
+	if result.Error != nil || result.ExitCode != 0 {
+		return formatCommandError("failed to prepare "+spec, result)
+	}
+	return nil
+}
+
+// corepackSpec returns the name@version corepack should activate for the
+// detected package manager. A packageManager pin for a different manager is
+// ignored; yarn berry without a pin gets the current stable release.
+func corepackSpec(pm, pinned string) string {
+	name := packageManagerBinary(pm)
+	if pinnedName, _, ok := strings.Cut(pinned, "@"); ok && pinnedName == name {
+		return pinned
+	}
+	if pm == "yarn-berry" {
+		return "yarn@stable"
+	}
+	return ""
+}
+
+// packageManagerBinary maps a detected package manager to the command it runs as
+func packageManagerBinary(pm string) string {
+	if tool, ok := runtime.ToolFor(pm); ok {
+		return tool.Binary
+	}
+	return pm
+}
@@ -0,0 +1,285 @@
+package installers
+
+import (
+	"lightfold/pkg/detector"
+	"lightfold/pkg/ssh"
+	"lightfold/pkg/state"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNodeInstaller_EnsurePackageManagers_CorepackPin(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+	ctx := &Context{
+		SSH: mockSSH,
+		Detection: &detector.Detection{
+			Meta: map[string]string{"package_manager": "pnpm", "package_manager_spec": "pnpm@9.1.0"},
+		},
+	}
+
+	if err := (&nodeInstaller{}).ensurePackageManagers(ctx); err != nil {
+		t.Fatalf("ensurePackageManagers() error: %v", err)
+	}
+
+	if !mockSSH.hasCommand("sudo -n corepack enable") {
+		t.Error("expected corepack to be enabled")
+	}
+	if !mockSSH.hasCommand("corepack prepare pnpm@9.1.0 --activate") {
+		t.Error("expected the pinned pnpm version to be activated")
+	}
+	if mockSSH.hasCommand("npm install -g") {
+		t.Error("npm install -g should not run when corepack works")
+	}
+}
+
+func TestNodeInstaller_EnsurePackageManagers_FallsBackToNpm(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+	mockSSH.failures["sudo -n corepack enable"] = true
+	ctx := &Context{
+		SSH:       mockSSH,
+		Detection: &detector.Detection{Meta: map[string]string{"package_manager": "yarn-berry"}},
+	}
+
+	if err := (&nodeInstaller{}).ensurePackageManagers(ctx); err != nil {
+		t.Fatalf("ensurePackageManagers() error: %v", err)
+	}
+
+	if !mockSSH.hasCommand("sudo -n npm install -g yarn") {
+		t.Errorf("expected yarn to be installed with npm, got %v", mockSSH.commands)
+	}
+}
+
+func TestCorepackSpec(t *testing.T) {
+	tests := []struct {
+		pm     string
+		pinned string
+		want   string
+	}{
+		{pm: "pnpm", pinned: "pnpm@9.1.0", want: "pnpm@9.1.0"},
+		{pm: "pnpm", pinned: "", want: ""},
+		{pm: "yarn", pinned: "yarn@1.22.22", want: "yarn@1.22.22"},
+		{pm: "yarn-berry", pinned: "yarn@4.1.0", want: "yarn@4.1.0"},
+		{pm: "yarn-berry", pinned: "", want: "yarn@stable"},
+		{pm: "pnpm", pinned: "yarn@4.1.0", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := corepackSpec(tt.pm, tt.pinned); got != tt.want {
+			t.Errorf("corepackSpec(%q, %q) = %q, want %q", tt.pm, tt.pinned, got, tt.want)
+		}
+	}
+}
+
+func TestNodeInstaller_DownloadsArchiveForServerArch(t *testing.T) {
+	mockSSH := newMockSSHExecutor()
+
+	if err := (&nodeInstaller{}).downloadAndInstallNode(&Context{SSH: mockSSH}, "v20.11.1"); err != nil {
+		t.Fatalf("downloadAndInstallNode() error: %v", err)
+	}
+
+	if !mockSSH.hasCommand("https://nodejs.org/dist/v20.11.1/node-v20.11.1-linux-arm64.tar.xz") {
+		t.Errorf("expected the arm64 archive on an aarch64 server, got %v", mockSSH.commands)
+	}
+	if !mockSSH.hasCommand("cp -r /tmp/node-v20.11.1-linux-arm64/* /usr/local/") {
+		t.Errorf("expected the arm64 archive to be installed, got %v", mockSSH.commands)
+	}
+	if mockSSH.hasCommand("linux-x64") {
+		t.Error("x64 archive should not be used on an aarch64 server")
+	}
+}
+
+func TestNodeArchiveName(t *testing.T) {
+	if got, _ := nodeArchiveName("v20.11.1", "x86_64"); got != "node-v20.11.1-linux-x64" {
+		t.Errorf("nodeArchiveName(x86_64) = %q", got)
+	}
+	if got, _ := nodeArchiveName("v22.11.0", "arm64"); got != "node-v22.11.0-linux-arm64" {
+		t.Errorf("nodeArchiveName(arm64) = %q", got)
+	}
+	if _, err := nodeArchiveName("v20.11.1", "riscv64"); err == nil {
+		t.Error("nodeArchiveName(riscv64) should fail")
+	}
+}
+
+// nodeServer fakes a server's default node and the side-by-side installs
+// that appear once they are copied into place
+type nodeServer struct {
+	*mockSSHExecutor
+	defaultVersion string
+	sideBySide     map[string]string // Major directory to version
+}
+
+func newNodeServer(defaultVersion string) *nodeServer {
+	return &nodeServer{mockSSHExecutor: newMockSSHExecutor(), defaultVersion: defaultVersion, sideBySide: map[string]string{}}
+}
+
+func (s *nodeServer) Execute(command string) *ssh.CommandResult {
+	s.commands = append(s.commands, command)
+	switch {
+	case strings.HasPrefix(command, "/usr/local/bin/node --version"), command == "/usr/bin/node --version":
+		if s.defaultVersion == "" {
+			return &ssh.CommandResult{Stdout: "not-found"}
+		}
+		return &ssh.CommandResult{Stdout: s.defaultVersion}
+	case strings.HasPrefix(command, "/opt/lightfold/node/"):
+		dir := strings.Split(command, "/")[4]
+		if version, ok := s.sideBySide[dir]; ok {
+			return &ssh.CommandResult{Stdout: version}
+		}
+		return &ssh.CommandResult{Stdout: "not-found"}
+	case strings.Contains(command, "uname -m"):
+		return &ssh.CommandResult{Stdout: "x86_64"}
+	case strings.Contains(command, "cp -r /tmp/node-v22.11.0-linux-x64/* /usr/local/"):
+		s.defaultVersion = "v22.11.0"
+	case strings.Contains(command, "/opt/lightfold/node/20/"):
+		s.sideBySide["20"] = "v20.11.1"
+	}
+	return &ssh.CommandResult{}
+}
+
+func (s *nodeServer) ExecuteSudo(command string) *ssh.CommandResult {
+	return s.Execute("sudo -n " + command)
+}
+
+func nodeContext(server *nodeServer, pin string, shared ...state.RuntimeUse) *Context {
+	return &Context{
+		SSH:       server,
+		Detection: &detector.Detection{Language: "JavaScript/TypeScript", Meta: map[string]string{"runtime_version": pin}},
+		Shared:    shared,
+	}
+}
+
+func TestNodeInstaller_PinnedMajorInstallsSideBySide(t *testing.T) {
+	server := newNodeServer("v22.11.0")
+	ctx := nodeContext(server, "20", state.RuntimeUse{TargetName: "app-b", Runtime: "nodejs", Version: "22"})
+
+	if installed, _ := (&nodeInstaller{}).IsInstalled(ctx); installed {
+		t.Fatal("IsInstalled() = true, want false without Node.js 20")
+	}
+	if err := (&nodeInstaller{}).Install(ctx); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+
+	if !server.hasCommand("https://nodejs.org/dist/v20.11.1/node-v20.11.1-linux-x64.tar.xz") {
+		t.Errorf("expected Node.js 20 to be downloaded, got %v", server.commands)
+	}
+	if !server.hasCommand("mkdir -p /opt/lightfold/node/20 && cp -r /tmp/node-v20.11.1-linux-x64/* /opt/lightfold/node/20/") {
+		t.Errorf("expected Node.js 20 next to the default node, got %v", server.commands)
+	}
+	if server.hasCommand("apt-get remove") || server.hasCommand("cp -r /tmp/node-v20.11.1-linux-x64/* /usr/local/") {
+		t.Errorf("the default Node.js 22 was touched: %v", server.commands)
+	}
+	if server.defaultVersion != "v22.11.0" {
+		t.Errorf("default node = %s, want v22.11.0 kept", server.defaultVersion)
+	}
+	if installed, _ := (&nodeInstaller{}).IsInstalled(ctx); !installed {
+		t.Error("IsInstalled() = false after the side-by-side install")
+	}
+}
+
+func TestNodeInstaller_UnpinnedAppKeepsNewerDefault(t *testing.T) {
+	server := newNodeServer("v22.11.0")
+
+	if err := (&nodeInstaller{}).Install(nodeContext(server, "")); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if server.hasCommand("apt-get remove") || server.hasCommand("nodejs.org/dist") {
+		t.Errorf("Node.js 22 was replaced for an app that pins no version: %v", server.commands)
+	}
+}
+
+func TestNodeInstaller_MatchingDefaultIsUsed(t *testing.T) {
+	server := newNodeServer("v22.11.0")
+
+	if err := (&nodeInstaller{}).Install(nodeContext(server, "v22.3.0")); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if server.hasCommand("nodejs.org/dist") || server.hasCommand("/opt/lightfold/node/22 ") {
+		t.Errorf("installed Node.js although the default matches the pinned major: %v", server.commands)
+	}
+}
+
+func TestNodeInstaller_LegacyDefault(t *testing.T) {
+	// Nobody else needs the distro node: it is replaced as before
+	server := newNodeServer("v12.22.9")
+	if err := (&nodeInstaller{}).Install(nodeContext(server, "", state.RuntimeUse{TargetName: "api", Runtime: "python"})); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if !server.hasCommand("apt-get remove -y nodejs") || !server.hasCommand("node-v20.11.1-linux-x64.tar.xz") {
+		t.Errorf("expected the distro node to be replaced with the default, got %v", server.commands)
+	}
+
+	// Another app runs on it: it stays
+	server = newNodeServer("v12.22.9")
+	if err := (&nodeInstaller{}).Install(nodeContext(server, "", state.RuntimeUse{TargetName: "legacy", Runtime: "nodejs", Version: "12"})); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if server.hasCommand("apt-get remove") || server.hasCommand("nodejs.org/dist") {
+		t.Errorf("Node.js 12 was removed although legacy requires it: %v", server.commands)
+	}
+}
+
+func TestNodeInstaller_FreshServerInstallsPinnedMajorAsDefault(t *testing.T) {
+	server := newNodeServer("")
+
+	if err := (&nodeInstaller{}).Install(nodeContext(server, "22")); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if !server.hasCommand("cp -r /tmp/node-v22.11.0-linux-x64/* /usr/local/") {
+		t.Errorf("expected Node.js 22 as the default node, got %v", server.commands)
+	}
+	if server.hasCommand("/opt/lightfold/node/22 ") {
+		t.Errorf("no side-by-side install expected on a fresh server: %v", server.commands)
+	}
+}
+
+func TestNodeUsers(t *testing.T) {
+	shared := []state.RuntimeUse{
+		{TargetName: "web", Runtime: "nodejs"},
+		{TargetName: "admin", Runtime: "nodejs", Version: "22"},
+		{TargetName: "docs", Runtime: "nodejs", Version: "20"},
+		{TargetName: "api", Runtime: "python", Version: "22"},
+	}
+	if got := nodeUsers(shared, 22); strings.Join(got, ",") != "web,admin" {
+		t.Errorf("nodeUsers(22) = %v, want web and admin", got)
+	}
+}
+
+func TestLinkNodeBinariesScript(t *testing.T) {
+	root := t.TempDir()
+	local, usrBin, lib := filepath.Join(root, "local"), filepath.Join(root, "bin"), filepath.Join(root, "lib")
+	for _, dir := range []string{local, usrBin, lib} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A kept distro node reachable from both directories, and an npm installed in lib
+	if err := os.WriteFile(filepath.Join(usrBin, "node"), []byte("distro node"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(usrBin, "node"), filepath.Join(local, "node")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib, "npm-cli.js"), []byte("npm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(lib, "npm-cli.js"), filepath.Join(local, "npm")); err != nil {
+		t.Fatal(err)
+	}
+
+	if output, err := exec.Command("sh", "-c", linkNodeBinariesScript(local, usrBin)).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, output)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(usrBin, "node")); err != nil || string(data) != "distro node" {
+		t.Errorf("the distro node was replaced: %q, %v", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(usrBin, "npm")); err != nil || target != filepath.Join(local, "npm") {
+		t.Errorf("npm link = %q, %v; want a link to %s", target, err, filepath.Join(local, "npm"))
+	}
+	if _, err := os.Lstat(filepath.Join(usrBin, "npx")); !os.IsNotExist(err) {
+		t.Errorf("npx was linked without a binary to link to: %v", err)
+	}
+}
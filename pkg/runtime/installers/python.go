@@ -35,15 +35,8 @@ func (p *pythonInstaller) IsInstalled(ctx *Context) (bool, error) {
 	}
 
 	if ctx.Detection != nil {
-		if pm, ok := ctx.Detection.Meta["package_manager"]; ok && pm != "" && pm != "pip" {
-			switch pm {
-			case "poetry":
-				return commandAvailable(ctx, "poetry")
-			case "pipenv":
-				return commandAvailable(ctx, "pipenv")
-			case "uv":
-				return commandAvailable(ctx, "uv")
-			}
+		if tool, ok := pythonPackageManager(ctx.Detection.Meta["package_manager"]); ok {
+			return toolAvailable(ctx, tool.Name)
 		}
 	}
 
@@ -93,28 +86,19 @@ func (p *pythonInstaller) Install(ctx *Context) error {
 		return nil
 	}
 
-	pm, ok := ctx.Detection.Meta["package_manager"]
-	if !ok || pm == "" || pm == "pip" {
+	tool, ok := pythonPackageManager(ctx.Detection.Meta["package_manager"])
+	if !ok {
 		return nil
 	}
+	return installTool(ctx, tool.Name)
+}
 
-	switch pm {
-	case "poetry":
-		result = ctx.SSH.Execute("curl -sSL https://install.python-poetry.org | python3 -")
-	case "pipenv":
-		result = ctx.SSH.Execute("pip3 install --user pipenv")
-	case "uv":
-		result = ctx.SSH.Execute("curl -LsSf https://astral.sh/uv/install.sh | sh")
-	default:
-		return nil
+// pythonPackageManager returns the installable Python tool for a detected
+// package manager; pip ships with Python
+func pythonPackageManager(pm string) (runtime.Tool, bool) {
+	tool, ok := runtime.ToolFor(pm)
+	if !ok || tool.Runtime != runtime.RuntimePython || tool.Install == "" {
+		return runtime.Tool{}, false
 	}
-
-	if ctx.Tail != nil {
-		ctx.Tail(result, 3)
-	}
-	if result.Error != nil || result.ExitCode != 0 {
-		return formatCommandError("failed to install "+pm, result)
-	}
-
-	return nil
+	return tool, true
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"lightfold/pkg/detector"
 	"lightfold/pkg/runtime"
+	"lightfold/pkg/state"
 	"os"
 	"time"
 
@@ -29,9 +30,11 @@ type SSHExecutor interface {
 // Context carries shared information for runtime installers.
 type Context struct {
 	SSH       SSHExecutor
+	Server    string // Server address keying the tool cache; empty disables caching
 	Detection *detector.Detection
 	Output    func(string)
 	Tail      func(result *sshpkg.CommandResult, lastNLines int)
+	Shared    []state.RuntimeUse // Versions other apps on the server require; installs never remove or downgrade them
 }
 
 // Installer provides hooks for ensuring a runtime is installed on a server.
@@ -62,7 +65,7 @@ func EnsureRuntimeInstalled(ctx *Context) error {
 		return nil
 	}
 
-	rt := runtime.GetRuntimeFromLanguage(ctx.Detection.Language)
+	rt := runtime.GetRuntimeForDetection(ctx.Detection.Language, ctx.Detection.Framework)
 	if rt == runtime.RuntimeUnknown {
 		return nil
 	}
@@ -89,7 +92,7 @@ func RuntimeNeedsInstall(ctx *Context) (bool, error) {
 		return false, nil
 	}
 
-	rt := runtime.GetRuntimeFromLanguage(ctx.Detection.Language)
+	rt := runtime.GetRuntimeForDetection(ctx.Detection.Language, ctx.Detection.Framework)
 	if rt == runtime.RuntimeUnknown {
 		return false, nil
 	}
@@ -25,7 +25,7 @@ func (r *rubyInstaller) IsInstalled(ctx *Context) (bool, error) {
 }
 
 func (r *rubyInstaller) Install(ctx *Context) error {
-	result := ctx.SSH.ExecuteSudo("DEBIAN_FRONTEND=noninteractive apt-get install -y -o Dpkg::Options::=\"--force-confdef\" -o Dpkg::Options::=\"--force-confold\" ruby-full")
+	result := ctx.SSH.ExecuteSudo("DEBIAN_FRONTEND=noninteractive apt-get install -y -o Dpkg::Options::=\"--force-confdef\" -o Dpkg::Options::=\"--force-confold\" ruby-full build-essential zlib1g-dev")
 	if ctx.Tail != nil {
 		ctx.Tail(result, 3)
 	}
@@ -14,12 +14,14 @@ import (
 type mockSSHExecutor struct {
 	commands []string
 	failures map[string]bool
+	outputs  map[string]string // Stdout for commands starting with the key, ahead of the defaults below
 }
 
 func newMockSSHExecutor() *mockSSHExecutor {
 	return &mockSSHExecutor{
 		commands: []string{},
 		failures: make(map[string]bool),
+		outputs:  make(map[string]string),
 	}
 }
 
@@ -54,6 +56,13 @@ func (m *mockSSHExecutor) Execute(command string) *ssh.CommandResult {
 		ExitCode: 0,
 	}
 
+	for prefix, stdout := range m.outputs {
+		if strings.HasPrefix(command, prefix) {
+			result.Stdout = stdout
+			return result
+		}
+	}
+
 	switch {
 	case strings.Contains(command, "python3 --version"):
 		result.Stdout = "Python 3.10.12"
@@ -81,6 +90,14 @@ func (m *mockSSHExecutor) Execute(command string) *ssh.CommandResult {
 		result.Stdout = "found"
 	case strings.Contains(command, "uv --version"):
 		result.Stdout = "uv 0.1.0"
+	case strings.Contains(command, "hugo version"):
+		result.Stdout = "hugo v0.128.0-e6d2712ee062321dc2fc49e963597dd5a6157660+extended linux/amd64"
+	case strings.Contains(command, "dpkg --print-architecture"):
+		result.Stdout = "arm64"
+	case command == "/usr/bin/node --version":
+		result.Stdout = "v20.11.1"
+	case strings.Contains(command, "uname -m"):
+		result.Stdout = "aarch64"
 	}
 
 	return result
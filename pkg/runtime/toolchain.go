@@ -0,0 +1,218 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Tool describes a package manager or runtime binary on the server: how to
+// tell it is installed, how to install it, and where it lives. The build
+// step, the systemd ExecStart command and the runtime installers all read
+// these, so they agree on paths.
+type Tool struct {
+	Name        string   // Name as detected in Meta["package_manager"], e.g. "yarn-berry"
+	Binary      string   // Command the tool runs as, e.g. "yarn"
+	Runtime     Runtime  // Runtime the tool belongs to
+	Install     string   // Shell command installing the tool; empty when it ships with the runtime
+	BinPath     string   // Absolute path for systemd units, which get no login PATH; empty to leave the command as is
+	PathExports []string // Exports build shells need to find the tool
+	Interpreter bool     // The runtime's own interpreter rather than a package manager
+}
+
+var tools = []Tool{
+	{Name: "node", Binary: "node", Runtime: RuntimeNodeJS, BinPath: "/usr/bin/node", Interpreter: true},
+	{Name: "npm", Binary: "npm", Runtime: RuntimeNodeJS, BinPath: "/usr/bin/npm"},
+	// Corepack shims and `npm install -g` both land next to node in /usr/local/bin
+	{Name: "pnpm", Binary: "pnpm", Runtime: RuntimeNodeJS, Install: "npm install -g pnpm", BinPath: "/usr/local/bin/pnpm",
+		PathExports: []string{`export PNPM_HOME="$HOME/.local/share/pnpm"`, `export PATH="$PNPM_HOME:$PATH"`}},
+	{Name: "yarn", Binary: "yarn", Runtime: RuntimeNodeJS, Install: "npm install -g yarn", BinPath: "/usr/local/bin/yarn"},
+	{Name: "yarn-berry", Binary: "yarn", Runtime: RuntimeNodeJS, Install: "npm install -g yarn", BinPath: "/usr/local/bin/yarn"},
+	{Name: "bun", Binary: "bun", Runtime: RuntimeNodeJS, Install: "curl -fsSL https://bun.sh/install | bash", BinPath: "/home/deploy/.bun/bin/bun",
+		PathExports: []string{`export PATH="$HOME/.bun/bin:$PATH"`}},
+	{Name: "python3", Binary: "python3", Runtime: RuntimePython, BinPath: "/usr/bin/python3", Interpreter: true},
+	{Name: "pip3", Binary: "pip3", Runtime: RuntimePython, BinPath: "/usr/bin/pip3"},
+	{Name: "poetry", Binary: "poetry", Runtime: RuntimePython, Install: "curl -sSL https://install.python-poetry.org | python3 -",
+		PathExports: []string{`export PATH="$HOME/.local/bin:$PATH"`}},
+	{Name: "uv", Binary: "uv", Runtime: RuntimePython, Install: "curl -LsSf https://astral.sh/uv/install.sh | sh",
+		PathExports: []string{`export PATH="$HOME/.local/bin:$PATH"`}},
+	{Name: "pipenv", Binary: "pipenv", Runtime: RuntimePython, Install: "pip3 install --user pipenv",
+		PathExports: []string{`export PATH="$HOME/.local/bin:$PATH"`}},
+	{Name: "bundler", Binary: "bundle", Runtime: RuntimeRuby, Install: "gem install bundler"},
+}
+
+// ToolFor returns the tool registered under a detected package manager name
+func ToolFor(name string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+// toolForBinary returns the tool a command name runs
+func toolForBinary(binary string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Binary == binary {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+// IsInstalledCheck returns a command that succeeds when the tool is on PATH
+func (t Tool) IsInstalledCheck() string {
+	return fmt.Sprintf("command -v %s >/dev/null 2>&1", t.Binary)
+}
+
+// ProbeCommand checks for the tool from a plain SSH session, which does not
+// load the exports build shells use
+func (t Tool) ProbeCommand() string {
+	return pathPrefix(t.PathExports) + t.IsInstalledCheck()
+}
+
+// BinaryPath returns the absolute path a systemd unit should run name from,
+// or name itself when it is not a registered tool
+func BinaryPath(name string) string {
+	if tool, ok := ToolFor(name); ok && tool.BinPath != "" {
+		return tool.BinPath
+	}
+	return name
+}
+
+// ExecStartCommand rewrites a run command that starts with a registered
+// package manager to use its absolute path. Interpreter commands such as
+// "node server.js" are left to the caller.
+func ExecStartCommand(runCommand string) string {
+	binary, rest, ok := strings.Cut(runCommand, " ")
+	if !ok {
+		return runCommand
+	}
+	tool, found := toolForBinary(binary)
+	if !found || tool.BinPath == "" || tool.Interpreter {
+		return runCommand
+	}
+	return tool.BinPath + " " + rest
+}
+
+// BuildPathPrefix returns the exports build commands run behind for the
+// detected language, package manager and pinned runtime version, ending in
+// " && " when not empty. Apps pinning a Node.js major find their own node first.
+func BuildPathPrefix(language, packageManager, runtimeVersion string) string {
+	var exports []string
+	if language == "JavaScript/TypeScript" {
+		if dir := NodePathDir(runtimeVersion); dir != "" {
+			exports = append(exports, fmt.Sprintf(`export PATH="%s:/usr/bin:$PATH"`, dir), `export NODE="$(command -v node)"`, "hash -r")
+		} else {
+			exports = append(exports, `export PATH="/usr/bin:$PATH"`, `export NODE="/usr/bin/node"`, "hash -r")
+		}
+	}
+	if tool, ok := ToolFor(packageManager); ok {
+		exports = append(exports, tool.PathExports...)
+	}
+	return pathPrefix(exports)
+}
+
+func pathPrefix(exports []string) string {
+	if len(exports) == 0 {
+		return ""
+	}
+	return strings.Join(exports, " && ") + " && "
+}
+
+// CommandTool returns the name of the installable tool a build command runs,
+// or "" when it runs none
+func CommandTool(command string) string {
+	if tool, ok := installableTool(command); ok {
+		return tool.Name
+	}
+	return ""
+}
+
+// EnsureToolCommand prefixes a build command with an install of the tool it
+// runs, unless the tool is already known to be installed on server. prefix is
+// the PATH prefix the command runs behind; exports it lacks are added.
+func EnsureToolCommand(server, command, prefix string) string {
+	tool, ok := installableTool(command)
+	if !ok {
+		return command
+	}
+	if installed, known := installCache.lookup(server, tool.Name); known && installed {
+		return command
+	}
+
+	var missing []string
+	for _, export := range tool.PathExports {
+		if !strings.Contains(prefix, export) {
+			missing = append(missing, export)
+		}
+	}
+	return fmt.Sprintf("%s(%s || %s) && %s", pathPrefix(missing), tool.IsInstalledCheck(), tool.Install, command)
+}
+
+// installableTool returns the first installable tool run in command position
+// (at the start or after &&, ||, ; or |)
+func installableTool(command string) (Tool, bool) {
+	commandPosition := true
+	for _, word := range strings.Fields(command) {
+		switch word {
+		case "&&", "||", ";", "|":
+			commandPosition = true
+			continue
+		}
+		if commandPosition {
+			if tool, ok := toolForBinary(word); ok && tool.Install != "" {
+				return tool, true
+			}
+		}
+		commandPosition = false
+	}
+	return Tool{}, false
+}
+
+// toolCache remembers tool checks per server for the rest of the command run,
+// so a tool is not probed or installed again for every build command
+type toolCache struct {
+	mu        sync.Mutex
+	installed map[string]bool
+}
+
+var installCache = &toolCache{installed: map[string]bool{}}
+
+func (c *toolCache) lookup(server, tool string) (installed, known bool) {
+	if server == "" {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	installed, known = c.installed[server+"|"+tool]
+	return installed, known
+}
+
+func (c *toolCache) record(server, tool string, installed bool) {
+	if server == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.installed[server+"|"+tool] = installed
+}
+
+// ToolInstalled returns the cached result of checking tool on server
+func ToolInstalled(server, tool string) (installed, known bool) {
+	return installCache.lookup(server, tool)
+}
+
+// RecordToolInstalled caches whether tool is installed on server. Results for
+// an empty server are not cached.
+func RecordToolInstalled(server, tool string, installed bool) {
+	installCache.record(server, tool, installed)
+}
+
+// ResetToolCache forgets every cached tool check
+func ResetToolCache() {
+	installCache.mu.Lock()
+	defer installCache.mu.Unlock()
+	installCache.installed = map[string]bool{}
+}
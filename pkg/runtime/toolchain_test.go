@@ -0,0 +1,97 @@
+package runtime
+
+import "testing"
+
+func TestExecStartCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		runCommand string
+		expected   string
+	}{
+		{"bun run start", "bun run start", "/home/deploy/.bun/bin/bun run start"},
+		{"bun start (no run)", "bun start", "/home/deploy/.bun/bin/bun start"},
+		{"npm start", "npm start", "/usr/bin/npm start"},
+		{"npm run dev", "npm run dev", "/usr/bin/npm run dev"},
+		{"pnpm start", "pnpm start", "/usr/local/bin/pnpm start"},
+		{"yarn start", "yarn start", "/usr/local/bin/yarn start"},
+		{"unknown tool", "deno run main.ts", "deno run main.ts"},
+		{"interpreter left alone", "node server.js", "node server.js"},
+		{"tool in middle of command", "echo bun is great", "echo bun is great"},
+		{"absolute path", "/usr/bin/node server.js", "/usr/bin/node server.js"},
+		{"npm with multiple spaces", "npm  start", "/usr/bin/npm  start"},
+		{"no arguments", "npm", "npm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExecStartCommand(tt.runCommand); got != tt.expected {
+				t.Errorf("ExecStartCommand(%q) = %q, want %q", tt.runCommand, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildPathPrefix(t *testing.T) {
+	jsBase := `export PATH="/usr/bin:$PATH" && export NODE="/usr/bin/node" && hash -r && `
+
+	tests := []struct {
+		language       string
+		packageManager string
+		runtimeVersion string
+		expected       string
+	}{
+		{"JavaScript/TypeScript", "npm", "", jsBase},
+		{"JavaScript/TypeScript", "bun", "", jsBase + `export PATH="$HOME/.bun/bin:$PATH" && `},
+		{"JavaScript/TypeScript", "pnpm", "", jsBase + `export PNPM_HOME="$HOME/.local/share/pnpm" && export PATH="$PNPM_HOME:$PATH" && `},
+		{"JavaScript/TypeScript", "npm", "lts/*", jsBase},
+		{"JavaScript/TypeScript", "npm", "22", `export PATH="/opt/lightfold/node/22/bin:/usr/bin:$PATH" && export NODE="$(command -v node)" && hash -r && `},
+		{"Python", "uv", "3.12", `export PATH="$HOME/.local/bin:$PATH" && `},
+		{"Python", "pip", "", ""},
+		{"Go", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := BuildPathPrefix(tt.language, tt.packageManager, tt.runtimeVersion); got != tt.expected {
+			t.Errorf("BuildPathPrefix(%q, %q, %q) = %q, want %q", tt.language, tt.packageManager, tt.runtimeVersion, got, tt.expected)
+		}
+	}
+}
+
+func TestEnsureToolCommand(t *testing.T) {
+	ResetToolCache()
+	t.Cleanup(ResetToolCache)
+
+	bunExports := `export PATH="$HOME/.bun/bin:$PATH" && `
+	wrapped := bunExports + "(command -v bun >/dev/null 2>&1 || curl -fsSL https://bun.sh/install | bash) && bun install"
+
+	if got := EnsureToolCommand("203.0.113.10", "bun install", ""); got != wrapped {
+		t.Errorf("EnsureToolCommand() = %q, want %q", got, wrapped)
+	}
+	if got := EnsureToolCommand("203.0.113.10", "bun install", bunExports); got != wrapped[len(bunExports):] {
+		t.Errorf("EnsureToolCommand() with exports in prefix = %q, want exports not repeated", got)
+	}
+	if got := EnsureToolCommand("203.0.113.10", "npm ci && npm run build", ""); got != "npm ci && npm run build" {
+		t.Errorf("EnsureToolCommand() = %q, want npm commands unchanged", got)
+	}
+	if got := EnsureToolCommand("203.0.113.10", "echo bun", ""); got != "echo bun" {
+		t.Errorf("EnsureToolCommand() = %q, want tool outside command position unchanged", got)
+	}
+
+	RecordToolInstalled("203.0.113.10", "bun", true)
+	if got := EnsureToolCommand("203.0.113.10", "bun install", ""); got != "bun install" {
+		t.Errorf("EnsureToolCommand() after install = %q, want %q", got, "bun install")
+	}
+	if got := EnsureToolCommand("203.0.113.11", "bun install", ""); got != wrapped {
+		t.Errorf("EnsureToolCommand() on another server = %q, want %q", got, wrapped)
+	}
+
+	RecordToolInstalled("", "bun", true)
+	if _, known := ToolInstalled("", "bun"); known {
+		t.Error("checks without a server should not be cached")
+	}
+
+	ResetToolCache()
+	if got := EnsureToolCommand("203.0.113.10", "bun install", ""); got != wrapped {
+		t.Errorf("EnsureToolCommand() after reset = %q, want %q", got, wrapped)
+	}
+}
@@ -11,6 +11,7 @@ const (
 	RuntimeRuby    Runtime = "ruby"   // Ruby
 	RuntimeJava    Runtime = "java"   // Java
 	RuntimeDocker  Runtime = "docker" // Docker + Docker Compose V2
+	RuntimeHugo    Runtime = "hugo"   // Hugo static site generator (release binary)
 	RuntimeUnknown Runtime = "unknown"
 )
 
@@ -44,6 +45,15 @@ func GetRuntimeFromLanguage(language string) Runtime {
 	}
 }
 
+// GetRuntimeForDetection maps a detected language and framework to its runtime.
+// Frameworks that ship their own toolchain (e.g. Hugo) take precedence over the language.
+func GetRuntimeForDetection(language, framework string) Runtime {
+	if framework == "Hugo" {
+		return RuntimeHugo
+	}
+	return GetRuntimeFromLanguage(language)
+}
+
 // GetRuntimeInfo returns cleanup information for a specific runtime
 func GetRuntimeInfo(rt Runtime) RuntimeInfo {
 	switch rt {
@@ -62,6 +72,7 @@ func GetRuntimeInfo(rt Runtime) RuntimeInfo {
 				"/home/deploy/.bun",
 				"/home/deploy/.npm",
 				"/home/deploy/.local/share/pnpm",
+				NodeVersionsDir,
 			},
 			Commands: []string{
 				"rm -f /usr/bin/node /usr/bin/npm /usr/bin/npx",
@@ -136,6 +147,16 @@ func GetRuntimeInfo(rt Runtime) RuntimeInfo {
 			Commands:    []string{},
 		}
 
+	case RuntimeHugo:
+		return RuntimeInfo{
+			Runtime: RuntimeHugo,
+			Packages: []string{
+				"hugo",
+			},
+			Directories: []string{},
+			Commands:    []string{},
+		}
+
 	case RuntimeDocker:
 		return RuntimeInfo{
 			Runtime: RuntimeDocker,
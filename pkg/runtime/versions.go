@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NodeDefaultVersion is installed for apps that do not pin a Node.js version
+const NodeDefaultVersion = "v20.11.1"
+
+// NodeVersionsDir holds Node.js versions installed next to the server's
+// default node, one directory per major version, for apps pinning a major
+// the default does not match
+const NodeVersionsDir = "/opt/lightfold/node"
+
+// nodeReleases are the releases installed for a pinned major version
+var nodeReleases = map[int]string{
+	18: "v18.20.4",
+	20: NodeDefaultVersion,
+	22: "v22.11.0",
+}
+
+var (
+	versionMajorPattern = regexp.MustCompile(`^(?:>=|\^|~|=)?\s*v?(\d+)(?:\.[\dx*]+)*$`)
+	exactVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)$`)
+)
+
+// NodeMajor returns the major version a .nvmrc or .node-version pin asks for
+// ("22", "v22.3.0", "^22", "22.x"), or 0 for pins without one such as lts/*
+func NodeMajor(spec string) int {
+	match := versionMajorPattern.FindStringSubmatch(strings.TrimSpace(spec))
+	if match == nil {
+		return 0
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// NodeRelease returns the release to install for a pin: an exact version as
+// written, the known release of its major, or the default when the pin names
+// no major. It fails for a major without a known release.
+func NodeRelease(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	if exactVersionPattern.MatchString(spec) {
+		return "v" + strings.TrimPrefix(spec, "v"), nil
+	}
+	major := NodeMajor(spec)
+	if major == 0 {
+		return NodeDefaultVersion, nil
+	}
+	if release, ok := nodeReleases[major]; ok {
+		return release, nil
+	}
+	return "", fmt.Errorf("no known Node.js %d release; pin an exact version such as v%d.0.0 in .nvmrc", major, major)
+}
+
+// NodeBinDir returns where a side-by-side install of a major version keeps
+// node, npm and npx
+func NodeBinDir(major int) string {
+	return fmt.Sprintf("%s/%d/bin", NodeVersionsDir, major)
+}
+
+// RequiredVersion returns the runtime version an app pins, normalized for
+// comparison: the major version for Node.js, the pin as written otherwise.
+// It is empty when any version will do.
+func RequiredVersion(rt Runtime, spec string) string {
+	if rt == RuntimeNodeJS {
+		if major := NodeMajor(spec); major > 0 {
+			return strconv.Itoa(major)
+		}
+		return ""
+	}
+	return strings.TrimSpace(spec)
+}
+
+// SystemPath is the PATH systemd gives services, which units extend with an
+// app's own runtime directory
+const SystemPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// NodePathDir returns the directory an app pinning spec finds its node in
+// ahead of the default one, or "" for apps using the default node. The
+// directory only exists when the server's default node is another major; PATH
+// falls through to the default otherwise.
+func NodePathDir(spec string) string {
+	if major := NodeMajor(spec); major > 0 {
+		return NodeBinDir(major)
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"lightfold/pkg/state"
+	"strings"
+	"testing"
+)
+
+func TestNodeMajor(t *testing.T) {
+	tests := map[string]int{
+		"22":        22,
+		"v22":       22,
+		"v22.3.0":   22,
+		" 20.11.1 ": 20,
+		"18.x":      18,
+		"^20":       20,
+		">=22":      22,
+		"lts/*":     0,
+		"lts/iron":  0,
+		"node":      0,
+		"":          0,
+	}
+	for spec, want := range tests {
+		if got := NodeMajor(spec); got != want {
+			t.Errorf("NodeMajor(%q) = %d, want %d", spec, got, want)
+		}
+	}
+}
+
+func TestNodeRelease(t *testing.T) {
+	tests := map[string]string{
+		"":        NodeDefaultVersion,
+		"lts/*":   NodeDefaultVersion,
+		"20":      "v20.11.1",
+		"22":      "v22.11.0",
+		"v18":     "v18.20.4",
+		"22.3.0":  "v22.3.0",
+		"v21.7.3": "v21.7.3",
+		"^22.1.0": "v22.11.0",
+	}
+	for spec, want := range tests {
+		if got, err := NodeRelease(spec); err != nil || got != want {
+			t.Errorf("NodeRelease(%q) = %q, %v; want %q", spec, got, err, want)
+		}
+	}
+	if _, err := NodeRelease("23"); err == nil || !strings.Contains(err.Error(), "pin an exact version") {
+		t.Errorf("NodeRelease(23) = %v, want an error asking for an exact version", err)
+	}
+}
+
+func TestRequiredVersion(t *testing.T) {
+	if got := RequiredVersion(RuntimeNodeJS, "v22.3.0"); got != "22" {
+		t.Errorf("RequiredVersion(nodejs, v22.3.0) = %q, want 22", got)
+	}
+	if got := RequiredVersion(RuntimeNodeJS, "lts/*"); got != "" {
+		t.Errorf("RequiredVersion(nodejs, lts/*) = %q, want any", got)
+	}
+	if got := RequiredVersion(RuntimePython, " 3.12 "); got != "3.12" {
+		t.Errorf("RequiredVersion(python, 3.12) = %q", got)
+	}
+}
+
+func TestPruneNodeVersionsCommand(t *testing.T) {
+	got := pruneNodeVersionsCommand([]state.RuntimeUse{
+		{TargetName: "web", Runtime: "nodejs", Version: "20"},
+		{TargetName: "docs", Runtime: "nodejs"},
+		{TargetName: "admin", Runtime: "nodejs", Version: "22"},
+		{TargetName: "api", Runtime: "python", Version: "3"},
+	})
+	if !strings.Contains(got, `case "${dir##*/}" in 20|22) ;; *) rm -rf "$dir"`) {
+		t.Errorf("pruneNodeVersionsCommand() = %q, want 20 and 22 kept", got)
+	}
+
+	if got := pruneNodeVersionsCommand([]state.RuntimeUse{{TargetName: "docs", Runtime: "nodejs"}}); got != "rm -rf /opt/lightfold/node 2>/dev/null || true" {
+		t.Errorf("pruneNodeVersionsCommand() without pins = %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"lightfold/pkg/config"
+	"lightfold/pkg/debuglog"
+	"lightfold/pkg/util"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repository is the GitHub repository lightfold releases are published to
+const Repository = "theognis1002/lightfold-cli"
+
+// DisableEnv turns off release checks when set, for machines without access
+// to GitHub
+const DisableEnv = "LIGHTFOLD_NO_UPDATE_CHECK"
+
+// CheckTimeout bounds a release check so it never holds up a command
+const CheckTimeout = 2 * time.Second
+
+// CacheTTL is how long the result of a release check is reused
+const CacheTTL = 24 * time.Hour
+
+// cacheFileName is the file in ~/.lightfold caching the latest release
+const cacheFileName = "update-check.json"
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Release is a published lightfold release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Version returns the release's version without the leading v
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// NewerThan reports whether the release is newer than version
+func (r *Release) NewerThan(version string) bool {
+	return util.CompareVersions(r.Version(), version) > 0
+}
+
+// Asset returns the release's asset with the given name
+func (r *Release) Asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Disabled reports whether release checks are turned off with DisableEnv
+func Disabled() bool {
+	return os.Getenv(DisableEnv) != ""
+}
+
+// cachedRelease is the cache file's contents
+type cachedRelease struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Release   Release   `json:"release"`
+}
+
+// Checker looks up the latest release on GitHub
+type Checker struct {
+	APIURL    string
+	Client    *http.Client
+	CachePath string // Empty disables the cache
+	Now       func() time.Time
+}
+
+// NewChecker returns a checker for the lightfold repository that caches its
+// result in ~/.lightfold
+func NewChecker() *Checker {
+	cachePath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		cachePath = filepath.Join(home, config.LocalConfigDir, cacheFileName)
+	}
+	return &Checker{
+		APIURL:    "https://api.github.com",
+		Client:    debuglog.HTTPClient("github"),
+		CachePath: cachePath,
+		Now:       time.Now,
+	}
+}
+
+// Latest returns the latest release. With useCache, a result fetched within
+// CacheTTL is returned without contacting GitHub.
+func (c *Checker) Latest(ctx context.Context, useCache bool) (*Release, error) {
+	if useCache {
+		if release, ok := c.cached(); ok {
+			return release, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(c.APIURL, "/"), Repository)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for a new release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for a new release: GitHub returned %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if release.Tag == "" {
+		return nil, fmt.Errorf("failed to parse release: no tag")
+	}
+
+	c.store(&release)
+	return &release, nil
+}
+
+func (c *Checker) cached() (*Release, bool) {
+	if c.CachePath == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.CachePath)
+	if err != nil {
+		return nil, false
+	}
+	var cache cachedRelease
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Release.Tag == "" {
+		return nil, false
+	}
+	if age := c.Now().Sub(cache.CheckedAt); age < 0 || age >= CacheTTL {
+		return nil, false
+	}
+	return &cache.Release, true
+}
+
+// store caches a release; a cache that cannot be written only costs a fetch
+func (c *Checker) store(release *Release) {
+	if c.CachePath == "" {
+		return
+	}
+	data, err := json.Marshal(cachedRelease{CheckedAt: c.Now(), Release: *release})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.CachePath), config.PermDirectory); err != nil {
+		return
+	}
+	os.WriteFile(c.CachePath, data, config.PermConfigFile)
+}
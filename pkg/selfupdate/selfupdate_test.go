@@ -0,0 +1,187 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tarGz(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// releaseServer serves a latest release with one linux/amd64 archive. The
+// checksum listed for the archive is the one given, or the real one when empty.
+func releaseServer(t *testing.T, binary []byte, checksum string) (*httptest.Server, *int32) {
+	t.Helper()
+	archiveName := ArchiveName("1.2.0", "linux", "amd64")
+	archive := tarGz(t, "lightfold", binary)
+	if checksum == "" {
+		sum := sha256.Sum256(archive)
+		checksum = hex.EncodeToString(sum[:])
+	}
+
+	var releaseCalls int32
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/repos/"+Repository+"/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&releaseCalls, 1)
+		json.NewEncoder(w).Encode(Release{
+			Tag: "v1.2.0",
+			URL: "https://github.com/" + Repository + "/releases/tag/v1.2.0",
+			Assets: []Asset{
+				{Name: archiveName, URL: server.URL + "/download/" + archiveName},
+				{Name: ChecksumsAsset, URL: server.URL + "/download/" + ChecksumsAsset},
+			},
+		})
+	})
+	mux.HandleFunc("/download/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	mux.HandleFunc("/download/"+ChecksumsAsset, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n%s  lightfold_1.2.0_Darwin_arm64.tar.gz\n", checksum, archiveName, strings.Repeat("0", 64))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &releaseCalls
+}
+
+func newTestChecker(server *httptest.Server, cachePath string, now time.Time) *Checker {
+	return &Checker{
+		APIURL:    server.URL,
+		Client:    server.Client(),
+		CachePath: cachePath,
+		Now:       func() time.Time { return now },
+	}
+}
+
+func TestLatestCachesForADay(t *testing.T) {
+	server, calls := releaseServer(t, []byte("binary"), "")
+	cachePath := filepath.Join(t.TempDir(), cacheFileName)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	release, err := newTestChecker(server, cachePath, now).Latest(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+	if release.Version() != "1.2.0" || !release.NewerThan("1.1.9") || release.NewerThan("1.2.0") {
+		t.Errorf("release = %+v", release)
+	}
+
+	if _, err := newTestChecker(server, cachePath, now.Add(CacheTTL-time.Minute)).Latest(context.Background(), true); err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("release fetched %d times within the TTL, want 1", got)
+	}
+
+	newTestChecker(server, cachePath, now.Add(CacheTTL+time.Minute)).Latest(context.Background(), true)
+	newTestChecker(server, cachePath, now).Latest(context.Background(), false)
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Errorf("release fetched %d times after expiry and without the cache, want 3", got)
+	}
+}
+
+func TestDownloadBinary(t *testing.T) {
+	server, _ := releaseServer(t, []byte("new lightfold"), "")
+	checker := newTestChecker(server, "", time.Now())
+	release, err := checker.Latest(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+
+	binary, err := checker.DownloadBinary(context.Background(), release, "linux", "amd64")
+	if err != nil {
+		t.Fatalf("DownloadBinary() error: %v", err)
+	}
+	if string(binary) != "new lightfold" {
+		t.Errorf("binary = %q", binary)
+	}
+
+	if _, err := checker.DownloadBinary(context.Background(), release, "freebsd", "amd64"); err == nil || !strings.Contains(err.Error(), "no build for freebsd/amd64") {
+		t.Errorf("DownloadBinary() for a missing platform = %v", err)
+	}
+}
+
+func TestDownloadBinaryChecksumMismatch(t *testing.T) {
+	server, _ := releaseServer(t, []byte("tampered"), strings.Repeat("a", 64))
+	checker := newTestChecker(server, "", time.Now())
+	release, err := checker.Latest(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+
+	if _, err := checker.DownloadBinary(context.Background(), release, "linux", "amd64"); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("DownloadBinary() = %v, want a checksum mismatch", err)
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		goos, goarch, want string
+	}{
+		{"linux", "amd64", "lightfold_1.2.0_Linux_x86_64.tar.gz"},
+		{"darwin", "arm64", "lightfold_1.2.0_Darwin_arm64.tar.gz"},
+		{"windows", "386", "lightfold_1.2.0_Windows_i386.zip"},
+	}
+	for _, tt := range tests {
+		if got := ArchiveName("1.2.0", tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("ArchiveName(%s, %s) = %q, want %q", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	for _, goos := range []string{"linux", "windows"} {
+		t.Run(goos, func(t *testing.T) {
+			exePath := filepath.Join(t.TempDir(), "lightfold")
+			if err := os.WriteFile(exePath, []byte("old"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := replaceExecutable(exePath, []byte("new"), goos); err != nil {
+				t.Fatalf("replaceExecutable() error: %v", err)
+			}
+			data, _ := os.ReadFile(exePath)
+			info, _ := os.Stat(exePath)
+			if string(data) != "new" || info.Mode().Perm() != 0755 {
+				t.Errorf("executable = %q mode %v, want the new binary with mode 0755", data, info.Mode().Perm())
+			}
+
+			old, err := os.ReadFile(OldExecutablePath(exePath))
+			if goos == "windows" && string(old) != "old" {
+				t.Errorf("old executable = %q, %v; want it moved aside", old, err)
+			}
+			if goos != "windows" && err == nil {
+				t.Error("old executable left behind")
+			}
+			entries, _ := os.ReadDir(filepath.Dir(exePath))
+			if want := map[string]int{"linux": 1, "windows": 2}[goos]; len(entries) != want {
+				t.Errorf("%d files next to the executable, want %d", len(entries), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ChecksumsAsset is the release asset listing the SHA-256 of every archive
+const ChecksumsAsset = "checksums.txt"
+
+// maxArchiveSize caps a downloaded archive
+const maxArchiveSize = 200 << 20
+
+// ArchiveName returns the archive a release ships for a platform, following
+// the GoReleaser name template, e.g. lightfold_0.2.0_Linux_x86_64.tar.gz
+func ArchiveName(version, goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	}
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("lightfold_%s_%s_%s.%s", strings.TrimPrefix(version, "v"), strings.ToUpper(goos[:1])+goos[1:], arch, ext)
+}
+
+// parseChecksums reads "<sha256>  <file>" lines
+func parseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	return sums
+}
+
+// DownloadBinary downloads the release's archive for the platform, verifies
+// it against the release checksums and returns the lightfold binary inside
+func (c *Checker) DownloadBinary(ctx context.Context, release *Release, goos, goarch string) ([]byte, error) {
+	archiveName := ArchiveName(release.Version(), goos, goarch)
+	archive, ok := release.Asset(archiveName)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s (%s)", release.Tag, goos, goarch, archiveName)
+	}
+	checksums, ok := release.Asset(ChecksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s to verify the download against", release.Tag, ChecksumsAsset)
+	}
+
+	sumsData, err := c.download(ctx, checksums.URL)
+	if err != nil {
+		return nil, err
+	}
+	want, ok := parseChecksums(sumsData)[archiveName]
+	if !ok {
+		return nil, fmt.Errorf("%s of release %s does not list %s", ChecksumsAsset, release.Tag, archiveName)
+	}
+
+	data, err := c.download(ctx, archive.URL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", archiveName, got, want)
+	}
+
+	return extractBinary(archiveName, data)
+}
+
+func (c *Checker) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path.Base(url), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", path.Base(url), resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", path.Base(url), err)
+	}
+	if len(data) > maxArchiveSize {
+		return nil, fmt.Errorf("failed to download %s: larger than %d MB", path.Base(url), maxArchiveSize>>20)
+	}
+	return data, nil
+}
+
+func isBinaryName(name string) bool {
+	base := path.Base(name)
+	return base == "lightfold" || base == "lightfold.exe"
+}
+
+// extractBinary returns the lightfold executable from a .tar.gz or .zip archive
+func extractBinary(archiveName string, data []byte) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", archiveName, err)
+		}
+		for _, file := range reader.File {
+			if !isBinaryName(file.Name) {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %w", file.Name, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, fmt.Errorf("%s does not contain a lightfold binary", archiveName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", archiveName, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s does not contain a lightfold binary", archiveName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archiveName, err)
+		}
+		if header.Typeflag == tar.TypeReg && isBinaryName(header.Name) {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// OldExecutablePath is where an update on Windows moves the replaced
+// executable until the next run removes it
+func OldExecutablePath(exePath string) string {
+	return exePath + ".old"
+}
+
+// ReplaceExecutable atomically replaces the executable at exePath with
+// binary, keeping its permissions
+func ReplaceExecutable(exePath string, binary []byte) error {
+	return replaceExecutable(exePath, binary, runtime.GOOS)
+}
+
+func replaceExecutable(exePath string, binary []byte, goos string) error {
+	info, err := os.Stat(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to read current executable: %w", err)
+	}
+
+	// The new binary is written next to the old one so the rename stays on
+	// one filesystem and is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(exePath), ".lightfold-update-*")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("no permission to write to %s; rerun with sudo or reinstall lightfold somewhere you own", filepath.Dir(exePath))
+		}
+		return fmt.Errorf("failed to stage update: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, writeErr := tmp.Write(binary)
+	closeErr := tmp.Close()
+	if err := errors.Join(writeErr, closeErr, os.Chmod(tmpPath, info.Mode().Perm())); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to stage update: %w", err)
+	}
+
+	if goos != "windows" {
+		if err := os.Rename(tmpPath, exePath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to replace %s: %w", exePath, err)
+		}
+		return nil
+	}
+
+	// Windows cannot overwrite or delete a running executable, but it can
+	// rename it out of the way
+	oldPath := OldExecutablePath(exePath)
+	os.Remove(oldPath)
+	if err := os.Rename(exePath, oldPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move %s aside: %w", exePath, err)
+	}
+	if err := os.Rename(tmpPath, exePath); err != nil {
+		os.Rename(oldPath, exePath)
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", exePath, err)
+	}
+	return nil
+}
+
+// RemoveReplacedExecutable deletes the executable an update on Windows left
+// behind, once it is no longer running
+func RemoveReplacedExecutable() {
+	if runtime.GOOS != "windows" {
+		return
+	}
+	if exePath, err := os.Executable(); err == nil {
+		os.Remove(OldExecutablePath(exePath))
+	}
+}
@@ -0,0 +1,124 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RemoteFile describes a file installed on the server with InstallFile
+type RemoteFile struct {
+	Path  string
+	Mode  os.FileMode
+	Owner string // user:group; empty leaves the owner to install (root)
+	// Backup keeps the replaced version at BackupPath(Path) for RestoreBackup
+	Backup bool
+}
+
+// StagingPath is where a new version of path is assembled before it is renamed
+// into place. It sits next to path so the rename never crosses filesystems and
+// stays atomic.
+func StagingPath(path string) string {
+	return path + ".lightfold-new"
+}
+
+// BackupPath is where the version of path replaced by the last install is kept
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// UploadPath is the /tmp file the content of path is uploaded to before a
+// privileged install. The lightfold- prefix lets the stale temp file sweep
+// clean it up after an interrupted install.
+func UploadPath(path string) string {
+	return "/tmp/lightfold-upload" + strings.ReplaceAll(path, "/", "-")
+}
+
+// InstallFileCommand moves an uploaded file into place: it is copied next to
+// the destination, synced to disk, the current version is backed up if asked
+// for, and the copy is renamed over the destination. An interruption at any
+// point leaves either the old or the new file, never a truncated one.
+func InstallFileCommand(f RemoteFile, uploadPath string) string {
+	staging := StagingPath(f.Path)
+
+	install := fmt.Sprintf("install -m %04o", f.Mode.Perm())
+	if user, group, ok := strings.Cut(f.Owner, ":"); ok {
+		install += fmt.Sprintf(" -o %s -g %s", user, group)
+	}
+
+	steps := []string{
+		fmt.Sprintf("%s %s %s", install, uploadPath, staging),
+		"rm -f " + uploadPath,
+		"sync " + staging,
+	}
+	if f.Backup {
+		steps = append(steps, fmt.Sprintf("{ test ! -e %[1]s || cp -pf %[1]s %[2]s; }", f.Path, BackupPath(f.Path)))
+	}
+	steps = append(steps, fmt.Sprintf("mv -Tf %s %s", staging, f.Path))
+	return strings.Join(steps, " && ")
+}
+
+// RenameIntoPlaceCommand syncs a file written next to path and renames it over path
+func RenameIntoPlaceCommand(stagingPath, path string) string {
+	return fmt.Sprintf("sync %s && mv -Tf %s %s", stagingPath, stagingPath, path)
+}
+
+// RestoreBackupCommand puts the backup of path back in place, atomically like
+// an install. The backup is kept so a restore can be repeated.
+func RestoreBackupCommand(path string) string {
+	backup := BackupPath(path)
+	staging := StagingPath(path)
+	return fmt.Sprintf("test -e %s && cp -pf %s %s && %s", backup, backup, staging, RenameIntoPlaceCommand(staging, path))
+}
+
+// InstallFile writes content to a root-owned location on the server, see
+// InstallFileCommand
+func (e *Executor) InstallFile(f RemoteFile, content string) error {
+	uploadPath := UploadPath(f.Path)
+	if err := e.WriteRemoteFile(uploadPath, content, f.Mode); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", f.Path, err)
+	}
+
+	result := e.ExecuteSudo(InstallFileCommand(f, uploadPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		e.ExecuteSudo(fmt.Sprintf("rm -f %s %s", uploadPath, StagingPath(f.Path)))
+		return fmt.Errorf("failed to install %s: %s", f.Path, resultError(result))
+	}
+	return nil
+}
+
+// WriteRemoteFileAtomic writes content to a path the SSH user can write to,
+// renaming it into place so readers never see a partial file
+func (e *Executor) WriteRemoteFileAtomic(remotePath, content string, mode os.FileMode) error {
+	staging := StagingPath(remotePath)
+	if err := e.WriteRemoteFile(staging, content, mode); err != nil {
+		return err
+	}
+
+	result := e.Execute(RenameIntoPlaceCommand(staging, remotePath))
+	if result.Error != nil || result.ExitCode != 0 {
+		e.Execute("rm -f " + staging)
+		return fmt.Errorf("failed to move %s into place: %s", remotePath, resultError(result))
+	}
+	return nil
+}
+
+// RestoreBackup puts back the version of path that the last InstallFile with
+// Backup replaced
+func (e *Executor) RestoreBackup(path string) error {
+	result := e.ExecuteSudo(RestoreBackupCommand(path))
+	if result.Error != nil || result.ExitCode != 0 {
+		if result.Error == nil && strings.TrimSpace(result.Stderr) == "" {
+			return fmt.Errorf("no backup of %s to restore", path)
+		}
+		return fmt.Errorf("failed to restore %s: %s", path, resultError(result))
+	}
+	return nil
+}
+
+func resultError(result *CommandResult) string {
+	if result.Error != nil {
+		return result.Error.Error()
+	}
+	return strings.TrimSpace(result.Stderr)
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import "testing"
+
+func TestInstallFileCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		file RemoteFile
+		want string
+	}{
+		{
+			name: "with backup",
+			file: RemoteFile{Path: "/etc/nginx/sites-available/myapp", Mode: 0644, Owner: "root:root", Backup: true},
+			want: "install -m 0644 -o root -g root /tmp/up /etc/nginx/sites-available/myapp.lightfold-new && " +
+				"rm -f /tmp/up && " +
+				"sync /etc/nginx/sites-available/myapp.lightfold-new && " +
+				"{ test ! -e /etc/nginx/sites-available/myapp || cp -pf /etc/nginx/sites-available/myapp /etc/nginx/sites-available/myapp.bak; } && " +
+				"mv -Tf /etc/nginx/sites-available/myapp.lightfold-new /etc/nginx/sites-available/myapp",
+		},
+		{
+			name: "without backup or owner",
+			file: RemoteFile{Path: "/etc/lightfold/configured", Mode: 0644},
+			want: "install -m 0644 /tmp/up /etc/lightfold/configured.lightfold-new && " +
+				"rm -f /tmp/up && " +
+				"sync /etc/lightfold/configured.lightfold-new && " +
+				"mv -Tf /etc/lightfold/configured.lightfold-new /etc/lightfold/configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InstallFileCommand(tt.file, "/tmp/up"); got != tt.want {
+				t.Errorf("InstallFileCommand() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreBackupCommand(t *testing.T) {
+	want := "test -e /etc/systemd/system/myapp.service.bak && " +
+		"cp -pf /etc/systemd/system/myapp.service.bak /etc/systemd/system/myapp.service.lightfold-new && " +
+		"sync /etc/systemd/system/myapp.service.lightfold-new && " +
+		"mv -Tf /etc/systemd/system/myapp.service.lightfold-new /etc/systemd/system/myapp.service"
+	if got := RestoreBackupCommand("/etc/systemd/system/myapp.service"); got != want {
+		t.Errorf("RestoreBackupCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUploadPath(t *testing.T) {
+	if got := UploadPath("/srv/myapp/shared/env/.env"); got != "/tmp/lightfold-upload-srv-myapp-shared-env-.env" {
+		t.Errorf("UploadPath() = %q", got)
+	}
+}
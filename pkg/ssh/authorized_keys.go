@@ -0,0 +1,166 @@
+package ssh
+
+import (
+	"fmt"
+	"lightfold/pkg/util"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ResolveAuthorizedKey turns an authorized key entry into a public key line.
+// Entries may be literal public keys ("ssh-ed25519 AAAA... user@host") or
+// paths to public key files (e.g. ~/.ssh/teammate.pub).
+func ResolveAuthorizedKey(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", fmt.Errorf("empty SSH public key")
+	}
+
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(entry)); err == nil {
+		return entry, nil
+	}
+
+	keyPath := entry
+	if strings.HasPrefix(keyPath, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		keyPath = filepath.Join(home, keyPath[2:])
+	}
+
+	publicKey, err := LoadPublicKey(keyPath)
+	if err != nil {
+		return "", err
+	}
+
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)); err != nil {
+		return "", fmt.Errorf("invalid SSH public key in %s: %w", entry, err)
+	}
+
+	if strings.Contains(publicKey, "\n") {
+		return "", fmt.Errorf("%s contains more than one public key", entry)
+	}
+
+	return publicKey, nil
+}
+
+// ResolveAuthorizedKeys resolves a list of key entries, dropping duplicates
+func ResolveAuthorizedKeys(entries []string) ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		key, err := ResolveAuthorizedKey(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[authorizedKeyIdentity(key)] {
+			continue
+		}
+		seen[authorizedKeyIdentity(key)] = true
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// authorizedKeyIdentity is the SHA256 fingerprint of an authorized_keys line,
+// so the same key matches whatever its options and comment. Lines that do not
+// parse are their own identity.
+func authorizedKeyIdentity(line string) string {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return strings.TrimSpace(line)
+	}
+	return ssh.FingerprintSHA256(key)
+}
+
+// SameAuthorizedKey reports whether two authorized_keys lines refer to the
+// same key, ignoring options and comments
+func SameAuthorizedKey(a, b string) bool {
+	return authorizedKeyIdentity(a) == authorizedKeyIdentity(b)
+}
+
+// withoutAuthorizedKey returns the lines of an authorized_keys file that are
+// not publicKey, and whether any were
+func withoutAuthorizedKey(content, publicKey string) (kept []string, found bool) {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) != "" && SameAuthorizedKey(line, publicKey) {
+			found = true
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return kept, found
+}
+
+// readAuthorizedKeys returns the remote user's authorized_keys, empty when the
+// file does not exist yet
+func (e *Executor) readAuthorizedKeys() (string, error) {
+	result := e.Execute("cat ~/.ssh/authorized_keys 2>/dev/null || true")
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to read authorized keys: %w", result.Error)
+	}
+	return result.Stdout, nil
+}
+
+// AddAuthorizedKey appends a public key to the remote user's authorized_keys
+// unless the key is already there, with any comment or options
+func (e *Executor) AddAuthorizedKey(publicKey string) error {
+	current, err := e.readAuthorizedKeys()
+	if err != nil {
+		return fmt.Errorf("failed to add authorized key: %w", err)
+	}
+	if _, found := withoutAuthorizedKey(current, publicKey); found {
+		return nil
+	}
+
+	cmd := fmt.Sprintf(
+		"mkdir -p ~/.ssh && chmod 700 ~/.ssh && touch ~/.ssh/authorized_keys && chmod 600 ~/.ssh/authorized_keys && "+
+			"echo %s >> ~/.ssh/authorized_keys",
+		util.ShellQuote(strings.TrimSpace(publicKey)),
+	)
+
+	result := e.Execute(cmd)
+	if result.Error != nil {
+		return fmt.Errorf("failed to add authorized key: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to add authorized key: %s", strings.TrimSpace(result.Stderr))
+	}
+
+	return nil
+}
+
+// RemoveAuthorizedKey removes every line for a public key from the remote
+// user's authorized_keys, whatever their comments or options
+func (e *Executor) RemoveAuthorizedKey(publicKey string) error {
+	current, err := e.readAuthorizedKeys()
+	if err != nil {
+		return fmt.Errorf("failed to remove authorized key: %w", err)
+	}
+	kept, found := withoutAuthorizedKey(current, publicKey)
+	if !found {
+		return nil
+	}
+
+	cmd := fmt.Sprintf(
+		"printf '%%s' %s > ~/.ssh/authorized_keys.lightfold && "+
+			"chmod 600 ~/.ssh/authorized_keys.lightfold && mv ~/.ssh/authorized_keys.lightfold ~/.ssh/authorized_keys",
+		util.ShellQuote(strings.Join(kept, "\n")),
+	)
+
+	result := e.Execute(cmd)
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove authorized key: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to remove authorized key: %s", strings.TrimSpace(result.Stderr))
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testAuthorizedKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("NewPublicKey() error: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestSameAuthorizedKey(t *testing.T) {
+	key := testAuthorizedKey(t)
+	other := testAuthorizedKey(t)
+
+	if !SameAuthorizedKey(key+" alice@laptop", key+" alice@desktop") {
+		t.Error("comments should not change a key's identity")
+	}
+	if !SameAuthorizedKey(`from="10.0.0.0/8",no-pty `+key, key) {
+		t.Error("options should not change a key's identity")
+	}
+	if SameAuthorizedKey(key, other) {
+		t.Error("different keys should not match")
+	}
+}
+
+func TestWithoutAuthorizedKey(t *testing.T) {
+	key := testAuthorizedKey(t)
+	other := testAuthorizedKey(t)
+	content := strings.Join([]string{
+		"# team keys",
+		other + " deploy@ci",
+		key + " alice@laptop",
+		`no-port-forwarding ` + key + " alice@desktop",
+		"",
+	}, "\n")
+
+	kept, found := withoutAuthorizedKey(content, key+" alice")
+	if !found {
+		t.Fatal("the key should be found under another comment")
+	}
+	if got, want := strings.Join(kept, "\n"), "# team keys\n"+other+" deploy@ci\n"; got != want {
+		t.Errorf("kept =\n%q\nwant\n%q", got, want)
+	}
+
+	if _, found := withoutAuthorizedKey(other+"\n", key); found {
+		t.Error("a missing key should not be found")
+	}
+}
+
+func TestResolveAuthorizedKeysDropsDuplicateKeys(t *testing.T) {
+	key := testAuthorizedKey(t)
+	keys, err := ResolveAuthorizedKeys([]string{key + " alice@laptop", key + " alice@desktop"})
+	if err != nil {
+		t.Fatalf("ResolveAuthorizedKeys() error: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != key+" alice@laptop" {
+		t.Errorf("keys = %q, want the first entry only", keys)
+	}
+}
@@ -0,0 +1,197 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"lightfold/pkg/config"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionOptions control how an executor keeps its connection alive and
+// recovers when it drops
+type ConnectionOptions struct {
+	// KeepAliveInterval is how often a keepalive request is sent; 0 disables keepalives
+	KeepAliveInterval time.Duration
+	// KeepAliveMaxMissed is how many unanswered keepalives close the connection
+	KeepAliveMaxMissed int
+	// ReconnectAttempts is how many times a dropped connection is redialed
+	ReconnectAttempts int
+	// ReconnectDelay is the wait after the first failed redial; it doubles on every attempt after
+	ReconnectDelay time.Duration
+}
+
+// DefaultConnectionOptions returns the options used when a target sets none
+func DefaultConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{
+		KeepAliveInterval:  config.DefaultSSHKeepAliveInterval,
+		KeepAliveMaxMissed: config.DefaultSSHKeepAliveMaxMissed,
+		ReconnectAttempts:  config.DefaultSSHReconnectAttempts,
+		ReconnectDelay:     config.DefaultSSHReconnectDelay,
+	}
+}
+
+var defaultConnectionOptions = DefaultConnectionOptions()
+
+// SetDefaultConnectionOptions sets the options of executors created afterwards
+func SetDefaultConnectionOptions(opts ConnectionOptions) {
+	defaultConnectionOptions = opts
+}
+
+// ConnectionLostError reports a command whose connection dropped before it
+// finished. The command may or may not have run to completion on the server.
+type ConnectionLostError struct {
+	Command string
+	Err     error
+}
+
+func (e *ConnectionLostError) Error() string {
+	return fmt.Sprintf("connection lost while running %q: %v", e.Command, e.Err)
+}
+
+func (e *ConnectionLostError) Unwrap() error {
+	return e.Err
+}
+
+// IsConnectionLost reports whether err was caused by a dropped connection
+func IsConnectionLost(err error) bool {
+	var lost *ConnectionLostError
+	return errors.As(err, &lost)
+}
+
+// isTransportError reports whether a session error came from the connection
+// rather than from the command's exit status
+func isTransportError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return false
+	}
+	var missing *ssh.ExitMissingError
+	return errors.As(err, &missing) || errors.Is(err, io.EOF) || isNetError(err)
+}
+
+func isNetError(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr)
+}
+
+// keepAlive sends keepalive requests on client until it closes, and closes it
+// after maxMissed requests in a row go unanswered so commands blocked on a dead
+// connection fail instead of hanging
+func keepAlive(client *ssh.Client, interval time.Duration, maxMissed int) {
+	if interval <= 0 {
+		return
+	}
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		missed := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if sendKeepAlive(client, interval) {
+				missed = 0
+				continue
+			}
+			missed++
+			if missed >= maxMissed {
+				client.Close()
+				return
+			}
+		}
+	}()
+}
+
+// sendKeepAlive sends one keepalive request and reports whether the server
+// answered within timeout
+func sendKeepAlive(client *ssh.Client, timeout time.Duration) bool {
+	replied := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		replied <- err
+	}()
+
+	select {
+	case err := <-replied:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// reconnect replaces a dropped connection. The first redial is immediate; later
+// ones wait ReconnectDelay, doubling the wait after each failed attempt.
+func (e *Executor) reconnect() error {
+	if e.client != nil {
+		if e.pool != nil {
+			e.pool.invalidate(e.poolKey(), e.client)
+		} else {
+			e.client.Close()
+		}
+		e.client = nil
+	}
+
+	delay := e.connOpts.ReconnectDelay
+	var lastErr error
+	for attempt := 0; attempt < e.connOpts.ReconnectAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		client, err := e.dial()
+		if err == nil {
+			e.client = client
+			return nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("reconnecting is disabled")
+	}
+	return fmt.Errorf("failed to reconnect after %d attempts: %w", e.connOpts.ReconnectAttempts, lastErr)
+}
+
+// ExecuteIdempotent runs a command that is safe to run twice. When the
+// connection drops mid-command, it reconnects and runs the command again.
+func (e *Executor) ExecuteIdempotent(command string) *CommandResult {
+	return e.retryOnDrop(func() *CommandResult { return e.Execute(command) })
+}
+
+// ExecuteSudoIdempotent is ExecuteIdempotent with sudo
+func (e *Executor) ExecuteSudoIdempotent(command string) *CommandResult {
+	return e.retryOnDrop(func() *CommandResult { return e.ExecuteSudo(command) })
+}
+
+func (e *Executor) retryOnDrop(run func() *CommandResult) *CommandResult {
+	result := run()
+	for attempt := 0; attempt < e.connOpts.ReconnectAttempts && IsConnectionLost(result.Error); attempt++ {
+		if err := e.reconnect(); err != nil {
+			result.Error = fmt.Errorf("%w (%v)", result.Error, err)
+			return result
+		}
+		result = run()
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"lightfold/pkg/ssh/sshtest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func connectDropping(t *testing.T, dialer *sshtest.Server, opts ConnectionOptions) *Executor {
+	t.Helper()
+	UsePool(NewPool(dialer.Dial))
+	t.Cleanup(func() { ClosePool() })
+
+	exec := NewExecutor("203.0.113.10", "22", "deploy", writeTestKey(t))
+	exec.SetConnectionOptions(opts)
+	if err := exec.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	return exec
+}
+
+func testConnectionOptions() ConnectionOptions {
+	return ConnectionOptions{ReconnectAttempts: 3, ReconnectDelay: time.Millisecond}
+}
+
+func TestExecuteIdempotentRerunsAfterDrop(t *testing.T) {
+	dialer := &sshtest.Server{DropCount: 1}
+	exec := connectDropping(t, dialer, testConnectionOptions())
+
+	result := exec.ExecuteIdempotent("mkdir -p /srv/app")
+	if result.Error != nil || result.ExitCode != 0 {
+		t.Fatalf("ExecuteIdempotent() = %+v, want success after reconnect", result)
+	}
+	if got := dialer.Count("mkdir -p /srv/app"); got != 2 {
+		t.Errorf("command ran %d times, want 2", got)
+	}
+	if dialer.Dials() != 2 {
+		t.Errorf("dials = %d, want one reconnect", dialer.Dials())
+	}
+}
+
+func TestExecuteDoesNotRerunAfterDrop(t *testing.T) {
+	dialer := &sshtest.Server{DropCount: 1}
+	exec := connectDropping(t, dialer, testConnectionOptions())
+
+	result := exec.Execute("npm run build")
+	if !IsConnectionLost(result.Error) {
+		t.Fatalf("Execute() error = %v, want a connection lost error", result.Error)
+	}
+	if got := dialer.Count("npm run build"); got != 1 {
+		t.Errorf("command ran %d times, want 1", got)
+	}
+
+	// The next command reconnects on its own
+	if result := exec.Execute("true"); result.Error != nil {
+		t.Fatalf("Execute() after drop error: %v", result.Error)
+	}
+	if dialer.Dials() != 2 {
+		t.Errorf("dials = %d, want one reconnect", dialer.Dials())
+	}
+}
+
+func TestExecuteIdempotentGivesUpAfterAttempts(t *testing.T) {
+	dialer := &sshtest.Server{DropCount: 10}
+	exec := connectDropping(t, dialer, ConnectionOptions{ReconnectAttempts: 2, ReconnectDelay: time.Millisecond})
+
+	result := exec.ExecuteIdempotent("chown -R deploy:deploy /srv/app")
+	if !IsConnectionLost(result.Error) {
+		t.Fatalf("ExecuteIdempotent() error = %v, want a connection lost error", result.Error)
+	}
+	if got := dialer.Count("chown -R deploy:deploy /srv/app"); got != 3 {
+		t.Errorf("command ran %d times, want 3", got)
+	}
+}
+
+func TestKeepAliveClosesDeadConnection(t *testing.T) {
+	dialer := &sshtest.Server{IgnoreKeepAlive: true}
+	opts := testConnectionOptions()
+	opts.KeepAliveInterval = 10 * time.Millisecond
+	opts.KeepAliveMaxMissed = 2
+	exec := connectDropping(t, dialer, opts)
+
+	closed := make(chan struct{})
+	go func() {
+		exec.client.Wait()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection was not closed after missed keepalives")
+	}
+}
+
+func TestIsTransportError(t *testing.T) {
+	if isTransportError(nil) {
+		t.Error("nil should not be a transport error")
+	}
+	if isTransportError(&ssh.ExitError{}) {
+		t.Error("exit status should not be a transport error")
+	}
+	if !isTransportError(&ssh.ExitMissingError{}) {
+		t.Error("missing exit status should be a transport error")
+	}
+}
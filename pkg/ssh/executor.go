@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"lightfold/pkg/config"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,7 +19,46 @@ type Executor struct {
 	Port       string
 	Username   string
 	SSHKeyPath string
-	client     *ssh.Client
+	// ProxyJump lists the hosts to reach the server through, as in ssh_config
+	ProxyJump string
+	client    *ssh.Client
+	// sudoPassword is kept in memory for the session only and piped to sudo -S
+	sudoPassword string
+	// privilege is detected on the first privileged command; see Privilege
+	privilege        Privilege
+	privilegeChecked bool
+	traceHook        TraceHook
+	// pool shares the connection with other executors of the same command
+	pool     *Pool
+	connOpts ConnectionOptions
+	// dialTimeout bounds opening the TCP connection; zero uses config.DefaultSSHTimeout
+	dialTimeout time.Duration
+}
+
+// CommandTrace describes a finished remote command. Command is not redacted;
+// hooks must redact it before recording it anywhere.
+type CommandTrace struct {
+	Host     string
+	Command  string
+	Duration time.Duration
+	ExitCode int
+	Err      error
+}
+
+// TraceHook is called after every remote command
+type TraceHook func(CommandTrace)
+
+var defaultTraceHook TraceHook
+
+// SetDefaultTraceHook installs a trace hook on executors created afterwards
+func SetDefaultTraceHook(hook TraceHook) {
+	defaultTraceHook = hook
+}
+
+// Address joins a host and port for dialing. IPv6 literals are bracketed, and
+// hosts that already carry brackets are accepted.
+func Address(host, port string) string {
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), port)
 }
 
 func NewExecutor(host, port, username, sshKeyPath string) *Executor {
@@ -26,11 +66,43 @@ func NewExecutor(host, port, username, sshKeyPath string) *Executor {
 		port = config.DefaultSSHPort
 	}
 	return &Executor{
-		Host:       host,
+		Host:       strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"),
 		Port:       port,
 		Username:   username,
 		SSHKeyPath: sshKeyPath,
+		traceHook:  defaultTraceHook,
+		pool:       currentPool(),
+		connOpts:   defaultConnectionOptions,
+	}
+}
+
+// NewExecutorFromConfig creates an executor for a provider config's server.
+// Configs using an ssh_config Host alias also supply the port and ProxyJump.
+func NewExecutorFromConfig(pc config.ProviderConfig) *Executor {
+	e := NewExecutor(pc.GetIP(), config.DefaultSSHPort, pc.GetUsername(), pc.GetSSHKey())
+	if hostCfg, ok := pc.(config.SSHHostConfig); ok && hostCfg.GetSSHHost() != "" {
+		if port := hostCfg.GetSSHPort(); port != "" {
+			e.Port = port
+		}
+		e.ProxyJump = hostCfg.GetProxyJump()
 	}
+	return e
+}
+
+// SetConnectionOptions sets the keepalive and reconnect behaviour of this executor
+func (e *Executor) SetConnectionOptions(opts ConnectionOptions) {
+	e.connOpts = opts
+}
+
+// SetDialTimeout bounds how long opening a connection may take, for callers
+// that would rather report a server as unreachable than wait on it
+func (e *Executor) SetDialTimeout(timeout time.Duration) {
+	e.dialTimeout = timeout
+}
+
+// SetTraceHook sets the hook called after every command on this executor
+func (e *Executor) SetTraceHook(hook TraceHook) {
+	e.traceHook = hook
 }
 
 func (e *Executor) Connect(retries int, retryDelay time.Duration) error {
@@ -41,56 +113,119 @@ func (e *Executor) Connect(retries int, retryDelay time.Duration) error {
 			time.Sleep(retryDelay)
 		}
 
-		keyPath := e.SSHKeyPath
-		if strings.HasPrefix(keyPath, "~/") {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				lastErr = fmt.Errorf("failed to get home directory: %w", err)
-				continue
-			}
-			keyPath = filepath.Join(home, keyPath[2:])
-		}
-
-		keyBytes, err := os.ReadFile(keyPath)
+		client, err := e.dial()
 		if err != nil {
-			lastErr = fmt.Errorf("failed to read SSH key: %w", err)
+			lastErr = fmt.Errorf("failed to connect to SSH server (attempt %d/%d): %w", attempt+1, retries+1, err)
 			continue
 		}
 
-		signer, err := ssh.ParsePrivateKey(keyBytes)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to parse SSH key: %w", err)
-			continue
-		}
+		e.client = client
+		return nil
+	}
 
-		config := &ssh.ClientConfig{
-			User: e.Username,
-			Auth: []ssh.AuthMethod{
-				ssh.PublicKeys(signer),
-			},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			Timeout:         config.DefaultSSHTimeout,
-		}
+	return fmt.Errorf("failed to connect after %d attempts: %w", retries+1, lastErr)
+}
+
+// dial opens a connection, or takes the shared one from the pool, and starts
+// keepalives on connections it opens
+func (e *Executor) dial() (*ssh.Client, error) {
+	addr := Address(e.Host, e.Port)
+	startKeepAlive := func(client *ssh.Client) {
+		keepAlive(client, e.connOpts.KeepAliveInterval, e.connOpts.KeepAliveMaxMissed)
+	}
+
+	if e.pool != nil {
+		return e.pool.get(e.poolKey(), addr, e.clientConfig, e.jumpDialer(), startKeepAlive)
+	}
+
+	client, err := e.Dial()
+	if err != nil {
+		return nil, err
+	}
+	startKeepAlive(client)
+	return client, nil
+}
+
+// Dial opens a connection of its own, outside any pool, for callers that
+// drive sessions themselves such as an interactive shell
+func (e *Executor) Dial() (*ssh.Client, error) {
+	cfg, err := e.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	dial := ssh.Dial
+	if jump := e.jumpDialer(); jump != nil {
+		dial = jump
+	}
+	return dial("tcp", Address(e.Host, e.Port), cfg)
+}
+
+func (e *Executor) clientConfig() (*ssh.ClientConfig, error) {
+	cfg, err := clientConfigFor(e.Username, e.SSHKeyPath)
+	if err == nil && e.dialTimeout > 0 {
+		cfg.Timeout = e.dialTimeout
+	}
+	return cfg, err
+}
 
-		addr := fmt.Sprintf("%s:%s", e.Host, e.Port)
-		client, err := ssh.Dial("tcp", addr, config)
+func clientConfigFor(username, keyPath string) (*ssh.ClientConfig, error) {
+	if strings.HasPrefix(keyPath, "~/") {
+		home, err := os.UserHomeDir()
 		if err != nil {
-			lastErr = fmt.Errorf("failed to connect to SSH server (attempt %d/%d): %w", attempt+1, retries+1, err)
-			continue
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
 		}
+		keyPath = filepath.Join(home, keyPath[2:])
+	}
 
-		e.client = client
-		return nil
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
 	}
 
-	return fmt.Errorf("failed to connect after %d attempts: %w", retries+1, lastErr)
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+
+	return &ssh.ClientConfig{
+		User: username,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signer),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         config.DefaultSSHTimeout,
+	}, nil
+}
+
+func (e *Executor) poolKey() string {
+	return fmt.Sprintf("%s@%s:%s|%s|%s", e.Username, e.Host, e.Port, e.SSHKeyPath, e.ProxyJump)
 }
 
+// Disconnect closes the connection. Pooled connections stay open for other
+// executors of the command and are closed by the pool.
 func (e *Executor) Disconnect() error {
-	if e.client != nil {
-		return e.client.Close()
+	if e.client == nil {
+		return nil
 	}
-	return nil
+	if e.pool != nil {
+		e.client = nil
+		return nil
+	}
+	return e.client.Close()
+}
+
+// newSession opens a session, reconnecting with backoff when the connection
+// has dropped. No command has started yet, so this is safe for every command.
+func (e *Executor) newSession() (*ssh.Session, error) {
+	session, err := e.client.NewSession()
+	if err == nil {
+		return session, nil
+	}
+
+	if reconnectErr := e.reconnect(); reconnectErr != nil {
+		return nil, fmt.Errorf("%w (%v)", err, reconnectErr)
+	}
+	return e.client.NewSession()
 }
 
 type CommandResult struct {
@@ -105,13 +240,34 @@ func (e *Executor) Execute(command string) *CommandResult {
 }
 
 func (e *Executor) ExecuteWithStreaming(command string, stdoutWriter, stderrWriter io.Writer) *CommandResult {
+	return e.executeWithInput(command, nil, stdoutWriter, stderrWriter)
+}
+
+func (e *Executor) executeWithInput(command string, stdin io.Reader, stdoutWriter, stderrWriter io.Writer) *CommandResult {
+	if e.traceHook == nil {
+		return e.run(command, stdin, stdoutWriter, stderrWriter)
+	}
+
+	start := time.Now()
+	result := e.run(command, stdin, stdoutWriter, stderrWriter)
+	e.traceHook(CommandTrace{
+		Host:     e.Host,
+		Command:  command,
+		Duration: time.Since(start),
+		ExitCode: result.ExitCode,
+		Err:      result.Error,
+	})
+	return result
+}
+
+func (e *Executor) run(command string, stdin io.Reader, stdoutWriter, stderrWriter io.Writer) *CommandResult {
 	if e.client == nil {
 		return &CommandResult{
 			Error: fmt.Errorf("not connected to SSH server"),
 		}
 	}
 
-	session, err := e.client.NewSession()
+	session, err := e.newSession()
 	if err != nil {
 		return &CommandResult{
 			Error: fmt.Errorf("failed to create session: %w", err),
@@ -121,6 +277,10 @@ func (e *Executor) ExecuteWithStreaming(command string, stdoutWriter, stderrWrit
 
 	var stdoutBuf, stderrBuf bytes.Buffer
 
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+
 	if stdoutWriter != nil {
 		session.Stdout = io.MultiWriter(&stdoutBuf, stdoutWriter)
 	} else {
@@ -143,6 +303,8 @@ func (e *Executor) ExecuteWithStreaming(command string, stdoutWriter, stderrWrit
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
 			result.ExitCode = exitErr.ExitStatus()
+		} else if isTransportError(err) {
+			result.Error = &ConnectionLostError{Command: command, Err: err}
 		} else {
 			result.Error = err
 		}
@@ -152,13 +314,57 @@ func (e *Executor) ExecuteWithStreaming(command string, stdoutWriter, stderrWrit
 }
 
 func (e *Executor) ExecuteSudo(command string) *CommandResult {
-	sudoCommand := fmt.Sprintf("sudo -n %s", command)
-	return e.Execute(sudoCommand)
+	return e.ExecuteSudoWithStreaming(command, nil, nil)
 }
 
+// ExecuteSudoWithStreaming runs command with root privileges: directly when
+// connected as root, through sudo otherwise
 func (e *Executor) ExecuteSudoWithStreaming(command string, stdoutWriter, stderrWriter io.Writer) *CommandResult {
+	switch e.Privilege() {
+	case PrivilegeRoot:
+		return e.ExecuteWithStreaming(command, stdoutWriter, stderrWriter)
+	case PrivilegeNone:
+		return &CommandResult{Error: e.noPrivilegeError()}
+	}
+
+	if e.sudoPassword == "" {
+		e.sudoPassword = sessionSudoPassword(e.Username, e.Host, false)
+	}
+	if e.sudoPassword != "" {
+		return e.executeSudoWithPassword(command, stdoutWriter, stderrWriter)
+	}
+
 	sudoCommand := fmt.Sprintf("sudo -n %s", command)
-	return e.ExecuteWithStreaming(sudoCommand, stdoutWriter, stderrWriter)
+	result := e.ExecuteWithStreaming(sudoCommand, stdoutWriter, stderrWriter)
+	if !sudoNeedsPassword(result) {
+		return result
+	}
+	// sudo -n refuses before running anything, so the command is run again
+	// once --sudo-password-prompt supplied the password
+	if e.sudoPassword = sessionSudoPassword(e.Username, e.Host, true); e.sudoPassword == "" {
+		return result
+	}
+	return e.executeSudoWithPassword(command, stdoutWriter, stderrWriter)
+}
+
+// executeSudoWithPassword pipes the session's sudo password to sudo -S. A
+// rejected password is forgotten so the next command asks again.
+func (e *Executor) executeSudoWithPassword(command string, stdoutWriter, stderrWriter io.Writer) *CommandResult {
+	sudoCommand := fmt.Sprintf("sudo -S -p '' %s", command)
+	result := e.executeWithInput(sudoCommand, strings.NewReader(e.sudoPassword+"\n"), stdoutWriter, stderrWriter)
+	if sudoPasswordRejected(result) {
+		e.sudoPassword = ""
+		rememberSudoPassword(e.Username, e.Host, "")
+	}
+	return result
+}
+
+// SetSudoPassword makes sudo commands read the given password from stdin
+// instead of requiring passwordless sudo. Other executors for the same user
+// and server in this invocation use it too; it is never persisted.
+func (e *Executor) SetSudoPassword(password string) {
+	e.sudoPassword = password
+	rememberSudoPassword(e.Username, e.Host, password)
 }
 
 func (e *Executor) UploadFile(localPath, remotePath string) error {
@@ -184,7 +390,7 @@ func (e *Executor) UploadBytes(content []byte, remotePath string, mode os.FileMo
 		return fmt.Errorf("not connected to SSH server")
 	}
 
-	session, err := e.client.NewSession()
+	session, err := e.newSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
@@ -193,7 +399,7 @@ func (e *Executor) UploadBytes(content []byte, remotePath string, mode os.FileMo
 	filename := filepath.Base(remotePath)
 	remoteDir := filepath.Dir(remotePath)
 
-	mkdirResult := e.Execute(fmt.Sprintf("mkdir -p %s", remoteDir))
+	mkdirResult := e.ExecuteIdempotent(fmt.Sprintf("mkdir -p %s", remoteDir))
 	if mkdirResult.Error != nil || mkdirResult.ExitCode != 0 {
 		return fmt.Errorf("failed to create remote directory: %w", mkdirResult.Error)
 	}
@@ -248,12 +454,16 @@ func (e *Executor) WriteRemoteFile(remotePath, content string, mode os.FileMode)
 	return e.UploadBytes([]byte(content), remotePath, mode)
 }
 
-func (e *Executor) RenderAndWriteTemplate(template string, data map[string]string, remotePath string, mode os.FileMode) error {
+// RenderTemplate replaces the {{KEY}} placeholders in template with data
+func RenderTemplate(template string, data map[string]string) string {
 	rendered := template
 	for key, value := range data {
 		placeholder := fmt.Sprintf("{{%s}}", key)
 		rendered = strings.ReplaceAll(rendered, placeholder, value)
 	}
+	return rendered
+}
 
-	return e.WriteRemoteFile(remotePath, rendered, mode)
+func (e *Executor) RenderAndWriteTemplate(template string, data map[string]string, remotePath string, mode os.FileMode) error {
+	return e.WriteRemoteFile(remotePath, RenderTemplate(template, data), mode)
 }
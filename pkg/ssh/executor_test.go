@@ -13,6 +13,7 @@ func TestNewExecutor(t *testing.T) {
 	tests := []struct {
 		name     string
 		host     string
+		wantHost string
 		port     string
 		username string
 		keyPath  string
@@ -34,13 +35,25 @@ func TestNewExecutor(t *testing.T) {
 			keyPath:  "~/.ssh/deploy_key",
 			wantPort: "2222",
 		},
+		{
+			name:     "bracketed IPv6 literal",
+			host:     "[2001:db8::1]",
+			wantHost: "2001:db8::1",
+			username: "deploy",
+			keyPath:  "~/.ssh/deploy_key",
+			wantPort: "22",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			exec := NewExecutor(tt.host, tt.port, tt.username, tt.keyPath)
-			if exec.Host != tt.host {
-				t.Errorf("Host = %v, want %v", exec.Host, tt.host)
+			wantHost := tt.wantHost
+			if wantHost == "" {
+				wantHost = tt.host
+			}
+			if exec.Host != wantHost {
+				t.Errorf("Host = %v, want %v", exec.Host, wantHost)
 			}
 			if exec.Port != tt.wantPort {
 				t.Errorf("Port = %v, want %v", exec.Port, tt.wantPort)
@@ -55,6 +68,20 @@ func TestNewExecutor(t *testing.T) {
 	}
 }
 
+func TestAddress(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.10":  "203.0.113.10:22",
+		"example.com":   "example.com:22",
+		"2001:db8::1":   "[2001:db8::1]:22",
+		"[2001:db8::1]": "[2001:db8::1]:22",
+	}
+	for host, want := range tests {
+		if got := Address(host, "22"); got != want {
+			t.Errorf("Address(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
 func TestCommandResult(t *testing.T) {
 	result := &CommandResult{
 		Stdout:   "hello world",
@@ -0,0 +1,195 @@
+package ssh
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Dialer opens an SSH client connection. ssh.Dial is used unless a test replaces it.
+type Dialer func(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error)
+
+// Pool shares one SSH connection per host, user and key among all executors of a
+// command, so the many executors a deploy creates for the same server only dial
+// and authenticate once. Each Execute/Upload still runs in its own session on the
+// shared connection. Executors release the connection on Disconnect; the pool
+// closes it when the command finishes.
+type Pool struct {
+	mu      sync.Mutex
+	dial    Dialer
+	clients map[string]*ssh.Client
+	// pending holds the dials in progress by key, so concurrent gets for a
+	// key share one dial and gets for other keys never wait on it
+	pending map[string]*pendingDial
+	dials   int
+	// privileges caches each connection's detected Privilege
+	privileges map[string]Privilege
+}
+
+// NewPool creates a pool that opens connections with dial (ssh.Dial when nil)
+func NewPool(dial Dialer) *Pool {
+	if dial == nil {
+		dial = ssh.Dial
+	}
+	return &Pool{dial: dial, clients: make(map[string]*ssh.Client), pending: make(map[string]*pendingDial), privileges: make(map[string]Privilege)}
+}
+
+// pendingDial is a dial in progress; done is closed once client or err is set
+type pendingDial struct {
+	done   chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+var (
+	defaultPoolMu sync.Mutex
+	defaultPool   *Pool
+)
+
+// EnablePooling makes executors created afterwards share connections through
+// the default pool, creating it unless pooling is already on. Call ClosePool
+// when the command is done.
+func EnablePooling() *Pool {
+	defaultPoolMu.Lock()
+	defer defaultPoolMu.Unlock()
+	if defaultPool == nil {
+		defaultPool = NewPool(nil)
+	}
+	return defaultPool
+}
+
+// UsePool sets the pool new executors join; nil turns pooling off
+func UsePool(pool *Pool) {
+	defaultPoolMu.Lock()
+	defer defaultPoolMu.Unlock()
+	defaultPool = pool
+}
+
+// ClosePool closes every connection of the default pool and turns pooling off
+func ClosePool() error {
+	defaultPoolMu.Lock()
+	pool := defaultPool
+	defaultPool = nil
+	defaultPoolMu.Unlock()
+
+	if pool == nil {
+		return nil
+	}
+	return pool.Close()
+}
+
+func currentPool() *Pool {
+	defaultPoolMu.Lock()
+	defer defaultPoolMu.Unlock()
+	return defaultPool
+}
+
+// Dials returns how many connections the pool has opened
+func (p *Pool) Dials() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dials
+}
+
+// get returns the live shared connection for key, dialing a new one when there
+// is none or the cached one no longer answers. New connections are opened with
+// via when it is set, and dialed is called with each of them. The dial runs
+// without the pool lock, so a host that does not answer only holds up the
+// gets for its own key, which wait for that dial instead of starting another.
+func (p *Pool) get(key, addr string, clientConfig func() (*ssh.ClientConfig, error), via Dialer, dialed func(*ssh.Client)) (*ssh.Client, error) {
+	var pending *pendingDial
+	for pending == nil {
+		p.mu.Lock()
+		if inFlight := p.pending[key]; inFlight != nil {
+			p.mu.Unlock()
+			<-inFlight.done
+			return inFlight.client, inFlight.err
+		}
+		client, ok := p.clients[key]
+		if !ok {
+			pending = &pendingDial{done: make(chan struct{})}
+			p.pending[key] = pending
+			p.dials++
+		}
+		p.mu.Unlock()
+
+		if ok {
+			if isAlive(client) {
+				return client, nil
+			}
+			p.invalidate(key, client)
+		}
+	}
+
+	pending.client, pending.err = p.dialNew(addr, clientConfig, via)
+
+	p.mu.Lock()
+	delete(p.pending, key)
+	if pending.err == nil {
+		p.clients[key] = pending.client
+	}
+	p.mu.Unlock()
+	if pending.err == nil && dialed != nil {
+		dialed(pending.client)
+	}
+	close(pending.done)
+	return pending.client, pending.err
+}
+
+func (p *Pool) dialNew(addr string, clientConfig func() (*ssh.ClientConfig, error), via Dialer) (*ssh.Client, error) {
+	cfg, err := clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	dial := p.dial
+	if via != nil {
+		dial = via
+	}
+	return dial("tcp", addr, cfg)
+}
+
+// invalidate drops client from the pool after it failed, so the next get redials
+func (p *Pool) invalidate(key string, client *ssh.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cached, ok := p.clients[key]; ok && cached == client {
+		cached.Close()
+		delete(p.clients, key)
+	}
+}
+
+// privilege returns the privilege detected for key's connection
+func (p *Pool) privilege(key string) (Privilege, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	privilege, ok := p.privileges[key]
+	return privilege, ok
+}
+
+func (p *Pool) setPrivilege(key string, privilege Privilege) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.privileges[key] = privilege
+}
+
+// Close closes all pooled connections
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for key, client := range p.clients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close SSH connection %s: %w", key, err)
+		}
+		delete(p.clients, key)
+	}
+	return firstErr
+}
+
+func isAlive(client *ssh.Client) bool {
+	_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
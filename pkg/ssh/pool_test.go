@@ -0,0 +1,143 @@
+package ssh
+
+import (
+	"lightfold/pkg/ssh/sshtest"
+	"testing"
+	"time"
+)
+
+func writeTestKey(t *testing.T) string {
+	return sshtest.WriteKey(t)
+}
+
+func usePooledDialer(t *testing.T) (*sshtest.Server, *Pool) {
+	server := &sshtest.Server{}
+	pool := NewPool(server.Dial)
+	UsePool(pool)
+	t.Cleanup(func() { ClosePool() })
+	return server, pool
+}
+
+func TestPoolReusesConnectionAcrossExecutors(t *testing.T) {
+	dialer, pool := usePooledDialer(t)
+	keyPath := writeTestKey(t)
+
+	for i := 0; i < 5; i++ {
+		exec := NewExecutor("203.0.113.10", "22", "deploy", keyPath)
+		if err := exec.Connect(0, time.Millisecond); err != nil {
+			t.Fatalf("Connect() error: %v", err)
+		}
+		if result := exec.Execute("true"); result.Error != nil || result.ExitCode != 0 {
+			t.Fatalf("Execute() = %+v", result)
+		}
+		if err := exec.WriteRemoteFile("/tmp/file", "content", 0644); err != nil {
+			t.Fatalf("WriteRemoteFile() error: %v", err)
+		}
+		exec.Disconnect()
+	}
+
+	if dialer.Dials() != 1 || pool.Dials() != 1 {
+		t.Errorf("dials = %d, want 1 shared connection", dialer.Dials())
+	}
+
+	other := NewExecutor("203.0.113.11", "22", "deploy", keyPath)
+	if err := other.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	if dialer.Dials() != 2 {
+		t.Errorf("dials = %d, want a separate connection per host", dialer.Dials())
+	}
+}
+
+func TestPoolReconnectsAfterConnectionDrops(t *testing.T) {
+	dialer, _ := usePooledDialer(t)
+	keyPath := writeTestKey(t)
+
+	exec := NewExecutor("203.0.113.10", "22", "deploy", keyPath)
+	if err := exec.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	exec.client.Close()
+
+	if result := exec.Execute("true"); result.Error != nil {
+		t.Fatalf("Execute() after drop error: %v", result.Error)
+	}
+	if dialer.Dials() != 2 {
+		t.Errorf("dials = %d, want one reconnect", dialer.Dials())
+	}
+
+	exec.client.Close()
+	next := NewExecutor("203.0.113.10", "22", "deploy", keyPath)
+	if err := next.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	if dialer.Dials() != 3 {
+		t.Errorf("dials = %d, want a fresh connection instead of the dead one", dialer.Dials())
+	}
+}
+
+func TestPoolDialsOutsideTheLock(t *testing.T) {
+	dialer, pool := usePooledDialer(t)
+	dialer.Unreachable = []string{"203.0.113.99:22"}
+	keyPath := writeTestKey(t)
+
+	hung := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			exec := NewExecutor("203.0.113.99", "22", "deploy", keyPath)
+			exec.SetDialTimeout(500 * time.Millisecond)
+			hung <- exec.Connect(0, 0)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	exec := NewExecutor("203.0.113.10", "22", "deploy", keyPath)
+	if err := exec.Connect(0, 0); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	if waited := time.Since(start); waited > 250*time.Millisecond {
+		t.Errorf("Connect() to a reachable host took %s, waiting on the unreachable one", waited)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := <-hung; err == nil {
+			t.Error("Connect() to an unreachable host should fail once the dial timeout passes")
+		}
+	}
+	if pool.Dials() != 2 {
+		t.Errorf("dials = %d, want one dial shared by both executors of the unreachable host plus the reachable one", pool.Dials())
+	}
+}
+
+func TestClosePoolDisablesPooling(t *testing.T) {
+	_, pool := usePooledDialer(t)
+	exec := NewExecutor("203.0.113.10", "22", "deploy", writeTestKey(t))
+	if err := exec.Connect(0, time.Millisecond); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+
+	if err := ClosePool(); err != nil {
+		t.Fatalf("ClosePool() error: %v", err)
+	}
+	if len(pool.clients) != 0 {
+		t.Errorf("pool still holds %d connections", len(pool.clients))
+	}
+	if NewExecutor("203.0.113.10", "22", "deploy", "").pool != nil {
+		t.Error("executors created after ClosePool should not be pooled")
+	}
+}
+
+func TestEnablePoolingKeepsTheCurrentPool(t *testing.T) {
+	t.Cleanup(func() { ClosePool() })
+
+	first := EnablePooling()
+	if second := EnablePooling(); second != first {
+		t.Error("EnablePooling() replaced the pool that was already on, dropping its connections")
+	}
+
+	ClosePool()
+	if EnablePooling() == first {
+		t.Error("EnablePooling() after ClosePool() should start a new pool")
+	}
+}
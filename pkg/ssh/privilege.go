@@ -0,0 +1,101 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoPrivilege indicates the user is not root and sudo is not installed, so
+// no privileged command can run
+var ErrNoPrivilege = errors.New("neither root nor sudo is available")
+
+// Privilege is how an executor runs privileged commands
+type Privilege int
+
+const (
+	// PrivilegeUnknown means detection has not run or failed; sudo is used
+	PrivilegeUnknown Privilege = iota
+	// PrivilegeRoot means the user is root and commands run without sudo
+	PrivilegeRoot
+	// PrivilegeSudo means commands are prefixed with sudo
+	PrivilegeSudo
+	// PrivilegeNone means the user is not root and sudo is not installed
+	PrivilegeNone
+)
+
+func (p Privilege) String() string {
+	switch p {
+	case PrivilegeRoot:
+		return "root"
+	case PrivilegeSudo:
+		return "sudo"
+	case PrivilegeNone:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+// CommandRunner runs a command on the server. It is satisfied by *Executor.
+type CommandRunner interface {
+	Execute(command string) *CommandResult
+}
+
+// privilegeProbe prints the user id and whether sudo is installed in one round trip
+const privilegeProbe = `id -u; command -v sudo >/dev/null 2>&1 && echo sudo || echo no-sudo`
+
+// DetectPrivilege finds out whether the connected user is root or has sudo
+// installed. Whether sudo works without a password is CheckSudo's job.
+func DetectPrivilege(runner CommandRunner) (Privilege, error) {
+	result := runner.Execute(privilegeProbe)
+	if result.Error != nil {
+		return PrivilegeUnknown, fmt.Errorf("failed to detect privileges: %w", result.Error)
+	}
+	lines := strings.Fields(result.Stdout)
+	if result.ExitCode != 0 || len(lines) != 2 {
+		return PrivilegeUnknown, fmt.Errorf("failed to detect privileges: unexpected output %q", strings.TrimSpace(result.Stdout))
+	}
+
+	switch {
+	case lines[0] == "0":
+		return PrivilegeRoot, nil
+	case lines[1] == "sudo":
+		return PrivilegeSudo, nil
+	default:
+		return PrivilegeNone, nil
+	}
+}
+
+// Privilege returns how this executor runs privileged commands, detecting it
+// on the first privileged command after connecting (CheckSudo runs one right
+// after configure connects). Executors sharing a pooled connection detect it
+// once; when detection fails, sudo is used as before.
+func (e *Executor) Privilege() Privilege {
+	if e.privilegeChecked || e.client == nil {
+		return e.privilege
+	}
+	e.privilegeChecked = true
+	if e.pool != nil {
+		if privilege, ok := e.pool.privilege(e.poolKey()); ok {
+			e.privilege = privilege
+			return privilege
+		}
+	}
+
+	privilege, err := DetectPrivilege(e)
+	if err != nil {
+		return PrivilegeUnknown
+	}
+	e.privilege = privilege
+	if e.pool != nil {
+		e.pool.setPrivilege(e.poolKey(), privilege)
+	}
+	return privilege
+}
+
+// noPrivilegeError explains that privileged commands cannot run on this server
+func (e *Executor) noPrivilegeError() error {
+	return fmt.Errorf("%w: user '%s' is not root and sudo is not installed on %s. "+
+		"Connect as root or install sudo and allow the user to use it", ErrNoPrivilege, e.Username, e.Host)
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpHost is one hop of a ProxyJump: [user@]host[:port]
+type jumpHost struct {
+	User string
+	Host string
+	Port string
+}
+
+// parseProxyJump splits a ProxyJump value into its hops, first hop first
+func parseProxyJump(spec string) ([]jumpHost, error) {
+	var hops []jumpHost
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "ssh://"))
+		if part == "" {
+			return nil, fmt.Errorf("invalid ProxyJump %q: empty host", spec)
+		}
+		var hop jumpHost
+		if at := strings.LastIndex(part, "@"); at >= 0 {
+			hop.User, part = part[:at], part[at+1:]
+		}
+		if host, port, err := net.SplitHostPort(part); err == nil {
+			hop.Host, hop.Port = host, port
+		} else {
+			hop.Host = strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
+		}
+		if hop.Host == "" {
+			return nil, fmt.Errorf("invalid ProxyJump %q: empty host", spec)
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// jumpDialer returns a Dialer that reaches the server through the executor's
+// ProxyJump hosts, or nil for a direct connection. A hop may itself be an
+// ssh_config Host alias; its user and key default to the executor's.
+func (e *Executor) jumpDialer() Dialer {
+	if e.ProxyJump == "" {
+		return nil
+	}
+	return func(network, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+		hops, err := parseProxyJump(e.ProxyJump)
+		if err != nil {
+			return nil, err
+		}
+
+		var via []*ssh.Client
+		closeHops := func() {
+			for i := len(via) - 1; i >= 0; i-- {
+				via[i].Close()
+			}
+		}
+		for _, hop := range hops {
+			hopAddr, hopCfg, err := e.hopConfig(hop)
+			if err != nil {
+				closeHops()
+				return nil, err
+			}
+			client, err := dialThrough(via, network, hopAddr, hopCfg)
+			if err != nil {
+				closeHops()
+				return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.Host, err)
+			}
+			via = append(via, client)
+		}
+
+		client, err := dialThrough(via, network, addr, cfg)
+		if err != nil {
+			closeHops()
+			return nil, err
+		}
+		go func() {
+			client.Wait()
+			closeHops()
+		}()
+		return client, nil
+	}
+}
+
+// hopConfig resolves where and as whom to connect to a jump host
+func (e *Executor) hopConfig(hop jumpHost) (string, *ssh.ClientConfig, error) {
+	settings, _ := config.ResolveSSHHost(hop.Host)
+	user := firstNonEmpty(hop.User, settings.User, e.Username)
+	port := firstNonEmpty(hop.Port, settings.Port, config.DefaultSSHPort)
+	keyPath := firstNonEmpty(settings.IdentityFile, e.SSHKeyPath)
+
+	cfg, err := clientConfigFor(user, keyPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("jump host %s: %w", hop.Host, err)
+	}
+	return Address(settings.HostName, port), cfg, nil
+}
+
+// dialThrough opens an SSH connection to addr, tunnelled through the last
+// client in via when there is one
+func dialThrough(via []*ssh.Client, network, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	if len(via) == 0 {
+		return ssh.Dial(network, addr, cfg)
+	}
+	conn, err := via[len(via)-1].Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProxyJump(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []jumpHost
+		wantErr bool
+	}{
+		{"bastion", []jumpHost{{Host: "bastion"}}, false},
+		{"jump@bastion.example.com:2200", []jumpHost{{User: "jump", Host: "bastion.example.com", Port: "2200"}}, false},
+		{"a, ops@b:22", []jumpHost{{Host: "a"}, {User: "ops", Host: "b", Port: "22"}}, false},
+		{"ssh://ops@[2001:db8::1]:2222", []jumpHost{{User: "ops", Host: "2001:db8::1", Port: "2222"}}, false},
+		{"[2001:db8::1]", []jumpHost{{Host: "2001:db8::1"}}, false},
+		{"a,,b", nil, true},
+		{"user@", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseProxyJump(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxyJump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProxyJump() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJumpDialerOnlyWithProxyJump(t *testing.T) {
+	e := NewExecutor("10.0.2.15", "", "deploy", "~/.ssh/id_ed25519")
+	if e.jumpDialer() != nil {
+		t.Error("jumpDialer() without ProxyJump is not nil")
+	}
+	e.ProxyJump = "bastion"
+	if e.jumpDialer() == nil {
+		t.Error("jumpDialer() with ProxyJump is nil")
+	}
+}
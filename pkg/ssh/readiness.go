@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Clock is the time source readiness polling waits on; tests replace it
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// SystemClock is the real clock
+var SystemClock Clock = systemClock{}
+
+// Backoff is an exponential delay between polls: Initial, doubling up to Max
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Delay returns the wait after the given attempt, counting from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// PollTimeoutError is returned by PollUntil when the deadline passes
+type PollTimeoutError struct {
+	Elapsed time.Duration
+	Last    error // Error of the last probe, if any
+}
+
+func (e *PollTimeoutError) Error() string {
+	if e.Last != nil {
+		return fmt.Sprintf("not ready after %s: %v", e.Elapsed.Round(time.Second), e.Last)
+	}
+	return fmt.Sprintf("not ready after %s", e.Elapsed.Round(time.Second))
+}
+
+func (e *PollTimeoutError) Unwrap() error { return e.Last }
+
+// PollUntil calls probe until it reports done, waiting per backoff between
+// attempts. Waits are cut short at the deadline, so the whole poll never takes
+// much longer than timeout. probe gets the attempt number and the time spent so
+// far, and its error is kept for the timeout error.
+func PollUntil(clock Clock, timeout time.Duration, backoff Backoff, probe func(attempt int, elapsed time.Duration) (bool, error)) error {
+	start := clock.Now()
+	deadline := start.Add(timeout)
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		done, err := probe(attempt, clock.Now().Sub(start))
+		if done {
+			return err
+		}
+		lastErr = err
+
+		remaining := deadline.Sub(clock.Now())
+		if remaining <= 0 {
+			return &PollTimeoutError{Elapsed: clock.Now().Sub(start), Last: lastErr}
+		}
+		delay := backoff.Delay(attempt)
+		if delay > remaining {
+			delay = remaining
+		}
+		clock.Sleep(delay)
+	}
+}
+
+// reachabilityBackoff polls a fresh server's SSH port often at first: most
+// servers boot in well under a minute
+var reachabilityBackoff = Backoff{Initial: time.Second, Max: 5 * time.Second}
+
+// WaitUntilReachable connects to a server that may still be booting. The SSH
+// port is polled with short TCP dials, and authentication is tried as soon as
+// it opens; it may fail briefly until cloud-init has installed the key. report
+// is called before every wait with the time spent and what is being waited for.
+func (e *Executor) WaitUntilReachable(timeout time.Duration, report func(elapsed time.Duration, status string)) error {
+	addr := Address(e.Host, e.Port)
+	portOpen := func() bool {
+		if e.ProxyJump != "" {
+			// Only the jump host can reach the port
+			return true
+		}
+		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	connect := func() error {
+		client, err := e.dial()
+		if err != nil {
+			return err
+		}
+		e.client = client
+		return nil
+	}
+
+	if err := waitReachable(SystemClock, timeout, portOpen, connect, report); err != nil {
+		return fmt.Errorf("failed to connect to SSH server at %s: %w", addr, err)
+	}
+	return nil
+}
+
+func waitReachable(clock Clock, timeout time.Duration, portOpen func() bool, connect func() error, report func(elapsed time.Duration, status string)) error {
+	return PollUntil(clock, timeout, reachabilityBackoff, func(attempt int, elapsed time.Duration) (bool, error) {
+		if !portOpen() {
+			if report != nil {
+				report(elapsed, "waiting for the SSH port to open")
+			}
+			return false, fmt.Errorf("SSH port closed")
+		}
+		if err := connect(); err != nil {
+			if report != nil {
+				report(elapsed, "SSH port open, waiting for authentication")
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
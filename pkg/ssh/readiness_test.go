@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when slept on and records every sleep
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempt, w := range want {
+		if got := b.Delay(attempt); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, w)
+		}
+	}
+}
+
+func TestPollUntil_BackoffSchedule(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	calls := 0
+	err := PollUntil(clock, time.Minute, Backoff{Initial: time.Second, Max: 4 * time.Second}, func(attempt int, elapsed time.Duration) (bool, error) {
+		calls++
+		return attempt == 5, nil
+	})
+	if err != nil {
+		t.Fatalf("PollUntil() = %v", err)
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second}
+	if !reflect.DeepEqual(clock.sleeps, want) {
+		t.Errorf("sleeps = %v, want %v", clock.sleeps, want)
+	}
+	if calls != 6 {
+		t.Errorf("probe called %d times, want 6", calls)
+	}
+}
+
+func TestPollUntil_RespectsDeadline(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	start := clock.now
+	probeErr := errors.New("port closed")
+
+	err := PollUntil(clock, 10*time.Second, Backoff{Initial: 3 * time.Second, Max: 3 * time.Second}, func(int, time.Duration) (bool, error) {
+		return false, probeErr
+	})
+
+	var timeout *PollTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("PollUntil() = %v, want PollTimeoutError", err)
+	}
+	if !errors.Is(err, probeErr) {
+		t.Errorf("PollUntil() = %v, want it to wrap the last probe error", err)
+	}
+	if elapsed := clock.now.Sub(start); elapsed != 10*time.Second {
+		t.Errorf("polled for %v, want exactly the 10s timeout", elapsed)
+	}
+	// The last wait is cut short at the deadline
+	if last := clock.sleeps[len(clock.sleeps)-1]; last != time.Second {
+		t.Errorf("last sleep = %v, want 1s", last)
+	}
+}
+
+func TestWaitReachable_ConnectsAsSoonAsPortOpens(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	start := clock.now
+	portOpenAfter := 7 * time.Second
+	authAttempts := 0
+	var statuses []string
+
+	err := waitReachable(clock, time.Minute,
+		func() bool { return clock.now.Sub(start) >= portOpenAfter },
+		func() error {
+			authAttempts++
+			if authAttempts == 1 {
+				return errors.New("unable to authenticate")
+			}
+			return nil
+		},
+		func(elapsed time.Duration, status string) { statuses = append(statuses, status) },
+	)
+	if err != nil {
+		t.Fatalf("waitReachable() = %v", err)
+	}
+
+	// Port polls at 0s, 1s, 3s, 7s; auth fails once at 7s and succeeds after 5s more
+	if elapsed := clock.now.Sub(start); elapsed != 12*time.Second {
+		t.Errorf("connected after %v, want 12s", elapsed)
+	}
+	if authAttempts != 2 {
+		t.Errorf("authentication tried %d times, want 2", authAttempts)
+	}
+	if statuses[0] != "waiting for the SSH port to open" || statuses[len(statuses)-1] != "SSH port open, waiting for authentication" {
+		t.Errorf("statuses = %v", statuses)
+	}
+}
+
+func TestWaitReachable_Timeout(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	start := clock.now
+
+	err := waitReachable(clock, 30*time.Second, func() bool { return false }, func() error { return nil }, nil)
+	if err == nil {
+		t.Fatal("waitReachable() = nil, want timeout")
+	}
+	if elapsed := clock.now.Sub(start); elapsed != 30*time.Second {
+		t.Errorf("gave up after %v, want 30s", elapsed)
+	}
+}
@@ -0,0 +1,235 @@
+// Package sshtest provides an in-process SSH server for tests. Pass
+// Server.Dial to ssh.NewPool so executors connect to it instead of a real
+// host.
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Server records every command it is asked to run, with what the command
+// read from stdin, and answers with exit status 0 unless told otherwise.
+// Configure it before the first dial.
+type Server struct {
+	// FailOn makes commands containing any of these substrings exit 1
+	FailOn []string
+	// Replies makes commands containing a key print its outputs in turn,
+	// repeating the last one
+	Replies map[string][]string
+	// DropCount cuts the connection in the middle of the first DropCount
+	// commands, after some partial output
+	DropCount int
+	// IgnoreKeepAlive leaves keepalive requests unanswered, like a dead peer
+	IgnoreKeepAlive bool
+	// Unreachable makes dials to these addresses hang like a host that drops
+	// packets, failing once the client config's Timeout has passed
+	Unreachable []string
+
+	mu       sync.Mutex
+	dials    int
+	commands []string
+	inputs   []string
+}
+
+// Dial has the signature of ssh.Dial and connects to a fresh server
+// connection over loopback TCP
+func (s *Server) Dial(network, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	s.mu.Lock()
+	s.dials++
+	s.mu.Unlock()
+
+	if slices.Contains(s.Unreachable, addr) {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = time.Hour
+		}
+		time.Sleep(timeout)
+		return nil, fmt.Errorf("dial %s %s: i/o timeout", network, addr)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		serverConn, err := listener.Accept()
+		listener.Close()
+		if err == nil {
+			s.serve(serverConn)
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	conn, chans, reqs, err := ssh.NewClientConn(clientConn, addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewClient(conn, chans, reqs), nil
+}
+
+func (s *Server) serve(conn net.Conn) {
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go func() {
+		for req := range reqs {
+			if s.IgnoreKeepAlive {
+				continue
+			}
+			req.Reply(true, nil)
+		}
+	}()
+
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+
+				input, _ := io.ReadAll(channel)
+				exitCode, stdout, drop := s.record(payload.Command, string(input))
+				if drop {
+					io.WriteString(channel, "partial output")
+					conn.Close()
+					return
+				}
+
+				io.WriteString(channel, stdout)
+				status := make([]byte, 4)
+				binary.BigEndian.PutUint32(status, exitCode)
+				channel.SendRequest("exit-status", false, status)
+				channel.Close()
+			}
+		}()
+	}
+}
+
+func (s *Server) record(command, input string) (uint32, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, command)
+	s.inputs = append(s.inputs, input)
+
+	if s.DropCount > 0 {
+		s.DropCount--
+		return 0, "", true
+	}
+
+	stdout := ""
+	for match, outputs := range s.Replies {
+		if strings.Contains(command, match) && len(outputs) > 0 {
+			stdout = outputs[0]
+			if len(outputs) > 1 {
+				s.Replies[match] = outputs[1:]
+			}
+		}
+	}
+	for _, fail := range s.FailOn {
+		if strings.Contains(command, fail) {
+			return 1, stdout, false
+		}
+	}
+	return 0, stdout, false
+}
+
+// Dials returns the number of connections made
+func (s *Server) Dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// Commands returns the commands run so far, in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Inputs returns what each command in Commands read from stdin, e.g.
+// uploaded files
+func (s *Server) Inputs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.inputs...)
+}
+
+// Index returns the position of the first command containing substr, or -1
+func (s *Server) Index(substr string) int {
+	for i, command := range s.Commands() {
+		if strings.Contains(command, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Ran reports whether any command contained substr
+func (s *Server) Ran(substr string) bool {
+	return s.Index(substr) >= 0
+}
+
+// Count returns how many times exactly command ran
+func (s *Server) Count(command string) int {
+	count := 0
+	for _, c := range s.Commands() {
+		if c == command {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteKey writes a fresh ed25519 private key for the client side and
+// returns its path
+func WriteKey(t testing.TB) string {
+	t.Helper()
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return keyPath
+}
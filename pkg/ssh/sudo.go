@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrSudoPasswordRequired indicates the deploy user can only sudo with a password
+var ErrSudoPasswordRequired = errors.New("sudo requires a password")
+
+// SudoRunner runs a command through sudo. It is satisfied by *Executor.
+type SudoRunner interface {
+	ExecuteSudo(command string) *CommandResult
+}
+
+// SudoPasswordPrompt asks for the sudo password of username on host
+type SudoPasswordPrompt func(username, host string) (string, error)
+
+var (
+	sudoPrompt    SudoPasswordPrompt
+	sudoPasswords = map[string]string{}
+	sudoMu        sync.Mutex
+)
+
+// SetSudoPasswordPrompt lets every executor ask for the sudo password the
+// first time sudo on a server needs one, for --sudo-password-prompt. Each
+// password is asked once per user and server and only kept in memory.
+func SetSudoPasswordPrompt(prompt SudoPasswordPrompt) {
+	sudoMu.Lock()
+	defer sudoMu.Unlock()
+	sudoPrompt = prompt
+}
+
+func sudoPasswordKey(username, host string) string {
+	return username + "@" + host
+}
+
+// rememberSudoPassword keeps a password for the other executors of the same
+// user and server in this invocation; an empty password forgets it
+func rememberSudoPassword(username, host, password string) {
+	sudoMu.Lock()
+	defer sudoMu.Unlock()
+	if password == "" {
+		delete(sudoPasswords, sudoPasswordKey(username, host))
+		return
+	}
+	sudoPasswords[sudoPasswordKey(username, host)] = password
+}
+
+// sessionSudoPassword returns the password remembered for username on host.
+// With ask set and none remembered, it asks through the prompt, if there is
+// one; executors asking at the same time share one prompt.
+func sessionSudoPassword(username, host string, ask bool) string {
+	sudoMu.Lock()
+	defer sudoMu.Unlock()
+	key := sudoPasswordKey(username, host)
+	if password := sudoPasswords[key]; password != "" || !ask || sudoPrompt == nil {
+		return password
+	}
+	password, err := sudoPrompt(username, host)
+	if err != nil {
+		return ""
+	}
+	sudoPasswords[key] = password
+	return password
+}
+
+// sudoNeedsPassword reports whether sudo -n refused to run without a password
+func sudoNeedsPassword(result *CommandResult) bool {
+	if result.Error != nil || result.ExitCode == 0 {
+		return false
+	}
+	stderr := strings.ToLower(result.Stderr)
+	return strings.Contains(stderr, "password is required") || strings.Contains(stderr, "terminal is required")
+}
+
+// sudoPasswordRejected reports whether sudo -S was given a wrong password
+func sudoPasswordRejected(result *CommandResult) bool {
+	stderr := strings.ToLower(result.Stderr)
+	return result.ExitCode != 0 && (strings.Contains(stderr, "incorrect password") || strings.Contains(stderr, "sorry, try again"))
+}
+
+// SudoersLine returns the sudoers entry that grants username passwordless sudo
+func SudoersLine(username string) string {
+	return fmt.Sprintf("%s ALL=(ALL) NOPASSWD:ALL", username)
+}
+
+// CheckSudo verifies that username can run sudo non-interactively on the server,
+// so configuration fails early with an actionable message instead of on the
+// first privileged command.
+func CheckSudo(runner SudoRunner, username string) error {
+	result := runner.ExecuteSudo("true")
+	if errors.Is(result.Error, ErrNoPrivilege) {
+		return result.Error
+	}
+	if result.Error != nil {
+		return fmt.Errorf("failed to check sudo access: %w", result.Error)
+	}
+	if result.ExitCode == 0 {
+		return nil
+	}
+
+	stderr := strings.ToLower(result.Stderr)
+	switch {
+	case sudoNeedsPassword(result):
+		return fmt.Errorf("%w for user '%s'. Lightfold needs passwordless sudo for the deploy user.\n"+
+			"Add it on the server with 'sudo visudo -f /etc/sudoers.d/lightfold' and the line:\n\n    %s\n\n"+
+			"or rerun with --sudo-password-prompt to enter the password for this session",
+			ErrSudoPasswordRequired, username, SudoersLine(username))
+	case strings.Contains(stderr, "incorrect password") || strings.Contains(stderr, "sorry, try again"):
+		return fmt.Errorf("sudo password for user '%s' was rejected", username)
+	case strings.Contains(stderr, "not in the sudoers") || strings.Contains(stderr, "not allowed to"):
+		return fmt.Errorf("user '%s' is not allowed to use sudo on this server. Add it to /etc/sudoers.d with:\n\n    %s",
+			username, SudoersLine(username))
+	case result.ExitCode == 127 || strings.Contains(stderr, "command not found"):
+		return fmt.Errorf("sudo is not installed on the server")
+	default:
+		return fmt.Errorf("sudo check failed for user '%s' (exit code %d): %s",
+			username, result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+}
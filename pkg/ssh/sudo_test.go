@@ -0,0 +1,216 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeSudoRunner struct {
+	result   *CommandResult
+	commands []string
+}
+
+func (f *fakeSudoRunner) ExecuteSudo(command string) *CommandResult {
+	f.commands = append(f.commands, command)
+	return f.result
+}
+
+func TestCheckSudo(t *testing.T) {
+	tests := []struct {
+		name         string
+		result       *CommandResult
+		wantErr      bool
+		wantPassword bool
+		wantContains string
+	}{
+		{
+			name:   "passwordless sudo",
+			result: &CommandResult{ExitCode: 0},
+		},
+		{
+			name:         "password required",
+			result:       &CommandResult{ExitCode: 1, Stderr: "sudo: a password is required\n"},
+			wantErr:      true,
+			wantPassword: true,
+			wantContains: "deploy ALL=(ALL) NOPASSWD:ALL",
+		},
+		{
+			name:         "terminal required",
+			result:       &CommandResult{ExitCode: 1, Stderr: "sudo: a terminal is required to read the password; either use the -S option to read from standard input or configure an askpass helper\n"},
+			wantErr:      true,
+			wantPassword: true,
+			wantContains: "--sudo-password-prompt",
+		},
+		{
+			name:         "wrong password",
+			result:       &CommandResult{ExitCode: 1, Stderr: "Sorry, try again.\nsudo: 1 incorrect password attempt\n"},
+			wantErr:      true,
+			wantContains: "rejected",
+		},
+		{
+			name:         "not a sudoer",
+			result:       &CommandResult{ExitCode: 1, Stderr: "deploy is not in the sudoers file.  This incident will be reported.\n"},
+			wantErr:      true,
+			wantContains: "not allowed to use sudo",
+		},
+		{
+			name:         "sudo missing",
+			result:       &CommandResult{ExitCode: 127, Stderr: "bash: sudo: command not found\n"},
+			wantErr:      true,
+			wantContains: "not installed",
+		},
+		{
+			name:         "connection error",
+			result:       &CommandResult{Error: errors.New("not connected to SSH server")},
+			wantErr:      true,
+			wantContains: "failed to check sudo access",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeSudoRunner{result: tt.result}
+			err := CheckSudo(runner, "deploy")
+
+			if len(runner.commands) != 1 || runner.commands[0] != "true" {
+				t.Errorf("expected a single 'true' probe, got %v", runner.commands)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckSudo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if errors.Is(err, ErrSudoPasswordRequired) != tt.wantPassword {
+				t.Errorf("errors.Is(ErrSudoPasswordRequired) = %v, want %v", !tt.wantPassword, tt.wantPassword)
+			}
+			if !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("error %q should contain %q", err.Error(), tt.wantContains)
+			}
+		})
+	}
+}
+
+type fakeCommandRunner struct {
+	result *CommandResult
+}
+
+func (f *fakeCommandRunner) Execute(command string) *CommandResult {
+	return f.result
+}
+
+func TestDetectPrivilege(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  *CommandResult
+		want    Privilege
+		wantErr bool
+	}{
+		{"root", &CommandResult{Stdout: "0\nno-sudo\n"}, PrivilegeRoot, false},
+		{"root with sudo", &CommandResult{Stdout: "0\nsudo\n"}, PrivilegeRoot, false},
+		{"sudo", &CommandResult{Stdout: "1000\nsudo\n"}, PrivilegeSudo, false},
+		{"neither", &CommandResult{Stdout: "1000\nno-sudo\n"}, PrivilegeNone, false},
+		{"unexpected output", &CommandResult{Stdout: "welcome\n"}, PrivilegeUnknown, true},
+		{"connection error", &CommandResult{Error: errors.New("not connected to SSH server")}, PrivilegeUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectPrivilege(&fakeCommandRunner{result: tt.result})
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("DetectPrivilege() = %v, %v; want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteSudoFollowsPrivilege(t *testing.T) {
+	tests := []struct {
+		privilege   Privilege
+		password    string
+		wantCommand string
+	}{
+		{PrivilegeRoot, "", "mkdir -p /srv/app"},
+		{PrivilegeRoot, "secret", "mkdir -p /srv/app"},
+		{PrivilegeSudo, "", "sudo -n mkdir -p /srv/app"},
+		{PrivilegeSudo, "secret", "sudo -S -p '' mkdir -p /srv/app"},
+		{PrivilegeUnknown, "", "sudo -n mkdir -p /srv/app"},
+		{PrivilegeNone, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.privilege.String(), func(t *testing.T) {
+			exec := NewExecutor("203.0.113.10", "22", "app", "~/.ssh/id")
+			exec.privilege, exec.privilegeChecked = tt.privilege, true
+			exec.SetSudoPassword(tt.password)
+			var ran []string
+			exec.SetTraceHook(func(trace CommandTrace) { ran = append(ran, trace.Command) })
+
+			result := exec.ExecuteSudo("mkdir -p /srv/app")
+
+			if tt.wantCommand == "" {
+				if !errors.Is(result.Error, ErrNoPrivilege) || len(ran) != 0 {
+					t.Errorf("ExecuteSudo() error = %v, ran %v; want ErrNoPrivilege without running anything", result.Error, ran)
+				}
+				if err := CheckSudo(exec, "app"); !errors.Is(err, ErrNoPrivilege) || !strings.Contains(err.Error(), "Connect as root or install sudo") {
+					t.Errorf("CheckSudo() error = %v, want the no privilege message", err)
+				}
+				return
+			}
+			if len(ran) != 1 || ran[0] != tt.wantCommand {
+				t.Errorf("ran %v, want %q", ran, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestSessionSudoPassword(t *testing.T) {
+	asked := 0
+	SetSudoPasswordPrompt(func(username, host string) (string, error) {
+		asked++
+		return "hunter2", nil
+	})
+	t.Cleanup(func() {
+		SetSudoPasswordPrompt(nil)
+		rememberSudoPassword("deploy", "203.0.113.10", "")
+	})
+
+	if got := sessionSudoPassword("deploy", "203.0.113.10", false); got != "" || asked != 0 {
+		t.Fatalf("without ask = %q after %d prompts, want nothing asked", got, asked)
+	}
+	for i := 0; i < 2; i++ {
+		if got := sessionSudoPassword("deploy", "203.0.113.10", true); got != "hunter2" {
+			t.Fatalf("sessionSudoPassword() = %q", got)
+		}
+	}
+	if asked != 1 {
+		t.Errorf("asked %d times, want once per server", asked)
+	}
+
+	// Another executor for the same server picks it up without asking
+	e := NewExecutor("203.0.113.10", "22", "deploy", "")
+	if got := sessionSudoPassword(e.Username, e.Host, false); got != "hunter2" {
+		t.Errorf("remembered password = %q", got)
+	}
+
+	rememberSudoPassword("deploy", "203.0.113.10", "")
+	if got := sessionSudoPassword("deploy", "203.0.113.10", false); got != "" {
+		t.Errorf("forgotten password = %q", got)
+	}
+}
+
+func TestSudoNeedsPassword(t *testing.T) {
+	tests := []struct {
+		result *CommandResult
+		want   bool
+	}{
+		{&CommandResult{ExitCode: 1, Stderr: "sudo: a password is required\n"}, true},
+		{&CommandResult{ExitCode: 1, Stderr: "sudo: a terminal is required to read the password\n"}, true},
+		{&CommandResult{ExitCode: 1, Stderr: "mkdir: cannot create directory\n"}, false},
+		{&CommandResult{ExitCode: 0}, false},
+	}
+	for _, tt := range tests {
+		if got := sudoNeedsPassword(tt.result); got != tt.want {
+			t.Errorf("sudoNeedsPassword(%q) = %v, want %v", tt.result.Stderr, got, tt.want)
+		}
+	}
+}
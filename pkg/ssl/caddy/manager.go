@@ -57,6 +57,29 @@ func (m *Manager) IssueCertificate(domain string, email string) error {
 	return nil
 }
 
+// CertificateExists checks Caddy's certificate storage for every domain
+func (m *Manager) CertificateExists(domains []string) (bool, error) {
+	if m.executor == nil {
+		return false, fmt.Errorf("SSH executor not configured")
+	}
+
+	for _, domain := range domains {
+		certPath, _, err := m.GetCertificatePath(domain)
+		if err != nil {
+			return false, err
+		}
+		result := m.executor.ExecuteSudo(fmt.Sprintf("test -f %s", certPath))
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.ExitCode != 0 {
+			return false, nil
+		}
+	}
+
+	return len(domains) > 0, nil
+}
+
 // RenewCertificate is a no-op for Caddy since it handles renewal automatically
 func (m *Manager) RenewCertificate(domain string) error {
 	// Caddy handles renewal automatically
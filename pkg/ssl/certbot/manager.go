@@ -16,6 +16,8 @@ func init() {
 // Manager implements SSL certificate management using Certbot/Let's Encrypt
 type Manager struct {
 	executor *ssh.Executor
+	staging  bool
+	aliases  []string
 }
 
 // NewManager creates a new Certbot SSL manager
@@ -30,6 +32,18 @@ func (m *Manager) SetExecutor(executor *ssh.Executor) {
 	m.executor = executor
 }
 
+// SetStaging issues certificates from the Let's Encrypt staging environment,
+// which has generous rate limits but is not trusted by browsers
+func (m *Manager) SetStaging(staging bool) {
+	m.staging = staging
+}
+
+// SetAliases adds names the certificate covers next to the domain it is
+// issued for, e.g. the www name redirected to an apex domain
+func (m *Manager) SetAliases(aliases []string) {
+	m.aliases = aliases
+}
+
 // Name returns the name of this SSL manager
 func (m *Manager) Name() string {
 	return "certbot"
@@ -76,24 +90,81 @@ func (m *Manager) IssueCertificate(domain string, email string) error {
 		}
 	}
 
-	cmd := fmt.Sprintf(
-		"certbot --nginx -d %s --non-interactive --agree-tos --email %s",
-		domain,
-		email,
-	)
+	// Reissuing a certificate that already exists burns the duplicate-certificate
+	// rate limit, so an existing one is installed into nginx instead
+	if cert, found, err := m.findCertificate(append([]string{domain}, m.aliases...)); err == nil && found {
+		result := m.executor.ExecuteSudo(fmt.Sprintf("certbot install --nginx --cert-name %s --non-interactive", cert.Name))
+		if result.Error == nil && result.ExitCode == 0 {
+			return nil
+		}
+	}
 
-	result := m.executor.ExecuteSudo(cmd)
+	result := m.executor.ExecuteSudo(m.IssueCommand(domain, email))
 	if result.Error != nil {
 		return fmt.Errorf("failed to execute certbot: %w", result.Error)
 	}
 
 	if result.ExitCode != 0 {
+		if rateLimit := parseRateLimit(result.Stdout + "\n" + result.Stderr); rateLimit != nil {
+			return rateLimit
+		}
 		return fmt.Errorf("certbot failed (exit code %d): %s", result.ExitCode, result.Stderr)
 	}
 
 	return nil
 }
 
+// IssueCommand returns the certbot command that issues a certificate for
+// domain and its aliases and installs it into their nginx server blocks
+func (m *Manager) IssueCommand(domain, email string) string {
+	names := domain
+	for _, alias := range m.aliases {
+		names += " -d " + alias
+	}
+	cmd := fmt.Sprintf(
+		"certbot --nginx -d %s --non-interactive --agree-tos --email %s",
+		names,
+		email,
+	)
+	if len(m.aliases) > 0 {
+		// A certificate issued for the domain alone is widened rather than
+		// left next to a second one
+		cmd += " --expand"
+	}
+	if m.staging {
+		cmd += " --test-cert --break-my-certs"
+	}
+	return cmd
+}
+
+// CertificateExists reports whether certbot already manages a valid
+// certificate for exactly these domains
+func (m *Manager) CertificateExists(domains []string) (bool, error) {
+	_, found, err := m.findCertificate(domains)
+	return found, err
+}
+
+func (m *Manager) findCertificate(domains []string) (certificate, bool, error) {
+	if m.executor == nil {
+		return certificate{}, false, fmt.Errorf("SSH executor not configured")
+	}
+	if len(domains) == 0 {
+		return certificate{}, false, nil
+	}
+
+	result := m.executor.ExecuteSudo(fmt.Sprintf("certbot certificates -d '%s' 2>/dev/null", strings.Join(domains, "' -d '")))
+	if result.Error != nil {
+		return certificate{}, false, result.Error
+	}
+	if result.ExitCode != 0 {
+		// certbot missing or no certificates yet
+		return certificate{}, false, nil
+	}
+
+	cert, found := findCertificate(parseCertificates(result.Stdout), domains, m.staging)
+	return cert, found, nil
+}
+
 // RenewCertificate renews an existing SSL certificate
 func (m *Manager) RenewCertificate(domain string) error {
 	if m.executor == nil {
@@ -114,26 +185,15 @@ func (m *Manager) RenewCertificate(domain string) error {
 	return nil
 }
 
-// EnableAutoRenewal sets up automatic certificate renewal using systemd timer
+// EnableAutoRenewal enables the certbot systemd timer, installs a cron
+// fallback when the timer is not active afterwards, and verifies one of them
+// is scheduled
 func (m *Manager) EnableAutoRenewal() error {
 	if m.executor == nil {
 		return fmt.Errorf("SSH executor not configured")
 	}
-
-	// Enable and start certbot systemd timer
-	// This is the modern approach for auto-renewal on Ubuntu/Debian systems
-	result := m.executor.ExecuteSudo("systemctl enable certbot.timer && systemctl start certbot.timer")
-
-	if result.Error != nil {
-		return fmt.Errorf("failed to enable certbot auto-renewal: %w", result.Error)
-	}
-
-	if result.ExitCode != 0 {
-		// Fallback: try to set up a cron job if systemd timer is not available
-		return m.setupCronRenewal()
-	}
-
-	return nil
+	_, err := EnsureRenewal(m.executor)
+	return err
 }
 
 // GetCertificatePath returns the paths to the certificate and key files
@@ -167,29 +227,6 @@ func (m *Manager) installCertbot() error {
 	return nil
 }
 
-// setupCronRenewal sets up a cron job for certificate renewal (fallback method)
-func (m *Manager) setupCronRenewal() error {
-	result := m.executor.Execute("crontab -l 2>/dev/null | grep -q 'certbot renew'")
-	if result.ExitCode == 0 {
-		return nil
-	}
-
-	// Add a cron job to renew certificates twice daily (recommended by Let's Encrypt)
-	cronEntry := "0 0,12 * * * certbot renew --quiet"
-	cmd := fmt.Sprintf("(crontab -l 2>/dev/null; echo '%s') | crontab -", cronEntry)
-
-	result = m.executor.Execute(cmd)
-	if result.Error != nil {
-		return fmt.Errorf("failed to setup cron renewal: %w", result.Error)
-	}
-
-	if result.ExitCode != 0 {
-		return fmt.Errorf("cron setup failed (exit code %d): %s", result.ExitCode, result.Stderr)
-	}
-
-	return nil
-}
-
 // CheckCertificateExpiry checks when a certificate will expire
 func (m *Manager) CheckCertificateExpiry(domain string) (daysRemaining int, err error) {
 	if m.executor == nil {
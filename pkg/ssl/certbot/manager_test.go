@@ -0,0 +1,19 @@
+package certbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIssueCommand_Aliases(t *testing.T) {
+	m := NewManager(nil)
+	if cmd := m.IssueCommand("example.com", "ops@example.com"); strings.Contains(cmd, "--expand") || !strings.Contains(cmd, "-d example.com --non-interactive") {
+		t.Errorf("IssueCommand() = %q", cmd)
+	}
+
+	m.SetAliases([]string{"www.example.com"})
+	cmd := m.IssueCommand("example.com", "ops@example.com")
+	if !strings.Contains(cmd, "certbot --nginx -d example.com -d www.example.com ") || !strings.Contains(cmd, "--expand") {
+		t.Errorf("IssueCommand() with an alias = %q", cmd)
+	}
+}
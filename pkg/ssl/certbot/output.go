@@ -0,0 +1,151 @@
+package certbot
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// certificate is one entry of `certbot certificates`
+type certificate struct {
+	Name    string
+	Domains []string
+	Valid   bool
+	Test    bool
+	Expired bool
+}
+
+// parseCertificates reads the certificate list printed by `certbot certificates`
+func parseCertificates(output string) []certificate {
+	var certs []certificate
+	var current *certificate
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Certificate Name:"):
+			certs = append(certs, certificate{Name: strings.TrimSpace(strings.TrimPrefix(line, "Certificate Name:"))})
+			current = &certs[len(certs)-1]
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "Domains:"):
+			current.Domains = strings.Fields(strings.TrimPrefix(line, "Domains:"))
+		case strings.HasPrefix(line, "Expiry Date:"):
+			current.Valid = strings.Contains(line, "(VALID:")
+			current.Test = strings.Contains(line, "TEST_CERT")
+			current.Expired = strings.Contains(line, "EXPIRED")
+		}
+	}
+
+	return certs
+}
+
+// findCertificate returns the certificate covering exactly domains that can be
+// reused. Staging certificates only count when staging is requested.
+func findCertificate(certs []certificate, domains []string, staging bool) (certificate, bool) {
+	want := normalizeDomains(domains)
+	for _, cert := range certs {
+		if strings.Join(normalizeDomains(cert.Domains), " ") != strings.Join(want, " ") {
+			continue
+		}
+		if cert.Valid || (staging && cert.Test && !cert.Expired) {
+			return cert, true
+		}
+	}
+	return certificate{}, false
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(domain)))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// RateLimitError reports that Let's Encrypt refused to issue because a rate
+// limit was reached. Retrying before RetryAfter fails the same way.
+type RateLimitError struct {
+	// Limit describes the limit that was hit
+	Limit string
+	// RetryAfter is when issuing can be retried; zero when certbot did not say
+	RetryAfter time.Time
+	// Window is how long the limit lasts, used when RetryAfter is unknown
+	Window time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("Let's Encrypt rate limit reached: %s", e.Limit)
+	switch {
+	case !e.RetryAfter.IsZero():
+		msg += fmt.Sprintf("; retry after %s", e.RetryAfter.UTC().Format("2006-01-02 15:04 MST"))
+	case e.Window > 0:
+		msg += fmt.Sprintf("; the limit resets within %s", formatWindow(e.Window))
+	}
+	return msg + ". Use --staging to test the domain setup without using production quota"
+}
+
+func formatWindow(window time.Duration) string {
+	if window >= 24*time.Hour {
+		return fmt.Sprintf("%d days", int(window/(24*time.Hour)))
+	}
+	if window == time.Hour {
+		return "an hour"
+	}
+	return fmt.Sprintf("%d hours", int(window/time.Hour))
+}
+
+// rateLimits maps certbot error text to the Let's Encrypt limit it reports,
+// most specific first
+var rateLimits = []struct {
+	match  []string
+	limit  string
+	window time.Duration
+}{
+	{[]string{"too many certificates", "exact set"}, "too many certificates already issued for this exact set of domains", 7 * 24 * time.Hour},
+	{[]string{"too many certificates"}, "too many certificates already issued for this registered domain", 7 * 24 * time.Hour},
+	{[]string{"too many failed authorizations"}, "too many failed validation attempts for this domain", time.Hour},
+	{[]string{"too many new orders"}, "too many new certificate orders from this account", 3 * time.Hour},
+	{[]string{"too many registrations"}, "too many account registrations from this server's IP", 3 * time.Hour},
+	{[]string{"ratelimited"}, "too many requests", 0},
+}
+
+var retryAfterPattern = regexp.MustCompile(`(?i)retry after (\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:Z| UTC)?)`)
+
+// parseRateLimit recognizes a rate-limit failure in certbot output, or returns nil
+func parseRateLimit(output string) *RateLimitError {
+	lower := strings.ToLower(output)
+
+	for _, rl := range rateLimits {
+		matched := true
+		for _, fragment := range rl.match {
+			if !strings.Contains(lower, fragment) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		err := &RateLimitError{Limit: rl.limit, Window: rl.window}
+		if m := retryAfterPattern.FindStringSubmatch(output); m != nil {
+			err.RetryAfter = parseRetryAfter(m[1])
+		}
+		return err
+	}
+
+	return nil
+}
+
+func parseRetryAfter(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
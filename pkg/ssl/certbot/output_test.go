@@ -0,0 +1,156 @@
+package certbot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const certificatesOutput = `Saving debug log to /var/log/letsencrypt/letsencrypt.log
+
+- - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+Found the following certs:
+  Certificate Name: app.example.com
+    Serial Number: 4a1b2c3d4e5f60718293a4b5c6d7e8f90a1
+    Key Type: ECDSA
+    Domains: app.example.com
+    Expiry Date: 2026-12-01 10:15:42+00:00 (VALID: 45 days)
+    Certificate Path: /etc/letsencrypt/live/app.example.com/fullchain.pem
+    Private Key Path: /etc/letsencrypt/live/app.example.com/privkey.pem
+  Certificate Name: example.com
+    Serial Number: 3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6
+    Key Type: ECDSA
+    Domains: example.com www.example.com
+    Expiry Date: 2026-11-20 08:00:00+00:00 (VALID: 34 days)
+    Certificate Path: /etc/letsencrypt/live/example.com/fullchain.pem
+    Private Key Path: /etc/letsencrypt/live/example.com/privkey.pem
+  Certificate Name: old.example.com
+    Serial Number: 1a2b3c4d5e6f
+    Key Type: RSA
+    Domains: old.example.com
+    Expiry Date: 2026-06-01 00:00:00+00:00 (INVALID: EXPIRED)
+    Certificate Path: /etc/letsencrypt/live/old.example.com/fullchain.pem
+    Private Key Path: /etc/letsencrypt/live/old.example.com/privkey.pem
+  Certificate Name: staging.example.com
+    Serial Number: fa12b34c56d78e90
+    Key Type: ECDSA
+    Domains: staging.example.com
+    Expiry Date: 2027-01-10 12:00:00+00:00 (INVALID: TEST_CERT)
+    Certificate Path: /etc/letsencrypt/live/staging.example.com/fullchain.pem
+    Private Key Path: /etc/letsencrypt/live/staging.example.com/privkey.pem
+- - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+`
+
+func TestFindCertificate(t *testing.T) {
+	certs := parseCertificates(certificatesOutput)
+	if len(certs) != 4 {
+		t.Fatalf("parseCertificates() found %d certificates, want 4", len(certs))
+	}
+
+	tests := []struct {
+		name     string
+		domains  []string
+		staging  bool
+		wantName string
+	}{
+		{"exact single domain", []string{"app.example.com"}, false, "app.example.com"},
+		{"exact set in any order", []string{"www.example.com", "EXAMPLE.com"}, false, "example.com"},
+		{"subset is not a match", []string{"example.com"}, false, ""},
+		{"expired certificate", []string{"old.example.com"}, false, ""},
+		{"test certificate in production", []string{"staging.example.com"}, false, ""},
+		{"test certificate in staging", []string{"staging.example.com"}, true, "staging.example.com"},
+		{"unknown domain", []string{"other.example.com"}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, found := findCertificate(certs, tt.domains, tt.staging)
+			if found != (tt.wantName != "") || cert.Name != tt.wantName {
+				t.Errorf("findCertificate(%v) = %q, %v; want %q", tt.domains, cert.Name, found, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		wantLimit  string
+		wantRetry  time.Time
+		wantWindow time.Duration
+	}{
+		{
+			name: "duplicate certificate with RFC 3339 retry time",
+			output: `Saving debug log to /var/log/letsencrypt/letsencrypt.log
+Requesting a certificate for app.example.com
+An unexpected error occurred:
+Error creating new order :: too many certificates (5) already issued for this exact set of domains in the last 168 hours: app.example.com, retry after 2026-10-21T19:13:03Z: see https://letsencrypt.org/docs/rate-limits/#new-certificates-per-exact-set-of-hostnames
+Ask for help or search for solutions at https://community.letsencrypt.org. See the logfile /var/log/letsencrypt/letsencrypt.log or re-run Certbot with -v for more details.`,
+			wantLimit:  "exact set of domains",
+			wantRetry:  time.Date(2026, 10, 21, 19, 13, 3, 0, time.UTC),
+			wantWindow: 7 * 24 * time.Hour,
+		},
+		{
+			name: "duplicate certificate with UTC retry time",
+			output: `An unexpected error occurred:
+There were too many requests of a given type :: Error creating new order :: too many certificates already issued for exact set of domains: app.example.com: retry after 2026-10-21 19:13:03 UTC: see https://letsencrypt.org/docs/duplicate-certificate-limit/`,
+			wantLimit:  "exact set of domains",
+			wantRetry:  time.Date(2026, 10, 21, 19, 13, 3, 0, time.UTC),
+			wantWindow: 7 * 24 * time.Hour,
+		},
+		{
+			name: "registered domain without retry time",
+			output: `An unexpected error occurred:
+There were too many requests of a given type :: Error creating new order :: too many certificates already issued for: example.com: see https://letsencrypt.org/docs/rate-limits/`,
+			wantLimit:  "registered domain",
+			wantWindow: 7 * 24 * time.Hour,
+		},
+		{
+			name: "failed validations",
+			output: `Certbot failed to authenticate some domains (authenticator: nginx).
+An unexpected error occurred:
+Error creating new order :: too many failed authorizations recently: see https://letsencrypt.org/docs/failed-validation-limit/`,
+			wantLimit:  "failed validation",
+			wantWindow: time.Hour,
+		},
+		{
+			name:   "unrelated failure",
+			output: `Certbot failed to authenticate some domains (authenticator: nginx). The Certificate Authority reported these problems: Domain: app.example.com Type: dns Detail: DNS problem: NXDOMAIN looking up A for app.example.com`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRateLimit(tt.output)
+			if tt.wantLimit == "" {
+				if got != nil {
+					t.Fatalf("parseRateLimit() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("parseRateLimit() = nil, want a rate limit")
+			}
+			if !strings.Contains(got.Limit, tt.wantLimit) {
+				t.Errorf("Limit = %q, want it to mention %q", got.Limit, tt.wantLimit)
+			}
+			if !got.RetryAfter.Equal(tt.wantRetry) {
+				t.Errorf("RetryAfter = %v, want %v", got.RetryAfter, tt.wantRetry)
+			}
+			if got.Window != tt.wantWindow {
+				t.Errorf("Window = %v, want %v", got.Window, tt.wantWindow)
+			}
+
+			var err error = got
+			var rateLimit *RateLimitError
+			if !errors.As(err, &rateLimit) {
+				t.Error("errors.As() did not find *RateLimitError")
+			}
+			if !strings.Contains(err.Error(), "--staging") {
+				t.Errorf("Error() = %q, want a hint about --staging", err.Error())
+			}
+		})
+	}
+}
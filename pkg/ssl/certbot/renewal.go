@@ -0,0 +1,156 @@
+package certbot
+
+import (
+	"fmt"
+	"lightfold/pkg/ssh"
+	"strings"
+	"time"
+)
+
+// CronRenewalFile is the cron fallback installed when the certbot systemd
+// timer cannot be enabled
+const CronRenewalFile = "/etc/cron.d/lightfold-certbot"
+
+// cronRenewalEntry renews twice a day, as Let's Encrypt recommends
+const cronRenewalEntry = "0 0,12 * * * root certbot renew --quiet"
+
+// Renewal methods
+const (
+	RenewalTimer = "certbot.timer"
+	RenewalCron  = "cron"
+)
+
+// RenewalSchedule is how certificate renewal is scheduled on a server
+type RenewalSchedule struct {
+	Method     string    // RenewalTimer, RenewalCron, or "" when nothing renews
+	NextRun    string    // Next timer run as systemctl list-timers prints it
+	CronEntry  string    // File or crontab holding the cron entry
+	CertExpiry time.Time // Expiry of the probed certificate; zero when unknown
+}
+
+// Scheduled reports whether a timer or cron job renews certificates
+func (s RenewalSchedule) Scheduled() bool {
+	return s.Method != ""
+}
+
+func (s RenewalSchedule) String() string {
+	switch s.Method {
+	case RenewalTimer:
+		if s.NextRun != "" {
+			return fmt.Sprintf("certbot.timer, next run %s", s.NextRun)
+		}
+		return "certbot.timer"
+	case RenewalCron:
+		return "cron (" + s.CronEntry + ")"
+	}
+	return "not scheduled"
+}
+
+// renewalProbeScript reports the timer, a running cron daemon with a renew
+// entry, and the expiry of certName's certificate, one key=value per line
+func renewalProbeScript(certName string) string {
+	script := `echo "timer=$(systemctl is-active certbot.timer 2>/dev/null)"
+echo "next=$(systemctl list-timers certbot.timer --all --no-legend 2>/dev/null | head -n1)"
+if pgrep -x cron >/dev/null 2>&1 || pgrep -x crond >/dev/null 2>&1; then
+  if grep -qs 'certbot.*renew' ` + CronRenewalFile + `; then echo "cron=` + CronRenewalFile + `"
+  elif crontab -l 2>/dev/null | grep -q 'certbot.*renew'; then echo "cron=root crontab"; fi
+fi`
+	if certName != "" {
+		script += fmt.Sprintf("\necho \"expiry=$(openssl x509 -enddate -noout -in /etc/letsencrypt/live/%s/fullchain.pem 2>/dev/null | cut -d= -f2)\"", certName)
+	}
+	return script
+}
+
+// parseRenewalProbe reads renewalProbeScript's output. The timer wins over
+// cron when both are set up.
+func parseRenewalProbe(output string) RenewalSchedule {
+	var schedule RenewalSchedule
+	values := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+
+	switch {
+	case values["timer"] == "active":
+		schedule.Method = RenewalTimer
+		// "Fri 2026-10-17 05:43:12 UTC 9h left Thu ..."; "n/a" or "-" when
+		// no run is due
+		if fields := strings.Fields(values["next"]); len(fields) >= 4 && fields[0] != "n/a" && fields[0] != "-" {
+			schedule.NextRun = strings.Join(fields[:4], " ")
+		}
+	case values["cron"] != "":
+		schedule.Method = RenewalCron
+		schedule.CronEntry = values["cron"]
+	}
+
+	if expiry, err := time.Parse("Jan _2 15:04:05 2006 MST", values["expiry"]); err == nil {
+		schedule.CertExpiry = expiry
+	}
+	return schedule
+}
+
+// ProbeRenewal checks how certificate renewal is scheduled on the server and,
+// when certName is set, when that certificate expires
+func ProbeRenewal(runner ssh.SudoRunner, certName string) (RenewalSchedule, error) {
+	result := runner.ExecuteSudo(renewalProbeScript(certName))
+	if result.Error != nil {
+		return RenewalSchedule{}, fmt.Errorf("failed to check certificate renewal: %w", result.Error)
+	}
+	return parseRenewalProbe(result.Stdout), nil
+}
+
+// RenewDryRun runs `certbot renew --dry-run`, which renews every certificate
+// against the staging environment without saving anything. The error carries
+// certbot's last output line.
+func RenewDryRun(runner ssh.SudoRunner) error {
+	result := runner.ExecuteSudo("certbot renew --dry-run --no-random-sleep-on-renew 2>&1")
+	if result.Error != nil {
+		return fmt.Errorf("failed to run certbot renew --dry-run: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+		return fmt.Errorf("certbot renew --dry-run failed (exit code %d): %s", result.ExitCode, strings.TrimSpace(lines[len(lines)-1]))
+	}
+	return nil
+}
+
+// installCronRenewal writes the cron fallback for servers where the certbot
+// timer cannot run. Renewal needs root, so it goes in /etc/cron.d rather
+// than the deploy user's crontab.
+func installCronRenewal(runner ssh.SudoRunner) error {
+	result := runner.ExecuteSudo(fmt.Sprintf("printf '%%s\\n' '%s' > %s && chmod 644 %s", cronRenewalEntry, CronRenewalFile, CronRenewalFile))
+	if result.Error != nil {
+		return fmt.Errorf("failed to install cron renewal: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("cron renewal setup failed (exit code %d): %s", result.ExitCode, result.Stderr)
+	}
+	return nil
+}
+
+// EnsureRenewal enables the certbot timer, falls back to a cron entry when
+// the timer is not active afterwards, and returns the schedule it verified.
+// An error means nothing renews the certificates.
+func EnsureRenewal(runner ssh.SudoRunner) (RenewalSchedule, error) {
+	runner.ExecuteSudo("systemctl enable --now certbot.timer")
+	schedule, err := ProbeRenewal(runner, "")
+	if err != nil {
+		return schedule, err
+	}
+	if schedule.Scheduled() {
+		return schedule, nil
+	}
+
+	if err := installCronRenewal(runner); err != nil {
+		return schedule, err
+	}
+	if schedule, err = ProbeRenewal(runner, ""); err != nil {
+		return schedule, err
+	}
+	if !schedule.Scheduled() {
+		return schedule, fmt.Errorf("certificate renewal is not scheduled: certbot.timer is inactive and no cron daemon runs %s", CronRenewalFile)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,133 @@
+package certbot
+
+import (
+	"lightfold/pkg/ssh"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedRunner records sudo commands and answers the renewal probe with the
+// next of probes
+type scriptedRunner struct {
+	probes   []string
+	commands []string
+}
+
+func (r *scriptedRunner) ExecuteSudo(command string) *ssh.CommandResult {
+	r.commands = append(r.commands, command)
+	if strings.HasPrefix(command, `echo "timer=`) {
+		output := r.probes[0]
+		if len(r.probes) > 1 {
+			r.probes = r.probes[1:]
+		}
+		return &ssh.CommandResult{Stdout: output}
+	}
+	return &ssh.CommandResult{}
+}
+
+func TestParseRenewalProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   RenewalSchedule
+	}{
+		{
+			name:   "timer",
+			output: "timer=active\nnext=Fri 2026-10-17 05:43:12 UTC 9h left Thu 2026-10-16 17:01:02 UTC 3h ago certbot.timer certbot.service\ncron=",
+			want:   RenewalSchedule{Method: RenewalTimer, NextRun: "Fri 2026-10-17 05:43:12 UTC"},
+		},
+		{
+			name:   "timer without a due run",
+			output: "timer=active\nnext=n/a n/a n/a n/a certbot.timer certbot.service",
+			want:   RenewalSchedule{Method: RenewalTimer},
+		},
+		{
+			name:   "timer wins over cron",
+			output: "timer=active\nnext=\ncron=" + CronRenewalFile,
+			want:   RenewalSchedule{Method: RenewalTimer},
+		},
+		{
+			name:   "cron",
+			output: "timer=inactive\nnext=\ncron=root crontab",
+			want:   RenewalSchedule{Method: RenewalCron, CronEntry: "root crontab"},
+		},
+		{
+			name:   "nothing",
+			output: "timer=inactive\nnext=",
+			want:   RenewalSchedule{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRenewalProbe(tt.output); got != tt.want {
+				t.Errorf("parseRenewalProbe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRenewalProbe_Expiry(t *testing.T) {
+	schedule := parseRenewalProbe("timer=active\nexpiry=Jan  5 10:20:30 2027 GMT")
+	if want := time.Date(2027, 1, 5, 10, 20, 30, 0, time.UTC); !schedule.CertExpiry.Equal(want) {
+		t.Errorf("CertExpiry = %v, want %v", schedule.CertExpiry, want)
+	}
+	if schedule := parseRenewalProbe("timer=active\nexpiry="); !schedule.CertExpiry.IsZero() {
+		t.Errorf("CertExpiry without a certificate = %v, want zero", schedule.CertExpiry)
+	}
+}
+
+func TestEnsureRenewal_Timer(t *testing.T) {
+	runner := &scriptedRunner{probes: []string{"timer=active\nnext="}}
+	schedule, err := EnsureRenewal(runner)
+	if err != nil || schedule.Method != RenewalTimer {
+		t.Fatalf("EnsureRenewal() = %+v, %v; want the timer", schedule, err)
+	}
+	for _, command := range runner.commands {
+		if strings.Contains(command, CronRenewalFile+" &&") {
+			t.Errorf("cron fallback installed although the timer is active: %q", command)
+		}
+	}
+}
+
+func TestEnsureRenewal_CronFallback(t *testing.T) {
+	runner := &scriptedRunner{probes: []string{"timer=inactive", "timer=inactive\ncron=" + CronRenewalFile}}
+	schedule, err := EnsureRenewal(runner)
+	if err != nil || schedule.Method != RenewalCron || schedule.CronEntry != CronRenewalFile {
+		t.Fatalf("EnsureRenewal() = %+v, %v; want the cron fallback", schedule, err)
+	}
+	installed := false
+	for _, command := range runner.commands {
+		if strings.Contains(command, cronRenewalEntry) && strings.Contains(command, "> "+CronRenewalFile) {
+			installed = true
+		}
+	}
+	if !installed {
+		t.Errorf("cron fallback not installed; commands: %q", runner.commands)
+	}
+}
+
+func TestEnsureRenewal_NothingScheduled(t *testing.T) {
+	runner := &scriptedRunner{probes: []string{"timer=inactive"}}
+	if _, err := EnsureRenewal(runner); err == nil || !strings.Contains(err.Error(), "not scheduled") {
+		t.Errorf("EnsureRenewal() error = %v, want not scheduled", err)
+	}
+}
+
+func TestRenewDryRun(t *testing.T) {
+	failing := fakeRunnerFunc(func(string) *ssh.CommandResult {
+		return &ssh.CommandResult{ExitCode: 1, Stdout: "Processing example.com\nChallenge failed for domain example.com\n"}
+	})
+	if err := RenewDryRun(failing); err == nil || !strings.Contains(err.Error(), "Challenge failed for domain example.com") {
+		t.Errorf("RenewDryRun() error = %v, want certbot's last line", err)
+	}
+
+	passing := fakeRunnerFunc(func(string) *ssh.CommandResult { return &ssh.CommandResult{} })
+	if err := RenewDryRun(passing); err != nil {
+		t.Errorf("RenewDryRun() error = %v", err)
+	}
+}
+
+type fakeRunnerFunc func(string) *ssh.CommandResult
+
+func (f fakeRunnerFunc) ExecuteSudo(command string) *ssh.CommandResult { return f(command) }
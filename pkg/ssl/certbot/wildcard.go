@@ -0,0 +1,105 @@
+package certbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dnsPlugin is a certbot DNS plugin that solves DNS-01 challenges, which
+// wildcard certificates require
+type dnsPlugin struct {
+	Package       string // apt package providing the plugin
+	Name          string // certbot authenticator, e.g. dns-digitalocean
+	CredentialKey string // key the API token is stored under in the credentials file
+}
+
+var dnsPlugins = map[string]dnsPlugin{
+	"digitalocean": {Package: "python3-certbot-dns-digitalocean", Name: "dns-digitalocean", CredentialKey: "dns_digitalocean_token"},
+	"cloudflare":   {Package: "python3-certbot-dns-cloudflare", Name: "dns-cloudflare", CredentialKey: "dns_cloudflare_api_token"},
+}
+
+// SupportedDNSProviders returns the DNS providers wildcard certificates can be
+// issued through
+func SupportedDNSProviders() []string {
+	names := make([]string, 0, len(dnsPlugins))
+	for name := range dnsPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateDNSProvider checks that certbot can solve DNS challenges through provider
+func ValidateDNSProvider(provider string) error {
+	if _, ok := dnsPlugins[provider]; !ok {
+		return fmt.Errorf("unsupported DNS provider %q (supported: %s)", provider, strings.Join(SupportedDNSProviders(), ", "))
+	}
+	return nil
+}
+
+// IssueWildcardCertificate issues a certificate for *.domain through the DNS
+// provider's certbot plugin, authenticating with the provider's API token. The
+// certificate is named after domain. An existing valid certificate is reused.
+func (m *Manager) IssueWildcardCertificate(domain, email, dnsProvider, token string) error {
+	if m.executor == nil {
+		return fmt.Errorf("SSH executor not configured")
+	}
+	if err := ValidateDNSProvider(dnsProvider); err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("no API token for DNS provider %s", dnsProvider)
+	}
+	if email == "" {
+		email = "noreply@" + domain
+	}
+	plugin := dnsPlugins[dnsProvider]
+
+	if _, found, err := m.findCertificate([]string{"*." + domain}); err == nil && found {
+		return nil
+	}
+
+	if result := m.executor.ExecuteSudo("certbot plugins 2>/dev/null | grep -q " + plugin.Name); result.ExitCode != 0 {
+		result = m.executor.ExecuteSudo("apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y certbot " + plugin.Package)
+		if result.Error != nil || result.ExitCode != 0 {
+			return fmt.Errorf("failed to install %s: %s", plugin.Package, result.Stderr)
+		}
+	}
+
+	// The token goes through an upload rather than the command line so it
+	// never shows up in process lists or command logs
+	credentialsPath := fmt.Sprintf("/etc/letsencrypt/lightfold-%s.ini", dnsProvider)
+	tmpPath := fmt.Sprintf("/tmp/lightfold-%s-dns.ini", dnsProvider)
+	if err := m.executor.WriteRemoteFile(tmpPath, fmt.Sprintf("%s = %s\n", plugin.CredentialKey, token), 0600); err != nil {
+		return fmt.Errorf("failed to upload DNS credentials: %w", err)
+	}
+	result := m.executor.ExecuteSudo(fmt.Sprintf("mkdir -p /etc/letsencrypt && install -m 600 -o root -g root %s %s; rm -f %s", tmpPath, credentialsPath, tmpPath))
+	if result.Error != nil || result.ExitCode != 0 {
+		return fmt.Errorf("failed to install DNS credentials: %s", result.Stderr)
+	}
+
+	result = m.executor.ExecuteSudo(wildcardCommand(domain, email, plugin, credentialsPath, m.staging))
+	if result.Error != nil {
+		return fmt.Errorf("failed to execute certbot: %w", result.Error)
+	}
+	if result.ExitCode != 0 {
+		if rateLimit := parseRateLimit(result.Stdout + "\n" + result.Stderr); rateLimit != nil {
+			return rateLimit
+		}
+		return fmt.Errorf("certbot failed (exit code %d): %s", result.ExitCode, result.Stderr)
+	}
+
+	return nil
+}
+
+func wildcardCommand(domain, email string, plugin dnsPlugin, credentialsPath string, staging bool) string {
+	cmd := fmt.Sprintf(
+		"certbot certonly --%s --%s-credentials %s --%s-propagation-seconds 60 -d '*.%s' --cert-name %s --non-interactive --agree-tos --email %s",
+		plugin.Name, plugin.Name, credentialsPath, plugin.Name, domain, domain, email,
+	)
+	if staging {
+		cmd += " --test-cert --break-my-certs"
+	}
+	return cmd
+}
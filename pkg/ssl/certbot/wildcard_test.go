@@ -0,0 +1,50 @@
+package certbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWildcardCommand(t *testing.T) {
+	cmd := wildcardCommand("preview.example.com", "ops@example.com", dnsPlugins["digitalocean"], "/etc/letsencrypt/lightfold-digitalocean.ini", false)
+	for _, want := range []string{
+		"certbot certonly --dns-digitalocean",
+		"--dns-digitalocean-credentials /etc/letsencrypt/lightfold-digitalocean.ini",
+		"-d '*.preview.example.com'",
+		"--cert-name preview.example.com",
+		"--email ops@example.com",
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("wildcardCommand() = %q, missing %q", cmd, want)
+		}
+	}
+	if strings.Contains(cmd, "--test-cert") {
+		t.Error("production wildcard certificate should not use the staging environment")
+	}
+
+	staging := wildcardCommand("preview.example.com", "ops@example.com", dnsPlugins["cloudflare"], "/etc/letsencrypt/lightfold-cloudflare.ini", true)
+	if !strings.Contains(staging, "--dns-cloudflare ") || !strings.Contains(staging, "--test-cert") {
+		t.Errorf("staging wildcardCommand() = %q", staging)
+	}
+}
+
+func TestValidateDNSProvider(t *testing.T) {
+	for _, provider := range SupportedDNSProviders() {
+		if err := ValidateDNSProvider(provider); err != nil {
+			t.Errorf("ValidateDNSProvider(%q) error = %v", provider, err)
+		}
+	}
+	if err := ValidateDNSProvider("route53"); err == nil || !strings.Contains(err.Error(), "cloudflare, digitalocean") {
+		t.Errorf("ValidateDNSProvider(route53) error = %v, want the supported providers listed", err)
+	}
+}
+
+func TestFindCertificate_Wildcard(t *testing.T) {
+	certs := []certificate{{Name: "preview.example.com", Domains: []string{"*.preview.example.com"}, Valid: true}}
+	if cert, ok := findCertificate(certs, []string{"*.preview.example.com"}, false); !ok || cert.Name != "preview.example.com" {
+		t.Errorf("findCertificate() = %+v, %v, want the wildcard certificate", cert, ok)
+	}
+	if _, ok := findCertificate(certs, []string{"pr-1.preview.example.com"}, false); ok {
+		t.Error("a wildcard certificate should only match a wildcard request")
+	}
+}
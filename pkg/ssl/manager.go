@@ -13,6 +13,10 @@ type SSLManager interface {
 	// IssueCertificate issues a new SSL certificate for the given domain
 	IssueCertificate(domain string, email string) error
 
+	// CertificateExists reports whether a valid certificate covering exactly
+	// these domains is already on the server, so it can be reused instead of reissued
+	CertificateExists(domains []string) (bool, error)
+
 	// RenewCertificate renews an existing SSL certificate for the given domain
 	RenewCertificate(domain string) error
 
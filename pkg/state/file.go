@@ -0,0 +1,128 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// CorruptStateError is a state file that failed to parse and had no usable
+// backup to recover from
+type CorruptStateError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptStateError) Error() string {
+	return fmt.Sprintf("state file %s is corrupt and its backup could not be used: %v", e.Path, e.Err)
+}
+
+func (e *CorruptStateError) Unwrap() error {
+	return e.Err
+}
+
+// backupPath is where the previous version of a state file is kept
+func backupPath(path string) string {
+	return path + ".bak"
+}
+
+// decodeStateFile parses a state file, treating empty files and invalid
+// UTF-8 as corrupt rather than as an empty state
+func decodeStateFile(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("file is empty")
+	}
+	if !utf8.Valid(data) {
+		return fmt.Errorf("file is not valid UTF-8")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readStateFile loads a state file into v, upgrading it to the current schema
+// first. When the file does not parse, the backup is loaded instead and
+// written back over it, with a warning. It returns false when neither file
+// exists.
+func readStateFile(path string, schema config.Schema, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read state file: %w", err)
+	}
+	parseErr := decodeStateFile(data, v)
+	if parseErr != nil {
+		backup, err := os.ReadFile(backupPath(path))
+		if err != nil || decodeStateFile(backup, v) != nil {
+			return false, &CorruptStateError{Path: path, Err: parseErr}
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s was corrupt (%v); restored it from %s\n", path, parseErr, filepath.Base(backupPath(path)))
+		if err := config.WriteFileAtomic(path, backup); err != nil {
+			return false, fmt.Errorf("failed to restore state file from backup: %w", err)
+		}
+		data = backup
+	}
+
+	migrated, changed, err := schema.Migrate(path, data)
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		return true, nil
+	}
+	if err := writeStateFile(path, migrated); err != nil {
+		return false, fmt.Errorf("failed to write migrated state file: %w", err)
+	}
+	return true, json.Unmarshal(migrated, v)
+}
+
+// writeStateFile replaces a state file without ever leaving it half written.
+// The version being replaced is kept as the backup, unless it is corrupt.
+func writeStateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), config.PermDirectory); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if current, err := os.ReadFile(path); err == nil {
+		var probe interface{}
+		if decodeStateFile(current, &probe) == nil {
+			if err := config.WriteFileAtomic(backupPath(path), current); err != nil {
+				return fmt.Errorf("failed to back up state file: %w", err)
+			}
+		}
+	}
+	return config.WriteFileAtomic(path, data)
+}
+
+// removeStateFile deletes a state file with its backup and lock
+func removeStateFile(path string) error {
+	for _, p := range []string{path, backupPath(path), lockPath(path)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAsideCorruptState moves a target's corrupt state file out of the way so
+// its state can be rebuilt from scratch, and returns where the file went. The
+// corrupt backup is removed.
+func SetAsideCorruptState(targetName string) (string, error) {
+	statePath := GetTargetStatePath(targetName)
+	unlock, err := lockStateFile(statePath)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	corruptPath := statePath + ".corrupt"
+	if err := os.Rename(statePath, corruptPath); err != nil {
+		return "", fmt.Errorf("failed to move corrupt state file: %w", err)
+	}
+	if err := os.Remove(backupPath(statePath)); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to remove corrupt state backup: %w", err)
+	}
+	return corruptPath, nil
+}
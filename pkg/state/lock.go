@@ -0,0 +1,34 @@
+package state
+
+import (
+	"fmt"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+)
+
+// lockPath is the file parallel lightfold processes lock to take turns
+// changing a state file
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
+// lockStateFile blocks until this process holds the lock on a state file.
+// The returned function releases it.
+func lockStateFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), config.PermDirectory); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	f, err := os.OpenFile(lockPath(path), os.O_CREATE|os.O_RDWR, config.PermConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock state file: %w", err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,85 @@
+package state
+
+import (
+	"lightfold/pkg/config"
+	"sort"
+)
+
+// TargetStateSchema is the migration history of per-target state files.
+// Append new migrations; never edit or reorder released ones.
+var TargetStateSchema = config.Schema{
+	Name: "target state",
+	Migrations: []config.Migration{
+		{Description: "seed the deploy history from the last deploy", Apply: migrateDeploymentHistory},
+	},
+}
+
+// ServerStateSchema is the migration history of server state files
+var ServerStateSchema = config.Schema{
+	Name: "server state",
+	Migrations: []config.Migration{
+		{Description: "allocate ports after the ones apps already use", Apply: migrateNextPort},
+	},
+}
+
+// migrateDeploymentHistory records the last deploy of state files written
+// before deploy history existed as its first entry
+func migrateDeploymentHistory(doc map[string]interface{}) {
+	if history, ok := doc["deployments"].([]interface{}); ok && len(history) > 0 {
+		return
+	}
+	release, _ := doc["last_release"].(string)
+	commit, _ := doc["last_commit"].(string)
+	if release == "" && commit == "" {
+		return
+	}
+
+	record := map[string]interface{}{"deployed_at": doc["last_deploy"]}
+	if record["deployed_at"] == nil {
+		record["deployed_at"] = "0001-01-01T00:00:00Z"
+	}
+	if release != "" {
+		record["release"] = release
+	}
+	if commit != "" {
+		record["commit"] = commit
+	}
+	doc["deployments"] = []interface{}{record}
+}
+
+// migrateNextPort moves next_port past the ports apps hold. Early releases
+// left it at 0 or behind registered apps, so every allocation scanned from
+// the start of the range or handed out a port already in use.
+func migrateNextPort(doc map[string]interface{}) {
+	next, _ := config.IntField(doc, "next_port")
+	if next < PortRangeStart {
+		next = PortRangeStart
+	}
+
+	apps, _ := doc["deployed_apps"].([]interface{})
+	ports := []int{}
+	for _, value := range apps {
+		app, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if port, ok := config.IntField(app, "port"); ok && port > 0 {
+			app["port"] = port
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		if port >= next && port < PortRangeEnd {
+			next = port + 1
+		}
+	}
+	doc["next_port"] = next
+
+	if apps == nil {
+		doc["deployed_apps"] = []interface{}{}
+	}
+	if _, ok := doc["installed_runtimes"].([]interface{}); !ok {
+		doc["installed_runtimes"] = []interface{}{}
+	}
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"lightfold/pkg/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// assertMigration applies one migration to the old fixture and compares the
+// result with the expected one, as JSON
+func assertMigration(t *testing.T, migration config.Migration, old, want string) {
+	t.Helper()
+	var doc, wantDoc map[string]interface{}
+	if err := json.Unmarshal([]byte(old), &doc); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantDoc); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	migration.Apply(doc)
+
+	got, _ := json.Marshal(doc)
+	var gotDoc map[string]interface{}
+	json.Unmarshal(got, &gotDoc)
+	if !reflect.DeepEqual(gotDoc, wantDoc) {
+		t.Errorf("%s:\ngot  %s\nwant %s", migration.Description, got, want)
+	}
+}
+
+func TestTargetStateMigrationDeploymentHistory(t *testing.T) {
+	assertMigration(t, TargetStateSchema.Migrations[0],
+		`{"created": true, "last_commit": "abc123", "last_release": "20240101120000", "last_deploy": "2024-01-01T12:00:00Z"}`,
+		`{"created": true, "last_commit": "abc123", "last_release": "20240101120000", "last_deploy": "2024-01-01T12:00:00Z",
+			"deployments": [{"commit": "abc123", "release": "20240101120000", "deployed_at": "2024-01-01T12:00:00Z"}]}`)
+	assertMigration(t, TargetStateSchema.Migrations[0],
+		`{"created": true, "configured": true}`,
+		`{"created": true, "configured": true}`)
+	assertMigration(t, TargetStateSchema.Migrations[0],
+		`{"last_commit": "def456", "deployments": [{"commit": "abc123", "deployed_at": "2024-01-01T12:00:00Z"}]}`,
+		`{"last_commit": "def456", "deployments": [{"commit": "abc123", "deployed_at": "2024-01-01T12:00:00Z"}]}`)
+}
+
+func TestServerStateMigrationNextPort(t *testing.T) {
+	assertMigration(t, ServerStateSchema.Migrations[0],
+		`{"server_ip": "203.0.113.10", "next_port": 0, "deployed_apps": [{"app_name": "web", "port": 3000}, {"app_name": "api", "port": "3001"}], "installed_runtimes": null}`,
+		`{"server_ip": "203.0.113.10", "next_port": 3002, "deployed_apps": [{"app_name": "web", "port": 3000}, {"app_name": "api", "port": 3001}], "installed_runtimes": []}`)
+	assertMigration(t, ServerStateSchema.Migrations[0],
+		`{"server_ip": "203.0.113.10", "next_port": 3010, "deployed_apps": null}`,
+		`{"server_ip": "203.0.113.10", "next_port": 3010, "deployed_apps": [], "installed_runtimes": []}`)
+}
+
+func TestLoadStateMigratesFile(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	path := GetTargetStatePath("web")
+	if err := os.MkdirAll(filepath.Dir(path), config.PermDirectory); err != nil {
+		t.Fatal(err)
+	}
+	old := []byte(`{"created": true, "last_commit": "abc123", "last_release": "20240101120000", "last_deploy": "2024-01-01T12:00:00Z"}`)
+	if err := os.WriteFile(path, old, config.PermConfigFile); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState("web")
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if loaded.SchemaVersion != TargetStateSchema.Current() || len(loaded.Deployments) != 1 || loaded.Deployments[0].Commit != "abc123" {
+		t.Errorf("LoadState() = %+v", loaded)
+	}
+	if backup, err := os.ReadFile(backupPath(path)); err != nil || string(backup) != string(old) {
+		t.Errorf("backup = %q, %v; want the pre-migration file", backup, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"schema_version": 99}`), config.PermConfigFile); err != nil {
+		t.Fatal(err)
+	}
+	var newer *config.NewerSchemaError
+	if _, err := LoadState("web"); !errors.As(err, &newer) {
+		t.Errorf("LoadState() of a newer file = %v, want a NewerSchemaError", err)
+	}
+}
+
+func TestGetServerStateMigratesFile(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	path := GetServerStatePath("203.0.113.10")
+	if err := os.MkdirAll(filepath.Dir(path), config.PermDirectory); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"server_ip": "203.0.113.10", "deployed_apps": [{"target_name": "web", "app_name": "web", "port": 3000}]}`), config.PermConfigFile); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := GetServerState("203.0.113.10")
+	if err != nil {
+		t.Fatalf("GetServerState() error = %v", err)
+	}
+	if server.NextPort != 3001 || server.SchemaVersion != ServerStateSchema.Current() {
+		t.Errorf("GetServerState() = %+v", server)
+	}
+	if port, err := AllocatePort("203.0.113.10"); err != nil || port != 3001 {
+		t.Errorf("AllocatePort() = %d, %v; want 3001", port, err)
+	}
+}
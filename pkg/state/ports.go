@@ -13,14 +13,59 @@ const (
 
 // AllocatePort finds and allocates the next available port on a server
 func AllocatePort(serverIP string) (int, error) {
+	return AllocatePortWith(serverIP, nil)
+}
+
+// AllocatePortWith allocates the next available port on a server while
+// holding the server state lock, so parallel lightfold processes never hand
+// out the same port. When confirm is set it is asked about each candidate
+// before the allocation is saved; candidates it declines are skipped.
+func AllocatePortWith(serverIP string, confirm func(port int) (bool, error)) (int, error) {
+	var port int
+	err := updateServerState(serverIP, func(state *ServerState) error {
+		declined := make(map[int]bool)
+		for {
+			candidate, err := nextFreePort(state, declined)
+			if err != nil {
+				return err
+			}
+			if confirm != nil {
+				ok, err := confirm(candidate)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					declined[candidate] = true
+					continue
+				}
+			}
+			port = candidate
+			state.NextPort = port + 1
+			return nil
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// PeekPort returns the port AllocatePort would hand out next, without reserving it
+func PeekPort(serverIP string) (int, error) {
 	state, err := GetServerState(serverIP)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get server state: %w", err)
 	}
+	return nextFreePort(state, nil)
+}
 
+// nextFreePort finds the first port at or after NextPort not used by a
+// deployed app or listed in skip
+func nextFreePort(state *ServerState, skip map[int]bool) (int, error) {
 	// Initialize NextPort if not set
-	if state.NextPort == 0 {
-		state.NextPort = PortRangeStart
+	start := state.NextPort
+	if start < PortRangeStart || start > PortRangeEnd {
+		start = PortRangeStart
 	}
 
 	// Build map of used ports
@@ -28,17 +73,14 @@ func AllocatePort(serverIP string) (int, error) {
 	for _, app := range state.DeployedApps {
 		usedPorts[app.Port] = true
 	}
+	for port := range skip {
+		usedPorts[port] = true
+	}
 
 	// Find next available port starting from NextPort
-	port := state.NextPort
+	port := start
 	for {
-		// Check if port is available
 		if !usedPorts[port] {
-			// Found available port
-			state.NextPort = port + 1
-			if err := SaveServerState(state); err != nil {
-				return 0, fmt.Errorf("failed to save server state: %w", err)
-			}
 			return port, nil
 		}
 
@@ -50,7 +92,7 @@ func AllocatePort(serverIP string) (int, error) {
 		}
 
 		// Check if we've exhausted all ports
-		if port == state.NextPort {
+		if port == start {
 			return 0, fmt.Errorf("no available ports in range %d-%d (all %d ports are in use)", PortRangeStart, PortRangeEnd, len(usedPorts))
 		}
 	}
@@ -95,27 +137,23 @@ func IsPortAvailable(serverIP string, port int) (bool, error) {
 // ReleasePort frees up a port when an app is destroyed
 // Note: This is typically called as part of UnregisterApp
 func ReleasePort(serverIP string, port int) error {
-	state, err := GetServerState(serverIP)
-	if err != nil {
-		return fmt.Errorf("failed to get server state: %w", err)
-	}
-
-	// Remove any app using this port
-	newApps := []DeployedApp{}
-	for _, app := range state.DeployedApps {
-		if app.Port != port {
-			newApps = append(newApps, app)
+	return updateServerState(serverIP, func(state *ServerState) error {
+		// Remove any app using this port
+		newApps := []DeployedApp{}
+		for _, app := range state.DeployedApps {
+			if app.Port != port {
+				newApps = append(newApps, app)
+			}
 		}
-	}
 
-	state.DeployedApps = newApps
+		state.DeployedApps = newApps
 
-	// If NextPort is ahead of released port, potentially reuse it
-	if port < state.NextPort {
-		state.NextPort = port
-	}
-
-	return SaveServerState(state)
+		// If NextPort is ahead of released port, potentially reuse it
+		if port < state.NextPort {
+			state.NextPort = port
+		}
+		return nil
+	})
 }
 
 // GetPortStatistics returns statistics about port usage on a server
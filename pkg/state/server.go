@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"lightfold/pkg/config"
+	sshpkg "lightfold/pkg/ssh"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
@@ -14,16 +16,25 @@ type Runtime string
 
 // ServerState tracks all apps deployed to a single server
 type ServerState struct {
-	ServerIP          string        `json:"server_ip"`
-	Provider          string        `json:"provider"`           // "digitalocean", "vultr", "hetzner", "byos"
-	ServerID          string        `json:"server_id"`          // Droplet/instance ID (empty for BYOS)
-	ProxyType         string        `json:"proxy_type"`         // "caddy" or "nginx"
-	RootDomain        string        `json:"root_domain"`        // Optional: example.com
-	DeployedApps      []DeployedApp `json:"deployed_apps"`      // All apps on this server
-	InstalledRuntimes []Runtime     `json:"installed_runtimes"` // Runtimes installed on server
-	NextPort          int           `json:"next_port"`          // Next available port
-	CreatedAt         time.Time     `json:"created_at"`
-	UpdatedAt         time.Time     `json:"updated_at"`
+	SchemaVersion     int               `json:"schema_version"` // ServerStateSchema version the file is written in
+	ServerIP          string            `json:"server_ip"`
+	Provider          string            `json:"provider"`                  // "digitalocean", "vultr", "hetzner", "byos"
+	ServerID          string            `json:"server_id"`                 // Droplet/instance ID (empty for BYOS)
+	Adopted           bool              `json:"adopted,omitempty"`         // Server was created outside lightfold
+	ProxyType         string            `json:"proxy_type"`                // "caddy" or "nginx"
+	RootDomain        string            `json:"root_domain"`               // Optional: example.com
+	DeployedApps      []DeployedApp     `json:"deployed_apps"`             // All apps on this server
+	InstalledRuntimes []Runtime         `json:"installed_runtimes"`        // Runtimes installed on server
+	RuntimeUses       []RuntimeUse      `json:"runtime_uses,omitempty"`    // Runtime version each app requires
+	NextPort          int               `json:"next_port"`                 // Next available port
+	AuthorizedKeys    []string          `json:"authorized_keys,omitempty"` // Extra public keys added via 'server add-key'
+	PathRoutes        []PathRoute       `json:"path_routes,omitempty"`     // Path prefixes routed to apps on another app's domain
+	CPUCount          int               `json:"cpu_count,omitempty"`       // vCPUs reported by the server during configure
+	MemoryMB          int               `json:"memory_mb,omitempty"`       // Total memory reported by the server during configure
+	Snapshots         []Snapshot        `json:"snapshots,omitempty"`       // Provider snapshots taken via 'server snapshot'
+	Labels            map[string]string `json:"labels,omitempty"`          // Labels applied at provisioning or via 'server retag'
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
 // DeployedApp represents an app deployed to a server
@@ -36,6 +47,29 @@ type DeployedApp struct {
 	LastDeploy time.Time `json:"last_deploy"`
 }
 
+// RuntimeUse records the runtime version an app on the server requires
+type RuntimeUse struct {
+	TargetName string  `json:"target_name"`
+	Runtime    Runtime `json:"runtime"`
+	Version    string  `json:"version,omitempty"` // Major version for Node.js; empty when any version will do
+}
+
+// Snapshot records a provider snapshot of the server's disk
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PathRoute routes a path prefix on a domain owned by one app to another app
+type PathRoute struct {
+	Domain     string `json:"domain"`      // Domain owned by another app: example.com
+	PathPrefix string `json:"path_prefix"` // Prefix without trailing slash: /api
+	TargetName string `json:"target_name"` // Target serving the prefix
+	Port       int    `json:"port"`        // Port of the target serving the prefix
+}
+
 // GetServersPath returns the directory where server state files are stored
 func GetServersPath() string {
 	homeDir, err := os.UserHomeDir()
@@ -72,6 +106,7 @@ func GetServerState(serverIP string) (*ServerState, error) {
 	// Return empty state if file doesn't exist
 	if _, err := os.Stat(statePath); os.IsNotExist(err) {
 		return &ServerState{
+			SchemaVersion:     ServerStateSchema.Current(),
 			ServerIP:          serverIP,
 			DeployedApps:      []DeployedApp{},
 			InstalledRuntimes: []Runtime{},
@@ -87,6 +122,16 @@ func GetServerState(serverIP string) (*ServerState, error) {
 		return nil, fmt.Errorf("failed to read server state file: %w", err)
 	}
 
+	data, migrated, err := ServerStateSchema.Migrate(statePath, data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if err := config.WriteFileAtomic(statePath, data); err != nil {
+			return nil, fmt.Errorf("failed to write migrated server state file: %w", err)
+		}
+	}
+
 	var state ServerState
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, fmt.Errorf("failed to parse server state file: %w", err)
@@ -125,6 +170,7 @@ func SaveServerState(state *ServerState) error {
 	}
 
 	// Update timestamp
+	state.SchemaVersion = ServerStateSchema.Current()
 	state.UpdatedAt = time.Now()
 
 	// Marshal and write
@@ -133,13 +179,33 @@ func SaveServerState(state *ServerState) error {
 		return fmt.Errorf("failed to marshal server state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, config.PermConfigFile); err != nil {
+	if err := config.WriteFileAtomic(statePath, data); err != nil {
 		return fmt.Errorf("failed to write server state file: %w", err)
 	}
 
 	return nil
 }
 
+// updateServerState loads a server's state, applies update and saves it while
+// holding the server state lock, so parallel lightfold processes do not
+// overwrite each other's changes. Nothing is saved when update fails.
+func updateServerState(serverIP string, update func(state *ServerState) error) error {
+	unlock, err := lockStateFile(GetServerStatePath(serverIP))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+	if err := update(state); err != nil {
+		return err
+	}
+	return SaveServerState(state)
+}
+
 // DeleteServerState removes a server state file
 func DeleteServerState(serverIP string) error {
 	statePath := GetServerStatePath(serverIP)
@@ -157,32 +223,30 @@ func DeleteServerState(serverIP string) error {
 
 // RegisterApp adds or updates an app in the server's deployed apps list
 func RegisterApp(serverIP string, app DeployedApp) error {
-	state, err := GetServerState(serverIP)
-	if err != nil {
-		return err
-	}
-
-	// Check if app already exists
-	found := false
-	for i, existingApp := range state.DeployedApps {
-		if existingApp.TargetName == app.TargetName {
-			// Update existing app
-			state.DeployedApps[i] = app
-			found = true
-			break
+	return updateServerState(serverIP, func(state *ServerState) error {
+		// Check if app already exists
+		for i, existingApp := range state.DeployedApps {
+			if existingApp.TargetName == app.TargetName {
+				// Update existing app
+				state.DeployedApps[i] = app
+				return nil
+			}
 		}
-	}
 
-	// Add new app if not found
-	if !found {
+		// Add new app if not found
 		state.DeployedApps = append(state.DeployedApps, app)
-	}
-
-	return SaveServerState(state)
+		return nil
+	})
 }
 
 // UnregisterApp removes an app from the server's deployed apps list
 func UnregisterApp(serverIP, targetName string) error {
+	unlock, err := lockStateFile(GetServerStatePath(serverIP))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	state, err := GetServerState(serverIP)
 	if err != nil {
 		return err
@@ -198,6 +262,24 @@ func UnregisterApp(serverIP, targetName string) error {
 
 	state.DeployedApps = newApps
 
+	// Drop path routes served by the removed app
+	newRoutes := []PathRoute{}
+	for _, route := range state.PathRoutes {
+		if route.TargetName != targetName {
+			newRoutes = append(newRoutes, route)
+		}
+	}
+	state.PathRoutes = newRoutes
+
+	// Versions the removed app required are no longer protected
+	newUses := []RuntimeUse{}
+	for _, use := range state.RuntimeUses {
+		if use.TargetName != targetName {
+			newUses = append(newUses, use)
+		}
+	}
+	state.RuntimeUses = newUses
+
 	// Delete server state if no apps remain
 	if len(state.DeployedApps) == 0 {
 		return DeleteServerState(serverIP)
@@ -270,6 +352,182 @@ func RegisterRuntime(serverIP string, runtime Runtime) error {
 	return SaveServerState(state)
 }
 
+// RegisterRuntimeUse records the runtime version an app requires, replacing
+// what the app required before
+func RegisterRuntimeUse(serverIP string, use RuntimeUse) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	uses := []RuntimeUse{}
+	for _, existing := range state.RuntimeUses {
+		if existing.TargetName != use.TargetName {
+			uses = append(uses, existing)
+		}
+	}
+	state.RuntimeUses = append(uses, use)
+
+	return SaveServerState(state)
+}
+
+// OtherRuntimeUses returns the runtime versions required by apps other than targetName
+func (s *ServerState) OtherRuntimeUses(targetName string) []RuntimeUse {
+	var uses []RuntimeUse
+	for _, use := range s.RuntimeUses {
+		if use.TargetName != targetName {
+			uses = append(uses, use)
+		}
+	}
+	return uses
+}
+
+// UpdateServerResources records the server's CPU count and memory
+func UpdateServerResources(serverIP string, cpuCount, memoryMB int) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	state.CPUCount = cpuCount
+	state.MemoryMB = memoryMB
+
+	return SaveServerState(state)
+}
+
+// SetServerLabels records the labels applied to the server
+func SetServerLabels(serverIP string, labels map[string]string) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	state.Labels = labels
+
+	return SaveServerState(state)
+}
+
+// AddAuthorizedKey records an extra public key authorized on the server
+func AddAuthorizedKey(serverIP, publicKey string) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range state.AuthorizedKeys {
+		if sshpkg.SameAuthorizedKey(key, publicKey) {
+			return nil
+		}
+	}
+
+	state.AuthorizedKeys = append(state.AuthorizedKeys, publicKey)
+
+	return SaveServerState(state)
+}
+
+// RecordSnapshot records a snapshot taken of the server, replacing an earlier
+// record with the same ID
+func RecordSnapshot(serverIP string, snapshot Snapshot) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	snapshots := []Snapshot{}
+	for _, existing := range state.Snapshots {
+		if existing.ID != snapshot.ID {
+			snapshots = append(snapshots, existing)
+		}
+	}
+	state.Snapshots = append(snapshots, snapshot)
+
+	return SaveServerState(state)
+}
+
+// RemoveAuthorizedKey removes a recorded public key from the server state
+func RemoveAuthorizedKey(serverIP, publicKey string) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	newKeys := []string{}
+	for _, key := range state.AuthorizedKeys {
+		if !sshpkg.SameAuthorizedKey(key, publicKey) {
+			newKeys = append(newKeys, key)
+		}
+	}
+
+	state.AuthorizedKeys = newKeys
+
+	return SaveServerState(state)
+}
+
+// RegisterPathRoute adds or updates a path route. A domain and prefix pair can
+// only be served by one target at a time.
+func RegisterPathRoute(serverIP string, route PathRoute) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range state.PathRoutes {
+		if existing.Domain != route.Domain || existing.PathPrefix != route.PathPrefix {
+			continue
+		}
+		if existing.TargetName != route.TargetName {
+			return fmt.Errorf("%s%s is already routed to target '%s'", route.Domain, route.PathPrefix, existing.TargetName)
+		}
+		state.PathRoutes[i] = route
+		return SaveServerState(state)
+	}
+
+	state.PathRoutes = append(state.PathRoutes, route)
+
+	return SaveServerState(state)
+}
+
+// UnregisterPathRoute removes a target's route for a domain and prefix
+func UnregisterPathRoute(serverIP, domain, pathPrefix, targetName string) error {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return err
+	}
+
+	newRoutes := []PathRoute{}
+	for _, route := range state.PathRoutes {
+		if route.Domain == domain && route.PathPrefix == pathPrefix && route.TargetName == targetName {
+			continue
+		}
+		newRoutes = append(newRoutes, route)
+	}
+
+	state.PathRoutes = newRoutes
+
+	return SaveServerState(state)
+}
+
+// GetPathRoutesForDomain returns the path routes registered on a domain, ordered by prefix
+func GetPathRoutesForDomain(serverIP, domain string) ([]PathRoute, error) {
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []PathRoute
+	for _, route := range state.PathRoutes {
+		if route.Domain == domain {
+			routes = append(routes, route)
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].PathPrefix < routes[j].PathPrefix
+	})
+
+	return routes, nil
+}
+
 // ListAllServers returns a list of all server IPs with state files
 func ListAllServers() ([]string, error) {
 	serversPath := GetServersPath()
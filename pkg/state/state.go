@@ -2,14 +2,18 @@ package state
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"lightfold/pkg/config"
+	"lightfold/pkg/util"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
 type TargetState struct {
+	SchemaVersion   int       `json:"schema_version"` // TargetStateSchema version the file is written in
 	LastCommit      string    `json:"last_commit,omitempty"`
 	LastDeploy      time.Time `json:"last_deploy,omitempty"`
 	Created         bool      `json:"created"`
@@ -17,6 +21,7 @@ type TargetState struct {
 	LastRelease     string    `json:"last_release,omitempty"`
 	ProvisionedID   string    `json:"provisioned_id,omitempty"`
 	Builder         string    `json:"builder,omitempty"`
+	BuilderVersion  string    `json:"builder_version,omitempty"` // Version of the builder's tool the last build used
 	SSLConfigured   bool      `json:"ssl_configured,omitempty"`
 	LastSSLRenewal  time.Time `json:"last_ssl_renewal,omitempty"`
 	CreateFailed    bool      `json:"create_failed,omitempty"`
@@ -26,6 +31,183 @@ type TargetState struct {
 	PushFailed      bool      `json:"push_failed,omitempty"`
 	PushError       string    `json:"push_error,omitempty"`
 	LastFailure     time.Time `json:"last_failure,omitempty"`
+	// DomainServerID and DomainServerIP record the server the domain's proxy
+	// config and certificate were last applied to
+	DomainServerID string `json:"domain_server_id,omitempty"`
+	DomainServerIP string `json:"domain_server_ip,omitempty"`
+	// Paused is set while the target's servers are powered off by `lightfold pause`
+	Paused   bool      `json:"paused,omitempty"`
+	PausedAt time.Time `json:"paused_at,omitempty"`
+	// ScheduleCheckedAt is when `lightfold schedule run` last evaluated the
+	// target's power schedule; only stop and start times after it are acted on
+	ScheduleCheckedAt time.Time `json:"schedule_checked_at,omitempty"`
+	// PushCheckpoint records a push whose connection dropped during a step that
+	// is unsafe to re-run on its own; the next push resumes from it
+	PushCheckpoint *PushCheckpoint `json:"push_checkpoint,omitempty"`
+	// LastSuperseded records the last push that stopped before switching
+	// because a push started after it took over the target
+	LastSuperseded *SupersededPush `json:"last_superseded,omitempty"`
+	// Previews are the preview deployments on the target's primary server, by name
+	Previews map[string]Preview `json:"previews,omitempty"`
+	// Deployments is the target's deploy history, newest last, capped at
+	// MaxDeploymentHistory entries
+	Deployments []DeploymentRecord `json:"deployments,omitempty"`
+	// LastDeployHealth is the outcome of the last deploy's health checks
+	LastDeployHealth *DeployHealth `json:"last_deploy_health,omitempty"`
+	// Maintenance is set while `lightfold maintenance on` serves the
+	// maintenance page instead of the app
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+	// FirstDeploy is the last run of the target's first-deploy commands
+	FirstDeploy *FirstDeployRun `json:"first_deploy,omitempty"`
+	// SSLRenewal is the last check that certificate renewal is scheduled on
+	// the server
+	SSLRenewal *SSLRenewal `json:"ssl_renewal,omitempty"`
+	// BaseDir is the directory the app was last deployed under; empty for
+	// deploys from before deploy.base_dir, which all went to /srv
+	BaseDir string `json:"base_dir,omitempty"`
+	// ImportedAt is when `lightfold target import` adopted the app from a
+	// deployment made by hand
+	ImportedAt time.Time `json:"imported_at,omitempty"`
+}
+
+// Maintenance records a target put into maintenance mode
+type Maintenance struct {
+	Since time.Time `json:"since"`
+	By    string    `json:"by,omitempty"`    // Local user@host that turned it on
+	Allow []string  `json:"allow,omitempty"` // Paths still proxied to the app
+}
+
+// MaxDeploymentHistory is how many deploys TargetState.Deployments keeps
+const MaxDeploymentHistory = 50
+
+// DeploymentRecord is one entry of a target's deploy history
+type DeploymentRecord struct {
+	Commit     string    `json:"commit,omitempty"`
+	Release    string    `json:"release,omitempty"`
+	DeployedAt time.Time `json:"deployed_at"`
+	DeployedBy string    `json:"deployed_by,omitempty"` // Local user@host that ran the deploy
+	// TarballSHA256 is the checksum of the release tarball, verified on the
+	// server before it was extracted
+	TarballSHA256 string `json:"tarball_sha256,omitempty"`
+	// Seeded is set on the deploy whose first-deploy commands succeeded
+	Seeded bool `json:"seeded,omitempty"`
+}
+
+// LastDeployedBy returns who ran the target's last recorded deploy
+func (s *TargetState) LastDeployedBy() string {
+	if len(s.Deployments) == 0 {
+		return ""
+	}
+	return s.Deployments[len(s.Deployments)-1].DeployedBy
+}
+
+// Health probe outcomes
+const (
+	ProbePassed  = "passed"
+	ProbeFailed  = "failed"
+	ProbeSkipped = "skipped"
+)
+
+// SSLRenewal is the result of verifying a server's certificate renewal
+type SSLRenewal struct {
+	Method     string    `json:"method,omitempty"`      // certbot.timer or cron; empty when nothing renews
+	NextRun    string    `json:"next_run,omitempty"`    // Next timer run as systemctl reports it
+	DryRun     string    `json:"dry_run,omitempty"`     // ProbePassed or ProbeFailed after 'domain verify-renewal'
+	Error      string    `json:"error,omitempty"`       // Why verification failed
+	CertExpiry time.Time `json:"cert_expiry,omitempty"` // Expiry of the domain's certificate when checked
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Failed reports whether renewal is unscheduled or its dry run failed
+func (r *SSLRenewal) Failed() bool {
+	return r != nil && (r.Method == "" || r.DryRun == ProbeFailed)
+}
+
+// First-deploy command outcomes
+const (
+	FirstDeploySucceeded = "succeeded"
+	FirstDeployFailed    = "failed"
+)
+
+// FirstDeployRun is one run of deploy.first_deploy_commands, which seed a
+// new app once after its first healthy deploy
+type FirstDeployRun struct {
+	Release string    `json:"release"` // Release the commands ran in
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	RanAt   time.Time `json:"ran_at"`
+}
+
+// seeded reports whether the first-deploy commands succeeded in release
+func (r *FirstDeployRun) seeded(release string) bool {
+	return r != nil && r.Status == FirstDeploySucceeded && r.Release == release
+}
+
+// HealthProbe is one health check request made during a deploy
+type HealthProbe struct {
+	Status   string `json:"status"`
+	URL      string `json:"url,omitempty"`
+	HTTPCode int    `json:"http_code,omitempty"`
+	Detail   string `json:"detail,omitempty"` // Why the probe failed or was skipped
+}
+
+// DeployHealth holds the two health checks of a deploy: the app on its port,
+// then the same path through nginx
+type DeployHealth struct {
+	App       HealthProbe `json:"app"`
+	Proxy     HealthProbe `json:"proxy"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// Summary describes both probes on one line, e.g. "app HTTP 200, nginx HTTP 200"
+func (h DeployHealth) Summary() string {
+	return "app " + h.App.summary() + ", nginx " + h.Proxy.summary()
+}
+
+func (p HealthProbe) summary() string {
+	switch {
+	case p.Status == ProbePassed:
+		return fmt.Sprintf("HTTP %d", p.HTTPCode)
+	case p.Detail != "":
+		return p.Status + " (" + p.Detail + ")"
+	case p.Status == "":
+		return "not run"
+	default:
+		return p.Status
+	}
+}
+
+// Preview is a preview deployment served next to the production site
+type Preview struct {
+	Commit    string    `json:"commit,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero when the preview never expires
+}
+
+// Expired reports whether the preview's TTL ran out before now
+func (p Preview) Expired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && now.After(p.ExpiresAt)
+}
+
+// SupersededPush is a push that gave way to a newer one
+type SupersededPush struct {
+	Release string    `json:"release"`
+	Commit  string    `json:"commit,omitempty"` // Commit the superseded push deployed
+	By      string    `json:"by,omitempty"`     // Commit of the push that took over, when known
+	At      time.Time `json:"at"`
+}
+
+// PushCheckpoint is where an interrupted push stopped
+type PushCheckpoint struct {
+	Release  string    `json:"release"`          // Release timestamp already uploaded to the servers
+	Commit   string    `json:"commit,omitempty"` // Commit the release was built from
+	Step     string    `json:"step"`             // Step the connection dropped in, e.g. "build" or "restart"
+	ServerIP string    `json:"server_ip,omitempty"`
+	At       time.Time `json:"at"`
+	// TarballSHA256 is the checksum of the tarball the release was uploaded from
+	TarballSHA256 string `json:"tarball_sha256,omitempty"`
 }
 
 func GetStatePath() string {
@@ -40,6 +222,8 @@ func GetTargetStatePath(targetName string) string {
 	return filepath.Join(GetStatePath(), targetName+".json")
 }
 
+// LoadState reads a target's state. A target without a state file has an
+// empty state; a corrupt file is recovered from its backup.
 func LoadState(targetName string) (*TargetState, error) {
 	statePath := GetTargetStatePath(targetName)
 
@@ -48,78 +232,96 @@ func LoadState(targetName string) (*TargetState, error) {
 		return nil, fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		return &TargetState{}, nil
-	}
-
-	data, err := os.ReadFile(statePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %w", err)
-	}
-
 	var state TargetState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	if _, err := readStateFile(statePath, TargetStateSchema, &state); err != nil {
+		var corrupt *CorruptStateError
+		if errors.As(err, &corrupt) {
+			return nil, fmt.Errorf("%w; run 'lightfold state repair --target %s' to rebuild it from the server", err, targetName)
+		}
+		return nil, err
 	}
 
 	return &state, nil
 }
 
+// SaveState replaces a target's state, holding the state lock while it writes
 func SaveState(targetName string, state *TargetState) error {
-	statePath := GetTargetStatePath(targetName)
-
-	stateDir := filepath.Dir(statePath)
-	if err := os.MkdirAll(stateDir, config.PermDirectory); err != nil {
-		return fmt.Errorf("failed to create state directory: %w", err)
+	unlock, err := lockStateFile(GetTargetStatePath(targetName))
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
+	return saveState(targetName, state)
+}
+
+func saveState(targetName string, state *TargetState) error {
+	state.SchemaVersion = TargetStateSchema.Current()
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, config.PermConfigFile); err != nil {
+	if err := writeStateFile(GetTargetStatePath(targetName), data); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
 	return nil
 }
 
-func MarkCreated(targetName string, provisionedID string) error {
-	state, err := LoadState(targetName)
+// updateState loads a target's state, applies update and saves it while
+// holding the state lock, so parallel lightfold processes do not overwrite
+// each other's changes
+func updateState(targetName string, update func(state *TargetState)) error {
+	unlock, err := lockStateFile(GetTargetStatePath(targetName))
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	state.Created = true
-	if provisionedID != "" {
-		state.ProvisionedID = provisionedID
-	}
-
-	return SaveState(targetName, state)
-}
-
-func MarkConfigured(targetName string) error {
 	state, err := LoadState(targetName)
 	if err != nil {
 		return err
 	}
-
-	state.Configured = true
-	return SaveState(targetName, state)
+	update(state)
+	return saveState(targetName, state)
 }
 
-func UpdateDeployment(targetName, commitHash, releaseTimestamp string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
+func MarkCreated(targetName string, provisionedID string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Created = true
+		if provisionedID != "" {
+			state.ProvisionedID = provisionedID
+		}
+	})
+}
 
-	state.LastCommit = commitHash
-	state.LastDeploy = time.Now()
-	state.LastRelease = releaseTimestamp
+func MarkConfigured(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Configured = true
+	})
+}
 
-	return SaveState(targetName, state)
+// UpdateDeployment records a finished deploy and appends it to the deploy
+// history. tarballSHA256 is empty for deploys that upload no tarball.
+func UpdateDeployment(targetName, commitHash, releaseTimestamp, tarballSHA256 string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.LastCommit = commitHash
+		state.LastDeploy = time.Now()
+		state.LastRelease = releaseTimestamp
+
+		state.Deployments = append(state.Deployments, DeploymentRecord{
+			Commit:        commitHash,
+			Release:       releaseTimestamp,
+			DeployedAt:    state.LastDeploy,
+			DeployedBy:    util.LocalIdentity(),
+			TarballSHA256: tarballSHA256,
+			Seeded:        state.FirstDeploy.seeded(releaseTimestamp),
+		})
+		if len(state.Deployments) > MaxDeploymentHistory {
+			state.Deployments = state.Deployments[len(state.Deployments)-MaxDeploymentHistory:]
+		}
+	})
 }
 
 func IsCreated(targetName string) bool {
@@ -154,14 +356,13 @@ func GetProvisionedID(targetName string) string {
 	return state.ProvisionedID
 }
 
-func UpdateBuilder(targetName, builder string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
-
-	state.Builder = builder
-	return SaveState(targetName, state)
+// UpdateBuilder records the builder the last build used and its version, ""
+// when unknown
+func UpdateBuilder(targetName, builder, version string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Builder = builder
+		state.BuilderVersion = version
+	})
 }
 
 func GetTargetState(targetName string) (*TargetState, error) {
@@ -169,13 +370,7 @@ func GetTargetState(targetName string) (*TargetState, error) {
 }
 
 func DeleteState(targetName string) error {
-	statePath := GetTargetStatePath(targetName)
-
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		return nil
-	}
-
-	if err := os.Remove(statePath); err != nil {
+	if err := removeStateFile(GetTargetStatePath(targetName)); err != nil {
 		return fmt.Errorf("failed to delete state file: %w", err)
 	}
 
@@ -183,26 +378,122 @@ func DeleteState(targetName string) error {
 }
 
 func MarkSSLConfigured(targetName string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
+	return updateState(targetName, func(state *TargetState) {
+		state.SSLConfigured = true
+		state.LastSSLRenewal = time.Now()
+	})
+}
+
+// RecordSSLRenewal saves the last certificate renewal verification
+func RecordSSLRenewal(targetName string, renewal SSLRenewal) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.SSLRenewal = &renewal
+	})
+}
+
+// RecordBaseDir saves the directory the target's app was deployed under
+func RecordBaseDir(targetName, baseDir string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.BaseDir = baseDir
+	})
+}
+
+// RecordImport marks a target adopted from an app deployed by hand as
+// created and configured, with the release it runs as the last deploy
+func RecordImport(targetName, release, baseDir string, deployedAt time.Time) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Created = true
+		state.Configured = true
+		state.LastRelease = release
+		state.LastDeploy = deployedAt
+		state.BaseDir = baseDir
+		state.ImportedAt = time.Now()
+	})
+}
+
+// MarkDomainApplied records that the domain config was applied to the given server
+func MarkDomainApplied(targetName, serverID, serverIP string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.DomainServerID = serverID
+		state.DomainServerIP = serverIP
+	})
+}
+
+// ClearDomainApplied forgets where the domain config was applied, e.g. after the domain is removed
+func ClearDomainApplied(targetName string) error {
+	return MarkDomainApplied(targetName, "", "")
+}
+
+// DomainDrifted reports whether the domain config was applied to a different
+// server than the given one. It is false when no server has been recorded yet.
+func (s *TargetState) DomainDrifted(serverID, serverIP string) bool {
+	if s.DomainServerIP == "" && s.DomainServerID == "" {
+		return false
+	}
+	if serverID != "" && s.DomainServerID != "" && serverID != s.DomainServerID {
+		return true
 	}
+	return serverIP != "" && s.DomainServerIP != "" && serverIP != s.DomainServerIP
+}
+
+// MarkPaused records that the target's servers were powered off
+func MarkPaused(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Paused = true
+		state.PausedAt = time.Now()
+	})
+}
 
-	state.SSLConfigured = true
-	state.LastSSLRenewal = time.Now()
+// MarkResumed clears the paused flag once the servers are back up
+func MarkResumed(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Paused = false
+		state.PausedAt = time.Time{}
+	})
+}
 
-	return SaveState(targetName, state)
+// MarkMaintenance records that the target serves its maintenance page
+func MarkMaintenance(targetName string, allow []string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Maintenance = &Maintenance{Since: time.Now().UTC(), By: util.LocalIdentity(), Allow: allow}
+	})
 }
 
-func UpdateSSLRenewal(targetName string) error {
+// ClearMaintenance records that the target serves the app again
+func ClearMaintenance(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.Maintenance = nil
+	})
+}
+
+// InMaintenance reports whether the target was put into maintenance mode
+func InMaintenance(targetName string) bool {
 	state, err := LoadState(targetName)
 	if err != nil {
-		return err
+		return false
 	}
+	return state.Maintenance != nil
+}
 
-	state.LastSSLRenewal = time.Now()
+// RecordScheduleCheck records when the target's power schedule was evaluated
+func RecordScheduleCheck(targetName string, at time.Time) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.ScheduleCheckedAt = at
+	})
+}
 
-	return SaveState(targetName, state)
+func IsPaused(targetName string) bool {
+	state, err := LoadState(targetName)
+	if err != nil {
+		return false
+	}
+	return state.Paused
+}
+
+func UpdateSSLRenewal(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.LastSSLRenewal = time.Now()
+	})
 }
 
 func IsSSLConfigured(targetName string) bool {
@@ -222,76 +513,134 @@ func GetLastSSLRenewal(targetName string) time.Time {
 }
 
 func MarkConfigureFailed(targetName string, errMsg string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
-
-	state.ConfigureFailed = true
-	state.ConfigureError = errMsg
-	state.LastFailure = time.Now()
-
-	return SaveState(targetName, state)
+	return updateState(targetName, func(state *TargetState) {
+		state.ConfigureFailed = true
+		state.ConfigureError = errMsg
+		state.LastFailure = time.Now()
+	})
 }
 
 func MarkPushFailed(targetName string, errMsg string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
+	return updateState(targetName, func(state *TargetState) {
+		state.PushFailed = true
+		state.PushError = errMsg
+		state.LastFailure = time.Now()
+	})
+}
 
-	state.PushFailed = true
-	state.PushError = errMsg
-	state.LastFailure = time.Now()
+func ClearConfigureFailure(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.ConfigureFailed = false
+		state.ConfigureError = ""
+	})
+}
 
-	return SaveState(targetName, state)
+// MarkPushInterrupted records a push failure together with the checkpoint the
+// next push resumes from
+func MarkPushInterrupted(targetName, errMsg string, checkpoint PushCheckpoint) error {
+	return updateState(targetName, func(state *TargetState) {
+		checkpoint.At = time.Now()
+		state.PushFailed = true
+		state.PushError = errMsg
+		state.LastFailure = checkpoint.At
+		state.PushCheckpoint = &checkpoint
+	})
 }
 
-func ClearConfigureFailure(targetName string) error {
+// GetPushCheckpoint returns the checkpoint of an interrupted push, or nil
+func GetPushCheckpoint(targetName string) *PushCheckpoint {
 	state, err := LoadState(targetName)
 	if err != nil {
-		return err
+		return nil
 	}
+	return state.PushCheckpoint
+}
 
-	state.ConfigureFailed = false
-	state.ConfigureError = ""
-
-	return SaveState(targetName, state)
+// MarkPushSuperseded records a push that stopped because a newer push took over.
+// It is not a failure, so the push failure flags are left alone.
+func MarkPushSuperseded(targetName string, push SupersededPush) error {
+	return updateState(targetName, func(state *TargetState) {
+		push.At = time.Now()
+		state.LastSuperseded = &push
+	})
 }
 
 func ClearPushFailure(targetName string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
+	return updateState(targetName, func(state *TargetState) {
+		state.PushFailed = false
+		state.PushError = ""
+		state.PushCheckpoint = nil
+	})
+}
 
-	state.PushFailed = false
-	state.PushError = ""
+func MarkCreateFailed(targetName string, errMsg string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.CreateFailed = true
+		state.CreateError = errMsg
+		state.LastFailure = time.Now()
+	})
+}
 
-	return SaveState(targetName, state)
+func ClearCreateFailure(targetName string) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.CreateFailed = false
+		state.CreateError = ""
+	})
 }
 
-func MarkCreateFailed(targetName string, errMsg string) error {
-	state, err := LoadState(targetName)
-	if err != nil {
-		return err
-	}
+// RecordFirstDeploy saves the outcome of the first-deploy commands.
+// UpdateDeployment then marks the deploy of run.Release as seeded when they
+// succeeded.
+func RecordFirstDeploy(targetName string, run FirstDeployRun) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.FirstDeploy = &run
+	})
+}
 
-	state.CreateFailed = true
-	state.CreateError = errMsg
-	state.LastFailure = time.Now()
+// RecordDeployHealth saves the health check results of the target's last deploy
+func RecordDeployHealth(targetName string, health DeployHealth) error {
+	return updateState(targetName, func(state *TargetState) {
+		state.LastDeployHealth = &health
+	})
+}
 
-	return SaveState(targetName, state)
+// RecordPreview saves a preview deployment. Pushing an existing preview again
+// keeps its creation time.
+func RecordPreview(targetName, name string, preview Preview) error {
+	return updateState(targetName, func(state *TargetState) {
+		now := time.Now()
+		preview.CreatedAt = now
+		if existing, ok := state.Previews[name]; ok && !existing.CreatedAt.IsZero() {
+			preview.CreatedAt = existing.CreatedAt
+		}
+		preview.UpdatedAt = now
+		if state.Previews == nil {
+			state.Previews = make(map[string]Preview)
+		}
+		state.Previews[name] = preview
+	})
 }
 
-func ClearCreateFailure(targetName string) error {
+// RemovePreview forgets a preview deployment
+func RemovePreview(targetName, name string) error {
+	return updateState(targetName, func(state *TargetState) {
+		delete(state.Previews, name)
+	})
+}
+
+// ExpiredPreviews returns the names of the target's previews whose TTL ran out
+func ExpiredPreviews(targetName string, now time.Time) []string {
 	state, err := LoadState(targetName)
 	if err != nil {
-		return err
+		return nil
 	}
 
-	state.CreateFailed = false
-	state.CreateError = ""
-
-	return SaveState(targetName, state)
+	var names []string
+	for name, preview := range state.Previews {
+		if preview.Expired(now) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
@@ -1,8 +1,12 @@
 package state
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -143,7 +147,7 @@ func TestUpdateDeployment(t *testing.T) {
 	releaseTimestamp := "20231003120000"
 
 	// Update deployment
-	if err := UpdateDeployment(targetName, commitHash, releaseTimestamp); err != nil {
+	if err := UpdateDeployment(targetName, commitHash, releaseTimestamp, "9f86d081884c7d65"); err != nil {
 		t.Fatalf("Failed to update deployment: %v", err)
 	}
 
@@ -162,6 +166,73 @@ func TestUpdateDeployment(t *testing.T) {
 	if state.LastDeploy.IsZero() {
 		t.Error("Expected LastDeploy to be set")
 	}
+	if len(state.Deployments) != 1 || state.Deployments[0].TarballSHA256 != "9f86d081884c7d65" {
+		t.Errorf("Expected the tarball checksum in the deploy history, got %+v", state.Deployments)
+	}
+}
+
+func TestUpdateDeploymentRecordsHistory(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	for i := 0; i < MaxDeploymentHistory+2; i++ {
+		if err := UpdateDeployment(targetName, fmt.Sprintf("commit%d", i), fmt.Sprintf("2023100312%04d", i), ""); err != nil {
+			t.Fatalf("Failed to update deployment: %v", err)
+		}
+	}
+
+	state, err := LoadState(targetName)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	if len(state.Deployments) != MaxDeploymentHistory {
+		t.Fatalf("Expected %d deployments, got %d", MaxDeploymentHistory, len(state.Deployments))
+	}
+	if first := state.Deployments[0].Commit; first != "commit2" {
+		t.Errorf("Expected oldest kept deployment commit2, got %s", first)
+	}
+	last := state.Deployments[len(state.Deployments)-1]
+	if last.Commit != state.LastCommit || last.Release != state.LastRelease {
+		t.Errorf("Expected last deployment to match LastCommit/LastRelease, got %+v", last)
+	}
+	if last.DeployedBy == "" || !strings.Contains(last.DeployedBy, "@") {
+		t.Errorf("Expected DeployedBy as user@host, got %q", last.DeployedBy)
+	}
+	if state.LastDeployedBy() != last.DeployedBy {
+		t.Errorf("LastDeployedBy() = %q, want %q", state.LastDeployedBy(), last.DeployedBy)
+	}
+}
+
+func TestRecordFirstDeployMarksSeededDeploy(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	if err := RecordFirstDeploy(targetName, FirstDeployRun{Release: "20231003120000", Status: FirstDeployFailed, Error: "exit code 1: boom", RanAt: time.Now()}); err != nil {
+		t.Fatalf("RecordFirstDeploy() error: %v", err)
+	}
+	if err := UpdateDeployment(targetName, "commit1", "20231003120000", ""); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	if err := RecordFirstDeploy(targetName, FirstDeployRun{Release: "20231003130000", Status: FirstDeploySucceeded, RanAt: time.Now()}); err != nil {
+		t.Fatalf("RecordFirstDeploy() error: %v", err)
+	}
+	if err := UpdateDeployment(targetName, "commit2", "20231003130000", ""); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	state, err := LoadState(targetName)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if state.FirstDeploy == nil || state.FirstDeploy.Status != FirstDeploySucceeded {
+		t.Errorf("FirstDeploy = %+v, want the successful retry", state.FirstDeploy)
+	}
+	if len(state.Deployments) != 2 || state.Deployments[0].Seeded || !state.Deployments[1].Seeded {
+		t.Errorf("Expected only the second deploy marked seeded, got %+v", state.Deployments)
+	}
 }
 
 func TestIsCreated(t *testing.T) {
@@ -221,7 +292,7 @@ func TestGetLastCommit(t *testing.T) {
 
 	// Update deployment
 	expectedCommit := "abc123def456"
-	if err := UpdateDeployment(targetName, expectedCommit, "20231003120000"); err != nil {
+	if err := UpdateDeployment(targetName, expectedCommit, "20231003120000", ""); err != nil {
 		t.Fatalf("Failed to update deployment: %v", err)
 	}
 
@@ -348,3 +419,417 @@ func TestMultipleTargets(t *testing.T) {
 		t.Error("Expected target-1 and target-3 to still exist")
 	}
 }
+
+func TestMarkDomainApplied(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	if err := MarkDomainApplied(targetName, "12345", "203.0.113.10"); err != nil {
+		t.Fatalf("MarkDomainApplied failed: %v", err)
+	}
+
+	state, err := LoadState(targetName)
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if state.DomainServerID != "12345" || state.DomainServerIP != "203.0.113.10" {
+		t.Errorf("Expected domain applied to 12345/203.0.113.10, got %s/%s", state.DomainServerID, state.DomainServerIP)
+	}
+
+	if err := ClearDomainApplied(targetName); err != nil {
+		t.Fatalf("ClearDomainApplied failed: %v", err)
+	}
+	state, _ = LoadState(targetName)
+	if state.DomainServerID != "" || state.DomainServerIP != "" {
+		t.Errorf("Expected domain record cleared, got %s/%s", state.DomainServerID, state.DomainServerIP)
+	}
+}
+
+func TestDomainDrifted(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    TargetState
+		serverID string
+		serverIP string
+		want     bool
+	}{
+		{"nothing recorded", TargetState{}, "1", "203.0.113.10", false},
+		{"same server", TargetState{DomainServerID: "1", DomainServerIP: "203.0.113.10"}, "1", "203.0.113.10", false},
+		{"new IP", TargetState{DomainServerID: "1", DomainServerIP: "203.0.113.10"}, "1", "203.0.113.20", true},
+		{"rebuilt server", TargetState{DomainServerID: "1", DomainServerIP: "203.0.113.10"}, "2", "203.0.113.10", true},
+		{"BYOS IP only", TargetState{DomainServerIP: "203.0.113.10"}, "", "203.0.113.20", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.DomainDrifted(tt.serverID, tt.serverIP); got != tt.want {
+				t.Errorf("DomainDrifted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkPausedAndResumed(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	if err := MarkCreated(targetName, "server-123"); err != nil {
+		t.Fatalf("Failed to mark created: %v", err)
+	}
+
+	if IsPaused(targetName) {
+		t.Fatal("New target should not be paused")
+	}
+
+	if err := MarkPaused(targetName); err != nil {
+		t.Fatalf("MarkPaused() error: %v", err)
+	}
+	state, _ := LoadState(targetName)
+	if !state.Paused || state.PausedAt.IsZero() {
+		t.Errorf("MarkPaused() state = %+v, want paused with a timestamp", state)
+	}
+	if !state.Created || state.ProvisionedID != "server-123" {
+		t.Error("MarkPaused() should keep the rest of the state")
+	}
+
+	if err := MarkResumed(targetName); err != nil {
+		t.Fatalf("MarkResumed() error: %v", err)
+	}
+	if IsPaused(targetName) {
+		t.Error("Target should not be paused after MarkResumed()")
+	}
+}
+
+func TestMarkAndClearMaintenance(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	if InMaintenance(targetName) {
+		t.Fatal("New target should not be in maintenance")
+	}
+
+	if err := MarkMaintenance(targetName, []string{"/healthz"}); err != nil {
+		t.Fatalf("MarkMaintenance() error: %v", err)
+	}
+	state, _ := LoadState(targetName)
+	if state.Maintenance == nil || state.Maintenance.Since.IsZero() || len(state.Maintenance.Allow) != 1 {
+		t.Errorf("MarkMaintenance() state = %+v, want maintenance with a timestamp and allowed paths", state.Maintenance)
+	}
+	if !InMaintenance(targetName) {
+		t.Error("InMaintenance() = false after MarkMaintenance()")
+	}
+
+	if err := ClearMaintenance(targetName); err != nil {
+		t.Fatalf("ClearMaintenance() error: %v", err)
+	}
+	if InMaintenance(targetName) {
+		t.Error("Target should not be in maintenance after ClearMaintenance()")
+	}
+}
+
+func TestPushCheckpoint(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	if GetPushCheckpoint(targetName) != nil {
+		t.Fatal("New target should have no push checkpoint")
+	}
+
+	checkpoint := PushCheckpoint{Release: "20260101120000", Commit: "abc123", Step: "build", ServerIP: "203.0.113.10"}
+	if err := MarkPushInterrupted(targetName, "connection lost", checkpoint); err != nil {
+		t.Fatalf("MarkPushInterrupted() error: %v", err)
+	}
+
+	got := GetPushCheckpoint(targetName)
+	if got == nil || got.Release != checkpoint.Release || got.Step != "build" || got.At.IsZero() {
+		t.Fatalf("GetPushCheckpoint() = %+v, want the recorded checkpoint", got)
+	}
+	state, _ := LoadState(targetName)
+	if !state.PushFailed || state.PushError != "connection lost" {
+		t.Errorf("MarkPushInterrupted() should record the push failure, got %+v", state)
+	}
+
+	if err := ClearPushFailure(targetName); err != nil {
+		t.Fatalf("ClearPushFailure() error: %v", err)
+	}
+	if GetPushCheckpoint(targetName) != nil {
+		t.Error("ClearPushFailure() should drop the checkpoint")
+	}
+}
+
+func TestRecordSnapshot(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	serverIP := "203.0.113.10"
+	if err := SaveServerState(&ServerState{ServerIP: serverIP, Provider: "hetzner"}); err != nil {
+		t.Fatalf("SaveServerState() error: %v", err)
+	}
+
+	first := Snapshot{ID: "101", Name: "before-configure", Provider: "hetzner", CreatedAt: time.Now()}
+	if err := RecordSnapshot(serverIP, first); err != nil {
+		t.Fatalf("RecordSnapshot() error: %v", err)
+	}
+	if err := RecordSnapshot(serverIP, Snapshot{ID: "102", Name: "nightly", Provider: "hetzner"}); err != nil {
+		t.Fatalf("RecordSnapshot() error: %v", err)
+	}
+	first.Name = "renamed"
+	if err := RecordSnapshot(serverIP, first); err != nil {
+		t.Fatalf("RecordSnapshot() error: %v", err)
+	}
+
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		t.Fatalf("GetServerState() error: %v", err)
+	}
+	if len(state.Snapshots) != 2 {
+		t.Fatalf("Snapshots = %+v, want 2 records", state.Snapshots)
+	}
+	if last := state.Snapshots[1]; last.ID != "101" || last.Name != "renamed" {
+		t.Errorf("re-recorded snapshot = %+v, want the updated record last", last)
+	}
+}
+
+func TestRecordPreview(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	now := time.Now()
+
+	if err := RecordPreview(targetName, "pr-142", Preview{Commit: "abc123", ExpiresAt: now.Add(-time.Hour)}); err != nil {
+		t.Fatalf("RecordPreview() error: %v", err)
+	}
+	if err := RecordPreview(targetName, "pr-150", Preview{Commit: "def456"}); err != nil {
+		t.Fatalf("RecordPreview() error: %v", err)
+	}
+
+	state, _ := LoadState(targetName)
+	created := state.Previews["pr-142"].CreatedAt
+	if created.IsZero() {
+		t.Fatal("RecordPreview() should set the creation time")
+	}
+
+	if expired := ExpiredPreviews(targetName, now); len(expired) != 1 || expired[0] != "pr-142" {
+		t.Errorf("ExpiredPreviews() = %v, want [pr-142]; previews without a TTL never expire", expired)
+	}
+
+	if err := RecordPreview(targetName, "pr-142", Preview{Commit: "abc999", ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("RecordPreview() error: %v", err)
+	}
+	state, _ = LoadState(targetName)
+	if preview := state.Previews["pr-142"]; preview.Commit != "abc999" || !preview.CreatedAt.Equal(created) {
+		t.Errorf("re-pushed preview = %+v, want the new commit and the original creation time", preview)
+	}
+	if expired := ExpiredPreviews(targetName, now); len(expired) != 0 {
+		t.Errorf("ExpiredPreviews() = %v, want none after the TTL was renewed", expired)
+	}
+
+	if err := RemovePreview(targetName, "pr-142"); err != nil {
+		t.Fatalf("RemovePreview() error: %v", err)
+	}
+	state, _ = LoadState(targetName)
+	if _, ok := state.Previews["pr-142"]; ok || len(state.Previews) != 1 {
+		t.Errorf("Previews = %+v, want only pr-150", state.Previews)
+	}
+}
+
+func TestRegisterRuntimeUse(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	serverIP := "203.0.113.20"
+	for _, app := range []string{"app-a", "app-b"} {
+		if err := RegisterApp(serverIP, DeployedApp{TargetName: app, AppName: app}); err != nil {
+			t.Fatalf("RegisterApp() error: %v", err)
+		}
+	}
+	uses := []RuntimeUse{
+		{TargetName: "app-a", Runtime: "nodejs", Version: "20"},
+		{TargetName: "app-b", Runtime: "nodejs", Version: "22"},
+		{TargetName: "app-a", Runtime: "nodejs", Version: "18"}, // app-a changed its pin
+	}
+	for _, use := range uses {
+		if err := RegisterRuntimeUse(serverIP, use); err != nil {
+			t.Fatalf("RegisterRuntimeUse() error: %v", err)
+		}
+	}
+
+	state, err := GetServerState(serverIP)
+	if err != nil {
+		t.Fatalf("GetServerState() error: %v", err)
+	}
+	if len(state.RuntimeUses) != 2 {
+		t.Fatalf("RuntimeUses = %+v, want one per app", state.RuntimeUses)
+	}
+	others := state.OtherRuntimeUses("app-b")
+	if len(others) != 1 || others[0].TargetName != "app-a" || others[0].Version != "18" {
+		t.Errorf("OtherRuntimeUses(app-b) = %+v, want app-a's latest pin", others)
+	}
+
+	if err := UnregisterApp(serverIP, "app-a"); err != nil {
+		t.Fatalf("UnregisterApp() error: %v", err)
+	}
+	state, _ = GetServerState(serverIP)
+	if len(state.RuntimeUses) != 1 || state.RuntimeUses[0].TargetName != "app-b" {
+		t.Errorf("RuntimeUses after removing app-a = %+v", state.RuntimeUses)
+	}
+}
+
+func TestLoadStateRecoversFromBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt []byte
+	}{
+		{"truncated JSON", []byte(`{"created": true, "last_rel`)},
+		{"empty file", []byte{}},
+		{"invalid UTF-8", []byte("{\"created\": true, \"last_commit\": \"\xff\xfe\"}")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupTestStateDir(t)
+			defer cleanup()
+
+			targetName := "test-target"
+			if err := SaveState(targetName, &TargetState{Created: true, LastCommit: "abc123"}); err != nil {
+				t.Fatalf("SaveState() error: %v", err)
+			}
+			if err := SaveState(targetName, &TargetState{Created: true, LastCommit: "def456"}); err != nil {
+				t.Fatalf("SaveState() error: %v", err)
+			}
+
+			statePath := GetTargetStatePath(targetName)
+			if err := os.WriteFile(statePath, tt.corrupt, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := LoadState(targetName)
+			if err != nil {
+				t.Fatalf("LoadState() error: %v", err)
+			}
+			if state.LastCommit != "abc123" {
+				t.Errorf("LastCommit = %q, want the backup's abc123", state.LastCommit)
+			}
+
+			// The primary is restored from the backup
+			data, err := os.ReadFile(statePath)
+			if err != nil || !strings.Contains(string(data), "abc123") {
+				t.Errorf("state file after recovery = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestLoadStateCorruptWithoutBackup(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	statePath := GetTargetStatePath(targetName)
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath, []byte(`{"created": tr`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backupPath(statePath), []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadState(targetName)
+	var corrupt *CorruptStateError
+	if !errors.As(err, &corrupt) || !strings.Contains(err.Error(), "lightfold state repair --target test-target") {
+		t.Fatalf("LoadState() = %v, want a CorruptStateError suggesting state repair", err)
+	}
+
+	corruptPath, err := SetAsideCorruptState(targetName)
+	if err != nil {
+		t.Fatalf("SetAsideCorruptState() error: %v", err)
+	}
+	if _, err := os.Stat(corruptPath); err != nil {
+		t.Errorf("corrupt file not kept at %s: %v", corruptPath, err)
+	}
+	state, err := LoadState(targetName)
+	if err != nil || state.Created {
+		t.Errorf("LoadState() after setting aside = %+v, %v, want an empty state", state, err)
+	}
+}
+
+func TestSaveStateKeepsValidBackup(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	statePath := GetTargetStatePath(targetName)
+	if err := SaveState(targetName, &TargetState{LastCommit: "abc123"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath, []byte(`{"last_`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A corrupt primary must not replace the good backup
+	if err := writeStateFile(statePath, []byte(`{"last_commit": "def456"}`)); err != nil {
+		t.Fatalf("writeStateFile() error: %v", err)
+	}
+	if err := SaveState(targetName, &TargetState{LastCommit: "0a1b2c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := os.ReadFile(backupPath(statePath))
+	if err != nil || !strings.Contains(string(backup), "def456") {
+		t.Errorf("backup = %q, %v, want the previous version", backup, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(statePath))
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+}
+
+func TestUpdateStateConcurrentWriters(t *testing.T) {
+	_, cleanup := setupTestStateDir(t)
+	defer cleanup()
+
+	targetName := "test-target"
+	const writers = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := UpdateDeployment(targetName, fmt.Sprintf("commit-%d", i), fmt.Sprintf("release-%d", i), ""); err != nil {
+				t.Errorf("UpdateDeployment() error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	state, err := LoadState(targetName)
+	if err != nil {
+		t.Fatalf("LoadState() error: %v", err)
+	}
+	if len(state.Deployments) != writers {
+		t.Errorf("len(Deployments) = %d, want %d; concurrent updates were lost", len(state.Deployments), writers)
+	}
+}
+
+func TestDeployHealthSummary(t *testing.T) {
+	health := DeployHealth{
+		App:   HealthProbe{Status: ProbePassed, HTTPCode: 200},
+		Proxy: HealthProbe{Status: ProbeFailed, HTTPCode: 502, Detail: "expected status 200, got 502"},
+	}
+	if got, want := health.Summary(), "app HTTP 200, nginx failed (expected status 200, got 502)"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if got, want := (DeployHealth{App: HealthProbe{Status: ProbeSkipped}}).Summary(), "app skipped, nginx not run"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+// Package timefmt renders timestamps for output. JSON carries RFC 3339 in
+// UTC; people get an absolute time in their own time zone, or in UTC with
+// --utc, next to a relative form such as "2h ago". Release names are UTC
+// timestamps (local time for releases named before that) and are shown as
+// readable times wherever they appear.
+package timefmt
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout is the absolute form of a timestamp in human output. The zone
+// abbreviation keeps times pasted across time zones unambiguous.
+const Layout = "2006-01-02 15:04 MST"
+
+// ReleaseLayout is the layout release directory names are written in, in UTC.
+// Older releases were named in the deploying machine's local time.
+const ReleaseLayout = "20060102150405"
+
+var utc bool
+
+// SetUTC makes human output show times in UTC instead of the local time zone
+func SetUTC(on bool) {
+	utc = on
+}
+
+// Location is the time zone human output shows times in
+func Location() *time.Location {
+	if utc {
+		return time.UTC
+	}
+	return time.Local
+}
+
+// JSON formats t as RFC 3339 in UTC, or "" for the zero time
+func JSON(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Absolute formats t in the output time zone
+func Absolute(t time.Time) string {
+	return t.In(Location()).Format(Layout)
+}
+
+// Human formats t as an absolute time followed by how long ago it was:
+// "2024-01-15 13:30 CET (2h ago)"
+func Human(t time.Time) string {
+	return HumanAt(t, time.Now())
+}
+
+// HumanAt is Human measured against now
+func HumanAt(t, now time.Time) string {
+	return fmt.Sprintf("%s (%s)", Absolute(t), Relative(t, now))
+}
+
+// Relative describes t against now: "just now", "5m ago", "3h ago", "2d ago",
+// or "in 3h" for times ahead of now. Durations are measured in elapsed time,
+// so a day across a DST change is still "1d".
+func Relative(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 {
+		return "in " + span(-d)
+	}
+	if d < time.Minute {
+		return "just now"
+	}
+	return span(d) + " ago"
+}
+
+// span is a duration in its largest whole unit, under a minute as 1m
+func span(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+}
+
+// ReleaseName is the name of a release created at t
+func ReleaseName(t time.Time) string {
+	return t.UTC().Format(ReleaseLayout)
+}
+
+// ReleaseTime reads the time a release name stands for, false when the name
+// is not a timestamp
+func ReleaseTime(name string) (time.Time, bool) {
+	if len(name) != len(ReleaseLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(ReleaseLayout, name)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// releaseNamingLag is how long after its name a release can be recorded as
+// deployed. Zone offsets are an hour or more, so a name within it of the
+// deploy time is UTC.
+const releaseNamingLag = time.Hour
+
+// Release shows a release name with the time it stands for:
+// "20240115123045 (2024-01-15 12:30:45)". Releases named before names were
+// UTC carry the deploying machine's local time, so no zone is claimed; use
+// ReleaseAt when the deploy time is known. Other names are returned as they
+// are.
+func Release(name string) string {
+	return ReleaseAt(name, time.Time{})
+}
+
+// ReleaseAt is Release for a release deployed at deployedAt, labelled UTC
+// when deployedAt confirms the name is UTC. A legacy name is off by the
+// deploying machine's zone offset and stays unlabelled.
+func ReleaseAt(name string, deployedAt time.Time) string {
+	t, ok := ReleaseTime(name)
+	if !ok {
+		return name
+	}
+	label := t.Format("2006-01-02 15:04:05")
+	if lag := deployedAt.Sub(t); !deployedAt.IsZero() && lag >= 0 && lag < releaseNamingLag {
+		label += " UTC"
+	}
+	return fmt.Sprintf("%s (%s)", name, label)
+}
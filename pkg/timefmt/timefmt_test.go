@@ -0,0 +1,130 @@
+package timefmt
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func useLocal(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := time.Local
+	time.Local = loc
+	t.Cleanup(func() {
+		time.Local = original
+		SetUTC(false)
+	})
+	return loc
+}
+
+func TestRelativeThresholds(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{59 * time.Second, "just now"},
+		{time.Minute, "1m ago"},
+		{59*time.Minute + 59*time.Second, "59m ago"},
+		{time.Hour, "1h ago"},
+		{23*time.Hour + 59*time.Minute, "23h ago"},
+		{24 * time.Hour, "1d ago"},
+		{47 * time.Hour, "1d ago"},
+		{400 * 24 * time.Hour, "400d ago"},
+		{-30 * time.Second, "in 1m"},
+		{-3 * time.Hour, "in 3h"},
+	}
+	for _, tt := range tests {
+		if got := Relative(now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("Relative(%v ago) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestDSTBoundaries(t *testing.T) {
+	ny := useLocal(t, "America/New_York")
+
+	// Clocks in New York went from 02:00 EST to 03:00 EDT on 2026-03-08
+	beforeSpring := time.Date(2026, 3, 8, 6, 30, 0, 0, time.UTC)
+	afterSpring := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)
+	if got := Absolute(beforeSpring); got != "2026-03-08 01:30 EST" {
+		t.Errorf("Absolute() before the change = %q", got)
+	}
+	if got := Absolute(afterSpring); got != "2026-03-08 03:30 EDT" {
+		t.Errorf("Absolute() after the change = %q", got)
+	}
+	// One elapsed hour, though the wall clock moved two
+	if got := Relative(beforeSpring, afterSpring); got != "1h ago" {
+		t.Errorf("Relative() across the spring change = %q, want 1h ago", got)
+	}
+
+	// And back from 02:00 EDT to 01:00 EST on 2026-11-01: 01:30 happens twice
+	firstPass := time.Date(2026, 11, 1, 1, 30, 0, 0, ny)
+	secondPass := firstPass.Add(time.Hour)
+	if Absolute(firstPass) == Absolute(secondPass) {
+		t.Errorf("the repeated hour should be told apart by its zone: %q", Absolute(firstPass))
+	}
+	if got := Relative(firstPass, secondPass); got != "1h ago" {
+		t.Errorf("Relative() across the fall change = %q, want 1h ago", got)
+	}
+
+	// A calendar day across the change is 23 elapsed hours
+	if got := Relative(time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 8, 12, 0, 0, 0, ny)); got != "23h ago" {
+		t.Errorf("Relative() over the short day = %q, want 23h ago", got)
+	}
+}
+
+func TestUTCOutput(t *testing.T) {
+	useLocal(t, "Asia/Tokyo")
+	at := time.Date(2026, 1, 15, 12, 30, 45, 0, time.UTC)
+
+	if got := Absolute(at); got != "2026-01-15 21:30 JST" {
+		t.Errorf("Absolute() = %q, want local time", got)
+	}
+	SetUTC(true)
+	if got := Absolute(at); got != "2026-01-15 12:30 UTC" {
+		t.Errorf("Absolute() with --utc = %q", got)
+	}
+	if got := HumanAt(at, at.Add(2*time.Hour)); got != "2026-01-15 12:30 UTC (2h ago)" {
+		t.Errorf("HumanAt() = %q", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tokyo := useLocal(t, "Asia/Tokyo")
+	if got := JSON(time.Date(2026, 1, 15, 21, 30, 45, 0, tokyo)); got != "2026-01-15T12:30:45Z" {
+		t.Errorf("JSON() = %q, want UTC", got)
+	}
+	if got := JSON(time.Time{}); got != "" {
+		t.Errorf("JSON() of the zero time = %q", got)
+	}
+}
+
+func TestReleaseNames(t *testing.T) {
+	tokyo := useLocal(t, "Asia/Tokyo")
+	name := ReleaseName(time.Date(2026, 1, 15, 21, 30, 45, 0, tokyo))
+	if name != "20260115123045" {
+		t.Errorf("ReleaseName() = %q, want the UTC time", name)
+	}
+	if got := Release(name); got != "20260115123045 (2026-01-15 12:30:45)" {
+		t.Errorf("Release() = %q, want no zone without a deploy time", got)
+	}
+	deployedAt := time.Date(2026, 1, 15, 12, 34, 0, 0, time.UTC)
+	if got := ReleaseAt(name, deployedAt); got != "20260115123045 (2026-01-15 12:30:45 UTC)" {
+		t.Errorf("ReleaseAt() = %q", got)
+	}
+	// Named in Tokyo's local time before names were UTC
+	if got := ReleaseAt("20260115213045", deployedAt); got != "20260115213045 (2026-01-15 21:30:45)" {
+		t.Errorf("ReleaseAt() of a legacy name = %q", got)
+	}
+	for _, other := range []string{"", "v7", "20261399999999", "preview-feature", "2026011512304"} {
+		if got := Release(other); got != other {
+			t.Errorf("Release(%q) = %q, want it unchanged", other, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DotenvWarning describes a line that could not be parsed and was skipped
+type DotenvWarning struct {
+	Line    int
+	Message string
+}
+
+func (w DotenvWarning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// ParseDotenv parses .env content.
+//
+// Supported syntax:
+//   - KEY=value, with optional `export ` prefix
+//   - single-quoted values (literal, may span lines)
+//   - double-quoted values with \n, \r, \t, \", \\ and \$ escapes (may span lines)
+//   - comments on their own line, or after a value when preceded by whitespace
+//   - ${VAR} and $VAR references to earlier keys when expand is true
+//     (unquoted and double-quoted values only; unknown references are kept as-is)
+//
+// Lines that cannot be parsed are skipped and reported as warnings.
+func ParseDotenv(content string, expand bool) (map[string]string, []DotenvWarning) {
+	envVars := make(map[string]string)
+	var warnings []DotenvWarning
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		eq := strings.Index(line, "=")
+		if eq == -1 {
+			// The line is not echoed back since it may contain a secret
+			warnings = append(warnings, DotenvWarning{lineNum, "expected KEY=VALUE"})
+			continue
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		if !isValidEnvKey(key) {
+			warnings = append(warnings, DotenvWarning{lineNum, fmt.Sprintf("invalid variable name %q", key)})
+			continue
+		}
+
+		raw := strings.TrimLeft(line[eq+1:], " \t")
+
+		if raw != "" && (raw[0] == '"' || raw[0] == '\'') {
+			quote := raw[0]
+			body := raw[1:]
+
+			// Quoted values may continue onto following lines until the closing quote
+			end := findClosingQuote(body, quote)
+			for end == -1 && i+1 < len(lines) {
+				i++
+				body += "\n" + lines[i]
+				end = findClosingQuote(body, quote)
+			}
+			if end == -1 {
+				warnings = append(warnings, DotenvWarning{lineNum, fmt.Sprintf("unterminated quoted value for %s", key)})
+				continue
+			}
+
+			rest := strings.TrimSpace(body[end+1:])
+			if rest != "" && !strings.HasPrefix(rest, "#") {
+				warnings = append(warnings, DotenvWarning{lineNum, fmt.Sprintf("unexpected characters after quoted value for %s", key)})
+				continue
+			}
+
+			value := body[:end]
+			if quote == '"' {
+				value = unescapeDoubleQuoted(value, expand, envVars)
+			}
+			envVars[key] = value
+			continue
+		}
+
+		value := stripInlineComment(raw)
+		if expand {
+			value = expandEnvReferences(value, envVars)
+		}
+		envVars[key] = value
+	}
+
+	return envVars, warnings
+}
+
+// FormatDotenv renders env vars as .env content with keys in sorted order.
+// Values that would not survive a round trip unquoted are double-quoted and escaped.
+func FormatDotenv(envVars map[string]string) string {
+	keys := make([]string, 0, len(envVars))
+	for key := range envVars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(quoteEnvValue(envVars[key]))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func quoteEnvValue(value string) string {
+	needsQuotes := value != strings.TrimSpace(value) ||
+		strings.ContainsAny(value, "\n\r\"'\\#")
+	if !needsQuotes {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func isValidEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z'):
+		case i > 0 && ((c >= '0' && c <= '9') || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// findClosingQuote returns the index of the unescaped closing quote, or -1
+func findClosingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDoubleQuoted(s string, expand bool, envVars map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		if c == '$' && expand {
+			if value, width, ok := lookupEnvReference(s[i:], envVars); ok {
+				b.WriteString(value)
+				i += width - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// stripInlineComment removes a trailing " # comment" from an unquoted value
+func stripInlineComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+func expandEnvReferences(value string, envVars map[string]string) string {
+	if !strings.Contains(value, "$") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '$' {
+			if resolved, width, ok := lookupEnvReference(value[i:], envVars); ok {
+				b.WriteString(resolved)
+				i += width - 1
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// lookupEnvReference resolves a ${VAR} or $VAR reference at the start of s.
+// It returns the value and the number of bytes consumed, or ok=false when the
+// reference is malformed or names a key that has not been defined yet.
+func lookupEnvReference(s string, envVars map[string]string) (value string, width int, ok bool) {
+	if len(s) < 2 || s[0] != '$' {
+		return "", 0, false
+	}
+
+	var name string
+	if s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end == -1 {
+			return "", 0, false
+		}
+		name = s[2:end]
+		width = end + 1
+	} else {
+		end := 1
+		for end < len(s) && isEnvNameChar(s[end], end == 1) {
+			end++
+		}
+		name = s[1:end]
+		width = end
+	}
+
+	if !isValidEnvKey(name) {
+		return "", 0, false
+	}
+	value, ok = envVars[name]
+	return value, width, ok
+}
+
+func isEnvNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
@@ -5,35 +5,17 @@ import (
 	"os"
 )
 
-// LoadEnvFile reads and parses a .env file into a map of environment variables
+// LoadEnvFile reads and parses a .env file into a map of environment variables.
+// Unlike the lenient deploy-time loader, any unparseable line is an error.
 func LoadEnvFile(filePath string) (map[string]string, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	envVars := make(map[string]string)
-	lines := SplitLines(string(data))
-
-	for i, line := range lines {
-		line = TrimSpace(line)
-		if line == "" || StartsWithHash(line) {
-			continue
-		}
-
-		parts := SplitEnvVar(line)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid env var at line %d: %s", i+1, line)
-		}
-
-		value := parts[1]
-		// Remove surrounding quotes if present
-		if len(value) >= 2 && ((value[0] == '"' && value[len(value)-1] == '"') ||
-			(value[0] == '\'' && value[len(value)-1] == '\'')) {
-			value = value[1 : len(value)-1]
-		}
-
-		envVars[parts[0]] = value
+	envVars, warnings := ParseDotenv(string(data), true)
+	if len(warnings) > 0 {
+		return nil, fmt.Errorf("invalid env var at %s", warnings[0])
 	}
 
 	return envVars, nil
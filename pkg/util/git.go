@@ -69,3 +69,62 @@ func GetGitHubRepo(projectPath string) (org, repo string, err error) {
 
 	return ParseGitHubRepo(remoteURL)
 }
+
+// GitRevision is the commit a project is checked out at
+type GitRevision struct {
+	Commit  string
+	Branch  string // Empty on a detached HEAD outside CI
+	Subject string // First line of the commit message
+}
+
+// ciBranchVars are the variables CI systems set to the branch being built,
+// in the order they are checked. GITHUB_HEAD_REF is the source branch of a
+// pull request, where GITHUB_REF_NAME is "<number>/merge".
+var ciBranchVars = []string{
+	"GITHUB_HEAD_REF",
+	"GITHUB_REF_NAME",
+	"CI_COMMIT_REF_NAME",
+	"BITBUCKET_BRANCH",
+	"CIRCLE_BRANCH",
+	"BUILDKITE_BRANCH",
+	"DRONE_BRANCH",
+	"TRAVIS_BRANCH",
+	"GIT_BRANCH",
+}
+
+// GetGitRevision reads the commit, branch and commit subject of the project.
+// CI checkouts are usually a detached HEAD, so the branch then comes from
+// the CI's variables. Fields are empty when git or the repository is missing.
+func GetGitRevision(projectPath string) GitRevision {
+	var rev GitRevision
+	if projectPath != "" {
+		if output, err := exec.Command("git", "-C", projectPath, "log", "-1", "--format=%H%n%s").Output(); err == nil {
+			rev.Commit, rev.Subject = parseGitLog(string(output))
+		}
+		if output, err := exec.Command("git", "-C", projectPath, "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+			rev.Branch = strings.TrimSpace(string(output))
+		}
+	}
+	if rev.Branch == "" || rev.Branch == "HEAD" {
+		rev.Branch = CIBranch(os.Getenv)
+	}
+	return rev
+}
+
+// parseGitLog reads the output of git log -1 --format=%H%n%s
+func parseGitLog(output string) (commit, subject string) {
+	commit, subject, _ = strings.Cut(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(commit), strings.TrimSpace(subject)
+}
+
+// CIBranch returns the branch a CI job builds from its environment, or ""
+// outside CI
+func CIBranch(getenv func(string) string) string {
+	for _, name := range ciBranchVars {
+		if branch := strings.TrimSpace(getenv(name)); branch != "" {
+			branch = strings.TrimPrefix(branch, "refs/heads/")
+			return strings.TrimPrefix(branch, "origin/")
+		}
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"os/exec"
 	"testing"
 )
 
@@ -90,3 +91,74 @@ func TestIsGitRepository(t *testing.T) {
 		t.Error("Expected non-existent directory to not be a git repository")
 	}
 }
+
+func TestCIBranch(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "outside CI", env: map[string]string{}, want: ""},
+		{name: "GitHub push", env: map[string]string{"GITHUB_REF_NAME": "main"}, want: "main"},
+		{name: "GitHub pull request", env: map[string]string{"GITHUB_REF_NAME": "42/merge", "GITHUB_HEAD_REF": "hotfix/login"}, want: "hotfix/login"},
+		{name: "GitLab", env: map[string]string{"CI_COMMIT_REF_NAME": "release-2"}, want: "release-2"},
+		{name: "Jenkins remote branch", env: map[string]string{"GIT_BRANCH": "origin/main"}, want: "main"},
+		{name: "full ref", env: map[string]string{"BUILDKITE_BRANCH": "refs/heads/main"}, want: "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CIBranch(func(name string) string { return tt.env[name] }); got != tt.want {
+				t.Errorf("CIBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGitLog(t *testing.T) {
+	commit, subject := parseGitLog("0123abc\nFix the login redirect\n")
+	if commit != "0123abc" || subject != "Fix the login redirect" {
+		t.Errorf("parseGitLog() = %q, %q", commit, subject)
+	}
+	if commit, subject := parseGitLog(""); commit != "" || subject != "" {
+		t.Errorf("parseGitLog(\"\") = %q, %q", commit, subject)
+	}
+}
+
+func TestGetGitRevision(t *testing.T) {
+	for _, name := range ciBranchVars {
+		t.Setenv(name, "")
+	}
+
+	if rev := GetGitRevision(t.TempDir()); rev != (GitRevision{}) {
+		t.Errorf("GetGitRevision() outside a repository = %+v, want nothing", rev)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "Fix the login redirect", "-m", "Longer description")
+
+	rev := GetGitRevision(dir)
+	if len(rev.Commit) != 40 || rev.Branch != "main" || rev.Subject != "Fix the login redirect" {
+		t.Errorf("GetGitRevision() = %+v", rev)
+	}
+
+	// CI checks out a detached HEAD and names the branch in its variables
+	git("checkout", "-q", "--detach")
+	if rev := GetGitRevision(dir); rev.Branch != "" {
+		t.Errorf("detached HEAD outside CI: branch = %q, want none", rev.Branch)
+	}
+	t.Setenv("GITHUB_REF_NAME", "main")
+	if rev := GetGitRevision(dir); rev.Branch != "main" || rev.Commit == "" {
+		t.Errorf("detached HEAD in CI = %+v, want branch main", rev)
+	}
+}
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"os"
+	"os/user"
+)
+
+// LocalIdentity names who is running lightfold as user@host, e.g. alice@laptop
+// or runner@ci-host
+func LocalIdentity() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
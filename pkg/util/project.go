@@ -37,7 +37,20 @@ func GetTargetName(projectPath string) string {
 	// Extract base name
 	baseName := filepath.Base(cleaned)
 	// Sanitize for use as hostname/target name
-	return SanitizeHostname(baseName)
+	return AppNameFromTarget(baseName)
+}
+
+// AppNameFromTarget returns the app name used on the server for a target or
+// project directory name: the systemd unit, /srv/<app> and the nginx site.
+// It is the hyphenated hostname form (e.g., "my_app" -> "my-app").
+func AppNameFromTarget(name string) string {
+	return SanitizeHostname(name)
+}
+
+// LegacyAppName returns the underscore form of appName that older releases
+// looked up on the server (e.g., "my-app" -> "my_app")
+func LegacyAppName(appName string) string {
+	return strings.ReplaceAll(appName, "-", "_")
 }
 
 // ValidateProjectPath validates and cleans a project path
@@ -385,3 +385,22 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestAppNameFromTarget(t *testing.T) {
+	for input, want := range map[string]string{
+		"my-app": "my-app",
+		"my_app": "my-app",
+		"My App": "MyApp",
+	} {
+		if got := AppNameFromTarget(input); got != want {
+			t.Errorf("AppNameFromTarget(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	if got := GetTargetName("/home/dev/my_app"); got != AppNameFromTarget("my_app") {
+		t.Errorf("GetTargetName() = %q, want it to match AppNameFromTarget", got)
+	}
+	if got := LegacyAppName("my-app"); got != "my_app" {
+		t.Errorf("LegacyAppName(my-app) = %q, want my_app", got)
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"sort"
+	"strings"
+)
+
+// ShellQuote wraps a value in single quotes for safe use in a shell command
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// SortedKeys returns the keys of m in order, for output that does not change
+// between runs
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
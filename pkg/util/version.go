@@ -0,0 +1,61 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseVersion splits "v1.2.3-rc.1" into its numeric parts and prerelease
+func parseVersion(version string) ([3]int, string) {
+	var parts [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(version, '+'); i >= 0 {
+		version = version[:i]
+	}
+	prerelease := ""
+	if i := strings.IndexByte(version, '-'); i >= 0 {
+		version, prerelease = version[:i], version[i+1:]
+	}
+	for i, field := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(field)
+	}
+	return parts, prerelease
+}
+
+// CompareVersions compares two semantic versions, with or without a leading
+// v, returning -1, 0 or 1. A prerelease sorts before its release.
+func CompareVersions(a, b string) int {
+	partsA, preA := parseVersion(a)
+	partsB, preB := parseVersion(b)
+	for i := range partsA {
+		if partsA[i] != partsB[i] {
+			if partsA[i] < partsB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA < preB:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// ReleaseLine returns the part of a version that changes on breaking
+// releases: the major version, or "0.<minor>" before 1.0 where every minor
+// release may break
+func ReleaseLine(version string) string {
+	parts, _ := parseVersion(version)
+	if parts[0] == 0 {
+		return "0." + strconv.Itoa(parts[1])
+	}
+	return strconv.Itoa(parts[0])
+}
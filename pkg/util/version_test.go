@@ -0,0 +1,39 @@
+package util
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.1.3", "0.1.3", 0},
+		{"v0.1.3", "0.1.3", 0},
+		{"0.1.3", "0.2.0", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-beta.1", "1.0.0-rc.1", -1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.0+build.5", "1.2.0", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseLine(t *testing.T) {
+	tests := map[string]string{
+		"0.1.3":       "0.1",
+		"v0.2.0-rc.1": "0.2",
+		"1.4.2":       "1",
+		"v2.0.0":      "2",
+	}
+	for version, want := range tests {
+		if got := ReleaseLine(version); got != want {
+			t.Errorf("ReleaseLine(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
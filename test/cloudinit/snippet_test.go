@@ -0,0 +1,170 @@
+package cloudinit
+
+import (
+	"lightfold/pkg/providers/cloudinit"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGq1234567890abcdef test@example.com"
+
+const complianceSnippet = `#cloud-config
+write_files:
+  - path: /usr/local/share/ca-certificates/corp.crt
+    content: |
+      -----BEGIN CERTIFICATE-----
+      MIIBszCCAVmgAwIBAgIU
+      -----END CERTIFICATE-----
+    permissions: '0644'
+runcmd:
+  - update-ca-certificates
+  - [bash, -c, "DD_API_KEY=abc bash -c \"$(curl -L https://example.com/install.sh)\""]
+`
+
+func TestParseSnippet(t *testing.T) {
+	t.Run("write_files and runcmd", func(t *testing.T) {
+		snippet, err := cloudinit.ParseSnippet([]byte(complianceSnippet))
+		if err != nil {
+			t.Fatalf("ParseSnippet failed: %v", err)
+		}
+		if len(snippet.WriteFiles) != 1 || len(snippet.RunCmd) != 2 {
+			t.Errorf("Expected 1 file and 2 commands, got %d and %d", len(snippet.WriteFiles), len(snippet.RunCmd))
+		}
+	})
+
+	invalid := map[string]string{
+		"invalid yaml":       "runcmd: [unclosed",
+		"unsupported key":    "packages:\n  - datadog-agent\n",
+		"runcmd not a list":  "runcmd: echo hi\n",
+		"relative file path": "write_files:\n  - path: etc/app.conf\n    content: x\n",
+		"nested runcmd arg":  "runcmd:\n  - [echo, {a: b}]\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := cloudinit.ParseSnippet([]byte(content)); err == nil {
+				t.Errorf("Expected error for %s", name)
+			}
+		})
+	}
+}
+
+func TestLoadSnippetMissingFile(t *testing.T) {
+	if _, err := cloudinit.LoadSnippet(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestMergeSnippet(t *testing.T) {
+	userData, err := cloudinit.GenerateWebAppUserData("deploy", testPublicKey, "my-app", "")
+	if err != nil {
+		t.Fatalf("Failed to generate user data: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "extra.yaml")
+	if err := os.WriteFile(path, []byte(complianceSnippet), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snippet, err := cloudinit.LoadSnippet(path)
+	if err != nil {
+		t.Fatalf("LoadSnippet failed: %v", err)
+	}
+
+	merged, err := cloudinit.MergeSnippet(userData, snippet)
+	if err != nil {
+		t.Fatalf("MergeSnippet failed: %v", err)
+	}
+
+	if !strings.HasPrefix(merged, "#cloud-config\n") {
+		t.Error("Merged user data must start with #cloud-config")
+	}
+
+	var doc struct {
+		Users      []map[string]interface{} `yaml:"users"`
+		Packages   []string                 `yaml:"packages"`
+		WriteFiles []map[string]interface{} `yaml:"write_files"`
+		RunCmd     []interface{}            `yaml:"runcmd"`
+	}
+	if err := yaml.Unmarshal([]byte(merged), &doc); err != nil {
+		t.Fatalf("Merged user data is not valid YAML: %v", err)
+	}
+
+	if len(doc.Users) != 1 || doc.Users[0]["name"] != "deploy" {
+		t.Errorf("Expected deploy user to be preserved, got %v", doc.Users)
+	}
+	if len(doc.Packages) == 0 {
+		t.Error("Expected default packages to be preserved")
+	}
+	if len(doc.WriteFiles) != 1 || doc.WriteFiles[0]["path"] != "/usr/local/share/ca-certificates/corp.crt" {
+		t.Errorf("Expected CA certificate in write_files, got %v", doc.WriteFiles)
+	}
+
+	last := doc.RunCmd[len(doc.RunCmd)-1]
+	if s, _ := last.(string); !strings.Contains(s, "lightfold-setup-complete") {
+		t.Errorf("Expected setup-complete marker to stay last, got %v", last)
+	}
+	if doc.RunCmd[len(doc.RunCmd)-3] != "update-ca-certificates" {
+		t.Errorf("Expected user commands right before the marker, got %v", doc.RunCmd)
+	}
+	if doc.RunCmd[0] != "mkdir -p /srv/my-app/releases" {
+		t.Errorf("Expected generated commands to run first, got %v", doc.RunCmd[0])
+	}
+}
+
+func TestMergeEmptySnippetIsNoop(t *testing.T) {
+	userData, _ := cloudinit.GenerateWebAppUserData("deploy", testPublicKey, "my-app", "")
+	merged, err := cloudinit.MergeSnippet(userData, &cloudinit.Snippet{})
+	if err != nil {
+		t.Fatalf("MergeSnippet failed: %v", err)
+	}
+	if merged != userData {
+		t.Error("Expected user data to be unchanged")
+	}
+}
+
+func TestValidateUserDataSize(t *testing.T) {
+	large := strings.Repeat("a", 65*1024)
+
+	if err := cloudinit.ValidateUserDataSize("digitalocean", large); err == nil {
+		t.Error("Expected DigitalOcean 64KB limit to be enforced")
+	}
+	if err := cloudinit.ValidateUserDataSize("digitalocean", "#cloud-config\n"); err != nil {
+		t.Errorf("Unexpected error for small user data: %v", err)
+	}
+	if err := cloudinit.ValidateUserDataSize("unknown", large); err != nil {
+		t.Errorf("Expected no limit for unknown provider, got %v", err)
+	}
+}
+
+func TestSnippetScript(t *testing.T) {
+	snippet, err := cloudinit.ParseSnippet([]byte(complianceSnippet))
+	if err != nil {
+		t.Fatalf("ParseSnippet failed: %v", err)
+	}
+
+	script, err := snippet.Script()
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"#!/bin/bash\nset -euo pipefail\n",
+		"mkdir -p '/usr/local/share/ca-certificates'",
+		"| base64 -d > '/usr/local/share/ca-certificates/corp.crt'",
+		"chmod '0644' '/usr/local/share/ca-certificates/corp.crt'",
+		"chown 'root:root' '/usr/local/share/ca-certificates/corp.crt'",
+		"\nupdate-ca-certificates\n",
+		`'bash' '-c' 'DD_API_KEY=abc bash -c "$(curl -L https://example.com/install.sh)"'`,
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected script to contain %q, got:\n%s", expected, script)
+		}
+	}
+
+	if strings.Index(script, "base64 -d") > strings.Index(script, "update-ca-certificates") {
+		t.Error("Expected files to be written before commands run")
+	}
+}
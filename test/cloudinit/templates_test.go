@@ -11,7 +11,7 @@ func TestGenerateWebAppUserData(t *testing.T) {
 	publicKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGq1234567890abcdef test@example.com"
 	appName := "my-test-app"
 
-	userData, err := cloudinit.GenerateWebAppUserData(username, publicKey, appName)
+	userData, err := cloudinit.GenerateWebAppUserData(username, publicKey, appName, "")
 	if err != nil {
 		t.Fatalf("Failed to generate user data: %v", err)
 	}
@@ -517,3 +517,41 @@ func TestCloudInitFileWithEncoding(t *testing.T) {
 		t.Error("Should contain encoding specification")
 	}
 }
+
+func TestGenerateWebAppUserDataWithTeamKeys(t *testing.T) {
+	publicKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGq1234567890abcdef deploy@lightfold"
+	teammate := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHteammate0000000000 alice@laptop"
+
+	userData, err := cloudinit.GenerateWebAppUserData("deploy", publicKey, "my-app", "", teammate, publicKey, "", teammate)
+	if err != nil {
+		t.Fatalf("Failed to generate user data: %v", err)
+	}
+
+	if !strings.Contains(userData, "      - "+teammate) {
+		t.Error("Expected teammate key in ssh_authorized_keys")
+	}
+
+	if strings.Count(userData, publicKey) != 1 {
+		t.Errorf("Expected primary key exactly once, found %d", strings.Count(userData, publicKey))
+	}
+
+	if strings.Count(userData, teammate) != 1 {
+		t.Errorf("Expected teammate key exactly once, found %d", strings.Count(userData, teammate))
+	}
+}
+
+func TestGenerateWebAppUserDataWithAppDir(t *testing.T) {
+	publicKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGq1234567890abcdef deploy@lightfold"
+
+	userData, err := cloudinit.GenerateWebAppUserData("deploy", publicKey, "my-app", "/opt/apps/my-app")
+	if err != nil {
+		t.Fatalf("Failed to generate user data: %v", err)
+	}
+
+	if !strings.Contains(userData, "/opt/apps/my-app") {
+		t.Error("Expected user data to create /opt/apps/my-app")
+	}
+	if strings.Contains(userData, "/srv/") {
+		t.Error("Expected no /srv paths with a custom app directory")
+	}
+}
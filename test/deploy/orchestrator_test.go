@@ -42,6 +42,34 @@ func TestCheckExistingServer_DigitalOcean_AlreadyProvisioned(t *testing.T) {
 	}
 }
 
+func TestCheckExistingServer_Adopted_RequiresIP(t *testing.T) {
+	// Adopted servers are never provisioned, so a missing IP is a configuration error
+	projectConfig := config.TargetConfig{
+		Framework: "FastAPI",
+		Provider:  "digitalocean",
+	}
+	projectConfig.SetProviderConfig("digitalocean", config.DigitalOceanConfig{
+		DropletID: "522181726",
+		SSHKey:    "/Users/test/.ssh/id_ed25519",
+		Username:  "root",
+		Adopted:   true,
+	})
+
+	orchestrator, err := deploy.GetOrchestrator(projectConfig, "/tmp/test-project", "test-project", "test-target")
+	if err != nil {
+		t.Fatalf("Failed to create orchestrator: %v", err)
+	}
+
+	_, err = orchestrator.Deploy(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when adopted server has no IP, got nil")
+	}
+
+	if !contains(err.Error(), "adopted server 522181726 has no IP") {
+		t.Errorf("Expected adopted server error, got: %s", err.Error())
+	}
+}
+
 func TestCheckExistingServer_DigitalOcean_NewProvisioning(t *testing.T) {
 	t.Skip("Skipping test that makes real API/network calls - needs mocking")
 	// Test case: Allow provisioning when no server exists yet
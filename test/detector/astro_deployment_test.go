@@ -247,6 +247,97 @@ export default defineConfig({
 	}
 }
 
+func TestAstroOutputModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		deps         string
+		wantRun      string
+		wantStatic   bool
+		expectedMeta map[string]string
+	}{
+		{
+			name: "static output with unused adapter package",
+			config: `import { defineConfig } from 'astro/config';
+export default defineConfig({ output: 'static' });`,
+			deps:       `"astro": "^4.0.0", "@astrojs/node": "^8.0.0"`,
+			wantRun:    "# Static site - serve dist/ with nginx",
+			wantStatic: true,
+			expectedMeta: map[string]string{
+				"output":   "static",
+				"adapter":  "static",
+				"run_mode": "static",
+			},
+		},
+		{
+			name: "hybrid output with node adapter",
+			config: `import { defineConfig } from 'astro/config';
+import node from '@astrojs/node';
+export default defineConfig({ output: 'hybrid', adapter: node({ mode: 'standalone' }) });`,
+			deps:    `"astro": "^4.0.0", "@astrojs/node": "^8.0.0"`,
+			wantRun: "node ./dist/server/entry.mjs",
+			expectedMeta: map[string]string{
+				"output":       "hybrid",
+				"adapter":      "node",
+				"adapter_mode": "standalone",
+				"start_env":    "HOST=127.0.0.1",
+				"default_port": "4321",
+			},
+		},
+		{
+			name: "server output with node adapter",
+			config: `import { defineConfig } from "astro/config";
+import node from "@astrojs/node";
+export default defineConfig({
+  output: "server",
+  adapter: node({ mode: "standalone" }),
+});`,
+			deps:    `"astro": "^5.0.0", "@astrojs/node": "^9.0.0"`,
+			wantRun: "node ./dist/server/entry.mjs",
+			expectedMeta: map[string]string{
+				"output":   "server",
+				"adapter":  "node",
+				"run_mode": "server",
+				"server":   "astro-node",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, map[string]string{
+				"astro.config.mjs":      tt.config,
+				"package.json":          `{"dependencies": {` + tt.deps + `}}`,
+				"src/pages/index.astro": "<h1>Hello</h1>",
+			})
+			detection := captureDetectFramework(t, projectPath)
+
+			if detection.Framework != "Astro" {
+				t.Fatalf("Expected framework Astro, got %s", detection.Framework)
+			}
+			if len(detection.RunPlan) == 0 || detection.RunPlan[0] != tt.wantRun {
+				t.Errorf("Expected run plan %q, got %v", tt.wantRun, detection.RunPlan)
+			}
+			for key, expectedValue := range tt.expectedMeta {
+				if actualValue := detection.Meta[key]; actualValue != expectedValue {
+					t.Errorf("Expected meta['%s'] = '%s', got '%s'", key, expectedValue, actualValue)
+				}
+			}
+
+			isStatic := detection.Meta["deployment_type"] == "static"
+			if isStatic != tt.wantStatic {
+				t.Errorf("Expected static deployment = %v, got %v", tt.wantStatic, isStatic)
+			}
+			if tt.wantStatic && (detection.Healthcheck != nil || detection.Meta["default_port"] != "") {
+				t.Errorf("Static site should have no health check or port, got %v / %q", detection.Healthcheck, detection.Meta["default_port"])
+			}
+			if !tt.wantStatic && detection.Healthcheck == nil {
+				t.Error("Expected health check for SSR build")
+			}
+		})
+	}
+}
+
 func TestNextJSStaticExport(t *testing.T) {
 	tests := []struct {
 		name              string
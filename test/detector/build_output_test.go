@@ -312,7 +312,17 @@ func TestStaticSiteGeneratorsBuildOutput(t *testing.T) {
 				"docs/intro.md":        "# Intro",
 			},
 			buildOutput: "build/",
-			isStatic:    false, // Docusaurus can be SSR
+			isStatic:    true,
+		},
+		{
+			name:      "Gatsby",
+			framework: "Gatsby",
+			files: map[string]string{
+				"gatsby-config.js": "module.exports = {}",
+				"package.json":     `{"dependencies": {"gatsby": "^5.0.0"}}`,
+			},
+			buildOutput: "public/",
+			isStatic:    true,
 		},
 	}
 
@@ -333,6 +343,10 @@ func TestStaticSiteGeneratorsBuildOutput(t *testing.T) {
 				if detection.Meta["static"] != "true" {
 					t.Errorf("Expected static 'true', got '%s'", detection.Meta["static"])
 				}
+				// deployment_type is what the executor reads to skip systemd and serve files
+				if detection.Meta["deployment_type"] != "static" {
+					t.Errorf("Expected deployment_type 'static', got '%s'", detection.Meta["deployment_type"])
+				}
 			}
 		})
 	}
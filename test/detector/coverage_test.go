@@ -319,7 +319,7 @@ func TestPackageManagerBuildPlans(t *testing.T) {
 				"yarn.lock":      "# yarn lockfile v1",
 			},
 			expectedFramework:  "Next.js",
-			expectedInstallCmd: "yarn install",
+			expectedInstallCmd: "yarn install --frozen-lockfile",
 			expectedBuildCmd:   "yarn build",
 		},
 		{
@@ -343,7 +343,7 @@ func TestPackageManagerBuildPlans(t *testing.T) {
 				"public/favicon.ico":    "favicon",
 			},
 			expectedFramework:  "Astro",
-			expectedInstallCmd: "bun install",
+			expectedInstallCmd: "bun install --frozen-lockfile",
 			expectedBuildCmd:   "bun run build",
 		},
 	}
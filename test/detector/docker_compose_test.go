@@ -0,0 +1,76 @@
+package detector_test
+
+import (
+	"testing"
+)
+
+func TestDockerComposeDeploymentMeta(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		composeFile string
+		composePort string
+	}{
+		{
+			name: "docker-compose.yml with literal published port",
+			files: map[string]string{
+				"docker-compose.yml": `services:
+  web:
+    build: .
+    ports:
+      - "8080:3000"
+  redis:
+    image: redis:7
+`,
+				"Dockerfile": "FROM node:20",
+			},
+			composeFile: "docker-compose.yml",
+			composePort: "8080",
+		},
+		{
+			name: "compose.yaml with bound host address",
+			files: map[string]string{
+				"compose.yaml": `services:
+  app:
+    image: myapp
+    ports:
+      - 127.0.0.1:9000:80/tcp
+`,
+			},
+			composeFile: "compose.yaml",
+			composePort: "9000",
+		},
+		{
+			name: "compose file with interpolated port",
+			files: map[string]string{
+				"compose.yml": `services:
+  app:
+    build: .
+    ports:
+      - "${PORT}:3000"
+`,
+			},
+			composeFile: "compose.yml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			if detection.Framework != "Docker Compose" {
+				t.Fatalf("Expected Docker Compose, got %s", detection.Framework)
+			}
+			if detection.Meta["deployment_type"] != "docker-compose" {
+				t.Errorf("Expected deployment_type 'docker-compose', got %q", detection.Meta["deployment_type"])
+			}
+			if detection.Meta["compose_file"] != tt.composeFile {
+				t.Errorf("Expected compose_file %q, got %q", tt.composeFile, detection.Meta["compose_file"])
+			}
+			if detection.Meta["compose_port"] != tt.composePort {
+				t.Errorf("Expected compose_port %q, got %q", tt.composePort, detection.Meta["compose_port"])
+			}
+		})
+	}
+}
@@ -215,16 +215,16 @@ export default nextConfig`,
 				firstCommand := detection.BuildPlan[0]
 				switch tt.packageManager {
 				case "pnpm":
-					if firstCommand != "pnpm install" {
-						t.Errorf("Expected pnpm install, got %s", firstCommand)
+					if firstCommand != "pnpm install --frozen-lockfile" {
+						t.Errorf("Expected pnpm install --frozen-lockfile, got %s", firstCommand)
 					}
 				case "yarn":
-					if firstCommand != "yarn install" {
-						t.Errorf("Expected yarn install, got %s", firstCommand)
+					if firstCommand != "yarn install --frozen-lockfile" {
+						t.Errorf("Expected yarn install --frozen-lockfile, got %s", firstCommand)
 					}
 				case "bun":
-					if firstCommand != "bun install" {
-						t.Errorf("Expected bun install, got %s", firstCommand)
+					if firstCommand != "bun install --frozen-lockfile" {
+						t.Errorf("Expected bun install --frozen-lockfile, got %s", firstCommand)
 					}
 				default:
 					if firstCommand != "npm install" {
@@ -0,0 +1,104 @@
+package detector_test
+
+import (
+	"strings"
+	"testing"
+)
+
+// Library Detection Tests
+// Packages that are published rather than deployed must be flagged so deploy refuses them
+
+func TestLibraryDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantLibrary string
+	}{
+		{
+			name: "Vite library mode",
+			files: map[string]string{
+				"vite.config.ts": `import { defineConfig } from 'vite';
+import vue from '@vitejs/plugin-vue';
+
+export default defineConfig({
+  plugins: [vue()],
+  build: {
+    lib: {
+      entry: 'src/index.ts',
+      name: 'UiKit',
+    },
+  },
+});`,
+				"package.json": `{
+  "name": "@acme/ui-kit",
+  "private": true,
+  "scripts": {"dev": "vite", "build": "vite build", "preview": "vite preview"},
+  "devDependencies": {"vite": "^5.0.0", "vue": "^3.4.0"}
+}`,
+				"src/index.ts":   `export { default as Button } from './Button.vue';`,
+				"src/Button.vue": `<template><button><slot /></button></template>`,
+			},
+			wantLibrary: "vite.config.ts builds in library mode",
+		},
+		{
+			name: "published package without start script",
+			files: map[string]string{
+				"package.json": `{
+  "name": "left-pad-ts",
+  "private": false,
+  "main": "dist/index.js",
+  "scripts": {"build": "tsc", "test": "vitest"},
+  "devDependencies": {"typescript": "^5.0.0"}
+}`,
+				"src/index.ts": `export const leftPad = (s: string, n: number) => s.padStart(n);`,
+			},
+			wantLibrary: `"private": false`,
+		},
+		{
+			name: "Vite app",
+			files: map[string]string{
+				"vite.config.js": `import { defineConfig } from 'vite';
+import vue from '@vitejs/plugin-vue';
+
+export default defineConfig({
+  plugins: [vue()],
+  resolve: { alias: { lib: '/src/lib' } },
+});`,
+				"package.json": `{
+  "scripts": {"dev": "vite", "build": "vite build", "preview": "vite preview"},
+  "dependencies": {"vue": "^3.4.0"}
+}`,
+				"src/App.vue": `<template><div /></template>`,
+			},
+		},
+		{
+			name: "public package that serves itself",
+			files: map[string]string{
+				"package.json": `{
+  "private": false,
+  "scripts": {"start": "node server.js"},
+  "dependencies": {"express": "^4.18.0"}
+}`,
+				"server.js": `require('express')().listen(3000);`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			library := detection.Meta["library"]
+			if tt.wantLibrary == "" {
+				if library != "" {
+					t.Errorf("Expected an app, got library %q", library)
+				}
+				return
+			}
+			if !strings.Contains(library, tt.wantLibrary) {
+				t.Errorf("Expected library reason containing %q, got %q", tt.wantLibrary, library)
+			}
+		})
+	}
+}
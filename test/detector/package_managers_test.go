@@ -71,6 +71,67 @@ yarnPath: .yarn/releases/yarn-3.6.0.cjs`,
 	}
 }
 
+func TestPackageManagerPinDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantInstall string
+		wantSpec    string
+		wantLinker  string
+	}{
+		{
+			name: "pnpm pinned with integrity hash",
+			files: map[string]string{
+				"package.json":   `{"packageManager": "pnpm@9.1.0+sha512.abc", "dependencies": {"next": "^14.0.0"}}`,
+				"pnpm-lock.yaml": "lockfileVersion: '9.0'",
+				"next.config.js": "module.exports = {}",
+			},
+			wantInstall: "pnpm install --frozen-lockfile",
+			wantSpec:    "pnpm@9.1.0",
+		},
+		{
+			name: "yarn berry with Plug'n'Play",
+			files: map[string]string{
+				".yarnrc.yml":    "enableTelemetry: false\n",
+				"package.json":   `{"packageManager": "yarn@4.1.0", "dependencies": {"next": "^14.0.0"}}`,
+				"yarn.lock":      "__metadata:\n  version: 8",
+				"next.config.js": "module.exports = {}",
+			},
+			wantInstall: "yarn install --immutable",
+			wantSpec:    "yarn@4.1.0",
+			wantLinker:  "pnp",
+		},
+		{
+			name: "yarn berry with node_modules linker",
+			files: map[string]string{
+				".yarnrc.yml":    `nodeLinker: "node-modules"`,
+				"package.json":   `{"dependencies": {"next": "^14.0.0"}}`,
+				"yarn.lock":      "__metadata:\n  version: 8",
+				"next.config.js": "module.exports = {}",
+			},
+			wantInstall: "yarn install --immutable",
+			wantLinker:  "node-modules",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			if len(detection.BuildPlan) == 0 || detection.BuildPlan[0] != tt.wantInstall {
+				t.Errorf("Expected install command %q, got %v", tt.wantInstall, detection.BuildPlan)
+			}
+			if got := detection.Meta["package_manager_spec"]; got != tt.wantSpec {
+				t.Errorf("Expected package_manager_spec %q, got %q", tt.wantSpec, got)
+			}
+			if got := detection.Meta["yarn_linker"]; got != tt.wantLinker {
+				t.Errorf("Expected yarn_linker %q, got %q", tt.wantLinker, got)
+			}
+		})
+	}
+}
+
 func TestPDMDetection(t *testing.T) {
 	tests := []struct {
 		name              string
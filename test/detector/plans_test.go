@@ -89,10 +89,11 @@ func TestJSCommandGeneration(t *testing.T) {
 		expectedBuild   string
 		expectedStart   string
 	}{
-		{"bun", "bun install", "bun run build", "bun run start"},
-		{"pnpm", "pnpm install", "pnpm run build", "pnpm start"},
-		{"yarn", "yarn install", "yarn build", "yarn start"},
-		{"npm", "npm install", "npm run build", "npm start"},
+		{"bun", "bun install --frozen-lockfile", "bun run build", "bun run start"},
+		{"pnpm", "pnpm install --frozen-lockfile", "pnpm run build", "pnpm start"},
+		{"yarn", "yarn install --frozen-lockfile", "yarn build", "yarn start"},
+		{"yarn-berry", "yarn install --immutable", "yarn build", "yarn start"},
+		{"npm", "npm ci", "npm run build", "npm start"},
 	}
 
 	for _, tt := range tests {
@@ -147,7 +148,7 @@ func TestBuildPlans(t *testing.T) {
 				"pnpm-lock.yaml": "lockfileVersion: 6.0",
 				"package.json":   "{}",
 			},
-			expectedCommands: []string{"pnpm install", "pnpm run build"},
+			expectedCommands: []string{"pnpm install --frozen-lockfile", "pnpm run build"},
 			expectedPM:       "pnpm",
 		},
 		{
@@ -157,7 +158,7 @@ func TestBuildPlans(t *testing.T) {
 				"bun.lockb":    "binary content",
 				"package.json": "{}",
 			},
-			expectedCommands: []string{"bun install", "bun run build"},
+			expectedCommands: []string{"bun install --frozen-lockfile", "bun run build"},
 			expectedPM:       "bun",
 		},
 		{
@@ -167,7 +168,7 @@ func TestBuildPlans(t *testing.T) {
 				"bun.lock":     "binary content",
 				"package.json": "{}",
 			},
-			expectedCommands: []string{"bun install", "bun run build"},
+			expectedCommands: []string{"bun install --frozen-lockfile", "bun run build"},
 			expectedPM:       "bun",
 		},
 		{
@@ -0,0 +1,206 @@
+package detector_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSRAdapterStartCommands(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantRun      string
+		expectedMeta map[string]string
+	}{
+		{
+			name: "SvelteKit adapter-node",
+			files: map[string]string{
+				"svelte.config.js": `import adapter from '@sveltejs/adapter-node';
+export default { kit: { adapter: adapter() } };`,
+				"package.json": `{
+  "scripts": {"build": "vite build", "preview": "vite preview"},
+  "devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-node": "^5.0.0", "svelte": "^4.0.0"}
+}`,
+			},
+			wantRun: "node build/index.js",
+			expectedMeta: map[string]string{
+				"server":    "adapter-node",
+				"start_env": "HOST=127.0.0.1 PORT=$PORT",
+			},
+		},
+		{
+			name: "SvelteKit adapter-node with custom out and envPrefix",
+			files: map[string]string{
+				"svelte.config.js": `import adapter from '@sveltejs/adapter-node';
+export default { kit: { adapter: adapter({ out: 'dist', envPrefix: 'APP_' }) } };`,
+				"package.json": `{"devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-node": "^5.0.0", "svelte": "^4.0.0"}}`,
+			},
+			wantRun: "node dist/index.js",
+			expectedMeta: map[string]string{
+				"server":    "adapter-node",
+				"start_env": "APP_HOST=127.0.0.1 APP_PORT=$PORT",
+			},
+		},
+		{
+			name: "SvelteKit behind a custom Express server",
+			files: map[string]string{
+				"svelte.config.js": `import adapter from '@sveltejs/adapter-node';
+export default { kit: { adapter: adapter() } };`,
+				"server.js": `import { handler } from './build/handler.js';`,
+				"package.json": `{
+  "dependencies": {"express": "^4.18.0"},
+  "devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-node": "^5.0.0", "svelte": "^4.0.0"}
+}`,
+			},
+			wantRun: "node server.js",
+			expectedMeta: map[string]string{
+				"server": "custom",
+			},
+		},
+		{
+			name: "Remix Vite with remix-serve",
+			files: map[string]string{
+				"vite.config.ts": `import { vitePlugin as remix } from "@remix-run/dev";
+export default { plugins: [remix()] };`,
+				"app/root.tsx": "export default function App() {}",
+				"package.json": `{
+  "scripts": {"start": "remix-serve ./build/server/index.js"},
+  "dependencies": {"@remix-run/node": "^2.8.0", "@remix-run/react": "^2.8.0", "@remix-run/serve": "^2.8.0"},
+  "devDependencies": {"@remix-run/dev": "^2.8.0"}
+}`,
+			},
+			wantRun: "remix-serve build/server/index.js",
+			expectedMeta: map[string]string{
+				"adapter":   "node",
+				"server":    "remix-serve",
+				"start_env": "HOST=127.0.0.1",
+			},
+		},
+		{
+			name: "Remix classic compiler with serverBuildPath",
+			files: map[string]string{
+				"remix.config.js": `module.exports = { serverBuildPath: "build/server.js" };`,
+				"app/root.tsx":    "export default function App() {}",
+				"package.json":    `{"dependencies": {"@remix-run/node": "^1.19.0", "@remix-run/react": "^1.19.0", "@remix-run/serve": "^1.19.0"}}`,
+			},
+			wantRun: "remix-serve build/server.js",
+			expectedMeta: map[string]string{
+				"server": "remix-serve",
+			},
+		},
+		{
+			name: "Remix with Express server",
+			files: map[string]string{
+				"remix.config.js": `module.exports = {};`,
+				"app/root.tsx":    "export default function App() {}",
+				"server.mjs":      `import { createRequestHandler } from "@remix-run/express";`,
+				"package.json":    `{"dependencies": {"@remix-run/node": "^2.8.0", "@remix-run/react": "^2.8.0", "@remix-run/express": "^2.8.0", "express": "^4.18.0"}}`,
+			},
+			wantRun: "node server.mjs",
+			expectedMeta: map[string]string{
+				"server": "custom",
+			},
+		},
+		{
+			name: "Nuxt Nitro server",
+			files: map[string]string{
+				"nuxt.config.ts": `export default defineNuxtConfig({})`,
+				"app.vue":        "<template><div>App</div></template>",
+				"package.json":   `{"dependencies": {"nuxt": "^3.10.0"}}`,
+			},
+			wantRun: "node .output/server/index.mjs",
+			expectedMeta: map[string]string{
+				"server":    "nitro",
+				"start_env": "HOST=127.0.0.1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			if len(detection.RunPlan) == 0 || detection.RunPlan[0] != tt.wantRun {
+				t.Errorf("Expected run plan %q, got %v", tt.wantRun, detection.RunPlan)
+			}
+
+			for key, expectedValue := range tt.expectedMeta {
+				if actualValue := detection.Meta[key]; actualValue != expectedValue {
+					t.Errorf("Expected meta['%s'] = '%s', got '%s'", key, expectedValue, actualValue)
+				}
+			}
+		})
+	}
+}
+
+func TestSvelteKitWithoutNodeAdapterUsesStartScript(t *testing.T) {
+	projectPath := createTestProject(t, map[string]string{
+		"svelte.config.js": `import adapter from '@sveltejs/adapter-auto';
+export default { kit: { adapter: adapter() } };`,
+		"package.json": `{
+  "scripts": {"start": "node server/index.js"},
+  "devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-auto": "^3.0.0", "svelte": "^4.0.0"}
+}`,
+	})
+	detection := captureDetectFramework(t, projectPath)
+
+	if detection.Meta["server"] != "" {
+		t.Errorf("Expected no adapter server for adapter-auto, got %q", detection.Meta["server"])
+	}
+	if len(detection.RunPlan) == 0 || detection.RunPlan[0] == "node build/index.js" {
+		t.Errorf("Expected package start script, got %v", detection.RunPlan)
+	}
+}
+
+func TestSvelteKitAdapterStaticDeploysAsStatic(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           map[string]string
+		wantBuildOutput string
+	}{
+		{
+			name: "default output",
+			files: map[string]string{
+				"svelte.config.js": `import adapter from '@sveltejs/adapter-static';
+export default { kit: { adapter: adapter() } };`,
+				"package.json": `{
+  "scripts": {"build": "vite build", "preview": "vite preview"},
+  "devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-static": "^3.0.0", "svelte": "^4.0.0"}
+}`,
+			},
+			wantBuildOutput: "build/",
+		},
+		{
+			name: "custom pages directory, adapter only in svelte.config.js",
+			files: map[string]string{
+				"svelte.config.js": `import adapter from '@sveltejs/adapter-static';
+export default { kit: { adapter: adapter({ pages: './public', assets: './public', fallback: '404.html' }) } };`,
+				"package.json": `{
+  "scripts": {"build": "vite build", "preview": "vite preview"},
+  "devDependencies": {"@sveltejs/kit": "^2.0.0", "@sveltejs/adapter-auto": "^3.0.0", "svelte": "^4.0.0"}
+}`,
+			},
+			wantBuildOutput: "public/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			if detection.Meta["deployment_type"] != "static" {
+				t.Errorf("Expected deployment_type 'static', got %q", detection.Meta["deployment_type"])
+			}
+			if detection.Meta["build_output"] != tt.wantBuildOutput {
+				t.Errorf("Expected build_output %q, got %q", tt.wantBuildOutput, detection.Meta["build_output"])
+			}
+			for _, cmd := range detection.RunPlan {
+				if strings.Contains(cmd, "preview") {
+					t.Errorf("Expected no preview server for adapter-static, got %v", detection.RunPlan)
+				}
+			}
+		})
+	}
+}
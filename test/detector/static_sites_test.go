@@ -104,6 +104,55 @@ title: My Hugo Site`,
 	}
 }
 
+func TestStaticGeneratorsDeployAsStatic(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		hugoVersion string
+	}{
+		{
+			name: "Hugo with .hugo-version",
+			files: map[string]string{
+				"hugo.toml":       "title = 'Site'",
+				"content/post.md": "# Post",
+				".hugo-version":   "v0.140.1\n",
+			},
+			hugoVersion: "0.140.1",
+		},
+		{
+			name: "Hugo with netlify.toml",
+			files: map[string]string{
+				"hugo.toml":       "title = 'Site'",
+				"content/post.md": "# Post",
+				"netlify.toml":    "[build.environment]\n  HUGO_VERSION = \"0.125.4\"\n",
+			},
+			hugoVersion: "0.125.4",
+		},
+		{
+			name: "Jekyll",
+			files: map[string]string{
+				"_config.yml": "title: Site",
+				"Gemfile":     `gem "jekyll"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := createTestProject(t, tt.files)
+			detection := captureDetectFramework(t, projectPath)
+
+			if detection.Meta["deployment_type"] != "static" {
+				t.Errorf("Expected deployment_type 'static', got %q", detection.Meta["deployment_type"])
+			}
+
+			if detection.Meta["hugo_version"] != tt.hugoVersion {
+				t.Errorf("Expected hugo_version %q, got %q", tt.hugoVersion, detection.Meta["hugo_version"])
+			}
+		})
+	}
+}
+
 func TestEleventyDetection(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -174,3 +174,22 @@ func TestContextHandling(t *testing.T) {
 		t.Error("Expected context error, got nil")
 	}
 }
+
+func TestDigitalOceanLoadBalancerSupport(t *testing.T) {
+	client := digitalocean.NewClient("fake-token")
+	if !providers.SupportsLoadBalancers(client) {
+		t.Fatal("Expected DigitalOcean to support load balancers")
+	}
+
+	_, err := client.EnsureLoadBalancer(context.Background(), providers.LoadBalancerConfig{
+		Name:      "myapp-lb",
+		Region:    "nyc1",
+		ServerIDs: []string{"not-a-droplet"},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid droplet ID")
+	}
+	if providerErr, ok := err.(*providers.ProviderError); !ok || providerErr.Code != "invalid_load_balancer_config" {
+		t.Errorf("Expected invalid_load_balancer_config error, got %v", err)
+	}
+}
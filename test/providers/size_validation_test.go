@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"lightfold/pkg/providers"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSizeProvider returns a fixed size list per region
+type fakeSizeProvider struct {
+	sizes    map[string][]providers.Size
+	sizesErr error
+}
+
+func (f *fakeSizeProvider) Name() string                                  { return "fake" }
+func (f *fakeSizeProvider) DisplayName() string                           { return "Fake" }
+func (f *fakeSizeProvider) SupportsProvisioning() bool                    { return true }
+func (f *fakeSizeProvider) SupportsBYOS() bool                            { return false }
+func (f *fakeSizeProvider) SupportsSSH() bool                             { return true }
+func (f *fakeSizeProvider) ValidateCredentials(ctx context.Context) error { return nil }
+func (f *fakeSizeProvider) GetRegions(ctx context.Context) ([]providers.Region, error) {
+	return nil, nil
+}
+func (f *fakeSizeProvider) GetSizes(ctx context.Context, region string) ([]providers.Size, error) {
+	if f.sizesErr != nil {
+		return nil, f.sizesErr
+	}
+	return f.sizes[region], nil
+}
+func (f *fakeSizeProvider) GetImages(ctx context.Context) ([]providers.Image, error) {
+	return nil, nil
+}
+func (f *fakeSizeProvider) Provision(ctx context.Context, config providers.ProvisionConfig) (*providers.Server, error) {
+	return nil, nil
+}
+func (f *fakeSizeProvider) GetServer(ctx context.Context, serverID string) (*providers.Server, error) {
+	return nil, nil
+}
+func (f *fakeSizeProvider) Destroy(ctx context.Context, serverID string) error { return nil }
+func (f *fakeSizeProvider) WaitForActive(ctx context.Context, serverID string, timeout time.Duration) (*providers.Server, error) {
+	return nil, nil
+}
+func (f *fakeSizeProvider) UploadSSHKey(ctx context.Context, name, publicKey string) (*providers.SSHKey, error) {
+	return nil, nil
+}
+
+func TestValidateSizeForRegion(t *testing.T) {
+	fake := &fakeSizeProvider{
+		sizes: map[string][]providers.Size{
+			"nyc1": {{ID: "small"}, {ID: "medium"}},
+			"fra1": {{ID: "medium"}},
+		},
+	}
+	ctx := context.Background()
+
+	if err := providers.ValidateSizeForRegion(ctx, fake, "nyc1", "small"); err != nil {
+		t.Errorf("Expected small to be valid in nyc1, got %v", err)
+	}
+
+	err := providers.ValidateSizeForRegion(ctx, fake, "fra1", "small")
+	if err == nil {
+		t.Fatal("Expected error for size not offered in fra1")
+	}
+
+	var providerErr *providers.ProviderError
+	if !errors.As(err, &providerErr) {
+		t.Fatalf("Expected ProviderError, got %T", err)
+	}
+	if providerErr.Code != "invalid_size_for_region" {
+		t.Errorf("Expected code invalid_size_for_region, got %s", providerErr.Code)
+	}
+	if !strings.Contains(err.Error(), "medium") {
+		t.Errorf("Expected error to list valid sizes, got %q", err.Error())
+	}
+}
+
+func TestValidateSizeForRegionSkipsWhenUnknown(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSizeProvider{sizes: map[string][]providers.Size{"nyc1": {{ID: "small"}}}}
+
+	tests := []struct {
+		name     string
+		provider providers.Provider
+		region   string
+		size     string
+	}{
+		{"empty region", fake, "", "large"},
+		{"empty size", fake, "nyc1", ""},
+		{"no sizes listed", fake, "ams3", "large"},
+		{"lookup error", &fakeSizeProvider{sizesErr: errors.New("api down")}, "nyc1", "large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := providers.ValidateSizeForRegion(ctx, tt.provider, tt.region, tt.size); err != nil {
+				t.Errorf("Expected validation to be skipped, got %v", err)
+			}
+		})
+	}
+}
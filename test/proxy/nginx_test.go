@@ -2,7 +2,8 @@ package proxy_test
 
 import (
 	"lightfold/pkg/proxy"
-	_ "lightfold/pkg/proxy/nginx"
+	"lightfold/pkg/proxy/nginx"
+	"strings"
 	"testing"
 )
 
@@ -130,3 +131,410 @@ func TestNginxMultiAppConfiguration(t *testing.T) {
 		}
 	})
 }
+
+func TestNginxPathRouteRendering(t *testing.T) {
+	manager := nginx.NewManager(nil)
+
+	base := proxy.ProxyConfig{
+		Domain:  "example.com",
+		Port:    3000,
+		AppName: "web",
+		StaticPaths: []proxy.StaticPath{
+			{URLPrefix: "/media/", Dir: "/srv/web/shared/media"},
+			{URLPrefix: "/static/", Dir: "/srv/web/shared/static"},
+		},
+	}
+	withRoutes := base
+	withRoutes.PathRoutes = []proxy.PathRoute{
+		{PathPrefix: "/docs", Port: 3002, AppName: "docs"},
+		{PathPrefix: "/api", Port: 3001, AppName: "api"},
+	}
+
+	rootLocation := `  location / {
+    proxy_pass http://127.0.0.1:3000;`
+
+	t.Run("no routes renders only the root location", func(t *testing.T) {
+		conf := manager.GenerateConfig(base)
+		if strings.Count(conf, "location ") != 4 {
+			t.Errorf("Expected static, media, @app fallback and root locations only, got:\n%s", conf)
+		}
+		if !strings.Contains(conf, rootLocation) {
+			t.Errorf("Expected root location for the owning app, got:\n%s", conf)
+		}
+	})
+
+	t.Run("static paths are aliased only when declared", func(t *testing.T) {
+		conf := manager.GenerateConfig(base)
+		if !strings.Contains(conf, "location /media/  { alias /srv/web/shared/media/; try_files $uri @app; }") ||
+			!strings.Contains(conf, "location @app {") {
+			t.Errorf("Expected media alias, got:\n%s", conf)
+		}
+
+		noStatic := base
+		noStatic.StaticPaths = nil
+		conf = manager.GenerateConfig(noStatic)
+		if strings.Contains(conf, "alias") || strings.Count(conf, "location ") != 1 {
+			t.Errorf("Expected only the root location without static paths, got:\n%s", conf)
+		}
+	})
+
+	t.Run("routes render one location per prefix", func(t *testing.T) {
+		conf := manager.GenerateConfig(withRoutes)
+
+		for _, expected := range []string{
+			"location = /api { return 301 /api/$is_args$args; }",
+			"location /api/ {\n    proxy_pass http://127.0.0.1:3001/;",
+			"proxy_set_header X-Forwarded-Prefix /api;",
+			"location = /docs { return 301 /docs/$is_args$args; }",
+			"location /docs/ {\n    proxy_pass http://127.0.0.1:3002/;",
+			rootLocation,
+		} {
+			if !strings.Contains(conf, expected) {
+				t.Errorf("Expected config to contain %q, got:\n%s", expected, conf)
+			}
+		}
+
+		apiIdx := strings.Index(conf, "location /api/")
+		docsIdx := strings.Index(conf, "location /docs/")
+		rootIdx := strings.Index(conf, "location / {")
+		if !(apiIdx < docsIdx && docsIdx < rootIdx) {
+			t.Errorf("Expected routes in prefix order before the root location, got:\n%s", conf)
+		}
+	})
+
+	t.Run("routes are added to the HTTPS server block", func(t *testing.T) {
+		sslConfig := withRoutes
+		sslConfig.SSLEnabled = true
+		sslConfig.SSLCertPath = "/etc/letsencrypt/live/example.com/fullchain.pem"
+		sslConfig.SSLKeyPath = "/etc/letsencrypt/live/example.com/privkey.pem"
+
+		conf := manager.GenerateConfig(sslConfig)
+		httpsIdx := strings.Index(conf, "listen 443")
+		apiIdx := strings.Index(conf, "location /api/")
+		if httpsIdx == -1 || apiIdx < httpsIdx {
+			t.Errorf("Expected /api route inside the HTTPS server block, got:\n%s", conf)
+		}
+		if strings.Count(conf, "location /api/") != 1 {
+			t.Errorf("Expected a single /api location, got:\n%s", conf)
+		}
+	})
+
+	t.Run("removing a route leaves the owner's config as before", func(t *testing.T) {
+		removed := base
+		removed.PathRoutes = []proxy.PathRoute{{PathPrefix: "/docs", Port: 3002, AppName: "docs"}}
+		conf := manager.GenerateConfig(removed)
+		if strings.Contains(conf, "/api") {
+			t.Errorf("Expected /api route to be gone, got:\n%s", conf)
+		}
+
+		removed.PathRoutes = nil
+		if manager.GenerateConfig(removed) != manager.GenerateConfig(base) {
+			t.Error("Expected config without routes to match the original owner config")
+		}
+	})
+}
+
+func TestNginxListensOnIPv6(t *testing.T) {
+	manager := nginx.NewManager(nil)
+	base := proxy.ProxyConfig{Domain: "example.com", Port: 3000, AppName: "web"}
+
+	conf := manager.GenerateConfig(base)
+	if !strings.Contains(conf, "listen [::]:80;") {
+		t.Errorf("Expected an IPv6 listener on port 80, got:\n%s", conf)
+	}
+
+	sslConfig := base
+	sslConfig.SSLEnabled = true
+	sslConfig.SSLCertPath = "/etc/letsencrypt/live/example.com/fullchain.pem"
+	sslConfig.SSLKeyPath = "/etc/letsencrypt/live/example.com/privkey.pem"
+	conf = manager.GenerateConfig(sslConfig)
+	for _, listen := range []string{"listen [::]:80;", "listen [::]:443 ssl http2;"} {
+		if !strings.Contains(conf, listen) {
+			t.Errorf("Expected %q in the SSL config, got:\n%s", listen, conf)
+		}
+	}
+}
+
+func TestNginxWWWRedirect(t *testing.T) {
+	manager := nginx.NewManager(nil)
+
+	tests := []struct {
+		name   string
+		domain string
+		from   string
+	}{
+		{"www to apex", "example.com", "www.example.com"},
+		{"apex to www", "www.example.com", "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := proxy.ProxyConfig{Domain: tt.domain, Port: 3000, AppName: "web", RedirectFrom: tt.from}
+
+			conf := manager.GenerateConfig(base)
+			for _, expected := range []string{
+				"server_name " + tt.from + ";\n  return 301 $scheme://" + tt.domain + "$request_uri;",
+				"server_name " + tt.domain + ";",
+			} {
+				if !strings.Contains(conf, expected) {
+					t.Errorf("Expected HTTP config to contain %q, got:\n%s", expected, conf)
+				}
+			}
+			if strings.Count(conf, "proxy_pass") != 1 || strings.Index(conf, "return 301") > strings.Index(conf, "proxy_pass") {
+				t.Errorf("Expected the redirect block ahead of a single proxied server, got:\n%s", conf)
+			}
+
+			sslConfig := base
+			sslConfig.SSLEnabled = true
+			sslConfig.SSLCertPath = "/etc/letsencrypt/live/" + tt.domain + "/fullchain.pem"
+			sslConfig.SSLKeyPath = "/etc/letsencrypt/live/" + tt.domain + "/privkey.pem"
+			conf = manager.GenerateConfig(sslConfig)
+			for _, expected := range []string{
+				"server_name " + tt.domain + " " + tt.from + ";\n  return 301 https://$server_name$request_uri;",
+				"server_name " + tt.from + ";\n\n  ssl_certificate " + sslConfig.SSLCertPath + ";",
+				"return 301 https://" + tt.domain + "$request_uri;",
+			} {
+				if !strings.Contains(conf, expected) {
+					t.Errorf("Expected SSL config to contain %q, got:\n%s", expected, conf)
+				}
+			}
+			if strings.Count(conf, "listen 443 ssl http2;") != 2 || strings.Count(conf, "proxy_pass") != 1 {
+				t.Errorf("Expected an HTTPS redirect server and one proxied HTTPS server, got:\n%s", conf)
+			}
+		})
+	}
+
+	t.Run("no redirect leaves the config unchanged", func(t *testing.T) {
+		conf := manager.GenerateConfig(proxy.ProxyConfig{Domain: "example.com", Port: 3000, AppName: "web"})
+		if strings.Contains(conf, "Redirect") || strings.Count(conf, "server {") != 1 {
+			t.Errorf("Expected a single server block, got:\n%s", conf)
+		}
+	})
+}
+
+func TestNginxHTTPProtocols(t *testing.T) {
+	manager := nginx.NewManager(nil)
+
+	tests := []struct {
+		name         string
+		disableHTTP2 bool
+		http3        bool
+		want         []string
+		wantNot      []string
+	}{
+		{
+			name:    "http2 by default",
+			want:    []string{"listen 443 ssl http2;", "listen [::]:443 ssl http2;"},
+			wantNot: []string{"quic", "Alt-Svc"},
+		},
+		{
+			name:         "http2 off",
+			disableHTTP2: true,
+			want:         []string{"listen 443 ssl;", "listen [::]:443 ssl;"},
+			wantNot:      []string{"http2", "quic", "Alt-Svc"},
+		},
+		{
+			name:  "http2 and http3",
+			http3: true,
+			want:  []string{"listen 443 ssl http2;", "listen 443 quic;", "listen [::]:443 quic;", `add_header Alt-Svc 'h3=":443"; ma=86400' always;`},
+		},
+		{
+			name:         "http3 without http2",
+			disableHTTP2: true,
+			http3:        true,
+			want:         []string{"listen 443 ssl;", "listen 443 quic;", "Alt-Svc"},
+			wantNot:      []string{"http2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := manager.GenerateConfig(proxy.ProxyConfig{
+				Domain:       "example.com",
+				Port:         3000,
+				AppName:      "web",
+				SSLEnabled:   true,
+				SSLCertPath:  "/etc/letsencrypt/live/example.com/fullchain.pem",
+				SSLKeyPath:   "/etc/letsencrypt/live/example.com/privkey.pem",
+				DisableHTTP2: tt.disableHTTP2,
+				HTTP3:        tt.http3,
+			})
+			for _, want := range tt.want {
+				if !strings.Contains(conf, want) {
+					t.Errorf("Expected %q in the SSL config, got:\n%s", want, conf)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(conf, unwanted) {
+					t.Errorf("Did not expect %q in the SSL config, got:\n%s", unwanted, conf)
+				}
+			}
+		})
+	}
+
+	t.Run("http only config is unaffected", func(t *testing.T) {
+		conf := manager.GenerateConfig(proxy.ProxyConfig{Domain: "example.com", Port: 3000, AppName: "web", HTTP3: true})
+		if strings.Contains(conf, "443") || strings.Contains(conf, "Alt-Svc") {
+			t.Errorf("HTTP/3 needs a certificate, got:\n%s", conf)
+		}
+	})
+
+	t.Run("quic is listened on once", func(t *testing.T) {
+		// nginx refuses a second quic listener on the same port, so the www
+		// redirect server only gets TLS over TCP
+		conf := manager.GenerateConfig(proxy.ProxyConfig{
+			Domain:       "example.com",
+			RedirectFrom: "www.example.com",
+			Port:         3000,
+			AppName:      "web",
+			SSLEnabled:   true,
+			SSLCertPath:  "/etc/letsencrypt/live/example.com/fullchain.pem",
+			SSLKeyPath:   "/etc/letsencrypt/live/example.com/privkey.pem",
+			HTTP3:        true,
+		})
+		if strings.Count(conf, "listen 443 quic;") != 1 || strings.Count(conf, "listen 443 ssl http2;") != 2 {
+			t.Errorf("Expected one quic listener and two TLS servers, got:\n%s", conf)
+		}
+	})
+
+	t.Run("reuseport on the addresses the site holds", func(t *testing.T) {
+		conf := manager.GenerateConfig(proxy.ProxyConfig{
+			Domain:        "example.com",
+			RedirectFrom:  "www.example.com",
+			Port:          3000,
+			AppName:       "web",
+			SSLEnabled:    true,
+			SSLCertPath:   "/etc/letsencrypt/live/example.com/fullchain.pem",
+			SSLKeyPath:    "/etc/letsencrypt/live/example.com/privkey.pem",
+			HTTP3:         true,
+			QUICReuseport: []string{"[::]:443"},
+		})
+		if !strings.Contains(conf, "listen 443 quic;") || strings.Count(conf, "listen [::]:443 quic reuseport;") != 1 || strings.Count(conf, "reuseport") != 1 {
+			t.Errorf("Expected reuseport on the IPv6 quic listener only, got:\n%s", conf)
+		}
+	})
+}
+
+func TestNginxReuseportHolders(t *testing.T) {
+	output := `/etc/nginx/sites-enabled/blog.conf:  listen 443 quic reuseport;
+/etc/nginx/sites-enabled/blog.conf:  listen [::]:443 quic reuseport;
+/etc/nginx/conf.d/shop.conf:    listen *:443 reuseport quic;
+/etc/nginx/sites-enabled/notes.conf:  # listen 8443 quic reuseport;
+`
+	want := map[string]string{
+		"443":      "/etc/nginx/sites-enabled/blog.conf",
+		"[::]:443": "/etc/nginx/sites-enabled/blog.conf",
+	}
+	got := nginx.ReuseportHolders(output)
+	if len(got) != len(want) {
+		t.Fatalf("ReuseportHolders() = %v, want %v", got, want)
+	}
+	for address, path := range want {
+		if got[address] != path {
+			t.Errorf("ReuseportHolders()[%q] = %q, want %q", address, got[address], path)
+		}
+	}
+}
+
+func TestNginxCertificatePaths(t *testing.T) {
+	site := `server {
+    server_name example.com;
+    listen 443 ssl; # managed by Certbot
+    ssl_certificate /etc/letsencrypt/live/example.com/fullchain.pem; # managed by Certbot
+    ssl_certificate_key /etc/letsencrypt/live/example.com/privkey.pem; # managed by Certbot
+    include /etc/letsencrypt/options-ssl-nginx.conf; # managed by Certbot
+}`
+	cert, key := nginx.CertificatePaths(site)
+	if cert != "/etc/letsencrypt/live/example.com/fullchain.pem" || key != "/etc/letsencrypt/live/example.com/privkey.pem" {
+		t.Errorf("CertificatePaths() = %q, %q", cert, key)
+	}
+	if cert, key := nginx.CertificatePaths("server {\n  listen 80;\n}"); cert != "" || key != "" {
+		t.Errorf("CertificatePaths() of an HTTP site = %q, %q", cert, key)
+	}
+}
+
+func TestParseNginxBuildInfo(t *testing.T) {
+	const ubuntuArgs = "configure arguments: --with-cc-opt='-g -O2' --prefix=/usr/share/nginx --with-http_ssl_module --with-http_v2_module --with-http_realip_module"
+
+	tests := []struct {
+		name   string
+		output string
+		want   nginx.BuildInfo
+	}{
+		{
+			name:   "ubuntu 22.04",
+			output: "nginx version: nginx/1.18.0 (Ubuntu)\nbuilt with OpenSSL 3.0.2 15 Mar 2022\nTLS SNI support enabled\n" + ubuntuArgs + "\n",
+			want:   nginx.BuildInfo{Version: "1.18.0", HTTP2: true},
+		},
+		{
+			name:   "ubuntu 24.04",
+			output: "nginx version: nginx/1.24.0 (Ubuntu)\n" + ubuntuArgs + "\n",
+			want:   nginx.BuildInfo{Version: "1.24.0", HTTP2: true},
+		},
+		{
+			name:   "nginx.org mainline",
+			output: "nginx version: nginx/1.27.1\nbuilt by gcc 12.2.0\nconfigure arguments: --prefix=/etc/nginx --with-http_v2_module --with-http_v3_module --with-stream\n",
+			want:   nginx.BuildInfo{Version: "1.27.1", HTTP2: true, HTTP3: true},
+		},
+		{
+			name:   "quiche patched build",
+			output: "nginx version: nginx/1.16.1\nconfigure arguments: --with-http_v2_module --with-http_v3_module --with-quiche=../quiche\n",
+			want:   nginx.BuildInfo{Version: "1.16.1", HTTP2: true},
+		},
+		{
+			name:   "openresty",
+			output: "nginx version: openresty/1.25.3.1\nconfigure arguments: --with-http_v3_module\n",
+			want:   nginx.BuildInfo{Version: "1.25.3.1", HTTP3: true},
+		},
+		{
+			name:   "http2 module prefix only",
+			output: "nginx version: nginx/1.24.0\nconfigure arguments: --with-http_v2_module_custom\n",
+			want:   nginx.BuildInfo{Version: "1.24.0"},
+		},
+		{name: "not installed", output: "bash: nginx: command not found\n"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nginx.ParseBuildInfo(tt.output); got != tt.want {
+				t.Errorf("ParseBuildInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNginxBuildInfoCheckProtocols(t *testing.T) {
+	ubuntu := nginx.BuildInfo{Version: "1.24.0", HTTP2: true}
+	mainline := nginx.BuildInfo{Version: "1.27.1", HTTP2: true, HTTP3: true}
+	noHTTP2 := nginx.BuildInfo{Version: "1.24.0"}
+
+	tests := []struct {
+		name    string
+		info    nginx.BuildInfo
+		config  proxy.ProxyConfig
+		wantErr string
+	}{
+		{name: "http2 on ubuntu", info: ubuntu},
+		{name: "http3 on mainline", info: mainline, config: proxy.ProxyConfig{HTTP3: true}},
+		{name: "http3 on ubuntu", info: ubuntu, config: proxy.ProxyConfig{HTTP3: true}, wantErr: "lightfold config set --target web domain.http3=false"},
+		{name: "http2 without the module", info: noHTTP2, wantErr: "lightfold config set --target web domain.http2=false"},
+		{name: "http2 off without the module", info: noHTTP2, config: proxy.ProxyConfig{DisableHTTP2: true}},
+		{name: "unknown build", config: proxy.ProxyConfig{HTTP3: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.info.CheckProtocols(tt.config, "web")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckProtocols() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckProtocols() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
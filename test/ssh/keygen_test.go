@@ -377,3 +377,43 @@ func TestCleanupUnusedKeys(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveAuthorizedKey(t *testing.T) {
+	tempDir := t.TempDir()
+	originalHome := os.Getenv("HOME")
+	os.Setenv("HOME", tempDir)
+	defer os.Setenv("HOME", originalHome)
+
+	keyPair, err := ssh.GenerateKeyPair("teammate_ed25519")
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	fromPath, err := ssh.ResolveAuthorizedKey(keyPair.PublicKeyPath)
+	if err != nil {
+		t.Fatalf("Failed to resolve key from path: %v", err)
+	}
+	if fromPath != keyPair.PublicKey {
+		t.Errorf("Expected %q, got %q", keyPair.PublicKey, fromPath)
+	}
+
+	literal, err := ssh.ResolveAuthorizedKey(keyPair.PublicKey + " alice@laptop")
+	if err != nil {
+		t.Fatalf("Failed to resolve literal key: %v", err)
+	}
+	if !ssh.SameAuthorizedKey(literal, fromPath) {
+		t.Error("Expected literal key with comment to match key loaded from file")
+	}
+
+	if _, err := ssh.ResolveAuthorizedKey(filepath.Join(tempDir, "missing.pub")); err == nil {
+		t.Error("Expected error for missing key file")
+	}
+
+	keys, err := ssh.ResolveAuthorizedKeys([]string{keyPair.PublicKeyPath, keyPair.PublicKey})
+	if err != nil {
+		t.Fatalf("ResolveAuthorizedKeys failed: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected duplicate keys to collapse, got %d", len(keys))
+	}
+}
@@ -1,8 +1,10 @@
 package state
 
 import (
+	"fmt"
 	"lightfold/pkg/state"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -304,3 +306,79 @@ func TestPortExhaustion(t *testing.T) {
 
 	t.Logf("Port exhaustion correctly detected (total ports: %d)", totalPorts)
 }
+
+func TestConcurrentPortAllocation(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	serverIP := "192.168.1.200"
+	const workers = 20
+
+	ports := make(chan int, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := state.AllocatePort(serverIP)
+			if err != nil {
+				t.Errorf("AllocatePort failed: %v", err)
+				return
+			}
+			ports <- port
+		}()
+	}
+	wg.Wait()
+	close(ports)
+
+	seen := make(map[int]bool)
+	for port := range ports {
+		if seen[port] {
+			t.Errorf("Port %d was allocated twice", port)
+		}
+		seen[port] = true
+	}
+	if len(seen) != workers {
+		t.Errorf("Expected %d unique ports, got %d", workers, len(seen))
+	}
+}
+
+func TestAllocatePortWithDeclinedPorts(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	serverIP := "192.168.1.201"
+
+	// Another machine already reserved the first two ports on the server
+	reservedElsewhere := map[int]bool{state.PortRangeStart: true, state.PortRangeStart + 1: true}
+	port, err := state.AllocatePortWith(serverIP, func(port int) (bool, error) {
+		return !reservedElsewhere[port], nil
+	})
+	if err != nil {
+		t.Fatalf("AllocatePortWith failed: %v", err)
+	}
+	if port != state.PortRangeStart+2 {
+		t.Errorf("Expected port %d, got %d", state.PortRangeStart+2, port)
+	}
+
+	next, err := state.PeekPort(serverIP)
+	if err != nil {
+		t.Fatalf("PeekPort failed: %v", err)
+	}
+	if next != port+1 {
+		t.Errorf("Expected next port %d, got %d", port+1, next)
+	}
+
+	if _, err := state.AllocatePortWith(serverIP, func(port int) (bool, error) {
+		return false, fmt.Errorf("server unreachable")
+	}); err == nil {
+		t.Error("Expected the confirm error to fail the allocation")
+	}
+	if after, _ := state.PeekPort(serverIP); after != next {
+		t.Errorf("A failed allocation moved the next port from %d to %d", next, after)
+	}
+}
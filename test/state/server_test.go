@@ -313,3 +313,141 @@ func TestUpdateAppDeployment(t *testing.T) {
 		t.Errorf("Expected LastDeploy to be updated to %v, got %v", newTime, updatedApp.LastDeploy)
 	}
 }
+
+func TestServerAuthorizedKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	serverIP := "192.168.1.110"
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHteammate0000000000 alice@laptop"
+
+	if err := state.AddAuthorizedKey(serverIP, key); err != nil {
+		t.Fatalf("AddAuthorizedKey failed: %v", err)
+	}
+	if err := state.AddAuthorizedKey(serverIP, key); err != nil {
+		t.Fatalf("AddAuthorizedKey (duplicate) failed: %v", err)
+	}
+
+	s, err := state.GetServerState(serverIP)
+	if err != nil {
+		t.Fatalf("GetServerState failed: %v", err)
+	}
+	if len(s.AuthorizedKeys) != 1 || s.AuthorizedKeys[0] != key {
+		t.Fatalf("Expected exactly one recorded key, got %v", s.AuthorizedKeys)
+	}
+
+	if err := state.RemoveAuthorizedKey(serverIP, key); err != nil {
+		t.Fatalf("RemoveAuthorizedKey failed: %v", err)
+	}
+
+	s, _ = state.GetServerState(serverIP)
+	if len(s.AuthorizedKeys) != 0 {
+		t.Errorf("Expected no recorded keys after removal, got %v", s.AuthorizedKeys)
+	}
+}
+
+func TestServerPathRoutes(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	serverIP := "192.168.1.120"
+
+	for _, app := range []string{"web", "api", "docs"} {
+		if err := state.RegisterApp(serverIP, state.DeployedApp{TargetName: app, AppName: app}); err != nil {
+			t.Fatalf("RegisterApp failed: %v", err)
+		}
+	}
+
+	routes := []state.PathRoute{
+		{Domain: "example.com", PathPrefix: "/docs", TargetName: "docs", Port: 3002},
+		{Domain: "example.com", PathPrefix: "/api", TargetName: "api", Port: 3001},
+		{Domain: "other.com", PathPrefix: "/api", TargetName: "api", Port: 3001},
+	}
+	for _, route := range routes {
+		if err := state.RegisterPathRoute(serverIP, route); err != nil {
+			t.Fatalf("RegisterPathRoute failed: %v", err)
+		}
+	}
+
+	t.Run("routes are listed per domain in prefix order", func(t *testing.T) {
+		got, err := state.GetPathRoutesForDomain(serverIP, "example.com")
+		if err != nil {
+			t.Fatalf("GetPathRoutesForDomain failed: %v", err)
+		}
+		if len(got) != 2 || got[0].PathPrefix != "/api" || got[1].PathPrefix != "/docs" {
+			t.Errorf("Expected /api then /docs, got %+v", got)
+		}
+	})
+
+	t.Run("prefix already routed to another target is rejected", func(t *testing.T) {
+		err := state.RegisterPathRoute(serverIP, state.PathRoute{Domain: "example.com", PathPrefix: "/api", TargetName: "docs", Port: 3002})
+		if err == nil {
+			t.Error("Expected conflict error")
+		}
+	})
+
+	t.Run("re-registering the same target updates the port", func(t *testing.T) {
+		if err := state.RegisterPathRoute(serverIP, state.PathRoute{Domain: "example.com", PathPrefix: "/api", TargetName: "api", Port: 3005}); err != nil {
+			t.Fatalf("RegisterPathRoute failed: %v", err)
+		}
+		got, _ := state.GetPathRoutesForDomain(serverIP, "example.com")
+		if len(got) != 2 || got[0].Port != 3005 {
+			t.Errorf("Expected updated port 3005, got %+v", got)
+		}
+	})
+
+	t.Run("unregistering one route keeps the others", func(t *testing.T) {
+		if err := state.UnregisterPathRoute(serverIP, "example.com", "/api", "api"); err != nil {
+			t.Fatalf("UnregisterPathRoute failed: %v", err)
+		}
+		got, _ := state.GetPathRoutesForDomain(serverIP, "example.com")
+		if len(got) != 1 || got[0].TargetName != "docs" {
+			t.Errorf("Expected only the docs route, got %+v", got)
+		}
+		other, _ := state.GetPathRoutesForDomain(serverIP, "other.com")
+		if len(other) != 1 {
+			t.Errorf("Expected other.com route to remain, got %+v", other)
+		}
+	})
+
+	t.Run("unregistering an app drops its routes", func(t *testing.T) {
+		if err := state.UnregisterApp(serverIP, "api"); err != nil {
+			t.Fatalf("UnregisterApp failed: %v", err)
+		}
+		other, _ := state.GetPathRoutesForDomain(serverIP, "other.com")
+		if len(other) != 0 {
+			t.Errorf("Expected api routes to be dropped, got %+v", other)
+		}
+	})
+}
+
+func TestUpdateServerResources(t *testing.T) {
+	tmpDir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	serverIP := "192.168.1.120"
+	if err := state.RegisterApp(serverIP, state.DeployedApp{TargetName: "api", AppName: "api", Port: 3000}); err != nil {
+		t.Fatalf("RegisterApp failed: %v", err)
+	}
+
+	if err := state.UpdateServerResources(serverIP, 4, 7951); err != nil {
+		t.Fatalf("UpdateServerResources failed: %v", err)
+	}
+
+	s, err := state.GetServerState(serverIP)
+	if err != nil {
+		t.Fatalf("GetServerState failed: %v", err)
+	}
+	if s.CPUCount != 4 || s.MemoryMB != 7951 {
+		t.Errorf("resources = %d CPUs, %d MB, want 4 CPUs, 7951 MB", s.CPUCount, s.MemoryMB)
+	}
+	if len(s.DeployedApps) != 1 {
+		t.Errorf("Expected deployed apps to be kept, got %v", s.DeployedApps)
+	}
+}